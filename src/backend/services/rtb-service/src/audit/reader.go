@@ -0,0 +1,155 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxLineBytes bounds a single audit line when reading
+const maxLineBytes = 1 << 20
+
+// Files returns the audit files written for path, oldest rotated file first and path itself last
+func Files(path string) ([]string, error) {
+	matches, err := filepath.Glob(path + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	type rotated struct {
+		name  string
+		index int
+	}
+	var files []rotated
+	for _, name := range matches {
+		suffix := strings.TrimSuffix(strings.TrimPrefix(name, path+"."), ".gz")
+		if index, err := strconv.Atoi(suffix); err == nil && index > 0 {
+			files = append(files, rotated{name: name, index: index})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].index > files[j].index
+	})
+
+	names := make([]string, 0, len(files)+1)
+	for _, file := range files {
+		names = append(names, file.name)
+	}
+	if _, err := os.Stat(path); err == nil {
+		names = append(names, path)
+	}
+	return names, nil
+}
+
+// Reader iterates the records of a set of audit files in order, decompressing gzipped files.
+// Call Next until it returns io.EOF.
+type Reader struct {
+	files   []string
+	current io.ReadCloser
+	gz      *gzip.Reader
+	scanner *bufio.Scanner
+	file    string
+	line    int
+}
+
+// NewReader reads every audit file written for path, oldest first. The active file may end in a
+// partly written line while the service is running, so reconciliation should prefer rotated files.
+func NewReader(path string) (*Reader, error) {
+	files, err := Files(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewFileReader(files...), nil
+}
+
+// NewFileReader reads the given audit files in order
+func NewFileReader(files ...string) *Reader {
+	return &Reader{files: files}
+}
+
+// Next returns the next record, or io.EOF after the last file
+func (r *Reader) Next() (Record, error) {
+	for {
+		if r.scanner == nil {
+			if len(r.files) == 0 {
+				return Record{}, io.EOF
+			}
+			if err := r.openNext(); err != nil {
+				return Record{}, err
+			}
+		}
+
+		if r.scanner.Scan() {
+			r.line++
+			line := r.scanner.Bytes()
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			var record Record
+			if err := json.Unmarshal(line, &record); err != nil {
+				return Record{}, fmt.Errorf("%s line %d: %w", r.file, r.line, err)
+			}
+			return record, nil
+		}
+		if err := r.scanner.Err(); err != nil {
+			return Record{}, fmt.Errorf("%s: %w", r.file, err)
+		}
+		if err := r.closeCurrent(); err != nil {
+			return Record{}, err
+		}
+	}
+}
+
+// Close closes the file being read
+func (r *Reader) Close() error {
+	r.files = nil
+	return r.closeCurrent()
+}
+
+// openNext opens the next file in the list
+func (r *Reader) openNext() error {
+	r.file, r.files = r.files[0], r.files[1:]
+	r.line = 0
+
+	file, err := os.Open(r.file)
+	if err != nil {
+		return err
+	}
+	r.current = file
+
+	var source io.Reader = file
+	if strings.HasSuffix(r.file, ".gz") {
+		r.gz, err = gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("%s: %w", r.file, err)
+		}
+		source = r.gz
+	}
+	r.scanner = bufio.NewScanner(source)
+	r.scanner.Buffer(make([]byte, 0, 64*1024), maxLineBytes)
+	return nil
+}
+
+// closeCurrent closes the open file, if any
+func (r *Reader) closeCurrent() error {
+	r.scanner = nil
+	if r.gz != nil {
+		r.gz.Close()
+		r.gz = nil
+	}
+	if r.current == nil {
+		return nil
+	}
+	err := r.current.Close()
+	r.current = nil
+	return err
+}
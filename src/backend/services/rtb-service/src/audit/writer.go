@@ -0,0 +1,305 @@
+// Package audit writes and reads the append-only JSONL log of winning bids used for invoice reconciliation
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// Reasons a record is dropped instead of written
+const (
+	dropReasonBufferFull = "buffer_full"
+	dropReasonWriteError = "write_error"
+)
+
+// ErrClosed is returned when writing to a closed writer
+var ErrClosed = errors.New("audit writer closed")
+
+var recordsDropped = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rtb_audit_records_dropped_total",
+		Help: "Total number of winning-bid audit records not written, by reason",
+	},
+	[]string{"reason"},
+)
+
+func init() {
+	prometheus.MustRegister(recordsDropped)
+}
+
+// Record is one winning bid as written to the audit log. BidPrice is in the partner's
+// PricingModel and ClearingPrice is a cost per lead, so they differ when a fixed-price deal set
+// the price or the partner bid a revenue share.
+type Record struct {
+	Timestamp     time.Time `json:"timestamp"`
+	RequestID     string    `json:"request_id"`
+	LeadID        string    `json:"lead_id"`
+	PartnerID     string    `json:"partner_id"`
+	BidID         string    `json:"bid_id"`
+	DealID        string    `json:"deal_id,omitempty"`
+	Vertical      string    `json:"vertical"`
+	BidPrice      float64   `json:"bid_price"`
+	PricingModel  string    `json:"pricing_model,omitempty"`
+	ClearingPrice float64   `json:"clearing_price"`
+	// AuctionSeq and AuctionULID order the auction when auction sequence numbers are enabled; they
+	// are omitted otherwise so records written before them still verify
+	AuctionSeq  int64  `json:"auction_seq,omitempty"`
+	AuctionULID string `json:"auction_ulid,omitempty"`
+	Signature   string `json:"signature,omitempty"`
+}
+
+// Sign returns the hex HMAC-SHA256 of the record's JSON encoding without its signature
+func (r Record) Sign(key []byte) string {
+	r.Signature = ""
+	payload, _ := json.Marshal(r)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether the record carries a valid signature for key
+func (r Record) Verify(key []byte) bool {
+	signature, err := hex.DecodeString(r.Signature)
+	if err != nil || r.Signature == "" {
+		return false
+	}
+	expected, _ := hex.DecodeString(r.Sign(key))
+	return hmac.Equal(signature, expected)
+}
+
+// Writer appends records to the audit file from a background goroutine, rotating the file at
+// the configured size. Records are dropped rather than blocking auctions when the buffer is full.
+type Writer struct {
+	config     *config.AuditConfig
+	signingKey []byte
+	records    chan Record
+	done       chan struct{}
+	mutex      sync.RWMutex
+	closed     bool
+	file       *os.File
+	buffer     *bufio.Writer
+	size       int64
+	err        error
+}
+
+// NewWriter opens the audit file for appending and starts the background writer, or returns nil
+// when auditing is disabled
+func NewWriter(cfg *config.AuditConfig) (*Writer, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+
+	w := &Writer{
+		config:  cfg,
+		records: make(chan Record, cfg.BufferSize),
+		done:    make(chan struct{}),
+	}
+	if cfg.SigningKey != "" {
+		key, err := config.ResolveSecret(cfg.SigningKey)
+		if err != nil {
+			return nil, fmt.Errorf("resolving audit signing key: %w", err)
+		}
+		w.signingKey = []byte(key)
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Write queues a record, signing it when a signing key is configured
+func (w *Writer) Write(record Record) error {
+	if w == nil {
+		return nil
+	}
+	if w.signingKey != nil {
+		record.Signature = record.Sign(w.signingKey)
+	}
+
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if w.closed {
+		return ErrClosed
+	}
+	select {
+	case w.records <- record:
+	default:
+		recordsDropped.WithLabelValues(dropReasonBufferFull).Inc()
+	}
+	return nil
+}
+
+// Close stops accepting records, writes those already queued, and closes the file
+func (w *Writer) Close() error {
+	if w == nil {
+		return nil
+	}
+
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.records)
+	w.mutex.Unlock()
+
+	<-w.done
+	if err := w.buffer.Flush(); err != nil && w.err == nil {
+		w.err = err
+	}
+	if err := w.file.Close(); err != nil && w.err == nil {
+		w.err = err
+	}
+	return w.err
+}
+
+// run writes queued records, flushing whenever the queue drains
+func (w *Writer) run() {
+	defer close(w.done)
+	for record := range w.records {
+		if err := w.append(record); err != nil {
+			recordsDropped.WithLabelValues(dropReasonWriteError).Inc()
+			w.err = err
+			continue
+		}
+		if len(w.records) == 0 {
+			if err := w.buffer.Flush(); err != nil {
+				w.err = err
+			}
+		}
+	}
+}
+
+// append writes one record as a JSON line, rotating first when it would exceed the size limit
+func (w *Writer) append(record Record) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if w.size > 0 && w.size+int64(len(line)) > w.config.MaxSizeBytes {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.buffer.Write(line)
+	w.size += int64(n)
+	return err
+}
+
+// open opens the audit file for appending and records its current size
+func (w *Writer) open() error {
+	file, err := os.OpenFile(w.config.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return fmt.Errorf("opening audit file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("opening audit file: %w", err)
+	}
+	w.file, w.size = file, info.Size()
+	if w.buffer == nil {
+		w.buffer = bufio.NewWriter(file)
+	} else {
+		w.buffer.Reset(file)
+	}
+	return nil
+}
+
+// rotate closes the current file, shifts it to path.1, and reopens path. A failed shift is kept
+// as the writer error and appending continues to the current file rather than dropping records.
+func (w *Writer) rotate() error {
+	if err := w.buffer.Flush(); err != nil {
+		return err
+	}
+	if err := w.file.Close(); err != nil {
+		return err
+	}
+	if err := w.shiftFiles(); err != nil {
+		w.err = err
+	}
+	return w.open()
+}
+
+// shiftFiles moves the closed audit file to path.1, compressing it when configured. Older files
+// move up one index and the file past MaxFiles is removed.
+func (w *Writer) shiftFiles() error {
+	for i := w.config.MaxFiles; i >= 1; i-- {
+		for _, name := range []string{rotatedName(w.config.Path, i, false), rotatedName(w.config.Path, i, true)} {
+			if i == w.config.MaxFiles {
+				os.Remove(name)
+				continue
+			}
+			if _, err := os.Stat(name); err == nil {
+				if err := os.Rename(name, rotatedName(w.config.Path, i+1, strings.HasSuffix(name, ".gz"))); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	rotated := rotatedName(w.config.Path, 1, false)
+	if err := os.Rename(w.config.Path, rotated); err != nil {
+		return err
+	}
+	if w.config.Compress {
+		return compressFile(rotated)
+	}
+	return nil
+}
+
+// rotatedName returns the name of the index-th rotated file
+func rotatedName(path string, index int, compressed bool) string {
+	name := fmt.Sprintf("%s.%d", path, index)
+	if compressed {
+		name += ".gz"
+	}
+	return name
+}
+
+// compressFile gzips a file to name.gz and removes the original
+func compressFile(name string) error {
+	source, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	target, err := os.OpenFile(name+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(target)
+	if _, err := io.Copy(gz, source); err != nil {
+		target.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		target.Close()
+		return err
+	}
+	if err := target.Close(); err != nil {
+		return err
+	}
+	return os.Remove(name)
+}
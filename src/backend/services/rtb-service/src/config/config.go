@@ -3,11 +3,25 @@
 package config
 
 import (
-	"encoding/json" // v1.21.0
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"fmt"
+	"math"
+	"net/http"
+	"net/netip"
+	"net/url"
 	"os"      // v1.21.0
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
 	"time"    // v1.21.0
+	"github.com/mitchellh/mapstructure" // v1.5.0
 	"github.com/spf13/viper" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/rules"
 )
 
 // Default configuration values
@@ -18,44 +32,3321 @@ const (
 	defaultMaxBidPrice   = 100.0
 	defaultRedisTimeout  = 200 * time.Millisecond
 	defaultMetricsInterval = 10 * time.Second
+	defaultHealthCacheTTL  = 2 * time.Second
+	defaultBreakerFailures = 5
+	defaultBreakerCooldown = 30 * time.Second
+	maxScoringTimeout      = 50 * time.Millisecond
+	defaultMaxDecompressedBytes = 10 << 20
+	defaultPartnerWorkers  = 1024
+)
+
+// Config represents the main RTB service configuration
+type Config struct {
+	Port                 int              `json:"port" mapstructure:"port"`
+	GRPCPort             int              `json:"grpcPort" mapstructure:"grpc_port"`
+	BidTimeout          time.Duration    `json:"bidTimeout" mapstructure:"bid_timeout"`
+	MaxBidsPerRequest   int              `json:"maxBidsPerRequest" mapstructure:"max_bids_per_request"`
+	MinBidPrice         float64          `json:"minBidPrice" mapstructure:"min_bid_price"`
+	MaxBidPrice         float64          `json:"maxBidPrice" mapstructure:"max_bid_price"`
+	Partners            map[string]*PartnerConfig `json:"partners" mapstructure:"partners"`
+	Redis               *RedisConfig     `json:"redis" mapstructure:"redis"`
+	Metrics             *MetricsConfig   `json:"metrics" mapstructure:"metrics"`
+	EnableDynamicPricing bool            `json:"enableDynamicPricing" mapstructure:"enable_dynamic_pricing"`
+	ConfigReloadInterval time.Duration   `json:"configReloadInterval" mapstructure:"config_reload_interval"`
+	HealthCacheTTL      time.Duration    `json:"healthCacheTTL" mapstructure:"health_cache_ttl"`
+	CircuitBreaker      *CircuitBreakerConfig `json:"circuitBreaker" mapstructure:"circuit_breaker"`
+	RateLimitBackoff    *RateLimitBackoffConfig `json:"rateLimitBackoff" mapstructure:"rate_limit_backoff"`
+	Admin               *AdminConfig     `json:"admin" mapstructure:"admin"`
+	DuplicateRequestWindow time.Duration `json:"duplicateRequestWindow" mapstructure:"duplicate_request_window"`
+	Idempotency         *IdempotencyConfig `json:"idempotency" mapstructure:"idempotency"`
+	MaxConcurrentAuctions int            `json:"maxConcurrentAuctions" mapstructure:"max_concurrent_auctions"`
+	MaxConcurrentStreams int             `json:"maxConcurrentStreams" mapstructure:"max_concurrent_streams"`
+	VerticalLimits      map[string]*VerticalLimit `json:"verticalLimits" mapstructure:"vertical_limits"`
+	Verticals           map[string]*VerticalConfig `json:"verticals" mapstructure:"verticals"`
+	Batch               *BatchConfig     `json:"batch" mapstructure:"batch"`
+	Strategies          map[string]string `json:"strategies" mapstructure:"strategies"`
+	ResponseTTLs        map[string]time.Duration `json:"responseTtl" mapstructure:"response_ttl"`
+	Scoring             *ScoringConfig   `json:"scoring" mapstructure:"scoring"`
+	TimeMultipliers     *TimeMultiplierConfig `json:"timeMultipliers" mapstructure:"time_multipliers"`
+	Consent             *ConsentConfig   `json:"consent" mapstructure:"consent"`
+	PIIPolicy           *PIIPolicy       `json:"piiPolicy" mapstructure:"pii_policy"`
+	Enrichment          *EnrichmentConfig `json:"enrichment" mapstructure:"enrichment"`
+	Dedup               *DedupConfig     `json:"dedup" mapstructure:"dedup"`
+	Compression         *CompressionConfig `json:"compression" mapstructure:"compression"`
+	HTTPClient          *HTTPClientConfig `json:"httpClient" mapstructure:"http_client"`
+	Deals               map[string]*DealConfig `json:"deals" mapstructure:"deals"`
+	MinBidders          map[string]int   `json:"minBidders" mapstructure:"min_bidders"`
+	QuorumFailureStatus int              `json:"quorumFailureStatus" mapstructure:"quorum_failure_status"`
+	Analytics           *AnalyticsConfig `json:"analytics" mapstructure:"analytics"`
+	Audit               *AuditConfig     `json:"audit" mapstructure:"audit"`
+	Webhooks            *WebhooksConfig  `json:"webhooks" mapstructure:"webhooks"`
+	Recording           *RecordingConfig `json:"recording" mapstructure:"recording"`
+	Estimates           *EstimatesConfig `json:"estimates" mapstructure:"estimates"`
+	Reservations        *ReservationsConfig `json:"reservations" mapstructure:"reservations"`
+	ExpectedPremiums    map[string]float64 `json:"expectedPremiums" mapstructure:"expected_premiums"`
+	DeterministicMode   bool             `json:"deterministicMode" mapstructure:"deterministic_mode"`
+	PartnerWorkers      int              `json:"partnerWorkers" mapstructure:"partner_workers"`
+	JSONCodec           string           `json:"jsonCodec" mapstructure:"json_codec"`
+	PriceRounding       *PriceRoundingConfig `json:"priceRounding" mapstructure:"price_rounding"`
+	UserDataSchemas     map[string]*UserDataSchema `json:"userDataSchemas" mapstructure:"user_data_schemas"`
+	BidExtSchemas       map[string]*BidExtSchema `json:"bidExtSchemas" mapstructure:"bid_ext_schemas"`
+	IVT                 *IVTConfig       `json:"ivt" mapstructure:"ivt"`
+	ResponseProfiles    map[string]*ResponseProfile `json:"responseProfiles" mapstructure:"response_profiles"`
+	NoBidResponse       *NoBidResponseConfig `json:"noBidResponse" mapstructure:"no_bid_response"`
+	HouseOffers         map[string]*HouseOfferConfig `json:"houseOffers" mapstructure:"house_offers"`
+	NegativeCache       *NegativeCacheConfig `json:"negativeCache" mapstructure:"negative_cache"`
+	ReplayProtection    *ReplayProtectionConfig `json:"replayProtection" mapstructure:"replay_protection"`
+	TimeoutBudget       *TimeoutBudgetConfig `json:"timeoutBudget" mapstructure:"timeout_budget"`
+	QualityWeight       *float64         `json:"qualityWeight" mapstructure:"quality_weight"`
+	QualityAcknowledgedBonus float64     `json:"qualityAcknowledgedBonus" mapstructure:"quality_acknowledged_bonus"`
+	Experiments         map[string]*ExperimentConfig `json:"experiments" mapstructure:"experiments"`
+	Capture             *CaptureConfig   `json:"capture" mapstructure:"capture"`
+	AllowInsecurePartnerTLS bool         `json:"allowInsecurePartnerTls" mapstructure:"allow_insecure_partner_tls"`
+	MaxPartnersPerAuction int            `json:"maxPartnersPerAuction" mapstructure:"max_partners_per_auction"`
+	PartnerSelectionMode string          `json:"partnerSelectionMode" mapstructure:"partner_selection_mode"`
+	MirroringDisabled   bool             `json:"mirroringDisabled" mapstructure:"mirroring_disabled"`
+	Export              *ExportConfig    `json:"export" mapstructure:"export"`
+	SelfTest            *SelfTestConfig  `json:"selfTest" mapstructure:"self_test"`
+	PayloadLimits       *PayloadLimitsConfig `json:"payloadLimits" mapstructure:"payload_limits"`
+	Warmup              *WarmupConfig    `json:"warmup" mapstructure:"warmup"`
+	Localization        *LocalizationConfig `json:"localization" mapstructure:"localization"`
+	PartnerGroups       map[string][]string `json:"partnerGroups" mapstructure:"partner_groups"`
+	Budgets             *BudgetConfig    `json:"budgets" mapstructure:"budgets"`
+	Canary              *CanaryConfig    `json:"canary" mapstructure:"canary"`
+	FaultInjection      *FaultInjectionConfig `json:"faultInjection" mapstructure:"fault_injection"`
+	AssetVerification   *AssetVerificationConfig `json:"assetVerification" mapstructure:"asset_verification"`
+	EarlyTermination    *EarlyTerminationConfig `json:"earlyTermination" mapstructure:"early_termination"`
+	AuctionSequence     *AuctionSequenceConfig `json:"auctionSequence" mapstructure:"auction_sequence"`
+	Rules               *RulesConfig     `json:"rules" mapstructure:"rules"`
+	Hygiene             *HygieneConfig   `json:"hygiene" mapstructure:"hygiene"`
+	// ServiceRegion names the region this instance serves, such as us-east. It labels metrics,
+	// webhook events, partner requests, health, and recordings, and is matched against partners'
+	// PreferredRegions.
+	ServiceRegion       string           `json:"serviceRegion" mapstructure:"service_region"`
+}
+
+// PartnerConfig represents configuration for individual RTB partners
+type PartnerConfig struct {
+	ID                 string             `json:"id" mapstructure:"id"`
+	Endpoint           string             `json:"endpoint" mapstructure:"endpoint"`
+	Endpoints          []EndpointConfig   `json:"endpoints" mapstructure:"endpoints"`
+	APIKey             string             `json:"-" mapstructure:"api_key"`
+	Timeout            time.Duration      `json:"timeout" mapstructure:"timeout"`
+	MinBid             float64            `json:"minBid" mapstructure:"min_bid"`
+	MaxBid             float64            `json:"maxBid" mapstructure:"max_bid"`
+	VerticalMultipliers map[string]float64 `json:"verticalMultipliers" mapstructure:"vertical_multipliers"`
+	Priority           int                `json:"priority" mapstructure:"priority"`
+	Enabled            bool               `json:"enabled" mapstructure:"enabled"`
+	MaxQPS             int                `json:"maxQps" mapstructure:"max_qps"`
+	Schedule           *PartnerSchedule   `json:"schedule" mapstructure:"schedule"`
+	RequiresConsent    bool               `json:"requiresConsent" mapstructure:"requires_consent"`
+	DPASigned          bool               `json:"dpaSigned" mapstructure:"dpa_signed"`
+	AllowedRegions     []string           `json:"allowedRegions" mapstructure:"allowed_regions"`
+	DeviceMultipliers  map[string]float64 `json:"deviceMultipliers" mapstructure:"device_multipliers"`
+	Format             string             `json:"format" mapstructure:"format"`
+	FieldMapping       *FieldMapping      `json:"fieldMapping" mapstructure:"field_mapping"`
+	Auth               *PartnerAuth       `json:"auth" mapstructure:"auth"`
+	TLS                *PartnerTLS        `json:"tls" mapstructure:"tls"`
+	QueryParams        map[string]string  `json:"queryParams" mapstructure:"query_params"`
+	Gzip               bool               `json:"gzip" mapstructure:"gzip"`
+	Retry              *RetryPolicy       `json:"retry" mapstructure:"retry"`
+	MaxBidsPerResponse int                `json:"maxBidsPerResponse" mapstructure:"max_bids_per_response"`
+	SLA                *PartnerSLA        `json:"sla" mapstructure:"sla"`
+	PricingModel       string             `json:"pricingModel" mapstructure:"pricing_model"`
+	ResponseValidation string             `json:"responseValidation" mapstructure:"response_validation"`
+	MaxResponseBytes   int64              `json:"maxResponseBytes" mapstructure:"max_response_bytes"`
+	TrafficPercentage  float64            `json:"trafficPercentage" mapstructure:"traffic_percentage"`
+	Mirror             *PartnerMirror     `json:"mirror" mapstructure:"mirror"`
+	WarmupMethod       string             `json:"warmupMethod" mapstructure:"warmup_method"`
+	// DrainDeadline marks a partner being offboarded: it gets no new auctions, and bids it already
+	// won settle until the deadline, after which it is treated as disabled
+	DrainDeadline      time.Time          `json:"drainDeadline" mapstructure:"drain_deadline"`
+	// DailyBudget caps what the partner's winning bids are charged per UTC day; zero is unlimited
+	DailyBudget        float64            `json:"dailyBudget" mapstructure:"daily_budget"`
+	// BudgetAlertThresholds are percentages of DailyBudget whose crossing is alerted once per day
+	BudgetAlertThresholds []float64       `json:"budgetAlertThresholds" mapstructure:"budget_alert_thresholds"`
+	// Fields limits the request fields and UserData keys the partner's payload carries
+	Fields             *FieldsPolicy      `json:"fields" mapstructure:"fields"`
+	// Canary opts the partner in to canary auctions run against real partners
+	Canary             bool               `json:"canary" mapstructure:"canary"`
+	// PreferredRegions limits the partner to traffic from instances serving one of these regions
+	PreferredRegions   []string           `json:"preferredRegions" mapstructure:"preferred_regions"`
+	// ShareBidGuidance sends the partner's win and loss webhooks the price its bid needed; off by
+	// default since some contracts forbid sharing competitive signals
+	ShareBidGuidance   bool               `json:"shareBidGuidance" mapstructure:"share_bid_guidance"`
+	// PayloadExperiment sends a variant payload on a share of the partner's calls until it ends
+	PayloadExperiment  *PayloadExperiment `json:"payloadExperiment" mapstructure:"payload_experiment"`
+}
+
+// serviceRegionPattern matches service region names, such as us-east
+var serviceRegionPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// PrefersRegion reports whether the partner takes traffic from an instance serving region.
+// Partners without preferred regions take every region's traffic, as do instances without a region.
+func (p *PartnerConfig) PrefersRegion(region string) bool {
+	if len(p.PreferredRegions) == 0 || region == "" {
+		return true
+	}
+	for _, preferred := range p.PreferredRegions {
+		if preferred == region {
+			return true
+		}
+	}
+	return false
+}
+
+// BudgetHold returns the most one auction can charge the partner, which is held against its daily
+// budget while the auction runs: its max bid when set below maxBidPrice, otherwise maxBidPrice
+func (p *PartnerConfig) BudgetHold(maxBidPrice float64) float64 {
+	if p.MaxBid > 0 && p.MaxBid < maxBidPrice {
+		return p.MaxBid
+	}
+	return maxBidPrice
+}
+
+// DefaultMirrorTimeout bounds a mirrored request when the mirror sets no timeout
+const DefaultMirrorTimeout = 200 * time.Millisecond
+
+// maxMirrorTimeout bounds how long a mirrored request may hold its connection
+const maxMirrorTimeout = 5 * time.Second
+
+// PartnerMirror copies a partner's production bid requests to a sandbox endpoint, such as while
+// the partner moves to a new bidder. Copies use the partner's auth scheme, with APIKey in place
+// of its secret when one is set, and are sent at most MaxQPS a second. Responses are discarded.
+type PartnerMirror struct {
+	Endpoint string        `json:"endpoint" mapstructure:"endpoint"`
+	Timeout  time.Duration `json:"timeout" mapstructure:"timeout"`
+	MaxQPS   int           `json:"maxQps" mapstructure:"max_qps"`
+	APIKey   string        `json:"-" mapstructure:"api_key"`
+}
+
+// RequestTimeout returns the mirrored request timeout, defaulting to DefaultMirrorTimeout
+func (m *PartnerMirror) RequestTimeout() time.Duration {
+	if m.Timeout <= 0 {
+		return DefaultMirrorTimeout
+	}
+	return m.Timeout
+}
+
+// validate checks the mirror's endpoint, timeout, and QPS cap
+func (m *PartnerMirror) validate(partnerID string) error {
+	if m == nil {
+		return nil
+	}
+	if _, err := ParseTemplate(m.Endpoint); err != nil {
+		return fmt.Errorf("invalid mirror endpoint template for partner %s: %v", partnerID, err)
+	}
+	parsed, err := url.Parse(m.Endpoint)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || strings.Contains(parsed.Host, "{") {
+		return fmt.Errorf("invalid mirror endpoint for partner %s: %q", partnerID, m.Endpoint)
+	}
+	if m.Timeout < 0 || m.Timeout > maxMirrorTimeout {
+		return fmt.Errorf("mirror timeout must be between 0 and %v for partner %s", maxMirrorTimeout, partnerID)
+	}
+	if m.MaxQPS <= 0 {
+		return fmt.Errorf("mirror max QPS must be positive for partner %s", partnerID)
+	}
+	if _, err := ResolveSecret(m.APIKey); m.APIKey != "" && err != nil {
+		return fmt.Errorf("unresolved mirror API key for partner %s: %v", partnerID, err)
+	}
+	return nil
+}
+
+// Partner selection modes for auctions capped at MaxPartnersPerAuction. Performance mode, the
+// default, contacts the partners with the best recent revenue per call; fair mode picks partners
+// by consistent hashing of the lead so each sees a representative cross-section of traffic.
+const (
+	PartnerSelectionPerformance = "performance"
+	PartnerSelectionFair        = "fair"
+)
+
+// Partner pricing models. CPL partners bid a flat cost per lead; revenue-share partners bid a
+// percentage of the premium, priced against the vertical's expected premium.
+const (
+	PricingModelCPL      = "cpl"
+	PricingModelRevShare = "revshare"
+)
+
+// Pricing returns the partner's pricing model, defaulting to CPL
+func (p *PartnerConfig) Pricing() string {
+	if p.PricingModel == "" {
+		return PricingModelCPL
+	}
+	return p.PricingModel
+}
+
+// DefaultMaxBidsPerResponse caps the bids taken from one partner response when MaxBidsPerResponse is unset
+const DefaultMaxBidsPerResponse = 5
+
+// BidsPerResponse returns the maximum number of bids taken from one partner response
+func (p *PartnerConfig) BidsPerResponse() int {
+	if p.MaxBidsPerResponse <= 0 {
+		return DefaultMaxBidsPerResponse
+	}
+	return p.MaxBidsPerResponse
+}
+
+// Partner response size defaults and bounds
+const (
+	DefaultMaxPartnerResponseBytes = 1 << 20
+	maxPartnerResponseBytes        = 16 << 20
+)
+
+// ResponseLimit returns the most bytes of a decoded partner response that are read before the
+// response is rejected as too large
+func (p *PartnerConfig) ResponseLimit() int64 {
+	if p.MaxResponseBytes <= 0 {
+		return DefaultMaxPartnerResponseBytes
+	}
+	return p.MaxResponseBytes
+}
+
+// TrafficShare returns the share of fair partner selection's virtual nodes the partner holds
+// relative to a partner at full traffic; an unset TrafficPercentage is full traffic
+func (p *PartnerConfig) TrafficShare() float64 {
+	if p.TrafficPercentage <= 0 {
+		return 1
+	}
+	return p.TrafficPercentage / 100
+}
+
+// EndpointConfig is one of several URLs serving a partner. Weight sets the share of first
+// attempts routed to it; a zero weight marks a backup only tried on failover.
+type EndpointConfig struct {
+	URL    string `json:"url" mapstructure:"url"`
+	Weight int    `json:"weight" mapstructure:"weight"`
+	Region string `json:"region" mapstructure:"region"`
+}
+
+// EndpointList returns the partner's endpoints, treating a lone Endpoint as a single weighted endpoint
+func (p *PartnerConfig) EndpointList() []EndpointConfig {
+	if len(p.Endpoints) > 0 {
+		return p.Endpoints
+	}
+	if p.Endpoint == "" {
+		return nil
+	}
+	return []EndpointConfig{{URL: p.Endpoint, Weight: 1}}
+}
+
+// TemplatePlaceholders are the bid request fields partner endpoints and query parameters can
+// reference as {name}
+var TemplatePlaceholders = map[string]bool{
+	"request_id": true,
+	"lead_id":    true,
+	"vertical":   true,
+	"state":      true,
+	"zip":        true,
+	"country":    true,
+	"device":     true,
+}
+
+// TemplatePart is a literal or a placeholder of a parsed template
+type TemplatePart struct {
+	Literal     string
+	Placeholder string
+}
+
+// ParseTemplate splits a template into literals and {name} placeholders, rejecting unknown names
+func ParseTemplate(template string) ([]TemplatePart, error) {
+	var parts []TemplatePart
+	for template != "" {
+		start := strings.IndexByte(template, '{')
+		if start < 0 {
+			parts = append(parts, TemplatePart{Literal: template})
+			break
+		}
+		if start > 0 {
+			parts = append(parts, TemplatePart{Literal: template[:start]})
+		}
+		end := strings.IndexByte(template[start:], '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed placeholder in %q", template)
+		}
+		name := template[start+1 : start+end]
+		if !TemplatePlaceholders[name] {
+			return nil, fmt.Errorf("unknown placeholder {%s}", name)
+		}
+		parts = append(parts, TemplatePart{Placeholder: name})
+		template = template[start+end+1:]
+	}
+	return parts, nil
+}
+
+// validateTemplates checks the placeholders of the partner's endpoints and query parameters.
+// Endpoints may only template their path and query, so the host a partner call reaches is fixed.
+func (p *PartnerConfig) validateTemplates(partnerID string) error {
+	for _, endpoint := range p.EndpointList() {
+		if _, err := ParseTemplate(endpoint.URL); err != nil {
+			return fmt.Errorf("invalid endpoint template %s for partner %s: %v", endpoint.URL, partnerID, err)
+		}
+		authority := endpoint.URL
+		if scheme := strings.Index(authority, "://"); scheme >= 0 {
+			authority = authority[scheme+len("://"):]
+		}
+		if end := strings.IndexAny(authority, "/?#"); end >= 0 {
+			authority = authority[:end]
+		}
+		if strings.Contains(authority, "{") {
+			return fmt.Errorf("endpoint %s for partner %s has a placeholder outside its path and query", endpoint.URL, partnerID)
+		}
+	}
+	for name, value := range p.QueryParams {
+		if name == "" {
+			return fmt.Errorf("empty query parameter name for partner %s", partnerID)
+		}
+		if _, err := ParseTemplate(value); err != nil {
+			return fmt.Errorf("invalid query parameter %s for partner %s: %v", name, partnerID, err)
+		}
+	}
+	return nil
+}
+
+// Fields policy modes
+const (
+	FieldsPolicyAllow = "allow"
+	FieldsPolicyDeny  = "deny"
+)
+
+// RequestFields are the bid request fields a fields policy can name, by their JSON names
+var RequestFields = map[string]bool{
+	"request_id":     true,
+	"lead_id":        true,
+	"vertical":       true,
+	"user_data":      true,
+	"timeout":        true,
+	"timestamp":      true,
+	"consent":        true,
+	"geo":            true,
+	"device":         true,
+	"lead_quality":   true,
+	"auction_params": true,
+	"constraints":    true,
+}
+
+// placeholderFields maps template placeholders to the request fields they read
+var placeholderFields = map[string]string{
+	"request_id": "request_id",
+	"lead_id":    "lead_id",
+	"vertical":   "vertical",
+	"state":      "geo",
+	"zip":        "geo",
+	"country":    "geo",
+	"device":     "device",
+}
+
+// FieldsPolicy limits what a partner's outbound payload carries, for partners that reject fields
+// they do not know or may only receive the minimum data necessary. In allow mode only Fields are
+// sent, and user_data only keeps the UserData keys; in deny mode Fields and the UserData keys are
+// withheld. The policy applies after consent redaction, to every adapter and the partner's mirror.
+type FieldsPolicy struct {
+	Mode     string   `json:"mode" mapstructure:"mode"`
+	Fields   []string `json:"fields" mapstructure:"fields"`
+	UserData []string `json:"userData" mapstructure:"user_data"`
+}
+
+// Sends reports whether the payload carries a request field
+func (f *FieldsPolicy) Sends(field string) bool {
+	if f == nil {
+		return true
+	}
+	listed := false
+	for _, name := range f.Fields {
+		if name == field {
+			listed = true
+			break
+		}
+	}
+	if field == "user_data" && f.Mode == FieldsPolicyAllow {
+		listed = listed || len(f.UserData) > 0
+	}
+	return listed == (f.Mode == FieldsPolicyAllow)
+}
+
+// SendsUserData reports whether the payload's user_data carries a key
+func (f *FieldsPolicy) SendsUserData(key string) bool {
+	if f == nil {
+		return true
+	}
+	if !f.Sends("user_data") {
+		return false
+	}
+	if f.Mode == FieldsPolicyAllow && len(f.UserData) == 0 {
+		return true
+	}
+	listed := false
+	for _, name := range f.UserData {
+		if name == key {
+			listed = true
+			break
+		}
+	}
+	return listed == (f.Mode == FieldsPolicyAllow)
+}
+
+// validateFields checks the partner's fields policy against the known request fields and, when
+// vertical schemas are configured, their UserData keys. Fields the partner's endpoint, query
+// parameter, or mirror templates read cannot be withheld, or they would still reach it in the URL.
+func (p *PartnerConfig) validateFields(partnerID string, schemas map[string]*UserDataSchema) error {
+	f := p.Fields
+	if f == nil {
+		return nil
+	}
+	if f.Mode != FieldsPolicyAllow && f.Mode != FieldsPolicyDeny {
+		return fmt.Errorf("unknown fields policy mode %q for partner %s", f.Mode, partnerID)
+	}
+	seen := make(map[string]bool)
+	for _, field := range f.Fields {
+		if !RequestFields[field] {
+			return fmt.Errorf("fields policy for partner %s names unknown request field %q", partnerID, field)
+		}
+		if seen[field] {
+			return fmt.Errorf("fields policy for partner %s lists request field %q twice", partnerID, field)
+		}
+		seen[field] = true
+	}
+	if len(f.UserData) > 0 && f.Mode == FieldsPolicyDeny && seen["user_data"] {
+		return fmt.Errorf("fields policy for partner %s denies user_data and lists user data keys", partnerID)
+	}
+	seen = make(map[string]bool)
+	for _, key := range f.UserData {
+		if key == "" || seen[key] {
+			return fmt.Errorf("fields policy for partner %s has an empty or repeated user data key %q", partnerID, key)
+		}
+		seen[key] = true
+		if len(schemas) == 0 {
+			continue
+		}
+		known := false
+		for _, schema := range schemas {
+			if schema != nil && schema.Fields[key] != nil {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("fields policy for partner %s names user data key %q outside every vertical schema", partnerID, key)
+		}
+	}
+
+	templates := make([]string, 0, len(p.QueryParams)+2)
+	for _, endpoint := range p.EndpointList() {
+		templates = append(templates, endpoint.URL)
+	}
+	for _, value := range p.QueryParams {
+		templates = append(templates, value)
+	}
+	if p.Mirror != nil {
+		templates = append(templates, p.Mirror.Endpoint)
+	}
+	for _, template := range templates {
+		parts, _ := ParseTemplate(template)
+		for _, part := range parts {
+			if field := placeholderFields[part.Placeholder]; field != "" && !f.Sends(field) {
+				return fmt.Errorf("fields policy for partner %s withholds %s, which template %s reads", partnerID, field, template)
+			}
+		}
+	}
+	return nil
+}
+
+// PayloadExperiment tests a payload variant on a partner's traffic until EndsAt, such as an extra
+// field the partner claims it would bid more for. TrafficPercent of the partner's calls, chosen by
+// a hash of the lead ID so retries of a lead keep their variant, are sent the treatment payload;
+// the rest are sent the configured payload as the control. Calls without a lead ID, and every
+// call after EndsAt, are left out of the experiment.
+type PayloadExperiment struct {
+	ID             string         `json:"id" mapstructure:"id"`
+	TrafficPercent float64        `json:"trafficPercent" mapstructure:"traffic_percent"`
+	EndsAt         time.Time      `json:"endsAt" mapstructure:"ends_at"`
+	Variant        PayloadVariant `json:"variant" mapstructure:"variant"`
+}
+
+// PayloadVariant is what the treatment payload changes. ExtraFields are request fields, or
+// UserData keys named with the SegmentFieldUserDataPrefix, that the partner's fields policy
+// withholds and the treatment sends. Format with FieldMapping, Endpoint, and QueryParams replace
+// the partner's when set.
+type PayloadVariant struct {
+	ExtraFields  []string          `json:"extraFields" mapstructure:"extra_fields"`
+	Format       string            `json:"format" mapstructure:"format"`
+	FieldMapping *FieldMapping     `json:"fieldMapping" mapstructure:"field_mapping"`
+	Endpoint     string            `json:"endpoint" mapstructure:"endpoint"`
+	QueryParams  map[string]string `json:"queryParams" mapstructure:"query_params"`
+}
+
+// Active reports whether the experiment still runs at now
+func (e *PayloadExperiment) Active(now time.Time) bool {
+	return e != nil && now.Before(e.EndsAt)
+}
+
+// PayloadVariantConfig returns a copy of the partner sending the treatment payload of its
+// experiment, or nil when it has none. In allow mode extra fields join the fields policy's lists;
+// in deny mode they leave them.
+func (p *PartnerConfig) PayloadVariantConfig() *PartnerConfig {
+	if p.PayloadExperiment == nil {
+		return nil
+	}
+	variant := p.PayloadExperiment.Variant
+	copied := *p
+	copied.PayloadExperiment = nil
+	if len(variant.ExtraFields) > 0 && p.Fields != nil {
+		fields := &FieldsPolicy{Mode: p.Fields.Mode}
+		fields.Fields = append([]string(nil), p.Fields.Fields...)
+		fields.UserData = append([]string(nil), p.Fields.UserData...)
+		for _, extra := range variant.ExtraFields {
+			key, userData := strings.CutPrefix(extra, SegmentFieldUserDataPrefix)
+			list := &fields.Fields
+			if userData {
+				list = &fields.UserData
+			}
+			if fields.Mode == FieldsPolicyAllow {
+				*list = append(*list, key)
+			} else {
+				*list = slices.DeleteFunc(*list, func(name string) bool { return name == key })
+			}
+		}
+		copied.Fields = fields
+	}
+	if variant.Format != "" {
+		copied.Format = variant.Format
+		copied.FieldMapping = variant.FieldMapping
+	}
+	if variant.Endpoint != "" {
+		copied.Endpoint = variant.Endpoint
+		copied.Endpoints = nil
+	}
+	if variant.QueryParams != nil {
+		copied.QueryParams = variant.QueryParams
+	}
+	return &copied
+}
+
+// validatePayloadExperiment checks the experiment's traffic and end, that its variant changes the
+// payload, and that each extra field is one the partner is not sent yet. The variant's endpoint,
+// templates, format, and fields policy are checked as the partner's are.
+func (p *PartnerConfig) validatePayloadExperiment(partnerID string, schemas map[string]*UserDataSchema, allowInsecureTLS bool) error {
+	e := p.PayloadExperiment
+	if e == nil {
+		return nil
+	}
+	if e.ID == "" {
+		return fmt.Errorf("missing payload experiment ID for partner %s", partnerID)
+	}
+	if e.TrafficPercent <= 0 || e.TrafficPercent > 100 {
+		return fmt.Errorf("payload experiment %s traffic percent must be in (0, 100] for partner %s: %v", e.ID, partnerID, e.TrafficPercent)
+	}
+	if e.EndsAt.IsZero() {
+		return fmt.Errorf("payload experiment %s for partner %s has no end", e.ID, partnerID)
+	}
+	variant := e.Variant
+	if len(variant.ExtraFields) == 0 && variant.Format == "" && variant.Endpoint == "" && variant.QueryParams == nil {
+		return fmt.Errorf("payload experiment %s for partner %s changes nothing", e.ID, partnerID)
+	}
+
+	treatment := p.PayloadVariantConfig()
+	sends := func(fields *FieldsPolicy, extra string) bool {
+		if key, userData := strings.CutPrefix(extra, SegmentFieldUserDataPrefix); userData {
+			return fields.SendsUserData(key)
+		}
+		return fields.Sends(extra)
+	}
+	seen := make(map[string]bool, len(variant.ExtraFields))
+	for _, extra := range variant.ExtraFields {
+		key, userData := strings.CutPrefix(extra, SegmentFieldUserDataPrefix)
+		if (userData && key == "") || (!userData && !RequestFields[extra]) || seen[extra] {
+			return fmt.Errorf("payload experiment %s for partner %s has an unknown or repeated extra field %q", e.ID, partnerID, extra)
+		}
+		seen[extra] = true
+		if sends(p.Fields, extra) {
+			return fmt.Errorf("payload experiment %s for partner %s adds field %q, which the partner is already sent", e.ID, partnerID, extra)
+		}
+		if !sends(treatment.Fields, extra) {
+			return fmt.Errorf("payload experiment %s for partner %s cannot add field %q while its fields policy withholds user_data", e.ID, partnerID, extra)
+		}
+	}
+
+	if err := treatment.validateEndpoints(partnerID); err != nil {
+		return err
+	}
+	if err := treatment.TLS.validate(partnerID, treatment.EndpointList(), allowInsecureTLS); err != nil {
+		return err
+	}
+	if err := treatment.validateTemplates(partnerID); err != nil {
+		return err
+	}
+	if err := treatment.validateFormat(partnerID); err != nil {
+		return err
+	}
+	return treatment.validateFields(partnerID, schemas)
+}
+
+// validateEndpoints requires at least one routable endpoint with unique URLs and non-negative weights
+func (p *PartnerConfig) validateEndpoints(partnerID string) error {
+	endpoints := p.EndpointList()
+	if len(endpoints) == 0 {
+		return fmt.Errorf("missing endpoint for partner %s", partnerID)
+	}
+
+	seen := make(map[string]bool, len(endpoints))
+	totalWeight := 0
+	for _, endpoint := range endpoints {
+		if endpoint.URL == "" {
+			return fmt.Errorf("missing endpoint URL for partner %s", partnerID)
+		}
+		if seen[endpoint.URL] {
+			return fmt.Errorf("duplicate endpoint %s for partner %s", endpoint.URL, partnerID)
+		}
+		seen[endpoint.URL] = true
+		if endpoint.Weight < 0 {
+			return fmt.Errorf("invalid weight %d for endpoint %s in partner %s", endpoint.Weight, endpoint.URL, partnerID)
+		}
+		totalWeight += endpoint.Weight
+	}
+	if totalWeight == 0 {
+		return fmt.Errorf("partner %s needs at least one endpoint with a positive weight", partnerID)
+	}
+	return nil
+}
+
+// Conditions a partner retry policy may retry on
+const (
+	RetryOnConnectError = "connect_error"
+	RetryOn5xx          = "5xx"
+	RetryOn429          = "429"
+)
+
+// RetryPolicy retries transient partner failures within the partner timeout; nil or a
+// MaxAttempts below 2 means a single attempt
+type RetryPolicy struct {
+	MaxAttempts int           `json:"maxAttempts" mapstructure:"max_attempts"`
+	RetryOn     []string      `json:"retryOn" mapstructure:"retry_on"`
+	BackoffBase time.Duration `json:"backoffBase" mapstructure:"backoff_base"`
+}
+
+// Attempts returns the maximum number of attempts, at least 1
+func (r *RetryPolicy) Attempts() int {
+	if r == nil || r.MaxAttempts < 1 {
+		return 1
+	}
+	return r.MaxAttempts
+}
+
+// RetriesOn reports whether the policy retries the given condition
+func (r *RetryPolicy) RetriesOn(condition string) bool {
+	if r == nil {
+		return false
+	}
+	for _, candidate := range r.RetryOn {
+		if candidate == condition {
+			return true
+		}
+	}
+	return false
+}
+
+// validate bounds attempts and backoff to the partner timeout and rejects unknown conditions
+func (r *RetryPolicy) validate(partnerID string, timeout time.Duration) error {
+	if r == nil {
+		return nil
+	}
+	if r.MaxAttempts < 0 || r.MaxAttempts > 5 {
+		return fmt.Errorf("retry max attempts must be between 0 and 5 for partner %s", partnerID)
+	}
+	if r.BackoffBase < 0 || r.BackoffBase >= timeout {
+		return fmt.Errorf("retry backoff must be non-negative and below the timeout for partner %s", partnerID)
+	}
+	for _, condition := range r.RetryOn {
+		switch condition {
+		case RetryOnConnectError, RetryOn5xx, RetryOn429:
+		default:
+			return fmt.Errorf("unknown retry condition %q for partner %s", condition, partnerID)
+		}
+	}
+	return nil
+}
+
+// PartnerSLA sets the service levels a partner is held to in partner reports and breach alerts;
+// a zero target is not checked
+type PartnerSLA struct {
+	P95Latency     time.Duration `json:"p95Latency" mapstructure:"p95_latency"`
+	MaxTimeoutRate float64       `json:"maxTimeoutRate" mapstructure:"max_timeout_rate"`
+}
+
+// validate requires the latency target within the partner timeout and the timeout rate between 0 and 1
+func (s *PartnerSLA) validate(partnerID string, timeout time.Duration) error {
+	if s == nil {
+		return nil
+	}
+	if s.P95Latency < 0 || s.P95Latency > timeout {
+		return fmt.Errorf("SLA p95 latency must be between 0 and the timeout for partner %s", partnerID)
+	}
+	if s.MaxTimeoutRate < 0 || s.MaxTimeoutRate > 1 {
+		return fmt.Errorf("SLA max timeout rate must be between 0 and 1 for partner %s", partnerID)
+	}
+	return nil
+}
+
+// Partner authentication schemes
+const (
+	AuthBearer = "bearer"
+	AuthBasic  = "basic"
+	AuthHMAC   = "hmac"
+	AuthHeader = "header"
+)
+
+// HMAC signature algorithms
+const (
+	HMACSHA256 = "sha256"
+	HMACSHA512 = "sha512"
 )
 
-// Config represents the main RTB service configuration
-type Config struct {
-	Port                 int              `json:"port" mapstructure:"port"`
-	BidTimeout          time.Duration    `json:"bidTimeout" mapstructure:"bid_timeout"`
-	MaxBidsPerRequest   int              `json:"maxBidsPerRequest" mapstructure:"max_bids_per_request"`
-	MinBidPrice         float64          `json:"minBidPrice" mapstructure:"min_bid_price"`
-	MaxBidPrice         float64          `json:"maxBidPrice" mapstructure:"max_bid_price"`
-	Partners            map[string]*PartnerConfig `json:"partners" mapstructure:"partners"`
-	Redis               *RedisConfig     `json:"redis" mapstructure:"redis"`
-	Metrics             *MetricsConfig   `json:"metrics" mapstructure:"metrics"`
-	EnableDynamicPricing bool            `json:"enableDynamicPricing" mapstructure:"enable_dynamic_pricing"`
-	ConfigReloadInterval time.Duration   `json:"configReloadInterval" mapstructure:"config_reload_interval"`
+// PartnerAuth selects how outbound requests authenticate to a partner; nil means a bearer APIKey.
+// Secret fields hold secret references (see ResolveSecret) and are never serialized.
+type PartnerAuth struct {
+	Type            string `json:"type" mapstructure:"type"`
+	HeaderName      string `json:"headerName" mapstructure:"header_name"`
+	Token           string `json:"-" mapstructure:"token"`
+	Username        string `json:"username" mapstructure:"username"`
+	Password        string `json:"-" mapstructure:"password"`
+	SigningKey      string `json:"-" mapstructure:"signing_key"`
+	Algorithm       string `json:"algorithm" mapstructure:"algorithm"`
+	SignatureHeader string `json:"signatureHeader" mapstructure:"signature_header"`
+	TimestampHeader string `json:"timestampHeader" mapstructure:"timestamp_header"`
+}
+
+// ResolveSecret resolves a secret reference: "env:NAME" reads an environment variable,
+// "file:PATH" reads a file with surrounding whitespace trimmed, and anything else is a literal
+func ResolveSecret(ref string) (string, error) {
+	var value string
+	switch {
+	case strings.HasPrefix(ref, "env:"):
+		value = os.Getenv(strings.TrimPrefix(ref, "env:"))
+		if value == "" {
+			return "", fmt.Errorf("environment variable %s is not set", strings.TrimPrefix(ref, "env:"))
+		}
+	case strings.HasPrefix(ref, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(ref, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("reading secret file: %w", err)
+		}
+		value = strings.TrimSpace(string(data))
+	default:
+		value = ref
+	}
+	if value == "" {
+		return "", fmt.Errorf("secret is empty")
+	}
+	return value, nil
+}
+
+// validate checks the auth type and that every secret the type needs resolves
+func (a *PartnerAuth) validate(partnerID string, apiKey string) error {
+	if a == nil {
+		if apiKey == "" {
+			return fmt.Errorf("missing API key for partner %s", partnerID)
+		}
+		return nil
+	}
+
+	resolve := func(name, ref string) error {
+		if _, err := ResolveSecret(ref); err != nil {
+			return fmt.Errorf("unresolved auth %s for partner %s: %v", name, partnerID, err)
+		}
+		return nil
+	}
+
+	switch a.Type {
+	case AuthBearer:
+		if a.Token == "" {
+			return resolve("API key", apiKey)
+		}
+		return resolve("token", a.Token)
+	case AuthHeader:
+		if a.HeaderName == "" {
+			return fmt.Errorf("missing auth header name for partner %s", partnerID)
+		}
+		if a.Token == "" {
+			return resolve("API key", apiKey)
+		}
+		return resolve("token", a.Token)
+	case AuthBasic:
+		if a.Username == "" {
+			return fmt.Errorf("missing basic auth username for partner %s", partnerID)
+		}
+		return resolve("password", a.Password)
+	case AuthHMAC:
+		switch a.Algorithm {
+		case "", HMACSHA256, HMACSHA512:
+		default:
+			return fmt.Errorf("unknown HMAC algorithm %q for partner %s", a.Algorithm, partnerID)
+		}
+		return resolve("signing key", a.SigningKey)
+	default:
+		return fmt.Errorf("unknown auth type %q for partner %s", a.Type, partnerID)
+	}
+}
+
+// PartnerTLS configures TLS for calls to a partner: a client certificate for mutual TLS, given as
+// files or as secret references holding PEM (see ResolveSecret), a CA bundle used in place of the
+// system roots, and a ServerName override. InsecureSkipVerify also needs AllowInsecurePartnerTLS,
+// which is for non-production environments only.
+type PartnerTLS struct {
+	CertFile           string `json:"certFile" mapstructure:"cert_file"`
+	KeyFile            string `json:"keyFile" mapstructure:"key_file"`
+	Cert               string `json:"-" mapstructure:"cert"`
+	Key                string `json:"-" mapstructure:"key"`
+	CAFile             string `json:"caFile" mapstructure:"ca_file"`
+	ServerName         string `json:"serverName" mapstructure:"server_name"`
+	InsecureSkipVerify bool   `json:"insecureSkipVerify" mapstructure:"insecure_skip_verify"`
+}
+
+// ClientConfig reads the partner's certificates and returns the TLS config for calls to it, with
+// an identity that is equal for configs presenting the same certificates to the same server
+func (t *PartnerTLS) ClientConfig() (*tls.Config, string, error) {
+	tlsConfig := &tls.Config{ServerName: t.ServerName, InsecureSkipVerify: t.InsecureSkipVerify, MinVersion: tls.VersionTLS12}
+	identity := sha256.New()
+	fmt.Fprintf(identity, "%s\x00%t\x00", t.ServerName, t.InsecureSkipVerify)
+
+	certPEM, keyPEM, err := t.clientPEM()
+	if err != nil {
+		return nil, "", err
+	}
+	if certPEM != nil {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, "", fmt.Errorf("loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+		identity.Write(certPEM)
+		identity.Write(keyPEM)
+	}
+	identity.Write([]byte{0})
+
+	if t.CAFile != "" {
+		caPEM, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, "", fmt.Errorf("reading CA bundle: %w", err)
+		}
+		roots := x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(caPEM) {
+			return nil, "", fmt.Errorf("no certificates in CA bundle %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+		identity.Write(caPEM)
+	}
+	return tlsConfig, hex.EncodeToString(identity.Sum(nil)), nil
+}
+
+// clientPEM reads the client certificate and key, returning nil when none is configured
+func (t *PartnerTLS) clientPEM() ([]byte, []byte, error) {
+	files := t.CertFile != "" || t.KeyFile != ""
+	secrets := t.Cert != "" || t.Key != ""
+	switch {
+	case files && secrets:
+		return nil, nil, fmt.Errorf("client certificate set both as files and as secrets")
+	case files:
+		if t.CertFile == "" || t.KeyFile == "" {
+			return nil, nil, fmt.Errorf("client certificate and key files must be set together")
+		}
+		cert, err := os.ReadFile(t.CertFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading client certificate: %w", err)
+		}
+		key, err := os.ReadFile(t.KeyFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("reading client key: %w", err)
+		}
+		return cert, key, nil
+	case secrets:
+		cert, err := ResolveSecret(t.Cert)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unresolved client certificate: %v", err)
+		}
+		key, err := ResolveSecret(t.Key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("unresolved client key: %v", err)
+		}
+		return []byte(cert), []byte(key), nil
+	default:
+		return nil, nil, nil
+	}
+}
+
+// validate loads the partner's certificates, so a missing or malformed one fails at startup and on
+// reload, and requires the global guard for InsecureSkipVerify and https endpoints
+func (t *PartnerTLS) validate(partnerID string, endpoints []EndpointConfig, allowInsecure bool) error {
+	if t == nil {
+		return nil
+	}
+	if t.InsecureSkipVerify && !allowInsecure {
+		return fmt.Errorf("insecure_skip_verify for partner %s requires allow_insecure_partner_tls", partnerID)
+	}
+	for _, endpoint := range endpoints {
+		if !strings.HasPrefix(endpoint.URL, "https://") {
+			return fmt.Errorf("TLS for partner %s needs an https endpoint: %s", partnerID, endpoint.URL)
+		}
+	}
+	if _, _, err := t.ClientConfig(); err != nil {
+		return fmt.Errorf("invalid TLS for partner %s: %v", partnerID, err)
+	}
+	return nil
+}
+
+// Partner wire formats
+const (
+	FormatJSON       = "json"
+	FormatXML        = "xml"
+	FormatMappedJSON = "mapped_json"
+	FormatForm       = "form"
+)
+
+// Partner response validation modes. Strict rejects a response with a field of the wrong type, an
+// unknown field, a missing required field, or a NaN or infinite number. Lenient, the default,
+// converts numeric strings and numeric IDs, drops unknown fields, and rounds prices and scores to
+// four decimal places, rejecting only what it cannot convert.
+const (
+	ResponseValidationStrict  = "strict"
+	ResponseValidationLenient = "lenient"
+)
+
+// Validation returns the partner's response validation mode, defaulting to lenient
+func (p *PartnerConfig) Validation() string {
+	if p.ResponseValidation == "" {
+		return ResponseValidationLenient
+	}
+	return p.ResponseValidation
+}
+
+// Response mapping keys locating an array of bids for multi-seat partners, and a no-bid reason
+// code whose presence marks the response as a no-bid
+const (
+	ResponseFieldBids        = "bids"
+	ResponseFieldNoBidReason = "no_bid_reason"
+)
+
+// FieldMapping translates between our schema and a partner's own field names using dotted paths.
+// Request maps partner fields to bid request paths (e.g. "applicant.zip": "user_data.zip");
+// Response maps bid fields (id, price, click_url, quality_score, quality_acknowledged, adomain,
+// creative, vertical_ext) to partner paths, relative to each entry of the array at the optional "bids" path.
+// A value at the optional "no_bid_reason" path marks the response as a no-bid with that reason code.
+type FieldMapping struct {
+	Request  map[string]string `json:"request" mapstructure:"request"`
+	Response map[string]string `json:"response" mapstructure:"response"`
+}
+
+// validateFormat checks the partner format and requires a mapping for formats that use one
+func (p *PartnerConfig) validateFormat(partnerID string) error {
+	switch p.Format {
+	case "", FormatJSON, FormatXML:
+		return nil
+	case FormatMappedJSON, FormatForm:
+	default:
+		return fmt.Errorf("unknown format %q for partner %s", p.Format, partnerID)
+	}
+
+	if p.FieldMapping == nil || len(p.FieldMapping.Request) == 0 {
+		return fmt.Errorf("format %s requires a request field mapping for partner %s", p.Format, partnerID)
+	}
+	for _, field := range []string{"id", "price", "click_url"} {
+		if p.FieldMapping.Response[field] == "" {
+			return fmt.Errorf("missing response field mapping %q for partner %s", field, partnerID)
+		}
+	}
+	return nil
+}
+
+// PartnerSchedule restricts a partner to active-hours windows in its timezone; nil means always active
+type PartnerSchedule struct {
+	Timezone string           `json:"timezone" mapstructure:"timezone"`
+	Windows  []ScheduleWindow `json:"windows" mapstructure:"windows"`
+}
+
+// ScheduleWindow is an active period from StartHour (inclusive) to EndHour (exclusive) on the
+// given days, or every day when Days is empty
+type ScheduleWindow struct {
+	Days      []string `json:"days" mapstructure:"days"`
+	StartHour int      `json:"startHour" mapstructure:"start_hour"`
+	EndHour   int      `json:"endHour" mapstructure:"end_hour"`
+}
+
+// RedisConfig represents Redis connection configuration
+type RedisConfig struct {
+	Host          string            `json:"host" mapstructure:"host"`
+	Port          int               `json:"port" mapstructure:"port"`
+	Password      string            `json:"password" mapstructure:"password"`
+	Database      int               `json:"database" mapstructure:"database"`
+	Timeout       time.Duration     `json:"timeout" mapstructure:"timeout"`
+	MaxRetries    int               `json:"maxRetries" mapstructure:"max_retries"`
+	RetryInterval time.Duration     `json:"retryInterval" mapstructure:"retry_interval"`
+	StaleReads    *StaleReadsConfig `json:"staleReads" mapstructure:"stale_reads"`
+}
+
+// Defaults for serving Redis-backed reads from the in-process cache
+const (
+	DefaultStaleReadsFreshFor = time.Second
+	DefaultStaleReadsHardTTL  = 30 * time.Second
+)
+
+// StaleReadsConfig serves Redis-backed reads on the auction path, negative cache lookups and
+// spent budget checks, from an in-process cache. Values younger than FreshFor are served as they
+// are; older ones are still served while a refresh runs in the background, until HardTTL, after
+// which the read falls back as if Redis were unavailable. Auctions never wait on Redis for these
+// reads. Zero durations use the defaults.
+type StaleReadsConfig struct {
+	Enabled  bool          `json:"enabled" mapstructure:"enabled"`
+	FreshFor time.Duration `json:"freshFor" mapstructure:"fresh_for"`
+	HardTTL  time.Duration `json:"hardTTL" mapstructure:"hard_ttl"`
+}
+
+// Active reports whether stale reads are enabled
+func (s *StaleReadsConfig) Active() bool {
+	return s != nil && s.Enabled
+}
+
+// Freshness returns how long a cached value is served without a refresh
+func (s *StaleReadsConfig) Freshness() time.Duration {
+	if s == nil || s.FreshFor <= 0 {
+		return DefaultStaleReadsFreshFor
+	}
+	return s.FreshFor
+}
+
+// Expiry returns how long a cached value is served at all
+func (s *StaleReadsConfig) Expiry() time.Duration {
+	if s == nil || s.HardTTL <= 0 {
+		return DefaultStaleReadsHardTTL
+	}
+	return s.HardTTL
+}
+
+// Defaults for persisting partner breaker state to Redis across restarts
+const (
+	DefaultBreakerStateTTL        = 10 * time.Minute
+	DefaultBreakerPersistInterval = 10 * time.Second
+)
+
+// CircuitBreakerConfig controls when a failing partner is temporarily removed from auctions.
+// When Redis is configured, partner breaker state is saved on every transition and every
+// PersistInterval, kept for StateTTL, and restored on startup. Zero durations use the defaults,
+// with the TTL raised to twice the cooldown when that is longer.
+type CircuitBreakerConfig struct {
+	FailureThreshold int           `json:"failureThreshold" mapstructure:"failure_threshold"`
+	Cooldown         time.Duration `json:"cooldown" mapstructure:"cooldown"`
+	StateTTL         time.Duration `json:"stateTTL" mapstructure:"state_ttl"`
+	PersistInterval  time.Duration `json:"persistInterval" mapstructure:"persist_interval"`
+}
+
+// Rate limit backoff defaults and bounds
+const (
+	DefaultRateLimitBackoffBase = time.Second
+	DefaultRateLimitBackoffMax  = 5 * time.Minute
+	maxRateLimitBackoff         = time.Hour
+)
+
+// RateLimitBackoffConfig controls how long a partner that rate-limits us is left out of auctions.
+// A Retry-After hint is honored up to Max; without one the backoff starts at Base and doubles with
+// each consecutive rate limit, up to Max. A nil config or zero fields use the defaults.
+type RateLimitBackoffConfig struct {
+	Base time.Duration `json:"base" mapstructure:"base"`
+	Max  time.Duration `json:"max" mapstructure:"max"`
+}
+
+// Settings returns the backoff settings with the defaults filled in
+func (c *RateLimitBackoffConfig) Settings() RateLimitBackoffConfig {
+	settings := RateLimitBackoffConfig{Base: DefaultRateLimitBackoffBase, Max: DefaultRateLimitBackoffMax}
+	if c == nil {
+		return settings
+	}
+	if c.Base > 0 {
+		settings.Base = c.Base
+	}
+	if c.Max > 0 {
+		settings.Max = c.Max
+	}
+	return settings
+}
+
+// validate checks the backoff starts below its cap and the cap keeps partners out for at most an hour
+func (c *RateLimitBackoffConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.Base < 0 || c.Max < 0 || c.Max > maxRateLimitBackoff {
+		return fmt.Errorf("rate limit backoff must be between 0 and %v: base %v, max %v", maxRateLimitBackoff, c.Base, c.Max)
+	}
+	if settings := c.Settings(); settings.Base > settings.Max {
+		return fmt.Errorf("rate limit backoff base %v exceeds max %v", settings.Base, settings.Max)
+	}
+	return nil
+}
+
+// DefaultVerticalPool is the VerticalLimits key whose limits every unlisted vertical shares
+const DefaultVerticalPool = "default"
+
+// maxVerticalTimeout bounds a vertical's auction timeout override
+const maxVerticalTimeout = 10 * time.Second
+
+// VerticalLimit isolates a vertical's auctions from surges in others. MaxConcurrent caps the
+// vertical's in-flight auctions ahead of the global limit, Timeout replaces the bid timeout for
+// its auctions, and PartnerQPSShare caps the share of each partner's max QPS its auctions may
+// use. Zero leaves a setting unlimited or at the global value.
+type VerticalLimit struct {
+	MaxConcurrent   int           `json:"maxConcurrent" mapstructure:"max_concurrent"`
+	Timeout         time.Duration `json:"timeout" mapstructure:"timeout"`
+	PartnerQPSShare float64       `json:"partnerQpsShare" mapstructure:"partner_qps_share"`
+}
+
+// VerticalPool returns the pool a vertical's auctions are limited in and its limits: the
+// vertical's own entry, or the default pool shared by unlisted verticals. It returns nil limits
+// when neither is configured.
+func (c *Config) VerticalPool(vertical string) (string, *VerticalLimit) {
+	if limit, exists := c.VerticalLimits[vertical]; exists {
+		return vertical, limit
+	}
+	if limit, exists := c.VerticalLimits[DefaultVerticalPool]; exists {
+		return DefaultVerticalPool, limit
+	}
+	return "", nil
+}
+
+// AuctionTimeout returns the timeout for a vertical's auctions, falling back to the bid timeout
+func (c *Config) AuctionTimeout(vertical string) time.Duration {
+	if _, limit := c.VerticalPool(vertical); limit != nil && limit.Timeout > 0 {
+		return limit.Timeout
+	}
+	return c.BidTimeout
+}
+
+// validateVerticalLimits checks every vertical's concurrency cap, timeout, and partner QPS share
+func (c *Config) validateVerticalLimits() error {
+	for vertical, limit := range c.VerticalLimits {
+		if limit == nil {
+			return fmt.Errorf("missing vertical limits for %s", vertical)
+		}
+		if limit.MaxConcurrent < 0 {
+			return fmt.Errorf("invalid max concurrent auctions for vertical %s: %d", vertical, limit.MaxConcurrent)
+		}
+		if limit.Timeout != 0 && (limit.Timeout < 50*time.Millisecond || limit.Timeout > maxVerticalTimeout) {
+			return fmt.Errorf("timeout for vertical %s must be between 50ms and %v: %v", vertical, maxVerticalTimeout, limit.Timeout)
+		}
+		if limit.PartnerQPSShare < 0 || limit.PartnerQPSShare > 1 {
+			return fmt.Errorf("partner QPS share for vertical %s must be between 0 and 1: %v", vertical, limit.PartnerQPSShare)
+		}
+	}
+	return nil
+}
+
+// AdminConfig controls the authenticated admin endpoint group
+type AdminConfig struct {
+	Enabled         bool     `json:"enabled" mapstructure:"enabled"`
+	APIKeys         []string `json:"apiKeys" mapstructure:"api_keys"`
+	EnableProfiling bool     `json:"enableProfiling" mapstructure:"enable_profiling"`
+}
+
+// IdempotencyConfig controls replay of completed auctions for retried request IDs
+type IdempotencyConfig struct {
+	Enabled             bool          `json:"enabled" mapstructure:"enabled"`
+	Window              time.Duration `json:"window" mapstructure:"window"`
+	MaxEntries          int           `json:"maxEntries" mapstructure:"max_entries"`
+	RejectReusedUnknown bool          `json:"rejectReusedUnknown" mapstructure:"reject_reused_unknown"`
+	// FingerprintFields are the UserData fields fingerprinted with the lead ID and vertical; a
+	// reused request ID whose fingerprint differs is rejected instead of replayed. Defaults to
+	// zip, state, email, and phone.
+	FingerprintFields []string `json:"fingerprintFields" mapstructure:"fingerprint_fields"`
+}
+
+// Batch priority modes
+const (
+	BatchPriorityEqual = "equal"
+	BatchPriorityLow   = "low"
+)
+
+// Bid optimization strategy names, selected per vertical via Config.Strategies
+const (
+	StrategyEffectivePrice  = "effective_price"
+	StrategyQualityWeighted = "quality_weighted"
+	StrategyPassthrough     = "passthrough"
+
+	// DefaultStrategyKey selects the strategy for verticals without their own entry
+	DefaultStrategyKey = "default"
+)
+
+// maxResponseTTL bounds how long an auction result may be cached downstream
+const maxResponseTTL = 24 * time.Hour
+
+// ResponseTTL returns how long a vertical's auction results stay valid, falling back to the
+// "default" entry. Zero means results are only limited by partner expiries.
+func (c *Config) ResponseTTL(vertical string) time.Duration {
+	if ttl, exists := c.ResponseTTLs[vertical]; exists {
+		return ttl
+	}
+	return c.ResponseTTLs["default"]
+}
+
+// validateResponseTTLs checks that every response TTL is within bounds
+func (c *Config) validateResponseTTLs() error {
+	for vertical, ttl := range c.ResponseTTLs {
+		if ttl < 0 || ttl > maxResponseTTL {
+			return fmt.Errorf("response TTL for vertical %s must be between 0 and %v: %v", vertical, maxResponseTTL, ttl)
+		}
+	}
+	return nil
+}
+
+// Winner diversity modes of a vertical's auctions
+const (
+	DiversityPartner = "partner" // at most one winner per partner
+	DiversityNone    = "none"    // a partner's seats may win several slots
+)
+
+// VerticalConfig tunes the auctions of one vertical. MaxBids replaces max_bids_per_request,
+// Diversity chooses whether a partner may win more than one slot, Floor is a minimum open auction
+// bids must meet alongside any adaptive floor, ResponseTTL replaces the vertical's response_ttl
+// entry, and Strategy replaces its strategies entry. Zero values keep the global setting.
+type VerticalConfig struct {
+	MaxBids     int           `json:"maxBids" mapstructure:"max_bids"`
+	Diversity   string        `json:"diversity" mapstructure:"diversity"`
+	Floor       float64       `json:"floor" mapstructure:"floor"`
+	ResponseTTL time.Duration `json:"responseTtl" mapstructure:"response_ttl"`
+	Strategy    string        `json:"strategy" mapstructure:"strategy"`
+}
+
+// EffectiveVertical is what a vertical's auctions run with once its Verticals entry is laid over
+// the global settings. Overridden reports whether the vertical has an entry.
+type EffectiveVertical struct {
+	Vertical    string        `json:"vertical"`
+	Overridden  bool          `json:"overridden"`
+	MaxBids     int           `json:"maxBids"`
+	Diversity   string        `json:"diversity"`
+	Floor       float64       `json:"floor"`
+	ResponseTTL time.Duration `json:"responseTtl"`
+	Strategy    string        `json:"strategy"`
+}
+
+// EffectiveVertical resolves the auction settings of a vertical, falling back to
+// max_bids_per_request, partner diversity, no vertical floor, the response_ttl entries, and the
+// strategies entries
+func (c *Config) EffectiveVertical(vertical string) EffectiveVertical {
+	effective := EffectiveVertical{
+		Vertical:    vertical,
+		MaxBids:     c.MaxBidsPerRequest,
+		Diversity:   DiversityPartner,
+		ResponseTTL: c.ResponseTTL(vertical),
+		Strategy:    c.strategyName(vertical),
+	}
+	settings := c.Verticals[vertical]
+	if settings == nil {
+		return effective
+	}
+	effective.Overridden = true
+	if settings.MaxBids > 0 {
+		effective.MaxBids = settings.MaxBids
+	}
+	if settings.Diversity != "" {
+		effective.Diversity = settings.Diversity
+	}
+	effective.Floor = settings.Floor
+	if settings.ResponseTTL > 0 {
+		effective.ResponseTTL = settings.ResponseTTL
+	}
+	if settings.Strategy != "" {
+		effective.Strategy = settings.Strategy
+	}
+	return effective
+}
+
+// strategyName returns the strategies entry of a vertical, falling back to the default entry
+// and then to the effective price strategy
+func (c *Config) strategyName(vertical string) string {
+	if strategy, exists := c.Strategies[vertical]; exists {
+		return strategy
+	}
+	if strategy, exists := c.Strategies[DefaultStrategyKey]; exists {
+		return strategy
+	}
+	return StrategyEffectivePrice
+}
+
+// VerticalStrategies returns the strategies entries with each vertical's own strategy laid over
+// them, as the bid optimizer selects strategies by vertical
+func (c *Config) VerticalStrategies() map[string]string {
+	strategies := make(map[string]string, len(c.Strategies)+len(c.Verticals))
+	for vertical, strategy := range c.Strategies {
+		strategies[vertical] = strategy
+	}
+	for vertical, settings := range c.Verticals {
+		if settings != nil && settings.Strategy != "" {
+			strategies[vertical] = settings.Strategy
+		}
+	}
+	return strategies
+}
+
+// ValidateVerticals checks every vertical's settings, and that none contradicts a global one: a
+// floor must be below the max bid price, and a strategy or response TTL may only be set for a
+// vertical in one place
+func (c *Config) ValidateVerticals() error {
+	for vertical, settings := range c.Verticals {
+		if settings == nil {
+			return fmt.Errorf("missing vertical settings for %s", vertical)
+		}
+		if vertical == DefaultStrategyKey {
+			return fmt.Errorf("vertical settings cannot use the reserved name %q: global settings apply to unlisted verticals", vertical)
+		}
+		if settings.MaxBids < 0 {
+			return fmt.Errorf("invalid max bids for vertical %s: %d", vertical, settings.MaxBids)
+		}
+		switch settings.Diversity {
+		case "", DiversityPartner, DiversityNone:
+		default:
+			return fmt.Errorf("unknown diversity mode %q for vertical %s", settings.Diversity, vertical)
+		}
+		if settings.Floor < 0 || math.IsNaN(settings.Floor) {
+			return fmt.Errorf("invalid floor for vertical %s: %v", vertical, settings.Floor)
+		}
+		if settings.Floor >= c.MaxBidPrice {
+			return fmt.Errorf("floor %v for vertical %s is at or above the max bid price %v", settings.Floor, vertical, c.MaxBidPrice)
+		}
+		if settings.ResponseTTL < 0 || settings.ResponseTTL > maxResponseTTL {
+			return fmt.Errorf("response TTL for vertical %s must be between 0 and %v: %v", vertical, maxResponseTTL, settings.ResponseTTL)
+		}
+		if _, exists := c.ResponseTTLs[vertical]; exists && settings.ResponseTTL > 0 {
+			return fmt.Errorf("response TTL for vertical %s is set in both verticals and response_ttl", vertical)
+		}
+		switch settings.Strategy {
+		case "", StrategyEffectivePrice, StrategyQualityWeighted, StrategyPassthrough:
+		default:
+			return fmt.Errorf("unknown optimization strategy %q for vertical %s", settings.Strategy, vertical)
+		}
+		if _, exists := c.Strategies[vertical]; exists && settings.Strategy != "" {
+			return fmt.Errorf("optimization strategy for vertical %s is set in both verticals and strategies", vertical)
+		}
+	}
+	return nil
+}
+
+// LocalizationConfig controls localized display fields. Winning bids whose creatives carry
+// localized variants get their title and description from the variant best matching the caller's
+// Accept-Language, falling back to DefaultLanguage when no preferred language is offered.
+type LocalizationConfig struct {
+	Enabled         bool   `json:"enabled" mapstructure:"enabled"`
+	DefaultLanguage string `json:"defaultLanguage" mapstructure:"default_language"`
+}
+
+// languageTagPattern matches BCP 47 language tags: a 2-3 letter language followed by optional
+// script, region, and variant subtags
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{1,8})*$`)
+
+// ValidLanguageTag reports whether tag is a well-formed language tag such as "fr" or "fr-CA"
+func ValidLanguageTag(tag string) bool {
+	return len(tag) <= 35 && languageTagPattern.MatchString(tag)
+}
+
+// CanonicalLanguageTag returns tag in its conventional case: a lowercase language, title-case
+// script, uppercase region, and lowercase variants and extensions, as in "zh-Hant-TW"
+func CanonicalLanguageTag(tag string) string {
+	subtags := strings.Split(tag, "-")
+	extension := false
+	for i, subtag := range subtags {
+		extension = extension || len(subtag) == 1
+		switch {
+		case i == 0 || extension:
+			subtags[i] = strings.ToLower(subtag)
+		case len(subtag) == 4:
+			subtags[i] = strings.ToUpper(subtag[:1]) + strings.ToLower(subtag[1:])
+		case len(subtag) == 2 || (len(subtag) == 3 && subtag[0] >= '0' && subtag[0] <= '9'):
+			subtags[i] = strings.ToUpper(subtag)
+		default:
+			subtags[i] = strings.ToLower(subtag)
+		}
+	}
+	return strings.Join(subtags, "-")
+}
+
+// validate checks the default language is a well-formed tag
+func (l *LocalizationConfig) validate() error {
+	if l == nil || !l.Enabled || l.DefaultLanguage == "" {
+		return nil
+	}
+	if !ValidLanguageTag(l.DefaultLanguage) {
+		return fmt.Errorf("invalid default language: %q", l.DefaultLanguage)
+	}
+	return nil
+}
+
+// JSON codec names for bid requests and responses, selected via Config.JSONCodec. The standard
+// library codec is the default and the reference the fast codec is tested against.
+const (
+	JSONCodecStd  = "std"
+	JSONCodecFast = "fast"
+)
+
+// Price rounding modes, selected via PriceRoundingConfig.Mode. Half-up rounds ties away from zero;
+// floor never rounds a price up, so a partner is never charged more than it bid.
+const (
+	RoundingHalfUp = "half_up"
+	RoundingFloor  = "floor"
+)
+
+// Price precision bounds in decimal places
+const (
+	DefaultPricePrecision = 2
+	MaxPricePrecision     = 6
+)
+
+// PriceRoundingConfig controls how clearing prices are rounded once winners are chosen. Ranking
+// always uses full precision; a nil config rounds half-up to cents.
+type PriceRoundingConfig struct {
+	Precision int    `json:"precision" mapstructure:"precision"`
+	Mode      string `json:"mode" mapstructure:"mode"`
+}
+
+// CompressionConfig controls gzip handling of inbound requests and our responses.
+// Partner calls opt in separately with PartnerConfig.Gzip.
+type CompressionConfig struct {
+	DecompressRequests   bool  `json:"decompressRequests" mapstructure:"decompress_requests"`
+	CompressResponses    bool  `json:"compressResponses" mapstructure:"compress_responses"`
+	MaxDecompressedBytes int64 `json:"maxDecompressedBytes" mapstructure:"max_decompressed_bytes"`
+}
+
+// HTTPClientConfig tunes the partner HTTP transport; nil keeps Go's default transport settings
+type HTTPClientConfig struct {
+	MaxIdleConns        int           `json:"maxIdleConns" mapstructure:"max_idle_conns"`
+	MaxIdleConnsPerHost int           `json:"maxIdleConnsPerHost" mapstructure:"max_idle_conns_per_host"`
+	IdleConnTimeout     time.Duration `json:"idleConnTimeout" mapstructure:"idle_conn_timeout"`
+	TLSHandshakeTimeout time.Duration `json:"tlsHandshakeTimeout" mapstructure:"tls_handshake_timeout"`
+	EnableHTTP2         bool          `json:"enableHttp2" mapstructure:"enable_http2"`
+	DNSCacheTTL         time.Duration `json:"dnsCacheTTL" mapstructure:"dns_cache_ttl"`
+}
+
+// AnalyticsConfig bounds the in-memory clearing-price aggregates behind the price analytics endpoint.
+// Each tracked vertical or vertical and region keeps one histogram per hour for the last Windows hours.
+type AnalyticsConfig struct {
+	Enabled   bool    `json:"enabled" mapstructure:"enabled"`
+	Windows   int     `json:"windows" mapstructure:"windows"`
+	MaxSeries int     `json:"maxSeries" mapstructure:"max_series"`
+	Precision float64 `json:"precision" mapstructure:"precision"`
+	ByRegion  bool    `json:"byRegion" mapstructure:"by_region"`
+	Floors    *AdaptiveFloorConfig `json:"floors" mapstructure:"floors"`
+}
+
+// AdaptiveFloorConfig learns floors from the price analytics: the Percentile of clearing prices over
+// the last Window for each vertical, and each vertical and region when ByRegion, bounded by MinFloor
+// and MaxFloor. Series with fewer than MinSamples clearing prices fall back to the static floor.
+// Floors are recomputed every RefreshInterval and only enforced on auctions when Apply is set.
+type AdaptiveFloorConfig struct {
+	Enabled         bool          `json:"enabled" mapstructure:"enabled"`
+	Apply           bool          `json:"apply" mapstructure:"apply"`
+	Percentile      float64       `json:"percentile" mapstructure:"percentile"`
+	Window          time.Duration `json:"window" mapstructure:"window"`
+	MinSamples      int           `json:"minSamples" mapstructure:"min_samples"`
+	MinFloor        float64       `json:"minFloor" mapstructure:"min_floor"`
+	MaxFloor        float64       `json:"maxFloor" mapstructure:"max_floor"`
+	ByRegion        bool          `json:"byRegion" mapstructure:"by_region"`
+	RefreshInterval time.Duration `json:"refreshInterval" mapstructure:"refresh_interval"`
+}
+
+// validate checks adaptive floors against the analytics they are learned from
+func (f *AdaptiveFloorConfig) validate(c *Config) error {
+	if f == nil || !f.Enabled {
+		return nil
+	}
+	if f.Percentile <= 0 || f.Percentile >= 1 {
+		return fmt.Errorf("adaptive floor percentile must be between 0 and 1: %v", f.Percentile)
+	}
+	if f.Window < time.Hour || f.Window > time.Duration(c.Analytics.Windows)*time.Hour {
+		return fmt.Errorf("adaptive floor window must be between 1h and the %dh of analytics windows: %v", c.Analytics.Windows, f.Window)
+	}
+	if f.MinSamples < 1 {
+		return fmt.Errorf("adaptive floor min samples must be positive: %d", f.MinSamples)
+	}
+	if f.MinFloor < 0 || f.MinFloor > f.MaxFloor || f.MaxFloor > c.MaxBidPrice {
+		return fmt.Errorf("adaptive floor bounds must satisfy 0 <= min <= max <= max bid price: min=%v, max=%v", f.MinFloor, f.MaxFloor)
+	}
+	if f.ByRegion && !c.Analytics.ByRegion {
+		return fmt.Errorf("regional adaptive floors require regional analytics")
+	}
+	if f.RefreshInterval < time.Second || f.RefreshInterval > time.Hour {
+		return fmt.Errorf("adaptive floor refresh interval must be between 1s and 1h: %v", f.RefreshInterval)
+	}
+	return nil
+}
+
+// AuditConfig controls the append-only audit log of winning bids. The file rotates at MaxSizeBytes,
+// keeping MaxFiles rotated files; SigningKey, a secret reference, adds an HMAC signature to each record.
+type AuditConfig struct {
+	Enabled      bool   `json:"enabled" mapstructure:"enabled"`
+	Path         string `json:"path" mapstructure:"path"`
+	MaxSizeBytes int64  `json:"maxSizeBytes" mapstructure:"max_size_bytes"`
+	MaxFiles     int    `json:"maxFiles" mapstructure:"max_files"`
+	Compress     bool   `json:"compress" mapstructure:"compress"`
+	BufferSize   int    `json:"bufferSize" mapstructure:"buffer_size"`
+	SigningKey   string `json:"-" mapstructure:"signing_key"`
+}
+
+// RecordingConfig controls sampled capture of auctions and their raw partner responses for replay.
+// Recording stops once the file reaches MaxSizeBytes.
+type RecordingConfig struct {
+	Enabled      bool    `json:"enabled" mapstructure:"enabled"`
+	SampleRate   float64 `json:"sampleRate" mapstructure:"sample_rate"`
+	Path         string  `json:"path" mapstructure:"path"`
+	MaxSizeBytes int64   `json:"maxSizeBytes" mapstructure:"max_size_bytes"`
+	BufferSize   int     `json:"bufferSize" mapstructure:"buffer_size"`
+}
+
+// Export file formats
+const (
+	ExportFormatNDJSON = "ndjson"
+	ExportFormatCSV    = "csv"
+)
+
+// ExportConfig controls sampled exports of auction outcomes for offline model training. Rows
+// are grouped into files by Window, written to Dir or an S3-compatible bucket once their window
+// ends and checked for every FlushInterval, and split into parts of at most MaxFileRows rows.
+type ExportConfig struct {
+	Enabled       bool            `json:"enabled" mapstructure:"enabled"`
+	SampleRate    float64         `json:"sampleRate" mapstructure:"sample_rate"`
+	Format        string          `json:"format" mapstructure:"format"`
+	Window        time.Duration   `json:"window" mapstructure:"window"`
+	FlushInterval time.Duration   `json:"flushInterval" mapstructure:"flush_interval"`
+	MaxFileRows   int             `json:"maxFileRows" mapstructure:"max_file_rows"`
+	BufferSize    int             `json:"bufferSize" mapstructure:"buffer_size"`
+	Dir           string          `json:"dir" mapstructure:"dir"`
+	S3            *S3ExportConfig `json:"s3" mapstructure:"s3"`
+}
+
+// S3ExportConfig addresses an S3-compatible bucket by path-style URLs. The keys are secret
+// references (see ResolveSecret).
+type S3ExportConfig struct {
+	Endpoint        string `json:"endpoint" mapstructure:"endpoint"`
+	Bucket          string `json:"bucket" mapstructure:"bucket"`
+	Prefix          string `json:"prefix" mapstructure:"prefix"`
+	Region          string `json:"region" mapstructure:"region"`
+	AccessKeyID     string `json:"-" mapstructure:"access_key_id"`
+	SecretAccessKey string `json:"-" mapstructure:"secret_access_key"`
+}
+
+// SelfTestConfig controls the startup self-test, which runs Auctions synthetic auctions against
+// simulated partners before the service reports ready
+type SelfTestConfig struct {
+	Enabled  bool `json:"enabled" mapstructure:"enabled"`
+	Auctions int  `json:"auctions" mapstructure:"auctions"`
+}
+
+// Canary partner modes
+const (
+	CanaryPartnersSimulated = "simulated"
+	CanaryPartnersReal      = "real"
+)
+
+// Canary defaults and bounds
+const (
+	DefaultCanaryInterval = time.Minute
+	DefaultCanaryVertical = "canary"
+	minCanaryInterval     = 5 * time.Second
+	maxCanaryInterval     = time.Hour
+)
+
+// CanaryConfig controls canary auctions, synthetic auctions run every Interval through the full
+// pipeline so alerting sees a broken auction path before customers do. Partners is simulated
+// (default), for in-process partners like the self-test's, or real, for the partners opted in
+// with canary: true.
+type CanaryConfig struct {
+	Enabled  bool          `json:"enabled" mapstructure:"enabled"`
+	Interval time.Duration `json:"interval" mapstructure:"interval"`
+	Vertical string        `json:"vertical" mapstructure:"vertical"`
+	Partners string        `json:"partners" mapstructure:"partners"`
+}
+
+// RunInterval returns the time between canary auctions, defaulting to DefaultCanaryInterval
+func (c *CanaryConfig) RunInterval() time.Duration {
+	if c.Interval <= 0 {
+		return DefaultCanaryInterval
+	}
+	return c.Interval
+}
+
+// AuctionVertical returns the vertical canary auctions run in, defaulting to DefaultCanaryVertical
+func (c *CanaryConfig) AuctionVertical() string {
+	if c.Vertical == "" {
+		return DefaultCanaryVertical
+	}
+	return c.Vertical
+}
+
+// PartnerMode returns where canary auctions find partners, defaulting to simulated ones
+func (c *CanaryConfig) PartnerMode() string {
+	if c.Partners == "" {
+		return CanaryPartnersSimulated
+	}
+	return c.Partners
+}
+
+// validate checks the interval and partner mode, and that real canaries have a partner to call
+func (c *CanaryConfig) validate(partners map[string]*PartnerConfig) error {
+	if c == nil || !c.Enabled {
+		return nil
+	}
+	if c.Interval != 0 && (c.Interval < minCanaryInterval || c.Interval > maxCanaryInterval) {
+		return fmt.Errorf("canary interval must be between %v and %v: %v", minCanaryInterval, maxCanaryInterval, c.Interval)
+	}
+	switch c.PartnerMode() {
+	case CanaryPartnersSimulated:
+	case CanaryPartnersReal:
+		optedIn := false
+		for _, partner := range partners {
+			optedIn = optedIn || (partner.Enabled && partner.Canary)
+		}
+		if !optedIn {
+			return fmt.Errorf("canary runs against real partners but no enabled partner has canary set")
+		}
+	default:
+		return fmt.Errorf("unknown canary partner mode %q", c.Partners)
+	}
+	return nil
+}
+
+// EnvironmentVar names the deployment environment, such as staging or production
+const EnvironmentVar = "RTB_ENVIRONMENT"
+
+// productionEnvironments are the EnvironmentVar values that hard-disable fault injection
+var productionEnvironments = map[string]bool{"prod": true, "production": true}
+
+// FaultInjectionConfig lets admins inject partner latency, errors, malformed responses, and
+// Redis failures through the admin API, for resilience testing in staging. Injection stays off
+// in production whatever Enabled says: see Allowed.
+type FaultInjectionConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+}
+
+// Allowed reports whether faults may be injected: they must be enabled, and EnvironmentVar must
+// not name a production environment
+func (f *FaultInjectionConfig) Allowed() bool {
+	return f != nil && f.Enabled && !f.Guarded()
+}
+
+// Guarded reports whether fault injection is enabled but hard-disabled by the environment guard
+func (f *FaultInjectionConfig) Guarded() bool {
+	environment := strings.ToLower(strings.TrimSpace(os.Getenv(EnvironmentVar)))
+	return f != nil && f.Enabled && productionEnvironments[environment]
+}
+
+// Asset verification defaults and bounds
+const (
+	DefaultAssetSampleRate      = 0.1
+	DefaultAssetTimeout         = 2 * time.Second
+	DefaultAssetRateLimit       = 5.0
+	DefaultAssetMaxBytes        = 5 << 20
+	DefaultAssetFlagFailureRate = 0.2
+	DefaultAssetMinChecks       = 20
+	maxAssetTimeout             = 10 * time.Second
+)
+
+// AssetVerificationConfig controls the asset verifier, which after auctions complete samples
+// SampleRate of winning bids and checks their creative image with a HEAD request, off the auction
+// path. Checks are limited to RateLimit per second; a partner whose failure rate over its recent
+// checks reaches FlagFailureRate, once it has MinChecks, is flagged, and with Webhook set a
+// partner.assets_flagged event is sent.
+type AssetVerificationConfig struct {
+	Enabled         bool          `json:"enabled" mapstructure:"enabled"`
+	SampleRate      float64       `json:"sampleRate" mapstructure:"sample_rate"`
+	Timeout         time.Duration `json:"timeout" mapstructure:"timeout"`
+	RateLimit       float64       `json:"rateLimit" mapstructure:"rate_limit"`
+	MaxBytes        int64         `json:"maxBytes" mapstructure:"max_bytes"`
+	FlagFailureRate float64       `json:"flagFailureRate" mapstructure:"flag_failure_rate"`
+	MinChecks       int           `json:"minChecks" mapstructure:"min_checks"`
+	Webhook         bool          `json:"webhook" mapstructure:"webhook"`
+}
+
+// CheckSampleRate returns the share of winning bids checked, defaulting to DefaultAssetSampleRate
+func (a *AssetVerificationConfig) CheckSampleRate() float64 {
+	if a.SampleRate <= 0 {
+		return DefaultAssetSampleRate
+	}
+	return a.SampleRate
+}
+
+// CheckTimeout returns how long one check may take, defaulting to DefaultAssetTimeout
+func (a *AssetVerificationConfig) CheckTimeout() time.Duration {
+	if a.Timeout <= 0 {
+		return DefaultAssetTimeout
+	}
+	return a.Timeout
+}
+
+// CheckRateLimit returns the checks allowed per second, defaulting to DefaultAssetRateLimit
+func (a *AssetVerificationConfig) CheckRateLimit() float64 {
+	if a.RateLimit <= 0 {
+		return DefaultAssetRateLimit
+	}
+	return a.RateLimit
+}
+
+// MaxAssetBytes returns the largest image accepted, defaulting to DefaultAssetMaxBytes
+func (a *AssetVerificationConfig) MaxAssetBytes() int64 {
+	if a.MaxBytes <= 0 {
+		return DefaultAssetMaxBytes
+	}
+	return a.MaxBytes
+}
+
+// FlagThreshold returns the failure rate that flags a partner, defaulting to
+// DefaultAssetFlagFailureRate
+func (a *AssetVerificationConfig) FlagThreshold() float64 {
+	if a.FlagFailureRate <= 0 {
+		return DefaultAssetFlagFailureRate
+	}
+	return a.FlagFailureRate
+}
+
+// FlagMinChecks returns the checks a partner needs before it can be flagged, defaulting to
+// DefaultAssetMinChecks
+func (a *AssetVerificationConfig) FlagMinChecks() int {
+	if a.MinChecks <= 0 {
+		return DefaultAssetMinChecks
+	}
+	return a.MinChecks
+}
+
+// validate checks the sample rate, timeout, and flag threshold
+func (a *AssetVerificationConfig) validate() error {
+	if a == nil || !a.Enabled {
+		return nil
+	}
+	if a.SampleRate < 0 || a.SampleRate > 1 {
+		return fmt.Errorf("asset verification sample rate must be in [0, 1]: %v", a.SampleRate)
+	}
+	if a.Timeout < 0 || a.Timeout > maxAssetTimeout {
+		return fmt.Errorf("asset verification timeout must be between 0 and %v: %v", maxAssetTimeout, a.Timeout)
+	}
+	if a.RateLimit < 0 || a.MaxBytes < 0 || a.MinChecks < 0 {
+		return fmt.Errorf("asset verification rate limit, max bytes, and min checks must not be negative")
+	}
+	if a.FlagFailureRate < 0 || a.FlagFailureRate > 1 {
+		return fmt.Errorf("asset verification flag failure rate must be in [0, 1]: %v", a.FlagFailureRate)
+	}
+	return nil
+}
+
+// EarlyTerminationConfig controls early termination of bid collection. In single-winner auctions
+// ranked by effective price, once a bid in hand is certain to outrank anything the partners still
+// being waited on could return, their calls are cancelled rather than waited out. Enabling it also
+// enforces each partner's MaxBid, since a pending partner's best possible bid is bounded by it.
+type EarlyTerminationConfig struct {
+	Enabled bool `json:"enabled" mapstructure:"enabled"`
+}
+
+// EarlyTerminationEnabled reports whether bid collection may end before every partner answers
+func (c *Config) EarlyTerminationEnabled() bool {
+	return c.EarlyTermination != nil && c.EarlyTermination.Enabled
+}
+
+// DefaultAuctionSeqBatchSize is how many auction sequence numbers an instance reserves at once
+const DefaultAuctionSeqBatchSize = 1000
+
+// maxAuctionSeqBatchSize bounds a reservation, and so the numbers a crash can leave unused
+const maxAuctionSeqBatchSize = 100000
+
+// AuctionSequenceConfig controls auction sequence numbers. Each auction with an outcome gets the
+// next number of a counter in Redis, which instances reserve BatchSize numbers of at a time.
+// Without Redis, or while it cannot be reached, auctions get a ULID instead.
+type AuctionSequenceConfig struct {
+	Enabled   bool  `json:"enabled" mapstructure:"enabled"`
+	BatchSize int64 `json:"batchSize" mapstructure:"batch_size"`
+}
+
+// ReservedNumbers returns how many numbers a reservation takes, defaulting to DefaultAuctionSeqBatchSize
+func (a *AuctionSequenceConfig) ReservedNumbers() int64 {
+	if a.BatchSize <= 0 {
+		return DefaultAuctionSeqBatchSize
+	}
+	return a.BatchSize
+}
+
+// validate checks the batch size
+func (a *AuctionSequenceConfig) validate() error {
+	if a == nil || !a.Enabled {
+		return nil
+	}
+	if a.BatchSize < 0 || a.BatchSize > maxAuctionSeqBatchSize {
+		return fmt.Errorf("auction sequence batch size must be between 0 and %d: %d", maxAuctionSeqBatchSize, a.BatchSize)
+	}
+	return nil
+}
+
+// Bid rule actions
+const (
+	RuleActionAccept      = "accept"       // keep the bid and check no further rules
+	RuleActionReject      = "reject"       // drop the bid, with the rule as its loss reason
+	RuleActionAdjustPrice = "adjust_price" // scale the bid's price by PriceMultiplier and go on to the next rule
+)
+
+// ruleIDPattern keeps rule IDs usable in loss reasons and metric labels
+var ruleIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// RulesConfig holds business rules on bids, written as conditions rather than code. Every valid
+// bid is checked against Bids in order as it arrives, with time fields read in Timezone, or UTC
+// when it is empty.
+type RulesConfig struct {
+	Timezone string    `json:"timezone" mapstructure:"timezone"`
+	Bids     []BidRule `json:"bids" mapstructure:"bids"`
+}
+
+// BidRule applies Action to the bids matching When, a condition in the rules language. ID names
+// the rule in loss reasons and metrics.
+type BidRule struct {
+	ID              string  `json:"id" mapstructure:"id"`
+	When            string  `json:"when" mapstructure:"when"`
+	Action          string  `json:"action" mapstructure:"action"`
+	PriceMultiplier float64 `json:"priceMultiplier" mapstructure:"price_multiplier"`
+}
+
+// Location returns the time zone time fields are read in
+func (r *RulesConfig) Location() *time.Location {
+	if r == nil || r.Timezone == "" {
+		return time.UTC
+	}
+	location, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return location
+}
+
+// validate compiles every rule's condition, so a rule that does not compile fails the config with
+// its ID and the position of the problem
+func (r *RulesConfig) validate() error {
+	if r == nil {
+		return nil
+	}
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return fmt.Errorf("invalid rules timezone %q: %v", r.Timezone, err)
+	}
+	ids := make(map[string]bool, len(r.Bids))
+	for i, rule := range r.Bids {
+		if !ruleIDPattern.MatchString(rule.ID) {
+			return fmt.Errorf("bid rule %d has invalid id %q: use lowercase letters, digits, '-', and '_'", i+1, rule.ID)
+		}
+		if ids[rule.ID] {
+			return fmt.Errorf("duplicate bid rule id %s", rule.ID)
+		}
+		ids[rule.ID] = true
+		if _, err := rules.Compile(rule.When); err != nil {
+			return fmt.Errorf("invalid condition for bid rule %s: %w", rule.ID, err)
+		}
+		switch rule.Action {
+		case RuleActionAccept, RuleActionReject:
+			if rule.PriceMultiplier != 0 {
+				return fmt.Errorf("bid rule %s sets a price multiplier without the %s action", rule.ID, RuleActionAdjustPrice)
+			}
+		case RuleActionAdjustPrice:
+			if rule.PriceMultiplier <= 0 {
+				return fmt.Errorf("bid rule %s needs a positive price multiplier: %v", rule.ID, rule.PriceMultiplier)
+			}
+		default:
+			return fmt.Errorf("unknown action %q for bid rule %s", rule.Action, rule.ID)
+		}
+	}
+	return nil
+}
+
+// Partner warmup methods
+const (
+	WarmupConnect = "connect" // resolve DNS and complete the TCP and TLS handshakes without a request
+	WarmupHead    = "head"    // send HEAD requests, leaving their connections idle in the pool
+	WarmupOptions = "options" // send OPTIONS requests, leaving their connections idle in the pool
+)
+
+// Partner warmup defaults
+const (
+	DefaultWarmupConnections = 2
+	DefaultWarmupTimeout     = 5 * time.Second
+)
+
+// defaultIdleConnsPerHost is the transport's idle connection limit per host when the HTTP client
+// config sets none, matching http.DefaultMaxIdleConnsPerHost
+const defaultIdleConnsPerHost = 2
+
+// WarmupConfig controls partner connection warmup, which runs at startup before the service
+// reports ready. Each enabled partner's endpoint hosts get Connections keep-alive connections by
+// the partner's warmup method, and the whole warmup gives up after Timeout so a dead partner cannot
+// hold up startup.
+type WarmupConfig struct {
+	Enabled     bool          `json:"enabled" mapstructure:"enabled"`
+	Connections int           `json:"connections" mapstructure:"connections"`
+	Timeout     time.Duration `json:"timeout" mapstructure:"timeout"`
+}
+
+// ConnectionCount returns the connections to open per host, defaulting to DefaultWarmupConnections
+func (w *WarmupConfig) ConnectionCount() int {
+	if w.Connections <= 0 {
+		return DefaultWarmupConnections
+	}
+	return w.Connections
+}
+
+// WarmupTimeout returns the bound on the whole warmup, defaulting to DefaultWarmupTimeout
+func (w *WarmupConfig) WarmupTimeout() time.Duration {
+	if w.Timeout <= 0 {
+		return DefaultWarmupTimeout
+	}
+	return w.Timeout
+}
+
+// validate checks the warmup connections fit the idle pool and the timeout is bounded
+func (w *WarmupConfig) validate(httpClient *HTTPClientConfig) error {
+	if w == nil || !w.Enabled {
+		return nil
+	}
+	idlePerHost := defaultIdleConnsPerHost
+	if httpClient != nil {
+		idlePerHost = httpClient.MaxIdleConnsPerHost
+	}
+	if w.Connections < 0 || w.Connections > idlePerHost {
+		return fmt.Errorf("warmup connections must be between 0 and the %d idle connections kept per host: %d", idlePerHost, w.Connections)
+	}
+	if w.Timeout < 0 || w.Timeout > 30*time.Second {
+		return fmt.Errorf("warmup timeout must be between 0 and 30s: %v", w.Timeout)
+	}
+	return nil
+}
+
+// EstimatesConfig controls pre-bid value estimates. Estimates come from the p25 to p75 clearing
+// prices of the last Window of auctions when at least MinSamples prices were recorded, and from the
+// Static range for the vertical otherwise.
+type EstimatesConfig struct {
+	Enabled               bool                      `json:"enabled" mapstructure:"enabled"`
+	Window                time.Duration             `json:"window" mapstructure:"window"`
+	MinSamples            int                       `json:"minSamples" mapstructure:"min_samples"`
+	HighConfidenceSamples int                       `json:"highConfidenceSamples" mapstructure:"high_confidence_samples"`
+	CacheMaxAge           time.Duration             `json:"cacheMaxAge" mapstructure:"cache_max_age"`
+	Static                map[string]StaticEstimate `json:"static" mapstructure:"static"`
+}
+
+// StaticEstimate is the configured value range for a vertical
+type StaticEstimate struct {
+	Low  float64 `json:"low" mapstructure:"low"`
+	High float64 `json:"high" mapstructure:"high"`
+}
+
+// ReservationsConfig controls two-step auctions whose winners are held for TTL until confirmed.
+// Reservations are kept in Redis when configured, otherwise in memory up to MaxEntries; expired
+// reservations are found every SweepInterval.
+type ReservationsConfig struct {
+	Enabled       bool          `json:"enabled" mapstructure:"enabled"`
+	TTL           time.Duration `json:"ttl" mapstructure:"ttl"`
+	SweepInterval time.Duration `json:"sweepInterval" mapstructure:"sweep_interval"`
+	MaxEntries    int           `json:"maxEntries" mapstructure:"max_entries"`
+}
+
+// Webhook event types
+const (
+	WebhookEventBidWon               = "bid.won"
+	WebhookEventBidLost              = "bid.lost"
+	WebhookEventAuctionCompleted     = "auction.completed"
+	WebhookEventReservationAbandoned = "reservation.abandoned"
+	WebhookEventPartnerAssetsFlagged = "partner.assets_flagged"
+	WebhookEventConfigChanged        = "config.changed"
+	WebhookEventAuctionSeqReserved   = "auction_seq.reserved"
+	WebhookEventAuctionSeqReleased   = "auction_seq.released"
+	WebhookEventPartnerBudgetAlert   = "partner.budget_alert"
+	WebhookEventPartnerShadowed      = "partner.shadowed"
+)
+
+// WebhooksConfig controls notifications of auction outcomes to external systems. Secret, a secret
+// reference, signs each delivery; failed deliveries are retried up to MaxAttempts with exponential
+// backoff between BackoffBase and BackoffMax, then appended to DeadLetterPath.
+type WebhooksConfig struct {
+	Enabled        bool              `json:"enabled" mapstructure:"enabled"`
+	Endpoints      []WebhookEndpoint `json:"endpoints" mapstructure:"endpoints"`
+	Secret         string            `json:"-" mapstructure:"secret"`
+	Timeout        time.Duration     `json:"timeout" mapstructure:"timeout"`
+	MaxAttempts    int               `json:"maxAttempts" mapstructure:"max_attempts"`
+	BackoffBase    time.Duration     `json:"backoffBase" mapstructure:"backoff_base"`
+	BackoffMax     time.Duration     `json:"backoffMax" mapstructure:"backoff_max"`
+	Workers        int               `json:"workers" mapstructure:"workers"`
+	QueueSize      int               `json:"queueSize" mapstructure:"queue_size"`
+	DeadLetterPath string            `json:"deadLetterPath" mapstructure:"dead_letter_path"`
+	RecentFailures int               `json:"recentFailures" mapstructure:"recent_failures"`
+}
+
+// WebhookEndpoint is a webhook URL and the event types sent to it; no events means all events
+type WebhookEndpoint struct {
+	URL    string   `json:"url" mapstructure:"url"`
+	Events []string `json:"events" mapstructure:"events"`
+}
+
+// Wants reports whether the endpoint subscribes to event
+func (e WebhookEndpoint) Wants(event string) bool {
+	if len(e.Events) == 0 {
+		return true
+	}
+	for _, candidate := range e.Events {
+		if candidate == event {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks endpoints, event filters, and delivery limits
+func (w *WebhooksConfig) validate() error {
+	if len(w.Endpoints) == 0 {
+		return fmt.Errorf("webhooks enabled without endpoints")
+	}
+	for _, endpoint := range w.Endpoints {
+		parsed, err := url.Parse(endpoint.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid webhook URL %q", endpoint.URL)
+		}
+		for _, event := range endpoint.Events {
+			switch event {
+			case WebhookEventBidWon, WebhookEventBidLost, WebhookEventAuctionCompleted, WebhookEventReservationAbandoned, WebhookEventPartnerAssetsFlagged,
+				WebhookEventConfigChanged, WebhookEventAuctionSeqReserved, WebhookEventAuctionSeqReleased, WebhookEventPartnerBudgetAlert,
+				WebhookEventPartnerShadowed:
+			default:
+				return fmt.Errorf("unknown webhook event %q for %s", event, endpoint.URL)
+			}
+		}
+	}
+	if w.Timeout <= 0 {
+		return fmt.Errorf("webhook timeout must be positive")
+	}
+	if w.MaxAttempts < 1 || w.MaxAttempts > 20 {
+		return fmt.Errorf("webhook max attempts must be between 1 and 20: %d", w.MaxAttempts)
+	}
+	if w.BackoffBase <= 0 || w.BackoffMax < w.BackoffBase {
+		return fmt.Errorf("webhook backoff base must be positive and at most the backoff max")
+	}
+	if w.Workers < 1 || w.QueueSize < 1 {
+		return fmt.Errorf("webhook workers and queue size must be at least 1")
+	}
+	if w.DeadLetterPath == "" {
+		return fmt.Errorf("missing webhook dead letter path")
+	}
+	if w.RecentFailures < 0 {
+		return fmt.Errorf("webhook recent failures must not be negative")
+	}
+	return nil
+}
+
+// BatchConfig controls the batch auction endpoint and how it shares capacity with live traffic
+type BatchConfig struct {
+	MaxItems    int           `json:"maxItems" mapstructure:"max_items"`
+	Concurrency int           `json:"concurrency" mapstructure:"concurrency"`
+	Timeout     time.Duration `json:"timeout" mapstructure:"timeout"`
+	Priority    string        `json:"priority" mapstructure:"priority"`
+	LiveReserve float64       `json:"liveReserve" mapstructure:"live_reserve"`
+}
+
+// ScoringConfig configures the external ML scoring service blended into bid quality scores
+type ScoringConfig struct {
+	Enabled     bool          `json:"enabled" mapstructure:"enabled"`
+	Endpoint    string        `json:"endpoint" mapstructure:"endpoint"`
+	Timeout     time.Duration `json:"timeout" mapstructure:"timeout"`
+	BlendWeight float64       `json:"blendWeight" mapstructure:"blend_weight"`
+}
+
+// Consent actions applied to personal UserData fields that may not be shared
+const (
+	ConsentActionStrip = "strip"
+	ConsentActionHash  = "hash"
+)
+
+// ConsentConfig controls how personal UserData is withheld from partners when consent or a
+// data-processing agreement does not cover sharing it
+type ConsentConfig struct {
+	PersonalDataFields []string `json:"personalDataFields" mapstructure:"personal_data_fields"`
+	Action             string   `json:"action" mapstructure:"action"`
+	HashSalt           string   `json:"hashSalt" mapstructure:"hash_salt"`
+}
+
+// LeadQualityPrefix prefixes lead quality fields named in a PII policy, such as "lead_quality.score"
+const LeadQualityPrefix = "lead_quality."
+
+// VerticalExtPrefix prefixes bid extension keys named in a PII policy, such as "vertical_ext.agent_phone"
+const VerticalExtPrefix = "vertical_ext."
+
+// leadQualityFields are the lead quality fields a PII policy may drop
+var leadQualityFields = map[string]bool{
+	"consent_timestamp":   true,
+	"session_duration_ms": true,
+	"previously_sold":     true,
+	"score":               true,
+}
+
+// PIIPolicy lists UserData fields to drop, mask, or hash before requests reach logs, events,
+// the recent-auction buffer, cache keys, or exports. Lead quality fields are named with
+// LeadQualityPrefix and can only be dropped, since they are typed values rather than text.
+// Top-level keys of bid extensions are named with VerticalExtPrefix and sanitized wherever bids
+// reach debug output or events.
+type PIIPolicy struct {
+	Drop     []string `json:"drop" mapstructure:"drop"`
+	Mask     []string `json:"mask" mapstructure:"mask"`
+	Hash     []string `json:"hash" mapstructure:"hash"`
+	HashSalt string   `json:"hashSalt" mapstructure:"hash_salt"`
+}
+
+// validate checks that each field has exactly one action and hashing is salted
+func (p *PIIPolicy) validate() error {
+	if p == nil {
+		return nil
+	}
+	seen := make(map[string]bool)
+	for _, fields := range [][]string{p.Drop, p.Mask, p.Hash} {
+		for _, field := range fields {
+			if seen[field] {
+				return fmt.Errorf("PII field %q listed under more than one action", field)
+			}
+			seen[field] = true
+		}
+	}
+	if len(p.Hash) > 0 && p.HashSalt == "" {
+		return fmt.Errorf("PII policy hashes fields but has no hash salt")
+	}
+	for _, field := range p.Drop {
+		if name, isLeadQuality := strings.CutPrefix(field, LeadQualityPrefix); isLeadQuality && !leadQualityFields[name] {
+			return fmt.Errorf("PII policy drops unknown lead quality field %q", field)
+		}
+	}
+	for _, field := range append(append([]string(nil), p.Mask...), p.Hash...) {
+		if strings.HasPrefix(field, LeadQualityPrefix) {
+			return fmt.Errorf("PII policy can only drop lead quality field %q", field)
+		}
+	}
+	return nil
+}
+
+// EnrichmentConfig controls in-process geo and device enrichment of requests that lack them
+type EnrichmentConfig struct {
+	GeoIPDatabasePath string `json:"geoipDatabasePath" mapstructure:"geoip_database_path"`
+	ParseUserAgent    bool   `json:"parseUserAgent" mapstructure:"parse_user_agent"`
+}
+
+// Duplicate demand keys
+const (
+	DedupKeyURL           = "url"
+	DedupKeyAdomain       = "adomain"
+	DedupKeyCreativeTitle = "creative_title"
+)
+
+// DealConfig is a private marketplace deal negotiated with one partner. Bids referencing the deal
+// ID bypass the open auction floor and compete at FixedPrice, or at their own price when it
+// meets FloorPrice. Empty Verticals or Zips allow any; a zero ExpiresAt never expires.
+type DealConfig struct {
+	PartnerID  string    `json:"partnerId" mapstructure:"partner_id"`
+	FixedPrice float64   `json:"fixedPrice" mapstructure:"fixed_price"`
+	FloorPrice float64   `json:"floorPrice" mapstructure:"floor_price"`
+	Verticals  []string  `json:"verticals" mapstructure:"verticals"`
+	Zips       []string  `json:"zips" mapstructure:"zips"`
+	ExpiresAt  time.Time `json:"expiresAt" mapstructure:"expires_at"`
+	Guaranteed bool      `json:"guaranteed" mapstructure:"guaranteed"`
+}
+
+// validate requires a known partner and exactly one of a fixed price or floor within the price range
+func (d *DealConfig) validate(dealID string, c *Config) error {
+	if d == nil {
+		return fmt.Errorf("empty deal %s", dealID)
+	}
+	if _, exists := c.Partners[d.PartnerID]; !exists {
+		return fmt.Errorf("unknown partner %q for deal %s", d.PartnerID, dealID)
+	}
+	if (d.FixedPrice > 0) == (d.FloorPrice > 0) {
+		return fmt.Errorf("deal %s needs exactly one of a fixed price or a floor price", dealID)
+	}
+	if d.FixedPrice < 0 || d.FloorPrice < 0 || d.FixedPrice > c.MaxBidPrice || d.FloorPrice > c.MaxBidPrice {
+		return fmt.Errorf("invalid price for deal %s", dealID)
+	}
+	return nil
+}
+
+// validatePartnerGroups checks that every partner group lists known partners, each at most once.
+// Groups name partners for the ranking constraints of bid requests.
+func (c *Config) validatePartnerGroups() error {
+	for group, partners := range c.PartnerGroups {
+		if len(partners) == 0 {
+			return fmt.Errorf("partner group %s has no partners", group)
+		}
+		seen := make(map[string]bool, len(partners))
+		for _, partnerID := range partners {
+			if _, exists := c.Partners[partnerID]; !exists {
+				return fmt.Errorf("unknown partner %q in partner group %s", partnerID, group)
+			}
+			if seen[partnerID] {
+				return fmt.Errorf("partner %s listed twice in partner group %s", partnerID, group)
+			}
+			seen[partnerID] = true
+		}
+	}
+	return nil
+}
+
+// ResponseProfile selects the Bid fields returned to clients sending one of APIKeys in the
+// X-API-Key header. Fields are Bid JSON names; a fields query parameter takes precedence.
+// NoBidResponse, when set, replaces the global no-bid response for these clients.
+type ResponseProfile struct {
+	APIKeys       []string             `json:"-" mapstructure:"api_keys"`
+	Fields        []string             `json:"fields" mapstructure:"fields"`
+	NoBidResponse *NoBidResponseConfig `json:"noBidResponse" mapstructure:"no_bid_response"`
+}
+
+// validateResponseProfiles checks that every profile has fields or a no-bid response and that
+// API keys select one profile; field names are checked against the Bid type when the bid
+// handler is created
+func validateResponseProfiles(profiles map[string]*ResponseProfile) error {
+	owners := make(map[string]string)
+	for name, profile := range profiles {
+		if profile == nil || len(profile.APIKeys) == 0 || (len(profile.Fields) == 0 && profile.NoBidResponse == nil) {
+			return fmt.Errorf("response profile %s needs api keys and fields or a no-bid response", name)
+		}
+		if err := profile.NoBidResponse.validate(); err != nil {
+			return fmt.Errorf("response profile %s: %w", name, err)
+		}
+		for _, key := range profile.APIKeys {
+			if key == "" {
+				return fmt.Errorf("response profile %s has an empty api key", name)
+			}
+			if owner, exists := owners[key]; exists {
+				return fmt.Errorf("api key in both response profiles %s and %s", owner, name)
+			}
+			owners[key] = name
+		}
+	}
+	return nil
+}
+
+// Replay protection clock skew bounds
+const (
+	DefaultReplayMaxSkew = 5 * time.Minute
+	MinReplayMaxSkew     = time.Second
+	MaxReplayMaxSkew     = time.Hour
+)
+
+// ReplayProtectionConfig rejects replayed bid requests from the API clients it lists, keyed by
+// client name. Their requests must carry a Unix timestamp within MaxSkew of the service clock and
+// a nonce not used within the window such timestamps span. When nonces cannot be checked,
+// FailOpen lets requests through instead of refusing them.
+type ReplayProtectionConfig struct {
+	MaxSkew  time.Duration            `json:"maxSkew" mapstructure:"max_skew"`
+	FailOpen bool                     `json:"failOpen" mapstructure:"fail_open"`
+	Clients  map[string]*ReplayClient `json:"clients" mapstructure:"clients"`
+}
+
+// ReplayClient is an API client opted in to replay protection, identified by the X-API-Key
+// values it sends
+type ReplayClient struct {
+	APIKeys []string `json:"-" mapstructure:"api_keys"`
+}
+
+// Skew returns the accepted clock skew, defaulting to DefaultReplayMaxSkew
+func (r *ReplayProtectionConfig) Skew() time.Duration {
+	if r == nil || r.MaxSkew <= 0 {
+		return DefaultReplayMaxSkew
+	}
+	return r.MaxSkew
+}
+
+// Window returns how long a nonce is remembered: the span of timestamps accepted at any moment,
+// so a nonce cannot be reused while its timestamp is still accepted
+func (r *ReplayProtectionConfig) Window() time.Duration {
+	return 2 * r.Skew()
+}
+
+// validate checks the clock skew and that every client has API keys no other client uses
+func (r *ReplayProtectionConfig) validate() error {
+	if r == nil {
+		return nil
+	}
+	if r.MaxSkew != 0 && (r.MaxSkew < MinReplayMaxSkew || r.MaxSkew > MaxReplayMaxSkew) {
+		return fmt.Errorf("replay protection max skew must be between %v and %v: %v", MinReplayMaxSkew, MaxReplayMaxSkew, r.MaxSkew)
+	}
+	owners := make(map[string]string)
+	for name, client := range r.Clients {
+		if client == nil || len(client.APIKeys) == 0 {
+			return fmt.Errorf("replay protection client %s needs api keys", name)
+		}
+		for _, key := range client.APIKeys {
+			if key == "" {
+				return fmt.Errorf("replay protection client %s has an empty api key", name)
+			}
+			if owner, exists := owners[key]; exists {
+				return fmt.Errorf("api key in both replay protection clients %s and %s", owner, name)
+			}
+			owners[key] = name
+		}
+	}
+	return nil
+}
+
+// NoBidResponseConfig shapes the response to an auction without valid bids. Status is 204, the
+// default, or 200. A 200 response has a body with no bids, naming the no_valid_bids reason when
+// IncludeReason is set. With HouseOffers, requests for a vertical with a house offer get it
+// instead, flagged as a house offer.
+type NoBidResponseConfig struct {
+	Status        int  `json:"status" mapstructure:"status"`
+	IncludeReason bool `json:"includeReason" mapstructure:"include_reason"`
+	HouseOffers   bool `json:"houseOffers" mapstructure:"house_offers"`
+}
+
+// StatusCode returns the no-bid response status; a nil config keeps the 204 default
+func (n *NoBidResponseConfig) StatusCode() int {
+	if n == nil || n.Status == 0 {
+		return http.StatusNoContent
+	}
+	return n.Status
+}
+
+// validate checks the status and that a body is only requested with a 200 status
+func (n *NoBidResponseConfig) validate() error {
+	if n == nil {
+		return nil
+	}
+	status := n.StatusCode()
+	if status != http.StatusOK && status != http.StatusNoContent {
+		return fmt.Errorf("no-bid response status must be 200 or 204: %d", n.Status)
+	}
+	if (n.IncludeReason || n.HouseOffers) && status != http.StatusOK {
+		return fmt.Errorf("no-bid response reasons and house offers need status 200")
+	}
+	return nil
+}
+
+// HouseOfferConfig is a vertical's static offer, returned when an auction has no valid bids and
+// the caller's no-bid response allows house offers. House offers are not partner bids and are
+// left out of partner metrics.
+type HouseOfferConfig struct {
+	ID       string                 `json:"id" mapstructure:"id"`
+	ClickURL string                 `json:"clickUrl" mapstructure:"click_url"`
+	Creative map[string]interface{} `json:"creative" mapstructure:"creative"`
+}
+
+// validateHouseOffers checks that every house offer has an ID and an absolute click URL
+func (c *Config) validateHouseOffers() error {
+	for vertical, offer := range c.HouseOffers {
+		if offer == nil || offer.ID == "" {
+			return fmt.Errorf("house offer for vertical %s needs an id", vertical)
+		}
+		parsed, err := url.Parse(offer.ClickURL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("invalid house offer click URL for vertical %s: %q", vertical, offer.ClickURL)
+		}
+	}
+	return nil
+}
+
+// Negative cache segment fields naming bid request attributes; UserData fields are named with
+// the SegmentFieldUserDataPrefix, e.g. user_data.risk_tier
+const (
+	SegmentFieldVertical       = "vertical"
+	SegmentFieldCountry        = "geo.country"
+	SegmentFieldRegion         = "geo.region"
+	SegmentFieldZip            = "geo.zip"
+	SegmentFieldDeviceType     = "device.type"
+	SegmentFieldUserDataPrefix = "user_data."
+	DefaultNegativeCacheTTL    = 5 * time.Minute
+)
+
+// NegativeCacheConfig controls skipping partners that recently sent an explicit no-bid for the
+// same lead segment, the request's values for SegmentFields. Partners limits caching to the
+// listed partners, or applies it to every partner when empty.
+type NegativeCacheConfig struct {
+	Enabled       bool          `json:"enabled" mapstructure:"enabled"`
+	TTL           time.Duration `json:"ttl" mapstructure:"ttl"`
+	SegmentFields []string      `json:"segmentFields" mapstructure:"segment_fields"`
+	Partners      []string      `json:"partners" mapstructure:"partners"`
+}
+
+// validate checks the TTL, segment fields, and partner IDs
+func (n *NegativeCacheConfig) validate(partners map[string]*PartnerConfig) error {
+	if n == nil || !n.Enabled {
+		return nil
+	}
+	if n.TTL <= 0 {
+		return fmt.Errorf("negative cache ttl must be greater than 0: %v", n.TTL)
+	}
+	if len(n.SegmentFields) == 0 {
+		return fmt.Errorf("negative cache requires at least one segment field")
+	}
+	for _, field := range n.SegmentFields {
+		switch {
+		case field == SegmentFieldVertical, field == SegmentFieldCountry, field == SegmentFieldRegion,
+			field == SegmentFieldZip, field == SegmentFieldDeviceType:
+		case strings.HasPrefix(field, SegmentFieldUserDataPrefix) && len(field) > len(SegmentFieldUserDataPrefix):
+		default:
+			return fmt.Errorf("unknown negative cache segment field %q", field)
+		}
+	}
+	for _, partnerID := range n.Partners {
+		if _, exists := partners[partnerID]; !exists {
+			return fmt.Errorf("unknown partner %q for negative cache", partnerID)
+		}
+	}
+	return nil
+}
+
+// Budget hold defaults and bounds
+const (
+	DefaultBudgetHoldTTL = time.Minute
+	maxBudgetHoldTTL     = time.Hour
+)
+
+// BudgetConfig tunes partner daily budgets. HoldTTL is how long an auction's hold on a partner's
+// budget lasts when the instance holding it dies before committing or releasing it. With
+// AlertWebhook set, crossed budget alert thresholds are also sent as partner.budget_alert webhooks.
+type BudgetConfig struct {
+	HoldTTL      time.Duration `json:"holdTtl" mapstructure:"hold_ttl"`
+	AlertWebhook bool          `json:"alertWebhook" mapstructure:"alert_webhook"`
+}
+
+// AlertsWebhook reports whether crossed budget alert thresholds are sent as webhooks
+func (b *BudgetConfig) AlertsWebhook() bool {
+	return b != nil && b.AlertWebhook
+}
+
+// validateBudgetAlerts checks that a partner's budget alert thresholds are distinct percentages
+// of a daily budget it has
+func (p *PartnerConfig) validateBudgetAlerts(id string) error {
+	if len(p.BudgetAlertThresholds) == 0 {
+		return nil
+	}
+	if p.DailyBudget <= 0 {
+		return fmt.Errorf("budget alert thresholds for partner %s require a daily budget", id)
+	}
+	seen := make(map[float64]bool, len(p.BudgetAlertThresholds))
+	for _, threshold := range p.BudgetAlertThresholds {
+		if !(threshold > 0 && threshold <= 100) {
+			return fmt.Errorf("budget alert thresholds for partner %s must be percentages above 0 and at most 100: %v", id, threshold)
+		}
+		if seen[threshold] {
+			return fmt.Errorf("duplicate budget alert threshold for partner %s: %v", id, threshold)
+		}
+		seen[threshold] = true
+	}
+	return nil
+}
+
+// HoldExpiry returns the hold TTL, defaulting to DefaultBudgetHoldTTL
+func (b *BudgetConfig) HoldExpiry() time.Duration {
+	if b == nil || b.HoldTTL <= 0 {
+		return DefaultBudgetHoldTTL
+	}
+	return b.HoldTTL
+}
+
+// validate checks that holds outlive the auctions taking them
+func (b *BudgetConfig) validate(bidTimeout time.Duration) error {
+	if b == nil || b.HoldTTL == 0 {
+		return nil
+	}
+	if b.HoldTTL < bidTimeout || b.HoldTTL > maxBudgetHoldTTL {
+		return fmt.Errorf("budget hold TTL must be between the bid timeout and %v: %v", maxBudgetHoldTTL, b.HoldTTL)
+	}
+	return nil
+}
+
+// Partner hygiene defaults and bounds
+const (
+	DefaultIdleBidDays          = 30
+	DefaultIdleWinDays          = 60
+	DefaultShadowAfterDays      = 90
+	DefaultHygieneReportWindow  = 7 * 24 * time.Hour
+	DefaultHygieneInterval      = time.Hour
+	DefaultActivitySyncInterval = time.Minute
+	maxHygieneReportWindow      = 31 * 24 * time.Hour
+)
+
+// HygieneConfig tunes idle partner detection. Partners without a valid bid in IdleBidDays or a win
+// in IdleWinDays are reported as idle, and calls are judged over ReportWindow. With AutoShadow set,
+// partners that were called but never bid for ShadowAfterDays are moved into shadow mode, checked
+// every CheckInterval, until an admin restores or disables them. SyncInterval is how often partner
+// activity is merged with the copy kept in Redis.
+type HygieneConfig struct {
+	IdleBidDays     int           `json:"idleBidDays" mapstructure:"idle_bid_days"`
+	IdleWinDays     int           `json:"idleWinDays" mapstructure:"idle_win_days"`
+	ReportWindow    time.Duration `json:"reportWindow" mapstructure:"report_window"`
+	AutoShadow      bool          `json:"autoShadow" mapstructure:"auto_shadow"`
+	ShadowAfterDays int           `json:"shadowAfterDays" mapstructure:"shadow_after_days"`
+	CheckInterval   time.Duration `json:"checkInterval" mapstructure:"check_interval"`
+	SyncInterval    time.Duration `json:"syncInterval" mapstructure:"sync_interval"`
+}
+
+// BidIdleAfter returns how long a partner may go without a valid bid before it is idle
+func (h *HygieneConfig) BidIdleAfter() time.Duration {
+	if h == nil || h.IdleBidDays <= 0 {
+		return DefaultIdleBidDays * 24 * time.Hour
+	}
+	return time.Duration(h.IdleBidDays) * 24 * time.Hour
+}
+
+// WinIdleAfter returns how long a partner may go without a win before it is idle
+func (h *HygieneConfig) WinIdleAfter() time.Duration {
+	if h == nil || h.IdleWinDays <= 0 {
+		return DefaultIdleWinDays * 24 * time.Hour
+	}
+	return time.Duration(h.IdleWinDays) * 24 * time.Hour
+}
+
+// ShadowAfter returns how long a partner may go without a valid bid before it is shadowed
+func (h *HygieneConfig) ShadowAfter() time.Duration {
+	if h == nil || h.ShadowAfterDays <= 0 {
+		return DefaultShadowAfterDays * 24 * time.Hour
+	}
+	return time.Duration(h.ShadowAfterDays) * 24 * time.Hour
+}
+
+// Window returns the window partner calls are judged over, defaulting to DefaultHygieneReportWindow
+func (h *HygieneConfig) Window() time.Duration {
+	if h == nil || h.ReportWindow <= 0 {
+		return DefaultHygieneReportWindow
+	}
+	return h.ReportWindow
+}
+
+// AutoShadows reports whether chronically idle partners are moved into shadow mode
+func (h *HygieneConfig) AutoShadows() bool {
+	return h != nil && h.AutoShadow
+}
+
+// Interval returns how often idle partners are checked for shadowing, defaulting to
+// DefaultHygieneInterval
+func (h *HygieneConfig) Interval() time.Duration {
+	if h == nil || h.CheckInterval <= 0 {
+		return DefaultHygieneInterval
+	}
+	return h.CheckInterval
+}
+
+// ActivitySyncInterval returns how often partner activity is synced with Redis, defaulting to
+// DefaultActivitySyncInterval
+func (h *HygieneConfig) ActivitySyncInterval() time.Duration {
+	if h == nil || h.SyncInterval <= 0 {
+		return DefaultActivitySyncInterval
+	}
+	return h.SyncInterval
+}
+
+// validate checks that the report window fits the partner report retention and that partners are
+// only shadowed once they are idle
+func (h *HygieneConfig) validate() error {
+	if h == nil {
+		return nil
+	}
+	if h.IdleBidDays < 0 || h.IdleWinDays < 0 || h.ShadowAfterDays < 0 {
+		return fmt.Errorf("hygiene idle days must not be negative")
+	}
+	if h.ReportWindow < 0 || h.ReportWindow > maxHygieneReportWindow {
+		return fmt.Errorf("hygiene report window must be at most %v: %v", maxHygieneReportWindow, h.ReportWindow)
+	}
+	if h.CheckInterval < 0 || h.SyncInterval < 0 {
+		return fmt.Errorf("hygiene intervals must not be negative")
+	}
+	if h.AutoShadow && h.ShadowAfter() < h.BidIdleAfter() {
+		return fmt.Errorf("hygiene shadow after days must be at least the idle bid days: %d", h.ShadowAfterDays)
+	}
+	return nil
+}
+
+// Inbound payload defaults and bounds
+const (
+	DefaultMaxRequestBytes  = 256 << 10
+	DefaultMaxUserDataBytes = 64 << 10
+	maxRequestBytes         = 16 << 20
+)
+
+// PayloadLimitsConfig bounds single-lead bid requests. A body over MaxRequestBytes is rejected
+// before it is parsed, and a parsed request whose UserData encodes to more than MaxUserDataBytes
+// is rejected before the auction. A nil config or zero fields use the defaults.
+type PayloadLimitsConfig struct {
+	MaxRequestBytes  int64 `json:"maxRequestBytes" mapstructure:"max_request_bytes"`
+	MaxUserDataBytes int   `json:"maxUserDataBytes" mapstructure:"max_user_data_bytes"`
+}
+
+// Limits returns the payload limits with the defaults filled in
+func (c *PayloadLimitsConfig) Limits() PayloadLimitsConfig {
+	limits := PayloadLimitsConfig{MaxRequestBytes: DefaultMaxRequestBytes, MaxUserDataBytes: DefaultMaxUserDataBytes}
+	if c == nil {
+		return limits
+	}
+	if c.MaxRequestBytes > 0 {
+		limits.MaxRequestBytes = c.MaxRequestBytes
+	}
+	if c.MaxUserDataBytes > 0 {
+		limits.MaxUserDataBytes = c.MaxUserDataBytes
+	}
+	return limits
+}
+
+// validate checks the UserData limit fits within the request limit
+func (c *PayloadLimitsConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.MaxRequestBytes < 0 || c.MaxRequestBytes > maxRequestBytes {
+		return fmt.Errorf("max request bytes must be between 0 and %d: %d", maxRequestBytes, c.MaxRequestBytes)
+	}
+	limits := c.Limits()
+	if c.MaxUserDataBytes < 0 || int64(limits.MaxUserDataBytes) > limits.MaxRequestBytes {
+		return fmt.Errorf("max user data bytes must be between 0 and the max request bytes %d: %d", limits.MaxRequestBytes, c.MaxUserDataBytes)
+	}
+	return nil
+}
+
+// Partner capture defaults and bounds
+const (
+	DefaultCaptureMaxBodyBytes = 16 << 10
+	DefaultCaptureMaxEntries   = 100
+	DefaultCaptureMaxDuration  = time.Hour
+	maxCaptureBodyBytes        = 1 << 20
+	maxCaptureEntries          = 10000
+)
+
+// CaptureConfig bounds the partner traffic captures admins switch on to debug an integration.
+// Each partner keeps its MaxEntries most recent captures with bodies cut at MaxBodyBytes, and a
+// capture stays on for at most MaxDuration. A nil config or zero fields use the defaults.
+type CaptureConfig struct {
+	MaxBodyBytes int           `json:"maxBodyBytes" mapstructure:"max_body_bytes"`
+	MaxEntries   int           `json:"maxEntries" mapstructure:"max_entries"`
+	MaxDuration  time.Duration `json:"maxDuration" mapstructure:"max_duration"`
+}
+
+// Limits returns the capture limits with the defaults filled in
+func (c *CaptureConfig) Limits() CaptureConfig {
+	limits := CaptureConfig{MaxBodyBytes: DefaultCaptureMaxBodyBytes, MaxEntries: DefaultCaptureMaxEntries, MaxDuration: DefaultCaptureMaxDuration}
+	if c == nil {
+		return limits
+	}
+	if c.MaxBodyBytes > 0 {
+		limits.MaxBodyBytes = c.MaxBodyBytes
+	}
+	if c.MaxEntries > 0 {
+		limits.MaxEntries = c.MaxEntries
+	}
+	if c.MaxDuration > 0 {
+		limits.MaxDuration = c.MaxDuration
+	}
+	return limits
+}
+
+// validate checks the limits keep captures bounded
+func (c *CaptureConfig) validate() error {
+	if c == nil {
+		return nil
+	}
+	if c.MaxBodyBytes < 0 || c.MaxBodyBytes > maxCaptureBodyBytes {
+		return fmt.Errorf("capture max body bytes must be between 0 and %d: %d", maxCaptureBodyBytes, c.MaxBodyBytes)
+	}
+	if c.MaxEntries < 0 || c.MaxEntries > maxCaptureEntries {
+		return fmt.Errorf("capture max entries must be between 0 and %d: %d", maxCaptureEntries, c.MaxEntries)
+	}
+	if c.MaxDuration < 0 || c.MaxDuration > 24*time.Hour {
+		return fmt.Errorf("capture max duration must be between 0 and 24h: %v", c.MaxDuration)
+	}
+	return nil
+}
+
+// Timeout budget defaults
+const (
+	DefaultBudgetReserveFraction = 0.1
+	DefaultSerializationReserve  = 2 * time.Millisecond
+)
+
+// TimeoutBudgetConfig reserves the end of each auction's deadline for the work after bid
+// collection. The reserve is the larger of Reserve and ReserveFraction of the time left when the
+// auction starts, and never more than half of it. Optimization may use the reserve minus
+// Serialization, and at least half the reserve; the rest is left for encoding the response.
+type TimeoutBudgetConfig struct {
+	Reserve         time.Duration `json:"reserve" mapstructure:"reserve"`
+	ReserveFraction float64       `json:"reserveFraction" mapstructure:"reserve_fraction"`
+	Serialization   time.Duration `json:"serialization" mapstructure:"serialization"`
+}
+
+// Split divides the time left for an auction into the collection and optimization slices.
+// A nil config uses the defaults.
+func (t *TimeoutBudgetConfig) Split(remaining time.Duration) (collection, optimization time.Duration) {
+	reserve, fraction, serialization := time.Duration(0), DefaultBudgetReserveFraction, DefaultSerializationReserve
+	if t != nil {
+		reserve, fraction, serialization = t.Reserve, t.ReserveFraction, t.Serialization
+	}
+	if share := time.Duration(float64(remaining) * fraction); share > reserve {
+		reserve = share
+	}
+	if reserve > remaining/2 {
+		reserve = remaining / 2
+	}
+	optimization = reserve - serialization
+	if optimization < reserve/2 {
+		optimization = reserve / 2
+	}
+	return remaining - reserve, optimization
+}
+
+// validate checks that the reserve leaves time for collection
+func (t *TimeoutBudgetConfig) validate(bidTimeout time.Duration) error {
+	if t == nil {
+		return nil
+	}
+	if t.Reserve < 0 || t.Serialization < 0 {
+		return fmt.Errorf("timeout budget reserve and serialization must not be negative")
+	}
+	if t.ReserveFraction < 0 || t.ReserveFraction > 0.5 {
+		return fmt.Errorf("timeout budget reserve fraction must be between 0 and 0.5: %v", t.ReserveFraction)
+	}
+	if t.Reserve > bidTimeout/2 {
+		return fmt.Errorf("timeout budget reserve %v must not exceed half the bid timeout %v", t.Reserve, bidTimeout)
+	}
+	return nil
+}
+
+// DefaultQualityWeight is the weight of a bid's quality score in its effective price when
+// QualityWeight is unset
+const DefaultQualityWeight = 0.3
+
+// QualityScoreWeight returns the weight of a bid's quality score in its effective price
+func (c *Config) QualityScoreWeight() float64 {
+	if c.QualityWeight != nil {
+		return *c.QualityWeight
+	}
+	return DefaultQualityWeight
+}
+
+// MaxQualityAcknowledgedBonus bounds the effective price bonus for bids acknowledging lead quality
+const MaxQualityAcknowledgedBonus = 0.2
+
+// Auction parameters an experiment can override
+const (
+	ExperimentParamQualityWeight = "quality_weight"
+	ExperimentParamStrategy      = "strategy"
+	ExperimentParamFloor         = "floor"
+)
+
+// Experiment bucketing keys; UserData fields are named with the SegmentFieldUserDataPrefix,
+// e.g. user_data.email_hash
+const (
+	ExperimentBucketLeadID    = "lead_id"
+	ExperimentBucketRequestID = "request_id"
+)
+
+// Experiment variants
+const (
+	ExperimentVariantControl   = "control"
+	ExperimentVariantTreatment = "treatment"
+)
+
+// ExperimentConfig runs an A/B test of auction parameters. TrafficPercent of requests, chosen by
+// a hash of their BucketBy value, run in the treatment variant with Overrides applied; the rest
+// run in the control variant with the configured parameters. BucketBy defaults to lead_id, so a
+// lead stays in one variant across auctions.
+type ExperimentConfig struct {
+	TrafficPercent float64             `json:"trafficPercent" mapstructure:"traffic_percent"`
+	BucketBy       string              `json:"bucketBy" mapstructure:"bucket_by"`
+	Overrides      ExperimentOverrides `json:"overrides" mapstructure:"overrides"`
+}
+
+// ExperimentOverrides are the parameters the treatment variant runs with. Strategy replaces the
+// strategy of every vertical and Floor replaces MinBidPrice; unset fields keep the configured values.
+type ExperimentOverrides struct {
+	QualityWeight *float64 `json:"qualityWeight,omitempty" mapstructure:"quality_weight"`
+	Strategy      string   `json:"strategy,omitempty" mapstructure:"strategy"`
+	Floor         float64  `json:"floor,omitempty" mapstructure:"floor"`
+}
+
+// BucketKey returns the request attribute that buckets requests into variants
+func (e *ExperimentConfig) BucketKey() string {
+	if e.BucketBy == "" {
+		return ExperimentBucketLeadID
+	}
+	return e.BucketBy
+}
+
+// Params returns the auction parameters the experiment overrides
+func (o ExperimentOverrides) Params() []string {
+	var params []string
+	if o.QualityWeight != nil {
+		params = append(params, ExperimentParamQualityWeight)
+	}
+	if o.Strategy != "" {
+		params = append(params, ExperimentParamStrategy)
+	}
+	if o.Floor != 0 {
+		params = append(params, ExperimentParamFloor)
+	}
+	return params
+}
+
+// Apply returns a copy of cfg running with the overrides
+func (o ExperimentOverrides) Apply(cfg *Config) *Config {
+	variant := *cfg
+	if o.QualityWeight != nil {
+		variant.QualityWeight = o.QualityWeight
+	}
+	if o.Strategy != "" {
+		variant.Strategies = map[string]string{DefaultStrategyKey: o.Strategy}
+		// Vertical strategies give way to the treatment's too
+		variant.Verticals = make(map[string]*VerticalConfig, len(cfg.Verticals))
+		for vertical, settings := range cfg.Verticals {
+			if settings != nil {
+				unranked := *settings
+				unranked.Strategy = ""
+				settings = &unranked
+			}
+			variant.Verticals[vertical] = settings
+		}
+	}
+	if o.Floor != 0 {
+		variant.MinBidPrice = o.Floor
+	}
+	return &variant
+}
+
+// validateExperiments checks each experiment's traffic, bucketing key, and overrides, and that
+// no two experiments override the same parameter, which would leave their results confounded
+func (c *Config) validateExperiments() error {
+	ids := make([]string, 0, len(c.Experiments))
+	for id := range c.Experiments {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	owners := make(map[string]string)
+	for _, id := range ids {
+		experiment := c.Experiments[id]
+		if experiment == nil {
+			return fmt.Errorf("experiment %s has no configuration", id)
+		}
+		if experiment.TrafficPercent <= 0 || experiment.TrafficPercent > 100 {
+			return fmt.Errorf("experiment %s traffic percent must be in (0, 100]: %v", id, experiment.TrafficPercent)
+		}
+		switch key := experiment.BucketKey(); {
+		case key == ExperimentBucketLeadID, key == ExperimentBucketRequestID:
+		case strings.HasPrefix(key, SegmentFieldUserDataPrefix) && len(key) > len(SegmentFieldUserDataPrefix):
+		default:
+			return fmt.Errorf("unknown bucketing key %q for experiment %s", key, id)
+		}
+
+		overrides := experiment.Overrides
+		params := overrides.Params()
+		if len(params) == 0 {
+			return fmt.Errorf("experiment %s overrides no parameters", id)
+		}
+		if overrides.QualityWeight != nil && (*overrides.QualityWeight < 0 || *overrides.QualityWeight > 1) {
+			return fmt.Errorf("experiment %s quality weight must be in [0, 1]: %v", id, *overrides.QualityWeight)
+		}
+		switch overrides.Strategy {
+		case "", StrategyEffectivePrice, StrategyQualityWeighted, StrategyPassthrough:
+		default:
+			return fmt.Errorf("unknown optimization strategy %q for experiment %s", overrides.Strategy, id)
+		}
+		if overrides.Floor < 0 || overrides.Floor > c.MaxBidPrice {
+			return fmt.Errorf("experiment %s floor must be between 0 and the max bid price: %v", id, overrides.Floor)
+		}
+		for _, param := range params {
+			if owner, exists := owners[param]; exists {
+				return fmt.Errorf("experiments %s and %s both override %s", owner, id, param)
+			}
+			owners[param] = id
+		}
+	}
+	return nil
+}
+
+// Invalid traffic rules, each checked against every bid request when IVT filtering is enabled
+const (
+	IVTRuleUserAgent     = "user_agent"
+	IVTRuleIPBlocklist   = "ip_blocklist"
+	IVTRuleLeadVelocity  = "lead_velocity"
+	IVTRuleMissingHeader = "missing_header"
+)
+
+// Invalid traffic actions. Reject refuses the request, shadow answers with a no-bid response
+// without calling partners, and flag only counts the request and runs the auction as usual.
+const (
+	IVTActionReject = "reject"
+	IVTActionShadow = "shadow"
+	IVTActionFlag   = "flag"
+)
+
+// IVTConfig controls invalid-traffic filtering of bid requests before the auction runs. A request
+// is invalid when its User-Agent contains a BotUserAgents entry, its IP is in BlockedCIDRs, its
+// LeadID was seen more than MaxLeadRepeats times within LeadRepeatWindow, or it lacks one of the
+// RequiredHeaders. Action applies to every rule without a RuleActions entry.
+type IVTConfig struct {
+	Enabled          bool              `json:"enabled" mapstructure:"enabled"`
+	Action           string            `json:"action" mapstructure:"action"`
+	RuleActions      map[string]string `json:"ruleActions" mapstructure:"rule_actions"`
+	BotUserAgents    []string          `json:"botUserAgents" mapstructure:"bot_user_agents"`
+	BlockedCIDRs     []string          `json:"blockedCidrs" mapstructure:"blocked_cidrs"`
+	MaxLeadRepeats   int               `json:"maxLeadRepeats" mapstructure:"max_lead_repeats"`
+	LeadRepeatWindow time.Duration     `json:"leadRepeatWindow" mapstructure:"lead_repeat_window"`
+	RequiredHeaders  []string          `json:"requiredHeaders" mapstructure:"required_headers"`
+}
+
+// ActionFor returns the action for requests matching rule, defaulting to reject
+func (c *IVTConfig) ActionFor(rule string) string {
+	if action, exists := c.RuleActions[rule]; exists {
+		return action
+	}
+	if c.Action == "" {
+		return IVTActionReject
+	}
+	return c.Action
+}
+
+// ParseIVTNetwork parses a blocklist entry, either a CIDR or a single IP address
+func ParseIVTNetwork(entry string) (netip.Prefix, error) {
+	if prefix, err := netip.ParsePrefix(entry); err == nil {
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(entry)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid ivt blocklist entry %q", entry)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// validate checks rule names, actions, and blocklist entries
+func (c *IVTConfig) validate() error {
+	if c == nil || !c.Enabled {
+		return nil
+	}
+	actions := map[string]string{"default": c.Action}
+	for rule, action := range c.RuleActions {
+		switch rule {
+		case IVTRuleUserAgent, IVTRuleIPBlocklist, IVTRuleLeadVelocity, IVTRuleMissingHeader:
+		default:
+			return fmt.Errorf("unknown ivt rule %q", rule)
+		}
+		actions[rule] = action
+	}
+	for rule, action := range actions {
+		switch action {
+		case IVTActionReject, IVTActionShadow, IVTActionFlag:
+		case "":
+			if rule != "default" {
+				return fmt.Errorf("missing ivt action for rule %s", rule)
+			}
+		default:
+			return fmt.Errorf("unknown ivt action %q for rule %s", action, rule)
+		}
+	}
+	for _, entry := range c.BlockedCIDRs {
+		if _, err := ParseIVTNetwork(entry); err != nil {
+			return err
+		}
+	}
+	if c.MaxLeadRepeats < 0 {
+		return fmt.Errorf("ivt max lead repeats must not be negative: %d", c.MaxLeadRepeats)
+	}
+	if c.MaxLeadRepeats > 0 && c.LeadRepeatWindow <= 0 {
+		return fmt.Errorf("ivt lead repeat window must be greater than 0: %v", c.LeadRepeatWindow)
+	}
+	return nil
+}
+
+// UserData field types
+const (
+	UserDataTypeString = "string"
+	UserDataTypeInt    = "int"
+	UserDataTypeBool   = "bool"
+)
+
+// UserDataSchema lists the UserData fields of a vertical's leads, keyed by field name
+type UserDataSchema struct {
+	Fields map[string]*UserDataField `json:"fields" mapstructure:"fields"`
+}
+
+// UserDataField describes one UserData field. A missing Required field rejects the request; a
+// field only listed for RequiredForPartners skips those partners instead. Present values must
+// have Type, and strings must match Pattern and be at most MaxLength characters.
+type UserDataField struct {
+	Type                string   `json:"type" mapstructure:"type"`
+	Required            bool     `json:"required" mapstructure:"required"`
+	Pattern             string   `json:"pattern" mapstructure:"pattern"`
+	MaxLength           int      `json:"maxLength" mapstructure:"max_length"`
+	RequiredForPartners []string `json:"requiredForPartners" mapstructure:"required_for_partners"`
+}
+
+// validate checks a vertical's schema against the configured partners
+func (s *UserDataSchema) validate(vertical string, c *Config) error {
+	if s == nil {
+		return fmt.Errorf("empty user data schema for vertical %s", vertical)
+	}
+	for name, field := range s.Fields {
+		if field == nil {
+			return fmt.Errorf("empty user data field %s for vertical %s", name, vertical)
+		}
+		switch field.Type {
+		case UserDataTypeString:
+		case UserDataTypeInt, UserDataTypeBool:
+			if field.Pattern != "" || field.MaxLength != 0 {
+				return fmt.Errorf("user data field %s for vertical %s: pattern and max length need type string", name, vertical)
+			}
+		default:
+			return fmt.Errorf("unknown type %q for user data field %s in vertical %s", field.Type, name, vertical)
+		}
+		if _, err := regexp.Compile(field.Pattern); err != nil {
+			return fmt.Errorf("invalid pattern for user data field %s in vertical %s: %w", name, vertical, err)
+		}
+		if field.MaxLength < 0 {
+			return fmt.Errorf("max length for user data field %s in vertical %s must not be negative: %d", name, vertical, field.MaxLength)
+		}
+		for _, partnerID := range field.RequiredForPartners {
+			if _, exists := c.Partners[partnerID]; !exists {
+				return fmt.Errorf("unknown partner %q for user data field %s in vertical %s", partnerID, name, vertical)
+			}
+		}
+	}
+	return nil
+}
+
+// Bid extension field types, named after the JSON value they accept
+const (
+	BidExtTypeString = "string"
+	BidExtTypeNumber = "number"
+	BidExtTypeBool   = "bool"
+	BidExtTypeObject = "object"
+	BidExtTypeArray  = "array"
+)
+
+// Bid extension size caps
+const (
+	DefaultBidExtMaxBytes = 4096
+	MaxBidExtMaxBytes     = 64 * 1024
+)
+
+// BidExtSchema describes the vertical_ext object a vertical's bids may carry: its top-level keys
+// by name, and its size cap in bytes. Keys not listed are passed through unchecked.
+type BidExtSchema struct {
+	Fields   map[string]*BidExtField `json:"fields" mapstructure:"fields"`
+	MaxBytes int                     `json:"maxBytes" mapstructure:"max_bytes"`
+}
+
+// BidExtField describes one vertical_ext key. A bid carrying an extension without a Required key
+// is invalid, as is one whose key holds a value of another Type.
+type BidExtField struct {
+	Type     string `json:"type" mapstructure:"type"`
+	Required bool   `json:"required" mapstructure:"required"`
+}
+
+// SizeLimit returns the extension size cap, defaulting to DefaultBidExtMaxBytes
+func (s *BidExtSchema) SizeLimit() int {
+	if s == nil || s.MaxBytes <= 0 {
+		return DefaultBidExtMaxBytes
+	}
+	return s.MaxBytes
+}
+
+// validate checks a vertical's bid extension schema
+func (s *BidExtSchema) validate(vertical string) error {
+	if s == nil {
+		return fmt.Errorf("empty bid extension schema for vertical %s", vertical)
+	}
+	if s.MaxBytes < 0 || s.MaxBytes > MaxBidExtMaxBytes {
+		return fmt.Errorf("bid extension max bytes for vertical %s must be between 0 and %d: %d", vertical, MaxBidExtMaxBytes, s.MaxBytes)
+	}
+	for name, field := range s.Fields {
+		if field == nil {
+			return fmt.Errorf("empty bid extension field %s for vertical %s", name, vertical)
+		}
+		switch field.Type {
+		case BidExtTypeString, BidExtTypeNumber, BidExtTypeBool, BidExtTypeObject, BidExtTypeArray:
+		default:
+			return fmt.Errorf("unknown type %q for bid extension field %s in vertical %s", field.Type, name, vertical)
+		}
+	}
+	return nil
+}
+
+// ExpectedPremium returns the expected premium that prices revenue-share bids in vertical
+func (c *Config) ExpectedPremium(vertical string) (float64, bool) {
+	premium, exists := c.ExpectedPremiums[vertical]
+	return premium, exists
+}
+
+// MinBiddersFor returns the distinct partners that must bid before a lead in vertical is sold,
+// falling back to the default entry and then to a single bidder
+func (c *Config) MinBiddersFor(vertical string) int {
+	if minBidders, exists := c.MinBidders[vertical]; exists {
+		return minBidders
+	}
+	if minBidders, exists := c.MinBidders[DefaultStrategyKey]; exists {
+		return minBidders
+	}
+	return 1
+}
+
+// PartnerWorkerLimit returns the most partner calls that run at once across all auctions,
+// falling back to the default when unset
+func (c *Config) PartnerWorkerLimit() int {
+	if c.PartnerWorkers > 0 {
+		return c.PartnerWorkers
+	}
+	return defaultPartnerWorkers
+}
+
+// DedupConfig selects the keys that identify the same demand resold by different partners.
+// Bids matching on any key are duplicates; Verticals overrides Keys per vertical.
+type DedupConfig struct {
+	Keys      []string            `json:"keys" mapstructure:"keys"`
+	Verticals map[string][]string `json:"verticals" mapstructure:"verticals"`
+}
+
+// KeysFor returns the dedup keys for a vertical; nil config disables deduplication
+func (d *DedupConfig) KeysFor(vertical string) []string {
+	if d == nil {
+		return nil
+	}
+	if keys, exists := d.Verticals[vertical]; exists {
+		return keys
+	}
+	return d.Keys
+}
+
+// validate rejects unknown dedup keys
+func (d *DedupConfig) validate() error {
+	if d == nil {
+		return nil
+	}
+	check := func(keys []string) error {
+		for _, key := range keys {
+			switch key {
+			case DedupKeyURL, DedupKeyAdomain, DedupKeyCreativeTitle:
+			default:
+				return fmt.Errorf("unknown dedup key %q", key)
+			}
+		}
+		return nil
+	}
+	if err := check(d.Keys); err != nil {
+		return err
+	}
+	for _, keys := range d.Verticals {
+		if err := check(keys); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Device types recognized by partner device multipliers
+const (
+	DeviceTypeDesktop = "desktop"
+	DeviceTypeMobile  = "mobile"
+	DeviceTypeTablet  = "tablet"
+	DeviceTypeBot     = "bot"
+)
+
+// deviceTypes lists the device types a partner may price
+var deviceTypes = map[string]bool{
+	DeviceTypeDesktop: true,
+	DeviceTypeMobile:  true,
+	DeviceTypeTablet:  true,
+	DeviceTypeBot:     true,
+}
+
+// Time multiplier bounds
+const (
+	MinTimeMultiplier = 0.5
+	MaxTimeMultiplier = 2.0
+)
+
+// TimeMultiplierConfig schedules effective-price multipliers by local time of day.
+// Verticals override the default rules; an empty schedule means a multiplier of 1.0.
+type TimeMultiplierConfig struct {
+	Timezone  string                          `json:"timezone" mapstructure:"timezone"`
+	Rules     []TimeMultiplierRule            `json:"rules" mapstructure:"rules"`
+	Verticals map[string][]TimeMultiplierRule `json:"verticals" mapstructure:"verticals"`
+}
+
+// TimeMultiplierRule applies a multiplier from StartHour (inclusive) to EndHour (exclusive) on
+// the given days, or every day when Days is empty
+type TimeMultiplierRule struct {
+	Days       []string `json:"days" mapstructure:"days"`
+	StartHour  int      `json:"startHour" mapstructure:"start_hour"`
+	EndHour    int      `json:"endHour" mapstructure:"end_hour"`
+	Multiplier float64  `json:"multiplier" mapstructure:"multiplier"`
+}
+
+// weekdays maps accepted day names to weekdays
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseWeekday parses a three-letter lowercase day name such as "mon"
+func ParseWeekday(day string) (time.Weekday, bool) {
+	weekday, ok := weekdays[day]
+	return weekday, ok
 }
 
-// PartnerConfig represents configuration for individual RTB partners
-type PartnerConfig struct {
-	ID                 string             `json:"id" mapstructure:"id"`
-	Endpoint           string             `json:"endpoint" mapstructure:"endpoint"`
-	APIKey             string             `json:"apiKey" mapstructure:"api_key"`
-	Timeout            time.Duration      `json:"timeout" mapstructure:"timeout"`
-	MinBid             float64            `json:"minBid" mapstructure:"min_bid"`
-	MaxBid             float64            `json:"maxBid" mapstructure:"max_bid"`
-	VerticalMultipliers map[string]float64 `json:"verticalMultipliers" mapstructure:"vertical_multipliers"`
-	Priority           int                `json:"priority" mapstructure:"priority"`
-	Enabled            bool               `json:"enabled" mapstructure:"enabled"`
+// RuleDays returns the weekdays a schedule entry covers, treating no days as every day
+func RuleDays(days []string) []time.Weekday {
+	if len(days) == 0 {
+		return []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}
+	}
+	result := make([]time.Weekday, 0, len(days))
+	for _, day := range days {
+		if weekday, ok := ParseWeekday(day); ok {
+			result = append(result, weekday)
+		}
+	}
+	return result
 }
 
-// RedisConfig represents Redis connection configuration
-type RedisConfig struct {
-	Host          string        `json:"host" mapstructure:"host"`
-	Port          int           `json:"port" mapstructure:"port"`
-	Password      string        `json:"password" mapstructure:"password"`
-	Database      int           `json:"database" mapstructure:"database"`
-	Timeout       time.Duration `json:"timeout" mapstructure:"timeout"`
-	MaxRetries    int           `json:"maxRetries" mapstructure:"max_retries"`
-	RetryInterval time.Duration `json:"retryInterval" mapstructure:"retry_interval"`
+// validate checks the schedule timezone, days, and hour ranges
+func (ps *PartnerSchedule) validate(partnerID string) error {
+	if ps == nil {
+		return nil
+	}
+	if _, err := time.LoadLocation(ps.Timezone); err != nil {
+		return fmt.Errorf("invalid schedule timezone %q for partner %s: %v", ps.Timezone, partnerID, err)
+	}
+	if len(ps.Windows) == 0 {
+		return fmt.Errorf("schedule for partner %s has no windows", partnerID)
+	}
+	for i, window := range ps.Windows {
+		if window.StartHour < 0 || window.EndHour > 24 || window.StartHour >= window.EndHour {
+			return fmt.Errorf("schedule window %d for partner %s has invalid hours %d-%d", i, partnerID, window.StartHour, window.EndHour)
+		}
+		for _, day := range window.Days {
+			if _, ok := ParseWeekday(day); !ok {
+				return fmt.Errorf("schedule window %d for partner %s has unknown day %q", i, partnerID, day)
+			}
+		}
+	}
+	return nil
+}
+
+// validateTimeMultiplierRules checks bounds and that no two rules overlap on the same day
+func validateTimeMultiplierRules(name string, rules []TimeMultiplierRule) error {
+	var covered [7][24]bool
+	for i, rule := range rules {
+		if rule.StartHour < 0 || rule.EndHour > 24 || rule.StartHour >= rule.EndHour {
+			return fmt.Errorf("time multiplier rule %d for %s has invalid hours %d-%d", i, name, rule.StartHour, rule.EndHour)
+		}
+		if rule.Multiplier < MinTimeMultiplier || rule.Multiplier > MaxTimeMultiplier {
+			return fmt.Errorf("time multiplier rule %d for %s must be between %v and %v: %v", i, name, MinTimeMultiplier, MaxTimeMultiplier, rule.Multiplier)
+		}
+		for _, day := range rule.Days {
+			if _, ok := ParseWeekday(day); !ok {
+				return fmt.Errorf("time multiplier rule %d for %s has unknown day %q", i, name, day)
+			}
+		}
+		for _, day := range RuleDays(rule.Days) {
+			for hour := rule.StartHour; hour < rule.EndHour; hour++ {
+				if covered[day][hour] {
+					return fmt.Errorf("time multiplier rule %d for %s overlaps another rule on %s at %02d:00", i, name, day, hour)
+				}
+				covered[day][hour] = true
+			}
+		}
+	}
+	return nil
 }
 
 // MetricsConfig represents metrics collection configuration
@@ -69,6 +3360,22 @@ type MetricsConfig struct {
 
 // LoadConfig loads and validates RTB service configuration from multiple sources
 func LoadConfig(configPath string) (*Config, error) {
+	config, err := ReadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Validate configuration
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("config validation failed: %w", err)
+	}
+
+	return config, nil
+}
+
+// ReadConfig loads RTB service configuration from the config file, environment, and defaults
+// without validating it
+func ReadConfig(configPath string) (*Config, error) {
 	v := viper.New()
 
 	// Set default values
@@ -79,6 +3386,78 @@ func LoadConfig(configPath string) (*Config, error) {
 	v.SetDefault("max_bid_price", defaultMaxBidPrice)
 	v.SetDefault("enable_dynamic_pricing", true)
 	v.SetDefault("config_reload_interval", time.Minute)
+	v.SetDefault("health_cache_ttl", defaultHealthCacheTTL)
+	v.SetDefault("duplicate_request_window", time.Minute)
+	v.SetDefault("max_concurrent_streams", 100)
+	v.SetDefault("partner_workers", defaultPartnerWorkers)
+	v.SetDefault("service_region", "")
+	v.SetDefault("json_codec", JSONCodecStd)
+	v.SetDefault("price_rounding.precision", DefaultPricePrecision)
+	v.SetDefault("price_rounding.mode", RoundingHalfUp)
+	v.SetDefault("ivt.action", IVTActionReject)
+	v.SetDefault("ivt.lead_repeat_window", time.Hour)
+	v.SetDefault("negative_cache.ttl", DefaultNegativeCacheTTL)
+	v.SetDefault("timeout_budget.reserve_fraction", DefaultBudgetReserveFraction)
+	v.SetDefault("timeout_budget.serialization", DefaultSerializationReserve)
+	v.SetDefault("quorum_failure_status", http.StatusOK)
+	v.SetDefault("strategies", map[string]string{DefaultStrategyKey: StrategyEffectivePrice})
+	v.SetDefault("scoring.timeout", maxScoringTimeout)
+	v.SetDefault("scoring.blend_weight", 0.5)
+	v.SetDefault("consent.action", ConsentActionStrip)
+	v.SetDefault("dedup.keys", []string{DedupKeyURL, DedupKeyAdomain})
+	v.SetDefault("compression.decompress_requests", true)
+	v.SetDefault("compression.compress_responses", true)
+	v.SetDefault("compression.max_decompressed_bytes", defaultMaxDecompressedBytes)
+	v.SetDefault("http_client.max_idle_conns", 100)
+	v.SetDefault("http_client.max_idle_conns_per_host", 2)
+	v.SetDefault("http_client.idle_conn_timeout", 90*time.Second)
+	v.SetDefault("http_client.tls_handshake_timeout", 10*time.Second)
+	v.SetDefault("http_client.enable_http2", true)
+	v.SetDefault("batch.max_items", 100)
+	v.SetDefault("batch.concurrency", 10)
+	v.SetDefault("batch.timeout", 5*time.Second)
+	v.SetDefault("batch.priority", BatchPriorityLow)
+	v.SetDefault("batch.live_reserve", 0.2)
+	v.SetDefault("analytics.windows", 168)
+	v.SetDefault("analytics.max_series", 100)
+	v.SetDefault("analytics.precision", 0.02)
+	v.SetDefault("analytics.floors.percentile", 0.2)
+	v.SetDefault("analytics.floors.window", 24*time.Hour)
+	v.SetDefault("analytics.floors.min_samples", 100)
+	v.SetDefault("analytics.floors.refresh_interval", time.Minute)
+	v.SetDefault("audit.path", "audit/winning-bids.jsonl")
+	v.SetDefault("audit.max_size_bytes", 100<<20)
+	v.SetDefault("audit.max_files", 10)
+	v.SetDefault("audit.compress", true)
+	v.SetDefault("audit.buffer_size", 10000)
+	v.SetDefault("recording.sample_rate", 0.01)
+	v.SetDefault("recording.path", "recordings/auctions.jsonl")
+	v.SetDefault("recording.max_size_bytes", 1<<30)
+	v.SetDefault("recording.buffer_size", 1000)
+	v.SetDefault("export.sample_rate", 0.01)
+	v.SetDefault("export.format", ExportFormatNDJSON)
+	v.SetDefault("export.window", 24*time.Hour)
+	v.SetDefault("export.flush_interval", 5*time.Minute)
+	v.SetDefault("export.max_file_rows", 500000)
+	v.SetDefault("export.buffer_size", 10000)
+	v.SetDefault("self_test.auctions", 3)
+	v.SetDefault("estimates.window", 24*time.Hour)
+	v.SetDefault("estimates.min_samples", 20)
+	v.SetDefault("estimates.high_confidence_samples", 200)
+	v.SetDefault("estimates.cache_max_age", 5*time.Minute)
+	v.SetDefault("reservations.ttl", 10*time.Minute)
+	v.SetDefault("reservations.sweep_interval", 10*time.Second)
+	v.SetDefault("reservations.max_entries", 10000)
+	v.SetDefault("webhooks.timeout", 5*time.Second)
+	v.SetDefault("webhooks.max_attempts", 5)
+	v.SetDefault("webhooks.backoff_base", time.Second)
+	v.SetDefault("webhooks.backoff_max", time.Minute)
+	v.SetDefault("webhooks.workers", 4)
+	v.SetDefault("webhooks.queue_size", 10000)
+	v.SetDefault("webhooks.dead_letter_path", "audit/webhook-dead-letters.jsonl")
+	v.SetDefault("webhooks.recent_failures", 100)
+	v.SetDefault("circuit_breaker.failure_threshold", defaultBreakerFailures)
+	v.SetDefault("circuit_breaker.cooldown", defaultBreakerCooldown)
 
 	// Configure Viper
 	v.SetEnvPrefix("RTB")
@@ -93,15 +3472,15 @@ func LoadConfig(configPath string) (*Config, error) {
 	}
 
 	config := &Config{}
-	if err := v.Unmarshal(config); err != nil {
+	decodeHook := mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+		mapstructure.StringToTimeHookFunc(time.RFC3339),
+	)
+	if err := v.Unmarshal(config, viper.DecodeHook(decodeHook)); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
-	// Validate configuration
-	if err := config.Validate(); err != nil {
-		return nil, fmt.Errorf("config validation failed: %w", err)
-	}
-
 	return config, nil
 }
 
@@ -112,6 +3491,10 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("invalid port number: %d", c.Port)
 	}
 
+	if c.GRPCPort != 0 && (c.GRPCPort < 1024 || c.GRPCPort > 65535 || c.GRPCPort == c.Port) {
+		return fmt.Errorf("invalid gRPC port number: %d", c.GRPCPort)
+	}
+
 	if c.BidTimeout < 100*time.Millisecond || c.BidTimeout > time.Second {
 		return fmt.Errorf("bid timeout must be between 100ms and 1s")
 	}
@@ -123,20 +3506,101 @@ func (c *Config) Validate() error {
 	// Validate partner configurations
 	for id, partner := range c.Partners {
 		if partner.Enabled {
-			if partner.Endpoint == "" {
-				return fmt.Errorf("missing endpoint for partner %s", id)
+			if err := partner.validateEndpoints(id); err != nil {
+				return err
 			}
-			if partner.APIKey == "" {
-				return fmt.Errorf("missing API key for partner %s", id)
+			if err := partner.Auth.validate(id, partner.APIKey); err != nil {
+				return err
+			}
+			if err := partner.TLS.validate(id, partner.EndpointList(), c.AllowInsecurePartnerTLS); err != nil {
+				return err
+			}
+			if err := partner.validateTemplates(id); err != nil {
+				return err
 			}
 			if partner.Timeout < 50*time.Millisecond || partner.Timeout > c.BidTimeout {
 				return fmt.Errorf("invalid timeout for partner %s", id)
 			}
+			if partner.MaxQPS < 0 {
+				return fmt.Errorf("invalid max QPS for partner %s", id)
+			}
+			if partner.MaxBidsPerResponse < 0 || partner.MaxBidsPerResponse > 50 {
+				return fmt.Errorf("max bids per response must be between 0 and 50 for partner %s", id)
+			}
+			if partner.MaxResponseBytes < 0 || partner.MaxResponseBytes > maxPartnerResponseBytes {
+				return fmt.Errorf("max response bytes must be between 0 and %d for partner %s", maxPartnerResponseBytes, id)
+			}
+			if hold := partner.BudgetHold(c.MaxBidPrice); partner.DailyBudget < 0 || (partner.DailyBudget > 0 && partner.DailyBudget < hold) {
+				return fmt.Errorf("daily budget for partner %s must be 0 or at least its max clearing price %v: %v", id, hold, partner.DailyBudget)
+			}
+			if err := partner.validateBudgetAlerts(id); err != nil {
+				return err
+			}
+			if partner.TrafficPercentage < 0 || partner.TrafficPercentage > 100 {
+				return fmt.Errorf("traffic percentage must be between 0 and 100 for partner %s: %v", id, partner.TrafficPercentage)
+			}
+			if err := partner.Mirror.validate(id); err != nil {
+				return err
+			}
+			if err := partner.validateFields(id, c.UserDataSchemas); err != nil {
+				return err
+			}
+			switch partner.WarmupMethod {
+			case "", WarmupConnect, WarmupHead, WarmupOptions:
+			default:
+				return fmt.Errorf("unknown warmup method for partner %s: %q", id, partner.WarmupMethod)
+			}
+			if err := partner.Schedule.validate(id); err != nil {
+				return err
+			}
+			if err := partner.validateFormat(id); err != nil {
+				return err
+			}
+			if err := partner.validatePayloadExperiment(id, c.UserDataSchemas, c.AllowInsecurePartnerTLS); err != nil {
+				return err
+			}
+			if err := partner.Retry.validate(id, partner.Timeout); err != nil {
+				return err
+			}
+			if err := partner.SLA.validate(id, partner.Timeout); err != nil {
+				return err
+			}
 			for vertical, multiplier := range partner.VerticalMultipliers {
 				if multiplier < 0.1 || multiplier > 10.0 {
 					return fmt.Errorf("invalid multiplier %v for vertical %s in partner %s", multiplier, vertical, id)
 				}
 			}
+			for device, multiplier := range partner.DeviceMultipliers {
+				if !deviceTypes[device] {
+					return fmt.Errorf("unknown device type %q in partner %s", device, id)
+				}
+				if multiplier < 0.1 || multiplier > 10.0 {
+					return fmt.Errorf("invalid multiplier %v for device %s in partner %s", multiplier, device, id)
+				}
+			}
+			for _, region := range partner.AllowedRegions {
+				if region == "" {
+					return fmt.Errorf("empty allowed region in partner %s", id)
+				}
+			}
+			for _, region := range partner.PreferredRegions {
+				if !serviceRegionPattern.MatchString(region) {
+					return fmt.Errorf("invalid preferred region %q in partner %s", region, id)
+				}
+			}
+			if pricing := partner.Pricing(); pricing != PricingModelCPL && pricing != PricingModelRevShare {
+				return fmt.Errorf("unknown pricing model %q in partner %s", partner.PricingModel, id)
+			}
+			if mode := partner.Validation(); mode != ResponseValidationStrict && mode != ResponseValidationLenient {
+				return fmt.Errorf("unknown response validation mode %q in partner %s", partner.ResponseValidation, id)
+			}
+		}
+	}
+
+	// Validate expected premiums for revenue-share pricing
+	for vertical, premium := range c.ExpectedPremiums {
+		if premium <= 0 {
+			return fmt.Errorf("expected premium for vertical %s must be greater than 0: %v", vertical, premium)
 		}
 	}
 
@@ -151,6 +3615,437 @@ func (c *Config) Validate() error {
 		if c.Redis.Timeout < 50*time.Millisecond {
 			return fmt.Errorf("Redis timeout too low: %v", c.Redis.Timeout)
 		}
+		if stale := c.Redis.StaleReads; stale.Active() && stale.Expiry() <= stale.Freshness() {
+			return fmt.Errorf("Redis stale reads hard ttl %v must be longer than fresh_for %v", stale.Expiry(), stale.Freshness())
+		}
+	}
+
+	// Validate circuit breaker configuration
+	if c.CircuitBreaker != nil {
+		if c.CircuitBreaker.FailureThreshold < 1 {
+			return fmt.Errorf("circuit breaker failure threshold must be at least 1")
+		}
+		if c.CircuitBreaker.Cooldown < time.Second {
+			return fmt.Errorf("circuit breaker cooldown too low: %v", c.CircuitBreaker.Cooldown)
+		}
+		// Saved state must outlive the cooldown, or a breaker opened just before a restart is forgotten
+		if c.CircuitBreaker.StateTTL < 0 || (c.CircuitBreaker.StateTTL > 0 && c.CircuitBreaker.StateTTL < c.CircuitBreaker.Cooldown) {
+			return fmt.Errorf("circuit breaker state TTL must be at least the cooldown: %v", c.CircuitBreaker.StateTTL)
+		}
+		if c.CircuitBreaker.PersistInterval < 0 || (c.CircuitBreaker.PersistInterval > 0 && c.CircuitBreaker.PersistInterval < 100*time.Millisecond) {
+			return fmt.Errorf("circuit breaker persist interval too low: %v", c.CircuitBreaker.PersistInterval)
+		}
+	}
+
+	// Validate admin configuration
+	if c.Admin != nil && c.Admin.Enabled {
+		if len(c.Admin.APIKeys) == 0 {
+			return fmt.Errorf("admin endpoints enabled without API keys")
+		}
+		for _, key := range c.Admin.APIKeys {
+			if len(key) < 16 {
+				return fmt.Errorf("admin API keys must be at least 16 characters")
+			}
+		}
+	}
+
+	// Validate idempotency configuration
+	if c.Idempotency != nil && c.Idempotency.Enabled {
+		if c.Idempotency.Window < time.Second || c.Idempotency.Window > 24*time.Hour {
+			return fmt.Errorf("idempotency window must be between 1s and 24h: %v", c.Idempotency.Window)
+		}
+		if c.Idempotency.MaxEntries < 0 {
+			return fmt.Errorf("invalid idempotency max entries: %d", c.Idempotency.MaxEntries)
+		}
+		for _, field := range c.Idempotency.FingerprintFields {
+			if field == "" {
+				return fmt.Errorf("empty idempotency fingerprint field")
+			}
+		}
+	}
+
+	// Validate concurrency and batch configuration
+	if c.MaxConcurrentAuctions < 0 {
+		return fmt.Errorf("invalid max concurrent auctions: %d", c.MaxConcurrentAuctions)
+	}
+	if c.MaxConcurrentStreams < 0 {
+		return fmt.Errorf("invalid max concurrent streams: %d", c.MaxConcurrentStreams)
+	}
+	if err := c.validateVerticalLimits(); err != nil {
+		return err
+	}
+	if c.PartnerWorkers < 0 {
+		return fmt.Errorf("invalid partner workers: %d", c.PartnerWorkers)
+	}
+
+	if c.MaxPartnersPerAuction < 0 {
+		return fmt.Errorf("invalid max partners per auction: %d", c.MaxPartnersPerAuction)
+	}
+	switch c.PartnerSelectionMode {
+	case "", PartnerSelectionPerformance, PartnerSelectionFair:
+	default:
+		return fmt.Errorf("unknown partner selection mode: %s", c.PartnerSelectionMode)
+	}
+	if c.Batch != nil {
+		if c.Batch.MaxItems < 1 || c.Batch.MaxItems > 1000 {
+			return fmt.Errorf("batch max items must be between 1 and 1000: %d", c.Batch.MaxItems)
+		}
+		if c.Batch.Concurrency < 1 {
+			return fmt.Errorf("batch concurrency must be at least 1")
+		}
+		if c.Batch.Timeout < c.BidTimeout {
+			return fmt.Errorf("batch timeout %v must not be below bid timeout %v", c.Batch.Timeout, c.BidTimeout)
+		}
+		if c.Batch.Priority != BatchPriorityEqual && c.Batch.Priority != BatchPriorityLow {
+			return fmt.Errorf("invalid batch priority: %s", c.Batch.Priority)
+		}
+		if c.Batch.LiveReserve < 0 || c.Batch.LiveReserve >= 1 {
+			return fmt.Errorf("batch live reserve must be in [0, 1): %v", c.Batch.LiveReserve)
+		}
+	}
+
+	// Validate optimization strategies
+	for vertical, strategy := range c.Strategies {
+		switch strategy {
+		case StrategyEffectivePrice, StrategyQualityWeighted, StrategyPassthrough:
+		default:
+			return fmt.Errorf("unknown optimization strategy %q for vertical %s", strategy, vertical)
+		}
+	}
+
+	// Validate JSON codec
+	switch c.JSONCodec {
+	case "", JSONCodecStd, JSONCodecFast:
+	default:
+		return fmt.Errorf("unknown json codec %q", c.JSONCodec)
+	}
+
+	// Validate price rounding
+	if c.PriceRounding != nil {
+		if c.PriceRounding.Precision < 0 || c.PriceRounding.Precision > MaxPricePrecision {
+			return fmt.Errorf("price precision must be between 0 and %d: %d", MaxPricePrecision, c.PriceRounding.Precision)
+		}
+		switch c.PriceRounding.Mode {
+		case "", RoundingHalfUp, RoundingFloor:
+		default:
+			return fmt.Errorf("unknown price rounding mode %q", c.PriceRounding.Mode)
+		}
+	}
+
+	// Validate scoring configuration
+	if c.Scoring != nil && c.Scoring.Enabled {
+		if c.Scoring.Endpoint == "" {
+			return fmt.Errorf("missing scoring endpoint")
+		}
+		if c.Scoring.Timeout <= 0 || c.Scoring.Timeout > maxScoringTimeout {
+			return fmt.Errorf("scoring timeout must be between 0 and %v: %v", maxScoringTimeout, c.Scoring.Timeout)
+		}
+		if c.Scoring.BlendWeight < 0 || c.Scoring.BlendWeight > 1 {
+			return fmt.Errorf("scoring blend weight must be between 0 and 1: %v", c.Scoring.BlendWeight)
+		}
+	}
+
+	// Validate consent configuration
+	if c.Consent != nil && c.Consent.Action != ConsentActionStrip && c.Consent.Action != ConsentActionHash {
+		return fmt.Errorf("invalid consent action: %s", c.Consent.Action)
+	}
+
+	// Validate enrichment configuration
+	if c.Enrichment != nil && c.Enrichment.GeoIPDatabasePath != "" {
+		if _, err := os.Stat(c.Enrichment.GeoIPDatabasePath); err != nil {
+			return fmt.Errorf("invalid GeoIP database path: %v", err)
+		}
+	}
+
+	if err := c.PIIPolicy.validate(); err != nil {
+		return err
+	}
+
+	if err := c.Dedup.validate(); err != nil {
+		return err
+	}
+
+	for dealID, deal := range c.Deals {
+		if err := deal.validate(dealID, c); err != nil {
+			return err
+		}
+	}
+
+	for vertical, schema := range c.UserDataSchemas {
+		if err := schema.validate(vertical, c); err != nil {
+			return err
+		}
+	}
+	for vertical, schema := range c.BidExtSchemas {
+		if err := schema.validate(vertical); err != nil {
+			return err
+		}
+	}
+
+	if err := c.IVT.validate(); err != nil {
+		return err
+	}
+
+	if err := validateResponseProfiles(c.ResponseProfiles); err != nil {
+		return err
+	}
+	if err := c.NoBidResponse.validate(); err != nil {
+		return err
+	}
+	if err := c.validateHouseOffers(); err != nil {
+		return err
+	}
+
+	if err := c.NegativeCache.validate(c.Partners); err != nil {
+		return err
+	}
+	if err := c.ReplayProtection.validate(); err != nil {
+		return err
+	}
+
+	if err := c.TimeoutBudget.validate(c.BidTimeout); err != nil {
+		return err
+	}
+
+	if c.QualityWeight != nil && (*c.QualityWeight < 0 || *c.QualityWeight > 1) {
+		return fmt.Errorf("quality weight must be in [0, 1]: %v", *c.QualityWeight)
+	}
+	if err := c.validateResponseTTLs(); err != nil {
+		return err
+	}
+	if err := c.ValidateVerticals(); err != nil {
+		return err
+	}
+	if err := c.Localization.validate(); err != nil {
+		return err
+	}
+	if err := c.validatePartnerGroups(); err != nil {
+		return err
+	}
+	if err := c.Budgets.validate(c.BidTimeout); err != nil {
+		return err
+	}
+	if err := c.Canary.validate(c.Partners); err != nil {
+		return err
+	}
+	if err := c.AssetVerification.validate(); err != nil {
+		return err
+	}
+	if err := c.AuctionSequence.validate(); err != nil {
+		return err
+	}
+	if err := c.Rules.validate(); err != nil {
+		return err
+	}
+	if err := c.Hygiene.validate(); err != nil {
+		return err
+	}
+	if c.ServiceRegion != "" && !serviceRegionPattern.MatchString(c.ServiceRegion) {
+		return fmt.Errorf("invalid service region %q: use lowercase letters, digits, and hyphens", c.ServiceRegion)
+	}
+	if c.QualityAcknowledgedBonus < 0 || c.QualityAcknowledgedBonus > MaxQualityAcknowledgedBonus {
+		return fmt.Errorf("quality acknowledged bonus must be in [0, %v]: %v", MaxQualityAcknowledgedBonus, c.QualityAcknowledgedBonus)
+	}
+	if err := c.validateExperiments(); err != nil {
+		return err
+	}
+	if err := c.Capture.validate(); err != nil {
+		return err
+	}
+	if err := c.PayloadLimits.validate(); err != nil {
+		return err
+	}
+	if err := c.RateLimitBackoff.validate(); err != nil {
+		return err
+	}
+
+	// Validate auction quorum configuration
+	for vertical, minBidders := range c.MinBidders {
+		if minBidders < 1 {
+			return fmt.Errorf("min bidders must be at least 1 for vertical %s", vertical)
+		}
+	}
+	if c.QuorumFailureStatus != 0 && c.QuorumFailureStatus != http.StatusOK && c.QuorumFailureStatus != http.StatusNoContent {
+		return fmt.Errorf("quorum failure status must be 200 or 204: %d", c.QuorumFailureStatus)
+	}
+
+	if c.Compression != nil && c.Compression.DecompressRequests && c.Compression.MaxDecompressedBytes < 1024 {
+		return fmt.Errorf("max decompressed bytes too low: %d", c.Compression.MaxDecompressedBytes)
+	}
+
+	// Validate partner HTTP client configuration
+	if c.HTTPClient != nil {
+		if c.HTTPClient.MaxIdleConns < 0 || c.HTTPClient.MaxIdleConnsPerHost < 1 {
+			return fmt.Errorf("invalid idle connection limits: max=%d, per host=%d",
+				c.HTTPClient.MaxIdleConns, c.HTTPClient.MaxIdleConnsPerHost)
+		}
+		if c.HTTPClient.IdleConnTimeout < time.Second {
+			return fmt.Errorf("idle connection timeout too low: %v", c.HTTPClient.IdleConnTimeout)
+		}
+		if c.HTTPClient.TLSHandshakeTimeout < 10*time.Millisecond || c.HTTPClient.TLSHandshakeTimeout > time.Minute {
+			return fmt.Errorf("TLS handshake timeout must be between 10ms and 1m: %v", c.HTTPClient.TLSHandshakeTimeout)
+		}
+		if c.HTTPClient.DNSCacheTTL < 0 || c.HTTPClient.DNSCacheTTL > time.Hour {
+			return fmt.Errorf("DNS cache TTL must be between 0 and 1h: %v", c.HTTPClient.DNSCacheTTL)
+		}
+	}
+	if err := c.Warmup.validate(c.HTTPClient); err != nil {
+		return err
+	}
+
+	// Validate price analytics configuration
+	if c.Analytics != nil && c.Analytics.Enabled {
+		if c.Analytics.Windows < 1 || c.Analytics.Windows > 720 {
+			return fmt.Errorf("analytics windows must be between 1 and 720 hours: %d", c.Analytics.Windows)
+		}
+		if c.Analytics.MaxSeries < 1 || c.Analytics.MaxSeries > 10000 {
+			return fmt.Errorf("analytics max series must be between 1 and 10000: %d", c.Analytics.MaxSeries)
+		}
+		if c.Analytics.Precision < 0.001 || c.Analytics.Precision > 0.1 {
+			return fmt.Errorf("analytics precision must be between 0.001 and 0.1: %v", c.Analytics.Precision)
+		}
+		if err := c.Analytics.Floors.validate(c); err != nil {
+			return err
+		}
+	} else if c.Analytics != nil && c.Analytics.Floors != nil && c.Analytics.Floors.Enabled {
+		return fmt.Errorf("adaptive floors require price analytics")
+	}
+
+	// Validate audit log configuration
+	if c.Audit != nil && c.Audit.Enabled {
+		if c.Audit.Path == "" {
+			return fmt.Errorf("missing audit log path")
+		}
+		if c.Audit.MaxSizeBytes < 1024 {
+			return fmt.Errorf("audit max size too low: %d", c.Audit.MaxSizeBytes)
+		}
+		if c.Audit.MaxFiles < 1 || c.Audit.MaxFiles > 1000 {
+			return fmt.Errorf("audit max files must be between 1 and 1000: %d", c.Audit.MaxFiles)
+		}
+		if c.Audit.BufferSize < 1 {
+			return fmt.Errorf("audit buffer size must be at least 1")
+		}
+	}
+
+	// Validate auction recording configuration
+	if c.Recording != nil && c.Recording.Enabled {
+		if c.Recording.SampleRate <= 0 || c.Recording.SampleRate > 1 {
+			return fmt.Errorf("recording sample rate must be above 0 and at most 1: %v", c.Recording.SampleRate)
+		}
+		if c.Recording.Path == "" {
+			return fmt.Errorf("missing recording path")
+		}
+		if c.Recording.MaxSizeBytes < 1024 {
+			return fmt.Errorf("recording max size too low: %d", c.Recording.MaxSizeBytes)
+		}
+		if c.Recording.BufferSize < 1 {
+			return fmt.Errorf("recording buffer size must be at least 1")
+		}
+	}
+
+	// Validate auction export configuration
+	if c.Export != nil && c.Export.Enabled {
+		if c.Export.SampleRate <= 0 || c.Export.SampleRate > 1 {
+			return fmt.Errorf("export sample rate must be above 0 and at most 1: %v", c.Export.SampleRate)
+		}
+		switch c.Export.Format {
+		case "", ExportFormatNDJSON, ExportFormatCSV:
+		default:
+			return fmt.Errorf("unknown export format: %s", c.Export.Format)
+		}
+		if c.Export.Window < time.Minute || c.Export.Window > 24*time.Hour {
+			return fmt.Errorf("export window must be between 1m and 24h: %v", c.Export.Window)
+		}
+		if c.Export.FlushInterval <= 0 || c.Export.FlushInterval > c.Export.Window {
+			return fmt.Errorf("export flush interval must be above 0 and at most the window: %v", c.Export.FlushInterval)
+		}
+		if c.Export.MaxFileRows < 1 {
+			return fmt.Errorf("export max file rows must be at least 1")
+		}
+		if c.Export.BufferSize < 1 {
+			return fmt.Errorf("export buffer size must be at least 1")
+		}
+		if (c.Export.Dir == "") == (c.Export.S3 == nil) {
+			return fmt.Errorf("export needs exactly one of dir and s3")
+		}
+		if s3 := c.Export.S3; s3 != nil {
+			if s3.Endpoint == "" || s3.Bucket == "" || s3.Region == "" {
+				return fmt.Errorf("export s3 needs an endpoint, bucket, and region")
+			}
+			if s3.AccessKeyID == "" || s3.SecretAccessKey == "" {
+				return fmt.Errorf("export s3 needs an access key id and secret access key")
+			}
+		}
+	}
+
+	// Validate self-test configuration
+	if c.SelfTest != nil && c.SelfTest.Enabled {
+		if c.SelfTest.Auctions < 1 || c.SelfTest.Auctions > 100 {
+			return fmt.Errorf("self-test auctions must be between 1 and 100: %d", c.SelfTest.Auctions)
+		}
+	}
+
+	// Validate estimate configuration
+	if c.Estimates != nil && c.Estimates.Enabled {
+		if c.Estimates.Window < time.Hour || c.Estimates.Window > 720*time.Hour {
+			return fmt.Errorf("estimate window must be between 1h and 720h: %v", c.Estimates.Window)
+		}
+		if c.Analytics != nil && c.Analytics.Enabled && c.Estimates.Window > time.Duration(c.Analytics.Windows)*time.Hour {
+			return fmt.Errorf("estimate window %v exceeds the %dh of price analytics kept", c.Estimates.Window, c.Analytics.Windows)
+		}
+		if c.Estimates.MinSamples < 1 {
+			return fmt.Errorf("estimate min samples must be at least 1: %d", c.Estimates.MinSamples)
+		}
+		if c.Estimates.HighConfidenceSamples < c.Estimates.MinSamples {
+			return fmt.Errorf("estimate high confidence samples must be at least min samples: %d", c.Estimates.HighConfidenceSamples)
+		}
+		if c.Estimates.CacheMaxAge < 0 || c.Estimates.CacheMaxAge > time.Hour {
+			return fmt.Errorf("estimate cache max age must be between 0 and 1h: %v", c.Estimates.CacheMaxAge)
+		}
+		for vertical, estimate := range c.Estimates.Static {
+			if estimate.Low < 0 || estimate.High < estimate.Low {
+				return fmt.Errorf("invalid static estimate for vertical %s: %v-%v", vertical, estimate.Low, estimate.High)
+			}
+		}
+	}
+
+	// Validate reservation configuration
+	if c.Reservations != nil && c.Reservations.Enabled {
+		if c.Reservations.TTL < time.Second || c.Reservations.TTL > 24*time.Hour {
+			return fmt.Errorf("reservation TTL must be between 1s and 24h: %v", c.Reservations.TTL)
+		}
+		if c.Reservations.SweepInterval < 100*time.Millisecond || c.Reservations.SweepInterval > time.Minute {
+			return fmt.Errorf("reservation sweep interval must be between 100ms and 1m: %v", c.Reservations.SweepInterval)
+		}
+		if c.Reservations.MaxEntries < 1 {
+			return fmt.Errorf("reservation max entries must be at least 1: %d", c.Reservations.MaxEntries)
+		}
+	}
+
+	// Validate webhook configuration
+	if c.Webhooks != nil && c.Webhooks.Enabled {
+		if err := c.Webhooks.validate(); err != nil {
+			return err
+		}
+	}
+
+	// Validate time-of-day multipliers
+	if c.TimeMultipliers != nil {
+		if _, err := time.LoadLocation(c.TimeMultipliers.Timezone); err != nil {
+			return fmt.Errorf("invalid time multiplier timezone %q: %v", c.TimeMultipliers.Timezone, err)
+		}
+		if err := validateTimeMultiplierRules("default", c.TimeMultipliers.Rules); err != nil {
+			return err
+		}
+		for vertical, rules := range c.TimeMultipliers.Verticals {
+			if err := validateTimeMultiplierRules(vertical, rules); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.HealthCacheTTL < 0 || c.HealthCacheTTL > time.Minute {
+		return fmt.Errorf("health cache TTL must be between 0 and 1m: %v", c.HealthCacheTTL)
 	}
 
 	// Validate metrics configuration
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config change kinds
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeChanged = "changed"
+)
+
+// redactedValue replaces the values of sensitive settings in a diff
+const redactedValue = "[redacted]"
+
+// sensitiveFields are the JSON names of settings whose values are never shown in a diff. Most
+// secrets are left out of the config's JSON entirely and so never reach a diff at all.
+var sensitiveFields = map[string]bool{
+	"password": true, "secret": true, "token": true, "apikey": true, "apikeys": true, "signingkey": true,
+	"key": true, "cert": true, "accesskeyid": true, "secretaccesskey": true,
+}
+
+// Change is one difference between two configs. Path names the setting by its JSON field names
+// and map keys, such as partners.partner-1.timeout. Old and New are set for scalar and list
+// settings; added and removed sections, such as a partner, carry no values.
+type Change struct {
+	Path string      `json:"path"`
+	Kind string      `json:"kind"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// Hash returns a short digest of the config's content, which identifies a config version.
+// Secrets left out of the config's JSON do not contribute, so rotating them keeps the hash.
+func (c *Config) Hash() string {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Diff returns the differences from previous to current, ordered by path, with the values of
+// sensitive settings redacted
+func Diff(previous, current *Config) []Change {
+	var changes []Change
+	diffValues("", reflect.ValueOf(previous), reflect.ValueOf(current), false, &changes)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// diffValues appends the differences between two values of the same type at path
+func diffValues(path string, previous, current reflect.Value, sensitive bool, changes *[]Change) {
+	if previous.Kind() == reflect.Pointer || previous.Kind() == reflect.Interface {
+		switch {
+		case previous.IsNil() && current.IsNil():
+			return
+		case previous.IsNil():
+			*changes = append(*changes, Change{Path: path, Kind: ChangeAdded, New: displayValue(current, sensitive)})
+			return
+		case current.IsNil():
+			*changes = append(*changes, Change{Path: path, Kind: ChangeRemoved, Old: displayValue(previous, sensitive)})
+			return
+		}
+		diffValues(path, previous.Elem(), current.Elem(), sensitive, changes)
+		return
+	}
+
+	switch previous.Kind() {
+	case reflect.Struct:
+		if previous.Type() == reflect.TypeOf(time.Time{}) {
+			break
+		}
+		for i := 0; i < previous.NumField(); i++ {
+			field := previous.Type().Field(i)
+			name := jsonFieldName(field)
+			if name == "" {
+				continue
+			}
+			diffValues(joinPath(path, name), previous.Field(i), current.Field(i),
+				sensitive || sensitiveFields[strings.ToLower(name)], changes)
+		}
+		return
+	case reflect.Map:
+		keys := make(map[string]reflect.Value)
+		for _, key := range append(previous.MapKeys(), current.MapKeys()...) {
+			keys[fmt.Sprint(key.Interface())] = key
+		}
+		for name, key := range keys {
+			oldValue, newValue := previous.MapIndex(key), current.MapIndex(key)
+			switch {
+			case !oldValue.IsValid():
+				*changes = append(*changes, Change{Path: joinPath(path, name), Kind: ChangeAdded, New: displayValue(newValue, sensitive)})
+			case !newValue.IsValid():
+				*changes = append(*changes, Change{Path: joinPath(path, name), Kind: ChangeRemoved, Old: displayValue(oldValue, sensitive)})
+			default:
+				diffValues(joinPath(path, name), oldValue, newValue, sensitive, changes)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(previous.Interface(), current.Interface()) {
+		*changes = append(*changes, Change{Path: path, Kind: ChangeChanged,
+			Old: displayValue(previous, sensitive), New: displayValue(current, sensitive)})
+	}
+}
+
+// jsonFieldName returns the name a struct field has in the config's JSON, or "" for fields
+// left out of it
+func jsonFieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// joinPath appends a field name or map key to a path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+// displayValue returns how a value is shown in a diff: durations as text and other scalars and
+// lists of scalars as they are. Sensitive values are redacted, and sections such as structs and
+// maps are left out, since they may hold secrets and their paths already say what changed.
+func displayValue(value reflect.Value, sensitive bool) interface{} {
+	for value.Kind() == reflect.Pointer || value.Kind() == reflect.Interface {
+		if value.IsNil() {
+			return nil
+		}
+		value = value.Elem()
+	}
+	if sensitive {
+		return redactedValue
+	}
+	if duration, ok := value.Interface().(time.Duration); ok {
+		return duration.String()
+	}
+	switch value.Kind() {
+	case reflect.Map:
+		return nil
+	case reflect.Struct:
+		if t, ok := value.Interface().(time.Time); ok {
+			return t
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		switch value.Type().Elem().Kind() {
+		case reflect.Struct, reflect.Map, reflect.Pointer, reflect.Interface, reflect.Slice:
+			return nil
+		}
+	}
+	return value.Interface()
+}
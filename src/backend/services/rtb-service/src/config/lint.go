@@ -0,0 +1,188 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Lint finding severities. Errors fail a validate-only run; warnings flag settings that load but
+// probably do not do what was intended.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// Lint finding codes
+const (
+	LintInvalidConfig    = "invalid_config"
+	LintUnusedPriority   = "unused_priority"
+	LintNoControlTraffic = "no_control_traffic"
+	LintUnknownVertical  = "unknown_vertical"
+	LintDealNeverMatches = "deal_never_matches"
+	LintFloorAboveCap    = "floor_above_cap"
+)
+
+// Finding is one problem Lint found, located by the config path of the offending setting
+type Finding struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Path     string `json:"path"`
+	Message  string `json:"message"`
+}
+
+// Lint runs Validate and then checks for settings that load but cannot take effect: partner
+// priority tiers the auction ignores, experiments leaving no control traffic, multipliers and
+// deals for verticals nothing else configures, deals that can never match at now, and floors at or
+// above their caps. Findings are sorted errors first, then by path.
+func Lint(c *Config, now time.Time) []Finding {
+	var findings []Finding
+	add := func(severity, code, path, format string, args ...interface{}) {
+		findings = append(findings, Finding{Severity: severity, Code: code, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if err := c.Validate(); err != nil {
+		add(LintError, LintInvalidConfig, "", "%v", err)
+	}
+
+	// Every eligible partner is called in every auction, so distinct priorities never rank partners
+	priorities := make(map[int]bool)
+	for _, partner := range c.Partners {
+		if partner != nil && partner.Enabled {
+			priorities[partner.Priority] = true
+		}
+	}
+	if len(priorities) > 1 {
+		for id, partner := range c.Partners {
+			if partner != nil && partner.Enabled && partner.Priority != 0 {
+				add(LintWarning, LintUnusedPriority, "partners."+id+".priority",
+					"priority %d has no effect: auctions call every eligible partner regardless of priority tier", partner.Priority)
+			}
+		}
+	}
+
+	// An experiment taking all traffic leaves its control variant empty
+	for id, experiment := range c.Experiments {
+		if experiment != nil && experiment.TrafficPercent >= 100 {
+			add(LintWarning, LintNoControlTraffic, "experiments."+id+".traffic_percent",
+				"experiment sends all traffic to treatment, so its control variant gets none")
+		}
+	}
+
+	// Verticals are free-form, so a vertical only one setting names is likely a typo
+	if known := c.configuredVerticals(); len(known) > 0 {
+		for id, partner := range c.Partners {
+			if partner == nil {
+				continue
+			}
+			for vertical := range partner.VerticalMultipliers {
+				if !known[vertical] {
+					add(LintWarning, LintUnknownVertical, "partners."+id+".vertical_multipliers."+vertical,
+						"vertical %s is not configured anywhere else", vertical)
+				}
+			}
+		}
+		for id, deal := range c.Deals {
+			if deal == nil {
+				continue
+			}
+			for _, vertical := range deal.Verticals {
+				if !known[vertical] {
+					add(LintWarning, LintUnknownVertical, "deals."+id+".verticals",
+						"vertical %s is not configured anywhere else", vertical)
+				}
+			}
+		}
+	}
+
+	// Deals that can never match a bid
+	for id, deal := range c.Deals {
+		if deal == nil {
+			continue
+		}
+		if !deal.ExpiresAt.IsZero() && !now.Before(deal.ExpiresAt) {
+			add(LintWarning, LintDealNeverMatches, "deals."+id+".expires_at", "deal expired at %s", deal.ExpiresAt.Format(time.RFC3339))
+		}
+		if partner, exists := c.Partners[deal.PartnerID]; exists && partner != nil && !partner.Enabled {
+			add(LintWarning, LintDealNeverMatches, "deals."+id+".partner_id", "deal partner %s is disabled", deal.PartnerID)
+		}
+	}
+
+	// Floors at or above their caps accept nothing, or only bids at exactly the cap
+	for id, partner := range c.Partners {
+		if partner != nil && partner.MaxBid > 0 && partner.MinBid > partner.MaxBid {
+			add(LintError, LintFloorAboveCap, "partners."+id+".min_bid", "min bid %v is above max bid %v", partner.MinBid, partner.MaxBid)
+		}
+	}
+	for id, deal := range c.Deals {
+		if deal != nil && deal.FloorPrice > 0 && deal.FloorPrice >= c.MaxBidPrice {
+			add(LintWarning, LintFloorAboveCap, "deals."+id+".floor_price",
+				"deal floor %v is at or above the max bid price %v", deal.FloorPrice, c.MaxBidPrice)
+		}
+	}
+	for id, experiment := range c.Experiments {
+		if experiment != nil && experiment.Overrides.Floor > 0 && experiment.Overrides.Floor >= c.MaxBidPrice {
+			add(LintWarning, LintFloorAboveCap, "experiments."+id+".overrides.floor",
+				"experiment floor %v is at or above the max bid price %v", experiment.Overrides.Floor, c.MaxBidPrice)
+		}
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Severity != findings[j].Severity {
+			return findings[i].Severity == LintError
+		}
+		if findings[i].Path != findings[j].Path {
+			return findings[i].Path < findings[j].Path
+		}
+		return findings[i].Message < findings[j].Message
+	})
+	return findings
+}
+
+// HasLintErrors reports whether any finding is an error
+func HasLintErrors(findings []Finding) bool {
+	for _, finding := range findings {
+		if finding.Severity == LintError {
+			return true
+		}
+	}
+	return false
+}
+
+// configuredVerticals returns the verticals named by vertical-keyed settings
+func (c *Config) configuredVerticals() map[string]bool {
+	known := make(map[string]bool)
+	for vertical := range c.Strategies {
+		if vertical != DefaultStrategyKey {
+			known[vertical] = true
+		}
+	}
+	for vertical := range c.UserDataSchemas {
+		known[vertical] = true
+	}
+	for vertical := range c.ExpectedPremiums {
+		known[vertical] = true
+	}
+	for vertical := range c.MinBidders {
+		known[vertical] = true
+	}
+	for vertical := range c.Verticals {
+		known[vertical] = true
+	}
+	if c.Estimates != nil {
+		for vertical := range c.Estimates.Static {
+			known[vertical] = true
+		}
+	}
+	if c.TimeMultipliers != nil {
+		for vertical := range c.TimeMultipliers.Verticals {
+			known[vertical] = true
+		}
+	}
+	if c.Dedup != nil {
+		for vertical := range c.Dedup.Verticals {
+			known[vertical] = true
+		}
+	}
+	return known
+}
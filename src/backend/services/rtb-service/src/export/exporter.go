@@ -0,0 +1,360 @@
+// Package export samples completed auctions into flat per-bid rows and writes them in
+// time-windowed files for offline model training
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	mathrand "math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Reasons a row is dropped instead of exported
+const (
+	dropReasonBufferFull = "buffer_full"
+	dropReasonWriteError = "write_error"
+)
+
+// fileTimeFormat formats window bounds in file names, so names sort by window
+const fileTimeFormat = "20060102T150405Z"
+
+var (
+	rowsExported = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rtb_export_rows_total",
+			Help: "Total number of sampled auction rows written to export files",
+		},
+	)
+
+	rowsDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_export_rows_dropped_total",
+			Help: "Total number of sampled auction rows not exported, by reason",
+		},
+		[]string{"reason"},
+	)
+
+	writeFailures = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rtb_export_write_failures_total",
+			Help: "Total number of export files that failed to write",
+		},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(rowsExported)
+	prometheus.MustRegister(rowsDropped)
+	prometheus.MustRegister(writeFailures)
+}
+
+// Row is one bid of a sampled auction with the auction's context joined in. Every row carries
+// every column, so files load into columnar tables without per-row schema inference. BidPrice is
+// in the partner's PricingModel and CPL is a cost per lead; ClearingPrice is set for winners only.
+// Lead quality columns are null, or empty in CSV, when the request did not carry the signal or
+// the PII policy drops it. ConfigHash is the version of the config the auction ran with.
+type Row struct {
+	AuctionTime         time.Time  `json:"auction_time"`
+	RequestID           string     `json:"request_id"`
+	LeadID              string     `json:"lead_id"`
+	Vertical            string     `json:"vertical"`
+	State               string     `json:"state"`
+	Country             string     `json:"country"`
+	DeviceType          string     `json:"device_type"`
+	ConsentTimestamp    *time.Time `json:"consent_timestamp"`
+	SessionDurationMs   *int64     `json:"session_duration_ms"`
+	PreviouslySold      *bool      `json:"previously_sold"`
+	LeadScore           *float64   `json:"lead_score"`
+	PartnersContacted   int        `json:"partners_contacted"`
+	PartnersBid         int        `json:"partners_bid"`
+	Bids                int        `json:"bids"`
+	Sold                bool       `json:"sold"`
+	PartnerID           string     `json:"partner_id"`
+	BidID               string     `json:"bid_id"`
+	DealID              string     `json:"deal_id"`
+	BidPrice            float64    `json:"bid_price"`
+	PricingModel        string     `json:"pricing_model"`
+	CPL                 float64    `json:"cpl"`
+	QualityScore        float64    `json:"quality_score"`
+	QualityAcknowledged bool       `json:"quality_acknowledged"`
+	Won                 bool       `json:"won"`
+	Rank                int        `json:"rank"`
+	ClearingPrice       float64    `json:"clearing_price"`
+	ConfigHash          string     `json:"config_hash"`
+}
+
+// csvHeader names the CSV columns, in the order of csvRecord
+var csvHeader = []string{
+	"auction_time", "request_id", "lead_id", "vertical", "state", "country", "device_type",
+	"consent_timestamp", "session_duration_ms", "previously_sold", "lead_score",
+	"partners_contacted", "partners_bid", "bids", "sold", "partner_id", "bid_id", "deal_id",
+	"bid_price", "pricing_model", "cpl", "quality_score", "quality_acknowledged", "won", "rank", "clearing_price",
+	"config_hash",
+}
+
+// csvRecord returns the row's CSV fields in csvHeader order
+func (r Row) csvRecord() []string {
+	return []string{
+		r.AuctionTime.Format(time.RFC3339Nano), r.RequestID, r.LeadID, r.Vertical, r.State, r.Country, r.DeviceType,
+		formatOptional(r.ConsentTimestamp, func(t time.Time) string { return t.Format(time.RFC3339Nano) }),
+		formatOptional(r.SessionDurationMs, func(ms int64) string { return strconv.FormatInt(ms, 10) }),
+		formatOptional(r.PreviouslySold, strconv.FormatBool), formatOptional(r.LeadScore, formatFloat),
+		strconv.Itoa(r.PartnersContacted), strconv.Itoa(r.PartnersBid), strconv.Itoa(r.Bids), strconv.FormatBool(r.Sold),
+		r.PartnerID, r.BidID, r.DealID, formatFloat(r.BidPrice), r.PricingModel, formatFloat(r.CPL),
+		formatFloat(r.QualityScore), strconv.FormatBool(r.QualityAcknowledged),
+		strconv.FormatBool(r.Won), strconv.Itoa(r.Rank), formatFloat(r.ClearingPrice), r.ConfigHash,
+	}
+}
+
+// formatOptional formats a value that may be unset, leaving an unset value empty
+func formatOptional[T any](value *T, format func(T) string) string {
+	if value == nil {
+		return ""
+	}
+	return format(*value)
+}
+
+// formatFloat formats a float in its shortest exact form
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// Exporter buffers sampled rows by the time window of their auction from a background goroutine
+// and writes each window's file once the window ends. Rows are dropped rather than blocking
+// auctions when the buffer is full.
+type Exporter struct {
+	config   *config.ExportConfig
+	store    BlobStore
+	clock    utils.Clock
+	instance string
+	rows     chan []Row
+	flushes  chan chan error
+	done     chan struct{}
+	mutex    sync.RWMutex
+	closed   bool
+	err      error
+
+	// Owned by the background goroutine
+	windows  map[int64][]Row
+	sequence int
+}
+
+// NewExporter starts an exporter writing files to store, or returns nil when exports are disabled
+func NewExporter(cfg *config.ExportConfig, store BlobStore, clock utils.Clock) *Exporter {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	if clock == nil {
+		clock = utils.SystemClock{}
+	}
+
+	e := &Exporter{
+		config:   cfg,
+		store:    store,
+		clock:    clock,
+		instance: newInstanceID(),
+		rows:     make(chan []Row, cfg.BufferSize),
+		flushes:  make(chan chan error),
+		done:     make(chan struct{}),
+		windows:  make(map[int64][]Row),
+	}
+	go e.run()
+	return e
+}
+
+// newInstanceID returns a random ID that keeps file names from different instances apart
+func newInstanceID() string {
+	id := make([]byte, 4)
+	if _, err := rand.Read(id); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(id)
+}
+
+// Sample reports whether an auction should be exported, drawing from random
+func (e *Exporter) Sample(random *mathrand.Rand) bool {
+	return e != nil && random.Float64() < e.config.SampleRate
+}
+
+// Export queues the rows of one auction
+func (e *Exporter) Export(rows []Row) {
+	if e == nil || len(rows) == 0 {
+		return
+	}
+
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	if e.closed {
+		return
+	}
+	select {
+	case e.rows <- rows:
+	default:
+		rowsDropped.WithLabelValues(dropReasonBufferFull).Add(float64(len(rows)))
+	}
+}
+
+// Flush writes the files of every window that has ended, including rows queued before the call
+func (e *Exporter) Flush() error {
+	if e == nil {
+		return nil
+	}
+
+	e.mutex.RLock()
+	if e.closed {
+		e.mutex.RUnlock()
+		return nil
+	}
+	result := make(chan error, 1)
+	e.flushes <- result
+	e.mutex.RUnlock()
+	return <-result
+}
+
+// Close stops accepting rows and writes every buffered window, ended or not
+func (e *Exporter) Close() error {
+	if e == nil {
+		return nil
+	}
+
+	e.mutex.Lock()
+	if e.closed {
+		e.mutex.Unlock()
+		return nil
+	}
+	e.closed = true
+	close(e.rows)
+	e.mutex.Unlock()
+
+	<-e.done
+	return e.err
+}
+
+// run buffers queued rows and writes ended windows every flush interval
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.config.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case rows, ok := <-e.rows:
+			if !ok {
+				e.err = e.write(time.Time{})
+				return
+			}
+			e.add(rows)
+		case <-ticker.C:
+			e.write(e.clock.Now())
+		case result := <-e.flushes:
+			for len(e.rows) > 0 {
+				e.add(<-e.rows)
+			}
+			result <- e.write(e.clock.Now())
+		}
+	}
+}
+
+// add buffers rows in the window of their auction, writing a part as soon as a window holds
+// MaxFileRows rows
+func (e *Exporter) add(rows []Row) {
+	for _, row := range rows {
+		start := row.AuctionTime.Truncate(e.config.Window).Unix()
+		e.windows[start] = append(e.windows[start], row)
+		if len(e.windows[start]) >= e.config.MaxFileRows {
+			e.writeWindow(start)
+		}
+	}
+}
+
+// write writes every window that ended by now, oldest first, or every window when now is zero.
+// It returns the first write error.
+func (e *Exporter) write(now time.Time) error {
+	starts := make([]int64, 0, len(e.windows))
+	for start := range e.windows {
+		if now.IsZero() || !time.Unix(start, 0).Add(e.config.Window).After(now) {
+			starts = append(starts, start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+	var first error
+	for _, start := range starts {
+		if err := e.writeWindow(start); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// writeWindow writes a window's buffered rows as one file. Rows of a failed write are dropped.
+func (e *Exporter) writeWindow(start int64) error {
+	rows := e.windows[start]
+	delete(e.windows, start)
+
+	name := e.fileName(start)
+	data, err := encodeRows(e.config.Format, rows)
+	if err == nil {
+		err = e.store.Put(context.Background(), name, data)
+	}
+	if err != nil {
+		writeFailures.Inc()
+		rowsDropped.WithLabelValues(dropReasonWriteError).Add(float64(len(rows)))
+		return fmt.Errorf("writing export file %s: %w", name, err)
+	}
+	rowsExported.Add(float64(len(rows)))
+	return nil
+}
+
+// fileName names the next file of a window by the window's bounds, this instance, and a sequence
+// number, so parts of one window and files from several instances never collide
+func (e *Exporter) fileName(start int64) string {
+	e.sequence++
+	from := time.Unix(start, 0).UTC()
+	to := from.Add(e.config.Window)
+	return fmt.Sprintf("auctions-%s-%s-%s-%06d.%s", from.Format(fileTimeFormat), to.Format(fileTimeFormat),
+		e.instance, e.sequence, formatExtension(e.config.Format))
+}
+
+// formatExtension returns the file extension of an export format
+func formatExtension(format string) string {
+	if format == config.ExportFormatCSV {
+		return config.ExportFormatCSV
+	}
+	return config.ExportFormatNDJSON
+}
+
+// encodeRows encodes rows as newline-delimited JSON, or as CSV with a header line
+func encodeRows(format string, rows []Row) ([]byte, error) {
+	var buffer bytes.Buffer
+	if format == config.ExportFormatCSV {
+		writer := csv.NewWriter(&buffer)
+		writer.Write(csvHeader)
+		for _, row := range rows {
+			writer.Write(row.csvRecord())
+		}
+		writer.Flush()
+		return buffer.Bytes(), writer.Error()
+	}
+
+	encoder := json.NewEncoder(&buffer)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return nil, err
+		}
+	}
+	return buffer.Bytes(), nil
+}
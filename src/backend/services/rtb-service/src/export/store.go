@@ -0,0 +1,165 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// s3UploadTimeout bounds one file upload to an S3-compatible bucket
+const s3UploadTimeout = 30 * time.Second
+
+// BlobStore stores finished export files. Put must make a file visible whole or not at all.
+type BlobStore interface {
+	Put(ctx context.Context, name string, data []byte) error
+}
+
+// NewStore returns the configured store: an S3-compatible bucket, or a local directory
+func NewStore(cfg *config.ExportConfig) (BlobStore, error) {
+	if cfg.S3 != nil {
+		return NewS3Store(cfg.S3)
+	}
+	return &DirStore{Dir: cfg.Dir}, nil
+}
+
+// DirStore writes files to a local directory
+type DirStore struct {
+	Dir string
+}
+
+// Put writes data to a hidden temporary file in the directory and renames it into place, so
+// readers never see a partial file
+func (d *DirStore) Put(ctx context.Context, name string, data []byte) error {
+	if err := os.MkdirAll(d.Dir, 0o750); err != nil {
+		return err
+	}
+	temp, err := os.CreateTemp(d.Dir, "."+name+".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Sync(); err != nil {
+		temp.Close()
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	if err := os.Rename(temp.Name(), filepath.Join(d.Dir, name)); err != nil {
+		os.Remove(temp.Name())
+		return err
+	}
+	return nil
+}
+
+// S3Store uploads files to an S3-compatible bucket with Signature Version 4 signed PUTs. A PUT
+// only creates the object once the whole body arrives.
+type S3Store struct {
+	config          *config.S3ExportConfig
+	accessKeyID     string
+	secretAccessKey string
+	client          *http.Client
+}
+
+// NewS3Store resolves the bucket's keys and creates its client
+func NewS3Store(cfg *config.S3ExportConfig) (*S3Store, error) {
+	accessKeyID, err := config.ResolveSecret(cfg.AccessKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("resolving export s3 access key id: %w", err)
+	}
+	secretAccessKey, err := config.ResolveSecret(cfg.SecretAccessKey)
+	if err != nil {
+		return nil, fmt.Errorf("resolving export s3 secret access key: %w", err)
+	}
+	return &S3Store{
+		config:          cfg,
+		accessKeyID:     accessKeyID,
+		secretAccessKey: secretAccessKey,
+		client:          &http.Client{Timeout: s3UploadTimeout},
+	}, nil
+}
+
+// Put uploads data as the object Prefix+name
+func (s *S3Store) Put(ctx context.Context, name string, data []byte) error {
+	segments := strings.Split(s.config.Bucket+"/"+s.config.Prefix+name, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	target := strings.TrimRight(s.config.Endpoint, "/") + "/" + strings.Join(segments, "/")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, target, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	s.sign(req, data, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("uploading %s: status %d", name, resp.StatusCode)
+	}
+	return nil
+}
+
+// sign adds Signature Version 4 headers covering the host, payload hash, and date
+func (s *S3Store) sign(req *http.Request, payload []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+	payloadHash := sha256Hex(payload)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	const signedHeaders = "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host + "\nx-amz-content-sha256:" + payloadHash + "\nx-amz-date:" + amzDate + "\n",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := day + "/" + s.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), day)
+	for _, part := range []string{s.config.Region, "s3", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+// sha256Hex returns the hex SHA-256 of data
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
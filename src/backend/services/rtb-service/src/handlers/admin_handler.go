@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"math"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/replay"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// Admin authentication header
+const adminKeyHeader = "X-Admin-Key"
+
+// Replay request limits
+const (
+	maxReplayRecordings = 1000
+	maxReplayBodyBytes  = 64 << 20
+)
+
+// BuildInfo describes the running binary
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+}
+
+// AdminHandler serves authenticated operational endpoints
+type AdminHandler struct {
+	auctionService *services.AuctionService
+	config         *config.Config
+	build          BuildInfo
+	cpuProfiles    chan struct{}
+	replayer       *replay.Replayer
+	selfTest       *SelfTest
+}
+
+// NewAdminHandler creates a new AdminHandler instance
+func NewAdminHandler(auction *services.AuctionService, cfg *config.Config, build BuildInfo) (*AdminHandler, error) {
+	if auction == nil || cfg == nil {
+		return nil, models.ErrInvalidInput
+	}
+
+	return &AdminHandler{
+		auctionService: auction,
+		config:         cfg,
+		build:          build,
+		cpuProfiles:    make(chan struct{}, 1),
+		replayer:       replay.NewReplayer(cfg),
+	}, nil
+}
+
+// SetSelfTest sets the self-test the self-test endpoint runs
+func (a *AdminHandler) SetSelfTest(selfTest *SelfTest) {
+	a.selfTest = selfTest
+}
+
+// Enabled reports whether the admin group should be mounted
+func (a *AdminHandler) Enabled() bool {
+	return a.config.Admin != nil && a.config.Admin.Enabled
+}
+
+// RegisterRoutes mounts the admin endpoints under the given group, guarded by admin auth
+func (a *AdminHandler) RegisterRoutes(group *gin.RouterGroup) {
+	group.Use(a.RequireAdmin())
+
+	group.GET("/runtime", a.HandleRuntimeStats)
+	group.GET("/partners", a.HandlePartners)
+	group.GET("/partners/hygiene", a.HandlePartnerHygiene)
+	group.POST("/partners/:id/capture", a.HandleStartCapture)
+	group.DELETE("/partners/:id/capture", a.HandleStopCapture)
+	group.GET("/partners/:id/captures", a.HandleCaptures)
+	group.POST("/partners/:id/drain", a.HandleDrainPartner)
+	group.GET("/partners/:id/budget", a.HandlePartnerBudget)
+	group.DELETE("/partners/:id/shadow", a.HandleRestorePartner)
+	group.GET("/webhooks/failures", a.HandleWebhookFailures)
+	group.GET("/assets/failures", a.HandleAssetFailures)
+	group.GET("/config/history", a.HandleConfigHistory)
+	group.GET("/config/effective", a.HandleEffectiveConfig)
+	group.POST("/replay", a.HandleReplay)
+	group.POST("/selftest", a.HandleSelfTest)
+	group.GET("/ivt/blocklists", a.HandleIVTBlocklists)
+	group.PUT("/ivt/blocklists", a.HandleUpdateIVTBlocklists)
+	group.DELETE("/negative-cache", a.HandleFlushNegativeCache)
+	group.GET("/overrides", a.HandleOverrides)
+	group.POST("/overrides", a.HandleAddOverride)
+	group.DELETE("/overrides/:id", a.HandleRemoveOverride)
+	group.GET("/faults", a.HandleFaults)
+	group.POST("/faults", a.HandleAddFault)
+	group.DELETE("/faults/:id", a.HandleRemoveFault)
+	group.GET("/experiments", a.HandleExperiments)
+	group.GET("/docs", a.HandleSwaggerUI)
+
+	if a.config.Admin.EnableProfiling {
+		debugGroup := group.Group("/debug/pprof")
+		debugGroup.GET("/", gin.WrapF(pprof.Index))
+		debugGroup.GET("/cmdline", gin.WrapF(pprof.Cmdline))
+		debugGroup.GET("/symbol", gin.WrapF(pprof.Symbol))
+		debugGroup.GET("/profile", a.limitCPUProfiles(), gin.WrapF(pprof.Profile))
+		debugGroup.GET("/trace", a.limitCPUProfiles(), gin.WrapF(pprof.Trace))
+		for _, profile := range []string{"heap", "goroutine", "allocs", "block", "mutex", "threadcreate"} {
+			debugGroup.GET("/"+profile, gin.WrapH(pprof.Handler(profile)))
+		}
+	}
+}
+
+// RequireAdmin returns middleware that rejects requests without a valid admin key
+func (a *AdminHandler) RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !isAdminKey(a.config, adminKeyFromRequest(c)) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Admin authentication required"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// isAdminKey compares a presented key against configured admin keys in constant time
+func isAdminKey(cfg *config.Config, key string) bool {
+	if key == "" || cfg.Admin == nil || !cfg.Admin.Enabled {
+		return false
+	}
+	for _, candidate := range cfg.Admin.APIKeys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(candidate)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// adminKeyFromRequest extracts the admin key from the admin header or a bearer token
+func adminKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader(adminKeyHeader); key != "" {
+		return key
+	}
+	return strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+}
+
+// limitCPUProfiles allows only one CPU profile or trace to run at a time
+func (a *AdminHandler) limitCPUProfiles() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		select {
+		case a.cpuProfiles <- struct{}{}:
+			defer func() { <-a.cpuProfiles }()
+			c.Next()
+		default:
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "A CPU profile is already in progress"})
+		}
+	}
+}
+
+// HandlePartners returns each partner's enabled, circuit breaker, active-hours, and fields policy state
+func (a *AdminHandler) HandlePartners(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"partners":  a.auctionService.PartnerStatuses(),
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// HandleWebhookFailures returns the most recent webhook deliveries that were not acknowledged
+func (a *AdminHandler) HandleWebhookFailures(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"failures":  a.auctionService.WebhookFailures(),
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// HandleExperiments returns each experiment's configuration and the auctions, winners, and
+// revenue of its variants on this instance
+func (a *AdminHandler) HandleExperiments(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"experiments": a.auctionService.Experiments(),
+		"timestamp":   time.Now().UTC(),
+	})
+}
+
+// HandleReplay replays the recordings in the request body, one JSON recording or a recording
+// file's lines, and returns each replay's winners and diff with a summary
+func (a *AdminHandler) HandleReplay(c *gin.Context) {
+	recordings, err := replay.Read(http.MaxBytesReader(c.Writer, c.Request.Body, maxReplayBodyBytes))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if len(recordings) == 0 || len(recordings) > maxReplayRecordings {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Between 1 and 1000 recordings are required"})
+		return
+	}
+
+	results := make([]*replay.Result, 0, len(recordings))
+	changed := 0
+	revenueDelta := 0.0
+	for _, recording := range recordings {
+		result, err := a.replayer.Replay(c.Request.Context(), recording)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if result.Diff.Changed {
+			changed++
+		}
+		revenueDelta += result.Diff.RevenueDelta
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"results": results,
+		"summary": gin.H{
+			"replayed":      len(results),
+			"changed":       changed,
+			"revenue_delta": math.Round(revenueDelta*100) / 100,
+		},
+	})
+}
+
+// HandleSelfTest runs the self-test and returns its report, answering 503 when a step failed
+func (a *AdminHandler) HandleSelfTest(c *gin.Context) {
+	if a.selfTest == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Self-test not available"})
+		return
+	}
+
+	report := a.selfTest.Run(c.Request.Context())
+	status := http.StatusOK
+	if !report.Passed {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+// HandleRuntimeStats returns goroutine, heap, and GC statistics with build information
+func (a *AdminHandler) HandleRuntimeStats(c *gin.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	gcStats := debug.GCStats{PauseQuantiles: make([]time.Duration, 5)}
+	debug.ReadGCStats(&gcStats)
+
+	gc := gin.H{
+		"num_gc":      gcStats.NumGC,
+		"pause_total": gcStats.PauseTotal.String(),
+		"last_gc":     gcStats.LastGC,
+	}
+	if gcStats.NumGC > 0 {
+		gc["pause_min"] = gcStats.PauseQuantiles[0].String()
+		gc["pause_p25"] = gcStats.PauseQuantiles[1].String()
+		gc["pause_p50"] = gcStats.PauseQuantiles[2].String()
+		gc["pause_p75"] = gcStats.PauseQuantiles[3].String()
+		gc["pause_max"] = gcStats.PauseQuantiles[4].String()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"build":      a.build,
+		"go_version": runtime.Version(),
+		"goroutines": runtime.NumGoroutine(),
+		"num_cpu":    runtime.NumCPU(),
+		"heap": gin.H{
+			"alloc_bytes":   mem.HeapAlloc,
+			"inuse_bytes":   mem.HeapInuse,
+			"idle_bytes":    mem.HeapIdle,
+			"sys_bytes":     mem.HeapSys,
+			"objects":       mem.HeapObjects,
+			"total_alloc":   mem.TotalAlloc,
+			"next_gc_bytes": mem.NextGC,
+		},
+		"gc":        gc,
+		"timestamp": time.Now().UTC(),
+	})
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// defaultAnalyticsWindow is the price analytics window when none is requested
+const defaultAnalyticsWindow = 24 * time.Hour
+
+// HandlePriceAnalytics returns clearing-price percentiles, average winners, and the no-bid rate
+// for a vertical, and optionally a region, over a window of whole hours
+func (h *BidHandler) HandlePriceAnalytics(c *gin.Context) {
+	vertical := c.Query("vertical")
+	if vertical == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "vertical is required"})
+		return
+	}
+
+	window, err := parseWindow(c.Query("window"), defaultAnalyticsWindow)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window"})
+		return
+	}
+
+	summary, err := h.auctionService.PriceAnalytics(vertical, c.Query("region"), window)
+	switch {
+	case errors.Is(err, services.ErrAnalyticsDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Price analytics disabled"})
+	case errors.Is(err, services.ErrInvalidAnalyticsQuery):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	default:
+		c.JSON(http.StatusOK, summary)
+	}
+}
+
+// HandleAdaptiveFloors returns the floors learned from recent clearing prices for every tracked
+// vertical and region, or for one vertical, and whether auctions apply them
+func (h *BidHandler) HandleAdaptiveFloors(c *gin.Context) {
+	floors, err := h.auctionService.AdaptiveFloors(c.Query("vertical"))
+	switch {
+	case errors.Is(err, services.ErrFloorsDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Adaptive floors disabled"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	default:
+		c.JSON(http.StatusOK, gin.H{"floors": floors})
+	}
+}
+
+// parseWindow parses a reporting window as a Go duration or a whole number of days such as 7d,
+// returning fallback when value is empty
+func parseWindow(value string, fallback time.Duration) (time.Duration, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if days, found := strings.CutSuffix(value, "d"); found {
+		count, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(count) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(value)
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// APIVersion selects the JSON shape of bid responses. v1 is frozen: its compatibility serializer
+// writes the auction response exactly as the service always has. v2 writes models.BidResponseV2.
+type APIVersion int
+
+// API versions, each served under its own path prefix
+const (
+	APIVersion1 APIVersion = 1
+	APIVersion2 APIVersion = 2
+)
+
+// responseProfileV2 labels the size of v2 responses, which response profiles do not shape
+const responseProfileV2 = "v2"
+
+// v2ContentType names the v2 profile so clients can tell the shapes apart
+const v2ContentType = jsonContentType + `; profile="v2"`
+
+// Errors resolving the API version of a request
+var (
+	errUnknownAPIVersion = errors.New("unknown API version profile")
+	errV2Fields          = errors.New("the fields parameter is only supported in API v1")
+)
+
+// String returns the version's profile name, e.g. v2
+func (v APIVersion) String() string {
+	return fmt.Sprintf("v%d", int(v))
+}
+
+// requestedAPIVersion returns the API version of a request: the profile parameter of a JSON
+// media range in the Accept header, e.g. `application/json; profile="v2"`, then the version of
+// the path it came in on
+func requestedAPIVersion(c *gin.Context, pathVersion APIVersion) (APIVersion, error) {
+	for _, mediaRange := range strings.Split(c.GetHeader("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil || params["profile"] == "" {
+			continue
+		}
+		if mediaType != "application/json" && mediaType != "application/*" && mediaType != "*/*" {
+			continue
+		}
+		for _, version := range []APIVersion{APIVersion1, APIVersion2} {
+			if params["profile"] == version.String() {
+				return version, nil
+			}
+		}
+		return 0, fmt.Errorf("%w %q", errUnknownAPIVersion, params["profile"])
+	}
+	return pathVersion, nil
+}
+
+// bidResponseBody returns the value a version's serializer encodes for an auction response
+func bidResponseBody(version APIVersion, fields models.BidFields, response *models.BidResponse) interface{} {
+	if version == APIVersion2 {
+		return models.NewBidResponseV2(response)
+	}
+	if fields != models.AllBidFields {
+		return &models.ShapedResponse{Response: response, Fields: fields}
+	}
+	return response
+}
+
+// EncodeBidResponse encodes an auction response in a version's shape with codec, as the bid
+// endpoint writes it. fields applies to v1 only.
+func EncodeBidResponse(codec models.Codec, version APIVersion, fields models.BidFields, response *models.BidResponse) ([]byte, error) {
+	return codec.Marshal(bidResponseBody(version, fields, response))
+}
+
+// writeBidResponse writes an auction response in the version's shape and records its size
+func (h *BidHandler) writeBidResponse(c *gin.Context, version APIVersion, shape responseShape, response *models.BidResponse) {
+	if version != APIVersion2 {
+		h.writeShapedResponse(c, shape, response)
+		return
+	}
+	size := writeJSONContent(c, h.codec, http.StatusOK, v2ContentType, bidResponseBody(version, models.AllBidFields, response))
+	responseSizeBytes.WithLabelValues(responseProfileV2).Observe(float64(size))
+}
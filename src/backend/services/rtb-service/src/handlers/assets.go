@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+)
+
+// HandleAssetFailures returns each checked partner's creative image verification record with its
+// most recent failed assets
+func (a *AdminHandler) HandleAssetFailures(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":   a.auctionService.AssetVerificationEnabled(),
+		"partners":  a.auctionService.AssetFailures(),
+		"timestamp": time.Now().UTC(),
+	})
+}
@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+	"go.uber.org/zap"          // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// auctionParamsHeader carries auction parameters an admin caller forces on one auction, as the
+// JSON object also accepted in the request's auction_params field, e.g.
+// {"strategy": "passthrough", "quality_weight": 0.2, "floor": 8}
+const auctionParamsHeader = "X-RTB-Auction-Params"
+
+// requestAuctionParams reads the forced auction parameters from the header or the request body,
+// removing them from the request, and reports whether a response was already written. Only admin
+// callers may force parameters; anyone else, or parameters outside the safety bounds, is refused
+// before the auction runs.
+func (h *BidHandler) requestAuctionParams(c *gin.Context, request *models.BidRequest) (*models.AuctionParams, bool) {
+	params := request.AuctionParams
+	request.AuctionParams = nil
+	header := c.GetHeader(auctionParamsHeader)
+	if header == "" && params == nil {
+		return nil, false
+	}
+	key := adminKeyFromRequest(c)
+	if !isAdminKey(h.config, key) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Auction params require admin authentication"})
+		return nil, true
+	}
+	if header != "" {
+		if params != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Send auction params in the header or the body, not both"})
+			return nil, true
+		}
+		params = &models.AuctionParams{}
+		if err := json.Unmarshal([]byte(header), params); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid auction params header"})
+			return nil, true
+		}
+	}
+	if err := h.auctionService.ValidateAuctionParams(params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, true
+	}
+
+	h.logger.Info("auction params forced",
+		zap.String("request_id", request.RequestID),
+		zap.Any("auction_params", params),
+		zap.String("admin_key", adminKeyFingerprint(key)),
+	)
+	return params, false
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// Batch defaults used when no batch configuration is supplied
+const (
+	defaultBatchMaxItems    = 100
+	defaultBatchConcurrency = 10
+	defaultBatchTimeout     = 5 * time.Second
+)
+
+// batchSettings resolves batch configuration with defaults
+func (h *BidHandler) batchSettings() config.BatchConfig {
+	settings := config.BatchConfig{
+		MaxItems:    defaultBatchMaxItems,
+		Concurrency: defaultBatchConcurrency,
+		Timeout:     defaultBatchTimeout,
+		Priority:    config.BatchPriorityEqual,
+	}
+	if h.config.Batch != nil {
+		settings = *h.config.Batch
+	}
+	return settings
+}
+
+// HandleBatchBidRequest runs auctions for multiple leads concurrently with a bounded worker pool
+func (h *BidHandler) HandleBatchBidRequest(c *gin.Context) {
+	startTime := time.Now()
+	settings := h.batchSettings()
+
+	var requests []*models.BidRequest
+	if err := c.ShouldBindJSON(&requests); err != nil {
+		bidErrors.WithLabelValues("invalid_request", "unknown", transportHTTP, trafficLive).Inc()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	if len(requests) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Batch must contain at least one request"})
+		return
+	}
+	if len(requests) > settings.MaxItems {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("Batch exceeds maximum of %d requests", settings.MaxItems),
+		})
+		return
+	}
+
+	batchCtx, cancel := context.WithTimeout(h.ctx, settings.Timeout)
+	defer cancel()
+
+	results := make([]*models.BatchItemResult, len(requests))
+	lowPriority := settings.Priority == config.BatchPriorityLow
+
+	workers := settings.Concurrency
+	if workers > len(requests) {
+		workers = len(requests)
+	}
+
+	items := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range items {
+				results[index] = h.runBatchItem(batchCtx, index, requests[index], lowPriority)
+			}
+		}()
+	}
+	for index := range requests {
+		items <- index
+	}
+	close(items)
+	wg.Wait()
+
+	response := &models.BatchBidResponse{
+		Results:        results,
+		Timestamp:      time.Now(),
+		ProcessingTime: time.Since(startTime),
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+	}
+
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.JSON(http.StatusOK, response)
+}
+
+// runBatchItem runs a single batch auction, converting any failure into a structured item error
+func (h *BidHandler) runBatchItem(ctx context.Context, index int, request *models.BidRequest, lowPriority bool) *models.BatchItemResult {
+	result := &models.BatchItemResult{Index: index}
+	if request == nil {
+		result.Error = &models.BatchItemError{Code: "invalid_request", Message: "Invalid bid request"}
+		return result
+	}
+
+	if request.RequestID == "" {
+		request.RequestID = newUUIDv7()
+	}
+	result.RequestID = request.RequestID
+
+	release, err := h.acquireAuction(ctx, request.Vertical, lowPriority)
+	if err != nil {
+		auctionsShed.WithLabelValues(sourceBatch).Inc()
+		result.Error = &models.BatchItemError{Code: "timeout", Message: "Batch deadline reached before auction started"}
+		return result
+	}
+	defer release()
+
+	bidRequestsTotal.WithLabelValues(request.Vertical, "all", transportHTTP, trafficLive).Inc()
+
+	itemCtx, cancel := context.WithTimeout(ctx, h.config.AuctionTimeout(request.Vertical))
+	defer cancel()
+	itemCtx = models.ContextWithBatchItem(models.ContextWithRequestID(itemCtx, request.RequestID))
+
+	response, _, err := h.auctionService.RunAuctionIdempotent(itemCtx, request)
+	if err != nil {
+		_, code, message := auctionErrorInfo(err)
+		bidErrors.WithLabelValues(code, "all", transportHTTP, trafficLive).Inc()
+		h.logAuctionError(request, code, err)
+		result.Error = &models.BatchItemError{Code: code, Message: message, Fields: userDataFieldErrors(err)}
+		return result
+	}
+
+	for _, bid := range response.Bids {
+		successfulBids.WithLabelValues(request.Vertical, bid.PartnerID, transportHTTP, trafficLive).Inc()
+	}
+	result.Response = response
+	return result
+}
@@ -4,19 +4,38 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin" // v1.9.1
+	"github.com/gin-gonic/gin/binding" // v1.9.1
 	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+	"go.uber.org/zap"                                // v1.24.0
 
 	"github.com/yourdomain/rtb-service/src/config"
 	"github.com/yourdomain/rtb-service/src/models"
 	"github.com/yourdomain/rtb-service/src/services"
 )
 
+// Transport label values distinguishing HTTP, gRPC, and in-process traffic
+const (
+	transportHTTP      = "http"
+	transportGRPC      = "grpc"
+	transportInProcess = "in_process"
+)
+
+// Traffic label values keeping dry runs, synthetic auctions, and auctions with forced parameters
+// out of live business metrics
+const (
+	trafficLive           = "live"
+	trafficDryRun         = "dry_run"
+	trafficSynthetic      = "synthetic"
+	trafficManualOverride = models.ManualOverride
+)
+
 // Prometheus metrics
 var (
 	bidRequestsTotal = prometheus.NewCounterVec(
@@ -24,7 +43,7 @@ var (
 			Name: "rtb_bid_requests_total",
 			Help: "Total number of bid requests received",
 		},
-		[]string{"vertical", "partner"},
+		[]string{"vertical", "partner", "transport", "traffic"},
 	)
 
 	bidResponseTime = prometheus.NewHistogramVec(
@@ -33,7 +52,7 @@ var (
 			Help:    "Bid response time in seconds",
 			Buckets: []float64{0.1, 0.2, 0.3, 0.4, 0.5},
 		},
-		[]string{"vertical", "partner"},
+		[]string{"vertical", "partner", "transport", "traffic"},
 	)
 
 	successfulBids = prometheus.NewCounterVec(
@@ -41,7 +60,7 @@ var (
 			Name: "rtb_successful_bids_total",
 			Help: "Total number of successful bid responses",
 		},
-		[]string{"vertical", "partner"},
+		[]string{"vertical", "partner", "transport", "traffic"},
 	)
 
 	bidErrors = prometheus.NewCounterVec(
@@ -49,7 +68,7 @@ var (
 			Name: "rtb_bid_errors_total",
 			Help: "Total number of bid errors by type",
 		},
-		[]string{"error_type", "partner"},
+		[]string{"error_type", "partner", "transport", "traffic"},
 	)
 
 	activeBidGauge = prometheus.NewGauge(
@@ -58,6 +77,13 @@ var (
 			Help: "Number of currently active bid requests",
 		},
 	)
+
+	idempotentReplays = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rtb_idempotent_replays_total",
+			Help: "Total number of bid requests answered from a previously completed auction",
+		},
+	)
 )
 
 func init() {
@@ -67,6 +93,7 @@ func init() {
 	prometheus.MustRegister(successfulBids)
 	prometheus.MustRegister(bidErrors)
 	prometheus.MustRegister(activeBidGauge)
+	prometheus.MustRegister(idempotentReplays)
 }
 
 // BidHandler handles RTB requests with thread-safety and metrics
@@ -76,6 +103,17 @@ type BidHandler struct {
 	mutex          sync.RWMutex
 	ctx            context.Context
 	cancel         context.CancelFunc
+	health         healthCache
+	requestIDs     *requestIDTracker
+	limiter        *auctionLimiter
+	streams        *auctionLimiter
+	logger         *zap.Logger
+	codec          models.Codec
+	jsonBinding    binding.BindingBody
+	responseProfiles map[string]responseShape
+	verticals      *verticalPools
+	selfTest       *SelfTest
+	canary         *Canary
 }
 
 // NewBidHandler creates a new BidHandler instance
@@ -84,6 +122,16 @@ func NewBidHandler(auction *services.AuctionService, cfg *config.Config) (*BidHa
 		return nil, models.ErrInvalidInput
 	}
 
+	codec, err := models.NewCodec(cfg.JSONCodec)
+	if err != nil {
+		return nil, err
+	}
+
+	responseProfiles, err := newResponseProfiles(cfg.ResponseProfiles)
+	if err != nil {
+		return nil, err
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &BidHandler{
@@ -91,96 +139,295 @@ func NewBidHandler(auction *services.AuctionService, cfg *config.Config) (*BidHa
 		config:         cfg,
 		ctx:            ctx,
 		cancel:         cancel,
+		requestIDs:     newRequestIDTracker(cfg.DuplicateRequestWindow),
+		limiter:        newAuctionLimiter(cfg),
+		streams:        newStreamLimiter(cfg),
+		logger:         zap.NewNop(),
+		codec:          codec,
+		jsonBinding:    newJSONBinding(codec),
+		responseProfiles: responseProfiles,
+		verticals:      newVerticalPools(cfg),
 	}, nil
 }
 
-// HandleBidRequest processes incoming RTB requests
+// SetLogger sets the logger used for auction failures
+func (h *BidHandler) SetLogger(logger *zap.Logger) {
+	if logger != nil {
+		h.logger = logger
+	}
+}
+
+// SetSelfTest sets the self-test whose last report gates readiness when it is enabled
+func (h *BidHandler) SetSelfTest(selfTest *SelfTest) {
+	h.selfTest = selfTest
+}
+
+// SetCanary sets the canary whose latest result health reports carry
+func (h *BidHandler) SetCanary(canary *Canary) {
+	h.canary = canary
+}
+
+// HandleBidRequest processes incoming RTB requests, answering in the v1 shape unless the Accept
+// header asks for another version
 func (h *BidHandler) HandleBidRequest(c *gin.Context) {
+	h.handleBidRequest(c, APIVersion1)
+}
+
+// HandleBidRequestV2 processes RTB requests on /v2/bids, answering in the v2 shape unless the
+// Accept header asks for another version
+func (h *BidHandler) HandleBidRequestV2(c *gin.Context) {
+	h.handleBidRequest(c, APIVersion2)
+}
+
+// handleBidRequest runs an auction and writes the response with the requested version's serializer
+func (h *BidHandler) handleBidRequest(c *gin.Context, pathVersion APIVersion) {
 	startTime := time.Now()
 	activeBidGauge.Inc()
 	defer activeBidGauge.Dec()
 
 	// Parse request body
 	var bidRequest models.BidRequest
-	if err := c.ShouldBindJSON(&bidRequest); err != nil {
-		bidErrors.WithLabelValues("invalid_request", "unknown").Inc()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+	if !h.bindBidRequest(c, &bidRequest, h.jsonBinding, trafficLive) {
+		return
+	}
+
+	version, err := requestedAPIVersion(c, pathVersion)
+	if err != nil {
+		bidErrors.WithLabelValues("invalid_version", "unknown", transportHTTP, trafficLive).Inc()
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Resolve the response fields before the auction so an unknown field costs no partner calls
+	shape, err := h.responseShape(c, version)
+	if err != nil {
+		bidErrors.WithLabelValues("invalid_fields", "unknown", transportHTTP, trafficLive).Inc()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Resolve request ID from header, body, or generation
+	bidRequest.RequestID = resolveRequestID(c, bidRequest.RequestID)
+	h.auctionService.EnrichRequest(&bidRequest, c.ClientIP(), c.GetHeader("User-Agent"))
+	if h.requestIDs.Observe(bidRequest.RequestID) {
+		duplicateRequestIDs.Inc()
+	}
+
+	override, written := h.requestOverride(c, bidRequest.RequestID)
+	if written {
+		bidErrors.WithLabelValues("invalid_override", "unknown", transportHTTP, trafficLive).Inc()
+		return
+	}
+	params, written := h.requestAuctionParams(c, &bidRequest)
+	if written {
+		bidErrors.WithLabelValues("invalid_auction_params", "unknown", transportHTTP, trafficLive).Inc()
 		return
 	}
+	traffic := trafficLive
+	if params != nil {
+		traffic = trafficManualOverride
+	}
 
 	// Record request metric
-	bidRequestsTotal.WithLabelValues(bidRequest.Vertical, "all").Inc()
+	bidRequestsTotal.WithLabelValues(bidRequest.Vertical, "all", transportHTTP, traffic).Inc()
+
+	// Shed load when the concurrency limit is reached
+	release, acquired := h.tryAcquireAuction(h.limiter, bidRequest.Vertical)
+	if !acquired {
+		auctionsShed.WithLabelValues(sourceLive).Inc()
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service at capacity"})
+		return
+	}
+	defer release()
 
 	// Create timeout context
-	reqCtx, cancel := context.WithTimeout(h.ctx, h.config.BidTimeout)
+	reqCtx, cancel := context.WithTimeout(h.ctx, h.config.AuctionTimeout(bidRequest.Vertical))
 	defer cancel()
+	reqCtx = withTrafficScreening(c, h.withAuction(c, reqCtx, bidRequest.RequestID))
+	if override != nil {
+		reqCtx = models.ContextWithOverride(reqCtx, override)
+	}
+	if params != nil {
+		reqCtx = models.ContextWithAuctionParams(reqCtx, params)
+	}
 
-	// Execute auction
-	response, err := h.auctionService.RunAuction(reqCtx, &bidRequest)
+	// Execute auction, replaying the stored response for retried request IDs
+	response, replayed, err := h.auctionService.RunAuctionIdempotent(reqCtx, &bidRequest)
+	if h.writeTrafficVerdict(c, &bidRequest, err, version) {
+		return
+	}
 	if err != nil {
-		h.handleAuctionError(c, err)
+		h.handleAuctionError(c, &bidRequest, err, version, true)
+		return
+	}
+	if replayed {
+		idempotentReplays.Inc()
+		c.Header("Idempotent-Replay", "true")
+		setCacheHeaders(c, response)
+		setSummaryHeaders(c, response)
+		h.writeBidResponse(c, version, shape, h.localizeResponse(c, response))
 		return
 	}
 
 	// Record successful bids
 	for _, bid := range response.Bids {
-		successfulBids.WithLabelValues(bidRequest.Vertical, bid.PartnerID).Inc()
+		successfulBids.WithLabelValues(bidRequest.Vertical, bid.PartnerID, transportHTTP, traffic).Inc()
 	}
 
 	// Record response time
-	duration := time.Since(startTime).Seconds()
-	bidResponseTime.WithLabelValues(bidRequest.Vertical, "all").Observe(duration)
+	duration := time.Since(startTime)
+	bidResponseTime.WithLabelValues(bidRequest.Vertical, "all", transportHTTP, traffic).Observe(duration.Seconds())
 
 	// Set response headers
-	c.Header("X-RTB-Request-ID", bidRequest.RequestID)
-	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	setCacheHeaders(c, response)
 	c.Header("X-RTB-Processing-Time", duration.String())
+	setSummaryHeaders(c, response)
+
+	h.writeBidResponse(c, version, shape, h.localizeResponse(c, response))
+	h.auctionService.RecordResponseWritten(reqCtx)
+}
+
+// setSummaryHeaders repeats the auction summary's bidder and winner counts in headers for
+// proxies that do not read response bodies
+func setSummaryHeaders(c *gin.Context, response *models.BidResponse) {
+	if response.Summary == nil {
+		return
+	}
+	c.Header("X-RTB-Partners-Bid", strconv.Itoa(response.Summary.PartnersBid))
+	c.Header("X-RTB-Winners", strconv.Itoa(response.Summary.Winners))
+}
 
-	c.JSON(http.StatusOK, response)
+// setCacheHeaders lets downstream caches keep a response until its ValidUntil. Responses without
+// one, or already past it, must not be cached.
+func setCacheHeaders(c *gin.Context, response *models.BidResponse) {
+	if response.ValidUntil != nil {
+		if maxAge := int(time.Until(*response.ValidUntil) / time.Second); maxAge > 0 {
+			c.Header("Cache-Control", "max-age="+strconv.Itoa(maxAge))
+			c.Header("Expires", response.ValidUntil.UTC().Format(http.TimeFormat))
+			return
+		}
+	}
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
 }
 
-// HandleHealthCheck provides service health status
-func (h *BidHandler) HandleHealthCheck(c *gin.Context) {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
+// withAuction returns ctx carrying the context of an HTTP request's auction, with debug output
+// for admin callers that pass debug=true
+func (h *BidHandler) withAuction(c *gin.Context, ctx context.Context, requestID string) context.Context {
+	admin := isAdminKey(h.config, adminKeyFromRequest(c))
+	auction := newAuctionContext(ctx, requestID, models.ClientIdentity{
+		IP:        c.ClientIP(),
+		UserAgent: c.GetHeader("User-Agent"),
+		Transport: transportHTTP,
+		Admin:     admin,
+	})
+	if admin && c.Query("debug") == "true" {
+		auction = auction.WithDebug(models.NewDebugInfo())
+	}
+	return models.ContextWithAuction(ctx, auction)
+}
 
-	status := gin.H{
-		"status":    "healthy",
-		"timestamp": time.Now().UTC(),
+// newAuctionContext starts the context of an auction asked for by client, which must answer by
+// the deadline of ctx
+func newAuctionContext(ctx context.Context, requestID string, client models.ClientIdentity) *models.AuctionContext {
+	auction := models.NewAuctionContext(requestID).WithClient(client)
+	if deadline, ok := ctx.Deadline(); ok {
+		auction = auction.WithDeadline(deadline)
 	}
+	return auction
+}
 
-	// Check auction service health
-	if h.auctionService == nil {
-		status["status"] = "degraded"
-		status["reason"] = "auction service unavailable"
-		c.JSON(http.StatusServiceUnavailable, status)
+// handleAuctionError handles various auction error cases, writing no-bid and insufficient
+// competition responses in the version's shape. houseOffers allows a house offer in place of
+// the no-bid response.
+func (h *BidHandler) handleAuctionError(c *gin.Context, request *models.BidRequest, err error, version APIVersion, houseOffers bool) {
+	status, code, message := auctionErrorInfo(err)
+	bidErrors.WithLabelValues(code, "all", transportHTTP, trafficLive).Inc()
+	h.logAuctionError(request, code, err)
+	if err == services.ErrNoValidBids {
+		h.writeNoBidResponse(c, request, version, houseOffers)
+		return
+	}
+	if err == services.ErrInsufficientCompetition {
+		if h.config.QuorumFailureStatus == http.StatusNoContent {
+			c.Status(http.StatusNoContent)
+			return
+		}
+		if version == APIVersion2 {
+			h.writeBidResponse(c, version, fullResponseShape, insufficientCompetitionResponse(request))
+			return
+		}
+		c.JSON(http.StatusOK, insufficientCompetitionResponse(request))
+		return
+	}
+	if fields := userDataFieldErrors(err); fields != nil {
+		c.JSON(status, gin.H{"error": message, "fields": fields})
+		return
+	}
+	if errors.Is(err, services.ErrInvalidConstraints) {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	// A conflict carries its code, so callers can tell it from a reuse without a response to replay
+	if errors.Is(err, services.ErrRequestConflict) {
+		c.JSON(status, gin.H{"error": message, "code": code})
 		return
 	}
+	c.JSON(status, gin.H{"error": message})
+}
 
-	// Add service stats
-	status["active_bids"] = activeBidGauge.Get()
-	status["partner_stats"] = h.auctionService.GetPartnerStats()
+// insufficientCompetitionResponse is the empty response for an auction that missed its bidder quorum
+func insufficientCompetitionResponse(request *models.BidRequest) *models.BidResponse {
+	return &models.BidResponse{
+		RequestID: request.RequestID,
+		Bids:      []*models.Bid{},
+		Timestamp: time.Now(),
+		Reason:    models.ReasonInsufficientCompetition,
+	}
+}
 
-	c.JSON(http.StatusOK, status)
+// logAuctionError logs a failed auction with the request sanitized by the PII policy
+func (h *BidHandler) logAuctionError(request *models.BidRequest, code string, err error) {
+	level := zap.WarnLevel
+	if err == services.ErrNoValidBids || err == services.ErrInsufficientCompetition {
+		level = zap.DebugLevel
+	}
+	if entry := h.logger.Check(level, "auction failed"); entry != nil {
+		entry.Write(
+			zap.String("code", code),
+			zap.Error(err),
+			zap.Any("request", h.auctionService.SanitizeRequest(request)),
+		)
+	}
 }
 
-// handleAuctionError handles various auction error cases
-func (h *BidHandler) handleAuctionError(c *gin.Context, err error) {
+// auctionErrorInfo maps an auction error to its HTTP status, error code, and client message
+func auctionErrorInfo(err error) (int, string, string) {
+	if errors.Is(err, services.ErrInvalidUserData) {
+		return http.StatusBadRequest, "invalid_user_data", "Invalid user data"
+	}
+	if errors.Is(err, services.ErrInvalidConstraints) {
+		return http.StatusBadRequest, "invalid_constraints", "Invalid ranking constraints"
+	}
+	if errors.Is(err, services.ErrHookRejected) {
+		return http.StatusForbidden, "hook_rejected", "Request rejected"
+	}
+	if errors.Is(err, services.ErrRequestConflict) {
+		return http.StatusConflict, "request_id_conflict", "Request ID already used for a different payload"
+	}
 	switch err {
 	case services.ErrNoValidBids:
-		bidErrors.WithLabelValues("no_valid_bids", "all").Inc()
-		c.JSON(http.StatusNoContent, gin.H{"error": "No valid bids received"})
+		return http.StatusNoContent, string(models.ReasonNoValidBids), "No valid bids received"
 	case services.ErrAuctionTimeout:
-		bidErrors.WithLabelValues("timeout", "all").Inc()
-		c.JSON(http.StatusGatewayTimeout, gin.H{"error": "Auction timed out"})
+		return http.StatusGatewayTimeout, "timeout", "Auction timed out"
 	case services.ErrInvalidRequest:
-		bidErrors.WithLabelValues("invalid_request", "all").Inc()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bid request"})
+		return http.StatusBadRequest, "invalid_request", "Invalid bid request"
+	case services.ErrDuplicateRequest:
+		return http.StatusConflict, "duplicate_request", "Request ID already used"
 	case services.ErrPartnerFailure:
-		bidErrors.WithLabelValues("partner_failure", "all").Inc()
-		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Partner bid collection failed"})
+		return http.StatusServiceUnavailable, "partner_failure", "Partner bid collection failed"
+	case services.ErrInsufficientCompetition:
+		return http.StatusOK, string(models.ReasonInsufficientCompetition), "Too few partners bid to sell the lead"
 	default:
-		bidErrors.WithLabelValues("unknown", "all").Inc()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return http.StatusInternalServerError, "unknown", "Internal server error"
 	}
-}
\ No newline at end of file
+}
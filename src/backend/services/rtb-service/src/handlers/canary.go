@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+	"go.uber.org/zap"                                // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// Canary auction results
+const (
+	canaryResultSuccess   = "success"
+	canaryResultNoWinners = "no_winners"
+	canaryResultError     = "error"
+)
+
+var (
+	canaryAuctionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_canary_auctions_total",
+			Help: "Total number of canary auctions by partner mode and result: success, no_winners, or error",
+		},
+		[]string{"partners", "result"},
+	)
+
+	canaryWinners = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rtb_canary_winners",
+			Help: "Number of winners in the latest canary auction by partner mode",
+		},
+		[]string{"partners"},
+	)
+
+	canaryLatency = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rtb_canary_latency_seconds",
+			Help:    "End-to-end latency of canary auctions by partner mode",
+			Buckets: prometheus.DefBuckets,
+		},
+		[]string{"partners"},
+	)
+
+	canaryLastSuccess = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rtb_canary_last_success_timestamp_seconds",
+			Help: "Unix time of the latest canary auction that produced winners, by partner mode",
+		},
+		[]string{"partners"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(canaryAuctionsTotal)
+	prometheus.MustRegister(canaryWinners)
+	prometheus.MustRegister(canaryLatency)
+	prometheus.MustRegister(canaryLastSuccess)
+}
+
+// CanaryResult is the outcome of one canary auction
+type CanaryResult struct {
+	Result    string        `json:"result"`
+	Partners  string        `json:"partners"`
+	Winners   int           `json:"winners"`
+	Latency   time.Duration `json:"latency"`
+	Error     string        `json:"error,omitempty"`
+	StartedAt time.Time     `json:"started_at"`
+}
+
+// Canary runs a synthetic auction every canary interval and records its outcome in the
+// rtb_canary_* metrics that alerting watches. Canary auctions are synthetic, so they run as dry
+// runs that never reach business metrics, events, partner reports, or budgets, and leave partner
+// stats and breakers alone. With simulated partners they run on an auction service built like the
+// self-test's; with real partners they run on the live service and only reach opted-in partners.
+type Canary struct {
+	config  *config.Config
+	service *services.AuctionService
+	logger  *zap.Logger
+	mutex   sync.RWMutex // guards last
+	last    *CanaryResult
+}
+
+// NewCanary creates the canary for cfg, running real partner canaries on service
+func NewCanary(service *services.AuctionService, cfg *config.Config) (*Canary, error) {
+	if service == nil || cfg == nil {
+		return nil, models.ErrInvalidInput
+	}
+	return &Canary{config: cfg, service: service, logger: zap.NewNop()}, nil
+}
+
+// SetLogger sets the logger failed canaries are written to
+func (c *Canary) SetLogger(logger *zap.Logger) {
+	if logger != nil {
+		c.logger = logger
+	}
+}
+
+// Enabled reports whether canary auctions are configured to run
+func (c *Canary) Enabled() bool {
+	return c.config.Canary != nil && c.config.Canary.Enabled
+}
+
+// Last returns the latest canary result, or nil before the first canary
+func (c *Canary) Last() *CanaryResult {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.last
+}
+
+// Run runs a canary auction every interval until ctx is done. Simulated partners are served for
+// as long as it runs.
+func (c *Canary) Run(ctx context.Context) error {
+	if !c.Enabled() {
+		return nil
+	}
+
+	service := c.service
+	if c.config.Canary.PartnerMode() == config.CanaryPartnersSimulated {
+		server, partners, err := startSelfTestPartners(c.config)
+		if err != nil {
+			return err
+		}
+		defer server.Close()
+		for _, partner := range partners {
+			partner.Canary = true
+		}
+		if service, err = services.NewAuctionService(selfTestConfig(c.config, partners)); err != nil {
+			return fmt.Errorf("creating canary auction service: %w", err)
+		}
+		defer service.Close()
+		service.SetLogger(c.logger)
+	}
+
+	ticker := time.NewTicker(c.config.Canary.RunInterval())
+	defer ticker.Stop()
+	for {
+		c.runOnce(ctx, service)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce runs one canary auction on service and records its result
+func (c *Canary) runOnce(ctx context.Context, service *services.AuctionService) *CanaryResult {
+	mode := c.config.Canary.PartnerMode()
+	vertical := c.config.Canary.AuctionVertical()
+	startTime := time.Now()
+	request := &models.BidRequest{
+		RequestID: fmt.Sprintf("canary-%d", startTime.UnixNano()),
+		LeadID:    "canary",
+		Vertical:  vertical,
+		Timestamp: startTime.UTC(),
+	}
+
+	auctionCtx, cancel := context.WithTimeout(models.ContextWithCanary(models.ContextWithSynthetic(ctx)), c.config.AuctionTimeout(vertical))
+	defer cancel()
+	auctionCtx = models.ContextWithRequestID(auctionCtx, request.RequestID)
+	response, err := service.RunAuction(auctionCtx, request)
+
+	result := &CanaryResult{Result: canaryResultSuccess, Partners: mode, Latency: time.Since(startTime), StartedAt: startTime.UTC()}
+	switch {
+	case errors.Is(err, services.ErrNoValidBids) || errors.Is(err, services.ErrInsufficientCompetition):
+		result.Result, result.Error = canaryResultNoWinners, err.Error()
+	case err != nil:
+		result.Result, result.Error = canaryResultError, err.Error()
+	case len(response.Bids) == 0:
+		result.Result = canaryResultNoWinners
+	default:
+		result.Winners = len(response.Bids)
+	}
+
+	canaryAuctionsTotal.WithLabelValues(mode, result.Result).Inc()
+	canaryWinners.WithLabelValues(mode).Set(float64(result.Winners))
+	canaryLatency.WithLabelValues(mode).Observe(result.Latency.Seconds())
+	if result.Result == canaryResultSuccess {
+		canaryLastSuccess.WithLabelValues(mode).Set(float64(startTime.Unix()))
+	} else {
+		c.logger.Warn("canary auction failed",
+			zap.String("request_id", request.RequestID),
+			zap.String("partners", mode),
+			zap.String("result", result.Result),
+			zap.String("error", result.Error),
+			zap.Duration("latency", result.Latency))
+	}
+
+	c.mutex.Lock()
+	c.last = result
+	c.mutex.Unlock()
+	return result
+}
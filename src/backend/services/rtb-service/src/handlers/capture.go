@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// captureRequest is the body of POST /admin/partners/:id/capture. SampleRate defaults to every call.
+type captureRequest struct {
+	Duration    string   `json:"duration"`
+	SampleRate  *float64 `json:"sample_rate"`
+	RequestedBy string   `json:"requested_by"`
+}
+
+// HandleStartCapture turns on capturing a partner's raw requests and responses until the
+// requested duration passes
+func (a *AdminHandler) HandleStartCapture(c *gin.Context) {
+	var request captureRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid capture request"})
+		return
+	}
+	duration, err := time.ParseDuration(request.Duration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrCaptureDuration.Error()})
+		return
+	}
+	sampleRate := 1.0
+	if request.SampleRate != nil {
+		sampleRate = *request.SampleRate
+	}
+	requestedBy := strings.TrimSpace(request.RequestedBy)
+	if requestedBy != "" {
+		requestedBy += " (" + adminKeyFingerprint(adminKeyFromRequest(c)) + ")"
+	}
+
+	session, err := a.auctionService.StartCapture(c.Param("id"), sampleRate, duration, requestedBy)
+	switch {
+	case errors.Is(err, services.ErrUnknownPartner):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown partner"})
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusCreated, gin.H{
+			"capture":   session,
+			"timestamp": time.Now().UTC(),
+		})
+	}
+}
+
+// HandleStopCapture turns a partner's capture off and discards its captures
+func (a *AdminHandler) HandleStopCapture(c *gin.Context) {
+	stoppedBy := adminKeyFingerprint(adminKeyFromRequest(c))
+	if requestedBy := c.Query("requested_by"); requestedBy != "" {
+		stoppedBy = requestedBy + " (" + stoppedBy + ")"
+	}
+	if err := a.auctionService.StopCapture(c.Param("id"), stoppedBy); errors.Is(err, services.ErrUnknownPartner) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown partner"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"partner_id": c.Param("id"),
+		"timestamp":  time.Now().UTC(),
+	})
+}
+
+// HandleCaptures returns a partner's capture session, null when off, and its captures, newest first
+func (a *AdminHandler) HandleCaptures(c *gin.Context) {
+	session, captures, err := a.auctionService.Captures(c.Param("id"))
+	if errors.Is(err, services.ErrUnknownPartner) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown partner"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"partner_id": c.Param("id"),
+		"capture":    session,
+		"captures":   captures,
+		"timestamp":  time.Now().UTC(),
+	})
+}
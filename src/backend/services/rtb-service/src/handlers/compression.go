@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"                       // v1.9.1
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// Wire size directions and stages for compressed HTTP bodies
+const (
+	wireDirectionRequest  = "request"
+	wireDirectionResponse = "response"
+	wireStageCompressed   = "compressed"
+	wireStageDecoded      = "decoded"
+)
+
+var httpWireBytes = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rtb_http_wire_bytes_total",
+		Help: "Total bytes of gzip-encoded HTTP bodies, compressed on the wire and decoded, by direction",
+	},
+	[]string{"direction", "stage"},
+)
+
+func init() {
+	prometheus.MustRegister(httpWireBytes)
+}
+
+// CompressionMiddleware decompresses gzip request bodies up to the configured size and gzips
+// responses for clients that accept it. A nil config disables both.
+func CompressionMiddleware(cfg *config.CompressionConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cfg == nil {
+			c.Next()
+			return
+		}
+
+		if cfg.DecompressRequests && strings.EqualFold(c.GetHeader("Content-Encoding"), "gzip") {
+			if !decompressRequest(c, cfg.MaxDecompressedBytes) {
+				return
+			}
+		}
+
+		if cfg.CompressResponses && acceptsGzip(c.GetHeader("Accept-Encoding")) {
+			writer := &gzipResponseWriter{ResponseWriter: c.Writer}
+			c.Writer = writer
+			defer writer.Close()
+		}
+
+		c.Next()
+	}
+}
+
+// decompressRequest replaces a gzip request body with its decoded form, aborting the request
+// when the body is malformed or decodes past maxBytes
+func decompressRequest(c *gin.Context, maxBytes int64) bool {
+	compressed, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBytes+1))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return false
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return false
+	}
+	decoded, err := io.ReadAll(io.LimitReader(reader, maxBytes+1))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return false
+	}
+	if int64(len(compressed)) > maxBytes || int64(len(decoded)) > maxBytes {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+		return false
+	}
+
+	httpWireBytes.WithLabelValues(wireDirectionRequest, wireStageCompressed).Add(float64(len(compressed)))
+	httpWireBytes.WithLabelValues(wireDirectionRequest, wireStageDecoded).Add(float64(len(decoded)))
+
+	c.Request.Body = io.NopCloser(bytes.NewReader(decoded))
+	c.Request.ContentLength = int64(len(decoded))
+	c.Request.Header.Del("Content-Encoding")
+	c.Request.Header.Set("Content-Length", strconv.Itoa(len(decoded)))
+	return true
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if !strings.EqualFold(strings.TrimSpace(coding), "gzip") {
+			continue
+		}
+		return strings.ReplaceAll(strings.TrimSpace(params), " ", "") != "q=0"
+	}
+	return false
+}
+
+// countingWriter counts bytes written through it
+type countingWriter struct {
+	writer io.Writer
+	count  int
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.count += n
+	return n, err
+}
+
+// gzipResponseWriter compresses the response body, deciding on the first write or flush so
+// handlers can still set Content-Type; event streams and empty statuses pass through
+type gzipResponseWriter struct {
+	gin.ResponseWriter
+	decided bool
+	gzip    *gzip.Writer
+	wire    *countingWriter
+	decoded int
+}
+
+// decide enables compression unless the response is an event stream, already encoded, or bodiless
+func (w *gzipResponseWriter) decide() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+
+	header := w.Header()
+	status := w.Status()
+	if header.Get("Content-Encoding") != "" || strings.HasPrefix(header.Get("Content-Type"), "text/event-stream") ||
+		status == http.StatusNoContent || status == http.StatusNotModified {
+		return
+	}
+
+	header.Set("Content-Encoding", "gzip")
+	header.Add("Vary", "Accept-Encoding")
+	header.Del("Content-Length")
+	w.wire = &countingWriter{writer: w.ResponseWriter}
+	w.gzip = gzip.NewWriter(w.wire)
+}
+
+// Write compresses p when compression is enabled
+func (w *gzipResponseWriter) Write(p []byte) (int, error) {
+	w.decide()
+	if w.gzip == nil {
+		return w.ResponseWriter.Write(p)
+	}
+	w.decoded += len(p)
+	return w.gzip.Write(p)
+}
+
+// WriteString compresses s when compression is enabled
+func (w *gzipResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush flushes compressed data before flushing the underlying writer
+func (w *gzipResponseWriter) Flush() {
+	w.decide()
+	if w.gzip != nil {
+		w.gzip.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// Close finishes the gzip stream and records wire sizes
+func (w *gzipResponseWriter) Close() {
+	if w.gzip == nil {
+		return
+	}
+	w.gzip.Close()
+	httpWireBytes.WithLabelValues(wireDirectionResponse, wireStageCompressed).Add(float64(w.wire.count))
+	httpWireBytes.WithLabelValues(wireDirectionResponse, wireStageDecoded).Add(float64(w.decoded))
+}
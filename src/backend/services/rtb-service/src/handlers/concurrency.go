@@ -0,0 +1,265 @@
+package handlers
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// Auction traffic sources used in metrics labels
+const (
+	sourceLive   = "live"
+	sourceBatch  = "batch"
+	sourceStream = "stream"
+)
+
+var auctionsShed = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rtb_auctions_shed_total",
+		Help: "Total number of auctions rejected because the concurrency limit was reached",
+	},
+	[]string{"source"},
+)
+
+var (
+	verticalAuctionsShed = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_vertical_auctions_shed_total",
+			Help: "Total number of auctions rejected because their vertical pool's concurrency limit was reached",
+		},
+		[]string{"pool"},
+	)
+
+	verticalAuctionsInFlight = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rtb_vertical_auctions_in_flight",
+			Help: "Number of auctions in flight by vertical pool",
+		},
+		[]string{"pool"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(auctionsShed)
+	prometheus.MustRegister(verticalAuctionsShed)
+	prometheus.MustRegister(verticalAuctionsInFlight)
+}
+
+// auctionLimiter bounds concurrent auctions. Low-priority work may not use the reserved slots.
+// A capacity of zero disables the limit.
+type auctionLimiter struct {
+	capacity int
+	reserved int
+	mutex    sync.Mutex
+	inFlight int
+	released chan struct{}
+}
+
+// newAuctionLimiter creates the limiter from configuration
+func newAuctionLimiter(cfg *config.Config) *auctionLimiter {
+	limiter := &auctionLimiter{
+		capacity: cfg.MaxConcurrentAuctions,
+		released: make(chan struct{}),
+	}
+	if cfg.Batch != nil && cfg.Batch.Priority == config.BatchPriorityLow {
+		limiter.reserved = int(math.Ceil(float64(cfg.MaxConcurrentAuctions) * cfg.Batch.LiveReserve))
+	}
+	return limiter
+}
+
+// newStreamLimiter creates the limiter for streaming auctions, capped separately from regular auctions
+func newStreamLimiter(cfg *config.Config) *auctionLimiter {
+	return &auctionLimiter{
+		capacity: cfg.MaxConcurrentStreams,
+		released: make(chan struct{}),
+	}
+}
+
+// TryAcquire takes a slot without waiting, reporting whether one was available
+func (l *auctionLimiter) TryAcquire(lowPriority bool) bool {
+	acquired, _ := l.tryAcquire(lowPriority)
+	return acquired
+}
+
+// tryAcquire takes a slot if available, otherwise returns a channel closed on the next release
+func (l *auctionLimiter) tryAcquire(lowPriority bool) (bool, <-chan struct{}) {
+	if l.capacity <= 0 {
+		return true, nil
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	limit := l.capacity
+	if lowPriority {
+		limit -= l.reserved
+	}
+	if l.inFlight >= limit {
+		return false, l.released
+	}
+	l.inFlight++
+	return true, nil
+}
+
+// Acquire waits for a slot until ctx is done
+func (l *auctionLimiter) Acquire(ctx context.Context, lowPriority bool) error {
+	for {
+		acquired, released := l.tryAcquire(lowPriority)
+		if acquired {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-released:
+		}
+	}
+}
+
+// Release returns a slot and wakes all waiters to re-check availability
+func (l *auctionLimiter) Release() {
+	if l.capacity <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.inFlight--
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+// InFlight returns the number of auctions currently holding a slot
+func (l *auctionLimiter) InFlight() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.inFlight
+}
+
+// VerticalLoad is a vertical pool's in-flight auctions, its limit, and the auctions it has shed
+// since startup. A limit of zero is unlimited.
+type VerticalLoad struct {
+	InFlight      int    `json:"in_flight"`
+	MaxConcurrent int    `json:"max_concurrent"`
+	Shed          uint64 `json:"shed"`
+}
+
+// verticalPool limits the auctions of one vertical, or of every unlisted vertical for the
+// default pool
+type verticalPool struct {
+	name     string
+	limiter  *auctionLimiter
+	inFlight atomic.Int64
+	shed     atomic.Uint64
+}
+
+// verticalPools holds a pool for every configured vertical limit
+type verticalPools struct {
+	config *config.Config
+	pools  map[string]*verticalPool
+}
+
+// newVerticalPools creates the pools from configuration. Pools without a concurrency cap still
+// count their in-flight auctions.
+func newVerticalPools(cfg *config.Config) *verticalPools {
+	pools := &verticalPools{config: cfg, pools: make(map[string]*verticalPool, len(cfg.VerticalLimits))}
+	for name, limit := range cfg.VerticalLimits {
+		pools.pools[name] = &verticalPool{name: name, limiter: &auctionLimiter{capacity: limit.MaxConcurrent, released: make(chan struct{})}}
+	}
+	return pools
+}
+
+// pool returns the pool a vertical's auctions run in, or nil when none is configured
+func (p *verticalPools) pool(vertical string) *verticalPool {
+	name, _ := p.config.VerticalPool(vertical)
+	return p.pools[name]
+}
+
+// tryAcquire takes a slot in the pool without waiting, counting a shed when it is full
+func (p *verticalPool) tryAcquire() bool {
+	if !p.limiter.TryAcquire(false) {
+		p.shed.Add(1)
+		verticalAuctionsShed.WithLabelValues(p.name).Inc()
+		return false
+	}
+	p.inFlight.Add(1)
+	verticalAuctionsInFlight.WithLabelValues(p.name).Inc()
+	return true
+}
+
+// acquire waits for a slot in the pool until ctx is done
+func (p *verticalPool) acquire(ctx context.Context) error {
+	if err := p.limiter.Acquire(ctx, false); err != nil {
+		return err
+	}
+	p.inFlight.Add(1)
+	verticalAuctionsInFlight.WithLabelValues(p.name).Inc()
+	return nil
+}
+
+// release returns a slot to the pool
+func (p *verticalPool) release() {
+	p.limiter.Release()
+	p.inFlight.Add(-1)
+	verticalAuctionsInFlight.WithLabelValues(p.name).Dec()
+}
+
+// loads returns the load of every pool by name
+func (p *verticalPools) loads() map[string]VerticalLoad {
+	loads := make(map[string]VerticalLoad, len(p.pools))
+	for name, pool := range p.pools {
+		loads[name] = VerticalLoad{InFlight: int(pool.inFlight.Load()), MaxConcurrent: pool.limiter.capacity, Shed: pool.shed.Load()}
+	}
+	return loads
+}
+
+// tryAcquireAuction takes a slot in the vertical's pool and then one from limiter, without
+// waiting, so a surge in one vertical sheds its own auctions before it fills the global limit.
+// It returns the function releasing both slots, or false when either was full.
+func (h *BidHandler) tryAcquireAuction(limiter *auctionLimiter, vertical string) (func(), bool) {
+	pool := h.verticals.pool(vertical)
+	if pool != nil && !pool.tryAcquire() {
+		return nil, false
+	}
+	if !limiter.TryAcquire(false) {
+		if pool != nil {
+			pool.release()
+		}
+		return nil, false
+	}
+	return func() {
+		limiter.Release()
+		if pool != nil {
+			pool.release()
+		}
+	}, true
+}
+
+// acquireAuction waits until ctx is done for a slot in the vertical's pool and then a global
+// slot, returning the function releasing both
+func (h *BidHandler) acquireAuction(ctx context.Context, vertical string, lowPriority bool) (func(), error) {
+	pool := h.verticals.pool(vertical)
+	if pool != nil {
+		if err := pool.acquire(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if err := h.limiter.Acquire(ctx, lowPriority); err != nil {
+		if pool != nil {
+			pool.release()
+		}
+		return nil, err
+	}
+	return func() {
+		h.limiter.Release()
+		if pool != nil {
+			pool.release()
+		}
+	}, nil
+}
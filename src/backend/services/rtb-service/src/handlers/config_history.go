@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+)
+
+// HandleConfigHistory returns the hash of the config in effect and the config changes recorded
+// since start, newest first
+func (a *AdminHandler) HandleConfigHistory(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"config_hash": a.auctionService.ConfigHash(),
+		"changes":     a.auctionService.ConfigHistory(),
+		"timestamp":   time.Now().UTC(),
+	})
+}
@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"         // v1.9.1
+	"github.com/gin-gonic/gin/binding" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// HandleDryRunBidRequest runs a full auction against the real partners for admin callers without
+// its side effects: audit records, webhooks, analytics, and partner report updates are returned in
+// the response instead of performed. Auctions that sell nothing still answer 200 so the skipped
+// side effects can be inspected.
+func (h *BidHandler) HandleDryRunBidRequest(c *gin.Context) {
+	if !isAdminKey(h.config, adminKeyFromRequest(c)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin authentication required"})
+		return
+	}
+
+	startTime := time.Now()
+	var bidRequest models.BidRequest
+	if !h.bindBidRequest(c, &bidRequest, binding.JSON, trafficDryRun) {
+		return
+	}
+
+	bidRequest.RequestID = resolveRequestID(c, bidRequest.RequestID)
+	h.auctionService.EnrichRequest(&bidRequest, c.ClientIP(), c.GetHeader("User-Agent"))
+	override, written := h.requestOverride(c, bidRequest.RequestID)
+	if written {
+		bidErrors.WithLabelValues("invalid_override", "unknown", transportHTTP, trafficDryRun).Inc()
+		return
+	}
+	params, written := h.requestAuctionParams(c, &bidRequest)
+	if written {
+		bidErrors.WithLabelValues("invalid_auction_params", "unknown", transportHTTP, trafficDryRun).Inc()
+		return
+	}
+	bidRequestsTotal.WithLabelValues(bidRequest.Vertical, "all", transportHTTP, trafficDryRun).Inc()
+
+	release, acquired := h.tryAcquireAuction(h.limiter, bidRequest.Vertical)
+	if !acquired {
+		auctionsShed.WithLabelValues(sourceLive).Inc()
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service at capacity"})
+		return
+	}
+	defer release()
+
+	reqCtx, cancel := context.WithTimeout(h.ctx, h.config.AuctionTimeout(bidRequest.Vertical))
+	defer cancel()
+	dryRun := models.NewDryRun()
+	reqCtx = models.ContextWithDryRun(h.withAuction(c, reqCtx, bidRequest.RequestID), dryRun)
+	if override != nil {
+		reqCtx = models.ContextWithOverride(reqCtx, override)
+	}
+	if params != nil {
+		reqCtx = models.ContextWithAuctionParams(reqCtx, params)
+	}
+
+	// Dry runs bypass idempotency so they neither replay nor store live responses
+	response, err := h.auctionService.RunAuction(reqCtx, &bidRequest)
+	if err != nil {
+		status, code, message := auctionErrorInfo(err)
+		bidErrors.WithLabelValues(code, "all", transportHTTP, trafficDryRun).Inc()
+		if errors.Is(err, services.ErrNoValidBids) || errors.Is(err, services.ErrInsufficientCompetition) {
+			c.JSON(http.StatusOK, &models.BidResponse{
+				RequestID: bidRequest.RequestID,
+				Bids:      []*models.Bid{},
+				Timestamp: time.Now(),
+				DryRun:    dryRun,
+				Reason:    models.Reason(code),
+			})
+			return
+		}
+		if fields := userDataFieldErrors(err); fields != nil {
+			c.JSON(status, gin.H{"error": message, "fields": fields, "dry_run": dryRun})
+			return
+		}
+		c.JSON(status, gin.H{"error": message, "dry_run": dryRun})
+		return
+	}
+
+	for _, bid := range response.Bids {
+		successfulBids.WithLabelValues(bidRequest.Vertical, bid.PartnerID, transportHTTP, trafficDryRun).Inc()
+	}
+	bidResponseTime.WithLabelValues(bidRequest.Vertical, "all", transportHTTP, trafficDryRun).Observe(time.Since(startTime).Seconds())
+
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	setSummaryHeaders(c, response)
+	c.JSON(http.StatusOK, response)
+}
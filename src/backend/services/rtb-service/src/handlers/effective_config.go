@@ -0,0 +1,19 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+)
+
+// HandleEffectiveConfig returns the auction settings the vertical named by the vertical query
+// parameter currently runs with, or those of unlisted verticals without one, with the hash of the
+// config in effect
+func (a *AdminHandler) HandleEffectiveConfig(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"effective":   a.auctionService.EffectiveVertical(c.Query("vertical")),
+		"config_hash": a.auctionService.ConfigHash(),
+		"timestamp":   time.Now().UTC(),
+	})
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// HandleEstimate returns the expected value range for a lead in a vertical, and optionally a state,
+// without running an auction. Responses are cacheable for the configured max age.
+func (h *BidHandler) HandleEstimate(c *gin.Context) {
+	vertical := c.Query("vertical")
+	if vertical == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "vertical is required"})
+		return
+	}
+
+	estimate, err := h.auctionService.Estimate(vertical, c.Query("state"))
+	switch {
+	case errors.Is(err, services.ErrEstimatesDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Estimates disabled"})
+	case errors.Is(err, services.ErrNoEstimate):
+		c.JSON(http.StatusNotFound, gin.H{"error": "No estimate available"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	default:
+		c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", int(h.config.Estimates.CacheMaxAge.Seconds())))
+		c.JSON(http.StatusOK, estimate)
+	}
+}
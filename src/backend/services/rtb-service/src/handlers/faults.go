@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// faultRequest is the body of POST /admin/faults
+type faultRequest struct {
+	Kind        string  `json:"kind"`
+	PartnerID   string  `json:"partner_id"`
+	Percentage  float64 `json:"percentage"`
+	Latency     string  `json:"latency"`
+	TTL         string  `json:"ttl"`
+	Reason      string  `json:"reason"`
+	RequestedBy string  `json:"requested_by"`
+}
+
+// HandleFaults returns the injected faults in effect
+func (a *AdminHandler) HandleFaults(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":   a.config.FaultInjection.Allowed(),
+		"faults":    a.auctionService.Faults(),
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// HandleAddFault injects latency, errors, malformed responses, or Redis failures for a while
+func (a *AdminHandler) HandleAddFault(c *gin.Context) {
+	var request faultRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fault"})
+		return
+	}
+	ttl, err := time.ParseDuration(request.TTL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrFaultTTL.Error()})
+		return
+	}
+	var latency time.Duration
+	if request.Latency != "" {
+		if latency, err = time.ParseDuration(request.Latency); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrFaultLatency.Error()})
+			return
+		}
+	}
+
+	fault, err := a.auctionService.AddFault(services.Fault{
+		Kind:        request.Kind,
+		PartnerID:   request.PartnerID,
+		Percentage:  request.Percentage,
+		Latency:     latency,
+		Reason:      strings.TrimSpace(request.Reason),
+		RequestedBy: strings.TrimSpace(request.RequestedBy),
+		AdminKey:    adminKeyFingerprint(adminKeyFromRequest(c)),
+	}, ttl)
+	if errors.Is(err, services.ErrFaultsDisabled) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Fault injection is disabled"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"fault":     fault,
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// HandleRemoveFault lifts an injected fault before it expires
+func (a *AdminHandler) HandleRemoveFault(c *gin.Context) {
+	liftedBy := adminKeyFingerprint(adminKeyFromRequest(c))
+	if requestedBy := c.Query("requested_by"); requestedBy != "" {
+		liftedBy = requestedBy + " (" + liftedBy + ")"
+	}
+	err := a.auctionService.RemoveFault(c.Param("id"), liftedBy)
+	if errors.Is(err, services.ErrFaultNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fault not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":        c.Param("id"),
+		"timestamp": time.Now().UTC(),
+	})
+}
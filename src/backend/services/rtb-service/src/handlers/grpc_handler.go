@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"google.golang.org/grpc"          // v1.59.0
+	"google.golang.org/grpc/codes"    // v1.59.0
+	"google.golang.org/grpc/metadata" // v1.59.0
+	"google.golang.org/grpc/status"   // v1.59.0
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/rtbpb"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// minGRPCBudget is the smallest remaining deadline worth starting an auction for
+const minGRPCBudget = 50 * time.Millisecond
+
+// gRPC metadata keys
+const (
+	grpcRequestIDKey        = "x-request-id"
+	grpcIdempotentReplayKey = "idempotent-replay"
+	grpcClientIPKey         = "x-client-ip"
+	grpcClientUserAgentKey  = "x-client-user-agent"
+)
+
+// GRPCServer implements the RTBService gRPC interface on top of the same auction service as HTTP
+type GRPCServer struct {
+	rtbpb.UnimplementedRTBServiceServer
+	handler *BidHandler
+}
+
+// NewGRPCServer creates a gRPC server sharing the handler's auction service and concurrency limiter
+func (h *BidHandler) NewGRPCServer() *GRPCServer {
+	return &GRPCServer{handler: h}
+}
+
+// RunAuction runs an auction, bounding it by the smaller of the gRPC deadline and the configured bid timeout
+func (s *GRPCServer) RunAuction(ctx context.Context, req *rtbpb.BidRequest) (*rtbpb.BidResponse, error) {
+	startTime := time.Now()
+	h := s.handler
+
+	request := req.ToModel()
+	if request.RequestID == "" {
+		request.RequestID = metadataValue(ctx, grpcRequestIDKey)
+	}
+	if request.RequestID == "" {
+		request.RequestID = newUUIDv7()
+	}
+	h.auctionService.EnrichRequest(request, metadataValue(ctx, grpcClientIPKey), metadataValue(ctx, grpcClientUserAgentKey))
+
+	bidRequestsTotal.WithLabelValues(request.Vertical, "all", transportGRPC, trafficLive).Inc()
+
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < minGRPCBudget {
+		bidErrors.WithLabelValues("timeout", "all", transportGRPC, trafficLive).Inc()
+		return nil, grpcError(codes.DeadlineExceeded, rtbpb.ErrorCode_ERROR_CODE_TIMEOUT, "Deadline too short to run auction")
+	}
+
+	release, acquired := h.tryAcquireAuction(h.limiter, request.Vertical)
+	if !acquired {
+		auctionsShed.WithLabelValues(sourceLive).Inc()
+		return nil, grpcError(codes.ResourceExhausted, rtbpb.ErrorCode_ERROR_CODE_OVERLOADED, "Service at capacity")
+	}
+	defer release()
+
+	// context.WithTimeout keeps the caller's deadline when it is earlier than the auction timeout
+	auctionCtx, cancel := context.WithTimeout(ctx, h.config.AuctionTimeout(request.Vertical))
+	defer cancel()
+	auctionCtx = models.ContextWithAuction(auctionCtx, newAuctionContext(auctionCtx, request.RequestID, models.ClientIdentity{
+		IP:        metadataValue(ctx, grpcClientIPKey),
+		UserAgent: metadataValue(ctx, grpcClientUserAgentKey),
+		Transport: transportGRPC,
+	}))
+
+	response, replayed, err := h.auctionService.RunAuctionIdempotent(auctionCtx, request)
+	if err != nil {
+		_, code, message := auctionErrorInfo(err)
+		bidErrors.WithLabelValues(code, "all", transportGRPC, trafficLive).Inc()
+		h.logAuctionError(request, code, err)
+		return nil, auctionGRPCError(err, message)
+	}
+
+	header := metadata.Pairs(grpcRequestIDKey, request.RequestID)
+	if replayed {
+		idempotentReplays.Inc()
+		header.Set(grpcIdempotentReplayKey, "true")
+	}
+	grpc.SetHeader(ctx, header)
+
+	converted, err := rtbpb.BidResponseFromModel(response)
+	if err != nil {
+		bidErrors.WithLabelValues("encoding", "all", transportGRPC, trafficLive).Inc()
+		return nil, status.Error(codes.Internal, "Failed to encode response")
+	}
+
+	for _, bid := range response.Bids {
+		successfulBids.WithLabelValues(request.Vertical, bid.PartnerID, transportGRPC, trafficLive).Inc()
+	}
+	bidResponseTime.WithLabelValues(request.Vertical, "all", transportGRPC, trafficLive).Observe(time.Since(startTime).Seconds())
+
+	return converted, nil
+}
+
+// GetPartnerStats returns partner failure statistics and whether each partner is within its active hours
+func (s *GRPCServer) GetPartnerStats(ctx context.Context, req *rtbpb.GetPartnerStatsRequest) (*rtbpb.GetPartnerStatsResponse, error) {
+	stats := s.handler.auctionService.GetPartnerStats()
+
+	failures := make(map[string]int64, len(stats))
+	for partnerID, count := range stats {
+		failures[partnerID] = int64(count)
+	}
+
+	statuses := s.handler.auctionService.PartnerStatuses()
+	inSchedule := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		inSchedule[status.ID] = status.InSchedule
+	}
+
+	return &rtbpb.GetPartnerStatsResponse{Failures: failures, InSchedule: inSchedule}, nil
+}
+
+// metadataValue reads the first value for key from incoming gRPC metadata
+func metadataValue(ctx context.Context, key string) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// auctionGRPCError maps auction errors to gRPC status codes with an ErrorDetail attached
+func auctionGRPCError(err error, message string) error {
+	if errors.Is(err, services.ErrInvalidUserData) || errors.Is(err, services.ErrInvalidConstraints) {
+		return grpcError(codes.InvalidArgument, rtbpb.ErrorCode_ERROR_CODE_INVALID_REQUEST, err.Error())
+	}
+	if errors.Is(err, services.ErrHookRejected) {
+		return grpcError(codes.PermissionDenied, rtbpb.ErrorCode_ERROR_CODE_UNSPECIFIED, message)
+	}
+	if errors.Is(err, services.ErrRequestConflict) {
+		return grpcError(codes.AlreadyExists, rtbpb.ErrorCode_ERROR_CODE_REQUEST_CONFLICT, message)
+	}
+	switch err {
+	case services.ErrNoValidBids:
+		return grpcError(codes.NotFound, rtbpb.ErrorCode_ERROR_CODE_NO_VALID_BIDS, message)
+	case services.ErrAuctionTimeout:
+		return grpcError(codes.DeadlineExceeded, rtbpb.ErrorCode_ERROR_CODE_TIMEOUT, message)
+	case services.ErrInvalidRequest:
+		return grpcError(codes.InvalidArgument, rtbpb.ErrorCode_ERROR_CODE_INVALID_REQUEST, message)
+	case services.ErrDuplicateRequest:
+		return grpcError(codes.AlreadyExists, rtbpb.ErrorCode_ERROR_CODE_DUPLICATE_REQUEST, message)
+	case services.ErrPartnerFailure:
+		return grpcError(codes.Unavailable, rtbpb.ErrorCode_ERROR_CODE_PARTNER_FAILURE, message)
+	case services.ErrInsufficientCompetition:
+		return grpcError(codes.FailedPrecondition, rtbpb.ErrorCode_ERROR_CODE_INSUFFICIENT_COMPETITION, message)
+	default:
+		return grpcError(codes.Internal, rtbpb.ErrorCode_ERROR_CODE_UNSPECIFIED, message)
+	}
+}
+
+// grpcError builds a status error carrying an ErrorDetail
+func grpcError(code codes.Code, errorCode rtbpb.ErrorCode, message string) error {
+	st := status.New(code, message)
+	if detailed, err := st.WithDetails(&rtbpb.ErrorDetail{Code: errorCode, Message: message}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"                       // v1.9.1
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// Health check status values
+const (
+	healthStatusHealthy   = "healthy"
+	healthStatusUnhealthy = "unhealthy"
+	checkStatusOK         = "ok"
+	checkStatusFail       = "fail"
+	checkStatusSkipped    = "skipped"
+)
+
+// Health check timeouts
+const (
+	livenessTimeout   = 100 * time.Millisecond
+	dependencyTimeout = time.Second
+)
+
+// checkResult represents the outcome of a single health check
+type checkResult struct {
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// healthReport represents an aggregated health check response
+type healthReport struct {
+	Status       string                           `json:"status"`
+	Region       string                           `json:"region,omitempty"`
+	ConfigHash   string                           `json:"config_hash"`
+	Timestamp    time.Time                        `json:"timestamp"`
+	Checks       map[string]checkResult           `json:"checks"`
+	PartnerStats map[string]int                   `json:"partner_stats,omitempty"`
+	Overrides    []services.Override              `json:"overrides,omitempty"`
+	Verticals    map[string]VerticalLoad          `json:"verticals,omitempty"`
+	Warmup       map[string]services.WarmupResult `json:"warmup,omitempty"`
+	Canary       *CanaryResult                    `json:"canary,omitempty"`
+}
+
+// healthy reports whether every check in the report passed or was skipped
+func (r *healthReport) healthy() bool {
+	for _, check := range r.Checks {
+		if check.Status == checkStatusFail {
+			return false
+		}
+	}
+	return true
+}
+
+// healthCache caches the readiness report so frequent probes don't hammer dependencies
+type healthCache struct {
+	mutex     sync.Mutex
+	report    *healthReport
+	checkedAt time.Time
+}
+
+// HandleLiveness reports whether the process is up and able to schedule work
+func (h *BidHandler) HandleLiveness(c *gin.Context) {
+	report := &healthReport{
+		Timestamp: time.Now().UTC(),
+		Checks: map[string]checkResult{
+			"scheduler": checkScheduler(),
+		},
+	}
+	h.writeHealthReport(c, report)
+}
+
+// HandleReadiness reports whether the service can serve auctions, using cached results
+func (h *BidHandler) HandleReadiness(c *gin.Context) {
+	h.health.mutex.Lock()
+	report := h.health.report
+	if report == nil || time.Since(h.health.checkedAt) >= h.config.HealthCacheTTL {
+		report = h.runReadinessChecks(c.Request.Context())
+		h.health.report = report
+		h.health.checkedAt = time.Now()
+	}
+	h.health.mutex.Unlock()
+
+	// Vertical loads change with every auction, and canaries run on their own interval, so both
+	// are read fresh rather than cached. Canary results are informational and never fail readiness.
+	fresh := *report
+	fresh.Verticals = h.verticals.loads()
+	if h.canary != nil {
+		fresh.Canary = h.canary.Last()
+	}
+	h.writeHealthReport(c, &fresh)
+}
+
+// HandleHealthCheck provides service health status; kept as an alias for readiness
+func (h *BidHandler) HandleHealthCheck(c *gin.Context) {
+	h.HandleReadiness(c)
+}
+
+// runReadinessChecks executes all readiness checks against the service dependencies
+func (h *BidHandler) runReadinessChecks(ctx context.Context) *healthReport {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	report := &healthReport{
+		Timestamp: time.Now().UTC(),
+		Checks:    make(map[string]checkResult),
+	}
+
+	report.Checks["config"] = h.checkConfig()
+	report.Checks["partners"] = h.checkPartners()
+	report.Checks["redis"] = h.checkRedis(ctx)
+	report.Checks["metrics"] = h.checkMetrics()
+	report.Checks["selftest"] = h.checkSelfTest()
+	report.PartnerStats = h.auctionService.GetPartnerStats()
+	report.Overrides = h.auctionService.Overrides()
+	report.Warmup = h.auctionService.WarmupResults()
+
+	return report
+}
+
+// writeHealthReport sets the overall status and writes the report with a 200 or 503
+func (h *BidHandler) writeHealthReport(c *gin.Context, report *healthReport) {
+	statusCode := http.StatusOK
+	report.Status = healthStatusHealthy
+	report.Region = h.config.ServiceRegion
+	report.ConfigHash = h.auctionService.ConfigHash()
+	if !report.healthy() {
+		statusCode = http.StatusServiceUnavailable
+		report.Status = healthStatusUnhealthy
+	}
+
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.JSON(statusCode, report)
+}
+
+// checkScheduler verifies the Go runtime can schedule a goroutine promptly
+func checkScheduler() checkResult {
+	done := make(chan struct{})
+	go close(done)
+
+	select {
+	case <-done:
+		return checkResult{Status: checkStatusOK}
+	case <-time.After(livenessTimeout):
+		return checkResult{Status: checkStatusFail, Detail: "goroutine scheduling exceeded " + livenessTimeout.String()}
+	}
+}
+
+// checkConfig verifies the loaded configuration is still valid
+func (h *BidHandler) checkConfig() checkResult {
+	if h.config == nil {
+		return checkResult{Status: checkStatusFail, Detail: "configuration not loaded"}
+	}
+	if err := h.config.Validate(); err != nil {
+		return checkResult{Status: checkStatusFail, Detail: err.Error()}
+	}
+	return checkResult{Status: checkStatusOK}
+}
+
+// checkPartners verifies at least one partner is enabled and not circuit-broken
+func (h *BidHandler) checkPartners() checkResult {
+	available := h.auctionService.AvailablePartners()
+	if len(available) == 0 {
+		return checkResult{Status: checkStatusFail, Detail: "no enabled partners with closed circuit"}
+	}
+	return checkResult{Status: checkStatusOK, Detail: fmt.Sprintf("%d partners available", len(available))}
+}
+
+// checkRedis verifies Redis is reachable when configured
+func (h *BidHandler) checkRedis(ctx context.Context) checkResult {
+	if !h.auctionService.RedisConfigured() {
+		return checkResult{Status: checkStatusSkipped, Detail: "redis not configured"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, dependencyTimeout)
+	defer cancel()
+
+	if err := h.auctionService.CheckRedis(ctx); err != nil {
+		return checkResult{Status: checkStatusFail, Detail: err.Error()}
+	}
+	return checkResult{Status: checkStatusOK}
+}
+
+// checkSelfTest verifies the last self-test passed when the startup self-test is enabled. An
+// on-demand run replaces the startup result, so a passing rerun restores readiness.
+func (h *BidHandler) checkSelfTest() checkResult {
+	if h.selfTest == nil || !h.selfTest.Enabled() {
+		return checkResult{Status: checkStatusSkipped, Detail: "self-test not enabled"}
+	}
+
+	report := h.selfTest.Last()
+	if report == nil {
+		return checkResult{Status: checkStatusFail, Detail: "self-test has not run"}
+	}
+	if failed := report.failure(); failed != nil {
+		return checkResult{Status: checkStatusFail, Detail: fmt.Sprintf("%s step failed on auction %d: %s", failed.Name, failed.Auction, failed.Detail)}
+	}
+	return checkResult{Status: checkStatusOK, Detail: fmt.Sprintf("passed at %s", report.StartedAt.Format(time.RFC3339))}
+}
+
+// checkMetrics verifies the metrics registry gathers. StatsD is fire-and-forget over UDP, so its
+// reachability cannot be checked.
+func (h *BidHandler) checkMetrics() checkResult {
+	if _, err := prometheus.DefaultGatherer.Gather(); err != nil {
+		return checkResult{Status: checkStatusFail, Detail: err.Error()}
+	}
+	return checkResult{Status: checkStatusOK}
+}
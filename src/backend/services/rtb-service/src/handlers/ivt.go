@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// withTrafficScreening returns ctx with the request's headers on the auction's client identity,
+// so the ivt hook screens the auction for invalid traffic before any partner is called
+func withTrafficScreening(c *gin.Context, ctx context.Context) context.Context {
+	auction := models.AuctionFromContext(ctx)
+	client := auction.Client()
+	client.Header = c.Request.Header
+	return models.ContextWithAuction(ctx, auction.WithClient(client))
+}
+
+// writeTrafficVerdict writes the response to an auction the ivt hook refused and reports whether
+// it did. Shadowed requests get the same response as an auction without bids, so a source cannot
+// tell it is being filtered.
+func (h *BidHandler) writeTrafficVerdict(c *gin.Context, request *models.BidRequest, err error, version APIVersion) bool {
+	switch {
+	case errors.Is(err, services.ErrIVTRejected):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Request rejected"})
+		return true
+	case errors.Is(err, services.ErrIVTShadowed):
+		h.writeNoBidResponse(c, request, version, true)
+		return true
+	default:
+		return false
+	}
+}
+
+// HandleIVTBlocklists returns the bot User-Agent and blocked network lists in use
+func (a *AdminHandler) HandleIVTBlocklists(c *gin.Context) {
+	lists, err := a.auctionService.IVTBlocklists()
+	if errors.Is(err, services.ErrIVTDisabled) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "IVT filtering disabled"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"blocklists": lists,
+		"timestamp":  time.Now().UTC(),
+	})
+}
+
+// HandleUpdateIVTBlocklists replaces the bot User-Agent and blocked network lists. Updates last
+// until the next update or restart.
+func (a *AdminHandler) HandleUpdateIVTBlocklists(c *gin.Context) {
+	var lists services.IVTBlocklists
+	if err := c.ShouldBindJSON(&lists); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid blocklists"})
+		return
+	}
+
+	err := a.auctionService.SetIVTBlocklists(lists)
+	switch {
+	case errors.Is(err, services.ErrIVTDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": "IVT filtering disabled"})
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"blocklists": lists,
+			"timestamp":  time.Now().UTC(),
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"                       // v1.9.1
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// localeNone labels responses whose display fields were left unlocalized
+const localeNone = "none"
+
+// responseLocales counts bid responses by the locale their display fields were localized for
+var responseLocales = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rtb_response_locales_total",
+		Help: "Total number of bid responses by the locale of their display fields",
+	},
+	[]string{"locale"},
+)
+
+func init() {
+	prometheus.MustRegister(responseLocales)
+}
+
+// acceptedLanguage is a language tag from an Accept-Language header with its quality
+type acceptedLanguage struct {
+	tag     string
+	quality float64
+}
+
+// acceptedLanguages returns the language tags of an Accept-Language header from most to least
+// preferred, skipping wildcards, malformed tags, and tags with a quality of zero
+func acceptedLanguages(header string) []string {
+	var accepted []acceptedLanguage
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.TrimSpace(tag)
+		if !config.ValidLanguageTag(tag) {
+			continue
+		}
+		quality := 1.0
+		if value, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality > 0 {
+			accepted = append(accepted, acceptedLanguage{tag: config.CanonicalLanguageTag(tag), quality: quality})
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		return accepted[i].quality > accepted[j].quality
+	})
+
+	tags := make([]string, len(accepted))
+	for i, language := range accepted {
+		tags[i] = language.tag
+	}
+	return tags
+}
+
+// localizeResponse picks the response locale, the first of the caller's accepted languages and
+// then the default language that any winning bid has a variant for, and returns a copy of the
+// response whose bids show that locale's display fields. Bids without a variant for it keep their
+// own fields. The response itself is returned when localization is disabled or no locale matches.
+func (h *BidHandler) localizeResponse(c *gin.Context, response *models.BidResponse) *models.BidResponse {
+	localization := h.config.Localization
+	if localization == nil || !localization.Enabled {
+		return response
+	}
+	// Cached responses are only good for callers accepting the same languages
+	c.Writer.Header().Add("Vary", "Accept-Language")
+
+	preferences := acceptedLanguages(c.GetHeader("Accept-Language"))
+	if localization.DefaultLanguage != "" {
+		preferences = append(preferences, config.CanonicalLanguageTag(localization.DefaultLanguage))
+	}
+	for _, tag := range preferences {
+		for _, bid := range response.Bids {
+			if bid == nil || !bid.HasLocale(tag) {
+				continue
+			}
+			localized := *response
+			localized.Locale = tag
+			localized.Bids = make([]*models.Bid, len(response.Bids))
+			for i, bid := range response.Bids {
+				if bid != nil {
+					bid = bid.Localize(tag)
+				}
+				localized.Bids[i] = bid
+			}
+			responseLocales.WithLabelValues(tag).Inc()
+			return &localized
+		}
+	}
+	responseLocales.WithLabelValues(localeNone).Inc()
+	return response
+}
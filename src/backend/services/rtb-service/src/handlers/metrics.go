@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"          // v1.16.0
+	"github.com/prometheus/client_golang/prometheus/promhttp" // v1.16.0
+	dto "github.com/prometheus/client_model/go"               // v0.4.0
+	"google.golang.org/protobuf/proto"                        // v1.34.2
+)
+
+// serviceRegionLabel is the label every metric carries when the service region is set
+const serviceRegionLabel = "service_region"
+
+// regionGatherer labels every gathered metric with the service region, so dashboards can tell
+// regions apart however Prometheus scrapes them
+type regionGatherer struct {
+	prometheus.Gatherer
+	region string
+}
+
+// Gather gathers the wrapped metrics and adds the region label to each
+func (g regionGatherer) Gather() ([]*dto.MetricFamily, error) {
+	families, err := g.Gatherer.Gather()
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			metric.Label = append(metric.Label, &dto.LabelPair{Name: proto.String(serviceRegionLabel), Value: proto.String(g.region)})
+			sort.Slice(metric.Label, func(i, j int) bool { return metric.Label[i].GetName() < metric.Label[j].GetName() })
+		}
+	}
+	return families, err
+}
+
+// MetricsHandler serves the registered metrics, labelled with region when it is set
+func MetricsHandler(region string) http.Handler {
+	if region == "" {
+		return promhttp.Handler()
+	}
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer,
+		promhttp.HandlerFor(regionGatherer{Gatherer: prometheus.DefaultGatherer, region: region}, promhttp.HandlerOpts{}))
+}
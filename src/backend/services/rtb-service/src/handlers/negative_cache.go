@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// HandleFlushNegativeCache removes cached partner no-bids, for one partner when the partner
+// query parameter is set and otherwise for every partner
+func (a *AdminHandler) HandleFlushNegativeCache(c *gin.Context) {
+	partnerID := c.Query("partner")
+	removed, err := a.auctionService.FlushNegativeCache(c.Request.Context(), partnerID)
+	switch {
+	case errors.Is(err, services.ErrNegativeCacheDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Negative caching disabled"})
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Failed to flush negative cache", "removed": removed})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"partner":   partnerID,
+			"removed":   removed,
+			"timestamp": time.Now().UTC(),
+		})
+	}
+}
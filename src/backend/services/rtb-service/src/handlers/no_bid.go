@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"                       // v1.9.1
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// houseOffersServed counts house offers apart from the partner bid metrics, which never see them
+var houseOffersServed = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rtb_house_offers_total",
+		Help: "Total number of house offers returned for auctions without valid bids",
+	},
+	[]string{"vertical"},
+)
+
+func init() {
+	prometheus.MustRegister(houseOffersServed)
+}
+
+// noBidResponse returns the no-bid response for the caller's API key profile, falling back to
+// the global one
+func (h *BidHandler) noBidResponse(c *gin.Context) *config.NoBidResponseConfig {
+	if shape, exists := h.responseProfiles[c.GetHeader(apiKeyHeader)]; exists && shape.noBid != nil {
+		return shape.noBid
+	}
+	return h.config.NoBidResponse
+}
+
+// writeNoBidResponse writes the response to an auction without valid bids as the caller's no-bid
+// response configures it: the default 204, or a 200 body in the version's shape carrying the
+// reason or the vertical's house offer. houseOffers allows a house offer for this request.
+func (h *BidHandler) writeNoBidResponse(c *gin.Context, request *models.BidRequest, version APIVersion, houseOffers bool) {
+	noBid := h.noBidResponse(c)
+	if noBid.StatusCode() == http.StatusNoContent {
+		status, _, message := auctionErrorInfo(services.ErrNoValidBids)
+		c.JSON(status, gin.H{"error": message})
+		return
+	}
+
+	response := &models.BidResponse{RequestID: request.RequestID, Bids: []*models.Bid{}, Timestamp: time.Now()}
+	if noBid.IncludeReason {
+		response.Reason = models.ReasonNoValidBids
+	}
+	if offer := h.config.HouseOffers[request.Vertical]; houseOffers && noBid.HouseOffers && offer != nil {
+		response.Bids = []*models.Bid{houseOfferBid(offer, h.auctionService.EffectiveVertical(request.Vertical).ResponseTTL, response)}
+		response.HouseOffer = true
+		houseOffersServed.WithLabelValues(request.Vertical).Inc()
+	}
+
+	shape, err := h.responseShape(c, version)
+	if err != nil {
+		shape = fullResponseShape
+	}
+	setCacheHeaders(c, response)
+	h.writeBidResponse(c, version, shape, response)
+}
+
+// houseOfferBid returns a vertical's house offer as a bid from the house partner. With a response
+// TTL for the vertical, the offer and response stay valid for it, as auction results do.
+func houseOfferBid(offer *config.HouseOfferConfig, ttl time.Duration, response *models.BidResponse) *models.Bid {
+	bid := &models.Bid{
+		ID:        offer.ID,
+		PartnerID: models.HouseOfferPartnerID,
+		ClickURL:  offer.ClickURL,
+		Creative:  offer.Creative,
+	}
+	if ttl > 0 {
+		validUntil := response.Timestamp.Add(ttl)
+		bid.ExpiresAt = validUntil
+		response.ValidUntil = &validUntil
+	}
+	return bid
+}
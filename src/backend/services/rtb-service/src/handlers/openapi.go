@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/openapi"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// errorResponse is the error envelope every endpoint writes with a 4xx or 5xx status. Fields
+// lists the user data fields that failed validation.
+type errorResponse struct {
+	Error  string              `json:"error"`
+	Fields []models.FieldError `json:"fields,omitempty"`
+}
+
+// The OpenAPI document, built once from the model structs on first use
+var (
+	openAPIOnce     sync.Once
+	openAPIDocument *openapi.Document
+	openAPIJSON     []byte
+	openAPIErr      error
+)
+
+// OpenAPIDocument returns the service's OpenAPI document
+func OpenAPIDocument() (*openapi.Document, error) {
+	openAPIOnce.Do(func() {
+		openAPIDocument, openAPIErr = buildOpenAPIDocument()
+		if openAPIErr == nil {
+			openAPIJSON, openAPIErr = json.Marshal(openAPIDocument)
+		}
+	})
+	return openAPIDocument, openAPIErr
+}
+
+// buildOpenAPIDocument describes the v1 and v2 bid, batch, health, and partner report endpoints
+func buildOpenAPIDocument() (*openapi.Document, error) {
+	b := openapi.NewBuilder()
+
+	// Response shaping may leave any bid field out
+	b.Optional(models.Bid{})
+	// Bid extensions are passed through as partners sent them; their keys depend on the vertical.
+	// Defined first, as debug output carries them too.
+	b.Define(json.RawMessage(nil), &openapi.Schema{Type: "object", Description: "Vertical-specific bid fields", AdditionalProperties: &openapi.Schema{}})
+	// Debug output and dry-run side effects are encoded by their own MarshalJSON methods
+	b.Define(&models.DebugInfo{}, &openapi.Schema{
+		Type:       "object",
+		Properties: map[string]*openapi.Schema{"partners": {Type: "object", AdditionalProperties: b.Response(models.PartnerDebug{})}},
+	})
+	b.Define(&models.DryRun{}, &openapi.Schema{
+		Type:       "object",
+		Properties: map[string]*openapi.Schema{"side_effects": {Type: "array", Items: b.Response(models.SideEffect{})}},
+	})
+
+	bidRequest := b.Request(models.BidRequest{})
+	bidResponse := b.Response(models.BidResponse{})
+	errorSchema := b.Response(errorResponse{})
+	errorStatus := func(description string) *openapi.Response {
+		return &openapi.Response{Description: description, Content: openapi.JSON(errorSchema)}
+	}
+	health := &openapi.Response{Description: "Health report; 503 when a check failed", Content: openapi.JSON(b.Response(healthReport{}))}
+
+	bidOperation := func(id, summary string, response *openapi.Schema) *openapi.Operation {
+		return &openapi.Operation{
+			OperationID: id,
+			Summary:     summary,
+			Parameters: []openapi.Parameter{
+				{Name: "fields", In: "query", Description: "Comma-separated bid fields to return; v1 only", Schema: &openapi.Schema{Type: "string"}},
+				{Name: "debug", In: "query", Description: "Attach debug output; admin callers only", Schema: &openapi.Schema{Type: "boolean"}},
+				{Name: "Accept", In: "header", Description: `Response version by profile, e.g. application/json; profile="v2"`, Schema: &openapi.Schema{Type: "string"}},
+				{Name: RequestIDHeader, In: "header", Description: "Request ID; takes precedence over request_id in the body", Schema: &openapi.Schema{Type: "string"}},
+				{Name: overrideHeader, In: "header", Description: "Per-request floor and disabled partners; admin callers only", Schema: &openapi.Schema{Type: "string"}},
+				{Name: auctionParamsHeader, In: "header", Description: "JSON auction parameters forced on this auction, as in auction_params; admin callers only", Schema: &openapi.Schema{Type: "string"}},
+			},
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(bidRequest)},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Winning bids, or a reason when too few partners bid", Content: openapi.JSON(response)},
+				"204": {Description: "No valid bids"},
+				"400": errorStatus("Malformed request, unknown response fields, or invalid user data"),
+				"403": errorStatus("Rejected as invalid traffic, or an override header or auction params without admin authentication"),
+				"406": errorStatus("Unknown version profile in the Accept header"),
+				"409": errorStatus("Request ID already used"),
+				"500": errorStatus("Internal error"),
+				"503": errorStatus("Service at capacity or partner collection failed"),
+				"504": errorStatus("Auction timed out"),
+			},
+		}
+	}
+
+	paths := map[string]*openapi.PathItem{
+		"/v1/bids": {Post: bidOperation("runAuction", "Run an auction for a lead", bidResponse)},
+		"/v2/bids": {Post: bidOperation("runAuctionV2", "Run an auction for a lead, with prices as money and per-bid clearing prices", b.Response(models.BidResponseV2{}))},
+		"/v1/bids/batch": {Post: &openapi.Operation{
+			OperationID: "runBatchAuction",
+			Summary:     "Run an auction for each lead in a batch",
+			RequestBody: &openapi.RequestBody{Required: true, Content: openapi.JSON(&openapi.Schema{Type: "array", Items: bidRequest})},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "A result per request, in request order", Content: openapi.JSON(b.Response(models.BatchBidResponse{}))},
+				"400": errorStatus("Malformed or empty batch"),
+				"413": errorStatus("Batch exceeds the maximum number of requests"),
+			},
+		}},
+		"/health": {Get: &openapi.Operation{
+			OperationID: "health",
+			Summary:     "Dependency checks with partner failure counts",
+			Responses:   map[string]*openapi.Response{"200": health, "503": health},
+		}},
+		"/v1/reasons": {Get: &openapi.Operation{
+			OperationID: "listReasons",
+			Summary:     "Registered reason codes used in metrics, debug output, responses, and events",
+			Parameters: []openapi.Parameter{
+				{Name: "kind", In: "query", Description: "Only list reasons of this kind: skip, selection, loss, no_bid, or auction", Schema: &openapi.Schema{Type: "string"}},
+			},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Registered reasons, ordered by kind and reason", Content: openapi.JSON(&openapi.Schema{
+					Type:       "object",
+					Properties: map[string]*openapi.Schema{"reasons": {Type: "array", Items: b.Response(models.ReasonInfo{})}},
+				})},
+				"400": errorStatus("Unknown reason kind"),
+			},
+		}},
+		"/v1/partners/{id}/report": {Get: &openapi.Operation{
+			OperationID: "partnerReport",
+			Summary:     "A partner's rates, clearing price, and SLA compliance; admin callers only",
+			Parameters: []openapi.Parameter{
+				{Name: "id", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}},
+				{Name: "window", In: "query", Description: "Report window in whole hours, e.g. 24h", Schema: &openapi.Schema{Type: "string"}},
+			},
+			Responses: map[string]*openapi.Response{
+				"200": {Description: "Partner report", Content: openapi.JSON(b.Response(services.PartnerReport{}))},
+				"400": errorStatus("Invalid window"),
+				"401": errorStatus("Admin authentication required"),
+				"404": errorStatus("Unknown partner"),
+				"500": errorStatus("Internal error"),
+			},
+		}},
+	}
+
+	schemas, err := b.Schemas()
+	if err != nil {
+		return nil, err
+	}
+	return &openapi.Document{
+		OpenAPI:    openapi.Version,
+		Info:       openapi.Info{Title: "RTB Service", Version: "1.0.0", Description: "Real-time bidding auctions for leads"},
+		Paths:      paths,
+		Components: openapi.Components{Schemas: schemas},
+	}, nil
+}
+
+// HandleOpenAPI serves the OpenAPI document
+func (h *BidHandler) HandleOpenAPI(c *gin.Context) {
+	if _, err := OpenAPIDocument(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", openAPIJSON)
+}
+
+// swaggerUIPage renders the OpenAPI document with Swagger UI loaded from a CDN
+const swaggerUIPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>RTB Service API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// HandleSwaggerUI serves a Swagger UI page for the OpenAPI document
+func (a *AdminHandler) HandleSwaggerUI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
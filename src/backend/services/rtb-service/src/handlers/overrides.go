@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+	"go.uber.org/zap"          // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// overrideHeader carries a per-request override from an admin caller, e.g.
+// "floor=12.50; disable=partner-1,partner-2"
+const overrideHeader = "X-RTB-Override"
+
+// overrideRequest is the body of POST /admin/overrides
+type overrideRequest struct {
+	Floor            float64  `json:"floor"`
+	DisabledPartners []string `json:"disabled_partners"`
+	TTL              string   `json:"ttl"`
+	Reason           string   `json:"reason"`
+	RequestedBy      string   `json:"requested_by"`
+}
+
+// adminKeyFingerprint identifies an admin key in logs and override listings without revealing it
+func adminKeyFingerprint(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:6])
+}
+
+// HandleOverrides returns the admin overrides in effect
+func (a *AdminHandler) HandleOverrides(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"overrides": a.auctionService.Overrides(),
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// HandleAddOverride puts a temporary floor or partner kill switch into effect for every auction
+func (a *AdminHandler) HandleAddOverride(c *gin.Context) {
+	var request overrideRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid override"})
+		return
+	}
+	ttl, err := time.ParseDuration(request.TTL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrOverrideTTL.Error()})
+		return
+	}
+
+	override, err := a.auctionService.AddOverride(services.Override{
+		Floor:            request.Floor,
+		DisabledPartners: request.DisabledPartners,
+		Reason:           strings.TrimSpace(request.Reason),
+		RequestedBy:      strings.TrimSpace(request.RequestedBy),
+		AdminKey:         adminKeyFingerprint(adminKeyFromRequest(c)),
+	}, ttl)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{
+		"override":  override,
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// HandleRemoveOverride lifts an override before it expires
+func (a *AdminHandler) HandleRemoveOverride(c *gin.Context) {
+	liftedBy := adminKeyFingerprint(adminKeyFromRequest(c))
+	if requestedBy := c.Query("requested_by"); requestedBy != "" {
+		liftedBy = requestedBy + " (" + liftedBy + ")"
+	}
+	err := a.auctionService.RemoveOverride(c.Param("id"), liftedBy)
+	if errors.Is(err, services.ErrOverrideNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Override not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":        c.Param("id"),
+		"timestamp": time.Now().UTC(),
+	})
+}
+
+// requestOverride reads the override header and reports whether a response was already written.
+// Only admin callers may send it; anyone else, or a malformed override, is refused before the
+// auction runs.
+func (h *BidHandler) requestOverride(c *gin.Context, requestID string) (*models.Override, bool) {
+	header := c.GetHeader(overrideHeader)
+	if header == "" {
+		return nil, false
+	}
+	key := adminKeyFromRequest(c)
+	if !isAdminKey(h.config, key) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Override header requires admin authentication"})
+		return nil, true
+	}
+
+	override, err := parseOverrideHeader(header)
+	if err == nil {
+		err = h.auctionService.ValidateRequestOverride(override)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, true
+	}
+
+	h.logger.Info("request override",
+		zap.String("request_id", requestID),
+		zap.Float64("floor", override.Floor),
+		zap.Strings("disabled_partners", override.DisabledPartners),
+		zap.String("admin_key", adminKeyFingerprint(key)),
+	)
+	return override, false
+}
+
+// parseOverrideHeader parses "floor=12.50; disable=partner-1,partner-2", where either part may be omitted
+func parseOverrideHeader(header string) (*models.Override, error) {
+	override := &models.Override{}
+	for _, part := range strings.Split(header, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, found := strings.Cut(part, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid override %q", part)
+		}
+		switch strings.TrimSpace(name) {
+		case "floor":
+			floor, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid override floor %q", value)
+			}
+			override.Floor = floor
+		case "disable":
+			for _, partnerID := range strings.Split(value, ",") {
+				if partnerID = strings.TrimSpace(partnerID); partnerID != "" {
+					override.DisabledPartners = append(override.DisabledPartners, partnerID)
+				}
+			}
+		default:
+			return nil, fmt.Errorf("unknown override %q", name)
+		}
+	}
+	return override, nil
+}
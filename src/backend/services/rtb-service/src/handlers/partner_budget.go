@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// HandlePartnerBudget reports a partner's daily budget for the current UTC day: what its wins were
+// charged and what running auctions hold against it
+func (a *AdminHandler) HandlePartnerBudget(c *gin.Context) {
+	status, err := a.auctionService.PartnerBudget(c.Request.Context(), c.Param("id"))
+	switch {
+	case errors.Is(err, services.ErrUnknownPartner):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown partner"})
+	case errors.Is(err, services.ErrNoBudget):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Budget unavailable"})
+	default:
+		c.JSON(http.StatusOK, status)
+	}
+}
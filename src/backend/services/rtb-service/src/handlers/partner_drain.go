@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// drainRequest is the body of POST /admin/partners/:id/drain. The deadline is either an RFC 3339
+// time or a duration from now.
+type drainRequest struct {
+	Deadline    *time.Time `json:"deadline"`
+	Duration    string     `json:"duration"`
+	RequestedBy string     `json:"requested_by"`
+}
+
+// HandleDrainPartner starts draining a partner being offboarded: it gets no new auctions, and bids
+// it already won settle until the deadline
+func (a *AdminHandler) HandleDrainPartner(c *gin.Context) {
+	var request drainRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid drain request"})
+		return
+	}
+	var deadline time.Time
+	switch {
+	case request.Deadline != nil && request.Duration != "":
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Set either deadline or duration"})
+		return
+	case request.Deadline != nil:
+		deadline = *request.Deadline
+	default:
+		duration, err := time.ParseDuration(request.Duration)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": services.ErrDrainDeadline.Error()})
+			return
+		}
+		deadline = time.Now().Add(duration)
+	}
+	requestedBy := strings.TrimSpace(request.RequestedBy)
+	if requestedBy != "" {
+		requestedBy += " (" + adminKeyFingerprint(adminKeyFromRequest(c)) + ")"
+	}
+
+	err := a.auctionService.DrainPartner(c.Param("id"), deadline, requestedBy)
+	switch {
+	case errors.Is(err, services.ErrUnknownPartner):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown partner"})
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusCreated, gin.H{
+			"partner_id":     c.Param("id"),
+			"drain_deadline": deadline.UTC(),
+			"timestamp":      time.Now().UTC(),
+		})
+	}
+}
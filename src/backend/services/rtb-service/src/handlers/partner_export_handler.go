@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+	"go.uber.org/zap"          // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// Partner export formats
+const (
+	exportFormatCSV  = "csv"
+	exportFormatXLSX = "xlsx"
+)
+
+// partnerExportColumns are the export's columns ahead of one quality score column per vertical
+var partnerExportColumns = []string{
+	"partner_id", "window", "requests", "bid_rate", "win_rate", "avg_latency_ms", "p95_latency_ms",
+	"avg_clearing_price", "timeout_rate", "budget_utilization",
+}
+
+// partnerExportQualityPrefix prefixes the vertical in a quality score column's name
+const partnerExportQualityPrefix = "quality_score_"
+
+// rowWriter writes an export a row at a time, flushing each row as it goes
+type rowWriter interface {
+	WriteRow(cells []any) error
+	Close() error
+}
+
+// csvRowWriter writes rows as CSV
+type csvRowWriter struct {
+	writer *csv.Writer
+}
+
+// WriteRow writes a row of strings, float64s, and uint64s, leaving nil cells empty
+func (w *csvRowWriter) WriteRow(cells []any) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch value := cell.(type) {
+		case nil:
+		case string:
+			record[i] = value
+		case float64:
+			record[i] = strconv.FormatFloat(value, 'f', -1, 64)
+		case uint64:
+			record[i] = strconv.FormatUint(value, 10)
+		default:
+			return fmt.Errorf("unsupported csv cell type %T", cell)
+		}
+	}
+	w.writer.Write(record)
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+// Close is a no-op; every row is flushed as it is written
+func (w *csvRowWriter) Close() error {
+	return nil
+}
+
+// HandlePartnerExport streams one row per partner, with the same figures as the partner report
+// over the window, as CSV or, with format=xlsx, as an Excel workbook. Rows are written as each
+// partner's report is read, so the export is never held in memory. Admin callers only.
+func (h *BidHandler) HandlePartnerExport(c *gin.Context) {
+	if !isAdminKey(h.config, adminKeyFromRequest(c)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin authentication required"})
+		return
+	}
+	window, err := parseWindow(c.Query("window"), defaultReportWindow)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window"})
+		return
+	}
+	format := c.DefaultQuery("format", exportFormatCSV)
+	if format != exportFormatCSV && format != exportFormatXLSX {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected csv or xlsx"})
+		return
+	}
+
+	export, err := h.auctionService.PartnerExport(window)
+	switch {
+	case errors.Is(err, services.ErrInvalidReportWindow):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+
+	fileName := fmt.Sprintf("partners-%s-%s.%s", formatExportWindow(window), time.Now().UTC().Format("20060102"), format)
+	contentType := "text/csv; charset=utf-8"
+	if format == exportFormatXLSX {
+		contentType = xlsxContentType
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fileName))
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.Status(http.StatusOK)
+
+	if err := h.writePartnerExport(c, format, export); err != nil {
+		// The status is already sent, so a failed export can only end early
+		h.logger.Warn("partner export failed", zap.Error(err))
+	}
+}
+
+// writePartnerExport writes the export's header and a row per partner in format, flushing each row
+// to the client
+func (h *BidHandler) writePartnerExport(c *gin.Context, format string, export *services.PartnerExport) error {
+	var rows rowWriter = &csvRowWriter{writer: csv.NewWriter(c.Writer)}
+	if format == exportFormatXLSX {
+		sheet, err := newXLSXWriter(c.Writer, "Partners")
+		if err != nil {
+			return err
+		}
+		rows = sheet
+	}
+
+	header := make([]any, 0, len(partnerExportColumns)+len(export.Verticals))
+	for _, column := range partnerExportColumns {
+		header = append(header, column)
+	}
+	for _, vertical := range export.Verticals {
+		header = append(header, partnerExportQualityPrefix+vertical)
+	}
+	if err := rows.WriteRow(header); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+
+	for _, partnerID := range export.PartnerIDs {
+		if err := c.Request.Context().Err(); err != nil {
+			return err
+		}
+		report, err := h.auctionService.PartnerReport(partnerID, export.Window)
+		if err != nil {
+			return fmt.Errorf("partner %s: %w", partnerID, err)
+		}
+		if err := rows.WriteRow(partnerExportRow(report, export.Verticals)); err != nil {
+			return err
+		}
+		c.Writer.Flush()
+	}
+	return rows.Close()
+}
+
+// partnerExportRow returns a report's cells in column order, leaving empty the budget utilization
+// of a partner without a budget and the quality score of a vertical it did not bid in
+func partnerExportRow(report *services.PartnerReport, verticals []string) []any {
+	row := []any{
+		report.PartnerID, report.Window, report.Calls, report.BidRate, report.WinRate, report.AvgLatencyMs,
+		report.P95LatencyMs, report.AvgClearingPrice, report.TimeoutRate, nil,
+	}
+	if report.BudgetUtilization != nil {
+		row[len(row)-1] = *report.BudgetUtilization
+	}
+	for _, vertical := range verticals {
+		if score, exists := report.QualityScores[vertical]; exists {
+			row = append(row, score)
+		} else {
+			row = append(row, nil)
+		}
+	}
+	return row
+}
+
+// formatExportWindow names a window, rounded up to whole hours like the report's, in days when it
+// is whole days and in hours otherwise
+func formatExportWindow(window time.Duration) string {
+	hours := int64(math.Ceil(window.Hours()))
+	if hours%24 == 0 {
+		return strconv.FormatInt(hours/24, 10) + "d"
+	}
+	return strconv.FormatInt(hours, 10) + "h"
+}
@@ -0,0 +1,67 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// HandlePartnerHygiene lists the partners without recent bids, wins, or traffic, or timing out on
+// every call, with why each is idle and a suggested action. bid_days and win_days override the
+// configured idle days.
+func (a *AdminHandler) HandlePartnerHygiene(c *gin.Context) {
+	idleBid, err := parseIdleDays(c.Query("bid_days"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid bid_days"})
+		return
+	}
+	idleWin, err := parseIdleDays(c.Query("win_days"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid win_days"})
+		return
+	}
+	report, err := a.auctionService.PartnerHygiene(idleBid, idleWin)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// parseIdleDays parses a positive number of days, returning zero for the configured default when
+// value is empty
+func parseIdleDays(value string) (time.Duration, error) {
+	if value == "" {
+		return 0, nil
+	}
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return 0, errors.New("idle days must be a positive integer")
+	}
+	return time.Duration(days) * 24 * time.Hour, nil
+}
+
+// HandleRestorePartner takes a partner out of shadow mode, so its bids compete again
+func (a *AdminHandler) HandleRestorePartner(c *gin.Context) {
+	restoredBy := adminKeyFingerprint(adminKeyFromRequest(c))
+	if requestedBy := c.Query("requested_by"); requestedBy != "" {
+		restoredBy = requestedBy + " (" + restoredBy + ")"
+	}
+	err := a.auctionService.RestorePartner(c.Param("id"), restoredBy)
+	switch {
+	case errors.Is(err, services.ErrUnknownPartner):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown partner"})
+	case errors.Is(err, services.ErrPartnerNotShadowed):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"partner_id": c.Param("id"),
+			"timestamp":  time.Now().UTC(),
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// defaultReportWindow is the partner report window when none is requested
+const defaultReportWindow = 7 * 24 * time.Hour
+
+// HandlePartnerReport returns a partner's timeout, invalid-bid, and win rates, average clearing
+// price, and SLA compliance over a window of whole hours. Admin callers only.
+func (h *BidHandler) HandlePartnerReport(c *gin.Context) {
+	if !isAdminKey(h.config, adminKeyFromRequest(c)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin authentication required"})
+		return
+	}
+	window, err := parseWindow(c.Query("window"), defaultReportWindow)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid window"})
+		return
+	}
+
+	report, err := h.auctionService.PartnerReport(c.Param("id"), window)
+	switch {
+	case errors.Is(err, services.ErrUnknownPartner):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Unknown partner"})
+	case errors.Is(err, services.ErrInvalidReportWindow):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// HandlePartnerTrafficMix returns, per partner, the verticals and regions of the traffic it was
+// offered next to those of all auctions capped by partner selection
+func (h *BidHandler) HandlePartnerTrafficMix(c *gin.Context) {
+	report, err := h.auctionService.PartnerTrafficMix()
+	switch {
+	case errors.Is(err, services.ErrPartnerSelectionDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Partner selection disabled"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
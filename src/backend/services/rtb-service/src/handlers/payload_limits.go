@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"                       // v1.9.1
+	"github.com/gin-gonic/gin/binding"               // v1.9.1
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// Oversized inbound payloads
+const (
+	payloadRequestBody = "request_body"
+	payloadUserData    = "user_data"
+)
+
+var payloadsTooLarge = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "rtb_payloads_too_large_total",
+		Help: "Total number of bid requests rejected for an oversized request body or UserData, by payload and traffic",
+	},
+	[]string{"payload", "traffic"},
+)
+
+func init() {
+	prometheus.MustRegister(payloadsTooLarge)
+}
+
+// bindBidRequest binds a single-lead bid request within the payload limits, writing the error
+// response when it is rejected. The body is read up to the request limit before any of it is
+// parsed, and a request declaring a longer Content-Length is rejected without reading it.
+func (h *BidHandler) bindBidRequest(c *gin.Context, bidRequest *models.BidRequest, bind binding.Binding, traffic string) bool {
+	limits := h.config.PayloadLimits.Limits()
+
+	var body []byte
+	var err error
+	if c.Request.ContentLength > limits.MaxRequestBytes {
+		err = &http.MaxBytesError{Limit: limits.MaxRequestBytes}
+	} else {
+		body, err = io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxRequestBytes))
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		rejectPayload(c, payloadRequestBody, traffic, "Request body too large")
+		return false
+	}
+	if err == nil {
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		err = c.ShouldBindWith(bidRequest, bind)
+	}
+	if err != nil {
+		bidErrors.WithLabelValues("invalid_request", "unknown", transportHTTP, traffic).Inc()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return false
+	}
+
+	if userDataBytes(bidRequest.UserData) > limits.MaxUserDataBytes {
+		rejectPayload(c, payloadUserData, traffic, "User data too large")
+		return false
+	}
+	return true
+}
+
+// rejectPayload answers 413 for an oversized payload and counts the rejection
+func rejectPayload(c *gin.Context, payload, traffic, message string) {
+	payloadsTooLarge.WithLabelValues(payload, traffic).Inc()
+	bidErrors.WithLabelValues("payload_too_large", "unknown", transportHTTP, traffic).Inc()
+	c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": message})
+}
+
+// userDataBytes returns the size of UserData encoded as JSON
+func userDataBytes(userData map[string]interface{}) int {
+	if len(userData) == 0 {
+		return 0
+	}
+	encoded, err := json.Marshal(userData)
+	if err != nil {
+		return 0
+	}
+	return len(encoded)
+}
@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// HandleReasons lists the registered skip, selection, loss, no-bid, and auction reasons with
+// their descriptions, so consumers can label them without hardcoding the codes. The kind query
+// parameter narrows the list to one kind.
+func (h *BidHandler) HandleReasons(c *gin.Context) {
+	kind := models.ReasonKind(c.Query("kind"))
+	reasons := make([]models.ReasonInfo, 0)
+	for _, info := range models.Reasons() {
+		if kind == "" || info.Kind == kind {
+			reasons = append(reasons, info)
+		}
+	}
+	if len(reasons) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown reason kind"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"reasons": reasons})
+}
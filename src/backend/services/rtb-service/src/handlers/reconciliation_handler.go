@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/reconcile"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// maxReconcileBodyBytes bounds the partner CSV accepted for reconciliation
+const maxReconcileBodyBytes = 32 << 20
+
+// HandlePartnerSpend returns a partner's won bids and their summed clearing prices by day and
+// vertical between from and to, read from the audit log, for admin callers
+func (h *BidHandler) HandlePartnerSpend(c *gin.Context) {
+	if !isAdminKey(h.config, adminKeyFromRequest(c)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin authentication required"})
+		return
+	}
+	partnerID := c.Query("partner")
+	if partnerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "partner is required"})
+		return
+	}
+	window, err := reconcile.ParseWindow(c.Query("from"), c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	report, err := h.auctionService.PartnerSpend(partnerID, window)
+	switch {
+	case errors.Is(err, services.ErrReconciliationDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audit log disabled"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// HandleReconcile compares a partner's CSV of the leads it bought, the request body, with its won
+// bids in the audit log and returns the days that disagree by more than the tolerance, for admin
+// callers. Without from and to, the window is the days the CSV covers.
+func (h *BidHandler) HandleReconcile(c *gin.Context) {
+	if !isAdminKey(h.config, adminKeyFromRequest(c)) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Admin authentication required"})
+		return
+	}
+	partnerID := c.Query("partner")
+	if partnerID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "partner is required"})
+		return
+	}
+	tolerance := reconcile.DefaultTolerance
+	if value := c.Query("tolerance"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid tolerance"})
+			return
+		}
+		tolerance = parsed
+	}
+
+	records, err := reconcile.ParseCSV(http.MaxBytesReader(c.Writer, c.Request.Body, maxReconcileBodyBytes))
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.As(err, &maxBytesErr):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Request body too large"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid CSV: " + err.Error()})
+		return
+	}
+
+	var window reconcile.Window
+	if c.Query("from") == "" && c.Query("to") == "" {
+		var covered bool
+		if window, covered = reconcile.WindowOf(records); !covered {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "CSV has no records; from and to are required"})
+			return
+		}
+	} else if window, err = reconcile.ParseWindow(c.Query("from"), c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.auctionService.ReconcilePartner(partnerID, window, records, tolerance)
+	switch {
+	case errors.Is(err, services.ErrReconciliationDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audit log disabled"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// Replay protection headers: the request's Unix timestamp in seconds and a nonce unique to it
+const (
+	replayTimestampHeader = "X-RTB-Timestamp"
+	replayNonceHeader     = "X-RTB-Nonce"
+)
+
+// ReplayProtection returns middleware that rejects replayed bid requests from API clients opted
+// in to replay protection. Other clients pass through unchecked.
+func (h *BidHandler) ReplayProtection() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := h.auctionService.CheckReplay(c.Request.Context(), c.GetHeader(apiKeyHeader),
+			c.GetHeader(replayTimestampHeader), c.GetHeader(replayNonceHeader))
+		switch {
+		case err == nil:
+			c.Next()
+		case errors.Is(err, services.ErrReplayHeaders):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "replay_headers_missing"})
+		case errors.Is(err, services.ErrReplaySkew):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "replay_timestamp_skew"})
+		case errors.Is(err, services.ErrReplayNonce):
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error(), "code": "replay_nonce_reused"})
+		default:
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Replay check unavailable", "code": "replay_check_unavailable"})
+		}
+	}
+}
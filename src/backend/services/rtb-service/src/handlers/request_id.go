@@ -0,0 +1,139 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"                       // v1.9.1
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// Request ID header and context keys
+const (
+	RequestIDHeader          = services.RequestIDHeader
+	rtbRequestIDHeader       = "X-RTB-Request-ID"
+	requestIDContextKey      = "request_id"
+	requestIDGeneratedKey    = "request_id_generated"
+	defaultDuplicateIDWindow = time.Minute
+)
+
+var duplicateRequestIDs = prometheus.NewCounter(
+	prometheus.CounterOpts{
+		Name: "rtb_duplicate_request_ids_total",
+		Help: "Total number of bid requests reusing a request ID seen within the duplicate window",
+	},
+)
+
+func init() {
+	prometheus.MustRegister(duplicateRequestIDs)
+}
+
+// RequestIDMiddleware reads X-Request-ID or generates a UUIDv7, and echoes it on the response
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requestID := c.GetHeader(RequestIDHeader)
+		generated := requestID == ""
+		if generated {
+			requestID = newUUIDv7()
+		}
+
+		setRequestID(c, requestID, generated)
+		c.Next()
+	}
+}
+
+// setRequestID stores the request ID on the gin context and echoes it in response headers
+func setRequestID(c *gin.Context, requestID string, generated bool) {
+	c.Set(requestIDContextKey, requestID)
+	c.Set(requestIDGeneratedKey, generated)
+	c.Header(RequestIDHeader, requestID)
+	c.Header(rtbRequestIDHeader, requestID)
+}
+
+// resolveRequestID picks the request ID for an auction: header first, then body, then generated
+func resolveRequestID(c *gin.Context, bodyID string) string {
+	requestID := c.GetString(requestIDContextKey)
+	generated := c.GetBool(requestIDGeneratedKey)
+	if _, exists := c.Get(requestIDContextKey); !exists {
+		requestID = c.GetHeader(RequestIDHeader)
+	}
+
+	switch {
+	case bodyID != "" && (requestID == "" || generated):
+		requestID, generated = bodyID, false
+	case requestID == "":
+		requestID, generated = newUUIDv7(), true
+	}
+
+	setRequestID(c, requestID, generated)
+	return requestID
+}
+
+// newUUIDv7 generates a time-ordered RFC 9562 version 7 UUID
+func newUUIDv7() string {
+	var uuid [16]byte
+	binary.BigEndian.PutUint64(uuid[0:8], uint64(time.Now().UnixMilli())<<16)
+	if _, err := rand.Read(uuid[6:]); err != nil {
+		panic(err)
+	}
+	uuid[6] = (uuid[6] & 0x0f) | 0x70
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+	return string(buf[:])
+}
+
+// requestIDTracker remembers recently seen request IDs to flag duplicates
+type requestIDTracker struct {
+	window    time.Duration
+	mutex     sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+// newRequestIDTracker creates a tracker for the given duplicate window
+func newRequestIDTracker(window time.Duration) *requestIDTracker {
+	if window <= 0 {
+		window = defaultDuplicateIDWindow
+	}
+	return &requestIDTracker{
+		window:    window,
+		seen:      make(map[string]time.Time),
+		lastPrune: time.Now(),
+	}
+}
+
+// Observe records a request ID and reports whether it was already seen within the window
+func (t *requestIDTracker) Observe(requestID string) bool {
+	now := time.Now()
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if now.Sub(t.lastPrune) >= t.window {
+		for id, seenAt := range t.seen {
+			if now.Sub(seenAt) >= t.window {
+				delete(t.seen, id)
+			}
+		}
+		t.lastPrune = now
+	}
+
+	seenAt, exists := t.seen[requestID]
+	t.seen[requestID] = now
+	return exists && now.Sub(seenAt) < t.window
+}
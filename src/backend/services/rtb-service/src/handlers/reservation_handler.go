@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"         // v1.9.1
+	"github.com/gin-gonic/gin/binding" // v1.9.1
+	"go.uber.org/zap"                  // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// HandleReserveRequest runs an auction and reserves its winners for the reservation TTL. The
+// response carries the winning bids and the token that confirms them; nothing is settled until then.
+func (h *BidHandler) HandleReserveRequest(c *gin.Context) {
+	startTime := time.Now()
+	activeBidGauge.Inc()
+	defer activeBidGauge.Dec()
+
+	var bidRequest models.BidRequest
+	if !h.bindBidRequest(c, &bidRequest, binding.JSON, trafficLive) {
+		return
+	}
+
+	bidRequest.RequestID = resolveRequestID(c, bidRequest.RequestID)
+	h.auctionService.EnrichRequest(&bidRequest, c.ClientIP(), c.GetHeader("User-Agent"))
+	if h.requestIDs.Observe(bidRequest.RequestID) {
+		duplicateRequestIDs.Inc()
+	}
+	bidRequestsTotal.WithLabelValues(bidRequest.Vertical, "all", transportHTTP, trafficLive).Inc()
+
+	release, acquired := h.tryAcquireAuction(h.limiter, bidRequest.Vertical)
+	if !acquired {
+		auctionsShed.WithLabelValues(sourceLive).Inc()
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service at capacity"})
+		return
+	}
+	defer release()
+
+	reqCtx, cancel := context.WithTimeout(h.ctx, h.config.AuctionTimeout(bidRequest.Vertical))
+	defer cancel()
+	reqCtx = h.withAuction(c, reqCtx, bidRequest.RequestID)
+
+	// Every reservation runs its own auction; idempotent replay would hand out a second token for the same winners
+	reservation, err := h.auctionService.Reserve(reqCtx, &bidRequest)
+	switch {
+	case errors.Is(err, services.ErrReservationsFull):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Too many pending reservations"})
+		return
+	case err != nil:
+		// A house offer has no winner to hold, so reservations never get one
+		h.handleAuctionError(c, &bidRequest, err, APIVersion1, false)
+		return
+	}
+
+	for _, bid := range reservation.Bids {
+		successfulBids.WithLabelValues(bidRequest.Vertical, bid.PartnerID, transportHTTP, trafficLive).Inc()
+	}
+	duration := time.Since(startTime)
+	bidResponseTime.WithLabelValues(bidRequest.Vertical, "all", transportHTTP, trafficLive).Observe(duration.Seconds())
+
+	c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+	c.Header("X-RTB-Processing-Time", duration.String())
+	c.JSON(http.StatusOK, reservation)
+}
+
+// HandleConfirmReservation confirms a reservation, settling its winners, and returns the final
+// bids. Expired and unknown tokens answer 410, with the response_expired code when the reserved
+// response passed its valid_until, and tokens already confirmed answer 409.
+func (h *BidHandler) HandleConfirmReservation(c *gin.Context) {
+	response, err := h.auctionService.ConfirmReservation(c.Request.Context(), c.Param("token"))
+	switch {
+	case errors.Is(err, services.ErrReservationsDisabled):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reservations disabled"})
+	case errors.Is(err, services.ErrResponseExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Auction response expired", "code": "response_expired"})
+	case errors.Is(err, services.ErrReservationExpired):
+		c.JSON(http.StatusGone, gin.H{"error": "Reservation expired", "code": "reservation_expired"})
+	case errors.Is(err, services.ErrReservationConfirmed):
+		c.JSON(http.StatusConflict, gin.H{"error": "Reservation already confirmed"})
+	case err != nil:
+		h.logger.Error("confirming reservation", zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	default:
+		c.Header("Cache-Control", "no-cache, no-store, must-revalidate")
+		c.JSON(http.StatusOK, response)
+	}
+}
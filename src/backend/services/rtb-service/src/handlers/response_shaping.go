@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"                       // v1.9.1
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// apiKeyHeader carries the client API key that selects a response profile
+const apiKeyHeader = "X-API-Key"
+
+// Response size label values for responses not shaped by a configured profile
+const (
+	responseProfileFull  = "full"
+	responseProfileQuery = "query"
+)
+
+var responseSizeBytes = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "rtb_response_size_bytes",
+		Help:    "Size of bid response bodies in bytes by response profile",
+		Buckets: prometheus.ExponentialBuckets(256, 2, 10),
+	},
+	[]string{"profile"},
+)
+
+func init() {
+	prometheus.MustRegister(responseSizeBytes)
+}
+
+// responseShape is the set of Bid fields written for a request and the profile it came from,
+// with the profile's no-bid response when it overrides the global one
+type responseShape struct {
+	profile string
+	fields  models.BidFields
+	noBid   *config.NoBidResponseConfig
+}
+
+// fullResponseShape writes the complete bid response
+var fullResponseShape = responseShape{profile: responseProfileFull, fields: models.AllBidFields}
+
+// newResponseProfiles indexes the configured response profiles by API key
+func newResponseProfiles(profiles map[string]*config.ResponseProfile) (map[string]responseShape, error) {
+	shapes := make(map[string]responseShape)
+	for name, profile := range profiles {
+		fields, err := models.ParseBidFields(strings.Join(profile.Fields, ","))
+		if err != nil {
+			return nil, fmt.Errorf("response profile %s: %w", name, err)
+		}
+		for _, key := range profile.APIKeys {
+			shapes[key] = responseShape{profile: name, fields: fields, noBid: profile.NoBidResponse}
+		}
+	}
+	return shapes, nil
+}
+
+// responseShape resolves the Bid fields for a request: the fields query parameter, then the
+// profile for the caller's API key, then the full response. v2 responses are never shaped.
+func (h *BidHandler) responseShape(c *gin.Context, version APIVersion) (responseShape, error) {
+	if version == APIVersion2 {
+		if _, exists := c.GetQuery("fields"); exists {
+			return responseShape{}, errV2Fields
+		}
+		return fullResponseShape, nil
+	}
+	if list, exists := c.GetQuery("fields"); exists {
+		fields, err := models.ParseBidFields(list)
+		if err != nil {
+			return responseShape{}, err
+		}
+		if fields == models.AllBidFields {
+			return fullResponseShape, nil
+		}
+		return responseShape{profile: responseProfileQuery, fields: fields}, nil
+	}
+	if shape, exists := h.responseProfiles[c.GetHeader(apiKeyHeader)]; exists {
+		return shape, nil
+	}
+	return fullResponseShape, nil
+}
+
+// writeShapedResponse writes the bid response with the shape's fields and records its size
+func (h *BidHandler) writeShapedResponse(c *gin.Context, shape responseShape, response *models.BidResponse) {
+	size := writeJSON(c, h.codec, http.StatusOK, bidResponseBody(APIVersion1, shape.fields, response))
+	responseSizeBytes.WithLabelValues(shape.profile).Observe(float64(size))
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// HandleUserDataSchema returns the UserData schema for a vertical so clients can validate leads
+// with the same rules before submitting them
+func (h *BidHandler) HandleUserDataSchema(c *gin.Context) {
+	vertical := c.Param("vertical")
+	schema, exists := h.auctionService.UserDataSchema(vertical)
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No schema for vertical"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.JSON(http.StatusOK, gin.H{"vertical": vertical, "fields": schema.Fields})
+}
+
+// userDataFieldErrors returns the failed fields of a UserData validation error, or nil for other errors
+func userDataFieldErrors(err error) []models.FieldError {
+	var userDataErr *services.UserDataError
+	if errors.As(err, &userDataErr) {
+		return userDataErr.Fields
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// Server-Sent Event names emitted by the bid stream
+const (
+	streamEventBid     = "bid"
+	streamEventWinners = "winners"
+	streamEventError   = "error"
+)
+
+// streamResult carries the outcome of a streaming auction back to the writer loop
+type streamResult struct {
+	response *models.BidResponse
+	err      error
+}
+
+// HandleBidStream runs an auction and streams each validated bid as an SSE event as partners respond,
+// followed by a terminal winners event with the final selection
+func (h *BidHandler) HandleBidStream(c *gin.Context) {
+	startTime := time.Now()
+
+	bidRequest, err := bindStreamRequest(c)
+	if err != nil {
+		bidErrors.WithLabelValues("invalid_request", "unknown", transportHTTP, trafficLive).Inc()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+		return
+	}
+	bidRequest.RequestID = resolveRequestID(c, bidRequest.RequestID)
+	h.auctionService.EnrichRequest(bidRequest, c.ClientIP(), c.GetHeader("User-Agent"))
+
+	bidRequestsTotal.WithLabelValues(bidRequest.Vertical, "all", transportHTTP, trafficLive).Inc()
+
+	release, acquired := h.tryAcquireAuction(h.streams, bidRequest.Vertical)
+	if !acquired {
+		auctionsShed.WithLabelValues(sourceStream).Inc()
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Service at capacity"})
+		return
+	}
+	defer release()
+
+	// The request context is canceled when the client disconnects, which stops the auction
+	streamCtx, cancel := context.WithTimeout(c.Request.Context(), h.config.AuctionTimeout(bidRequest.Vertical))
+	defer cancel()
+	streamCtx = h.withAuction(c, streamCtx, bidRequest.RequestID)
+
+	// Size the buffer for every seat partners may return, so it never blocks partner goroutines,
+	// and stop sending once the stream ends in case a late call outlives the auction
+	capacity := 0
+	for _, partner := range h.config.Partners {
+		capacity += partner.BidsPerResponse()
+	}
+	bids := make(chan *models.Bid, capacity)
+	done := make(chan streamResult, 1)
+	go func() {
+		response, err := h.auctionService.RunAuctionStream(streamCtx, bidRequest, func(bid *models.Bid) {
+			select {
+			case bids <- bid:
+			case <-streamCtx.Done():
+			}
+		})
+		done <- streamResult{response: response, err: err}
+	}()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	for {
+		select {
+		case bid := <-bids:
+			h.writeStreamEvent(c, streamEventBid, bid)
+		case result := <-done:
+			// Flush bids that arrived before the auction finished
+			for len(bids) > 0 {
+				h.writeStreamEvent(c, streamEventBid, <-bids)
+			}
+			h.finishStream(c, bidRequest, result, startTime)
+			return
+		}
+	}
+}
+
+// finishStream writes the terminal winners or error event and records metrics
+func (h *BidHandler) finishStream(c *gin.Context, request *models.BidRequest, result streamResult, startTime time.Time) {
+	if result.err != nil {
+		_, code, message := auctionErrorInfo(result.err)
+		bidErrors.WithLabelValues(code, "all", transportHTTP, trafficLive).Inc()
+		h.logAuctionError(request, code, result.err)
+		if result.err == services.ErrInsufficientCompetition {
+			h.writeStreamEvent(c, streamEventWinners, insufficientCompetitionResponse(request))
+			return
+		}
+		if fields := userDataFieldErrors(result.err); fields != nil {
+			h.writeStreamEvent(c, streamEventError, gin.H{"code": code, "error": message, "fields": fields})
+			return
+		}
+		h.writeStreamEvent(c, streamEventError, gin.H{"code": code, "error": message})
+		return
+	}
+
+	for _, bid := range result.response.Bids {
+		successfulBids.WithLabelValues(request.Vertical, bid.PartnerID, transportHTTP, trafficLive).Inc()
+	}
+	bidResponseTime.WithLabelValues(request.Vertical, "all", transportHTTP, trafficLive).Observe(time.Since(startTime).Seconds())
+
+	h.writeStreamEvent(c, streamEventWinners, result.response)
+}
+
+// writeStreamEvent writes and flushes a single SSE event
+func (h *BidHandler) writeStreamEvent(c *gin.Context, event string, data interface{}) {
+	c.SSEvent(event, data)
+	c.Writer.Flush()
+}
+
+// bindStreamRequest reads the bid request from the JSON body for POST or query parameters for GET,
+// where user_data is a JSON-encoded object
+func bindStreamRequest(c *gin.Context) (*models.BidRequest, error) {
+	var request models.BidRequest
+	if c.Request.Method == http.MethodPost {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			return nil, err
+		}
+		return &request, nil
+	}
+
+	request.RequestID = c.Query("request_id")
+	request.LeadID = c.Query("lead_id")
+	request.Vertical = c.Query("vertical")
+	if userData := c.Query("user_data"); userData != "" {
+		if err := json.Unmarshal([]byte(userData), &request.UserData); err != nil {
+			return nil, err
+		}
+	}
+	return &request, nil
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// xlsxContentType is the media type of an xlsx workbook
+const xlsxContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// xlsxPart is one file of an xlsx archive
+type xlsxPart struct {
+	name    string
+	content string
+}
+
+// xlsxParts are the fixed parts of a workbook holding the single sheet xlsxWriter streams
+var xlsxParts = []xlsxPart{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// xlsxWriter streams a single-sheet xlsx workbook a row at a time. Strings are written as inline
+// strings and numbers as numeric cells, so no shared string table has to be held in memory.
+type xlsxWriter struct {
+	zip   *zip.Writer
+	sheet io.Writer
+	rows  int
+}
+
+// newXLSXWriter writes the workbook's fixed parts to w and opens its sheet, named sheetName
+func newXLSXWriter(w io.Writer, sheetName string) (*xlsxWriter, error) {
+	archive := zip.NewWriter(w)
+	workbook := xlsxPart{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="` + xmlEscape(sheetName) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`}
+	for _, part := range append(xlsxParts[:len(xlsxParts):len(xlsxParts)], workbook) {
+		file, err := archive.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(file, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := archive.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(sheet, xml.Header+`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`); err != nil {
+		return nil, err
+	}
+	return &xlsxWriter{zip: archive, sheet: sheet}, nil
+}
+
+// WriteRow writes a row of cells and flushes it to the underlying writer. Cells are strings,
+// float64s, or uint64s; nil cells are left empty.
+func (x *xlsxWriter) WriteRow(cells []any) error {
+	x.rows++
+	if _, err := fmt.Fprintf(x.sheet, `<row r="%d">`, x.rows); err != nil {
+		return err
+	}
+	for i, cell := range cells {
+		ref := xlsxColumn(i) + strconv.Itoa(x.rows)
+		var err error
+		switch value := cell.(type) {
+		case nil:
+			continue
+		case string:
+			_, err = fmt.Fprintf(x.sheet, `<c r="%s" t="inlineStr"><is><t>%s</t></is></c>`, ref, xmlEscape(value))
+		case float64:
+			_, err = fmt.Fprintf(x.sheet, `<c r="%s"><v>%s</v></c>`, ref, strconv.FormatFloat(value, 'f', -1, 64))
+		case uint64:
+			_, err = fmt.Fprintf(x.sheet, `<c r="%s"><v>%d</v></c>`, ref, value)
+		default:
+			err = fmt.Errorf("unsupported xlsx cell type %T", cell)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if _, err := io.WriteString(x.sheet, `</row>`); err != nil {
+		return err
+	}
+	return x.zip.Flush()
+}
+
+// Close ends the sheet and writes the archive's central directory
+func (x *xlsxWriter) Close() error {
+	if _, err := io.WriteString(x.sheet, `</sheetData></worksheet>`); err != nil {
+		return err
+	}
+	return x.zip.Close()
+}
+
+// xlsxColumn returns the letters naming a zero-based column: A to Z, then AA, AB, and so on
+func xlsxColumn(index int) string {
+	name := ""
+	for index++; index > 0; index = (index - 1) / 26 {
+		name = string(rune('A'+(index-1)%26)) + name
+	}
+	return name
+}
+
+// xmlEscape escapes text for XML character data and attribute values
+func xmlEscape(text string) string {
+	var escaped strings.Builder
+	xml.EscapeText(&escaped, []byte(text))
+	return escaped.String()
+}
@@ -0,0 +1,349 @@
+// Package main provides the entry point for the RTB service
+// Version: 1.0.0
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin" // v1.9.1
+	"go.uber.org/zap"          // v1.24.0
+	"google.golang.org/grpc"   // v1.59.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/rtbpb"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// Build information injected via ldflags
+var (
+	Version   = "dev"
+	BuildDate = "unknown"
+	GitCommit = "unknown"
+)
+
+const (
+	defaultConfigPath = "/config/config.yaml"
+	shutdownTimeout   = 15 * time.Second
+)
+
+func main() {
+	configPath := flag.String("config", defaultConfigPath, "path to the service configuration file")
+	validateOnly := flag.Bool("validate-config", false, "validate and lint the configuration file, print findings, and exit")
+	flag.Parse()
+
+	if *validateOnly {
+		os.Exit(lintConfig(*configPath))
+	}
+
+	command := "serve"
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+
+	switch command {
+	case "serve":
+		if err := serve(*configPath); err != nil {
+			fmt.Fprintf(os.Stderr, "rtb-service: %v\n", err)
+			os.Exit(1)
+		}
+	case "health":
+		os.Exit(probe())
+	default:
+		fmt.Fprintf(os.Stderr, "rtb-service: unknown command %q\n", command)
+		os.Exit(2)
+	}
+}
+
+// lintConfig loads the configuration file without starting the service and prints its lint
+// findings as JSON lines, returning a non-zero exit status when any finding is an error
+func lintConfig(configPath string) int {
+	var findings []config.Finding
+	cfg, err := config.ReadConfig(configPath)
+	if err != nil {
+		findings = []config.Finding{{Severity: config.LintError, Code: config.LintInvalidConfig, Message: err.Error()}}
+	} else {
+		findings = config.Lint(cfg, time.Now())
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, finding := range findings {
+		encoder.Encode(finding)
+	}
+	if config.HasLintErrors(findings) {
+		return 1
+	}
+	return 0
+}
+
+// serve loads configuration and runs the HTTP server until a shutdown signal is received
+func serve(configPath string) error {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		return fmt.Errorf("error creating logger: %w", err)
+	}
+	defer logger.Sync()
+
+	cfg, err := config.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	auctionService, err := services.NewAuctionService(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating auction service: %w", err)
+	}
+	auctionService.SetLogger(logger)
+	if cfg.FaultInjection.Guarded() {
+		logger.Warn("fault injection is enabled but disabled in production",
+			zap.String("environment", os.Getenv(config.EnvironmentVar)))
+	}
+	defer func() {
+		if err := auctionService.Close(); err != nil {
+			logger.Error("error closing auction service", zap.Error(err))
+		}
+	}()
+
+	bidHandler, err := handlers.NewBidHandler(auctionService, cfg)
+	if err != nil {
+		return fmt.Errorf("error creating bid handler: %w", err)
+	}
+	bidHandler.SetLogger(logger)
+
+	adminHandler, err := handlers.NewAdminHandler(auctionService, cfg, handlers.BuildInfo{
+		Version:   Version,
+		Commit:    GitCommit,
+		BuildDate: BuildDate,
+	})
+	if err != nil {
+		return fmt.Errorf("error creating admin handler: %w", err)
+	}
+
+	selfTest, err := handlers.NewSelfTest(cfg)
+	if err != nil {
+		return fmt.Errorf("error creating self-test: %w", err)
+	}
+	selfTest.SetLogger(logger)
+	bidHandler.SetSelfTest(selfTest)
+	adminHandler.SetSelfTest(selfTest)
+
+	canary, err := handlers.NewCanary(auctionService, cfg)
+	if err != nil {
+		return fmt.Errorf("error creating canary: %w", err)
+	}
+	canary.SetLogger(logger)
+	bidHandler.SetCanary(canary)
+
+	// Warm partner connections before serving so the first auctions skip connection setup and the
+	// first readiness report carries the results; the warmup timeout bounds how long this can take
+	for partnerID, result := range auctionService.WarmupPartners(context.Background()) {
+		logger.Info("partner warmup",
+			zap.String("partner", partnerID),
+			zap.Bool("success", result.Success),
+			zap.String("method", result.Method),
+			zap.Int("connections", result.Connections),
+			zap.Float64("handshake_ms", result.HandshakeMs),
+			zap.String("error", result.Error))
+	}
+
+	// Run the startup self-test before serving so readiness reflects it from the first probe;
+	// a failure keeps the service unready rather than stopping it
+	if selfTest.Enabled() {
+		selfTest.Run(context.Background())
+	}
+
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Port),
+		Handler: setupRouter(cfg, bidHandler, adminHandler),
+	}
+
+	errChan := make(chan error, 2)
+
+	// Serve gRPC alongside HTTP when a gRPC port is configured
+	var grpcServer *grpc.Server
+	if cfg.GRPCPort > 0 {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GRPCPort))
+		if err != nil {
+			return fmt.Errorf("error listening on gRPC port: %w", err)
+		}
+		grpcServer = grpc.NewServer()
+		rtbpb.RegisterRTBServiceServer(grpcServer, bidHandler.NewGRPCServer())
+		go func() {
+			logger.Info("starting gRPC server", zap.Int("port", cfg.GRPCPort))
+			if err := grpcServer.Serve(listener); err != nil {
+				errChan <- err
+			}
+		}()
+	}
+
+	go func() {
+		logger.Info("starting RTB service",
+			zap.String("version", Version),
+			zap.String("commit", GitCommit),
+			zap.Int("port", cfg.Port))
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			errChan <- err
+		}
+	}()
+
+	// Reload hot-reloadable settings from the config file until shutdown
+	reloadCtx, stopReload := context.WithCancel(context.Background())
+	defer stopReload()
+	go reloadConfig(reloadCtx, configPath, cfg.ConfigReloadInterval, auctionService, logger)
+
+	// Run canary auctions until shutdown so alerting notices a broken auction path
+	canaryCtx, stopCanary := context.WithCancel(context.Background())
+	defer stopCanary()
+	go func() {
+		if err := canary.Run(canaryCtx); err != nil {
+			logger.Error("canary stopped", zap.Error(err))
+		}
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-errChan:
+		return err
+	case sig := <-signals:
+		logger.Info("shutting down RTB service", zap.String("signal", sig.String()))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	if grpcServer != nil {
+		go func() {
+			<-ctx.Done()
+			grpcServer.Stop()
+		}()
+		grpcServer.GracefulStop()
+	}
+
+	return server.Shutdown(ctx)
+}
+
+// reloadConfig re-reads the config file every interval and applies the settings that can change
+// while serving, currently the UserData schemas, partner TLS certificates, and partner drains. An
+// invalid file keeps the running settings; a valid one that changed is recorded in the config
+// change history.
+func reloadConfig(ctx context.Context, configPath string, interval time.Duration, auctionService *services.AuctionService, logger *zap.Logger) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cfg, err := config.LoadConfig(configPath)
+		if err != nil {
+			logger.Warn("config reload failed", zap.Error(err))
+			continue
+		}
+		if err := auctionService.SetUserDataSchemas(cfg.UserDataSchemas); err != nil {
+			logger.Warn("user data schema reload failed", zap.Error(err))
+		}
+		if err := auctionService.SetBidRules(cfg.Rules); err != nil {
+			logger.Warn("bid rules reload failed", zap.Error(err))
+		}
+		if err := auctionService.SetPartnerTLS(cfg.Partners); err != nil {
+			logger.Warn("partner TLS reload failed", zap.Error(err))
+		}
+		if err := auctionService.SetVerticals(cfg); err != nil {
+			logger.Warn("vertical settings reload failed", zap.Error(err))
+		}
+		auctionService.SetPartnerDrains(cfg.Partners)
+		auctionService.RecordConfigReload(cfg, services.ConfigSourceFile)
+	}
+}
+
+// setupRouter registers all HTTP routes for the service
+func setupRouter(cfg *config.Config, bidHandler *handlers.BidHandler, adminHandler *handlers.AdminHandler) *gin.Engine {
+	router := gin.New()
+	router.Use(gin.Recovery())
+	router.Use(handlers.RequestIDMiddleware())
+	router.Use(handlers.CompressionMiddleware(cfg.Compression))
+
+	router.GET("/healthz", bidHandler.HandleLiveness)
+	router.GET("/readyz", bidHandler.HandleReadiness)
+	router.GET("/health", bidHandler.HandleHealthCheck)
+	router.GET("/metrics", gin.WrapH(handlers.MetricsHandler(cfg.ServiceRegion)))
+	router.GET("/openapi.json", bidHandler.HandleOpenAPI)
+
+	replays := bidHandler.ReplayProtection()
+	v1 := router.Group("/v1")
+	v1.POST("/bids", replays, bidHandler.HandleBidRequest)
+	v1.POST("/bids/batch", replays, bidHandler.HandleBatchBidRequest)
+	v1.POST("/bids/dryrun", replays, bidHandler.HandleDryRunBidRequest)
+	if cfg.Reservations != nil && cfg.Reservations.Enabled {
+		v1.POST("/bids/reserve", replays, bidHandler.HandleReserveRequest)
+		v1.POST("/bids/confirm/:token", bidHandler.HandleConfirmReservation)
+	}
+	v1.GET("/bids/stream", bidHandler.HandleBidStream)
+	v1.POST("/bids/stream", replays, bidHandler.HandleBidStream)
+	v1.GET("/partners/export", bidHandler.HandlePartnerExport)
+	v1.GET("/partners/:id/report", bidHandler.HandlePartnerReport)
+	if cfg.MaxPartnersPerAuction > 0 {
+		v1.GET("/partner-selection/traffic", bidHandler.HandlePartnerTrafficMix)
+	}
+	v1.GET("/schemas/:vertical", bidHandler.HandleUserDataSchema)
+	v1.GET("/reasons", bidHandler.HandleReasons)
+	if cfg.Analytics != nil && cfg.Analytics.Enabled {
+		v1.GET("/analytics/prices", bidHandler.HandlePriceAnalytics)
+		v1.GET("/analytics/floors", bidHandler.HandleAdaptiveFloors)
+	}
+	if cfg.Estimates != nil && cfg.Estimates.Enabled {
+		v1.GET("/estimates", bidHandler.HandleEstimate)
+	}
+	if cfg.Audit != nil && cfg.Audit.Enabled {
+		v1.GET("/reports/partner-spend", bidHandler.HandlePartnerSpend)
+		v1.POST("/reports/reconcile", bidHandler.HandleReconcile)
+	}
+
+	v2 := router.Group("/v2")
+	v2.POST("/bids", replays, bidHandler.HandleBidRequestV2)
+
+	if adminHandler.Enabled() {
+		adminHandler.RegisterRoutes(router.Group("/admin"))
+	}
+
+	return router
+}
+
+// probe performs a liveness check against the local server for container health checks
+func probe() int {
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://127.0.0.1:%s/healthz", port))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "health probe failed: %v\n", err)
+		return 1
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		fmt.Fprintf(os.Stderr, "health probe returned %d\n", resp.StatusCode)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,241 @@
+package models
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// ClientIdentity is who asked for an auction: the caller's address and user agent, the transport
+// the request came in on, and whether it authenticated as an admin. Header is set only for
+// auctions screened for invalid traffic.
+type ClientIdentity struct {
+	IP        string
+	UserAgent string
+	Transport string
+	Admin     bool
+	Header    http.Header
+}
+
+// AuctionContext is the state of one auction that travels with it from the handler that starts
+// it through bid collection, the optimizer, and partner adapters. It is created once per auction
+// and carried in its context.Context; the With methods return changed copies, so an auction
+// context is never modified once it is shared. Copies share the auction's reason collector.
+//
+// Every accessor is safe on a nil AuctionContext and returns the zero value.
+type AuctionContext struct {
+	requestID   string
+	request     *BidRequest
+	client      ClientIdentity
+	experiments []ExperimentAssignment
+	deadline    time.Time
+	debug       *DebugInfo
+	reasons     *ReasonCollector
+	dryRun      *DryRun
+	recording   *Recording
+	replay      *Replay
+	override    *Override
+	params      *AuctionParams
+	batchItem   bool
+	reservation bool
+	synthetic   bool
+	canary      bool
+}
+
+// NewAuctionContext starts the context of an auction with its own reason collector
+func NewAuctionContext(requestID string) *AuctionContext {
+	return &AuctionContext{requestID: requestID, reasons: NewReasonCollector(nil)}
+}
+
+// ContextWithAuction returns a copy of ctx carrying auction
+func ContextWithAuction(ctx context.Context, auction *AuctionContext) context.Context {
+	return context.WithValue(ctx, auctionKey, auction)
+}
+
+// AuctionFromContext returns the auction context carried by ctx, or nil when there is none
+func AuctionFromContext(ctx context.Context) *AuctionContext {
+	auction, _ := ctx.Value(auctionKey).(*AuctionContext)
+	return auction
+}
+
+// ReasonsFromContext returns the reason collector of the auction carried by ctx, or nil when
+// there is none; recording into a nil collector does nothing
+func ReasonsFromContext(ctx context.Context) *ReasonCollector {
+	return AuctionFromContext(ctx).Reasons()
+}
+
+// RequestID returns the auction's request ID
+func (a *AuctionContext) RequestID() string {
+	if a == nil {
+		return ""
+	}
+	return a.requestID
+}
+
+// Request returns the bid request the auction runs, once the auction has started
+func (a *AuctionContext) Request() *BidRequest {
+	if a == nil {
+		return nil
+	}
+	return a.request
+}
+
+// Client returns who asked for the auction
+func (a *AuctionContext) Client() ClientIdentity {
+	if a == nil {
+		return ClientIdentity{}
+	}
+	return a.client
+}
+
+// Experiments returns the experiment variants the auction runs in
+func (a *AuctionContext) Experiments() []ExperimentAssignment {
+	if a == nil {
+		return nil
+	}
+	return a.experiments
+}
+
+// Deadline returns when the auction must have answered, and false when it has no deadline
+func (a *AuctionContext) Deadline() (time.Time, bool) {
+	if a == nil || a.deadline.IsZero() {
+		return time.Time{}, false
+	}
+	return a.deadline, true
+}
+
+// Remaining returns the time left until the auction's deadline at now, never negative, and false
+// when the auction has no deadline
+func (a *AuctionContext) Remaining(now time.Time) (time.Duration, bool) {
+	deadline, ok := a.Deadline()
+	if !ok {
+		return 0, false
+	}
+	if remaining := deadline.Sub(now); remaining > 0 {
+		return remaining, true
+	}
+	return 0, true
+}
+
+// Debug returns the auction's debug recorder, or nil when debug output was not requested
+func (a *AuctionContext) Debug() *DebugInfo {
+	if a == nil {
+		return nil
+	}
+	return a.debug
+}
+
+// IsDebug reports whether debug output was requested for the auction
+func (a *AuctionContext) IsDebug() bool {
+	return a.Debug() != nil
+}
+
+// Reasons returns the collector of the auction's skip, selection, no-bid, and loss reasons
+func (a *AuctionContext) Reasons() *ReasonCollector {
+	if a == nil {
+		return nil
+	}
+	return a.reasons
+}
+
+// DryRun returns the auction's dry run recorder, or nil for a live auction
+func (a *AuctionContext) DryRun() *DryRun {
+	if a == nil {
+		return nil
+	}
+	return a.dryRun
+}
+
+// Recording returns the recording capturing the auction's partner responses, or nil
+func (a *AuctionContext) Recording() *Recording {
+	if a == nil {
+		return nil
+	}
+	return a.recording
+}
+
+// Replay returns the replay supplying the auction's partner responses, or nil for live partner calls
+func (a *AuctionContext) Replay() *Replay {
+	if a == nil {
+		return nil
+	}
+	return a.replay
+}
+
+// Override returns the admin caller's per-request override, or nil
+func (a *AuctionContext) Override() *Override {
+	if a == nil {
+		return nil
+	}
+	return a.override
+}
+
+// AuctionParams returns the admin caller's forced auction parameters, or nil
+func (a *AuctionContext) AuctionParams() *AuctionParams {
+	if a == nil {
+		return nil
+	}
+	return a.params
+}
+
+// IsBatchItem reports whether the auction runs inside a batch
+func (a *AuctionContext) IsBatchItem() bool {
+	return a != nil && a.batchItem
+}
+
+// IsReservation reports whether the auction's winners are reserved rather than settled
+func (a *AuctionContext) IsReservation() bool {
+	return a != nil && a.reservation
+}
+
+// IsSynthetic reports whether the auction is synthetic, such as the self-test
+func (a *AuctionContext) IsSynthetic() bool {
+	return a != nil && a.synthetic
+}
+
+// IsCanary reports whether the auction is a canary
+func (a *AuctionContext) IsCanary() bool {
+	return a != nil && a.canary
+}
+
+// WithRequest returns a copy running request
+func (a *AuctionContext) WithRequest(request *BidRequest) *AuctionContext {
+	return a.with(func(c *AuctionContext) { c.request = request })
+}
+
+// WithClient returns a copy identifying who asked for the auction
+func (a *AuctionContext) WithClient(client ClientIdentity) *AuctionContext {
+	return a.with(func(c *AuctionContext) { c.client = client })
+}
+
+// WithExperiments returns a copy running in the given experiment variants
+func (a *AuctionContext) WithExperiments(experiments []ExperimentAssignment) *AuctionContext {
+	return a.with(func(c *AuctionContext) { c.experiments = experiments })
+}
+
+// WithDeadline returns a copy that must answer by deadline
+func (a *AuctionContext) WithDeadline(deadline time.Time) *AuctionContext {
+	return a.with(func(c *AuctionContext) { c.deadline = deadline })
+}
+
+// WithDebug returns a copy recording its decisions, and the reasons collected from then on, into
+// debug. Set it before the auction records any reason.
+func (a *AuctionContext) WithDebug(debug *DebugInfo) *AuctionContext {
+	return a.with(func(c *AuctionContext) {
+		c.debug = debug
+		c.reasons = c.reasons.withDebug(debug)
+	})
+}
+
+// with returns a copy of the auction context changed by fn, starting a new one when a is nil
+func (a *AuctionContext) with(fn func(*AuctionContext)) *AuctionContext {
+	var copied AuctionContext
+	if a != nil {
+		copied = *a
+	}
+	if copied.reasons == nil {
+		copied.reasons = NewReasonCollector(copied.debug)
+	}
+	fn(&copied)
+	return &copied
+}
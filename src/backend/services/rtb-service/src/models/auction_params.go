@@ -0,0 +1,20 @@
+package models
+
+// AuctionTypeFirstPrice is the only auction type the service runs: winners pay their own bid
+const AuctionTypeFirstPrice = "first_price"
+
+// ManualOverride tags auctions run with forced parameters in place of an experiment assignment,
+// so they stay out of experiment results
+const ManualOverride = "manual_override"
+
+// AuctionParams are auction parameters an admin caller forces on a single auction, for example to
+// reproduce an issue. Unset fields keep the configured values.
+type AuctionParams struct {
+	AuctionType   string   `json:"auction_type,omitempty"`
+	QualityWeight *float64 `json:"quality_weight,omitempty"`
+	Strategy      string   `json:"strategy,omitempty"`
+	Floor         float64  `json:"floor,omitempty"`
+}
+
+// ManualOverrideAssignment is the assignment reported for auctions run with forced parameters
+var ManualOverrideAssignment = ExperimentAssignment{ID: ManualOverride, Variant: ManualOverride}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// BatchItemError describes why a single item in a batch auction failed
+type BatchItemError struct {
+	Code    string       `json:"code"`
+	Message string       `json:"message"`
+	Fields  []FieldError `json:"fields,omitempty"`
+}
+
+// BatchItemResult carries either the auction response or a structured error for one batch item
+type BatchItemResult struct {
+	Index     int             `json:"index"`
+	RequestID string          `json:"request_id"`
+	Response  *BidResponse    `json:"response,omitempty"`
+	Error     *BatchItemError `json:"error,omitempty"`
+}
+
+// BatchBidResponse represents the results of a batch auction in request order
+type BatchBidResponse struct {
+	Results        []*BatchItemResult `json:"results"`
+	Succeeded      int                `json:"succeeded"`
+	Failed         int                `json:"failed"`
+	Timestamp      time.Time          `json:"timestamp"`
+	ProcessingTime time.Duration      `json:"processing_time"`
+}
@@ -6,6 +6,8 @@ import (
 	"encoding/json" // v1.21
 	"errors"        // v1.21
 	"time"         // v1.21
+
+	"github.com/yourdomain/rtb-service/src/config"
 )
 
 // Error definitions for bid validation
@@ -16,6 +18,8 @@ var (
 	ErrMissingClickURL    = errors.New("click URL is required")
 	ErrInvalidQualityScore = errors.New("quality score must be between 0 and 1")
 	ErrExpiredBid         = errors.New("bid has expired")
+	ErrInvalidInput       = errors.New("invalid input parameters")
+	ErrInvalidRevShare    = errors.New("revenue share must be at most 100 percent")
 )
 
 // Constants for bid processing
@@ -33,6 +37,39 @@ type Bid struct {
 	QualityScore float64                `json:"quality_score"`
 	ExpiresAt    time.Time             `json:"expires_at"`
 	Creative     map[string]interface{} `json:"creative,omitempty"`
+	AdvertiserDomains []string          `json:"adomain,omitempty"`
+	DealID       string                 `json:"deal_id,omitempty"`
+	// PricingModel and NormalizedPrice are set from the partner's config: Price stays in the
+	// partner's own model while NormalizedPrice is its expected cost per lead
+	PricingModel    string              `json:"pricing_model,omitempty"`
+	NormalizedPrice float64             `json:"normalized_price,omitempty"`
+	// QualityAcknowledged is echoed by partners that used the request's lead quality signals
+	QualityAcknowledged bool            `json:"quality_acknowledged,omitempty"`
+	// VerticalExt carries vertical-specific bid fields, such as a health plan's metal tier, checked
+	// against the vertical's configured schema and otherwise passed through untouched
+	VerticalExt  json.RawMessage        `json:"vertical_ext,omitempty"`
+	// PayloadExperiment is the variant of the partner's payload experiment the bid answered
+	PayloadExperiment *ExperimentAssignment `json:"payload_experiment,omitempty"`
+	// BidPrice keeps the partner's own price when a fixed-price deal replaced Price
+	BidPrice     float64                `json:"-"`
+}
+
+// CPL returns the bid's price as a cost per lead, which is what auctions compare. Revenue-share
+// bids are only comparable once normalized; until then their CPL is zero.
+func (b *Bid) CPL() float64 {
+	if b.PricingModel == config.PricingModelRevShare {
+		return b.NormalizedPrice
+	}
+	return b.Price
+}
+
+// CreativeImageURL is the creative field holding the URL of the image quote cards render
+const CreativeImageURL = "image_url"
+
+// ImageURL returns the creative's image URL, or "" when it has none
+func (b *Bid) ImageURL() string {
+	url, _ := b.Creative[CreativeImageURL].(string)
+	return url
 }
 
 // BidRequest represents a request for bids from RTB partners with timeout and user targeting support
@@ -43,6 +80,15 @@ type BidRequest struct {
 	UserData   map[string]interface{} `json:"user_data,omitempty"`
 	Timeout    time.Duration          `json:"timeout"`
 	Timestamp  time.Time              `json:"timestamp"`
+	Consent    *Consent               `json:"consent,omitempty"`
+	Geo        *Geo                   `json:"geo,omitempty"`
+	Device     *Device                `json:"device,omitempty"`
+	LeadQuality *LeadQuality          `json:"lead_quality,omitempty"`
+	// AuctionParams forces auction parameters for this request; only admin callers may set it
+	AuctionParams *AuctionParams      `json:"auction_params,omitempty"`
+	// Constraints are requirements on the winner set; constraints the auction cannot meet are
+	// reported in the response summary
+	Constraints []RankingConstraint   `json:"constraints,omitempty"`
 }
 
 // BidResponse represents the response containing collected bids with timing information
@@ -50,9 +96,74 @@ type BidResponse struct {
 	RequestID      string        `json:"request_id"`
 	Bids          []*Bid        `json:"bids"`
 	Timestamp     time.Time     `json:"timestamp"`
+	// ValidUntil is when the earliest winning bid expires; caches must not serve the response after it
+	ValidUntil    *time.Time    `json:"valid_until,omitempty"`
 	ProcessingTime time.Duration `json:"processing_time"`
+	CollectionTime time.Duration `json:"collection_time"`
+	OptimizationTime time.Duration `json:"optimization_time"`
+	Summary        *AuctionSummary `json:"summary,omitempty"`
+	Experiments    []ExperimentAssignment `json:"experiments,omitempty"`
+	Debug          *DebugInfo    `json:"debug,omitempty"`
+	DryRun         *DryRun       `json:"dry_run,omitempty"`
+	Reason         Reason        `json:"reason,omitempty"`
+	// HouseOffer marks a response carrying the vertical's house offer in place of partner bids
+	HouseOffer     bool          `json:"house_offer,omitempty"`
+	// Locale is the caller's language the winning bids' display fields were localized for
+	Locale         string        `json:"locale,omitempty"`
+	// Floor is the adaptive floor the auction applied; callers only see it in debug output
+	Floor          *AuctionFloor `json:"-"`
+	// AuctionSeq orders the auction for reconciliation, when auction sequence numbers are enabled
+	AuctionSeq     *AuctionSeq   `json:"auction_seq,omitempty"`
+	// Guidance is the bid guidance of partners sharing it, sent only in their webhooks
+	Guidance       []BidGuidance `json:"-"`
 }
 
+// BidGuidance is the price a partner's bid needed in an auction, in the partner's own pricing
+// model. A winning bid gets MinToWin, the price at which it would have tied the best competing
+// bid, and unset when no other partner's bid competed. A losing partner's best bid gets
+// PriceToBeat, the price at which it would have tied the lowest winner.
+type BidGuidance struct {
+	PartnerID    string
+	BidID        string
+	PricingModel string
+	Won          bool
+	MinToWin     *float64
+	PriceToBeat  *float64
+}
+
+// ExperimentAssignment is the variant of an experiment an auction ran in
+type ExperimentAssignment struct {
+	ID      string `json:"id"`
+	Variant string `json:"variant"`
+}
+
+// AuctionSeq orders an auction among every auction with an outcome. Seq is the auction's number
+// from the shared counter. When the counter cannot be reached Seq is zero, and ULID orders the
+// auction by time instead, without the counter's gap detection.
+type AuctionSeq struct {
+	Seq  int64  `json:"seq,omitempty"`
+	ULID string `json:"ulid,omitempty"`
+}
+
+// AuctionSummary counts how partners took part in an auction. Partners skipped before the call,
+// such as by their schedule or an open circuit breaker, are not contacted. PartnersBid counts
+// partners that returned at least one bid, and ValidBids the bids that passed validation, whether
+// or not they went on to win.
+type AuctionSummary struct {
+	PartnersContacted int `json:"partners_contacted"`
+	PartnersBid       int `json:"partners_bid"`
+	ValidBids         int `json:"valid_bids"`
+	Winners           int `json:"winners"`
+	TimedOutPartners  int `json:"timed_out_partners"`
+	// EarlyTerminatedPartners are the partner calls cancelled once the winner was decided without them
+	EarlyTerminatedPartners int `json:"early_terminated_partners,omitempty"`
+	// UnsatisfiedConstraints are the request's ranking constraints no winner set could meet
+	UnsatisfiedConstraints []RankingConstraint `json:"unsatisfied_constraints,omitempty"`
+}
+
+// HouseOfferPartnerID is the partner ID of house offer bids, which no partner placed
+const HouseOfferPartnerID = "house"
+
 // ValidateBid validates a bid object ensuring all required fields are present and valid
 func ValidateBid(bid *Bid) error {
 	if bid == nil {
@@ -71,6 +182,10 @@ func ValidateBid(bid *Bid) error {
 		return ErrInvalidBidPrice
 	}
 
+	if bid.PricingModel == config.PricingModelRevShare && bid.Price > 100 {
+		return ErrInvalidRevShare
+	}
+
 	if bid.ClickURL == "" {
 		return ErrMissingClickURL
 	}
@@ -88,6 +203,9 @@ func ValidateBid(bid *Bid) error {
 		if _, err := json.Marshal(bid.Creative); err != nil {
 			return errors.New("invalid creative format")
 		}
+		if err := validateLocalizedCreative(bid.Creative); err != nil {
+			return err
+		}
 	}
 
 	return nil
@@ -107,8 +225,8 @@ func CompareBids(a, b *Bid) int {
 	}
 
 	// Calculate effective prices incorporating quality scores
-	effectivePriceA := a.Price * (1 + QualityScoreWeight*a.QualityScore)
-	effectivePriceB := b.Price * (1 + QualityScoreWeight*b.QualityScore)
+	effectivePriceA := a.CPL() * (1 + QualityScoreWeight*a.QualityScore)
+	effectivePriceB := b.CPL() * (1 + QualityScoreWeight*b.QualityScore)
 
 	if effectivePriceA < effectivePriceB {
 		return -1
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// CurrencyUSD is the currency of every price the service handles
+const CurrencyUSD = "USD"
+
+// Money is an amount split into whole units and nanos, matching the gRPC Money message, so
+// prices cross JSON without float rounding
+type Money struct {
+	CurrencyCode string `json:"currency_code"`
+	Units        int64  `json:"units"`
+	Nanos        int32  `json:"nanos"`
+}
+
+// NewMoney returns amount in US dollars as Money
+func NewMoney(amount float64) Money {
+	nanos := int64(math.Round(amount * 1e9))
+	return Money{CurrencyCode: CurrencyUSD, Units: nanos / 1e9, Nanos: int32(nanos % 1e9)}
+}
+
+// BidResponseV2 is the v2 API shape of a bid response. Prices are Money, each bid carries its
+// clearing price, and participation and timing are grouped in a summary that is always present.
+type BidResponseV2 struct {
+	RequestID   string                 `json:"request_id"`
+	Timestamp   time.Time              `json:"timestamp"`
+	ValidUntil  *time.Time             `json:"valid_until,omitempty"`
+	Bids        []BidV2                `json:"bids"`
+	Summary     ResponseSummary        `json:"summary"`
+	Experiments []ExperimentAssignment `json:"experiments,omitempty"`
+	Debug       *DebugInfo             `json:"debug,omitempty"`
+	DryRun      *DryRun                `json:"dry_run,omitempty"`
+	Reason      Reason                 `json:"reason,omitempty"`
+	HouseOffer  bool                   `json:"house_offer,omitempty"`
+	Locale      string                 `json:"locale,omitempty"`
+	AuctionSeq  *AuctionSeq            `json:"auction_seq,omitempty"`
+}
+
+// BidV2 is the v2 API shape of a winning bid. ClearingPrice is what the lead sells for, as a
+// cost per lead; BidPrice is the partner's own bid in its pricing model, a percentage for
+// revenue-share partners, and before any fixed-price deal replaced it.
+type BidV2 struct {
+	ID                string                 `json:"id"`
+	PartnerID         string                 `json:"partner_id"`
+	ClearingPrice     Money                  `json:"clearing_price"`
+	BidPrice          float64                `json:"bid_price"`
+	PricingModel      string                 `json:"pricing_model"`
+	DealID            string                 `json:"deal_id,omitempty"`
+	ClickURL          string                 `json:"click_url"`
+	QualityScore      float64                `json:"quality_score"`
+	ExpiresAt         time.Time              `json:"expires_at"`
+	Creative          map[string]interface{} `json:"creative,omitempty"`
+	AdvertiserDomains []string               `json:"adomain,omitempty"`
+	VerticalExt       json.RawMessage        `json:"vertical_ext,omitempty"`
+	PayloadExperiment *ExperimentAssignment  `json:"payload_experiment,omitempty"`
+}
+
+// ResponseSummary is the auction's participation counts and phase timings
+type ResponseSummary struct {
+	AuctionSummary
+	Timing ResponseTiming `json:"timing"`
+}
+
+// ResponseTiming is the time an auction spent in each phase, in milliseconds
+type ResponseTiming struct {
+	ProcessingMs   float64 `json:"processing_ms"`
+	CollectionMs   float64 `json:"collection_ms"`
+	OptimizationMs float64 `json:"optimization_ms"`
+}
+
+// NewBidResponseV2 returns the v2 shape of a bid response
+func NewBidResponseV2(r *BidResponse) *BidResponseV2 {
+	response := &BidResponseV2{
+		RequestID:   r.RequestID,
+		Timestamp:   r.Timestamp,
+		ValidUntil:  r.ValidUntil,
+		Bids:        make([]BidV2, 0, len(r.Bids)),
+		Experiments: r.Experiments,
+		Debug:       r.Debug,
+		DryRun:      r.DryRun,
+		Reason:      r.Reason,
+		HouseOffer:  r.HouseOffer,
+		Locale:      r.Locale,
+		AuctionSeq:  r.AuctionSeq,
+		Summary: ResponseSummary{Timing: ResponseTiming{
+			ProcessingMs:   milliseconds(r.ProcessingTime),
+			CollectionMs:   milliseconds(r.CollectionTime),
+			OptimizationMs: milliseconds(r.OptimizationTime),
+		}},
+	}
+	if r.Summary != nil {
+		response.Summary.AuctionSummary = *r.Summary
+	}
+	for _, bid := range r.Bids {
+		if bid != nil {
+			response.Bids = append(response.Bids, newBidV2(bid))
+		}
+	}
+	return response
+}
+
+// newBidV2 returns the v2 shape of a winning bid
+func newBidV2(b *Bid) BidV2 {
+	bidPrice := b.Price
+	if b.BidPrice > 0 {
+		bidPrice = b.BidPrice
+	}
+	pricingModel := b.PricingModel
+	if pricingModel == "" {
+		pricingModel = config.PricingModelCPL
+	}
+	return BidV2{
+		ID:                b.ID,
+		PartnerID:         b.PartnerID,
+		ClearingPrice:     NewMoney(b.CPL()),
+		BidPrice:          bidPrice,
+		PricingModel:      pricingModel,
+		DealID:            b.DealID,
+		ClickURL:          b.ClickURL,
+		QualityScore:      b.QualityScore,
+		ExpiresAt:         b.ExpiresAt,
+		Creative:          b.Creative,
+		AdvertiserDomains: b.AdvertiserDomains,
+		VerticalExt:       b.VerticalExt,
+		PayloadExperiment: b.PayloadExperiment,
+	}
+}
+
+// milliseconds returns d in fractional milliseconds
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	gojson "github.com/goccy/go-json" // v0.10.2
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// Codec encodes and decodes bid requests and responses. Every codec must produce the same
+// bytes as encoding/json for our types, so the choice only affects speed.
+type Codec interface {
+	// Name returns the codec's config name
+	Name() string
+	// Marshal returns the JSON encoding of v
+	Marshal(v interface{}) ([]byte, error)
+	// Unmarshal decodes JSON data into v
+	Unmarshal(data []byte, v interface{}) error
+	// Encode writes the JSON encoding of v to w followed by a newline, like json.Encoder
+	Encode(w io.Writer, v interface{}) error
+	// Decode reads one JSON value from r into v, like json.Decoder
+	Decode(r io.Reader, v interface{}) error
+}
+
+// NewCodec returns the codec for a config name, defaulting to encoding/json when name is empty
+func NewCodec(name string) (Codec, error) {
+	switch name {
+	case "", config.JSONCodecStd:
+		return stdCodec{}, nil
+	case config.JSONCodecFast:
+		return fastCodec{}, nil
+	default:
+		return nil, fmt.Errorf("unknown json codec %q", name)
+	}
+}
+
+// stdCodec uses encoding/json
+type stdCodec struct{}
+
+func (stdCodec) Name() string { return config.JSONCodecStd }
+
+func (stdCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+func (stdCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+func (stdCodec) Encode(w io.Writer, v interface{}) error { return json.NewEncoder(w).Encode(v) }
+
+func (stdCodec) Decode(r io.Reader, v interface{}) error { return json.NewDecoder(r).Decode(v) }
+
+// fastCodec encodes bid types with the field-by-field encoders in codec_encode.go and decodes
+// with github.com/goccy/go-json, which compiles a decoder per type instead of reflecting on
+// every call. Other types fall back to encoding/json.
+type fastCodec struct{}
+
+func (fastCodec) Name() string { return config.JSONCodecFast }
+
+func (fastCodec) Marshal(v interface{}) ([]byte, error) {
+	return appendJSON(nil, v)
+}
+
+func (fastCodec) Unmarshal(data []byte, v interface{}) error { return gojson.Unmarshal(data, v) }
+
+func (fastCodec) Encode(w io.Writer, v interface{}) error {
+	// Appending into a buffer's spare capacity avoids allocating the encoding separately
+	var dst []byte
+	if buffer, ok := w.(*bytes.Buffer); ok {
+		dst = buffer.AvailableBuffer()
+	}
+	dst, err := appendJSON(dst, v)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(dst, '\n'))
+	return err
+}
+
+func (fastCodec) Decode(r io.Reader, v interface{}) error { return gojson.NewDecoder(r).Decode(v) }
+
+// appendJSON appends the JSON encoding of v, using the bid type encoders where they apply
+func appendJSON(dst []byte, v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case *BidResponse:
+		return appendBidResponse(dst, value, AllBidFields)
+	case *ShapedResponse:
+		return appendBidResponse(dst, value.Response, value.Fields)
+	case *BidRequest:
+		return appendBidRequest(dst, value)
+	case *Bid:
+		return appendBid(dst, value, AllBidFields)
+	default:
+		return appendValue(dst, v)
+	}
+}
@@ -0,0 +1,334 @@
+package models
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"time"
+)
+
+// The append functions below encode bid types field by field in the order and form encoding/json
+// uses for their struct tags. Values whose encoding has edge cases, such as free-form maps,
+// custom marshalers, and strings needing escapes, are handed to encoding/json itself, so the
+// output stays byte-identical while the common fields skip reflection. A change to the struct
+// tags in bid.go must be mirrored here; FuzzCodecRoundTrip fails otherwise.
+
+// appendBidResponse appends the JSON encoding of r, writing only the selected fields of its bids
+func appendBidResponse(dst []byte, r *BidResponse, fields BidFields) ([]byte, error) {
+	if r == nil {
+		return append(dst, "null"...), nil
+	}
+
+	var err error
+	dst = append(dst, `{"request_id":`...)
+	dst = appendString(dst, r.RequestID)
+	dst = append(dst, `,"bids":`...)
+	if r.Bids == nil {
+		dst = append(dst, "null"...)
+	} else {
+		dst = append(dst, '[')
+		for i, bid := range r.Bids {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			if dst, err = appendBid(dst, bid, fields); err != nil {
+				return nil, err
+			}
+		}
+		dst = append(dst, ']')
+	}
+	dst = append(dst, `,"timestamp":`...)
+	if dst, err = appendTime(dst, r.Timestamp); err != nil {
+		return nil, err
+	}
+	if r.ValidUntil != nil {
+		dst = append(dst, `,"valid_until":`...)
+		if dst, err = appendTime(dst, *r.ValidUntil); err != nil {
+			return nil, err
+		}
+	}
+	dst = append(dst, `,"processing_time":`...)
+	dst = strconv.AppendInt(dst, int64(r.ProcessingTime), 10)
+	dst = append(dst, `,"collection_time":`...)
+	dst = strconv.AppendInt(dst, int64(r.CollectionTime), 10)
+	dst = append(dst, `,"optimization_time":`...)
+	dst = strconv.AppendInt(dst, int64(r.OptimizationTime), 10)
+	if r.Summary != nil {
+		dst = append(dst, `,"summary":{"partners_contacted":`...)
+		dst = strconv.AppendInt(dst, int64(r.Summary.PartnersContacted), 10)
+		dst = append(dst, `,"partners_bid":`...)
+		dst = strconv.AppendInt(dst, int64(r.Summary.PartnersBid), 10)
+		dst = append(dst, `,"valid_bids":`...)
+		dst = strconv.AppendInt(dst, int64(r.Summary.ValidBids), 10)
+		dst = append(dst, `,"winners":`...)
+		dst = strconv.AppendInt(dst, int64(r.Summary.Winners), 10)
+		dst = append(dst, `,"timed_out_partners":`...)
+		dst = strconv.AppendInt(dst, int64(r.Summary.TimedOutPartners), 10)
+		if r.Summary.EarlyTerminatedPartners > 0 {
+			dst = append(dst, `,"early_terminated_partners":`...)
+			dst = strconv.AppendInt(dst, int64(r.Summary.EarlyTerminatedPartners), 10)
+		}
+		if len(r.Summary.UnsatisfiedConstraints) > 0 {
+			dst = append(dst, `,"unsatisfied_constraints":`...)
+			if dst, err = appendValue(dst, r.Summary.UnsatisfiedConstraints); err != nil {
+				return nil, err
+			}
+		}
+		dst = append(dst, '}')
+	}
+	if len(r.Experiments) > 0 {
+		dst = append(dst, `,"experiments":[`...)
+		for i, assignment := range r.Experiments {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = append(dst, `{"id":`...)
+			dst = appendString(dst, assignment.ID)
+			dst = append(dst, `,"variant":`...)
+			dst = appendString(dst, assignment.Variant)
+			dst = append(dst, '}')
+		}
+		dst = append(dst, ']')
+	}
+	if r.Debug != nil {
+		dst = append(dst, `,"debug":`...)
+		if dst, err = appendValue(dst, r.Debug); err != nil {
+			return nil, err
+		}
+	}
+	if r.DryRun != nil {
+		dst = append(dst, `,"dry_run":`...)
+		if dst, err = appendValue(dst, r.DryRun); err != nil {
+			return nil, err
+		}
+	}
+	if r.Reason != "" {
+		dst = append(dst, `,"reason":`...)
+		dst = appendString(dst, string(r.Reason))
+	}
+	if r.HouseOffer {
+		dst = append(dst, `,"house_offer":true`...)
+	}
+	if r.Locale != "" {
+		dst = append(dst, `,"locale":`...)
+		dst = appendString(dst, r.Locale)
+	}
+	if r.AuctionSeq != nil {
+		dst = append(dst, `,"auction_seq":`...)
+		if dst, err = appendValue(dst, r.AuctionSeq); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+// appendBid appends the JSON encoding of b with only the selected fields
+func appendBid(dst []byte, b *Bid, fields BidFields) ([]byte, error) {
+	if b == nil {
+		return append(dst, "null"...), nil
+	}
+
+	var err error
+	dst = append(dst, '{')
+	start := len(dst)
+	if fields&BidFieldID != 0 {
+		dst = appendKey(dst, start, `"id":`)
+		dst = appendString(dst, b.ID)
+	}
+	if fields&BidFieldPartnerID != 0 {
+		dst = appendKey(dst, start, `"partner_id":`)
+		dst = appendString(dst, b.PartnerID)
+	}
+	if fields&BidFieldPrice != 0 {
+		dst = appendKey(dst, start, `"price":`)
+		if dst, err = appendFloat(dst, b.Price); err != nil {
+			return nil, err
+		}
+	}
+	if fields&BidFieldClickURL != 0 {
+		dst = appendKey(dst, start, `"click_url":`)
+		dst = appendString(dst, b.ClickURL)
+	}
+	if fields&BidFieldQualityScore != 0 {
+		dst = appendKey(dst, start, `"quality_score":`)
+		if dst, err = appendFloat(dst, b.QualityScore); err != nil {
+			return nil, err
+		}
+	}
+	if fields&BidFieldExpiresAt != 0 {
+		dst = appendKey(dst, start, `"expires_at":`)
+		if dst, err = appendTime(dst, b.ExpiresAt); err != nil {
+			return nil, err
+		}
+	}
+	if fields&BidFieldCreative != 0 && len(b.Creative) > 0 {
+		dst = appendKey(dst, start, `"creative":`)
+		if dst, err = appendValue(dst, b.Creative); err != nil {
+			return nil, err
+		}
+	}
+	if fields&BidFieldAdvertiserDomains != 0 && len(b.AdvertiserDomains) > 0 {
+		dst = appendKey(dst, start, `"adomain":[`)
+		for i, domain := range b.AdvertiserDomains {
+			if i > 0 {
+				dst = append(dst, ',')
+			}
+			dst = appendString(dst, domain)
+		}
+		dst = append(dst, ']')
+	}
+	if fields&BidFieldDealID != 0 && b.DealID != "" {
+		dst = appendKey(dst, start, `"deal_id":`)
+		dst = appendString(dst, b.DealID)
+	}
+	if fields&BidFieldPricingModel != 0 && b.PricingModel != "" {
+		dst = appendKey(dst, start, `"pricing_model":`)
+		dst = appendString(dst, b.PricingModel)
+	}
+	if fields&BidFieldNormalizedPrice != 0 && b.NormalizedPrice != 0 {
+		dst = appendKey(dst, start, `"normalized_price":`)
+		if dst, err = appendFloat(dst, b.NormalizedPrice); err != nil {
+			return nil, err
+		}
+	}
+	if fields&BidFieldQualityAcknowledged != 0 && b.QualityAcknowledged {
+		dst = appendKey(dst, start, `"quality_acknowledged":true`)
+	}
+	if fields&BidFieldVerticalExt != 0 && len(b.VerticalExt) > 0 {
+		dst = appendKey(dst, start, `"vertical_ext":`)
+		if dst, err = appendValue(dst, b.VerticalExt); err != nil {
+			return nil, err
+		}
+	}
+	if fields&BidFieldPayloadExperiment != 0 && b.PayloadExperiment != nil {
+		dst = appendKey(dst, start, `"payload_experiment":{"id":`)
+		dst = appendString(dst, b.PayloadExperiment.ID)
+		dst = append(dst, `,"variant":`...)
+		dst = appendString(dst, b.PayloadExperiment.Variant)
+		dst = append(dst, '}')
+	}
+	return append(dst, '}'), nil
+}
+
+// appendKey appends an object key, preceded by a comma unless it is the first since start
+func appendKey(dst []byte, start int, key string) []byte {
+	if len(dst) > start {
+		dst = append(dst, ',')
+	}
+	return append(dst, key...)
+}
+
+// appendBidRequest appends the JSON encoding of r
+func appendBidRequest(dst []byte, r *BidRequest) ([]byte, error) {
+	if r == nil {
+		return append(dst, "null"...), nil
+	}
+
+	var err error
+	dst = append(dst, `{"request_id":`...)
+	dst = appendString(dst, r.RequestID)
+	dst = append(dst, `,"lead_id":`...)
+	dst = appendString(dst, r.LeadID)
+	dst = append(dst, `,"vertical":`...)
+	dst = appendString(dst, r.Vertical)
+	if len(r.UserData) > 0 {
+		dst = append(dst, `,"user_data":`...)
+		if dst, err = appendValue(dst, r.UserData); err != nil {
+			return nil, err
+		}
+	}
+	dst = append(dst, `,"timeout":`...)
+	dst = strconv.AppendInt(dst, int64(r.Timeout), 10)
+	dst = append(dst, `,"timestamp":`...)
+	if dst, err = appendTime(dst, r.Timestamp); err != nil {
+		return nil, err
+	}
+	if r.Consent != nil {
+		dst = append(dst, `,"consent":`...)
+		if dst, err = appendValue(dst, r.Consent); err != nil {
+			return nil, err
+		}
+	}
+	if r.Geo != nil {
+		dst = append(dst, `,"geo":`...)
+		if dst, err = appendValue(dst, r.Geo); err != nil {
+			return nil, err
+		}
+	}
+	if r.Device != nil {
+		dst = append(dst, `,"device":`...)
+		if dst, err = appendValue(dst, r.Device); err != nil {
+			return nil, err
+		}
+	}
+	if r.LeadQuality != nil {
+		dst = append(dst, `,"lead_quality":`...)
+		if dst, err = appendValue(dst, r.LeadQuality); err != nil {
+			return nil, err
+		}
+	}
+	if len(r.Constraints) > 0 {
+		dst = append(dst, `,"constraints":`...)
+		if dst, err = appendValue(dst, r.Constraints); err != nil {
+			return nil, err
+		}
+	}
+	return append(dst, '}'), nil
+}
+
+// appendValue appends v as encoded by encoding/json
+func appendValue(dst []byte, v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(dst, data...), nil
+}
+
+// appendString appends s as a JSON string. Strings of printable ASCII without characters
+// encoding/json escapes are copied directly; anything else is left to encoding/json.
+func appendString(dst []byte, s string) []byte {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= 0x80 || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			data, _ := json.Marshal(s)
+			return append(dst, data...)
+		}
+	}
+	dst = append(dst, '"')
+	dst = append(dst, s...)
+	return append(dst, '"')
+}
+
+// appendFloat appends f formatted as encoding/json formats float64 values, switching to
+// exponent form outside [1e-6, 1e21) and trimming the exponent's leading zero
+func appendFloat(dst []byte, f float64) ([]byte, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return appendValue(dst, f)
+	}
+
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	dst = strconv.AppendFloat(dst, f, format, -1, 64)
+	if format == 'e' {
+		// Turn e-07 into e-7
+		if n := len(dst); n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+	return dst, nil
+}
+
+// appendTime appends t as encoded by its MarshalJSON method
+func appendTime(dst []byte, t time.Time) ([]byte, error) {
+	// MarshalJSON rejects years and zone offsets it cannot write as RFC 3339; let it report the error
+	_, offset := t.Zone()
+	if year := t.Year(); year < 0 || year > 9999 || offset <= -24*60*60 || offset >= 24*60*60 {
+		return appendValue(dst, t)
+	}
+	dst = append(dst, '"')
+	dst = t.AppendFormat(dst, time.RFC3339Nano)
+	return append(dst, '"'), nil
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"strings"
+)
+
+// IAB TCF v2 consent strings start with the version 2 core segment, which base64url-encodes to "C"
+const tcfV2Prefix = "C"
+
+// Consent carries the consumer's privacy signals for a bid request
+type Consent struct {
+	GDPRApplies   bool   `json:"gdpr_applies"`
+	ConsentString string `json:"consent_string,omitempty"`
+	USPrivacy     string `json:"us_privacy,omitempty"`
+}
+
+// HasTCFConsent reports whether a well-formed IAB TCF v2 consent string is present.
+// This is a presence and shape check only; purposes and vendor consents are not decoded.
+func (c *Consent) HasTCFConsent() bool {
+	if c == nil || !strings.HasPrefix(c.ConsentString, tcfV2Prefix) {
+		return false
+	}
+	for _, segment := range strings.Split(c.ConsentString, ".") {
+		if segment == "" {
+			return false
+		}
+		for _, r := range segment {
+			if !isBase64URLRune(r) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// USPrivacyOptOut reports whether the CCPA US Privacy string signals an opt-out of sale ("1YYN")
+func (c *Consent) USPrivacyOptOut() bool {
+	if c == nil || len(c.USPrivacy) != 4 {
+		return false
+	}
+	return c.USPrivacy[2] == 'Y'
+}
+
+// RequiresGDPRConsent reports whether GDPR applies to the request
+func (c *Consent) RequiresGDPRConsent() bool {
+	return c != nil && c.GDPRApplies
+}
+
+// AllowsDataSharing reports whether the consumer's signals permit sharing personal data with partners
+func (c *Consent) AllowsDataSharing() bool {
+	if c.RequiresGDPRConsent() && !c.HasTCFConsent() {
+		return false
+	}
+	return !c.USPrivacyOptOut()
+}
+
+// isBase64URLRune reports whether r is in the base64url alphabet
+func isBase64URLRune(r rune) bool {
+	return (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '-' || r == '_'
+}
@@ -0,0 +1,20 @@
+package models
+
+// Ranking constraint types
+const (
+	// ConstraintMaxPriceAtLeastOne requires at least one winning bid at or below MaxPrice
+	ConstraintMaxPriceAtLeastOne = "max_price_at_least_one"
+	// ConstraintMaxFromPartnerGroup allows at most Max winning bids from the partners in Group
+	ConstraintMaxFromPartnerGroup = "max_from_partner_group"
+	// ConstraintRequirePartnerGroup requires at least one winning bid from the partners in Group
+	ConstraintRequirePartnerGroup = "require_partner_group"
+)
+
+// RankingConstraint is a requirement a caller puts on the winner set, for example to build a
+// balanced comparison page. Groups name the partner groups in the service config.
+type RankingConstraint struct {
+	Type     string  `json:"type"`
+	MaxPrice float64 `json:"max_price,omitempty"`
+	Group    string  `json:"group,omitempty"`
+	Max      int     `json:"max,omitempty"`
+}
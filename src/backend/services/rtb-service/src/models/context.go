@@ -0,0 +1,139 @@
+package models
+
+import "context"
+
+// contextKey is an unexported type for context keys defined in this package
+type contextKey int
+
+// auctionKey carries the AuctionContext. The functions below predate it and read and write its
+// fields, each write carrying a changed copy.
+const auctionKey contextKey = 0
+
+// ContextWithRequestID returns a copy of ctx carrying the auction request ID
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return withAuction(ctx, func(a *AuctionContext) {
+		// A different request is a different auction, which collects its own reasons
+		if a.requestID != requestID {
+			a.reasons = NewReasonCollector(a.debug)
+		}
+		a.requestID = requestID
+	})
+}
+
+// RequestIDFromContext returns the auction request ID carried by ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	return AuctionFromContext(ctx).RequestID()
+}
+
+// ContextWithBatchItem marks ctx as belonging to an auction running inside a batch
+func ContextWithBatchItem(ctx context.Context) context.Context {
+	return withAuction(ctx, func(a *AuctionContext) { a.batchItem = true })
+}
+
+// IsBatchItem reports whether ctx belongs to an auction running inside a batch
+func IsBatchItem(ctx context.Context) bool {
+	return AuctionFromContext(ctx).IsBatchItem()
+}
+
+// ContextWithDebug returns a copy of ctx that records auction decisions into debug
+func ContextWithDebug(ctx context.Context, debug *DebugInfo) context.Context {
+	return ContextWithAuction(ctx, AuctionFromContext(ctx).WithDebug(debug))
+}
+
+// DebugFromContext returns the debug recorder carried by ctx, or nil when debug output was not requested
+func DebugFromContext(ctx context.Context) *DebugInfo {
+	return AuctionFromContext(ctx).Debug()
+}
+
+// ContextWithDryRun returns a copy of ctx whose auction records its side effects into dryRun instead of performing them
+func ContextWithDryRun(ctx context.Context, dryRun *DryRun) context.Context {
+	return withAuction(ctx, func(a *AuctionContext) { a.dryRun = dryRun })
+}
+
+// DryRunFromContext returns the dry run recorder carried by ctx, or nil for a live auction
+func DryRunFromContext(ctx context.Context) *DryRun {
+	return AuctionFromContext(ctx).DryRun()
+}
+
+// ContextWithRecording returns a copy of ctx whose auction captures its partner responses into recording
+func ContextWithRecording(ctx context.Context, recording *Recording) context.Context {
+	return withAuction(ctx, func(a *AuctionContext) { a.recording = recording })
+}
+
+// RecordingFromContext returns the recording carried by ctx, or nil when the auction is not recorded
+func RecordingFromContext(ctx context.Context) *Recording {
+	return AuctionFromContext(ctx).Recording()
+}
+
+// ContextWithReplay returns a copy of ctx whose auction takes partner responses from replay instead of calling partners
+func ContextWithReplay(ctx context.Context, replay *Replay) context.Context {
+	return withAuction(ctx, func(a *AuctionContext) { a.replay = replay })
+}
+
+// ReplayFromContext returns the replay carried by ctx, or nil for an auction with live partner calls
+func ReplayFromContext(ctx context.Context) *Replay {
+	return AuctionFromContext(ctx).Replay()
+}
+
+// ContextWithReservation marks ctx as belonging to an auction whose winners are reserved rather than settled
+func ContextWithReservation(ctx context.Context) context.Context {
+	return withAuction(ctx, func(a *AuctionContext) { a.reservation = true })
+}
+
+// IsReservation reports whether ctx belongs to an auction whose winners are reserved rather than settled
+func IsReservation(ctx context.Context) bool {
+	return AuctionFromContext(ctx).IsReservation()
+}
+
+// Override is a per-request floor and partner kill switch an admin caller applies to one auction
+type Override struct {
+	Floor            float64
+	DisabledPartners []string
+}
+
+// ContextWithOverride returns a copy of ctx whose auction applies override on top of any admin overrides in effect
+func ContextWithOverride(ctx context.Context, override *Override) context.Context {
+	return withAuction(ctx, func(a *AuctionContext) { a.override = override })
+}
+
+// OverrideFromContext returns the per-request override carried by ctx, or nil when there is none
+func OverrideFromContext(ctx context.Context) *Override {
+	return AuctionFromContext(ctx).Override()
+}
+
+// ContextWithAuctionParams returns a copy of ctx whose auction runs with the admin caller's forced parameters
+func ContextWithAuctionParams(ctx context.Context, params *AuctionParams) context.Context {
+	return withAuction(ctx, func(a *AuctionContext) { a.params = params })
+}
+
+// AuctionParamsFromContext returns the forced auction parameters carried by ctx, or nil when there are none
+func AuctionParamsFromContext(ctx context.Context) *AuctionParams {
+	return AuctionFromContext(ctx).AuctionParams()
+}
+
+// ContextWithSynthetic marks ctx as belonging to a synthetic auction, such as the self-test, that
+// must stay out of business metrics and events
+func ContextWithSynthetic(ctx context.Context) context.Context {
+	return withAuction(ctx, func(a *AuctionContext) { a.synthetic = true })
+}
+
+// IsSynthetic reports whether ctx belongs to a synthetic auction
+func IsSynthetic(ctx context.Context) bool {
+	return AuctionFromContext(ctx).IsSynthetic()
+}
+
+// ContextWithCanary marks ctx as belonging to a canary auction, which only reaches partners opted
+// in to canaries
+func ContextWithCanary(ctx context.Context) context.Context {
+	return withAuction(ctx, func(a *AuctionContext) { a.canary = true })
+}
+
+// IsCanary reports whether ctx belongs to a canary auction
+func IsCanary(ctx context.Context) bool {
+	return AuctionFromContext(ctx).IsCanary()
+}
+
+// withAuction returns a copy of ctx carrying its auction context changed by fn
+func withAuction(ctx context.Context, fn func(*AuctionContext)) context.Context {
+	return ContextWithAuction(ctx, AuctionFromContext(ctx).with(fn))
+}
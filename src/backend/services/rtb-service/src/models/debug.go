@@ -0,0 +1,244 @@
+package models
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// DebugInfo explains which partner filters and price multipliers fired during an auction.
+// All methods are safe for concurrent use and no-ops on a nil receiver.
+type DebugInfo struct {
+	mutex    sync.Mutex
+	partners map[string]*PartnerDebug
+	floor    *AuctionFloor
+	params   *AuctionParams
+}
+
+// Auction floor sources: learned from recent clearing prices, the static floor when too few
+// prices were seen, or the vertical's configured floor when it is the higher
+const (
+	FloorSourceLearned  = "learned"
+	FloorSourceStatic   = "static"
+	FloorSourceVertical = "vertical"
+)
+
+// AuctionFloor is the adaptive or vertical floor an auction's open bids had to meet. Region is
+// set when the floor was learned from the vertical and region rather than the vertical alone.
+type AuctionFloor struct {
+	Floor  float64 `json:"floor"`
+	Source string  `json:"source"`
+	Region string  `json:"region,omitempty"`
+}
+
+// PartnerDebug records the decisions made for a single partner
+type PartnerDebug struct {
+	SkipReason  Reason             `json:"skip_reason,omitempty"`
+	Error       string             `json:"error,omitempty"`
+	NoBid       string             `json:"no_bid,omitempty"`
+	Bids        int                `json:"bids,omitempty"`
+	Losses      map[string]Reason  `json:"losses,omitempty"`
+	Multipliers map[string]float64 `json:"multipliers,omitempty"`
+	Selection   *PartnerSelection  `json:"selection,omitempty"`
+	// VerticalExt holds the extensions of the partner's valid bids, sanitized, by bid ID
+	VerticalExt map[string]json.RawMessage `json:"vertical_ext,omitempty"`
+	// InvalidExt explains why bids failed their vertical's extension schema, by bid ID
+	InvalidExt map[string]string `json:"invalid_vertical_ext,omitempty"`
+}
+
+// PartnerSelection explains whether an auction capped at a maximum number of partners chose to
+// contact a partner: its score, and the reason it was chosen or not_selected
+type PartnerSelection struct {
+	Score  float64 `json:"score"`
+	Reason Reason  `json:"reason"`
+}
+
+// NewDebugInfo creates an empty debug recorder
+func NewDebugInfo() *DebugInfo {
+	return &DebugInfo{partners: make(map[string]*PartnerDebug)}
+}
+
+// RecordSkip notes that a partner was filtered out of the auction and why
+func (d *DebugInfo) RecordSkip(partnerID string, reason Reason) {
+	d.update(partnerID, func(p *PartnerDebug) { p.SkipReason = reason })
+}
+
+// RecordError notes that a partner call failed
+func (d *DebugInfo) RecordError(partnerID string, err error) {
+	d.update(partnerID, func(p *PartnerDebug) { p.Error = err.Error() })
+}
+
+// RecordNoBid notes that a partner declined to bid, with a description of why
+func (d *DebugInfo) RecordNoBid(partnerID, description string) {
+	d.update(partnerID, func(p *PartnerDebug) { p.NoBid = description })
+}
+
+// RecordBids notes how many bids a partner returned
+func (d *DebugInfo) RecordBids(partnerID string, count int) {
+	d.update(partnerID, func(p *PartnerDebug) { p.Bids = count })
+}
+
+// RecordLoss notes why one of a partner's valid bids was not selected
+func (d *DebugInfo) RecordLoss(partnerID, bidID string, reason Reason) {
+	d.update(partnerID, func(p *PartnerDebug) {
+		if p.Losses == nil {
+			p.Losses = make(map[string]Reason)
+		}
+		p.Losses[bidID] = reason
+	})
+}
+
+// RecordVerticalExt notes the extension one of a partner's bids carried, already sanitized
+func (d *DebugInfo) RecordVerticalExt(partnerID, bidID string, ext json.RawMessage) {
+	d.update(partnerID, func(p *PartnerDebug) {
+		if p.VerticalExt == nil {
+			p.VerticalExt = make(map[string]json.RawMessage)
+		}
+		p.VerticalExt[bidID] = ext
+	})
+}
+
+// RecordInvalidExt notes that one of a partner's bids was dropped for failing its vertical's
+// extension schema
+func (d *DebugInfo) RecordInvalidExt(partnerID, bidID string, err error) {
+	d.update(partnerID, func(p *PartnerDebug) {
+		if p.InvalidExt == nil {
+			p.InvalidExt = make(map[string]string)
+		}
+		p.InvalidExt[bidID] = err.Error()
+	})
+}
+
+// ClearLoss forgets the loss of a bid that went on to win after all
+func (d *DebugInfo) ClearLoss(partnerID, bidID string) {
+	d.update(partnerID, func(p *PartnerDebug) { delete(p.Losses, bidID) })
+}
+
+// RecordMultipliers notes the price multipliers applied to a partner's bids, keyed by multiplier name
+func (d *DebugInfo) RecordMultipliers(partnerID string, multipliers map[string]float64) {
+	d.update(partnerID, func(p *PartnerDebug) { p.Multipliers = multipliers })
+}
+
+// RecordSelection notes why partner selection chose or passed over a partner, with its score
+func (d *DebugInfo) RecordSelection(partnerID string, reason Reason, score float64) {
+	d.update(partnerID, func(p *PartnerDebug) { p.Selection = &PartnerSelection{Score: score, Reason: reason} })
+}
+
+// RecordFloor notes the adaptive floor the auction applied
+func (d *DebugInfo) RecordFloor(floor *AuctionFloor) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.floor = floor
+}
+
+// Floor returns the adaptive floor the auction applied, or nil when it applied none
+func (d *DebugInfo) Floor() *AuctionFloor {
+	if d == nil {
+		return nil
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.floor
+}
+
+// RecordAuctionParams notes the parameters an admin caller forced on the auction
+func (d *DebugInfo) RecordAuctionParams(params *AuctionParams) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.params = params
+}
+
+// AuctionParams returns the parameters forced on the auction, or nil when none were
+func (d *DebugInfo) AuctionParams() *AuctionParams {
+	if d == nil {
+		return nil
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.params
+}
+
+// Partner returns a copy of the decisions recorded for a partner
+func (d *DebugInfo) Partner(partnerID string) (PartnerDebug, bool) {
+	if d == nil {
+		return PartnerDebug{}, false
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	partner, exists := d.partners[partnerID]
+	if !exists {
+		return PartnerDebug{}, false
+	}
+	copied := *partner
+	if partner.Losses != nil {
+		copied.Losses = make(map[string]Reason, len(partner.Losses))
+		for bidID, reason := range partner.Losses {
+			copied.Losses[bidID] = reason
+		}
+	}
+	if partner.VerticalExt != nil {
+		copied.VerticalExt = make(map[string]json.RawMessage, len(partner.VerticalExt))
+		for bidID, ext := range partner.VerticalExt {
+			copied.VerticalExt[bidID] = ext
+		}
+	}
+	if partner.InvalidExt != nil {
+		copied.InvalidExt = make(map[string]string, len(partner.InvalidExt))
+		for bidID, failure := range partner.InvalidExt {
+			copied.InvalidExt[bidID] = failure
+		}
+	}
+	return copied, true
+}
+
+// MarshalJSON encodes the recorded decisions keyed by partner ID, the adaptive floor, and the
+// forced auction parameters
+func (d *DebugInfo) MarshalJSON() ([]byte, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return json.Marshal(struct {
+		Partners      map[string]*PartnerDebug `json:"partners"`
+		Floor         *AuctionFloor            `json:"floor,omitempty"`
+		AuctionParams *AuctionParams           `json:"auction_params,omitempty"`
+	}{Partners: d.partners, Floor: d.floor, AuctionParams: d.params})
+}
+
+// UnmarshalJSON decodes decisions previously encoded with MarshalJSON
+func (d *DebugInfo) UnmarshalJSON(data []byte) error {
+	var decoded struct {
+		Partners      map[string]*PartnerDebug `json:"partners"`
+		Floor         *AuctionFloor            `json:"floor,omitempty"`
+		AuctionParams *AuctionParams           `json:"auction_params,omitempty"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.partners = decoded.Partners
+	d.floor = decoded.Floor
+	d.params = decoded.AuctionParams
+	return nil
+}
+
+// update applies fn to the partner entry, creating it when missing
+func (d *DebugInfo) update(partnerID string, fn func(*PartnerDebug)) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	partner, exists := d.partners[partnerID]
+	if !exists {
+		partner = &PartnerDebug{}
+		d.partners[partnerID] = partner
+	}
+	fn(partner)
+}
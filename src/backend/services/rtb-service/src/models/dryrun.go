@@ -0,0 +1,82 @@
+package models
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// Side effect types recorded by a dry run
+const (
+	SideEffectAuditRecord    = "audit_record"
+	SideEffectWebhook        = "webhook"
+	SideEffectPriceAnalytics = "price_analytics"
+	SideEffectPartnerCall    = "partner_report_call"
+	SideEffectPartnerWin     = "partner_report_win"
+	SideEffectNegativeCache  = "negative_cache"
+	SideEffectTrafficMix     = "traffic_mix"
+	SideEffectMirror         = "mirror"
+	SideEffectBudgetSpend    = "budget_spend"
+	SideEffectAssetCheck     = "asset_check"
+)
+
+// SideEffect is an action a live auction would have taken beyond selecting winners
+type SideEffect struct {
+	Type      string      `json:"type"`
+	PartnerID string      `json:"partner_id,omitempty"`
+	Detail    interface{} `json:"detail,omitempty"`
+}
+
+// DryRun collects the side effects an auction skipped because it ran as a dry run.
+// All methods are safe for concurrent use and no-ops on a nil receiver.
+type DryRun struct {
+	mutex   sync.Mutex
+	effects []SideEffect
+}
+
+// NewDryRun creates an empty dry run recorder
+func NewDryRun() *DryRun {
+	return &DryRun{effects: []SideEffect{}}
+}
+
+// Record notes a side effect that was skipped
+func (d *DryRun) Record(effect SideEffect) {
+	if d == nil {
+		return
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.effects = append(d.effects, effect)
+}
+
+// Effects returns a copy of the skipped side effects in the order they were recorded
+func (d *DryRun) Effects() []SideEffect {
+	if d == nil {
+		return nil
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return append([]SideEffect(nil), d.effects...)
+}
+
+// MarshalJSON encodes the skipped side effects
+func (d *DryRun) MarshalJSON() ([]byte, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return json.Marshal(struct {
+		SideEffects []SideEffect `json:"side_effects"`
+	}{SideEffects: d.effects})
+}
+
+// UnmarshalJSON decodes side effects previously encoded with MarshalJSON
+func (d *DryRun) UnmarshalJSON(data []byte) error {
+	var decoded struct {
+		SideEffects []SideEffect `json:"side_effects"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.effects = decoded.SideEffects
+	return nil
+}
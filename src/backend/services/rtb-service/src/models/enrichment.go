@@ -0,0 +1,25 @@
+package models
+
+import "github.com/yourdomain/rtb-service/src/config"
+
+// Device types assigned by User-Agent classification
+const (
+	DeviceTypeDesktop = config.DeviceTypeDesktop
+	DeviceTypeMobile  = config.DeviceTypeMobile
+	DeviceTypeTablet  = config.DeviceTypeTablet
+	DeviceTypeBot     = config.DeviceTypeBot
+)
+
+// Geo describes the consumer's location
+type Geo struct {
+	Country string `json:"country,omitempty"`
+	Region  string `json:"region,omitempty"`
+	Zip     string `json:"zip,omitempty"`
+}
+
+// Device describes the consumer's device
+type Device struct {
+	Type    string `json:"type,omitempty"`
+	OS      string `json:"os,omitempty"`
+	Browser string `json:"browser,omitempty"`
+}
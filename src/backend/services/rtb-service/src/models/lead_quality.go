@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// leadQualityClockSkew is how far in the future a consent timestamp may be before it is rejected
+const leadQualityClockSkew = time.Minute
+
+// LeadQuality carries the publisher's signals about the lead for partners and modeling. Unset
+// fields are unknown rather than false or zero. The struct is typed so only these signals are
+// forwarded: a session ID or other identifier sent alongside them is not decoded, and the
+// session is only described by its duration.
+type LeadQuality struct {
+	ConsentTimestamp  *time.Time `json:"consent_timestamp,omitempty" xml:"ConsentTimestamp,omitempty"`
+	SessionDurationMs *int64     `json:"session_duration_ms,omitempty" xml:"SessionDurationMs,omitempty"`
+	PreviouslySold    *bool      `json:"previously_sold,omitempty" xml:"PreviouslySold,omitempty"`
+	Score             *float64   `json:"score,omitempty" xml:"Score,omitempty"`
+}
+
+// Lead quality fields as named in validation errors and the PII policy
+const (
+	LeadQualityConsentTimestamp = config.LeadQualityPrefix + "consent_timestamp"
+	LeadQualitySessionDuration  = config.LeadQualityPrefix + "session_duration_ms"
+	LeadQualityPreviouslySold   = config.LeadQualityPrefix + "previously_sold"
+	LeadQualityScore            = config.LeadQualityPrefix + "score"
+)
+
+// Validate checks the signals against now, returning a FieldError per invalid field
+func (q *LeadQuality) Validate(now time.Time) []FieldError {
+	if q == nil {
+		return nil
+	}
+
+	var failures []FieldError
+	if q.ConsentTimestamp != nil && q.ConsentTimestamp.After(now.Add(leadQualityClockSkew)) {
+		failures = append(failures, FieldError{Field: LeadQualityConsentTimestamp, Reason: FieldReasonRange, Message: "must not be in the future"})
+	}
+	if q.SessionDurationMs != nil && *q.SessionDurationMs < 0 {
+		failures = append(failures, FieldError{Field: LeadQualitySessionDuration, Reason: FieldReasonRange, Message: "must not be negative"})
+	}
+	if q.Score != nil && (*q.Score < 0 || *q.Score > 1) {
+		failures = append(failures, FieldError{Field: LeadQualityScore, Reason: FieldReasonRange, Message: "must be between 0 and 1"})
+	}
+	return failures
+}
+
+// Sanitized returns a copy of the signals without the fields the policy drops, or nil when none remain
+func (q *LeadQuality) Sanitized(policy *config.PIIPolicy) *LeadQuality {
+	if q == nil {
+		return nil
+	}
+	sanitized := *q
+	if policy == nil {
+		return &sanitized
+	}
+	for _, field := range policy.Drop {
+		switch field {
+		case LeadQualityConsentTimestamp:
+			sanitized.ConsentTimestamp = nil
+		case LeadQualitySessionDuration:
+			sanitized.SessionDurationMs = nil
+		case LeadQualityPreviouslySold:
+			sanitized.PreviouslySold = nil
+		case LeadQualityScore:
+			sanitized.Score = nil
+		}
+	}
+	if sanitized == (LeadQuality{}) {
+		return nil
+	}
+	return &sanitized
+}
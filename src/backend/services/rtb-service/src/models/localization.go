@@ -0,0 +1,119 @@
+package models
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// LocalizedCreativeKey is the creative field holding localized variants of its display fields,
+// keyed by language tag, as in {"localized": {"fr-CA": {"title": "...", "description": "..."}}}
+const LocalizedCreativeKey = "localized"
+
+// Creative display fields that localized variants replace
+const (
+	CreativeTitle       = "title"
+	CreativeDescription = "description"
+)
+
+// ErrInvalidLocalizedCreative rejects bids whose localized variants are malformed
+var ErrInvalidLocalizedCreative = errors.New("localized creative must map language tags to a title and description")
+
+// validateLocalizedCreative checks that the creative's localized variants, if any, are keyed by
+// well-formed language tags and carry string display fields
+func validateLocalizedCreative(creative map[string]interface{}) error {
+	raw, exists := creative[LocalizedCreativeKey]
+	if !exists {
+		return nil
+	}
+	variants, ok := raw.(map[string]interface{})
+	if !ok {
+		return ErrInvalidLocalizedCreative
+	}
+	for tag, raw := range variants {
+		variant, ok := raw.(map[string]interface{})
+		if !ok || !config.ValidLanguageTag(tag) {
+			return ErrInvalidLocalizedCreative
+		}
+		for _, field := range []string{CreativeTitle, CreativeDescription} {
+			if value, exists := variant[field]; exists {
+				if _, ok := value.(string); !ok {
+					return ErrInvalidLocalizedCreative
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// localizedVariant returns the creative's variant for tag: an exact match ignoring case, else a
+// variant of the same language, preferring the bare language ("fr" for "fr-CA") and then the first
+// tag in sorted order
+func (b *Bid) localizedVariant(tag string) (map[string]interface{}, bool) {
+	variants, _ := b.Creative[LocalizedCreativeKey].(map[string]interface{})
+	if len(variants) == 0 {
+		return nil, false
+	}
+
+	language := baseLanguage(tag)
+	var sameLanguage []string
+	for variantTag := range variants {
+		if strings.EqualFold(variantTag, tag) {
+			variant, ok := variants[variantTag].(map[string]interface{})
+			return variant, ok
+		}
+		if baseLanguage(variantTag) == language {
+			sameLanguage = append(sameLanguage, variantTag)
+		}
+	}
+	if len(sameLanguage) == 0 {
+		return nil, false
+	}
+	sort.Slice(sameLanguage, func(i, j int) bool {
+		iBare, jBare := strings.EqualFold(sameLanguage[i], language), strings.EqualFold(sameLanguage[j], language)
+		if iBare != jBare {
+			return iBare
+		}
+		return sameLanguage[i] < sameLanguage[j]
+	})
+	variant, ok := variants[sameLanguage[0]].(map[string]interface{})
+	return variant, ok
+}
+
+// baseLanguage returns the lowercase language subtag of tag
+func baseLanguage(tag string) string {
+	language, _, _ := strings.Cut(tag, "-")
+	return strings.ToLower(language)
+}
+
+// HasLocale reports whether the bid's creative has a variant for tag or another tag of its language
+func (b *Bid) HasLocale(tag string) bool {
+	_, exists := b.localizedVariant(tag)
+	return exists
+}
+
+// Localize returns a copy of the bid whose creative takes its title and description from the
+// variant for tag, or the bid itself when it has no such variant. Fields the variant leaves out
+// keep their unlocalized values. The bid itself is never changed, since auction results are shared
+// with idempotent replays and reservations.
+func (b *Bid) Localize(tag string) *Bid {
+	variant, exists := b.localizedVariant(tag)
+	if !exists {
+		return b
+	}
+
+	creative := make(map[string]interface{}, len(b.Creative)+2)
+	for field, value := range b.Creative {
+		creative[field] = value
+	}
+	for _, field := range []string{CreativeTitle, CreativeDescription} {
+		if value, ok := variant[field].(string); ok && value != "" {
+			creative[field] = value
+		}
+	}
+	localized := *b
+	localized.Creative = creative
+	return &localized
+}
@@ -0,0 +1,225 @@
+package models
+
+import (
+	"sort"
+	"strings"
+)
+
+// Reason is a machine-readable code explaining an auction decision. Every reason the auction
+// emits in metrics labels, debug output, responses, and events is one of the constants below, so
+// dashboards and consumers can rely on the registered set. The one exception is the loss reason
+// of a bid rejected by a bid rule, which names the configured rule.
+type Reason string
+
+// ReasonKind groups reasons by the decision they explain
+type ReasonKind string
+
+// Reason kinds
+const (
+	// ReasonKindSkip explains why a partner was not contacted for an auction
+	ReasonKindSkip ReasonKind = "skip"
+	// ReasonKindSelection explains why partner selection chose a partner
+	ReasonKindSelection ReasonKind = "selection"
+	// ReasonKindLoss explains why a valid bid was not selected as a winner
+	ReasonKindLoss ReasonKind = "loss"
+	// ReasonKindNoBid classifies a partner's decline to bid
+	ReasonKindNoBid ReasonKind = "no_bid"
+	// ReasonKindAuction explains why an auction sold nothing
+	ReasonKindAuction ReasonKind = "auction"
+	// ReasonKindCallError explains why a partner's call ended without an answer
+	ReasonKindCallError ReasonKind = "call_error"
+)
+
+// Partner skip reasons
+const (
+	ReasonDraining          Reason = "draining"
+	ReasonOverrideDisabled  Reason = "override_disabled"
+	ReasonOffSchedule       Reason = "off_schedule"
+	ReasonPartnerBackoff    Reason = "partner_backoff"
+	ReasonConsentMissing    Reason = "consent_missing"
+	ReasonGeoExcluded       Reason = "geo_excluded"
+	ReasonUserDataMissing   Reason = "user_data_missing"
+	ReasonPremiumUnknown    Reason = "premium_unknown"
+	ReasonNegativeCached    Reason = "negative_cached"
+	ReasonNotSelected       Reason = "not_selected"
+	ReasonVerticalQPSCapped Reason = "vertical_qps_capped"
+	ReasonQPSCapped         Reason = "qps_capped"
+	ReasonBudgetSpent       Reason = "budget_spent"
+	ReasonBudgetUnavailable Reason = "budget_unavailable"
+	ReasonRegionMismatch    Reason = "region_mismatch"
+	ReasonEarlyTerminated   Reason = "early_terminated"
+)
+
+// Partner selection reasons
+const (
+	ReasonSelectedDeal   Reason = "deal"
+	ReasonSelectedCold   Reason = "cold"
+	ReasonSelectedRanked Reason = "ranked"
+	ReasonSelectedHashed Reason = "hashed"
+)
+
+// Bid loss reasons
+const (
+	ReasonDuplicateDemand     Reason = "duplicate_demand"
+	ReasonResponseCap         Reason = "response_cap"
+	ReasonPartnerCap          Reason = "partner_cap"
+	ReasonDealIneligible      Reason = "deal_ineligible"
+	ReasonOverrideFloor       Reason = "override_floor"
+	ReasonExperimentFloor     Reason = "experiment_floor"
+	ReasonManualOverrideFloor Reason = "manual_override_floor"
+	ReasonAdaptiveFloor       Reason = "adaptive_floor"
+	ReasonVerticalFloor       Reason = "vertical_floor"
+	ReasonRankingConstraint   Reason = "ranking_constraint"
+	ReasonBudgetHold          Reason = "budget_hold"
+	ReasonAboveMaxBid         Reason = "above_max_bid"
+	ReasonShadowed            Reason = "shadowed"
+)
+
+// ReasonRulePrefix starts the loss reason of a bid rejected by a bid rule, followed by the rule's ID
+const ReasonRulePrefix = "rule:"
+
+// RuleReason returns the loss reason of bids rejected by the bid rule ruleID
+func RuleReason(ruleID string) Reason {
+	return Reason(ReasonRulePrefix + ruleID)
+}
+
+// Partner no-bid reasons. Partners may send any code; codes outside the known ones are counted as
+// ReasonNoBidOther.
+const (
+	ReasonBelowFloor       Reason = "below_floor"
+	ReasonNoDemand         Reason = "no_demand"
+	ReasonOutsideTargeting Reason = "outside_targeting"
+	ReasonBudgetExhausted  Reason = "budget_exhausted"
+	ReasonDuplicateLead    Reason = "duplicate_lead"
+	ReasonNoBidUnspecified Reason = "unspecified"
+	ReasonNoBidOther       Reason = "other"
+)
+
+// Partner call error reasons
+const (
+	ReasonCallTimeout   Reason = "call_timeout"
+	ReasonCallCancelled Reason = "call_cancelled"
+	ReasonCallFailed    Reason = "call_failed"
+)
+
+// Auction outcome reasons
+const (
+	// ReasonInsufficientCompetition explains an empty response for an auction that missed its bidder quorum
+	ReasonInsufficientCompetition Reason = "insufficient_competition"
+	// ReasonNoValidBids explains a no-bid response for an auction no partner returned a valid bid in
+	ReasonNoValidBids Reason = "no_valid_bids"
+	// ReasonNoBids explains an auction.completed event for an auction without valid bids
+	ReasonNoBids Reason = "no_bids"
+)
+
+// ReasonInfo describes a registered reason
+type ReasonInfo struct {
+	Reason      Reason     `json:"reason"`
+	Kind        ReasonKind `json:"kind"`
+	Description string     `json:"description"`
+}
+
+// reasons is the registry of every reason the auction emits
+var reasons = map[Reason]ReasonInfo{}
+
+// registerReasons adds reasons of one kind to the registry, keyed by reason with its description
+func registerReasons(kind ReasonKind, descriptions map[Reason]string) {
+	for reason, description := range descriptions {
+		if _, exists := reasons[reason]; exists {
+			panic("models: reason registered twice: " + string(reason))
+		}
+		reasons[reason] = ReasonInfo{Reason: reason, Kind: kind, Description: description}
+	}
+}
+
+func init() {
+	registerReasons(ReasonKindSkip, map[Reason]string{
+		ReasonDraining:          "The partner is being offboarded and gets no new auctions",
+		ReasonOverrideDisabled:  "An ops override disabled the partner",
+		ReasonOffSchedule:       "The auction is outside the partner's active hours",
+		ReasonPartnerBackoff:    "The partner rate limited recent calls and is being backed off",
+		ReasonConsentMissing:    "The consumer has not given the consent the partner requires",
+		ReasonGeoExcluded:       "The partner's region filter excludes the consumer",
+		ReasonUserDataMissing:   "The request lacks UserData fields the partner requires",
+		ReasonPremiumUnknown:    "The vertical has no expected premium to price the revenue-share partner's bids",
+		ReasonNegativeCached:    "The partner recently declined to bid on this lead segment",
+		ReasonNotSelected:       "Partner selection did not pick the partner for a capped auction",
+		ReasonVerticalQPSCapped: "The vertical used up its share of the partner's QPS",
+		ReasonQPSCapped:         "The partner's QPS cap was reached",
+		ReasonBudgetSpent:       "The partner's daily budget, less the holds of running auctions, cannot cover another win",
+		ReasonBudgetUnavailable: "The partner's daily budget could not be checked",
+		ReasonRegionMismatch:    "The partner prefers traffic from other service regions, and partners preferring this one were eligible",
+		ReasonEarlyTerminated:   "The partner's call was cancelled once a bid in hand was certain to win whatever it returned",
+	})
+	registerReasons(ReasonKindSelection, map[Reason]string{
+		ReasonSelectedDeal:   "The partner holds a deal for the auction",
+		ReasonSelectedCold:   "The partner has too few recent calls to rank and is always contacted",
+		ReasonSelectedRanked: "The partner ranked within the auction's partner cap",
+		ReasonSelectedHashed: "Fair selection picked the partner from the hash ring",
+	})
+	registerReasons(ReasonKindLoss, map[Reason]string{
+		ReasonDuplicateDemand:     "A higher-ranked bid already carries the same demand",
+		ReasonResponseCap:         "The partner returned more bids than it may place per response",
+		ReasonPartnerCap:          "A higher-ranked bid of the same partner already won",
+		ReasonDealIneligible:      "The bid's deal is unknown, expired, or does not match the request",
+		ReasonOverrideFloor:       "The bid was below an ops override floor",
+		ReasonExperimentFloor:     "The bid was below an experiment treatment's floor",
+		ReasonManualOverrideFloor: "The bid was below a floor an admin caller forced on the auction",
+		ReasonAdaptiveFloor:       "The bid was below the adaptive floor",
+		ReasonVerticalFloor:       "The bid was below the floor configured for the lead's vertical",
+		ReasonRankingConstraint:   "The bid gave up its winner slot to meet the request's ranking constraints",
+		ReasonBudgetHold:          "The bid was above, or won after, the hold on the partner's daily budget",
+		ReasonAboveMaxBid:         "The bid was above the partner's max bid, which early termination enforces",
+		ReasonShadowed:            "The partner is in shadow mode after going idle, so its bids do not compete",
+	})
+	registerReasons(ReasonKindNoBid, map[Reason]string{
+		ReasonBelowFloor:       "The lead was below the partner's floor",
+		ReasonNoDemand:         "The partner had no matching demand",
+		ReasonOutsideTargeting: "The lead was outside the partner's targeting",
+		ReasonBudgetExhausted:  "The partner's budget was exhausted",
+		ReasonDuplicateLead:    "The partner had already seen the lead",
+		ReasonNoBidUnspecified: "The partner declined without a reason code",
+		ReasonNoBidOther:       "The partner declined with an unknown reason code",
+	})
+	registerReasons(ReasonKindAuction, map[Reason]string{
+		ReasonInsufficientCompetition: "Too few partners bid to meet the auction's quorum",
+		ReasonNoValidBids:             "No partner returned a valid bid",
+		ReasonNoBids:                  "No partner returned a valid bid; reported in auction.completed events",
+	})
+	registerReasons(ReasonKindCallError, map[Reason]string{
+		ReasonCallTimeout:   "The partner did not answer within its timeout",
+		ReasonCallCancelled: "Bid collection ran out of time before the partner's call finished or started",
+		ReasonCallFailed:    "The partner's call failed, or its response could not be used",
+	})
+}
+
+// IsValid reports whether the reason is registered or names a bid rule
+func (r Reason) IsValid() bool {
+	_, exists := r.Info()
+	return exists
+}
+
+// Info returns the registered description of the reason and whether it is registered. A bid
+// rule's loss reason is described by its rule.
+func (r Reason) Info() (ReasonInfo, bool) {
+	if ruleID := strings.TrimPrefix(string(r), ReasonRulePrefix); ruleID != string(r) && ruleID != "" {
+		return ReasonInfo{Reason: r, Kind: ReasonKindLoss, Description: "The bid was rejected by bid rule " + ruleID}, true
+	}
+	info, exists := reasons[r]
+	return info, exists
+}
+
+// Reasons returns every registered reason, ordered by kind and then reason
+func Reasons() []ReasonInfo {
+	registered := make([]ReasonInfo, 0, len(reasons))
+	for _, info := range reasons {
+		registered = append(registered, info)
+	}
+	sort.Slice(registered, func(i, j int) bool {
+		if registered[i].Kind != registered[j].Kind {
+			return registered[i].Kind < registered[j].Kind
+		}
+		return registered[i].Reason < registered[j].Reason
+	})
+	return registered
+}
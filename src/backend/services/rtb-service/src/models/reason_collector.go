@@ -0,0 +1,166 @@
+package models
+
+import "sync"
+
+// ReasonObserver is told of each reason an auction records, such as to count it in metrics.
+// It is called from partner goroutines and must be safe for concurrent use.
+type ReasonObserver func(kind ReasonKind, partnerID string, reason Reason)
+
+// ReasonCounts counts the reasons an auction recorded by kind
+type ReasonCounts map[ReasonKind]map[Reason]int
+
+// ReasonCollector is the one place an auction's skip, selection, no-bid, loss, and call error
+// reasons accumulate. Each reason goes to the auction's debug output, when requested, to its
+// observer, and into the counts reported with the auction's events.
+// All methods are safe for concurrent use and no-ops on a nil receiver.
+type ReasonCollector struct {
+	debug *DebugInfo
+	tally *reasonTally
+}
+
+// reasonTally is the state shared by the collectors of one auction
+type reasonTally struct {
+	mutex    sync.Mutex
+	observer ReasonObserver
+	counts   ReasonCounts
+	losses   map[bidKey]Reason
+}
+
+// bidKey identifies a bid within an auction; partner bid IDs are only unique per partner
+type bidKey struct {
+	partnerID string
+	bidID     string
+}
+
+// NewReasonCollector creates an empty collector recording into debug, which may be nil
+func NewReasonCollector(debug *DebugInfo) *ReasonCollector {
+	return &ReasonCollector{debug: debug, tally: &reasonTally{counts: make(ReasonCounts), losses: make(map[bidKey]Reason)}}
+}
+
+// withDebug returns a collector sharing r's reasons that records into debug from then on
+func (r *ReasonCollector) withDebug(debug *DebugInfo) *ReasonCollector {
+	if r == nil {
+		return NewReasonCollector(debug)
+	}
+	return &ReasonCollector{debug: debug, tally: r.tally}
+}
+
+// Observe sets the observer told of each reason recorded from then on, replacing any earlier one
+func (r *ReasonCollector) Observe(observer ReasonObserver) {
+	if r == nil {
+		return
+	}
+	r.tally.mutex.Lock()
+	defer r.tally.mutex.Unlock()
+	r.tally.observer = observer
+}
+
+// Skip records that a partner was filtered out of the auction and why
+func (r *ReasonCollector) Skip(partnerID string, reason Reason) {
+	if r == nil {
+		return
+	}
+	r.debug.RecordSkip(partnerID, reason)
+	r.record(ReasonKindSkip, partnerID, reason)
+}
+
+// Selection records why partner selection chose or passed over a partner, with its score
+func (r *ReasonCollector) Selection(partnerID string, reason Reason, score float64) {
+	if r == nil {
+		return
+	}
+	r.debug.RecordSelection(partnerID, reason, score)
+	r.record(ReasonKindSelection, partnerID, reason)
+}
+
+// NoBid records that a partner declined to bid, with the reason counted and the description shown
+// in debug output
+func (r *ReasonCollector) NoBid(partnerID string, reason Reason, description string) {
+	if r == nil {
+		return
+	}
+	r.debug.RecordNoBid(partnerID, description)
+	r.record(ReasonKindNoBid, partnerID, reason)
+}
+
+// CallError records why a partner's call ended without an answer. The error itself is recorded
+// in debug output separately.
+func (r *ReasonCollector) CallError(partnerID string, reason Reason) {
+	if r == nil {
+		return
+	}
+	r.record(ReasonKindCallError, partnerID, reason)
+}
+
+// Loss records why one of a partner's valid bids was removed from winner selection
+func (r *ReasonCollector) Loss(partnerID, bidID string, reason Reason) {
+	if r == nil {
+		return
+	}
+	r.debug.RecordLoss(partnerID, bidID, reason)
+	r.tally.mutex.Lock()
+	r.tally.losses[bidKey{partnerID, bidID}] = reason
+	r.tally.mutex.Unlock()
+	r.record(ReasonKindLoss, partnerID, reason)
+}
+
+// ClearLoss forgets the loss of a bid that went on to win after all. The observer was already
+// told of the loss and is not told again.
+func (r *ReasonCollector) ClearLoss(partnerID, bidID string) {
+	if r == nil {
+		return
+	}
+	r.debug.ClearLoss(partnerID, bidID)
+	r.tally.mutex.Lock()
+	defer r.tally.mutex.Unlock()
+	key := bidKey{partnerID, bidID}
+	reason, lost := r.tally.losses[key]
+	if !lost {
+		return
+	}
+	delete(r.tally.losses, key)
+	losses := r.tally.counts[ReasonKindLoss]
+	if losses[reason]--; losses[reason] <= 0 {
+		delete(losses, reason)
+	}
+	if len(losses) == 0 {
+		delete(r.tally.counts, ReasonKindLoss)
+	}
+}
+
+// Counts returns a copy of the reasons recorded so far by kind, or nil when there are none
+func (r *ReasonCollector) Counts() ReasonCounts {
+	if r == nil {
+		return nil
+	}
+	r.tally.mutex.Lock()
+	defer r.tally.mutex.Unlock()
+	if len(r.tally.counts) == 0 {
+		return nil
+	}
+	counts := make(ReasonCounts, len(r.tally.counts))
+	for kind, reasons := range r.tally.counts {
+		copied := make(map[Reason]int, len(reasons))
+		for reason, count := range reasons {
+			copied[reason] = count
+		}
+		counts[kind] = copied
+	}
+	return counts
+}
+
+// record counts a reason and tells the observer, outside the lock
+func (r *ReasonCollector) record(kind ReasonKind, partnerID string, reason Reason) {
+	r.tally.mutex.Lock()
+	reasons := r.tally.counts[kind]
+	if reasons == nil {
+		reasons = make(map[Reason]int)
+		r.tally.counts[kind] = reasons
+	}
+	reasons[reason]++
+	observer := r.tally.observer
+	r.tally.mutex.Unlock()
+	if observer != nil {
+		observer(kind, partnerID, reason)
+	}
+}
@@ -0,0 +1,124 @@
+package models
+
+import (
+	"sync"
+	"time"
+)
+
+// RecordingVersion is the recording format version written by this service
+const RecordingVersion = 1
+
+// Recording captures an auction request, every raw partner response, and the winners, so the
+// auction can be replayed later. Recordings are written one JSON object per line; response
+// bodies are base64 encoded.
+type Recording struct {
+	Version    int               `json:"version"`
+	RecordedAt time.Time         `json:"recorded_at"`
+	Region     string            `json:"region,omitempty"` // service region the auction ran in
+	Request    *BidRequest       `json:"request"`
+	Exchanges  []PartnerExchange `json:"exchanges"`
+	Winners    []RecordedWinner  `json:"winners"`
+	Error      string            `json:"error,omitempty"`
+
+	mutex sync.Mutex
+}
+
+// PartnerExchange is one partner call in the order it was made. Error is set instead of a
+// status and body when the call failed before a response was read.
+type PartnerExchange struct {
+	PartnerID string `json:"partner_id"`
+	Status    int    `json:"status,omitempty"`
+	Body      []byte `json:"body,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// RecordedWinner is a winning bid as compared between a recording and its replay, priced as a cost per lead
+type RecordedWinner struct {
+	PartnerID string  `json:"partner_id"`
+	BidID     string  `json:"bid_id"`
+	Price     float64 `json:"price"`
+}
+
+// NewRecording starts a recording of request at recordedAt
+func NewRecording(request *BidRequest, recordedAt time.Time) *Recording {
+	snapshot := *request
+	return &Recording{
+		Version:    RecordingVersion,
+		RecordedAt: recordedAt.UTC(),
+		Request:    &snapshot,
+		Exchanges:  []PartnerExchange{},
+		Winners:    []RecordedWinner{},
+	}
+}
+
+// AddExchange appends a partner call; it is safe for concurrent use and a no-op on a nil receiver
+func (r *Recording) AddExchange(exchange PartnerExchange) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.Exchanges = append(r.Exchanges, exchange)
+}
+
+// WinnersOf converts winning bids into their recorded form
+func WinnersOf(bids []*Bid) []RecordedWinner {
+	winners := make([]RecordedWinner, 0, len(bids))
+	for _, bid := range bids {
+		winners = append(winners, RecordedWinner{PartnerID: bid.PartnerID, BidID: bid.ID, Price: bid.CPL()})
+	}
+	return winners
+}
+
+// Replay serves a recording's partner responses in place of live partner calls.
+// All methods are safe for concurrent use.
+type Replay struct {
+	recordedAt time.Time
+	mutex      sync.Mutex
+	exchanges  map[string][]PartnerExchange
+	missing    map[string]bool
+}
+
+// NewReplay prepares the exchanges of recording to be served per partner in recorded order
+func NewReplay(recording *Recording) *Replay {
+	replay := &Replay{
+		recordedAt: recording.RecordedAt,
+		exchanges:  make(map[string][]PartnerExchange),
+		missing:    make(map[string]bool),
+	}
+	for _, exchange := range recording.Exchanges {
+		replay.exchanges[exchange.PartnerID] = append(replay.exchanges[exchange.PartnerID], exchange)
+	}
+	return replay
+}
+
+// RecordedAt returns when the replayed auction was recorded
+func (r *Replay) RecordedAt() time.Time {
+	return r.recordedAt
+}
+
+// Next returns the partner's next recorded exchange, noting the partner as missing when none is left
+func (r *Replay) Next(partnerID string) (PartnerExchange, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	exchanges := r.exchanges[partnerID]
+	if len(exchanges) == 0 {
+		r.missing[partnerID] = true
+		return PartnerExchange{}, false
+	}
+	r.exchanges[partnerID] = exchanges[1:]
+	return exchanges[0], true
+}
+
+// Missing returns the partners called during the replay without a recorded response
+func (r *Replay) Missing() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	missing := make([]string, 0, len(r.missing))
+	for partnerID := range r.missing {
+		missing = append(missing, partnerID)
+	}
+	return missing
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnknownBidField is wrapped by ParseBidFields errors naming the unknown field
+var ErrUnknownBidField = errors.New("unknown bid field")
+
+// BidFields is a set of Bid JSON fields to include when encoding a response
+type BidFields uint16
+
+// Bid JSON fields, in encoding order
+const (
+	BidFieldID BidFields = 1 << iota
+	BidFieldPartnerID
+	BidFieldPrice
+	BidFieldClickURL
+	BidFieldQualityScore
+	BidFieldExpiresAt
+	BidFieldCreative
+	BidFieldAdvertiserDomains
+	BidFieldDealID
+	BidFieldPricingModel
+	BidFieldNormalizedPrice
+	BidFieldQualityAcknowledged
+	BidFieldVerticalExt
+	BidFieldPayloadExperiment
+
+	// AllBidFields selects the full Bid encoding
+	AllBidFields = BidFieldPayloadExperiment<<1 - 1
+)
+
+// bidFieldNames maps JSON names to fields; the names must match the struct tags in bid.go
+var bidFieldNames = map[string]BidFields{
+	"id":                   BidFieldID,
+	"partner_id":           BidFieldPartnerID,
+	"price":                BidFieldPrice,
+	"click_url":            BidFieldClickURL,
+	"quality_score":        BidFieldQualityScore,
+	"expires_at":           BidFieldExpiresAt,
+	"creative":             BidFieldCreative,
+	"adomain":              BidFieldAdvertiserDomains,
+	"deal_id":              BidFieldDealID,
+	"pricing_model":        BidFieldPricingModel,
+	"normalized_price":     BidFieldNormalizedPrice,
+	"quality_acknowledged": BidFieldQualityAcknowledged,
+	"vertical_ext":         BidFieldVerticalExt,
+	"payload_experiment":   BidFieldPayloadExperiment,
+}
+
+// ParseBidFields parses a comma-separated list of Bid JSON field names. An empty list selects
+// every field.
+func ParseBidFields(list string) (BidFields, error) {
+	var fields BidFields
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		field, exists := bidFieldNames[name]
+		if !exists {
+			return 0, fmt.Errorf("%w %q", ErrUnknownBidField, name)
+		}
+		fields |= field
+	}
+	if fields == 0 {
+		return AllBidFields, nil
+	}
+	return fields, nil
+}
+
+// ShapedResponse encodes a bid response with only the selected Bid fields. Omitted fields are
+// left out of the JSON rather than written as zero values.
+type ShapedResponse struct {
+	Response *BidResponse
+	Fields   BidFields
+}
+
+// MarshalJSON encodes the response with the field-by-field encoders
+func (s *ShapedResponse) MarshalJSON() ([]byte, error) {
+	return appendBidResponse(nil, s.Response, s.Fields)
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// maskVisibleChars is the number of trailing characters left visible by masking
+const maskVisibleChars = 4
+
+// SanitizeUserData returns a copy of userData with the policy's fields dropped, masked, or hashed.
+// Raw values must only reach the partner-call path; every other sink receives sanitized data.
+func SanitizeUserData(userData map[string]interface{}, policy *config.PIIPolicy) map[string]interface{} {
+	if userData == nil {
+		return nil
+	}
+
+	sanitized := make(map[string]interface{}, len(userData))
+	for key, value := range userData {
+		sanitized[key] = value
+	}
+	if policy == nil {
+		return sanitized
+	}
+
+	for _, field := range policy.Drop {
+		delete(sanitized, field)
+	}
+	for _, field := range policy.Mask {
+		if value, exists := sanitized[field]; exists {
+			sanitized[field] = MaskValue(value)
+		}
+	}
+	for _, field := range policy.Hash {
+		if value, exists := sanitized[field]; exists {
+			sanitized[field] = HashValue(policy.HashSalt, value)
+		}
+	}
+	return sanitized
+}
+
+// SanitizeVerticalExt returns a copy of a bid extension with the top-level keys the policy names
+// with config.VerticalExtPrefix dropped, masked, or hashed. Extensions the policy names no key of,
+// and those that are not JSON objects, are returned as they are.
+func SanitizeVerticalExt(ext json.RawMessage, policy *config.PIIPolicy) json.RawMessage {
+	if len(ext) == 0 || policy == nil {
+		return ext
+	}
+	keys := &config.PIIPolicy{
+		Drop:     verticalExtKeys(policy.Drop),
+		Mask:     verticalExtKeys(policy.Mask),
+		Hash:     verticalExtKeys(policy.Hash),
+		HashSalt: policy.HashSalt,
+	}
+	if len(keys.Drop)+len(keys.Mask)+len(keys.Hash) == 0 {
+		return ext
+	}
+
+	var object map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(ext))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil || object == nil {
+		return ext
+	}
+	sanitized, err := json.Marshal(SanitizeUserData(object, keys))
+	if err != nil {
+		return ext
+	}
+	return sanitized
+}
+
+// verticalExtKeys returns the bid extension keys among a PII policy's fields
+func verticalExtKeys(fields []string) []string {
+	var keys []string
+	for _, field := range fields {
+		if key, isExt := strings.CutPrefix(field, config.VerticalExtPrefix); isExt {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// Sanitized returns a copy of the bid with its extension sanitized by policy
+func (b *Bid) Sanitized(policy *config.PIIPolicy) *Bid {
+	if b == nil {
+		return nil
+	}
+	sanitized := *b
+	sanitized.VerticalExt = SanitizeVerticalExt(b.VerticalExt, policy)
+	return &sanitized
+}
+
+// Sanitized returns a copy of the request with UserData and lead quality sanitized by policy
+func (r *BidRequest) Sanitized(policy *config.PIIPolicy) *BidRequest {
+	if r == nil {
+		return nil
+	}
+	sanitized := *r
+	sanitized.UserData = SanitizeUserData(r.UserData, policy)
+	sanitized.LeadQuality = r.LeadQuality.Sanitized(policy)
+	return &sanitized
+}
+
+// Minimized returns a copy of the request holding only the fields and UserData keys a partner's
+// fields policy sends. Withheld fields are cleared, so no adapter can read them.
+func (r *BidRequest) Minimized(policy *config.FieldsPolicy) *BidRequest {
+	if r == nil || policy == nil {
+		return r
+	}
+	minimized := *r
+	if !policy.Sends("request_id") {
+		minimized.RequestID = ""
+	}
+	if !policy.Sends("lead_id") {
+		minimized.LeadID = ""
+	}
+	if !policy.Sends("vertical") {
+		minimized.Vertical = ""
+	}
+	if !policy.Sends("timeout") {
+		minimized.Timeout = 0
+	}
+	if !policy.Sends("timestamp") {
+		minimized.Timestamp = time.Time{}
+	}
+	if !policy.Sends("consent") {
+		minimized.Consent = nil
+	}
+	if !policy.Sends("geo") {
+		minimized.Geo = nil
+	}
+	if !policy.Sends("device") {
+		minimized.Device = nil
+	}
+	if !policy.Sends("lead_quality") {
+		minimized.LeadQuality = nil
+	}
+	if !policy.Sends("auction_params") {
+		minimized.AuctionParams = nil
+	}
+	if !policy.Sends("constraints") {
+		minimized.Constraints = nil
+	}
+	minimized.UserData = nil
+	for key, value := range r.UserData {
+		if !policy.SendsUserData(key) {
+			continue
+		}
+		if minimized.UserData == nil {
+			minimized.UserData = make(map[string]interface{}, len(r.UserData))
+		}
+		minimized.UserData[key] = value
+	}
+	return &minimized
+}
+
+// HashValue returns the salted SHA-256 hex digest of a value
+func HashValue(salt string, value interface{}) string {
+	sum := sha256.Sum256([]byte(salt + fmt.Sprint(value)))
+	return hex.EncodeToString(sum[:])
+}
+
+// MaskValue replaces all but the last few characters of a value with asterisks
+func MaskValue(value interface{}) string {
+	text := []rune(fmt.Sprint(value))
+	if len(text) <= maskVisibleChars {
+		return strings.Repeat("*", len(text))
+	}
+	return strings.Repeat("*", len(text)-maskVisibleChars) + string(text[len(text)-maskVisibleChars:])
+}
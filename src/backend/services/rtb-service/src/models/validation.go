@@ -0,0 +1,17 @@
+package models
+
+// Field validation failure reasons
+const (
+	FieldReasonRequired  = "required"
+	FieldReasonType      = "type"
+	FieldReasonPattern   = "pattern"
+	FieldReasonMaxLength = "max_length"
+	FieldReasonRange     = "range"
+)
+
+// FieldError reports why one request field failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
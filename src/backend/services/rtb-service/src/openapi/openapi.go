@@ -0,0 +1,281 @@
+// Package openapi builds the service's OpenAPI 3 document from the model structs, so the
+// published schemas follow the types the handlers actually encode and decode
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Version is the OpenAPI version of the documents built here
+const Version = "3.0.3"
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info describes the API
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations on a path
+type PathItem struct {
+	Get    *Operation `json:"get,omitempty"`
+	Post   *Operation `json:"post,omitempty"`
+	Put    *Operation `json:"put,omitempty"`
+	Delete *Operation `json:"delete,omitempty"`
+}
+
+// Operation is a single API operation
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Description string               `json:"description,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path, query, or header parameter
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is an operation's JSON request body
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is one status of an operation
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType holds the schema of a body
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Components holds the named schemas
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is an OpenAPI schema object. The empty schema accepts any value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []string           `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+}
+
+// JSON returns a JSON body of schema
+func JSON(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
+
+// refPrefix prefixes references to named schemas
+const refPrefix = "#/components/schemas/"
+
+// Builder derives schemas from Go types by reflection, following their JSON encoding. Structs
+// become named schemas; fields without omitempty are required in response schemas, since the
+// service always writes them, while request schemas require nothing the decoder would accept
+// without. Types with their own JSON encoding must be given a schema with Define, so a new
+// custom encoding cannot drift from the document unnoticed.
+type Builder struct {
+	schemas  map[string]*Schema
+	names    map[reflect.Type]string
+	defined  map[reflect.Type]*Schema
+	optional map[reflect.Type]bool
+	errs     []string
+}
+
+// NewBuilder creates an empty builder
+func NewBuilder() *Builder {
+	return &Builder{
+		schemas:  make(map[string]*Schema),
+		names:    make(map[reflect.Type]string),
+		defined:  make(map[reflect.Type]*Schema),
+		optional: make(map[reflect.Type]bool),
+	}
+}
+
+// Define sets the schema of a type with its own JSON encoding. Named schemas in it must be
+// added with Response or Request.
+func (b *Builder) Define(v interface{}, schema *Schema) {
+	b.defined[reflect.TypeOf(v)] = schema
+}
+
+// Optional marks every field of a struct type optional, for types whose responses may leave
+// fields out
+func (b *Builder) Optional(v interface{}) {
+	b.optional[reflect.TypeOf(v)] = true
+}
+
+// Response returns a schema for a value the service writes
+func (b *Builder) Response(v interface{}) *Schema {
+	return b.schema(reflect.TypeOf(v), true)
+}
+
+// Request returns a schema for a value the service reads
+func (b *Builder) Request(v interface{}) *Schema {
+	return b.schema(reflect.TypeOf(v), false)
+}
+
+// Schemas returns the named schemas, or an error naming every type that could not be described
+func (b *Builder) Schemas() (map[string]*Schema, error) {
+	if len(b.errs) > 0 {
+		sort.Strings(b.errs)
+		return nil, fmt.Errorf("openapi: %s", strings.Join(b.errs, "; "))
+	}
+	return b.schemas, nil
+}
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	durationType  = reflect.TypeOf(time.Duration(0))
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schema returns the schema of t, adding named schemas for the structs it contains
+func (b *Builder) schema(t reflect.Type, required bool) *Schema {
+	if t == nil {
+		return &Schema{}
+	}
+	nullable := false
+	for t.Kind() == reflect.Pointer {
+		t, nullable = t.Elem(), true
+	}
+	if defined, exists := b.defined[t]; exists {
+		return defined
+	}
+	if defined, exists := b.defined[reflect.PointerTo(t)]; exists {
+		return defined
+	}
+
+	var schema *Schema
+	switch {
+	case t == timeType:
+		schema = &Schema{Type: "string", Format: "date-time"}
+	case t == durationType:
+		schema = &Schema{Type: "integer", Format: "int64", Description: "Duration in nanoseconds"}
+	case t.Implements(marshalerType) || reflect.PointerTo(t).Implements(marshalerType):
+		b.errs = append(b.errs, fmt.Sprintf("%s has its own JSON encoding and no defined schema", t))
+		return &Schema{}
+	default:
+		schema = b.kindSchema(t, required)
+	}
+	if nullable && schema.Ref == "" {
+		schema.Nullable = true
+	}
+	return schema
+}
+
+// kindSchema returns the schema of a type by kind
+func (b *Builder) kindSchema(t reflect.Type, required bool) *Schema {
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: b.schema(t.Elem(), required)}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: b.schema(t.Elem(), required)}
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Struct:
+		return &Schema{Ref: refPrefix + b.structName(t, required)}
+	default:
+		b.errs = append(b.errs, fmt.Sprintf("%s cannot be encoded as JSON", t))
+		return &Schema{}
+	}
+}
+
+// structName returns the name of a struct's schema, adding the schema on first use. A struct
+// reached from both a request and a response requires none of its fields.
+func (b *Builder) structName(t reflect.Type, required bool) string {
+	if name, exists := b.names[t]; exists {
+		if !required {
+			b.schemas[name].Required = nil
+		}
+		return name
+	}
+
+	name := t.Name()
+	if name == "" {
+		name = "Anonymous"
+	}
+	name = strings.ToUpper(name[:1]) + name[1:]
+	if _, taken := b.schemas[name]; taken {
+		pkg := path.Base(t.PkgPath())
+		name = strings.ToUpper(pkg[:1]) + pkg[1:] + name
+	}
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	b.names[t] = name
+	b.schemas[name] = schema
+	b.addFields(schema, t, required && !b.optional[t])
+	sort.Strings(schema.Required)
+	return name
+}
+
+// addFields adds a struct's exported fields to schema, flattening embedded structs as encoding/json does
+func (b *Builder) addFields(schema *Schema, t reflect.Type, required bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			b.addFields(schema, field.Type, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = b.schema(field.Type, required)
+		if required && !strings.Contains(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
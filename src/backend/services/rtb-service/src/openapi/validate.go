@@ -0,0 +1,129 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Validate checks a JSON body against a schema of the document. It is stricter than the
+// service's decoder: properties the schema does not list are errors, so a field added to a
+// model without reaching the document is caught.
+func (d *Document) Validate(schema *Schema, data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return err
+	}
+	return d.validate(schema, value, "$")
+}
+
+// validate checks a decoded value against a schema, naming the failing value by its path
+func (d *Document) validate(schema *Schema, value interface{}, at string) error {
+	if schema.Ref != "" {
+		resolved, exists := d.Components.Schemas[strings.TrimPrefix(schema.Ref, refPrefix)]
+		if !exists {
+			return fmt.Errorf("%s: unknown schema %s", at, schema.Ref)
+		}
+		schema = resolved
+	}
+	if value == nil {
+		if schema.Type == "" || schema.Nullable || schema.Type == "object" || schema.Type == "array" {
+			return nil
+		}
+		return fmt.Errorf("%s: null is not a %s", at, schema.Type)
+	}
+
+	switch schema.Type {
+	case "":
+		return nil
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fmt.Errorf("%s: expected a boolean", at)
+		}
+	case "integer":
+		number, ok := value.(json.Number)
+		if !ok {
+			return fmt.Errorf("%s: expected an integer", at)
+		}
+		if _, err := number.Int64(); err != nil {
+			return fmt.Errorf("%s: expected an integer, got %s", at, number)
+		}
+	case "number":
+		if _, ok := value.(json.Number); !ok {
+			return fmt.Errorf("%s: expected a number", at)
+		}
+	case "string":
+		text, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("%s: expected a string", at)
+		}
+		if schema.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339Nano, text); err != nil {
+				return fmt.Errorf("%s: expected an RFC 3339 date-time", at)
+			}
+		}
+		if len(schema.Enum) > 0 && !contains(schema.Enum, text) {
+			return fmt.Errorf("%s: %q is not one of %v", at, text, schema.Enum)
+		}
+	case "array":
+		items, ok := value.([]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an array", at)
+		}
+		for i, item := range items {
+			if err := d.validate(schema.Items, item, fmt.Sprintf("%s[%d]", at, i)); err != nil {
+				return err
+			}
+		}
+	case "object":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%s: expected an object", at)
+		}
+		return d.validateObject(schema, object, at)
+	}
+	return nil
+}
+
+// validateObject checks an object's required, listed, and additional properties
+func (d *Document) validateObject(schema *Schema, object map[string]interface{}, at string) error {
+	for _, name := range schema.Required {
+		if _, exists := object[name]; !exists {
+			return fmt.Errorf("%s: missing required property %s", at, name)
+		}
+	}
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		property, listed := schema.Properties[name]
+		switch {
+		case listed:
+		case schema.AdditionalProperties != nil:
+			property = schema.AdditionalProperties
+		default:
+			return fmt.Errorf("%s: unexpected property %s", at, name)
+		}
+		if err := d.validate(property, object[name], at+"."+name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// contains reports whether values holds value
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,176 @@
+package reconcile
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PartnerRecord is one lead a partner says it bought, as read from its CSV
+type PartnerRecord struct {
+	Line      int       `json:"line"`
+	BidID     string    `json:"bid_id,omitempty"`
+	RequestID string    `json:"request_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	Amount    float64   `json:"amount"`
+	Vertical  string    `json:"vertical,omitempty"`
+}
+
+// ParseError is a partner CSV that cannot be read, with the 1-based line of the problem
+type ParseError struct {
+	Line int
+	Msg  string
+}
+
+// Error returns the line and the problem
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+// columnAliases maps the header names a partner CSV may use to the column they fill
+var columnAliases = map[string]string{
+	"bid_id":         "bid_id",
+	"request_id":     "request_id",
+	"timestamp":      "timestamp",
+	"date":           "timestamp",
+	"amount":         "amount",
+	"price":          "amount",
+	"clearing_price": "amount",
+	"vertical":       "vertical",
+}
+
+// timestampLayouts are the accepted timestamp formats; a date alone is midnight UTC
+var timestampLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02"}
+
+// ParseCSV reads a partner's records. The first row is a header naming the columns in any case
+// and order: bid_id and request_id, at least one of which each row must fill, date or timestamp,
+// amount (or price or clearing_price), and an optional vertical. Other columns are ignored.
+func ParseCSV(r io.Reader) ([]PartnerRecord, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, &ParseError{Line: 1, Msg: "missing header"}
+	}
+	if err != nil {
+		return nil, csvError(err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		column, known := columnAliases[name]
+		if !known {
+			continue
+		}
+		if _, exists := columns[column]; exists {
+			return nil, &ParseError{Line: 1, Msg: fmt.Sprintf("more than one %s column", column)}
+		}
+		columns[column] = i
+	}
+	switch {
+	case !hasColumn(columns, "bid_id") && !hasColumn(columns, "request_id"):
+		return nil, &ParseError{Line: 1, Msg: "header needs a bid_id or request_id column"}
+	case !hasColumn(columns, "timestamp"):
+		return nil, &ParseError{Line: 1, Msg: "header needs a date or timestamp column"}
+	case !hasColumn(columns, "amount"):
+		return nil, &ParseError{Line: 1, Msg: "header needs an amount column"}
+	}
+
+	var records []PartnerRecord
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		}
+		if err != nil {
+			return nil, csvError(err)
+		}
+		line, _ := reader.FieldPos(0)
+		if isBlankRow(row) {
+			continue
+		}
+		record, err := parseRow(row, columns)
+		if err != nil {
+			return nil, &ParseError{Line: line, Msg: err.Error()}
+		}
+		record.Line = line
+		records = append(records, record)
+	}
+}
+
+// parseRow reads one data row
+func parseRow(row []string, columns map[string]int) (PartnerRecord, error) {
+	field := func(column string) string {
+		i, exists := columns[column]
+		if !exists || i >= len(row) {
+			return ""
+		}
+		return strings.TrimSpace(row[i])
+	}
+
+	record := PartnerRecord{BidID: field("bid_id"), RequestID: field("request_id"), Vertical: field("vertical")}
+	if record.BidID == "" && record.RequestID == "" {
+		return record, errors.New("needs a bid_id or request_id")
+	}
+
+	timestamp, err := parseTimestamp(field("timestamp"))
+	if err != nil {
+		return record, err
+	}
+	record.Timestamp = timestamp
+
+	amount := strings.TrimPrefix(field("amount"), "$")
+	if amount == "" {
+		return record, errors.New("missing amount")
+	}
+	record.Amount, err = strconv.ParseFloat(amount, 64)
+	if err != nil || math.IsNaN(record.Amount) || math.IsInf(record.Amount, 0) || record.Amount < 0 {
+		return record, fmt.Errorf("invalid amount %q", field("amount"))
+	}
+	return record, nil
+}
+
+// parseTimestamp reads a timestamp in any accepted layout, in UTC when it has no zone
+func parseTimestamp(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("missing date")
+	}
+	for _, layout := range timestampLayouts {
+		if timestamp, err := time.Parse(layout, value); err == nil {
+			return timestamp.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or an RFC 3339 timestamp", value)
+}
+
+// hasColumn reports whether the header named a column
+func hasColumn(columns map[string]int, column string) bool {
+	_, exists := columns[column]
+	return exists
+}
+
+// isBlankRow reports whether every field of a row is empty
+func isBlankRow(row []string) bool {
+	for _, field := range row {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// csvError converts an encoding/csv error to a ParseError
+func csvError(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &ParseError{Line: parseErr.StartLine, Msg: parseErr.Err.Error()}
+	}
+	return err
+}
@@ -0,0 +1,423 @@
+// Package reconcile totals a partner's winning bids from the audit log by day and vertical, and
+// compares them with the partner's own records of the leads it bought so invoice disputes can be
+// traced to the bids behind them. Days are UTC days and amounts are costs per lead.
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/audit"
+)
+
+// DefaultTolerance is how far two amounts may differ and still agree
+const DefaultTolerance = 0.01
+
+// DayLayout formats the UTC day records are bucketed by
+const DayLayout = "2006-01-02"
+
+// amountUnits counts money in millionths so sums of many amounts do not drift
+const amountUnits = 1e6
+
+// Discrepancy kinds
+const (
+	// DiscrepancyAmountMismatch is a win the partner recorded at a different amount
+	DiscrepancyAmountMismatch = "amount_mismatch"
+	// DiscrepancyMissingFromPartner is a win the partner did not record
+	DiscrepancyMissingFromPartner = "missing_from_partner"
+	// DiscrepancyMissingFromOurs is a partner record that matches no win in the audit log
+	DiscrepancyMissingFromOurs = "missing_from_ours"
+	// DiscrepancyDuplicate is a partner record for a win an earlier record already matched
+	DiscrepancyDuplicate = "duplicate"
+)
+
+// How a partner record matched a win
+const (
+	MatchedByBidID     = "bid_id"
+	MatchedByRequestID = "request_id"
+)
+
+// ErrInvalidWindow is returned for a report window that cannot be parsed or is empty
+var ErrInvalidWindow = errors.New("invalid report window")
+
+// Window is the time range [From, To) a report covers
+type Window struct {
+	From time.Time
+	To   time.Time
+}
+
+// Contains reports whether t falls in the window
+func (w Window) Contains(t time.Time) bool {
+	return !t.Before(w.From) && t.Before(w.To)
+}
+
+// ParseWindow parses the bounds of a report window, each a date or an RFC 3339 timestamp. A date
+// as the end includes that whole day.
+func ParseWindow(from, to string) (Window, error) {
+	if from == "" || to == "" {
+		return Window{}, fmt.Errorf("%w: from and to are required", ErrInvalidWindow)
+	}
+	var window Window
+	var err error
+	if window.From, err = parseTimestamp(from); err != nil {
+		return Window{}, fmt.Errorf("%w: from: %v", ErrInvalidWindow, err)
+	}
+	if window.To, err = parseTimestamp(to); err != nil {
+		return Window{}, fmt.Errorf("%w: to: %v", ErrInvalidWindow, err)
+	}
+	if len(to) == len(DayLayout) {
+		window.To = window.To.AddDate(0, 0, 1)
+	}
+	if !window.To.After(window.From) {
+		return Window{}, fmt.Errorf("%w: to must be after from", ErrInvalidWindow)
+	}
+	return window, nil
+}
+
+// WindowOf returns the whole days covered by a partner's records, or false when there are none
+func WindowOf(records []PartnerRecord) (Window, bool) {
+	if len(records) == 0 {
+		return Window{}, false
+	}
+	first, last := records[0].Timestamp, records[0].Timestamp
+	for _, record := range records[1:] {
+		if record.Timestamp.Before(first) {
+			first = record.Timestamp
+		}
+		if record.Timestamp.After(last) {
+			last = record.Timestamp
+		}
+	}
+	return Window{From: startOfDay(first), To: startOfDay(last).AddDate(0, 0, 1)}, true
+}
+
+// SpendBucket totals a partner's winning bids for one day and vertical
+type SpendBucket struct {
+	Day      string  `json:"day"`
+	Vertical string  `json:"vertical"`
+	Wins     int     `json:"wins"`
+	Spend    float64 `json:"spend"`
+}
+
+// SpendReport is what a partner was charged over a window, by day and vertical
+type SpendReport struct {
+	PartnerID string        `json:"partner_id"`
+	From      time.Time     `json:"from"`
+	To        time.Time     `json:"to"`
+	Wins      int           `json:"wins"`
+	Spend     float64       `json:"spend"`
+	Buckets   []SpendBucket `json:"buckets"`
+}
+
+// Spend totals a partner's winning bids in a window by day and vertical, ordered by day and then
+// vertical. Wins are the partner's audit records in the window.
+func Spend(partnerID string, window Window, wins []audit.Record) *SpendReport {
+	type bucketKey struct {
+		day      string
+		vertical string
+	}
+	type bucketTotal struct {
+		wins  int
+		units int64
+	}
+
+	totals := make(map[bucketKey]*bucketTotal)
+	var totalUnits int64
+	for _, win := range wins {
+		key := bucketKey{day: dayOf(win.Timestamp), vertical: win.Vertical}
+		total := totals[key]
+		if total == nil {
+			total = &bucketTotal{}
+			totals[key] = total
+		}
+		total.wins++
+		total.units += toUnits(win.ClearingPrice)
+		totalUnits += toUnits(win.ClearingPrice)
+	}
+
+	report := &SpendReport{PartnerID: partnerID, From: window.From, To: window.To, Wins: len(wins), Spend: fromUnits(totalUnits), Buckets: make([]SpendBucket, 0, len(totals))}
+	for key, total := range totals {
+		report.Buckets = append(report.Buckets, SpendBucket{Day: key.day, Vertical: key.vertical, Wins: total.wins, Spend: fromUnits(total.units)})
+	}
+	sort.Slice(report.Buckets, func(i, j int) bool {
+		if report.Buckets[i].Day != report.Buckets[j].Day {
+			return report.Buckets[i].Day < report.Buckets[j].Day
+		}
+		return report.Buckets[i].Vertical < report.Buckets[j].Vertical
+	})
+	return report
+}
+
+// Discrepancy is a win or partner record the two sides disagree on. Line is the partner record's
+// CSV line, and Difference is the partner's amount less ours.
+type Discrepancy struct {
+	Kind          string  `json:"kind"`
+	BidID         string  `json:"bid_id,omitempty"`
+	RequestID     string  `json:"request_id,omitempty"`
+	Line          int     `json:"line,omitempty"`
+	MatchedBy     string  `json:"matched_by,omitempty"`
+	OurAmount     float64 `json:"our_amount"`
+	PartnerAmount float64 `json:"partner_amount"`
+	Difference    float64 `json:"difference"`
+}
+
+// DayResult compares one day's wins with the partner's records for it
+type DayResult struct {
+	Day            string        `json:"day"`
+	OurWins        int           `json:"our_wins"`
+	OurAmount      float64       `json:"our_amount"`
+	PartnerRecords int           `json:"partner_records"`
+	PartnerAmount  float64       `json:"partner_amount"`
+	Difference     float64       `json:"difference"`
+	Discrepancies  []Discrepancy `json:"discrepancies"`
+}
+
+// Result compares a partner's wins with its records over a window. Days lists only the days whose
+// totals differ by more than the tolerance or that have a discrepancy.
+type Result struct {
+	PartnerID      string      `json:"partner_id"`
+	From           time.Time   `json:"from"`
+	To             time.Time   `json:"to"`
+	Tolerance      float64     `json:"tolerance"`
+	OurWins        int         `json:"our_wins"`
+	OurAmount      float64     `json:"our_amount"`
+	PartnerRecords int         `json:"partner_records"`
+	PartnerAmount  float64     `json:"partner_amount"`
+	Difference     float64     `json:"difference"`
+	Matched        int         `json:"matched"`
+	OutsideWindow  int         `json:"outside_window"`
+	Days           []DayResult `json:"days"`
+}
+
+// Reconcile matches a partner's records to its wins in a window and classifies every record and
+// win the two sides disagree on. Wins are the partner's audit records in the window; partner
+// records outside it are counted and otherwise ignored.
+//
+// Records are matched by bid ID first, only to a win of the same request when the record names
+// one, because partners may reuse bid IDs across auctions. Records left over are matched
+// by request ID. A record whose only matches were already taken by earlier records is a duplicate.
+func Reconcile(partnerID string, window Window, wins []audit.Record, records []PartnerRecord, tolerance float64) *Result {
+	result := &Result{PartnerID: partnerID, From: window.From, To: window.To, Tolerance: tolerance, OurWins: len(wins)}
+	toleranceUnits := toUnits(tolerance)
+
+	inWindow := make([]PartnerRecord, 0, len(records))
+	for _, record := range records {
+		if window.Contains(record.Timestamp) {
+			inWindow = append(inWindow, record)
+		} else {
+			result.OutsideWindow++
+		}
+	}
+	result.PartnerRecords = len(inWindow)
+
+	days := make(map[string]*dayTotal)
+	day := func(name string) *dayTotal {
+		total := days[name]
+		if total == nil {
+			total = &dayTotal{}
+			days[name] = total
+		}
+		return total
+	}
+	var ourUnits, partnerUnits int64
+	for _, win := range wins {
+		units := toUnits(win.ClearingPrice)
+		day(dayOf(win.Timestamp)).addOurs(units)
+		ourUnits += units
+	}
+	for _, record := range inWindow {
+		units := toUnits(record.Amount)
+		day(dayOf(record.Timestamp)).addPartner(units)
+		partnerUnits += units
+	}
+	result.OurAmount, result.PartnerAmount, result.Difference = fromUnits(ourUnits), fromUnits(partnerUnits), fromUnits(partnerUnits-ourUnits)
+
+	m := newMatcher(wins)
+	matches := m.matchAll(inWindow)
+	for i, record := range inWindow {
+		match := matches[i]
+		recordUnits := toUnits(record.Amount)
+		discrepancy := Discrepancy{BidID: record.BidID, RequestID: record.RequestID, Line: record.Line, MatchedBy: match.by, PartnerAmount: record.Amount}
+		dayName := dayOf(record.Timestamp)
+		switch {
+		case match.index < 0:
+			discrepancy.Kind = DiscrepancyMissingFromOurs
+			discrepancy.Difference = record.Amount
+		case match.duplicate:
+			win := wins[match.index]
+			discrepancy.Kind = DiscrepancyDuplicate
+			discrepancy.BidID, discrepancy.RequestID = win.BidID, win.RequestID
+			discrepancy.Difference = record.Amount
+			dayName = dayOf(win.Timestamp)
+		default:
+			win := wins[match.index]
+			result.Matched++
+			winUnits := toUnits(win.ClearingPrice)
+			if abs(recordUnits-winUnits) <= toleranceUnits {
+				continue
+			}
+			discrepancy.Kind = DiscrepancyAmountMismatch
+			discrepancy.BidID, discrepancy.RequestID = win.BidID, win.RequestID
+			discrepancy.OurAmount = fromUnits(winUnits)
+			discrepancy.Difference = fromUnits(recordUnits - winUnits)
+			dayName = dayOf(win.Timestamp)
+		}
+		day(dayName).discrepancies = append(day(dayName).discrepancies, discrepancy)
+	}
+	for i, win := range wins {
+		if m.claimed[i] {
+			continue
+		}
+		dayName := dayOf(win.Timestamp)
+		day(dayName).discrepancies = append(day(dayName).discrepancies, Discrepancy{
+			Kind:       DiscrepancyMissingFromPartner,
+			BidID:      win.BidID,
+			RequestID:  win.RequestID,
+			OurAmount:  win.ClearingPrice,
+			Difference: -win.ClearingPrice,
+		})
+	}
+
+	result.Days = make([]DayResult, 0, len(days))
+	for name, total := range days {
+		if len(total.discrepancies) == 0 && abs(total.partnerUnits-total.ourUnits) <= toleranceUnits {
+			continue
+		}
+		result.Days = append(result.Days, DayResult{
+			Day:            name,
+			OurWins:        total.ourWins,
+			OurAmount:      fromUnits(total.ourUnits),
+			PartnerRecords: total.partnerRecords,
+			PartnerAmount:  fromUnits(total.partnerUnits),
+			Difference:     fromUnits(total.partnerUnits - total.ourUnits),
+			Discrepancies:  total.discrepancies,
+		})
+	}
+	sort.Slice(result.Days, func(i, j int) bool {
+		return result.Days[i].Day < result.Days[j].Day
+	})
+	return result
+}
+
+// dayTotal accumulates one day of a reconciliation
+type dayTotal struct {
+	ourWins        int
+	ourUnits       int64
+	partnerRecords int
+	partnerUnits   int64
+	discrepancies  []Discrepancy
+}
+
+func (d *dayTotal) addOurs(units int64) {
+	d.ourWins++
+	d.ourUnits += units
+}
+
+func (d *dayTotal) addPartner(units int64) {
+	d.partnerRecords++
+	d.partnerUnits += units
+}
+
+// match is the win a partner record matched, with index -1 when it matched none
+type match struct {
+	index     int
+	by        string
+	duplicate bool
+}
+
+// matcher pairs partner records with wins, each win with at most one record
+type matcher struct {
+	wins        []audit.Record
+	claimed     []bool
+	byBidID     map[string][]int
+	byRequestID map[string][]int
+}
+
+// newMatcher indexes wins by bid ID and request ID, keeping their order
+func newMatcher(wins []audit.Record) *matcher {
+	m := &matcher{wins: wins, claimed: make([]bool, len(wins)), byBidID: make(map[string][]int), byRequestID: make(map[string][]int)}
+	for i, win := range wins {
+		if win.BidID != "" {
+			m.byBidID[win.BidID] = append(m.byBidID[win.BidID], i)
+		}
+		if win.RequestID != "" {
+			m.byRequestID[win.RequestID] = append(m.byRequestID[win.RequestID], i)
+		}
+	}
+	return m
+}
+
+// matchAll matches every record by bid ID and then the records left over by request ID, so a
+// request ID fallback never takes a win a later record names by bid ID
+func (m *matcher) matchAll(records []PartnerRecord) []match {
+	matches := make([]match, len(records))
+	for i, record := range records {
+		matches[i] = match{index: -1}
+		if record.BidID == "" {
+			continue
+		}
+		if index, duplicate, found := m.claim(m.byBidID[record.BidID], record.RequestID); found {
+			matches[i] = match{index: index, by: MatchedByBidID, duplicate: duplicate}
+		}
+	}
+	for i, record := range records {
+		if matches[i].index >= 0 || record.RequestID == "" {
+			continue
+		}
+		if index, duplicate, found := m.claim(m.byRequestID[record.RequestID], ""); found {
+			matches[i] = match{index: index, by: MatchedByRequestID, duplicate: duplicate}
+		}
+	}
+	return matches
+}
+
+// claim takes the first unclaimed candidate of requestID, or of any request when it is empty. When
+// every such candidate is taken it returns the first as a duplicate.
+func (m *matcher) claim(candidates []int, requestID string) (int, bool, bool) {
+	first := -1
+	for _, i := range candidates {
+		if requestID != "" && m.wins[i].RequestID != requestID {
+			continue
+		}
+		if !m.claimed[i] {
+			m.claimed[i] = true
+			return i, false, true
+		}
+		if first < 0 {
+			first = i
+		}
+	}
+	return first, true, first >= 0
+}
+
+// dayOf returns the UTC day of t
+func dayOf(t time.Time) string {
+	return t.UTC().Format(DayLayout)
+}
+
+// startOfDay returns the start of the UTC day of t
+func startOfDay(t time.Time) time.Time {
+	year, month, day := t.UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// toUnits converts an amount to millionths
+func toUnits(amount float64) int64 {
+	return int64(math.Round(amount * amountUnits))
+}
+
+// fromUnits converts millionths to an amount
+func fromUnits(units int64) float64 {
+	return float64(units) / amountUnits
+}
+
+// abs returns the size of a difference in millionths
+func abs(units int64) int64 {
+	if units < 0 {
+		return -units
+	}
+	return units
+}
@@ -0,0 +1,185 @@
+// Package replay re-runs recorded auctions against their recorded partner responses through the
+// current optimizer and config, and compares the outcome with the recorded winners
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// ErrUnsupportedVersion is returned for recordings written in an unknown format version
+var ErrUnsupportedVersion = errors.New("unsupported recording version")
+
+// Result is the outcome of replaying one recording
+type Result struct {
+	RequestID          string                  `json:"request_id"`
+	RecordedAt         time.Time               `json:"recorded_at"`
+	Region             string                  `json:"region,omitempty"`
+	Original           []models.RecordedWinner `json:"original"`
+	Replayed           []models.RecordedWinner `json:"replayed"`
+	OriginalError      string                  `json:"original_error,omitempty"`
+	Error              string                  `json:"error,omitempty"`
+	UnrecordedPartners []string                `json:"unrecorded_partners,omitempty"`
+	Diff               Diff                    `json:"diff"`
+}
+
+// Diff describes how the replayed winners differ from the recorded ones. Winners are matched by
+// partner and bid ID; RevenueDelta is the replayed clearing total minus the recorded one.
+type Diff struct {
+	Changed      bool                    `json:"changed"`
+	Added        []models.RecordedWinner `json:"added"`
+	Removed      []models.RecordedWinner `json:"removed"`
+	PriceChanges []PriceChange           `json:"price_changes"`
+	RevenueDelta float64                 `json:"revenue_delta"`
+}
+
+// PriceChange is a bid that won in both runs at different clearing prices
+type PriceChange struct {
+	PartnerID string  `json:"partner_id"`
+	BidID     string  `json:"bid_id"`
+	Original  float64 `json:"original"`
+	Replayed  float64 `json:"replayed"`
+}
+
+// Read decodes a stream of recordings, such as a recording file or a single JSON recording
+func Read(r io.Reader) ([]*models.Recording, error) {
+	decoder := json.NewDecoder(r)
+	var recordings []*models.Recording
+	for {
+		recording := &models.Recording{}
+		if err := decoder.Decode(recording); err == io.EOF {
+			return recordings, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("decoding recording %d: %w", len(recordings)+1, err)
+		}
+		if recording.Version != models.RecordingVersion {
+			return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, recording.Version)
+		}
+		if recording.Request == nil {
+			return nil, fmt.Errorf("decoding recording %d: missing request", len(recordings)+1)
+		}
+		recordings = append(recordings, recording)
+	}
+}
+
+// Replayer replays recordings under a config. Each replay runs on its own auction service whose
+// clock is fixed at the recording time, so schedules, multipliers, circuit breakers, and rate
+// limits behave as they would have then rather than reflecting live state.
+type Replayer struct {
+	config *config.Config
+}
+
+// NewReplayer creates a replayer for cfg. Audit logging, webhooks, recording, enrichment, price
+// analytics, and Redis are left out of replays; recorded requests are already enriched.
+func NewReplayer(cfg *config.Config) *Replayer {
+	replayConfig := *cfg
+	replayConfig.Audit = nil
+	replayConfig.Webhooks = nil
+	replayConfig.Recording = nil
+	replayConfig.Enrichment = nil
+	replayConfig.Analytics = nil
+	replayConfig.Redis = nil
+	replayConfig.Idempotency = nil
+	return &Replayer{config: &replayConfig}
+}
+
+// Replay re-runs a recorded auction and compares its winners with the recorded ones. Auctions
+// recorded in a service region are replayed as that region, so partner region preferences apply
+// as they did when recorded, whichever region replays them.
+func (r *Replayer) Replay(ctx context.Context, recording *models.Recording) (*Result, error) {
+	cfg := r.config
+	if recording.Region != "" && recording.Region != cfg.ServiceRegion {
+		regional := *cfg
+		regional.ServiceRegion = recording.Region
+		cfg = &regional
+	}
+	service, err := services.NewAuctionServiceWithClock(cfg, fixedClock{recording.RecordedAt})
+	if err != nil {
+		return nil, err
+	}
+	defer service.Close()
+
+	replay := models.NewReplay(recording)
+	ctx = models.ContextWithReplay(ctx, replay)
+	ctx = models.ContextWithDryRun(ctx, models.NewDryRun())
+	ctx, cancel := context.WithTimeout(ctx, r.config.AuctionTimeout(recording.Request.Vertical))
+	defer cancel()
+
+	request := *recording.Request
+	result := &Result{
+		RequestID:     request.RequestID,
+		RecordedAt:    recording.RecordedAt,
+		Region:        recording.Region,
+		Original:      recording.Winners,
+		Replayed:      []models.RecordedWinner{},
+		OriginalError: recording.Error,
+	}
+	response, err := service.RunAuction(ctx, &request)
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		result.Replayed = models.WinnersOf(response.Bids)
+	}
+	result.UnrecordedPartners = replay.Missing()
+	sort.Strings(result.UnrecordedPartners)
+	result.Diff = Compare(result.Original, result.Replayed)
+	return result, nil
+}
+
+// Compare diffs replayed winners against the originally recorded ones
+func Compare(original, replayed []models.RecordedWinner) Diff {
+	type winnerKey struct{ partnerID, bidID string }
+	recorded := make(map[winnerKey]models.RecordedWinner, len(original))
+	var originalTotal, replayedTotal float64
+	for _, winner := range original {
+		recorded[winnerKey{winner.PartnerID, winner.BidID}] = winner
+		originalTotal += winner.Price
+	}
+
+	diff := Diff{
+		Added:        []models.RecordedWinner{},
+		Removed:      []models.RecordedWinner{},
+		PriceChanges: []PriceChange{},
+	}
+	for _, winner := range replayed {
+		replayedTotal += winner.Price
+		key := winnerKey{winner.PartnerID, winner.BidID}
+		previous, won := recorded[key]
+		if !won {
+			diff.Added = append(diff.Added, winner)
+			continue
+		}
+		delete(recorded, key)
+		if previous.Price != winner.Price {
+			diff.PriceChanges = append(diff.PriceChanges, PriceChange{PartnerID: winner.PartnerID, BidID: winner.BidID, Original: previous.Price, Replayed: winner.Price})
+		}
+	}
+	for _, winner := range original {
+		if _, unmatched := recorded[winnerKey{winner.PartnerID, winner.BidID}]; unmatched {
+			diff.Removed = append(diff.Removed, winner)
+		}
+	}
+
+	diff.RevenueDelta = math.Round((replayedTotal-originalTotal)*100) / 100
+	diff.Changed = len(diff.Added) > 0 || len(diff.Removed) > 0 || len(diff.PriceChanges) > 0
+	return diff
+}
+
+// fixedClock reports the recording time
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
@@ -0,0 +1,83 @@
+// Package rtbclient provides a Go client for the RTB service gRPC interface
+// Version: 1.0.0
+package rtbclient
+
+import (
+	"context"
+
+	"google.golang.org/grpc"        // v1.59.0
+	"google.golang.org/grpc/status" // v1.59.0
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/rtbpb"
+)
+
+// Client wraps the generated RTBService client with model conversions
+type Client struct {
+	conn *grpc.ClientConn
+	rpc  rtbpb.RTBServiceClient
+}
+
+// Dial connects to an RTB service gRPC endpoint
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient creates a Client on an existing connection
+func NewClient(conn *grpc.ClientConn) *Client {
+	return &Client{
+		conn: conn,
+		rpc:  rtbpb.NewRTBServiceClient(conn),
+	}
+}
+
+// RunAuction runs an auction; the context deadline bounds the auction timeout on the server
+func (c *Client) RunAuction(ctx context.Context, request *models.BidRequest, opts ...grpc.CallOption) (*models.BidResponse, error) {
+	req, err := rtbpb.BidRequestFromModel(request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.rpc.RunAuction(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return resp.ToModel(), nil
+}
+
+// GetPartnerStats returns partner failure counts
+func (c *Client) GetPartnerStats(ctx context.Context, opts ...grpc.CallOption) (map[string]int, error) {
+	resp, err := c.rpc.GetPartnerStats(ctx, &rtbpb.GetPartnerStatsRequest{}, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]int, len(resp.GetFailures()))
+	for partnerID, count := range resp.GetFailures() {
+		stats[partnerID] = int(count)
+	}
+	return stats, nil
+}
+
+// Close closes the underlying connection
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// ErrorCode extracts the auction ErrorCode from a gRPC error, if present
+func ErrorCode(err error) rtbpb.ErrorCode {
+	st, ok := status.FromError(err)
+	if !ok {
+		return rtbpb.ErrorCode_ERROR_CODE_UNSPECIFIED
+	}
+	for _, detail := range st.Details() {
+		if errorDetail, ok := detail.(*rtbpb.ErrorDetail); ok {
+			return errorDetail.GetCode()
+		}
+	}
+	return rtbpb.ErrorCode_ERROR_CODE_UNSPECIFIED
+}
@@ -0,0 +1,150 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.3
+// source: auction.proto
+
+package rtbpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	RTBService_RunAuction_FullMethodName      = "/insurance.rtb.auction.v1.RTBService/RunAuction"
+	RTBService_GetPartnerStats_FullMethodName = "/insurance.rtb.auction.v1.RTBService/GetPartnerStats"
+)
+
+// RTBServiceClient is the client API for RTBService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RTBServiceClient interface {
+	// RunAuction collects partner bids and returns the optimized winners
+	RunAuction(ctx context.Context, in *BidRequest, opts ...grpc.CallOption) (*BidResponse, error)
+	// GetPartnerStats returns partner failure statistics
+	GetPartnerStats(ctx context.Context, in *GetPartnerStatsRequest, opts ...grpc.CallOption) (*GetPartnerStatsResponse, error)
+}
+
+type rTBServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRTBServiceClient(cc grpc.ClientConnInterface) RTBServiceClient {
+	return &rTBServiceClient{cc}
+}
+
+func (c *rTBServiceClient) RunAuction(ctx context.Context, in *BidRequest, opts ...grpc.CallOption) (*BidResponse, error) {
+	out := new(BidResponse)
+	err := c.cc.Invoke(ctx, RTBService_RunAuction_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rTBServiceClient) GetPartnerStats(ctx context.Context, in *GetPartnerStatsRequest, opts ...grpc.CallOption) (*GetPartnerStatsResponse, error) {
+	out := new(GetPartnerStatsResponse)
+	err := c.cc.Invoke(ctx, RTBService_GetPartnerStats_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// RTBServiceServer is the server API for RTBService service.
+// All implementations must embed UnimplementedRTBServiceServer
+// for forward compatibility
+type RTBServiceServer interface {
+	// RunAuction collects partner bids and returns the optimized winners
+	RunAuction(context.Context, *BidRequest) (*BidResponse, error)
+	// GetPartnerStats returns partner failure statistics
+	GetPartnerStats(context.Context, *GetPartnerStatsRequest) (*GetPartnerStatsResponse, error)
+	mustEmbedUnimplementedRTBServiceServer()
+}
+
+// UnimplementedRTBServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedRTBServiceServer struct {
+}
+
+func (UnimplementedRTBServiceServer) RunAuction(context.Context, *BidRequest) (*BidResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RunAuction not implemented")
+}
+func (UnimplementedRTBServiceServer) GetPartnerStats(context.Context, *GetPartnerStatsRequest) (*GetPartnerStatsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetPartnerStats not implemented")
+}
+func (UnimplementedRTBServiceServer) mustEmbedUnimplementedRTBServiceServer() {}
+
+// UnsafeRTBServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RTBServiceServer will
+// result in compilation errors.
+type UnsafeRTBServiceServer interface {
+	mustEmbedUnimplementedRTBServiceServer()
+}
+
+func RegisterRTBServiceServer(s grpc.ServiceRegistrar, srv RTBServiceServer) {
+	s.RegisterService(&RTBService_ServiceDesc, srv)
+}
+
+func _RTBService_RunAuction_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BidRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RTBServiceServer).RunAuction(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RTBService_RunAuction_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RTBServiceServer).RunAuction(ctx, req.(*BidRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RTBService_GetPartnerStats_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetPartnerStatsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RTBServiceServer).GetPartnerStats(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RTBService_GetPartnerStats_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RTBServiceServer).GetPartnerStats(ctx, req.(*GetPartnerStatsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// RTBService_ServiceDesc is the grpc.ServiceDesc for RTBService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RTBService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "insurance.rtb.auction.v1.RTBService",
+	HandlerType: (*RTBServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "RunAuction",
+			Handler:    _RTBService_RunAuction_Handler,
+		},
+		{
+			MethodName: "GetPartnerStats",
+			Handler:    _RTBService_GetPartnerStats_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "auction.proto",
+}
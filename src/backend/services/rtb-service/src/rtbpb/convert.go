@@ -0,0 +1,227 @@
+// Package rtbpb contains the generated gRPC bindings for the auction service and
+// conversions between the protobuf messages and the service models.
+package rtbpb
+
+import (
+	"math"
+	"time"
+
+	"google.golang.org/protobuf/types/known/durationpb"  // v1.34.2
+	"google.golang.org/protobuf/types/known/structpb"    // v1.34.2
+	"google.golang.org/protobuf/types/known/timestamppb" // v1.34.2
+
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// DefaultCurrency is the currency for all bid prices
+const DefaultCurrency = "USD"
+
+// NewMoney converts a decimal price into Money
+func NewMoney(amount float64) *Money {
+	units, frac := math.Modf(amount)
+	return &Money{
+		CurrencyCode: DefaultCurrency,
+		Units:        int64(units),
+		Nanos:        int32(math.Round(frac * 1e9)),
+	}
+}
+
+// Float64 returns the Money amount as a decimal
+func (m *Money) Float64() float64 {
+	if m == nil {
+		return 0
+	}
+	return float64(m.Units) + float64(m.Nanos)/1e9
+}
+
+// BidRequestFromModel converts a models.BidRequest into its protobuf form
+func BidRequestFromModel(request *models.BidRequest) (*BidRequest, error) {
+	userData, err := structFromMap(request.UserData)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BidRequest{
+		RequestId: request.RequestID,
+		LeadId:    request.LeadID,
+		Vertical:  request.Vertical,
+		UserData:  userData,
+		Timeout:   durationFromModel(request.Timeout),
+		Timestamp: timestampFromModel(request.Timestamp),
+		Consent:   consentFromModel(request.Consent),
+		Geo:       geoFromModel(request.Geo),
+		Device:    deviceFromModel(request.Device),
+	}, nil
+}
+
+// ToModel converts a protobuf BidRequest into models.BidRequest
+func (r *BidRequest) ToModel() *models.BidRequest {
+	return &models.BidRequest{
+		RequestID: r.GetRequestId(),
+		LeadID:    r.GetLeadId(),
+		Vertical:  r.GetVertical(),
+		UserData:  mapFromStruct(r.GetUserData()),
+		Timeout:   r.GetTimeout().AsDuration(),
+		Timestamp: timeFromTimestamp(r.GetTimestamp()),
+		Consent:   r.GetConsent().ToModel(),
+		Geo:       r.GetGeo().ToModel(),
+		Device:    r.GetDevice().ToModel(),
+	}
+}
+
+// ToModel converts a protobuf Consent into models.Consent
+func (c *Consent) ToModel() *models.Consent {
+	if c == nil {
+		return nil
+	}
+	return &models.Consent{
+		GDPRApplies:   c.GetGdprApplies(),
+		ConsentString: c.GetConsentString(),
+		USPrivacy:     c.GetUsPrivacy(),
+	}
+}
+
+// ToModel converts a protobuf Geo into models.Geo
+func (g *Geo) ToModel() *models.Geo {
+	if g == nil {
+		return nil
+	}
+	return &models.Geo{Country: g.GetCountry(), Region: g.GetRegion(), Zip: g.GetZip()}
+}
+
+// ToModel converts a protobuf Device into models.Device
+func (d *Device) ToModel() *models.Device {
+	if d == nil {
+		return nil
+	}
+	return &models.Device{Type: d.GetType(), OS: d.GetOs(), Browser: d.GetBrowser()}
+}
+
+// BidFromModel converts a models.Bid into its protobuf form
+func BidFromModel(bid *models.Bid) (*Bid, error) {
+	creative, err := structFromMap(bid.Creative)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Bid{
+		Id:           bid.ID,
+		PartnerId:    bid.PartnerID,
+		Price:        NewMoney(bid.Price),
+		ClickUrl:     bid.ClickURL,
+		QualityScore: bid.QualityScore,
+		ExpiresAt:    timestampFromModel(bid.ExpiresAt),
+		Creative:     creative,
+		Adomain:      bid.AdvertiserDomains,
+		DealId:       bid.DealID,
+	}, nil
+}
+
+// ToModel converts a protobuf Bid into models.Bid
+func (b *Bid) ToModel() *models.Bid {
+	return &models.Bid{
+		ID:                b.GetId(),
+		PartnerID:         b.GetPartnerId(),
+		Price:             b.GetPrice().Float64(),
+		ClickURL:          b.GetClickUrl(),
+		QualityScore:      b.GetQualityScore(),
+		ExpiresAt:         timeFromTimestamp(b.GetExpiresAt()),
+		Creative:          mapFromStruct(b.GetCreative()),
+		AdvertiserDomains: b.GetAdomain(),
+		DealID:            b.GetDealId(),
+	}
+}
+
+// BidResponseFromModel converts a models.BidResponse into its protobuf form
+func BidResponseFromModel(response *models.BidResponse) (*BidResponse, error) {
+	bids := make([]*Bid, 0, len(response.Bids))
+	for _, bid := range response.Bids {
+		converted, err := BidFromModel(bid)
+		if err != nil {
+			return nil, err
+		}
+		bids = append(bids, converted)
+	}
+
+	return &BidResponse{
+		RequestId:      response.RequestID,
+		Bids:           bids,
+		Timestamp:      timestampFromModel(response.Timestamp),
+		ProcessingTime: durationFromModel(response.ProcessingTime),
+	}, nil
+}
+
+// ToModel converts a protobuf BidResponse into models.BidResponse
+func (r *BidResponse) ToModel() *models.BidResponse {
+	bids := make([]*models.Bid, 0, len(r.GetBids()))
+	for _, bid := range r.GetBids() {
+		bids = append(bids, bid.ToModel())
+	}
+
+	return &models.BidResponse{
+		RequestID:      r.GetRequestId(),
+		Bids:           bids,
+		Timestamp:      timeFromTimestamp(r.GetTimestamp()),
+		ProcessingTime: r.GetProcessingTime().AsDuration(),
+	}
+}
+
+func consentFromModel(consent *models.Consent) *Consent {
+	if consent == nil {
+		return nil
+	}
+	return &Consent{
+		GdprApplies:   consent.GDPRApplies,
+		ConsentString: consent.ConsentString,
+		UsPrivacy:     consent.USPrivacy,
+	}
+}
+
+func geoFromModel(geo *models.Geo) *Geo {
+	if geo == nil {
+		return nil
+	}
+	return &Geo{Country: geo.Country, Region: geo.Region, Zip: geo.Zip}
+}
+
+func deviceFromModel(device *models.Device) *Device {
+	if device == nil {
+		return nil
+	}
+	return &Device{Type: device.Type, Os: device.OS, Browser: device.Browser}
+}
+
+func structFromMap(values map[string]interface{}) (*structpb.Struct, error) {
+	if values == nil {
+		return nil, nil
+	}
+	return structpb.NewStruct(values)
+}
+
+func mapFromStruct(values *structpb.Struct) map[string]interface{} {
+	if values == nil {
+		return nil
+	}
+	return values.AsMap()
+}
+
+func durationFromModel(d time.Duration) *durationpb.Duration {
+	if d == 0 {
+		return nil
+	}
+	return durationpb.New(d)
+}
+
+func timestampFromModel(t time.Time) *timestamppb.Timestamp {
+	if t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(t)
+}
+
+func timeFromTimestamp(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
@@ -0,0 +1,322 @@
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tokenKind is the kind of a lexical token
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenNumber
+	tokenString
+	tokenAnd
+	tokenOr
+	tokenNot
+	tokenIn
+	tokenOperator
+	tokenLeftParen
+	tokenRightParen
+	tokenLeftBracket
+	tokenRightBracket
+	tokenComma
+)
+
+// keywords maps the upper case keywords, which match in any case, to their tokens
+var keywords = map[string]tokenKind{"AND": tokenAnd, "OR": tokenOr, "NOT": tokenNot, "IN": tokenIn}
+
+// operators maps comparison operators to their operators
+var operators = map[string]operator{
+	"==": opEqual, "!=": opNotEqual, "<": opLess, "<=": opLessOrEqual, ">": opGreater, ">=": opGreaterOrEqual,
+}
+
+// punctuation maps single-character tokens to their kinds
+var punctuation = map[byte]tokenKind{'(': tokenLeftParen, ')': tokenRightParen, '[': tokenLeftBracket, ']': tokenRightBracket, ',': tokenComma}
+
+// weekdays are the values of time.weekday
+var weekdays = map[string]bool{"sun": true, "mon": true, "tue": true, "wed": true, "thu": true, "fri": true, "sat": true}
+
+// token is a lexical token and its 1-based character position
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// String describes the token for error messages
+func (t token) String() string {
+	if t.kind == tokenEOF {
+		return "end of condition"
+	}
+	return strconv.Quote(t.text)
+}
+
+// lexer splits a condition into tokens
+type lexer struct {
+	source string
+	offset int
+}
+
+// next returns the next token, or an error for text that is not one
+func (l *lexer) next() (token, *Error) {
+	for l.offset < len(l.source) && strings.IndexByte(" \t\r\n", l.source[l.offset]) >= 0 {
+		l.offset++
+	}
+	start := l.offset
+	pos := len([]rune(l.source[:start])) + 1
+	if start == len(l.source) {
+		return token{kind: tokenEOF, pos: pos}, nil
+	}
+
+	c := l.source[start]
+	switch {
+	case punctuation[c] != tokenEOF:
+		l.offset++
+		return token{kind: punctuation[c], text: string(c), pos: pos}, nil
+	case c == '=' || c == '!' || c == '<' || c == '>':
+		l.offset++
+		if l.offset < len(l.source) && l.source[l.offset] == '=' {
+			l.offset++
+		}
+		text := l.source[start:l.offset]
+		if _, exists := operators[text]; !exists {
+			return token{}, &Error{Pos: pos, Msg: fmt.Sprintf("unknown operator %q", text)}
+		}
+		return token{kind: tokenOperator, text: text, pos: pos}, nil
+	case c == '"':
+		l.offset++
+		for l.offset < len(l.source) && l.source[l.offset] != '"' {
+			if l.source[l.offset] == '\\' {
+				l.offset++
+			}
+			l.offset++
+		}
+		if l.offset >= len(l.source) {
+			return token{}, &Error{Pos: pos, Msg: "unterminated string"}
+		}
+		l.offset++
+		text, err := strconv.Unquote(l.source[start:l.offset])
+		if err != nil {
+			return token{}, &Error{Pos: pos, Msg: "invalid string " + l.source[start:l.offset]}
+		}
+		return token{kind: tokenString, text: text, pos: pos}, nil
+	case c == '-' || c == '.' || (c >= '0' && c <= '9'):
+		l.offset++
+		for l.offset < len(l.source) && isNumberByte(l.source[l.offset]) {
+			l.offset++
+		}
+		text := l.source[start:l.offset]
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return token{}, &Error{Pos: pos, Msg: fmt.Sprintf("invalid number %q", text)}
+		}
+		return token{kind: tokenNumber, text: text, pos: pos}, nil
+	case isIdentByte(c):
+		for l.offset < len(l.source) && (isIdentByte(l.source[l.offset]) || isNumberByte(l.source[l.offset]) || l.source[l.offset] == '-') {
+			l.offset++
+		}
+		text := l.source[start:l.offset]
+		if keyword, exists := keywords[strings.ToUpper(text)]; exists {
+			return token{kind: keyword, text: text, pos: pos}, nil
+		}
+		return token{kind: tokenIdent, text: text, pos: pos}, nil
+	}
+	return token{}, &Error{Pos: pos, Msg: fmt.Sprintf("unexpected character %q", c)}
+}
+
+// isIdentByte reports whether c can start a field name
+func isIdentByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isNumberByte reports whether c can continue a number
+func isNumberByte(c byte) bool {
+	return c == '.' || (c >= '0' && c <= '9')
+}
+
+// parser compiles a condition by recursive descent:
+//
+//	or         = and { OR and }
+//	and        = unary { AND unary }
+//	unary      = NOT unary | "(" or ")" | comparison
+//	comparison = field operator literal | field IN "[" literal { "," literal } "]"
+//
+// It stops at the first error.
+type parser struct {
+	lexer lexer
+	token token
+	err   *Error
+}
+
+// advance moves to the next token
+func (p *parser) advance() {
+	if p.err != nil {
+		return
+	}
+	next, err := p.lexer.next()
+	if err != nil {
+		p.err = err
+		next = token{kind: tokenEOF, pos: err.Pos}
+	}
+	p.token = next
+}
+
+// fail records the first error and ends the token stream
+func (p *parser) fail(pos int, msg string) {
+	if p.err == nil {
+		p.err = &Error{Pos: pos, Msg: msg}
+	}
+	p.token = token{kind: tokenEOF, pos: pos}
+}
+
+// expect consumes a token of kind, or fails naming what was expected
+func (p *parser) expect(kind tokenKind, expected string) bool {
+	if p.token.kind != kind {
+		p.fail(p.token.pos, fmt.Sprintf("expected %s, found %s", expected, p.token))
+		return false
+	}
+	p.advance()
+	return true
+}
+
+func (p *parser) parseOr() node {
+	terms := anyOf{p.parseAnd()}
+	for p.token.kind == tokenOr {
+		p.advance()
+		terms = append(terms, p.parseAnd())
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return terms
+}
+
+func (p *parser) parseAnd() node {
+	terms := allOf{p.parseUnary()}
+	for p.token.kind == tokenAnd {
+		p.advance()
+		terms = append(terms, p.parseUnary())
+	}
+	if len(terms) == 1 {
+		return terms[0]
+	}
+	return terms
+}
+
+func (p *parser) parseUnary() node {
+	switch p.token.kind {
+	case tokenNot:
+		p.advance()
+		return not{term: p.parseUnary()}
+	case tokenLeftParen:
+		p.advance()
+		term := p.parseOr()
+		p.expect(tokenRightParen, `")"`)
+		return term
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() node {
+	fieldToken := p.token
+	if !p.expect(tokenIdent, "a field") {
+		return allOf{}
+	}
+	field, key, fieldKind, exists := lookupField(fieldToken.text)
+	if !exists {
+		p.fail(fieldToken.pos, fmt.Sprintf("unknown field %q", fieldToken.text))
+		return allOf{}
+	}
+
+	if p.token.kind == tokenIn {
+		p.advance()
+		return p.parseIn(fieldToken, field, key, fieldKind)
+	}
+	opToken := p.token
+	if !p.expect(tokenOperator, "a comparison operator") {
+		return allOf{}
+	}
+	op := operators[opToken.text]
+	literal := p.token
+	if literal.kind != tokenNumber && literal.kind != tokenString {
+		p.fail(literal.pos, fmt.Sprintf("expected a number or string, found %s", literal))
+		return allOf{}
+	}
+	p.advance()
+	if !p.checkLiteral(fieldToken, field, fieldKind, literal) {
+		return allOf{}
+	}
+	if literal.kind == tokenString {
+		if op != opEqual && op != opNotEqual {
+			p.fail(opToken.pos, fmt.Sprintf("strings can only be compared with == or !=, not %s", opToken.text))
+			return allOf{}
+		}
+		return compareString{field: field, key: key, value: literal.text, notEqual: op == opNotEqual}
+	}
+	value, _ := strconv.ParseFloat(literal.text, 64)
+	return compareNumber{field: field, key: key, op: op, value: value}
+}
+
+// parseIn parses the list of an IN comparison, whose literals must all be of one type
+func (p *parser) parseIn(fieldToken token, field Field, key string, fieldKind kind) node {
+	if !p.expect(tokenLeftBracket, `"["`) {
+		return allOf{}
+	}
+	var literals []token
+	for {
+		literal := p.token
+		if literal.kind != tokenNumber && literal.kind != tokenString {
+			p.fail(literal.pos, fmt.Sprintf("expected a number or string, found %s", literal))
+			return allOf{}
+		}
+		p.advance()
+		if !p.checkLiteral(fieldToken, field, fieldKind, literal) {
+			return allOf{}
+		}
+		if len(literals) > 0 && literal.kind != literals[0].kind {
+			p.fail(literal.pos, "list mixes numbers and strings")
+			return allOf{}
+		}
+		literals = append(literals, literal)
+		if p.token.kind != tokenComma {
+			break
+		}
+		p.advance()
+	}
+	if !p.expect(tokenRightBracket, `"]"`) {
+		return allOf{}
+	}
+
+	if literals[0].kind == tokenString {
+		values := make([]string, len(literals))
+		for i, literal := range literals {
+			values[i] = literal.text
+		}
+		return stringIn{field: field, key: key, values: values}
+	}
+	values := make([]float64, len(literals))
+	for i, literal := range literals {
+		values[i], _ = strconv.ParseFloat(literal.text, 64)
+	}
+	return numberIn{field: field, key: key, values: values}
+}
+
+// checkLiteral fails when a literal's type does not match its field's, or it is a value the field
+// never takes
+func (p *parser) checkLiteral(fieldToken token, field Field, fieldKind kind, literal token) bool {
+	switch {
+	case field == FieldTimeWeekday && literal.kind == tokenString && !weekdays[literal.text]:
+		p.fail(literal.pos, fmt.Sprintf("unknown weekday %s, expected sun, mon, tue, wed, thu, fri, or sat", literal))
+		return false
+	case fieldKind == kindNumber && literal.kind != tokenNumber:
+		p.fail(literal.pos, fmt.Sprintf("%s is a number, found string %s", fieldToken.text, literal))
+		return false
+	case fieldKind == kindString && literal.kind != tokenString:
+		p.fail(literal.pos, fmt.Sprintf("%s is a string, found number %s", fieldToken.text, literal.text))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,271 @@
+// Package rules compiles and evaluates bid rule conditions: comparisons of bid, partner, request,
+// and time fields joined with AND, OR, and NOT, such as
+//
+//	partner.id == "partner-x" AND request.vertical == "auto" AND time.weekday IN ["sat", "sun"] AND bid.price < 5
+//
+// Conditions are compiled once, when the config is loaded, into a tree evaluated per bid without
+// allocating.
+package rules
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Field is a value a condition can compare
+type Field int
+
+// Fields. Bid prices are costs per lead.
+const (
+	FieldBidPrice Field = iota
+	FieldBidQualityScore
+	FieldBidDealID
+	FieldBidPricingModel
+	FieldPartnerID
+	FieldRequestVertical
+	FieldRequestCountry
+	FieldRequestRegion
+	FieldRequestDeviceType
+	FieldRequestUserData // request.user_data.<key>, compared as a number or a string by its literal
+	FieldTimeHour
+	FieldTimeWeekday // sun, mon, tue, wed, thu, fri, or sat
+)
+
+// UserDataPrefix starts the name of a request UserData field
+const UserDataPrefix = "request.user_data."
+
+// kind is the type of a field's values
+type kind int
+
+const (
+	kindNumber kind = iota
+	kindString
+	kindAny // compared as the type of the literal
+)
+
+// fields maps field names to fields and their value types
+var fields = map[string]struct {
+	field Field
+	kind  kind
+}{
+	"bid.price":           {FieldBidPrice, kindNumber},
+	"bid.quality_score":   {FieldBidQualityScore, kindNumber},
+	"bid.deal_id":         {FieldBidDealID, kindString},
+	"bid.pricing_model":   {FieldBidPricingModel, kindString},
+	"partner.id":          {FieldPartnerID, kindString},
+	"request.vertical":    {FieldRequestVertical, kindString},
+	"request.country":     {FieldRequestCountry, kindString},
+	"request.region":      {FieldRequestRegion, kindString},
+	"request.device_type": {FieldRequestDeviceType, kindString},
+	"time.hour":           {FieldTimeHour, kindNumber},
+	"time.weekday":        {FieldTimeWeekday, kindString},
+}
+
+// FieldNames returns the names of every field, with UserData fields as their prefix
+func FieldNames() []string {
+	names := make([]string, 0, len(fields)+1)
+	for name := range fields {
+		names = append(names, name)
+	}
+	names = append(names, UserDataPrefix+"<key>")
+	sort.Strings(names)
+	return names
+}
+
+// Env supplies the field values of the bid a condition is evaluated for. Key is the UserData key
+// of FieldRequestUserData and empty otherwise. A field without a value of the asked type reports
+// false, and every comparison of it is false.
+type Env interface {
+	Number(field Field, key string) (float64, bool)
+	String(field Field, key string) (string, bool)
+}
+
+// Error is a condition that does not compile, with the 1-based character position of the problem
+type Error struct {
+	Pos int
+	Msg string
+}
+
+// Error returns the position and the problem
+func (e *Error) Error() string {
+	return fmt.Sprintf("position %d: %s", e.Pos, e.Msg)
+}
+
+// Condition is a compiled condition
+type Condition struct {
+	source string
+	root   node
+}
+
+// Compile compiles a condition, returning an *Error for any syntax or type error
+func Compile(source string) (*Condition, error) {
+	p := &parser{lexer: lexer{source: source}}
+	p.advance()
+	if p.err != nil {
+		return nil, p.err
+	}
+	if p.token.kind == tokenEOF {
+		return nil, &Error{Pos: 1, Msg: "empty condition"}
+	}
+	root := p.parseOr()
+	if p.err == nil && p.token.kind != tokenEOF {
+		p.fail(p.token.pos, fmt.Sprintf("unexpected %s", p.token))
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &Condition{source: source, root: root}, nil
+}
+
+// Matches reports whether the condition holds for env
+func (c *Condition) Matches(env Env) bool {
+	return c.root.eval(env)
+}
+
+// String returns the condition's source
+func (c *Condition) String() string {
+	return c.source
+}
+
+// node is a compiled condition or part of one
+type node interface {
+	eval(env Env) bool
+}
+
+// anyOf holds when any of its terms holds
+type anyOf []node
+
+func (n anyOf) eval(env Env) bool {
+	for _, term := range n {
+		if term.eval(env) {
+			return true
+		}
+	}
+	return false
+}
+
+// allOf holds when every one of its terms holds
+type allOf []node
+
+func (n allOf) eval(env Env) bool {
+	for _, term := range n {
+		if !term.eval(env) {
+			return false
+		}
+	}
+	return true
+}
+
+// not holds when its term does not
+type not struct {
+	term node
+}
+
+func (n not) eval(env Env) bool {
+	return !n.term.eval(env)
+}
+
+// operator is a comparison operator
+type operator int
+
+const (
+	opEqual operator = iota
+	opNotEqual
+	opLess
+	opLessOrEqual
+	opGreater
+	opGreaterOrEqual
+	opIn
+)
+
+// compareNumber compares a number field to a number
+type compareNumber struct {
+	field Field
+	key   string
+	op    operator
+	value float64
+}
+
+func (n compareNumber) eval(env Env) bool {
+	value, ok := env.Number(n.field, n.key)
+	if !ok {
+		return false
+	}
+	switch n.op {
+	case opEqual:
+		return value == n.value
+	case opNotEqual:
+		return value != n.value
+	case opLess:
+		return value < n.value
+	case opLessOrEqual:
+		return value <= n.value
+	case opGreater:
+		return value > n.value
+	default:
+		return value >= n.value
+	}
+}
+
+// compareString compares a string field to a string for equality
+type compareString struct {
+	field    Field
+	key      string
+	value    string
+	notEqual bool
+}
+
+func (n compareString) eval(env Env) bool {
+	value, ok := env.String(n.field, n.key)
+	return ok && (value == n.value) != n.notEqual
+}
+
+// numberIn holds when a number field equals any of its numbers
+type numberIn struct {
+	field  Field
+	key    string
+	values []float64
+}
+
+func (n numberIn) eval(env Env) bool {
+	value, ok := env.Number(n.field, n.key)
+	if !ok {
+		return false
+	}
+	for _, candidate := range n.values {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// stringIn holds when a string field equals any of its strings
+type stringIn struct {
+	field  Field
+	key    string
+	values []string
+}
+
+func (n stringIn) eval(env Env) bool {
+	value, ok := env.String(n.field, n.key)
+	if !ok {
+		return false
+	}
+	for _, candidate := range n.values {
+		if value == candidate {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupField returns the field and value type of a field name
+func lookupField(name string) (Field, string, kind, bool) {
+	if key := strings.TrimPrefix(name, UserDataPrefix); key != name {
+		return FieldRequestUserData, key, kindAny, key != ""
+	}
+	entry, exists := fields[name]
+	return entry.field, "", entry.kind, exists
+}
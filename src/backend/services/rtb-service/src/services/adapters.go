@@ -0,0 +1,764 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// ErrUnexpectedStatus is returned when a partner responds with a status other than 200 or 204
+var ErrUnexpectedStatus = errors.New("unexpected partner status")
+
+// ErrEmptyTemplateField is returned when a partner's endpoint or query parameters reference a
+// request field the bid request leaves empty
+var ErrEmptyTemplateField = errors.New("templated request field is empty")
+
+// StatusError reports the unexpected status a partner responded with
+type StatusError struct {
+	Status int
+}
+
+// Error implements the error interface
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%v %d", ErrUnexpectedStatus, e.Status)
+}
+
+// Is matches ErrUnexpectedStatus
+func (e *StatusError) Is(target error) bool {
+	return target == ErrUnexpectedStatus
+}
+
+// PartnerAdapter translates bid requests and responses to and from a partner's wire format.
+// BuildRequest returns a request without a context; callers attach one before sending.
+// ParseResponse returns every bid in the response, one per seat for partners representing several
+// buyers, and a *NoBidResponse error when the partner declined to bid.
+type PartnerAdapter interface {
+	BuildRequest(request *models.BidRequest, partner *config.PartnerConfig) (*http.Request, error)
+	ParseResponse(body []byte, status int) ([]*models.Bid, error)
+}
+
+// AdapterError identifies the adapter and, when known, the field that failed to translate
+type AdapterError struct {
+	Adapter string
+	Field   string
+	Err     error
+}
+
+// Error implements the error interface
+func (e *AdapterError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s adapter: %v", e.Adapter, e.Err)
+	}
+	return fmt.Sprintf("%s adapter: field %s: %v", e.Adapter, e.Field, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *AdapterError) Unwrap() error {
+	return e.Err
+}
+
+// NewPartnerAdapter returns the adapter for a partner's configured format
+func NewPartnerAdapter(partner *config.PartnerConfig) (PartnerAdapter, error) {
+	schema := newResponseSchema(partner)
+	switch partner.Format {
+	case "", config.FormatJSON:
+		return JSONAdapter{schema: schema}, nil
+	case config.FormatXML:
+		return XMLAdapter{schema: schema}, nil
+	case config.FormatMappedJSON:
+		if partner.FieldMapping == nil {
+			return nil, fmt.Errorf("format %s requires a field mapping", partner.Format)
+		}
+		adapter := NewMappedJSONAdapter(partner.FieldMapping)
+		adapter.schema = schema
+		return adapter, nil
+	case config.FormatForm:
+		if partner.FieldMapping == nil {
+			return nil, fmt.Errorf("format %s requires a field mapping", partner.Format)
+		}
+		adapter := NewFormAdapter(partner.FieldMapping)
+		adapter.schema = schema
+		return adapter, nil
+	default:
+		return nil, fmt.Errorf("unknown partner format %q", partner.Format)
+	}
+}
+
+// newPartnerAdapters builds authenticated adapters for every configured partner
+func newPartnerAdapters(cfg *config.Config, clock utils.Clock) (map[string]PartnerAdapter, error) {
+	adapters := make(map[string]PartnerAdapter, len(cfg.Partners))
+	for partnerID, partner := range cfg.Partners {
+		adapter, err := NewPartnerAdapter(partner)
+		if err != nil {
+			return nil, fmt.Errorf("partner %s: %w", partnerID, err)
+		}
+		auth, err := NewPartnerAuthenticator(partner, clock)
+		if err != nil {
+			return nil, fmt.Errorf("partner %s: %w", partnerID, err)
+		}
+		adapters[partnerID] = &authenticatedAdapter{PartnerAdapter: adapter, auth: auth}
+	}
+	return adapters, nil
+}
+
+// adapterFor returns the adapter for a partner, or for the treatment of its payload experiment
+// when partner is the treatment's config; partners added after construction fall back to JSON
+// with a bearer APIKey
+func (s *AuctionService) adapterFor(partnerID string, partner *config.PartnerConfig) PartnerAdapter {
+	if variant, exists := s.payloadVariants[partnerID]; exists && variant.config == partner {
+		return variant.adapter
+	}
+	if adapter, exists := s.adapters[partnerID]; exists {
+		return adapter
+	}
+	return &authenticatedAdapter{
+		PartnerAdapter: JSONAdapter{schema: newResponseSchema(partner)},
+		auth:           &PartnerAuthenticator{scheme: config.AuthBearer, token: partner.APIKey, bearer: "Bearer " + partner.APIKey},
+	}
+}
+
+// checkStatus reports whether the response carries a bid; partners signal no bid with 204
+func checkStatus(adapter string, status int) (bool, error) {
+	switch status {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNoContent:
+		return false, &NoBidResponse{}
+	default:
+		return false, &AdapterError{Adapter: adapter, Err: &StatusError{Status: status}}
+	}
+}
+
+// buildPartnerRequest builds an auction's request to a partner with adapter, carrying the
+// auction's request ID and, for dry runs, the partner test header
+func buildPartnerRequest(auction *models.AuctionContext, adapter PartnerAdapter, request *models.BidRequest, partner *config.PartnerConfig) (*http.Request, error) {
+	httpReq, err := adapter.BuildRequest(request, partner)
+	if err != nil {
+		return nil, err
+	}
+	requestID := auction.RequestID()
+	if requestID == "" {
+		requestID = request.RequestID
+	}
+	httpReq.Header[requestIDHeaderKey] = []string{requestID}
+	if auction.DryRun() != nil {
+		httpReq.Header.Set(PartnerTestHeader, "1")
+	}
+	return httpReq, nil
+}
+
+// newPartnerRequest builds a POST to the partner endpoint with the given body and content type
+func newPartnerRequest(adapter string, request *models.BidRequest, partner *config.PartnerConfig, contentType string, body []byte) (*http.Request, error) {
+	endpoint, err := partnerURL(adapter, request, partner)
+	if err != nil {
+		return nil, err
+	}
+	httpReq, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, &AdapterError{Adapter: adapter, Err: err}
+	}
+	httpReq.Header.Set("Content-Type", contentType)
+	return httpReq, nil
+}
+
+// partnerTemplates caches parsed endpoint and query parameter templates by their text
+var partnerTemplates sync.Map // template -> []config.TemplatePart
+
+// partnerURL returns the partner's endpoint with its placeholders resolved from the request,
+// path-escaped in the path and query-escaped in the query, followed by its query parameters
+func partnerURL(adapter string, request *models.BidRequest, partner *config.PartnerConfig) (string, error) {
+	if strings.IndexByte(partner.Endpoint, '{') < 0 && len(partner.QueryParams) == 0 {
+		return partner.Endpoint, nil
+	}
+
+	path, query, hasQuery := strings.Cut(partner.Endpoint, "?")
+	endpoint, err := resolveTemplate(adapter, path, request, url.PathEscape)
+	if err != nil {
+		return "", err
+	}
+	if hasQuery {
+		query, err = resolveTemplate(adapter, query, request, url.QueryEscape)
+		if err != nil {
+			return "", err
+		}
+		endpoint += "?" + query
+	}
+	if len(partner.QueryParams) == 0 {
+		return endpoint, nil
+	}
+
+	params := make(url.Values, len(partner.QueryParams))
+	for name, template := range partner.QueryParams {
+		// Encode escapes the values
+		value, err := resolveTemplate(adapter, template, request, func(value string) string { return value })
+		if err != nil {
+			return "", err
+		}
+		params.Set(name, value)
+	}
+	if hasQuery {
+		return endpoint + "&" + params.Encode(), nil
+	}
+	return endpoint + "?" + params.Encode(), nil
+}
+
+// resolveTemplate replaces a template's placeholders with the escaped request fields they name
+func resolveTemplate(adapter, template string, request *models.BidRequest, escape func(string) string) (string, error) {
+	cached, exists := partnerTemplates.Load(template)
+	if !exists {
+		parts, err := config.ParseTemplate(template)
+		if err != nil {
+			return "", &AdapterError{Adapter: adapter, Err: err}
+		}
+		cached, _ = partnerTemplates.LoadOrStore(template, parts)
+	}
+
+	var resolved strings.Builder
+	for _, part := range cached.([]config.TemplatePart) {
+		if part.Placeholder == "" {
+			resolved.WriteString(part.Literal)
+			continue
+		}
+		value := templateField(request, part.Placeholder)
+		if value == "" {
+			return "", &AdapterError{Adapter: adapter, Field: part.Placeholder, Err: ErrEmptyTemplateField}
+		}
+		resolved.WriteString(escape(value))
+	}
+	return resolved.String(), nil
+}
+
+// templateField returns the request field a placeholder names, or empty when the request has none
+func templateField(request *models.BidRequest, placeholder string) string {
+	switch placeholder {
+	case "request_id":
+		return request.RequestID
+	case "lead_id":
+		return request.LeadID
+	case "vertical":
+		return request.Vertical
+	case "state":
+		if request.Geo != nil {
+			return request.Geo.Region
+		}
+	case "zip":
+		if request.Geo != nil {
+			return request.Geo.Zip
+		}
+	case "country":
+		if request.Geo != nil {
+			return request.Geo.Country
+		}
+	case "device":
+		if request.Device != nil {
+			return request.Device.Type
+		}
+	}
+	return ""
+}
+
+// JSONAdapter speaks our native JSON schema
+type JSONAdapter struct {
+	schema responseSchema
+}
+
+// BuildRequest encodes the bid request as JSON. Fields the partner's fields policy withholds are
+// left out of the document rather than sent empty.
+func (JSONAdapter) BuildRequest(request *models.BidRequest, partner *config.PartnerConfig) (*http.Request, error) {
+	var body []byte
+	var err error
+	if partner.Fields == nil {
+		body, err = json.Marshal(request)
+	} else {
+		body, err = minimizedDocument(request, partner.Fields)
+	}
+	if err != nil {
+		return nil, &AdapterError{Adapter: config.FormatJSON, Err: err}
+	}
+	return newPartnerRequest(config.FormatJSON, request, partner, "application/json", body)
+}
+
+// jsonNoBid is a JSON no-bid object, {"no_bid_reason": "below_floor"}
+type jsonNoBid struct {
+	NoBidReason *string `json:"no_bid_reason"`
+}
+
+// ParseResponse decodes a JSON bid object or an array of bids, checking each bid against the
+// partner's response validation mode. An empty array or an object with a no_bid_reason is a no-bid.
+func (a JSONAdapter) ParseResponse(body []byte, status int) ([]*models.Bid, error) {
+	if hasBid, err := checkStatus(config.FormatJSON, status); !hasBid {
+		return nil, err
+	}
+	// Only bodies mentioning the key pay for the second decode
+	if bytes.Contains(body, []byte(`"no_bid_reason"`)) {
+		var noBid jsonNoBid
+		if json.Unmarshal(body, &noBid) == nil && noBid.NoBidReason != nil {
+			return nil, &NoBidResponse{Reason: *noBid.NoBidReason}
+		}
+	}
+
+	if bid, ok := a.schema.scanJSONBid(body); ok {
+		return []*models.Bid{bid}, nil
+	}
+
+	var documents []map[string]interface{}
+	var err error
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if trimmed := bytes.TrimSpace(body); len(trimmed) > 0 && trimmed[0] == '[' {
+		var entries []map[string]interface{}
+		err = decoder.Decode(&entries)
+		for _, entry := range entries {
+			if entry != nil {
+				documents = append(documents, entry)
+			}
+		}
+	} else {
+		var document map[string]interface{}
+		if err = decoder.Decode(&document); err == nil && document != nil {
+			documents = []map[string]interface{}{document}
+		}
+	}
+	if err != nil {
+		adapterErr := &AdapterError{Adapter: config.FormatJSON, Err: err}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			adapterErr.Field = typeErr.Field
+		}
+		return nil, adapterErr
+	}
+	if len(documents) == 0 {
+		return nil, &NoBidResponse{}
+	}
+
+	bids := make([]*models.Bid, 0, len(documents))
+	for _, document := range documents {
+		bid, err := a.schema.jsonBid(document)
+		if err != nil {
+			return nil, schemaAdapterError(config.FormatJSON, err)
+		}
+		bids = append(bids, bid)
+	}
+	return bids, nil
+}
+
+// XMLAdapter speaks an XML rendering of our schema for legacy partners. XML values are all text,
+// so only range and precision checks depend on the partner's response validation mode.
+type XMLAdapter struct {
+	schema responseSchema
+}
+
+// xmlBidRequest is the XML form of a bid request
+type xmlBidRequest struct {
+	XMLName     xml.Name            `xml:"BidRequest"`
+	RequestID   string              `xml:"RequestID"`
+	LeadID      string              `xml:"LeadID"`
+	Vertical    string              `xml:"Vertical"`
+	Timestamp   string              `xml:"Timestamp,omitempty"`
+	UserData    []xmlField          `xml:"UserData>Field"`
+	Geo         *models.Geo         `xml:"Geo,omitempty"`
+	Device      *models.Device      `xml:"Device,omitempty"`
+	LeadQuality *models.LeadQuality `xml:"LeadQuality,omitempty"`
+}
+
+// xmlField is a named UserData value
+type xmlField struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:",chardata"`
+}
+
+// xmlBids is the XML form of a multi-seat response, a Bids element wrapping Bid elements
+type xmlBids struct {
+	Bids []xmlBid `xml:"Bid"`
+}
+
+// xmlBid is the XML form of a bid; numeric fields are parsed separately to report bad values by field
+type xmlBid struct {
+	ID                  string   `xml:"ID"`
+	Price               string   `xml:"Price"`
+	ClickURL            string   `xml:"ClickURL"`
+	QualityScore        string   `xml:"QualityScore"`
+	QualityAcknowledged string   `xml:"QualityAcknowledged"`
+	ExpiresAt           string   `xml:"ExpiresAt"`
+	AdvertiserDomains   []string `xml:"Adomain"`
+}
+
+// BuildRequest encodes the bid request as XML with UserData fields in name order
+func (XMLAdapter) BuildRequest(request *models.BidRequest, partner *config.PartnerConfig) (*http.Request, error) {
+	payload := xmlBidRequest{
+		RequestID:   request.RequestID,
+		LeadID:      request.LeadID,
+		Vertical:    request.Vertical,
+		Geo:         request.Geo,
+		Device:      request.Device,
+		LeadQuality: request.LeadQuality,
+	}
+	if !request.Timestamp.IsZero() {
+		payload.Timestamp = request.Timestamp.Format(time.RFC3339)
+	}
+
+	names := make([]string, 0, len(request.UserData))
+	for name := range request.UserData {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		payload.UserData = append(payload.UserData, xmlField{Name: name, Value: fmt.Sprint(request.UserData[name])})
+	}
+
+	body, err := xml.Marshal(payload)
+	if err != nil {
+		return nil, &AdapterError{Adapter: config.FormatXML, Err: err}
+	}
+	return newPartnerRequest(config.FormatXML, request, partner, "application/xml", append([]byte(xml.Header), body...))
+}
+
+// xmlNoBid is the XML no-bid element, <NoBid reason="below_floor"/>
+type xmlNoBid struct {
+	Reason string `xml:"reason,attr"`
+}
+
+// ParseResponse decodes a Bid element or a Bids element wrapping one Bid per seat. A NoBid
+// element or a Bids element without bids is a no-bid.
+func (a XMLAdapter) ParseResponse(body []byte, status int) ([]*models.Bid, error) {
+	if hasBid, err := checkStatus(config.FormatXML, status); !hasBid {
+		return nil, err
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	var root xml.StartElement
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, &AdapterError{Adapter: config.FormatXML, Err: err}
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			root = start
+			break
+		}
+	}
+
+	var decoded []xmlBid
+	switch root.Name.Local {
+	case "NoBid":
+		var noBid xmlNoBid
+		if err := decoder.DecodeElement(&noBid, &root); err != nil {
+			return nil, &AdapterError{Adapter: config.FormatXML, Err: err}
+		}
+		return nil, &NoBidResponse{Reason: noBid.Reason}
+	case "Bids":
+		var list xmlBids
+		if err := decoder.DecodeElement(&list, &root); err != nil {
+			return nil, &AdapterError{Adapter: config.FormatXML, Err: err}
+		}
+		if len(list.Bids) == 0 {
+			return nil, &NoBidResponse{}
+		}
+		decoded = list.Bids
+	default:
+		var single xmlBid
+		if err := decoder.DecodeElement(&single, &root); err != nil {
+			return nil, &AdapterError{Adapter: config.FormatXML, Err: err}
+		}
+		decoded = []xmlBid{single}
+	}
+
+	bids := make([]*models.Bid, 0, len(decoded))
+	for _, entry := range decoded {
+		bid, err := entry.bid(a.schema)
+		if err != nil {
+			return nil, schemaAdapterError(config.FormatXML, err)
+		}
+		bids = append(bids, bid)
+	}
+	return bids, nil
+}
+
+// bid converts a decoded XML bid, reporting missing, unparseable, and non-finite fields by element name
+func (decoded xmlBid) bid(schema responseSchema) (*models.Bid, error) {
+	bid := &models.Bid{
+		ID:                decoded.ID,
+		ClickURL:          decoded.ClickURL,
+		AdvertiserDomains: decoded.AdvertiserDomains,
+	}
+	if strings.TrimSpace(decoded.Price) == "" {
+		return nil, newSchemaError("Price", SchemaReasonMissing, nil)
+	}
+	if schema.strict {
+		if strings.TrimSpace(decoded.ID) == "" {
+			return nil, newSchemaError("ID", SchemaReasonMissing, nil)
+		}
+		if strings.TrimSpace(decoded.ClickURL) == "" {
+			return nil, newSchemaError("ClickURL", SchemaReasonMissing, nil)
+		}
+	}
+
+	var err error
+	if bid.Price, err = schema.parseNumber("Price", strings.TrimSpace(decoded.Price), decoded.Price); err != nil {
+		return nil, err
+	}
+	if decoded.QualityScore != "" {
+		if bid.QualityScore, err = schema.parseNumber("QualityScore", strings.TrimSpace(decoded.QualityScore), decoded.QualityScore); err != nil {
+			return nil, err
+		}
+	}
+	if decoded.QualityAcknowledged != "" {
+		if bid.QualityAcknowledged, err = strconv.ParseBool(strings.TrimSpace(decoded.QualityAcknowledged)); err != nil {
+			return nil, newSchemaError("QualityAcknowledged", SchemaReasonWrongType, decoded.QualityAcknowledged)
+		}
+	}
+	if decoded.ExpiresAt != "" {
+		if bid.ExpiresAt, err = schema.timestamp("ExpiresAt", decoded.ExpiresAt); err != nil {
+			return nil, err
+		}
+	}
+	return bid, nil
+}
+
+// MappedJSONAdapter translates to and from a partner's own JSON field names using a field mapping
+type MappedJSONAdapter struct {
+	mapping *config.FieldMapping
+	schema  responseSchema
+}
+
+// NewMappedJSONAdapter creates a mapped JSON adapter
+func NewMappedJSONAdapter(mapping *config.FieldMapping) *MappedJSONAdapter {
+	return &MappedJSONAdapter{mapping: mapping}
+}
+
+// BuildRequest builds the partner's JSON document from the request mapping.
+// Request fields without a value are omitted.
+func (a *MappedJSONAdapter) BuildRequest(request *models.BidRequest, partner *config.PartnerConfig) (*http.Request, error) {
+	source, err := requestDocument(request)
+	if err != nil {
+		return nil, &AdapterError{Adapter: config.FormatMappedJSON, Err: err}
+	}
+
+	document := make(map[string]interface{})
+	for partnerField, sourcePath := range a.mapping.Request {
+		if value, found := lookupPath(source, sourcePath); found {
+			setPath(document, partnerField, value)
+		}
+	}
+
+	body, err := json.Marshal(document)
+	if err != nil {
+		return nil, &AdapterError{Adapter: config.FormatMappedJSON, Err: err}
+	}
+	return newPartnerRequest(config.FormatMappedJSON, request, partner, "application/json", body)
+}
+
+// ParseResponse decodes partner JSON bids using the response mapping
+func (a *MappedJSONAdapter) ParseResponse(body []byte, status int) ([]*models.Bid, error) {
+	return parseMappedResponse(config.FormatMappedJSON, a.mapping, a.schema, body, status)
+}
+
+// FormAdapter posts form-encoded requests with the partner's field names and reads mapped JSON responses
+type FormAdapter struct {
+	mapping *config.FieldMapping
+	schema  responseSchema
+}
+
+// NewFormAdapter creates a form-encoded adapter
+func NewFormAdapter(mapping *config.FieldMapping) *FormAdapter {
+	return &FormAdapter{mapping: mapping}
+}
+
+// BuildRequest encodes mapped request fields as an application/x-www-form-urlencoded body
+func (a *FormAdapter) BuildRequest(request *models.BidRequest, partner *config.PartnerConfig) (*http.Request, error) {
+	source, err := requestDocument(request)
+	if err != nil {
+		return nil, &AdapterError{Adapter: config.FormatForm, Err: err}
+	}
+
+	form := url.Values{}
+	for partnerField, sourcePath := range a.mapping.Request {
+		value, found := lookupPath(source, sourcePath)
+		if !found {
+			continue
+		}
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			return nil, &AdapterError{Adapter: config.FormatForm, Field: partnerField, Err: errors.New("value is not a scalar")}
+		}
+		form.Set(partnerField, fmt.Sprint(value))
+	}
+	return newPartnerRequest(config.FormatForm, request, partner, "application/x-www-form-urlencoded", []byte(form.Encode()))
+}
+
+// ParseResponse decodes partner JSON bids using the response mapping
+func (a *FormAdapter) ParseResponse(body []byte, status int) ([]*models.Bid, error) {
+	return parseMappedResponse(config.FormatForm, a.mapping, a.schema, body, status)
+}
+
+// requestDocument renders a bid request as a generic JSON document for path lookups
+func requestDocument(request *models.BidRequest) (map[string]interface{}, error) {
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	return document, nil
+}
+
+// minimizedDocument encodes a bid request as JSON without the fields a fields policy withholds
+func minimizedDocument(request *models.BidRequest, policy *config.FieldsPolicy) ([]byte, error) {
+	document, err := requestDocument(request)
+	if err != nil {
+		return nil, err
+	}
+	for field := range document {
+		if !policy.Sends(field) {
+			delete(document, field)
+		}
+	}
+	return json.Marshal(document)
+}
+
+// parseMappedResponse extracts bids from a partner JSON document using the response mapping.
+// When the mapping has a bids path, each entry of the array there is mapped as one bid with
+// field paths relative to the entry; otherwise the document holds a single bid. Mapped fields are
+// checked against the partner's response validation mode; unmapped fields are not part of the schema.
+func parseMappedResponse(adapter string, mapping *config.FieldMapping, schema responseSchema, body []byte, status int) ([]*models.Bid, error) {
+	if hasBid, err := checkStatus(adapter, status); !hasBid {
+		return nil, err
+	}
+
+	var document map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&document); err != nil {
+		return nil, &AdapterError{Adapter: adapter, Err: err}
+	}
+
+	if reasonPath := mapping.Response[config.ResponseFieldNoBidReason]; reasonPath != "" {
+		if reason, found := lookupPath(document, reasonPath); found && reason != nil {
+			return nil, &NoBidResponse{Reason: fmt.Sprint(reason)}
+		}
+	}
+
+	bidsPath := mapping.Response[config.ResponseFieldBids]
+	if bidsPath == "" {
+		bid, err := mapBid(mapping, schema, document)
+		if err != nil {
+			return nil, schemaAdapterError(adapter, err)
+		}
+		return []*models.Bid{bid}, nil
+	}
+
+	value, found := lookupPath(document, bidsPath)
+	if !found {
+		return nil, &NoBidResponse{}
+	}
+	entries, ok := value.([]interface{})
+	if !ok {
+		return nil, &AdapterError{Adapter: adapter, Field: bidsPath, Err: errors.New("expected a list")}
+	}
+	bids := make([]*models.Bid, 0, len(entries))
+	for i, entry := range entries {
+		object, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, &AdapterError{Adapter: adapter, Field: fmt.Sprintf("%s.%d", bidsPath, i), Err: errors.New("expected an object")}
+		}
+		bid, err := mapBid(mapping, schema, object)
+		if err != nil {
+			return nil, schemaAdapterError(adapter, err)
+		}
+		bids = append(bids, bid)
+	}
+	if len(bids) == 0 {
+		return nil, &NoBidResponse{}
+	}
+	return bids, nil
+}
+
+// mapBid builds one bid from a decoded JSON object using the response field mapping, naming
+// failed fields by their partner path
+func mapBid(mapping *config.FieldMapping, schema responseSchema, document map[string]interface{}) (*models.Bid, error) {
+	bid := &models.Bid{}
+	for _, field := range []string{"id", "price", "click_url", "quality_score", "quality_acknowledged", "adomain", "creative", "vertical_ext"} {
+		path := mapping.Response[field]
+		if path == "" {
+			continue
+		}
+		value, found := lookupPath(document, path)
+		if !found {
+			if field == "id" || field == "price" || field == "click_url" {
+				return nil, newSchemaError(path, SchemaReasonMissing, nil)
+			}
+			continue
+		}
+
+		var err error
+		switch field {
+		case "id":
+			bid.ID, err = schema.text(path, value)
+		case "click_url":
+			bid.ClickURL, err = schema.text(path, value)
+		case "price":
+			bid.Price, err = schema.number(path, value)
+		case "quality_score":
+			bid.QualityScore, err = schema.number(path, value)
+		case "quality_acknowledged":
+			bid.QualityAcknowledged, err = schema.flag(path, value)
+		case "adomain":
+			bid.AdvertiserDomains, err = schema.domains(path, value)
+		case "creative":
+			bid.Creative, err = schema.object(path, value)
+		case "vertical_ext":
+			bid.VerticalExt, err = schema.extension(path, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bid, nil
+}
+
+// lookupPath resolves a dotted path in a decoded JSON document
+func lookupPath(document map[string]interface{}, path string) (interface{}, bool) {
+	var current interface{} = document
+	for _, segment := range strings.Split(path, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if current, ok = object[segment]; !ok || current == nil {
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+// setPath stores value at a dotted path, creating intermediate objects
+func setPath(document map[string]interface{}, path string, value interface{}) {
+	segments := strings.Split(path, ".")
+	for _, segment := range segments[:len(segments)-1] {
+		next, ok := document[segment].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			document[segment] = next
+		}
+		document = next
+	}
+	document[segments[len(segments)-1]] = value
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Price analytics errors
+var (
+	ErrAnalyticsDisabled     = errors.New("price analytics disabled")
+	ErrInvalidAnalyticsQuery = errors.New("invalid analytics query")
+)
+
+// PriceSummary describes the auctions for a vertical, and optionally a region, over a window of hours
+type PriceSummary struct {
+	Vertical   string  `json:"vertical"`
+	Region     string  `json:"region,omitempty"`
+	Window     string  `json:"window"`
+	Count      uint64  `json:"count"`
+	P25        float64 `json:"p25"`
+	P50        float64 `json:"p50"`
+	P75        float64 `json:"p75"`
+	P95        float64 `json:"p95"`
+	AvgWinners float64 `json:"avg_winners"`
+	NoBidRate  float64 `json:"no_bid_rate"`
+}
+
+// priceSeriesKey identifies a tracked series; Region is empty for the vertical-wide series
+type priceSeriesKey struct {
+	Vertical string
+	Region   string
+}
+
+// priceWindow aggregates one series' auctions within one hour
+type priceWindow struct {
+	hour     int64
+	auctions uint64
+	noBids   uint64
+	winners  uint64
+	prices   []uint32
+}
+
+// priceAnalytics keeps hourly clearing-price histograms per series in fixed-size rings. Prices fall in
+// log-scaled buckets whose width is the configured relative precision, so memory per window is fixed.
+type priceAnalytics struct {
+	config *config.AnalyticsConfig
+	clock  utils.Clock
+	mutex  sync.Mutex
+	series map[priceSeriesKey][]priceWindow
+	scale  logScale
+}
+
+// newPriceAnalytics creates the aggregates for prices between the configured bid bounds, or nil when disabled
+func newPriceAnalytics(cfg *config.Config, clock utils.Clock) *priceAnalytics {
+	if cfg.Analytics == nil || !cfg.Analytics.Enabled {
+		return nil
+	}
+
+	return &priceAnalytics{
+		config: cfg.Analytics,
+		clock:  clock,
+		series: make(map[priceSeriesKey][]priceWindow),
+		scale:  newLogScale(cfg.MinBidPrice, cfg.MaxBidPrice, cfg.Analytics.Precision),
+	}
+}
+
+// Record adds an auction's winning prices to the current hour; an auction without winners counts as a no-bid
+func (a *priceAnalytics) Record(request *models.BidRequest, winners []*models.Bid) {
+	if a == nil {
+		return
+	}
+
+	keys := []priceSeriesKey{{Vertical: request.Vertical}}
+	if a.config.ByRegion && request.Geo != nil && request.Geo.Region != "" {
+		keys = append(keys, priceSeriesKey{Vertical: request.Vertical, Region: request.Geo.Region})
+	}
+	hour := a.clock.Now().Unix() / 3600
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	for _, key := range keys {
+		windows, exists := a.series[key]
+		if !exists {
+			if len(a.series) >= a.config.MaxSeries {
+				analyticsSeriesDroppedTotal.Inc()
+				continue
+			}
+			windows = make([]priceWindow, a.config.Windows)
+			a.series[key] = windows
+		}
+
+		window := &windows[hour%int64(len(windows))]
+		if window.hour != hour {
+			window.hour, window.auctions, window.noBids, window.winners = hour, 0, 0, 0
+			clear(window.prices)
+		}
+
+		window.auctions++
+		if len(winners) == 0 {
+			window.noBids++
+			continue
+		}
+		window.winners += uint64(len(winners))
+		if window.prices == nil {
+			window.prices = make([]uint32, a.scale.buckets)
+		}
+		for _, bid := range winners {
+			window.prices[a.scale.bucket(bid.CPL())]++
+		}
+	}
+}
+
+// Summary merges the hourly windows covering the last window for a series
+func (a *priceAnalytics) Summary(vertical, region string, window time.Duration) (*PriceSummary, error) {
+	if a == nil {
+		return nil, ErrAnalyticsDisabled
+	}
+	hours := int64(math.Ceil(window.Hours()))
+	if hours < 1 || hours > int64(a.config.Windows) {
+		return nil, fmt.Errorf("%w: window must be between 1h and %dh", ErrInvalidAnalyticsQuery, a.config.Windows)
+	}
+	if region != "" && !a.config.ByRegion {
+		return nil, fmt.Errorf("%w: regional analytics are not enabled", ErrInvalidAnalyticsQuery)
+	}
+
+	summary := &PriceSummary{Vertical: vertical, Region: region, Window: (time.Duration(hours) * time.Hour).String()}
+	totals := a.totals(priceSeriesKey{Vertical: vertical, Region: region}, hours)
+	summary.Count = totals.auctions
+	if summary.Count == 0 {
+		return summary, nil
+	}
+	summary.AvgWinners = float64(totals.winners) / float64(summary.Count)
+	summary.NoBidRate = float64(totals.noBids) / float64(summary.Count)
+	summary.P25 = a.percentile(totals.prices, totals.sold, 0.25)
+	summary.P50 = a.percentile(totals.prices, totals.sold, 0.50)
+	summary.P75 = a.percentile(totals.prices, totals.sold, 0.75)
+	summary.P95 = a.percentile(totals.prices, totals.sold, 0.95)
+	return summary, nil
+}
+
+// priceTotals sums a series' hourly windows; sold counts the clearing prices in the histogram
+type priceTotals struct {
+	auctions uint64
+	noBids   uint64
+	winners  uint64
+	sold     uint64
+	prices   []uint64
+}
+
+// totals merges the hourly windows covering the last hours of a series
+func (a *priceAnalytics) totals(key priceSeriesKey, hours int64) priceTotals {
+	totals := priceTotals{prices: make([]uint64, a.scale.buckets)}
+	now := a.clock.Now().Unix() / 3600
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	windows := a.series[key]
+	for hour := now - hours + 1; hour <= now && len(windows) > 0; hour++ {
+		w := &windows[hour%int64(len(windows))]
+		if w.hour != hour {
+			continue
+		}
+		totals.auctions += w.auctions
+		totals.winners += w.winners
+		totals.noBids += w.noBids
+		for i, count := range w.prices {
+			totals.prices[i] += uint64(count)
+			totals.sold += uint64(count)
+		}
+	}
+	return totals
+}
+
+// clearingPrice returns quantile q of a series' clearing prices over the last hours, rounded to
+// cents, and how many prices it was drawn from
+func (a *priceAnalytics) clearingPrice(key priceSeriesKey, hours int64, q float64) (float64, uint64) {
+	totals := a.totals(key, hours)
+	if totals.sold == 0 {
+		return 0, 0
+	}
+	return a.percentile(totals.prices, totals.sold, q), totals.sold
+}
+
+// seriesKeys returns the tracked series
+func (a *priceAnalytics) seriesKeys() []priceSeriesKey {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	keys := make([]priceSeriesKey, 0, len(a.series))
+	for key := range a.series {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// percentile returns quantile q of the clearing prices, rounded to cents
+func (a *priceAnalytics) percentile(prices []uint64, total uint64, q float64) float64 {
+	return math.Round(a.scale.quantile(prices, total, q)*100) / 100
+}
+
+// PriceAnalytics summarizes clearing prices for a vertical, and a region when non-empty, over window
+func (s *AuctionService) PriceAnalytics(vertical, region string, window time.Duration) (*PriceSummary, error) {
+	return s.analytics.Summary(vertical, region, window)
+}
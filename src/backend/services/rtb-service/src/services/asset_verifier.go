@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap" // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+	"github.com/yourdomain/rtb-service/src/webhooks"
+)
+
+// AssetVerifierUserAgent identifies asset verification requests to the hosts serving creatives
+const AssetVerifierUserAgent = "rtb-service-asset-verifier/1.0"
+
+// Asset check results counted in rtb_asset_checks_total; every result but ok is a failure
+const (
+	assetResultOK          = "ok"
+	assetResultStatus      = "status"       // the image URL answered with a non-2xx status
+	assetResultContentType = "content_type" // the image URL did not serve an image
+	assetResultTooLarge    = "too_large"    // the image is larger than the configured maximum
+	assetResultError       = "error"        // the request failed or timed out
+	assetResultInvalidURL  = "invalid_url"  // the image URL is not an absolute http(s) URL
+	assetResultDropped     = "dropped"      // the check was skipped by the rate limit
+)
+
+// Asset verification history kept per partner
+const (
+	assetCheckWindow       = 100 // checks the failure rate is computed over
+	maxRecentAssetFailures = 20
+)
+
+// AssetFailure is a winning creative whose image failed verification
+type AssetFailure struct {
+	BidID       string    `json:"bid_id"`
+	RequestID   string    `json:"request_id"`
+	ImageURL    string    `json:"image_url"`
+	Result      string    `json:"result"`
+	Status      int       `json:"status,omitempty"`
+	ContentType string    `json:"content_type,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	CheckedAt   time.Time `json:"checked_at"`
+}
+
+// AssetStatus is a partner's asset verification record over its recent checks
+type AssetStatus struct {
+	Checks      int     `json:"checks"`
+	Failures    int     `json:"failures"`
+	FailureRate float64 `json:"failure_rate"`
+	Flagged     bool    `json:"flagged"`
+	// RecentFailures are the partner's latest failed assets, newest first
+	RecentFailures []AssetFailure `json:"recent_failures,omitempty"`
+}
+
+// AssetsFlaggedEvent is the data of a partner.assets_flagged webhook
+type AssetsFlaggedEvent struct {
+	PartnerID      string         `json:"partner_id"`
+	Checks         int            `json:"checks"`
+	Failures       int            `json:"failures"`
+	FailureRate    float64        `json:"failure_rate"`
+	Threshold      float64        `json:"threshold"`
+	RecentFailures []AssetFailure `json:"recent_failures"`
+	FlaggedAt      time.Time      `json:"flagged_at"`
+}
+
+// partnerAssets is one partner's recent asset checks
+type partnerAssets struct {
+	failed   []bool // ring of the last assetCheckWindow checks, true where the check failed
+	next     int
+	failures int
+	recent   []AssetFailure // oldest first
+	flagged  bool
+}
+
+// record adds a check to the window and returns whether it changed the partner's flag
+func (p *partnerAssets) record(check AssetFailure, threshold float64, minChecks int) bool {
+	failed := check.Result != assetResultOK
+	if len(p.failed) < assetCheckWindow {
+		p.failed = append(p.failed, failed)
+	} else {
+		if p.failed[p.next] {
+			p.failures--
+		}
+		p.failed[p.next] = failed
+		p.next = (p.next + 1) % assetCheckWindow
+	}
+	if failed {
+		p.failures++
+		p.recent = append(p.recent, check)
+		if len(p.recent) > maxRecentAssetFailures {
+			p.recent = p.recent[len(p.recent)-maxRecentAssetFailures:]
+		}
+	}
+
+	flagged := len(p.failed) >= minChecks && p.failureRate() >= threshold
+	changed := flagged != p.flagged
+	p.flagged = flagged
+	return changed
+}
+
+// failureRate returns the share of the window's checks that failed
+func (p *partnerAssets) failureRate() float64 {
+	if len(p.failed) == 0 {
+		return 0
+	}
+	return float64(p.failures) / float64(len(p.failed))
+}
+
+// status returns the partner's record, with its recent failures when withFailures is set
+func (p *partnerAssets) status(withFailures bool) AssetStatus {
+	status := AssetStatus{Checks: len(p.failed), Failures: p.failures, FailureRate: p.failureRate(), Flagged: p.flagged}
+	if withFailures {
+		status.RecentFailures = make([]AssetFailure, len(p.recent))
+		for i, failure := range p.recent {
+			status.RecentFailures[len(p.recent)-1-i] = failure
+		}
+	}
+	return status
+}
+
+// assetVerifier checks the images of sampled winning creatives on their own goroutines, which
+// Close waits for. It has no config when asset verification is disabled.
+type assetVerifier struct {
+	config   *config.AssetVerificationConfig
+	client   *http.Client
+	limiter  *partnerLimiter
+	logger   *zap.Logger
+	mutex    sync.Mutex
+	partners map[string]*partnerAssets
+	wg       sync.WaitGroup
+}
+
+// newAssetVerifier creates the asset verifier, or an idle one when verification is disabled
+func newAssetVerifier(cfg *config.AssetVerificationConfig, clock utils.Clock) *assetVerifier {
+	verifier := &assetVerifier{logger: zap.NewNop(), partners: make(map[string]*partnerAssets)}
+	if cfg == nil || !cfg.Enabled {
+		return verifier
+	}
+	rate := cfg.CheckRateLimit()
+	verifier.config = cfg
+	verifier.client = &http.Client{Timeout: cfg.CheckTimeout()}
+	verifier.limiter = &partnerLimiter{rate: rate, burst: rate, tokens: rate, lastFill: clock.Now()}
+	return verifier
+}
+
+// Close waits for asset checks in flight, which end within their timeout
+func (v *assetVerifier) Close() {
+	v.wg.Wait()
+}
+
+// verifyAssets checks the images of a sample of an auction's winning creatives without waiting
+// for them. Checks run after the auction and never touch its response, the partner's breaker, or
+// its live stats. Dry runs record the checks instead, and replays skip them.
+func (s *AuctionService) verifyAssets(ctx context.Context, request *models.BidRequest, response *models.BidResponse) {
+	verifier := s.assets
+	if verifier.config == nil || models.ReplayFromContext(ctx) != nil {
+		return
+	}
+	random := s.randFor(request, randomAssets)
+	for _, bid := range response.Bids {
+		imageURL := bid.ImageURL()
+		if imageURL == "" || random.Float64() >= verifier.config.CheckSampleRate() {
+			continue
+		}
+		if dryRun := models.DryRunFromContext(ctx); dryRun != nil {
+			dryRun.Record(models.SideEffect{Type: models.SideEffectAssetCheck, PartnerID: bid.PartnerID, Detail: imageURL})
+			continue
+		}
+		if !verifier.limiter.Allow(false) {
+			assetChecksTotal.WithLabelValues(bid.PartnerID, assetResultDropped).Inc()
+			continue
+		}
+
+		// Copy what the check needs now, while the auction still owns the bid
+		partnerID := bid.PartnerID
+		check := AssetFailure{BidID: bid.ID, RequestID: request.RequestID, ImageURL: imageURL}
+		verifier.wg.Add(1)
+		go func() {
+			defer verifier.wg.Done()
+			verifier.check(&check)
+			check.CheckedAt = s.clock.Now().UTC()
+			s.recordAssetCheck(partnerID, check)
+		}()
+	}
+}
+
+// check requests the image and sets the check's result. Hosts that refuse HEAD are asked with
+// GET, reading no more of the image than the size limit.
+func (v *assetVerifier) check(check *AssetFailure) {
+	parsed, err := url.Parse(check.ImageURL)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		check.Result = assetResultInvalidURL
+		return
+	}
+
+	resp, err := v.request(http.MethodHead, check.ImageURL)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		resp.Body.Close()
+		resp, err = v.request(http.MethodGet, check.ImageURL)
+	}
+	if err != nil {
+		check.Result, check.Error = assetResultError, err.Error()
+		return
+	}
+	defer resp.Body.Close()
+	size := resp.ContentLength
+	if resp.Request.Method == http.MethodGet {
+		read, err := io.Copy(io.Discard, io.LimitReader(resp.Body, v.config.MaxAssetBytes()+1))
+		if err != nil {
+			check.Result, check.Error = assetResultError, err.Error()
+			return
+		}
+		size = max(size, read)
+	}
+
+	check.Status = resp.StatusCode
+	check.ContentType = resp.Header.Get("Content-Type")
+	mediaType, _, _ := mime.ParseMediaType(check.ContentType)
+	switch {
+	case resp.StatusCode < 200 || resp.StatusCode >= 300:
+		check.Result = assetResultStatus
+	case !strings.HasPrefix(mediaType, "image/"):
+		check.Result = assetResultContentType
+	case size > v.config.MaxAssetBytes():
+		check.Result = assetResultTooLarge
+	default:
+		check.Result = assetResultOK
+	}
+}
+
+// request sends one identified verification request for an image
+func (v *assetVerifier) request(method, imageURL string) (*http.Response, error) {
+	req, err := http.NewRequest(method, imageURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", AssetVerifierUserAgent)
+	return v.client.Do(req)
+}
+
+// recordAssetCheck adds a check to the partner's record, flagging or clearing the partner when it
+// crosses the failure rate threshold. Newly flagged partners are announced by webhook when the
+// config asks for it.
+func (s *AuctionService) recordAssetCheck(partnerID string, check AssetFailure) {
+	verifier := s.assets
+	assetChecksTotal.WithLabelValues(partnerID, check.Result).Inc()
+
+	verifier.mutex.Lock()
+	assets, exists := verifier.partners[partnerID]
+	if !exists {
+		assets = &partnerAssets{}
+		verifier.partners[partnerID] = assets
+	}
+	changed := assets.record(check, verifier.config.FlagThreshold(), verifier.config.FlagMinChecks())
+	status := assets.status(changed && assets.flagged)
+	verifier.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+	if !status.Flagged {
+		partnerAssetsFlagged.WithLabelValues(partnerID).Set(0)
+		verifier.logger.Info("partner assets no longer flagged", zap.String("partner", partnerID),
+			zap.Float64("failure_rate", status.FailureRate))
+		return
+	}
+	partnerAssetsFlagged.WithLabelValues(partnerID).Set(1)
+	verifier.logger.Warn("partner assets flagged", zap.String("partner", partnerID),
+		zap.Int("checks", status.Checks), zap.Int("failures", status.Failures), zap.Float64("failure_rate", status.FailureRate))
+	if !verifier.config.Webhook {
+		return
+	}
+	s.dispatchWebhook(context.Background(), webhooks.Event{
+		ID:        webhookEventID(config.WebhookEventPartnerAssetsFlagged, partnerID, check.CheckedAt.Format(time.RFC3339Nano)),
+		Type:      config.WebhookEventPartnerAssetsFlagged,
+		CreatedAt: check.CheckedAt,
+		Data: AssetsFlaggedEvent{
+			PartnerID:      partnerID,
+			Checks:         status.Checks,
+			Failures:       status.Failures,
+			FailureRate:    status.FailureRate,
+			Threshold:      verifier.config.FlagThreshold(),
+			RecentFailures: status.RecentFailures,
+			FlaggedAt:      check.CheckedAt,
+		},
+	})
+}
+
+// assetStatus returns a partner's asset verification record, or nil when it has no checks
+func (s *AuctionService) assetStatus(partnerID string) *AssetStatus {
+	s.assets.mutex.Lock()
+	defer s.assets.mutex.Unlock()
+	assets, exists := s.assets.partners[partnerID]
+	if !exists {
+		return nil
+	}
+	status := assets.status(false)
+	return &status
+}
+
+// AssetFailures returns every checked partner's asset verification record with its recent failed
+// assets, keyed by partner ID
+func (s *AuctionService) AssetFailures() map[string]AssetStatus {
+	s.assets.mutex.Lock()
+	defer s.assets.mutex.Unlock()
+	statuses := make(map[string]AssetStatus, len(s.assets.partners))
+	for partnerID, assets := range s.assets.partners {
+		statuses[partnerID] = assets.status(true)
+	}
+	return statuses
+}
+
+// AssetVerificationEnabled reports whether winning creatives' images are being checked
+func (s *AuctionService) AssetVerificationEnabled() bool {
+	return s.assets.config != nil
+}
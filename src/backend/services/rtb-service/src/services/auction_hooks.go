@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"go.uber.org/zap" // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// DefaultHookTimeout bounds a hook that does not set its own timeout
+const DefaultHookTimeout = 100 * time.Millisecond
+
+// Built-in hook names, reserved so registered hooks cannot shadow them
+const (
+	hookIVT   = "ivt"
+	hookAudit = "audit"
+)
+
+// Auction phases and hook outcomes reported by the hook metrics
+const (
+	hookPhasePreAuction     = "pre_auction"
+	hookPhasePostCollection = "post_collection"
+	hookPhasePostAuction    = "post_auction"
+
+	hookOutcomeOK       = "ok"
+	hookOutcomeRejected = "rejected"
+	hookOutcomeTimeout  = "timeout"
+	hookOutcomePanic    = "panic"
+)
+
+// Auction hook errors
+var (
+	ErrInvalidHook  = errors.New("invalid auction hook")
+	ErrHookRejected = errors.New("auction rejected by hook")
+)
+
+// AuctionHook runs custom code around every auction. Each hook sets any of its three phases:
+//   - PreAuction runs before partners are called and may change the request, which is then
+//     validated like any other, or reject the auction by returning an error
+//   - PostCollection sees the bids collected from partners before winners are selected. The slice
+//     is reused once the auction ends and must not be kept.
+//   - PostAuction sees the response of an auction that produced winners
+//
+// Hooks run in registration order, after the built-in ones, on the auction's goroutine, with a
+// context that expires after Timeout; a hook must return once it does. A hook that times out or
+// panics is skipped, and the auction goes on without it. The auction's request is available to
+// every phase through models.AuctionFromContext(ctx).Request().
+type AuctionHook struct {
+	Name           string
+	Timeout        time.Duration // zero means DefaultHookTimeout
+	PreAuction     func(ctx context.Context, request *models.BidRequest) error
+	PostCollection func(ctx context.Context, bids []*models.Bid)
+	PostAuction    func(ctx context.Context, response *models.BidResponse)
+}
+
+// timeout returns how long the hook may run
+func (h *AuctionHook) timeout() time.Duration {
+	if h.Timeout <= 0 {
+		return DefaultHookTimeout
+	}
+	return h.Timeout
+}
+
+// validateAuctionHooks checks that every hook has a unique name, not one of the built-in ones,
+// and at least one phase
+func validateAuctionHooks(hooks []AuctionHook) error {
+	names := map[string]bool{hookIVT: true, hookAudit: true}
+	for _, hook := range hooks {
+		if hook.Name == "" {
+			return fmt.Errorf("%w: name is required", ErrInvalidHook)
+		}
+		if names[hook.Name] {
+			return fmt.Errorf("%w: duplicate or reserved name %s", ErrInvalidHook, hook.Name)
+		}
+		names[hook.Name] = true
+		if hook.Timeout < 0 {
+			return fmt.Errorf("%w: %s has a negative timeout", ErrInvalidHook, hook.Name)
+		}
+		if hook.PreAuction == nil && hook.PostCollection == nil && hook.PostAuction == nil {
+			return fmt.Errorf("%w: %s sets no phase", ErrInvalidHook, hook.Name)
+		}
+	}
+	return nil
+}
+
+// auctionHooks holds the hooks of each phase in the order they run
+type auctionHooks struct {
+	logger         *zap.Logger
+	preAuction     []AuctionHook
+	postCollection []AuctionHook
+	postAuction    []AuctionHook
+}
+
+// newAuctionHooks sorts validated hooks into their phases
+func newAuctionHooks(hooks []AuctionHook) *auctionHooks {
+	registry := &auctionHooks{logger: zap.NewNop()}
+	for _, hook := range hooks {
+		if hook.PreAuction != nil {
+			registry.preAuction = append(registry.preAuction, hook)
+		}
+		if hook.PostCollection != nil {
+			registry.postCollection = append(registry.postCollection, hook)
+		}
+		if hook.PostAuction != nil {
+			registry.postAuction = append(registry.postAuction, hook)
+		}
+	}
+	return registry
+}
+
+// builtinHooks returns the hooks behind the service's own cross-cutting features, in the order
+// they run ahead of registered hooks
+func (s *AuctionService) builtinHooks() []AuctionHook {
+	var hooks []AuctionHook
+	if s.ivt != nil {
+		hooks = append(hooks, AuctionHook{Name: hookIVT, Timeout: ivtHookTimeout, PreAuction: s.screenAuction})
+	}
+	if s.audit != nil {
+		hooks = append(hooks, AuctionHook{Name: hookAudit, PostAuction: s.auditAuction})
+	}
+	return hooks
+}
+
+// runPreAuction runs the pre-auction hooks in order, stopping at the first that rejects the auction
+func (h *auctionHooks) runPreAuction(ctx context.Context, request *models.BidRequest) error {
+	for i := range h.preAuction {
+		hook := &h.preAuction[i]
+		err := h.run(ctx, hook, hookPhasePreAuction, func(ctx context.Context) error {
+			return hook.PreAuction(ctx, request)
+		})
+		if err != nil {
+			return fmt.Errorf("%w: %s: %w", ErrHookRejected, hook.Name, err)
+		}
+	}
+	return nil
+}
+
+// runPostCollection runs the post-collection hooks in order
+func (h *auctionHooks) runPostCollection(ctx context.Context, bids []*models.Bid) {
+	for i := range h.postCollection {
+		hook := &h.postCollection[i]
+		h.run(ctx, hook, hookPhasePostCollection, func(ctx context.Context) error {
+			hook.PostCollection(ctx, bids)
+			return nil
+		})
+	}
+}
+
+// runPostAuction runs the post-auction hooks in order
+func (h *auctionHooks) runPostAuction(ctx context.Context, response *models.BidResponse) {
+	for i := range h.postAuction {
+		hook := &h.postAuction[i]
+		h.run(ctx, hook, hookPhasePostAuction, func(ctx context.Context) error {
+			hook.PostAuction(ctx, response)
+			return nil
+		})
+	}
+}
+
+// run calls one hook within its timeout, timing it and recovering a panic, and returns the error
+// rejecting the auction, if any. Errors caused by the hook's own deadline count as a timeout.
+func (h *auctionHooks) run(ctx context.Context, hook *AuctionHook, phase string, call func(context.Context) error) (err error) {
+	hookCtx, cancel := context.WithTimeout(ctx, hook.timeout())
+	defer cancel()
+	start := time.Now()
+	defer func() {
+		outcome := hookOutcomeOK
+		if recovered := recover(); recovered != nil {
+			h.logger.Error("auction hook panicked", zap.String("hook", hook.Name), zap.String("phase", phase),
+				zap.Any("panic", recovered), zap.ByteString("stack", debug.Stack()))
+			outcome, err = hookOutcomePanic, nil
+		} else if errors.Is(hookCtx.Err(), context.DeadlineExceeded) && (err == nil || errors.Is(err, context.DeadlineExceeded)) {
+			h.logger.Warn("auction hook timed out", zap.String("hook", hook.Name), zap.String("phase", phase),
+				zap.Duration("timeout", hook.timeout()))
+			outcome, err = hookOutcomeTimeout, nil
+		} else if err != nil {
+			outcome = hookOutcomeRejected
+		}
+		hookDuration.WithLabelValues(hook.Name, phase).Observe(time.Since(start).Seconds())
+		hookRunsTotal.WithLabelValues(hook.Name, phase, outcome).Inc()
+	}()
+	return call(hookCtx)
+}
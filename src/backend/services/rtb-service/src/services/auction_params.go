@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// ErrAuctionParamsEmpty rejects forced auction parameters that set nothing
+var ErrAuctionParamsEmpty = errors.New("auction params must set at least one parameter")
+
+// ValidateAuctionParams checks forced auction parameters against the hard safety bounds: first
+// price auctions only, a quality weight in [0, 1], a known strategy, and a floor no higher than
+// the max bid price
+func (s *AuctionService) ValidateAuctionParams(params *models.AuctionParams) error {
+	if params == nil || *params == (models.AuctionParams{}) {
+		return ErrAuctionParamsEmpty
+	}
+	switch params.AuctionType {
+	case "", models.AuctionTypeFirstPrice:
+	default:
+		return fmt.Errorf("unsupported auction type %q: only %s auctions are run", params.AuctionType, models.AuctionTypeFirstPrice)
+	}
+	if weight := params.QualityWeight; weight != nil && !(*weight >= 0 && *weight <= 1) {
+		return fmt.Errorf("auction params quality weight must be in [0, 1]: %v", *weight)
+	}
+	switch params.Strategy {
+	case "", config.StrategyEffectivePrice, config.StrategyQualityWeighted, config.StrategyPassthrough:
+	default:
+		return fmt.Errorf("unknown optimization strategy %q", params.Strategy)
+	}
+	if params.Floor < 0 || params.Floor > s.config.MaxBidPrice || math.IsNaN(params.Floor) {
+		return fmt.Errorf("auction params floor must be between 0 and the max bid price: %v", params.Floor)
+	}
+	return nil
+}
+
+// auctionArm returns the arm an auction runs in: the forced parameters of an admin caller, which
+// take the auction out of every experiment, or else its experiment variants
+func (s *AuctionService) auctionArm(ctx context.Context, request *models.BidRequest) experimentArm {
+	params := models.AuctionParamsFromContext(ctx)
+	if params == nil {
+		return s.experiments.assign(request)
+	}
+	models.DebugFromContext(ctx).RecordAuctionParams(params)
+
+	arm := experimentArm{assignments: []models.ExperimentAssignment{models.ManualOverrideAssignment}, floor: params.Floor, manual: true}
+	overrides := config.ExperimentOverrides{QualityWeight: params.QualityWeight, Strategy: params.Strategy, Floor: params.Floor}
+	if optimizer, err := utils.NewBidOptimizerWithClock(overrides.Apply(s.config), nil, s.clock); err == nil {
+		arm.optimizer = optimizer
+	}
+	return arm
+}
+
+// manualOverride reports whether an auction ran with forced parameters rather than in experiments
+func manualOverride(assignments []models.ExperimentAssignment) bool {
+	return len(assignments) == 1 && assignments[0] == models.ManualOverrideAssignment
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8" // v8.11.5
+	"go.uber.org/zap"              // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+	"github.com/yourdomain/rtb-service/src/webhooks"
+)
+
+// auctionSeqKey is the Redis counter every instance reserves auction sequence numbers from
+const auctionSeqKey = "rtb:auction_seq"
+
+// Reservations block the auctions waiting on them, so a slow Redis falls back to ULIDs quickly
+// and is only retried once per interval
+const (
+	auctionSeqTimeout       = 100 * time.Millisecond
+	auctionSeqRetryInterval = time.Second
+)
+
+// Auction sequence sources reported by the sequence metric
+const (
+	auctionSeqSourceCounter = "counter"
+	auctionSeqSourceULID    = "ulid"
+)
+
+// AuctionSeqRange is the data of auction_seq.reserved and auction_seq.released webhooks: the
+// sequence numbers from From to To inclusive
+type AuctionSeqRange struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// auctionSequencer assigns auction sequence numbers from batches reserved from the shared Redis
+// counter, so only one auction in a batch waits on Redis. Numbers within a batch are assigned in
+// order, but batches reserved by different instances interleave, so across instances a higher
+// number does not mean a later auction.
+type auctionSequencer struct {
+	client    *redis.Client // nil assigns every auction a ULID
+	batchSize int64
+	clock     utils.Clock
+	logger    *zap.Logger
+	ulids     *utils.ULIDSource
+	mutex     sync.Mutex
+	next      int64 // the next number of the current batch
+	end       int64 // the last number of the current batch, below next once it is used up
+	retryAt   time.Time
+}
+
+// newAuctionSequencer creates the sequencer when auction sequence numbers are enabled, otherwise nil
+func newAuctionSequencer(cfg *config.AuctionSequenceConfig, client *redis.Client, clock utils.Clock) *auctionSequencer {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	return &auctionSequencer{
+		client:    client,
+		batchSize: cfg.ReservedNumbers(),
+		clock:     clock,
+		logger:    zap.NewNop(),
+		ulids:     utils.NewULIDSource(clock),
+		next:      1,
+	}
+}
+
+// assign returns the next auction sequence number, reserving a new batch when the current one is
+// used up. It returns the batch when it reserved one. While Redis cannot be reached auctions get a
+// ULID instead.
+func (a *auctionSequencer) assign() (*models.AuctionSeq, *AuctionSeqRange) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	var reserved *AuctionSeqRange
+	if a.next > a.end && a.client != nil && !a.clock.Now().Before(a.retryAt) {
+		reserved = a.reserve()
+	}
+	if a.next > a.end {
+		auctionSeqsTotal.WithLabelValues(auctionSeqSourceULID).Inc()
+		return &models.AuctionSeq{ULID: a.ulids.New()}, nil
+	}
+	seq := a.next
+	a.next++
+	auctionSeqsTotal.WithLabelValues(auctionSeqSourceCounter).Inc()
+	return &models.AuctionSeq{Seq: seq}, reserved
+}
+
+// reserve takes the next batch from the Redis counter, or backs off until the retry interval has
+// passed when Redis fails. It is called with the mutex held.
+func (a *auctionSequencer) reserve() *AuctionSeqRange {
+	ctx, cancel := context.WithTimeout(context.Background(), auctionSeqTimeout)
+	defer cancel()
+	end, err := a.client.IncrBy(ctx, auctionSeqKey, a.batchSize).Result()
+	if err != nil {
+		a.retryAt = a.clock.Now().Add(auctionSeqRetryInterval)
+		a.logger.Warn("reserving auction sequence numbers, falling back to ULIDs", zap.Error(err))
+		return nil
+	}
+	a.next, a.end = end-a.batchSize+1, end
+	return &AuctionSeqRange{From: a.next, To: a.end}
+}
+
+// release gives up the rest of the current batch, returning it, or nil when it is used up
+func (a *auctionSequencer) release() *AuctionSeqRange {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.next > a.end {
+		return nil
+	}
+	released := &AuctionSeqRange{From: a.next, To: a.end}
+	a.next = a.end + 1
+	return released
+}
+
+// assignAuctionSeq returns the sequence number of an auction with an outcome, or nil when
+// sequence numbers are disabled. Dry runs and replays have no outcome of their own, so they
+// take no number.
+func (s *AuctionService) assignAuctionSeq(ctx context.Context) *models.AuctionSeq {
+	if s.sequence == nil || models.DryRunFromContext(ctx) != nil || models.ReplayFromContext(ctx) != nil {
+		return nil
+	}
+	seq, reserved := s.sequence.assign()
+	if reserved != nil {
+		s.notifyAuctionSeqs(config.WebhookEventAuctionSeqReserved, reserved)
+	}
+	return seq
+}
+
+// releaseAuctionSeqs dispatches an auction_seq.released webhook for the numbers of the current
+// batch no auction took, so consumers do not wait for them
+func (s *AuctionService) releaseAuctionSeqs() {
+	if s.sequence == nil {
+		return
+	}
+	if released := s.sequence.release(); released != nil {
+		s.notifyAuctionSeqs(config.WebhookEventAuctionSeqReleased, released)
+	}
+}
+
+// notifyAuctionSeqs dispatches an auction_seq.reserved or auction_seq.released webhook. Batches are
+// reserved once, so the range identifies the event.
+func (s *AuctionService) notifyAuctionSeqs(eventType string, seqs *AuctionSeqRange) {
+	s.dispatchWebhook(context.Background(), webhooks.Event{
+		ID:        webhookEventID(eventType, strconv.FormatInt(seqs.From, 10), strconv.FormatInt(seqs.To, 10)),
+		Type:      eventType,
+		CreatedAt: s.clock.Now().UTC(),
+		Data:      *seqs,
+	})
+}
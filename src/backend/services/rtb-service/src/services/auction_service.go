@@ -5,207 +5,1132 @@ package services
 import (
     "context"
     "errors"
+    "fmt"
+    "math/rand"
+    "net/http"
+    "sort"
     "sync"
+    "sync/atomic"
     "time"
 
+    "github.com/go-redis/redis/v8" // v8.11.5
+
+    "github.com/yourdomain/rtb-service/src/audit"
     "github.com/yourdomain/rtb-service/src/config"
+    "github.com/yourdomain/rtb-service/src/export"
     "github.com/yourdomain/rtb-service/src/models"
     "github.com/yourdomain/rtb-service/src/utils"
+    "github.com/yourdomain/rtb-service/src/webhooks"
 )
 
+// RequestIDHeader carries the auction request ID to partners for cross-system correlation
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDHeaderKey is RequestIDHeader in canonical form, so setting it skips canonicalizing per call
+var requestIDHeaderKey = http.CanonicalHeaderKey(RequestIDHeader)
+
+// RegionHeader tells partners which service region an auction ran in
+const RegionHeader = "X-RTB-Region"
+
 // Global error definitions
 var (
     ErrNoValidBids     = errors.New("no valid bids received")
     ErrAuctionTimeout  = errors.New("auction timed out")
     ErrInvalidRequest  = errors.New("invalid bid request")
     ErrPartnerFailure  = errors.New("partner bid collection failed")
+    ErrInsufficientCompetition = errors.New("too few distinct partners bid")
 )
 
 // AuctionService manages RTB auctions with thread-safe operations
 type AuctionService struct {
     config          *config.Config
     optimizer       *utils.BidOptimizer
-    mutex           sync.RWMutex
-    partnerFailures map[string]int
+    rounder         *utils.PriceRounder
+    mutex           sync.RWMutex // guards scorer
+    partners        atomic.Value // map[string]*config.PartnerConfig
+    schemas         atomic.Value // map[string]*userDataSchema
+    bidRules        atomic.Value // *bidRules
+    verticals       atomic.Value // *verticalSettings
+    stats           *partnerStats
+    breakers        *circuitBreakers
+    backoffs        *partnerBackoffs
+    redis           *redis.Client
+    clients         *partnerClients
+    mirrors         *partnerMirrors
+    idempotency     *idempotencyGuard
+    partnerLimiters map[string]*partnerLimiter
+    verticalLimiters map[verticalPartnerKey]*partnerLimiter
+    scorer          ScoringService
+    schedules       map[string]*partnerSchedule
+    clock           utils.Clock
+    random          *rand.Rand
+    enricher        *enricher
+    adapters        map[string]PartnerAdapter
+    payloadVariants map[string]*payloadVariant
+    endpoints       *endpointRouter
+    analytics       *priceAnalytics
+    floors          *adaptiveFloors
+    reports         *PartnerReporter
+    audit           *audit.Writer
+    webhooks        *webhooks.Dispatcher
+    recorder        *recordingWriter
+    exporter        *export.Exporter
+    reservations    *reservationSweeper
+    workers         *partnerWorkers
+    ivt             *ivtFilter
+    negatives       *negativeCache
+    replays         *replayGuard
+    overrides       *overrides
+    captures        *partnerCaptures
+    selection       *partnerSelection
+    experiments     *experiments
+    breakerState    *breakerPersister
+    warmup          atomic.Value // map[string]WarmupResult
+    drains          *partnerDrains
+    budgets         *partnerBudgets
+    faults          *faultInjector
+    assets          *assetVerifier
+    configs         *configHistory
+    sequence        *auctionSequencer
+    hooks           *auctionHooks
+    activity        *partnerActivities
 }
 
 // NewAuctionService creates a new AuctionService instance with configuration validation
 func NewAuctionService(cfg *config.Config) (*AuctionService, error) {
+    return NewAuctionServiceWithClock(cfg, utils.SystemClock{})
+}
+
+// NewAuctionServiceWithClock creates an AuctionService that evaluates schedules against clock
+func NewAuctionServiceWithClock(cfg *config.Config, clock utils.Clock) (*AuctionService, error) {
+    return NewAuctionServiceWithSources(cfg, clock, nil)
+}
+
+// NewAuctionServiceWithSources creates an AuctionService that reads time from clock and draws
+// endpoint shuffles, retry jitter, and recording samples from source. Nil arguments fall back
+// to the system clock and a time-seeded source.
+func NewAuctionServiceWithSources(cfg *config.Config, clock utils.Clock, source rand.Source) (*AuctionService, error) {
+    return newAuctionService(cfg, clock, source, nil)
+}
+
+// NewAuctionServiceWithHooks creates an AuctionService that reads time from clock and runs hooks
+// around every auction, after the built-in ones, in the order given
+func NewAuctionServiceWithHooks(cfg *config.Config, clock utils.Clock, hooks ...AuctionHook) (*AuctionService, error) {
+    return newAuctionService(cfg, clock, nil, hooks)
+}
+
+// newAuctionService creates an AuctionService with its sources and registered hooks
+func newAuctionService(cfg *config.Config, clock utils.Clock, source rand.Source, hooks []AuctionHook) (*AuctionService, error) {
     if cfg == nil {
         return nil, errors.New("configuration cannot be nil")
     }
+    if err := validateAuctionHooks(hooks); err != nil {
+        return nil, err
+    }
+    if clock == nil {
+        clock = utils.SystemClock{}
+    }
+    if source == nil {
+        source = rand.NewSource(time.Now().UnixNano())
+    }
+
+    optimizer, err := utils.NewBidOptimizerWithClock(cfg, nil, clock)
+    if err != nil {
+        return nil, err
+    }
+
+    schemas, err := compileUserDataSchemas(cfg.UserDataSchemas)
+    if err != nil {
+        return nil, err
+    }
+    bidRules, err := compileBidRules(cfg.Rules)
+    if err != nil {
+        return nil, err
+    }
+
+    enricher, err := newEnricher(cfg.Enrichment)
+    if err != nil {
+        return nil, fmt.Errorf("opening GeoIP database: %w", err)
+    }
+
+    adapters, err := newPartnerAdapters(cfg, clock)
+    if err != nil {
+        return nil, err
+    }
+    payloadVariants, err := newPayloadVariants(cfg, clock)
+    if err != nil {
+        return nil, err
+    }
+
+    clients, err := newPartnerClients(cfg.HTTPClient, cfg.Partners)
+    if err != nil {
+        return nil, err
+    }
+
+    mirrors, err := newPartnerMirrors(cfg, clock)
+    if err != nil {
+        return nil, err
+    }
+
+    exportStore, err := newExportStore(cfg.Export)
+    if err != nil {
+        return nil, err
+    }
+
+    auditWriter, err := audit.NewWriter(cfg.Audit)
+    if err != nil {
+        return nil, err
+    }
 
-    optimizer, err := utils.NewBidOptimizer(cfg, nil)
+    dispatcher, err := webhooks.NewDispatcher(cfg.Webhooks)
     if err != nil {
+        auditWriter.Close()
         return nil, err
     }
 
-    return &AuctionService{
+    recorder, err := newRecordingWriter(cfg.Recording)
+    if err != nil {
+        auditWriter.Close()
+        dispatcher.Close()
+        return nil, err
+    }
+
+    redisClient := newRedisClient(cfg.Redis)
+
+    ivt, err := newIVTFilter(cfg.IVT, redisClient, clock)
+    if err != nil {
+        auditWriter.Close()
+        dispatcher.Close()
+        recorder.Close()
+        return nil, err
+    }
+
+    analytics := newPriceAnalytics(cfg, clock)
+    service := &AuctionService{
         config:          cfg,
         optimizer:       optimizer,
-        partnerFailures: make(map[string]int),
-    }, nil
+        rounder:         utils.NewPriceRounder(cfg.PriceRounding),
+        stats:           &partnerStats{},
+        breakers:        newCircuitBreakers(cfg.CircuitBreaker, clock),
+        backoffs:        newPartnerBackoffs(cfg.RateLimitBackoff, clock),
+        redis:           redisClient,
+        clients:         clients,
+        mirrors:         mirrors,
+        idempotency:     newIdempotencyGuard(cfg.Idempotency, redisClient),
+        partnerLimiters: newPartnerLimiters(cfg),
+        verticalLimiters: newVerticalPartnerLimiters(cfg),
+        scorer:          newScoringService(cfg.Scoring),
+        schedules:       newPartnerSchedules(cfg),
+        clock:           clock,
+        random:          rand.New(utils.NewLockedSource(source)),
+        enricher:        enricher,
+        adapters:        adapters,
+        payloadVariants: payloadVariants,
+        endpoints:       newEndpointRouter(cfg.CircuitBreaker, clock),
+        analytics:       analytics,
+        floors:          newAdaptiveFloors(cfg, analytics, clock),
+        reports:         NewPartnerReporter(cfg, clock),
+        audit:           auditWriter,
+        webhooks:        dispatcher,
+        recorder:        recorder,
+        exporter:        export.NewExporter(cfg.Export, exportStore, clock),
+        reservations:    newReservationSweeper(cfg.Reservations, redisClient),
+        ivt:             ivt,
+        negatives:       newNegativeCache(cfg, redisClient, clock),
+        replays:         newReplayGuard(cfg, redisClient, clock),
+        overrides:       newOverrides(clock),
+        captures:        newPartnerCaptures(cfg.Capture, clock),
+        selection:       newPartnerSelection(cfg, clock),
+        experiments:     newExperiments(cfg, clock),
+        drains:          newPartnerDrains(cfg.Partners),
+        budgets:         newPartnerBudgets(cfg, redisClient, clock),
+        assets:          newAssetVerifier(cfg.AssetVerification, clock),
+        configs:         newConfigHistory(cfg, clock),
+        sequence:        newAuctionSequencer(cfg.AuctionSequence, redisClient, clock),
+        activity:        newPartnerActivities(cfg, redisClient, clock),
+    }
+    service.faults = newFaultInjector(clock, service.random)
+    service.hooks = newAuctionHooks(append(service.builtinHooks(), hooks...))
+    if cfg.Budgets.AlertsWebhook() {
+        service.budgets.alert = service.sendBudgetAlert
+    }
+    if redisClient != nil && cfg.FaultInjection.Allowed() {
+        redisClient.AddHook(redisFaultHook{faults: service.faults})
+    }
+    service.partners.Store(cfg.Partners)
+    service.schemas.Store(schemas)
+    service.bidRules.Store(bidRules)
+    service.verticals.Store(&verticalSettings{config: cfg, optimizer: optimizer})
+    service.workers = newPartnerWorkers(cfg.PartnerWorkerLimit(), service.callPartner)
+
+    // Restore partner breakers tripped before a restart, so they stay out until their cooldown passes
+    service.breakerState = newBreakerPersister(cfg, redisClient, service.breakers, service.stats)
+    service.breakerState.restore(cfg.Partners)
+    service.breakerState.start()
+    // Pick up partner activity from before a restart and, with auto shadowing, watch for partners gone idle
+    var checkIdle func()
+    if cfg.Hygiene.AutoShadows() {
+        checkIdle = service.checkIdlePartners
+    }
+    service.activity.start(cfg.Hygiene.Interval(), checkIdle)
+    if service.reservations != nil {
+        service.reservations.start(service.sweepReservations)
+    }
+    return service, nil
 }
 
+// BidObserver receives each validated bid as it arrives from a partner.
+// It is called from partner goroutines and must be safe for concurrent use.
+type BidObserver func(bid *models.Bid)
+
 // RunAuction executes a complete RTB auction process
 func (s *AuctionService) RunAuction(ctx context.Context, request *models.BidRequest) (*models.BidResponse, error) {
+    return s.runAuction(ctx, request, nil)
+}
+
+// RunAuctionStream executes an auction, passing each validated bid to onBid as it arrives
+func (s *AuctionService) RunAuctionStream(ctx context.Context, request *models.BidRequest, onBid BidObserver) (*models.BidResponse, error) {
+    return s.runAuction(ctx, request, onBid)
+}
+
+// runAuction executes an auction with an optional bid observer and its adaptive floor, and adds its
+// outcome to the price analytics and experiment results
+func (s *AuctionService) runAuction(ctx context.Context, request *models.BidRequest, onBid BidObserver) (*models.BidResponse, error) {
+    // Synthetic auctions run as dry runs so they never reach reports, analytics, audit, webhooks,
+    // recordings, or exports
+    if models.IsSynthetic(ctx) && models.DryRunFromContext(ctx) == nil {
+        ctx = models.ContextWithDryRun(ctx, models.NewDryRun())
+    }
+    // Forced auction parameters travel in ctx and are never sent to partners
+    if request != nil && request.AuctionParams != nil {
+        stripped := *request
+        stripped.AuctionParams = nil
+        request = &stripped
+    }
+    ctx = s.startAuctionContext(ctx, request)
+    // Pre-auction hooks may change the request, so they run before it is recorded or validated
+    if request != nil {
+        if err := s.hooks.runPreAuction(ctx, request); err != nil {
+            return nil, err
+        }
+    }
+    ctx, recording := s.startRecording(ctx, request)
+    arm := s.auctionArm(ctx, request)
+    ctx = models.ContextWithAuction(ctx, models.AuctionFromContext(ctx).WithExperiments(arm.assignments))
+    settings := s.settingsFor(request)
+    floor := settings.floor(s.floors.floorFor(request))
+    response, err := s.executeAuction(ctx, request, onBid, arm, settings, floor)
+    // Only auctions with an outcome take a sequence number, so a gap means a missed event
+    var seq *models.AuctionSeq
+    if err == nil || errors.Is(err, ErrNoValidBids) || errors.Is(err, ErrInsufficientCompetition) {
+        seq = s.assignAuctionSeq(ctx)
+    }
+    if response != nil {
+        response.AuctionSeq = seq
+    }
+    s.finishRecording(recording, response, err)
+    switch {
+    case err == nil:
+        s.recordExperimentAuction(ctx, arm.assignments)
+        s.recordAnalytics(ctx, request, response.Bids)
+        s.verifyAssets(ctx, request, response)
+        s.hooks.runPostAuction(ctx, response)
+        // Reserved winners are notified when the reservation is confirmed
+        if !models.IsReservation(ctx) {
+            s.recordExperimentRevenue(ctx, response)
+            s.notifyWinners(ctx, request, response)
+        }
+    case errors.Is(err, ErrNoValidBids) || errors.Is(err, ErrInsufficientCompetition):
+        s.recordExperimentAuction(ctx, arm.assignments)
+        s.recordAnalytics(ctx, request, nil)
+        s.notifyNoSale(ctx, request, err, arm.assignments, floor, seq)
+    }
+    return response, err
+}
+
+// startAuctionContext returns ctx carrying an auction context for request, started here for
+// callers that did not start one, with its reasons counted in metrics
+func (s *AuctionService) startAuctionContext(ctx context.Context, request *models.BidRequest) context.Context {
+    if models.RequestIDFromContext(ctx) == "" && request != nil {
+        ctx = models.ContextWithRequestID(ctx, request.RequestID)
+    }
+    if request != nil {
+        ctx = models.ContextWithAuction(ctx, models.AuctionFromContext(ctx).WithRequest(request))
+    }
+    models.ReasonsFromContext(ctx).Observe(countReason)
+    return ctx
+}
+
+// executeAuction collects bids and selects the winners with the parameters of the auction's experiment
+// arm, the settings of its vertical, and its floor, when one is applied
+func (s *AuctionService) executeAuction(ctx context.Context, request *models.BidRequest, onBid BidObserver, arm experimentArm, settings auctionSettings, floor *models.AuctionFloor) (*models.BidResponse, error) {
     startTime := time.Now()
 
     // Validate request
     if request == nil || request.RequestID == "" {
         return nil, ErrInvalidRequest
     }
+    if err := s.ValidateUserData(request); err != nil {
+        return nil, err
+    }
+    if err := s.ValidateConstraints(request.Constraints); err != nil {
+        return nil, err
+    }
+    models.DebugFromContext(ctx).RecordFloor(floor)
 
-    // Collect bids from partners
-    bids, err := s.collectBids(ctx, request)
+    // Collect bids from partners within the collection slice, keeping the rest of the
+    // deadline for optimization and serialization
+    budget := s.newAuctionBudget(ctx, startTime)
+    collectCtx, cancelCollect := budget.collectionContext(ctx)
+    holds := s.budgets.newHolds(ctx)
+    defer holds.release(ctx)
+    bids, summary, err := s.collectBids(collectCtx, request, onBid, holds, s.earlyStopFor(ctx, request, arm, settings, floor))
+    cancelCollect()
+    if errors.Is(err, ErrAuctionTimeout) {
+        budgetOverrunsTotal.WithLabelValues(budgetPhaseCollection).Inc()
+    }
     if err != nil {
         return nil, err
     }
+    defer s.optimizer.ReleaseBids(bids)
+    s.hooks.runPostCollection(ctx, bids)
+    optimizationStart := time.Now()
+    collectionTime := optimizationStart.Sub(startTime)
+
+    // Blend model scores into quality scores; scorer failures fall back to existing scores
+    optimizeCtx, cancelOptimize := budget.optimizationContext(ctx, optimizationStart)
+    defer cancelOptimize()
+    s.applyModelScores(optimizeCtx, request, bids)
 
     // Optimize and determine winners
-    winners, err := s.determineWinners(bids)
+    winners, unsatisfied, guidance, err := s.determineWinners(optimizeCtx, bids, request, arm, settings, floor, holds)
+    if errors.Is(optimizeCtx.Err(), context.DeadlineExceeded) {
+        budgetOverrunsTotal.WithLabelValues(budgetPhaseOptimization).Inc()
+    }
+    if err == nil || errors.Is(err, ErrNoValidBids) || errors.Is(err, ErrInsufficientCompetition) {
+        s.exportAuction(ctx, request, bids, winners, summary)
+    }
     if err != nil {
         return nil, err
     }
 
     // Create response
+    summary.Winners = len(winners)
+    summary.UnsatisfiedConstraints = unsatisfied
+    now := time.Now()
     response := &models.BidResponse{
-        RequestID:      request.RequestID,
-        Bids:          winners,
-        Timestamp:     time.Now(),
-        ProcessingTime: time.Since(startTime),
+        RequestID:        request.RequestID,
+        Bids:             winners,
+        Timestamp:        now,
+        ProcessingTime:   now.Sub(startTime),
+        CollectionTime:   collectionTime,
+        OptimizationTime: now.Sub(optimizationStart),
+        Summary:          &summary,
+        Experiments:      arm.assignments,
+        ValidUntil:       s.applyResponseTTL(settings.ResponseTTL, winners),
+        Floor:            floor,
+        Guidance:         guidance,
     }
 
+    // Attach filter and multiplier decisions when debug output was requested
+    response.Debug = models.DebugFromContext(ctx)
+    response.DryRun = models.DryRunFromContext(ctx)
+
     return response, nil
 }
 
-// collectBids collects bids from all configured RTB partners in parallel
-func (s *AuctionService) collectBids(ctx context.Context, request *models.BidRequest) ([]*models.Bid, error) {
-    s.mutex.RLock()
-    defer s.mutex.RUnlock()
+// collectBids collects bids from the eligible RTB partners, or those partner selection picks when
+// auctions are capped, in parallel on the partner workers, with a summary of which partners were
+// contacted and answered. Partners with a daily budget are only called once holds covers their
+// largest possible charge. When onBid is set, each validated
+// bid is also emitted the moment it arrives. With stop set, calls still pending are cancelled
+// once a bid in hand is certain to win. The returned slice comes from the optimizer's pool
+// and is released by executeAuction.
+func (s *AuctionService) collectBids(ctx context.Context, request *models.BidRequest, onBid BidObserver, holds *budgetHolds, stop *earlyStop) ([]*models.Bid, models.AuctionSummary, error) {
+    partners := s.partnerSnapshot()
+    round := newAuctionRound(ctx, request, onBid, s.optimizer.AcquireBids())
+    round.allowEarlyStop(stop)
+    defer round.endCalls()
+    reasons := round.auction.Reasons()
+    missingUserData := s.partnersMissingUserData(request)
+    negatives := s.lookupNoBids(ctx, request, partners)
+    round.segment = negatives.segment
+    round.override = s.overrideFor(ctx)
 
-    var wg sync.WaitGroup
-    bidChan := make(chan *models.Bid, len(s.config.Partners))
-    errChan := make(chan error, len(s.config.Partners))
+    // Find the partners eligible for the auction. Canary auctions only see partners opted in to them.
+    canary := models.IsCanary(ctx)
+    eligible := make([]string, 0, len(partners))
+    var otherRegions []string // eligible partners preferring other service regions
+    for partnerID, partner := range partners {
+        if !partner.Enabled || !s.breakers.Allow(partnerID) || (canary && !partner.Canary) {
+            continue
+        }
 
-    // Launch bid collection for each partner
-    for partnerID, partner := range s.config.Partners {
-        if !partner.Enabled {
+        // Skip partners being offboarded, whose won bids still settle until their drain deadline
+        if s.partnerDraining(partnerID) {
+            reasons.Skip(partnerID, models.ReasonDraining)
             continue
         }
 
-        wg.Add(1)
-        go func(pID string, p *config.PartnerConfig) {
-            defer wg.Done()
-            
-            // Create partner-specific timeout context
-            partnerCtx, cancel := context.WithTimeout(ctx, p.Timeout)
-            defer cancel()
+        // Skip partners an ops override has disabled
+        if round.override.disables(partnerID) {
+            reasons.Skip(partnerID, models.ReasonOverrideDisabled)
+            continue
+        }
 
-            bid, err := s.collectPartnerBid(partnerCtx, pID, p, request)
-            if err != nil {
-                s.recordPartnerFailure(pID)
-                errChan <- err
-                return
-            }
+        // Skip partners outside their active hours
+        if !s.PartnerInSchedule(partnerID) {
+            reasons.Skip(partnerID, models.ReasonOffSchedule)
+            continue
+        }
 
-            if bid != nil {
-                bidChan <- bid
-            }
-        }(partnerID, partner)
+        // Skip partners backing off after rate limiting us
+        if s.backoffs.Active(partnerID) {
+            reasons.Skip(partnerID, models.ReasonPartnerBackoff)
+            continue
+        }
+
+        // Skip partners requiring consent the consumer has not given
+        if !partnerConsentAllowed(partner, request) {
+            reasons.Skip(partnerID, models.ReasonConsentMissing)
+            continue
+        }
+
+        // Skip partners whose region filter excludes the consumer
+        if !partnerRegionAllowed(partner, request) {
+            reasons.Skip(partnerID, models.ReasonGeoExcluded)
+            continue
+        }
+
+        // Skip partners whose extra UserData fields the request lacks
+        if missingUserData[partnerID] {
+            reasons.Skip(partnerID, models.ReasonUserDataMissing)
+            continue
+        }
+
+        // Skip revenue-share partners when the vertical has no expected premium to price their bids
+        if !partnerPricingAllowed(s.config, partner, request) {
+            reasons.Skip(partnerID, models.ReasonPremiumUnknown)
+            continue
+        }
+
+        // Skip partners that recently sent an explicit no-bid for this lead segment
+        if negatives.skipsPartner(partnerID) {
+            reasons.Skip(partnerID, models.ReasonNegativeCached)
+            continue
+        }
+
+        if !partner.PrefersRegion(s.config.ServiceRegion) {
+            otherRegions = append(otherRegions, partnerID)
+            continue
+        }
+        eligible = append(eligible, partnerID)
     }
 
-    // Wait for all bid collections with timeout
-    done := make(chan struct{})
-    go func() {
-        wg.Wait()
-        close(done)
-    }()
+    // Partners preferring other service regions only take this region's traffic when no partner
+    // preferring it is eligible
+    if len(eligible) == 0 && len(otherRegions) > 0 {
+        eligible = otherRegions
+        regionFallbacksTotal.WithLabelValues(request.Vertical).Inc()
+    } else {
+        for _, partnerID := range otherRegions {
+            reasons.Skip(partnerID, models.ReasonRegionMismatch)
+        }
+    }
 
+    // Contact only the eligible partners partner selection picks when auctions are capped
+    pool, _ := s.config.VerticalPool(request.Vertical)
+    for _, partnerID := range s.choosePartners(ctx, request, eligible, reasons) {
+        // A vertical may only use its share of a partner's QPS, so its surges leave the rest to others
+        if limiter, exists := s.verticalLimiters[verticalPartnerKey{pool: pool, partnerID: partnerID}]; exists && !limiter.Allow(false) {
+            reasons.Skip(partnerID, models.ReasonVerticalQPSCapped)
+            continue
+        }
+        // Enforce partner QPS caps; batch items cannot consume the live reserve
+        if limiter, exists := s.partnerLimiters[partnerID]; exists && !limiter.Allow(models.IsBatchItem(ctx)) {
+            reasons.Skip(partnerID, models.ReasonQPSCapped)
+            continue
+        }
+        // Hold the most the auction could charge the partner against its daily budget
+        if reason, allowed := holds.reserve(ctx, partnerID, partners[partnerID]); !allowed {
+            reasons.Skip(partnerID, reason)
+            continue
+        }
+
+        round.pending.Add(1)
+        round.expectCall(partnerID, partners[partnerID])
+        if !s.workers.submit(ctx, partnerJob{round: round, partnerID: partnerID, partner: partners[partnerID]}) {
+            reasons.CallError(partnerID, models.ReasonCallCancelled)
+            round.settleCall(partnerID)
+            round.finish()
+            continue
+        }
+        round.recordContact()
+    }
+    round.armEarlyStop(holds)
+    round.finish()
+
+    // Wait for all bid collections with timeout. Partner calls run under ctx, so on a timeout
+    // they are already cancelled and are given a short grace to record why they failed; calls
+    // still running after it keep adding to the abandoned round rather than the pool.
     select {
     case <-ctx.Done():
-        return nil, ErrAuctionTimeout
-    case <-done:
-        close(bidChan)
-        close(errChan)
+        if round.drain(collectionDrainGrace) {
+            s.optimizer.ReleaseBids(round.bids)
+        } else {
+            collectionDrainTimeoutsTotal.Inc()
+        }
+        return nil, models.AuctionSummary{}, ErrAuctionTimeout
+    case <-round.done:
     }
 
-    // Collect and validate bids
-    var validBids []*models.Bid
-    for bid := range bidChan {
-        if err := models.ValidateBid(bid); err == nil {
-            validBids = append(validBids, bid)
-        }
+    // Deterministic auctions rank bids in partner order rather than arrival order
+    validBids := round.bids
+    if s.config.DeterministicMode {
+        sort.SliceStable(validBids, func(i, j int) bool {
+            return validBids[i].PartnerID < validBids[j].PartnerID
+        })
     }
 
     if len(validBids) == 0 {
-        return nil, ErrNoValidBids
+        s.optimizer.ReleaseBids(validBids)
+        return nil, round.summary, ErrNoValidBids
     }
 
-    return validBids, nil
+    return validBids, round.summary, nil
 }
 
-// determineWinners selects winning bids based on price and quality score
-func (s *AuctionService) determineWinners(bids []*models.Bid) ([]*models.Bid, error) {
+// callPartner collects one partner's bids for an auction round on a partner worker
+func (s *AuctionService) callPartner(job partnerJob) {
+    round, pID, p := job.round, job.partnerID, job.partner
+    defer round.finish()
+    defer round.settleCall(pID)
+
+    // A call in the treatment of the partner's payload experiment sends the variant payload
+    p, payloadExperiment := s.payloadVariantFor(pID, p, round.request, s.clock.Now())
+
+    // Create partner-specific timeout context
+    partnerCtx, cancel := context.WithTimeout(round.calls, p.Timeout)
+    defer cancel()
+
+    started := time.Now()
+    bids, err := s.collectPartnerBid(partnerCtx, pID, p, round.request)
+    call := PartnerCall{Latency: time.Since(started), TimedOut: errors.Is(partnerCtx.Err(), context.DeadlineExceeded), Bids: len(bids), Vertical: round.request.Vertical, PayloadExperiment: payloadExperiment}
+
+    // Synthetic auctions leave the partner stats, breakers, and backoffs to live traffic
+    health := s.partnerHealth(round.ctx)
+
+    // A partner declining to bid answered as expected, so it is kept out of the failure counts
+    if noBid := noBidFrom(err); noBid != nil {
+        call.NoBid = true
+        s.recordPartnerCall(round.ctx, pID, round.request.Vertical, call)
+        health.recordSuccess(pID)
+        health.recordNoBid(pID, noBid)
+        round.auction.Reasons().NoBid(pID, noBid.metricReason(), noBid.Description())
+        s.cacheNoBid(round.ctx, pID, round.segment)
+        round.recordCall(call.TimedOut, 0, 0)
+        return
+    }
+    // A call cancelled because the winner was decided without it says nothing about the partner
+    if err != nil && round.stoppedEarly(partnerCtx) {
+        round.auction.Reasons().Skip(pID, models.ReasonEarlyTerminated)
+        return
+    }
+    if err != nil {
+        round.recordCall(call.TimedOut, 0, 0)
+        round.auction.Reasons().CallError(pID, callErrorReason(round.calls, call.TimedOut))
+        s.recordPartnerCall(round.ctx, pID, round.request.Vertical, call)
+        // A partner rate limiting us is backed off rather than counted as failing, and a lead
+        // lacking a field the partner's URL needs is no fault of the partner
+        var limited *RateLimitedError
+        if errors.As(err, &limited) {
+            health.recordRateLimit(pID, limited)
+        } else if !errors.Is(err, ErrEmptyTemplateField) {
+            health.recordFailure(pID)
+        }
+        var schemaErr *SchemaError
+        if errors.As(err, &schemaErr) {
+            health.recordSchemaError(pID, *schemaErr)
+        }
+        round.auction.Debug().RecordError(pID, err)
+        return
+    }
+    health.recordSuccess(pID)
+
+    round.auction.Debug().RecordBids(pID, len(bids))
+    checked, invalidExts := s.checkVerticalExts(round.request.Vertical, pID, bids, round.auction.Debug())
+    valid, invalid := capPartnerBids(pID, p, checked, round.auction.Reasons())
+    invalid += invalidExts
+    valid = s.applyBidRules(round, pID, valid)
+    valid = round.override.applyFloor(pID, valid, round.auction.Reasons())
+    if s.config.EarlyTerminationEnabled() {
+        valid = applyMaxBid(pID, p, s.config.MaxBidPrice, valid, round.auction.Reasons())
+    }
+    call.InvalidBids = invalid
+    call.QualityScores = bidQualityScores(valid)
+    if payloadExperiment != nil {
+        call.Prices = bidPrices(valid)
+    }
+    // The variant tag is the service's to set, whatever the partner's response carried
+    for _, bid := range valid {
+        bid.PayloadExperiment = payloadExperiment
+    }
+    round.recordCall(call.TimedOut, len(bids), len(bids)-invalid)
+    s.recordPartnerCall(round.ctx, pID, round.request.Vertical, call)
+    valid = s.shadowBids(pID, valid, round.auction.Reasons())
+    if len(valid) > 0 {
+        if round.onBid != nil {
+            for _, bid := range valid {
+                round.onBid(bid)
+            }
+        }
+        round.addBids(valid)
+    }
+}
+
+// callErrorReason classifies a failed partner call: cancelled when bid collection ended first,
+// otherwise timed out or failed
+func callErrorReason(calls context.Context, timedOut bool) models.Reason {
+    switch {
+    case calls.Err() != nil:
+        return models.ReasonCallCancelled
+    case timedOut:
+        return models.ReasonCallTimeout
+    default:
+        return models.ReasonCallFailed
+    }
+}
+
+// capPartnerBids validates each bid from a partner response independently and keeps the first
+// valid bids up to the partner's per-response cap, in the order the partner returned them.
+// It also returns how many bids failed validation.
+func capPartnerBids(partnerID string, partner *config.PartnerConfig, bids []*models.Bid, reasons *models.ReasonCollector) ([]*models.Bid, int) {
+    limit := partner.BidsPerResponse()
+    // The call owns its bid slice, so the kept bids are filtered into it in place
+    valid := bids[:0]
+    invalid := 0
+    for _, bid := range bids {
+        if models.ValidateBid(bid) != nil {
+            invalid++
+            continue
+        }
+        if len(valid) >= limit {
+            reasons.Loss(partnerID, bid.ID, models.ReasonResponseCap)
+            continue
+        }
+        valid = append(valid, bid)
+    }
+    return valid, invalid
+}
+
+// countReason counts an auction's partner skips, bid losses, and call errors in metrics
+func countReason(kind models.ReasonKind, partnerID string, reason models.Reason) {
+    switch kind {
+    case models.ReasonKindSkip:
+        partnerSkipsTotal.WithLabelValues(partnerID, string(reason)).Inc()
+    case models.ReasonKindLoss:
+        bidLossesTotal.WithLabelValues(partnerID, string(reason)).Inc()
+    case models.ReasonKindCallError:
+        partnerCallErrorsTotal.WithLabelValues(partnerID, string(reason)).Inc()
+    }
+}
+
+// determineWinners selects winning bids using the optimization strategy for the request vertical,
+// or the strategy, quality weight, and floor of the experiment treatments the auction is in. Open
+// auction bids must also meet the auction's floor, when one is applied, and the vertical's settings
+// decide how many bids win and whether a partner may win more than once. The guidance of partners
+// sharing it is returned with the winners.
+func (s *AuctionService) determineWinners(ctx context.Context, bids []*models.Bid, request *models.BidRequest, arm experimentArm, settings auctionSettings, floor *models.AuctionFloor, holds *budgetHolds) ([]*models.Bid, []models.RankingConstraint, []models.BidGuidance, error) {
     if len(bids) == 0 {
-        return nil, ErrNoValidBids
+        return nil, nil, nil, ErrNoValidBids
     }
 
-    // Optimize bids using the bid optimizer
-    optimizedBids, err := s.optimizer.OptimizeBidSet(bids)
-    if err != nil {
-        return nil, err
+    // Check deal bids against their deal terms and price them at the deal price
+    bids = holds.filter(ctx, applyAdaptiveFloor(ctx, arm.applyFloor(ctx, s.applyDeals(ctx, bids, request)), floor))
+    if len(bids) == 0 {
+        return nil, nil, nil, ErrNoValidBids
+    }
+
+    // Leads in quorum verticals are only sold when enough distinct partners compete
+    if minBidders := s.config.MinBiddersFor(request.Vertical); minBidders > 1 && distinctPartners(bids) < minBidders {
+        quorumFailuresTotal.WithLabelValues(request.Vertical).Inc()
+        return nil, nil, nil, ErrInsufficientCompetition
     }
 
-    // Apply partner diversity rules and select top N bids
-    maxWinners := s.config.MaxBidsPerRequest
+    // Optimize bids using the bid optimizer, or rank by price once the optimization slice is spent
+    var optimizedBids []*models.Bid
+    var optimizer *utils.BidOptimizer
+    if ctx.Err() != nil {
+        optimizedBids = utils.RankByPrice(bids)
+    } else {
+        var err error
+        optimizer = arm.optimizerOr(settings.optimizer)
+        if optimizedBids, err = optimizer.OptimizeAuction(models.AuctionFromContext(ctx), bids, request); err != nil {
+            return nil, nil, nil, err
+        }
+    }
+
+    // Guaranteed deal bids claim winner slots ahead of the open auction
+    optimizedBids = prioritizeGuaranteedDeals(optimizedBids, s.config.Deals)
+
+    // Drop lower-ranked copies of demand resold by several partners
+    optimizedBids = dedupBids(optimizedBids, s.config.Dedup.KeysFor(request.Vertical), models.ReasonsFromContext(ctx))
+
+    // Apply the vertical's diversity rule and select its top N bids
+    maxWinners := settings.MaxBids
     if maxWinners > len(optimizedBids) {
         maxWinners = len(optimizedBids)
     }
 
     winners := make([]*models.Bid, 0, maxWinners)
-    seenPartners := make(map[string]bool)
-
     for _, bid := range optimizedBids {
         if len(winners) >= maxWinners {
             break
         }
 
-        // Ensure partner diversity
-        if !seenPartners[bid.PartnerID] {
-            winners = append(winners, bid)
-            seenPartners[bid.PartnerID] = true
+        // Ensure partner diversity; a partner's lower-ranked seats lose to its best bid
+        if settings.partnerDiverse() && hasPartner(winners, bid.PartnerID) {
+            models.ReasonsFromContext(ctx).Loss(bid.PartnerID, bid.ID, models.ReasonPartnerCap)
+            continue
         }
+        winners = append(winners, bid)
+    }
+
+    // Swap in bids the request's ranking constraints call for
+    var unsatisfied []models.RankingConstraint
+    if len(request.Constraints) > 0 {
+        winners, unsatisfied = s.applyConstraints(ctx, request, optimizedBids, winners, maxWinners, settings.partnerDiverse())
+    }
+
+    // Bid guidance inverts the ranking, so it too compares full-precision prices
+    var guidance []models.BidGuidance
+    if optimizer != nil {
+        guidance = s.bidGuidance(optimizer, request, optimizedBids, winners)
     }
 
-    return winners, nil
+    // Round what winners pay only now, so ranking above compared full-precision prices
+    s.roundClearingPrices(winners)
+
+    // Charge winners to their partners' daily budgets. An auction whose every winner was refused its
+    // charge sold nothing.
+    committed := holds.commit(ctx, winners)
+    if len(winners) > 0 && len(committed) == 0 {
+        return nil, nil, nil, ErrNoValidBids
+    }
+    winners = committed
+
+    if !models.IsReservation(ctx) {
+        s.recordWins(ctx, request, winners)
+    }
+    return winners, unsatisfied, committedGuidance(guidance, winners), nil
+}
+
+// recordWins counts winning bids against their deals and partners
+func (s *AuctionService) recordWins(ctx context.Context, request *models.BidRequest, winners []*models.Bid) {
+    recordDealWins(winners)
+    for _, bid := range winners {
+        s.recordPartnerWin(ctx, bid.PartnerID, request.Vertical, bid.CPL())
+    }
+}
+
+// hasPartner reports whether any of bids is from partnerID. Winner sets are small enough that
+// a scan is cheaper than a map.
+func hasPartner(bids []*models.Bid, partnerID string) bool {
+    for _, bid := range bids {
+        if bid.PartnerID == partnerID {
+            return true
+        }
+    }
+    return false
+}
+
+// distinctPartners counts the partners with at least one bid
+func distinctPartners(bids []*models.Bid) int {
+    partners := make(map[string]bool, len(bids))
+    for _, bid := range bids {
+        partners[bid.PartnerID] = true
+    }
+    return len(partners)
+}
+
+// partnerSnapshot returns the configured partners. The map is replaced rather than modified,
+// so auctions read it without locking.
+func (s *AuctionService) partnerSnapshot() map[string]*config.PartnerConfig {
+    partners, _ := s.partners.Load().(map[string]*config.PartnerConfig)
+    return partners
 }
 
 // recordPartnerFailure tracks partner failures for monitoring
 func (s *AuctionService) recordPartnerFailure(partnerID string) {
-    s.mutex.Lock()
-    defer s.mutex.Unlock()
-    s.partnerFailures[partnerID]++
+    s.stats.RecordFailure(partnerID)
+}
+
+// recordRateLimit backs a partner off after it rate limited us
+func (s *AuctionService) recordRateLimit(partnerID string, limited *RateLimitedError) {
+    source := s.backoffs.RecordRateLimit(partnerID, limited)
+    partnerRateLimitsTotal.WithLabelValues(partnerID, source).Inc()
+}
+
+// partnerHealth records the outcome of a partner call in the partner stats, circuit breakers,
+// and rate limit backoffs. Synthetic auctions, such as canaries, record nothing, so they can
+// neither trip a partner's breaker nor show up in its stats.
+type partnerHealth struct {
+    service   *AuctionService
+    synthetic bool
+}
+
+// partnerHealth returns the partner health recorder for an auction
+func (s *AuctionService) partnerHealth(ctx context.Context) partnerHealth {
+    return partnerHealth{service: s, synthetic: models.IsSynthetic(ctx)}
+}
+
+// recordSuccess closes the partner's breaker and ends its backoff after it answered
+func (h partnerHealth) recordSuccess(partnerID string) {
+    if h.synthetic {
+        return
+    }
+    h.service.breakers.RecordSuccess(partnerID)
+    h.service.backoffs.RecordSuccess(partnerID)
+}
+
+// recordFailure counts a failed call against the partner's breaker and stats
+func (h partnerHealth) recordFailure(partnerID string) {
+    if h.synthetic {
+        return
+    }
+    h.service.breakers.RecordFailure(partnerID)
+    h.service.recordPartnerFailure(partnerID)
+}
+
+// recordNoBid counts a partner's decline
+func (h partnerHealth) recordNoBid(partnerID string, noBid *NoBidResponse) {
+    if !h.synthetic {
+        h.service.recordNoBid(partnerID, noBid)
+    }
+}
+
+// recordRateLimit backs the partner off
+func (h partnerHealth) recordRateLimit(partnerID string, limited *RateLimitedError) {
+    if !h.synthetic {
+        h.service.recordRateLimit(partnerID, limited)
+    }
+}
+
+// recordSchemaError counts a rejected response
+func (h partnerHealth) recordSchemaError(partnerID string, schemaErr SchemaError) {
+    if !h.synthetic {
+        h.service.recordSchemaError(partnerID, schemaErr)
+    }
 }
 
 // GetPartnerStats returns partner performance statistics
 func (s *AuctionService) GetPartnerStats() map[string]int {
-    s.mutex.RLock()
-    defer s.mutex.RUnlock()
-    
-    stats := make(map[string]int)
-    for partner, failures := range s.partnerFailures {
-        stats[partner] = failures
-    }
-    return stats
-}
-
-// collectPartnerBid collects a bid from a single partner
-func (s *AuctionService) collectPartnerBid(ctx context.Context, partnerID string, 
-    partner *config.PartnerConfig, request *models.BidRequest) (*models.Bid, error) {
-    
-    // Implementation would include HTTP client call to partner endpoint
-    // Omitted for brevity as it depends on external HTTP client implementation
-    
-    return nil, nil
-}
\ No newline at end of file
+    return s.stats.Snapshot()
+}
+
+// AvailablePartners returns the IDs of enabled partners that are not draining and whose circuit
+// breaker is not open
+func (s *AuctionService) AvailablePartners() []string {
+    partners := s.partnerSnapshot()
+    available := make([]string, 0, len(partners))
+    for partnerID, partner := range partners {
+        if partner.Enabled && !s.partnerDraining(partnerID) && s.breakers.State(partnerID) != BreakerOpen {
+            available = append(available, partnerID)
+        }
+    }
+    return available
+}
+
+// PartnerStatus summarizes a partner's eligibility for auctions
+type PartnerStatus struct {
+    ID           string       `json:"id"`
+    Enabled      bool         `json:"enabled"`
+    BreakerState BreakerState `json:"breaker_state"`
+    InSchedule   bool         `json:"in_schedule"`
+    Failures     int          `json:"failures"`
+    NoBids       int          `json:"no_bids"`
+    SchemaErrors int          `json:"schema_errors"`
+    // RecentSchemaErrors are the partner's latest rejected responses, oldest first
+    RecentSchemaErrors []SchemaError `json:"recent_schema_errors,omitempty"`
+    // BackoffUntil is when the partner's rate limit backoff ends, while it has not answered since
+    // its last rate limit; RateLimits counts its consecutive rate limits
+    BackoffUntil *time.Time       `json:"backoff_until,omitempty"`
+    RateLimits   int              `json:"rate_limits"`
+    Endpoints    []EndpointStatus `json:"endpoints"`
+    // Draining partners get no auctions; their won bids settle until DrainDeadline
+    Draining      bool            `json:"draining,omitempty"`
+    DrainDeadline *time.Time      `json:"drain_deadline,omitempty"`
+    // Shadowed partners are called, but their bids never compete until an admin restores them
+    Shadowed      bool            `json:"shadowed,omitempty"`
+    // Fields is the partner's outbound fields policy, when it has one
+    Fields        *config.FieldsPolicy `json:"fields,omitempty"`
+    // Assets is the partner's creative image verification record, once its images are checked
+    Assets        *AssetStatus    `json:"assets,omitempty"`
+}
+
+// PartnerStatuses returns the status of every configured partner, ordered by ID
+func (s *AuctionService) PartnerStatuses() []PartnerStatus {
+    partners := s.partnerSnapshot()
+    statuses := make([]PartnerStatus, 0, len(partners))
+    for partnerID, partner := range partners {
+        schemaErrors, recent := s.stats.SchemaErrors(partnerID)
+        backoffUntil, rateLimits := s.backoffs.Status(partnerID)
+        status := PartnerStatus{
+            ID:                 partnerID,
+            Enabled:            partner.Enabled,
+            BreakerState:       s.breakers.State(partnerID),
+            InSchedule:         s.PartnerInSchedule(partnerID),
+            Failures:           s.stats.Failures(partnerID),
+            NoBids:             s.stats.NoBids(partnerID),
+            SchemaErrors:       schemaErrors,
+            RecentSchemaErrors: recent,
+            RateLimits:         rateLimits,
+            Endpoints:          s.endpoints.Statuses(partnerID, partner.EndpointList()),
+            Fields:             partner.Fields,
+            Assets:             s.assetStatus(partnerID),
+            Shadowed:           s.activity.shadowed(partnerID),
+        }
+        if deadline, draining := s.drains.deadline(partnerID); draining {
+            status.Draining = true
+            status.DrainDeadline = &deadline
+        }
+        if rateLimits > 0 {
+            status.BackoffUntil = &backoffUntil
+        }
+        statuses = append(statuses, status)
+    }
+    sort.Slice(statuses, func(i, j int) bool {
+        return statuses[i].ID < statuses[j].ID
+    })
+    return statuses
+}
+
+// PartnerBreakerState returns the circuit breaker state for a partner
+func (s *AuctionService) PartnerBreakerState(partnerID string) BreakerState {
+    return s.breakers.State(partnerID)
+}
+
+// collectPartnerBid collects the bids from a single partner, retrying transient failures per the
+// partner's retry policy while the partner timeout allows. Partners with a sandbox mirror also
+// get a copy of the request there, once per auction.
+func (s *AuctionService) collectPartnerBid(ctx context.Context, partnerID string,
+    partner *config.PartnerConfig, request *models.BidRequest) ([]*models.Bid, error) {
+
+    s.mirrorPartnerRequest(ctx, partnerID, partner, request)
+    random := s.randFor(request, randomPartner+partnerID)
+    for attempt := 1; ; attempt++ {
+        bids, condition, err := s.attemptPartnerEndpoints(ctx, random, partnerID, partner, request)
+        // A no-bid is an answer, so it ends the call like a bid does
+        if err == nil || errors.Is(err, ErrNoBid) {
+            if attempt > 1 {
+                partnerRetrySuccessesTotal.WithLabelValues(partnerID).Inc()
+            }
+            return bids, err
+        }
+
+        if attempt >= partner.Retry.Attempts() || !partner.Retry.RetriesOn(condition) {
+            return nil, err
+        }
+        // A rate-limited partner is not retried sooner than it asked, which usually means not
+        // within this auction
+        backoff := retryBackoff(random, partner.Retry.BackoffBase, attempt)
+        var limited *RateLimitedError
+        if errors.As(err, &limited) && limited.RetryAfter > backoff {
+            backoff = limited.RetryAfter
+        }
+        if !waitForRetry(ctx, backoff) {
+            return nil, err
+        }
+    }
+}
+
+// attemptPartnerEndpoints makes one attempt against a partner, starting with an endpoint picked
+// by weight and failing over to the next endpoint when a call fails before reaching the partner
+func (s *AuctionService) attemptPartnerEndpoints(ctx context.Context, random *rand.Rand, partnerID string,
+    partner *config.PartnerConfig, request *models.BidRequest) ([]*models.Bid, string, error) {
+
+    var (
+        bids      []*models.Bid
+        condition string
+        err       error
+    )
+    for _, endpoint := range s.endpoints.Order(random, partnerID, partner.EndpointList()) {
+        metricsFor(partnerID).attempts.Inc()
+        start := time.Now()
+        faults := s.partnerFaultsFor(ctx, random, partnerID)
+        bids, condition, err = s.attemptPartnerBid(ctx, partnerID, partner, endpoint.URL, request, faults)
+        s.endpoints.Record(partnerID, endpoint.URL, endpointHealthy(ctx, err, condition), time.Since(start))
+
+        if err == nil || !isFastFailure(err) || ctx.Err() != nil {
+            break
+        }
+    }
+    return bids, condition, err
+}
+
+// attemptPartnerBid makes a single bid call to one partner endpoint, with any injected faults
+// delaying it or standing in for the partner's response. On failure it also returns the retry
+// condition the failure matches, or empty when the failure must not be retried.
+func (s *AuctionService) attemptPartnerBid(ctx context.Context, partnerID string,
+    partner *config.PartnerConfig, endpoint string, request *models.BidRequest, faults partnerFaults) ([]*models.Bid, string, error) {
+
+    // Adapters address the partner's Endpoint, so point a copy at any other chosen endpoint
+    target := partner
+    if endpoint != partner.Endpoint {
+        copied := *partner
+        copied.Endpoint = endpoint
+        target = &copied
+    }
+
+    adapter := s.adapterFor(partnerID, partner)
+    httpReq, err := buildPartnerRequest(models.AuctionFromContext(ctx), adapter, s.outboundRequest(partnerID, partner, request), target)
+    if err != nil {
+        return nil, "", fmt.Errorf("%w: building request for %s: %w", ErrPartnerFailure, partnerID, err)
+    }
+    traceCtx, trace := withPartnerTrace(ctx, partnerID)
+    defer trace.release()
+    httpReq = httpReq.WithContext(traceCtx)
+    if partner.Gzip {
+        httpReq.Header.Set("Accept-Encoding", "gzip")
+    }
+    if s.config.ServiceRegion != "" {
+        httpReq.Header.Set(RegionHeader, s.config.ServiceRegion)
+    }
+
+    if err := faults.delay(ctx); err != nil {
+        return nil, transportRetryCondition(ctx), fmt.Errorf("%w: calling %s: %w", ErrPartnerFailure, partnerID, err)
+    }
+    var (
+        status    int
+        body      []byte
+        condition string
+    )
+    if faults.replaces() {
+        status, body, condition, err = faults.response(ctx, partnerID)
+    } else {
+        capture := s.captureCall(ctx, partnerID, partner, request, httpReq)
+        status, body, condition, err = s.partnerResponse(ctx, partnerID, partner.ResponseLimit(), httpReq, capture)
+    }
+    if err != nil {
+        return nil, condition, err
+    }
+
+    bids, err := adapter.ParseResponse(body, status)
+    if errors.Is(err, ErrNoBid) {
+        return nil, "", err
+    }
+    if err != nil {
+        return nil, statusRetryCondition(err), fmt.Errorf("%w: %s: %w", ErrPartnerFailure, partnerID, err)
+    }
+    replay := models.ReplayFromContext(ctx)
+    for _, bid := range bids {
+        bid.PartnerID = partnerID
+        normalizeBid(s.config, partner, request, bid)
+        // Replayed bids expire as long after now as they did after the recording
+        if replay != nil && !bid.ExpiresAt.IsZero() {
+            bid.ExpiresAt = bid.ExpiresAt.Add(time.Since(replay.RecordedAt()))
+        }
+    }
+
+    return bids, "", nil
+}
@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/yourdomain/rtb-service/src/audit"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// auditAuction is the audit hook. Reserved winners are audited when the reservation is confirmed.
+func (s *AuctionService) auditAuction(ctx context.Context, response *models.BidResponse) {
+	if models.IsReservation(ctx) {
+		return
+	}
+	s.auditWinners(ctx, models.AuctionFromContext(ctx).Request(), response)
+}
+
+// auditWinners queues an audit record for each winning bid. BidPrice is in the partner's pricing
+// model; the clearing price is a cost per lead.
+func (s *AuctionService) auditWinners(ctx context.Context, request *models.BidRequest, response *models.BidResponse) {
+	var seq models.AuctionSeq
+	if response.AuctionSeq != nil {
+		seq = *response.AuctionSeq
+	}
+	for _, bid := range response.Bids {
+		bidPrice := bid.Price
+		if bid.BidPrice > 0 {
+			bidPrice = bid.BidPrice
+		}
+		s.writeAudit(ctx, audit.Record{
+			Timestamp:     response.Timestamp.UTC(),
+			RequestID:     request.RequestID,
+			LeadID:        request.LeadID,
+			PartnerID:     bid.PartnerID,
+			BidID:         bid.ID,
+			DealID:        bid.DealID,
+			Vertical:      request.Vertical,
+			BidPrice:      bidPrice,
+			PricingModel:  bid.PricingModel,
+			ClearingPrice: bid.CPL(),
+			AuctionSeq:    seq.Seq,
+			AuctionULID:   seq.ULID,
+		})
+	}
+}
+
+// Close stops the reservation sweeper and idle partner workers, closes idle partner connections,
+// releases unused auction sequence numbers, writes any queued audit records, recordings, and
+// auction exports, flushes pending webhooks, and closes their files
+func (s *AuctionService) Close() error {
+	s.workers.Close()
+	s.clients.closeIdle()
+	s.mirrors.Close()
+	s.assets.Close()
+	s.releaseAuctionSeqs()
+	return errors.Join(s.reservations.Close(), s.breakerState.Close(), s.activity.Close(), s.audit.Close(), s.webhooks.Close(),
+		s.recorder.Close(), s.exporter.Close())
+}
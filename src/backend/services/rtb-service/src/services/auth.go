@@ -0,0 +1,159 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Default HMAC signing headers
+const (
+	defaultSignatureHeader = "X-Signature"
+	defaultTimestampHeader = "X-Timestamp"
+)
+
+// PartnerAuthenticator applies a partner's authentication scheme to outbound requests.
+// Secrets are resolved once at construction.
+type PartnerAuthenticator struct {
+	scheme          string
+	headerName      string
+	token           string
+	bearer          string
+	username        string
+	password        string
+	signingKey      []byte
+	newHash         func() hash.Hash
+	signatureHeader string
+	timestampHeader string
+	clock           utils.Clock
+}
+
+// NewPartnerAuthenticator resolves a partner's auth secrets; partners without an Auth section use a bearer APIKey
+func NewPartnerAuthenticator(partner *config.PartnerConfig, clock utils.Clock) (*PartnerAuthenticator, error) {
+	auth := partner.Auth
+	if auth == nil {
+		auth = &config.PartnerAuth{Type: config.AuthBearer}
+	}
+
+	a := &PartnerAuthenticator{
+		scheme:          auth.Type,
+		headerName:      auth.HeaderName,
+		username:        auth.Username,
+		signatureHeader: auth.SignatureHeader,
+		timestampHeader: auth.TimestampHeader,
+		clock:           clock,
+	}
+
+	var err error
+	switch auth.Type {
+	case config.AuthBearer, config.AuthHeader:
+		tokenRef := auth.Token
+		if tokenRef == "" {
+			tokenRef = partner.APIKey
+		}
+		a.token, err = config.ResolveSecret(tokenRef)
+		a.bearer = "Bearer " + a.token
+	case config.AuthBasic:
+		a.password, err = config.ResolveSecret(auth.Password)
+	case config.AuthHMAC:
+		var key string
+		key, err = config.ResolveSecret(auth.SigningKey)
+		a.signingKey = []byte(key)
+		a.newHash = sha256.New
+		if auth.Algorithm == config.HMACSHA512 {
+			a.newHash = sha512.New
+		}
+		if a.signatureHeader == "" {
+			a.signatureHeader = defaultSignatureHeader
+		}
+		if a.timestampHeader == "" {
+			a.timestampHeader = defaultTimestampHeader
+		}
+	default:
+		return nil, fmt.Errorf("unknown auth type %q", auth.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("resolving %s auth secret: %w", auth.Type, err)
+	}
+	return a, nil
+}
+
+// Apply sets authentication headers on a request whose body is body
+func (a *PartnerAuthenticator) Apply(httpReq *http.Request, body []byte) {
+	switch a.scheme {
+	case config.AuthBearer:
+		httpReq.Header.Set("Authorization", a.bearer)
+	case config.AuthHeader:
+		httpReq.Header.Set(a.headerName, a.token)
+	case config.AuthBasic:
+		httpReq.SetBasicAuth(a.username, a.password)
+	case config.AuthHMAC:
+		timestamp := strconv.FormatInt(a.clock.Now().Unix(), 10)
+		httpReq.Header.Set(a.timestampHeader, timestamp)
+		httpReq.Header.Set(a.signatureHeader, a.Sign(timestamp, body))
+	}
+}
+
+// redactions returns the headers carrying the partner's credentials besides Authorization, and
+// the resolved secrets themselves, so captures can leave both out
+func (a *PartnerAuthenticator) redactions() (headers []string, secrets []string) {
+	switch a.scheme {
+	case config.AuthHeader:
+		headers = append(headers, a.headerName)
+	case config.AuthHMAC:
+		headers = append(headers, a.signatureHeader)
+	}
+	for _, secret := range []string{a.token, a.password, string(a.signingKey)} {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	return headers, secrets
+}
+
+// Sign returns the hex HMAC of the timestamp and body joined by a period
+func (a *PartnerAuthenticator) Sign(timestamp string, body []byte) string {
+	mac := hmac.New(a.newHash, a.signingKey)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// authenticatedAdapter applies partner authentication to every request its adapter builds
+type authenticatedAdapter struct {
+	PartnerAdapter
+	auth *PartnerAuthenticator
+}
+
+// BuildRequest builds the request with the wrapped adapter and authenticates it over the final body
+func (a *authenticatedAdapter) BuildRequest(request *models.BidRequest, partner *config.PartnerConfig) (*http.Request, error) {
+	httpReq, err := a.PartnerAdapter.BuildRequest(request, partner)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only signatures cover the body, so other schemes skip copying it
+	var body []byte
+	if a.auth.scheme == config.AuthHMAC && httpReq.GetBody != nil {
+		reader, err := httpReq.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		if body, err = io.ReadAll(reader); err != nil {
+			return nil, err
+		}
+	}
+	a.auth.Apply(httpReq, body)
+	return httpReq, nil
+}
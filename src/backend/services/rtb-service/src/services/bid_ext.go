@@ -0,0 +1,107 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// ErrInvalidVerticalExt is wrapped by the error of a bid extension that fails its vertical's schema
+var ErrInvalidVerticalExt = errors.New("invalid vertical extension")
+
+// Outcomes of checking a bid extension, reported by rtb_bid_vertical_ext_total
+const (
+	bidExtValid       = "valid"
+	bidExtInvalid     = "invalid"
+	bidExtUnvalidated = "unvalidated"
+)
+
+// checkVerticalExts drops a partner's bids whose extension fails the vertical's schema, recording
+// why in debug output, and returns the bids kept and how many were dropped. Extensions of kept
+// bids are passed through untouched; verticals without a schema accept any extension.
+func (s *AuctionService) checkVerticalExts(vertical, partnerID string, bids []*models.Bid, debug *models.DebugInfo) ([]*models.Bid, int) {
+	schema := s.config.BidExtSchemas[vertical]
+	kept := bids[:0]
+	dropped := 0
+	for _, bid := range bids {
+		if len(bid.VerticalExt) == 0 {
+			kept = append(kept, bid)
+			continue
+		}
+		result := bidExtUnvalidated
+		if schema != nil {
+			if err := validateVerticalExt(schema, bid.VerticalExt); err != nil {
+				bidExtValidationsTotal.WithLabelValues(partnerID, vertical, bidExtInvalid).Inc()
+				debug.RecordInvalidExt(partnerID, bid.ID, err)
+				dropped++
+				continue
+			}
+			result = bidExtValid
+		}
+		bidExtValidationsTotal.WithLabelValues(partnerID, vertical, result).Inc()
+		if debug != nil {
+			debug.RecordVerticalExt(partnerID, bid.ID, models.SanitizeVerticalExt(bid.VerticalExt, s.config.PIIPolicy))
+		}
+		kept = append(kept, bid)
+	}
+	return kept, dropped
+}
+
+// validateVerticalExt checks a bid extension against its vertical's schema: it must fit the size
+// cap and be a JSON object holding every required key, with each listed key of its type
+func validateVerticalExt(schema *config.BidExtSchema, ext json.RawMessage) error {
+	if len(ext) > schema.SizeLimit() {
+		return fmt.Errorf("%w: %d bytes exceeds the %d byte limit", ErrInvalidVerticalExt, len(ext), schema.SizeLimit())
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(ext, &object); err != nil || object == nil {
+		return fmt.Errorf("%w: must be a JSON object", ErrInvalidVerticalExt)
+	}
+
+	// Keys are checked in name order so the same extension always reports the same failure
+	names := make([]string, 0, len(schema.Fields))
+	for name := range schema.Fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field := schema.Fields[name]
+		value, exists := object[name]
+		if !exists || bytes.Equal(value, []byte("null")) {
+			if field.Required {
+				return fmt.Errorf("%w: %s is required", ErrInvalidVerticalExt, name)
+			}
+			continue
+		}
+		if jsonType(value) != field.Type {
+			return fmt.Errorf("%w: %s must be of type %s", ErrInvalidVerticalExt, name, field.Type)
+		}
+	}
+	return nil
+}
+
+// jsonType names the type of a JSON value as bid extension schemas do
+func jsonType(value json.RawMessage) string {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 {
+		return ""
+	}
+	switch value[0] {
+	case '"':
+		return config.BidExtTypeString
+	case 't', 'f':
+		return config.BidExtTypeBool
+	case '{':
+		return config.BidExtTypeObject
+	case '[':
+		return config.BidExtTypeArray
+	case 'n':
+		return ""
+	}
+	return config.BidExtTypeNumber
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"math"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// bidGuidance returns the guidance of the partners sharing it, from the ranked bids and the
+// winners chosen from them. A competitor is a losing bid from a partner without a winner, as only
+// such a bid could have taken a winner's slot. Guidance inverts the effective-price multipliers,
+// so it is only given when optimizer ranked the auction by effective price, and deal bids, whose
+// price is fixed by their deal, get none.
+func (s *AuctionService) bidGuidance(optimizer *utils.BidOptimizer, request *models.BidRequest, ranked, winners []*models.Bid) []models.BidGuidance {
+	partners := s.partnerSnapshot()
+	sharing := false
+	for _, bid := range ranked {
+		if partner := partners[bid.PartnerID]; partner != nil && partner.ShareBidGuidance {
+			sharing = true
+			break
+		}
+	}
+	if !sharing || !optimizer.RanksByEffectivePrice(request.Vertical) {
+		return nil
+	}
+
+	won := make(map[*models.Bid]bool, len(winners))
+	winningPartners := make(map[string]bool, len(winners))
+	lowestWinner := math.Inf(1)
+	for _, bid := range winners {
+		won[bid] = true
+		winningPartners[bid.PartnerID] = true
+		if price, ok := optimizer.EffectivePrice(bid, request); ok {
+			lowestWinner = math.Min(lowestWinner, price)
+		}
+	}
+	bestCompetitor := math.Inf(-1)
+	for _, bid := range ranked {
+		if won[bid] || winningPartners[bid.PartnerID] {
+			continue
+		}
+		if price, ok := optimizer.EffectivePrice(bid, request); ok {
+			bestCompetitor = math.Max(bestCompetitor, price)
+		}
+	}
+
+	var guidance []models.BidGuidance
+	guided := make(map[string]bool)
+	for _, bid := range ranked {
+		partner := partners[bid.PartnerID]
+		if partner == nil || !partner.ShareBidGuidance || bid.DealID != "" {
+			continue
+		}
+		switch {
+		case won[bid]:
+			entry := models.BidGuidance{PartnerID: bid.PartnerID, BidID: bid.ID, PricingModel: bid.PricingModel, Won: true}
+			if !math.IsInf(bestCompetitor, -1) {
+				entry.MinToWin = s.guidancePrice(optimizer, request, bid, bestCompetitor)
+			}
+			guidance = append(guidance, entry)
+		// Only the best losing bid of a partner without a winner is guided; a partner's other
+		// bids lost to its own
+		case !winningPartners[bid.PartnerID] && !guided[bid.PartnerID] && !math.IsInf(lowestWinner, 1):
+			guided[bid.PartnerID] = true
+			guidance = append(guidance, models.BidGuidance{
+				PartnerID:    bid.PartnerID,
+				BidID:        bid.ID,
+				PricingModel: bid.PricingModel,
+				PriceToBeat:  s.guidancePrice(optimizer, request, bid, lowestWinner),
+			})
+		}
+	}
+	return guidance
+}
+
+// guidancePrice returns the price, in the bid's pricing model, at which bid would reach
+// effectivePrice, rounded like clearing prices, or nil when it cannot be worked out
+func (s *AuctionService) guidancePrice(optimizer *utils.BidOptimizer, request *models.BidRequest, bid *models.Bid, effectivePrice float64) *float64 {
+	cpl, ok := optimizer.PriceForEffectivePrice(bid, request, effectivePrice)
+	if !ok {
+		return nil
+	}
+	if bid.PricingModel != config.PricingModelRevShare {
+		price := s.rounder.Round(cpl)
+		return &price
+	}
+	// A revenue-share price is the percentage of the vertical's expected premium it costs
+	premium, exists := s.config.ExpectedPremium(request.Vertical)
+	if !exists || premium <= 0 {
+		return nil
+	}
+	price := cpl * 100 / premium
+	return &price
+}
+
+// committedGuidance drops the guidance of winners a budget refused after it was worked out
+func committedGuidance(guidance []models.BidGuidance, committed []*models.Bid) []models.BidGuidance {
+	if len(guidance) == 0 {
+		return guidance
+	}
+	kept := guidance[:0]
+	for _, entry := range guidance {
+		if !entry.Won || hasBid(committed, entry.PartnerID, entry.BidID) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept
+}
+
+// hasBid reports whether bids holds the partner's bid with ID bidID
+func hasBid(bids []*models.Bid, partnerID, bidID string) bool {
+	for _, bid := range bids {
+		if bid.PartnerID == partnerID && bid.ID == bidID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/rules"
+)
+
+// ruleWeekdays names weekdays as time.weekday conditions compare them
+var ruleWeekdays = [...]string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// bidRules are the configured bid rules with their conditions compiled
+type bidRules struct {
+	location *time.Location
+	rules    []bidRule
+}
+
+// bidRule is a bid rule with its condition compiled and its metric resolved
+type bidRule struct {
+	config.BidRule
+	condition *rules.Condition
+	matched   prometheus.Counter
+}
+
+// compileBidRules prepares the configured bid rules, returning nil when there are none. The config
+// is expected to be validated, so only a condition that does not compile is reported.
+func compileBidRules(cfg *config.RulesConfig) (*bidRules, error) {
+	if cfg == nil || len(cfg.Bids) == 0 {
+		return nil, nil
+	}
+	compiled := &bidRules{location: cfg.Location(), rules: make([]bidRule, 0, len(cfg.Bids))}
+	for _, rule := range cfg.Bids {
+		condition, err := rules.Compile(rule.When)
+		if err != nil {
+			return nil, err
+		}
+		compiled.rules = append(compiled.rules, bidRule{
+			BidRule:   rule,
+			condition: condition,
+			matched:   bidRuleMatches.WithLabelValues(rule.ID, rule.Action),
+		})
+	}
+	return compiled, nil
+}
+
+// SetBidRules replaces the bid rules; bids already checked keep the outcome of the old ones
+func (s *AuctionService) SetBidRules(cfg *config.RulesConfig) error {
+	compiled, err := compileBidRules(cfg)
+	if err != nil {
+		return err
+	}
+	s.bidRules.Store(compiled)
+	return nil
+}
+
+// applyBidRules checks each of a partner's valid bids against the bid rules in order. A matching
+// accept rule ends the check, a matching reject rule drops the bid with the rule as its loss
+// reason, and a matching adjust_price rule scales the bid's price before the next rule is checked.
+func (s *AuctionService) applyBidRules(round *auctionRound, partnerID string, bids []*models.Bid) []*models.Bid {
+	compiled, _ := s.bidRules.Load().(*bidRules)
+	if compiled == nil || len(bids) == 0 {
+		return bids
+	}
+
+	now := s.clock.Now().In(compiled.location)
+	env := &ruleEnv{request: round.request, partnerID: partnerID, hour: float64(now.Hour()), weekday: ruleWeekdays[now.Weekday()]}
+	kept := make([]*models.Bid, 0, len(bids))
+	for _, bid := range bids {
+		env.bid = bid
+		if rejectedBy := compiled.apply(env); rejectedBy != "" {
+			round.auction.Reasons().Loss(partnerID, bid.ID, models.RuleReason(rejectedBy))
+			continue
+		}
+		kept = append(kept, bid)
+	}
+	return kept
+}
+
+// apply runs the rules for the bid in env, returning the ID of the rule that rejected it, if any
+func (b *bidRules) apply(env *ruleEnv) string {
+	for i := range b.rules {
+		rule := &b.rules[i]
+		if !rule.condition.Matches(env) {
+			continue
+		}
+		rule.matched.Inc()
+		switch rule.Action {
+		case config.RuleActionAccept:
+			return ""
+		case config.RuleActionReject:
+			return rule.ID
+		case config.RuleActionAdjustPrice:
+			adjustBidPrice(env.bid, rule.PriceMultiplier)
+		}
+	}
+	return ""
+}
+
+// adjustBidPrice scales a bid's price, keeping the partner's own price for the audit log
+func adjustBidPrice(bid *models.Bid, multiplier float64) {
+	if bid.BidPrice == 0 {
+		bid.BidPrice = bid.Price
+	}
+	bid.Price *= multiplier
+	bid.NormalizedPrice *= multiplier
+}
+
+// ruleEnv supplies the fields of one bid to rule conditions
+type ruleEnv struct {
+	bid       *models.Bid
+	partnerID string
+	request   *models.BidRequest
+	hour      float64
+	weekday   string
+}
+
+// Number returns a number field
+func (e *ruleEnv) Number(field rules.Field, key string) (float64, bool) {
+	switch field {
+	case rules.FieldBidPrice:
+		return e.bid.CPL(), true
+	case rules.FieldBidQualityScore:
+		return e.bid.QualityScore, true
+	case rules.FieldTimeHour:
+		return e.hour, true
+	case rules.FieldRequestUserData:
+		switch value := e.request.UserData[key].(type) {
+		case float64:
+			return value, true
+		case int:
+			return float64(value), true
+		case int64:
+			return float64(value), true
+		case json.Number:
+			number, err := value.Float64()
+			return number, err == nil
+		}
+	}
+	return 0, false
+}
+
+// String returns a string field; empty request fields have no value
+func (e *ruleEnv) String(field rules.Field, key string) (string, bool) {
+	var value string
+	switch field {
+	case rules.FieldBidDealID:
+		return e.bid.DealID, true
+	case rules.FieldBidPricingModel:
+		return e.bid.PricingModel, true
+	case rules.FieldPartnerID:
+		return e.partnerID, true
+	case rules.FieldTimeWeekday:
+		return e.weekday, true
+	case rules.FieldRequestVertical:
+		value = e.request.Vertical
+	case rules.FieldRequestCountry:
+		if e.request.Geo != nil {
+			value = e.request.Geo.Country
+		}
+	case rules.FieldRequestRegion:
+		if e.request.Geo != nil {
+			value = e.request.Geo.Region
+		}
+	case rules.FieldRequestDeviceType:
+		if e.request.Device != nil {
+			value = e.request.Device.Type
+		}
+	case rules.FieldRequestUserData:
+		value, _ = e.request.UserData[key].(string)
+	}
+	return value, value != ""
+}
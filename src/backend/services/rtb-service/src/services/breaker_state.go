@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8" // v8.11.5
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// breakerStateKeyPrefix prefixes the Redis key holding each partner's saved breaker state
+const breakerStateKeyPrefix = "rtb:breaker:"
+
+// Breaker state persistence operations reported by the sync error metric
+const (
+	breakerSyncSave    = "save"
+	breakerSyncRestore = "restore"
+)
+
+// savedBreaker is a partner's breaker state and failure count as stored in Redis
+type savedBreaker struct {
+	State    BreakerState `json:"state"`
+	Failures int          `json:"failures"` // consecutive failures toward the threshold
+	OpenedAt time.Time    `json:"opened_at"`
+	Total    int          `json:"total_failures"` // failed calls counted in the partner stats
+}
+
+// breakerStateKey returns the key of a partner's saved state. Partner IDs are escaped so one
+// partner's key never collides with another's.
+func breakerStateKey(partnerID string) string {
+	return breakerStateKeyPrefix + url.QueryEscape(partnerID)
+}
+
+// breakerPersister saves partner breaker state to Redis so a restarted instance keeps partners
+// that were tripped for good reason out of auctions until their cooldown passes
+type breakerPersister struct {
+	client   *redis.Client
+	breakers *circuitBreakers
+	stats    *partnerStats
+	ttl      time.Duration
+	interval time.Duration
+	timeout  time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+}
+
+// newBreakerPersister creates the persister when Redis is configured, otherwise nil, and
+// connects it to the breakers' state transitions
+func newBreakerPersister(cfg *config.Config, client *redis.Client, breakers *circuitBreakers, stats *partnerStats) *breakerPersister {
+	if client == nil {
+		return nil
+	}
+	p := &breakerPersister{
+		client:   client,
+		breakers: breakers,
+		stats:    stats,
+		ttl:      config.DefaultBreakerStateTTL,
+		interval: config.DefaultBreakerPersistInterval,
+		timeout:  cfg.Redis.Timeout,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	if cb := cfg.CircuitBreaker; cb != nil {
+		if cb.StateTTL > 0 {
+			p.ttl = cb.StateTTL
+		}
+		if cb.PersistInterval > 0 {
+			p.interval = cb.PersistInterval
+		}
+	}
+	if cfg.CircuitBreaker == nil || cfg.CircuitBreaker.StateTTL == 0 {
+		p.ttl = max(p.ttl, 2*breakers.cooldown)
+	}
+	if p.timeout <= 0 {
+		p.timeout = time.Second
+	}
+	breakers.changed = make(chan string, 64)
+	return p
+}
+
+// restore loads the saved state of each partner. Partners without saved state, or every partner
+// when Redis cannot be read, start with a closed breaker and no failures.
+func (p *breakerPersister) restore(partners map[string]*config.PartnerConfig) {
+	if p == nil || len(partners) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	partnerIDs := make([]string, 0, len(partners))
+	keys := make([]string, 0, len(partners))
+	for partnerID := range partners {
+		partnerIDs = append(partnerIDs, partnerID)
+		keys = append(keys, breakerStateKey(partnerID))
+	}
+	values, err := p.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		breakerSyncErrorsTotal.WithLabelValues(breakerSyncRestore).Inc()
+		return
+	}
+	for i, value := range values {
+		text, ok := value.(string)
+		if !ok {
+			continue
+		}
+		var saved savedBreaker
+		if err := json.Unmarshal([]byte(text), &saved); err != nil {
+			breakerSyncErrorsTotal.WithLabelValues(breakerSyncRestore).Inc()
+			continue
+		}
+		state := p.breakers.restore(partnerIDs[i], partnerBreaker{state: saved.State, failures: saved.Failures, openedAt: saved.OpenedAt})
+		p.stats.RestoreFailures(partnerIDs[i], saved.Total)
+		breakerStatesRestoredTotal.WithLabelValues(string(state)).Inc()
+	}
+}
+
+// start saves each state transition as it happens and every partner's state each interval
+func (p *breakerPersister) start() {
+	if p == nil {
+		return
+	}
+	go func() {
+		defer close(p.done)
+		ticker := time.NewTicker(p.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.stop:
+				p.save(p.breakers.partnerIDs()...)
+				return
+			case partnerID := <-p.breakers.changed:
+				p.save(partnerID)
+			case <-ticker.C:
+				p.save(p.breakers.partnerIDs()...)
+			}
+		}
+	}()
+}
+
+// save writes the state of partners in one round trip, refreshing their TTL
+func (p *breakerPersister) save(partnerIDs ...string) {
+	if len(partnerIDs) == 0 {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.timeout)
+	defer cancel()
+
+	pipe := p.client.Pipeline()
+	for _, partnerID := range partnerIDs {
+		b, exists := p.breakers.snapshot(partnerID)
+		if !exists {
+			continue
+		}
+		value, err := json.Marshal(savedBreaker{State: b.state, Failures: b.failures, OpenedAt: b.openedAt, Total: p.stats.Failures(partnerID)})
+		if err != nil {
+			continue
+		}
+		pipe.Set(ctx, breakerStateKey(partnerID), value, p.ttl)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		breakerSyncErrorsTotal.WithLabelValues(breakerSyncSave).Inc()
+	}
+}
+
+// Close stops the persister after a final save of every partner's state
+func (p *breakerPersister) Close() error {
+	if p == nil {
+		return nil
+	}
+	p.once.Do(func() {
+		close(p.stop)
+		<-p.done
+	})
+	return nil
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// Auction phases whose budget overruns are counted
+const (
+	budgetPhaseCollection    = "collection"
+	budgetPhaseOptimization  = "optimization"
+	budgetPhaseSerialization = "serialization"
+)
+
+// auctionBudget splits an auction's deadline into a collection slice and an optimization slice,
+// leaving the end of the deadline for encoding the response
+type auctionBudget struct {
+	bounded      bool
+	collection   time.Time
+	optimization time.Duration
+}
+
+// newAuctionBudget budgets the time left before ctx's deadline, or the earlier deadline of the
+// auction's context. Auctions without a deadline are unbounded, so neither phase is cut short.
+func (s *AuctionService) newAuctionBudget(ctx context.Context, now time.Time) auctionBudget {
+	deadline, ok := ctx.Deadline()
+	if auctionDeadline, set := models.AuctionFromContext(ctx).Deadline(); set && (!ok || auctionDeadline.Before(deadline)) {
+		deadline, ok = auctionDeadline, true
+	}
+	if !ok {
+		return auctionBudget{}
+	}
+	collection, optimization := s.config.TimeoutBudget.Split(deadline.Sub(now))
+	return auctionBudget{bounded: true, collection: now.Add(collection), optimization: optimization}
+}
+
+// collectionContext returns ctx limited to the collection slice
+func (b auctionBudget) collectionContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if !b.bounded {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, b.collection)
+}
+
+// optimizationContext returns ctx limited to the optimization slice starting at start. A
+// collection that ends early leaves its unused time to serialization, not optimization.
+func (b auctionBudget) optimizationContext(ctx context.Context, start time.Time) (context.Context, context.CancelFunc) {
+	if !b.bounded {
+		return context.WithCancel(ctx)
+	}
+	return context.WithDeadline(ctx, start.Add(b.optimization))
+}
+
+// RecordResponseWritten counts a serialization overrun when an auction's response was written
+// after its deadline
+func (s *AuctionService) RecordResponseWritten(ctx context.Context) {
+	if deadline, ok := ctx.Deadline(); ok && time.Now().After(deadline) {
+		budgetOverrunsTotal.WithLabelValues(budgetPhaseSerialization).Inc()
+	}
+}
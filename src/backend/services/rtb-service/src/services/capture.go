@@ -0,0 +1,324 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap" // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// randomCapture draws the sampling decision for partner captures
+const randomCapture = "capture"
+
+// redacted replaces credentials in captures
+const redacted = "[REDACTED]"
+
+// Capture errors
+var (
+	ErrCaptureDuration   = errors.New("capture duration must be positive and at most the configured maximum")
+	ErrCaptureSampleRate = errors.New("capture sample rate must be above 0 and at most 1")
+	ErrCaptureRequester  = errors.New("capture requester is required")
+)
+
+// credentialHeaders are redacted from every capture, whatever the partner's auth scheme
+var credentialHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie"}
+
+// CaptureSession is a partner's capture while it is on
+type CaptureSession struct {
+	PartnerID   string    `json:"partner_id"`
+	SampleRate  float64   `json:"sample_rate"`
+	RequestedBy string    `json:"requested_by"`
+	StartedAt   time.Time `json:"started_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// PartnerCapture is one call to a partner as sent and received, with credentials redacted and
+// bodies cut at the configured size. Response is nil when the call failed before a response.
+type PartnerCapture struct {
+	RequestID  string           `json:"request_id"`
+	CapturedAt time.Time        `json:"captured_at"`
+	Duration   time.Duration    `json:"duration"`
+	Request    CapturedMessage  `json:"request"`
+	Response   *CapturedMessage `json:"response,omitempty"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// CapturedMessage is a captured request or response. BodyBytes is the full body size, which is
+// larger than Body when it was truncated.
+type CapturedMessage struct {
+	Method        string      `json:"method,omitempty"`
+	URL           string      `json:"url,omitempty"`
+	Status        int         `json:"status,omitempty"`
+	Headers       http.Header `json:"headers"`
+	Body          string      `json:"body"`
+	BodyBytes     int         `json:"body_bytes"`
+	BodyTruncated bool        `json:"body_truncated,omitempty"`
+}
+
+// partnerCaptures holds the capture sessions admins turned on and each partner's most recent
+// captures. Captures outlive their session so they can be read after it expires, until they are
+// cleared; each partner keeps at most MaxEntries.
+type partnerCaptures struct {
+	limits   config.CaptureConfig
+	clock    utils.Clock
+	logger   *zap.Logger
+	active   atomic.Int32 // sessions on, so partner calls skip the mutex when there are none
+	mutex    sync.Mutex
+	sessions map[string]*CaptureSession
+	entries  map[string][]*PartnerCapture // oldest first
+}
+
+// newPartnerCaptures creates a capture store with every partner's capture off
+func newPartnerCaptures(cfg *config.CaptureConfig, clock utils.Clock) *partnerCaptures {
+	return &partnerCaptures{
+		limits:   cfg.Limits(),
+		clock:    clock,
+		logger:   zap.NewNop(),
+		sessions: make(map[string]*CaptureSession),
+		entries:  make(map[string][]*PartnerCapture),
+	}
+}
+
+// session returns a partner's capture session, ending it once expired. Callers hold the mutex.
+func (p *partnerCaptures) session(partnerID string) *CaptureSession {
+	session, exists := p.sessions[partnerID]
+	if !exists {
+		return nil
+	}
+	if !p.clock.Now().Before(session.ExpiresAt) {
+		p.end(partnerID)
+		p.logger.Info("partner capture expired", zap.String("partner", partnerID))
+		return nil
+	}
+	return session
+}
+
+// end turns a partner's capture off. Callers hold the mutex.
+func (p *partnerCaptures) end(partnerID string) {
+	if _, exists := p.sessions[partnerID]; exists {
+		delete(p.sessions, partnerID)
+		p.active.Add(-1)
+	}
+}
+
+// sample reports whether to capture a call to a partner
+func (p *partnerCaptures) sample(partnerID string, random *rand.Rand) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	session := p.session(partnerID)
+	return session != nil && random.Float64() < session.SampleRate
+}
+
+// add keeps a capture, dropping the partner's oldest once it has MaxEntries
+func (p *partnerCaptures) add(partnerID string, capture *PartnerCapture) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	entries := append(p.entries[partnerID], capture)
+	if len(entries) > p.limits.MaxEntries {
+		entries = append(entries[:0], entries[len(entries)-p.limits.MaxEntries:]...)
+	}
+	p.entries[partnerID] = entries
+	partnerCapturesTotal.WithLabelValues(partnerID).Inc()
+}
+
+// StartCapture turns on capturing a sampleRate fraction of a partner's calls for duration,
+// replacing any capture already on. Captures kept so far are not cleared.
+func (s *AuctionService) StartCapture(partnerID string, sampleRate float64, duration time.Duration, requestedBy string) (*CaptureSession, error) {
+	if _, exists := s.partnerSnapshot()[partnerID]; !exists {
+		return nil, ErrUnknownPartner
+	}
+	if duration <= 0 || duration > s.captures.limits.MaxDuration {
+		return nil, ErrCaptureDuration
+	}
+	if !(sampleRate > 0 && sampleRate <= 1) {
+		return nil, ErrCaptureSampleRate
+	}
+	if requestedBy == "" {
+		return nil, ErrCaptureRequester
+	}
+
+	now := s.clock.Now().UTC()
+	session := &CaptureSession{PartnerID: partnerID, SampleRate: sampleRate, RequestedBy: requestedBy, StartedAt: now, ExpiresAt: now.Add(duration)}
+	s.captures.mutex.Lock()
+	defer s.captures.mutex.Unlock()
+	if _, exists := s.captures.sessions[partnerID]; !exists {
+		s.captures.active.Add(1)
+	}
+	s.captures.sessions[partnerID] = session
+	s.captures.logger.Warn("partner capture started", zap.String("partner", partnerID), zap.Float64("sample_rate", sampleRate),
+		zap.String("requested_by", requestedBy), zap.Time("expires_at", session.ExpiresAt))
+
+	// End the capture promptly even when no call comes to notice
+	time.AfterFunc(duration, func() {
+		s.captures.mutex.Lock()
+		defer s.captures.mutex.Unlock()
+		s.captures.session(partnerID)
+	})
+	result := *session
+	return &result, nil
+}
+
+// StopCapture turns a partner's capture off and clears its captures
+func (s *AuctionService) StopCapture(partnerID, requestedBy string) error {
+	if _, exists := s.partnerSnapshot()[partnerID]; !exists {
+		return ErrUnknownPartner
+	}
+	s.captures.mutex.Lock()
+	defer s.captures.mutex.Unlock()
+	s.captures.end(partnerID)
+	delete(s.captures.entries, partnerID)
+	s.captures.logger.Warn("partner capture stopped", zap.String("partner", partnerID), zap.String("requested_by", requestedBy))
+	return nil
+}
+
+// Captures returns a partner's capture session, nil when off, and its captures, newest first
+func (s *AuctionService) Captures(partnerID string) (*CaptureSession, []PartnerCapture, error) {
+	if _, exists := s.partnerSnapshot()[partnerID]; !exists {
+		return nil, nil, ErrUnknownPartner
+	}
+	s.captures.mutex.Lock()
+	defer s.captures.mutex.Unlock()
+	var session *CaptureSession
+	if current := s.captures.session(partnerID); current != nil {
+		copied := *current
+		session = &copied
+	}
+	entries := s.captures.entries[partnerID]
+	captures := make([]PartnerCapture, len(entries))
+	for i, capture := range entries {
+		captures[len(entries)-1-i] = *capture
+	}
+	return session, captures, nil
+}
+
+// captureCall starts capturing a partner call when the partner's capture is on and the call is
+// sampled, or returns nil. Replayed calls never reach the partner and are not captured.
+func (s *AuctionService) captureCall(ctx context.Context, partnerID string, partner *config.PartnerConfig,
+	request *models.BidRequest, httpReq *http.Request) *partnerCall {
+
+	if s.captures.active.Load() == 0 || models.ReplayFromContext(ctx) != nil {
+		return nil
+	}
+	if !s.captures.sample(partnerID, s.randFor(request, randomCapture)) {
+		return nil
+	}
+
+	call := &partnerCall{store: s.captures, partnerID: partnerID, start: s.clock.Now()}
+	call.redactor = s.captureRedactor(partnerID, partner, httpReq)
+	call.capture = &PartnerCapture{RequestID: request.RequestID, CapturedAt: call.start.UTC()}
+	var body []byte
+	if httpReq.GetBody != nil {
+		if reader, err := httpReq.GetBody(); err == nil {
+			body, _ = io.ReadAll(reader)
+		}
+	}
+	call.capture.Request = call.message(httpReq.Header, body)
+	call.capture.Request.Method = httpReq.Method
+	call.capture.Request.URL = call.redactor.scrub(httpReq.URL.Redacted())
+	return call
+}
+
+// captureRedactor collects what a partner's captures must leave out: its credential headers,
+// its resolved secrets, and the credential values sent on this call, such as a signature, in
+// case the partner echoes them back
+func (s *AuctionService) captureRedactor(partnerID string, partner *config.PartnerConfig, httpReq *http.Request) captureRedactor {
+	redactor := captureRedactor{headers: make(map[string]bool)}
+	for _, name := range credentialHeaders {
+		redactor.headers[http.CanonicalHeaderKey(name)] = true
+	}
+	secrets := []string{partner.APIKey}
+	if adapter, ok := s.adapterFor(partnerID, partner).(*authenticatedAdapter); ok {
+		headers, authSecrets := adapter.auth.redactions()
+		for _, name := range headers {
+			redactor.headers[http.CanonicalHeaderKey(name)] = true
+		}
+		secrets = append(secrets, authSecrets...)
+	}
+	for name, values := range httpReq.Header {
+		if redactor.headers[http.CanonicalHeaderKey(name)] {
+			secrets = append(secrets, values...)
+		}
+	}
+	for _, secret := range secrets {
+		if secret != "" {
+			redactor.secrets = append(redactor.secrets, secret)
+		}
+	}
+	return redactor
+}
+
+// captureRedactor removes credentials from captured headers, URLs, and bodies
+type captureRedactor struct {
+	headers map[string]bool // canonical names of headers replaced whole
+	secrets []string        // values removed wherever they appear
+}
+
+// scrub replaces every secret in text
+func (r captureRedactor) scrub(text string) string {
+	for _, secret := range r.secrets {
+		if strings.Contains(text, secret) {
+			text = strings.ReplaceAll(text, secret, redacted)
+		}
+	}
+	return text
+}
+
+// partnerCall is a partner call being captured
+type partnerCall struct {
+	store     *partnerCaptures
+	partnerID string
+	start     time.Time
+	redactor  captureRedactor
+	capture   *PartnerCapture
+}
+
+// message returns the captured form of headers and a body, redacted and cut at MaxBodyBytes.
+// Bodies are scrubbed before they are cut so no part of a secret survives at the cut.
+func (c *partnerCall) message(headers http.Header, body []byte) CapturedMessage {
+	message := CapturedMessage{Headers: make(http.Header, len(headers)), BodyBytes: len(body)}
+	for name, values := range headers {
+		redactedValues := make([]string, len(values))
+		for i, value := range values {
+			if c.redactor.headers[http.CanonicalHeaderKey(name)] {
+				redactedValues[i] = redacted
+			} else {
+				redactedValues[i] = c.redactor.scrub(value)
+			}
+		}
+		message.Headers[name] = redactedValues
+	}
+	message.Body = c.redactor.scrub(string(body))
+	if len(message.Body) > c.store.limits.MaxBodyBytes {
+		message.Body = message.Body[:c.store.limits.MaxBodyBytes]
+		message.BodyTruncated = true
+	}
+	return message
+}
+
+// finish records the partner's response, or the error that ended the call, and keeps the capture
+func (c *partnerCall) finish(resp *http.Response, body []byte, err error) {
+	if c == nil {
+		return
+	}
+	c.capture.Duration = c.store.clock.Now().Sub(c.start)
+	if resp != nil {
+		response := c.message(resp.Header, body)
+		response.Status = resp.StatusCode
+		c.capture.Response = &response
+	}
+	if err != nil {
+		c.capture.Error = c.redactor.scrub(err.Error())
+	}
+	c.store.add(c.partnerID, c.capture)
+}
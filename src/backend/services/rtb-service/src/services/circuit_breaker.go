@@ -0,0 +1,179 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Circuit breaker defaults used when no breaker configuration is supplied
+const (
+	defaultBreakerFailures = 5
+	defaultBreakerCooldown = 30 * time.Second
+)
+
+// BreakerState represents the state of a partner circuit breaker
+type BreakerState string
+
+// Circuit breaker states
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// partnerBreaker tracks consecutive failures for a single partner
+type partnerBreaker struct {
+	failures int
+	openedAt time.Time
+	state    BreakerState
+}
+
+// circuitBreakers manages per-partner circuit breakers with thread-safe access
+type circuitBreakers struct {
+	threshold int
+	cooldown  time.Duration
+	clock     utils.Clock
+	mutex     sync.Mutex
+	partners  map[string]*partnerBreaker
+	changed   chan string // partners whose state changed, when state is persisted
+}
+
+// newCircuitBreakers creates the breaker set from configuration, applying defaults
+func newCircuitBreakers(cfg *config.CircuitBreakerConfig, clock utils.Clock) *circuitBreakers {
+	cb := &circuitBreakers{
+		threshold: defaultBreakerFailures,
+		cooldown:  defaultBreakerCooldown,
+		clock:     clock,
+		partners:  make(map[string]*partnerBreaker),
+	}
+	if cfg != nil {
+		if cfg.FailureThreshold > 0 {
+			cb.threshold = cfg.FailureThreshold
+		}
+		if cfg.Cooldown > 0 {
+			cb.cooldown = cfg.Cooldown
+		}
+	}
+	return cb
+}
+
+// get returns the breaker for a partner, creating it if necessary. Caller must hold the mutex.
+func (cb *circuitBreakers) get(partnerID string) *partnerBreaker {
+	b, exists := cb.partners[partnerID]
+	if !exists {
+		b = &partnerBreaker{state: BreakerClosed}
+		cb.partners[partnerID] = b
+	}
+	return b
+}
+
+// Allow reports whether a partner may be called, moving open breakers to half-open after cooldown
+func (cb *circuitBreakers) Allow(partnerID string) bool {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	b := cb.get(partnerID)
+	if b.state == BreakerOpen && cb.clock.Now().Sub(b.openedAt) >= cb.cooldown {
+		b.state = BreakerHalfOpen
+		cb.notify(partnerID)
+	}
+	return b.state != BreakerOpen
+}
+
+// RecordSuccess closes the breaker for a partner
+func (cb *circuitBreakers) RecordSuccess(partnerID string) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	b := cb.get(partnerID)
+	if b.state != BreakerClosed {
+		cb.notify(partnerID)
+	}
+	b.failures = 0
+	b.state = BreakerClosed
+}
+
+// RecordFailure counts a failure and opens the breaker once the threshold is reached
+func (cb *circuitBreakers) RecordFailure(partnerID string) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	b := cb.get(partnerID)
+	b.failures++
+	if b.state == BreakerHalfOpen || b.failures >= cb.threshold {
+		if b.state != BreakerOpen {
+			cb.notify(partnerID)
+		}
+		b.state = BreakerOpen
+		b.openedAt = cb.clock.Now()
+	}
+}
+
+// State returns the current breaker state for a partner
+func (cb *circuitBreakers) State(partnerID string) BreakerState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	b, exists := cb.partners[partnerID]
+	if !exists {
+		return BreakerClosed
+	}
+	if b.state == BreakerOpen && cb.clock.Now().Sub(b.openedAt) >= cb.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// notify queues a partner whose state changed for persisting, without blocking the auction when
+// the queue is full; the periodic save catches up. Caller must hold the mutex.
+func (cb *circuitBreakers) notify(partnerID string) {
+	if cb.changed == nil {
+		return
+	}
+	select {
+	case cb.changed <- partnerID:
+	default:
+	}
+}
+
+// snapshot returns a copy of a partner's breaker and whether it exists
+func (cb *circuitBreakers) snapshot(partnerID string) (partnerBreaker, bool) {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	b, exists := cb.partners[partnerID]
+	if !exists {
+		return partnerBreaker{}, false
+	}
+	return *b, true
+}
+
+// partnerIDs returns the partners with a breaker, in order
+func (cb *circuitBreakers) partnerIDs() []string {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	ids := make([]string, 0, len(cb.partners))
+	for partnerID := range cb.partners {
+		ids = append(ids, partnerID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// restore replaces a partner's breaker with saved state. An open breaker whose cooldown passed
+// while the state was saved comes back half-open, so the partner gets a single probe.
+func (cb *circuitBreakers) restore(partnerID string, saved partnerBreaker) BreakerState {
+	cb.mutex.Lock()
+	defer cb.mutex.Unlock()
+
+	if saved.state == BreakerOpen && cb.clock.Now().Sub(saved.openedAt) >= cb.cooldown {
+		saved.state = BreakerHalfOpen
+	}
+	cb.partners[partnerID] = &saved
+	return saved.state
+}
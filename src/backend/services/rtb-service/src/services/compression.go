@@ -0,0 +1,89 @@
+package services
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Partner wire size stages
+const (
+	wireStageCompressed = "compressed"
+	wireStageDecoded    = "decoded"
+)
+
+// countingReader counts bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count += n
+	return n, err
+}
+
+// ReasonResponseTooLarge is the failure reason for a partner response past its size limit
+const ReasonResponseTooLarge = "response_too_large"
+
+// ResponseTooLargeError reports a partner response past the partner's size limit. Reading stops
+// at the limit and the response is never parsed, so an oversized body costs at most Limit bytes.
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+// Error implements the error interface
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("%s: over %d bytes", ReasonResponseTooLarge, e.Limit)
+}
+
+// readPartnerBody reads a decoded partner response of at most limit bytes, transparently
+// decompressing gzip bodies and recording compressed and decoded sizes. A response declaring a
+// longer Content-Length is rejected without reading it.
+func readPartnerBody(partnerID string, resp *http.Response, limit int64) ([]byte, error) {
+	if resp.ContentLength > limit {
+		return nil, &ResponseTooLargeError{Limit: limit}
+	}
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		// The transport holds a body to its declared length, so it is read into a buffer of that size
+		if resp.ContentLength >= 0 {
+			body := make([]byte, resp.ContentLength)
+			_, err := io.ReadFull(resp.Body, body)
+			return body, err
+		}
+		return readLimited(resp.Body, limit)
+	}
+
+	wire := &countingReader{reader: resp.Body}
+	reader, err := gzip.NewReader(wire)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	body, err := readLimited(reader, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	partnerWireBytes.WithLabelValues(partnerID, wireStageCompressed).Add(float64(wire.count))
+	partnerWireBytes.WithLabelValues(partnerID, wireStageDecoded).Add(float64(len(body)))
+	return body, nil
+}
+
+// readLimited reads all of reader, failing with a ResponseTooLargeError past limit bytes
+func readLimited(reader io.ReadCloser, limit int64) ([]byte, error) {
+	body, err := io.ReadAll(http.MaxBytesReader(nil, reader, limit))
+	if err == nil {
+		return body, nil
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return nil, &ResponseTooLargeError{Limit: limit}
+	}
+	return body, err
+}
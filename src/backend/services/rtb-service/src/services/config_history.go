@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap" // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/utils"
+	"github.com/yourdomain/rtb-service/src/webhooks"
+)
+
+// Config reload sources
+const (
+	ConfigSourceFile = "file" // the config file, re-read every config_reload_interval
+)
+
+// maxConfigHistory bounds the config changes kept for GET /admin/config/history
+const maxConfigHistory = 50
+
+// ConfigChange is a config reload that changed the config, with what changed. Secrets are left
+// out of the changes or redacted.
+type ConfigChange struct {
+	Source       string          `json:"source"`
+	PreviousHash string          `json:"previous_hash"`
+	Hash         string          `json:"hash"`
+	ChangedAt    time.Time       `json:"changed_at"`
+	Changes      []config.Change `json:"changes"`
+}
+
+// configHistory is the config last loaded and the changes between the configs loaded since start
+type configHistory struct {
+	clock   utils.Clock
+	logger  *zap.Logger
+	mutex   sync.Mutex
+	current *config.Config
+	hash    atomic.Value   // string, read by every auction event
+	changes []ConfigChange // oldest first
+}
+
+// newConfigHistory starts the history at the config the service started with
+func newConfigHistory(cfg *config.Config, clock utils.Clock) *configHistory {
+	history := &configHistory{clock: clock, logger: zap.NewNop(), current: cfg}
+	history.hash.Store(cfg.Hash())
+	return history
+}
+
+// RecordConfigReload diffs a successfully reloaded config against the config loaded before it
+// and, when they differ, logs the change, adds it to the history, and sends a config.changed
+// webhook. It returns the change, or nil when the config is unchanged.
+func (s *AuctionService) RecordConfigReload(cfg *config.Config, source string) *ConfigChange {
+	history := s.configs
+	hash := cfg.Hash()
+
+	history.mutex.Lock()
+	previousHash := history.hash.Load().(string)
+	if hash == previousHash {
+		history.mutex.Unlock()
+		return nil
+	}
+	change := ConfigChange{
+		Source:       source,
+		PreviousHash: previousHash,
+		Hash:         hash,
+		ChangedAt:    history.clock.Now().UTC(),
+		Changes:      config.Diff(history.current, cfg),
+	}
+	history.current = cfg
+	history.hash.Store(hash)
+	history.changes = append(history.changes, change)
+	if len(history.changes) > maxConfigHistory {
+		history.changes = history.changes[len(history.changes)-maxConfigHistory:]
+	}
+	history.mutex.Unlock()
+
+	history.logger.Info("config changed",
+		zap.String("source", source),
+		zap.String("previous_hash", previousHash),
+		zap.String("hash", hash),
+		zap.Any("changes", change.Changes))
+	s.dispatchWebhook(context.Background(), webhooks.Event{
+		ID:        webhookEventID(config.WebhookEventConfigChanged, previousHash, hash, change.ChangedAt.Format(time.RFC3339Nano)),
+		Type:      config.WebhookEventConfigChanged,
+		CreatedAt: change.ChangedAt,
+		Data:      change,
+	})
+	return &change
+}
+
+// ConfigHash returns the content hash of the config last loaded, which auction events carry so
+// outcomes can be joined to config versions
+func (s *AuctionService) ConfigHash() string {
+	return s.configs.hash.Load().(string)
+}
+
+// ConfigHistory returns the config changes since start, newest first
+func (s *AuctionService) ConfigHistory() []ConfigChange {
+	s.configs.mutex.Lock()
+	defer s.configs.mutex.Unlock()
+	history := make([]ConfigChange, len(s.configs.changes))
+	for i, change := range s.configs.changes {
+		history[len(s.configs.changes)-1-i] = change
+	}
+	return history
+}
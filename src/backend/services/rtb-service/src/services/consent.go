@@ -0,0 +1,56 @@
+package services
+
+import (
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// partnerConsentAllowed reports whether a partner may be called for the request's consent signals
+func partnerConsentAllowed(partner *config.PartnerConfig, request *models.BidRequest) bool {
+	return !partner.RequiresConsent || request.Consent.AllowsDataSharing()
+}
+
+// outboundRequest returns the request to send to a partner: consent redaction first, then the
+// partner's fields policy
+func (s *AuctionService) outboundRequest(partnerID string, partner *config.PartnerConfig, request *models.BidRequest) *models.BidRequest {
+	return s.consentRedacted(partnerID, partner, request).Minimized(partner.Fields)
+}
+
+// consentRedacted withholds configured personal UserData fields when the partner has no
+// data-processing agreement or consent does not cover sharing
+func (s *AuctionService) consentRedacted(partnerID string, partner *config.PartnerConfig, request *models.BidRequest) *models.BidRequest {
+	policy := s.config.Consent
+	if policy == nil || len(policy.PersonalDataFields) == 0 || len(request.UserData) == 0 {
+		return request
+	}
+	if partner.DPASigned && request.Consent.AllowsDataSharing() {
+		return request
+	}
+
+	userData := make(map[string]interface{}, len(request.UserData))
+	for key, value := range request.UserData {
+		userData[key] = value
+	}
+
+	redacted := false
+	for _, field := range policy.PersonalDataFields {
+		value, exists := userData[field]
+		if !exists {
+			continue
+		}
+		redacted = true
+		if policy.Action == config.ConsentActionHash {
+			userData[field] = models.HashValue(policy.HashSalt, value)
+		} else {
+			delete(userData, field)
+		}
+	}
+	if !redacted {
+		return request
+	}
+	consentRedactionsTotal.WithLabelValues(partnerID).Inc()
+
+	outbound := *request
+	outbound.UserData = userData
+	return &outbound
+}
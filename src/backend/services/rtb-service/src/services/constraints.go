@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// ErrInvalidConstraints is wrapped by errors for ranking constraints the service cannot apply
+var ErrInvalidConstraints = errors.New("invalid ranking constraints")
+
+// maxConstraints bounds the ranking constraints of a request, since each one may rescan the bids
+const maxConstraints = 10
+
+// ValidateConstraints checks that ranking constraints are well formed and name configured
+// partner groups
+func (s *AuctionService) ValidateConstraints(constraints []models.RankingConstraint) error {
+	if len(constraints) > maxConstraints {
+		return fmt.Errorf("%w: at most %d constraints are allowed: %d", ErrInvalidConstraints, maxConstraints, len(constraints))
+	}
+	for i, constraint := range constraints {
+		switch constraint.Type {
+		case models.ConstraintMaxPriceAtLeastOne:
+			if constraint.MaxPrice <= 0 {
+				return fmt.Errorf("%w: constraint %d: max price must be positive: %v", ErrInvalidConstraints, i, constraint.MaxPrice)
+			}
+		case models.ConstraintMaxFromPartnerGroup, models.ConstraintRequirePartnerGroup:
+			if _, exists := s.config.PartnerGroups[constraint.Group]; !exists {
+				return fmt.Errorf("%w: constraint %d: unknown partner group %q", ErrInvalidConstraints, i, constraint.Group)
+			}
+			if constraint.Type == models.ConstraintMaxFromPartnerGroup && constraint.Max < 1 {
+				return fmt.Errorf("%w: constraint %d: max must be at least 1: %d", ErrInvalidConstraints, i, constraint.Max)
+			}
+		default:
+			return fmt.Errorf("%w: constraint %d: unknown type %q", ErrInvalidConstraints, i, constraint.Type)
+		}
+	}
+	return nil
+}
+
+// applyConstraints adjusts winners, picked from ranked, to the request's ranking constraints and
+// returns them with the constraints they still miss. Winners that gave up their slot lose with
+// the ranking_constraint reason, and swapped-in bids no longer count an earlier loss.
+func (s *AuctionService) applyConstraints(ctx context.Context, request *models.BidRequest, ranked, winners []*models.Bid, maxWinners int, partnerDiverse bool) ([]*models.Bid, []models.RankingConstraint) {
+	pass := &constraintPass{
+		constraints:    request.Constraints,
+		groups:         s.config.PartnerGroups,
+		deals:          s.config.Deals,
+		maxWinners:     maxWinners,
+		partnerDiverse: partnerDiverse,
+		rank:           make(map[*models.Bid]int, len(ranked)),
+	}
+	for i, bid := range ranked {
+		pass.rank[bid] = i
+	}
+
+	adjusted := pass.apply(ranked, winners)
+	reasons := models.ReasonsFromContext(ctx)
+	for _, bid := range winners {
+		if !containsBid(adjusted, bid) {
+			reasons.Loss(bid.PartnerID, bid.ID, models.ReasonRankingConstraint)
+		}
+	}
+	for _, bid := range adjusted {
+		if !containsBid(winners, bid) {
+			reasons.ClearLoss(bid.PartnerID, bid.ID)
+		}
+	}
+
+	var unsatisfied []models.RankingConstraint
+	for _, constraint := range request.Constraints {
+		if !pass.satisfied(constraint, adjusted) {
+			unsatisfied = append(unsatisfied, constraint)
+		}
+	}
+	return adjusted, unsatisfied
+}
+
+// constraintPass changes a winner set as little as possible to meet ranking constraints. Changes
+// are greedy: over-represented groups give up their lowest-ranked winners, and an unmet requirement
+// takes a free slot with its best-ranked satisfying bid or else makes the one-for-one swap that
+// leaves the highest-ranked winners, so winners keep as much effective value as they can. A change
+// must keep partner diversity, in verticals that require it, and every constraint already met, and
+// guaranteed deal winners are never swapped out.
+type constraintPass struct {
+	constraints    []models.RankingConstraint
+	groups         map[string][]string
+	deals          map[string]*config.DealConfig
+	maxWinners     int
+	partnerDiverse bool
+	rank           map[*models.Bid]int
+}
+
+// apply returns the adjusted winners in ranked order
+func (p *constraintPass) apply(ranked, winners []*models.Bid) []*models.Bid {
+	adjusted := append([]*models.Bid(nil), winners...)
+
+	// Over-represented groups give up their lowest-ranked winners
+	for _, constraint := range p.constraints {
+		if constraint.Type != models.ConstraintMaxFromPartnerGroup {
+			continue
+		}
+		for i := len(adjusted) - 1; i >= 0 && p.groupWinners(constraint.Group, adjusted) > constraint.Max; i-- {
+			if p.inGroup(constraint.Group, adjusted[i].PartnerID) && !p.guaranteed(adjusted[i]) {
+				adjusted = append(adjusted[:i], adjusted[i+1:]...)
+			}
+		}
+	}
+
+	// Refill freed slots with the best bids the constraints allow
+	for _, bid := range ranked {
+		if len(adjusted) >= p.maxWinners {
+			break
+		}
+		if containsBid(adjusted, bid) {
+			continue
+		}
+		if candidate := p.with(adjusted, -1, bid); p.keeps(adjusted, candidate) {
+			adjusted = candidate
+		}
+	}
+
+	// Unmet requirements swap in their best-ranked satisfying bid
+	for _, constraint := range p.constraints {
+		if constraint.Type != models.ConstraintMaxFromPartnerGroup && !p.satisfied(constraint, adjusted) {
+			adjusted = p.satisfy(constraint, ranked, adjusted)
+		}
+	}
+	return adjusted
+}
+
+// satisfy adds or swaps in a bid meeting a requirement, or returns winners unchanged when no
+// change keeps the constraints already met
+func (p *constraintPass) satisfy(constraint models.RankingConstraint, ranked, winners []*models.Bid) []*models.Bid {
+	var swapped []*models.Bid
+	for _, bid := range ranked {
+		if containsBid(winners, bid) || !p.meets(constraint, bid) {
+			continue
+		}
+		// Filling a free slot gives up no winner, so the best-ranked bid that fits one is taken
+		if len(winners) < p.maxWinners {
+			if candidate := p.with(winners, -1, bid); p.keeps(winners, candidate) {
+				return candidate
+			}
+		}
+		for i := range winners {
+			if p.guaranteed(winners[i]) {
+				continue
+			}
+			if candidate := p.with(winners, i, bid); p.keeps(winners, candidate) && (swapped == nil || p.ranksAhead(candidate, swapped)) {
+				swapped = candidate
+			}
+		}
+	}
+	if swapped == nil {
+		return winners
+	}
+	return swapped
+}
+
+// ranksAhead reports whether winner set a, in ranked order, ranks ahead of b of the same size:
+// at the first position they differ, a holds the higher-ranked bid
+func (p *constraintPass) ranksAhead(a, b []*models.Bid) bool {
+	for i := range a {
+		if p.rank[a[i]] != p.rank[b[i]] {
+			return p.rank[a[i]] < p.rank[b[i]]
+		}
+	}
+	return false
+}
+
+// with returns a copy of winners with bid added, in place of the winner at replace when it is not
+// negative, in ranked order
+func (p *constraintPass) with(winners []*models.Bid, replace int, bid *models.Bid) []*models.Bid {
+	candidate := make([]*models.Bid, 0, len(winners)+1)
+	for i, winner := range winners {
+		if i != replace {
+			candidate = append(candidate, winner)
+		}
+	}
+	candidate = append(candidate, bid)
+	sort.SliceStable(candidate, func(i, j int) bool { return p.rank[candidate[i]] < p.rank[candidate[j]] })
+	return candidate
+}
+
+// keeps reports whether candidate keeps one bid per partner, when diversity is required, and every
+// constraint winners met, without adding winners to a group already over its limit
+func (p *constraintPass) keeps(winners, candidate []*models.Bid) bool {
+	if p.partnerDiverse && distinctPartners(candidate) < len(candidate) {
+		return false
+	}
+	for _, constraint := range p.constraints {
+		if constraint.Type == models.ConstraintMaxFromPartnerGroup {
+			if limit := max(constraint.Max, p.groupWinners(constraint.Group, winners)); p.groupWinners(constraint.Group, candidate) > limit {
+				return false
+			}
+			continue
+		}
+		if p.satisfied(constraint, winners) && !p.satisfied(constraint, candidate) {
+			return false
+		}
+	}
+	return true
+}
+
+// satisfied reports whether winners meet a constraint
+func (p *constraintPass) satisfied(constraint models.RankingConstraint, winners []*models.Bid) bool {
+	if constraint.Type == models.ConstraintMaxFromPartnerGroup {
+		return p.groupWinners(constraint.Group, winners) <= constraint.Max
+	}
+	for _, bid := range winners {
+		if p.meets(constraint, bid) {
+			return true
+		}
+	}
+	return false
+}
+
+// meets reports whether a single bid meets a requirement
+func (p *constraintPass) meets(constraint models.RankingConstraint, bid *models.Bid) bool {
+	switch constraint.Type {
+	case models.ConstraintMaxPriceAtLeastOne:
+		return bid.CPL() <= constraint.MaxPrice
+	case models.ConstraintRequirePartnerGroup:
+		return p.inGroup(constraint.Group, bid.PartnerID)
+	default:
+		return false
+	}
+}
+
+// groupWinners counts the winners from a partner group
+func (p *constraintPass) groupWinners(group string, winners []*models.Bid) int {
+	count := 0
+	for _, bid := range winners {
+		if p.inGroup(group, bid.PartnerID) {
+			count++
+		}
+	}
+	return count
+}
+
+// inGroup reports whether a partner is in a group. Groups are small enough that a scan is
+// cheaper than a map.
+func (p *constraintPass) inGroup(group, partnerID string) bool {
+	for _, member := range p.groups[group] {
+		if member == partnerID {
+			return true
+		}
+	}
+	return false
+}
+
+// guaranteed reports whether a bid is on a guaranteed deal
+func (p *constraintPass) guaranteed(bid *models.Bid) bool {
+	deal, exists := p.deals[bid.DealID]
+	return exists && deal.Guaranteed
+}
+
+// containsBid reports whether bids include bid
+func containsBid(bids []*models.Bid, bid *models.Bid) bool {
+	for _, candidate := range bids {
+		if candidate == bid {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// Deal bid outcomes recorded in metrics; every outcome but accepted and won rejects the bid
+const (
+	dealOutcomeAccepted     = "accepted"
+	dealOutcomeWon          = "won"
+	dealOutcomeUnknown      = "unknown_deal"
+	dealOutcomeWrongPartner = "wrong_partner"
+	dealOutcomeVertical     = "vertical_excluded"
+	dealOutcomeZip          = "zip_excluded"
+	dealOutcomeExpired      = "expired"
+	dealOutcomeBelowFloor   = "below_floor"
+)
+
+// dealLabelUnknown labels bids referencing unconfigured deals, keeping partner-supplied IDs out of metrics
+const dealLabelUnknown = "unknown"
+
+// applyDeals checks bids referencing a deal against its partner and constraints, pricing eligible
+// bids at the deal's fixed price. Ineligible deal bids are dropped; bids without a deal pass through.
+func (s *AuctionService) applyDeals(ctx context.Context, bids []*models.Bid, request *models.BidRequest) []*models.Bid {
+	reasons := models.ReasonsFromContext(ctx)
+	now := s.clock.Now()
+
+	kept := make([]*models.Bid, 0, len(bids))
+	for _, bid := range bids {
+		if bid.DealID == "" {
+			kept = append(kept, bid)
+			continue
+		}
+
+		deal, exists := s.config.Deals[bid.DealID]
+		outcome := dealOutcome(deal, exists, bid, request, now)
+		label := bid.DealID
+		if !exists {
+			label = dealLabelUnknown
+		}
+		dealBidsTotal.WithLabelValues(label, outcome).Inc()
+		if outcome != dealOutcomeAccepted {
+			reasons.Loss(bid.PartnerID, bid.ID, models.ReasonDealIneligible)
+			continue
+		}
+
+		// A fixed deal price is a cost per lead whatever the partner's own pricing model. A bid
+		// rule that adjusted the price already kept the partner's own.
+		if deal.FixedPrice > 0 {
+			if bid.BidPrice == 0 {
+				bid.BidPrice = bid.CPL()
+			}
+			bid.Price = deal.FixedPrice
+			bid.PricingModel, bid.NormalizedPrice = config.PricingModelCPL, deal.FixedPrice
+		}
+		kept = append(kept, bid)
+	}
+	return kept
+}
+
+// dealOutcome returns accepted when a bid meets its deal's terms, or the first term it breaks
+func dealOutcome(deal *config.DealConfig, exists bool, bid *models.Bid, request *models.BidRequest, now time.Time) string {
+	switch {
+	case !exists:
+		return dealOutcomeUnknown
+	case deal.PartnerID != bid.PartnerID:
+		return dealOutcomeWrongPartner
+	case !deal.ExpiresAt.IsZero() && !now.Before(deal.ExpiresAt):
+		return dealOutcomeExpired
+	case len(deal.Verticals) > 0 && !containsFold(deal.Verticals, request.Vertical):
+		return dealOutcomeVertical
+	case len(deal.Zips) > 0 && !containsFold(deal.Zips, requestZip(request)):
+		return dealOutcomeZip
+	case deal.FloorPrice > 0 && bid.CPL() < deal.FloorPrice:
+		return dealOutcomeBelowFloor
+	default:
+		return dealOutcomeAccepted
+	}
+}
+
+// prioritizeGuaranteedDeals moves bids on guaranteed deals ahead of all other bids, keeping
+// ranked order within each group, so they claim winner slots first
+func prioritizeGuaranteedDeals(bids []*models.Bid, deals map[string]*config.DealConfig) []*models.Bid {
+	guaranteed := make([]*models.Bid, 0, len(bids))
+	rest := make([]*models.Bid, 0, len(bids))
+	for _, bid := range bids {
+		if deal, exists := deals[bid.DealID]; exists && deal.Guaranteed {
+			guaranteed = append(guaranteed, bid)
+		} else {
+			rest = append(rest, bid)
+		}
+	}
+	return append(guaranteed, rest...)
+}
+
+// recordDealWins counts winning bids per deal
+func recordDealWins(winners []*models.Bid) {
+	for _, bid := range winners {
+		if bid.DealID != "" {
+			dealBidsTotal.WithLabelValues(bid.DealID, dealOutcomeWon).Inc()
+		}
+	}
+}
+
+// requestZip returns the consumer zip, preferring the enriched geo over submitted user data
+func requestZip(request *models.BidRequest) string {
+	if request.Geo != nil && request.Geo.Zip != "" {
+		return request.Geo.Zip
+	}
+	zip, _ := request.UserData["zip"].(string)
+	return zip
+}
+
+// containsFold reports whether values contains value, ignoring case and surrounding space
+func containsFold(values []string, value string) bool {
+	value = strings.TrimSpace(value)
+	for _, candidate := range values {
+		if strings.EqualFold(strings.TrimSpace(candidate), value) {
+			return true
+		}
+	}
+	return false
+}
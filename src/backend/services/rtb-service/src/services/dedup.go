@@ -0,0 +1,89 @@
+package services
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// dedupBids removes bids that duplicate a higher-ranked bid on any of keys. Bids must be in
+// ranked order so the first instance of each piece of demand is the one kept.
+func dedupBids(bids []*models.Bid, keys []string, reasons *models.ReasonCollector) []*models.Bid {
+	if len(keys) == 0 {
+		return bids
+	}
+
+	seen := make(map[string]bool)
+	kept := make([]*models.Bid, 0, len(bids))
+	for _, bid := range bids {
+		identities := demandKeys(bid, keys)
+
+		duplicate := false
+		for _, identity := range identities {
+			if seen[identity] {
+				duplicate = true
+				break
+			}
+		}
+		if duplicate {
+			reasons.Loss(bid.PartnerID, bid.ID, models.ReasonDuplicateDemand)
+			continue
+		}
+
+		for _, identity := range identities {
+			seen[identity] = true
+		}
+		kept = append(kept, bid)
+	}
+	return kept
+}
+
+// demandKeys returns the normalized identities of a bid for the configured keys, prefixed by key
+// so values from different keys never collide. Keys the bid has no value for are omitted.
+func demandKeys(bid *models.Bid, keys []string) []string {
+	identities := make([]string, 0, len(keys))
+	for _, key := range keys {
+		switch key {
+		case config.DedupKeyURL:
+			if normalized := normalizeClickURL(bid.ClickURL); normalized != "" {
+				identities = append(identities, key+":"+normalized)
+			}
+		case config.DedupKeyAdomain:
+			for _, domain := range bid.AdvertiserDomains {
+				if normalized := normalizeHost(domain); normalized != "" {
+					identities = append(identities, key+":"+normalized)
+				}
+			}
+		case config.DedupKeyCreativeTitle:
+			if title, ok := bid.Creative["title"].(string); ok {
+				if normalized := strings.Join(strings.Fields(strings.ToLower(title)), " "); normalized != "" {
+					identities = append(identities, key+":"+normalized)
+				}
+			}
+		}
+	}
+	return identities
+}
+
+// normalizeClickURL reduces a click URL to host and path, ignoring scheme, query, fragment,
+// default ports, and a trailing slash, so tracking parameters do not hide duplicates
+func normalizeClickURL(rawURL string) string {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || parsed.Host == "" {
+		return ""
+	}
+
+	host := normalizeHost(parsed.Hostname())
+	if port := parsed.Port(); port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+	return host + strings.TrimSuffix(parsed.EscapedPath(), "/")
+}
+
+// normalizeHost lowercases a host or domain and strips a leading www.
+func normalizeHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	return strings.TrimPrefix(host, "www.")
+}
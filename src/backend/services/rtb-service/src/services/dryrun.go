@@ -0,0 +1,102 @@
+package services
+
+import (
+	"context"
+
+	"github.com/yourdomain/rtb-service/src/audit"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/webhooks"
+)
+
+// PartnerTestHeader marks partner requests from dry-run auctions so partners can suppress their own side effects
+const PartnerTestHeader = "X-RTB-Test"
+
+// priceAnalyticsEffect describes a skipped price analytics update
+type priceAnalyticsEffect struct {
+	Vertical string `json:"vertical"`
+	Winners  int    `json:"winners"`
+}
+
+// partnerWinEffect describes a skipped partner report win
+type partnerWinEffect struct {
+	Price float64 `json:"price"`
+}
+
+// recordPartnerCall counts a partner call in the partner reports, partner selection stats, and
+// partner activity, or records it on a dry run
+func (s *AuctionService) recordPartnerCall(ctx context.Context, partnerID, vertical string, call PartnerCall) {
+	if dryRun := models.DryRunFromContext(ctx); dryRun != nil {
+		dryRun.Record(models.SideEffect{Type: models.SideEffectPartnerCall, PartnerID: partnerID, Detail: call})
+		return
+	}
+	s.reports.RecordCall(partnerID, call)
+	s.selection.recordCall(partnerID, vertical, call.Bids > call.InvalidBids)
+	s.activity.recordCall(partnerID, call.Bids > call.InvalidBids)
+}
+
+// recordPartnerWin counts a partner win in the partner reports, partner selection stats, and
+// partner activity, or records it on a dry run
+func (s *AuctionService) recordPartnerWin(ctx context.Context, partnerID, vertical string, price float64) {
+	if dryRun := models.DryRunFromContext(ctx); dryRun != nil {
+		dryRun.Record(models.SideEffect{Type: models.SideEffectPartnerWin, PartnerID: partnerID, Detail: partnerWinEffect{Price: price}})
+		return
+	}
+	s.reports.RecordWin(partnerID, price)
+	s.selection.recordWin(partnerID, vertical, price)
+	s.activity.recordWin(partnerID)
+}
+
+// trafficMixEffect describes a skipped traffic mix update
+type trafficMixEffect struct {
+	Vertical string   `json:"vertical"`
+	Offered  []string `json:"offered"`
+}
+
+// recordTrafficMix counts the partners a capped auction was offered to in the traffic mix, or
+// records it on a dry run
+func (s *AuctionService) recordTrafficMix(ctx context.Context, request *models.BidRequest, offered []string) {
+	if dryRun := models.DryRunFromContext(ctx); dryRun != nil {
+		dryRun.Record(models.SideEffect{Type: models.SideEffectTrafficMix, Detail: trafficMixEffect{Vertical: request.Vertical, Offered: offered}})
+		return
+	}
+	s.selection.traffic.record(request, offered)
+}
+
+// recordAnalytics adds an auction outcome to the price analytics, or records it on a dry run.
+// Auctions with forced parameters are left out so they cannot skew learned floors.
+func (s *AuctionService) recordAnalytics(ctx context.Context, request *models.BidRequest, winners []*models.Bid) {
+	if s.analytics == nil || models.AuctionParamsFromContext(ctx) != nil {
+		return
+	}
+	if dryRun := models.DryRunFromContext(ctx); dryRun != nil {
+		dryRun.Record(models.SideEffect{Type: models.SideEffectPriceAnalytics, Detail: priceAnalyticsEffect{Vertical: request.Vertical, Winners: len(winners)}})
+		return
+	}
+	s.analytics.Record(request, winners)
+}
+
+// writeAudit queues an audit record, or records it on a dry run
+func (s *AuctionService) writeAudit(ctx context.Context, record audit.Record) {
+	if s.audit == nil {
+		return
+	}
+	if dryRun := models.DryRunFromContext(ctx); dryRun != nil {
+		dryRun.Record(models.SideEffect{Type: models.SideEffectAuditRecord, PartnerID: record.PartnerID, Detail: record})
+		return
+	}
+	s.audit.Write(record)
+}
+
+// dispatchWebhook queues a webhook event, or records it on a dry run
+func (s *AuctionService) dispatchWebhook(ctx context.Context, event webhooks.Event) {
+	if s.webhooks == nil {
+		return
+	}
+	event.Region = s.config.ServiceRegion
+	event.ConfigHash = s.ConfigHash()
+	if dryRun := models.DryRunFromContext(ctx); dryRun != nil {
+		dryRun.Record(models.SideEffect{Type: models.SideEffectWebhook, Detail: event})
+		return
+	}
+	s.webhooks.Dispatch(event)
+}
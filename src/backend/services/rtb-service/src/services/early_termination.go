@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// errEarlyTerminated is the cause of partner calls cancelled because bid collection ended early
+var errEarlyTerminated = errors.New("bid collection ended early")
+
+// earlyStop decides when an auction can stop waiting for its remaining partners: once the best
+// bid in hand is certain to outrank anything they could return. Every bound is taken over all
+// quality scores, including model scores blended in after collection, and over every time-of-day
+// multiplier, so a bid that could still win keeps the auction waiting. It is guarded by the
+// round's mutex.
+type earlyStop struct {
+	optimizer   *utils.BidOptimizer
+	request     *models.BidRequest
+	maxBidPrice float64
+	floor       float64 // the adaptive floor the leading bid must clear, zero when none is applied
+	cancel      context.CancelCauseFunc
+	pending     map[string]pendingPartner
+	leader      float64 // the lowest effective price the best bid in hand can get
+	leaderPrice float64 // the highest cost per lead in hand, which wins when ranking falls back to price
+	armed       bool    // every partner call has been started, so pending is complete
+	stopped     bool
+}
+
+// pendingPartner bounds the bids a partner still being waited on could return
+type pendingPartner struct {
+	effectivePrice float64   // the highest effective price its bids can get
+	price          float64   // the highest cost per lead its bids can have
+	deadline       time.Time // when its call times out at the latest
+}
+
+// earlyStopFor returns the early stop for an auction, or nil when every partner must be waited
+// for. That is whenever the winner could turn on more than effective price rank: more than one
+// winner, another strategy, experiment treatments or forced parameters, deals, a bidder quorum, or
+// ranking constraints. Deterministic and replayed auctions also see every bid.
+func (s *AuctionService) earlyStopFor(ctx context.Context, request *models.BidRequest, arm experimentArm, settings auctionSettings, floor *models.AuctionFloor) *earlyStop {
+	if !s.config.EarlyTerminationEnabled() || settings.MaxBids != 1 || s.config.DeterministicMode ||
+		models.ReplayFromContext(ctx) != nil || len(arm.assignments) > 0 || len(s.config.Deals) > 0 ||
+		len(request.Constraints) > 0 || s.config.MinBiddersFor(request.Vertical) > 1 ||
+		!settings.optimizer.RanksByEffectivePrice(request.Vertical) {
+		return nil
+	}
+	stop := &earlyStop{
+		optimizer:   settings.optimizer,
+		request:     request,
+		maxBidPrice: s.config.MaxBidPrice,
+		pending:     make(map[string]pendingPartner),
+	}
+	if floor != nil {
+		stop.floor = floor.Floor
+	}
+	return stop
+}
+
+// expect adds a partner about to be called to the pending partners. A partner whose bids the
+// optimizer cannot bound keeps the auction waiting for it.
+func (e *earlyStop) expect(partnerID string, partner *config.PartnerConfig, now time.Time) {
+	price := partner.BudgetHold(e.maxBidPrice)
+	_, effectivePrice, ok := e.optimizer.EffectivePriceRange(partnerID, 0, price, nil, e.request)
+	if !ok {
+		effectivePrice = math.Inf(1)
+	}
+	e.pending[partnerID] = pendingPartner{effectivePrice: effectivePrice, price: price, deadline: now.Add(partner.Timeout)}
+}
+
+// offer considers a bid in hand for the lead. Deal bids and bids below the adaptive floor may be
+// dropped before ranking, so they never lead.
+func (e *earlyStop) offer(bid *models.Bid) {
+	if bid.DealID != "" || bid.CPL() < e.floor {
+		return
+	}
+	acknowledged := bid.QualityAcknowledged
+	low, _, ok := e.optimizer.EffectivePriceRange(bid.PartnerID, bid.CPL(), bid.CPL(), &acknowledged, e.request)
+	if !ok {
+		return
+	}
+	e.leader = math.Max(e.leader, low)
+	e.leaderPrice = math.Max(e.leaderPrice, bid.CPL())
+}
+
+// decided reports whether the best bid in hand strictly outranks anything a pending partner could
+// return, both by effective price and by cost per lead, so no tie or fallback ranking can favor it
+func (e *earlyStop) decided() bool {
+	if !e.armed || e.stopped || e.leader <= 0 || len(e.pending) == 0 {
+		return false
+	}
+	for _, partner := range e.pending {
+		if partner.effectivePrice >= e.leader || partner.price >= e.leaderPrice {
+			return false
+		}
+	}
+	return true
+}
+
+// stop cancels the pending partner calls, recording the most collection time it saves: until the
+// latest pending call would have timed out, or the collection deadline when that is sooner
+func (e *earlyStop) stop(ctx context.Context, now time.Time) {
+	e.stopped = true
+	var latest time.Time
+	for _, partner := range e.pending {
+		if partner.deadline.After(latest) {
+			latest = partner.deadline
+		}
+	}
+	if deadline, ok := ctx.Deadline(); ok && deadline.Before(latest) {
+		latest = deadline
+	}
+	earlyTerminationSaved.WithLabelValues(e.request.Vertical).Observe(math.Max(0, latest.Sub(now).Seconds()))
+	e.cancel(errEarlyTerminated)
+}
+
+// applyMaxBid drops bids priced above their partner's max bid, or the configured max bid price,
+// which early termination relies on to bound what a pending partner could return
+func applyMaxBid(partnerID string, partner *config.PartnerConfig, maxBidPrice float64, bids []*models.Bid, reasons *models.ReasonCollector) []*models.Bid {
+	limit := partner.BudgetHold(maxBidPrice)
+	kept := make([]*models.Bid, 0, len(bids))
+	for _, bid := range bids {
+		if bid.CPL() > limit {
+			reasons.Loss(partnerID, bid.ID, models.ReasonAboveMaxBid)
+			continue
+		}
+		kept = append(kept, bid)
+	}
+	return kept
+}
@@ -0,0 +1,172 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Endpoint call outcomes recorded in metrics
+const (
+	endpointOutcomeSuccess = "success"
+	endpointOutcomeFailure = "failure"
+)
+
+// EndpointStatus summarizes routing health and call results for one partner endpoint
+type EndpointStatus struct {
+	URL          string       `json:"url"`
+	Region       string       `json:"region,omitempty"`
+	Weight       int          `json:"weight"`
+	BreakerState BreakerState `json:"breaker_state"`
+	Successes    int          `json:"successes"`
+	Failures     int          `json:"failures"`
+	AvgLatencyMs float64      `json:"avg_latency_ms"`
+}
+
+// endpointStats accumulates call results for one endpoint
+type endpointStats struct {
+	successes int
+	failures  int
+	latency   time.Duration
+}
+
+// endpointRouter orders partner endpoints for each call and tracks their health, keeping
+// a breaker per endpoint so a dead primary drops behind healthy endpoints
+type endpointRouter struct {
+	breakers *circuitBreakers
+	mutex    sync.Mutex
+	stats    map[string]*endpointStats
+}
+
+// newEndpointRouter creates a router whose endpoint breakers share the partner breaker settings
+func newEndpointRouter(cfg *config.CircuitBreakerConfig, clock utils.Clock) *endpointRouter {
+	return &endpointRouter{
+		breakers: newCircuitBreakers(cfg, clock),
+		stats:    make(map[string]*endpointStats),
+	}
+}
+
+// endpointKey identifies an endpoint across partners
+func endpointKey(partnerID, url string) string {
+	return partnerID + "|" + url
+}
+
+// Order returns the endpoints to try in turn: endpoints with open breakers go last, backups
+// with zero weight follow the weighted endpoints, and weighted endpoints are shuffled so each
+// is tried first in proportion to its weight, drawing from random
+func (r *endpointRouter) Order(random *rand.Rand, partnerID string, endpoints []config.EndpointConfig) []config.EndpointConfig {
+	// A lone endpoint is tried whatever its breaker state
+	if len(endpoints) == 1 {
+		return endpoints
+	}
+
+	var weighted, backups, unhealthy []config.EndpointConfig
+	totalWeight := 0
+	for _, endpoint := range endpoints {
+		switch {
+		case !r.breakers.Allow(endpointKey(partnerID, endpoint.URL)):
+			unhealthy = append(unhealthy, endpoint)
+		case endpoint.Weight == 0:
+			backups = append(backups, endpoint)
+		default:
+			weighted = append(weighted, endpoint)
+			totalWeight += endpoint.Weight
+		}
+	}
+
+	ordered := make([]config.EndpointConfig, 0, len(endpoints))
+	for len(weighted) > 0 {
+		pick := random.Intn(totalWeight)
+		for i, endpoint := range weighted {
+			if pick < endpoint.Weight {
+				ordered = append(ordered, endpoint)
+				totalWeight -= endpoint.Weight
+				weighted = append(weighted[:i], weighted[i+1:]...)
+				break
+			}
+			pick -= endpoint.Weight
+		}
+	}
+	ordered = append(ordered, backups...)
+	return append(ordered, unhealthy...)
+}
+
+// Record updates an endpoint's breaker, stats, and metrics after a call
+func (r *endpointRouter) Record(partnerID, url string, healthy bool, latency time.Duration) {
+	metrics := metricsFor(partnerID).endpoint(url)
+	key := metrics.key
+	if healthy {
+		r.breakers.RecordSuccess(key)
+		metrics.successes.Inc()
+	} else {
+		r.breakers.RecordFailure(key)
+		metrics.failures.Inc()
+	}
+	metrics.duration.Observe(latency.Seconds())
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	stats, exists := r.stats[key]
+	if !exists {
+		stats = &endpointStats{}
+		r.stats[key] = stats
+	}
+	if healthy {
+		stats.successes++
+	} else {
+		stats.failures++
+	}
+	stats.latency += latency
+}
+
+// Statuses returns the status of each of a partner's endpoints in configured order
+func (r *endpointRouter) Statuses(partnerID string, endpoints []config.EndpointConfig) []EndpointStatus {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	statuses := make([]EndpointStatus, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		key := endpointKey(partnerID, endpoint.URL)
+		status := EndpointStatus{
+			URL:          endpoint.URL,
+			Region:       endpoint.Region,
+			Weight:       endpoint.Weight,
+			BreakerState: r.breakers.State(key),
+		}
+		if stats, exists := r.stats[key]; exists {
+			status.Successes = stats.successes
+			status.Failures = stats.failures
+			if calls := stats.successes + stats.failures; calls > 0 {
+				status.AvgLatencyMs = float64(stats.latency) / float64(time.Millisecond) / float64(calls)
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// endpointHealthy reports whether a call result reflects a working endpoint; transport
+// failures, timeouts, and 5xx responses count against it, while rejections and no-bids do not
+func endpointHealthy(ctx context.Context, err error, condition string) bool {
+	if err == nil {
+		return true
+	}
+	return ctx.Err() == nil && condition != config.RetryOnConnectError && condition != config.RetryOn5xx
+}
+
+// isFastFailure reports whether a call failed before reaching the partner, so another
+// endpoint can be tried within the same attempt
+func isFastFailure(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
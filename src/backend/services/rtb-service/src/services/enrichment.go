@@ -0,0 +1,98 @@
+package services
+
+import (
+	"net"
+	"strings"
+
+	"github.com/mssola/useragent"       // v1.0.0
+	"github.com/oschwald/geoip2-golang" // v1.9.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// enricher fills missing geo and device fields in-process from the client IP and User-Agent
+type enricher struct {
+	geo            *geoip2.Reader
+	parseUserAgent bool
+}
+
+// newEnricher opens the configured GeoIP database; a nil config disables enrichment
+func newEnricher(cfg *config.EnrichmentConfig) (*enricher, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+
+	e := &enricher{parseUserAgent: cfg.ParseUserAgent}
+	if cfg.GeoIPDatabasePath != "" {
+		reader, err := geoip2.Open(cfg.GeoIPDatabasePath)
+		if err != nil {
+			return nil, err
+		}
+		e.geo = reader
+	}
+	return e, nil
+}
+
+// lookupGeo resolves an IP to country, region, and postal code, returning nil when unknown
+func (e *enricher) lookupGeo(clientIP string) *models.Geo {
+	ip := net.ParseIP(clientIP)
+	if e.geo == nil || ip == nil {
+		return nil
+	}
+
+	record, err := e.geo.City(ip)
+	if err != nil || record.Country.IsoCode == "" {
+		return nil
+	}
+
+	geo := &models.Geo{
+		Country: record.Country.IsoCode,
+		Zip:     record.Postal.Code,
+	}
+	if len(record.Subdivisions) > 0 {
+		geo.Region = record.Subdivisions[0].IsoCode
+	}
+	return geo
+}
+
+// classifyDevice derives device type, OS, and browser from a User-Agent, returning nil when empty
+func classifyDevice(userAgent string) *models.Device {
+	if userAgent == "" {
+		return nil
+	}
+
+	ua := useragent.New(userAgent)
+	browser, _ := ua.Browser()
+	device := &models.Device{
+		OS:      ua.OSInfo().Name,
+		Browser: browser,
+	}
+
+	switch {
+	case ua.Bot():
+		device.Type = models.DeviceTypeBot
+	case strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "Tablet") ||
+		(strings.Contains(userAgent, "Android") && !strings.Contains(userAgent, "Mobile")):
+		device.Type = models.DeviceTypeTablet
+	case ua.Mobile():
+		device.Type = models.DeviceTypeMobile
+	default:
+		device.Type = models.DeviceTypeDesktop
+	}
+	return device
+}
+
+// EnrichRequest fills Geo and Device when the caller did not supply them.
+// Enrichment is best effort: lookups that fail leave the fields empty.
+func (s *AuctionService) EnrichRequest(request *models.BidRequest, clientIP, userAgent string) {
+	if s.enricher == nil || request == nil {
+		return
+	}
+	if request.Geo == nil {
+		request.Geo = s.enricher.lookupGeo(clientIP)
+	}
+	if request.Device == nil && s.enricher.parseUserAgent {
+		request.Device = classifyDevice(userAgent)
+	}
+}
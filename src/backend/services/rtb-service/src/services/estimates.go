@@ -0,0 +1,77 @@
+package services
+
+import (
+	"errors"
+	"math"
+)
+
+// Estimate errors
+var (
+	ErrEstimatesDisabled = errors.New("estimates disabled")
+	ErrNoEstimate        = errors.New("no estimate available")
+)
+
+// Estimate confidence levels
+const (
+	EstimateConfidenceHigh   = "high"
+	EstimateConfidenceMedium = "medium"
+	EstimateConfidenceLow    = "low"
+)
+
+// Estimate sources
+const (
+	EstimateSourceAnalytics = "analytics"
+	EstimateSourceStatic    = "static"
+)
+
+// Estimate is the expected value range of a lead before it is auctioned. State is set only when the
+// range comes from that state's own auctions.
+type Estimate struct {
+	Vertical   string  `json:"vertical"`
+	State      string  `json:"state,omitempty"`
+	Low        float64 `json:"low"`
+	High       float64 `json:"high"`
+	Confidence string  `json:"confidence"`
+	Source     string  `json:"source"`
+	Samples    uint64  `json:"samples,omitempty"`
+}
+
+// Estimate returns the value range for a lead in vertical, and state when non-empty, from recent
+// clearing prices in memory, falling back to the vertical-wide series, then to the static estimate
+func (s *AuctionService) Estimate(vertical, state string) (*Estimate, error) {
+	cfg := s.config.Estimates
+	if cfg == nil || !cfg.Enabled {
+		return nil, ErrEstimatesDisabled
+	}
+
+	regions := []string{""}
+	if state != "" && s.analytics != nil && s.analytics.config.ByRegion {
+		regions = []string{state, ""}
+	}
+	for _, region := range regions {
+		summary, err := s.analytics.Summary(vertical, region, cfg.Window)
+		if errors.Is(err, ErrAnalyticsDisabled) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		samples := uint64(math.Round(summary.AvgWinners * float64(summary.Count)))
+		if samples < uint64(cfg.MinSamples) {
+			continue
+		}
+		confidence := EstimateConfidenceMedium
+		if samples >= uint64(cfg.HighConfidenceSamples) {
+			confidence = EstimateConfidenceHigh
+		}
+		estimatesTotal.WithLabelValues(EstimateSourceAnalytics).Inc()
+		return &Estimate{Vertical: vertical, State: region, Low: summary.P25, High: summary.P75, Confidence: confidence, Source: EstimateSourceAnalytics, Samples: samples}, nil
+	}
+
+	static, exists := cfg.Static[vertical]
+	if !exists {
+		return nil, ErrNoEstimate
+	}
+	estimatesTotal.WithLabelValues(EstimateSourceStatic).Inc()
+	return &Estimate{Vertical: vertical, Low: static.Low, High: static.High, Confidence: EstimateConfidenceLow, Source: EstimateSourceStatic}, nil
+}
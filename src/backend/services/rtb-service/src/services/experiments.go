@@ -0,0 +1,237 @@
+package services
+
+import (
+	"context"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// experimentBuckets is the number of hash buckets traffic is split into, giving traffic
+// percentages a resolution of 0.01
+const experimentBuckets = 10000
+
+// ExperimentResult is the running tally of one variant of an experiment on this instance
+type ExperimentResult struct {
+	Auctions int64   `json:"auctions"`
+	Winners  int64   `json:"winners"`
+	Revenue  float64 `json:"revenue"`
+}
+
+// ExperimentReport describes an experiment and the results of its variants
+type ExperimentReport struct {
+	ID       string                      `json:"id"`
+	Config   *config.ExperimentConfig    `json:"config"`
+	Variants map[string]ExperimentResult `json:"variants"`
+}
+
+// experiments assigns auctions to experiment variants and tallies their results
+type experiments struct {
+	cfg        *config.Config
+	clock      utils.Clock
+	ids        []string // sorted, so assignments are listed in a stable order
+	optimizers sync.Map // optimizers by the IDs of the experiments an auction is in the treatment of
+	mutex      sync.Mutex
+	results    map[models.ExperimentAssignment]*ExperimentResult
+}
+
+// newExperiments returns the configured experiments, or nil when there are none
+func newExperiments(cfg *config.Config, clock utils.Clock) *experiments {
+	if len(cfg.Experiments) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(cfg.Experiments))
+	for id := range cfg.Experiments {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return &experiments{cfg: cfg, clock: clock, ids: ids, results: make(map[models.ExperimentAssignment]*ExperimentResult)}
+}
+
+// experimentArm is the variant of every experiment an auction runs in and the parameters it
+// runs with. The zero value runs with the configured parameters.
+type experimentArm struct {
+	assignments []models.ExperimentAssignment
+	optimizer   *utils.BidOptimizer // nil for the configured optimizer
+	floor       float64
+	manual      bool // parameters forced by an admin caller rather than an experiment treatment
+}
+
+// assign places a request in a variant of each experiment by hashing its bucketing key, so the
+// same lead lands in the same variant on every auction. Requests without the key are left out
+// of the experiment.
+func (e *experiments) assign(request *models.BidRequest) experimentArm {
+	var arm experimentArm
+	if e == nil || request == nil {
+		return arm
+	}
+
+	var treatments []string
+	var overrides config.ExperimentOverrides
+	for _, id := range e.ids {
+		experiment := e.cfg.Experiments[id]
+		key := experimentKey(experiment.BucketKey(), request)
+		if key == "" {
+			continue
+		}
+		variant := config.ExperimentVariantControl
+		if experimentBucket(id, key) < uint64(experiment.TrafficPercent*experimentBuckets/100) {
+			variant = config.ExperimentVariantTreatment
+			treatments = append(treatments, id)
+			mergeExperimentOverrides(&overrides, experiment.Overrides)
+		}
+		arm.assignments = append(arm.assignments, models.ExperimentAssignment{ID: id, Variant: variant})
+	}
+
+	if len(treatments) > 0 {
+		arm.optimizer = e.optimizerFor(strings.Join(treatments, ","), overrides)
+		arm.floor = overrides.Floor
+	}
+	return arm
+}
+
+// experimentKey returns the request's value for a bucketing key, or empty when it is missing
+func experimentKey(key string, request *models.BidRequest) string {
+	switch key {
+	case config.ExperimentBucketLeadID:
+		return request.LeadID
+	case config.ExperimentBucketRequestID:
+		return request.RequestID
+	default:
+		return segmentValue(key, request)
+	}
+}
+
+// experimentBucket hashes a bucketing key into one of the experiment's buckets. The experiment ID
+// is part of the hash so experiments split traffic independently of each other.
+func experimentBucket(id, key string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(id))
+	hash.Write([]byte{0})
+	hash.Write([]byte(key))
+	return hash.Sum64() % experimentBuckets
+}
+
+// mergeExperimentOverrides adds an experiment's overrides to those of the other experiments an
+// auction is in the treatment of. Validation keeps experiments from overriding the same parameter.
+func mergeExperimentOverrides(merged *config.ExperimentOverrides, overrides config.ExperimentOverrides) {
+	if overrides.QualityWeight != nil {
+		merged.QualityWeight = overrides.QualityWeight
+	}
+	if overrides.Strategy != "" {
+		merged.Strategy = overrides.Strategy
+	}
+	if overrides.Floor != 0 {
+		merged.Floor = overrides.Floor
+	}
+}
+
+// optimizerFor returns the optimizer for a set of treatments, building it on first use
+func (e *experiments) optimizerFor(treatments string, overrides config.ExperimentOverrides) *utils.BidOptimizer {
+	if optimizer, exists := e.optimizers.Load(treatments); exists {
+		return optimizer.(*utils.BidOptimizer)
+	}
+	optimizer, err := utils.NewBidOptimizerWithClock(overrides.Apply(e.cfg), nil, e.clock)
+	if err != nil {
+		return nil
+	}
+	stored, _ := e.optimizers.LoadOrStore(treatments, optimizer)
+	return stored.(*utils.BidOptimizer)
+}
+
+// optimizerOr returns the arm's optimizer, or fallback in the control variant
+func (a experimentArm) optimizerOr(fallback *utils.BidOptimizer) *utils.BidOptimizer {
+	if a.optimizer != nil {
+		return a.optimizer
+	}
+	return fallback
+}
+
+// applyFloor drops open auction bids priced below the treatment or forced floor, recording each as
+// a loss. Deal bids compete at their deal terms and are kept, as they are under the configured floor.
+func (a experimentArm) applyFloor(ctx context.Context, bids []*models.Bid) []*models.Bid {
+	if a.floor <= 0 {
+		return bids
+	}
+	reason := models.ReasonExperimentFloor
+	if a.manual {
+		reason = models.ReasonManualOverrideFloor
+	}
+	kept := make([]*models.Bid, 0, len(bids))
+	for _, bid := range bids {
+		if bid.DealID == "" && bid.CPL() < a.floor {
+			models.ReasonsFromContext(ctx).Loss(bid.PartnerID, bid.ID, reason)
+			continue
+		}
+		kept = append(kept, bid)
+	}
+	return kept
+}
+
+// recordExperimentAuction counts an auction in each variant it ran in. Dry runs and auctions with
+// forced parameters are left out of experiment results.
+func (s *AuctionService) recordExperimentAuction(ctx context.Context, assignments []models.ExperimentAssignment) {
+	if s.experiments == nil || models.DryRunFromContext(ctx) != nil || manualOverride(assignments) {
+		return
+	}
+	s.experiments.mutex.Lock()
+	defer s.experiments.mutex.Unlock()
+	for _, assignment := range assignments {
+		experimentAuctionsTotal.WithLabelValues(assignment.ID, assignment.Variant).Inc()
+		s.experiments.result(assignment).Auctions++
+	}
+}
+
+// recordExperimentRevenue adds an auction's sold winners to each variant it ran in
+func (s *AuctionService) recordExperimentRevenue(ctx context.Context, response *models.BidResponse) {
+	if s.experiments == nil || models.DryRunFromContext(ctx) != nil || len(response.Experiments) == 0 || manualOverride(response.Experiments) {
+		return
+	}
+	revenue := 0.0
+	for _, bid := range response.Bids {
+		revenue += bid.CPL()
+	}
+
+	s.experiments.mutex.Lock()
+	defer s.experiments.mutex.Unlock()
+	for _, assignment := range response.Experiments {
+		experimentWinnersTotal.WithLabelValues(assignment.ID, assignment.Variant).Add(float64(len(response.Bids)))
+		experimentRevenueTotal.WithLabelValues(assignment.ID, assignment.Variant).Add(revenue)
+		result := s.experiments.result(assignment)
+		result.Winners += int64(len(response.Bids))
+		result.Revenue += revenue
+	}
+}
+
+// result returns the tally of a variant. Callers hold the mutex.
+func (e *experiments) result(assignment models.ExperimentAssignment) *ExperimentResult {
+	result, exists := e.results[assignment]
+	if !exists {
+		result = &ExperimentResult{}
+		e.results[assignment] = result
+	}
+	return result
+}
+
+// Experiments returns the configured experiments with the results of their variants on this instance
+func (s *AuctionService) Experiments() []ExperimentReport {
+	if s.experiments == nil {
+		return []ExperimentReport{}
+	}
+	s.experiments.mutex.Lock()
+	defer s.experiments.mutex.Unlock()
+	reports := make([]ExperimentReport, 0, len(s.experiments.ids))
+	for _, id := range s.experiments.ids {
+		report := ExperimentReport{ID: id, Config: s.config.Experiments[id], Variants: make(map[string]ExperimentResult, 2)}
+		for _, variant := range []string{config.ExperimentVariantControl, config.ExperimentVariantTreatment} {
+			report.Variants[variant] = *s.experiments.result(models.ExperimentAssignment{ID: id, Variant: variant})
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/export"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// newExportStore opens the store auction exports are written to, or returns nil when exports are disabled
+func newExportStore(cfg *config.ExportConfig) (export.BlobStore, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+	store, err := export.NewStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("opening export store: %w", err)
+	}
+	return store, nil
+}
+
+// exportAuction queues a row per collected bid when the auction is sampled for export, whether
+// or not it sold. Winners carry their rank and clearing price. Dry runs and replays are never exported.
+func (s *AuctionService) exportAuction(ctx context.Context, request *models.BidRequest, bids, winners []*models.Bid, summary models.AuctionSummary) {
+	if s.exporter == nil || len(bids) == 0 || models.DryRunFromContext(ctx) != nil || models.ReplayFromContext(ctx) != nil ||
+		!s.exporter.Sample(s.randFor(request, randomExport)) {
+		return
+	}
+
+	ranks := make(map[*models.Bid]int, len(winners))
+	for i, winner := range winners {
+		ranks[winner] = i + 1
+	}
+	auction := export.Row{
+		AuctionTime:       s.clock.Now().UTC(),
+		RequestID:         request.RequestID,
+		LeadID:            request.LeadID,
+		Vertical:          request.Vertical,
+		PartnersContacted: summary.PartnersContacted,
+		PartnersBid:       summary.PartnersBid,
+		Bids:              len(bids),
+		Sold:              len(winners) > 0,
+		ConfigHash:        s.ConfigHash(),
+	}
+	if request.Geo != nil {
+		auction.State, auction.Country = request.Geo.Region, request.Geo.Country
+	}
+	if request.Device != nil {
+		auction.DeviceType = request.Device.Type
+	}
+	// Lead quality is exported as the PII policy leaves it
+	if quality := request.LeadQuality.Sanitized(s.config.PIIPolicy); quality != nil {
+		auction.ConsentTimestamp, auction.SessionDurationMs = quality.ConsentTimestamp, quality.SessionDurationMs
+		auction.PreviouslySold, auction.LeadScore = quality.PreviouslySold, quality.Score
+	}
+
+	rows := make([]export.Row, 0, len(bids))
+	for _, bid := range bids {
+		row := auction
+		row.PartnerID, row.BidID, row.DealID = bid.PartnerID, bid.ID, bid.DealID
+		row.BidPrice, row.PricingModel = bid.Price, bid.PricingModel
+		if bid.BidPrice > 0 {
+			row.BidPrice = bid.BidPrice
+		}
+		row.CPL, row.QualityScore, row.QualityAcknowledged = bid.CPL(), bid.QualityScore, bid.QualityAcknowledged
+		if rank, won := ranks[bid]; won {
+			row.Won, row.Rank, row.ClearingPrice = true, rank, bid.CPL()
+		}
+		rows = append(rows, row)
+	}
+	s.exporter.Export(rows)
+}
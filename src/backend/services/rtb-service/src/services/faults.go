@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"slices"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8" // v8.11.5
+	"go.uber.org/zap"              // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Fault injection bounds
+const (
+	MaxFaultTTL     = time.Hour
+	MaxFaultLatency = 10 * time.Second
+)
+
+// Fault kinds
+const (
+	FaultLatency   = "latency"   // delay calls to the partner by Latency
+	FaultError     = "error"     // fail calls to the partner before they are sent
+	FaultMalformed = "malformed" // answer calls to the partner with an unparseable response
+	FaultRedis     = "redis"     // fail Redis operations
+)
+
+// malformedFaultBody is the response a malformed fault answers partner calls with
+var malformedFaultBody = []byte(`{"bids": [{"id": "injected-fault", "price": `)
+
+// Fault errors
+var (
+	ErrFaultsDisabled = errors.New("fault injection is disabled")
+	ErrFaultTTL       = fmt.Errorf("fault ttl must be positive and at most %v", MaxFaultTTL)
+	ErrFaultReason    = errors.New("fault reason and requester are required")
+	ErrFaultKind      = errors.New("fault kind must be latency, error, malformed, or redis")
+	ErrFaultPercent   = errors.New("fault percentage must be greater than 0 and at most 100")
+	ErrFaultLatency   = fmt.Errorf("latency faults need a latency greater than 0 and at most %v, and other faults none", MaxFaultLatency)
+	ErrFaultPartner   = errors.New("partner faults need a known partner, and redis faults none")
+	ErrFaultNotFound  = errors.New("fault not found")
+	ErrInjectedFault  = errors.New("injected fault")
+)
+
+// Fault injects latency, errors, malformed responses, or Redis failures into a percentage of
+// partner calls or Redis operations until it expires or is lifted, for resilience testing in
+// staging
+type Fault struct {
+	ID          string        `json:"id"`
+	Kind        string        `json:"kind"`
+	PartnerID   string        `json:"partner_id,omitempty"`
+	Percentage  float64       `json:"percentage"`
+	Latency     time.Duration `json:"latency,omitempty"`
+	Reason      string        `json:"reason"`
+	RequestedBy string        `json:"requested_by"`
+	AdminKey    string        `json:"admin_key"` // fingerprint of the admin key that set it
+	CreatedAt   time.Time     `json:"created_at"`
+	ExpiresAt   time.Time     `json:"expires_at"`
+}
+
+// faultInjector holds the injected faults in effect
+type faultInjector struct {
+	clock   utils.Clock
+	random  *rand.Rand
+	logger  *zap.Logger
+	mutex   sync.Mutex
+	entries map[string]*Fault
+	present atomic.Bool // whether entries is non-empty, so calls without faults skip the mutex
+}
+
+// newFaultInjector creates an empty fault set drawing from random
+func newFaultInjector(clock utils.Clock, random *rand.Rand) *faultInjector {
+	return &faultInjector{clock: clock, random: random, logger: zap.NewNop(), entries: make(map[string]*Fault)}
+}
+
+// active returns the faults in effect, dropping expired ones. Callers hold the mutex.
+func (f *faultInjector) active() []*Fault {
+	now := f.clock.Now()
+	active := make([]*Fault, 0, len(f.entries))
+	for id, fault := range f.entries {
+		if !now.Before(fault.ExpiresAt) {
+			delete(f.entries, id)
+			f.logger.Info("fault expired", faultFields(fault)...)
+			continue
+		}
+		active = append(active, fault)
+	}
+	f.present.Store(len(f.entries) > 0)
+	sort.Slice(active, func(i, j int) bool { return active[i].CreatedAt.Before(active[j].CreatedAt) })
+	faultsActive.Reset()
+	for _, fault := range active {
+		faultsActive.WithLabelValues(fault.Kind, fault.PartnerID).Inc()
+	}
+	return active
+}
+
+// faultFields describes a fault for the log
+func faultFields(fault *Fault) []zap.Field {
+	return []zap.Field{
+		zap.String("id", fault.ID),
+		zap.String("kind", fault.Kind),
+		zap.String("partner", fault.PartnerID),
+		zap.Float64("percentage", fault.Percentage),
+		zap.Duration("latency", fault.Latency),
+		zap.String("reason", fault.Reason),
+		zap.String("requested_by", fault.RequestedBy),
+		zap.String("admin_key", fault.AdminKey),
+		zap.Time("expires_at", fault.ExpiresAt),
+	}
+}
+
+// AddFault puts a fault into effect for ttl and returns it with its ID and expiry set. Faults
+// can only be injected where fault injection is allowed (see config.FaultInjectionConfig).
+func (s *AuctionService) AddFault(fault Fault, ttl time.Duration) (*Fault, error) {
+	if !s.config.FaultInjection.Allowed() {
+		return nil, ErrFaultsDisabled
+	}
+	if ttl <= 0 || ttl > MaxFaultTTL {
+		return nil, ErrFaultTTL
+	}
+	if fault.Reason == "" || fault.RequestedBy == "" {
+		return nil, ErrFaultReason
+	}
+	if err := s.validateFault(fault); err != nil {
+		return nil, err
+	}
+
+	fault.ID = newOverrideID()
+	fault.CreatedAt = s.clock.Now().UTC()
+	fault.ExpiresAt = fault.CreatedAt.Add(ttl)
+
+	s.faults.mutex.Lock()
+	defer s.faults.mutex.Unlock()
+	s.faults.entries[fault.ID] = &fault
+	s.faults.logger.Warn("fault injected", faultFields(&fault)...)
+	s.faults.active()
+
+	// Expire the fault promptly even when no call runs to notice
+	time.AfterFunc(ttl, func() {
+		s.faults.mutex.Lock()
+		defer s.faults.mutex.Unlock()
+		s.faults.active()
+	})
+	result := fault
+	return &result, nil
+}
+
+// validateFault checks a fault's kind, percentage, latency, and partner
+func (s *AuctionService) validateFault(fault Fault) error {
+	switch fault.Kind {
+	case FaultLatency, FaultError, FaultMalformed, FaultRedis:
+	default:
+		return ErrFaultKind
+	}
+	if !(fault.Percentage > 0 && fault.Percentage <= 100) {
+		return ErrFaultPercent
+	}
+	if fault.Latency < 0 || fault.Latency > MaxFaultLatency || (fault.Kind == FaultLatency) != (fault.Latency > 0) {
+		return ErrFaultLatency
+	}
+	if fault.Kind == FaultRedis {
+		if fault.PartnerID != "" {
+			return ErrFaultPartner
+		}
+		return nil
+	}
+	if _, exists := s.partnerSnapshot()[fault.PartnerID]; !exists {
+		return fmt.Errorf("%w: %q", ErrFaultPartner, fault.PartnerID)
+	}
+	return nil
+}
+
+// RemoveFault lifts a fault before it expires
+func (s *AuctionService) RemoveFault(id, requestedBy string) error {
+	s.faults.mutex.Lock()
+	defer s.faults.mutex.Unlock()
+	fault, exists := s.faults.entries[id]
+	if !exists || !s.clock.Now().Before(fault.ExpiresAt) {
+		return ErrFaultNotFound
+	}
+	delete(s.faults.entries, id)
+	s.faults.logger.Warn("fault lifted", append(faultFields(fault), zap.String("lifted_by", requestedBy))...)
+	s.faults.active()
+	return nil
+}
+
+// Faults returns the faults in effect, oldest first
+func (s *AuctionService) Faults() []Fault {
+	s.faults.mutex.Lock()
+	defer s.faults.mutex.Unlock()
+	active := s.faults.active()
+	list := make([]Fault, len(active))
+	for i, fault := range active {
+		list[i] = *fault
+	}
+	return list
+}
+
+// draw returns the faults of the given kinds in effect for a partner, or for Redis when partnerID
+// is empty, each drawn against its percentage with random and counted as injected
+func (f *faultInjector) draw(random *rand.Rand, partnerID string, kinds ...string) []*Fault {
+	if !f.present.Load() {
+		return nil
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	var drawn []*Fault
+	for _, fault := range f.active() {
+		if fault.PartnerID != partnerID || !slices.Contains(kinds, fault.Kind) {
+			continue
+		}
+		if random.Float64()*100 >= fault.Percentage {
+			continue
+		}
+		faultsInjectedTotal.WithLabelValues(fault.Kind, fault.PartnerID, fault.ID).Inc()
+		drawn = append(drawn, fault)
+	}
+	return drawn
+}
+
+// partnerFaults are the faults drawn for one call to a partner endpoint
+type partnerFaults struct {
+	latency time.Duration
+	kind    string // FaultError or FaultMalformed when the partner's response is replaced
+}
+
+// partnerFaultsFor draws the faults for one call to a partner. Replayed auctions reproduce their
+// recording, so no faults apply to them.
+func (s *AuctionService) partnerFaultsFor(ctx context.Context, random *rand.Rand, partnerID string) partnerFaults {
+	var faults partnerFaults
+	if models.ReplayFromContext(ctx) != nil {
+		return faults
+	}
+	for _, fault := range s.faults.draw(random, partnerID, FaultLatency, FaultError, FaultMalformed) {
+		if fault.Kind == FaultLatency {
+			faults.latency += fault.Latency
+		} else if faults.kind == "" {
+			faults.kind = fault.Kind
+		}
+	}
+	return faults
+}
+
+// delay waits out the injected latency, failing like a slow partner when ctx ends first
+func (f partnerFaults) delay(ctx context.Context) error {
+	if f.latency <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(f.latency)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// replaces reports whether the faults replace the partner's response
+func (f partnerFaults) replaces() bool {
+	return f.kind != ""
+}
+
+// response returns the injected response in place of the partner's, as partnerResponse would
+func (f partnerFaults) response(ctx context.Context, partnerID string) (int, []byte, string, error) {
+	if f.kind == FaultMalformed {
+		return http.StatusOK, malformedFaultBody, "", nil
+	}
+	return 0, nil, transportRetryCondition(ctx), fmt.Errorf("%w: calling %s: %w", ErrPartnerFailure, partnerID, ErrInjectedFault)
+}
+
+// redisFaultHook fails a Redis command or pipeline when a Redis fault is drawn for it
+type redisFaultHook struct {
+	faults *faultInjector
+}
+
+// inject returns the error for a command or pipeline a Redis fault is drawn for
+func (h redisFaultHook) inject() error {
+	if len(h.faults.draw(h.faults.random, "", FaultRedis)) > 0 {
+		return fmt.Errorf("redis: %w", ErrInjectedFault)
+	}
+	return nil
+}
+
+func (h redisFaultHook) BeforeProcess(ctx context.Context, _ redis.Cmder) (context.Context, error) {
+	return ctx, h.inject()
+}
+
+func (h redisFaultHook) AfterProcess(context.Context, redis.Cmder) error {
+	return nil
+}
+
+func (h redisFaultHook) BeforeProcessPipeline(ctx context.Context, _ []redis.Cmder) (context.Context, error) {
+	return ctx, h.inject()
+}
+
+func (h redisFaultHook) AfterProcessPipeline(context.Context, []redis.Cmder) error {
+	return nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// ErrFloorsDisabled is returned when adaptive floors are not enabled
+var ErrFloorsDisabled = errors.New("adaptive floors disabled")
+
+// FloorSuggestion is the floor learned for a vertical, and optionally a region, from its recent
+// clearing prices. With fewer than the minimum samples the source is static and Floor is the
+// static floor. Applied reports whether auctions enforce the floor or it is only suggested.
+type FloorSuggestion struct {
+	Vertical    string    `json:"vertical"`
+	Region      string    `json:"region,omitempty"`
+	Floor       float64   `json:"floor"`
+	Source      string    `json:"source"`
+	Samples     uint64    `json:"samples"`
+	StaticFloor float64   `json:"static_floor"`
+	Applied     bool      `json:"applied"`
+	ComputedAt  time.Time `json:"computed_at"`
+}
+
+// adaptiveFloors learns floors from the price analytics. Each series' floor is cached for the
+// refresh interval so auctions do not merge histograms.
+type adaptiveFloors struct {
+	config    *config.AdaptiveFloorConfig
+	static    float64
+	maxSeries int
+	analytics *priceAnalytics
+	clock     utils.Clock
+	mutex     sync.Mutex
+	cached    map[priceSeriesKey]FloorSuggestion
+}
+
+// newAdaptiveFloors returns the floors learned from analytics, or nil when they are disabled
+func newAdaptiveFloors(cfg *config.Config, analytics *priceAnalytics, clock utils.Clock) *adaptiveFloors {
+	if analytics == nil || cfg.Analytics.Floors == nil || !cfg.Analytics.Floors.Enabled {
+		return nil
+	}
+	return &adaptiveFloors{
+		config:    cfg.Analytics.Floors,
+		static:    cfg.MinBidPrice,
+		maxSeries: cfg.Analytics.MaxSeries,
+		analytics: analytics,
+		clock:     clock,
+		cached:    make(map[priceSeriesKey]FloorSuggestion),
+	}
+}
+
+// suggestion returns a series' floor, recomputing it once the cached floor is older than the
+// refresh interval. Only as many series as analytics tracks are cached.
+func (f *adaptiveFloors) suggestion(key priceSeriesKey) FloorSuggestion {
+	now := f.clock.Now()
+	f.mutex.Lock()
+	cached, exists := f.cached[key]
+	f.mutex.Unlock()
+	if exists && now.Sub(cached.ComputedAt) < f.config.RefreshInterval {
+		return cached
+	}
+
+	hours := int64(math.Ceil(f.config.Window.Hours()))
+	price, samples := f.analytics.clearingPrice(key, hours, f.config.Percentile)
+	suggestion := FloorSuggestion{
+		Vertical:    key.Vertical,
+		Region:      key.Region,
+		Floor:       f.static,
+		Source:      models.FloorSourceStatic,
+		Samples:     samples,
+		StaticFloor: f.static,
+		Applied:     f.config.Apply,
+		ComputedAt:  now,
+	}
+	if samples >= uint64(f.config.MinSamples) {
+		suggestion.Floor = math.Max(f.config.MinFloor, math.Min(f.config.MaxFloor, price))
+		suggestion.Source = models.FloorSourceLearned
+	}
+
+	f.mutex.Lock()
+	if exists || len(f.cached) < f.maxSeries {
+		f.cached[key] = suggestion
+	}
+	f.mutex.Unlock()
+	return suggestion
+}
+
+// floorFor returns the floor an auction applies, or nil when floors are only suggested. With
+// regional floors, a region's learned floor is preferred over its vertical's.
+func (f *adaptiveFloors) floorFor(request *models.BidRequest) *models.AuctionFloor {
+	if f == nil || !f.config.Apply || request == nil {
+		return nil
+	}
+	if f.config.ByRegion && request.Geo != nil && request.Geo.Region != "" {
+		regional := f.suggestion(priceSeriesKey{Vertical: request.Vertical, Region: request.Geo.Region})
+		if regional.Source == models.FloorSourceLearned {
+			return &models.AuctionFloor{Floor: regional.Floor, Source: regional.Source, Region: regional.Region}
+		}
+	}
+	suggestion := f.suggestion(priceSeriesKey{Vertical: request.Vertical})
+	return &models.AuctionFloor{Floor: suggestion.Floor, Source: suggestion.Source}
+}
+
+// Suggestions returns the floors of the tracked series, of one vertical when vertical is non-empty,
+// sorted by vertical and region
+func (f *adaptiveFloors) Suggestions(vertical string) ([]FloorSuggestion, error) {
+	if f == nil {
+		return nil, ErrFloorsDisabled
+	}
+	suggestions := make([]FloorSuggestion, 0)
+	for _, key := range f.analytics.seriesKeys() {
+		if (vertical != "" && key.Vertical != vertical) || (key.Region != "" && !f.config.ByRegion) {
+			continue
+		}
+		suggestions = append(suggestions, f.suggestion(key))
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Vertical != suggestions[j].Vertical {
+			return suggestions[i].Vertical < suggestions[j].Vertical
+		}
+		return suggestions[i].Region < suggestions[j].Region
+	})
+	return suggestions, nil
+}
+
+// applyAdaptiveFloor drops open auction bids priced below the auction's adaptive or vertical
+// floor, recording each as a loss. Deal bids compete at their deal terms and are kept.
+func applyAdaptiveFloor(ctx context.Context, bids []*models.Bid, floor *models.AuctionFloor) []*models.Bid {
+	if floor == nil {
+		return bids
+	}
+	reason := models.ReasonAdaptiveFloor
+	if floor.Source == models.FloorSourceVertical {
+		reason = models.ReasonVerticalFloor
+	}
+	kept := make([]*models.Bid, 0, len(bids))
+	for _, bid := range bids {
+		if bid.DealID == "" && bid.CPL() < floor.Floor {
+			models.ReasonsFromContext(ctx).Loss(bid.PartnerID, bid.ID, reason)
+			continue
+		}
+		kept = append(kept, bid)
+	}
+	return kept
+}
+
+// AdaptiveFloors returns the learned floors of the tracked series, of one vertical when vertical
+// is non-empty
+func (s *AuctionService) AdaptiveFloors(vertical string) ([]FloorSuggestion, error) {
+	return s.floors.Suggestions(vertical)
+}
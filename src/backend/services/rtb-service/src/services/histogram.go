@@ -0,0 +1,47 @@
+package services
+
+import "math"
+
+// logScale maps values between min and max to log-spaced histogram buckets of a fixed relative
+// width, so a histogram's memory is fixed while its error stays proportional to the value
+type logScale struct {
+	min     float64
+	max     float64
+	growth  float64
+	buckets int
+}
+
+// newLogScale creates a scale whose buckets are precision wide relative to their lower bound
+func newLogScale(min, max, precision float64) logScale {
+	growth := math.Log1p(precision)
+	return logScale{
+		min:     min,
+		max:     max,
+		growth:  growth,
+		buckets: int(math.Ceil(math.Log(max/min)/growth)) + 1,
+	}
+}
+
+// bucket returns the bucket for a value, clamping values outside the scale
+func (l logScale) bucket(value float64) int {
+	if value <= l.min {
+		return 0
+	}
+	return int(math.Min(math.Log(value/l.min)/l.growth, float64(l.buckets-1)))
+}
+
+// quantile returns the midpoint of the bucket holding quantile q of counts, or 0 for an empty histogram
+func (l logScale) quantile(counts []uint64, total uint64, q float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	rank := uint64(math.Max(1, math.Ceil(q*float64(total))))
+	var seen uint64
+	for i, count := range counts {
+		seen += count
+		if seen >= rank {
+			return math.Min(l.min*math.Exp(l.growth*(float64(i)+0.5)), l.max)
+		}
+	}
+	return 0
+}
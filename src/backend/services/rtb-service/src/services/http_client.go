@@ -0,0 +1,357 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// HTTP trace phases recorded per partner call
+const (
+	phaseDNS       = "dns"
+	phaseConnect   = "connect"
+	phaseTLS       = "tls"
+	phaseFirstByte = "first_byte"
+)
+
+// TLS handshake failure reasons
+const (
+	handshakeVerify   = "verify"   // the partner's certificate was not trusted
+	handshakeRejected = "rejected" // the partner refused the handshake, often over the client certificate
+	handshakeProtocol = "protocol" // the endpoint did not speak TLS
+	handshakeTimeout  = "timeout"
+)
+
+// newPartnerHTTPClient creates the partner client from config with automatic compression disabled,
+// so only partners configured for gzip are offered it. A nil config keeps Go's default transport settings.
+func newPartnerHTTPClient(cfg *config.HTTPClientConfig) *http.Client {
+	return &http.Client{Transport: newPartnerTransport(cfg)}
+}
+
+// newPartnerTransport creates a partner transport from config
+func newPartnerTransport(cfg *config.HTTPClientConfig) *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableCompression = true
+	if cfg == nil {
+		return transport
+	}
+
+	transport.MaxIdleConns = cfg.MaxIdleConns
+	transport.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+	transport.IdleConnTimeout = cfg.IdleConnTimeout
+	transport.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+	transport.ForceAttemptHTTP2 = cfg.EnableHTTP2
+	if !cfg.EnableHTTP2 {
+		// A non-nil empty map disables HTTP/2 negotiation
+		transport.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+	if cfg.DNSCacheTTL > 0 {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		transport.DialContext = newDNSCache(cfg.DNSCacheTTL, net.DefaultResolver).dialContext(dialer)
+	}
+	return transport
+}
+
+// partnerClients holds the HTTP clients for partner calls. Partners with TLS settings get a client
+// for their TLS identity, shared by partners presenting the same certificates to the same server;
+// every other partner uses the shared client.
+type partnerClients struct {
+	cfg     *config.HTTPClientConfig
+	shared  *http.Client
+	mutex   sync.Mutex   // serializes updates
+	current atomic.Value // *partnerClientSet
+}
+
+// partnerClientSet is the clients in use, replaced whole when partner certificates reload
+type partnerClientSet struct {
+	partners   map[string]*http.Client // partner ID -> client
+	identities map[string]*http.Client // TLS identity -> client
+}
+
+// newPartnerClients creates the partner clients, loading the certificates of enabled partners
+func newPartnerClients(cfg *config.HTTPClientConfig, partners map[string]*config.PartnerConfig) (*partnerClients, error) {
+	clients := &partnerClients{cfg: cfg, shared: newPartnerHTTPClient(cfg)}
+	clients.current.Store(&partnerClientSet{})
+	if err := clients.set(partners); err != nil {
+		return nil, err
+	}
+	return clients, nil
+}
+
+// client returns the client for calls to a partner
+func (c *partnerClients) client(partnerID string) *http.Client {
+	if client, exists := c.current.Load().(*partnerClientSet).partners[partnerID]; exists {
+		return client
+	}
+	return c.shared
+}
+
+// set loads the certificates of enabled partners with TLS settings and switches to their clients.
+// A client whose identity is unchanged is kept with its connections; clients no longer used close
+// their idle connections. On error nothing changes.
+func (c *partnerClients) set(partners map[string]*config.PartnerConfig) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	previous := c.current.Load().(*partnerClientSet)
+	next := &partnerClientSet{partners: make(map[string]*http.Client), identities: make(map[string]*http.Client)}
+	for id, partner := range partners {
+		if !partner.Enabled || partner.TLS == nil {
+			continue
+		}
+		tlsConfig, identity, err := partner.TLS.ClientConfig()
+		if err != nil {
+			return fmt.Errorf("invalid TLS for partner %s: %w", id, err)
+		}
+		client, exists := next.identities[identity]
+		if !exists {
+			client, exists = previous.identities[identity]
+		}
+		if !exists {
+			transport := newPartnerTransport(c.cfg)
+			transport.TLSClientConfig = tlsConfig
+			client = &http.Client{Transport: transport}
+		}
+		next.identities[identity] = client
+		next.partners[id] = client
+	}
+	c.current.Store(next)
+
+	for identity, client := range previous.identities {
+		if _, kept := next.identities[identity]; !kept {
+			client.CloseIdleConnections()
+		}
+	}
+	return nil
+}
+
+// closeIdle closes the idle connections of every partner client
+func (c *partnerClients) closeIdle() {
+	c.shared.CloseIdleConnections()
+	for _, client := range c.current.Load().(*partnerClientSet).identities {
+		client.CloseIdleConnections()
+	}
+}
+
+// SetPartnerTLS reloads partner certificates from partners' TLS settings, so rotated certificates
+// take effect without a restart. Partners keep their current clients when an error is reported.
+func (s *AuctionService) SetPartnerTLS(partners map[string]*config.PartnerConfig) error {
+	return s.clients.set(partners)
+}
+
+// recordHandshakeFailure counts a partner call that failed in the TLS handshake for a reason other
+// than a timeout, which the call's trace counts as it happens
+func recordHandshakeFailure(partnerID string, err error) {
+	var (
+		verifyErr *tls.CertificateVerificationError
+		recordErr tls.RecordHeaderError
+		opErr     *net.OpError
+	)
+	switch {
+	case errors.As(err, &verifyErr):
+		partnerHandshakeFailuresTotal.WithLabelValues(partnerID, handshakeVerify).Inc()
+	case errors.As(err, &opErr) && opErr.Op == "remote error":
+		partnerHandshakeFailuresTotal.WithLabelValues(partnerID, handshakeRejected).Inc()
+	case errors.As(err, &recordErr):
+		partnerHandshakeFailuresTotal.WithLabelValues(partnerID, handshakeProtocol).Inc()
+	}
+}
+
+// isTimeout reports whether err is a timeout or a deadline passing
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+}
+
+// dnsEntry is a cached lookup result
+type dnsEntry struct {
+	addrs   []string
+	expires time.Time
+}
+
+// dnsCache caches partner hostname lookups for a fixed TTL
+type dnsCache struct {
+	ttl      time.Duration
+	resolver *net.Resolver
+	mutex    sync.Mutex
+	entries  map[string]dnsEntry
+}
+
+// newDNSCache creates a DNS cache backed by resolver
+func newDNSCache(ttl time.Duration, resolver *net.Resolver) *dnsCache {
+	return &dnsCache{ttl: ttl, resolver: resolver, entries: make(map[string]dnsEntry)}
+}
+
+// lookup returns cached addresses for host, resolving on a miss or after expiry.
+// Misses report DNS timing through the request's client trace.
+func (c *dnsCache) lookup(ctx context.Context, host string) ([]string, error) {
+	c.mutex.Lock()
+	entry, exists := c.entries[host]
+	c.mutex.Unlock()
+	if exists && time.Now().Before(entry.expires) {
+		return entry.addrs, nil
+	}
+
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	addrs, err := c.resolver.LookupHost(ctx, host)
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Err: err})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	c.mutex.Lock()
+	c.entries[host] = dnsEntry{addrs: addrs, expires: time.Now().Add(c.ttl)}
+	c.mutex.Unlock()
+	return addrs, nil
+}
+
+// dialContext returns a dial function that resolves hostnames through the cache and tries each address in turn
+func (c *dnsCache) dialContext(dialer *net.Dialer) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err != nil || net.ParseIP(host) != nil {
+			return dialer.DialContext(ctx, network, addr)
+		}
+
+		addrs, err := c.lookup(ctx, host)
+		if err != nil {
+			return nil, err
+		}
+
+		var lastErr error
+		for _, ip := range addrs {
+			conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip, port))
+			if err == nil {
+				return conn, nil
+			}
+			lastErr = err
+		}
+		return nil, lastErr
+	}
+}
+
+// partnerTrace records HTTP phase durations and connection reuse for a single partner call.
+// Traces are pooled per partner with their hooks built once; a hook firing late, such as a
+// background dial finishing after the call, updates whichever call now holds the trace.
+type partnerTrace struct {
+	metrics      *partnerMetrics
+	pool         *sync.Pool
+	trace        *httptrace.ClientTrace
+	mutex        sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+}
+
+// partnerTracePools maps partner IDs to pools of their traces
+var partnerTracePools sync.Map
+
+// newPartnerTrace creates a trace for partnerID whose hooks record into it
+func newPartnerTrace(partnerID string, pool *sync.Pool) *partnerTrace {
+	pt := &partnerTrace{metrics: metricsFor(partnerID), pool: pool}
+	pt.trace = &httptrace.ClientTrace{
+		GetConn: func(string) {
+			pt.mark(&pt.start)
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			pt.mark(&pt.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			pt.observe(phaseDNS, &pt.dnsStart)
+		},
+		ConnectStart: func(string, string) {
+			pt.mark(&pt.connectStart)
+		},
+		ConnectDone: func(string, string, error) {
+			pt.observe(phaseConnect, &pt.connectStart)
+		},
+		TLSHandshakeStart: func() {
+			pt.mark(&pt.tlsStart)
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			pt.observe(phaseTLS, &pt.tlsStart)
+			if err != nil && isTimeout(err) {
+				partnerHandshakeFailuresTotal.WithLabelValues(pt.metrics.partnerID, handshakeTimeout).Inc()
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				pt.metrics.reused.Inc()
+			} else {
+				pt.metrics.newConnections.Inc()
+			}
+		},
+		GotFirstResponseByte: func() {
+			pt.observe(phaseFirstByte, &pt.start)
+		},
+	}
+	return pt
+}
+
+// withPartnerTrace attaches phase instrumentation for partnerID to ctx. The returned trace must
+// be released once the call's response has been read.
+func withPartnerTrace(ctx context.Context, partnerID string) (context.Context, *partnerTrace) {
+	// Composing with an existing trace changes the hooks, so that trace cannot be reused
+	if httptrace.ContextClientTrace(ctx) != nil {
+		pt := newPartnerTrace(partnerID, nil)
+		return httptrace.WithClientTrace(ctx, pt.trace), pt
+	}
+
+	pool, exists := partnerTracePools.Load(partnerID)
+	if !exists {
+		pool, _ = partnerTracePools.LoadOrStore(partnerID, &sync.Pool{})
+	}
+	pt, _ := pool.(*sync.Pool).Get().(*partnerTrace)
+	if pt == nil {
+		pt = newPartnerTrace(partnerID, pool.(*sync.Pool))
+	}
+	return httptrace.WithClientTrace(ctx, pt.trace), pt
+}
+
+// release clears the trace and returns it to its partner's pool
+func (pt *partnerTrace) release() {
+	if pt == nil || pt.pool == nil {
+		return
+	}
+	pt.mutex.Lock()
+	pt.start, pt.dnsStart, pt.connectStart, pt.tlsStart = time.Time{}, time.Time{}, time.Time{}, time.Time{}
+	pt.mutex.Unlock()
+	pt.pool.Put(pt)
+}
+
+// mark records the start of a phase
+func (pt *partnerTrace) mark(start *time.Time) {
+	pt.mutex.Lock()
+	defer pt.mutex.Unlock()
+	*start = time.Now()
+}
+
+// observe records the duration of a phase that has started
+func (pt *partnerTrace) observe(phase string, start *time.Time) {
+	pt.mutex.Lock()
+	began := *start
+	pt.mutex.Unlock()
+	if began.IsZero() {
+		return
+	}
+	if phase == phaseFirstByte {
+		pt.metrics.firstByte.Observe(time.Since(began).Seconds())
+		return
+	}
+	partnerPhaseDuration.WithLabelValues(pt.metrics.partnerID, phase).Observe(time.Since(began).Seconds())
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"   // v8.11.5
+	"go.uber.org/zap"                // v1.24.0
+	"golang.org/x/sync/singleflight" // v0.3.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// Idempotency defaults and key layout
+const (
+	idempotencyKeyPrefix      = "rtb:idempotency:"
+	defaultIdempotencyWindow  = 10 * time.Minute
+	defaultIdempotencyEntries = 10000
+)
+
+// defaultFingerprintFields are the UserData fields fingerprinted with the lead ID and vertical
+// when no fingerprint fields are configured
+var defaultFingerprintFields = []string{"zip", "state", "email", "phone"}
+
+// ErrDuplicateRequest is returned when a request ID is reused without a completed response to replay
+var ErrDuplicateRequest = errors.New("request ID already used")
+
+// ErrRequestConflict is wrapped by every RequestConflictError
+var ErrRequestConflict = errors.New("request ID already used for a different payload")
+
+// RequestConflictError is returned when a request ID is reused for a payload whose fingerprint
+// differs from the one the ID was first used for, so the stored response is never sold to the wrong lead
+type RequestConflictError struct {
+	RequestID         string
+	StoredFingerprint string
+	Fingerprint       string
+}
+
+// Error returns the request ID and both fingerprints
+func (e *RequestConflictError) Error() string {
+	return ErrRequestConflict.Error() + ": " + e.RequestID + " (stored " + e.StoredFingerprint + ", received " + e.Fingerprint + ")"
+}
+
+// Unwrap returns ErrRequestConflict
+func (e *RequestConflictError) Unwrap() error {
+	return ErrRequestConflict
+}
+
+// idempotencyRecord is the stored state of an auction keyed by request ID.
+// A record without a response marks an auction that started but never completed.
+// The request is stored sanitized so the buffer never holds raw PII, and Fingerprint identifies
+// the raw payload the request ID was first used for.
+type idempotencyRecord struct {
+	Request     *models.BidRequest  `json:"request,omitempty"`
+	Response    *models.BidResponse `json:"response,omitempty"`
+	Fingerprint string              `json:"fingerprint,omitempty"`
+}
+
+// conflicts reports whether the record was stored for a payload other than fingerprint. Records
+// stored without a fingerprint match any payload.
+func (r *idempotencyRecord) conflicts(fingerprint string) bool {
+	return r.Fingerprint != "" && r.Fingerprint != fingerprint
+}
+
+// idempotencyStore persists idempotency records for the configured window
+type idempotencyStore interface {
+	Get(ctx context.Context, requestID string) (*idempotencyRecord, bool, error)
+	Put(ctx context.Context, requestID string, record *idempotencyRecord, ttl time.Duration) error
+}
+
+// newIdempotencyStore selects Redis when available, falling back to an in-memory buffer
+func newIdempotencyStore(cfg *config.IdempotencyConfig, client *redis.Client) idempotencyStore {
+	if client != nil {
+		return &redisIdempotencyStore{client: client}
+	}
+
+	maxEntries := cfg.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultIdempotencyEntries
+	}
+	return &memoryIdempotencyStore{
+		maxEntries: maxEntries,
+		entries:    make(map[string]memoryIdempotencyEntry),
+	}
+}
+
+// redisIdempotencyStore stores records as JSON values with a TTL
+type redisIdempotencyStore struct {
+	client *redis.Client
+}
+
+func (r *redisIdempotencyStore) Get(ctx context.Context, requestID string) (*idempotencyRecord, bool, error) {
+	data, err := r.client.Get(ctx, idempotencyKeyPrefix+requestID).Bytes()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, false, err
+	}
+	return &record, true, nil
+}
+
+func (r *redisIdempotencyStore) Put(ctx context.Context, requestID string, record *idempotencyRecord, ttl time.Duration) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return r.client.Set(ctx, idempotencyKeyPrefix+requestID, data, ttl).Err()
+}
+
+// memoryIdempotencyEntry is a record with its expiry time
+type memoryIdempotencyEntry struct {
+	record    *idempotencyRecord
+	expiresAt time.Time
+}
+
+// memoryIdempotencyStore is a bounded in-memory buffer of recent auctions, evicting oldest first
+type memoryIdempotencyStore struct {
+	maxEntries int
+	mutex      sync.Mutex
+	entries    map[string]memoryIdempotencyEntry
+	order      []string
+}
+
+func (m *memoryIdempotencyStore) Get(ctx context.Context, requestID string) (*idempotencyRecord, bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry, exists := m.entries[requestID]
+	if !exists || time.Now().After(entry.expiresAt) {
+		return nil, false, nil
+	}
+	return entry.record, true, nil
+}
+
+func (m *memoryIdempotencyStore) Put(ctx context.Context, requestID string, record *idempotencyRecord, ttl time.Duration) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.entries[requestID]; !exists {
+		m.order = append(m.order, requestID)
+	}
+	m.entries[requestID] = memoryIdempotencyEntry{record: record, expiresAt: time.Now().Add(ttl)}
+
+	for len(m.order) > m.maxEntries {
+		delete(m.entries, m.order[0])
+		m.order = m.order[1:]
+	}
+	return nil
+}
+
+// idempotencyGuard coalesces concurrent duplicates and replays completed auctions
+type idempotencyGuard struct {
+	store        idempotencyStore
+	window       time.Duration
+	rejectReused bool
+	fields       []string
+	inflight     singleflight.Group
+	logger       *zap.Logger
+}
+
+// newIdempotencyGuard creates the guard when idempotency is enabled, otherwise nil
+func newIdempotencyGuard(cfg *config.IdempotencyConfig, client *redis.Client) *idempotencyGuard {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+
+	window := cfg.Window
+	if window <= 0 {
+		window = defaultIdempotencyWindow
+	}
+	fields := cfg.FingerprintFields
+	if len(fields) == 0 {
+		fields = defaultFingerprintFields
+	}
+	fields = append([]string(nil), fields...)
+	sort.Strings(fields)
+	return &idempotencyGuard{
+		store:        newIdempotencyStore(cfg, client),
+		window:       window,
+		rejectReused: cfg.RejectReusedUnknown,
+		fields:       fields,
+		logger:       zap.NewNop(),
+	}
+}
+
+// fingerprint hashes the request's lead ID, vertical, and fingerprint fields, so retries of the
+// same payload match and a request ID reused for another lead does not. Field values are
+// JSON-encoded, so a number and the same digits as a string differ.
+func (g *idempotencyGuard) fingerprint(request *models.BidRequest) string {
+	hash := sha256.New()
+	for _, part := range []string{request.LeadID, request.Vertical} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	for _, field := range g.fields {
+		value, exists := request.UserData[field]
+		if !exists {
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		hash.Write([]byte(field))
+		hash.Write([]byte{0})
+		hash.Write(encoded)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// conflict logs and counts a request ID reused for a different payload and returns its error
+func (g *idempotencyGuard) conflict(requestID, storedFingerprint, fingerprint string) error {
+	idempotencyConflictsTotal.Inc()
+	g.logger.Warn("request ID reused for a different payload",
+		zap.String("request_id", requestID),
+		zap.String("stored_fingerprint", storedFingerprint),
+		zap.String("fingerprint", fingerprint))
+	return &RequestConflictError{RequestID: requestID, StoredFingerprint: storedFingerprint, Fingerprint: fingerprint}
+}
+
+// RunAuctionIdempotent runs an auction at most once per request ID within the idempotency window.
+// It returns the response, whether it was replayed from a previous or concurrent auction, and any error.
+// A request ID reused for a payload with a different fingerprint fails with a RequestConflictError
+// instead of replaying, whether the first auction has completed or is still running.
+func (s *AuctionService) RunAuctionIdempotent(ctx context.Context, request *models.BidRequest) (*models.BidResponse, bool, error) {
+	if s.idempotency == nil || request == nil || request.RequestID == "" {
+		response, err := s.RunAuction(ctx, request)
+		return response, false, err
+	}
+
+	// Invalid requests are rejected before the request ID is marked as used, so a corrected
+	// retry can reuse it
+	if err := s.ValidateUserData(request); err != nil {
+		return nil, false, err
+	}
+
+	guard := s.idempotency
+	fingerprint := guard.fingerprint(request)
+	fresh := false
+	result, err, _ := guard.inflight.Do(request.RequestID, func() (interface{}, error) {
+		record, found, err := guard.store.Get(ctx, request.RequestID)
+		if err == nil && found {
+			// Every caller compares its own fingerprint with the record, as coalesced callers
+			// may carry other payloads than the one running this closure
+			if record.Response != nil || record.conflicts(fingerprint) {
+				return record, nil
+			}
+			if guard.rejectReused {
+				return nil, ErrDuplicateRequest
+			}
+		}
+
+		// Mark the auction as started so a reuse after failure is recognized
+		sanitized := s.SanitizeRequest(request)
+		_ = guard.store.Put(ctx, request.RequestID, &idempotencyRecord{Request: sanitized, Fingerprint: fingerprint}, guard.window)
+
+		response, err := s.RunAuction(ctx, request)
+		if err != nil {
+			return &idempotencyRecord{Fingerprint: fingerprint}, err
+		}
+
+		// Debug output is per caller and never replayed
+		fresh = true
+		stored := *response
+		stored.Debug = nil
+		_ = guard.store.Put(ctx, request.RequestID, &idempotencyRecord{Request: sanitized, Response: &stored, Fingerprint: fingerprint}, guard.window)
+		return &idempotencyRecord{Response: response, Fingerprint: fingerprint}, nil
+	})
+	if record, ok := result.(*idempotencyRecord); ok && record.conflicts(fingerprint) {
+		return nil, false, guard.conflict(request.RequestID, record.Fingerprint, fingerprint)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Coalesced waiters never run the closure, so only the caller that ran a fresh auction sees
+	// fresh == true. The others get a copy without the debug output, which is the first caller's.
+	response := result.(*idempotencyRecord).Response
+	if !fresh && response.Debug != nil {
+		replayed := *response
+		replayed.Debug = nil
+		response = &replayed
+	}
+	return response, !fresh, nil
+}
@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8" // v8.11.5
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Lead velocity key layout and in-memory bound
+const (
+	ivtLeadKeyPrefix    = "rtb:ivt:lead:"
+	maxMemoryLeadCounts = 100000
+)
+
+// ivtHookTimeout bounds screening an auction, which may count the lead in Redis
+const ivtHookTimeout = time.Second
+
+// IVT errors. The ivt hook rejects auctions with ErrIVTRejected or ErrIVTShadowed, wrapped in
+// ErrHookRejected.
+var (
+	ErrIVTDisabled = errors.New("ivt filtering disabled")
+	ErrIVTRejected = errors.New("request rejected as invalid traffic")
+	ErrIVTShadowed = errors.New("request shadowed as invalid traffic")
+)
+
+// IVTSignals are the parts of a bid request the invalid traffic rules look at
+type IVTSignals struct {
+	UserAgent string
+	ClientIP  string
+	LeadID    string
+	Header    http.Header
+}
+
+// IVTVerdict is the outcome of screening a request. Action is empty for clean traffic and
+// otherwise the strictest action among the matched Rules.
+type IVTVerdict struct {
+	Action string
+	Rules  []string
+}
+
+// IVTBlocklists are the bot User-Agent substrings and blocked networks, replaceable at runtime
+type IVTBlocklists struct {
+	UserAgents []string `json:"user_agents"`
+	CIDRs      []string `json:"cidrs"`
+}
+
+// ivtLists are blocklists prepared for matching
+type ivtLists struct {
+	source     IVTBlocklists
+	userAgents []string
+	networks   []netip.Prefix
+}
+
+// newIVTLists lowercases User-Agent entries and parses networks
+func newIVTLists(lists IVTBlocklists) (*ivtLists, error) {
+	prepared := &ivtLists{source: lists, userAgents: make([]string, 0, len(lists.UserAgents)), networks: make([]netip.Prefix, 0, len(lists.CIDRs))}
+	for _, agent := range lists.UserAgents {
+		if agent = strings.ToLower(strings.TrimSpace(agent)); agent != "" {
+			prepared.userAgents = append(prepared.userAgents, agent)
+		}
+	}
+	for _, entry := range lists.CIDRs {
+		network, err := config.ParseIVTNetwork(entry)
+		if err != nil {
+			return nil, err
+		}
+		prepared.networks = append(prepared.networks, network)
+	}
+	return prepared, nil
+}
+
+// ivtFilter screens bid requests against the invalid traffic rules
+type ivtFilter struct {
+	config *config.IVTConfig
+	lists  atomic.Value // *ivtLists
+	leads  leadCounter
+}
+
+// newIVTFilter creates the filter when IVT filtering is enabled, otherwise nil
+func newIVTFilter(cfg *config.IVTConfig, client *redis.Client, clock utils.Clock) (*ivtFilter, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+
+	lists, err := newIVTLists(IVTBlocklists{UserAgents: cfg.BotUserAgents, CIDRs: cfg.BlockedCIDRs})
+	if err != nil {
+		return nil, err
+	}
+	filter := &ivtFilter{config: cfg}
+	filter.lists.Store(lists)
+	if cfg.MaxLeadRepeats > 0 {
+		if client != nil {
+			filter.leads = &redisLeadCounter{client: client}
+		} else {
+			filter.leads = &memoryLeadCounter{clock: clock, counts: make(map[string]*memoryLeadCount)}
+		}
+	}
+	return filter, nil
+}
+
+// screen checks every rule and returns the strictest action among those matched
+func (f *ivtFilter) screen(ctx context.Context, signals IVTSignals) IVTVerdict {
+	lists := f.lists.Load().(*ivtLists)
+	var verdict IVTVerdict
+
+	for _, name := range f.config.RequiredHeaders {
+		if signals.Header.Get(name) == "" {
+			verdict.Rules = append(verdict.Rules, config.IVTRuleMissingHeader)
+			break
+		}
+	}
+
+	if userAgent := strings.ToLower(signals.UserAgent); userAgent != "" {
+		for _, agent := range lists.userAgents {
+			if strings.Contains(userAgent, agent) {
+				verdict.Rules = append(verdict.Rules, config.IVTRuleUserAgent)
+				break
+			}
+		}
+	}
+
+	if addr, err := netip.ParseAddr(signals.ClientIP); err == nil {
+		addr = addr.Unmap()
+		for _, network := range lists.networks {
+			if network.Contains(addr) {
+				verdict.Rules = append(verdict.Rules, config.IVTRuleIPBlocklist)
+				break
+			}
+		}
+	}
+
+	// Counter failures let the request through rather than filtering on missing data
+	if f.leads != nil && signals.LeadID != "" {
+		if seen, err := f.leads.Add(ctx, signals.LeadID, f.config.LeadRepeatWindow); err == nil && seen > int64(f.config.MaxLeadRepeats) {
+			verdict.Rules = append(verdict.Rules, config.IVTRuleLeadVelocity)
+		}
+	}
+
+	for _, rule := range verdict.Rules {
+		if action := f.config.ActionFor(rule); ivtSeverity(action) > ivtSeverity(verdict.Action) {
+			verdict.Action = action
+		}
+	}
+	for _, rule := range verdict.Rules {
+		ivtFilteredTotal.WithLabelValues(rule, verdict.Action).Inc()
+	}
+	return verdict
+}
+
+// ivtSeverity orders actions so the strictest matched rule decides the outcome
+func ivtSeverity(action string) int {
+	switch action {
+	case config.IVTActionReject:
+		return 3
+	case config.IVTActionShadow:
+		return 2
+	case config.IVTActionFlag:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ScreenTraffic checks a bid request against the invalid traffic rules. Clean traffic, and all
+// traffic when IVT filtering is disabled, gets an empty verdict.
+func (s *AuctionService) ScreenTraffic(ctx context.Context, signals IVTSignals) IVTVerdict {
+	if s.ivt == nil {
+		return IVTVerdict{}
+	}
+	return s.ivt.screen(ctx, signals)
+}
+
+// screenAuction is the ivt hook. It screens auctions whose client identity carries the request's
+// headers and rejects those the rules reject or shadow.
+func (s *AuctionService) screenAuction(ctx context.Context, request *models.BidRequest) error {
+	client := models.AuctionFromContext(ctx).Client()
+	if client.Header == nil {
+		return nil
+	}
+	verdict := s.ScreenTraffic(ctx, IVTSignals{
+		UserAgent: client.UserAgent,
+		ClientIP:  client.IP,
+		LeadID:    request.LeadID,
+		Header:    client.Header,
+	})
+	switch verdict.Action {
+	case config.IVTActionReject:
+		return ErrIVTRejected
+	case config.IVTActionShadow:
+		return ErrIVTShadowed
+	default:
+		return nil
+	}
+}
+
+// IVTBlocklists returns the blocklists in use
+func (s *AuctionService) IVTBlocklists() (IVTBlocklists, error) {
+	if s.ivt == nil {
+		return IVTBlocklists{}, ErrIVTDisabled
+	}
+	return s.ivt.lists.Load().(*ivtLists).source, nil
+}
+
+// SetIVTBlocklists replaces the blocklists until the next update or restart. The lists are
+// replaced whole, so an invalid entry leaves the current lists in place.
+func (s *AuctionService) SetIVTBlocklists(lists IVTBlocklists) error {
+	if s.ivt == nil {
+		return ErrIVTDisabled
+	}
+	prepared, err := newIVTLists(lists)
+	if err != nil {
+		return err
+	}
+	s.ivt.lists.Store(prepared)
+	return nil
+}
+
+// leadCounter counts how often a lead was seen within a fixed window starting at its first sighting
+type leadCounter interface {
+	Add(ctx context.Context, leadID string, window time.Duration) (int64, error)
+}
+
+// redisLeadCounter shares lead counts across instances
+type redisLeadCounter struct {
+	client *redis.Client
+}
+
+func (r *redisLeadCounter) Add(ctx context.Context, leadID string, window time.Duration) (int64, error) {
+	key := ivtLeadKeyPrefix + leadID
+	seen, err := r.client.Incr(ctx, key).Result()
+	if err != nil {
+		return 0, err
+	}
+	if seen == 1 {
+		if err := r.client.Expire(ctx, key, window).Err(); err != nil {
+			return 0, err
+		}
+	}
+	return seen, nil
+}
+
+// memoryLeadCount is a lead's count in its current window
+type memoryLeadCount struct {
+	seen      int64
+	expiresAt time.Time
+}
+
+// memoryLeadCounter counts leads for a single instance when Redis is not configured
+type memoryLeadCounter struct {
+	clock  utils.Clock
+	mutex  sync.Mutex
+	counts map[string]*memoryLeadCount
+}
+
+func (m *memoryLeadCounter) Add(ctx context.Context, leadID string, window time.Duration) (int64, error) {
+	now := m.clock.Now()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	count, exists := m.counts[leadID]
+	if !exists || !now.Before(count.expiresAt) {
+		if len(m.counts) >= maxMemoryLeadCounts {
+			m.evict(now)
+		}
+		count = &memoryLeadCount{expiresAt: now.Add(window)}
+		m.counts[leadID] = count
+	}
+	count.seen++
+	return count.seen, nil
+}
+
+// evict drops expired counts, and then arbitrary ones if every count is still live
+func (m *memoryLeadCounter) evict(now time.Time) {
+	for leadID, count := range m.counts {
+		if !now.Before(count.expiresAt) {
+			delete(m.counts, leadID)
+		}
+	}
+	for leadID := range m.counts {
+		if len(m.counts) < maxMemoryLeadCounts {
+			return
+		}
+		delete(m.counts, leadID)
+	}
+}
@@ -0,0 +1,613 @@
+package services
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+)
+
+// Prometheus metrics for auction internals
+var (
+	partnerSkipsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_skips_total",
+			Help: "Total number of times a partner was skipped for an auction by reason",
+		},
+		[]string{"partner", "reason"},
+	)
+
+	scoringFallbacksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_scoring_fallbacks_total",
+			Help: "Total number of auctions ranked without model scores because the scorer failed",
+		},
+		[]string{"reason"},
+	)
+
+	consentRedactionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_consent_redactions_total",
+			Help: "Total number of partner requests sent with personal UserData withheld",
+		},
+		[]string{"partner"},
+	)
+
+	bidLossesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_bid_losses_total",
+			Help: "Total number of valid bids removed from winner selection by reason",
+		},
+		[]string{"partner", "reason"},
+	)
+
+	partnerWireBytes = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_wire_bytes_total",
+			Help: "Total bytes of gzip-encoded partner responses, compressed on the wire and decoded",
+		},
+		[]string{"partner", "stage"},
+	)
+
+	partnerPhaseDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rtb_partner_http_phase_seconds",
+			Help:    "Duration of partner HTTP call phases: dns, connect, tls, and first_byte from connection request",
+			Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5},
+		},
+		[]string{"partner", "phase"},
+	)
+
+	partnerConnectionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_connections_total",
+			Help: "Total number of connections obtained for partner calls, by whether an idle connection was reused",
+		},
+		[]string{"partner", "reused"},
+	)
+
+	partnerAttemptsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_attempts_total",
+			Help: "Total number of partner bid calls made, including retries",
+		},
+		[]string{"partner"},
+	)
+
+	partnerRetrySuccessesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_retry_successes_total",
+			Help: "Total number of partner calls that succeeded on a retry",
+		},
+		[]string{"partner"},
+	)
+
+	dealBidsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_deal_bids_total",
+			Help: "Total number of bids referencing a deal, by deal and whether the bid was accepted, won, or rejected and why",
+		},
+		[]string{"deal", "outcome"},
+	)
+
+	quorumFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_quorum_failures_total",
+			Help: "Total number of auctions with valid bids that were not sold because too few distinct partners bid",
+		},
+		[]string{"vertical"},
+	)
+
+	analyticsSeriesDroppedTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rtb_analytics_series_dropped_total",
+			Help: "Total number of auctions not added to a price analytics series because the series limit was reached",
+		},
+	)
+
+	partnerSLABreachTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_sla_breach_total",
+			Help: "Total number of hours in which a partner missed an SLA target, by partner and SLO",
+		},
+		[]string{"partner", "slo"},
+	)
+
+	partnerEndpointCallsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_endpoint_calls_total",
+			Help: "Total number of partner calls by endpoint and whether the endpoint responded healthily",
+		},
+		[]string{"partner", "endpoint", "outcome"},
+	)
+
+	partnerEndpointDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rtb_partner_endpoint_duration_seconds",
+			Help:    "Duration of partner calls by endpoint",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"partner", "endpoint"},
+	)
+
+	recordingsDroppedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_recordings_dropped_total",
+			Help: "Total number of sampled auction recordings not written, by reason",
+		},
+		[]string{"reason"},
+	)
+
+	estimatesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_estimates_total",
+			Help: "Total number of pre-bid estimates served, by source",
+		},
+		[]string{"source"},
+	)
+
+	reservationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_reservations_total",
+			Help: "Total number of bid reservations by outcome (reserved, confirmed, abandoned)",
+		},
+		[]string{"outcome"},
+	)
+
+	ivtFilteredTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_ivt_filtered_total",
+			Help: "Total number of bid requests matching an invalid traffic rule, by rule and the action taken",
+		},
+		[]string{"rule", "action"},
+	)
+
+	overridesActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rtb_overrides_active",
+			Help: "Number of admin overrides in effect that raise the floor or disable partners",
+		},
+		[]string{"kind"},
+	)
+
+	overrideFloor = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "rtb_override_floor",
+			Help: "Highest floor set by an admin override in effect, or 0 when none is",
+		},
+	)
+
+	overriddenAuctionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_overridden_auctions_total",
+			Help: "Total number of auctions run with an override in effect, by whether it came from an admin override or the request header",
+		},
+		[]string{"source"},
+	)
+
+	budgetOverrunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_auction_budget_overruns_total",
+			Help: "Total number of auctions whose collection, optimization, or serialization ran past its slice of the deadline",
+		},
+		[]string{"phase"},
+	)
+
+	noBidsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_no_bid_total",
+			Help: "Total number of partner calls answered with a no-bid, by partner and reason code",
+		},
+		[]string{"partner", "reason"},
+	)
+
+	negativeCacheLookupsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_negative_cache_lookups_total",
+			Help: "Total number of negative cache checks for a partner in an auction, by whether a cached no-bid skipped the partner",
+		},
+		[]string{"partner", "result"},
+	)
+
+	negativeCacheStoresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_negative_cache_stores_total",
+			Help: "Total number of explicit partner no-bids cached for a lead segment",
+		},
+		[]string{"partner"},
+	)
+
+	breakerStatesRestoredTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_breaker_states_restored_total",
+			Help: "Total number of partner breaker states restored from Redis on startup, by restored state",
+		},
+		[]string{"state"},
+	)
+
+	breakerSyncErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_breaker_state_sync_errors_total",
+			Help: "Total number of failures saving partner breaker state to Redis or restoring it",
+		},
+		[]string{"operation"},
+	)
+
+	experimentAuctionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_experiment_auctions_total",
+			Help: "Total number of auctions run in each variant of an experiment",
+		},
+		[]string{"experiment", "variant"},
+	)
+
+	experimentWinnersTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_experiment_winners_total",
+			Help: "Total number of winning bids sold in each variant of an experiment",
+		},
+		[]string{"experiment", "variant"},
+	)
+
+	experimentRevenueTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_experiment_revenue_total",
+			Help: "Total cost per lead of winning bids sold in each variant of an experiment",
+		},
+		[]string{"experiment", "variant"},
+	)
+
+	partnerCapturesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_captures_total",
+			Help: "Total number of partner calls captured for integration debugging",
+		},
+		[]string{"partner"},
+	)
+
+	partnerHandshakeFailuresTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_tls_handshake_failures_total",
+			Help: "Total number of partner calls failing the TLS handshake by reason: verify, rejected, protocol, or timeout",
+		},
+		[]string{"partner", "reason"},
+	)
+
+	partnerCoercionsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_response_coercions_total",
+			Help: "Total number of partner response fields lenient validation converted, by field and kind: string_number, number_string, string_list, unknown_field, or precision",
+		},
+		[]string{"partner", "field", "kind"},
+	)
+
+	partnerSchemaErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_schema_errors_total",
+			Help: "Total number of partner responses rejected by response validation, by field and reason",
+		},
+		[]string{"partner", "field", "reason"},
+	)
+
+	partnerResponsesTooLargeTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_responses_too_large_total",
+			Help: "Total number of partner responses rejected for exceeding the partner's response size limit",
+		},
+		[]string{"partner"},
+	)
+
+	partnerRateLimitsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_rate_limits_total",
+			Help: "Total number of partner rate limits backed off from, by whether the backoff came from Retry-After or the exponential fallback",
+		},
+		[]string{"partner", "source"},
+	)
+
+	partnerMirrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_mirrors_total",
+			Help: "Total number of bid requests mirrored to partner sandboxes, by result: success, failure, or dropped by the mirror QPS cap",
+		},
+		[]string{"partner", "result"},
+	)
+
+	partnerMirrorDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rtb_partner_mirror_duration_seconds",
+			Help:    "Duration of mirrored bid requests to partner sandboxes",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"partner"},
+	)
+
+	drainedBidsDropped = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_drained_partner_bids_dropped_total",
+			Help: "Total number of reserved bids dropped at confirmation because their partner finished draining",
+		},
+		[]string{"partner"},
+	)
+
+	budgetHoldsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_budget_holds_total",
+			Help: "Total number of partner daily budget hold operations by outcome: reserved, refused, committed, released, or error",
+		},
+		[]string{"partner", "outcome"},
+	)
+
+	budgetAlertsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_budget_alerts_total",
+			Help: "Total number of partner daily budget alert thresholds crossed, by threshold percentage",
+		},
+		[]string{"partner", "threshold"},
+	)
+
+	faultsActive = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rtb_faults_active",
+			Help: "Number of injected faults in effect by kind and partner, empty for Redis faults",
+		},
+		[]string{"kind", "partner"},
+	)
+
+	faultsInjectedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_faults_injected_total",
+			Help: "Total number of partner calls and Redis operations an injected fault applied to, by kind, partner, and fault ID",
+		},
+		[]string{"kind", "partner", "fault"},
+	)
+
+	regionFallbacksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_region_fallbacks_total",
+			Help: "Total number of auctions that called partners preferring other service regions because none preferring this one was eligible, by vertical",
+		},
+		[]string{"vertical"},
+	)
+
+	assetChecksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_asset_checks_total",
+			Help: "Total number of winning creative images checked by the asset verifier, by partner and result: ok, status, content_type, too_large, error, invalid_url, or dropped by the rate limit",
+		},
+		[]string{"partner", "result"},
+	)
+
+	partnerAssetsFlagged = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "rtb_partner_assets_flagged",
+			Help: "Whether a partner is flagged for a creative image failure rate at or above the asset verification threshold (1) or not (0)",
+		},
+		[]string{"partner"},
+	)
+
+	earlyTerminationSaved = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rtb_early_termination_saved_seconds",
+			Help:    "Most collection time saved by auctions ending bid collection early, up to the latest pending partner timeout or the collection deadline",
+			Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"vertical"},
+	)
+
+	auctionSeqsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_auction_seqs_total",
+			Help: "Auction sequence numbers assigned, by source: the shared counter or the ULID fallback",
+		},
+		[]string{"source"},
+	)
+
+	bidRuleMatches = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_bid_rule_matches_total",
+			Help: "Total number of bids matching a bid rule, by rule and action",
+		},
+		[]string{"rule", "action"},
+	)
+
+	bidExtValidationsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_bid_vertical_ext_total",
+			Help: "Total number of bids carrying a vertical extension, by outcome: valid, invalid, or unvalidated for verticals without a schema",
+		},
+		[]string{"partner", "vertical", "result"},
+	)
+
+	replayChecksTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_replay_checks_total",
+			Help: "Total number of replay protection checks by client and outcome: accepted, missing, skewed, replayed, unavailable, or failed_open",
+		},
+		[]string{"client", "outcome"},
+	)
+
+	hookDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "rtb_auction_hook_duration_seconds",
+			Help:    "Time spent running each auction hook by phase",
+			Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		},
+		[]string{"hook", "phase"},
+	)
+
+	hookRunsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_auction_hook_runs_total",
+			Help: "Total number of auction hook runs by phase and outcome: ok, rejected, timeout, or panic",
+		},
+		[]string{"hook", "phase", "outcome"},
+	)
+
+	partnersShadowedTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partners_shadowed_total",
+			Help: "Total number of partners moved into shadow mode for going without bids",
+		},
+		[]string{"partner"},
+	)
+
+	activitySyncErrorsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_activity_sync_errors_total",
+			Help: "Total number of failed partner activity syncs with Redis",
+		},
+	)
+
+	idempotencyConflictsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rtb_idempotency_conflicts_total",
+			Help: "Total number of requests rejected for reusing a request ID with a different payload",
+		},
+	)
+
+	partnerCallErrorsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_partner_call_errors_total",
+			Help: "Total number of partner calls that ended without an answer by reason",
+		},
+		[]string{"partner", "reason"},
+	)
+
+	collectionDrainTimeoutsTotal = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Name: "rtb_collection_drain_timeouts_total",
+			Help: "Total number of timed-out auctions whose cancelled partner calls did not all finish within the drain grace",
+		},
+	)
+
+	staleReadsTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_redis_stale_reads_total",
+			Help: "Total number of Redis-backed reads served from the in-process cache by cache and result: hit, stale, or miss",
+		},
+		[]string{"cache", "result"},
+	)
+
+	staleRefreshesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_redis_stale_refreshes_total",
+			Help: "Total number of background refreshes of cached Redis-backed reads by cache and result",
+		},
+		[]string{"cache", "result"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(partnerSkipsTotal)
+	prometheus.MustRegister(scoringFallbacksTotal)
+	prometheus.MustRegister(consentRedactionsTotal)
+	prometheus.MustRegister(bidLossesTotal)
+	prometheus.MustRegister(partnerWireBytes)
+	prometheus.MustRegister(partnerPhaseDuration)
+	prometheus.MustRegister(partnerConnectionsTotal)
+	prometheus.MustRegister(partnerAttemptsTotal)
+	prometheus.MustRegister(partnerRetrySuccessesTotal)
+	prometheus.MustRegister(partnerEndpointCallsTotal)
+	prometheus.MustRegister(partnerEndpointDuration)
+	prometheus.MustRegister(dealBidsTotal)
+	prometheus.MustRegister(quorumFailuresTotal)
+	prometheus.MustRegister(analyticsSeriesDroppedTotal)
+	prometheus.MustRegister(partnerSLABreachTotal)
+	prometheus.MustRegister(recordingsDroppedTotal)
+	prometheus.MustRegister(estimatesTotal)
+	prometheus.MustRegister(reservationsTotal)
+	prometheus.MustRegister(ivtFilteredTotal)
+	prometheus.MustRegister(overridesActive)
+	prometheus.MustRegister(overrideFloor)
+	prometheus.MustRegister(overriddenAuctionsTotal)
+	prometheus.MustRegister(budgetOverrunsTotal)
+	prometheus.MustRegister(noBidsTotal)
+	prometheus.MustRegister(negativeCacheLookupsTotal)
+	prometheus.MustRegister(negativeCacheStoresTotal)
+	prometheus.MustRegister(breakerStatesRestoredTotal)
+	prometheus.MustRegister(breakerSyncErrorsTotal)
+	prometheus.MustRegister(experimentAuctionsTotal)
+	prometheus.MustRegister(experimentWinnersTotal)
+	prometheus.MustRegister(experimentRevenueTotal)
+	prometheus.MustRegister(partnerCapturesTotal)
+	prometheus.MustRegister(partnerHandshakeFailuresTotal)
+	prometheus.MustRegister(partnerCoercionsTotal)
+	prometheus.MustRegister(partnerSchemaErrorsTotal)
+	prometheus.MustRegister(partnerResponsesTooLargeTotal)
+	prometheus.MustRegister(partnerRateLimitsTotal)
+	prometheus.MustRegister(partnerMirrorsTotal)
+	prometheus.MustRegister(partnerMirrorDuration)
+	prometheus.MustRegister(drainedBidsDropped)
+	prometheus.MustRegister(budgetHoldsTotal)
+	prometheus.MustRegister(budgetAlertsTotal)
+	prometheus.MustRegister(faultsActive)
+	prometheus.MustRegister(faultsInjectedTotal)
+	prometheus.MustRegister(regionFallbacksTotal)
+	prometheus.MustRegister(assetChecksTotal)
+	prometheus.MustRegister(partnerAssetsFlagged)
+	prometheus.MustRegister(earlyTerminationSaved)
+	prometheus.MustRegister(auctionSeqsTotal)
+	prometheus.MustRegister(bidRuleMatches)
+	prometheus.MustRegister(bidExtValidationsTotal)
+	prometheus.MustRegister(replayChecksTotal)
+	prometheus.MustRegister(hookDuration)
+	prometheus.MustRegister(hookRunsTotal)
+	prometheus.MustRegister(partnersShadowedTotal)
+	prometheus.MustRegister(activitySyncErrorsTotal)
+	prometheus.MustRegister(idempotencyConflictsTotal)
+	prometheus.MustRegister(partnerCallErrorsTotal)
+	prometheus.MustRegister(collectionDrainTimeoutsTotal)
+	prometheus.MustRegister(staleReadsTotal)
+	prometheus.MustRegister(staleRefreshesTotal)
+}
+
+// partnerMetrics holds the metric children updated on every partner call, resolved once per
+// partner so calls skip the label lookups
+type partnerMetrics struct {
+	partnerID      string
+	attempts       prometheus.Counter
+	newConnections prometheus.Counter
+	reused         prometheus.Counter
+	firstByte      prometheus.Observer
+	endpoints      sync.Map // endpoint URL -> *endpointMetrics
+}
+
+// endpointMetrics holds the metric children for one partner endpoint
+type endpointMetrics struct {
+	key       string // endpointKey, kept here so recording a call does not rebuild it
+	successes prometheus.Counter
+	failures  prometheus.Counter
+	duration  prometheus.Observer
+}
+
+// partnerMetricsCache maps partner IDs to their resolved metric children
+var partnerMetricsCache sync.Map
+
+// metricsFor returns the metric children for a partner
+func metricsFor(partnerID string) *partnerMetrics {
+	if metrics, exists := partnerMetricsCache.Load(partnerID); exists {
+		return metrics.(*partnerMetrics)
+	}
+	metrics, _ := partnerMetricsCache.LoadOrStore(partnerID, &partnerMetrics{
+		partnerID:      partnerID,
+		attempts:       partnerAttemptsTotal.WithLabelValues(partnerID),
+		newConnections: partnerConnectionsTotal.WithLabelValues(partnerID, "false"),
+		reused:         partnerConnectionsTotal.WithLabelValues(partnerID, "true"),
+		firstByte:      partnerPhaseDuration.WithLabelValues(partnerID, phaseFirstByte),
+	})
+	return metrics.(*partnerMetrics)
+}
+
+// endpoint returns the metric children for one of the partner's endpoints
+func (m *partnerMetrics) endpoint(url string) *endpointMetrics {
+	if metrics, exists := m.endpoints.Load(url); exists {
+		return metrics.(*endpointMetrics)
+	}
+	metrics, _ := m.endpoints.LoadOrStore(url, &endpointMetrics{
+		key:       endpointKey(m.partnerID, url),
+		successes: partnerEndpointCallsTotal.WithLabelValues(m.partnerID, url, endpointOutcomeSuccess),
+		failures:  partnerEndpointCallsTotal.WithLabelValues(m.partnerID, url, endpointOutcomeFailure),
+		duration:  partnerEndpointDuration.WithLabelValues(m.partnerID, url),
+	})
+	return metrics.(*endpointMetrics)
+}
@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Mirror results counted in rtb_partner_mirrors_total
+const (
+	mirrorResultSuccess = "success"
+	mirrorResultFailure = "failure"
+	mirrorResultDropped = "dropped"
+)
+
+// maxMirrorResponseBytes bounds how much of a mirrored response is drained so its connection can
+// be reused; the rest is discarded with the connection
+const maxMirrorResponseBytes = 64 << 10
+
+// partnerMirror sends copies of a partner's bid requests to its sandbox endpoint
+type partnerMirror struct {
+	partner *config.PartnerConfig
+	adapter PartnerAdapter
+	timeout time.Duration
+	limiter *partnerLimiter
+}
+
+// partnerMirrors sends mirrored requests on their own goroutines, which Close waits for
+type partnerMirrors struct {
+	mirrors map[string]*partnerMirror
+	wg      sync.WaitGroup
+}
+
+// newPartnerMirrors builds the mirrors of partners with a mirror endpoint, or none when mirroring
+// is disabled
+func newPartnerMirrors(cfg *config.Config, clock utils.Clock) (*partnerMirrors, error) {
+	mirrors := &partnerMirrors{mirrors: make(map[string]*partnerMirror)}
+	if cfg.MirroringDisabled {
+		return mirrors, nil
+	}
+	for partnerID, partner := range cfg.Partners {
+		if partner.Mirror == nil || !partner.Enabled {
+			continue
+		}
+		mirror, err := newPartnerMirror(partner, clock)
+		if err != nil {
+			return nil, fmt.Errorf("partner %s mirror: %w", partnerID, err)
+		}
+		mirrors.mirrors[partnerID] = mirror
+	}
+	return mirrors, nil
+}
+
+// newPartnerMirror builds a partner's mirror. Requests go to the mirror endpoint in the partner's
+// format and auth scheme, signed with the sandbox key in place of the partner's secret when the
+// mirror has one.
+func newPartnerMirror(partner *config.PartnerConfig, clock utils.Clock) (*partnerMirror, error) {
+	target := *partner
+	target.Endpoint = partner.Mirror.Endpoint
+	target.Endpoints = nil
+	if key := partner.Mirror.APIKey; key != "" {
+		target.APIKey = key
+		if partner.Auth != nil {
+			auth := *partner.Auth
+			switch auth.Type {
+			case config.AuthBearer, config.AuthHeader:
+				auth.Token = key
+			case config.AuthBasic:
+				auth.Password = key
+			case config.AuthHMAC:
+				auth.SigningKey = key
+			}
+			target.Auth = &auth
+		}
+	}
+
+	adapter, err := NewPartnerAdapter(&target)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := NewPartnerAuthenticator(&target, clock)
+	if err != nil {
+		return nil, err
+	}
+	qps := float64(partner.Mirror.MaxQPS)
+	return &partnerMirror{
+		partner: &target,
+		adapter: &authenticatedAdapter{PartnerAdapter: adapter, auth: auth},
+		timeout: partner.Mirror.RequestTimeout(),
+		limiter: &partnerLimiter{rate: qps, burst: qps, tokens: qps, lastFill: time.Now()},
+	}, nil
+}
+
+// Close waits for mirrored requests in flight, which end within their timeout
+func (m *partnerMirrors) Close() {
+	m.wg.Wait()
+}
+
+// mirrorPartnerRequest sends a copy of a live auction's request to the partner's sandbox without
+// waiting for it. The copy has its own timeout and never touches the auction, the partner's
+// stats, or its circuit breaker. Dry runs record the mirror instead, and replays skip it.
+func (s *AuctionService) mirrorPartnerRequest(ctx context.Context, partnerID string, partner *config.PartnerConfig, request *models.BidRequest) {
+	mirror, exists := s.mirrors.mirrors[partnerID]
+	if !exists || models.ReplayFromContext(ctx) != nil {
+		return
+	}
+	if dryRun := models.DryRunFromContext(ctx); dryRun != nil {
+		dryRun.Record(models.SideEffect{Type: models.SideEffectMirror, PartnerID: partnerID})
+		return
+	}
+	if !mirror.limiter.Allow(false) {
+		partnerMirrorsTotal.WithLabelValues(partnerID, mirrorResultDropped).Inc()
+		return
+	}
+
+	// Build the request now, while the auction still owns request
+	httpReq, err := buildPartnerRequest(models.AuctionFromContext(ctx), mirror.adapter, s.outboundRequest(partnerID, partner, request), mirror.partner)
+	if err != nil {
+		partnerMirrorsTotal.WithLabelValues(partnerID, mirrorResultFailure).Inc()
+		return
+	}
+
+	s.mirrors.wg.Add(1)
+	go func() {
+		defer s.mirrors.wg.Done()
+		mirrorCtx, cancel := context.WithTimeout(context.Background(), mirror.timeout)
+		defer cancel()
+
+		start := time.Now()
+		result := mirrorResultFailure
+		resp, err := s.clients.client(partnerID).Do(httpReq.WithContext(mirrorCtx))
+		if err == nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, maxMirrorResponseBytes))
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				result = mirrorResultSuccess
+			}
+		}
+		partnerMirrorDuration.WithLabelValues(partnerID).Observe(time.Since(start).Seconds())
+		partnerMirrorsTotal.WithLabelValues(partnerID, result).Inc()
+	}()
+}
@@ -0,0 +1,356 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8" // v8.11.5
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Negative cache key layout and in-memory bound
+const (
+	negativeCacheKeyPrefix      = "rtb:nobid:"
+	maxMemoryNegativeEntries    = 100000
+	negativeCacheFlushBatchSize = 500
+)
+
+// Negative cache lookup results
+const (
+	negativeCacheHit  = "hit"
+	negativeCacheMiss = "miss"
+)
+
+// ErrNegativeCacheDisabled is returned when the negative cache is flushed with negative caching disabled
+var ErrNegativeCacheDisabled = errors.New("negative caching disabled")
+
+// negativeCacheEffect describes a skipped negative cache entry
+type negativeCacheEffect struct {
+	Segment string        `json:"segment"`
+	TTL     time.Duration `json:"ttl"`
+}
+
+// negativeCache remembers explicit partner no-bids per lead segment so matching auctions skip
+// the partner until the entry expires
+type negativeCache struct {
+	config   *config.NegativeCacheConfig
+	partners map[string]bool // nil caches every partner
+	store    negativeStore
+	stale    *staleCache[bool] // nil unless lookups are served from memory
+}
+
+// newNegativeCache creates the cache when negative caching is enabled, otherwise nil. With Redis
+// stale reads on, lookups are served from memory and refreshed from Redis in the background.
+func newNegativeCache(cfg *config.Config, client *redis.Client, clock utils.Clock) *negativeCache {
+	if cfg.NegativeCache == nil || !cfg.NegativeCache.Enabled {
+		return nil
+	}
+
+	cache := &negativeCache{config: cfg.NegativeCache}
+	if len(cfg.NegativeCache.Partners) > 0 {
+		cache.partners = make(map[string]bool, len(cfg.NegativeCache.Partners))
+		for _, partnerID := range cfg.NegativeCache.Partners {
+			cache.partners[partnerID] = true
+		}
+	}
+	if client != nil {
+		cache.store = &redisNegativeStore{client: client}
+		cache.stale = newStaleCache("negative_cache", cfg.Redis, clock, cache.store.Cached)
+	} else {
+		cache.store = &memoryNegativeStore{clock: clock, entries: make(map[string]time.Time)}
+	}
+	return cache
+}
+
+// covers reports whether a partner's no-bids are cached
+func (n *negativeCache) covers(partnerID string) bool {
+	return n.partners == nil || n.partners[partnerID]
+}
+
+// segment returns the request's lead segment. Requests missing a segment field have no segment,
+// so they are neither skipped nor cached.
+func (n *negativeCache) segment(request *models.BidRequest) (string, bool) {
+	values := make([]string, 0, len(n.config.SegmentFields))
+	for _, field := range n.config.SegmentFields {
+		value := segmentValue(field, request)
+		if value == "" {
+			return "", false
+		}
+		values = append(values, url.QueryEscape(value))
+	}
+	return strings.Join(values, "|"), true
+}
+
+// segmentValue returns the request's value for a segment field, or empty when it is missing
+func segmentValue(field string, request *models.BidRequest) string {
+	switch field {
+	case config.SegmentFieldVertical:
+		return request.Vertical
+	case config.SegmentFieldCountry:
+		if request.Geo != nil {
+			return request.Geo.Country
+		}
+	case config.SegmentFieldRegion:
+		if request.Geo != nil {
+			return request.Geo.Region
+		}
+	case config.SegmentFieldZip:
+		if request.Geo != nil {
+			return request.Geo.Zip
+		}
+	case config.SegmentFieldDeviceType:
+		if request.Device != nil {
+			return request.Device.Type
+		}
+	default:
+		if value, exists := request.UserData[strings.TrimPrefix(field, config.SegmentFieldUserDataPrefix)]; exists && value != nil {
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
+
+// negativeCacheKey returns the key for a partner's no-bid in a segment. Partner IDs are escaped
+// so one partner's key prefix never matches another's keys.
+func negativeCacheKey(partnerID, segment string) string {
+	return negativeCachePartnerPrefix(partnerID) + segment
+}
+
+// negativeCachePartnerPrefix returns the prefix of every key for a partner
+func negativeCachePartnerPrefix(partnerID string) string {
+	return negativeCacheKeyPrefix + url.QueryEscape(partnerID) + ":"
+}
+
+// negativeLookup is the outcome of checking an auction's partners against the negative cache
+type negativeLookup struct {
+	segment string
+	cached  map[string]bool // partners checked, and whether each has a live no-bid entry
+}
+
+// lookupNoBids checks every covered partner for a live no-bid in the request's segment with one
+// store call, or from memory with stale reads on. Replayed auctions use the recorded responses, so
+// they skip the cache entirely, and a store failure or a lookup with no live cached value treats
+// the partner as uncached.
+func (s *AuctionService) lookupNoBids(ctx context.Context, request *models.BidRequest, partners map[string]*config.PartnerConfig) negativeLookup {
+	if s.negatives == nil || models.ReplayFromContext(ctx) != nil {
+		return negativeLookup{}
+	}
+	segment, ok := s.negatives.segment(request)
+	if !ok {
+		return negativeLookup{}
+	}
+
+	partnerIDs := make([]string, 0, len(partners))
+	keys := make([]string, 0, len(partners))
+	for partnerID, partner := range partners {
+		if partner.Enabled && s.negatives.covers(partnerID) {
+			partnerIDs = append(partnerIDs, partnerID)
+			keys = append(keys, negativeCacheKey(partnerID, segment))
+		}
+	}
+	lookup := negativeLookup{segment: segment, cached: make(map[string]bool, len(partnerIDs))}
+	if len(keys) == 0 {
+		return lookup
+	}
+	var found []bool
+	var err error
+	if s.negatives.stale != nil {
+		found = s.negatives.stale.get(keys, false)
+	} else {
+		found, err = s.negatives.store.Cached(ctx, keys)
+	}
+	for i, partnerID := range partnerIDs {
+		lookup.cached[partnerID] = err == nil && found[i]
+	}
+	return lookup
+}
+
+// skipsPartner reports whether a partner has a live no-bid for the auction's segment and counts
+// the lookup toward the partner's hit rate
+func (l negativeLookup) skipsPartner(partnerID string) bool {
+	cached, checked := l.cached[partnerID]
+	if !checked {
+		return false
+	}
+	if cached {
+		negativeCacheLookupsTotal.WithLabelValues(partnerID, negativeCacheHit).Inc()
+	} else {
+		negativeCacheLookupsTotal.WithLabelValues(partnerID, negativeCacheMiss).Inc()
+	}
+	return cached
+}
+
+// cacheNoBid stores a partner's explicit no-bid for the auction's segment, or records it on a
+// dry run. Store failures only cost the skip on later auctions. With stale reads on, this
+// instance skips the partner from memory at once and the no-bid is written to Redis off the
+// auction path.
+func (s *AuctionService) cacheNoBid(ctx context.Context, partnerID, segment string) {
+	if s.negatives == nil || segment == "" || !s.negatives.covers(partnerID) {
+		return
+	}
+	if dryRun := models.DryRunFromContext(ctx); dryRun != nil {
+		dryRun.Record(models.SideEffect{Type: models.SideEffectNegativeCache, PartnerID: partnerID,
+			Detail: negativeCacheEffect{Segment: segment, TTL: s.negatives.config.TTL}})
+		return
+	}
+	key := negativeCacheKey(partnerID, segment)
+	if s.negatives.stale == nil {
+		s.negatives.storeNoBid(ctx, partnerID, key)
+		return
+	}
+	s.negatives.stale.put(key, true)
+	go func() {
+		storeCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), s.negatives.stale.timeout)
+		defer cancel()
+		s.negatives.storeNoBid(storeCtx, partnerID, key)
+	}()
+}
+
+// storeNoBid writes a partner's no-bid under key for the cache TTL
+func (n *negativeCache) storeNoBid(ctx context.Context, partnerID, key string) {
+	if err := n.store.Store(ctx, key, n.config.TTL); err == nil {
+		negativeCacheStoresTotal.WithLabelValues(partnerID).Inc()
+	}
+}
+
+// FlushNegativeCache removes cached no-bids for one partner, or for every partner when partnerID
+// is empty, and returns how many entries were removed. Other instances serving stale reads keep
+// skipping flushed partners until their next refresh.
+func (s *AuctionService) FlushNegativeCache(ctx context.Context, partnerID string) (int, error) {
+	if s.negatives == nil {
+		return 0, ErrNegativeCacheDisabled
+	}
+	prefix := negativeCacheKeyPrefix
+	if partnerID != "" {
+		prefix = negativeCachePartnerPrefix(partnerID)
+	}
+	s.negatives.stale.forget(prefix)
+	return s.negatives.store.Flush(ctx, prefix)
+}
+
+// negativeStore holds no-bid entries until their TTL passes
+type negativeStore interface {
+	Cached(ctx context.Context, keys []string) ([]bool, error)
+	Store(ctx context.Context, key string, ttl time.Duration) error
+	Flush(ctx context.Context, prefix string) (int, error)
+}
+
+// redisNegativeStore shares no-bid entries across instances
+type redisNegativeStore struct {
+	client *redis.Client
+}
+
+func (r *redisNegativeStore) Cached(ctx context.Context, keys []string) ([]bool, error) {
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
+	}
+	found := make([]bool, len(values))
+	for i, value := range values {
+		found[i] = value != nil
+	}
+	return found, nil
+}
+
+func (r *redisNegativeStore) Store(ctx context.Context, key string, ttl time.Duration) error {
+	return r.client.Set(ctx, key, "1", ttl).Err()
+}
+
+func (r *redisNegativeStore) Flush(ctx context.Context, prefix string) (int, error) {
+	removed := 0
+	iter := r.client.Scan(ctx, 0, prefix+"*", negativeCacheFlushBatchSize).Iterator()
+	batch := make([]string, 0, negativeCacheFlushBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		deleted, err := r.client.Del(ctx, batch...).Result()
+		removed += int(deleted)
+		batch = batch[:0]
+		return err
+	}
+	for iter.Next(ctx) {
+		batch = append(batch, iter.Val())
+		if len(batch) == negativeCacheFlushBatchSize {
+			if err := flush(); err != nil {
+				return removed, err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return removed, err
+	}
+	return removed, flush()
+}
+
+// memoryNegativeStore keeps no-bid entries for a single instance when Redis is not configured
+type memoryNegativeStore struct {
+	clock   utils.Clock
+	mutex   sync.Mutex
+	entries map[string]time.Time // key -> expiry
+}
+
+func (m *memoryNegativeStore) Cached(ctx context.Context, keys []string) ([]bool, error) {
+	now := m.clock.Now()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	found := make([]bool, len(keys))
+	for i, key := range keys {
+		expiresAt, exists := m.entries[key]
+		found[i] = exists && now.Before(expiresAt)
+	}
+	return found, nil
+}
+
+func (m *memoryNegativeStore) Store(ctx context.Context, key string, ttl time.Duration) error {
+	now := m.clock.Now()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.entries[key]; !exists && len(m.entries) >= maxMemoryNegativeEntries {
+		m.evict(now)
+	}
+	m.entries[key] = now.Add(ttl)
+	return nil
+}
+
+func (m *memoryNegativeStore) Flush(ctx context.Context, prefix string) (int, error) {
+	now := m.clock.Now()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	removed := 0
+	for key, expiresAt := range m.entries {
+		if strings.HasPrefix(key, prefix) {
+			if now.Before(expiresAt) {
+				removed++
+			}
+			delete(m.entries, key)
+		}
+	}
+	return removed, nil
+}
+
+// evict drops expired entries, and then arbitrary ones if every entry is still live
+func (m *memoryNegativeStore) evict(now time.Time) {
+	for key, expiresAt := range m.entries {
+		if !now.Before(expiresAt) {
+			delete(m.entries, key)
+		}
+	}
+	for key := range m.entries {
+		if len(m.entries) < maxMemoryNegativeEntries {
+			return
+		}
+		delete(m.entries, key)
+	}
+}
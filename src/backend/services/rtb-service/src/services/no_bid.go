@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// Partner-supplied no-bid reason codes with a known meaning. Other codes are passed through to
+// debug output but counted as models.ReasonNoBidOther.
+const (
+	NoBidReasonBelowFloor       = models.ReasonBelowFloor
+	NoBidReasonNoDemand         = models.ReasonNoDemand
+	NoBidReasonOutsideTargeting = models.ReasonOutsideTargeting
+	NoBidReasonBudgetExhausted  = models.ReasonBudgetExhausted
+	NoBidReasonDuplicateLead    = models.ReasonDuplicateLead
+)
+
+// noBidDescriptions explain the known reason codes in debug output
+var noBidDescriptions = map[models.Reason]string{
+	NoBidReasonBelowFloor:       "below their floor",
+	NoBidReasonNoDemand:         "no matching demand",
+	NoBidReasonOutsideTargeting: "lead outside their targeting",
+	NoBidReasonBudgetExhausted:  "budget exhausted",
+	NoBidReasonDuplicateLead:    "lead already seen",
+}
+
+// ErrNoBid is matched by every NoBidResponse
+var ErrNoBid = errors.New("partner declined to bid")
+
+// NoBidResponse reports that a partner answered and declined to bid, with the reason code it gave,
+// if any. Adapters return it from ParseResponse so a decline is never mistaken for a failure: it
+// is not retried, does not count against the circuit breaker, and feeds the negative cache.
+type NoBidResponse struct {
+	Reason string
+}
+
+// Error implements the error interface
+func (n *NoBidResponse) Error() string {
+	if n.Reason == "" {
+		return ErrNoBid.Error()
+	}
+	return ErrNoBid.Error() + ": " + n.Reason
+}
+
+// Is matches ErrNoBid
+func (n *NoBidResponse) Is(target error) bool {
+	return target == ErrNoBid
+}
+
+// Description explains the decline for debug output, e.g. "no-bid: below their floor"
+func (n *NoBidResponse) Description() string {
+	if description, known := noBidDescriptions[models.Reason(n.Reason)]; known {
+		return "no-bid: " + description
+	}
+	if n.Reason == "" {
+		return "no-bid"
+	}
+	return "no-bid: " + n.Reason
+}
+
+// noBidFrom returns the NoBidResponse in err's chain, or nil. A nil err returns early, so calls
+// that bid do not allocate the errors.As target.
+func noBidFrom(err error) *NoBidResponse {
+	if err == nil {
+		return nil
+	}
+	var noBid *NoBidResponse
+	if !errors.As(err, &noBid) {
+		return nil
+	}
+	return noBid
+}
+
+// metricReason returns the reason label for the no-bid metric, bounded to the known codes
+func (n *NoBidResponse) metricReason() models.Reason {
+	reason := models.Reason(strings.TrimSpace(n.Reason))
+	if reason == "" {
+		return models.ReasonNoBidUnspecified
+	}
+	if _, known := noBidDescriptions[reason]; known {
+		return reason
+	}
+	return models.ReasonNoBidOther
+}
+
+// recordNoBid counts a partner's decline in the partner stats and the no-bid metric
+func (s *AuctionService) recordNoBid(partnerID string, noBid *NoBidResponse) {
+	s.stats.RecordNoBid(partnerID)
+	noBidsTotal.WithLabelValues(partnerID, string(noBid.metricReason())).Inc()
+}
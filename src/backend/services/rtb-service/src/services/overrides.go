@@ -0,0 +1,268 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap" // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// MaxOverrideTTL bounds how long an admin override can stay in effect
+const MaxOverrideTTL = 24 * time.Hour
+
+// Override kinds and sources reported by the override metrics
+const (
+	overrideKindFloor    = "floor"
+	overrideKindPartners = "partners"
+
+	overrideSourceAdmin  = "admin"
+	overrideSourceHeader = "header"
+)
+
+// Override errors
+var (
+	ErrOverrideTTL      = fmt.Errorf("override ttl must be positive and at most %v", MaxOverrideTTL)
+	ErrOverrideReason   = errors.New("override reason and requester are required")
+	ErrOverrideEmpty    = errors.New("override must set a floor or disable at least one partner")
+	ErrOverrideFloor    = errors.New("override floor must be a non-negative price")
+	ErrOverridePartner  = errors.New("override disables an unknown partner")
+	ErrOverrideNotFound = errors.New("override not found")
+)
+
+// Override temporarily raises the floor or disables partners for every auction, ahead of the
+// configured values, until it expires or is lifted
+type Override struct {
+	ID               string    `json:"id"`
+	Floor            float64   `json:"floor,omitempty"`
+	DisabledPartners []string  `json:"disabled_partners,omitempty"`
+	Reason           string    `json:"reason"`
+	RequestedBy      string    `json:"requested_by"`
+	AdminKey         string    `json:"admin_key"` // fingerprint of the admin key that set it
+	CreatedAt        time.Time `json:"created_at"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+// overrides holds the admin overrides in effect
+type overrides struct {
+	clock   utils.Clock
+	logger  *zap.Logger
+	mutex   sync.Mutex
+	entries map[string]*Override
+	present atomic.Bool // whether entries is non-empty, so auctions without overrides skip the mutex
+}
+
+// newOverrides creates an empty override set
+func newOverrides(clock utils.Clock) *overrides {
+	return &overrides{clock: clock, logger: zap.NewNop(), entries: make(map[string]*Override)}
+}
+
+// active returns the overrides in effect, dropping expired ones. Callers hold the mutex.
+func (o *overrides) active() []*Override {
+	now := o.clock.Now()
+	active := make([]*Override, 0, len(o.entries))
+	for id, override := range o.entries {
+		if !now.Before(override.ExpiresAt) {
+			delete(o.entries, id)
+			o.logger.Info("override expired", overrideFields(override)...)
+			continue
+		}
+		active = append(active, override)
+	}
+	o.present.Store(len(o.entries) > 0)
+	sort.Slice(active, func(i, j int) bool { return active[i].CreatedAt.Before(active[j].CreatedAt) })
+	updateOverrideGauges(active)
+	return active
+}
+
+// updateOverrideGauges reports how many overrides of each kind are in effect and the floor they set
+func updateOverrideGauges(active []*Override) {
+	floors, partners, floor := 0, 0, 0.0
+	for _, override := range active {
+		if override.Floor > 0 {
+			floors++
+			floor = math.Max(floor, override.Floor)
+		}
+		if len(override.DisabledPartners) > 0 {
+			partners++
+		}
+	}
+	overridesActive.WithLabelValues(overrideKindFloor).Set(float64(floors))
+	overridesActive.WithLabelValues(overrideKindPartners).Set(float64(partners))
+	overrideFloor.Set(floor)
+}
+
+// overrideFields describes an override for the log
+func overrideFields(override *Override) []zap.Field {
+	return []zap.Field{
+		zap.String("id", override.ID),
+		zap.Float64("floor", override.Floor),
+		zap.Strings("disabled_partners", override.DisabledPartners),
+		zap.String("reason", override.Reason),
+		zap.String("requested_by", override.RequestedBy),
+		zap.String("admin_key", override.AdminKey),
+		zap.Time("expires_at", override.ExpiresAt),
+	}
+}
+
+// AddOverride puts an override into effect for ttl and returns it with its ID and expiry set.
+// Reason and RequestedBy are required so every override can be traced to who set it and why.
+func (s *AuctionService) AddOverride(override Override, ttl time.Duration) (*Override, error) {
+	if ttl <= 0 || ttl > MaxOverrideTTL {
+		return nil, ErrOverrideTTL
+	}
+	if override.Reason == "" || override.RequestedBy == "" {
+		return nil, ErrOverrideReason
+	}
+	if err := s.validateOverride(override.Floor, override.DisabledPartners); err != nil {
+		return nil, err
+	}
+
+	override.ID = newOverrideID()
+	override.CreatedAt = s.clock.Now().UTC()
+	override.ExpiresAt = override.CreatedAt.Add(ttl)
+
+	s.overrides.mutex.Lock()
+	defer s.overrides.mutex.Unlock()
+	s.overrides.entries[override.ID] = &override
+	s.overrides.logger.Warn("override applied", overrideFields(&override)...)
+	s.overrides.active()
+
+	// Expire the override promptly even when no auction runs to notice
+	time.AfterFunc(ttl, func() {
+		s.overrides.mutex.Lock()
+		defer s.overrides.mutex.Unlock()
+		s.overrides.active()
+	})
+	result := override
+	return &result, nil
+}
+
+// validateOverride checks an override's floor and disabled partners, for admin and header overrides alike
+func (s *AuctionService) validateOverride(floor float64, disabledPartners []string) error {
+	if floor < 0 || math.IsNaN(floor) || math.IsInf(floor, 0) {
+		return ErrOverrideFloor
+	}
+	if floor == 0 && len(disabledPartners) == 0 {
+		return ErrOverrideEmpty
+	}
+	partners := s.partnerSnapshot()
+	for _, partnerID := range disabledPartners {
+		if _, exists := partners[partnerID]; !exists {
+			return fmt.Errorf("%w: %s", ErrOverridePartner, partnerID)
+		}
+	}
+	return nil
+}
+
+// RemoveOverride lifts an override before it expires
+func (s *AuctionService) RemoveOverride(id, requestedBy string) error {
+	s.overrides.mutex.Lock()
+	defer s.overrides.mutex.Unlock()
+	override, exists := s.overrides.entries[id]
+	if !exists || !s.clock.Now().Before(override.ExpiresAt) {
+		return ErrOverrideNotFound
+	}
+	delete(s.overrides.entries, id)
+	s.overrides.logger.Warn("override lifted", append(overrideFields(override), zap.String("lifted_by", requestedBy))...)
+	s.overrides.active()
+	return nil
+}
+
+// Overrides returns the overrides in effect, oldest first
+func (s *AuctionService) Overrides() []Override {
+	s.overrides.mutex.Lock()
+	defer s.overrides.mutex.Unlock()
+	active := s.overrides.active()
+	list := make([]Override, len(active))
+	for i, override := range active {
+		list[i] = *override
+	}
+	return list
+}
+
+// ValidateRequestOverride checks a per-request override before its auction runs
+func (s *AuctionService) ValidateRequestOverride(override *models.Override) error {
+	return s.validateOverride(override.Floor, override.DisabledPartners)
+}
+
+// auctionOverride is the floor and disabled partners one auction applies, merged from the admin
+// overrides in effect and the request's own override
+type auctionOverride struct {
+	floor    float64
+	disabled map[string]bool
+	source   string // overrideSourceHeader when the request carried its own override
+}
+
+// overrideFor merges the overrides that apply to an auction: the highest floor and every disabled
+// partner. Replayed auctions reproduce their recording, so only their own override applies.
+func (s *AuctionService) overrideFor(ctx context.Context) auctionOverride {
+	var merged auctionOverride
+	add := func(floor float64, disabledPartners []string, source string) {
+		merged.floor = math.Max(merged.floor, floor)
+		for _, partnerID := range disabledPartners {
+			if merged.disabled == nil {
+				merged.disabled = make(map[string]bool)
+			}
+			merged.disabled[partnerID] = true
+		}
+		if merged.source != overrideSourceHeader {
+			merged.source = source
+		}
+	}
+
+	if s.overrides.present.Load() && models.ReplayFromContext(ctx) == nil {
+		s.overrides.mutex.Lock()
+		for _, override := range s.overrides.active() {
+			add(override.Floor, override.DisabledPartners, overrideSourceAdmin)
+		}
+		s.overrides.mutex.Unlock()
+	}
+	if override := models.OverrideFromContext(ctx); override != nil {
+		add(override.Floor, override.DisabledPartners, overrideSourceHeader)
+	}
+	if merged.source != "" {
+		overriddenAuctionsTotal.WithLabelValues(merged.source).Inc()
+	}
+	return merged
+}
+
+// disables reports whether the override keeps a partner out of the auction
+func (o auctionOverride) disables(partnerID string) bool {
+	return o.disabled[partnerID]
+}
+
+// applyFloor drops bids priced below the override floor, recording each as a loss
+func (o auctionOverride) applyFloor(partnerID string, bids []*models.Bid, reasons *models.ReasonCollector) []*models.Bid {
+	if o.floor <= 0 {
+		return bids
+	}
+	kept := bids[:0]
+	for _, bid := range bids {
+		if bid.CPL() < o.floor {
+			reasons.Loss(partnerID, bid.ID, models.ReasonOverrideFloor)
+			continue
+		}
+		kept = append(kept, bid)
+	}
+	return kept
+}
+
+// newOverrideID returns a random override ID
+func newOverrideID() string {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8" // v8.11.5
+	"go.uber.org/zap"              // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// activityKeyPrefix prefixes the Redis hash holding each partner's activity
+const activityKeyPrefix = "rtb:partner_activity:"
+
+// activityKeyTTL is how long a partner's activity outlives its last sync, so partners removed from
+// config eventually leave no keys behind
+const activityKeyTTL = 400 * 24 * time.Hour
+
+// Partner activity fields, stored in Redis as Unix milliseconds
+const (
+	activityTrackedSince = "tracked_since"
+	activityLastCall     = "last_call"
+	activityLastBid      = "last_bid"
+	activityLastWin      = "last_win"
+	activityShadowedAt   = "shadowed_at"
+	activityRestoredAt   = "restored_at"
+)
+
+// mergeActivityScript merges an instance's view of a partner's activity into the stored one and
+// returns the result: tracked_since keeps the earliest time and every other field the latest, so
+// instances syncing in any order agree. ARGV[1] is the key TTL in milliseconds, followed by field
+// and value pairs.
+var mergeActivityScript = redis.NewScript(`
+for i = 2, #ARGV, 2 do
+	local field, value = ARGV[i], tonumber(ARGV[i + 1])
+	local current = tonumber(redis.call('HGET', KEYS[1], field))
+	if value > 0 and (not current or (field == 'tracked_since' and value < current) or (field ~= 'tracked_since' and value > current)) then
+		redis.call('HSET', KEYS[1], field, ARGV[i + 1])
+	end
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return redis.call('HGETALL', KEYS[1])
+`)
+
+// activityKey returns the key of a partner's activity. Partner IDs are escaped so one partner's key
+// never collides with another's.
+func activityKey(partnerID string) string {
+	return activityKeyPrefix + url.QueryEscape(partnerID)
+}
+
+// partnerActivity is when a partner was last called, sent a valid bid, and won, since when its
+// activity has been tracked, and when it was last shadowed and restored. Zero times never happened.
+type partnerActivity struct {
+	trackedSince time.Time
+	lastCall     time.Time
+	lastBid      time.Time
+	lastWin      time.Time
+	shadowedAt   time.Time
+	restoredAt   time.Time
+}
+
+// shadowed reports whether the partner was shadowed and not restored since
+func (a partnerActivity) shadowed() bool {
+	return !a.shadowedAt.IsZero() && a.shadowedAt.After(a.restoredAt)
+}
+
+// idleSince returns when the partner's current stretch without a valid bid started: its last bid,
+// or when tracking started or it was last restored from shadow mode, whichever is latest
+func (a partnerActivity) idleSince() time.Time {
+	since := a.trackedSince
+	for _, t := range []time.Time{a.lastBid, a.restoredAt} {
+		if t.After(since) {
+			since = t
+		}
+	}
+	return since
+}
+
+// idleFor reports whether last, the time of a call, bid, or win, is at least limit ago. A partner
+// only counts as idle once it has been tracked for the whole stretch.
+func (a partnerActivity) idleFor(last time.Time, limit time.Duration, now time.Time) bool {
+	return now.Sub(a.trackedSince) >= limit && now.Sub(last) >= limit
+}
+
+// fields returns the activity's non-zero times keyed by their Redis field
+func (a partnerActivity) fields() map[string]time.Time {
+	fields := map[string]time.Time{
+		activityTrackedSince: a.trackedSince,
+		activityLastCall:     a.lastCall,
+		activityLastBid:      a.lastBid,
+		activityLastWin:      a.lastWin,
+		activityShadowedAt:   a.shadowedAt,
+		activityRestoredAt:   a.restoredAt,
+	}
+	for field, t := range fields {
+		if t.IsZero() {
+			delete(fields, field)
+		}
+	}
+	return fields
+}
+
+// set sets the time of a Redis field
+func (a *partnerActivity) set(field string, t time.Time) {
+	switch field {
+	case activityTrackedSince:
+		a.trackedSince = t
+	case activityLastCall:
+		a.lastCall = t
+	case activityLastBid:
+		a.lastBid = t
+	case activityLastWin:
+		a.lastWin = t
+	case activityShadowedAt:
+		a.shadowedAt = t
+	case activityRestoredAt:
+		a.restoredAt = t
+	}
+}
+
+// partnerActivities tracks each configured partner's activity. It is kept in memory and, when
+// Redis is configured, merged with the copy in Redis every sync interval, so it survives restarts
+// and every instance sees the others' calls, bids, wins, and shadowing.
+type partnerActivities struct {
+	mutex    sync.Mutex
+	records  map[string]*partnerActivity
+	client   *redis.Client
+	clock    utils.Clock
+	timeout  time.Duration
+	interval time.Duration
+	stop     chan struct{}
+	done     chan struct{}
+	once     sync.Once
+	logger   *zap.Logger
+}
+
+// newPartnerActivities starts tracking the configured partners from now
+func newPartnerActivities(cfg *config.Config, client *redis.Client, clock utils.Clock) *partnerActivities {
+	activities := &partnerActivities{
+		records:  make(map[string]*partnerActivity, len(cfg.Partners)),
+		client:   client,
+		clock:    clock,
+		timeout:  time.Second,
+		interval: cfg.Hygiene.ActivitySyncInterval(),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		logger:   zap.NewNop(),
+	}
+	if client != nil && cfg.Redis.Timeout > 0 {
+		activities.timeout = cfg.Redis.Timeout
+	}
+	now := clock.Now()
+	for partnerID := range cfg.Partners {
+		activities.records[partnerID] = &partnerActivity{trackedSince: now}
+	}
+	return activities
+}
+
+// record updates a partner's activity under the mutex, ignoring partners that are not configured
+func (a *partnerActivities) record(partnerID string, update func(activity *partnerActivity, now time.Time)) {
+	now := a.clock.Now()
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if activity, exists := a.records[partnerID]; exists {
+		update(activity, now)
+	}
+}
+
+// recordCall records a call to a partner, and a valid bid when it sent one
+func (a *partnerActivities) recordCall(partnerID string, bid bool) {
+	a.record(partnerID, func(activity *partnerActivity, now time.Time) {
+		activity.lastCall = now
+		if bid {
+			activity.lastBid = now
+		}
+	})
+}
+
+// recordWin records a win by a partner
+func (a *partnerActivities) recordWin(partnerID string) {
+	a.record(partnerID, func(activity *partnerActivity, now time.Time) {
+		activity.lastWin = now
+	})
+}
+
+// get returns a partner's activity and whether the partner is tracked
+func (a *partnerActivities) get(partnerID string) (partnerActivity, bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	activity, exists := a.records[partnerID]
+	if !exists {
+		return partnerActivity{}, false
+	}
+	return *activity, true
+}
+
+// shadowed reports whether a partner is in shadow mode
+func (a *partnerActivities) shadowed(partnerID string) bool {
+	activity, _ := a.get(partnerID)
+	return activity.shadowed()
+}
+
+// shadow moves a partner into shadow mode unless it already is, returning its activity and whether
+// it was moved
+func (a *partnerActivities) shadow(partnerID string) (partnerActivity, bool) {
+	var shadowed partnerActivity
+	moved := false
+	a.record(partnerID, func(activity *partnerActivity, now time.Time) {
+		if !activity.shadowed() {
+			activity.shadowedAt = now
+			moved = true
+		}
+		shadowed = *activity
+	})
+	return shadowed, moved
+}
+
+// restore takes a partner out of shadow mode, returning whether it was shadowed
+func (a *partnerActivities) restore(partnerID string) bool {
+	restored := false
+	a.record(partnerID, func(activity *partnerActivity, now time.Time) {
+		if activity.shadowed() {
+			activity.restoredAt = now
+			restored = true
+		}
+	})
+	return restored
+}
+
+// sync merges every partner's activity with the copy in Redis in one round trip and keeps the
+// merged result. Without Redis, or when Redis cannot be reached, activity stays as it is.
+func (a *partnerActivities) sync() {
+	if a.client == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout)
+	defer cancel()
+
+	a.mutex.Lock()
+	partnerIDs := make([]string, 0, len(a.records))
+	args := make([][]interface{}, 0, len(a.records))
+	for partnerID, activity := range a.records {
+		partnerArgs := []interface{}{activityKeyTTL.Milliseconds()}
+		for field, t := range activity.fields() {
+			partnerArgs = append(partnerArgs, field, t.UnixMilli())
+		}
+		partnerIDs = append(partnerIDs, partnerID)
+		args = append(args, partnerArgs)
+	}
+	a.mutex.Unlock()
+
+	pipe := a.client.Pipeline()
+	results := make([]*redis.Cmd, len(partnerIDs))
+	for i, partnerID := range partnerIDs {
+		results[i] = mergeActivityScript.Eval(ctx, pipe, []string{activityKey(partnerID)}, args[i]...)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		activitySyncErrorsTotal.Inc()
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	for i, partnerID := range partnerIDs {
+		values, err := results[i].StringSlice()
+		if err != nil {
+			activitySyncErrorsTotal.Inc()
+			continue
+		}
+		activity := a.records[partnerID]
+		for j := 0; j+1 < len(values); j += 2 {
+			millis, err := strconv.ParseInt(values[j+1], 10, 64)
+			if err != nil {
+				continue
+			}
+			// Activity recorded while the sync was in flight is newer than the stored copy
+			if merged := time.UnixMilli(millis).UTC(); values[j] == activityTrackedSince || merged.After(activity.fields()[values[j]]) {
+				activity.set(values[j], merged)
+			}
+		}
+	}
+}
+
+// start syncs activity now, so a restarted instance picks up where it left off, and then every
+// sync interval. With check set, it also syncs and then calls check every checkInterval, so check
+// sees every instance's latest activity. Without Redis or check there is nothing to run.
+func (a *partnerActivities) start(checkInterval time.Duration, check func()) {
+	if a.client == nil && check == nil {
+		close(a.done)
+		return
+	}
+	a.sync()
+	go func() {
+		defer close(a.done)
+		var syncs, checks <-chan time.Time
+		if a.client != nil {
+			ticker := time.NewTicker(a.interval)
+			defer ticker.Stop()
+			syncs = ticker.C
+		}
+		if check != nil {
+			ticker := time.NewTicker(checkInterval)
+			defer ticker.Stop()
+			checks = ticker.C
+		}
+		for {
+			select {
+			case <-a.stop:
+				a.sync()
+				return
+			case <-syncs:
+				a.sync()
+			case <-checks:
+				a.sync()
+				check()
+			}
+		}
+	}()
+}
+
+// Close stops syncing after a final sync of every partner's activity
+func (a *partnerActivities) Close() error {
+	a.once.Do(func() {
+		close(a.stop)
+		<-a.done
+	})
+	return nil
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Sources of a partner's backoff length
+const (
+	backoffSourceRetryAfter  = "retry_after"
+	backoffSourceExponential = "exponential"
+)
+
+// maxRetryAfterSeconds bounds a parsed Retry-After before it is capped to the configured maximum
+const maxRetryAfterSeconds = 24 * 60 * 60
+
+// RateLimitedError reports a partner answering 429, or 503 with a Retry-After header. RetryAfter
+// is the partner's hint, zero when it sent none or one that could not be parsed.
+type RateLimitedError struct {
+	Status     int
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *RateLimitedError) Error() string {
+	if e.RetryAfter <= 0 {
+		return fmt.Sprintf("rate limited with status %d", e.Status)
+	}
+	return fmt.Sprintf("rate limited with status %d, retry after %v", e.Status, e.RetryAfter)
+}
+
+// Unwrap returns the status error, so a rate limit matches ErrUnexpectedStatus like other statuses
+func (e *RateLimitedError) Unwrap() error {
+	return &StatusError{Status: e.Status}
+}
+
+// rateLimitFrom returns the rate limit a partner response signals, or nil. A 429 is always a rate
+// limit; a 503 only when it carries Retry-After, since without one it is an ordinary outage.
+func rateLimitFrom(resp *http.Response, now time.Time) *RateLimitedError {
+	header := resp.Header.Get("Retry-After")
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+	case resp.StatusCode == http.StatusServiceUnavailable && header != "":
+	default:
+		return nil
+	}
+	return &RateLimitedError{Status: resp.StatusCode, RetryAfter: parseRetryAfter(header, now)}
+}
+
+// parseRetryAfter parses a Retry-After header given in seconds or as an HTTP date, returning zero
+// for a missing, malformed, or past value
+func parseRetryAfter(header string, now time.Time) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.ParseInt(header, 10, 64); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		if seconds > maxRetryAfterSeconds {
+			seconds = maxRetryAfterSeconds
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(header); err == nil && date.After(now) {
+		return date.Sub(now)
+	}
+	return 0
+}
+
+// partnerBackoff is one partner's rate limit backoff
+type partnerBackoff struct {
+	until       time.Time
+	consecutive int
+}
+
+// partnerBackoffs keeps partners that rate-limited us out of auctions until their backoff passes.
+// The first call after a backoff is the probe: a success clears the backoff, and another rate
+// limit starts a new one, doubling the fallback length when the partner gives no Retry-After.
+type partnerBackoffs struct {
+	settings config.RateLimitBackoffConfig
+	clock    utils.Clock
+	mutex    sync.Mutex
+	partners map[string]*partnerBackoff
+}
+
+// newPartnerBackoffs creates the backoff set from configuration, applying defaults
+func newPartnerBackoffs(cfg *config.RateLimitBackoffConfig, clock utils.Clock) *partnerBackoffs {
+	return &partnerBackoffs{
+		settings: cfg.Settings(),
+		clock:    clock,
+		partners: make(map[string]*partnerBackoff),
+	}
+}
+
+// Active reports whether a partner is backing off
+func (b *partnerBackoffs) Active(partnerID string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	backoff, exists := b.partners[partnerID]
+	return exists && b.clock.Now().Before(backoff.until)
+}
+
+// RecordRateLimit starts a partner's backoff, honoring its Retry-After hint up to the maximum,
+// and returns where the backoff length came from
+func (b *partnerBackoffs) RecordRateLimit(partnerID string, limited *RateLimitedError) string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	backoff, exists := b.partners[partnerID]
+	if !exists {
+		backoff = &partnerBackoff{}
+		b.partners[partnerID] = backoff
+	}
+	backoff.consecutive++
+
+	delay, source := limited.RetryAfter, backoffSourceRetryAfter
+	if delay <= 0 {
+		delay, source = b.settings.Base, backoffSourceExponential
+		for i := 1; i < backoff.consecutive && delay < b.settings.Max; i++ {
+			delay *= 2
+		}
+	}
+	if delay > b.settings.Max {
+		delay = b.settings.Max
+	}
+	backoff.until = b.clock.Now().Add(delay)
+	return source
+}
+
+// RecordSuccess clears a partner's backoff once it answers without rate limiting us
+func (b *partnerBackoffs) RecordSuccess(partnerID string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	delete(b.partners, partnerID)
+}
+
+// Status returns when a partner's latest backoff ends and how many consecutive rate limits it
+// has sent, or zero values when it has answered since its last rate limit
+func (b *partnerBackoffs) Status(partnerID string) (time.Time, int) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	backoff, exists := b.partners[partnerID]
+	if !exists {
+		return time.Time{}, 0
+	}
+	return backoff.until, backoff.consecutive
+}
@@ -0,0 +1,647 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8" // v8.11.5
+	"go.uber.org/zap"              // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+	"github.com/yourdomain/rtb-service/src/webhooks"
+)
+
+// Budget key layout. A day's keys outlive it so holds taken just before midnight still settle.
+const (
+	budgetKeyPrefix = "rtb:budget:"
+	budgetKeyTTL    = 48 * time.Hour
+)
+
+// budgetUnits is the number of budget units per currency unit. Budgets are counted in integer
+// units so concurrent charges add up exactly.
+const budgetUnits = 1e6
+
+// Budget hold outcomes reported by the hold metric
+const (
+	budgetHoldReserved  = "reserved"
+	budgetHoldRefused   = "refused"
+	budgetHoldCommitted = "committed"
+	budgetHoldReleased  = "released"
+	budgetHoldError     = "error"
+)
+
+// ErrNoBudget is returned for the budget of a partner without a daily budget
+var ErrNoBudget = errors.New("partner has no daily budget")
+
+// BudgetStatus is a partner's daily budget for the current UTC day, what its wins were charged,
+// and what running auctions hold against it
+type BudgetStatus struct {
+	PartnerID string  `json:"partner_id"`
+	Day       string  `json:"day"`
+	Budget    float64 `json:"budget"`
+	Spent     float64 `json:"spent"`
+	Held      float64 `json:"held"`
+	// Alerts are the alert thresholds, in percent of the budget, the day's spend has crossed
+	Alerts []float64 `json:"alerts,omitempty"`
+}
+
+// BudgetAlertEvent is the data of a partner.budget_alert webhook: a partner's spend for the day
+// crossed Threshold percent of its daily budget
+type BudgetAlertEvent struct {
+	PartnerID string    `json:"partner_id"`
+	Day       string    `json:"day"`
+	Threshold float64   `json:"threshold"`
+	Budget    float64   `json:"budget"`
+	Spent     float64   `json:"spent"`
+	CrossedAt time.Time `json:"crossed_at"`
+}
+
+// budgetSpendEffect describes a skipped budget charge
+type budgetSpendEffect struct {
+	Amount float64 `json:"amount"`
+}
+
+// partnerBudgets keeps partners within their daily budgets. An auction holds the most it could
+// charge a partner before calling it, and settles the hold when it ends: committed at the clearing
+// price when the partner wins, released otherwise. A hold is only granted while the day's spend
+// plus every live hold stays within budget, so concurrent auctions cannot overspend, and holds
+// expire on their own when the instance holding them dies. A commit that takes a partner's spend
+// past one of its alert thresholds calls alert once per threshold and day.
+type partnerBudgets struct {
+	store       budgetStore
+	clock       utils.Clock
+	holdTTL     time.Duration
+	timeout     time.Duration
+	maxBidPrice float64
+	logger      *zap.Logger
+	alert       func(ctx context.Context, alert BudgetAlertEvent)
+	spent       *staleCache[int64] // nil unless spent budgets are checked from memory
+}
+
+// newPartnerBudgets shares budgets through Redis when it is configured, otherwise keeps them per
+// instance. With Redis stale reads on, each budget's spend is also cached in memory, so a partner
+// whose budget is spent is refused without a Redis round trip.
+func newPartnerBudgets(cfg *config.Config, client *redis.Client, clock utils.Clock) *partnerBudgets {
+	budgets := &partnerBudgets{
+		clock:       clock,
+		holdTTL:     cfg.Budgets.HoldExpiry(),
+		timeout:     time.Second,
+		maxBidPrice: cfg.MaxBidPrice,
+		logger:      zap.NewNop(),
+	}
+	if client != nil {
+		budgets.store = &redisBudgetStore{client: client}
+		if cfg.Redis.Timeout > 0 {
+			budgets.timeout = cfg.Redis.Timeout
+		}
+		budgets.spent = newStaleCache("budget", cfg.Redis, clock, budgets.loadSpent)
+	} else {
+		budgets.store = &memoryBudgetStore{entries: make(map[string]*memoryBudget)}
+	}
+	return budgets
+}
+
+// loadSpent reads the spend of each budget key for the stale read cache
+func (b *partnerBudgets) loadSpent(ctx context.Context, keys []string) ([]int64, error) {
+	now := b.clock.Now()
+	spent := make([]int64, len(keys))
+	for i, key := range keys {
+		usage, err := b.store.Usage(ctx, key, now)
+		if err != nil {
+			return nil, err
+		}
+		spent[i] = usage.spent
+	}
+	return spent, nil
+}
+
+// budgetKey returns the key of a partner's budget for a day. Partner IDs are escaped so one
+// partner's key never collides with another's.
+func budgetKey(partnerID, day string) string {
+	return budgetKeyPrefix + url.QueryEscape(partnerID) + ":" + day
+}
+
+// budgetDay returns the UTC day budgets are counted in
+func budgetDay(now time.Time) string {
+	return now.UTC().Format("2006-01-02")
+}
+
+// toBudgetUnits converts a price to budget units
+func toBudgetUnits(price float64) int64 {
+	return int64(math.Round(price * budgetUnits))
+}
+
+// fromBudgetUnits converts budget units to a price
+func fromBudgetUnits(units int64) float64 {
+	return float64(units) / budgetUnits
+}
+
+// settleContext bounds a budget store call that must run even after the auction's deadline
+func (b *partnerBudgets) settleContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.WithoutCancel(ctx), b.timeout)
+}
+
+// budgetHold is an auction's hold on one partner's budget, with the partner's budget and alert
+// thresholds as they were when it was taken
+type budgetHold struct {
+	token  string
+	amount int64
+	budget float64
+	alerts []budgetThreshold
+}
+
+// budgetThreshold is a budget alert threshold in percent of the budget and in budget units
+type budgetThreshold struct {
+	percent float64
+	units   int64
+}
+
+// budgetThresholds returns a partner's budget alert thresholds
+func budgetThresholds(partner *config.PartnerConfig) []budgetThreshold {
+	if len(partner.BudgetAlertThresholds) == 0 {
+		return nil
+	}
+	thresholds := make([]budgetThreshold, 0, len(partner.BudgetAlertThresholds))
+	for _, percent := range partner.BudgetAlertThresholds {
+		thresholds = append(thresholds, budgetThreshold{percent: percent, units: toBudgetUnits(partner.DailyBudget * percent / 100)})
+	}
+	return thresholds
+}
+
+// budgetCommit is the outcome of committing a hold: whether it was charged, the day's spend after
+// the charge, and the alert thresholds, in percent, the charge crossed first
+type budgetCommit struct {
+	committed bool
+	spent     int64
+	crossed   []float64
+}
+
+// budgetHolds are the holds one auction takes on partner budgets, by partner. Partners are held
+// one at a time before they are called and settled after winner selection, so no lock is needed.
+// Dry runs take no holds and record the charges they would have made instead.
+type budgetHolds struct {
+	budgets *partnerBudgets
+	day     string
+	key     func(partnerID string) string
+	dryRun  *models.DryRun
+	holds   map[string]budgetHold
+}
+
+// newHolds starts an auction's budget holds. Replayed auctions charge nothing, so they get none.
+func (b *partnerBudgets) newHolds(ctx context.Context) *budgetHolds {
+	if b == nil || models.ReplayFromContext(ctx) != nil {
+		return nil
+	}
+	day := budgetDay(b.clock.Now())
+	return &budgetHolds{
+		budgets: b,
+		day:     day,
+		key:     func(partnerID string) string { return budgetKey(partnerID, day) },
+		dryRun:  models.DryRunFromContext(ctx),
+		holds:   make(map[string]budgetHold),
+	}
+}
+
+// reserve holds the most the auction could charge a partner against its daily budget, returning
+// the skip reason when the budget cannot cover it. Partners without a budget are always allowed.
+// Budgets that cannot be checked are treated as spent, since overspend cannot be undone.
+func (h *budgetHolds) reserve(ctx context.Context, partnerID string, partner *config.PartnerConfig) (models.Reason, bool) {
+	if h == nil || partner.DailyBudget <= 0 {
+		return "", true
+	}
+	hold := budgetHold{amount: toBudgetUnits(partner.BudgetHold(h.budgets.maxBidPrice)), budget: partner.DailyBudget, alerts: budgetThresholds(partner)}
+	if h.dryRun != nil {
+		h.holds[partnerID] = hold
+		return "", true
+	}
+
+	// A day's spend never falls, so a cached spend that already covers the budget refuses the hold
+	// without a round trip. Otherwise the hold is taken as usual.
+	if h.budgets.spent != nil && h.budgets.spent.get([]string{h.key(partnerID)}, 0)[0] >= toBudgetUnits(partner.DailyBudget) {
+		budgetHoldsTotal.WithLabelValues(partnerID, budgetHoldRefused).Inc()
+		return models.ReasonBudgetSpent, false
+	}
+
+	hold.token = newReservationToken()
+	now := h.budgets.clock.Now()
+	reserved, err := h.budgets.store.Reserve(ctx, h.key(partnerID), hold.token, hold.amount, toBudgetUnits(partner.DailyBudget), now, now.Add(h.budgets.holdTTL))
+	switch {
+	case err != nil:
+		budgetHoldsTotal.WithLabelValues(partnerID, budgetHoldError).Inc()
+		return models.ReasonBudgetUnavailable, false
+	case !reserved:
+		budgetHoldsTotal.WithLabelValues(partnerID, budgetHoldRefused).Inc()
+		return models.ReasonBudgetSpent, false
+	}
+	budgetHoldsTotal.WithLabelValues(partnerID, budgetHoldReserved).Inc()
+	h.holds[partnerID] = hold
+	return "", true
+}
+
+// empty reports whether the auction holds no partner budgets
+func (h *budgetHolds) empty() bool {
+	return h == nil || len(h.holds) == 0
+}
+
+// filter drops bids priced above their partner's hold, which their win could not be charged against
+func (h *budgetHolds) filter(ctx context.Context, bids []*models.Bid) []*models.Bid {
+	if h == nil || len(h.holds) == 0 {
+		return bids
+	}
+	kept := make([]*models.Bid, 0, len(bids))
+	for _, bid := range bids {
+		if hold, exists := h.holds[bid.PartnerID]; exists && toBudgetUnits(bid.CPL()) > hold.amount {
+			models.ReasonsFromContext(ctx).Loss(bid.PartnerID, bid.ID, models.ReasonBudgetHold)
+			continue
+		}
+		kept = append(kept, bid)
+	}
+	return kept
+}
+
+// commit charges winners to their partners' budgets at their clearing price, alerting the
+// thresholds each charge crosses. Winners whose hold expired or could not be committed lose with
+// the budget_hold reason.
+func (h *budgetHolds) commit(ctx context.Context, winners []*models.Bid) []*models.Bid {
+	if h == nil || len(h.holds) == 0 {
+		return winners
+	}
+	settleCtx, cancel := h.budgets.settleContext(ctx)
+	defer cancel()
+
+	kept := make([]*models.Bid, 0, len(winners))
+	for _, bid := range winners {
+		hold, exists := h.holds[bid.PartnerID]
+		if !exists {
+			kept = append(kept, bid)
+			continue
+		}
+		delete(h.holds, bid.PartnerID)
+		if h.dryRun != nil {
+			h.dryRun.Record(models.SideEffect{Type: models.SideEffectBudgetSpend, PartnerID: bid.PartnerID, Detail: budgetSpendEffect{Amount: bid.CPL()}})
+			kept = append(kept, bid)
+			continue
+		}
+
+		now := h.budgets.clock.Now()
+		commit, err := h.budgets.store.Commit(settleCtx, h.key(bid.PartnerID), hold.token, toBudgetUnits(bid.CPL()), now, hold.alerts)
+		switch {
+		case err != nil:
+			budgetHoldsTotal.WithLabelValues(bid.PartnerID, budgetHoldError).Inc()
+		case !commit.committed:
+			budgetHoldsTotal.WithLabelValues(bid.PartnerID, budgetHoldRefused).Inc()
+		default:
+			budgetHoldsTotal.WithLabelValues(bid.PartnerID, budgetHoldCommitted).Inc()
+			h.budgets.spent.put(h.key(bid.PartnerID), commit.spent)
+			for _, threshold := range commit.crossed {
+				h.budgets.alertCrossed(ctx, BudgetAlertEvent{
+					PartnerID: bid.PartnerID,
+					Day:       h.day,
+					Threshold: threshold,
+					Budget:    hold.budget,
+					Spent:     fromBudgetUnits(commit.spent),
+					CrossedAt: now.UTC(),
+				})
+			}
+			kept = append(kept, bid)
+			continue
+		}
+		models.ReasonsFromContext(ctx).Loss(bid.PartnerID, bid.ID, models.ReasonBudgetHold)
+	}
+	return kept
+}
+
+// alertCrossed logs and counts a crossed budget alert threshold and passes it to the alert hook
+func (b *partnerBudgets) alertCrossed(ctx context.Context, alert BudgetAlertEvent) {
+	threshold := strconv.FormatFloat(alert.Threshold, 'f', -1, 64)
+	budgetAlertsTotal.WithLabelValues(alert.PartnerID, threshold).Inc()
+	b.logger.Warn("partner budget alert threshold crossed",
+		zap.String("partner", alert.PartnerID),
+		zap.String("day", alert.Day),
+		zap.Float64("threshold", alert.Threshold),
+		zap.Float64("budget", alert.Budget),
+		zap.Float64("spent", alert.Spent),
+	)
+	if b.alert != nil {
+		b.alert(ctx, alert)
+	}
+}
+
+// sendBudgetAlert sends a crossed budget alert threshold as a partner.budget_alert webhook
+func (s *AuctionService) sendBudgetAlert(ctx context.Context, alert BudgetAlertEvent) {
+	threshold := strconv.FormatFloat(alert.Threshold, 'f', -1, 64)
+	s.dispatchWebhook(ctx, webhooks.Event{
+		ID:        webhookEventID(config.WebhookEventPartnerBudgetAlert, alert.PartnerID, alert.Day, threshold),
+		Type:      config.WebhookEventPartnerBudgetAlert,
+		CreatedAt: alert.CrossedAt,
+		Data:      alert,
+	})
+}
+
+// release frees every hold the auction did not commit. Holds that fail to release expire on their own.
+func (h *budgetHolds) release(ctx context.Context) {
+	if h == nil || len(h.holds) == 0 || h.dryRun != nil {
+		return
+	}
+	settleCtx, cancel := h.budgets.settleContext(ctx)
+	defer cancel()
+	for partnerID, hold := range h.holds {
+		if err := h.budgets.store.Release(settleCtx, h.key(partnerID), hold.token); err != nil {
+			budgetHoldsTotal.WithLabelValues(partnerID, budgetHoldError).Inc()
+		} else {
+			budgetHoldsTotal.WithLabelValues(partnerID, budgetHoldReleased).Inc()
+		}
+		delete(h.holds, partnerID)
+	}
+}
+
+// PartnerBudget returns a partner's budget for the current UTC day
+func (s *AuctionService) PartnerBudget(ctx context.Context, partnerID string) (*BudgetStatus, error) {
+	partner, exists := s.partnerSnapshot()[partnerID]
+	if !exists {
+		return nil, ErrUnknownPartner
+	}
+	if partner.DailyBudget <= 0 {
+		return nil, ErrNoBudget
+	}
+	now := s.clock.Now()
+	day := budgetDay(now)
+	usage, err := s.budgets.store.Usage(ctx, budgetKey(partnerID, day), now)
+	if err != nil {
+		return nil, err
+	}
+	sort.Float64s(usage.alerted)
+	return &BudgetStatus{
+		PartnerID: partnerID,
+		Day:       day,
+		Budget:    partner.DailyBudget,
+		Spent:     fromBudgetUnits(usage.spent),
+		Held:      fromBudgetUnits(usage.held),
+		Alerts:    usage.alerted,
+	}, nil
+}
+
+// budgetUsage is a budget's spend, its live holds, and the alert thresholds, in percent, its
+// spend has crossed
+type budgetUsage struct {
+	spent   int64
+	held    int64
+	alerted []float64
+}
+
+// budgetStore keeps each partner's daily spend, live holds, and crossed alert thresholds, in
+// budget units
+type budgetStore interface {
+	// Reserve adds a hold unless spend plus live holds would exceed budget
+	Reserve(ctx context.Context, key, token string, amount, budget int64, now, expiresAt time.Time) (bool, error)
+	// Commit replaces a live hold with a charge of at most the held amount, and marks the alert
+	// thresholds the spend reached that were not marked yet, returning them as crossed
+	Commit(ctx context.Context, key, token string, amount int64, now time.Time, alerts []budgetThreshold) (budgetCommit, error)
+	Release(ctx context.Context, key, token string) error
+	Usage(ctx context.Context, key string, now time.Time) (budgetUsage, error)
+}
+
+// reserveBudgetScript drops expired holds and adds a hold if the budget covers it.
+// KEYS: spend, hold expiries, hold amounts; ARGV: now in milliseconds, budget, amount, token,
+// expiry in milliseconds, key TTL in milliseconds.
+var reserveBudgetScript = redis.NewScript(`
+for _, token in ipairs(redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])) do
+	redis.call('HDEL', KEYS[3], token)
+end
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', ARGV[1])
+local held = 0
+for _, amount in ipairs(redis.call('HVALS', KEYS[3])) do held = held + tonumber(amount) end
+local spent = tonumber(redis.call('GET', KEYS[1]) or '0')
+if spent + held + tonumber(ARGV[3]) > tonumber(ARGV[2]) then return 0 end
+redis.call('HSET', KEYS[3], ARGV[4], ARGV[3])
+redis.call('ZADD', KEYS[2], ARGV[5], ARGV[4])
+redis.call('PEXPIRE', KEYS[2], ARGV[6])
+redis.call('PEXPIRE', KEYS[3], ARGV[6])
+return 1
+`)
+
+// commitBudgetScript removes a hold and, if it was live and covers the amount, charges the amount
+// and adds each alert threshold the spend reached to the set of crossed thresholds, returning
+// those it added. KEYS: spend, hold expiries, hold amounts, crossed alerts; ARGV: token, amount,
+// now in milliseconds, key TTL in milliseconds, then each threshold's percent and units.
+var commitBudgetScript = redis.NewScript(`
+local held = redis.call('HGET', KEYS[3], ARGV[1])
+local expires = redis.call('ZSCORE', KEYS[2], ARGV[1])
+redis.call('HDEL', KEYS[3], ARGV[1])
+redis.call('ZREM', KEYS[2], ARGV[1])
+if not held or not expires or tonumber(expires) <= tonumber(ARGV[3]) then return {0} end
+if tonumber(ARGV[2]) > tonumber(held) then return {0} end
+local spent = redis.call('INCRBY', KEYS[1], ARGV[2])
+redis.call('PEXPIRE', KEYS[1], ARGV[4])
+local crossed = {}
+for i = 5, #ARGV, 2 do
+	if spent >= tonumber(ARGV[i + 1]) and redis.call('SADD', KEYS[4], ARGV[i]) == 1 then
+		crossed[#crossed + 1] = ARGV[i]
+	end
+end
+if #crossed > 0 then redis.call('PEXPIRE', KEYS[4], ARGV[4]) end
+return {1, spent, crossed}
+`)
+
+// budgetUsageScript returns the spend, the sum of live holds, and the crossed alert thresholds.
+// KEYS: spend, hold expiries, hold amounts, crossed alerts; ARGV: now in milliseconds.
+var budgetUsageScript = redis.NewScript(`
+local held = 0
+for _, token in ipairs(redis.call('ZRANGEBYSCORE', KEYS[2], '(' .. ARGV[1], '+inf')) do
+	held = held + tonumber(redis.call('HGET', KEYS[3], token) or '0')
+end
+return {tonumber(redis.call('GET', KEYS[1]) or '0'), held, redis.call('SMEMBERS', KEYS[4])}
+`)
+
+// redisBudgetStore shares budgets across instances: a counter of the day's spend, each hold's
+// amount in a hash indexed by expiry in a sorted set, and the set of alert thresholds crossed.
+// The crossed set expires with the spend, so a restart never alerts a threshold again.
+type redisBudgetStore struct {
+	client *redis.Client
+}
+
+// keys returns the spend, hold expiry, hold amount, and crossed alert keys of a budget
+func (r *redisBudgetStore) keys(key string) []string {
+	return []string{key + ":spent", key + ":expiries", key + ":holds", key + ":alerts"}
+}
+
+// parseBudgetAlerts parses the crossed alert thresholds a budget script returned
+func parseBudgetAlerts(reply interface{}) ([]float64, error) {
+	members, _ := reply.([]interface{})
+	alerts := make([]float64, 0, len(members))
+	for _, member := range members {
+		text, _ := member.(string)
+		percent, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid budget alert threshold %q: %w", text, err)
+		}
+		alerts = append(alerts, percent)
+	}
+	return alerts, nil
+}
+
+func (r *redisBudgetStore) Reserve(ctx context.Context, key, token string, amount, budget int64, now, expiresAt time.Time) (bool, error) {
+	reserved, err := reserveBudgetScript.Run(ctx, r.client, r.keys(key),
+		now.UnixMilli(), budget, amount, token, expiresAt.UnixMilli(), budgetKeyTTL.Milliseconds()).Int()
+	return reserved == 1, err
+}
+
+func (r *redisBudgetStore) Commit(ctx context.Context, key, token string, amount int64, now time.Time, alerts []budgetThreshold) (budgetCommit, error) {
+	args := []interface{}{token, amount, now.UnixMilli(), budgetKeyTTL.Milliseconds()}
+	for _, alert := range alerts {
+		args = append(args, strconv.FormatFloat(alert.percent, 'f', -1, 64), alert.units)
+	}
+	values, err := commitBudgetScript.Run(ctx, r.client, r.keys(key), args...).Slice()
+	if err != nil || len(values) < 3 || values[0] != int64(1) {
+		return budgetCommit{}, err
+	}
+	spent, _ := values[1].(int64)
+	crossed, err := parseBudgetAlerts(values[2])
+	if err != nil {
+		// The charge stands; only the alerts are lost
+		return budgetCommit{committed: true, spent: spent}, nil
+	}
+	return budgetCommit{committed: true, spent: spent, crossed: crossed}, nil
+}
+
+func (r *redisBudgetStore) Release(ctx context.Context, key, token string) error {
+	keys := r.keys(key)
+	pipe := r.client.TxPipeline()
+	pipe.HDel(ctx, keys[2], token)
+	pipe.ZRem(ctx, keys[1], token)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisBudgetStore) Usage(ctx context.Context, key string, now time.Time) (budgetUsage, error) {
+	values, err := budgetUsageScript.Run(ctx, r.client, r.keys(key), now.UnixMilli()).Slice()
+	if err != nil {
+		return budgetUsage{}, err
+	}
+	if len(values) < 3 {
+		return budgetUsage{}, fmt.Errorf("unexpected budget usage reply: %v", values)
+	}
+	spent, _ := values[0].(int64)
+	held, _ := values[1].(int64)
+	alerted, err := parseBudgetAlerts(values[2])
+	if err != nil {
+		return budgetUsage{}, err
+	}
+	return budgetUsage{spent: spent, held: held, alerted: alerted}, nil
+}
+
+// memoryBudget is one partner's budget for one day
+type memoryBudget struct {
+	spent    int64
+	holds    map[string]memoryBudgetHold
+	alerted  map[float64]bool
+	lastUsed time.Time
+}
+
+// memoryBudgetHold is a held amount and when the hold expires
+type memoryBudgetHold struct {
+	amount    int64
+	expiresAt time.Time
+}
+
+// memoryBudgetStore keeps budgets for a single instance when Redis is not configured
+type memoryBudgetStore struct {
+	mutex   sync.Mutex
+	entries map[string]*memoryBudget
+}
+
+// entry returns a budget, creating it and dropping budgets unused for a key TTL when missing
+func (m *memoryBudgetStore) entry(key string, now time.Time) *memoryBudget {
+	budget, exists := m.entries[key]
+	if !exists {
+		for other, entry := range m.entries {
+			if now.Sub(entry.lastUsed) > budgetKeyTTL {
+				delete(m.entries, other)
+			}
+		}
+		budget = &memoryBudget{holds: make(map[string]memoryBudgetHold), alerted: make(map[float64]bool)}
+		m.entries[key] = budget
+	}
+	budget.lastUsed = now
+	return budget
+}
+
+func (m *memoryBudgetStore) Reserve(ctx context.Context, key, token string, amount, budget int64, now, expiresAt time.Time) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry := m.entry(key, now)
+	held := int64(0)
+	for holdToken, hold := range entry.holds {
+		if !now.Before(hold.expiresAt) {
+			delete(entry.holds, holdToken)
+			continue
+		}
+		held += hold.amount
+	}
+	if entry.spent+held+amount > budget {
+		return false, nil
+	}
+	entry.holds[token] = memoryBudgetHold{amount: amount, expiresAt: expiresAt}
+	return true, nil
+}
+
+func (m *memoryBudgetStore) Commit(ctx context.Context, key, token string, amount int64, now time.Time, alerts []budgetThreshold) (budgetCommit, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry := m.entry(key, now)
+	hold, exists := entry.holds[token]
+	delete(entry.holds, token)
+	if !exists || !now.Before(hold.expiresAt) || amount > hold.amount {
+		return budgetCommit{}, nil
+	}
+	entry.spent += amount
+	commit := budgetCommit{committed: true, spent: entry.spent}
+	for _, alert := range alerts {
+		if entry.spent >= alert.units && !entry.alerted[alert.percent] {
+			entry.alerted[alert.percent] = true
+			commit.crossed = append(commit.crossed, alert.percent)
+		}
+	}
+	return commit, nil
+}
+
+func (m *memoryBudgetStore) Release(ctx context.Context, key, token string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if entry, exists := m.entries[key]; exists {
+		delete(entry.holds, token)
+	}
+	return nil
+}
+
+func (m *memoryBudgetStore) Usage(ctx context.Context, key string, now time.Time) (budgetUsage, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry, exists := m.entries[key]
+	if !exists {
+		return budgetUsage{}, nil
+	}
+	usage := budgetUsage{spent: entry.spent}
+	for _, hold := range entry.holds {
+		if now.Before(hold.expiresAt) {
+			usage.held += hold.amount
+		}
+	}
+	for percent := range entry.alerted {
+		usage.alerted = append(usage.alerted, percent)
+	}
+	return usage, nil
+}
@@ -0,0 +1,292 @@
+package services
+
+import (
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// fairVirtualNodes is the number of points a partner at full traffic holds on the hash ring;
+// partners with a lower traffic percentage hold proportionally fewer, and at least one
+const fairVirtualNodes = 64
+
+// Traffic mix keys for auctions without a region, and for values past the tracked limit
+const (
+	trafficMixUnknown = "unknown"
+	trafficMixOther   = "other"
+)
+
+// maxTrafficMixKeys bounds the distinct verticals or regions a traffic mix counts separately
+const maxTrafficMixKeys = 200
+
+// fairRing places each partner's virtual nodes on a hash ring. Hashes are sorted, and ties go to
+// the lower partner ID, so the same partners always build the same ring.
+type fairRing struct {
+	hashes   []uint64
+	partners []string
+}
+
+// newFairRing builds the ring for the configured partners
+func newFairRing(partners map[string]*config.PartnerConfig) *fairRing {
+	type point struct {
+		hash      uint64
+		partnerID string
+	}
+	var points []point
+	for partnerID, partner := range partners {
+		nodes := max(1, int(math.Round(fairVirtualNodes*partner.TrafficShare())))
+		for i := 0; i < nodes; i++ {
+			points = append(points, point{hash: ringHash(partnerID + "#" + strconv.Itoa(i)), partnerID: partnerID})
+		}
+	}
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].hash != points[j].hash {
+			return points[i].hash < points[j].hash
+		}
+		return points[i].partnerID < points[j].partnerID
+	})
+
+	ring := &fairRing{hashes: make([]uint64, len(points)), partners: make([]string, len(points))}
+	for i, p := range points {
+		ring.hashes[i], ring.partners[i] = p.hash, p.partnerID
+	}
+	return ring
+}
+
+// ringHash places a key on the ring. FNV-1a barely moves the high bits for keys differing only
+// in their last characters, such as sequential lead IDs, so its hash is run through the murmur3
+// finalizer to spread them around the ring.
+func ringHash(key string) uint64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(key))
+	h := hash.Sum64()
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// walk calls fn with each partner in ring order, once each, starting clockwise from key's
+// position, until fn returns false
+func (r *fairRing) walk(key string, fn func(partnerID string) bool) {
+	if len(r.hashes) == 0 {
+		return
+	}
+	hash := ringHash(key)
+	start := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= hash })
+	seen := make(map[string]bool)
+	for i := 0; i < len(r.hashes); i++ {
+		partnerID := r.partners[(start+i)%len(r.hashes)]
+		if seen[partnerID] {
+			continue
+		}
+		seen[partnerID] = true
+		if !fn(partnerID) {
+			return
+		}
+	}
+}
+
+// selectPartnersFairly picks the eligible partners an auction contacts in fair mode: every partner
+// with an active deal for the lead, and then partners in ring order from the lead's position up to
+// max in all. The same lead always picks the same partners from the same eligible set. It returns
+// the choices for every eligible partner, selected ones first.
+func selectPartnersFairly(ring *fairRing, key string, eligible []string, dealPartners map[string]bool, max int) ([]partnerChoice, int) {
+	pending := make(map[string]bool, len(eligible))
+	choices := make([]partnerChoice, 0, len(eligible))
+	for _, partnerID := range eligible {
+		if dealPartners[partnerID] {
+			choices = append(choices, partnerChoice{partnerID: partnerID, reason: models.ReasonSelectedDeal})
+		} else {
+			pending[partnerID] = true
+		}
+	}
+	sort.Slice(choices, func(i, j int) bool { return choices[i].partnerID < choices[j].partnerID })
+
+	selected := len(choices)
+	ring.walk(key, func(partnerID string) bool {
+		if selected >= max {
+			return false
+		}
+		if pending[partnerID] {
+			choices = append(choices, partnerChoice{partnerID: partnerID, reason: models.ReasonSelectedHashed})
+			delete(pending, partnerID)
+			selected++
+		}
+		return true
+	})
+
+	rest := make([]string, 0, len(pending))
+	for partnerID := range pending {
+		rest = append(rest, partnerID)
+	}
+	sort.Strings(rest)
+	for _, partnerID := range rest {
+		choices = append(choices, partnerChoice{partnerID: partnerID, reason: models.ReasonNotSelected})
+	}
+	return choices, selected
+}
+
+// fairnessKey returns the request value fair selection hashes: the lead ID, or the request ID for
+// requests without one
+func fairnessKey(request *models.BidRequest) string {
+	if request.LeadID != "" {
+		return request.LeadID
+	}
+	return request.RequestID
+}
+
+// SelectPartnersFairly returns the partners fair selection picks for request from eligible,
+// without running an auction, or ErrPartnerSelectionDisabled when auctions are not capped in
+// fair mode
+func (s *AuctionService) SelectPartnersFairly(request *models.BidRequest, eligible []string) ([]string, error) {
+	if s.selection == nil || s.selection.ring == nil {
+		return nil, ErrPartnerSelectionDisabled
+	}
+	deals := dealPartners(s.config.Deals, request, s.clock.Now())
+	choices, selected := selectPartnersFairly(s.selection.ring, fairnessKey(request), eligible, deals, s.selection.max)
+	chosen := make([]string, 0, selected)
+	for _, choice := range choices[:selected] {
+		chosen = append(chosen, choice.partnerID)
+	}
+	return chosen, nil
+}
+
+// TrafficMix is the share of auctions by vertical and region in a body of traffic
+type TrafficMix struct {
+	Auctions  uint64             `json:"auctions"`
+	Verticals map[string]float64 `json:"verticals"`
+	Regions   map[string]float64 `json:"regions"`
+}
+
+// PartnerTrafficMix is the traffic a partner was offered. Distances are the total variation
+// distance between its mix and the overall mix, from 0 for a representative sample to 1.
+type PartnerTrafficMix struct {
+	TrafficMix
+	OfferRate        float64 `json:"offer_rate"`
+	VerticalDistance float64 `json:"vertical_distance"`
+	RegionDistance   float64 `json:"region_distance"`
+}
+
+// TrafficMixReport compares the traffic each partner was offered with all capped auctions
+type TrafficMixReport struct {
+	Mode     string                       `json:"mode"`
+	Overall  TrafficMix                   `json:"overall"`
+	Partners map[string]PartnerTrafficMix `json:"partners"`
+}
+
+// trafficCounts counts auctions by vertical and region
+type trafficCounts struct {
+	auctions  uint64
+	verticals map[string]uint64
+	regions   map[string]uint64
+}
+
+// newTrafficCounts creates empty counts
+func newTrafficCounts() *trafficCounts {
+	return &trafficCounts{verticals: make(map[string]uint64), regions: make(map[string]uint64)}
+}
+
+// add counts an auction in a vertical and region
+func (c *trafficCounts) add(vertical, region string) {
+	c.auctions++
+	countTrafficKey(c.verticals, vertical)
+	countTrafficKey(c.regions, region)
+}
+
+// countTrafficKey increments a key's count, folding empty keys into unknown and keys past the
+// tracked limit into other
+func countTrafficKey(counts map[string]uint64, key string) {
+	if key == "" {
+		key = trafficMixUnknown
+	}
+	if _, exists := counts[key]; !exists && len(counts) >= maxTrafficMixKeys {
+		key = trafficMixOther
+	}
+	counts[key]++
+}
+
+// mix converts the counts to shares
+func (c *trafficCounts) mix() TrafficMix {
+	return TrafficMix{Auctions: c.auctions, Verticals: trafficShares(c.verticals, c.auctions), Regions: trafficShares(c.regions, c.auctions)}
+}
+
+// trafficShares divides each count by total
+func trafficShares(counts map[string]uint64, total uint64) map[string]float64 {
+	shares := make(map[string]float64, len(counts))
+	for key, count := range counts {
+		shares[key] = ratio(count, total)
+	}
+	return shares
+}
+
+// trafficDistance is the total variation distance between two sets of shares
+func trafficDistance(a, b map[string]float64) float64 {
+	distance := 0.0
+	for key, share := range a {
+		distance += math.Abs(share - b[key])
+	}
+	for key, share := range b {
+		if _, exists := a[key]; !exists {
+			distance += share
+		}
+	}
+	return distance / 2
+}
+
+// trafficMix counts the vertical and region of every capped auction and of the auctions each
+// partner was offered, since the service started
+type trafficMix struct {
+	mutex    sync.Mutex
+	overall  *trafficCounts
+	partners map[string]*trafficCounts
+}
+
+// newTrafficMix creates empty traffic counts
+func newTrafficMix() *trafficMix {
+	return &trafficMix{overall: newTrafficCounts(), partners: make(map[string]*trafficCounts)}
+}
+
+// record counts an auction and the partners it was offered to
+func (m *trafficMix) record(request *models.BidRequest, offered []string) {
+	region := ""
+	if request.Geo != nil {
+		region = request.Geo.Region
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.overall.add(request.Vertical, region)
+	for _, partnerID := range offered {
+		counts, exists := m.partners[partnerID]
+		if !exists {
+			counts = newTrafficCounts()
+			m.partners[partnerID] = counts
+		}
+		counts.add(request.Vertical, region)
+	}
+}
+
+// report returns the overall mix and each partner's offered mix
+func (m *trafficMix) report(mode string) *TrafficMixReport {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	report := &TrafficMixReport{Mode: mode, Overall: m.overall.mix(), Partners: make(map[string]PartnerTrafficMix, len(m.partners))}
+	for partnerID, counts := range m.partners {
+		mix := counts.mix()
+		report.Partners[partnerID] = PartnerTrafficMix{
+			TrafficMix:       mix,
+			OfferRate:        ratio(counts.auctions, m.overall.auctions),
+			VerticalDistance: trafficDistance(mix.Verticals, report.Overall.Verticals),
+			RegionDistance:   trafficDistance(mix.Regions, report.Overall.Regions),
+		}
+	}
+	return report
+}
@@ -0,0 +1,313 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap" // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/webhooks"
+)
+
+// ErrPartnerNotShadowed is returned when restoring a partner that is not in shadow mode
+var ErrPartnerNotShadowed = errors.New("partner is not in shadow mode")
+
+// Hygiene findings, the reasons a partner is listed in the hygiene report
+const (
+	HygieneNoBids      = "no_bids"      // no valid bid within the idle bid days
+	HygieneNoWins      = "no_wins"      // no win within the idle win days
+	HygieneAllTimeouts = "all_timeouts" // every call in the report window timed out
+	HygieneNoTraffic   = "no_traffic"   // no call within the report window
+	HygieneShadowed    = "shadowed"     // in shadow mode, awaiting an admin's decision
+)
+
+// Idle causes, why a partner without bids or traffic is idle. Partners kept out of auctions by
+// their config, a drain, or an open breaker are idle through no choice of their own; only
+// never_bid partners were called and chose not to bid.
+const (
+	IdleDisabled    = "disabled"
+	IdleDraining    = "draining"
+	IdleCircuitOpen = "circuit_open"
+	IdleTimingOut   = "timing_out"
+	IdleNotCalled   = "not_called"
+	IdleNeverBid    = "never_bid"
+)
+
+// Suggested hygiene actions
+const (
+	HygieneActionRemove        = "remove_from_config"
+	HygieneActionCheckEndpoint = "check_endpoint"
+	HygieneActionReviewTargets = "review_targeting"
+	HygieneActionShadow        = "shadow"
+	HygieneActionDisable       = "disable"
+	HygieneActionReviewPricing = "review_pricing"
+	HygieneActionRestore       = "restore"
+)
+
+// PartnerHygiene is a partner's entry in the hygiene report. Last call, bid, and win times are
+// left out when they did not happen since TrackedSince; calls and the timeout rate cover the
+// report window.
+type PartnerHygiene struct {
+	PartnerID         string       `json:"partner_id"`
+	Enabled           bool         `json:"enabled"`
+	TrackedSince      time.Time    `json:"tracked_since"`
+	LastCallAt        *time.Time   `json:"last_call_at,omitempty"`
+	LastBidAt         *time.Time   `json:"last_bid_at,omitempty"`
+	LastWinAt         *time.Time   `json:"last_win_at,omitempty"`
+	Calls             uint64       `json:"calls"`
+	TimeoutRate       float64      `json:"timeout_rate"`
+	TrafficPercentage float64      `json:"traffic_percentage"`
+	BreakerState      BreakerState `json:"breaker_state"`
+	ShadowedAt        *time.Time   `json:"shadowed_at,omitempty"`
+	Findings          []string     `json:"findings"`
+	IdleCause         string       `json:"idle_cause,omitempty"`
+	SuggestedAction   string       `json:"suggested_action"`
+}
+
+// HygieneReport lists the partners with at least one hygiene finding, in ID order
+type HygieneReport struct {
+	GeneratedAt time.Time        `json:"generated_at"`
+	Window      string           `json:"window"`
+	IdleBidDays int              `json:"idle_bid_days"`
+	IdleWinDays int              `json:"idle_win_days"`
+	Partners    []PartnerHygiene `json:"partners"`
+}
+
+// PartnerShadowedEvent is the data of a partner.shadowed webhook: a partner called for
+// IdleDays without a valid bid was moved into shadow mode and awaits an admin's decision
+type PartnerShadowedEvent struct {
+	PartnerID    string     `json:"partner_id"`
+	IdleDays     int        `json:"idle_days"`
+	TrackedSince time.Time  `json:"tracked_since"`
+	LastCallAt   *time.Time `json:"last_call_at,omitempty"`
+	LastBidAt    *time.Time `json:"last_bid_at,omitempty"`
+	ShadowedAt   time.Time  `json:"shadowed_at"`
+}
+
+// optionalTime returns t, or nil when it is zero
+func optionalTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+// PartnerHygiene reports the partners without a valid bid in idleBid, without a win in idleWin,
+// timing out on every call, or not called at all within the configured report window, with why
+// they are idle and what to do about them. Zero durations use the configured idle days.
+func (s *AuctionService) PartnerHygiene(idleBid, idleWin time.Duration) (*HygieneReport, error) {
+	if idleBid <= 0 {
+		idleBid = s.config.Hygiene.BidIdleAfter()
+	}
+	if idleWin <= 0 {
+		idleWin = s.config.Hygiene.WinIdleAfter()
+	}
+	window := s.config.Hygiene.Window()
+	now := s.clock.Now()
+
+	partners := s.partnerSnapshot()
+	partnerIDs := make([]string, 0, len(partners))
+	for partnerID := range partners {
+		partnerIDs = append(partnerIDs, partnerID)
+	}
+	sort.Strings(partnerIDs)
+
+	report := &HygieneReport{
+		GeneratedAt: now.UTC(),
+		Window:      window.String(),
+		IdleBidDays: int(idleBid / (24 * time.Hour)),
+		IdleWinDays: int(idleWin / (24 * time.Hour)),
+		Partners:    []PartnerHygiene{},
+	}
+	for _, partnerID := range partnerIDs {
+		calls, err := s.reports.Report(partnerID, window)
+		if err != nil {
+			return nil, err
+		}
+		activity, _ := s.activity.get(partnerID)
+		entry := s.partnerHygiene(partnerID, partners[partnerID], activity, calls, now, idleBid, idleWin, window)
+		if len(entry.Findings) > 0 {
+			report.Partners = append(report.Partners, entry)
+		}
+	}
+	return report, nil
+}
+
+// partnerHygiene judges one partner's activity and calls in the report window
+func (s *AuctionService) partnerHygiene(partnerID string, partner *config.PartnerConfig, activity partnerActivity, calls *PartnerReport, now time.Time, idleBid, idleWin, window time.Duration) PartnerHygiene {
+	entry := PartnerHygiene{
+		PartnerID:         partnerID,
+		Enabled:           partner.Enabled,
+		TrackedSince:      activity.trackedSince.UTC(),
+		LastCallAt:        optionalTime(activity.lastCall),
+		LastBidAt:         optionalTime(activity.lastBid),
+		LastWinAt:         optionalTime(activity.lastWin),
+		Calls:             calls.Calls,
+		TimeoutRate:       calls.TimeoutRate,
+		TrafficPercentage: partner.TrafficShare() * 100,
+		BreakerState:      s.breakers.State(partnerID),
+		Findings:          []string{},
+	}
+	if activity.shadowed() {
+		entry.ShadowedAt = optionalTime(activity.shadowedAt)
+	}
+
+	noBids := activity.idleFor(activity.lastBid, idleBid, now)
+	noTraffic, allTimeouts := callFindings(activity, calls, now, window)
+	if noBids {
+		entry.Findings = append(entry.Findings, HygieneNoBids)
+	}
+	if activity.idleFor(activity.lastWin, idleWin, now) {
+		entry.Findings = append(entry.Findings, HygieneNoWins)
+	}
+	if allTimeouts {
+		entry.Findings = append(entry.Findings, HygieneAllTimeouts)
+	}
+	if noTraffic {
+		entry.Findings = append(entry.Findings, HygieneNoTraffic)
+	}
+	if entry.ShadowedAt != nil {
+		entry.Findings = append(entry.Findings, HygieneShadowed)
+	}
+	if len(entry.Findings) == 0 {
+		return entry
+	}
+
+	if noBids || noTraffic || allTimeouts {
+		entry.IdleCause = s.idleCause(partnerID, partner, entry.BreakerState, allTimeouts, noTraffic)
+	}
+	switch entry.IdleCause {
+	case IdleDisabled, IdleDraining:
+		entry.SuggestedAction = HygieneActionRemove
+	case IdleCircuitOpen, IdleTimingOut:
+		entry.SuggestedAction = HygieneActionCheckEndpoint
+	case IdleNotCalled:
+		entry.SuggestedAction = HygieneActionReviewTargets
+	case IdleNeverBid:
+		entry.SuggestedAction = HygieneActionShadow
+		if activity.shadowed() {
+			entry.SuggestedAction = HygieneActionDisable
+		}
+	default:
+		// A shadowed partner bidding again can be restored; any other bids but does not win
+		entry.SuggestedAction = HygieneActionReviewPricing
+		if activity.shadowed() {
+			entry.SuggestedAction = HygieneActionRestore
+		}
+	}
+	return entry
+}
+
+// callFindings returns whether a partner went uncalled for the whole report window and whether
+// every call it got in the window timed out
+func callFindings(activity partnerActivity, calls *PartnerReport, now time.Time, window time.Duration) (bool, bool) {
+	return activity.idleFor(activity.lastCall, window, now), calls.Calls > 0 && calls.TimeoutRate >= 1
+}
+
+// idleCause returns why a partner without bids or traffic is idle. Causes that keep the partner
+// out of auctions come first, so a partner whose breaker is open is never taken for one that
+// chooses not to bid.
+func (s *AuctionService) idleCause(partnerID string, partner *config.PartnerConfig, breaker BreakerState, allTimeouts, noTraffic bool) string {
+	switch {
+	case !partner.Enabled:
+		return IdleDisabled
+	case s.partnerDraining(partnerID):
+		return IdleDraining
+	case breaker != BreakerClosed:
+		return IdleCircuitOpen
+	case allTimeouts:
+		return IdleTimingOut
+	case noTraffic:
+		return IdleNotCalled
+	default:
+		return IdleNeverBid
+	}
+}
+
+// checkIdlePartners moves partners that were called but sent no valid bid for the configured
+// shadow days into shadow mode. Partners idle for any other cause are left for the report. It
+// runs every hygiene check interval when auto shadowing is on.
+func (s *AuctionService) checkIdlePartners() {
+	limit := s.config.Hygiene.ShadowAfter()
+	window := s.config.Hygiene.Window()
+	now := s.clock.Now()
+	for partnerID, partner := range s.partnerSnapshot() {
+		activity, _ := s.activity.get(partnerID)
+		if activity.shadowed() || now.Sub(activity.idleSince()) < limit {
+			continue
+		}
+		calls, err := s.reports.Report(partnerID, window)
+		if err != nil {
+			continue
+		}
+		noTraffic, allTimeouts := callFindings(activity, calls, now, window)
+		if s.idleCause(partnerID, partner, s.breakers.State(partnerID), allTimeouts, noTraffic) != IdleNeverBid {
+			continue
+		}
+		s.shadowPartner(partnerID, limit)
+	}
+}
+
+// shadowPartner moves a partner into shadow mode, logging, counting, and announcing it with a
+// partner.shadowed webhook so an admin can restore or disable it
+func (s *AuctionService) shadowPartner(partnerID string, limit time.Duration) {
+	activity, moved := s.activity.shadow(partnerID)
+	if !moved {
+		return
+	}
+	idleSince := activity.idleSince()
+	event := PartnerShadowedEvent{
+		PartnerID:    partnerID,
+		IdleDays:     int(activity.shadowedAt.Sub(idleSince) / (24 * time.Hour)),
+		TrackedSince: activity.trackedSince.UTC(),
+		LastCallAt:   optionalTime(activity.lastCall),
+		LastBidAt:    optionalTime(activity.lastBid),
+		ShadowedAt:   activity.shadowedAt.UTC(),
+	}
+	partnersShadowedTotal.WithLabelValues(partnerID).Inc()
+	s.activity.logger.Warn("partner shadowed", zap.String("partner", partnerID), zap.Time("idle_since", idleSince),
+		zap.Duration("shadow_after", limit))
+	// Instances that shadow the partner from the same synced activity send the same event ID
+	s.dispatchWebhook(context.Background(), webhooks.Event{
+		ID:        webhookEventID(config.WebhookEventPartnerShadowed, partnerID, strconv.FormatInt(idleSince.UnixMilli(), 10)),
+		Type:      config.WebhookEventPartnerShadowed,
+		CreatedAt: event.ShadowedAt,
+		Data:      event,
+	})
+}
+
+// RestorePartner takes a partner out of shadow mode, so its bids compete again. Its idle stretch
+// restarts, so it is not shadowed again before the shadow days pass once more.
+func (s *AuctionService) RestorePartner(partnerID, requestedBy string) error {
+	if _, exists := s.partnerSnapshot()[partnerID]; !exists {
+		return ErrUnknownPartner
+	}
+	if !s.activity.restore(partnerID) {
+		return ErrPartnerNotShadowed
+	}
+	s.activity.logger.Warn("partner restored from shadow mode", zap.String("partner", partnerID), zap.String("requested_by", requestedBy))
+	return nil
+}
+
+// PartnerShadowed reports whether a partner is in shadow mode
+func (s *AuctionService) PartnerShadowed(partnerID string) bool {
+	return s.activity.shadowed(partnerID)
+}
+
+// shadowBids drops the bids of a partner in shadow mode with the shadowed loss reason. Shadowed
+// partners are still called, so a partner that starts bidding again shows in its activity, but
+// their bids never compete.
+func (s *AuctionService) shadowBids(partnerID string, bids []*models.Bid, reasons *models.ReasonCollector) []*models.Bid {
+	if len(bids) == 0 || !s.activity.shadowed(partnerID) {
+		return bids
+	}
+	for _, bid := range bids {
+		reasons.Loss(partnerID, bid.ID, models.ReasonShadowed)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// partnerLimiter is a token bucket enforcing a partner's maximum QPS.
+// Low-priority callers may only take tokens while the bucket holds more than the live reserve.
+type partnerLimiter struct {
+	rate     float64
+	burst    float64
+	reserve  float64
+	mutex    sync.Mutex
+	tokens   float64
+	lastFill time.Time
+}
+
+// newPartnerLimiters creates limiters for every partner with a QPS cap
+func newPartnerLimiters(cfg *config.Config) map[string]*partnerLimiter {
+	reserve := 0.0
+	if cfg.Batch != nil && cfg.Batch.Priority == config.BatchPriorityLow {
+		reserve = cfg.Batch.LiveReserve
+	}
+
+	limiters := make(map[string]*partnerLimiter)
+	for partnerID, partner := range cfg.Partners {
+		if partner.MaxQPS <= 0 {
+			continue
+		}
+		qps := float64(partner.MaxQPS)
+		limiters[partnerID] = &partnerLimiter{
+			rate:     qps,
+			burst:    qps,
+			reserve:  math.Ceil(qps * reserve),
+			tokens:   qps,
+			lastFill: time.Now(),
+		}
+	}
+	return limiters
+}
+
+// verticalPartnerKey identifies a vertical pool's share of a partner's QPS
+type verticalPartnerKey struct {
+	pool      string
+	partnerID string
+}
+
+// newVerticalPartnerLimiters creates a limiter for each vertical pool's share of every partner
+// with a QPS cap. A pool's share is at least one request a second.
+func newVerticalPartnerLimiters(cfg *config.Config) map[verticalPartnerKey]*partnerLimiter {
+	limiters := make(map[verticalPartnerKey]*partnerLimiter)
+	for pool, limit := range cfg.VerticalLimits {
+		if limit.PartnerQPSShare <= 0 {
+			continue
+		}
+		for partnerID, partner := range cfg.Partners {
+			if partner.MaxQPS <= 0 {
+				continue
+			}
+			qps := math.Max(1, float64(partner.MaxQPS)*limit.PartnerQPSShare)
+			limiters[verticalPartnerKey{pool: pool, partnerID: partnerID}] = &partnerLimiter{
+				rate:     qps,
+				burst:    qps,
+				tokens:   qps,
+				lastFill: time.Now(),
+			}
+		}
+	}
+	return limiters
+}
+
+// Allow takes a token if available; lowPriority callers leave the live reserve untouched
+func (l *partnerLimiter) Allow(lowPriority bool) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.lastFill).Seconds()*l.rate)
+	l.lastFill = now
+
+	required := 1.0
+	if lowPriority {
+		required += l.reserve
+	}
+	if l.tokens < required {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,611 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap" // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// PartnerReportRetention is the longest window a partner report covers
+const PartnerReportRetention = 31 * 24 * time.Hour
+
+// minSLACalls is the fewest calls in an hour before its SLA compliance is judged, so a single
+// slow call to a quiet partner does not page
+const minSLACalls = 20
+
+// SLO names used in breach metrics and logs
+const (
+	sloP95Latency  = "p95_latency"
+	sloTimeoutRate = "timeout_rate"
+)
+
+// Partner report errors
+var (
+	ErrUnknownPartner      = errors.New("unknown partner")
+	ErrInvalidReportWindow = errors.New("invalid report window")
+)
+
+// PartnerCall is the outcome of one partner call as counted in partner reports. QualityScores
+// are the scores of the call's valid bids as the partner sent them, counted under Vertical.
+// Calls in the partner's payload experiment carry their variant, and the prices of their valid
+// bids are counted under it.
+type PartnerCall struct {
+	Latency           time.Duration                `json:"latency"`
+	TimedOut          bool                         `json:"timed_out"`
+	Bids              int                          `json:"bids"`
+	InvalidBids       int                          `json:"invalid_bids"`
+	NoBid             bool                         `json:"no_bid"`
+	Vertical          string                       `json:"vertical,omitempty"`
+	QualityScores     []float64                    `json:"quality_scores,omitempty"`
+	PayloadExperiment *models.ExperimentAssignment `json:"payload_experiment,omitempty"`
+	Prices            []float64                    `json:"prices,omitempty"`
+}
+
+// PartnerReport summarizes a partner's calls, bids, and wins over a window, with SLA compliance
+// when the partner has targets. Bid rate is the share of calls with a valid bid, and win rate the
+// share of auctions the partner bid in that it won. Quality scores average the partner's valid
+// bids per vertical. Budget utilization is the share of the partner's daily budget spent today,
+// whatever the window, and is left out for partners without a budget. Partners with a payload
+// experiment, running or ended, report its variants' calls within the window.
+type PartnerReport struct {
+	PartnerID         string                   `json:"partner_id"`
+	Window            string                   `json:"window"`
+	Calls             uint64                   `json:"calls"`
+	BidRate           float64                  `json:"bid_rate"`
+	TimeoutRate       float64                  `json:"timeout_rate"`
+	InvalidBidRate    float64                  `json:"invalid_bid_rate"`
+	NoBidRate         float64                  `json:"no_bid_rate"`
+	WinRate           float64                  `json:"win_rate"`
+	Wins              uint64                   `json:"wins"`
+	AvgClearingPrice  float64                  `json:"avg_clearing_price"`
+	AvgLatencyMs      float64                  `json:"avg_latency_ms"`
+	P95LatencyMs      float64                  `json:"p95_latency_ms"`
+	QualityScores     map[string]float64       `json:"quality_scores,omitempty"`
+	BudgetUtilization *float64                 `json:"budget_utilization,omitempty"`
+	SLA               *PartnerSLAStatus        `json:"sla,omitempty"`
+	PayloadExperiment *PayloadExperimentReport `json:"payload_experiment,omitempty"`
+}
+
+// PayloadExperimentReport compares the variants of a partner's payload experiment. Each variant's
+// bid rate is the share of its calls with a valid bid, and its average price that of its valid
+// bids. The deltas are the treatment's figures less the control's.
+type PayloadExperimentReport struct {
+	ID                string                         `json:"id"`
+	TrafficPercent    float64                        `json:"traffic_percent"`
+	EndsAt            time.Time                      `json:"ends_at"`
+	Active            bool                           `json:"active"`
+	Variants          map[string]PayloadVariantStats `json:"variants"`
+	BidRateDelta      float64                        `json:"bid_rate_delta"`
+	AvgPriceDelta     float64                        `json:"avg_price_delta"`
+	AvgLatencyDeltaMs float64                        `json:"avg_latency_delta_ms"`
+}
+
+// PayloadVariantStats are the calls one variant of a payload experiment was sent in
+type PayloadVariantStats struct {
+	Calls        uint64  `json:"calls"`
+	BidRate      float64 `json:"bid_rate"`
+	AvgPrice     float64 `json:"avg_price"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	TimeoutRate  float64 `json:"timeout_rate"`
+}
+
+// PartnerSLAStatus reports whether the window as a whole met each SLA target, and how many of its
+// hours were judged in breach
+type PartnerSLAStatus struct {
+	P95LatencyMs   float64 `json:"p95_latency_ms,omitempty"`
+	MaxTimeoutRate float64 `json:"max_timeout_rate,omitempty"`
+	LatencyMet     bool    `json:"latency_met"`
+	TimeoutRateMet bool    `json:"timeout_rate_met"`
+	Met            bool    `json:"met"`
+	BreachedHours  int     `json:"breached_hours"`
+}
+
+// partnerWindow aggregates one partner's calls within one hour
+type partnerWindow struct {
+	hour          int64
+	calls         uint64
+	timeouts      uint64
+	bids          uint64
+	invalidBids   uint64
+	noBids        uint64
+	auctionsBid   uint64
+	wins          uint64
+	clearingTotal float64
+	latencyTotal  time.Duration
+	latencies     []uint32
+	quality       map[string]qualityTally
+	payload       map[models.ExperimentAssignment]payloadTally
+	breached      bool
+}
+
+// payloadTally totals the calls of one payload experiment variant
+type payloadTally struct {
+	calls        uint64
+	auctionsBid  uint64
+	timeouts     uint64
+	prices       uint64
+	priceTotal   float64
+	latencyTotal time.Duration
+}
+
+// qualityTally totals the quality scores of a partner's bids in one vertical
+type qualityTally struct {
+	total float64
+	bids  uint64
+}
+
+// PartnerReporter keeps hourly call statistics per configured partner for the report retention and
+// checks each completed hour against the partner's SLA
+type PartnerReporter struct {
+	partners map[string]*config.PartnerConfig
+	clock    utils.Clock
+	mutex    sync.Mutex
+	windows  map[string][]partnerWindow
+	lastHour map[string]int64
+	scale    logScale
+	logger   *zap.Logger
+}
+
+// NewPartnerReporter creates a reporter for the configured partners
+func NewPartnerReporter(cfg *config.Config, clock utils.Clock) *PartnerReporter {
+	if clock == nil {
+		clock = utils.SystemClock{}
+	}
+
+	hours := int(PartnerReportRetention / time.Hour)
+	windows := make(map[string][]partnerWindow, len(cfg.Partners))
+	for partnerID := range cfg.Partners {
+		windows[partnerID] = make([]partnerWindow, hours)
+	}
+
+	return &PartnerReporter{
+		partners: cfg.Partners,
+		clock:    clock,
+		windows:  windows,
+		lastHour: make(map[string]int64, len(cfg.Partners)),
+		scale:    newLogScale(1, 10000, 0.1),
+		logger:   zap.NewNop(),
+	}
+}
+
+// SetLogger sets the logger used for SLA breach events
+func (r *PartnerReporter) SetLogger(logger *zap.Logger) {
+	if logger != nil {
+		r.logger = logger
+	}
+}
+
+// RecordCall counts a partner call in the current hour
+func (r *PartnerReporter) RecordCall(partnerID string, call PartnerCall) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	window := r.current(partnerID)
+	if window == nil {
+		return
+	}
+	window.calls++
+	if call.TimedOut {
+		window.timeouts++
+	}
+	window.bids += uint64(call.Bids)
+	window.invalidBids += uint64(call.InvalidBids)
+	if call.NoBid {
+		window.noBids++
+	}
+	if call.Bids > call.InvalidBids {
+		window.auctionsBid++
+	}
+	if len(call.QualityScores) > 0 && call.Vertical != "" {
+		if window.quality == nil {
+			window.quality = make(map[string]qualityTally)
+		}
+		tally := window.quality[call.Vertical]
+		for _, score := range call.QualityScores {
+			tally.total += score
+			tally.bids++
+		}
+		window.quality[call.Vertical] = tally
+	}
+	if call.PayloadExperiment != nil {
+		if window.payload == nil {
+			window.payload = make(map[models.ExperimentAssignment]payloadTally)
+		}
+		tally := window.payload[*call.PayloadExperiment]
+		tally.calls++
+		if call.Bids > call.InvalidBids {
+			tally.auctionsBid++
+		}
+		if call.TimedOut {
+			tally.timeouts++
+		}
+		for _, price := range call.Prices {
+			tally.priceTotal += price
+			tally.prices++
+		}
+		tally.latencyTotal += call.Latency
+		window.payload[*call.PayloadExperiment] = tally
+	}
+	window.latencyTotal += call.Latency
+	if window.latencies == nil {
+		window.latencies = make([]uint32, r.scale.buckets)
+	}
+	window.latencies[r.scale.bucket(float64(call.Latency)/float64(time.Millisecond))]++
+}
+
+// RecordWin counts an auction won by a partner at price in the current hour
+func (r *PartnerReporter) RecordWin(partnerID string, price float64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if window := r.current(partnerID); window != nil {
+		window.wins++
+		window.clearingTotal += price
+	}
+}
+
+// current returns the partner's window for this hour, resetting a stale slot and judging the
+// partner's previous hour against its SLA when the hour has changed
+func (r *PartnerReporter) current(partnerID string) *partnerWindow {
+	windows, exists := r.windows[partnerID]
+	if !exists {
+		return nil
+	}
+
+	hour := r.clock.Now().Unix() / 3600
+	if last, seen := r.lastHour[partnerID]; seen && last != hour {
+		if previous := &windows[last%int64(len(windows))]; previous.hour == last {
+			r.checkSLA(partnerID, previous)
+		}
+	}
+	r.lastHour[partnerID] = hour
+
+	window := &windows[hour%int64(len(windows))]
+	if window.hour != hour {
+		latencies := window.latencies
+		clear(latencies)
+		*window = partnerWindow{hour: hour, latencies: latencies}
+	}
+	return window
+}
+
+// checkSLA marks a completed hour in breach of any SLA target, counting and logging each breach
+func (r *PartnerReporter) checkSLA(partnerID string, window *partnerWindow) {
+	sla := r.partners[partnerID].SLA
+	if sla == nil || window.calls < minSLACalls {
+		return
+	}
+
+	p95 := r.p95Latency([]partnerWindow{*window})
+	timeoutRate := float64(window.timeouts) / float64(window.calls)
+	breach := func(slo string, observed, target float64) {
+		window.breached = true
+		partnerSLABreachTotal.WithLabelValues(partnerID, slo).Inc()
+		r.logger.Warn("partner SLA breached",
+			zap.String("partner", partnerID),
+			zap.String("slo", slo),
+			zap.Time("hour", time.Unix(window.hour*3600, 0).UTC()),
+			zap.Float64("observed", observed),
+			zap.Float64("target", target),
+			zap.Uint64("calls", window.calls),
+		)
+	}
+
+	if target := durationMs(sla.P95Latency); target > 0 && p95 > target {
+		breach(sloP95Latency, p95, target)
+	}
+	if sla.MaxTimeoutRate > 0 && timeoutRate > sla.MaxTimeoutRate {
+		breach(sloTimeoutRate, timeoutRate, sla.MaxTimeoutRate)
+	}
+}
+
+// Report summarizes a partner's hours within the last window, rounded up to whole hours
+func (r *PartnerReporter) Report(partnerID string, window time.Duration) (*PartnerReport, error) {
+	partner, exists := r.partners[partnerID]
+	if !exists {
+		return nil, ErrUnknownPartner
+	}
+	hours, err := reportHours(window)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	covered := r.covered(partnerID, hours)
+	report := r.summarize(covered)
+	if partner.PayloadExperiment != nil {
+		report.PayloadExperiment = r.payloadReport(partner.PayloadExperiment, covered)
+	}
+	r.mutex.Unlock()
+
+	report.PartnerID = partnerID
+	report.Window = (time.Duration(hours) * time.Hour).String()
+	if partner.SLA != nil {
+		report.SLA = r.slaStatus(partner.SLA, report, covered)
+	}
+	return report, nil
+}
+
+// Verticals returns the verticals any partner's bids were scored in within the last window, sorted
+func (r *PartnerReporter) Verticals(window time.Duration) ([]string, error) {
+	hours, err := reportHours(window)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	seen := make(map[string]bool)
+	for partnerID := range r.windows {
+		for _, w := range r.covered(partnerID, hours) {
+			for vertical := range w.quality {
+				seen[vertical] = true
+			}
+		}
+	}
+	verticals := make([]string, 0, len(seen))
+	for vertical := range seen {
+		verticals = append(verticals, vertical)
+	}
+	sort.Strings(verticals)
+	return verticals, nil
+}
+
+// PartnerIDs returns the IDs of the partners the reporter keeps statistics for, sorted
+func (r *PartnerReporter) PartnerIDs() []string {
+	partnerIDs := make([]string, 0, len(r.partners))
+	for partnerID := range r.partners {
+		partnerIDs = append(partnerIDs, partnerID)
+	}
+	sort.Strings(partnerIDs)
+	return partnerIDs
+}
+
+// reportHours returns the whole hours a report window covers, rounded up
+func reportHours(window time.Duration) (int64, error) {
+	hours := int64(math.Ceil(window.Hours()))
+	if hours < 1 || time.Duration(hours)*time.Hour > PartnerReportRetention {
+		return 0, fmt.Errorf("%w: must be between 1h and %v", ErrInvalidReportWindow, PartnerReportRetention)
+	}
+	return hours, nil
+}
+
+// covered returns the partner's windows within the last hours; the caller holds the mutex
+func (r *PartnerReporter) covered(partnerID string, hours int64) []partnerWindow {
+	windows := r.windows[partnerID]
+	now := r.clock.Now().Unix() / 3600
+	covered := make([]partnerWindow, 0, hours)
+	for hour := now - hours + 1; hour <= now; hour++ {
+		if w := windows[hour%int64(len(windows))]; w.hour == hour {
+			covered = append(covered, w)
+		}
+	}
+	return covered
+}
+
+// summarize totals windows into a report; the caller holds the mutex since windows share latency buckets
+func (r *PartnerReporter) summarize(windows []partnerWindow) *PartnerReport {
+	var timeouts, bids, invalidBids, noBids, auctionsBid uint64
+	var clearingTotal float64
+	var latencyTotal time.Duration
+	quality := make(map[string]qualityTally)
+	report := &PartnerReport{}
+	for _, w := range windows {
+		report.Calls += w.calls
+		report.Wins += w.wins
+		timeouts += w.timeouts
+		bids += w.bids
+		invalidBids += w.invalidBids
+		noBids += w.noBids
+		auctionsBid += w.auctionsBid
+		clearingTotal += w.clearingTotal
+		latencyTotal += w.latencyTotal
+		for vertical, tally := range w.quality {
+			total := quality[vertical]
+			total.total += tally.total
+			total.bids += tally.bids
+			quality[vertical] = total
+		}
+	}
+
+	report.BidRate = ratio(auctionsBid, report.Calls)
+	report.TimeoutRate = ratio(timeouts, report.Calls)
+	report.InvalidBidRate = ratio(invalidBids, bids)
+	report.NoBidRate = ratio(noBids, report.Calls)
+	report.WinRate = ratio(report.Wins, auctionsBid)
+	if report.Wins > 0 {
+		report.AvgClearingPrice = math.Round(clearingTotal/float64(report.Wins)*100) / 100
+	}
+	if report.Calls > 0 {
+		report.AvgLatencyMs = math.Round(durationMs(latencyTotal)/float64(report.Calls)*10) / 10
+	}
+	report.P95LatencyMs = r.p95Latency(windows)
+	if len(quality) > 0 {
+		report.QualityScores = make(map[string]float64, len(quality))
+		for vertical, tally := range quality {
+			report.QualityScores[vertical] = math.Round(tally.total/float64(tally.bids)*1000) / 1000
+		}
+	}
+	return report
+}
+
+// payloadReport compares the variants of a payload experiment across windows; the caller holds the
+// mutex since windows share their variant tallies
+func (r *PartnerReporter) payloadReport(experiment *config.PayloadExperiment, windows []partnerWindow) *PayloadExperimentReport {
+	report := &PayloadExperimentReport{
+		ID:             experiment.ID,
+		TrafficPercent: experiment.TrafficPercent,
+		EndsAt:         experiment.EndsAt,
+		Active:         experiment.Active(r.clock.Now()),
+		Variants:       make(map[string]PayloadVariantStats, 2),
+	}
+	for _, variant := range []string{config.ExperimentVariantControl, config.ExperimentVariantTreatment} {
+		var total payloadTally
+		for _, w := range windows {
+			tally := w.payload[models.ExperimentAssignment{ID: experiment.ID, Variant: variant}]
+			total.calls += tally.calls
+			total.auctionsBid += tally.auctionsBid
+			total.timeouts += tally.timeouts
+			total.prices += tally.prices
+			total.priceTotal += tally.priceTotal
+			total.latencyTotal += tally.latencyTotal
+		}
+		stats := PayloadVariantStats{
+			Calls:       total.calls,
+			BidRate:     ratio(total.auctionsBid, total.calls),
+			TimeoutRate: ratio(total.timeouts, total.calls),
+		}
+		if total.prices > 0 {
+			stats.AvgPrice = math.Round(total.priceTotal/float64(total.prices)*100) / 100
+		}
+		if total.calls > 0 {
+			stats.AvgLatencyMs = math.Round(durationMs(total.latencyTotal)/float64(total.calls)*10) / 10
+		}
+		report.Variants[variant] = stats
+	}
+
+	control, treatment := report.Variants[config.ExperimentVariantControl], report.Variants[config.ExperimentVariantTreatment]
+	report.BidRateDelta = treatment.BidRate - control.BidRate
+	report.AvgPriceDelta = math.Round((treatment.AvgPrice-control.AvgPrice)*100) / 100
+	report.AvgLatencyDeltaMs = math.Round((treatment.AvgLatencyMs-control.AvgLatencyMs)*10) / 10
+	return report
+}
+
+// slaStatus judges a report against the SLA targets and counts the breached hours it covers
+func (r *PartnerReporter) slaStatus(sla *config.PartnerSLA, report *PartnerReport, windows []partnerWindow) *PartnerSLAStatus {
+	status := &PartnerSLAStatus{
+		P95LatencyMs:   durationMs(sla.P95Latency),
+		MaxTimeoutRate: sla.MaxTimeoutRate,
+		LatencyMet:     sla.P95Latency == 0 || report.P95LatencyMs <= durationMs(sla.P95Latency),
+		TimeoutRateMet: sla.MaxTimeoutRate == 0 || report.TimeoutRate <= sla.MaxTimeoutRate,
+	}
+	status.Met = status.LatencyMet && status.TimeoutRateMet
+	for _, w := range windows {
+		if w.breached {
+			status.BreachedHours++
+		}
+	}
+	return status
+}
+
+// p95Latency returns the 95th percentile call latency in milliseconds across windows
+func (r *PartnerReporter) p95Latency(windows []partnerWindow) float64 {
+	counts := make([]uint64, r.scale.buckets)
+	var total uint64
+	for _, w := range windows {
+		for i, count := range w.latencies {
+			counts[i] += uint64(count)
+			total += uint64(count)
+		}
+	}
+	return math.Round(r.scale.quantile(counts, total, 0.95)*10) / 10
+}
+
+// ratio returns part/whole, or 0 when whole is 0
+func ratio(part, whole uint64) float64 {
+	if whole == 0 {
+		return 0
+	}
+	return float64(part) / float64(whole)
+}
+
+// durationMs converts a duration to fractional milliseconds
+func durationMs(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// bidQualityScores returns the quality scores of bids, or nil when there are none
+func bidQualityScores(bids []*models.Bid) []float64 {
+	if len(bids) == 0 {
+		return nil
+	}
+	scores := make([]float64, len(bids))
+	for i, bid := range bids {
+		scores[i] = bid.QualityScore
+	}
+	return scores
+}
+
+// PartnerReport summarizes a partner's calls, bids, wins, and SLA compliance over window, with
+// its budget utilization today
+func (s *AuctionService) PartnerReport(partnerID string, window time.Duration) (*PartnerReport, error) {
+	report, err := s.reports.Report(partnerID, window)
+	if err != nil {
+		return nil, err
+	}
+	report.BudgetUtilization = s.budgetUtilization(partnerID)
+	return report, nil
+}
+
+// budgetUtilization returns the share of a partner's daily budget spent today, or nil when the
+// partner has no budget or its spend cannot be read
+func (s *AuctionService) budgetUtilization(partnerID string) *float64 {
+	ctx, cancel := s.budgets.settleContext(context.Background())
+	defer cancel()
+	status, err := s.PartnerBudget(ctx, partnerID)
+	if err != nil {
+		if !errors.Is(err, ErrNoBudget) && !errors.Is(err, ErrUnknownPartner) {
+			s.budgets.logger.Warn("partner budget unavailable for report", zap.String("partner", partnerID), zap.Error(err))
+		}
+		return nil
+	}
+	utilization := status.Spent / status.Budget
+	return &utilization
+}
+
+// PartnerExport lists what a partner performance export over a window covers: the partners, in
+// ID order, and the verticals with quality scores, which become the export's quality columns.
+// Each partner's row is its PartnerReport over the same window.
+type PartnerExport struct {
+	Window     time.Duration
+	PartnerIDs []string
+	Verticals  []string
+}
+
+// PartnerExport returns the partners and verticals a partner performance export over window covers
+func (s *AuctionService) PartnerExport(window time.Duration) (*PartnerExport, error) {
+	verticals, err := s.reports.Verticals(window)
+	if err != nil {
+		return nil, err
+	}
+	return &PartnerExport{Window: window, PartnerIDs: s.reports.PartnerIDs(), Verticals: verticals}, nil
+}
+
+// SetLogger sets the logger used for service events such as partner SLA breaches, failed webhooks,
+// failed reservation sweeps, ops overrides, partner drains, budget alerts, shadowed partners, and
+// request ID conflicts
+func (s *AuctionService) SetLogger(logger *zap.Logger) {
+	if logger != nil {
+		s.overrides.logger = logger
+		s.captures.logger = logger
+		s.drains.logger = logger
+		s.faults.logger = logger
+		s.assets.logger = logger
+		s.budgets.logger = logger
+		s.activity.logger = logger
+		s.configs.logger = logger
+		s.hooks.logger = logger
+		if s.sequence != nil {
+			s.sequence.logger = logger
+		}
+		if s.replays != nil {
+			s.replays.logger = logger
+		}
+		if s.idempotency != nil {
+			s.idempotency.logger = logger
+		}
+	}
+	s.reports.SetLogger(logger)
+	s.webhooks.SetLogger(logger)
+	s.reservations.SetLogger(logger)
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// partnerSchedule evaluates a partner's active-hours windows in the partner timezone
+type partnerSchedule struct {
+	location *time.Location
+	windows  []config.ScheduleWindow
+}
+
+// newPartnerSchedules compiles schedules for every partner that has one
+func newPartnerSchedules(cfg *config.Config) map[string]*partnerSchedule {
+	schedules := make(map[string]*partnerSchedule)
+	for partnerID, partner := range cfg.Partners {
+		if partner.Schedule == nil {
+			continue
+		}
+		location, err := time.LoadLocation(partner.Schedule.Timezone)
+		if err != nil {
+			location = time.UTC
+		}
+		schedules[partnerID] = &partnerSchedule{
+			location: location,
+			windows:  partner.Schedule.Windows,
+		}
+	}
+	return schedules
+}
+
+// Active reports whether now falls within any of the schedule windows
+func (ps *partnerSchedule) Active(now time.Time) bool {
+	local := now.In(ps.location)
+	hour := local.Hour()
+	for _, window := range ps.windows {
+		if hour < window.StartHour || hour >= window.EndHour {
+			continue
+		}
+		for _, day := range config.RuleDays(window.Days) {
+			if day == local.Weekday() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PartnerInSchedule reports whether a partner is within its active hours; partners without a schedule always are
+func (s *AuctionService) PartnerInSchedule(partnerID string) bool {
+	schedule, exists := s.schedules[partnerID]
+	if !exists {
+		return true
+	}
+	return schedule.Active(s.clock.Now())
+}
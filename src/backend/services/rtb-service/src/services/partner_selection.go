@@ -0,0 +1,265 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// ErrPartnerSelectionDisabled is returned when auctions are not capped at a number of partners
+var ErrPartnerSelectionDisabled = errors.New("partner selection disabled")
+
+// selectionWindowHours is how many hours of calls partner selection ranks partners on
+const selectionWindowHours = 6
+
+// minSelectionCalls is the fewest calls to a partner in a vertical within the window before its
+// stats are trusted to rank it; partners with fewer are always contacted so their stats warm up
+const minSelectionCalls = 20
+
+// selectionKey identifies a partner's record in one vertical
+type selectionKey struct {
+	partnerID string
+	vertical  string
+}
+
+// selectionWindow counts a partner's calls and wins in one vertical within one hour
+type selectionWindow struct {
+	hour          int64
+	calls         uint64
+	bids          uint64
+	wins          uint64
+	clearingTotal float64
+}
+
+// selectionStats is a partner's rolling record in a vertical
+type selectionStats struct {
+	calls            uint64
+	bidRate          float64
+	avgClearingPrice float64
+}
+
+// score ranks partners by the revenue a call to them is expected to bring
+func (s selectionStats) score() float64 {
+	return s.bidRate * s.avgClearingPrice
+}
+
+// partnerChoice is partner selection's decision about one eligible partner
+type partnerChoice struct {
+	partnerID string
+	score     float64
+	reason    models.Reason
+}
+
+// partnerSelection keeps rolling per-partner, per-vertical bid and win counts and picks which
+// eligible partners an auction contacts when auctions are capped at max partners. In fair mode
+// partners are picked from the hash ring instead of by their stats.
+type partnerSelection struct {
+	max     int
+	mode    string
+	ring    *fairRing // nil outside fair mode
+	traffic *trafficMix
+	clock   utils.Clock
+	mutex   sync.Mutex
+	windows map[selectionKey]*[selectionWindowHours]selectionWindow
+}
+
+// newPartnerSelection creates partner selection, or returns nil when auctions contact every
+// eligible partner
+func newPartnerSelection(cfg *config.Config, clock utils.Clock) *partnerSelection {
+	if cfg.MaxPartnersPerAuction <= 0 {
+		return nil
+	}
+	selection := &partnerSelection{
+		max:     cfg.MaxPartnersPerAuction,
+		mode:    cfg.PartnerSelectionMode,
+		traffic: newTrafficMix(),
+		clock:   clock,
+		windows: make(map[selectionKey]*[selectionWindowHours]selectionWindow),
+	}
+	if selection.mode == "" {
+		selection.mode = config.PartnerSelectionPerformance
+	}
+	if selection.mode == config.PartnerSelectionFair {
+		selection.ring = newFairRing(cfg.Partners)
+	}
+	return selection
+}
+
+// recordCall counts a call to a partner in a vertical, and whether it returned a valid bid
+func (p *partnerSelection) recordCall(partnerID, vertical string, bid bool) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	window := p.current(selectionKey{partnerID: partnerID, vertical: vertical})
+	window.calls++
+	if bid {
+		window.bids++
+	}
+}
+
+// recordWin counts an auction in a vertical a partner won at price
+func (p *partnerSelection) recordWin(partnerID, vertical string, price float64) {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	window := p.current(selectionKey{partnerID: partnerID, vertical: vertical})
+	window.wins++
+	window.clearingTotal += price
+}
+
+// current returns the window for this hour, resetting a stale slot. Callers hold the mutex.
+func (p *partnerSelection) current(key selectionKey) *selectionWindow {
+	windows, exists := p.windows[key]
+	if !exists {
+		windows = &[selectionWindowHours]selectionWindow{}
+		p.windows[key] = windows
+	}
+	hour := p.clock.Now().Unix() / int64(time.Hour/time.Second)
+	window := &windows[hour%selectionWindowHours]
+	if window.hour != hour {
+		*window = selectionWindow{hour: hour}
+	}
+	return window
+}
+
+// snapshot returns the rolling stats of each partner in a vertical over the window
+func (p *partnerSelection) snapshot(vertical string, partnerIDs []string) map[string]selectionStats {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	oldest := p.clock.Now().Unix()/int64(time.Hour/time.Second) - selectionWindowHours + 1
+	stats := make(map[string]selectionStats, len(partnerIDs))
+	for _, partnerID := range partnerIDs {
+		windows, exists := p.windows[selectionKey{partnerID: partnerID, vertical: vertical}]
+		if !exists {
+			continue
+		}
+		var total selectionWindow
+		for _, window := range windows {
+			if window.hour < oldest {
+				continue
+			}
+			total.calls += window.calls
+			total.bids += window.bids
+			total.wins += window.wins
+			total.clearingTotal += window.clearingTotal
+		}
+		partnerStats := selectionStats{calls: total.calls, bidRate: ratio(total.bids, total.calls)}
+		if total.wins > 0 {
+			partnerStats.avgClearingPrice = total.clearingTotal / float64(total.wins)
+		}
+		stats[partnerID] = partnerStats
+	}
+	return stats
+}
+
+// selectPartners picks the eligible partners an auction contacts: every partner with an active
+// deal for the lead, every partner whose stats are still cold, and then the best scoring of the
+// rest up to max in all. Ties go to the lower partner ID, so the same stats always pick the same
+// partners. It returns the choices for every eligible partner, selected ones first.
+func selectPartners(eligible []string, stats map[string]selectionStats, dealPartners map[string]bool, max int) ([]partnerChoice, int) {
+	choices := make([]partnerChoice, 0, len(eligible))
+	var ranked []partnerChoice
+	for _, partnerID := range eligible {
+		partnerStats := stats[partnerID]
+		choice := partnerChoice{partnerID: partnerID, score: partnerStats.score()}
+		switch {
+		case dealPartners[partnerID]:
+			choice.reason = models.ReasonSelectedDeal
+		case partnerStats.calls < minSelectionCalls:
+			choice.reason = models.ReasonSelectedCold
+		default:
+			ranked = append(ranked, choice)
+			continue
+		}
+		choices = append(choices, choice)
+	}
+	sort.Slice(choices, func(i, j int) bool { return choices[i].partnerID < choices[j].partnerID })
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].partnerID < ranked[j].partnerID
+	})
+
+	selected := len(choices)
+	for _, choice := range ranked {
+		if selected < max {
+			choice.reason = models.ReasonSelectedRanked
+			selected++
+		} else {
+			choice.reason = models.ReasonNotSelected
+		}
+		choices = append(choices, choice)
+	}
+	return choices, selected
+}
+
+// dealPartners returns the partners holding a deal the request is eligible for: unexpired, and
+// covering its vertical and zip
+func dealPartners(deals map[string]*config.DealConfig, request *models.BidRequest, now time.Time) map[string]bool {
+	partners := make(map[string]bool)
+	for _, deal := range deals {
+		switch {
+		case !deal.ExpiresAt.IsZero() && !now.Before(deal.ExpiresAt):
+		case len(deal.Verticals) > 0 && !containsFold(deal.Verticals, request.Vertical):
+		case len(deal.Zips) > 0 && !containsFold(deal.Zips, requestZip(request)):
+		default:
+			partners[deal.PartnerID] = true
+		}
+	}
+	return partners
+}
+
+// choosePartners narrows the eligible partners to those partner selection picks, recording each
+// decision in reasons and the partners offered the auction in the traffic mix, or returns
+// them all when auctions are not capped
+func (s *AuctionService) choosePartners(ctx context.Context, request *models.BidRequest, eligible []string, reasons *models.ReasonCollector) []string {
+	if s.selection == nil {
+		return eligible
+	}
+	if len(eligible) <= s.selection.max {
+		s.recordTrafficMix(ctx, request, eligible)
+		return eligible
+	}
+
+	deals := dealPartners(s.config.Deals, request, s.clock.Now())
+	var choices []partnerChoice
+	var selected int
+	if s.selection.ring != nil {
+		choices, selected = selectPartnersFairly(s.selection.ring, fairnessKey(request), eligible, deals, s.selection.max)
+	} else {
+		choices, selected = selectPartners(eligible, s.selection.snapshot(request.Vertical, eligible), deals, s.selection.max)
+	}
+
+	chosen := make([]string, 0, selected)
+	for _, choice := range choices {
+		reasons.Selection(choice.partnerID, choice.reason, choice.score)
+		if choice.reason == models.ReasonNotSelected {
+			reasons.Skip(choice.partnerID, models.ReasonNotSelected)
+			continue
+		}
+		chosen = append(chosen, choice.partnerID)
+	}
+	s.recordTrafficMix(ctx, request, chosen)
+	return chosen
+}
+
+// PartnerTrafficMix compares the verticals and regions of the traffic each partner was offered
+// with all auctions capped by partner selection
+func (s *AuctionService) PartnerTrafficMix() (*TrafficMixReport, error) {
+	if s.selection == nil {
+		return nil, ErrPartnerSelectionDisabled
+	}
+	return s.selection.traffic.report(s.selection.mode), nil
+}
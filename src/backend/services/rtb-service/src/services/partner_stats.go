@@ -0,0 +1,99 @@
+package services
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// partnerCounters holds one partner's statistics
+type partnerCounters struct {
+	failures     atomic.Int64
+	noBids       atomic.Int64
+	schemaErrors atomic.Int64
+
+	// recentMutex guards recent, the partner's latest schema errors, oldest first
+	recentMutex sync.Mutex
+	recent      []SchemaError
+}
+
+// partnerStats tracks per-partner counters. Each partner's counters are created once and then
+// updated atomically, so recording a failure never blocks auctions or readers of the stats.
+type partnerStats struct {
+	partners sync.Map // partner ID -> *partnerCounters
+}
+
+// counters returns the counters for a partner, creating them on first use
+func (p *partnerStats) counters(partnerID string) *partnerCounters {
+	if counters, exists := p.partners.Load(partnerID); exists {
+		return counters.(*partnerCounters)
+	}
+	counters, _ := p.partners.LoadOrStore(partnerID, &partnerCounters{})
+	return counters.(*partnerCounters)
+}
+
+// RecordFailure counts a failed call to a partner
+func (p *partnerStats) RecordFailure(partnerID string) {
+	p.counters(partnerID).failures.Add(1)
+}
+
+// RecordNoBid counts a call in which a partner declined to bid
+func (p *partnerStats) RecordNoBid(partnerID string) {
+	p.counters(partnerID).noBids.Add(1)
+}
+
+// NoBids returns the calls counted for a partner in which it declined to bid
+func (p *partnerStats) NoBids(partnerID string) int {
+	if counters, exists := p.partners.Load(partnerID); exists {
+		return int(counters.(*partnerCounters).noBids.Load())
+	}
+	return 0
+}
+
+// Failures returns the failed calls counted for a partner
+func (p *partnerStats) Failures(partnerID string) int {
+	if counters, exists := p.partners.Load(partnerID); exists {
+		return int(counters.(*partnerCounters).failures.Load())
+	}
+	return 0
+}
+
+// Snapshot returns the failure counts of every partner that has failed
+func (p *partnerStats) Snapshot() map[string]int {
+	stats := make(map[string]int)
+	p.partners.Range(func(key, value any) bool {
+		stats[key.(string)] = int(value.(*partnerCounters).failures.Load())
+		return true
+	})
+	return stats
+}
+
+// RecordSchemaError counts a partner response rejected by validation, keeping the latest errors
+// so partners can be sent the failing field and value
+func (p *partnerStats) RecordSchemaError(partnerID string, schemaErr SchemaError) {
+	counters := p.counters(partnerID)
+	counters.schemaErrors.Add(1)
+
+	counters.recentMutex.Lock()
+	defer counters.recentMutex.Unlock()
+	if len(counters.recent) == maxRecentSchemaErrors {
+		counters.recent = append(counters.recent[:0], counters.recent[1:]...)
+	}
+	counters.recent = append(counters.recent, schemaErr)
+}
+
+// SchemaErrors returns the schema errors counted for a partner and a copy of the latest ones
+func (p *partnerStats) SchemaErrors(partnerID string) (int, []SchemaError) {
+	value, exists := p.partners.Load(partnerID)
+	if !exists {
+		return 0, nil
+	}
+	counters := value.(*partnerCounters)
+	counters.recentMutex.Lock()
+	defer counters.recentMutex.Unlock()
+	return int(counters.schemaErrors.Load()), append([]SchemaError(nil), counters.recent...)
+}
+
+// RestoreFailures adds failures counted before a restart to a partner's count
+func (p *partnerStats) RestoreFailures(partnerID string, failures int) {
+	p.counters(partnerID).failures.Add(int64(failures))
+}
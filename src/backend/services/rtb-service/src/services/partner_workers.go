@@ -0,0 +1,251 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// partnerWorkerIdle is how long a partner worker waits for another call before exiting
+const partnerWorkerIdle = 30 * time.Second
+
+// collectionDrainGrace is how long a timed-out collection waits for its cancelled partner calls
+// to finish, so their errors reach the auction's reasons rather than an abandoned round
+const collectionDrainGrace = 50 * time.Millisecond
+
+// auctionRound is the state an auction shares with its partner calls. Calls add their
+// bids under the mutex and the last one to finish closes done.
+type auctionRound struct {
+	ctx      context.Context
+	calls    context.Context // parent of the partner calls, cancelled when collection ends early
+	early    *earlyStop      // nil unless collection may end early
+	request  *models.BidRequest
+	onBid    BidObserver
+	auction  *models.AuctionContext
+	segment  string // lead segment for negative caching, empty when not cached
+	override auctionOverride
+	pending  atomic.Int32
+	done     chan struct{}
+	mutex    sync.Mutex
+	bids     []*models.Bid
+	summary  models.AuctionSummary
+}
+
+// newAuctionRound creates a round holding one pending call for the auction itself, released
+// by finish once every partner call has been started
+func newAuctionRound(ctx context.Context, request *models.BidRequest, onBid BidObserver, bids []*models.Bid) *auctionRound {
+	round := &auctionRound{ctx: ctx, calls: ctx, request: request, onBid: onBid, auction: models.AuctionFromContext(ctx), done: make(chan struct{}), bids: bids}
+	round.pending.Store(1)
+	return round
+}
+
+// addBids appends a partner's validated bids to the round
+func (r *auctionRound) addBids(bids []*models.Bid) {
+	r.mutex.Lock()
+	r.bids = append(r.bids, bids...)
+	if r.early != nil {
+		for _, bid := range bids {
+			r.early.offer(bid)
+		}
+	}
+	r.mutex.Unlock()
+}
+
+// allowEarlyStop lets partner calls be cancelled once the round's winner is decided, with stop
+// deciding when that is
+func (r *auctionRound) allowEarlyStop(stop *earlyStop) {
+	if stop == nil {
+		return
+	}
+	r.early = stop
+	r.calls, stop.cancel = context.WithCancelCause(r.ctx)
+}
+
+// endCalls releases the partner calls' parent context once collection returns
+func (r *auctionRound) endCalls() {
+	if r.early != nil {
+		r.early.cancel(nil)
+	}
+}
+
+// expectCall adds a partner about to be called to those collection may stop waiting for
+func (r *auctionRound) expectCall(partnerID string, partner *config.PartnerConfig) {
+	if r.early == nil {
+		return
+	}
+	r.mutex.Lock()
+	r.early.expect(partnerID, partner, time.Now())
+	r.mutex.Unlock()
+}
+
+// settleCall removes a partner whose call ended or never started from the pending partners,
+// ending collection if the winner is now decided
+func (r *auctionRound) settleCall(partnerID string) {
+	if r.early == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.early.pending, partnerID)
+	r.checkEarlyStop()
+}
+
+// armEarlyStop starts checking whether collection can end early, once every partner call has
+// been started. Partner budget holds leave the winner to be charged after selection, so an
+// auction holding any is always waited out.
+func (r *auctionRound) armEarlyStop(holds *budgetHolds) {
+	if r.early == nil || !holds.empty() {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.early.armed = true
+	r.checkEarlyStop()
+}
+
+// checkEarlyStop cancels the pending partner calls when the winner is decided. Callers hold the mutex.
+func (r *auctionRound) checkEarlyStop() {
+	if r.early.decided() {
+		r.early.stop(r.ctx, time.Now())
+	}
+}
+
+// stoppedEarly reports whether a partner call ended because collection ended early, counting it
+func (r *auctionRound) stoppedEarly(callCtx context.Context) bool {
+	if r.early == nil || !errors.Is(context.Cause(callCtx), errEarlyTerminated) {
+		return false
+	}
+	r.mutex.Lock()
+	r.summary.EarlyTerminatedPartners++
+	r.mutex.Unlock()
+	return true
+}
+
+// recordContact counts a partner call started for the round
+func (r *auctionRound) recordContact() {
+	r.mutex.Lock()
+	r.summary.PartnersContacted++
+	r.mutex.Unlock()
+}
+
+// recordCall counts a finished partner call's bids, how many of them were valid, and whether
+// the partner ran out its timeout
+func (r *auctionRound) recordCall(timedOut bool, bids, validBids int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if bids > 0 {
+		r.summary.PartnersBid++
+	}
+	r.summary.ValidBids += validBids
+	if timedOut {
+		r.summary.TimedOutPartners++
+	}
+}
+
+// drain waits up to grace for every started call to finish, reporting whether they all did
+func (r *auctionRound) drain(grace time.Duration) bool {
+	timer := time.NewTimer(grace)
+	defer timer.Stop()
+	select {
+	case <-r.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// finish marks one pending call complete, closing done after the last
+func (r *auctionRound) finish() {
+	if r.pending.Add(-1) == 0 {
+		close(r.done)
+	}
+}
+
+// partnerJob is one partner call within an auction
+type partnerJob struct {
+	round     *auctionRound
+	partnerID string
+	partner   *config.PartnerConfig
+}
+
+// partnerWorkers runs partner calls on a bounded set of goroutines. Workers start on demand
+// and exit after sitting idle, so a busy service reuses them across auctions.
+type partnerWorkers struct {
+	jobs      chan partnerJob
+	slots     chan struct{}
+	quit      chan struct{}
+	closeOnce sync.Once
+	run       func(partnerJob)
+}
+
+// newPartnerWorkers creates a pool running at most limit calls at once
+func newPartnerWorkers(limit int, run func(partnerJob)) *partnerWorkers {
+	return &partnerWorkers{
+		jobs:  make(chan partnerJob),
+		slots: make(chan struct{}, limit),
+		quit:  make(chan struct{}),
+		run:   run,
+	}
+}
+
+// submit hands job to an idle worker, starting one when none is free and the limit allows.
+// At the limit it waits for a worker, returning false if ctx ends or the pool closes first.
+func (w *partnerWorkers) submit(ctx context.Context, job partnerJob) bool {
+	select {
+	case w.jobs <- job:
+		return true
+	default:
+	}
+
+	select {
+	case w.jobs <- job:
+		return true
+	case w.slots <- struct{}{}:
+		go w.work(job)
+		return true
+	case <-ctx.Done():
+		return false
+	case <-w.quit:
+		return false
+	}
+}
+
+// work runs job and then any further jobs until the worker idles out or the pool closes
+func (w *partnerWorkers) work(job partnerJob) {
+	defer func() { <-w.slots }()
+	idle := time.NewTimer(partnerWorkerIdle)
+	defer idle.Stop()
+
+	for {
+		w.run(job)
+
+		if !idle.Stop() {
+			select {
+			case <-idle.C:
+			default:
+			}
+		}
+		idle.Reset(partnerWorkerIdle)
+
+		select {
+		case job = <-w.jobs:
+		case <-idle.C:
+			return
+		case <-w.quit:
+			return
+		}
+	}
+}
+
+// Close stops idle workers; calls already running finish normally
+func (w *partnerWorkers) Close() {
+	if w == nil {
+		return
+	}
+	w.closeOnce.Do(func() { close(w.quit) })
+}
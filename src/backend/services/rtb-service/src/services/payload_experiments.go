@@ -0,0 +1,67 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// payloadVariant is the treatment of a partner's payload experiment: the partner config sending
+// the variant payload and the adapter building it
+type payloadVariant struct {
+	config  *config.PartnerConfig
+	adapter PartnerAdapter
+}
+
+// newPayloadVariants builds the treatment of each partner's payload experiment, authenticated as
+// the partner is
+func newPayloadVariants(cfg *config.Config, clock utils.Clock) (map[string]*payloadVariant, error) {
+	variants := make(map[string]*payloadVariant)
+	for partnerID, partner := range cfg.Partners {
+		treatment := partner.PayloadVariantConfig()
+		if treatment == nil {
+			continue
+		}
+		adapter, err := NewPartnerAdapter(treatment)
+		if err != nil {
+			return nil, fmt.Errorf("partner %s payload experiment: %w", partnerID, err)
+		}
+		auth, err := NewPartnerAuthenticator(treatment, clock)
+		if err != nil {
+			return nil, fmt.Errorf("partner %s payload experiment: %w", partnerID, err)
+		}
+		variants[partnerID] = &payloadVariant{config: treatment, adapter: &authenticatedAdapter{PartnerAdapter: adapter, auth: auth}}
+	}
+	return variants, nil
+}
+
+// payloadVariantFor places a partner call in a variant of the partner's payload experiment by
+// hashing the lead ID, so retries of a lead keep their variant. It returns the partner config the
+// call sends its payload with and the call's assignment, which is nil when the call is left out
+// of the experiment: the experiment has ended, or the request has no lead ID.
+func (s *AuctionService) payloadVariantFor(partnerID string, partner *config.PartnerConfig, request *models.BidRequest, now time.Time) (*config.PartnerConfig, *models.ExperimentAssignment) {
+	experiment := partner.PayloadExperiment
+	variant, exists := s.payloadVariants[partnerID]
+	if !exists || !experiment.Active(now) || request.LeadID == "" {
+		return partner, nil
+	}
+	if experimentBucket(partnerID+"/"+experiment.ID, request.LeadID) < uint64(experiment.TrafficPercent*experimentBuckets/100) {
+		return variant.config, &models.ExperimentAssignment{ID: experiment.ID, Variant: config.ExperimentVariantTreatment}
+	}
+	return partner, &models.ExperimentAssignment{ID: experiment.ID, Variant: config.ExperimentVariantControl}
+}
+
+// bidPrices returns the prices of bids, or nil when there are none
+func bidPrices(bids []*models.Bid) []float64 {
+	if len(bids) == 0 {
+		return nil
+	}
+	prices := make([]float64, len(bids))
+	for i, bid := range bids {
+		prices[i] = bid.Price
+	}
+	return prices
+}
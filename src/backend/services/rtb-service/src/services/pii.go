@@ -0,0 +1,11 @@
+package services
+
+import (
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// SanitizeRequest returns a copy of the request safe for logs, events, buffers, and cache keys.
+// Only the partner-call path may use the raw request.
+func (s *AuctionService) SanitizeRequest(request *models.BidRequest) *models.BidRequest {
+	return request.Sanitized(s.config.PIIPolicy)
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// partnerPricingAllowed reports whether the partner's bids can be priced for the request's
+// vertical; revenue-share bids need the vertical's expected premium
+func partnerPricingAllowed(cfg *config.Config, partner *config.PartnerConfig, request *models.BidRequest) bool {
+	if partner.Pricing() != config.PricingModelRevShare {
+		return true
+	}
+	_, exists := cfg.ExpectedPremium(request.Vertical)
+	return exists
+}
+
+// normalizeBid records the partner's pricing model on a bid and its expected cost per lead.
+// A revenue-share bid is a percentage of the vertical's expected premium, kept at full precision
+// for ranking until roundClearingPrices runs on the winners.
+func normalizeBid(cfg *config.Config, partner *config.PartnerConfig, request *models.BidRequest, bid *models.Bid) {
+	bid.PricingModel = partner.Pricing()
+	bid.NormalizedPrice = bid.Price
+	if bid.PricingModel == config.PricingModelRevShare {
+		premium, _ := cfg.ExpectedPremium(request.Vertical)
+		bid.NormalizedPrice = bid.Price * premium / 100
+	}
+}
+
+// roundClearingPrices rounds winners' prices to the configured precision before they reach the
+// response, audit log, webhooks, and reports. A revenue-share bid's Price is a percentage and
+// stays as bid; its cost per lead is in NormalizedPrice.
+func (s *AuctionService) roundClearingPrices(winners []*models.Bid) {
+	for _, bid := range winners {
+		if bid.PricingModel != config.PricingModelRevShare {
+			bid.Price = s.rounder.Round(bid.Price)
+		}
+		bid.NormalizedPrice = s.rounder.Round(bid.NormalizedPrice)
+		bid.BidPrice = s.rounder.Round(bid.BidPrice)
+	}
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"math/rand"
+
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Purposes of random draws, keeping each deterministic sequence independent of the others
+const (
+	randomPartner   = "partner:"
+	randomRecording = "recording"
+	randomExport    = "export"
+	randomAssets    = "assets"
+)
+
+// randFor returns the random numbers for one use in an auction. In deterministic mode they are
+// seeded from the request ID and purpose, so the same request draws the same numbers however
+// its partner calls interleave; otherwise they come from the service's shared generator, which
+// is safe for concurrent use because its source is.
+func (s *AuctionService) randFor(request *models.BidRequest, purpose string) *rand.Rand {
+	if s.config.DeterministicMode {
+		return rand.New(rand.NewSource(utils.RequestSeed(request.RequestID, purpose)))
+	}
+	return s.random
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"errors"
+	"io"
+
+	"github.com/yourdomain/rtb-service/src/audit"
+	"github.com/yourdomain/rtb-service/src/reconcile"
+)
+
+// ErrReconciliationDisabled is returned for spend reports and reconciliations without an audit log
+var ErrReconciliationDisabled = errors.New("partner reconciliation requires the audit log")
+
+// PartnerSpend totals a partner's winning bids in a window by day and vertical, from the audit log
+func (s *AuctionService) PartnerSpend(partnerID string, window reconcile.Window) (*reconcile.SpendReport, error) {
+	wins, err := s.partnerWins(partnerID, window)
+	if err != nil {
+		return nil, err
+	}
+	return reconcile.Spend(partnerID, window, wins), nil
+}
+
+// ReconcilePartner compares a partner's records with its winning bids in a window from the audit
+// log, reporting the days that disagree by more than tolerance
+func (s *AuctionService) ReconcilePartner(partnerID string, window reconcile.Window, records []reconcile.PartnerRecord, tolerance float64) (*reconcile.Result, error) {
+	wins, err := s.partnerWins(partnerID, window)
+	if err != nil {
+		return nil, err
+	}
+	return reconcile.Reconcile(partnerID, window, wins, records, tolerance), nil
+}
+
+// partnerWins reads a partner's audit records in a window, oldest file first. Records still queued
+// for the audit file are not seen. The active file may end in a partly written line, which is
+// skipped; an unreadable line anywhere else fails the read.
+func (s *AuctionService) partnerWins(partnerID string, window reconcile.Window) ([]audit.Record, error) {
+	if s.config.Audit == nil || !s.config.Audit.Enabled {
+		return nil, ErrReconciliationDisabled
+	}
+	files, err := audit.Files(s.config.Audit.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var wins []audit.Record
+	for _, file := range files {
+		reader := audit.NewFileReader(file)
+		wins, err = readPartnerWins(reader, partnerID, window, file == s.config.Audit.Path, wins)
+		reader.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return wins, nil
+}
+
+// readPartnerWins appends a partner's records in a window from one audit file to wins
+func readPartnerWins(reader *audit.Reader, partnerID string, window reconcile.Window, active bool, wins []audit.Record) ([]audit.Record, error) {
+	for {
+		record, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return wins, nil
+		}
+		if err != nil {
+			// Only the last line of the active file can be partly written
+			if _, nextErr := reader.Next(); active && errors.Is(nextErr, io.EOF) {
+				return wins, nil
+			}
+			return nil, err
+		}
+		if record.PartnerID == partnerID && window.Contains(record.Timestamp) {
+			wins = append(wins, record)
+		}
+	}
+}
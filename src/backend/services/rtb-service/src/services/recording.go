@@ -0,0 +1,203 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// Reasons a sampled recording is not written
+const (
+	recordingDropBufferFull = "buffer_full"
+	recordingDropSizeLimit  = "size_limit"
+	recordingDropWriteError = "write_error"
+)
+
+// recordingWriter appends sampled recordings to the recording file from a background goroutine,
+// dropping recordings rather than blocking auctions
+type recordingWriter struct {
+	config     *config.RecordingConfig
+	recordings chan *models.Recording
+	done       chan struct{}
+	mutex      sync.RWMutex
+	closed     bool
+	file       *os.File
+	buffer     *bufio.Writer
+	size       int64
+}
+
+// newRecordingWriter opens the recording file for appending, or returns nil when recording is disabled
+func newRecordingWriter(cfg *config.RecordingConfig) (*recordingWriter, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+
+	file, err := os.OpenFile(cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("opening recording file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("opening recording file: %w", err)
+	}
+
+	w := &recordingWriter{
+		config:     cfg,
+		recordings: make(chan *models.Recording, cfg.BufferSize),
+		done:       make(chan struct{}),
+		file:       file,
+		buffer:     bufio.NewWriter(file),
+		size:       info.Size(),
+	}
+	go w.run()
+	return w, nil
+}
+
+// sample reports whether an auction should be recorded, drawing from random
+func (w *recordingWriter) sample(random *rand.Rand) bool {
+	return random.Float64() < w.config.SampleRate
+}
+
+// write queues a finished recording
+func (w *recordingWriter) write(recording *models.Recording) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+	if w.closed {
+		return
+	}
+	select {
+	case w.recordings <- recording:
+	default:
+		recordingsDroppedTotal.WithLabelValues(recordingDropBufferFull).Inc()
+	}
+}
+
+// run writes queued recordings as JSON lines, flushing whenever the queue drains
+func (w *recordingWriter) run() {
+	defer close(w.done)
+	for recording := range w.recordings {
+		line, err := json.Marshal(recording)
+		if err != nil {
+			recordingsDroppedTotal.WithLabelValues(recordingDropWriteError).Inc()
+			continue
+		}
+		line = append(line, '\n')
+		if w.size+int64(len(line)) > w.config.MaxSizeBytes {
+			recordingsDroppedTotal.WithLabelValues(recordingDropSizeLimit).Inc()
+			continue
+		}
+		n, err := w.buffer.Write(line)
+		w.size += int64(n)
+		if err != nil {
+			recordingsDroppedTotal.WithLabelValues(recordingDropWriteError).Inc()
+		}
+		if len(w.recordings) == 0 {
+			w.buffer.Flush()
+		}
+	}
+}
+
+// Close writes queued recordings and closes the file
+func (w *recordingWriter) Close() error {
+	if w == nil {
+		return nil
+	}
+
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	close(w.recordings)
+	w.mutex.Unlock()
+
+	<-w.done
+	if err := w.buffer.Flush(); err != nil {
+		w.file.Close()
+		return err
+	}
+	return w.file.Close()
+}
+
+// startRecording returns ctx carrying a new recording when the auction is sampled. Dry runs and
+// replays are never recorded.
+func (s *AuctionService) startRecording(ctx context.Context, request *models.BidRequest) (context.Context, *models.Recording) {
+	if s.recorder == nil || request == nil || models.DryRunFromContext(ctx) != nil || models.ReplayFromContext(ctx) != nil ||
+		!s.recorder.sample(s.randFor(request, randomRecording)) {
+		return ctx, nil
+	}
+	recording := models.NewRecording(request, s.clock.Now())
+	recording.Region = s.config.ServiceRegion
+	return models.ContextWithRecording(ctx, recording), recording
+}
+
+// finishRecording adds the auction outcome to a recording and queues it for writing
+func (s *AuctionService) finishRecording(recording *models.Recording, response *models.BidResponse, err error) {
+	if recording == nil {
+		return
+	}
+	if err != nil {
+		recording.Error = err.Error()
+	} else {
+		recording.Winners = models.WinnersOf(response.Bids)
+	}
+	s.recorder.write(recording)
+}
+
+// partnerResponse sends a partner request and reads the response, capturing the exchange when
+// the auction is recorded and passing it to capture when the call is captured. Replayed auctions
+// take the partner's next recorded exchange instead; a partner without one is treated as not bidding.
+// Responses past limit bytes fail without being read further.
+func (s *AuctionService) partnerResponse(ctx context.Context, partnerID string, limit int64, httpReq *http.Request, capture *partnerCall) (int, []byte, string, error) {
+	if replay := models.ReplayFromContext(ctx); replay != nil {
+		exchange, recorded := replay.Next(partnerID)
+		switch {
+		case !recorded:
+			return http.StatusNoContent, nil, "", nil
+		case exchange.Error != "":
+			return 0, nil, transportRetryCondition(ctx), fmt.Errorf("%w: calling %s: %s", ErrPartnerFailure, partnerID, exchange.Error)
+		default:
+			return exchange.Status, exchange.Body, "", nil
+		}
+	}
+
+	recording := models.RecordingFromContext(ctx)
+	resp, err := s.clients.client(partnerID).Do(httpReq)
+	if err != nil {
+		recordHandshakeFailure(partnerID, err)
+		recording.AddExchange(models.PartnerExchange{PartnerID: partnerID, Error: err.Error()})
+		capture.finish(nil, nil, err)
+		return 0, nil, transportRetryCondition(ctx), fmt.Errorf("%w: calling %s: %w", ErrPartnerFailure, partnerID, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := readPartnerBody(partnerID, resp, limit)
+	if err != nil {
+		recording.AddExchange(models.PartnerExchange{PartnerID: partnerID, Error: err.Error()})
+		capture.finish(resp, body, err)
+		// An oversized response would be as large on a retry, so it is not retried
+		var tooLarge *ResponseTooLargeError
+		if errors.As(err, &tooLarge) {
+			partnerResponsesTooLargeTotal.WithLabelValues(partnerID).Inc()
+			return 0, nil, "", fmt.Errorf("%w: reading response from %s: %w", ErrPartnerFailure, partnerID, err)
+		}
+		return 0, nil, transportRetryCondition(ctx), fmt.Errorf("%w: reading response from %s: %v", ErrPartnerFailure, partnerID, err)
+	}
+	recording.AddExchange(models.PartnerExchange{PartnerID: partnerID, Status: resp.StatusCode, Body: body})
+	capture.finish(resp, body, nil)
+	if limited := rateLimitFrom(resp, s.clock.Now()); limited != nil {
+		return resp.StatusCode, body, statusRetryCondition(limited), fmt.Errorf("%w: %s: %w", ErrPartnerFailure, partnerID, limited)
+	}
+	return resp.StatusCode, body, "", nil
+}
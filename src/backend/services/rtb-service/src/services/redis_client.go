@@ -0,0 +1,41 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-redis/redis/v8" // v8.11.5
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// newRedisClient creates a Redis client from configuration, returning nil when Redis is not configured
+func newRedisClient(cfg *config.RedisConfig) *redis.Client {
+	if cfg == nil {
+		return nil
+	}
+
+	return redis.NewClient(&redis.Options{
+		Addr:            fmt.Sprintf("%s:%d", cfg.Host, cfg.Port),
+		Password:        cfg.Password,
+		DB:              cfg.Database,
+		DialTimeout:     cfg.Timeout,
+		ReadTimeout:     cfg.Timeout,
+		WriteTimeout:    cfg.Timeout,
+		MaxRetries:      cfg.MaxRetries,
+		MinRetryBackoff: cfg.RetryInterval,
+	})
+}
+
+// CheckRedis verifies Redis connectivity, returning nil when Redis is not configured
+func (s *AuctionService) CheckRedis(ctx context.Context) error {
+	if s.redis == nil {
+		return nil
+	}
+	return s.redis.Ping(ctx).Err()
+}
+
+// RedisConfigured reports whether the service has a Redis connection configured
+func (s *AuctionService) RedisConfigured() bool {
+	return s.redis != nil
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8" // v8.11.5
+	"go.uber.org/zap"              // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Nonce key layout and bounds
+const (
+	nonceKeyPrefix  = "rtb:nonce:"
+	maxNonceLength  = 128
+	maxMemoryNonces = 100000
+)
+
+// Replay check outcomes reported by the replay metrics
+const (
+	replayAccepted    = "accepted"
+	replayMissing     = "missing"
+	replaySkewed      = "skewed"
+	replayReplayed    = "replayed"
+	replayUnavailable = "unavailable"
+	replayFailedOpen  = "failed_open"
+)
+
+// Replay protection errors
+var (
+	ErrReplayHeaders     = errors.New("request timestamp and nonce are required")
+	ErrReplaySkew        = errors.New("request timestamp outside the accepted clock skew")
+	ErrReplayNonce       = errors.New("request nonce already used")
+	ErrReplayUnavailable = errors.New("request nonce could not be checked")
+)
+
+// replayGuard rejects replayed requests from the API clients opted in to replay protection
+type replayGuard struct {
+	config  *config.ReplayProtectionConfig
+	clients map[string]string // API key -> client name
+	clock   utils.Clock
+	timeout time.Duration
+	logger  *zap.Logger
+	store   nonceStore
+}
+
+// newReplayGuard creates the guard when any client is opted in, otherwise nil
+func newReplayGuard(cfg *config.Config, client *redis.Client, clock utils.Clock) *replayGuard {
+	if cfg.ReplayProtection == nil || len(cfg.ReplayProtection.Clients) == 0 {
+		return nil
+	}
+
+	guard := &replayGuard{
+		config:  cfg.ReplayProtection,
+		clients: make(map[string]string),
+		clock:   clock,
+		timeout: time.Second,
+		logger:  zap.NewNop(),
+	}
+	for name, replayClient := range cfg.ReplayProtection.Clients {
+		for _, key := range replayClient.APIKeys {
+			guard.clients[key] = name
+		}
+	}
+	if client != nil {
+		guard.store = &redisNonceStore{client: client}
+		if cfg.Redis.Timeout > 0 {
+			guard.timeout = cfg.Redis.Timeout
+		}
+	} else {
+		guard.store = &memoryNonceStore{clock: clock, entries: make(map[string]time.Time)}
+	}
+	return guard
+}
+
+// nonceKey returns the key remembering a client's nonce. Client names and nonces are escaped so
+// one client's nonces never collide with another's.
+func nonceKey(client, nonce string) string {
+	return nonceKeyPrefix + url.QueryEscape(client) + ":" + url.QueryEscape(nonce)
+}
+
+// CheckReplay checks a request against replay protection. Requests from API keys that are not
+// opted in always pass. Opted-in requests need a Unix timestamp, in seconds, no further than the
+// configured skew from the service clock in either direction, and a nonce not used before within
+// the replay window. When the nonce store fails, the request passes under fail-open and is
+// refused with ErrReplayUnavailable otherwise.
+func (s *AuctionService) CheckReplay(ctx context.Context, apiKey, timestamp, nonce string) error {
+	if s.replays == nil {
+		return nil
+	}
+	client, exists := s.replays.clients[apiKey]
+	if !exists {
+		return nil
+	}
+
+	outcome, err := s.replays.check(ctx, client, timestamp, nonce)
+	replayChecksTotal.WithLabelValues(client, outcome).Inc()
+	return err
+}
+
+// check returns the outcome of a request's replay check and the error refusing it, if any
+func (r *replayGuard) check(ctx context.Context, client, timestamp, nonce string) (string, error) {
+	if timestamp == "" || nonce == "" {
+		return replayMissing, ErrReplayHeaders
+	}
+	if len(nonce) > maxNonceLength {
+		return replayMissing, fmt.Errorf("%w: nonce longer than %d bytes", ErrReplayHeaders, maxNonceLength)
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return replayMissing, fmt.Errorf("%w: timestamp must be Unix seconds", ErrReplayHeaders)
+	}
+	if skew := r.clock.Now().Sub(time.Unix(seconds, 0)).Abs(); skew > r.config.Skew() {
+		return replaySkewed, fmt.Errorf("%w: %v", ErrReplaySkew, skew)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+	claimed, err := r.store.Claim(ctx, nonceKey(client, nonce), r.config.Window())
+	switch {
+	case err != nil && r.config.FailOpen:
+		r.logger.Warn("nonce check failed, accepting request", zap.String("client", client), zap.Error(err))
+		return replayFailedOpen, nil
+	case err != nil:
+		r.logger.Error("nonce check failed, refusing request", zap.String("client", client), zap.Error(err))
+		return replayUnavailable, fmt.Errorf("%w: %v", ErrReplayUnavailable, err)
+	case !claimed:
+		return replayReplayed, ErrReplayNonce
+	}
+	return replayAccepted, nil
+}
+
+// nonceStore remembers claimed nonces until their TTL passes
+type nonceStore interface {
+	// Claim records key for ttl and reports whether it was unclaimed
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+}
+
+// redisNonceStore shares claimed nonces across instances
+type redisNonceStore struct {
+	client *redis.Client
+}
+
+func (r *redisNonceStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	return r.client.SetNX(ctx, key, "1", ttl).Result()
+}
+
+// memoryNonceStore keeps claimed nonces for a single instance when Redis is not configured
+type memoryNonceStore struct {
+	clock   utils.Clock
+	mutex   sync.Mutex
+	entries map[string]time.Time // key -> expiry
+}
+
+func (m *memoryNonceStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	now := m.clock.Now()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if expiresAt, exists := m.entries[key]; exists && now.Before(expiresAt) {
+		return false, nil
+	}
+	if len(m.entries) >= maxMemoryNonces {
+		m.evict(now)
+	}
+	m.entries[key] = now.Add(ttl)
+	return true, nil
+}
+
+// evict drops expired nonces, and when none have expired, the one expiring soonest. Callers hold
+// the mutex.
+func (m *memoryNonceStore) evict(now time.Time) {
+	var oldest string
+	var oldestExpiry time.Time
+	for key, expiresAt := range m.entries {
+		if !now.Before(expiresAt) {
+			delete(m.entries, key)
+			continue
+		}
+		if oldest == "" || expiresAt.Before(oldestExpiry) {
+			oldest, oldestExpiry = key, expiresAt
+		}
+	}
+	if len(m.entries) >= maxMemoryNonces && oldest != "" {
+		delete(m.entries, oldest)
+	}
+}
@@ -0,0 +1,455 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8" // v8.11.5
+	"go.uber.org/zap"              // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/webhooks"
+)
+
+// Reservation key layout and sweep batch size
+const (
+	reservationKeyPrefix  = "rtb:reservation:"
+	reservationExpiryKey  = "rtb:reservations:expiry"
+	reservationSweepBatch = 100
+)
+
+// Reservation errors
+var (
+	ErrReservationsDisabled = errors.New("reservations disabled")
+	ErrReservationExpired   = errors.New("reservation expired")
+	ErrReservationConfirmed = errors.New("reservation already confirmed")
+	ErrReservationsFull     = errors.New("too many pending reservations")
+)
+
+// Reservation states and confirmation outcomes; reserved, confirmed, and abandoned are also the
+// outcomes counted in metrics. A pending reservation past its expiry is abandoned by the
+// confirmation that finds it, exactly as the sweeper would have.
+const (
+	reservationPending          = "pending"
+	reservationReserved         = "reserved"
+	reservationConfirmed        = "confirmed"
+	reservationAlreadyConfirmed = "already_confirmed"
+	reservationAbandoned        = "abandoned"
+	reservationExpired          = "expired"
+)
+
+// Reservation is an auction response whose winners are held until confirmed with Token before ExpiresAt
+type Reservation struct {
+	*models.BidResponse
+	Token     string    `json:"reservation_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ReservationAbandonedEvent is the data of a reservation.abandoned webhook
+type ReservationAbandonedEvent struct {
+	RequestID string    `json:"request_id"`
+	LeadID    string    `json:"lead_id"`
+	Vertical  string    `json:"vertical"`
+	Winners   []string  `json:"winners"`
+	ExpiredAt time.Time `json:"expired_at"`
+	// AuctionSeq orders the reserved auction, when auction sequence numbers are enabled
+	AuctionSeq *models.AuctionSeq `json:"auction_seq,omitempty"`
+}
+
+// reservationRecord is a stored reservation. The request is stored sanitized so the store never
+// holds raw PII.
+type reservationRecord struct {
+	Token     string              `json:"token"`
+	ExpiresAt time.Time           `json:"expires_at"`
+	Request   *models.BidRequest  `json:"request"`
+	Response  *models.BidResponse `json:"response"`
+	// Floor is the auction's adaptive floor, which the stored response does not encode
+	Floor *models.AuctionFloor `json:"floor,omitempty"`
+}
+
+// reservationStore holds reservations. Confirm and Abandon each move a pending reservation out of
+// pending atomically, so exactly one of them wins for every reservation. Settled reservations are
+// kept until a further TTL has passed so repeated confirmations are recognized, and Confirm
+// returns the record of an already abandoned reservation with the expired outcome.
+type reservationStore interface {
+	Put(ctx context.Context, record *reservationRecord) error
+	Confirm(ctx context.Context, token string, now time.Time) (*reservationRecord, string, error)
+	Abandon(ctx context.Context, now time.Time, limit int) ([]*reservationRecord, error)
+}
+
+// newReservationStore selects Redis when available, falling back to an in-memory store
+func newReservationStore(cfg *config.ReservationsConfig, client *redis.Client) reservationStore {
+	if client != nil {
+		return &redisReservationStore{client: client, retention: cfg.TTL}
+	}
+	return &memoryReservationStore{
+		maxEntries: cfg.MaxEntries,
+		retention:  cfg.TTL,
+		entries:    make(map[string]*memoryReservation),
+	}
+}
+
+// confirmReservationScript confirms a pending reservation, or abandons it when it has expired.
+// Abandoned reservations are returned with their record so the caller can tell why they expired.
+// KEYS: reservation hash, expiry index; ARGV: now in milliseconds, token.
+var confirmReservationScript = redis.NewScript(`
+local state = redis.call('HGET', KEYS[1], 'state')
+if not state then return {'expired'} end
+if state == 'abandoned' then return {'expired', redis.call('HGET', KEYS[1], 'data')} end
+if state == 'confirmed' then return {'already_confirmed'} end
+redis.call('ZREM', KEYS[2], ARGV[2])
+local data = redis.call('HGET', KEYS[1], 'data')
+if tonumber(redis.call('HGET', KEYS[1], 'expires_at')) <= tonumber(ARGV[1]) then
+	redis.call('HSET', KEYS[1], 'state', 'abandoned')
+	return {'abandoned', data}
+end
+redis.call('HSET', KEYS[1], 'state', 'confirmed')
+return {'confirmed', data}
+`)
+
+// abandonReservationScript abandons a reservation that is still pending.
+// KEYS: reservation hash, expiry index; ARGV: token.
+var abandonReservationScript = redis.NewScript(`
+redis.call('ZREM', KEYS[2], ARGV[1])
+if redis.call('HGET', KEYS[1], 'state') ~= 'pending' then return false end
+redis.call('HSET', KEYS[1], 'state', 'abandoned')
+return redis.call('HGET', KEYS[1], 'data')
+`)
+
+// redisReservationStore keeps each reservation in a hash of its state, expiry, and JSON record,
+// indexed by expiry in a sorted set for the sweeper
+type redisReservationStore struct {
+	client    *redis.Client
+	retention time.Duration
+}
+
+func (r *redisReservationStore) Put(ctx context.Context, record *reservationRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	key := reservationKeyPrefix + record.Token
+	pipe := r.client.TxPipeline()
+	pipe.HSet(ctx, key, "state", reservationPending, "expires_at", record.ExpiresAt.UnixMilli(), "data", data)
+	pipe.PExpireAt(ctx, key, record.ExpiresAt.Add(r.retention))
+	pipe.ZAdd(ctx, reservationExpiryKey, &redis.Z{Score: float64(record.ExpiresAt.UnixMilli()), Member: record.Token})
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+func (r *redisReservationStore) Confirm(ctx context.Context, token string, now time.Time) (*reservationRecord, string, error) {
+	result, err := confirmReservationScript.Run(ctx, r.client, []string{reservationKeyPrefix + token, reservationExpiryKey}, now.UnixMilli(), token).Slice()
+	if err != nil {
+		return nil, "", err
+	}
+	outcome, _ := result[0].(string)
+	if len(result) < 2 {
+		return nil, outcome, nil
+	}
+	data, _ := result[1].(string)
+	if data == "" {
+		return nil, outcome, nil
+	}
+	var record reservationRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, "", err
+	}
+	return &record, outcome, nil
+}
+
+func (r *redisReservationStore) Abandon(ctx context.Context, now time.Time, limit int) ([]*reservationRecord, error) {
+	tokens, err := r.client.ZRangeByScore(ctx, reservationExpiryKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   formatMillis(now),
+		Count: int64(limit),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var abandoned []*reservationRecord
+	for _, token := range tokens {
+		data, err := abandonReservationScript.Run(ctx, r.client, []string{reservationKeyPrefix + token, reservationExpiryKey}, token).Text()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return abandoned, err
+		}
+		var record reservationRecord
+		if err := json.Unmarshal([]byte(data), &record); err != nil {
+			continue
+		}
+		abandoned = append(abandoned, &record)
+	}
+	return abandoned, nil
+}
+
+// memoryReservation is a stored reservation with its state
+type memoryReservation struct {
+	record *reservationRecord
+	state  string
+}
+
+// memoryReservationStore keeps reservations in a map bounded by maxEntries, refusing new
+// reservations rather than evicting held ones
+type memoryReservationStore struct {
+	maxEntries int
+	retention  time.Duration
+	mutex      sync.Mutex
+	entries    map[string]*memoryReservation
+}
+
+func (m *memoryReservationStore) Put(ctx context.Context, record *reservationRecord) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if len(m.entries) >= m.maxEntries {
+		m.prune(time.Now())
+		if len(m.entries) >= m.maxEntries {
+			return ErrReservationsFull
+		}
+	}
+	m.entries[record.Token] = &memoryReservation{record: record, state: reservationPending}
+	return nil
+}
+
+func (m *memoryReservationStore) Confirm(ctx context.Context, token string, now time.Time) (*reservationRecord, string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entry, exists := m.entries[token]
+	switch {
+	case !exists:
+		return nil, reservationExpired, nil
+	case entry.state == reservationAbandoned:
+		return entry.record, reservationExpired, nil
+	case entry.state == reservationConfirmed:
+		return nil, reservationAlreadyConfirmed, nil
+	case !now.Before(entry.record.ExpiresAt):
+		entry.state = reservationAbandoned
+		return entry.record, reservationAbandoned, nil
+	default:
+		entry.state = reservationConfirmed
+		return entry.record, reservationConfirmed, nil
+	}
+}
+
+func (m *memoryReservationStore) Abandon(ctx context.Context, now time.Time, limit int) ([]*reservationRecord, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var abandoned []*reservationRecord
+	for _, entry := range m.entries {
+		if len(abandoned) >= limit {
+			break
+		}
+		if entry.state == reservationPending && !now.Before(entry.record.ExpiresAt) {
+			entry.state = reservationAbandoned
+			abandoned = append(abandoned, entry.record)
+		}
+	}
+	m.prune(now)
+	return abandoned, nil
+}
+
+// prune drops settled reservations past their retention
+func (m *memoryReservationStore) prune(now time.Time) {
+	for token, entry := range m.entries {
+		if entry.state != reservationPending && now.After(entry.record.ExpiresAt.Add(m.retention)) {
+			delete(m.entries, token)
+		}
+	}
+}
+
+// reservationSweeper periodically abandons expired reservations
+type reservationSweeper struct {
+	config *config.ReservationsConfig
+	store  reservationStore
+	logger *zap.Logger
+	stop   chan struct{}
+	done   chan struct{}
+	once   sync.Once
+}
+
+// newReservationSweeper creates the reservation store and its sweeper when reservations are
+// enabled, otherwise nil. The sweeper starts with start.
+func newReservationSweeper(cfg *config.ReservationsConfig, client *redis.Client) *reservationSweeper {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	return &reservationSweeper{
+		config: cfg,
+		store:  newReservationStore(cfg, client),
+		logger: zap.NewNop(),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+}
+
+// SetLogger sets the logger used for failed sweeps
+func (r *reservationSweeper) SetLogger(logger *zap.Logger) {
+	if r != nil && logger != nil {
+		r.logger = logger
+	}
+}
+
+// start runs sweep every sweep interval until Close
+func (r *reservationSweeper) start(sweep func()) {
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.config.SweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				sweep()
+			}
+		}
+	}()
+}
+
+// Close stops the sweeper; reservations still pending are abandoned by a later sweep or confirmation
+func (r *reservationSweeper) Close() error {
+	if r == nil {
+		return nil
+	}
+	r.once.Do(func() {
+		close(r.stop)
+		<-r.done
+	})
+	return nil
+}
+
+// Reserve runs an auction and holds its winners for the reservation TTL without settling them:
+// partner wins, audit records, and webhooks wait for ConfirmReservation
+func (s *AuctionService) Reserve(ctx context.Context, request *models.BidRequest) (*Reservation, error) {
+	if s.reservations == nil {
+		return nil, ErrReservationsDisabled
+	}
+
+	response, err := s.RunAuction(models.ContextWithReservation(ctx), request)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := *response
+	stored.Debug = nil
+	record := &reservationRecord{
+		Token:     newReservationToken(),
+		ExpiresAt: s.clock.Now().Add(s.reservations.config.TTL).UTC(),
+		Request:   s.SanitizeRequest(request),
+		Response:  &stored,
+		Floor:     response.Floor,
+	}
+	// Winners cannot be held past the response's validity
+	if response.ValidUntil != nil && response.ValidUntil.Before(record.ExpiresAt) {
+		record.ExpiresAt = *response.ValidUntil
+	}
+	if err := s.reservations.store.Put(ctx, record); err != nil {
+		return nil, err
+	}
+	reservationsTotal.WithLabelValues(reservationReserved).Inc()
+	return &Reservation{BidResponse: response, Token: record.Token, ExpiresAt: record.ExpiresAt}, nil
+}
+
+// ConfirmReservation settles a reservation's winners and returns its final bids. It returns
+// ErrResponseExpired when the reserved response has passed its ValidUntil, ErrReservationExpired
+// for other unknown, expired, or abandoned tokens, and ErrReservationConfirmed for tokens already
+// confirmed.
+func (s *AuctionService) ConfirmReservation(ctx context.Context, token string) (*models.BidResponse, error) {
+	if s.reservations == nil {
+		return nil, ErrReservationsDisabled
+	}
+
+	record, outcome, err := s.reservations.store.Confirm(ctx, token, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	switch outcome {
+	case reservationConfirmed:
+		reservationsTotal.WithLabelValues(reservationConfirmed).Inc()
+		// Bids of partners that finished draining are no longer honored
+		if settling := s.settlingBids(record.Response.Bids); len(settling) < len(record.Response.Bids) {
+			response := *record.Response
+			response.Bids = settling
+			record.Response = &response
+		}
+		s.recordWins(ctx, record.Request, record.Response.Bids)
+		s.recordExperimentRevenue(ctx, record.Response)
+		s.auditWinners(ctx, record.Request, record.Response)
+		record.Response.Floor = record.Floor
+		s.notifyWinners(ctx, record.Request, record.Response)
+		return record.Response, nil
+	case reservationAlreadyConfirmed:
+		return nil, ErrReservationConfirmed
+	case reservationAbandoned:
+		s.abandonReservation(ctx, record)
+		return nil, s.reservationExpiredError(record)
+	default:
+		return nil, s.reservationExpiredError(record)
+	}
+}
+
+// sweepReservations abandons every expired reservation still pending
+func (s *AuctionService) sweepReservations() {
+	ctx := context.Background()
+	for {
+		abandoned, err := s.reservations.store.Abandon(ctx, s.clock.Now(), reservationSweepBatch)
+		for _, record := range abandoned {
+			s.abandonReservation(ctx, record)
+		}
+		if err != nil {
+			s.reservations.logger.Warn("sweeping expired reservations", zap.Error(err))
+			return
+		}
+		if len(abandoned) < reservationSweepBatch {
+			return
+		}
+	}
+}
+
+// abandonReservation counts an abandoned reservation and dispatches its reservation.abandoned webhook
+func (s *AuctionService) abandonReservation(ctx context.Context, record *reservationRecord) {
+	reservationsTotal.WithLabelValues(reservationAbandoned).Inc()
+	winners := make([]string, 0, len(record.Response.Bids))
+	for _, bid := range record.Response.Bids {
+		winners = append(winners, bid.PartnerID)
+	}
+	s.dispatchWebhook(ctx, webhooks.Event{
+		ID:        webhookEventID(config.WebhookEventReservationAbandoned, record.Request.RequestID, record.Token),
+		Type:      config.WebhookEventReservationAbandoned,
+		CreatedAt: s.clock.Now().UTC(),
+		Data: ReservationAbandonedEvent{
+			RequestID:  record.Request.RequestID,
+			LeadID:     record.Request.LeadID,
+			Vertical:   record.Request.Vertical,
+			Winners:    winners,
+			ExpiredAt:  record.ExpiresAt,
+			AuctionSeq: record.Response.AuctionSeq,
+		},
+	})
+}
+
+// newReservationToken returns a random 128-bit reservation token
+func newReservationToken() string {
+	var token [16]byte
+	if _, err := rand.Read(token[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(token[:])
+}
+
+// formatMillis formats t as a sorted set score in Unix milliseconds
+func formatMillis(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
@@ -0,0 +1,564 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// Reasons a partner response field fails validation
+const (
+	SchemaReasonMissing      = "missing"
+	SchemaReasonWrongType    = "wrong_type"
+	SchemaReasonNotFinite    = "not_finite"
+	SchemaReasonUnknownField = "unknown_field"
+)
+
+// Conversions lenient validation makes, counted per partner and field
+const (
+	coercionStringNumber = "string_number"
+	coercionNumberString = "number_string"
+	coercionStringList   = "string_list"
+	coercionUnknownField = "unknown_field"
+	coercionPrecision    = "precision"
+)
+
+// schemaPrecision is the scale lenient validation rounds prices and scores to, four decimal places
+const schemaPrecision = 1e4
+
+// maxSchemaValueBytes bounds the raw value a schema error keeps
+const maxSchemaValueBytes = 64
+
+// maxRecentSchemaErrors is how many schema errors partner stats keep per partner
+const maxRecentSchemaErrors = 10
+
+// SchemaError is a partner response field that failed validation. Field is the partner's name or
+// path for the field, and Value the raw value it sent, truncated. At is set when the error is
+// recorded in the partner stats.
+type SchemaError struct {
+	Field  string    `json:"field"`
+	Reason string    `json:"reason"`
+	Value  string    `json:"value,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Error implements the error interface
+func (e *SchemaError) Error() string {
+	if e.Value == "" {
+		return e.Reason
+	}
+	return fmt.Sprintf("%s: %s", e.Reason, e.Value)
+}
+
+// newSchemaError creates a schema error with value rendered as JSON and truncated
+func newSchemaError(field, reason string, value interface{}) *SchemaError {
+	schemaErr := &SchemaError{Field: field, Reason: reason}
+	if value == nil {
+		return schemaErr
+	}
+	raw, ok := value.(string)
+	if !ok {
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			encoded = []byte(fmt.Sprint(value))
+		}
+		raw = string(encoded)
+	} else {
+		raw = strconv.Quote(raw)
+	}
+	if len(raw) > maxSchemaValueBytes {
+		cut := maxSchemaValueBytes
+		for cut > 0 && !utf8.RuneStart(raw[cut]) {
+			cut--
+		}
+		raw = raw[:cut] + "..."
+	}
+	schemaErr.Value = raw
+	return schemaErr
+}
+
+// schemaAdapterError wraps a schema error in an adapter error naming its field
+func schemaAdapterError(adapter string, err error) error {
+	var schemaErr *SchemaError
+	if errors.As(err, &schemaErr) {
+		return &AdapterError{Adapter: adapter, Field: schemaErr.Field, Err: schemaErr}
+	}
+	return &AdapterError{Adapter: adapter, Err: err}
+}
+
+// responseSchema checks partner bid fields in the partner's validation mode, counting each
+// conversion lenient validation makes. The zero value is lenient.
+type responseSchema struct {
+	partnerID string
+	strict    bool
+}
+
+// newResponseSchema returns the response schema for a partner's validation mode
+func newResponseSchema(partner *config.PartnerConfig) responseSchema {
+	return responseSchema{partnerID: partner.ID, strict: partner.Validation() == config.ResponseValidationStrict}
+}
+
+// coerced counts a conversion lenient validation made
+func (s responseSchema) coerced(field, kind string) {
+	partnerCoercionsTotal.WithLabelValues(s.partnerID, field, kind).Inc()
+}
+
+// number accepts a JSON number, or in lenient mode a numeric string, that is finite
+func (s responseSchema) number(field string, value interface{}) (float64, error) {
+	var text string
+	switch v := value.(type) {
+	case json.Number:
+		text = v.String()
+	case string:
+		if s.strict {
+			return 0, newSchemaError(field, SchemaReasonWrongType, value)
+		}
+		text = strings.TrimSpace(v)
+	default:
+		return 0, newSchemaError(field, SchemaReasonWrongType, value)
+	}
+
+	number, err := s.parseNumber(field, text, value)
+	if err != nil {
+		return 0, err
+	}
+	if _, converted := value.(string); converted {
+		s.coerced(field, coercionStringNumber)
+	}
+	return number, nil
+}
+
+// parseNumber parses numeric text, rejecting NaN and infinities and, in lenient mode, rounding to
+// the schema precision
+func (s responseSchema) parseNumber(field, text string, value interface{}) (float64, error) {
+	number, err := strconv.ParseFloat(text, 64)
+	var numErr *strconv.NumError
+	if err != nil && !(errors.As(err, &numErr) && numErr.Err == strconv.ErrRange) {
+		return 0, newSchemaError(field, SchemaReasonWrongType, value)
+	}
+	if math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0, newSchemaError(field, SchemaReasonNotFinite, value)
+	}
+	if s.strict {
+		return number, nil
+	}
+	rounded := math.Round(number*schemaPrecision) / schemaPrecision
+	if rounded != number {
+		s.coerced(field, coercionPrecision)
+	}
+	return rounded, nil
+}
+
+// text accepts a JSON string, or in lenient mode a number used as an identifier
+func (s responseSchema) text(field string, value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		if !s.strict {
+			s.coerced(field, coercionNumberString)
+			return v.String(), nil
+		}
+	}
+	return "", newSchemaError(field, SchemaReasonWrongType, value)
+}
+
+// timestamp accepts an RFC 3339 string
+func (s responseSchema) timestamp(field string, value interface{}) (time.Time, error) {
+	text, ok := value.(string)
+	if !ok {
+		return time.Time{}, newSchemaError(field, SchemaReasonWrongType, value)
+	}
+	parsed, err := time.Parse(time.RFC3339, strings.TrimSpace(text))
+	if err != nil {
+		return time.Time{}, newSchemaError(field, SchemaReasonWrongType, value)
+	}
+	return parsed, nil
+}
+
+// domains accepts a list of strings, or in lenient mode a single string
+func (s responseSchema) domains(field string, value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		if !s.strict {
+			s.coerced(field, coercionStringList)
+			return []string{v}, nil
+		}
+	case []interface{}:
+		domains := make([]string, 0, len(v))
+		for _, item := range v {
+			domain, ok := item.(string)
+			if !ok {
+				return nil, newSchemaError(field, SchemaReasonWrongType, value)
+			}
+			domains = append(domains, domain)
+		}
+		return domains, nil
+	}
+	return nil, newSchemaError(field, SchemaReasonWrongType, value)
+}
+
+// flag accepts a JSON boolean
+func (s responseSchema) flag(field string, value interface{}) (bool, error) {
+	flag, ok := value.(bool)
+	if !ok {
+		return false, newSchemaError(field, SchemaReasonWrongType, value)
+	}
+	return flag, nil
+}
+
+// object accepts a JSON object
+func (s responseSchema) object(field string, value interface{}) (map[string]interface{}, error) {
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, newSchemaError(field, SchemaReasonWrongType, value)
+	}
+	return object, nil
+}
+
+// extension accepts a JSON object and keeps it encoded; the vertical's schema checks its keys later
+func (s responseSchema) extension(field string, value interface{}) (json.RawMessage, error) {
+	object, err := s.object(field, value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(object)
+}
+
+// unknown rejects a field outside the schema in strict mode and drops it in lenient mode
+func (s responseSchema) unknown(field string, value interface{}) error {
+	if s.strict {
+		return newSchemaError(field, SchemaReasonUnknownField, value)
+	}
+	s.coerced(field, coercionUnknownField)
+	return nil
+}
+
+// jsonBidRequired are the fields strict validation requires of every native JSON bid
+var jsonBidRequired = []string{"id", "price", "click_url"}
+
+// jsonBid builds a bid from a native JSON bid object decoded with json.Number values. Fields are
+// checked in name order so the same response always reports the same field; partner_id is
+// accepted but replaced by the configured partner later. In lenient mode a bid missing required
+// fields is left to bid validation, which drops it without rejecting the rest of the response.
+func (s responseSchema) jsonBid(document map[string]interface{}) (*models.Bid, error) {
+	if s.strict {
+		for _, field := range jsonBidRequired {
+			if document[field] == nil {
+				return nil, newSchemaError(field, SchemaReasonMissing, nil)
+			}
+		}
+	}
+
+	fields := make([]string, 0, len(document))
+	for field := range document {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	bid := &models.Bid{}
+	for _, field := range fields {
+		value := document[field]
+		if value == nil {
+			continue
+		}
+		var err error
+		switch field {
+		case "id":
+			bid.ID, err = s.text(field, value)
+		case "click_url":
+			bid.ClickURL, err = s.text(field, value)
+		case "deal_id":
+			bid.DealID, err = s.text(field, value)
+		case "partner_id":
+			_, err = s.text(field, value)
+		case "price":
+			bid.Price, err = s.number(field, value)
+		case "quality_score":
+			bid.QualityScore, err = s.number(field, value)
+		case "quality_acknowledged":
+			bid.QualityAcknowledged, err = s.flag(field, value)
+		case "expires_at":
+			bid.ExpiresAt, err = s.timestamp(field, value)
+		case "adomain":
+			bid.AdvertiserDomains, err = s.domains(field, value)
+		case "creative":
+			bid.Creative, err = s.object(field, value)
+		case "vertical_ext":
+			bid.VerticalExt, err = s.extension(field, value)
+		default:
+			err = s.unknown(field, value)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bid, nil
+}
+
+// recordSchemaError counts a partner response rejected by validation in the partner stats
+func (s *AuctionService) recordSchemaError(partnerID string, schemaErr SchemaError) {
+	schemaErr.At = s.clock.Now()
+	partnerSchemaErrorsTotal.WithLabelValues(partnerID, schemaErr.Field, schemaErr.Reason).Inc()
+	s.stats.RecordSchemaError(partnerID, schemaErr)
+}
+
+// scanJSONBid builds a bid from a single flat JSON bid object without decoding it into a map. It
+// handles only the common shape: known fields holding strings without escapes, numbers, booleans,
+// or null, each field once, and nothing the schema would coerce or reject. Anything else reports
+// false, leaving the body to jsonBid, which also counts the conversions and reports the errors.
+func (s responseSchema) scanJSONBid(body []byte) (*models.Bid, bool) {
+	scan := jsonScanner{data: body}
+	if !scan.consume('{') {
+		return nil, false
+	}
+	var (
+		bid       models.Bid
+		seen      uint
+		precision [2]bool
+	)
+	empty := scan.consume('}')
+	for next := !empty; next; next = scan.consume(',') {
+		key, ok := scan.plainString()
+		if !ok || !scan.consume(':') {
+			return nil, false
+		}
+		field := jsonBidFieldIndex(key)
+		if field < 0 || seen&(1<<field) != 0 {
+			return nil, false
+		}
+		seen |= 1 << field
+		if scan.null() {
+			seen &^= 1 << field
+			continue
+		}
+
+		switch field {
+		case jsonBidID, jsonBidClickURL, jsonBidDealID, jsonBidPartnerID, jsonBidExpiresAt:
+			value, ok := scan.plainString()
+			if !ok {
+				return nil, false
+			}
+			switch field {
+			case jsonBidID:
+				bid.ID = string(value)
+			case jsonBidClickURL:
+				bid.ClickURL = string(value)
+			case jsonBidDealID:
+				bid.DealID = string(value)
+			case jsonBidExpiresAt:
+				expiresAt, err := time.Parse(time.RFC3339, strings.TrimSpace(string(value)))
+				if err != nil {
+					return nil, false
+				}
+				bid.ExpiresAt = expiresAt
+			}
+		case jsonBidPrice, jsonBidQualityScore:
+			raw, ok := scan.number()
+			if !ok {
+				return nil, false
+			}
+			number, err := strconv.ParseFloat(string(raw), 64)
+			if err != nil || math.IsInf(number, 0) {
+				return nil, false
+			}
+			if !s.strict {
+				rounded := math.Round(number*schemaPrecision) / schemaPrecision
+				precision[field-jsonBidPrice] = rounded != number
+				number = rounded
+			}
+			if field == jsonBidPrice {
+				bid.Price = number
+			} else {
+				bid.QualityScore = number
+			}
+		case jsonBidQualityAcknowledged:
+			flag, ok := scan.boolean()
+			if !ok {
+				return nil, false
+			}
+			bid.QualityAcknowledged = flag
+		}
+	}
+	if !empty && !scan.consume('}') {
+		return nil, false
+	}
+	if !scan.end() {
+		return nil, false
+	}
+	if s.strict {
+		for _, field := range []int{jsonBidID, jsonBidPrice, jsonBidClickURL} {
+			if seen&(1<<field) == 0 {
+				return nil, false
+			}
+		}
+	}
+
+	if precision[0] {
+		s.coerced("price", coercionPrecision)
+	}
+	if precision[1] {
+		s.coerced("quality_score", coercionPrecision)
+	}
+	return &bid, true
+}
+
+// Native JSON bid fields scanJSONBid handles, as bit positions of the fields it has seen
+const (
+	jsonBidID = iota
+	jsonBidClickURL
+	jsonBidDealID
+	jsonBidPartnerID
+	jsonBidExpiresAt
+	jsonBidPrice
+	jsonBidQualityScore
+	jsonBidQualityAcknowledged
+)
+
+// jsonBidFieldIndex returns the scanJSONBid field for a key, or -1 for a field it leaves to jsonBid
+func jsonBidFieldIndex(key []byte) int {
+	switch string(key) {
+	case "id":
+		return jsonBidID
+	case "click_url":
+		return jsonBidClickURL
+	case "deal_id":
+		return jsonBidDealID
+	case "partner_id":
+		return jsonBidPartnerID
+	case "expires_at":
+		return jsonBidExpiresAt
+	case "price":
+		return jsonBidPrice
+	case "quality_score":
+		return jsonBidQualityScore
+	case "quality_acknowledged":
+		return jsonBidQualityAcknowledged
+	}
+	return -1
+}
+
+// jsonScanner reads the JSON tokens scanJSONBid accepts, skipping whitespace before each
+type jsonScanner struct {
+	data []byte
+	pos  int
+}
+
+// skipSpace skips JSON whitespace
+func (j *jsonScanner) skipSpace() {
+	for j.pos < len(j.data) {
+		switch j.data[j.pos] {
+		case ' ', '\t', '\n', '\r':
+			j.pos++
+		default:
+			return
+		}
+	}
+}
+
+// consume reads the delimiter c if it comes next
+func (j *jsonScanner) consume(c byte) bool {
+	j.skipSpace()
+	if j.pos < len(j.data) && j.data[j.pos] == c {
+		j.pos++
+		return true
+	}
+	return false
+}
+
+// literal reads the literal word if it comes next
+func (j *jsonScanner) literal(word string) bool {
+	j.skipSpace()
+	if !bytes.HasPrefix(j.data[j.pos:], []byte(word)) {
+		return false
+	}
+	j.pos += len(word)
+	return true
+}
+
+// null reads a null
+func (j *jsonScanner) null() bool {
+	return j.literal("null")
+}
+
+// boolean reads true or false
+func (j *jsonScanner) boolean() (bool, bool) {
+	if j.literal("true") {
+		return true, true
+	}
+	return false, j.literal("false")
+}
+
+// plainString reads a string of printable ASCII without escapes, returning its contents
+func (j *jsonScanner) plainString() ([]byte, bool) {
+	if !j.consume('"') {
+		return nil, false
+	}
+	start := j.pos
+	for ; j.pos < len(j.data); j.pos++ {
+		switch c := j.data[j.pos]; {
+		case c == '"':
+			j.pos++
+			return j.data[start : j.pos-1], true
+		case c == '\\' || c < 0x20 || c >= 0x80:
+			return nil, false
+		}
+	}
+	return nil, false
+}
+
+// number reads a number in JSON syntax, returning its text
+func (j *jsonScanner) number() ([]byte, bool) {
+	j.skipSpace()
+	start := j.pos
+	if j.pos < len(j.data) && j.data[j.pos] == '-' {
+		j.pos++
+	}
+	switch {
+	case j.pos < len(j.data) && j.data[j.pos] == '0':
+		j.pos++
+	case !j.digits():
+		return nil, false
+	}
+	if j.pos < len(j.data) && j.data[j.pos] == '.' {
+		j.pos++
+		if !j.digits() {
+			return nil, false
+		}
+	}
+	if j.pos < len(j.data) && (j.data[j.pos] == 'e' || j.data[j.pos] == 'E') {
+		j.pos++
+		if j.pos < len(j.data) && (j.data[j.pos] == '+' || j.data[j.pos] == '-') {
+			j.pos++
+		}
+		if !j.digits() {
+			return nil, false
+		}
+	}
+	return j.data[start:j.pos], true
+}
+
+// digits reads one or more decimal digits
+func (j *jsonScanner) digits() bool {
+	start := j.pos
+	for j.pos < len(j.data) && j.data[j.pos] >= '0' && j.data[j.pos] <= '9' {
+		j.pos++
+	}
+	return j.pos > start
+}
+
+// end reports whether only whitespace is left
+func (j *jsonScanner) end() bool {
+	j.skipSpace()
+	return j.pos == len(j.data)
+}
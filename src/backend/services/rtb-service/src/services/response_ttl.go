@@ -0,0 +1,46 @@
+package services
+
+import (
+	"errors"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// ErrResponseExpired is returned when a reserved auction response is confirmed after its ValidUntil
+var ErrResponseExpired = errors.New("auction response expired")
+
+// applyResponseTTL caps each winner's expiry at the vertical's response TTL, keeping partner
+// expiries that end sooner, and returns when the response stops being valid: the earliest winner
+// expiry, or nil when no winner expires
+func (s *AuctionService) applyResponseTTL(ttl time.Duration, winners []*models.Bid) *time.Time {
+	var deadline time.Time
+	if ttl > 0 {
+		deadline = s.clock.Now().Add(ttl).UTC()
+	}
+
+	var validUntil *time.Time
+	for _, bid := range winners {
+		if !deadline.IsZero() && (bid.ExpiresAt.IsZero() || bid.ExpiresAt.After(deadline)) {
+			bid.ExpiresAt = deadline
+		}
+		if bid.ExpiresAt.IsZero() {
+			continue
+		}
+		if validUntil == nil || bid.ExpiresAt.Before(*validUntil) {
+			expiresAt := bid.ExpiresAt
+			validUntil = &expiresAt
+		}
+	}
+	return validUntil
+}
+
+// reservationExpiredError explains why a reservation can no longer be confirmed: its response
+// passed its ValidUntil, or the reservation itself expired or is unknown
+func (s *AuctionService) reservationExpiredError(record *reservationRecord) error {
+	if record != nil && record.Response != nil && record.Response.ValidUntil != nil &&
+		!s.clock.Now().Before(*record.Response.ValidUntil) {
+		return ErrResponseExpired
+	}
+	return ErrReservationExpired
+}
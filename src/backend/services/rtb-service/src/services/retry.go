@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// retryBackoff returns a full-jitter exponential backoff for the retry after attempt
+func retryBackoff(random *rand.Rand, base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	ceiling := base << (attempt - 1)
+	return time.Duration(random.Int63n(int64(ceiling) + 1))
+}
+
+// waitForRetry sleeps for backoff, returning false without waiting when the remaining
+// context budget would be spent before the retry could start
+func waitForRetry(ctx context.Context, backoff time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) <= backoff {
+		return false
+	}
+	if backoff <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// transportRetryCondition classifies a transport failure; failures caused by the context ending are never retried
+func transportRetryCondition(ctx context.Context) string {
+	if ctx.Err() != nil {
+		return ""
+	}
+	return config.RetryOnConnectError
+}
+
+// statusRetryCondition classifies a response failure; only 5xx and 429 statuses are retryable
+func statusRetryCondition(err error) string {
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return ""
+	}
+	switch {
+	case statusErr.Status == http.StatusTooManyRequests:
+		return config.RetryOn429
+	case statusErr.Status >= 500:
+		return config.RetryOn5xx
+	default:
+		return ""
+	}
+}
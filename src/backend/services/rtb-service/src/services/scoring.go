@@ -0,0 +1,156 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// Scoring fallback reasons recorded when model scores are not applied
+const (
+	scoringFallbackTimeout = "timeout"
+	scoringFallbackError   = "error"
+)
+
+// ScoringService predicts a score in [0, 1] per bid, keyed by bid ID
+type ScoringService interface {
+	ScoreBids(ctx context.Context, request *models.BidRequest, bids []*models.Bid) (map[string]float64, error)
+}
+
+// scoringRequest is the payload sent to the scoring endpoint with all bids batched in one call
+type scoringRequest struct {
+	RequestID string          `json:"request_id"`
+	LeadID    string          `json:"lead_id"`
+	Vertical  string          `json:"vertical"`
+	Bids      []scoringBidRef `json:"bids"`
+}
+
+// scoringBidRef describes a single bid to the scoring endpoint
+type scoringBidRef struct {
+	ID           string  `json:"id"`
+	PartnerID    string  `json:"partner_id"`
+	Price        float64 `json:"price"`
+	QualityScore float64 `json:"quality_score"`
+}
+
+// scoringResponse is the scoring endpoint response
+type scoringResponse struct {
+	Scores map[string]float64 `json:"scores"`
+}
+
+// HTTPScoringService scores bids by calling an HTTP model endpoint
+type HTTPScoringService struct {
+	endpoint string
+	client   *http.Client
+}
+
+// NewHTTPScoringService creates a scoring client for the configured endpoint
+func NewHTTPScoringService(cfg *config.ScoringConfig) *HTTPScoringService {
+	return &HTTPScoringService{
+		endpoint: cfg.Endpoint,
+		client:   &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+// ScoreBids requests scores for all bids in a single call
+func (s *HTTPScoringService) ScoreBids(ctx context.Context, request *models.BidRequest, bids []*models.Bid) (map[string]float64, error) {
+	payload := scoringRequest{
+		RequestID: request.RequestID,
+		LeadID:    request.LeadID,
+		Vertical:  request.Vertical,
+		Bids:      make([]scoringBidRef, 0, len(bids)),
+	}
+	for _, bid := range bids {
+		payload.Bids = append(payload.Bids, scoringBidRef{
+			ID:           bid.ID,
+			PartnerID:    bid.PartnerID,
+			Price:        bid.CPL(),
+			QualityScore: bid.QualityScore,
+		})
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("encoding scoring request: %w", err)
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("building scoring request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set(RequestIDHeader, request.RequestID)
+
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("calling scoring service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil, fmt.Errorf("scoring service returned status %d", resp.StatusCode)
+	}
+
+	var scored scoringResponse
+	if err := json.NewDecoder(resp.Body).Decode(&scored); err != nil {
+		return nil, fmt.Errorf("decoding scoring response: %w", err)
+	}
+	return scored.Scores, nil
+}
+
+// SetScoringService replaces the scoring service; nil disables model scoring
+func (s *AuctionService) SetScoringService(scorer ScoringService) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.scorer = scorer
+}
+
+// applyModelScores blends model scores into bid quality scores. Scorer failures never fail the
+// auction: bids keep their existing scores and the fallback is counted.
+func (s *AuctionService) applyModelScores(ctx context.Context, request *models.BidRequest, bids []*models.Bid) {
+	s.mutex.RLock()
+	scorer := s.scorer
+	s.mutex.RUnlock()
+
+	if scorer == nil || s.config.Scoring == nil || len(bids) == 0 {
+		return
+	}
+
+	scoreCtx, cancel := context.WithTimeout(ctx, s.config.Scoring.Timeout)
+	defer cancel()
+
+	scores, err := scorer.ScoreBids(scoreCtx, request, bids)
+	if err != nil {
+		reason := scoringFallbackError
+		if errors.Is(err, context.DeadlineExceeded) || scoreCtx.Err() != nil {
+			reason = scoringFallbackTimeout
+		}
+		scoringFallbacksTotal.WithLabelValues(reason).Inc()
+		return
+	}
+
+	weight := s.config.Scoring.BlendWeight
+	for _, bid := range bids {
+		score, exists := scores[bid.ID]
+		if !exists || score < 0 || score > 1 {
+			continue
+		}
+		bid.QualityScore = (1-weight)*bid.QualityScore + weight*score
+	}
+}
+
+// newScoringService builds the configured scoring service, or nil when scoring is disabled
+func newScoringService(cfg *config.ScoringConfig) ScoringService {
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	return NewHTTPScoringService(cfg)
+}
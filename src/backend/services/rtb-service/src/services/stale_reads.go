@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// Stale read results reported by the stale read metric
+const (
+	staleReadHit   = "hit"
+	staleReadStale = "stale"
+	staleReadMiss  = "miss"
+)
+
+// Stale refresh results reported by the refresh metric
+const (
+	staleRefreshOK    = "ok"
+	staleRefreshError = "error"
+)
+
+// maxStaleEntries bounds each stale read cache. Keys past the bound get their fallback until
+// expired entries make room.
+const maxStaleEntries = 100000
+
+// staleEntry is a cached value, when it was read, whether a refresh is running, and how many
+// values were put since the entry was created
+type staleEntry[V any] struct {
+	value      V
+	readAt     time.Time
+	refreshing bool
+	puts       uint64
+}
+
+// staleLoad is a key to refresh and its entry's puts when the refresh started
+type staleLoad struct {
+	key  string
+	puts uint64
+}
+
+// staleCache serves Redis-backed reads from memory. A value younger than fresh is served as it is,
+// one younger than expiry is served while a background load refreshes it, and a missing or expired
+// one is loaded in the background while the caller gets its fallback, so callers never wait on
+// Redis. Keys needing a load in one read are loaded together, and a key is only loaded by one
+// refresh at a time.
+type staleCache[V any] struct {
+	name    string // labels the metrics
+	fresh   time.Duration
+	expiry  time.Duration
+	timeout time.Duration
+	clock   utils.Clock
+	load    func(ctx context.Context, keys []string) ([]V, error)
+	mutex   sync.Mutex
+	entries map[string]*staleEntry[V]
+}
+
+// newStaleCache creates a cache loading keys with load, or returns nil when stale reads are off
+func newStaleCache[V any](name string, cfg *config.RedisConfig, clock utils.Clock, load func(ctx context.Context, keys []string) ([]V, error)) *staleCache[V] {
+	if cfg == nil || !cfg.StaleReads.Active() {
+		return nil
+	}
+	cache := &staleCache[V]{
+		name:    name,
+		fresh:   cfg.StaleReads.Freshness(),
+		expiry:  cfg.StaleReads.Expiry(),
+		timeout: time.Second,
+		clock:   clock,
+		load:    load,
+		entries: make(map[string]*staleEntry[V]),
+	}
+	if cfg.Timeout > 0 {
+		cache.timeout = cfg.Timeout
+	}
+	return cache
+}
+
+// get returns the cached value of each key, or fallback for keys with no live value, and starts
+// one background load of every key that is stale, expired, or missing
+func (c *staleCache[V]) get(keys []string, fallback V) []V {
+	now := c.clock.Now()
+	values := make([]V, len(keys))
+	var refresh []staleLoad
+
+	c.mutex.Lock()
+	for i, key := range keys {
+		entry, exists := c.entries[key]
+		age := time.Duration(0)
+		if exists {
+			age = now.Sub(entry.readAt)
+		}
+		switch {
+		case exists && age < c.fresh:
+			values[i] = entry.value
+			staleReadsTotal.WithLabelValues(c.name, staleReadHit).Inc()
+			continue
+		case exists && age < c.expiry:
+			values[i] = entry.value
+			staleReadsTotal.WithLabelValues(c.name, staleReadStale).Inc()
+		default:
+			values[i] = fallback
+			staleReadsTotal.WithLabelValues(c.name, staleReadMiss).Inc()
+		}
+		if !exists {
+			if len(c.entries) >= maxStaleEntries && !c.sweep(now) {
+				continue
+			}
+			entry = &staleEntry[V]{}
+			c.entries[key] = entry
+		}
+		if !entry.refreshing {
+			entry.refreshing = true
+			refresh = append(refresh, staleLoad{key: key, puts: entry.puts})
+		}
+	}
+	c.mutex.Unlock()
+
+	if len(refresh) > 0 {
+		go c.refresh(refresh)
+	}
+	return values
+}
+
+// refresh reads the keys of loads, which load returns a value for each of, and caches what was
+// read unless a value was put while it ran. A failed load leaves the cached values to age toward their
+// expiry.
+func (c *staleCache[V]) refresh(loads []staleLoad) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+	keys := make([]string, len(loads))
+	for i, load := range loads {
+		keys[i] = load.key
+	}
+	values, err := c.load(ctx, keys)
+	now := c.clock.Now()
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for i, load := range loads {
+		entry, exists := c.entries[load.key]
+		if !exists {
+			continue
+		}
+		entry.refreshing = false
+		switch {
+		case err == nil && entry.puts == load.puts:
+			entry.value, entry.readAt = values[i], now
+		case err != nil && entry.readAt.IsZero():
+			delete(c.entries, load.key)
+		}
+	}
+	if err != nil {
+		staleRefreshesTotal.WithLabelValues(c.name, staleRefreshError).Inc()
+		return
+	}
+	staleRefreshesTotal.WithLabelValues(c.name, staleRefreshOK).Inc()
+}
+
+// put caches a value this instance just wrote to Redis, so it is served without a read
+func (c *staleCache[V]) put(key string, value V) {
+	if c == nil {
+		return
+	}
+	now := c.clock.Now()
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	entry, exists := c.entries[key]
+	if !exists {
+		if len(c.entries) >= maxStaleEntries && !c.sweep(now) {
+			return
+		}
+		entry = &staleEntry[V]{}
+		c.entries[key] = entry
+	}
+	entry.value, entry.readAt = value, now
+	entry.puts++
+}
+
+// forget drops every cached key starting with prefix
+func (c *staleCache[V]) forget(prefix string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// sweep drops expired entries that are not being refreshed, reporting whether there is now room
+// for another. Callers hold the mutex.
+func (c *staleCache[V]) sweep(now time.Time) bool {
+	for key, entry := range c.entries {
+		if !entry.refreshing && now.Sub(entry.readAt) >= c.expiry {
+			delete(c.entries, key)
+		}
+	}
+	return len(c.entries) < maxStaleEntries
+}
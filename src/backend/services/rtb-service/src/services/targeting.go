@@ -0,0 +1,25 @@
+package services
+
+import (
+	"strings"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// partnerRegionAllowed reports whether the request region passes the partner's region filter.
+// Partners without a filter accept every request; requests with an unknown region never pass a filter.
+func partnerRegionAllowed(partner *config.PartnerConfig, request *models.BidRequest) bool {
+	if len(partner.AllowedRegions) == 0 {
+		return true
+	}
+	if request.Geo == nil || request.Geo.Region == "" {
+		return false
+	}
+	for _, region := range partner.AllowedRegions {
+		if strings.EqualFold(region, request.Geo.Region) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,203 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// ErrInvalidUserData is wrapped by every UserDataError
+var ErrInvalidUserData = errors.New("invalid user data")
+
+// UserDataError lists the UserData fields of a request that failed its vertical's schema
+type UserDataError struct {
+	Fields []models.FieldError
+}
+
+// Error returns the failed fields and reasons
+func (e *UserDataError) Error() string {
+	failures := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		failures[i] = field.Field + ": " + field.Reason
+	}
+	return ErrInvalidUserData.Error() + ": " + strings.Join(failures, ", ")
+}
+
+// Unwrap returns ErrInvalidUserData
+func (e *UserDataError) Unwrap() error {
+	return ErrInvalidUserData
+}
+
+// userDataSchema is a vertical's schema with its fields in name order and patterns compiled
+type userDataSchema struct {
+	config *config.UserDataSchema
+	fields []userDataField
+}
+
+// userDataField is one schema field
+type userDataField struct {
+	name    string
+	config  *config.UserDataField
+	pattern *regexp.Regexp
+}
+
+// compileUserDataSchemas prepares configured schemas for validation
+func compileUserDataSchemas(schemas map[string]*config.UserDataSchema) (map[string]*userDataSchema, error) {
+	compiled := make(map[string]*userDataSchema, len(schemas))
+	for vertical, schema := range schemas {
+		if schema == nil {
+			continue
+		}
+		entry := &userDataSchema{config: schema, fields: make([]userDataField, 0, len(schema.Fields))}
+		for name, field := range schema.Fields {
+			if field == nil {
+				continue
+			}
+			pattern, err := regexp.Compile(field.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pattern for user data field %s in vertical %s: %w", name, vertical, err)
+			}
+			if field.Pattern == "" {
+				pattern = nil
+			}
+			entry.fields = append(entry.fields, userDataField{name: name, config: field, pattern: pattern})
+		}
+		sort.Slice(entry.fields, func(i, j int) bool { return entry.fields[i].name < entry.fields[j].name })
+		compiled[vertical] = entry
+	}
+	return compiled, nil
+}
+
+// SetUserDataSchemas replaces the UserData schemas; auctions already validated keep the old ones.
+// The config is expected to be validated, so only an invalid pattern is reported.
+func (s *AuctionService) SetUserDataSchemas(schemas map[string]*config.UserDataSchema) error {
+	compiled, err := compileUserDataSchemas(schemas)
+	if err != nil {
+		return err
+	}
+	s.schemas.Store(compiled)
+	return nil
+}
+
+// UserDataSchema returns the configured UserData schema for a vertical
+func (s *AuctionService) UserDataSchema(vertical string) (*config.UserDataSchema, bool) {
+	schema := s.userDataSchema(vertical)
+	if schema == nil {
+		return nil, false
+	}
+	return schema.config, true
+}
+
+// userDataSchema returns the compiled schema for a vertical, or nil when it has none
+func (s *AuctionService) userDataSchema(vertical string) *userDataSchema {
+	schemas, _ := s.schemas.Load().(map[string]*userDataSchema)
+	return schemas[vertical]
+}
+
+// ValidateUserData checks a request's UserData against its vertical's schema and its lead
+// quality signals, returning a UserDataError listing every failed field. Fields only required
+// for some partners do not fail the request when missing.
+func (s *AuctionService) ValidateUserData(request *models.BidRequest) error {
+	failures := request.LeadQuality.Validate(s.clock.Now())
+	schema := s.userDataSchema(request.Vertical)
+	if schema == nil {
+		return userDataError(failures)
+	}
+
+	for _, field := range schema.fields {
+		value, exists := request.UserData[field.name]
+		if !exists || value == nil {
+			if field.config.Required {
+				failures = append(failures, models.FieldError{Field: field.name, Reason: models.FieldReasonRequired, Message: "is required"})
+			}
+			continue
+		}
+		if failure, ok := field.check(value); !ok {
+			failures = append(failures, failure)
+		}
+	}
+	return userDataError(failures)
+}
+
+// userDataError returns a UserDataError for the failed fields, or nil when none failed
+func userDataError(failures []models.FieldError) error {
+	if len(failures) > 0 {
+		return &UserDataError{Fields: failures}
+	}
+	return nil
+}
+
+// check validates a present value against the field's type, pattern, and length
+func (f userDataField) check(value interface{}) (models.FieldError, bool) {
+	switch f.config.Type {
+	case config.UserDataTypeInt:
+		if !isInteger(value) {
+			return models.FieldError{Field: f.name, Reason: models.FieldReasonType, Message: "must be an integer"}, false
+		}
+	case config.UserDataTypeBool:
+		if _, ok := value.(bool); !ok {
+			return models.FieldError{Field: f.name, Reason: models.FieldReasonType, Message: "must be a boolean"}, false
+		}
+	default:
+		text, ok := value.(string)
+		if !ok {
+			return models.FieldError{Field: f.name, Reason: models.FieldReasonType, Message: "must be a string"}, false
+		}
+		if f.config.MaxLength > 0 && utf8.RuneCountInString(text) > f.config.MaxLength {
+			return models.FieldError{Field: f.name, Reason: models.FieldReasonMaxLength, Message: fmt.Sprintf("must be at most %d characters", f.config.MaxLength)}, false
+		}
+		if f.pattern != nil && !f.pattern.MatchString(text) {
+			return models.FieldError{Field: f.name, Reason: models.FieldReasonPattern, Message: "must match " + f.config.Pattern}, false
+		}
+	}
+	return models.FieldError{}, true
+}
+
+// isInteger reports whether a decoded UserData value is a whole number. JSON numbers decode to
+// float64, so integral floats count.
+func isInteger(value interface{}) bool {
+	switch number := value.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return !math.IsInf(number, 0) && number == math.Trunc(number)
+	case json.Number:
+		_, err := number.Int64()
+		return err == nil
+	default:
+		return false
+	}
+}
+
+// partnersMissingUserData returns the partners whose extra UserData fields the request lacks
+func (s *AuctionService) partnersMissingUserData(request *models.BidRequest) map[string]bool {
+	schema := s.userDataSchema(request.Vertical)
+	if schema == nil {
+		return nil
+	}
+
+	var missing map[string]bool
+	for _, field := range schema.fields {
+		if len(field.config.RequiredForPartners) == 0 {
+			continue
+		}
+		if value, exists := request.UserData[field.name]; exists && value != nil {
+			continue
+		}
+		if missing == nil {
+			missing = make(map[string]bool, len(field.config.RequiredForPartners))
+		}
+		for _, partnerID := range field.config.RequiredForPartners {
+			missing[partnerID] = true
+		}
+	}
+	return missing
+}
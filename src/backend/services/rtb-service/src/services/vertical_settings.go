@@ -0,0 +1,70 @@
+package services
+
+import (
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// verticalSettings is the config each vertical's settings resolve against, with the optimizer
+// ranking by its strategies. A reload swaps both at once, so no auction ranks with one config's
+// strategies and picks winners with another's limits.
+type verticalSettings struct {
+	config    *config.Config
+	optimizer *utils.BidOptimizer
+}
+
+// auctionSettings is what an auction runs with for its vertical, resolved once as it starts
+type auctionSettings struct {
+	config.EffectiveVertical
+	optimizer *utils.BidOptimizer
+}
+
+// SetVerticals swaps in the vertical settings of cfg along with the global settings they fall back
+// to: max bids per request, strategies, and response TTLs. Floors are checked against the max bid
+// price the service runs with. Invalid settings are rejected and the current ones kept.
+func (s *AuctionService) SetVerticals(cfg *config.Config) error {
+	resolved := *s.config
+	resolved.MaxBidsPerRequest = cfg.MaxBidsPerRequest
+	resolved.Strategies = cfg.Strategies
+	resolved.ResponseTTLs = cfg.ResponseTTLs
+	resolved.Verticals = cfg.Verticals
+	if err := resolved.ValidateVerticals(); err != nil {
+		return err
+	}
+	optimizer, err := utils.NewBidOptimizerWithClock(&resolved, nil, s.clock)
+	if err != nil {
+		return err
+	}
+	s.verticals.Store(&verticalSettings{config: &resolved, optimizer: optimizer})
+	return nil
+}
+
+// EffectiveVertical returns the settings a vertical's auctions currently run with
+func (s *AuctionService) EffectiveVertical(vertical string) config.EffectiveVertical {
+	return s.verticals.Load().(*verticalSettings).config.EffectiveVertical(vertical)
+}
+
+// settingsFor resolves the settings of the request's vertical
+func (s *AuctionService) settingsFor(request *models.BidRequest) auctionSettings {
+	settings := s.verticals.Load().(*verticalSettings)
+	var vertical string
+	if request != nil {
+		vertical = request.Vertical
+	}
+	return auctionSettings{EffectiveVertical: settings.config.EffectiveVertical(vertical), optimizer: settings.optimizer}
+}
+
+// floor returns the floor the auction applies: the vertical's floor when it is above the adaptive
+// floor or no adaptive floor is applied, or else the adaptive floor
+func (a auctionSettings) floor(adaptive *models.AuctionFloor) *models.AuctionFloor {
+	if a.Floor <= 0 || (adaptive != nil && adaptive.Floor >= a.Floor) {
+		return adaptive
+	}
+	return &models.AuctionFloor{Floor: a.Floor, Source: models.FloorSourceVertical}
+}
+
+// partnerDiverse reports whether a partner may win at most one of the auction's slots
+func (a auctionSettings) partnerDiverse() bool {
+	return a.Diversity != config.DiversityNone
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// maxWarmupResponseBytes bounds how much of a warmup response is drained so its connection can be
+// reused; HEAD and OPTIONS responses rarely have a body
+const maxWarmupResponseBytes = 4 << 10
+
+// WarmupResult is the outcome of warming a partner's connections at startup
+type WarmupResult struct {
+	Success     bool    `json:"success"`
+	Method      string  `json:"method"`
+	Connections int     `json:"connections"`
+	HandshakeMs float64 `json:"handshake_ms"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// WarmupPartners opens connections to the endpoint hosts of every enabled partner, concurrently
+// and within the warmup timeout, so the first auctions don't pay for DNS, TCP, and TLS setup.
+// Results are kept for readiness reports and returned; nothing runs when warmup is disabled.
+// Warmup calls never count toward partner stats or circuit breakers.
+func (s *AuctionService) WarmupPartners(ctx context.Context) map[string]WarmupResult {
+	cfg := s.config.Warmup
+	if cfg == nil || !cfg.Enabled {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, cfg.WarmupTimeout())
+	defer cancel()
+
+	partners := s.partners.Load().(map[string]*config.PartnerConfig)
+	results := make(map[string]WarmupResult, len(partners))
+	var (
+		mutex sync.Mutex
+		wg    sync.WaitGroup
+	)
+	for partnerID, partner := range partners {
+		if !partner.Enabled || s.partnerDraining(partnerID) {
+			continue
+		}
+		wg.Add(1)
+		go func(partnerID string, partner *config.PartnerConfig) {
+			defer wg.Done()
+			result := s.warmupPartner(ctx, partnerID, partner, cfg.ConnectionCount())
+			mutex.Lock()
+			results[partnerID] = result
+			mutex.Unlock()
+		}(partnerID, partner)
+	}
+	wg.Wait()
+
+	s.warmup.Store(results)
+	return results
+}
+
+// WarmupResults returns the results of the startup warmup, or nil when it has not run
+func (s *AuctionService) WarmupResults() map[string]WarmupResult {
+	results, _ := s.warmup.Load().(map[string]WarmupResult)
+	return results
+}
+
+// warmupPartner warms each of the partner's endpoint hosts by its warmup method. Request methods
+// open new connections that stay idle in the partner client's pool; the connect method
+// resolves DNS through the client's cache and completes one TCP and TLS handshake per host, which
+// checks the host without leaving a connection behind. HandshakeMs is the average setup time of
+// the connections opened.
+func (s *AuctionService) warmupPartner(ctx context.Context, partnerID string, partner *config.PartnerConfig, connections int) WarmupResult {
+	result := WarmupResult{Method: partner.WarmupMethod}
+	if result.Method == "" {
+		result.Method = config.WarmupConnect
+	}
+	client := s.clients.client(partnerID)
+
+	var (
+		setup    time.Duration
+		firstErr error
+	)
+	record := func(elapsed time.Duration, err error) {
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			return
+		}
+		if elapsed > 0 {
+			result.Connections++
+			setup += elapsed
+		}
+	}
+
+	for _, origin := range warmupOrigins(partner) {
+		if result.Method == config.WarmupConnect {
+			record(warmupConnect(ctx, client, origin))
+			continue
+		}
+
+		method := http.MethodHead
+		if result.Method == config.WarmupOptions {
+			method = http.MethodOptions
+		}
+		// Concurrent requests each need a connection of their own, so the pool ends up with one
+		// per request
+		var (
+			mutex sync.Mutex
+			wg    sync.WaitGroup
+		)
+		for i := 0; i < connections; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				elapsed, err := warmupRequest(ctx, client, method, origin)
+				mutex.Lock()
+				record(elapsed, err)
+				mutex.Unlock()
+			}()
+		}
+		wg.Wait()
+	}
+
+	if result.Connections > 0 {
+		result.HandshakeMs = float64(setup.Microseconds()) / float64(result.Connections) / 1000
+	}
+	if firstErr != nil {
+		result.Error = firstErr.Error()
+	}
+	result.Success = firstErr == nil
+	return result
+}
+
+// warmupOrigins returns the distinct scheme and host of the partner's endpoints. Endpoints may
+// only template their path and query, so the origins are fixed.
+func warmupOrigins(partner *config.PartnerConfig) []*url.URL {
+	seen := make(map[string]bool)
+	var origins []*url.URL
+	for _, endpoint := range partner.EndpointList() {
+		parsed, err := url.Parse(endpoint.URL)
+		if err != nil || parsed.Host == "" {
+			continue
+		}
+		origin := &url.URL{Scheme: parsed.Scheme, Host: parsed.Host, Path: "/"}
+		if key := origin.String(); !seen[key] {
+			seen[key] = true
+			origins = append(origins, origin)
+		}
+	}
+	return origins
+}
+
+// warmupRequest sends a request to origin and drains its response so the connection returns to
+// the pool. It reports the setup time of a new connection, or zero when one was reused; any
+// response status counts, since the connection is what matters.
+func warmupRequest(ctx context.Context, client *http.Client, method string, origin *url.URL) (time.Duration, error) {
+	var (
+		start  time.Time
+		setup  time.Duration
+		reused bool
+	)
+	trace := &httptrace.ClientTrace{
+		GetConn: func(string) {
+			start = time.Now()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			setup = time.Since(start)
+			reused = info.Reused
+		},
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace), method, origin.String(), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, maxWarmupResponseBytes))
+	resp.Body.Close()
+	if reused {
+		return 0, nil
+	}
+	return setup, nil
+}
+
+// warmupConnect dials origin with the client's dialer, which resolves through its DNS cache when
+// one is configured, completes the TLS handshake with the client's TLS settings for https, and
+// closes the connection. It reports the time taken.
+func warmupConnect(ctx context.Context, client *http.Client, origin *url.URL) (time.Duration, error) {
+	transport, ok := client.Transport.(*http.Transport)
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport)
+	}
+	dial := transport.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	port := origin.Port()
+	if port == "" {
+		port = "80"
+		if origin.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	start := time.Now()
+	conn, err := dial(ctx, "tcp", net.JoinHostPort(origin.Hostname(), port))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	if origin.Scheme == "https" {
+		tlsConfig := &tls.Config{}
+		if transport.TLSClientConfig != nil {
+			tlsConfig = transport.TLSClientConfig.Clone()
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName = origin.Hostname()
+		}
+		if err := tls.Client(conn, tlsConfig).HandshakeContext(ctx); err != nil {
+			return 0, err
+		}
+	}
+	return time.Since(start), nil
+}
@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/webhooks"
+)
+
+// BidWonEvent is the data of a bid.won webhook
+type BidWonEvent struct {
+	RequestID string  `json:"request_id"`
+	LeadID    string  `json:"lead_id"`
+	Vertical  string  `json:"vertical"`
+	PartnerID string  `json:"partner_id"`
+	BidID     string  `json:"bid_id"`
+	DealID    string  `json:"deal_id,omitempty"`
+	Price     float64 `json:"price"`
+	// PricingModel is the model Price is in; NormalizedPrice is its expected cost per lead
+	PricingModel    string  `json:"pricing_model,omitempty"`
+	NormalizedPrice float64 `json:"normalized_price,omitempty"`
+	// Experiments are the experiment variants the auction ran in
+	Experiments []models.ExperimentAssignment `json:"experiments,omitempty"`
+	// AuctionSeq orders the auction, when auction sequence numbers are enabled
+	AuctionSeq *models.AuctionSeq `json:"auction_seq,omitempty"`
+	// VerticalExt is the bid's vertical extension, sanitized by the PII policy
+	VerticalExt json.RawMessage `json:"vertical_ext,omitempty"`
+	// MinToWin is the lowest price that would still have won, for partners sharing bid guidance
+	MinToWin *float64 `json:"min_to_win,omitempty"`
+}
+
+// BidLostEvent is the data of a bid.lost webhook, sent for the best bid of each partner sharing
+// bid guidance that lost an auction with winners. PriceToBeat is the price, in the partner's
+// pricing model, that would have tied the lowest winner.
+type BidLostEvent struct {
+	RequestID    string   `json:"request_id"`
+	LeadID       string   `json:"lead_id"`
+	Vertical     string   `json:"vertical"`
+	PartnerID    string   `json:"partner_id"`
+	BidID        string   `json:"bid_id"`
+	PricingModel string   `json:"pricing_model,omitempty"`
+	PriceToBeat  *float64 `json:"price_to_beat,omitempty"`
+	// AuctionSeq orders the auction, when auction sequence numbers are enabled
+	AuctionSeq *models.AuctionSeq `json:"auction_seq,omitempty"`
+}
+
+// AuctionCompletedEvent is the data of an auction.completed webhook; Reason explains an auction
+// without winners
+type AuctionCompletedEvent struct {
+	RequestID string        `json:"request_id"`
+	LeadID    string        `json:"lead_id"`
+	Vertical  string        `json:"vertical"`
+	Winners   []string      `json:"winners"`
+	Reason    models.Reason `json:"reason,omitempty"`
+	// Experiments are the experiment variants the auction ran in
+	Experiments []models.ExperimentAssignment `json:"experiments,omitempty"`
+	// Floor is the adaptive floor the auction applied
+	Floor *models.AuctionFloor `json:"floor,omitempty"`
+	// AuctionSeq orders the auction, when auction sequence numbers are enabled
+	AuctionSeq *models.AuctionSeq `json:"auction_seq,omitempty"`
+	// Reasons counts the partner skips, selections, no-bids, and bid losses of the auction by kind
+	Reasons models.ReasonCounts `json:"reasons,omitempty"`
+}
+
+// notifyWinners dispatches a bid.won webhook per winning bid, a bid.lost webhook per losing
+// partner sharing bid guidance, and an auction.completed webhook
+func (s *AuctionService) notifyWinners(ctx context.Context, request *models.BidRequest, response *models.BidResponse) {
+	if s.webhooks == nil {
+		return
+	}
+
+	type bidKey struct{ partnerID, bidID string }
+	minToWin := make(map[bidKey]*float64)
+	for _, guidance := range response.Guidance {
+		if guidance.Won {
+			minToWin[bidKey{guidance.PartnerID, guidance.BidID}] = guidance.MinToWin
+			continue
+		}
+		s.dispatchWebhook(ctx, webhooks.Event{
+			ID:        webhookEventID(config.WebhookEventBidLost, request.RequestID, guidance.PartnerID, guidance.BidID),
+			Type:      config.WebhookEventBidLost,
+			CreatedAt: response.Timestamp.UTC(),
+			Data: BidLostEvent{
+				RequestID:    request.RequestID,
+				LeadID:       request.LeadID,
+				Vertical:     request.Vertical,
+				PartnerID:    guidance.PartnerID,
+				BidID:        guidance.BidID,
+				PricingModel: guidance.PricingModel,
+				PriceToBeat:  guidance.PriceToBeat,
+				AuctionSeq:   response.AuctionSeq,
+			},
+		})
+	}
+
+	winners := make([]string, 0, len(response.Bids))
+	for _, bid := range response.Bids {
+		winners = append(winners, bid.PartnerID)
+		s.dispatchWebhook(ctx, webhooks.Event{
+			ID:        webhookEventID(config.WebhookEventBidWon, request.RequestID, bid.PartnerID, bid.ID),
+			Type:      config.WebhookEventBidWon,
+			CreatedAt: response.Timestamp.UTC(),
+			Data: BidWonEvent{
+				RequestID:       request.RequestID,
+				LeadID:          request.LeadID,
+				Vertical:        request.Vertical,
+				PartnerID:       bid.PartnerID,
+				BidID:           bid.ID,
+				DealID:          bid.DealID,
+				Price:           bid.Price,
+				PricingModel:    bid.PricingModel,
+				NormalizedPrice: bid.NormalizedPrice,
+				Experiments:     response.Experiments,
+				AuctionSeq:      response.AuctionSeq,
+				VerticalExt:     models.SanitizeVerticalExt(bid.VerticalExt, s.config.PIIPolicy),
+				MinToWin:        minToWin[bidKey{bid.PartnerID, bid.ID}],
+			},
+		})
+	}
+	s.notifyCompleted(ctx, request, response.Timestamp, winners, "", response.Experiments, response.Floor, response.AuctionSeq)
+}
+
+// notifyNoSale dispatches an auction.completed webhook for an auction that ended without winners
+func (s *AuctionService) notifyNoSale(ctx context.Context, request *models.BidRequest, err error, experiments []models.ExperimentAssignment, floor *models.AuctionFloor, seq *models.AuctionSeq) {
+	if s.webhooks == nil {
+		return
+	}
+	reason := models.ReasonNoBids
+	if errors.Is(err, ErrInsufficientCompetition) {
+		reason = models.ReasonInsufficientCompetition
+	}
+	s.notifyCompleted(ctx, request, s.clock.Now(), []string{}, reason, experiments, floor, seq)
+}
+
+// notifyCompleted dispatches an auction.completed webhook
+func (s *AuctionService) notifyCompleted(ctx context.Context, request *models.BidRequest, completed time.Time, winners []string, reason models.Reason, experiments []models.ExperimentAssignment, floor *models.AuctionFloor, seq *models.AuctionSeq) {
+	s.dispatchWebhook(ctx, webhooks.Event{
+		ID:        webhookEventID(config.WebhookEventAuctionCompleted, request.RequestID),
+		Type:      config.WebhookEventAuctionCompleted,
+		CreatedAt: completed.UTC(),
+		Data: AuctionCompletedEvent{
+			RequestID:   request.RequestID,
+			LeadID:      request.LeadID,
+			Vertical:    request.Vertical,
+			Winners:     winners,
+			Reason:      reason,
+			Experiments: experiments,
+			Floor:       floor,
+			AuctionSeq:  seq,
+			Reasons:     models.ReasonsFromContext(ctx).Counts(),
+		},
+	})
+}
+
+// webhookEventID derives a stable event ID from the event type and the identifiers of its
+// outcome, so a redelivered or replayed event carries the same idempotency key. Partner bid IDs
+// are only unique per partner and request, so a bid.won ID covers all three.
+func webhookEventID(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// WebhookFailures returns the most recent webhook deliveries that were not acknowledged, newest first
+func (s *AuctionService) WebhookFailures() []webhooks.Failure {
+	return s.webhooks.Failures()
+}
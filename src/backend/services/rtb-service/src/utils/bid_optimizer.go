@@ -19,6 +19,7 @@ const (
 	minQualityScore        = 0.1
 	maxQualityScore        = 1.0
 	maxConcurrentProcessing = 100
+	sequentialBidLimit      = 64
 	bidProcessTimeout       = 400 * time.Millisecond
 )
 
@@ -37,6 +38,10 @@ type BidOptimizer struct {
 	mutex           sync.RWMutex
 	bidWorkerPool   *sync.Pool
 	metricsReporter MetricsReporter
+	strategies      map[string]OptimizationStrategy
+	defaultStrategy OptimizationStrategy
+	schedule        *timeSchedule
+	clock           Clock
 }
 
 // MetricsReporter interface for reporting optimization metrics
@@ -48,17 +53,36 @@ type MetricsReporter interface {
 
 // NewBidOptimizer creates a new BidOptimizer instance with configuration
 func NewBidOptimizer(cfg *config.Config, reporter MetricsReporter) (*BidOptimizer, error) {
+	return NewBidOptimizerWithClock(cfg, reporter, SystemClock{})
+}
+
+// NewBidOptimizerWithClock creates a BidOptimizer that evaluates time-of-day multipliers against clock
+func NewBidOptimizerWithClock(cfg *config.Config, reporter MetricsReporter, clock Clock) (*BidOptimizer, error) {
 	if cfg == nil {
 		return nil, ErrNilConfig
 	}
+	if clock == nil {
+		clock = SystemClock{}
+	}
+
+	names := cfg.VerticalStrategies()
+	strategies := make(map[string]OptimizationStrategy, len(names))
+	for vertical, name := range names {
+		strategies[vertical] = newStrategy(name, cfg, clock)
+	}
 
 	optimizer := &BidOptimizer{
 		config:          cfg,
 		partnerScores:   make(map[string]float64),
 		metricsReporter: reporter,
+		strategies:      strategies,
+		defaultStrategy: NewEffectivePriceStrategy(cfg, clock),
+		schedule:        newTimeSchedule(cfg.TimeMultipliers),
+		clock:           clock,
 		bidWorkerPool: &sync.Pool{
 			New: func() interface{} {
-				return make([]*models.Bid, 0, 10)
+				bids := make([]*models.Bid, 0, 10)
+				return &bids
 			},
 		},
 	}
@@ -66,16 +90,106 @@ func NewBidOptimizer(cfg *config.Config, reporter MetricsReporter) (*BidOptimize
 	return optimizer, nil
 }
 
-// OptimizeBids optimizes and ranks a collection of bids using concurrent processing
+// AcquireBids returns an empty bid slice from the optimizer's pool for gathering an auction's bids
+func (bo *BidOptimizer) AcquireBids() []*models.Bid {
+	return (*bo.bidWorkerPool.Get().(*[]*models.Bid))[:0]
+}
+
+// ReleaseBids returns a slice from AcquireBids to the pool. The caller must not use it afterwards.
+func (bo *BidOptimizer) ReleaseBids(bids []*models.Bid) {
+	if bids == nil {
+		return
+	}
+	clear(bids[:cap(bids)])
+	bids = bids[:0]
+	bo.bidWorkerPool.Put(&bids)
+}
+
+// OptimizeBids optimizes and ranks a collection of bids by effective price using concurrent processing
 func OptimizeBids(bids []*models.Bid, cfg *config.Config) ([]*models.Bid, error) {
+	if cfg == nil {
+		return nil, ErrInvalidInput
+	}
+	timeMultiplier := newTimeSchedule(cfg.TimeMultipliers).Multiplier("", time.Now())
+	return optimizeByEffectivePrice(bids, nil, timeMultiplier, cfg)
+}
+
+// pricedBid pairs a bid with its computed effective price and its position in the input
+type pricedBid struct {
+	bid   *models.Bid
+	price float64
+	index int
+}
+
+// pricedBids sorts by effective price descending, breaking ties with the quality-weighted
+// comparison and then input order, so the ranking does not depend on which worker finished first
+type pricedBids []pricedBid
+
+func (p pricedBids) Len() int      { return len(p) }
+func (p pricedBids) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p pricedBids) Less(i, j int) bool {
+	if p[i].price != p[j].price {
+		return p[i].price > p[j].price
+	}
+	if comparison := models.CompareBids(p[i].bid, p[j].bid); comparison != 0 {
+		return comparison > 0
+	}
+	return p[i].index < p[j].index
+}
+
+// pricedBidPool holds scratch space for ranking bid sets
+var pricedBidPool = sync.Pool{
+	New: func() interface{} {
+		priced := make(pricedBids, 0, sequentialBidLimit)
+		return &priced
+	},
+}
+
+// optimizeByEffectivePrice ranks bids by effective price, applying the time-of-day, vertical, and device multipliers
+func optimizeByEffectivePrice(bids []*models.Bid, request *models.BidRequest, timeMultiplier float64, cfg *config.Config) ([]*models.Bid, error) {
 	if bids == nil || cfg == nil {
 		return nil, ErrInvalidInput
 	}
 
+	scratch := pricedBidPool.Get().(*pricedBids)
+	defer func() {
+		clear((*scratch)[:cap(*scratch)])
+		*scratch = (*scratch)[:0]
+		pricedBidPool.Put(scratch)
+	}()
+
+	// Small bid sets are priced inline, where starting workers would cost more than the pricing
+	priced := (*scratch)[:0]
+	if len(bids) <= sequentialBidLimit {
+		for i, bid := range bids {
+			if effectivePrice, err := calculateEffectivePrice(bid, request, timeMultiplier, cfg); err == nil {
+				bid.QualityScore = clampQualityScore(bid.QualityScore)
+				priced = append(priced, pricedBid{bid: bid, price: effectivePrice, index: i})
+			}
+		}
+	} else {
+		var err error
+		if priced, err = priceConcurrently(priced, bids, request, timeMultiplier, cfg); err != nil {
+			return nil, err
+		}
+	}
+	*scratch = priced
+
+	sort.Sort(scratch)
+
+	optimizedBids := make([]*models.Bid, 0, len(priced))
+	for _, result := range priced {
+		optimizedBids = append(optimizedBids, result.bid)
+	}
+
+	return optimizedBids, nil
+}
+
+// priceConcurrently appends the effective price of each valid bid to priced using a bounded set of workers
+func priceConcurrently(priced pricedBids, bids []*models.Bid, request *models.BidRequest, timeMultiplier float64, cfg *config.Config) (pricedBids, error) {
 	// Create channels for concurrent processing
 	bidCount := len(bids)
-	resultChan := make(chan *models.Bid, bidCount)
-	errorChan := make(chan error, 1)
+	resultChan := make(chan pricedBid, bidCount)
 	
 	// Create worker pool with size limits
 	workerCount := int(math.Min(float64(bidCount), float64(maxConcurrentProcessing)))
@@ -87,10 +201,9 @@ func OptimizeBids(bids []*models.Bid, cfg *config.Config) ([]*models.Bid, error)
 		go func(start int) {
 			defer wg.Done()
 			for j := start; j < bidCount; j += workerCount {
-				if effectivePrice, err := calculateEffectivePrice(bids[j], cfg); err == nil {
-					bids[j].QualityScore = math.Max(minQualityScore, 
-						math.Min(maxQualityScore, bids[j].QualityScore))
-					resultChan <- bids[j]
+				if effectivePrice, err := calculateEffectivePrice(bids[j], request, timeMultiplier, cfg); err == nil {
+					bids[j].QualityScore = clampQualityScore(bids[j].QualityScore)
+					resultChan <- pricedBid{bid: bids[j], price: effectivePrice, index: j}
 				}
 			}
 		}(i)
@@ -110,75 +223,95 @@ func OptimizeBids(bids []*models.Bid, cfg *config.Config) ([]*models.Bid, error)
 		return nil, ErrTimeout
 	}
 
-	// Collect and sort results
-	optimizedBids := make([]*models.Bid, 0, bidCount)
-	for bid := range resultChan {
-		optimizedBids = append(optimizedBids, bid)
+	// Collect results
+	for result := range resultChan {
+		priced = append(priced, result)
 	}
+	return priced, nil
+}
 
-	// Sort by effective price descending
-	sort.Slice(optimizedBids, func(i, j int) bool {
-		return models.CompareBids(optimizedBids[i], optimizedBids[j]) > 0
-	})
-
-	return optimizedBids, nil
+// clampQualityScore bounds a quality score to the accepted range
+func clampQualityScore(score float64) float64 {
+	return math.Max(minQualityScore, math.Min(maxQualityScore, score))
 }
 
 // calculateEffectivePrice calculates the effective bid price with adjustments
-func calculateEffectivePrice(bid *models.Bid, cfg *config.Config) (float64, error) {
+func calculateEffectivePrice(bid *models.Bid, request *models.BidRequest, timeMultiplier float64, cfg *config.Config) (float64, error) {
 	if bid == nil || cfg == nil {
 		return 0, ErrInvalidInput
 	}
 
-	// Validate bid price bounds
-	if bid.Price < cfg.MinBidPrice || bid.Price > cfg.MaxBidPrice {
-		return 0, errors.New("bid price out of bounds")
+	// Deal bids bypass the open auction floor and compete at their deal price without adjustment
+	if _, isDeal := cfg.Deals[bid.DealID]; isDeal && bid.DealID != "" {
+		if bid.CPL() > cfg.MaxBidPrice {
+			return 0, errors.New("bid price out of bounds")
+		}
+		return bid.CPL(), nil
 	}
 
-	// Apply quality score multiplier
-	qualityMultiplier := 1.0 + (bid.QualityScore * models.QualityScoreWeight)
+	// Validate bid price bounds, comparing revenue-share bids by their normalized cost per lead
+	price := bid.CPL()
+	if price < cfg.MinBidPrice || price > cfg.MaxBidPrice {
+		return 0, errors.New("bid price out of bounds")
+	}
 
-	// Get partner configuration
-	partner, exists := cfg.Partners[bid.PartnerID]
+	// Calculate final effective price
+	multiplier, exists := priceMultiplier(bid, request, timeMultiplier, cfg)
 	if !exists {
 		return 0, errors.New("unknown partner")
 	}
+	effectivePrice := price * multiplier
 
-	// Apply time-of-day adjustment
-	hour := time.Now().Hour()
-	timeMultiplier := calculateTimeMultiplier(hour)
+	// Ensure price stays within bounds
+	effectivePrice = math.Max(cfg.MinBidPrice, math.Min(cfg.MaxBidPrice, effectivePrice))
 
-	// Apply partner vertical multiplier if exists
-	verticalMultiplier := 1.0
-	if multiplier, exists := partner.VerticalMultipliers["default"]; exists {
-		verticalMultiplier = multiplier
+	return effectivePrice, nil
+}
+
+// priceMultiplier returns the product of the quality, time-of-day, vertical, and device
+// multipliers an open auction bid's cost per lead is scaled by, and false for an unknown partner
+func priceMultiplier(bid *models.Bid, request *models.BidRequest, timeMultiplier float64, cfg *config.Config) (float64, bool) {
+	partner, exists := cfg.Partners[bid.PartnerID]
+	if !exists {
+		return 0, false
 	}
 
-	// Calculate final effective price
-	effectivePrice := bid.Price * qualityMultiplier * timeMultiplier * verticalMultiplier
+	// Apply quality score multiplier, with a bonus for bids that used the lead quality signals
+	qualityMultiplier := 1.0 + (bid.QualityScore * cfg.QualityScoreWeight())
+	if bid.QualityAcknowledged && request != nil && request.LeadQuality != nil {
+		qualityMultiplier *= 1.0 + cfg.QualityAcknowledgedBonus
+	}
 
-	// Ensure price stays within bounds
-	effectivePrice = math.Max(cfg.MinBidPrice, math.Min(cfg.MaxBidPrice, effectivePrice))
+	// Apply partner vertical and device multipliers
+	partnerMultiplier := verticalMultiplier(partner, requestVertical(request)) * deviceMultiplier(partner, request)
 
-	return effectivePrice, nil
+	return qualityMultiplier * timeMultiplier * partnerMultiplier, true
 }
 
-// calculateTimeMultiplier returns a multiplier based on hour of day
-func calculateTimeMultiplier(hour int) float64 {
-	// Peak hours (9AM-5PM) get higher multiplier
-	if hour >= 9 && hour <= 17 {
-		return 1.2
+// verticalMultiplier returns the partner multiplier for a vertical, falling back to the partner default
+func verticalMultiplier(partner *config.PartnerConfig, vertical string) float64 {
+	if multiplier, exists := partner.VerticalMultipliers[vertical]; exists {
+		return multiplier
 	}
-	// Evening hours (6PM-10PM) get medium multiplier
-	if hour >= 18 && hour <= 22 {
-		return 1.1
+	if multiplier, exists := partner.VerticalMultipliers["default"]; exists {
+		return multiplier
+	}
+	return 1.0
+}
+
+// deviceMultiplier returns the partner multiplier for the request device type, or 1 when unpriced
+func deviceMultiplier(partner *config.PartnerConfig, request *models.BidRequest) float64 {
+	if request == nil || request.Device == nil {
+		return 1.0
+	}
+	if multiplier, exists := partner.DeviceMultipliers[request.Device.Type]; exists {
+		return multiplier
 	}
-	// Off-peak hours get lower multiplier
-	return 0.9
+	return 1.0
 }
 
-// OptimizeBidSet provides thread-safe bid optimization with metrics
-func (bo *BidOptimizer) OptimizeBidSet(bids []*models.Bid) ([]*models.Bid, error) {
+// OptimizeBidSet ranks bids with the strategy configured for the request vertical
+func (bo *BidOptimizer) OptimizeBidSet(bids []*models.Bid, request *models.BidRequest) ([]*models.Bid, error) {
 	startTime := time.Now()
 	defer func() {
 		if bo.metricsReporter != nil {
@@ -187,14 +320,138 @@ func (bo *BidOptimizer) OptimizeBidSet(bids []*models.Bid) ([]*models.Bid, error
 		}
 	}()
 
-	// Acquire read lock for configuration access
+	// Acquire read lock for strategy access
 	bo.mutex.RLock()
-	optimizedBids, err := OptimizeBids(bids, bo.config)
+	strategy := bo.strategyFor(requestVertical(request))
 	bo.mutex.RUnlock()
 
+	optimizedBids, err := strategy.Optimize(bids, request)
 	if err != nil && bo.metricsReporter != nil {
 		bo.metricsReporter.RecordOptimizationError(err)
 	}
 
 	return optimizedBids, err
-}
\ No newline at end of file
+}
+
+// OptimizeAuction ranks an auction's bids like OptimizeBidSet, noting the multipliers applied to
+// each bidding partner in the auction's debug output
+func (bo *BidOptimizer) OptimizeAuction(auction *models.AuctionContext, bids []*models.Bid, request *models.BidRequest) ([]*models.Bid, error) {
+	if debug := auction.Debug(); debug != nil {
+		for _, bid := range bids {
+			debug.RecordMultipliers(bid.PartnerID, bo.PartnerMultipliers(bid.PartnerID, request))
+		}
+	}
+	return bo.OptimizeBidSet(bids, request)
+}
+
+// strategyFor returns the strategy for a vertical, falling back to the default strategy
+func (bo *BidOptimizer) strategyFor(vertical string) OptimizationStrategy {
+	if strategy, exists := bo.strategies[vertical]; exists {
+		return strategy
+	}
+	if strategy, exists := bo.strategies[config.DefaultStrategyKey]; exists {
+		return strategy
+	}
+	return bo.defaultStrategy
+}
+
+// TimeMultiplier returns the time-of-day multiplier currently applied to a vertical
+func (bo *BidOptimizer) TimeMultiplier(vertical string) float64 {
+	return bo.schedule.Multiplier(vertical, bo.clock.Now())
+}
+
+// PartnerMultipliers returns the time, vertical, and device multipliers the effective-price
+// strategy applies to a partner's bids for request, keyed by multiplier name
+func (bo *BidOptimizer) PartnerMultipliers(partnerID string, request *models.BidRequest) map[string]float64 {
+	partner, exists := bo.config.Partners[partnerID]
+	if !exists {
+		return nil
+	}
+
+	vertical := requestVertical(request)
+	return map[string]float64{
+		"time":     bo.TimeMultiplier(vertical),
+		"vertical": verticalMultiplier(partner, vertical),
+		"device":   deviceMultiplier(partner, request),
+	}
+}
+
+// EffectivePrice returns the effective price the effective-price strategy gives bid for request
+// now, and false when the bid is outside the bid bounds or from an unknown partner
+func (bo *BidOptimizer) EffectivePrice(bid *models.Bid, request *models.BidRequest) (float64, bool) {
+	effectivePrice, err := calculateEffectivePrice(bid, request, bo.TimeMultiplier(requestVertical(request)), bo.config)
+	return effectivePrice, err == nil
+}
+
+// PriceForEffectivePrice inverts EffectivePrice: it returns the cost per lead at which bid, with
+// its quality score and lead quality acknowledgement, reaches effectivePrice for request now. Deal
+// bids compete at their deal price, so for them it is effectivePrice itself. The result is not held
+// to the bid bounds, and an effective price clamped to them does not invert to the price behind it.
+func (bo *BidOptimizer) PriceForEffectivePrice(bid *models.Bid, request *models.BidRequest, effectivePrice float64) (float64, bool) {
+	if _, isDeal := bo.config.Deals[bid.DealID]; isDeal && bid.DealID != "" {
+		return effectivePrice, true
+	}
+	multiplier, exists := priceMultiplier(bid, request, bo.TimeMultiplier(requestVertical(request)), bo.config)
+	if !exists || multiplier <= 0 {
+		return 0, false
+	}
+	return effectivePrice / multiplier, true
+}
+
+// RanksByEffectivePrice reports whether a vertical's bids are ranked by the effective-price strategy
+func (bo *BidOptimizer) RanksByEffectivePrice(vertical string) bool {
+	bo.mutex.RLock()
+	defer bo.mutex.RUnlock()
+	_, ok := bo.strategyFor(vertical).(*EffectivePriceStrategy)
+	return ok
+}
+
+// EffectivePriceRange returns the lowest and highest effective price the effective-price strategy
+// can give a bid from partnerID for request, over every price in [minPrice, maxPrice], every
+// quality score, and every time-of-day multiplier of the vertical's schedule. acknowledged is
+// whether the bid acknowledges lead quality, or nil when either is possible. It returns false for
+// an unknown partner, or when no price in the range is within the configured bid bounds.
+func (bo *BidOptimizer) EffectivePriceRange(partnerID string, minPrice, maxPrice float64, acknowledged *bool, request *models.BidRequest) (float64, float64, bool) {
+	partner, exists := bo.config.Partners[partnerID]
+	if !exists {
+		return 0, 0, false
+	}
+	minPrice = math.Max(minPrice, bo.config.MinBidPrice)
+	maxPrice = math.Min(maxPrice, bo.config.MaxBidPrice)
+	if minPrice > maxPrice {
+		return 0, 0, false
+	}
+
+	bonuses := []float64{1.0}
+	if request != nil && request.LeadQuality != nil {
+		switch {
+		case acknowledged == nil:
+			bonuses = append(bonuses, 1.0+bo.config.QualityAcknowledgedBonus)
+		case *acknowledged:
+			bonuses[0] = 1.0 + bo.config.QualityAcknowledgedBonus
+		}
+	}
+	vertical := requestVertical(request)
+	lowTime, highTime := bo.schedule.Range(vertical)
+	partnerMultiplier := verticalMultiplier(partner, vertical) * deviceMultiplier(partner, request)
+	weight := bo.config.QualityScoreWeight()
+
+	// The effective price is linear in the price, quality score, and time multiplier alike, so
+	// its extremes are at the corners of their ranges whatever the signs of the weights
+	low, high := math.Inf(1), math.Inf(-1)
+	for _, price := range []float64{minPrice, maxPrice} {
+		for _, quality := range []float64{minQualityScore, maxQualityScore} {
+			for _, bonus := range bonuses {
+				for _, timeMultiplier := range []float64{lowTime, highTime} {
+					effectivePrice := price * (1.0 + quality*weight) * bonus * timeMultiplier * partnerMultiplier
+					low = math.Min(low, effectivePrice)
+					high = math.Max(high, effectivePrice)
+				}
+			}
+		}
+	}
+	clamp := func(price float64) float64 {
+		return math.Max(bo.config.MinBidPrice, math.Min(bo.config.MaxBidPrice, price))
+	}
+	return clamp(low), clamp(high), true
+}
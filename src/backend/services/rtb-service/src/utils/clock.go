@@ -0,0 +1,18 @@
+package utils
+
+import (
+	"time"
+)
+
+// Clock provides the current time so schedules can be evaluated against a frozen time in tests
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock is a Clock backed by the system time
+type SystemClock struct{}
+
+// Now returns the current system time
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
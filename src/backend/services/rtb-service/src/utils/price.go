@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// noiseDigits is the number of decimal places a price is read at before rounding. Formatting at
+// this precision first absorbs float noise such as 10.470000000000001 or the binary
+// 1.00499999999999989 behind 1.005, so rounding sees the decimal price a partner meant.
+const noiseDigits = 9
+
+// PriceRounder rounds clearing prices to a fixed number of decimal places
+type PriceRounder struct {
+	precision int
+	mode      string
+}
+
+// NewPriceRounder builds a rounder from configuration; a nil config rounds half-up to cents
+func NewPriceRounder(cfg *config.PriceRoundingConfig) *PriceRounder {
+	if cfg == nil {
+		return &PriceRounder{precision: config.DefaultPricePrecision, mode: config.RoundingHalfUp}
+	}
+
+	mode := cfg.Mode
+	if mode == "" {
+		mode = config.RoundingHalfUp
+	}
+	return &PriceRounder{precision: cfg.Precision, mode: mode}
+}
+
+// Round returns price rounded to the configured precision. Rounding works on the decimal digits
+// rather than on price*10^precision, which would itself pick up float error. NaN and infinities
+// are returned unchanged.
+func (r *PriceRounder) Round(price float64) float64 {
+	if math.IsNaN(price) || math.IsInf(price, 0) {
+		return price
+	}
+
+	negative := price < 0
+	var buffer [64]byte
+	formatted := strconv.AppendFloat(buffer[:0], math.Abs(price), 'f', noiseDigits, 64)
+
+	// Drop the decimal point so the kept digits form one integer scaled by 10^precision
+	point := len(formatted) - noiseDigits - 1
+	digits := append(formatted[:point], formatted[point+1:]...)
+	kept, dropped := digits[:len(digits)-noiseDigits+r.precision], digits[len(digits)-noiseDigits+r.precision:]
+
+	if r.roundsAway(dropped, negative) {
+		kept = incrementDigits(kept)
+	}
+
+	rounded, err := strconv.ParseFloat(string(kept)+"e-"+strconv.Itoa(r.precision), 64)
+	if err != nil || rounded == 0 {
+		return 0
+	}
+	if negative {
+		return -rounded
+	}
+	return rounded
+}
+
+// roundsAway reports whether dropping digits moves the magnitude up. Half-up rounds ties away from
+// zero; floor rounds toward negative infinity, so only negative prices move away from zero.
+func (r *PriceRounder) roundsAway(dropped []byte, negative bool) bool {
+	if len(dropped) == 0 {
+		return false
+	}
+	if r.mode == config.RoundingFloor {
+		if !negative {
+			return false
+		}
+		for _, digit := range dropped {
+			if digit != '0' {
+				return true
+			}
+		}
+		return false
+	}
+	return dropped[0] >= '5'
+}
+
+// incrementDigits adds one to a string of decimal digits, growing it when the carry overflows
+func incrementDigits(digits []byte) []byte {
+	for i := len(digits) - 1; i >= 0; i-- {
+		if digits[i] < '9' {
+			digits[i]++
+			return digits
+		}
+		digits[i] = '0'
+	}
+	return append([]byte{'1'}, digits...)
+}
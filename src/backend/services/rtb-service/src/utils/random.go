@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"sync"
+)
+
+// lockedSource is a rand.Source safe for concurrent use
+type lockedSource struct {
+	mutex  sync.Mutex
+	source rand.Source
+}
+
+// NewLockedSource wraps source so it can be shared across goroutines
+func NewLockedSource(source rand.Source) rand.Source {
+	return &lockedSource{source: source}
+}
+
+// Int63 returns the next value from the underlying source
+func (s *lockedSource) Int63() int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.source.Int63()
+}
+
+// Seed reseeds the underlying source
+func (s *lockedSource) Seed(seed int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.source.Seed(seed)
+}
+
+// RequestSeed derives a seed from a request ID and the purpose of the draw, so each use of
+// randomness in an auction gets its own sequence that is reproducible from the request ID
+func RequestSeed(requestID, purpose string) int64 {
+	hash := fnv.New64a()
+	hash.Write([]byte(requestID))
+	hash.Write([]byte{0})
+	hash.Write([]byte(purpose))
+	return int64(hash.Sum64())
+}
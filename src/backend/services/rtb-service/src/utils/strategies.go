@@ -0,0 +1,124 @@
+package utils
+
+import (
+	"sort"
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// qualityDominantWeight is the share of the quality-weighted score taken by quality rather than price
+const qualityDominantWeight = 0.7
+
+// OptimizationStrategy ranks a set of bids for a request, best first
+type OptimizationStrategy interface {
+	Optimize(bids []*models.Bid, request *models.BidRequest) ([]*models.Bid, error)
+}
+
+// EffectivePriceStrategy ranks bids by effective price including quality, time, and vertical multipliers
+type EffectivePriceStrategy struct {
+	config   *config.Config
+	schedule *timeSchedule
+	clock    Clock
+}
+
+// NewEffectivePriceStrategy creates an effective-price strategy evaluating time-of-day multipliers against clock
+func NewEffectivePriceStrategy(cfg *config.Config, clock Clock) *EffectivePriceStrategy {
+	return &EffectivePriceStrategy{
+		config:   cfg,
+		schedule: newTimeSchedule(cfg.TimeMultipliers),
+		clock:    clock,
+	}
+}
+
+// Optimize ranks bids by descending effective price
+func (s *EffectivePriceStrategy) Optimize(bids []*models.Bid, request *models.BidRequest) ([]*models.Bid, error) {
+	vertical := requestVertical(request)
+	timeMultiplier := s.schedule.Multiplier(vertical, s.clock.Now())
+	return optimizeByEffectivePrice(bids, request, timeMultiplier, s.config)
+}
+
+// QualityWeightedStrategy ranks bids primarily by quality score, using price relative to the best bid as a tiebreaker
+type QualityWeightedStrategy struct {
+	config *config.Config
+}
+
+// NewQualityWeightedStrategy creates a quality-weighted strategy
+func NewQualityWeightedStrategy(cfg *config.Config) *QualityWeightedStrategy {
+	return &QualityWeightedStrategy{config: cfg}
+}
+
+// Optimize ranks eligible bids by a blend of quality score and normalized price
+func (s *QualityWeightedStrategy) Optimize(bids []*models.Bid, request *models.BidRequest) ([]*models.Bid, error) {
+	if bids == nil || s.config == nil {
+		return nil, ErrInvalidInput
+	}
+
+	eligible := make([]*models.Bid, 0, len(bids))
+	maxPrice := 0.0
+	for _, bid := range bids {
+		if _, err := calculateEffectivePrice(bid, request, 1.0, s.config); err != nil {
+			continue
+		}
+		bid.QualityScore = clampQualityScore(bid.QualityScore)
+		if bid.CPL() > maxPrice {
+			maxPrice = bid.CPL()
+		}
+		eligible = append(eligible, bid)
+	}
+
+	scores := make(map[*models.Bid]float64, len(eligible))
+	for _, bid := range eligible {
+		relativePrice := 0.0
+		if maxPrice > 0 {
+			relativePrice = bid.CPL() / maxPrice
+		}
+		scores[bid] = (1-qualityDominantWeight)*relativePrice + qualityDominantWeight*bid.QualityScore
+	}
+
+	sort.SliceStable(eligible, func(i, j int) bool {
+		return scores[eligible[i]] > scores[eligible[j]]
+	})
+	return eligible, nil
+}
+
+// PassthroughStrategy returns bids in arrival order without reranking
+type PassthroughStrategy struct{}
+
+// Optimize returns a copy of bids unchanged
+func (PassthroughStrategy) Optimize(bids []*models.Bid, request *models.BidRequest) ([]*models.Bid, error) {
+	if bids == nil {
+		return nil, ErrInvalidInput
+	}
+	return append([]*models.Bid(nil), bids...), nil
+}
+
+// RankByPrice returns a copy of bids ranked by descending cost per lead, the fallback ranking
+// when there is no time left to run a strategy
+func RankByPrice(bids []*models.Bid) []*models.Bid {
+	ranked := append([]*models.Bid(nil), bids...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].CPL() > ranked[j].CPL()
+	})
+	return ranked
+}
+
+// newStrategy builds a named strategy, falling back to effective price for unknown names
+func newStrategy(name string, cfg *config.Config, clock Clock) OptimizationStrategy {
+	switch name {
+	case config.StrategyQualityWeighted:
+		return NewQualityWeightedStrategy(cfg)
+	case config.StrategyPassthrough:
+		return PassthroughStrategy{}
+	default:
+		return NewEffectivePriceStrategy(cfg, clock)
+	}
+}
+
+// requestVertical returns the request vertical, or empty when there is no request
+func requestVertical(request *models.BidRequest) string {
+	if request == nil {
+		return ""
+	}
+	return request.Vertical
+}
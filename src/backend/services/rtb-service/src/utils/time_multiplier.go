@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"math"
+	"time"
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// timeSchedule evaluates configured time-of-day multipliers in the schedule timezone
+type timeSchedule struct {
+	location  *time.Location
+	rules     []config.TimeMultiplierRule
+	verticals map[string][]config.TimeMultiplierRule
+}
+
+// newTimeSchedule builds a schedule from configuration; a nil config yields a multiplier of 1.0
+func newTimeSchedule(cfg *config.TimeMultiplierConfig) *timeSchedule {
+	schedule := &timeSchedule{location: time.UTC}
+	if cfg == nil {
+		return schedule
+	}
+
+	if location, err := time.LoadLocation(cfg.Timezone); err == nil {
+		schedule.location = location
+	}
+	schedule.rules = cfg.Rules
+	schedule.verticals = cfg.Verticals
+	return schedule
+}
+
+// Multiplier returns the multiplier for a vertical at the given instant, or 1.0 when no rule matches
+func (ts *timeSchedule) Multiplier(vertical string, now time.Time) float64 {
+	rules := ts.rulesFor(vertical)
+	local := now.In(ts.location)
+	hour := local.Hour()
+	for _, rule := range rules {
+		if hour < rule.StartHour || hour >= rule.EndHour {
+			continue
+		}
+		for _, day := range config.RuleDays(rule.Days) {
+			if day == local.Weekday() {
+				return rule.Multiplier
+			}
+		}
+	}
+	return 1.0
+}
+
+// Range returns the lowest and highest multipliers a vertical can get at any instant, including
+// the 1.0 applied when no rule matches
+func (ts *timeSchedule) Range(vertical string) (float64, float64) {
+	low, high := 1.0, 1.0
+	for _, rule := range ts.rulesFor(vertical) {
+		low = math.Min(low, rule.Multiplier)
+		high = math.Max(high, rule.Multiplier)
+	}
+	return low, high
+}
+
+// rulesFor returns a vertical's rules, falling back to the default rules
+func (ts *timeSchedule) rulesFor(vertical string) []config.TimeMultiplierRule {
+	if rules, exists := ts.verticals[vertical]; exists {
+		return rules
+	}
+	return ts.rules
+}
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+// ulidAlphabet is Crockford's base32, which keeps ULIDs sortable as text
+const ulidAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULIDSource generates ULIDs: a millisecond timestamp followed by 80 random bits, encoded as 26
+// characters that sort in generation order. Within a millisecond the random bits are incremented
+// rather than redrawn, so ULIDs from one source never sort out of order.
+type ULIDSource struct {
+	clock   Clock
+	mutex   sync.Mutex
+	lastMs  uint64
+	entropy [10]byte
+}
+
+// NewULIDSource creates a ULID source timestamping against clock
+func NewULIDSource(clock Clock) *ULIDSource {
+	if clock == nil {
+		clock = SystemClock{}
+	}
+	return &ULIDSource{clock: clock}
+}
+
+// New returns the next ULID
+func (u *ULIDSource) New() string {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	ms := uint64(u.clock.Now().UnixMilli())
+	if ms > u.lastMs {
+		u.lastMs = ms
+		rand.Read(u.entropy[:])
+	} else {
+		// A clock that stalls or steps back keeps the last timestamp so order is kept
+		for i := len(u.entropy) - 1; i >= 0; i-- {
+			u.entropy[i]++
+			if u.entropy[i] != 0 {
+				break
+			}
+		}
+	}
+
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:2], uint16(u.lastMs>>32))
+	binary.BigEndian.PutUint32(id[2:6], uint32(u.lastMs))
+	copy(id[6:], u.entropy[:])
+	return encodeULID(id)
+}
+
+// encodeULID encodes 128 bits as 26 base32 characters, five bits each from the most significant
+// end, the first character carrying the top three
+func encodeULID(id [16]byte) string {
+	high, low := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	var text [26]byte
+	for i := len(text) - 1; i >= 0; i-- {
+		text[i] = ulidAlphabet[low&31]
+		low = low>>5 | high<<59
+		high >>= 5
+	}
+	return string(text[:])
+}
+
+// ULIDTime returns the time encoded in a ULID's timestamp, or false for text that is not a ULID
+func ULIDTime(ulid string) (time.Time, bool) {
+	if len(ulid) != 26 {
+		return time.Time{}, false
+	}
+	var ms uint64
+	for i := 0; i < 10; i++ {
+		index := -1
+		for j := 0; j < len(ulidAlphabet); j++ {
+			if ulidAlphabet[j] == ulid[i] {
+				index = j
+				break
+			}
+		}
+		if index < 0 {
+			return time.Time{}, false
+		}
+		ms = ms<<5 | uint64(index)
+	}
+	return time.UnixMilli(int64(ms)).UTC(), true
+}
@@ -0,0 +1,388 @@
+// Package webhooks delivers signed notifications of auction outcomes to external systems
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+	"go.uber.org/zap"                                // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// Delivery headers
+const (
+	HeaderEvent          = "X-Webhook-Event"
+	HeaderIdempotencyKey = "Idempotency-Key"
+	HeaderTimestamp      = "X-Webhook-Timestamp"
+	HeaderSignature      = "X-Webhook-Signature"
+)
+
+// Delivery results counted in metrics
+const (
+	resultSuccess = "success"
+	resultFailure = "failure"
+	resultDropped = "dropped"
+)
+
+// Delivery errors
+var (
+	ErrQueueFull = errors.New("webhook queue full")
+	ErrShutdown  = errors.New("webhook dispatcher shut down")
+)
+
+var (
+	deliveriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_webhook_deliveries_total",
+			Help: "Total number of webhook deliveries by event and result",
+		},
+		[]string{"event", "result"},
+	)
+
+	retriesTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "rtb_webhook_retries_total",
+			Help: "Total number of webhook delivery retries by event",
+		},
+		[]string{"event"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(deliveriesTotal, retriesTotal)
+}
+
+// Event is a webhook notification. ID is stable for the same outcome, so receivers can
+// discard repeated deliveries. Region is the service region the event came from, and ConfigHash
+// the version of the config it was running.
+type Event struct {
+	ID         string      `json:"id"`
+	Type       string      `json:"type"`
+	CreatedAt  time.Time   `json:"created_at"`
+	Region     string      `json:"region,omitempty"`
+	ConfigHash string      `json:"config_hash,omitempty"`
+	Data       interface{} `json:"data"`
+}
+
+// Failure is a delivery that was not acknowledged, as listed by the admin endpoint and written
+// to the dead letter log
+type Failure struct {
+	Time     time.Time       `json:"time"`
+	URL      string          `json:"url"`
+	EventID  string          `json:"event_id"`
+	Event    string          `json:"event"`
+	Attempts int             `json:"attempts"`
+	Error    string          `json:"error"`
+	Payload  json.RawMessage `json:"payload"`
+}
+
+// StatusError reports a non-2xx webhook response
+type StatusError struct {
+	Status int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("webhook returned status %d", e.Status)
+}
+
+// delivery is one event to be sent to one endpoint
+type delivery struct {
+	url     string
+	event   string
+	id      string
+	payload []byte
+}
+
+// Dispatcher sends events to subscribed endpoints from a pool of workers, retrying failed
+// deliveries with backoff. Deliveries that exhaust their attempts, cannot be queued, or are
+// pending at shutdown are appended to the dead letter log, so every event is either
+// acknowledged or recorded for replay.
+type Dispatcher struct {
+	config      *config.WebhooksConfig
+	secret      []byte
+	client      *http.Client
+	queue       chan delivery
+	workers     sync.WaitGroup
+	mutex       sync.RWMutex
+	closed      bool
+	retryCtx    context.Context
+	stopRetries context.CancelFunc
+	sendCtx     context.Context
+	stopSends   context.CancelFunc
+	failMutex   sync.Mutex
+	failures    []Failure
+	deadLetters *os.File
+	logger      *zap.Logger
+}
+
+// NewDispatcher opens the dead letter log and starts the delivery workers, or returns nil when
+// webhooks are disabled
+func NewDispatcher(cfg *config.WebhooksConfig) (*Dispatcher, error) {
+	if cfg == nil || !cfg.Enabled {
+		return nil, nil
+	}
+
+	d := &Dispatcher{
+		config:   cfg,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		queue:    make(chan delivery, cfg.QueueSize),
+		failures: make([]Failure, 0, cfg.RecentFailures),
+		logger:   zap.NewNop(),
+	}
+	if cfg.Secret != "" {
+		secret, err := config.ResolveSecret(cfg.Secret)
+		if err != nil {
+			return nil, fmt.Errorf("resolving webhook secret: %w", err)
+		}
+		d.secret = []byte(secret)
+	}
+	file, err := os.OpenFile(cfg.DeadLetterPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("opening webhook dead letter log: %w", err)
+	}
+	d.deadLetters = file
+	d.retryCtx, d.stopRetries = context.WithCancel(context.Background())
+	d.sendCtx, d.stopSends = context.WithCancel(context.Background())
+
+	for i := 0; i < cfg.Workers; i++ {
+		d.workers.Add(1)
+		go d.run()
+	}
+	return d, nil
+}
+
+// SetLogger sets the logger used for failed deliveries
+func (d *Dispatcher) SetLogger(logger *zap.Logger) {
+	if d != nil && logger != nil {
+		d.logger = logger
+	}
+}
+
+// Dispatch queues event for every endpoint subscribed to its type without waiting for delivery
+func (d *Dispatcher) Dispatch(event Event) {
+	if d == nil {
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		d.logger.Error("error encoding webhook event", zap.String("event", event.Type), zap.Error(err))
+		return
+	}
+
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	for _, endpoint := range d.config.Endpoints {
+		if !endpoint.Wants(event.Type) {
+			continue
+		}
+		next := delivery{url: endpoint.URL, event: event.Type, id: event.ID, payload: payload}
+		if d.closed {
+			d.fail(next, 0, ErrShutdown, resultDropped)
+			continue
+		}
+		select {
+		case d.queue <- next:
+		default:
+			d.fail(next, 0, ErrQueueFull, resultDropped)
+		}
+	}
+}
+
+// Failures returns the most recent failed deliveries, newest first
+func (d *Dispatcher) Failures() []Failure {
+	if d == nil {
+		return []Failure{}
+	}
+	d.failMutex.Lock()
+	defer d.failMutex.Unlock()
+
+	failures := make([]Failure, len(d.failures))
+	for i, failure := range d.failures {
+		failures[len(d.failures)-1-i] = failure
+	}
+	return failures
+}
+
+// Close stops accepting events and stops retrying, gives queued deliveries one attempt within
+// the webhook timeout, dead-letters whatever remains, and closes the dead letter log
+func (d *Dispatcher) Close() error {
+	if d == nil {
+		return nil
+	}
+
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		return nil
+	}
+	d.closed = true
+	close(d.queue)
+	d.mutex.Unlock()
+
+	d.stopRetries()
+	grace := time.AfterFunc(d.config.Timeout, d.stopSends)
+	d.workers.Wait()
+	grace.Stop()
+	d.stopSends()
+
+	d.failMutex.Lock()
+	defer d.failMutex.Unlock()
+	err := d.deadLetters.Close()
+	d.deadLetters = nil
+	return err
+}
+
+// run delivers queued events until the queue is closed
+func (d *Dispatcher) run() {
+	defer d.workers.Done()
+	for next := range d.queue {
+		d.deliver(next)
+	}
+}
+
+// deliver sends a delivery, retrying transport errors, timeouts, 429s, and 5xx responses
+func (d *Dispatcher) deliver(next delivery) {
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		if err = d.send(next); err == nil {
+			deliveriesTotal.WithLabelValues(next.event, resultSuccess).Inc()
+			return
+		}
+		if attempt == d.config.MaxAttempts || !retryable(err) {
+			break
+		}
+		if !d.wait(backoff(d.config.BackoffBase, d.config.BackoffMax, attempt)) {
+			err = fmt.Errorf("%w after: %v", ErrShutdown, err)
+			break
+		}
+		retriesTotal.WithLabelValues(next.event).Inc()
+	}
+	d.fail(next, attempt, err, resultFailure)
+}
+
+// send makes one signed delivery attempt
+func (d *Dispatcher) send(next delivery) error {
+	if err := d.sendCtx.Err(); err != nil {
+		return ErrShutdown
+	}
+	req, err := http.NewRequestWithContext(d.sendCtx, http.MethodPost, next.url, bytes.NewReader(next.payload))
+	if err != nil {
+		return err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, next.event)
+	req.Header.Set(HeaderIdempotencyKey, next.id)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	if d.secret != nil {
+		req.Header.Set(HeaderSignature, Sign(d.secret, timestamp, next.payload))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return &StatusError{Status: resp.StatusCode}
+	}
+	return nil
+}
+
+// wait sleeps for a retry backoff, returning false when the dispatcher shuts down first
+func (d *Dispatcher) wait(delay time.Duration) bool {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-d.retryCtx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// fail records an unacknowledged delivery in the recent failures and the dead letter log
+func (d *Dispatcher) fail(next delivery, attempts int, err error, result string) {
+	deliveriesTotal.WithLabelValues(next.event, result).Inc()
+	failure := Failure{
+		Time:     time.Now().UTC(),
+		URL:      next.url,
+		EventID:  next.id,
+		Event:    next.event,
+		Attempts: attempts,
+		Error:    err.Error(),
+		Payload:  next.payload,
+	}
+	d.logger.Warn("webhook delivery failed",
+		zap.String("url", failure.URL),
+		zap.String("event", failure.Event),
+		zap.String("event_id", failure.EventID),
+		zap.Int("attempts", attempts),
+		zap.Error(err),
+	)
+
+	line, _ := json.Marshal(failure)
+	d.failMutex.Lock()
+	defer d.failMutex.Unlock()
+	if d.config.RecentFailures > 0 {
+		if len(d.failures) == d.config.RecentFailures {
+			copy(d.failures, d.failures[1:])
+			d.failures = d.failures[:len(d.failures)-1]
+		}
+		d.failures = append(d.failures, failure)
+	}
+	if d.deadLetters == nil {
+		return
+	}
+	if _, err := d.deadLetters.Write(append(line, '\n')); err != nil {
+		d.logger.Error("error writing webhook dead letter", zap.String("event_id", failure.EventID), zap.Error(err))
+	}
+}
+
+// Sign returns the signature header value for a payload sent at timestamp: the hex
+// HMAC-SHA256 of "timestamp.payload", prefixed with "sha256="
+func Sign(secret []byte, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryable reports whether a failed attempt may succeed when repeated
+func retryable(err error) bool {
+	if errors.Is(err, ErrShutdown) {
+		return false
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		return true
+	}
+	return statusErr.Status >= 500 || statusErr.Status == http.StatusTooManyRequests || statusErr.Status == http.StatusRequestTimeout
+}
+
+// backoff returns a full-jitter exponential backoff for the retry after attempt, capped at max
+func backoff(base, max time.Duration, attempt int) time.Duration {
+	ceiling := max
+	if attempt < 32 && base<<(attempt-1) < max {
+		ceiling = base << (attempt - 1)
+	}
+	return time.Duration(rand.Int63n(int64(ceiling) + 1))
+}
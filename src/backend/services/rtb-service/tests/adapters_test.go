@@ -0,0 +1,251 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// legacyMapping maps our schema onto the mapped_bid.json partner's field names
+var legacyMapping = &config.FieldMapping{
+	Request: map[string]string{
+		"ref":            "request_id",
+		"applicant.zip":  "user_data.zip",
+		"applicant.type": "vertical",
+	},
+	Response: map[string]string{
+		"id":            "offer.offer_id",
+		"price":         "offer.payout",
+		"click_url":     "offer.redirect",
+		"quality_score": "offer.score",
+		"adomain":       "offer.advertiser",
+	},
+}
+
+// newAdapterTestRequest returns a request with UserData for adapter encoding tests
+func newAdapterTestRequest() *models.BidRequest {
+	return &models.BidRequest{
+		RequestID: "adapter-test",
+		LeadID:    "lead-1",
+		Vertical:  "auto",
+		UserData:  map[string]interface{}{"zip": "78701", "age": 34},
+	}
+}
+
+// loadAdapterFixture reads a recorded partner response
+func loadAdapterFixture(t *testing.T, name string) []byte {
+	data, err := os.ReadFile("testdata/adapters/" + name)
+	require.NoError(t, err)
+	return data
+}
+
+// readRequestBody reads a built partner request body
+func readRequestBody(t *testing.T, request *http.Request) string {
+	body, err := io.ReadAll(request.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+// TestXMLAdapter tests XML request encoding and parsing of recorded XML responses
+func TestXMLAdapter(t *testing.T) {
+	adapter := services.XMLAdapter{}
+	partner := &config.PartnerConfig{Endpoint: "http://legacy.example.com/bid"}
+
+	request, err := adapter.BuildRequest(newAdapterTestRequest(), partner)
+	require.NoError(t, err)
+	assert.Equal(t, "application/xml", request.Header.Get("Content-Type"))
+	body := readRequestBody(t, request)
+	assert.Contains(t, body, "<RequestID>adapter-test</RequestID>")
+	assert.Contains(t, body, `<UserData><Field name="age">34</Field><Field name="zip">78701</Field></UserData>`)
+
+	bids, err := adapter.ParseResponse(loadAdapterFixture(t, "xml_bid.xml"), http.StatusOK)
+	require.NoError(t, err)
+	require.Len(t, bids, 1)
+	bid := bids[0]
+	assert.Equal(t, "legacy-7731", bid.ID)
+	assert.Equal(t, 12.75, bid.Price)
+	assert.Equal(t, 0.82, bid.QualityScore)
+	assert.Equal(t, []string{"carrier.example.com"}, bid.AdvertiserDomains)
+	assert.Equal(t, time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), bid.ExpiresAt)
+
+	_, err = adapter.ParseResponse(loadAdapterFixture(t, "xml_bid_bad_price.xml"), http.StatusOK)
+	var adapterErr *services.AdapterError
+	require.ErrorAs(t, err, &adapterErr)
+	assert.Equal(t, "xml", adapterErr.Adapter)
+	assert.Equal(t, "Price", adapterErr.Field)
+}
+
+// TestMappedJSONAdapter tests field-mapped request building and parsing of recorded partner responses
+func TestMappedJSONAdapter(t *testing.T) {
+	adapter := services.NewMappedJSONAdapter(legacyMapping)
+	partner := &config.PartnerConfig{Endpoint: "http://mapped.example.com/bid"}
+
+	request, err := adapter.BuildRequest(newAdapterTestRequest(), partner)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"ref":"adapter-test","applicant":{"zip":"78701","type":"auto"}}`, readRequestBody(t, request))
+
+	bids, err := adapter.ParseResponse(loadAdapterFixture(t, "mapped_bid.json"), http.StatusOK)
+	require.NoError(t, err)
+	require.Len(t, bids, 1)
+	bid := bids[0]
+	assert.Equal(t, "99812", bid.ID)
+	assert.Equal(t, 14.20, bid.Price)
+	assert.Equal(t, "https://mapped-buyer.example.com/r/99812", bid.ClickURL)
+	assert.Equal(t, []string{"carrier.example.com"}, bid.AdvertiserDomains)
+
+	_, err = adapter.ParseResponse(loadAdapterFixture(t, "mapped_bid_missing_url.json"), http.StatusOK)
+	var adapterErr *services.AdapterError
+	require.ErrorAs(t, err, &adapterErr)
+	assert.Equal(t, "mapped_json", adapterErr.Adapter)
+	assert.Equal(t, "offer.redirect", adapterErr.Field)
+}
+
+// TestMultiSeatResponses tests that each adapter returns every seat in a multi-bid response
+func TestMultiSeatResponses(t *testing.T) {
+	seatMapping := &config.FieldMapping{
+		Request: legacyMapping.Request,
+		Response: map[string]string{
+			"bids":      "offers",
+			"id":        "offer_id",
+			"price":     "payout",
+			"click_url": "redirect",
+		},
+	}
+
+	testCases := []struct {
+		name    string
+		adapter services.PartnerAdapter
+		fixture string
+	}{
+		{name: "JSON Array", adapter: services.JSONAdapter{}, fixture: "json_bids.json"},
+		{name: "XML Bids", adapter: services.XMLAdapter{}, fixture: "xml_bids.xml"},
+		{name: "Mapped Bids Path", adapter: services.NewMappedJSONAdapter(seatMapping), fixture: "mapped_bids.json"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bids, err := tc.adapter.ParseResponse(loadAdapterFixture(t, tc.fixture), http.StatusOK)
+			require.NoError(t, err)
+			require.Len(t, bids, 2)
+			assert.Equal(t, "seat-1", bids[0].ID)
+			assert.Equal(t, 11.5, bids[0].Price)
+			assert.Equal(t, "seat-2", bids[1].ID)
+			assert.Equal(t, 9.25, bids[1].Price)
+		})
+	}
+}
+
+// TestFormAdapter tests form-encoded requests using the partner's field names
+func TestFormAdapter(t *testing.T) {
+	adapter := services.NewFormAdapter(legacyMapping)
+	partner := &config.PartnerConfig{Endpoint: "http://form.example.com/bid"}
+
+	request, err := adapter.BuildRequest(newAdapterTestRequest(), partner)
+	require.NoError(t, err)
+	assert.Equal(t, "application/x-www-form-urlencoded", request.Header.Get("Content-Type"))
+
+	form, err := url.ParseQuery(readRequestBody(t, request))
+	require.NoError(t, err)
+	assert.Equal(t, "adapter-test", form.Get("ref"))
+	assert.Equal(t, "78701", form.Get("applicant.zip"))
+}
+
+// TestAdapterStatusHandling tests that every adapter treats 204 as a no-bid and other statuses as errors
+func TestAdapterStatusHandling(t *testing.T) {
+	adapters := map[string]services.PartnerAdapter{
+		"json":        services.JSONAdapter{},
+		"xml":         services.XMLAdapter{},
+		"mapped_json": services.NewMappedJSONAdapter(legacyMapping),
+		"form":        services.NewFormAdapter(legacyMapping),
+	}
+
+	for name, adapter := range adapters {
+		t.Run(name, func(t *testing.T) {
+			bids, err := adapter.ParseResponse(nil, http.StatusNoContent)
+			assert.True(t, errors.Is(err, services.ErrNoBid))
+			assert.Empty(t, bids)
+
+			_, err = adapter.ParseResponse([]byte("oops"), http.StatusBadGateway)
+			assert.True(t, errors.Is(err, services.ErrUnexpectedStatus))
+		})
+	}
+}
+
+// TestAuctionUsesPartnerAdapter tests that auctions talk to each partner in its configured format
+func TestAuctionUsesPartnerAdapter(t *testing.T) {
+	fixture := loadAdapterFixture(t, "xml_bid.xml")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/xml" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		w.Header().Set("Content-Type", "application/xml")
+		w.Write(fixture)
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"legacy": {ID: "legacy", Endpoint: server.URL, APIKey: "key", Timeout: 200 * time.Millisecond, Enabled: true, Format: config.FormatXML},
+	})
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	response, err := service.RunAuction(ctx, newAdapterTestRequest())
+	require.NoError(t, err)
+	require.Len(t, response.Bids, 1)
+	assert.Equal(t, "legacy-7731", response.Bids[0].ID)
+	assert.Equal(t, "legacy", response.Bids[0].PartnerID)
+}
+
+// TestPartnerFormatValidation tests that mapped formats require a complete field mapping
+func TestPartnerFormatValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		format      string
+		mapping     *config.FieldMapping
+		expectedErr string
+	}{
+		{name: "Default JSON", format: ""},
+		{name: "XML", format: config.FormatXML},
+		{name: "Mapped JSON", format: config.FormatMappedJSON, mapping: legacyMapping},
+		{name: "Unknown Format", format: "soap", expectedErr: "unknown format"},
+		{name: "Missing Mapping", format: config.FormatForm, expectedErr: "requires a request field mapping"},
+		{
+			name:        "Missing Price Mapping",
+			format:      config.FormatMappedJSON,
+			mapping:     &config.FieldMapping{Request: map[string]string{"ref": "request_id"}, Response: map[string]string{"id": "id", "click_url": "url"}},
+			expectedErr: `missing response field mapping "price"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].Format = tc.format
+			cfg.Partners["partner-1"].FieldMapping = tc.mapping
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
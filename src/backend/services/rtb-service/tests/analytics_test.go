@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newAnalyticsPartner returns a partner that bids price on every vertical except life
+func newAnalyticsPartner(t *testing.T, id string, price float64) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request models.BidRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Vertical == "life" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: id + "-bid", Price: price, QualityScore: 0.5, ClickURL: "http://" + id + ".example.com/c"})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newAnalyticsTestService creates a service with two partners bidding 10 and 20 that keeps windows hourly windows
+func newAnalyticsTestService(t *testing.T, clock *steppingClock, windows int) (*services.AuctionService, *config.Config) {
+	low := newAnalyticsPartner(t, "low", 10.0)
+	high := newAnalyticsPartner(t, "high", 20.0)
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"low":  {ID: "low", Endpoint: low.URL, APIKey: "key-low", Timeout: 200 * time.Millisecond, Enabled: true},
+		"high": {ID: "high", Endpoint: high.URL, APIKey: "key-high", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2))
+	cfg.Analytics = &config.AnalyticsConfig{Enabled: true, Windows: windows, MaxSeries: 3, Precision: 0.01, ByRegion: true}
+	service, err := services.NewAuctionServiceWithClock(cfg, clock)
+	require.NoError(t, err)
+	return service, cfg
+}
+
+// runAnalyticsAuction runs one auction for vertical in region, ignoring the no-bid outcome
+func runAnalyticsAuction(t *testing.T, service *services.AuctionService, vertical, region string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "analytics-test", LeadID: "lead-1", Vertical: vertical, Geo: &models.Geo{Region: region}})
+	if err != nil {
+		require.ErrorIs(t, err, services.ErrNoValidBids)
+	}
+}
+
+// TestPriceAnalytics tests clearing-price percentiles, rates, windowing, and the series limit
+func TestPriceAnalytics(t *testing.T) {
+	clock := &steppingClock{now: time.Date(2030, 6, 1, 12, 30, 0, 0, time.UTC)}
+	service, _ := newAnalyticsTestService(t, clock, 3)
+	droppedBefore := gatheredMetric(t, "rtb_analytics_series_dropped_total", nil)
+
+	for i := 0; i < 3; i++ {
+		runAnalyticsAuction(t, service, "auto", "TX")
+	}
+	runAnalyticsAuction(t, service, "life", "")
+	runAnalyticsAuction(t, service, "home", "")
+
+	clock.now = clock.now.Add(time.Hour)
+	runAnalyticsAuction(t, service, "auto", "CA")
+
+	testCases := []struct {
+		name               string
+		vertical           string
+		region             string
+		window             time.Duration
+		expectedCount      uint64
+		expectedPrices     []float64
+		expectedAvgWinners float64
+		expectedNoBidRate  float64
+	}{
+		{name: "Current Hour", vertical: "auto", window: time.Hour, expectedCount: 1, expectedPrices: []float64{10, 10, 20, 20}, expectedAvgWinners: 2},
+		{name: "Two Hours", vertical: "auto", window: 2 * time.Hour, expectedCount: 4, expectedPrices: []float64{10, 10, 20, 20}, expectedAvgWinners: 2},
+		{name: "Partial Hours Round Up", vertical: "auto", window: 90 * time.Minute, expectedCount: 4, expectedPrices: []float64{10, 10, 20, 20}, expectedAvgWinners: 2},
+		{name: "Region", vertical: "auto", region: "TX", window: 2 * time.Hour, expectedCount: 3, expectedPrices: []float64{10, 10, 20, 20}, expectedAvgWinners: 2},
+		{name: "No Bids", vertical: "life", window: 2 * time.Hour, expectedCount: 1, expectedPrices: []float64{0, 0, 0, 0}, expectedNoBidRate: 1},
+		{name: "Series Limit Reached", vertical: "home", window: 2 * time.Hour, expectedPrices: []float64{0, 0, 0, 0}},
+		{name: "Untracked Region", vertical: "auto", region: "CA", window: 2 * time.Hour, expectedPrices: []float64{0, 0, 0, 0}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			summary, err := service.PriceAnalytics(tc.vertical, tc.region, tc.window)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedCount, summary.Count)
+			actual := []float64{summary.P25, summary.P50, summary.P75, summary.P95}
+			for i, expected := range tc.expectedPrices {
+				assert.InDelta(t, expected, actual[i], expected*0.01)
+			}
+			assert.Equal(t, tc.expectedAvgWinners, summary.AvgWinners)
+			assert.Equal(t, tc.expectedNoBidRate, summary.NoBidRate)
+		})
+	}
+
+	// home and auto/CA were both past the three-series limit
+	assert.Equal(t, droppedBefore+2, gatheredMetric(t, "rtb_analytics_series_dropped_total", nil))
+
+	// Hours older than the retained windows are reused and drop out of every summary
+	clock.now = clock.now.Add(3 * time.Hour)
+	summary, err := service.PriceAnalytics("auto", "", 3*time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, summary.Count)
+}
+
+// TestPriceAnalyticsEndpoint tests query parsing and error statuses for the price analytics endpoint
+func TestPriceAnalyticsEndpoint(t *testing.T) {
+	clock := &steppingClock{now: time.Date(2030, 6, 1, 12, 30, 0, 0, time.UTC)}
+	service, cfg := newAnalyticsTestService(t, clock, 24)
+	runAnalyticsAuction(t, service, "auto", "TX")
+
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	router := gin.New()
+	router.GET("/v1/analytics/prices", handler.HandlePriceAnalytics)
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedWindow string
+	}{
+		{name: "Default Window", query: "vertical=auto", expectedStatus: http.StatusOK, expectedWindow: "24h0m0s"},
+		{name: "Region", query: "vertical=auto&region=TX&window=2h", expectedStatus: http.StatusOK, expectedWindow: "2h0m0s"},
+		{name: "Missing Vertical", query: "window=2h", expectedStatus: http.StatusBadRequest},
+		{name: "Malformed Window", query: "vertical=auto&window=week", expectedStatus: http.StatusBadRequest},
+		{name: "Window Beyond Retention", query: "vertical=auto&window=25h", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/v1/analytics/prices?"+tc.query, nil)
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedStatus == http.StatusOK {
+				var summary services.PriceSummary
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+				assert.Equal(t, uint64(1), summary.Count)
+				assert.Equal(t, tc.expectedWindow, summary.Window)
+			}
+		})
+	}
+}
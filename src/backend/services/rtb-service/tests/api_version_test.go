@@ -0,0 +1,196 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// newContractResponse returns an auction response using every response field, with a deal bid,
+// a revenue-share bid, and strings that encoding/json escapes
+func newContractResponse() *models.BidResponse {
+	timestamp := time.Date(2024, 1, 20, 10, 0, 0, 123456789, time.UTC)
+	return &models.BidResponse{
+		RequestID: "contract-1",
+		Bids: []*models.Bid{
+			{
+				ID: "bid-1", PartnerID: "partner-1", Price: 12.5, ClickURL: "https://example.com/click?a=1&b=<2>", QualityScore: 0.85,
+				ExpiresAt: timestamp.Add(5 * time.Minute), Creative: map[string]interface{}{"html": "<div>Offer</div>", "width": 300},
+				AdvertiserDomains: []string{"example.com"}, DealID: "deal-1", PricingModel: config.PricingModelCPL, NormalizedPrice: 12.5, BidPrice: 14.25,
+			},
+			{
+				ID: "bid-2", PartnerID: "partner-2", Price: 8, ClickURL: "https://example.com/2", QualityScore: 0.6,
+				ExpiresAt: timestamp.Add(5 * time.Minute), PricingModel: config.PricingModelRevShare, NormalizedPrice: 9.6,
+			},
+		},
+		Timestamp:        timestamp,
+		ProcessingTime:   42500 * time.Microsecond,
+		CollectionTime:   38 * time.Millisecond,
+		OptimizationTime: 4500 * time.Microsecond,
+		Summary:          &models.AuctionSummary{PartnersContacted: 3, PartnersBid: 2, ValidBids: 2, Winners: 2, TimedOutPartners: 1},
+		Experiments:      []models.ExperimentAssignment{{ID: "floor-test", Variant: config.ExperimentVariantTreatment}},
+	}
+}
+
+// newContractNoCompetitionResponse returns the response for an auction that missed its quorum
+func newContractNoCompetitionResponse() *models.BidResponse {
+	return &models.BidResponse{
+		RequestID: "contract-2",
+		Bids:      []*models.Bid{},
+		Timestamp: time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC),
+		Reason:    models.ReasonInsufficientCompetition,
+	}
+}
+
+// TestBidResponseContract pins the JSON of each API version byte for byte. The v1 golden files
+// must never change: existing consumers of /v1/bids depend on them.
+func TestBidResponseContract(t *testing.T) {
+	testCases := []struct {
+		name     string
+		version  handlers.APIVersion
+		fields   models.BidFields
+		response *models.BidResponse
+		golden   string
+	}{
+		{name: "V1 Full", version: handlers.APIVersion1, fields: models.AllBidFields, response: newContractResponse(), golden: "v1_bid_response.json"},
+		{name: "V1 Shaped", version: handlers.APIVersion1, fields: models.BidFieldID | models.BidFieldPrice | models.BidFieldClickURL,
+			response: newContractResponse(), golden: "v1_bid_response_shaped.json"},
+		{name: "V1 Insufficient Competition", version: handlers.APIVersion1, fields: models.AllBidFields, response: newContractNoCompetitionResponse(),
+			golden: "v1_insufficient_competition.json"},
+		{name: "V2 Full", version: handlers.APIVersion2, fields: models.AllBidFields, response: newContractResponse(), golden: "v2_bid_response.json"},
+		{name: "V2 Insufficient Competition", version: handlers.APIVersion2, fields: models.AllBidFields, response: newContractNoCompetitionResponse(),
+			golden: "v2_insufficient_competition.json"},
+	}
+
+	for _, tc := range testCases {
+		golden, err := os.ReadFile("testdata/api/" + tc.golden)
+		require.NoError(t, err)
+		golden = bytes.TrimSuffix(golden, []byte("\n"))
+
+		for _, name := range codecNames {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				codec, err := models.NewCodec(name)
+				require.NoError(t, err)
+
+				encoded, err := handlers.EncodeBidResponse(codec, tc.version, tc.fields, tc.response)
+				require.NoError(t, err)
+				assert.Equal(t, string(golden), string(encoded))
+			})
+		}
+	}
+}
+
+// TestMoney tests that prices split into units and nanos without float drift
+func TestMoney(t *testing.T) {
+	testCases := []struct {
+		name     string
+		amount   float64
+		expected models.Money
+	}{
+		{name: "Whole Units", amount: 12, expected: models.Money{CurrencyCode: models.CurrencyUSD, Units: 12}},
+		{name: "Cents", amount: 12.34, expected: models.Money{CurrencyCode: models.CurrencyUSD, Units: 12, Nanos: 340000000}},
+		{name: "Sub-Cent", amount: 0.015, expected: models.Money{CurrencyCode: models.CurrencyUSD, Nanos: 15000000}},
+		{name: "Rounds Up To A Unit", amount: 2.9999999999, expected: models.Money{CurrencyCode: models.CurrencyUSD, Units: 3}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, models.NewMoney(tc.amount))
+		})
+	}
+}
+
+// newVersionTestRouter serves v1 and v2 bids for an auction against one partner
+func newVersionTestRouter(t *testing.T, codec string) *gin.Engine {
+	partner := newPartnerServer(t, models.Bid{ID: "versioned-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/versioned",
+		Creative: map[string]interface{}{"html": "<div>Offer</div>"}})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.JSONCodec = codec
+	service := newTestService(t, cfg)
+	router, _ := newTestRouter(t, service, cfg)
+	return router
+}
+
+// TestAPIVersionSelection tests that the path and the Accept header profile pick the response
+// serializer, and that each endpoint writes exactly what its serializer encodes
+func TestAPIVersionSelection(t *testing.T) {
+	testCases := []struct {
+		name            string
+		path            string
+		query           string
+		accept          string
+		expectedStatus  int
+		expectedVersion handlers.APIVersion
+		expectedError   string
+	}{
+		{name: "V1 Path", path: "/v1/bids", expectedStatus: http.StatusOK, expectedVersion: handlers.APIVersion1},
+		{name: "V2 Path", path: "/v2/bids", expectedStatus: http.StatusOK, expectedVersion: handlers.APIVersion2},
+		{name: "V2 Profile On V1 Path", path: "/v1/bids", accept: `application/json; profile="v2"`, expectedStatus: http.StatusOK, expectedVersion: handlers.APIVersion2},
+		{name: "V1 Profile On V2 Path", path: "/v2/bids", accept: "application/json;profile=v1", expectedStatus: http.StatusOK, expectedVersion: handlers.APIVersion1},
+		{name: "Profile In Later Media Range", path: "/v1/bids", accept: `text/html, */*; profile="v2"`, expectedStatus: http.StatusOK, expectedVersion: handlers.APIVersion2},
+		{name: "Plain Accept", path: "/v2/bids", accept: "application/json", expectedStatus: http.StatusOK, expectedVersion: handlers.APIVersion2},
+		{name: "Unknown Profile", path: "/v1/bids", accept: `application/json; profile="v3"`, expectedStatus: http.StatusNotAcceptable,
+			expectedError: `unknown API version profile "v3"`},
+		{name: "Fields On V2", path: "/v2/bids", query: "?fields=id", expectedStatus: http.StatusBadRequest,
+			expectedError: "the fields parameter is only supported in API v1"},
+	}
+
+	for _, tc := range testCases {
+		for _, name := range codecNames {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				router := newVersionTestRouter(t, name)
+				req := httptest.NewRequest(http.MethodPost, tc.path+tc.query, bytes.NewBufferString(`{"lead_id": "lead-1", "vertical": "auto"}`))
+				req.Header.Set("Content-Type", "application/json")
+				if tc.accept != "" {
+					req.Header.Set("Accept", tc.accept)
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+				if tc.expectedError != "" {
+					var body map[string]string
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+					assert.Equal(t, tc.expectedError, body["error"])
+					return
+				}
+
+				codec, err := models.NewCodec(name)
+				require.NoError(t, err)
+				if tc.expectedVersion == handlers.APIVersion1 {
+					assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+					var response models.BidResponse
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+					encoded, err := handlers.EncodeBidResponse(codec, handlers.APIVersion1, models.AllBidFields, &response)
+					require.NoError(t, err)
+					assert.Equal(t, string(encoded), w.Body.String())
+					return
+				}
+
+				assert.Equal(t, `application/json; charset=utf-8; profile="v2"`, w.Header().Get("Content-Type"))
+				document, schema := openAPIBodySchema(t, "/v2/bids", http.MethodPost, "200")
+				assert.NoError(t, document.Validate(schema, w.Body.Bytes()), w.Body.String())
+				var response models.BidResponseV2
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				require.Len(t, response.Bids, 1)
+				assert.Equal(t, models.Money{CurrencyCode: models.CurrencyUSD, Units: 10}, response.Bids[0].ClearingPrice)
+				assert.Equal(t, 1, response.Summary.Winners)
+				assert.Greater(t, response.Summary.Timing.ProcessingMs, 0.0)
+			})
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// imageServer serves /image with a fixed status, content type, and size, recording the user agent
+// and method of each request
+type imageServer struct {
+	server      *httptest.Server
+	mutex       sync.Mutex
+	userAgents  []string
+	methods     []string
+	status      int
+	contentType string
+	size        int
+	refuseHead  bool
+}
+
+// newImageServer starts an image server answering with status, contentType, and size bytes
+func newImageServer(t *testing.T, status int, contentType string, size int) *imageServer {
+	images := &imageServer{status: status, contentType: contentType, size: size}
+	images.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		images.mutex.Lock()
+		images.userAgents = append(images.userAgents, r.UserAgent())
+		images.methods = append(images.methods, r.Method)
+		images.mutex.Unlock()
+		if images.refuseHead && r.Method == http.MethodHead {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", images.contentType)
+		w.Header().Set("Content-Length", strconv.Itoa(images.size))
+		w.WriteHeader(images.status)
+		if r.Method == http.MethodGet {
+			w.Write(make([]byte, images.size))
+		}
+	}))
+	t.Cleanup(images.server.Close)
+	return images
+}
+
+// requests returns the methods and user agents the image server received
+func (i *imageServer) requests() ([]string, []string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	return append([]string(nil), i.methods...), append([]string(nil), i.userAgents...)
+}
+
+// newAssetTestConfig returns a config whose only partner bids with a creative showing imageURL,
+// checking every winning creative
+func newAssetTestConfig(t *testing.T, partnerID, imageURL string) *config.Config {
+	partner := newPartnerServer(t, models.Bid{ID: "bid-1", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/1",
+		Creative: map[string]interface{}{models.CreativeTitle: "Quote", models.CreativeImageURL: imageURL}})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		partnerID: {ID: partnerID, Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.AssetVerification = &config.AssetVerificationConfig{Enabled: true, SampleRate: 1, RateLimit: 100, MaxBytes: 1024}
+	return cfg
+}
+
+// runAssetTestAuctions runs auctions assets-from to assets-(to-1) and waits until partnerID has
+// that many asset checks
+func runAssetTestAuctions(t *testing.T, service *services.AuctionService, partnerID string, from, to int) {
+	for i := from; i < to; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "assets-" + strconv.Itoa(i), LeadID: "lead-1", Vertical: "auto"})
+		cancel()
+		require.NoError(t, err)
+	}
+	require.Eventually(t, func() bool { return service.AssetFailures()[partnerID].Checks == to },
+		2*time.Second, 5*time.Millisecond)
+}
+
+// TestAssetVerificationResults tests that winning creative images are checked with an identified
+// HEAD request, falling back to GET, and that failures are kept with their result
+func TestAssetVerificationResults(t *testing.T) {
+	testCases := []struct {
+		name        string
+		status      int
+		contentType string
+		size        int
+		refuseHead  bool
+		result      string
+		methods     []string
+	}{
+		{name: "Image", status: http.StatusOK, contentType: "image/png", size: 512, methods: []string{http.MethodHead}},
+		{name: "Not Found", status: http.StatusNotFound, contentType: "text/plain", size: 9, result: "status", methods: []string{http.MethodHead}},
+		{name: "Not An Image", status: http.StatusOK, contentType: "text/html; charset=utf-8", size: 512, result: "content_type", methods: []string{http.MethodHead}},
+		{name: "Too Large", status: http.StatusOK, contentType: "image/jpeg", size: 4096, result: "too_large", methods: []string{http.MethodHead}},
+		{name: "HEAD Refused", status: http.StatusOK, contentType: "image/png", size: 512, refuseHead: true, methods: []string{http.MethodHead, http.MethodGet}},
+		{name: "HEAD Refused Too Large", status: http.StatusOK, contentType: "image/png", size: 4096, refuseHead: true, result: "too_large", methods: []string{http.MethodHead, http.MethodGet}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			images := newImageServer(t, tc.status, tc.contentType, tc.size)
+			images.refuseHead = tc.refuseHead
+			cfg := newAssetTestConfig(t, "partner-1", images.server.URL+"/image")
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			runAssetTestAuctions(t, service, "partner-1", 0, 1)
+			methods, userAgents := images.requests()
+			assert.Equal(t, tc.methods, methods)
+			for _, userAgent := range userAgents {
+				assert.Equal(t, services.AssetVerifierUserAgent, userAgent)
+			}
+
+			status := service.AssetFailures()["partner-1"]
+			if tc.result == "" {
+				assert.Zero(t, status.Failures)
+				assert.Empty(t, status.RecentFailures)
+				return
+			}
+			require.Len(t, status.RecentFailures, 1)
+			failure := status.RecentFailures[0]
+			assert.Equal(t, tc.result, failure.Result)
+			assert.Equal(t, "bid-1", failure.BidID)
+			assert.Equal(t, "assets-0", failure.RequestID)
+			assert.Equal(t, images.server.URL+"/image", failure.ImageURL)
+		})
+	}
+
+	t.Run("Invalid URL", func(t *testing.T) {
+		service, err := services.NewAuctionService(newAssetTestConfig(t, "partner-1", "/relative/image.png"))
+		require.NoError(t, err)
+		defer service.Close()
+		runAssetTestAuctions(t, service, "partner-1", 0, 1)
+		status := service.AssetFailures()["partner-1"]
+		require.Len(t, status.RecentFailures, 1)
+		assert.Equal(t, "invalid_url", status.RecentFailures[0].Result)
+	})
+}
+
+// TestAssetVerificationFlagging tests that a partner whose images keep failing is flagged in its
+// status, metrics, the admin listing, and by webhook, without affecting its auctions
+func TestAssetVerificationFlagging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	images := newImageServer(t, http.StatusNotFound, "text/plain", 9)
+	receiver, webhookServer := newWebhookReceiver(t, http.StatusOK)
+	cfg := newAssetTestConfig(t, "assets-flagged", images.server.URL+"/missing.png")
+	cfg.AssetVerification.MinChecks = 3
+	cfg.AssetVerification.FlagFailureRate = 0.5
+	cfg.AssetVerification.Webhook = true
+	cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: webhookServer.URL, Events: []string{config.WebhookEventPartnerAssetsFlagged}})
+	cfg.Admin = &config.AdminConfig{Enabled: true, APIKeys: []string{testAdminKey}}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	adminHandler, err := handlers.NewAdminHandler(service, cfg, handlers.BuildInfo{})
+	require.NoError(t, err)
+
+	// Two failures fall short of the checks a partner needs to be flagged
+	runAssetTestAuctions(t, service, "assets-flagged", 0, 2)
+	assert.False(t, service.AssetFailures()["assets-flagged"].Flagged)
+	assert.Empty(t, receiver.received())
+
+	// The third failure flags the partner, and its auctions still sell
+	runAssetTestAuctions(t, service, "assets-flagged", 2, 3)
+	require.Eventually(t, func() bool { return len(receiver.received()) == 1 }, time.Second, 5*time.Millisecond)
+	status := service.PartnerStatuses()[0].Assets
+	require.NotNil(t, status)
+	assert.True(t, status.Flagged)
+	assert.Equal(t, 3, status.Failures)
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_partner_assets_flagged", map[string]string{"partner": "assets-flagged"}))
+
+	deliveries := receiver.received()
+	assert.Equal(t, config.WebhookEventPartnerAssetsFlagged, deliveries[0].event.Type)
+	var flagged struct {
+		Data services.AssetsFlaggedEvent `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(deliveries[0].body, &flagged))
+	assert.Equal(t, "assets-flagged", flagged.Data.PartnerID)
+	assert.Equal(t, 1.0, flagged.Data.FailureRate)
+	assert.Len(t, flagged.Data.RecentFailures, 3)
+
+	router := gin.New()
+	adminHandler.RegisterRoutes(router.Group("/admin"))
+	w := serveOverrideTest(router, http.MethodGet, "/admin/assets/failures", "", map[string]string{"X-Admin-Key": testAdminKey})
+	require.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Enabled  bool                            `json:"enabled"`
+		Partners map[string]services.AssetStatus `json:"partners"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.True(t, listed.Enabled)
+	require.Len(t, listed.Partners["assets-flagged"].RecentFailures, 3)
+	assert.Equal(t, "assets-2", listed.Partners["assets-flagged"].RecentFailures[0].RequestID)
+	assert.Equal(t, http.StatusNotFound, listed.Partners["assets-flagged"].RecentFailures[0].Status)
+}
+
+// TestAssetVerificationSkipped tests that dry runs record asset checks instead of making them,
+// and that checks beyond the rate limit are dropped
+func TestAssetVerificationSkipped(t *testing.T) {
+	images := newImageServer(t, http.StatusOK, "image/png", 512)
+	cfg := newAssetTestConfig(t, "partner-1", images.server.URL+"/image")
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+
+	dryRun := models.NewDryRun()
+	ctx, cancel := context.WithTimeout(models.ContextWithDryRun(context.Background(), dryRun), time.Second)
+	defer cancel()
+	_, err = service.RunAuction(ctx, &models.BidRequest{RequestID: "dry-run-1", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	require.NoError(t, service.Close())
+
+	methods, _ := images.requests()
+	assert.Empty(t, methods)
+	assert.Empty(t, service.AssetFailures())
+	var recorded bool
+	for _, effect := range dryRun.Effects() {
+		recorded = recorded || (effect.Type == models.SideEffectAssetCheck && effect.PartnerID == "partner-1")
+	}
+	assert.True(t, recorded)
+
+	// A limit of one check a second allows the first of three back-to-back auctions' checks
+	cfg.AssetVerification.RateLimit = 1
+	service, err = services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		_, err = service.RunAuction(ctx, &models.BidRequest{RequestID: "assets-" + strconv.Itoa(i), LeadID: "lead-1", Vertical: "auto"})
+		cancel()
+		require.NoError(t, err)
+	}
+	require.NoError(t, service.Close())
+	assert.Equal(t, 1, service.AssetFailures()["partner-1"].Checks)
+}
+
+// TestAssetVerificationValidation tests the asset verification config bounds
+func TestAssetVerificationValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		assets      config.AssetVerificationConfig
+		expectedErr string
+	}{
+		{name: "Defaults", assets: config.AssetVerificationConfig{Enabled: true}},
+		{name: "Disabled Ignores Bounds", assets: config.AssetVerificationConfig{SampleRate: 2}},
+		{name: "Sample Rate Above One", assets: config.AssetVerificationConfig{Enabled: true, SampleRate: 1.5}, expectedErr: "sample rate"},
+		{name: "Timeout Too Long", assets: config.AssetVerificationConfig{Enabled: true, Timeout: time.Minute}, expectedErr: "timeout"},
+		{name: "Negative Rate Limit", assets: config.AssetVerificationConfig{Enabled: true, RateLimit: -1}, expectedErr: "must not be negative"},
+		{name: "Failure Rate Above One", assets: config.AssetVerificationConfig{Enabled: true, FlagFailureRate: 2}, expectedErr: "flag failure rate"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.AssetVerification = &tc.assets
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,329 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// requestIDPartner is a partner endpoint that records the request ID header of each call
+type requestIDPartner struct {
+	server     *httptest.Server
+	mutex      sync.Mutex
+	requestIDs []string
+}
+
+// newRequestIDPartner starts a partner that records request ID headers and always returns bid
+func newRequestIDPartner(t *testing.T, bid models.Bid) *requestIDPartner {
+	partner := &requestIDPartner{}
+	partner.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		partner.mutex.Lock()
+		partner.requestIDs = append(partner.requestIDs, r.Header.Get(services.RequestIDHeader))
+		partner.mutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bid)
+	}))
+	t.Cleanup(partner.server.Close)
+	return partner
+}
+
+// received returns the recorded request IDs
+func (p *requestIDPartner) received() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]string(nil), p.requestIDs...)
+}
+
+// newAuctionContextTestConfig configures partner-a to win over partner-b, with partner-c also
+// eligible
+func newAuctionContextTestConfig(partnerA, partnerB, partnerC *requestIDPartner) *config.Config {
+	return newTestConfig(map[string]*config.PartnerConfig{
+		"partner-a": {ID: "partner-a", Endpoint: partnerA.server.URL, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true},
+		"partner-b": {ID: "partner-b", Endpoint: partnerB.server.URL, APIKey: "key-b", Timeout: 200 * time.Millisecond, Enabled: true},
+		"partner-c": {ID: "partner-c", Endpoint: partnerC.server.URL, APIKey: "key-c", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+}
+
+// TestAuctionContextShims tests that the context functions read and write the auction context,
+// and that deriving a context never changes the auction context of its parent
+func TestAuctionContextShims(t *testing.T) {
+	debug := models.NewDebugInfo()
+	dryRun := models.NewDryRun()
+	override := &models.Override{Floor: 5}
+	params := &models.AuctionParams{}
+
+	testCases := []struct {
+		name   string
+		derive func(ctx context.Context) context.Context
+		check  func(t *testing.T, ctx context.Context, auction *models.AuctionContext)
+	}{
+		{name: "Request ID", derive: func(ctx context.Context) context.Context { return models.ContextWithRequestID(ctx, "req-2") },
+			check: func(t *testing.T, ctx context.Context, auction *models.AuctionContext) {
+				assert.Equal(t, "req-2", models.RequestIDFromContext(ctx))
+				assert.Equal(t, "req-2", auction.RequestID())
+			}},
+		{name: "Batch Item", derive: models.ContextWithBatchItem,
+			check: func(t *testing.T, ctx context.Context, auction *models.AuctionContext) {
+				assert.True(t, models.IsBatchItem(ctx))
+				assert.True(t, auction.IsBatchItem())
+			}},
+		{name: "Debug", derive: func(ctx context.Context) context.Context { return models.ContextWithDebug(ctx, debug) },
+			check: func(t *testing.T, ctx context.Context, auction *models.AuctionContext) {
+				assert.Same(t, debug, models.DebugFromContext(ctx))
+				assert.True(t, auction.IsDebug())
+			}},
+		{name: "Dry Run", derive: func(ctx context.Context) context.Context { return models.ContextWithDryRun(ctx, dryRun) },
+			check: func(t *testing.T, ctx context.Context, auction *models.AuctionContext) {
+				assert.Same(t, dryRun, models.DryRunFromContext(ctx))
+				assert.Same(t, dryRun, auction.DryRun())
+			}},
+		{name: "Reservation", derive: models.ContextWithReservation,
+			check: func(t *testing.T, ctx context.Context, auction *models.AuctionContext) {
+				assert.True(t, models.IsReservation(ctx))
+				assert.True(t, auction.IsReservation())
+			}},
+		{name: "Override", derive: func(ctx context.Context) context.Context { return models.ContextWithOverride(ctx, override) },
+			check: func(t *testing.T, ctx context.Context, auction *models.AuctionContext) {
+				assert.Same(t, override, models.OverrideFromContext(ctx))
+				assert.Same(t, override, auction.Override())
+			}},
+		{name: "Auction Params", derive: func(ctx context.Context) context.Context { return models.ContextWithAuctionParams(ctx, params) },
+			check: func(t *testing.T, ctx context.Context, auction *models.AuctionContext) {
+				assert.Same(t, params, models.AuctionParamsFromContext(ctx))
+				assert.Same(t, params, auction.AuctionParams())
+			}},
+		{name: "Synthetic", derive: models.ContextWithSynthetic,
+			check: func(t *testing.T, ctx context.Context, auction *models.AuctionContext) {
+				assert.True(t, models.IsSynthetic(ctx))
+				assert.True(t, auction.IsSynthetic())
+			}},
+		{name: "Canary", derive: models.ContextWithCanary,
+			check: func(t *testing.T, ctx context.Context, auction *models.AuctionContext) {
+				assert.True(t, models.IsCanary(ctx))
+				assert.True(t, auction.IsCanary())
+			}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := models.ClientIdentity{IP: "203.0.113.7", Transport: "http"}
+			parent := models.ContextWithAuction(context.Background(), models.NewAuctionContext("req-1").WithClient(client))
+
+			derived := tc.derive(parent)
+			tc.check(t, derived, models.AuctionFromContext(derived))
+			// The rest of the auction context carries over
+			assert.Equal(t, client, models.AuctionFromContext(derived).Client())
+
+			// The parent is unchanged
+			auction := models.AuctionFromContext(parent)
+			assert.Equal(t, "req-1", auction.RequestID())
+			assert.Nil(t, auction.Debug())
+			assert.Nil(t, auction.DryRun())
+			assert.Nil(t, auction.Override())
+			assert.Nil(t, auction.AuctionParams())
+			assert.False(t, auction.IsBatchItem() || auction.IsReservation() || auction.IsSynthetic() || auction.IsCanary())
+		})
+	}
+
+	t.Run("No Auction Context", func(t *testing.T) {
+		ctx := context.Background()
+		assert.Nil(t, models.AuctionFromContext(ctx))
+		assert.Empty(t, models.RequestIDFromContext(ctx))
+		assert.Nil(t, models.DebugFromContext(ctx))
+		assert.False(t, models.IsSynthetic(ctx))
+		assert.Nil(t, models.ReasonsFromContext(ctx))
+
+		// The first write starts an auction context
+		ctx = models.ContextWithSynthetic(ctx)
+		require.NotNil(t, models.AuctionFromContext(ctx))
+		assert.NotNil(t, models.ReasonsFromContext(ctx))
+	})
+}
+
+// TestAuctionContextDeadline tests the time left before an auction's deadline
+func TestAuctionContextDeadline(t *testing.T) {
+	now := time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name              string
+		auction           *models.AuctionContext
+		expectedRemaining time.Duration
+		expectedBounded   bool
+	}{
+		{name: "No Auction Context"},
+		{name: "No Deadline", auction: models.NewAuctionContext("req-1")},
+		{name: "Before Deadline", auction: models.NewAuctionContext("req-1").WithDeadline(now.Add(80 * time.Millisecond)),
+			expectedRemaining: 80 * time.Millisecond, expectedBounded: true},
+		{name: "Past Deadline", auction: models.NewAuctionContext("req-1").WithDeadline(now.Add(-time.Second)),
+			expectedBounded: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			remaining, bounded := tc.auction.Remaining(now)
+			assert.Equal(t, tc.expectedRemaining, remaining)
+			assert.Equal(t, tc.expectedBounded, bounded)
+		})
+	}
+}
+
+// TestReasonCollector tests that reasons reach debug output, counts, and the observer
+func TestReasonCollector(t *testing.T) {
+	debug := models.NewDebugInfo()
+	ctx := models.ContextWithDebug(models.ContextWithRequestID(context.Background(), "req-1"), debug)
+	reasons := models.ReasonsFromContext(ctx)
+	require.NotNil(t, reasons)
+
+	var observed []models.Reason
+	reasons.Observe(func(kind models.ReasonKind, partnerID string, reason models.Reason) {
+		observed = append(observed, reason)
+	})
+	reasons.Skip("partner-a", models.ReasonDraining)
+	reasons.Selection("partner-b", models.ReasonSelectedRanked, 0.8)
+	reasons.NoBid("partner-b", models.ReasonBelowFloor, "no-bid: below their floor")
+	reasons.Loss("partner-c", "bid-1", models.ReasonPartnerCap)
+	reasons.Loss("partner-c", "bid-2", models.ReasonRankingConstraint)
+	reasons.ClearLoss("partner-c", "bid-2")
+
+	partnerA, _ := debug.Partner("partner-a")
+	assert.Equal(t, models.ReasonDraining, partnerA.SkipReason)
+	partnerB, _ := debug.Partner("partner-b")
+	require.NotNil(t, partnerB.Selection)
+	assert.Equal(t, models.ReasonSelectedRanked, partnerB.Selection.Reason)
+	assert.Equal(t, "no-bid: below their floor", partnerB.NoBid)
+	partnerC, _ := debug.Partner("partner-c")
+	assert.Equal(t, map[string]models.Reason{"bid-1": models.ReasonPartnerCap}, partnerC.Losses)
+
+	assert.Equal(t, models.ReasonCounts{
+		models.ReasonKindSkip:      {models.ReasonDraining: 1},
+		models.ReasonKindSelection: {models.ReasonSelectedRanked: 1},
+		models.ReasonKindNoBid:     {models.ReasonBelowFloor: 1},
+		models.ReasonKindLoss:      {models.ReasonPartnerCap: 1},
+	}, reasons.Counts())
+	// A cleared loss was already observed
+	assert.Equal(t, []models.Reason{models.ReasonDraining, models.ReasonSelectedRanked, models.ReasonBelowFloor,
+		models.ReasonPartnerCap, models.ReasonRankingConstraint}, observed)
+
+	t.Run("Shared By Copies", func(t *testing.T) {
+		derived := models.ContextWithOverride(ctx, &models.Override{Floor: 1})
+		models.ReasonsFromContext(derived).Skip("partner-d", models.ReasonQPSCapped)
+		assert.Equal(t, 1, reasons.Counts()[models.ReasonKindSkip][models.ReasonQPSCapped])
+	})
+
+	t.Run("New Request", func(t *testing.T) {
+		other := models.ReasonsFromContext(models.ContextWithRequestID(ctx, "req-2"))
+		assert.Nil(t, other.Counts())
+		other.Skip("partner-e", models.ReasonOffSchedule)
+		assert.Zero(t, reasons.Counts()[models.ReasonKindSkip][models.ReasonOffSchedule])
+	})
+
+	t.Run("Nil Collector", func(t *testing.T) {
+		var collector *models.ReasonCollector
+		assert.NotPanics(t, func() {
+			collector.Observe(nil)
+			collector.Skip("partner-a", models.ReasonDraining)
+			collector.Selection("partner-a", models.ReasonSelectedCold, 0)
+			collector.NoBid("partner-a", models.ReasonNoDemand, "no-bid")
+			collector.Loss("partner-a", "bid-1", models.ReasonPartnerCap)
+			collector.ClearLoss("partner-a", "bid-1")
+		})
+		assert.Nil(t, collector.Counts())
+	})
+}
+
+// TestAuctionContextThreading tests that the auction context a handler starts reaches partner
+// calls, and that its reasons feed debug output, metrics, and auction.completed webhooks
+func TestAuctionContextThreading(t *testing.T) {
+	partnerA := newRequestIDPartner(t, models.Bid{ID: "bid-a", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/a"})
+	partnerB := newRequestIDPartner(t, models.Bid{ID: "bid-b", Price: 4.0, QualityScore: 0.5, ClickURL: "http://example.com/b"})
+	partnerC := newRequestIDPartner(t, models.Bid{ID: "bid-c", Price: 9.0, QualityScore: 0.5, ClickURL: "http://example.com/c"})
+	receiver, webhookServer := newWebhookReceiver(t, http.StatusOK)
+	cfg := newAuctionContextTestConfig(partnerA, partnerB, partnerC)
+	cfg.Admin = &config.AdminConfig{Enabled: true, APIKeys: []string{testAdminKey}}
+	cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: webhookServer.URL, Events: []string{config.WebhookEventAuctionCompleted}})
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	router, _ := newTestRouter(t, service, cfg)
+
+	skipped := map[string]string{"partner": "partner-c", "reason": string(models.ReasonOverrideDisabled)}
+	lost := map[string]string{"partner": "partner-b", "reason": string(models.ReasonOverrideFloor)}
+	skipsBefore := gatheredMetric(t, "rtb_partner_skips_total", skipped)
+	lossesBefore := gatheredMetric(t, "rtb_bid_losses_total", lost)
+
+	// The admin override disables partner-c and floors out partner-b's bid
+	w := serveOverrideTest(router, http.MethodPost, "/v1/bids?debug=true", `{"lead_id": "lead-1", "vertical": "auto"}`, map[string]string{
+		"X-Admin-Key":    testAdminKey,
+		"X-Request-ID":   "context-threading",
+		"X-RTB-Override": "floor=5; disable=partner-c",
+	})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response models.BidResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	// Partners see the request ID of the auction context
+	assert.Equal(t, []string{"context-threading"}, partnerA.received())
+	assert.Equal(t, []string{"context-threading"}, partnerB.received())
+	assert.Empty(t, partnerC.received())
+
+	// Debug output
+	require.NotNil(t, response.Debug)
+	partnerCDebug, _ := response.Debug.Partner("partner-c")
+	assert.Equal(t, models.ReasonOverrideDisabled, partnerCDebug.SkipReason)
+	partnerBDebug, _ := response.Debug.Partner("partner-b")
+	assert.Equal(t, map[string]models.Reason{"bid-b": models.ReasonOverrideFloor}, partnerBDebug.Losses)
+	partnerADebug, _ := response.Debug.Partner("partner-a")
+	assert.NotEmpty(t, partnerADebug.Multipliers)
+
+	// Metrics
+	assert.Equal(t, skipsBefore+1, gatheredMetric(t, "rtb_partner_skips_total", skipped))
+	assert.Equal(t, lossesBefore+1, gatheredMetric(t, "rtb_bid_losses_total", lost))
+
+	// auction.completed webhook
+	var reasons map[string]any
+	require.Eventually(t, func() bool {
+		for _, delivery := range receiver.received() {
+			data, _ := delivery.event.Data.(map[string]any)
+			if data["request_id"] == "context-threading" {
+				reasons, _ = data["reasons"].(map[string]any)
+				return true
+			}
+		}
+		return false
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.Equal(t, map[string]any{
+		string(models.ReasonKindSkip): map[string]any{string(models.ReasonOverrideDisabled): 1.0},
+		string(models.ReasonKindLoss): map[string]any{string(models.ReasonOverrideFloor): 1.0},
+	}, reasons)
+}
+
+// TestAuctionContextStartedByService tests that auctions run without an auction context get one
+// carrying the request ID, so partners still see it
+func TestAuctionContextStartedByService(t *testing.T) {
+	partnerA := newRequestIDPartner(t, models.Bid{ID: "bid-a", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/a"})
+	partnerB := newRequestIDPartner(t, models.Bid{ID: "bid-b", Price: 4.0, QualityScore: 0.5, ClickURL: "http://example.com/b"})
+	partnerC := newRequestIDPartner(t, models.Bid{ID: "bid-c", Price: 9.0, QualityScore: 0.5, ClickURL: "http://example.com/c"})
+	service, err := services.NewAuctionService(newAuctionContextTestConfig(partnerA, partnerB, partnerC))
+	require.NoError(t, err)
+	defer service.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "service-started", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	require.Len(t, response.Bids, 1)
+	assert.Equal(t, "bid-a", response.Bids[0].ID)
+	assert.Nil(t, response.Debug)
+	assert.Equal(t, []string{"service-started"}, partnerA.received())
+}
@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// newHookTestConfig returns a config with one partner bidding 10 on every auction
+func newHookTestConfig(t *testing.T) *config.Config {
+	partner := newPartnerServer(t, models.Bid{ID: "hook-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/hook"})
+	return newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 500 * time.Millisecond, Enabled: true},
+	}, withBidTimeout(time.Second))
+}
+
+// startHookTestService creates a service running hooks, closed when the test ends
+func startHookTestService(t *testing.T, cfg *config.Config, hooks ...services.AuctionHook) *services.AuctionService {
+	service, err := services.NewAuctionServiceWithHooks(cfg, utils.SystemClock{}, hooks...)
+	require.NoError(t, err)
+	t.Cleanup(func() { service.Close() })
+	return service
+}
+
+// runHookTestAuction runs an auto auction for request ID id
+func runHookTestAuction(service *services.AuctionService, id string) (*models.BidResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	return service.RunAuction(ctx, &models.BidRequest{RequestID: id, LeadID: "lead-1", Vertical: "auto"})
+}
+
+// TestAuctionHookPhases tests that hooks run in registration order in each phase, that a
+// pre-auction hook's change to the request reaches later hooks, and that every phase sees the
+// auction's request
+func TestAuctionHookPhases(t *testing.T) {
+	var mutex sync.Mutex
+	var calls []string
+	record := func(call string) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		calls = append(calls, call)
+	}
+
+	service := startHookTestService(t, newHookTestConfig(t),
+		services.AuctionHook{
+			Name: "phases-enrich",
+			PreAuction: func(ctx context.Context, request *models.BidRequest) error {
+				record("enrich:pre")
+				request.UserData = map[string]interface{}{"segment": "returning"}
+				return nil
+			},
+			PostAuction: func(ctx context.Context, response *models.BidResponse) {
+				record("enrich:post:" + response.Bids[0].ID)
+			},
+		},
+		services.AuctionHook{
+			Name: "phases-log",
+			PreAuction: func(ctx context.Context, request *models.BidRequest) error {
+				record("log:pre:" + request.UserData["segment"].(string))
+				return nil
+			},
+			PostCollection: func(ctx context.Context, bids []*models.Bid) {
+				record("log:collected:" + bids[0].ID)
+			},
+			PostAuction: func(ctx context.Context, response *models.BidResponse) {
+				record("log:post:" + models.AuctionFromContext(ctx).Request().RequestID)
+			},
+		},
+	)
+
+	durations := map[string]string{"hook": "phases-log", "phase": "post_collection"}
+	runs := map[string]string{"hook": "phases-log", "phase": "post_collection", "outcome": "ok"}
+	durationsBefore := gatheredMetric(t, "rtb_auction_hook_duration_seconds", durations)
+	runsBefore := gatheredMetric(t, "rtb_auction_hook_runs_total", runs)
+
+	response, err := runHookTestAuction(service, "hook-phases")
+	require.NoError(t, err)
+	require.Len(t, response.Bids, 1)
+	assert.Equal(t, []string{
+		"enrich:pre",
+		"log:pre:returning",
+		"log:collected:hook-bid",
+		"enrich:post:hook-bid",
+		"log:post:hook-phases",
+	}, calls)
+
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_auction_hook_duration_seconds", durations)-durationsBefore)
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_auction_hook_runs_total", runs)-runsBefore)
+}
+
+// TestAuctionHookRejection tests that a pre-auction hook's error rejects the auction before any
+// partner is called, and that the HTTP endpoint answers 403
+func TestAuctionHookRejection(t *testing.T) {
+	errFraud := errors.New("lead flagged by fraud check")
+	var postCalled bool
+	cfg := newHookTestConfig(t)
+	service := startHookTestService(t, cfg,
+		services.AuctionHook{
+			Name: "rejection-fraud",
+			PreAuction: func(ctx context.Context, request *models.BidRequest) error {
+				if request.LeadID == "lead-fraud" {
+					return errFraud
+				}
+				return nil
+			},
+			PostCollection: func(ctx context.Context, bids []*models.Bid) { postCalled = true },
+		},
+	)
+
+	rejectedLabels := map[string]string{"hook": "rejection-fraud", "outcome": "rejected"}
+	rejectedBefore := gatheredMetric(t, "rtb_auction_hook_runs_total", rejectedLabels)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "hook-fraud", LeadID: "lead-fraud", Vertical: "auto"})
+	assert.ErrorIs(t, err, services.ErrHookRejected)
+	assert.ErrorIs(t, err, errFraud)
+	assert.False(t, postCalled)
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_auction_hook_runs_total", rejectedLabels)-rejectedBefore)
+
+	router, _ := newTestRouter(t, service, cfg)
+	w := serveOverrideTest(router, http.MethodPost, "/v1/bids", `{"lead_id": "lead-fraud", "vertical": "auto"}`, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, w.Body.String())
+	w = serveOverrideTest(router, http.MethodPost, "/v1/bids", `{"lead_id": "lead-1", "vertical": "auto"}`, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+// TestAuctionHookInvalidRequest tests that a pre-auction hook cannot change the request past
+// validation
+func TestAuctionHookInvalidRequest(t *testing.T) {
+	service := startHookTestService(t, newHookTestConfig(t), services.AuctionHook{
+		Name: "invalid-request",
+		PreAuction: func(ctx context.Context, request *models.BidRequest) error {
+			request.RequestID = ""
+			return nil
+		},
+	})
+
+	_, err := runHookTestAuction(service, "hook-invalid")
+	assert.ErrorIs(t, err, services.ErrInvalidRequest)
+}
+
+// TestAuctionHookIsolation tests that a hook that panics or overruns its timeout is skipped and
+// the auction goes on
+func TestAuctionHookIsolation(t *testing.T) {
+	service := startHookTestService(t, newHookTestConfig(t),
+		services.AuctionHook{
+			Name:       "isolation-panic",
+			PreAuction: func(ctx context.Context, request *models.BidRequest) error { panic("nil enrichment client") },
+		},
+		services.AuctionHook{
+			Name:    "isolation-slow",
+			Timeout: 20 * time.Millisecond,
+			PreAuction: func(ctx context.Context, request *models.BidRequest) error {
+				<-ctx.Done()
+				return ctx.Err()
+			},
+			PostAuction: func(ctx context.Context, response *models.BidResponse) { panic("post-auction panic") },
+		},
+	)
+
+	runs := []map[string]string{
+		{"hook": "isolation-panic", "outcome": "panic"},
+		{"hook": "isolation-slow", "phase": "pre_auction", "outcome": "timeout"},
+		{"hook": "isolation-slow", "phase": "post_auction", "outcome": "panic"},
+	}
+	before := make([]float64, len(runs))
+	for i, labels := range runs {
+		before[i] = gatheredMetric(t, "rtb_auction_hook_runs_total", labels)
+	}
+
+	response, err := runHookTestAuction(service, "hook-isolation")
+	require.NoError(t, err)
+	assert.Len(t, response.Bids, 1)
+	for i, labels := range runs {
+		assert.Equal(t, 1.0, gatheredMetric(t, "rtb_auction_hook_runs_total", labels)-before[i], labels)
+	}
+}
+
+// TestAuctionHookValidation tests that hooks are checked at construction
+func TestAuctionHookValidation(t *testing.T) {
+	noop := func(ctx context.Context, request *models.BidRequest) error { return nil }
+
+	testCases := []struct {
+		name  string
+		hooks []services.AuctionHook
+	}{
+		{name: "Missing Name", hooks: []services.AuctionHook{{PreAuction: noop}}},
+		{name: "Built-in Name", hooks: []services.AuctionHook{{Name: "ivt", PreAuction: noop}}},
+		{name: "Duplicate Name", hooks: []services.AuctionHook{{Name: "check", PreAuction: noop}, {Name: "check", PreAuction: noop}}},
+		{name: "Negative Timeout", hooks: []services.AuctionHook{{Name: "check", Timeout: -time.Second, PreAuction: noop}}},
+		{name: "No Phase", hooks: []services.AuctionHook{{Name: "check"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := services.NewAuctionServiceWithHooks(newStrategyTestConfig(), utils.SystemClock{}, tc.hooks...)
+			assert.ErrorIs(t, err, services.ErrInvalidHook)
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// TestAuctionParamsRequest tests forcing auction parameters through the header and the request
+// body, and that only admin callers may force parameters within the safety bounds
+func TestAuctionParamsRequest(t *testing.T) {
+	admin := map[string]string{"X-Admin-Key": testAdminKey}
+	testCases := []struct {
+		name           string
+		body           string
+		headers        map[string]string
+		expectedStatus int
+		expectedError  string
+		expectedBids   []string
+	}{
+		{name: "Header Floor", body: `{"lead_id": "lead-1", "vertical": "auto"}`,
+			headers:        map[string]string{"X-Admin-Key": testAdminKey, "X-RTB-Auction-Params": `{"floor": 8, "auction_type": "first_price"}`},
+			expectedStatus: http.StatusOK, expectedBids: []string{"bid-1"}},
+		{name: "Body Floor", body: `{"lead_id": "lead-1", "vertical": "auto", "auction_params": {"floor": 8, "strategy": "passthrough"}}`,
+			headers: admin, expectedStatus: http.StatusOK, expectedBids: []string{"bid-1"}},
+		{name: "Quality Weight Only", body: `{"lead_id": "lead-1", "vertical": "auto", "auction_params": {"quality_weight": 0}}`,
+			headers: admin, expectedStatus: http.StatusOK, expectedBids: []string{"bid-1", "bid-2"}},
+		{name: "Header Without Admin Key", body: `{"lead_id": "lead-1", "vertical": "auto"}`,
+			headers:        map[string]string{"X-RTB-Auction-Params": `{"floor": 8}`},
+			expectedStatus: http.StatusForbidden, expectedError: "Auction params require admin authentication"},
+		{name: "Body Without Admin Key", body: `{"lead_id": "lead-1", "vertical": "auto", "auction_params": {"floor": 8}}`,
+			expectedStatus: http.StatusForbidden, expectedError: "Auction params require admin authentication"},
+		{name: "Wrong Admin Key", body: `{"lead_id": "lead-1", "vertical": "auto", "auction_params": {"floor": 8}}`,
+			headers:        map[string]string{"X-Admin-Key": "wrong"},
+			expectedStatus: http.StatusForbidden, expectedError: "Auction params require admin authentication"},
+		{name: "Quality Weight Out Of Bounds", body: `{"lead_id": "lead-1", "vertical": "auto", "auction_params": {"quality_weight": 2}}`,
+			headers: admin, expectedStatus: http.StatusBadRequest, expectedError: "auction params quality weight must be in [0, 1]: 2"},
+		{name: "Floor Above Max Price", body: `{"lead_id": "lead-1", "vertical": "auto", "auction_params": {"floor": 500}}`,
+			headers: admin, expectedStatus: http.StatusBadRequest, expectedError: "auction params floor must be between 0 and the max bid price: 500"},
+		{name: "Unsupported Auction Type", body: `{"lead_id": "lead-1", "vertical": "auto", "auction_params": {"auction_type": "second_price"}}`,
+			headers: admin, expectedStatus: http.StatusBadRequest, expectedError: `unsupported auction type "second_price": only first_price auctions are run`},
+		{name: "Unknown Strategy", body: `{"lead_id": "lead-1", "vertical": "auto", "auction_params": {"strategy": "random"}}`,
+			headers: admin, expectedStatus: http.StatusBadRequest, expectedError: `unknown optimization strategy "random"`},
+		{name: "Empty Params", body: `{"lead_id": "lead-1", "vertical": "auto", "auction_params": {}}`,
+			headers: admin, expectedStatus: http.StatusBadRequest, expectedError: "auction params must set at least one parameter"},
+		{name: "Malformed Header", body: `{"lead_id": "lead-1", "vertical": "auto"}`,
+			headers:        map[string]string{"X-Admin-Key": testAdminKey, "X-RTB-Auction-Params": "floor=8"},
+			expectedStatus: http.StatusBadRequest, expectedError: "Invalid auction params header"},
+		{name: "Header And Body", body: `{"lead_id": "lead-1", "vertical": "auto", "auction_params": {"floor": 8}}`,
+			headers:        map[string]string{"X-Admin-Key": testAdminKey, "X-RTB-Auction-Params": `{"floor": 8}`},
+			expectedStatus: http.StatusBadRequest, expectedError: "Send auction params in the header or the body, not both"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, _ := newOverrideTestRouter(t, &steppingClock{now: time.Now()})
+			manualLabels := map[string]string{"vertical": "auto", "partner": "all", "transport": "http", "traffic": "manual_override"}
+			before := gatheredMetric(t, "rtb_bid_requests_total", manualLabels)
+
+			w := serveOverrideTest(router, http.MethodPost, "/v1/bids?debug=true", tc.body, tc.headers)
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedError != "" {
+				var body map[string]interface{}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+				assert.Equal(t, tc.expectedError, body["error"])
+				assert.Equal(t, before, gatheredMetric(t, "rtb_bid_requests_total", manualLabels))
+				return
+			}
+
+			var response struct {
+				Bids        []*models.Bid                 `json:"bids"`
+				Experiments []models.ExperimentAssignment `json:"experiments"`
+				Debug       *models.DebugInfo             `json:"debug"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			winners := make([]string, 0, len(response.Bids))
+			for _, bid := range response.Bids {
+				winners = append(winners, bid.ID)
+			}
+			assert.ElementsMatch(t, tc.expectedBids, winners)
+			assert.Equal(t, []models.ExperimentAssignment{models.ManualOverrideAssignment}, response.Experiments)
+			require.NotNil(t, response.Debug)
+			assert.NotNil(t, response.Debug.AuctionParams())
+			assert.Equal(t, before+1, gatheredMetric(t, "rtb_bid_requests_total", manualLabels))
+		})
+	}
+}
+
+// TestAuctionParamsExcludedFromExperiments tests that an auction with forced parameters runs
+// outside every experiment, stays out of experiment results, and never sends the parameters to
+// partners
+func TestAuctionParamsExcludedFromExperiments(t *testing.T) {
+	var mutex sync.Mutex
+	var bodies []string
+	partner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mutex.Lock()
+		bodies = append(bodies, string(body))
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "bid-1", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	}))
+	t.Cleanup(partner.Close)
+
+	weight := 0.9
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.Experiments = map[string]*config.ExperimentConfig{
+		"manual-exclusion": {TrafficPercent: 100, BucketBy: config.ExperimentBucketRequestID, Overrides: config.ExperimentOverrides{QualityWeight: &weight}},
+	}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	treatment := map[string]string{"experiment": "manual-exclusion", "variant": config.ExperimentVariantTreatment}
+	before := gatheredMetric(t, "rtb_experiment_auctions_total", treatment)
+
+	params := &models.AuctionParams{Strategy: config.StrategyPassthrough}
+	ctx, cancel := context.WithTimeout(models.ContextWithAuctionParams(context.Background(), params), 500*time.Millisecond)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "manual-1", LeadID: "lead-1", Vertical: "auto", AuctionParams: params})
+	require.NoError(t, err)
+	assert.Equal(t, []models.ExperimentAssignment{models.ManualOverrideAssignment}, response.Experiments)
+	assert.Equal(t, before, gatheredMetric(t, "rtb_experiment_auctions_total", treatment))
+	for _, report := range service.Experiments() {
+		assert.Zero(t, report.Variants[config.ExperimentVariantTreatment].Auctions)
+	}
+
+	// The same request without forced parameters runs in the treatment
+	ctx, cancel = context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	response, err = service.RunAuction(ctx, &models.BidRequest{RequestID: "manual-2", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	assert.Equal(t, []models.ExperimentAssignment{{ID: "manual-exclusion", Variant: config.ExperimentVariantTreatment}}, response.Experiments)
+	assert.Equal(t, before+1, gatheredMetric(t, "rtb_experiment_auctions_total", treatment))
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	require.Len(t, bodies, 2)
+	for _, body := range bodies {
+		assert.NotContains(t, body, "auction_params")
+	}
+}
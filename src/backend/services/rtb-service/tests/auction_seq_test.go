@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// newAuctionSeqTestConfig returns a config with auction sequence numbers reserved in batches of
+// batchSize from redisServer, or assigned as ULIDs when it is nil, and every webhook sent to
+// webhookURL
+func newAuctionSeqTestConfig(t *testing.T, redisServer *miniredis.Miniredis, batchSize int64, webhookURL string) *config.Config {
+	partner := newPartnerServer(t, models.Bid{ID: "seq-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/seq"})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withAudit(filepath.Join(t.TempDir(), "winning-bids.jsonl")))
+	cfg.AuctionSequence = &config.AuctionSequenceConfig{Enabled: true, BatchSize: batchSize}
+	cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: webhookURL})
+	if redisServer != nil {
+		host, portText, err := net.SplitHostPort(redisServer.Addr())
+		require.NoError(t, err)
+		port, err := strconv.Atoi(portText)
+		require.NoError(t, err)
+		cfg.Redis = &config.RedisConfig{Host: host, Port: port, Timeout: time.Second}
+	}
+	return cfg
+}
+
+// runSeqAuction runs one auction and returns its sequence number
+func runSeqAuction(t *testing.T, ctx context.Context, service *services.AuctionService, requestID string) *models.AuctionSeq {
+	ctx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: requestID, LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	return response.AuctionSeq
+}
+
+// seqRanges returns the ranges of the auction_seq webhooks of eventType received so far
+func seqRanges(t *testing.T, receiver *webhookReceiver, eventType string) []services.AuctionSeqRange {
+	var ranges []services.AuctionSeqRange
+	for _, delivery := range receiver.received() {
+		if delivery.event.Type != eventType {
+			continue
+		}
+		data, err := json.Marshal(delivery.event.Data)
+		require.NoError(t, err)
+		var seqs services.AuctionSeqRange
+		require.NoError(t, json.Unmarshal(data, &seqs))
+		ranges = append(ranges, seqs)
+	}
+	return ranges
+}
+
+// TestAuctionSeq tests that auctions are numbered in order from one reservation per batch, and
+// that the numbers reach the auction's webhooks and audit records
+func TestAuctionSeq(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	receiver, webhookServer := newWebhookReceiver(t, 200)
+	cfg := newAuctionSeqTestConfig(t, redisServer, 10, webhookServer.URL)
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+
+	for i, requestID := range []string{"seq-1", "seq-2", "seq-3"} {
+		assert.Equal(t, &models.AuctionSeq{Seq: int64(i + 1)}, runSeqAuction(t, context.Background(), service, requestID))
+	}
+	// Dry runs have no outcome of their own, so they take no number
+	assert.Nil(t, runSeqAuction(t, models.ContextWithDryRun(context.Background(), models.NewDryRun()), service, "seq-dry-run"))
+	counter, err := redisServer.Get("rtb:auction_seq")
+	require.NoError(t, err)
+	assert.Equal(t, "10", counter)
+	require.NoError(t, service.Close())
+
+	assert.Equal(t, []services.AuctionSeqRange{{From: 1, To: 10}}, seqRanges(t, receiver, config.WebhookEventAuctionSeqReserved))
+	assert.Equal(t, []services.AuctionSeqRange{{From: 4, To: 10}}, seqRanges(t, receiver, config.WebhookEventAuctionSeqReleased))
+	seqs := make(map[string]float64)
+	for _, delivery := range receiver.received() {
+		if delivery.event.Type != config.WebhookEventBidWon && delivery.event.Type != config.WebhookEventAuctionCompleted {
+			continue
+		}
+		data := delivery.event.Data.(map[string]interface{})
+		seq := data["auction_seq"].(map[string]interface{})
+		seqs[delivery.event.Type+"/"+data["request_id"].(string)] = seq["seq"].(float64)
+	}
+	assert.Equal(t, map[string]float64{
+		"bid.won/seq-1": 1, "bid.won/seq-2": 2, "bid.won/seq-3": 3,
+		"auction.completed/seq-1": 1, "auction.completed/seq-2": 2, "auction.completed/seq-3": 3,
+	}, seqs)
+
+	records := readAuditRecords(t, cfg.Audit.Path)
+	require.Len(t, records, 3)
+	for i, record := range records {
+		assert.Equal(t, int64(i+1), record.AuctionSeq)
+	}
+}
+
+// TestAuctionSeqRestart tests that an instance that dies without releasing its batch leaves the
+// rest of it reserved but unused, and that an instance started after it continues above it
+func TestAuctionSeqRestart(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	receiver, webhookServer := newWebhookReceiver(t, 200)
+	crashed, err := services.NewAuctionService(newAuctionSeqTestConfig(t, redisServer, 10, webhookServer.URL))
+	require.NoError(t, err)
+	for _, requestID := range []string{"before-1", "before-2", "before-3"} {
+		runSeqAuction(t, context.Background(), crashed, requestID)
+	}
+	require.Eventually(t, func() bool {
+		return len(seqRanges(t, receiver, config.WebhookEventAuctionSeqReserved)) == 1
+	}, 2*time.Second, 5*time.Millisecond)
+
+	// The crashed instance never closes, so it never releases 4 to 10
+	restarted, err := services.NewAuctionService(newAuctionSeqTestConfig(t, redisServer, 10, webhookServer.URL))
+	require.NoError(t, err)
+	assert.Equal(t, &models.AuctionSeq{Seq: 11}, runSeqAuction(t, context.Background(), restarted, "after-1"))
+	require.NoError(t, restarted.Close())
+
+	assert.Equal(t, []services.AuctionSeqRange{{From: 1, To: 10}, {From: 11, To: 20}}, seqRanges(t, receiver, config.WebhookEventAuctionSeqReserved))
+	assert.Equal(t, []services.AuctionSeqRange{{From: 12, To: 20}}, seqRanges(t, receiver, config.WebhookEventAuctionSeqReleased))
+}
+
+// TestAuctionSeqFallback tests that auctions get ULIDs without Redis, or once Redis cannot be
+// reached after the current batch is used up
+func TestAuctionSeqFallback(t *testing.T) {
+	t.Run("No Redis", func(t *testing.T) {
+		_, webhookServer := newWebhookReceiver(t, 200)
+		service, err := services.NewAuctionService(newAuctionSeqTestConfig(t, nil, 10, webhookServer.URL))
+		require.NoError(t, err)
+		defer service.Close()
+
+		first := runSeqAuction(t, context.Background(), service, "ulid-1")
+		second := runSeqAuction(t, context.Background(), service, "ulid-2")
+		require.NotNil(t, first)
+		require.NotNil(t, second)
+		assert.Zero(t, first.Seq)
+		assert.Len(t, first.ULID, 26)
+		assert.Less(t, first.ULID, second.ULID)
+		created, ok := utils.ULIDTime(first.ULID)
+		require.True(t, ok)
+		assert.WithinDuration(t, time.Now(), created, time.Minute)
+	})
+
+	t.Run("Redis Down", func(t *testing.T) {
+		redisServer := miniredis.RunT(t)
+		_, webhookServer := newWebhookReceiver(t, 200)
+		service, err := services.NewAuctionService(newAuctionSeqTestConfig(t, redisServer, 2, webhookServer.URL))
+		require.NoError(t, err)
+		defer service.Close()
+
+		assert.Equal(t, &models.AuctionSeq{Seq: 1}, runSeqAuction(t, context.Background(), service, "down-1"))
+		redisServer.Close()
+		// The batch in hand is still used up before Redis is needed
+		assert.Equal(t, &models.AuctionSeq{Seq: 2}, runSeqAuction(t, context.Background(), service, "down-2"))
+		seq := runSeqAuction(t, context.Background(), service, "down-3")
+		require.NotNil(t, seq)
+		assert.Zero(t, seq.Seq)
+		assert.Len(t, seq.ULID, 26)
+	})
+}
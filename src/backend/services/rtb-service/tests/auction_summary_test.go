@@ -0,0 +1,103 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// summaryTestPartner is a partner answering every call with status and body after delay
+type summaryTestPartner struct {
+	status int
+	body   string
+	delay  time.Duration
+}
+
+// Partner behaviors for the auction summary tests
+var (
+	summaryBidding   = summaryTestPartner{status: http.StatusOK, body: `{"id": "high", "price": 10, "click_url": "http://example.com/high"}`}
+	summaryMixed     = summaryTestPartner{status: http.StatusOK, body: `[{"id": "low", "price": 8, "click_url": "http://example.com/low"}, {"id": "broken"}]`}
+	summaryDeclining = summaryTestPartner{status: http.StatusNoContent}
+	summaryFailing   = summaryTestPartner{status: http.StatusInternalServerError}
+	summarySlow      = summaryTestPartner{status: http.StatusOK, body: `{"id": "late", "price": 20, "click_url": "http://example.com/late"}`, delay: 300 * time.Millisecond}
+)
+
+// newSummaryTestRouter returns a router whose auctions call a partner for each behavior, plus a
+// disabled partner that is never contacted
+func newSummaryTestRouter(t *testing.T, partners map[string]summaryTestPartner, maxWinners int) *gin.Engine {
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"disabled": {ID: "disabled", Endpoint: "http://127.0.0.1:1", APIKey: "key-disabled", Timeout: 100 * time.Millisecond},
+	}, withMaxBids(maxWinners))
+	for partnerID, partner := range partners {
+		server, _ := newNegativeCachePartner(t, partner.status, partner.body, partner.delay)
+		cfg.Partners[partnerID] = &config.PartnerConfig{ID: partnerID, Endpoint: server.URL, APIKey: "key-" + partnerID,
+			Timeout: 100 * time.Millisecond, Enabled: true, MaxBidsPerResponse: 2}
+	}
+	service := newTestService(t, cfg)
+	router, _ := newTestRouter(t, service, cfg)
+	return router
+}
+
+// TestAuctionSummary tests the participation summary in the response body and headers when
+// partners win, lose, fail, decline, and time out in the same auction
+func TestAuctionSummary(t *testing.T) {
+	testCases := []struct {
+		name            string
+		partners        map[string]summaryTestPartner
+		maxWinners      int
+		expectedSummary models.AuctionSummary
+	}{
+		{
+			name:            "All Partners Bid",
+			partners:        map[string]summaryTestPartner{"high": summaryBidding, "mixed": summaryMixed},
+			maxWinners:      2,
+			expectedSummary: models.AuctionSummary{PartnersContacted: 2, PartnersBid: 2, ValidBids: 2, Winners: 2},
+		},
+		{
+			name: "Failures And No-Bids",
+			partners: map[string]summaryTestPartner{"high": summaryBidding, "mixed": summaryMixed,
+				"declining": summaryDeclining, "failing": summaryFailing},
+			maxWinners:      2,
+			expectedSummary: models.AuctionSummary{PartnersContacted: 4, PartnersBid: 2, ValidBids: 2, Winners: 2},
+		},
+		{
+			name: "Partial Timeout",
+			partners: map[string]summaryTestPartner{"high": summaryBidding, "mixed": summaryMixed,
+				"declining": summaryDeclining, "failing": summaryFailing, "slow": summarySlow},
+			maxWinners:      2,
+			expectedSummary: models.AuctionSummary{PartnersContacted: 5, PartnersBid: 2, ValidBids: 2, Winners: 2, TimedOutPartners: 1},
+		},
+		{
+			name:            "Fewer Winners Than Valid Bids",
+			partners:        map[string]summaryTestPartner{"high": summaryBidding, "mixed": summaryMixed, "slow": summarySlow},
+			maxWinners:      1,
+			expectedSummary: models.AuctionSummary{PartnersContacted: 3, PartnersBid: 2, ValidBids: 2, Winners: 1, TimedOutPartners: 1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := newSummaryTestRouter(t, tc.partners, tc.maxWinners)
+
+			w := serveOverrideTest(router, http.MethodPost, "/v1/bids", `{"lead_id": "lead-1", "vertical": "auto"}`, nil)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var response models.BidResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.NotNil(t, response.Summary)
+			assert.Equal(t, tc.expectedSummary, *response.Summary)
+			assert.Len(t, response.Bids, tc.expectedSummary.Winners)
+
+			assert.Equal(t, strconv.Itoa(tc.expectedSummary.PartnersBid), w.Header().Get("X-RTB-Partners-Bid"))
+			assert.Equal(t, strconv.Itoa(tc.expectedSummary.Winners), w.Header().Get("X-RTB-Winners"))
+		})
+	}
+}
@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/audit"
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// readAuditRecords reads every record written for path
+func readAuditRecords(t *testing.T, path string) []audit.Record {
+	reader, err := audit.NewReader(path)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	var records []audit.Record
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+// TestAuditRotation tests that the audit log rotates by size, keeps MaxFiles rotated files, and reads back in order
+func TestAuditRotation(t *testing.T) {
+	testCases := []struct {
+		name     string
+		compress bool
+	}{
+		{name: "Plain Rotation", compress: false},
+		{name: "Compressed Rotation", compress: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "winning-bids.jsonl")
+			writer, err := audit.NewWriter(&config.AuditConfig{
+				Enabled:      true,
+				Path:         path,
+				MaxSizeBytes: 1024,
+				MaxFiles:     2,
+				Compress:     tc.compress,
+				BufferSize:   1000,
+			})
+			require.NoError(t, err)
+
+			for i := 0; i < 40; i++ {
+				require.NoError(t, writer.Write(audit.Record{
+					Timestamp:     time.Date(2030, 6, 1, 12, 0, i, 0, time.UTC),
+					RequestID:     fmt.Sprintf("request-%02d", i),
+					LeadID:        "lead-1",
+					PartnerID:     "partner-1",
+					BidID:         "bid-1",
+					Vertical:      "auto",
+					BidPrice:      10.5,
+					ClearingPrice: 10.5,
+				}))
+			}
+			require.NoError(t, writer.Close())
+			assert.ErrorIs(t, writer.Write(audit.Record{}), audit.ErrClosed)
+
+			files, err := audit.Files(path)
+			require.NoError(t, err)
+			require.Len(t, files, 3)
+			assert.Equal(t, path, files[2])
+			for _, file := range files[:2] {
+				assert.Equal(t, tc.compress, strings.HasSuffix(file, ".gz"), file)
+				info, err := os.Stat(file)
+				require.NoError(t, err)
+				if !tc.compress {
+					assert.LessOrEqual(t, info.Size(), int64(1024))
+				}
+			}
+
+			// Older files past MaxFiles were removed, so the retained records are the most recent, in order
+			records := readAuditRecords(t, path)
+			require.NotEmpty(t, records)
+			assert.Less(t, len(records), 40)
+			assert.Equal(t, "request-39", records[len(records)-1].RequestID)
+			for i := 1; i < len(records); i++ {
+				assert.True(t, records[i].Timestamp.After(records[i-1].Timestamp))
+			}
+		})
+	}
+}
+
+// TestAuditSignatures tests record signing and verification
+func TestAuditSignatures(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "winning-bids.jsonl")
+	t.Setenv("AUDIT_SIGNING_KEY", "audit-signing-key")
+	writer, err := audit.NewWriter(&config.AuditConfig{Enabled: true, Path: path, MaxSizeBytes: 1 << 20, MaxFiles: 1, BufferSize: 10, SigningKey: "env:AUDIT_SIGNING_KEY"})
+	require.NoError(t, err)
+	require.NoError(t, writer.Write(audit.Record{Timestamp: time.Now().UTC(), RequestID: "request-1", PartnerID: "partner-1", BidPrice: 12.25, ClearingPrice: 12.25}))
+	require.NoError(t, writer.Close())
+
+	records := readAuditRecords(t, path)
+	require.Len(t, records, 1)
+
+	testCases := []struct {
+		name     string
+		key      string
+		tamper   func(record *audit.Record)
+		expected bool
+	}{
+		{name: "Valid Signature", key: "audit-signing-key", expected: true},
+		{name: "Wrong Key", key: "other-key", expected: false},
+		{name: "Tampered Price", key: "audit-signing-key", tamper: func(record *audit.Record) { record.ClearingPrice = 1.0 }, expected: false},
+		{name: "Missing Signature", key: "audit-signing-key", tamper: func(record *audit.Record) { record.Signature = "" }, expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			record := records[0]
+			if tc.tamper != nil {
+				tc.tamper(&record)
+			}
+			assert.Equal(t, tc.expected, record.Verify([]byte(tc.key)))
+		})
+	}
+}
+
+// TestAuditReaderErrors tests that malformed lines are reported with their file and line
+func TestAuditReaderErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "winning-bids.jsonl")
+	require.NoError(t, os.WriteFile(path, []byte("{\"request_id\":\"request-1\"}\n\nnot json\n"), 0o600))
+
+	reader := audit.NewFileReader(path)
+	defer reader.Close()
+
+	record, err := reader.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "request-1", record.RequestID)
+
+	_, err = reader.Next()
+	assert.ErrorContains(t, err, path+" line 3")
+}
+
+// TestAuctionAudit tests that each winning bid of an auction is written to the audit log on close
+func TestAuctionAudit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "winning-bids.jsonl")
+	open := newPartnerServer(t, models.Bid{ID: "open-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://open.example.com/c"})
+	buyer := newPartnerServer(t, models.Bid{ID: "deal-bid", Price: 2.0, QualityScore: 0.5, ClickURL: "http://buyer.example.com/c", DealID: "audit-deal"})
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"open":  {ID: "open", Endpoint: open.URL, APIKey: "key-open", Timeout: 200 * time.Millisecond, Enabled: true},
+		"buyer": {ID: "buyer", Endpoint: buyer.URL, APIKey: "key-buyer", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2), withAudit(path))
+	cfg.Deals = map[string]*config.DealConfig{"audit-deal": {PartnerID: "buyer", FixedPrice: 30.0}}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err = service.RunAuction(ctx, &models.BidRequest{RequestID: "audit-test", LeadID: "lead-7", Vertical: "auto"})
+	require.NoError(t, err)
+	require.NoError(t, service.Close())
+
+	records := readAuditRecords(t, path)
+	require.Len(t, records, 2)
+
+	byPartner := make(map[string]audit.Record, len(records))
+	for _, record := range records {
+		assert.Equal(t, "audit-test", record.RequestID)
+		assert.Equal(t, "lead-7", record.LeadID)
+		assert.Equal(t, "auto", record.Vertical)
+		assert.Empty(t, record.Signature)
+		byPartner[record.PartnerID] = record
+	}
+	assert.Equal(t, 10.0, byPartner["open"].BidPrice)
+	assert.Equal(t, 10.0, byPartner["open"].ClearingPrice)
+	assert.Equal(t, "audit-deal", byPartner["buyer"].DealID)
+	assert.Equal(t, 2.0, byPartner["buyer"].BidPrice)
+	assert.Equal(t, 30.0, byPartner["buyer"].ClearingPrice)
+}
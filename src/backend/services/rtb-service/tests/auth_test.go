@@ -0,0 +1,184 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// signingTestBody is the request body signed by the known HMAC vectors
+const signingTestBody = `{"lead_id":"lead-1"}`
+
+// TestHMACSigningVectors tests HMAC signatures over timestamp and body against precomputed vectors
+func TestHMACSigningVectors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		algorithm string
+		expected  string
+	}{
+		{name: "SHA-256 Default", algorithm: "", expected: "21a926508d64d58701dda7766199a8f0532c5700d52f11b1bf7aa3d81e34e2f1"},
+		{name: "SHA-512", algorithm: config.HMACSHA512, expected: "4a4008f3b134331a58b62ff593d3688b573b53537a1999f322bc9793d619945934ae072b1855bf9a091b261f7f501d143c568194cb02628b0136131fb1109cda"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partner := &config.PartnerConfig{Auth: &config.PartnerAuth{
+				Type:            config.AuthHMAC,
+				SigningKey:      "partner-secret",
+				Algorithm:       tc.algorithm,
+				TimestampHeader: "X-Partner-Time",
+			}}
+			auth, err := services.NewPartnerAuthenticator(partner, fixedClock{now: time.Unix(1700000000, 0)})
+			require.NoError(t, err)
+
+			request, err := http.NewRequest(http.MethodPost, "http://partner.example.com", nil)
+			require.NoError(t, err)
+			auth.Apply(request, []byte(signingTestBody))
+
+			assert.Equal(t, "1700000000", request.Header.Get("X-Partner-Time"))
+			assert.Equal(t, tc.expected, request.Header.Get("X-Signature"))
+		})
+	}
+}
+
+// TestPartnerAuthSchemes tests that each scheme reaches the partner with the expected credentials
+func TestPartnerAuthSchemes(t *testing.T) {
+	t.Setenv("RTB_TEST_PARTNER_PASSWORD", "s3cret")
+
+	testCases := []struct {
+		name  string
+		auth  *config.PartnerAuth
+		check func(t *testing.T, r *http.Request, body []byte)
+	}{
+		{
+			name: "Default Bearer",
+			check: func(t *testing.T, r *http.Request, body []byte) {
+				assert.Equal(t, "Bearer key-a", r.Header.Get("Authorization"))
+			},
+		},
+		{
+			name: "Custom Header",
+			auth: &config.PartnerAuth{Type: config.AuthHeader, HeaderName: "X-Api-Token", Token: "header-token"},
+			check: func(t *testing.T, r *http.Request, body []byte) {
+				assert.Equal(t, "header-token", r.Header.Get("X-Api-Token"))
+				assert.Empty(t, r.Header.Get("Authorization"))
+			},
+		},
+		{
+			name: "Basic From Environment",
+			auth: &config.PartnerAuth{Type: config.AuthBasic, Username: "rtb", Password: "env:RTB_TEST_PARTNER_PASSWORD"},
+			check: func(t *testing.T, r *http.Request, body []byte) {
+				username, password, ok := r.BasicAuth()
+				require.True(t, ok)
+				assert.Equal(t, "rtb", username)
+				assert.Equal(t, "s3cret", password)
+			},
+		},
+		{
+			name: "HMAC",
+			auth: &config.PartnerAuth{Type: config.AuthHMAC, SigningKey: "partner-secret"},
+			check: func(t *testing.T, r *http.Request, body []byte) {
+				verifier, err := services.NewPartnerAuthenticator(&config.PartnerConfig{
+					Auth: &config.PartnerAuth{Type: config.AuthHMAC, SigningKey: "partner-secret"},
+				}, nil)
+				require.NoError(t, err)
+				assert.NotEmpty(t, r.Header.Get("X-Timestamp"))
+				assert.Equal(t, verifier.Sign(r.Header.Get("X-Timestamp"), body), r.Header.Get("X-Signature"))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			received := make(chan *http.Request, 1)
+			bodies := make(chan []byte, 1)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				received <- r
+				bodies <- body
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			service, err := services.NewAuctionService(newTestConfig(map[string]*config.PartnerConfig{
+				"partner-a": {ID: "partner-a", Endpoint: server.URL, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true, Auth: tc.auth},
+			}))
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			service.RunAuction(ctx, &models.BidRequest{RequestID: "auth-test", LeadID: "lead-1", Vertical: "auto"})
+
+			tc.check(t, <-received, <-bodies)
+		})
+	}
+}
+
+// TestPartnerAuthValidation tests that auth types are known and their secrets resolve
+func TestPartnerAuthValidation(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "signing.key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("file-secret\n"), 0o600))
+	t.Setenv("RTB_TEST_EMPTY_SECRET", "")
+
+	testCases := []struct {
+		name        string
+		auth        *config.PartnerAuth
+		expectedErr string
+	}{
+		{name: "Default Bearer", auth: nil},
+		{name: "HMAC From File", auth: &config.PartnerAuth{Type: config.AuthHMAC, SigningKey: "file:" + keyFile}},
+		{name: "Unset Environment Secret", auth: &config.PartnerAuth{Type: config.AuthHMAC, SigningKey: "env:RTB_TEST_EMPTY_SECRET"}, expectedErr: "unresolved auth signing key"},
+		{name: "Missing Secret File", auth: &config.PartnerAuth{Type: config.AuthBasic, Username: "rtb", Password: "file:/nonexistent/secret"}, expectedErr: "unresolved auth password"},
+		{name: "Missing Header Name", auth: &config.PartnerAuth{Type: config.AuthHeader, Token: "token"}, expectedErr: "missing auth header name"},
+		{name: "Unknown Algorithm", auth: &config.PartnerAuth{Type: config.AuthHMAC, SigningKey: "key", Algorithm: "md5"}, expectedErr: "unknown HMAC algorithm"},
+		{name: "Unknown Type", auth: &config.PartnerAuth{Type: "oauth"}, expectedErr: "unknown auth type"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].Auth = tc.auth
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+// TestPartnerStatsOmitAuthMaterial tests that partner status and config serialization never include secrets
+func TestPartnerStatsOmitAuthMaterial(t *testing.T) {
+	cfg := newStrategyTestConfig()
+	cfg.Partners["partner-1"].APIKey = "api-key-material"
+	cfg.Partners["partner-2"].Auth = &config.PartnerAuth{Type: config.AuthHMAC, SigningKey: "signing-key-material"}
+
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+
+	statuses, err := json.Marshal(service.PartnerStatuses())
+	require.NoError(t, err)
+	partners, err := json.Marshal(cfg.Partners)
+	require.NoError(t, err)
+
+	for _, encoded := range [][]byte{statuses, partners} {
+		assert.False(t, bytes.Contains(encoded, []byte("api-key-material")))
+		assert.False(t, bytes.Contains(encoded, []byte("signing-key-material")))
+	}
+}
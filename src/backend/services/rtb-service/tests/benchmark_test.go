@@ -0,0 +1,175 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/rules"
+	"github.com/yourdomain/rtb-service/src/services"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// benchmarkPartners is the number of partners bidding in each benchmark auction
+const benchmarkPartners = 8
+
+// newBenchmarkPartner starts a partner that answers every call with the same encoded bid, so
+// benchmarks spend as little as possible on the partner side
+func newBenchmarkPartner(b *testing.B, id string, price float64) *httptest.Server {
+	body, err := json.Marshal(models.Bid{ID: id + "-bid", Price: price, QualityScore: 0.5, ClickURL: "http://" + id + ".example.com/c"})
+	require.NoError(b, err)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	b.Cleanup(server.Close)
+	return server
+}
+
+// newBenchmarkConfig returns a config for benchmarkPartners partners bidding between 10 and 17
+func newBenchmarkConfig(b *testing.B) *config.Config {
+	partners := make(map[string]*config.PartnerConfig, benchmarkPartners)
+	for i := 0; i < benchmarkPartners; i++ {
+		id := fmt.Sprintf("partner-%d", i)
+		server := newBenchmarkPartner(b, id, 10.0+float64(i))
+		partners[id] = &config.PartnerConfig{ID: id, Endpoint: server.URL, APIKey: "key-" + id, Timeout: time.Second, Enabled: true}
+	}
+	return newTestConfig(partners, withBidTimeout(2*time.Second), withMaxBids(3))
+}
+
+// BenchmarkRunAuction measures a full auction against local partners
+func BenchmarkRunAuction(b *testing.B) {
+	service, err := services.NewAuctionService(newBenchmarkConfig(b))
+	require.NoError(b, err)
+	defer service.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		_, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "bench-auction", LeadID: "lead-1", Vertical: "auto"})
+		cancel()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkOptimizeBids measures ranking a bid set with the effective price strategy
+func BenchmarkOptimizeBids(b *testing.B) {
+	cfg := newStrategyTestConfig()
+	cfg.Strategies = nil
+	optimizer, err := utils.NewBidOptimizer(cfg, nil)
+	require.NoError(b, err)
+
+	bids := make([]*models.Bid, 0, benchmarkPartners)
+	for i := 0; i < benchmarkPartners; i++ {
+		bids = append(bids, &models.Bid{ID: fmt.Sprintf("bid-%d", i), PartnerID: fmt.Sprintf("partner-%d", i%2+1), Price: 10.0 + float64(i), QualityScore: 0.5, ClickURL: "http://example.com/c"})
+	}
+	request := &models.BidRequest{RequestID: "bench-optimize", Vertical: "auto"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := optimizer.OptimizeBidSet(bids, request); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkHandleBidRequest measures the HTTP bid endpoint from request parsing to the encoded response
+func BenchmarkHandleBidRequest(b *testing.B) {
+	cfg := newBenchmarkConfig(b)
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(b, err)
+	defer service.Close()
+	router, _ := newTestRouter(b, service, cfg)
+
+	body, err := json.Marshal(models.BidRequest{LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(b, err)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		req := httptest.NewRequest(http.MethodPost, "/v1/bids", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			b.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+	}
+}
+
+// BenchmarkBidRules measures checking one bid against ten rules, none of which match, so every
+// condition is evaluated
+func BenchmarkBidRules(b *testing.B) {
+	sources := []string{
+		`partner.id == "partner-x" AND request.vertical == "auto" AND time.weekday IN ["fri", "sun"] AND bid.price < 5`,
+		`partner.id IN ["partner-a", "partner-b", "partner-c"] AND bid.quality_score < 0.2`,
+		`request.region IN ["NY", "NJ", "CT"] AND bid.price < 8 AND NOT bid.deal_id != ""`,
+		`(time.hour < 6 OR time.hour >= 22) AND bid.price > 50`,
+		`request.user_data.age < 18 OR request.user_data.age > 99`,
+		`request.user_data.homeowner == "no" AND request.vertical == "home"`,
+		`bid.pricing_model == "rev_share" AND partner.id != "partner-x"`,
+		`request.device_type == "tablet" AND bid.price < 2`,
+		`request.country != "US" AND request.country != ""`,
+		`bid.price > 90 AND bid.quality_score < 0.5 AND time.weekday == "mon"`,
+	}
+	conditions := make([]*rules.Condition, 0, len(sources))
+	for _, source := range sources {
+		condition, err := rules.Compile(source)
+		require.NoError(b, err)
+		conditions = append(conditions, condition)
+	}
+	env := newRuleTestEnv()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, condition := range conditions {
+			if condition.Matches(env) {
+				b.Fatal("no rule should match")
+			}
+		}
+	}
+}
+
+// BenchmarkSelectPartnersFairly measures fair selection picking 5 of 50 eligible partners at
+// mixed traffic percentages for a lead
+func BenchmarkSelectPartnersFairly(b *testing.B) {
+	partners := make(map[string]*config.PartnerConfig, 50)
+	eligible := make([]string, 0, 50)
+	for i := 0; i < 50; i++ {
+		id := fmt.Sprintf("partner-%d", i)
+		partners[id] = &config.PartnerConfig{ID: id, Endpoint: "http://" + id, APIKey: "key-" + id, Timeout: time.Second, Enabled: true,
+			TrafficPercentage: float64(25 * (1 + i%4))}
+		eligible = append(eligible, id)
+	}
+	cfg := newTestConfig(partners, withMaxBids(5))
+	cfg.MaxPartnersPerAuction = 5
+	cfg.PartnerSelectionMode = config.PartnerSelectionFair
+	service := newTestService(b, cfg)
+	requests := make([]*models.BidRequest, 1024)
+	for i := range requests {
+		requests[i] = &models.BidRequest{RequestID: "bench-fair", LeadID: fmt.Sprintf("lead-%d", i), Vertical: "auto"}
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		chosen, err := service.SelectPartnersFairly(requests[i%len(requests)], eligible)
+		if err != nil || len(chosen) != 5 {
+			b.Fatalf("selected %v: %v", chosen, err)
+		}
+	}
+}
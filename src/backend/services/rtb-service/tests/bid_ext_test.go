@@ -0,0 +1,148 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newBidExtTestConfig returns a config with one partner returning a bid carrying ext, and a
+// health extension schema requiring a metal tier and typing the carrier rating
+func newBidExtTestConfig(t *testing.T, ext string) *config.Config {
+	partner := newPartnerServer(t, models.Bid{ID: "ext-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/ext", VerticalExt: json.RawMessage(ext)})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 500 * time.Millisecond, Enabled: true},
+	}, withBidTimeout(time.Second))
+	cfg.BidExtSchemas = map[string]*config.BidExtSchema{
+		"health": {
+			MaxBytes: 128,
+			Fields: map[string]*config.BidExtField{
+				"metal_tier":     {Type: config.BidExtTypeString, Required: true},
+				"carrier_rating": {Type: config.BidExtTypeNumber},
+			},
+		},
+	}
+	return cfg
+}
+
+// TestVerticalExtValidation tests that bid extensions failing their vertical's schema drop the
+// bid, that valid ones reach the response untouched, and that verticals without a schema skip
+// validation
+func TestVerticalExtValidation(t *testing.T) {
+	testCases := []struct {
+		name           string
+		vertical       string
+		ext            string
+		expectedResult string
+		expectedErr    string
+	}{
+		{name: "Valid", vertical: "health", ext: `{"metal_tier":"gold","carrier_rating":4.5,"network":["ppo"]}`, expectedResult: "valid"},
+		{name: "Optional Key Missing", vertical: "health", ext: `{"metal_tier":"bronze"}`, expectedResult: "valid"},
+		{name: "Required Key Missing", vertical: "health", ext: `{"carrier_rating":4.5}`, expectedResult: "invalid", expectedErr: "metal_tier is required"},
+		{name: "Required Key Null", vertical: "health", ext: `{"metal_tier":null}`, expectedResult: "invalid", expectedErr: "metal_tier is required"},
+		{name: "Wrong Type", vertical: "health", ext: `{"metal_tier":"gold","carrier_rating":"A+"}`, expectedResult: "invalid", expectedErr: "carrier_rating must be of type number"},
+		{name: "Too Large", vertical: "health", ext: `{"metal_tier":"` + strings.Repeat("platinum", 16) + `"}`, expectedResult: "invalid", expectedErr: "byte limit"},
+		{name: "Unknown Vertical", vertical: "auto", ext: `{"coverage":"full"}`, expectedResult: "unvalidated"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, err := services.NewAuctionService(newBidExtTestConfig(t, tc.ext))
+			require.NoError(t, err)
+			defer service.Close()
+
+			labels := map[string]string{"partner": "partner-1", "vertical": tc.vertical, "result": tc.expectedResult}
+			before := gatheredMetric(t, "rtb_bid_vertical_ext_total", labels)
+
+			debug := models.NewDebugInfo()
+			ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), 2*time.Second)
+			defer cancel()
+			response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "ext-" + tc.name, LeadID: "lead-1", Vertical: tc.vertical})
+
+			assert.Equal(t, 1.0, gatheredMetric(t, "rtb_bid_vertical_ext_total", labels)-before)
+			partner, _ := debug.Partner("partner-1")
+			if tc.expectedErr != "" {
+				assert.ErrorIs(t, err, services.ErrNoValidBids)
+				assert.Contains(t, partner.InvalidExt["ext-bid"], tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, response.Bids, 1)
+			assert.JSONEq(t, tc.ext, string(response.Bids[0].VerticalExt))
+			assert.JSONEq(t, tc.ext, string(partner.VerticalExt["ext-bid"]))
+		})
+	}
+}
+
+// TestVerticalExtPII tests that the PII policy sanitizes the extension keys it names in debug
+// output and webhooks while the response carries the extension as the partner sent it
+func TestVerticalExtPII(t *testing.T) {
+	receiver, webhookServer := newWebhookReceiver(t, http.StatusOK)
+	ext := `{"metal_tier":"gold","agent_phone":"5551234567","agent_email":"agent@example.com"}`
+	cfg := newBidExtTestConfig(t, ext)
+	cfg.PIIPolicy = &config.PIIPolicy{Mask: []string{"vertical_ext.agent_phone"}, Drop: []string{"vertical_ext.agent_email"}}
+	cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: webhookServer.URL, Events: []string{config.WebhookEventBidWon}})
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	debug := models.NewDebugInfo()
+	ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), 2*time.Second)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "ext-pii", LeadID: "lead-1", Vertical: "health"})
+	require.NoError(t, err)
+	require.Len(t, response.Bids, 1)
+	assert.JSONEq(t, ext, string(response.Bids[0].VerticalExt))
+
+	sanitized := `{"metal_tier":"gold","agent_phone":"******4567"}`
+	partner, _ := debug.Partner("partner-1")
+	assert.JSONEq(t, sanitized, string(partner.VerticalExt["ext-bid"]))
+
+	require.Eventually(t, func() bool { return len(receiver.received()) == 1 }, time.Second, 5*time.Millisecond)
+	data, ok := receiver.received()[0].event.Data.(map[string]any)
+	require.True(t, ok)
+	encoded, err := json.Marshal(data["vertical_ext"])
+	require.NoError(t, err)
+	assert.JSONEq(t, sanitized, string(encoded))
+}
+
+// TestBidExtSchemaValidation tests bid extension schema bounds
+func TestBidExtSchemaValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		schema      *config.BidExtSchema
+		expectedErr string
+	}{
+		{name: "Valid", schema: &config.BidExtSchema{Fields: map[string]*config.BidExtField{"metal_tier": {Type: config.BidExtTypeString, Required: true}}}},
+		{name: "Size Cap", schema: &config.BidExtSchema{MaxBytes: config.MaxBidExtMaxBytes}},
+		{name: "Size Cap Too Large", schema: &config.BidExtSchema{MaxBytes: config.MaxBidExtMaxBytes + 1}, expectedErr: "bid extension max bytes"},
+		{name: "Negative Size Cap", schema: &config.BidExtSchema{MaxBytes: -1}, expectedErr: "bid extension max bytes"},
+		{name: "Unknown Type", schema: &config.BidExtSchema{Fields: map[string]*config.BidExtField{"rating": {Type: "int"}}}, expectedErr: `unknown type "int"`},
+		{name: "Empty Field", schema: &config.BidExtSchema{Fields: map[string]*config.BidExtField{"rating": nil}}, expectedErr: "empty bid extension field"},
+		{name: "Empty Schema", schema: nil, expectedErr: "empty bid extension schema"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.BidExtSchemas = map[string]*config.BidExtSchema{"health": tc.schema}
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+	"github.com/yourdomain/rtb-service/src/utils"
+	"github.com/yourdomain/rtb-service/src/webhooks"
+)
+
+// TestPriceForEffectivePrice tests that inverting the effective price recovers the bid price and
+// reaches a target effective price under quality, acknowledgement, time, vertical, and device multipliers
+func TestPriceForEffectivePrice(t *testing.T) {
+	weight := 0.4
+	cfg := newStrategyTestConfig()
+	cfg.Strategies = nil
+	cfg.QualityWeight = &weight
+	cfg.QualityAcknowledgedBonus = 0.1
+	cfg.TimeMultipliers = newTimeMultiplierConfig()
+	cfg.Partners["partner-2"].VerticalMultipliers = map[string]float64{"auto": 1.3, "default": 0.8}
+	cfg.Partners["partner-2"].DeviceMultipliers = map[string]float64{"mobile": 0.9}
+
+	// 10am Eastern on a Monday: auto is in business hours at 1.2 and health in its override at 1.5
+	optimizer, err := utils.NewBidOptimizerWithClock(cfg, nil, fixedClock{now: time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name               string
+		bid                models.Bid
+		request            models.BidRequest
+		expectedMultiplier float64
+	}{
+		{
+			name:               "Quality And Time",
+			bid:                models.Bid{PartnerID: "partner-1", Price: 10, QualityScore: 0.5},
+			request:            models.BidRequest{Vertical: "auto"},
+			expectedMultiplier: 1.2 * 1.2,
+		},
+		{
+			name:               "Quality Acknowledged",
+			bid:                models.Bid{PartnerID: "partner-1", Price: 10, QualityScore: 0.5, QualityAcknowledged: true},
+			request:            models.BidRequest{Vertical: "auto", LeadQuality: &models.LeadQuality{}},
+			expectedMultiplier: 1.2 * 1.1 * 1.2,
+		},
+		{
+			name:               "Acknowledged Without Lead Quality",
+			bid:                models.Bid{PartnerID: "partner-1", Price: 10, QualityScore: 0.5, QualityAcknowledged: true},
+			request:            models.BidRequest{Vertical: "auto"},
+			expectedMultiplier: 1.2 * 1.2,
+		},
+		{
+			name:               "Vertical And Device",
+			bid:                models.Bid{PartnerID: "partner-2", Price: 10, QualityScore: 0.25},
+			request:            models.BidRequest{Vertical: "auto", Device: &models.Device{Type: "mobile"}},
+			expectedMultiplier: 1.1 * 1.2 * 1.3 * 0.9,
+		},
+		{
+			name:               "Default Vertical And Time Override",
+			bid:                models.Bid{PartnerID: "partner-2", Price: 10, QualityScore: 1.0},
+			request:            models.BidRequest{Vertical: "health"},
+			expectedMultiplier: 1.4 * 1.5 * 0.8,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			effectivePrice, ok := optimizer.EffectivePrice(&tc.bid, &tc.request)
+			require.True(t, ok)
+			assert.InDelta(t, tc.bid.Price*tc.expectedMultiplier, effectivePrice, 1e-9)
+
+			price, ok := optimizer.PriceForEffectivePrice(&tc.bid, &tc.request, effectivePrice)
+			require.True(t, ok)
+			assert.InDelta(t, tc.bid.Price, price, 1e-9)
+
+			target := 20.0
+			price, ok = optimizer.PriceForEffectivePrice(&tc.bid, &tc.request, target)
+			require.True(t, ok)
+			assert.InDelta(t, target/tc.expectedMultiplier, price, 1e-9)
+			tc.bid.Price = price
+			reached, ok := optimizer.EffectivePrice(&tc.bid, &tc.request)
+			require.True(t, ok)
+			assert.InDelta(t, target, reached, 1e-9)
+		})
+	}
+
+	t.Run("Unknown Partner", func(t *testing.T) {
+		_, ok := optimizer.PriceForEffectivePrice(&models.Bid{PartnerID: "partner-9", Price: 10}, &models.BidRequest{Vertical: "auto"}, 20)
+		assert.False(t, ok)
+	})
+}
+
+// TestBidGuidanceWebhooks tests min-to-win on bid.won and price-to-beat on bid.lost, in the
+// partner's own pricing model and only for partners sharing bid guidance. Effective prices, at the
+// default quality weight of 0.3 and quality 0.5: the winner 10 * 1.15 * 1.2 = 13.8, the runner-up
+// 8 * 1.15 = 9.2, and the third 6 * 1.15 = 6.9.
+func TestBidGuidanceWebhooks(t *testing.T) {
+	testCases := []struct {
+		name                string
+		sharing             []string
+		revShare            bool
+		expectedMinToWin    interface{}
+		expectedPriceToBeat map[string]float64
+	}{
+		{
+			name:    "Winner And Losers Sharing",
+			sharing: []string{"winner", "runner-up", "third"},
+			// 9.2 / (1.15 * 1.2), rounded like clearing prices
+			expectedMinToWin: 6.67,
+			// 13.8 / 1.15 for both, as neither has a vertical multiplier
+			expectedPriceToBeat: map[string]float64{"runner-up": 12, "third": 12},
+		},
+		{
+			name:                "Loser Only Sharing",
+			sharing:             []string{"runner-up"},
+			expectedPriceToBeat: map[string]float64{"runner-up": 12},
+		},
+		{
+			name:     "Revenue Share Loser",
+			sharing:  []string{"runner-up"},
+			revShare: true,
+			// 12 as a percentage of the 200 expected premium
+			expectedPriceToBeat: map[string]float64{"runner-up": 6},
+		},
+		{
+			name: "Nobody Sharing",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver, server := newWebhookReceiver(t, http.StatusOK)
+			partners := map[string]*config.PartnerConfig{}
+			for _, entry := range []struct {
+				id    string
+				price float64
+			}{{"winner", 10}, {"runner-up", 8}, {"third", 6}} {
+				partner := newPartnerServer(t, models.Bid{ID: entry.id + "-bid", Price: entry.price, QualityScore: 0.5, ClickURL: "http://example.com/" + entry.id})
+				partners[entry.id] = &config.PartnerConfig{ID: entry.id, Endpoint: partner.URL, APIKey: "key-" + entry.id, Timeout: 500 * time.Millisecond, Enabled: true}
+			}
+			partners["winner"].VerticalMultipliers = map[string]float64{"auto": 1.2}
+			if tc.revShare {
+				// A 4% share of the 200 premium is the runner-up's 8 cost per lead
+				partners["runner-up"].PricingModel = config.PricingModelRevShare
+				partner := newPartnerServer(t, models.Bid{ID: "runner-up-bid", Price: 4, QualityScore: 0.5, ClickURL: "http://example.com/runner-up"})
+				partners["runner-up"].Endpoint = partner.URL
+			}
+			for _, id := range tc.sharing {
+				partners[id].ShareBidGuidance = true
+			}
+
+			cfg := newTestConfig(partners, withBidTimeout(time.Second))
+			cfg.ExpectedPremiums = map[string]float64{"auto": 200}
+			cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: server.URL, Events: []string{config.WebhookEventBidWon, config.WebhookEventBidLost}})
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "guidance", LeadID: "lead-1", Vertical: "auto"})
+			require.NoError(t, err)
+			require.Len(t, response.Bids, 1)
+			require.Equal(t, "winner", response.Bids[0].PartnerID)
+
+			expectedEvents := 1 + len(tc.expectedPriceToBeat)
+			require.Eventually(t, func() bool { return len(receiver.received()) == expectedEvents }, 2*time.Second, 5*time.Millisecond)
+
+			var won webhooks.Event
+			lost := make(map[string]float64)
+			for _, delivery := range receiver.received() {
+				data, _ := delivery.event.Data.(map[string]interface{})
+				switch delivery.event.Type {
+				case config.WebhookEventBidWon:
+					won = delivery.event
+				case config.WebhookEventBidLost:
+					assert.Equal(t, data["partner_id"].(string)+"-bid", data["bid_id"])
+					lost[data["partner_id"].(string)] = data["price_to_beat"].(float64)
+				}
+			}
+
+			data, ok := won.Data.(map[string]interface{})
+			require.True(t, ok)
+			assert.Equal(t, tc.expectedMinToWin, data["min_to_win"])
+			require.Len(t, lost, len(tc.expectedPriceToBeat))
+			for partnerID, expected := range tc.expectedPriceToBeat {
+				assert.InDelta(t, expected, lost[partnerID], 1e-9, partnerID)
+			}
+		})
+	}
+}
@@ -4,18 +4,18 @@ package tests
 
 import (
 	"bytes"
-	"context"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
 
-	"github.com/gin-gonic/gin" // v1.9.1
-	"github.com/stretchr/testify/assert" // v1.8.4
-	"github.com/stretchr/testify/mock" // v1.8.4
-	"golang.org/x/sync/errgroup" // v0.3.0
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+	"golang.org/x/sync/errgroup"          // v0.3.0
 
 	"github.com/yourdomain/rtb-service/src/config"
 	"github.com/yourdomain/rtb-service/src/handlers"
@@ -23,111 +23,123 @@ import (
 	"github.com/yourdomain/rtb-service/src/services"
 )
 
-// mockAuctionService implements a mock auction service for testing
-type mockAuctionService struct {
-	mock.Mock
-	healthy        bool
-	delay          time.Duration
-	qualityScores  map[string]float64
-	partnerOrder   []string
+// handlerTestPartner is a partner that answers with a configurable bid, status, and delay and
+// records the request IDs it receives
+type handlerTestPartner struct {
+	server     *httptest.Server
+	mutex      sync.Mutex
+	bid        *models.Bid
+	status     int
+	delay      time.Duration
+	requestIDs []string
 }
 
-func (m *mockAuctionService) RunAuction(ctx context.Context, request *models.BidRequest) (*models.BidResponse, error) {
-	// Simulate configured delay
-	if m.delay > 0 {
-		select {
-		case <-ctx.Done():
-			return nil, services.ErrAuctionTimeout
-		case <-time.After(m.delay):
+// newHandlerTestPartner starts a handler test partner answering with bid
+func newHandlerTestPartner(t *testing.T, bid models.Bid) *handlerTestPartner {
+	partner := &handlerTestPartner{bid: &bid, status: http.StatusOK}
+	partner.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		partner.mutex.Lock()
+		partner.requestIDs = append(partner.requestIDs, r.Header.Get(services.RequestIDHeader))
+		status, delay := partner.status, partner.delay
+		partner.mutex.Unlock()
+
+		time.Sleep(delay)
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
 		}
-	}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(partner.bid)
+	}))
+	t.Cleanup(partner.server.Close)
+	return partner
+}
 
-	args := m.Called(ctx, request)
-	if args.Get(0) == nil {
-		return nil, args.Error(1)
-	}
-	return args.Get(0).(*models.BidResponse), args.Error(1)
+// respond sets the status and delay of the partner's following answers
+func (p *handlerTestPartner) respond(status int, delay time.Duration) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.status, p.delay = status, delay
 }
 
-func (m *mockAuctionService) GetPartnerStats() map[string]int {
-	return map[string]int{"test-partner": 0}
+// lastRequestID returns the request ID of the partner's latest request
+func (p *handlerTestPartner) lastRequestID() string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if len(p.requestIDs) == 0 {
+		return ""
+	}
+	return p.requestIDs[len(p.requestIDs)-1]
 }
 
-// setupTestEnvironment creates a test environment with mocked dependencies
-func setupTestEnvironment(t *testing.T) (*gin.Engine, *handlers.BidHandler, *mockAuctionService) {
+// setupTestEnvironment creates a bid handler over a real auction service with one partner per bid
+func setupTestEnvironment(t *testing.T, bids ...models.Bid) (*gin.Engine, []*handlerTestPartner) {
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
 
-	cfg := &config.Config{
-		BidTimeout:        500 * time.Millisecond,
-		MaxBidsPerRequest: 3,
-		MinBidPrice:      0.01,
-		MaxBidPrice:      100.0,
-		Partners: map[string]*config.PartnerConfig{
-			"test-partner": {
-				ID:       "test-partner",
-				Enabled:  true,
-				Timeout:  200 * time.Millisecond,
-				MinBid:   0.01,
-				MaxBid:   50.0,
-				Priority: 1,
-			},
-		},
+	cfg := newTestConfig(make(map[string]*config.PartnerConfig, len(bids)), withMaxBids(3))
+
+	partners := make([]*handlerTestPartner, 0, len(bids))
+	for i, bid := range bids {
+		partnerID := fmt.Sprintf("partner-%d", i+1)
+		partner := newHandlerTestPartner(t, bid)
+		cfg.Partners[partnerID] = &config.PartnerConfig{
+			ID:       partnerID,
+			Endpoint: partner.server.URL,
+			APIKey:   "test-key",
+			Enabled:  true,
+			Timeout:  time.Second,
+			MinBid:   0.01,
+			MaxBid:   50.0,
+			Priority: 1,
+		}
+		partners = append(partners, partner)
 	}
 
-	mockAuction := &mockAuctionService{
-		healthy:       true,
-		qualityScores: make(map[string]float64),
-	}
+	auctionService := newTestService(t, cfg)
 
-	handler, err := handlers.NewBidHandler(mockAuction, cfg)
-	assert.NoError(t, err)
+	handler, err := handlers.NewBidHandler(auctionService, cfg)
+	require.NoError(t, err)
 
 	router.POST("/v1/bids", handler.HandleBidRequest)
 	router.GET("/health", handler.HandleHealthCheck)
 
-	return router, handler, mockAuction
+	return router, partners
 }
 
-// TestBidHandlerHandleBidRequest tests the basic bid request handling functionality
-func TestBidHandlerHandleBidRequest(t *testing.T) {
-	router, _, mockAuction := setupTestEnvironment(t)
-
-	// Prepare test bid response
-	testBids := []*models.Bid{
-		{
-			ID:           "bid-1",
-			PartnerID:    "test-partner",
-			Price:        10.0,
-			QualityScore: 0.8,
-			ClickURL:     "http://example.com/click1",
-		},
-	}
-
-	testResponse := &models.BidResponse{
-		RequestID: "test-123",
-		Bids:     testBids,
-		Timestamp: time.Now(),
+// postBidRequest posts body to the router's bid endpoint
+func postBidRequest(router *gin.Engine, body []byte, header http.Header) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/bids", bytes.NewBuffer(body))
+	for key, values := range header {
+		req.Header[key] = values
 	}
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
 
-	mockAuction.On("RunAuction", mock.Anything, mock.Anything).Return(testResponse, nil)
+// TestBidHandlerHandleBidRequest tests the basic bid request handling functionality
+func TestBidHandlerHandleBidRequest(t *testing.T) {
+	router, _ := setupTestEnvironment(t, models.Bid{
+		ID:           "bid-1",
+		Price:        10.0,
+		QualityScore: 0.8,
+		ClickURL:     "http://example.com/click1",
+	})
 
 	// Create test request
 	reqBody := models.BidRequest{
 		RequestID: "test-123",
-		LeadID:   "lead-123",
-		Vertical: "auto",
-		Timeout:  400 * time.Millisecond,
+		LeadID:    "lead-123",
+		Vertical:  "auto",
+		Timeout:   400 * time.Millisecond,
 	}
 
 	jsonBody, err := json.Marshal(reqBody)
 	assert.NoError(t, err)
 
-	// Execute request
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("POST", "/v1/bids", bytes.NewBuffer(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	router.ServeHTTP(w, req)
+	w := postBidRequest(router, jsonBody, nil)
 
 	// Verify response
 	assert.Equal(t, http.StatusOK, w.Code)
@@ -136,35 +148,19 @@ func TestBidHandlerHandleBidRequest(t *testing.T) {
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
 	assert.Equal(t, reqBody.RequestID, response.RequestID)
-	assert.Len(t, response.Bids, 1)
-	assert.Equal(t, testBids[0].ID, response.Bids[0].ID)
+	require.Len(t, response.Bids, 1)
+	assert.Equal(t, "bid-1", response.Bids[0].ID)
+	assert.Equal(t, "partner-1", response.Bids[0].PartnerID)
 }
 
 // TestBidHandlerConcurrentRequests tests handling of concurrent bid requests
 func TestBidHandlerConcurrentRequests(t *testing.T) {
-	router, _, mockAuction := setupTestEnvironment(t)
-
-	// Configure mock response
-	testResponse := &models.BidResponse{
-		RequestID: "test-123",
-		Bids: []*models.Bid{
-			{
-				ID:           "bid-1",
-				PartnerID:    "test-partner",
-				Price:        10.0,
-				QualityScore: 0.8,
-			},
-		},
-	}
-
-	mockAuction.On("RunAuction", mock.Anything, mock.Anything).Return(testResponse, nil)
-
-	// Create request template
-	reqBody := models.BidRequest{
-		LeadID:   "lead-123",
-		Vertical: "auto",
-		Timeout:  400 * time.Millisecond,
-	}
+	router, _ := setupTestEnvironment(t, models.Bid{
+		ID:           "bid-1",
+		Price:        10.0,
+		QualityScore: 0.8,
+		ClickURL:     "http://example.com/click1",
+	})
 
 	// Execute concurrent requests
 	concurrentRequests := 100
@@ -174,18 +170,17 @@ func TestBidHandlerConcurrentRequests(t *testing.T) {
 	for i := 0; i < concurrentRequests; i++ {
 		i := i
 		eg.Go(func() error {
-			reqBody.RequestID = fmt.Sprintf("test-%d", i)
-			jsonBody, err := json.Marshal(reqBody)
+			jsonBody, err := json.Marshal(models.BidRequest{
+				RequestID: fmt.Sprintf("test-%d", i),
+				LeadID:    "lead-123",
+				Vertical:  "auto",
+				Timeout:   400 * time.Millisecond,
+			})
 			if err != nil {
 				return err
 			}
 
-			w := httptest.NewRecorder()
-			req, _ := http.NewRequest("POST", "/v1/bids", bytes.NewBuffer(jsonBody))
-			req.Header.Set("Content-Type", "application/json")
-			router.ServeHTTP(w, req)
-
-			responses[i] = w.Code
+			responses[i] = postBidRequest(router, jsonBody, nil).Code
 			return nil
 		})
 	}
@@ -200,55 +195,20 @@ func TestBidHandlerConcurrentRequests(t *testing.T) {
 
 // TestBidHandlerQualityScoreOptimization tests quality score-based bid optimization
 func TestBidHandlerQualityScoreOptimization(t *testing.T) {
-	router, _, mockAuction := setupTestEnvironment(t)
+	router, _ := setupTestEnvironment(t,
+		models.Bid{ID: "bid-1", Price: 10.0, QualityScore: 0.9, ClickURL: "http://example.com/click1"},
+		models.Bid{ID: "bid-2", Price: 12.0, QualityScore: 0.5, ClickURL: "http://example.com/click2"},
+		models.Bid{ID: "bid-3", Price: 8.0, QualityScore: 0.95, ClickURL: "http://example.com/click3"},
+	)
 
-	// Prepare test bids with varying quality scores
-	testBids := []*models.Bid{
-		{
-			ID:           "bid-1",
-			PartnerID:    "partner-1",
-			Price:        10.0,
-			QualityScore: 0.9,
-			ClickURL:     "http://example.com/click1",
-		},
-		{
-			ID:           "bid-2",
-			PartnerID:    "partner-2",
-			Price:        12.0,
-			QualityScore: 0.5,
-			ClickURL:     "http://example.com/click2",
-		},
-		{
-			ID:           "bid-3",
-			PartnerID:    "partner-3",
-			Price:        8.0,
-			QualityScore: 0.95,
-			ClickURL:     "http://example.com/click3",
-		},
-	}
-
-	testResponse := &models.BidResponse{
-		RequestID: "test-123",
-		Bids:     testBids,
-		Timestamp: time.Now(),
-	}
-
-	mockAuction.On("RunAuction", mock.Anything, mock.Anything).Return(testResponse, nil)
-
-	// Execute request
-	reqBody := models.BidRequest{
+	jsonBody, err := json.Marshal(models.BidRequest{
 		RequestID: "test-123",
-		LeadID:   "lead-123",
-		Vertical: "auto",
-	}
-
-	jsonBody, err := json.Marshal(reqBody)
+		LeadID:    "lead-123",
+		Vertical:  "auto",
+	})
 	assert.NoError(t, err)
 
-	w := httptest.NewRecorder()
-	req, _ := http.NewRequest("POST", "/v1/bids", bytes.NewBuffer(jsonBody))
-	req.Header.Set("Content-Type", "application/json")
-	router.ServeHTTP(w, req)
+	w := postBidRequest(router, jsonBody, nil)
 
 	// Verify response
 	assert.Equal(t, http.StatusOK, w.Code)
@@ -256,6 +216,7 @@ func TestBidHandlerQualityScoreOptimization(t *testing.T) {
 	var response models.BidResponse
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
+	require.Len(t, response.Bids, 3)
 
 	// Verify bids are ordered by effective price (price * quality score)
 	for i := 0; i < len(response.Bids)-1; i++ {
@@ -267,54 +228,90 @@ func TestBidHandlerQualityScoreOptimization(t *testing.T) {
 
 // TestBidHandlerErrorScenarios tests various error handling scenarios
 func TestBidHandlerErrorScenarios(t *testing.T) {
-	router, _, mockAuction := setupTestEnvironment(t)
+	validBody, err := json.Marshal(models.BidRequest{RequestID: "test-123", LeadID: "lead-123", Vertical: "auto"})
+	require.NoError(t, err)
 
 	testCases := []struct {
 		name           string
-		request        models.BidRequest
-		mockError      error
+		body           []byte
+		partnerStatus  int
+		partnerDelay   time.Duration
 		expectedStatus int
 	}{
 		{
-			name:           "Invalid Request - Missing RequestID",
-			request:        models.BidRequest{LeadID: "lead-123"},
+			name:           "Invalid Request - Malformed Body",
+			body:           []byte(`{"lead_id":`),
+			partnerStatus:  http.StatusOK,
 			expectedStatus: http.StatusBadRequest,
 		},
 		{
 			name:           "Auction Timeout",
-			request:        models.BidRequest{RequestID: "test-123", LeadID: "lead-123"},
-			mockError:      services.ErrAuctionTimeout,
+			body:           validBody,
+			partnerStatus:  http.StatusOK,
+			partnerDelay:   600 * time.Millisecond,
 			expectedStatus: http.StatusGatewayTimeout,
 		},
 		{
 			name:           "No Valid Bids",
-			request:        models.BidRequest{RequestID: "test-123", LeadID: "lead-123"},
-			mockError:      services.ErrNoValidBids,
+			body:           validBody,
+			partnerStatus:  http.StatusNoContent,
 			expectedStatus: http.StatusNoContent,
 		},
 		{
 			name:           "Partner Failure",
-			request:        models.BidRequest{RequestID: "test-123", LeadID: "lead-123"},
-			mockError:      services.ErrPartnerFailure,
-			expectedStatus: http.StatusServiceUnavailable,
+			body:           validBody,
+			partnerStatus:  http.StatusInternalServerError,
+			expectedStatus: http.StatusNoContent,
 		},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if tc.mockError != nil {
-				mockAuction.On("RunAuction", mock.Anything, mock.Anything).Return(nil, tc.mockError).Once()
-			}
+			router, partners := setupTestEnvironment(t, models.Bid{ID: "bid-1", Price: 10.0, QualityScore: 0.8, ClickURL: "http://example.com/click1"})
+			partners[0].respond(tc.partnerStatus, tc.partnerDelay)
 
-			jsonBody, err := json.Marshal(tc.request)
-			assert.NoError(t, err)
-
-			w := httptest.NewRecorder()
-			req, _ := http.NewRequest("POST", "/v1/bids", bytes.NewBuffer(jsonBody))
-			req.Header.Set("Content-Type", "application/json")
-			router.ServeHTTP(w, req)
+			w := postBidRequest(router, tc.body, nil)
 
 			assert.Equal(t, tc.expectedStatus, w.Code)
 		})
 	}
-}
\ No newline at end of file
+}
+
+// TestBidHandlerRequestID tests request ID resolution, generation, and echo
+func TestBidHandlerRequestID(t *testing.T) {
+	router, partners := setupTestEnvironment(t, models.Bid{ID: "bid-1", Price: 10.0, QualityScore: 0.8, ClickURL: "http://example.com/click1"})
+
+	testCases := []struct {
+		name       string
+		headerID   string
+		bodyID     string
+		expectedID string
+	}{
+		{name: "Header ID", headerID: "header-id", bodyID: "body-id", expectedID: "header-id"},
+		{name: "Body ID", bodyID: "body-id", expectedID: "body-id"},
+		{name: "Generated ID"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jsonBody, err := json.Marshal(models.BidRequest{RequestID: tc.bodyID, LeadID: "lead-123", Vertical: "auto"})
+			assert.NoError(t, err)
+
+			header := http.Header{}
+			if tc.headerID != "" {
+				header.Set(handlers.RequestIDHeader, tc.headerID)
+			}
+			w := postBidRequest(router, jsonBody, header)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			echoed := w.Header().Get(handlers.RequestIDHeader)
+			assert.Equal(t, echoed, w.Header().Get("X-RTB-Request-ID"))
+			assert.Equal(t, echoed, partners[0].lastRequestID())
+			if tc.expectedID != "" {
+				assert.Equal(t, tc.expectedID, echoed)
+			} else {
+				assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, echoed)
+			}
+		})
+	}
+}
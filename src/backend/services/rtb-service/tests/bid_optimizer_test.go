@@ -0,0 +1,284 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// newStrategyTestConfig creates a config with per-vertical strategies and two partners
+func newStrategyTestConfig() *config.Config {
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: "http://partner-1", APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+		"partner-2": {ID: "partner-2", Endpoint: "http://partner-2", APIKey: "key-2", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.Strategies = map[string]string{
+		config.DefaultStrategyKey: config.StrategyPassthrough,
+		"auto":                    config.StrategyEffectivePrice,
+		"health":                  config.StrategyQualityWeighted,
+	}
+	return cfg
+}
+
+// newStrategyTestBids returns a high-price, low-quality bid followed by a low-price, high-quality bid
+func newStrategyTestBids() []*models.Bid {
+	return []*models.Bid{
+		{ID: "cheap-quality", PartnerID: "partner-2", Price: 6.0, QualityScore: 0.9, ClickURL: "http://example.com/2"},
+		{ID: "expensive-junk", PartnerID: "partner-1", Price: 10.0, QualityScore: 0.2, ClickURL: "http://example.com/1"},
+	}
+}
+
+// TestBidOptimizerStrategySelection tests that verticals rank the same bid set with their configured strategy
+func TestBidOptimizerStrategySelection(t *testing.T) {
+	cfg := newStrategyTestConfig()
+	optimizer, err := utils.NewBidOptimizer(cfg, nil)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name        string
+		vertical    string
+		expectedIDs []string
+	}{
+		{name: "Effective Price", vertical: "auto", expectedIDs: []string{"expensive-junk", "cheap-quality"}},
+		{name: "Quality Weighted", vertical: "health", expectedIDs: []string{"cheap-quality", "expensive-junk"}},
+		{name: "Default Fallback", vertical: "home", expectedIDs: []string{"cheap-quality", "expensive-junk"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ranked, err := optimizer.OptimizeBidSet(newStrategyTestBids(), &models.BidRequest{Vertical: tc.vertical})
+			require.NoError(t, err)
+
+			ids := make([]string, 0, len(ranked))
+			for _, bid := range ranked {
+				ids = append(ids, bid.ID)
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+}
+
+// TestBidOptimizerUnknownStrategy tests that configuration validation rejects unknown strategy names
+func TestBidOptimizerUnknownStrategy(t *testing.T) {
+	cfg := newStrategyTestConfig()
+	require.NoError(t, cfg.Validate())
+
+	cfg.Strategies["auto"] = "highest_bidder"
+	err := cfg.Validate()
+	assert.ErrorContains(t, err, "unknown optimization strategy")
+}
+
+// fixedClock is a utils.Clock frozen at a single instant
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+// newTimeMultiplierConfig schedules weekday business hours in Eastern time, with a health override
+func newTimeMultiplierConfig() *config.TimeMultiplierConfig {
+	return &config.TimeMultiplierConfig{
+		Timezone: "America/New_York",
+		Rules: []config.TimeMultiplierRule{
+			{Days: []string{"mon", "tue", "wed", "thu", "fri"}, StartHour: 9, EndHour: 17, Multiplier: 1.2},
+			{StartHour: 18, EndHour: 22, Multiplier: 1.1},
+		},
+		Verticals: map[string][]config.TimeMultiplierRule{
+			"health": {{StartHour: 9, EndHour: 12, Multiplier: 1.5}},
+		},
+	}
+}
+
+// TestBidOptimizerTimeMultiplier tests schedule evaluation in the configured timezone against a frozen clock
+func TestBidOptimizerTimeMultiplier(t *testing.T) {
+	testCases := []struct {
+		name     string
+		now      time.Time
+		vertical string
+		expected float64
+	}{
+		// 2024-01-15 is a Monday; Eastern time is UTC-5
+		{name: "Eastern Business Hours", now: time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC), vertical: "auto", expected: 1.2},
+		{name: "Before Eastern Opening", now: time.Date(2024, 1, 15, 13, 59, 0, 0, time.UTC), vertical: "auto", expected: 1.0},
+		{name: "Weekend Evening", now: time.Date(2024, 1, 13, 23, 30, 0, 0, time.UTC), vertical: "auto", expected: 1.1},
+		{name: "Weekend Business Hours", now: time.Date(2024, 1, 13, 15, 0, 0, 0, time.UTC), vertical: "auto", expected: 1.0},
+		{name: "Vertical Override", now: time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC), vertical: "health", expected: 1.5},
+		{name: "Vertical Override Outside Rules", now: time.Date(2024, 1, 15, 18, 0, 0, 0, time.UTC), vertical: "health", expected: 1.0},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.TimeMultipliers = newTimeMultiplierConfig()
+
+			optimizer, err := utils.NewBidOptimizerWithClock(cfg, nil, fixedClock{now: tc.now})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, optimizer.TimeMultiplier(tc.vertical))
+		})
+	}
+
+	t.Run("Empty Schedule", func(t *testing.T) {
+		optimizer, err := utils.NewBidOptimizerWithClock(newStrategyTestConfig(), nil, fixedClock{now: time.Date(2024, 1, 15, 14, 0, 0, 0, time.UTC)})
+		require.NoError(t, err)
+		assert.Equal(t, 1.0, optimizer.TimeMultiplier("auto"))
+	})
+}
+
+// TestTimeMultiplierValidation tests rejection of overlapping, out-of-bounds, and mislocated schedules
+func TestTimeMultiplierValidation(t *testing.T) {
+	testCases := []struct {
+		name   string
+		modify func(*config.TimeMultiplierConfig)
+		errMsg string
+	}{
+		{
+			name: "Overlapping Rules",
+			modify: func(tm *config.TimeMultiplierConfig) {
+				tm.Rules = append(tm.Rules, config.TimeMultiplierRule{Days: []string{"fri"}, StartHour: 16, EndHour: 19, Multiplier: 1.0})
+			},
+			errMsg: "overlaps",
+		},
+		{
+			name: "Multiplier Out Of Bounds",
+			modify: func(tm *config.TimeMultiplierConfig) {
+				tm.Rules[0].Multiplier = 2.5
+			},
+			errMsg: "must be between",
+		},
+		{
+			name: "Inverted Hours",
+			modify: func(tm *config.TimeMultiplierConfig) {
+				tm.Verticals["health"][0].EndHour = 8
+			},
+			errMsg: "invalid hours",
+		},
+		{
+			name: "Unknown Timezone",
+			modify: func(tm *config.TimeMultiplierConfig) {
+				tm.Timezone = "Mars/Olympus_Mons"
+			},
+			errMsg: "invalid time multiplier timezone",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.TimeMultipliers = newTimeMultiplierConfig()
+			require.NoError(t, cfg.Validate())
+
+			tc.modify(cfg.TimeMultipliers)
+			assert.ErrorContains(t, cfg.Validate(), tc.errMsg)
+		})
+	}
+}
+
+// TestEffectivePriceRange tests that effective price bounds cover every quality score, lead
+// quality acknowledgement, and time-of-day multiplier a bid could be ranked with
+func TestEffectivePriceRange(t *testing.T) {
+	acknowledged, unacknowledged := true, false
+	testCases := []struct {
+		name         string
+		modify       func(cfg *config.Config, request *models.BidRequest)
+		partnerID    string
+		minPrice     float64
+		maxPrice     float64
+		acknowledged *bool
+		low          float64
+		high         float64
+		ok           bool
+	}{
+		{name: "Single Price", minPrice: 10, maxPrice: 10, acknowledged: &unacknowledged, low: 10.3, high: 13, ok: true},
+		{name: "Price Range", minPrice: 5, maxPrice: 10, low: 5.15, high: 13, ok: true},
+		{
+			name: "Acknowledgement Possible",
+			modify: func(cfg *config.Config, request *models.BidRequest) {
+				cfg.QualityAcknowledgedBonus = 0.2
+				request.LeadQuality = &models.LeadQuality{}
+			},
+			minPrice: 10, maxPrice: 10, low: 10.3, high: 15.6, ok: true,
+		},
+		{
+			name: "Acknowledged",
+			modify: func(cfg *config.Config, request *models.BidRequest) {
+				cfg.QualityAcknowledgedBonus = 0.2
+				request.LeadQuality = &models.LeadQuality{}
+			},
+			minPrice: 10, maxPrice: 10, acknowledged: &acknowledged, low: 12.36, high: 15.6, ok: true,
+		},
+		{
+			name:     "Acknowledgement Without Lead Quality",
+			modify:   func(cfg *config.Config, request *models.BidRequest) { cfg.QualityAcknowledgedBonus = 0.2 },
+			minPrice: 10, maxPrice: 10, acknowledged: &acknowledged, low: 10.3, high: 13, ok: true,
+		},
+		{
+			name: "Negative Quality Weight",
+			modify: func(cfg *config.Config, request *models.BidRequest) {
+				weight := -0.5
+				cfg.QualityWeight = &weight
+			},
+			minPrice: 10, maxPrice: 10, low: 5, high: 9.5, ok: true,
+		},
+		{
+			name: "Partner Multipliers",
+			modify: func(cfg *config.Config, request *models.BidRequest) {
+				cfg.Partners["partner-1"].VerticalMultipliers = map[string]float64{"auto": 2.0}
+				cfg.Partners["partner-1"].DeviceMultipliers = map[string]float64{"mobile": 0.75}
+				request.Device = &models.Device{Type: "mobile"}
+			},
+			minPrice: 10, maxPrice: 10, low: 15.45, high: 19.5, ok: true,
+		},
+		{
+			name: "Time Multipliers",
+			modify: func(cfg *config.Config, request *models.BidRequest) {
+				cfg.TimeMultipliers = &config.TimeMultiplierConfig{Rules: []config.TimeMultiplierRule{
+					{StartHour: 0, EndHour: 6, Multiplier: 0.5},
+					{StartHour: 18, EndHour: 22, Multiplier: 1.5},
+				}}
+			},
+			minPrice: 10, maxPrice: 10, low: 5.15, high: 19.5, ok: true,
+		},
+		{
+			name:     "Clamped To Max Bid Price",
+			modify:   func(cfg *config.Config, request *models.BidRequest) { cfg.MaxBidPrice = 12 },
+			minPrice: 10, maxPrice: 10, low: 10.3, high: 12, ok: true,
+		},
+		{name: "Out Of Bounds", minPrice: 150, maxPrice: 200},
+		{name: "Unknown Partner", partnerID: "partner-9", minPrice: 10, maxPrice: 10},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			request := &models.BidRequest{RequestID: "range-test", Vertical: "auto"}
+			if tc.modify != nil {
+				tc.modify(cfg, request)
+			}
+			optimizer, err := utils.NewBidOptimizer(cfg, nil)
+			require.NoError(t, err)
+
+			partnerID := tc.partnerID
+			if partnerID == "" {
+				partnerID = "partner-1"
+			}
+			low, high, ok := optimizer.EffectivePriceRange(partnerID, tc.minPrice, tc.maxPrice, tc.acknowledged, request)
+			require.Equal(t, tc.ok, ok)
+			assert.InDelta(t, tc.low, low, 1e-9)
+			assert.InDelta(t, tc.high, high, 1e-9)
+		})
+	}
+
+	optimizer, err := utils.NewBidOptimizer(newStrategyTestConfig(), nil)
+	require.NoError(t, err)
+	assert.True(t, optimizer.RanksByEffectivePrice("auto"))
+	assert.False(t, optimizer.RanksByEffectivePrice("health"))
+	assert.False(t, optimizer.RanksByEffectivePrice("home"))
+}
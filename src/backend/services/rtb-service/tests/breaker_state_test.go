@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// breakerStateCooldown is the breaker cooldown of the breaker state tests
+const breakerStateCooldown = time.Minute
+
+// newBreakerStateTestConfig returns a config where "flaky" trips its breaker on its first failure
+// and "steady" always bids, saving breaker state to a fresh Redis when withRedis is set
+func newBreakerStateTestConfig(t *testing.T, withRedis bool) (*config.Config, *miniredis.Miniredis, func() int32) {
+	flaky, calls := newNegativeCachePartner(t, http.StatusInternalServerError, "", 0)
+	steady := newPartnerServer(t, models.Bid{ID: "steady-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/steady"})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"flaky":  {ID: "flaky", Endpoint: flaky.URL, APIKey: "key-flaky", Timeout: 200 * time.Millisecond, Enabled: true},
+		"steady": {ID: "steady", Endpoint: steady.URL, APIKey: "key-steady", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2))
+	cfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 1, Cooldown: breakerStateCooldown, StateTTL: 5 * time.Minute}
+	if !withRedis {
+		return cfg, nil, calls.Load
+	}
+	redisServer := miniredis.RunT(t)
+	host, portText, err := net.SplitHostPort(redisServer.Addr())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portText)
+	require.NoError(t, err)
+	cfg.Redis = &config.RedisConfig{Host: host, Port: port, Timeout: time.Second}
+	return cfg, redisServer, calls.Load
+}
+
+// runBreakerStateAuction runs one auction, which the steady partner always wins
+func runBreakerStateAuction(t *testing.T, service *services.AuctionService) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "breaker-" + strconv.FormatInt(time.Now().UnixNano(), 36), LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+}
+
+// TestBreakerStateRestart tests that a partner tripped before a restart stays out of auctions
+// for the rest of its cooldown, and comes back half-open when the cooldown passed during the restart
+func TestBreakerStateRestart(t *testing.T) {
+	testCases := []struct {
+		name             string
+		withRedis        bool
+		downtime         time.Duration
+		expire           bool
+		expectedState    services.BreakerState
+		expectedFailures int
+		expectedCalls    int32 // calls to flaky by the auction after the restart
+	}{
+		{name: "Restart Mid Cooldown", withRedis: true, downtime: 20 * time.Second, expectedState: services.BreakerOpen, expectedFailures: 1},
+		{name: "Cooldown Passed During Restart", withRedis: true, downtime: 2 * breakerStateCooldown, expectedState: services.BreakerHalfOpen, expectedFailures: 1, expectedCalls: 1},
+		{name: "Saved State Expired", withRedis: true, downtime: 20 * time.Second, expire: true, expectedState: services.BreakerClosed, expectedCalls: 1},
+		{name: "Without Redis", downtime: 20 * time.Second, expectedState: services.BreakerClosed, expectedCalls: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, redisServer, flakyCalls := newBreakerStateTestConfig(t, tc.withRedis)
+			clock := &steppingClock{now: time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC)}
+
+			before, err := services.NewAuctionServiceWithClock(cfg, clock)
+			require.NoError(t, err)
+			runBreakerStateAuction(t, before)
+			require.Equal(t, services.BreakerOpen, before.PartnerBreakerState("flaky"))
+			require.NoError(t, before.Close())
+
+			clock.now = clock.now.Add(tc.downtime)
+			if tc.expire {
+				redisServer.FastForward(10 * time.Minute)
+			}
+			after, err := services.NewAuctionServiceWithClock(cfg, clock)
+			require.NoError(t, err)
+			defer after.Close()
+
+			assert.Equal(t, tc.expectedState, after.PartnerBreakerState("flaky"))
+			assert.Equal(t, services.BreakerClosed, after.PartnerBreakerState("steady"))
+			for _, status := range after.PartnerStatuses() {
+				if status.ID == "flaky" {
+					assert.Equal(t, tc.expectedFailures, status.Failures)
+				}
+			}
+
+			callsBefore := flakyCalls()
+			runBreakerStateAuction(t, after)
+			assert.Equal(t, tc.expectedCalls, flakyCalls()-callsBefore)
+		})
+	}
+}
+
+// TestBreakerStateSavedOnTransition tests that a breaker opening is saved without waiting for
+// the periodic save or shutdown
+func TestBreakerStateSavedOnTransition(t *testing.T) {
+	cfg, redisServer, _ := newBreakerStateTestConfig(t, true)
+	cfg.CircuitBreaker.PersistInterval = time.Hour
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	runBreakerStateAuction(t, service)
+	require.Eventually(t, func() bool {
+		saved, err := redisServer.Get("rtb:breaker:flaky")
+		return err == nil && strings.Contains(saved, `"state":"open"`)
+	}, 2*time.Second, 5*time.Millisecond)
+	assert.Greater(t, redisServer.TTL("rtb:breaker:flaky"), breakerStateCooldown)
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// canaryHealth is the part of a health report the canary tests check
+type canaryHealth struct {
+	Canary *handlers.CanaryResult `json:"canary"`
+}
+
+// startCanary serves health for cfg and runs its canary until the test ends
+func startCanary(t *testing.T, cfg *config.Config) (*gin.Engine, *services.AuctionService) {
+	service := newTestService(t, cfg)
+
+	bidHandler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	canary, err := handlers.NewCanary(service, cfg)
+	require.NoError(t, err)
+	bidHandler.SetCanary(canary)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.NoError(t, canary.Run(ctx))
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+
+	router := gin.New()
+	router.GET("/health", bidHandler.HandleHealthCheck)
+	return router, service
+}
+
+// lastCanary waits for the first canary result and returns it as /health reports it
+func lastCanary(t *testing.T, router *gin.Engine) *handlers.CanaryResult {
+	var health canaryHealth
+	require.Eventually(t, func() bool {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+		health = canaryHealth{}
+		return json.Unmarshal(w.Body.Bytes(), &health) == nil && health.Canary != nil
+	}, 2*time.Second, 20*time.Millisecond)
+	return health.Canary
+}
+
+// TestCanarySimulatedPartners tests that simulated canaries run without touching the configured partners
+func TestCanarySimulatedPartners(t *testing.T) {
+	partner := newRecordingPartner(t, models.Bid{ID: "real-bid", Price: 8.0, QualityScore: 0.5, ClickURL: "http://example.com/real"})
+	cfg := newStrategyTestConfig()
+	cfg.MaxBidsPerRequest = 3
+	cfg.Partners["partner-1"].Endpoint = partner.server.URL
+	cfg.Partners["partner-1"].Canary = true
+	cfg.Canary = &config.CanaryConfig{Enabled: true, Interval: time.Minute}
+
+	router, _ := startCanary(t, cfg)
+	result := lastCanary(t, router)
+	assert.Equal(t, "success", result.Result)
+	assert.Equal(t, config.CanaryPartnersSimulated, result.Partners)
+	assert.Equal(t, 3, result.Winners)
+	assert.Positive(t, result.Latency)
+	assert.Empty(t, partner.received())
+}
+
+// TestCanaryRealPartners tests that real canaries only reach opted-in partners and leave their
+// stats, breakers, and budgets alone
+func TestCanaryRealPartners(t *testing.T) {
+	optedIn := newRecordingPartner(t, models.Bid{ID: "canary-bid", Price: 8.0, QualityScore: 0.5, ClickURL: "http://example.com/canary"})
+	other := newRecordingPartner(t, models.Bid{ID: "other-bid", Price: 9.0, QualityScore: 0.5, ClickURL: "http://example.com/other"})
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(failing.Close)
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"opted-in": {ID: "opted-in", Endpoint: optedIn.server.URL, APIKey: "key-opted-in", Timeout: 200 * time.Millisecond, Enabled: true,
+			Canary: true, MaxBid: 10.0, DailyBudget: 20.0},
+		"failing": {ID: "failing", Endpoint: failing.URL, APIKey: "key-failing", Timeout: 200 * time.Millisecond, Enabled: true, Canary: true},
+		"other":   {ID: "other", Endpoint: other.server.URL, APIKey: "key-other", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(3))
+	cfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Minute}
+	cfg.Canary = &config.CanaryConfig{Enabled: true, Partners: config.CanaryPartnersReal, Vertical: "auto"}
+
+	router, service := startCanary(t, cfg)
+	result := lastCanary(t, router)
+	assert.Equal(t, "success", result.Result)
+	assert.Equal(t, config.CanaryPartnersReal, result.Partners)
+	assert.Equal(t, 1, result.Winners)
+
+	require.Len(t, optedIn.received(), 1)
+	assert.Equal(t, "auto", optedIn.received()[0].Vertical)
+	assert.Empty(t, other.received())
+
+	// The failing partner neither counts a failure nor trips its breaker
+	for _, status := range service.PartnerStatuses() {
+		assert.Zero(t, status.Failures, status.ID)
+		assert.Equal(t, services.BreakerClosed, status.BreakerState, status.ID)
+	}
+	budget, err := service.PartnerBudget(context.Background(), "opted-in")
+	require.NoError(t, err)
+	assert.Zero(t, budget.Spent)
+}
+
+// TestCanaryValidation tests canary interval and partner mode bounds
+func TestCanaryValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		canary      *config.CanaryConfig
+		optIn       bool
+		expectedErr string
+	}{
+		{name: "Defaults", canary: &config.CanaryConfig{Enabled: true}},
+		{name: "Disabled With Bad Interval", canary: &config.CanaryConfig{Interval: time.Millisecond}},
+		{name: "Interval Too Short", canary: &config.CanaryConfig{Enabled: true, Interval: time.Second}, expectedErr: "canary interval"},
+		{name: "Interval Too Long", canary: &config.CanaryConfig{Enabled: true, Interval: 2 * time.Hour}, expectedErr: "canary interval"},
+		{name: "Unknown Mode", canary: &config.CanaryConfig{Enabled: true, Partners: "shadow"}, expectedErr: "unknown canary partner mode"},
+		{name: "Real Without Opt In", canary: &config.CanaryConfig{Enabled: true, Partners: config.CanaryPartnersReal}, expectedErr: "no enabled partner has canary set"},
+		{name: "Real With Opt In", canary: &config.CanaryConfig{Enabled: true, Partners: config.CanaryPartnersReal}, optIn: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Canary = tc.canary
+			cfg.Partners["partner-1"].Canary = tc.optIn
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,283 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// echoPartner bids while echoing every request header back in its bid's creative and the
+// Authorization header in a response header, the way a misbehaving partner might
+type echoPartner struct {
+	server   *httptest.Server
+	mutex    sync.Mutex
+	received []http.Header
+}
+
+// newEchoPartner starts an echo partner
+func newEchoPartner(t *testing.T) *echoPartner {
+	partner := &echoPartner{}
+	partner.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		partner.mutex.Lock()
+		partner.received = append(partner.received, r.Header.Clone())
+		partner.mutex.Unlock()
+
+		headers := make(map[string]interface{}, len(r.Header))
+		for name, values := range r.Header {
+			headers[name] = strings.Join(values, ",")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Echo-Authorization", r.Header.Get("Authorization"))
+		w.Header().Set("Set-Cookie", "session="+r.Header.Get("Authorization"))
+		json.NewEncoder(w).Encode(models.Bid{ID: "echo-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/echo", Creative: headers})
+	}))
+	t.Cleanup(partner.server.Close)
+	return partner
+}
+
+// receivedValues returns every header value the partner received
+func (p *echoPartner) receivedValues() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	var values []string
+	for _, headers := range p.received {
+		for _, headerValues := range headers {
+			values = append(values, headerValues...)
+		}
+	}
+	return values
+}
+
+// newCaptureTestConfig returns a config with one echo partner named "echo"
+func newCaptureTestConfig(partner *echoPartner, apiKey string, auth *config.PartnerAuth) *config.Config {
+	return newTestConfig(map[string]*config.PartnerConfig{
+		"echo": {ID: "echo", Endpoint: partner.server.URL, APIKey: apiKey, Auth: auth, Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withAdmin())
+}
+
+// runCaptureTestAuction runs an auction with a request ID
+func runCaptureTestAuction(t *testing.T, service *services.AuctionService, requestID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err := service.RunAuction(ctx, &models.BidRequest{RequestID: requestID, LeadID: "lead-capture", Vertical: "auto"})
+	require.NoError(t, err)
+}
+
+// TestCaptureRedaction tests that no credential reaches a capture under any auth scheme, whether
+// sent in a header, derived per request like a signature, or echoed back by the partner
+func TestCaptureRedaction(t *testing.T) {
+	testCases := []struct {
+		name             string
+		apiKey           string
+		auth             *config.PartnerAuth
+		secrets          []string
+		credentialHeader string
+	}{
+		{name: "Bearer API Key", apiKey: "bearer-api-key-8f3a", secrets: []string{"bearer-api-key-8f3a"}, credentialHeader: "Authorization"},
+		{
+			name:             "Custom Header Token",
+			apiKey:           "unused-api-key-1c9d",
+			auth:             &config.PartnerAuth{Type: config.AuthHeader, HeaderName: "X-Partner-Token", Token: "header-token-7b2e"},
+			secrets:          []string{"header-token-7b2e", "unused-api-key-1c9d"},
+			credentialHeader: "X-Partner-Token",
+		},
+		{
+			name:             "Basic Auth",
+			auth:             &config.PartnerAuth{Type: config.AuthBasic, Username: "rtb", Password: "basic-password-4d61"},
+			secrets:          []string{"basic-password-4d61"},
+			credentialHeader: "Authorization",
+		},
+		{
+			name:             "HMAC Signature",
+			auth:             &config.PartnerAuth{Type: config.AuthHMAC, SigningKey: "signing-key-93ce"},
+			secrets:          []string{"signing-key-93ce"},
+			credentialHeader: "X-Signature",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partner := newEchoPartner(t)
+			service, err := services.NewAuctionService(newCaptureTestConfig(partner, tc.apiKey, tc.auth))
+			require.NoError(t, err)
+			defer service.Close()
+
+			_, err = service.StartCapture("echo", 1, time.Minute, "ops")
+			require.NoError(t, err)
+			runCaptureTestAuction(t, service, "capture-redaction")
+
+			_, captures, err := service.Captures("echo")
+			require.NoError(t, err)
+			require.Len(t, captures, 1)
+			capture := captures[0]
+			require.NotNil(t, capture.Response)
+			assert.Equal(t, "capture-redaction", capture.RequestID)
+			assert.Equal(t, http.StatusOK, capture.Response.Status)
+			assert.Contains(t, capture.Request.Body, "lead-capture")
+			assert.Contains(t, capture.Response.Body, "echo-bid")
+			assert.Equal(t, []string{"[REDACTED]"}, capture.Request.Headers[tc.credentialHeader])
+			assert.Equal(t, []string{"[REDACTED]"}, capture.Response.Headers["Set-Cookie"])
+
+			encoded, err := json.Marshal(captures)
+			require.NoError(t, err)
+			for _, secret := range tc.secrets {
+				assert.NotContains(t, string(encoded), secret)
+			}
+			// The credential as sent, including a per-request signature, appears nowhere either
+			partner.mutex.Lock()
+			sent := partner.received[0].Get(tc.credentialHeader)
+			partner.mutex.Unlock()
+			require.NotEmpty(t, sent)
+			assert.NotContains(t, string(encoded), sent)
+		})
+	}
+}
+
+// newCaptureTestRouter serves auctions and the admin endpoints for an echo partner, with
+// captures limited to two entries and 64-byte bodies and expiring against clock
+func newCaptureTestRouter(t *testing.T, clock *steppingClock) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg := newCaptureTestConfig(newEchoPartner(t), "key-echo", nil)
+	cfg.Capture = &config.CaptureConfig{MaxEntries: 2, MaxBodyBytes: 64}
+	service := newTestServiceWithClock(t, cfg, clock)
+	bidHandler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	adminHandler, err := handlers.NewAdminHandler(service, cfg, handlers.BuildInfo{})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.POST("/v1/bids", bidHandler.HandleBidRequest)
+	adminHandler.RegisterRoutes(router.Group("/admin"))
+	return router
+}
+
+// captureTestListing is the body of GET /admin/partners/:id/captures
+type captureTestListing struct {
+	Capture  *services.CaptureSession  `json:"capture"`
+	Captures []services.PartnerCapture `json:"captures"`
+}
+
+// listCaptures returns the echo partner's capture session and captures
+func listCaptures(t *testing.T, router *gin.Engine) captureTestListing {
+	w := serveOverrideTest(router, http.MethodGet, "/admin/partners/echo/captures", "", map[string]string{"X-Admin-Key": testAdminKey})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var listing captureTestListing
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listing))
+	return listing
+}
+
+// captureRequestIDs returns the request IDs of captures, newest first
+func captureRequestIDs(captures []services.PartnerCapture) []string {
+	ids := make([]string, len(captures))
+	for i, capture := range captures {
+		ids[i] = capture.RequestID
+	}
+	return ids
+}
+
+// TestCaptureLifecycle tests that capture is off by default, keeps a bounded number of size-capped
+// captures while on, stops on expiry with its captures still readable, and clears them when stopped
+func TestCaptureLifecycle(t *testing.T) {
+	clock := &steppingClock{now: time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC)}
+	router := newCaptureTestRouter(t, clock)
+	admin := map[string]string{"X-Admin-Key": testAdminKey}
+	auction := func(requestID string) {
+		w := serveOverrideTest(router, http.MethodPost, "/v1/bids", `{"lead_id": "lead-capture", "vertical": "auto"}`, map[string]string{"X-Request-ID": requestID})
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	}
+
+	auction("before-capture")
+	listing := listCaptures(t, router)
+	assert.Nil(t, listing.Capture)
+	assert.Empty(t, listing.Captures)
+
+	w := serveOverrideTest(router, http.MethodPost, "/admin/partners/echo/capture", `{"duration": "10m", "requested_by": "ops"}`, admin)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	for _, requestID := range []string{"capture-1", "capture-2", "capture-3"} {
+		auction(requestID)
+	}
+	listing = listCaptures(t, router)
+	require.NotNil(t, listing.Capture)
+	assert.Equal(t, 1.0, listing.Capture.SampleRate)
+	assert.Equal(t, clock.now.Add(10*time.Minute), listing.Capture.ExpiresAt)
+	assert.Equal(t, []string{"capture-3", "capture-2"}, captureRequestIDs(listing.Captures))
+	request := listing.Captures[0].Request
+	assert.Equal(t, http.MethodPost, request.Method)
+	assert.True(t, request.BodyTruncated)
+	assert.Len(t, request.Body, 64)
+	assert.Greater(t, request.BodyBytes, 64)
+
+	clock.now = clock.now.Add(11 * time.Minute)
+	auction("after-expiry")
+	listing = listCaptures(t, router)
+	assert.Nil(t, listing.Capture)
+	assert.Equal(t, []string{"capture-3", "capture-2"}, captureRequestIDs(listing.Captures))
+
+	w = serveOverrideTest(router, http.MethodDelete, "/admin/partners/echo/capture", "", admin)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Empty(t, listCaptures(t, router).Captures)
+}
+
+// TestStartCaptureValidation tests that captures need a known partner, a bounded duration, a
+// sample rate in (0, 1], and a requester
+func TestStartCaptureValidation(t *testing.T) {
+	testCases := []struct {
+		name           string
+		partner        string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Valid", partner: "echo", body: `{"duration": "15m", "sample_rate": 0.1, "requested_by": "ops"}`, expectedStatus: http.StatusCreated},
+		{name: "Unknown Partner", partner: "nobody", body: `{"duration": "15m", "requested_by": "ops"}`, expectedStatus: http.StatusNotFound},
+		{name: "Duration Above Maximum", partner: "echo", body: `{"duration": "2h", "requested_by": "ops"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Zero Duration", partner: "echo", body: `{"duration": "0s", "requested_by": "ops"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Malformed Duration", partner: "echo", body: `{"duration": "soon", "requested_by": "ops"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Zero Sample Rate", partner: "echo", body: `{"duration": "15m", "sample_rate": 0, "requested_by": "ops"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Sample Rate Above One", partner: "echo", body: `{"duration": "15m", "sample_rate": 1.5, "requested_by": "ops"}`, expectedStatus: http.StatusBadRequest},
+		{name: "Missing Requester", partner: "echo", body: `{"duration": "15m"}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := newCaptureTestRouter(t, &steppingClock{now: time.Now()})
+			w := serveOverrideTest(router, http.MethodPost, fmt.Sprintf("/admin/partners/%s/capture", tc.partner), tc.body,
+				map[string]string{"X-Admin-Key": testAdminKey})
+			assert.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+		})
+	}
+}
+
+// TestCaptureSampling tests that a capture keeps about its sample rate of a partner's calls
+func TestCaptureSampling(t *testing.T) {
+	partner := newEchoPartner(t)
+	cfg := newCaptureTestConfig(partner, "key-echo", nil)
+	cfg.Capture = &config.CaptureConfig{MaxEntries: 1000}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	_, err = service.StartCapture("echo", 0.25, time.Minute, "ops")
+	require.NoError(t, err)
+	const calls = 400
+	for i := 0; i < calls; i++ {
+		runCaptureTestAuction(t, service, fmt.Sprintf("sampled-%d", i))
+	}
+	_, captures, err := service.Captures("echo")
+	require.NoError(t, err)
+	assert.InDelta(t, 0.25, float64(len(captures))/calls, 0.07)
+}
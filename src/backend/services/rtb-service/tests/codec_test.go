@@ -0,0 +1,262 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// codecNames lists every selectable codec
+var codecNames = []string{config.JSONCodecStd, config.JSONCodecFast}
+
+// newCodecTestRequest builds a bid request covering every field type, including the
+// nanosecond timeout and free-form user data
+func newCodecTestRequest(text string, number float64, nanos int64) *models.BidRequest {
+	consentTime, previouslySold := time.Unix(0, nanos).UTC(), true
+	return &models.BidRequest{
+		RequestID: text,
+		LeadID:    "lead-" + text,
+		Vertical:  "auto",
+		UserData:  map[string]interface{}{"name": text, "score": number, "tags": []interface{}{text, number, true, nil}},
+		Timeout:   time.Duration(nanos),
+		Timestamp: time.Unix(0, nanos).UTC(),
+		Consent:   &models.Consent{GDPRApplies: true, ConsentString: text},
+		Geo:       &models.Geo{Country: "US", Zip: text},
+		Device:    &models.Device{Type: "mobile", OS: text},
+		LeadQuality: &models.LeadQuality{ConsentTimestamp: &consentTime, SessionDurationMs: &nanos, PreviouslySold: &previouslySold,
+			Score: &number},
+		Constraints: []models.RankingConstraint{{Type: models.ConstraintMaxPriceAtLeastOne, MaxPrice: number}, {Type: text, Group: text, Max: 1}},
+	}
+}
+
+// newCodecTestResponse builds a bid response covering every field type, including the
+// processing time, debug output, and dry run side effects
+func newCodecTestResponse(text string, number float64, nanos int64) *models.BidResponse {
+	debug := models.NewDebugInfo()
+	debug.RecordSkip("partner-1", models.Reason(text))
+	debug.RecordBids("partner-2", 2)
+	debug.RecordMultipliers("partner-2", map[string]float64{"vertical": number})
+	dryRun := models.NewDryRun()
+	dryRun.Record(models.SideEffect{Type: "webhook", PartnerID: "partner-2", Detail: map[string]interface{}{"event": text}})
+
+	return &models.BidResponse{
+		RequestID: text,
+		Bids: []*models.Bid{
+			{ID: text, PartnerID: "partner-2", Price: number, ClickURL: "http://example.com/?q=" + text, QualityScore: 0.5,
+				ExpiresAt: time.Unix(0, nanos).UTC(), Creative: map[string]interface{}{"html": "<b>" + text + "</b>", "width": number},
+				AdvertiserDomains: []string{text}, PricingModel: config.PricingModelRevShare, NormalizedPrice: number * 4,
+				QualityAcknowledged: true, PayloadExperiment: &models.ExperimentAssignment{ID: text, Variant: config.ExperimentVariantTreatment}},
+			{ID: "bid-2", PartnerID: "partner-1", Price: 1.5, ClickURL: "http://example.com/2"},
+		},
+		Timestamp:      time.Unix(0, nanos),
+		ProcessingTime: time.Duration(nanos),
+		CollectionTime: time.Duration(nanos / 2),
+		Summary: &models.AuctionSummary{PartnersContacted: int(nanos), PartnersBid: 2, ValidBids: 2, Winners: 2, TimedOutPartners: int(nanos % 3),
+			EarlyTerminatedPartners: 1, UnsatisfiedConstraints: []models.RankingConstraint{{Type: models.ConstraintRequirePartnerGroup, Group: text}}},
+		Debug:      debug,
+		DryRun:     dryRun,
+		Reason:     models.Reason(text),
+		HouseOffer: true,
+		Locale:     text,
+		AuctionSeq: &models.AuctionSeq{Seq: nanos, ULID: text},
+	}
+}
+
+// FuzzCodecRoundTrip tests that every codec encodes bid requests and responses to the same bytes
+// as encoding/json and decodes encoding/json output to the same values
+func FuzzCodecRoundTrip(f *testing.F) {
+	f.Add("req-1", 12.5, int64(250*time.Millisecond))
+	f.Add("<script>&  </script>", 1e21, int64(-1))
+	f.Add("caf\xe9 \x00 \"quoted\" \\ \t", 1e-7, int64(1<<62))
+	f.Add("", 0.0, int64(0))
+	f.Add("😀 emoji", -3.25, time.Date(2026, 10, 15, 12, 0, 0, 123456789, time.UTC).UnixNano())
+
+	reference, err := models.NewCodec(config.JSONCodecStd)
+	require.NoError(f, err)
+
+	f.Fuzz(func(t *testing.T, text string, number float64, nanos int64) {
+		values := []interface{}{newCodecTestRequest(text, number, nanos), newCodecTestResponse(text, number, nanos)}
+		for _, name := range codecNames {
+			codec, err := models.NewCodec(name)
+			require.NoError(t, err)
+
+			for _, value := range values {
+				expected, expectedErr := reference.Marshal(value)
+				encoded, err := codec.Marshal(value)
+				if expectedErr != nil {
+					// Values encoding/json rejects, such as NaN, must be rejected too
+					assert.Error(t, err, name)
+					continue
+				}
+				require.NoError(t, err, name)
+				assert.Equal(t, string(expected), string(encoded), name)
+
+				var streamed bytes.Buffer
+				require.NoError(t, codec.Encode(&streamed, value), name)
+				assert.Equal(t, string(expected)+"\n", streamed.String(), name)
+
+				// Decoding the reference bytes must give back what encoding/json decodes
+				assertSameDecoding(t, reference, codec, expected, value)
+			}
+		}
+	})
+}
+
+// assertSameDecoding decodes data with the reference codec and codec into fresh values of
+// template's type and compares their reference encodings
+func assertSameDecoding(t *testing.T, reference, codec models.Codec, data []byte, template interface{}) {
+	newValue := func() interface{} {
+		switch template.(type) {
+		case *models.BidRequest:
+			return &models.BidRequest{}
+		default:
+			return &models.BidResponse{}
+		}
+	}
+
+	expected, decoded := newValue(), newValue()
+	require.NoError(t, reference.Unmarshal(data, expected))
+	require.NoError(t, codec.Unmarshal(data, decoded), codec.Name())
+	expectedBytes, err := reference.Marshal(expected)
+	require.NoError(t, err)
+	decodedBytes, err := reference.Marshal(decoded)
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedBytes), string(decodedBytes), codec.Name())
+
+	streamed := newValue()
+	require.NoError(t, codec.Decode(bytes.NewReader(data), streamed), codec.Name())
+	streamedBytes, err := reference.Marshal(streamed)
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedBytes), string(streamedBytes), codec.Name())
+}
+
+// TestCodecSelection tests codec names in config validation and codec construction
+func TestCodecSelection(t *testing.T) {
+	testCases := []struct {
+		name         string
+		codec        string
+		expectedName string
+		expectedErr  string
+	}{
+		{name: "Default", codec: "", expectedName: config.JSONCodecStd},
+		{name: "Standard Library", codec: config.JSONCodecStd, expectedName: config.JSONCodecStd},
+		{name: "Fast", codec: config.JSONCodecFast, expectedName: config.JSONCodecFast},
+		{name: "Unknown", codec: "sonic", expectedErr: `unknown json codec "sonic"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.JSONCodec = tc.codec
+
+			codec, err := models.NewCodec(tc.codec)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				assert.ErrorContains(t, cfg.Validate(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedName, codec.Name())
+			assert.NoError(t, cfg.Validate())
+		})
+	}
+}
+
+// newCodecTestRouter serves the bid endpoint for an auction against one partner using the named codec
+func newCodecTestRouter(t *testing.T, codec string) *gin.Engine {
+	partner := newPartnerServer(t, models.Bid{ID: "codec-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/<codec>"})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.JSONCodec = codec
+	service := newTestService(t, cfg)
+	router, _ := newTestRouter(t, service, cfg)
+	return router
+}
+
+// TestHandlerCodec tests that the bid endpoint parses requests and encodes responses the same
+// way with every codec
+func TestHandlerCodec(t *testing.T) {
+	testCases := []struct {
+		name           string
+		body           string
+		expectedStatus int
+		expectedBid    string
+	}{
+		{name: "Valid Request", body: `{"request_id": "codec-1", "lead_id": "lead-1", "vertical": "auto", "timeout": 250000000}`, expectedStatus: http.StatusOK, expectedBid: "codec-bid"},
+		{name: "Malformed Body", body: `{"request_id": `, expectedStatus: http.StatusBadRequest},
+		{name: "Wrong Field Type", body: `{"request_id": 42}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		for _, codec := range codecNames {
+			t.Run(tc.name+"/"+codec, func(t *testing.T) {
+				router := newCodecTestRouter(t, codec)
+				req := httptest.NewRequest(http.MethodPost, "/v1/bids", bytes.NewBufferString(tc.body))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+				if tc.expectedBid == "" {
+					return
+				}
+				assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+				assert.NotContains(t, w.Body.String(), "\n")
+				assert.Contains(t, w.Body.String(), `\u003ccodec\u003e`)
+
+				var response models.BidResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				require.Len(t, response.Bids, 1)
+				assert.Equal(t, tc.expectedBid, response.Bids[0].ID)
+				assert.Equal(t, "codec-1", response.RequestID)
+			})
+		}
+	}
+}
+
+// BenchmarkCodec measures encoding a bid response and decoding a bid request with each codec
+func BenchmarkCodec(b *testing.B) {
+	nanos := int64(250 * time.Millisecond)
+	response := newCodecTestResponse("bench", 12.5, nanos)
+	response.Debug, response.DryRun = nil, nil
+	request, err := json.Marshal(newCodecTestRequest("bench", 12.5, nanos))
+	require.NoError(b, err)
+
+	for _, name := range codecNames {
+		codec, err := models.NewCodec(name)
+		require.NoError(b, err)
+
+		b.Run("Encode Response/"+name, func(b *testing.B) {
+			var buffer bytes.Buffer
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buffer.Reset()
+				if err := codec.Encode(&buffer, response); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+
+		b.Run("Decode Request/"+name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				var decoded models.BidRequest
+				if err := codec.Unmarshal(request, &decoded); err != nil {
+					b.Fatal(errors.Join(err, errors.New(name)))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// gzipBytes compresses data
+func gzipBytes(t *testing.T, data []byte) []byte {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	_, err := writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+// newCompressionTestRouter echoes the decoded JSON request body and serves a small event stream
+func newCompressionTestRouter(cfg *config.CompressionConfig) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(handlers.CompressionMiddleware(cfg))
+	router.POST("/echo", func(c *gin.Context) {
+		var body map[string]interface{}
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
+			return
+		}
+		c.JSON(http.StatusOK, body)
+	})
+	router.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		c.SSEvent("bid", gin.H{"id": "bid-1"})
+	})
+	return router
+}
+
+// TestInboundGzip tests decompression of gzip request bodies and compression of responses
+func TestInboundGzip(t *testing.T) {
+	router := newCompressionTestRouter(&config.CompressionConfig{
+		DecompressRequests:   true,
+		CompressResponses:    true,
+		MaxDecompressedBytes: 64 << 10,
+	})
+	payload := []byte(`{"lead_id":"lead-1","vertical":"auto"}`)
+
+	t.Run("Compressed Request And Response", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(gzipBytes(t, payload)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+		req.Header.Set("Accept-Encoding", "br, gzip;q=0.8")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+		reader, err := gzip.NewReader(recorder.Body)
+		require.NoError(t, err)
+		decoded, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		assert.JSONEq(t, string(payload), string(decoded))
+	})
+
+	t.Run("Client Without Gzip", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(payload))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Encoding", "gzip;q=0")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		require.Equal(t, http.StatusOK, recorder.Code)
+		assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+		assert.JSONEq(t, string(payload), recorder.Body.String())
+	})
+
+	t.Run("Decompression Bomb", func(t *testing.T) {
+		bomb := gzipBytes(t, bytes.Repeat([]byte("0"), 1<<20))
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(bomb))
+		req.Header.Set("Content-Encoding", "gzip")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+	})
+
+	t.Run("Malformed Gzip", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodPost, "/echo", bytes.NewReader(payload))
+		req.Header.Set("Content-Encoding", "gzip")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("Event Streams Uncompressed", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, req)
+
+		assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+		assert.Contains(t, recorder.Body.String(), "event:bid")
+	})
+}
+
+// TestPartnerGzip tests that only opted-in partners are offered gzip and that their responses are decoded
+func TestPartnerGzip(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		acceptEncoding := make(chan string, 1)
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			acceptEncoding <- r.Header.Get("Accept-Encoding")
+			body, _ := json.Marshal(models.Bid{ID: "bid-gzip", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/gzip"})
+			if r.Header.Get("Accept-Encoding") == "gzip" {
+				w.Header().Set("Content-Encoding", "gzip")
+				body = gzipBytes(t, body)
+			}
+			w.Write(body)
+		}))
+
+		service, err := services.NewAuctionService(newTestConfig(map[string]*config.PartnerConfig{
+			"partner-a": {ID: "partner-a", Endpoint: server.URL, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true, Gzip: enabled},
+		}))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "gzip-test", LeadID: "lead-1", Vertical: "auto"})
+		cancel()
+		server.Close()
+
+		require.NoError(t, err)
+		assert.Equal(t, "bid-gzip", response.Bids[0].ID)
+		if enabled {
+			assert.Equal(t, "gzip", <-acceptEncoding)
+		} else {
+			assert.Empty(t, <-acceptEncoding)
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// TestConfigDiff tests that config diffs name added, removed, and changed settings by path and
+// never show secrets
+func TestConfigDiff(t *testing.T) {
+	testCases := []struct {
+		name     string
+		modify   func(c *config.Config)
+		expected []config.Change
+	}{
+		{name: "Unchanged", modify: func(c *config.Config) {}},
+		{
+			name: "Partner Added",
+			modify: func(c *config.Config) {
+				c.Partners["partner-3"] = &config.PartnerConfig{ID: "partner-3", Endpoint: "http://partner-3", Enabled: true}
+			},
+			expected: []config.Change{{Path: "partners.partner-3", Kind: config.ChangeAdded}},
+		},
+		{
+			name:     "Partner Removed",
+			modify:   func(c *config.Config) { delete(c.Partners, "partner-2") },
+			expected: []config.Change{{Path: "partners.partner-2", Kind: config.ChangeRemoved}},
+		},
+		{
+			name: "Timeout And Floor Changed",
+			modify: func(c *config.Config) {
+				c.Partners["partner-1"].Timeout = 300 * time.Millisecond
+				c.MinBidPrice = 0.5
+			},
+			expected: []config.Change{
+				{Path: "minBidPrice", Kind: config.ChangeChanged, Old: 0.01, New: 0.5},
+				{Path: "partners.partner-1.timeout", Kind: config.ChangeChanged, Old: "200ms", New: "300ms"},
+			},
+		},
+		{
+			name:     "Redis Password Redacted",
+			modify:   func(c *config.Config) { c.Redis.Password = "rotated" },
+			expected: []config.Change{{Path: "redis.password", Kind: config.ChangeChanged, Old: "[redacted]", New: "[redacted]"}},
+		},
+		{name: "API Key Left Out", modify: func(c *config.Config) { c.Partners["partner-1"].APIKey = "rotated" }},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			previous, current := newStrategyTestConfig(), newStrategyTestConfig()
+			previous.Redis = &config.RedisConfig{Host: "localhost", Port: 6379, Password: "original"}
+			current.Redis = &config.RedisConfig{Host: "localhost", Port: 6379, Password: "original"}
+			tc.modify(current)
+
+			assert.Equal(t, tc.expected, config.Diff(previous, current))
+			assert.Equal(t, len(tc.expected) == 0, previous.Hash() == current.Hash())
+		})
+	}
+}
+
+// TestRecordConfigReload tests that reloads that change the config are recorded in the history,
+// sent as config.changed events, and that auction events and health carry the new config hash
+func TestRecordConfigReload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	receiver, webhookServer := newWebhookReceiver(t, http.StatusOK)
+	partner := newPartnerServer(t, models.Bid{ID: "bid-1", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	webhookConfig := newWebhookTestConfig(t, config.WebhookEndpoint{URL: webhookServer.URL})
+	newConfig := func() *config.Config {
+		cfg := newTestConfig(map[string]*config.PartnerConfig{
+			"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+		}, withAdmin())
+		cfg.Webhooks = webhookConfig
+		return cfg
+	}
+	cfg := newConfig()
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	startHash := service.ConfigHash()
+	require.NotEmpty(t, startHash)
+
+	// An unchanged reload records nothing
+	assert.Nil(t, service.RecordConfigReload(newConfig(), services.ConfigSourceFile))
+	assert.Empty(t, service.ConfigHistory())
+
+	reloaded := newConfig()
+	reloaded.Partners["partner-1"].Timeout = 250 * time.Millisecond
+	change := service.RecordConfigReload(reloaded, services.ConfigSourceFile)
+	require.NotNil(t, change)
+	assert.Equal(t, startHash, change.PreviousHash)
+	assert.Equal(t, reloaded.Hash(), change.Hash)
+	assert.Equal(t, reloaded.Hash(), service.ConfigHash())
+	assert.Equal(t, []config.Change{{Path: "partners.partner-1.timeout", Kind: config.ChangeChanged, Old: "200ms", New: "250ms"}}, change.Changes)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = service.RunAuction(ctx, &models.BidRequest{RequestID: "config-1", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+
+	// config.changed, then bid.won and auction.completed, all carrying the new hash
+	require.Eventually(t, func() bool { return len(receiver.received()) == 3 }, time.Second, 5*time.Millisecond)
+	types := make(map[string]bool)
+	for _, delivery := range receiver.received() {
+		types[delivery.event.Type] = true
+		assert.Equal(t, change.Hash, delivery.event.ConfigHash, delivery.event.Type)
+		if delivery.event.Type == config.WebhookEventConfigChanged {
+			var changed struct {
+				Data services.ConfigChange `json:"data"`
+			}
+			require.NoError(t, json.Unmarshal(delivery.body, &changed))
+			assert.Equal(t, services.ConfigSourceFile, changed.Data.Source)
+			require.Len(t, changed.Data.Changes, 1)
+			assert.Equal(t, "partners.partner-1.timeout", changed.Data.Changes[0].Path)
+		}
+	}
+	assert.True(t, types[config.WebhookEventConfigChanged])
+
+	bidHandler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	adminHandler, err := handlers.NewAdminHandler(service, cfg, handlers.BuildInfo{})
+	require.NoError(t, err)
+	router := gin.New()
+	router.GET("/health", bidHandler.HandleHealthCheck)
+	adminHandler.RegisterRoutes(router.Group("/admin"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Contains(t, w.Body.String(), `"config_hash":"`+change.Hash+`"`)
+
+	// A second change is listed first
+	reloaded = newConfig()
+	reloaded.MinBidPrice = 1.0
+	require.NotNil(t, service.RecordConfigReload(reloaded, services.ConfigSourceFile))
+	w = serveOverrideTest(router, http.MethodGet, "/admin/config/history", "", map[string]string{"X-Admin-Key": testAdminKey})
+	require.Equal(t, http.StatusOK, w.Code)
+	var history struct {
+		ConfigHash string                  `json:"config_hash"`
+		Changes    []services.ConfigChange `json:"changes"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	assert.Equal(t, reloaded.Hash(), history.ConfigHash)
+	require.Len(t, history.Changes, 2)
+	assert.Equal(t, change.Hash, history.Changes[0].PreviousHash)
+	assert.Equal(t, startHash, history.Changes[1].PreviousHash)
+	assert.Len(t, history.Changes[0].Changes, 2)
+}
@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// lintResult is the part of a lint finding the lint tests compare
+type lintResult struct {
+	severity, code, path string
+}
+
+// TestConfigLint tests that Lint reports validation failures and settings that load but cannot
+// take effect, errors first
+func TestConfigLint(t *testing.T) {
+	now := time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		modify   func(*config.Config)
+		expected []lintResult
+	}{
+		{name: "Clean", modify: func(c *config.Config) {}},
+		{
+			name:     "Invalid",
+			modify:   func(c *config.Config) { c.Port = 80 },
+			expected: []lintResult{{config.LintError, config.LintInvalidConfig, ""}},
+		},
+		{
+			name: "Priority Tiers",
+			modify: func(c *config.Config) {
+				c.Partners["partner-1"].Priority = 1
+				c.Partners["partner-2"].Priority = 2
+			},
+			expected: []lintResult{
+				{config.LintWarning, config.LintUnusedPriority, "partners.partner-1.priority"},
+				{config.LintWarning, config.LintUnusedPriority, "partners.partner-2.priority"},
+			},
+		},
+		{
+			name: "Single Priority Tier",
+			modify: func(c *config.Config) {
+				c.Partners["partner-1"].Priority = 1
+				c.Partners["partner-2"].Priority = 1
+			},
+		},
+		{
+			name: "Disabled Partner Priority",
+			modify: func(c *config.Config) {
+				c.Partners["partner-1"].Priority = 1
+				c.Partners["partner-2"].Enabled, c.Partners["partner-2"].Priority = false, 2
+			},
+		},
+		{
+			name: "Experiment Without Control Traffic",
+			modify: func(c *config.Config) {
+				c.Experiments = map[string]*config.ExperimentConfig{
+					"all-in": {TrafficPercent: 100, Overrides: config.ExperimentOverrides{Strategy: config.StrategyPassthrough}},
+					"half":   {TrafficPercent: 50, Overrides: config.ExperimentOverrides{Floor: 1}},
+				}
+			},
+			expected: []lintResult{{config.LintWarning, config.LintNoControlTraffic, "experiments.all-in.traffic_percent"}},
+		},
+		{
+			name: "Unknown Vertical Multiplier",
+			modify: func(c *config.Config) {
+				c.Partners["partner-1"].VerticalMultipliers = map[string]float64{"auto": 1.2, "atuo": 1.1}
+			},
+			expected: []lintResult{{config.LintWarning, config.LintUnknownVertical, "partners.partner-1.vertical_multipliers.atuo"}},
+		},
+		{
+			name: "No Configured Verticals",
+			modify: func(c *config.Config) {
+				c.Strategies = nil
+				c.Partners["partner-1"].VerticalMultipliers = map[string]float64{"atuo": 1.1}
+			},
+		},
+		{
+			name: "Deals That Never Match",
+			modify: func(c *config.Config) {
+				c.Partners["partner-2"].Enabled = false
+				c.Deals = map[string]*config.DealConfig{
+					"expired":  {PartnerID: "partner-1", FloorPrice: 5, ExpiresAt: now.Add(-time.Hour)},
+					"disabled": {PartnerID: "partner-2", FloorPrice: 5},
+					"live":     {PartnerID: "partner-1", FloorPrice: 5, ExpiresAt: now.Add(time.Hour), Verticals: []string{"health", "home"}},
+				}
+			},
+			expected: []lintResult{
+				{config.LintWarning, config.LintDealNeverMatches, "deals.disabled.partner_id"},
+				{config.LintWarning, config.LintDealNeverMatches, "deals.expired.expires_at"},
+				{config.LintWarning, config.LintUnknownVertical, "deals.live.verticals"},
+			},
+		},
+		{
+			name: "Floors Above Caps",
+			modify: func(c *config.Config) {
+				c.Partners["partner-1"].MinBid, c.Partners["partner-1"].MaxBid = 5, 2
+				c.Partners["partner-2"].MinBid, c.Partners["partner-2"].MaxBid = 2, 5
+				c.Deals = map[string]*config.DealConfig{"capped": {PartnerID: "partner-1", FloorPrice: c.MaxBidPrice}}
+				c.Experiments = map[string]*config.ExperimentConfig{"floor": {TrafficPercent: 10, Overrides: config.ExperimentOverrides{Floor: c.MaxBidPrice}}}
+			},
+			expected: []lintResult{
+				{config.LintError, config.LintFloorAboveCap, "partners.partner-1.min_bid"},
+				{config.LintWarning, config.LintFloorAboveCap, "deals.capped.floor_price"},
+				{config.LintWarning, config.LintFloorAboveCap, "experiments.floor.overrides.floor"},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			tc.modify(cfg)
+
+			findings := config.Lint(cfg, now)
+			results := make([]lintResult, 0, len(findings))
+			for _, finding := range findings {
+				assert.NotEmpty(t, finding.Message)
+				results = append(results, lintResult{finding.Severity, finding.Code, finding.Path})
+			}
+			assert.Equal(t, len(tc.expected) > 0 && tc.expected[0].severity == config.LintError, config.HasLintErrors(findings))
+			if len(tc.expected) == 0 {
+				assert.Empty(t, results)
+			} else {
+				assert.Equal(t, tc.expected, results)
+			}
+		})
+	}
+}
+
+// TestReadConfig tests that ReadConfig loads a config file LoadConfig rejects, so it can be linted
+func TestReadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("port: 80\nbid_timeout: 500ms\n"), 0o600))
+
+	cfg, err := config.ReadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, 80, cfg.Port)
+	assert.Equal(t, 500*time.Millisecond, cfg.BidTimeout)
+
+	_, err = config.LoadConfig(path)
+	assert.ErrorContains(t, err, "config validation failed: invalid port number: 80")
+
+	_, err = config.ReadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Error(t, err)
+}
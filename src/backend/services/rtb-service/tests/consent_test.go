@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// sampleTCFString is a syntactically valid IAB TCF v2 consent string
+const sampleTCFString = "CPXxRfAPXxRfAAfKABENB-CgAAAAAAAAAAYgAAAAAAAA.YAAAAAAAAAAA"
+
+// recordingPartner is a partner endpoint that records the bid requests it receives
+type recordingPartner struct {
+	server   *httptest.Server
+	mutex    sync.Mutex
+	requests []models.BidRequest
+}
+
+// newRecordingPartner starts a partner that records requests and always returns the given bid
+func newRecordingPartner(t *testing.T, bid models.Bid) *recordingPartner {
+	partner := &recordingPartner{}
+	partner.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request models.BidRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		partner.mutex.Lock()
+		partner.requests = append(partner.requests, request)
+		partner.mutex.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bid)
+	}))
+	t.Cleanup(partner.server.Close)
+	return partner
+}
+
+// received returns the recorded requests
+func (p *recordingPartner) received() []models.BidRequest {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]models.BidRequest(nil), p.requests...)
+}
+
+// TestConsentParsing tests the TCF presence check and US Privacy opt-out detection
+func TestConsentParsing(t *testing.T) {
+	testCases := []struct {
+		name        string
+		consent     *models.Consent
+		hasTCF      bool
+		dataSharing bool
+	}{
+		{name: "No Signals", consent: nil, hasTCF: false, dataSharing: true},
+		{name: "GDPR With TCF", consent: &models.Consent{GDPRApplies: true, ConsentString: sampleTCFString}, hasTCF: true, dataSharing: true},
+		{name: "GDPR Without Consent", consent: &models.Consent{GDPRApplies: true}, hasTCF: false, dataSharing: false},
+		{name: "GDPR Malformed Consent", consent: &models.Consent{GDPRApplies: true, ConsentString: "BOgarbage!"}, hasTCF: false, dataSharing: false},
+		{name: "CCPA Opt Out", consent: &models.Consent{USPrivacy: "1YYN"}, hasTCF: false, dataSharing: false},
+		{name: "CCPA No Opt Out", consent: &models.Consent{USPrivacy: "1YNN"}, hasTCF: false, dataSharing: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.hasTCF, tc.consent.HasTCFConsent())
+			assert.Equal(t, tc.dataSharing, tc.consent.AllowsDataSharing())
+		})
+	}
+}
+
+// TestConsentGatesPartnerCalls tests partner skips and UserData redaction driven by consent and DPAs
+func TestConsentGatesPartnerCalls(t *testing.T) {
+	testCases := []struct {
+		name             string
+		consent          *models.Consent
+		dpaPartnerCalled bool
+		dpaPartnerEmail  string
+	}{
+		{name: "Consent Given", consent: &models.Consent{GDPRApplies: true, ConsentString: sampleTCFString}, dpaPartnerCalled: true, dpaPartnerEmail: "lead@example.com"},
+		{name: "Consent Absent", consent: &models.Consent{GDPRApplies: true}, dpaPartnerCalled: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dpaPartner := newRecordingPartner(t, models.Bid{ID: "bid-dpa", Price: 8.0, QualityScore: 0.5, ClickURL: "http://example.com/dpa"})
+			otherPartner := newRecordingPartner(t, models.Bid{ID: "bid-other", Price: 6.0, QualityScore: 0.5, ClickURL: "http://example.com/other"})
+
+			cfg := newTestConfig(map[string]*config.PartnerConfig{
+				"dpa":   {ID: "dpa", Endpoint: dpaPartner.server.URL, APIKey: "key-dpa", Timeout: 200 * time.Millisecond, Enabled: true, RequiresConsent: true, DPASigned: true},
+				"other": {ID: "other", Endpoint: otherPartner.server.URL, APIKey: "key-other", Timeout: 200 * time.Millisecond, Enabled: true},
+			}, withMaxBids(2))
+			cfg.Consent = &config.ConsentConfig{
+				PersonalDataFields: []string{"email"},
+				Action:             config.ConsentActionHash,
+				HashSalt:           "test-salt",
+			}
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			_, err = service.RunAuction(ctx, &models.BidRequest{
+				RequestID: "consent-" + tc.name,
+				LeadID:    "lead-1",
+				Vertical:  "auto",
+				UserData:  map[string]interface{}{"email": "lead@example.com", "zip": "10115"},
+				Consent:   tc.consent,
+			})
+			require.NoError(t, err)
+
+			if tc.dpaPartnerCalled {
+				require.Len(t, dpaPartner.received(), 1)
+				assert.Equal(t, tc.dpaPartnerEmail, dpaPartner.received()[0].UserData["email"])
+			} else {
+				assert.Empty(t, dpaPartner.received())
+			}
+
+			// The partner without a DPA never sees the raw email, but keeps non-personal fields
+			require.Len(t, otherPartner.received(), 1)
+			userData := otherPartner.received()[0].UserData
+			assert.NotEqual(t, "lead@example.com", userData["email"])
+			assert.Len(t, userData["email"], 64)
+			assert.Equal(t, "10115", userData["zip"])
+		})
+	}
+}
@@ -0,0 +1,198 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newConstraintTestConfig returns a config where partner-1 (bid-1) bids 20, partner-2 (bid-2) 16,
+// partner-3 (bid-3) 12, and partner-4 (bid-4) 8, with partner-1 and partner-2 in the carrier
+// group and partner-4 in the captive group. With seats, partner-1 also bids 9 as bid-1b.
+func newConstraintTestConfig(t *testing.T, maxBids int, seats bool) *config.Config {
+	partner1Bids := []models.Bid{{ID: "bid-1", Price: 20.0, QualityScore: 0.5, ClickURL: "http://example.com/1"}}
+	if seats {
+		partner1Bids = append(partner1Bids, models.Bid{ID: "bid-1b", Price: 9.0, QualityScore: 0.5, ClickURL: "http://example.com/1b"})
+	}
+	partners := map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: newSeatPartner(t, partner1Bids).URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	}
+	for id, bid := range map[string]models.Bid{
+		"partner-2": {ID: "bid-2", Price: 16.0, QualityScore: 0.5, ClickURL: "http://example.com/2"},
+		"partner-3": {ID: "bid-3", Price: 12.0, QualityScore: 0.5, ClickURL: "http://example.com/3"},
+		"partner-4": {ID: "bid-4", Price: 8.0, QualityScore: 0.5, ClickURL: "http://example.com/4"},
+	} {
+		partners[id] = &config.PartnerConfig{ID: id, Endpoint: newPartnerServer(t, bid).URL, APIKey: "key-" + id, Timeout: 200 * time.Millisecond, Enabled: true}
+	}
+	cfg := newTestConfig(partners, withMaxBids(maxBids))
+	cfg.PartnerGroups = map[string][]string{
+		"carrier": {"partner-1", "partner-2"},
+		"captive": {"partner-4"},
+	}
+	return cfg
+}
+
+// TestRankingConstraints tests that the winner set changes minimally to meet ranking constraints,
+// within MaxBids and partner diversity, and that constraints it cannot meet are reported
+func TestRankingConstraints(t *testing.T) {
+	maxPrice := func(price float64) models.RankingConstraint {
+		return models.RankingConstraint{Type: models.ConstraintMaxPriceAtLeastOne, MaxPrice: price}
+	}
+	maxFrom := func(group string, max int) models.RankingConstraint {
+		return models.RankingConstraint{Type: models.ConstraintMaxFromPartnerGroup, Group: group, Max: max}
+	}
+	requireGroup := func(group string) models.RankingConstraint {
+		return models.RankingConstraint{Type: models.ConstraintRequirePartnerGroup, Group: group}
+	}
+
+	testCases := []struct {
+		name                string
+		maxBids             int
+		seats               bool
+		constraints         []models.RankingConstraint
+		expectedWinners     []string
+		expectedUnsatisfied []models.RankingConstraint
+		expectedLosses      map[string]models.Reason
+	}{
+		{name: "No Constraints", maxBids: 2, expectedWinners: []string{"bid-1", "bid-2"}},
+		{name: "Already Satisfied", maxBids: 2, constraints: []models.RankingConstraint{maxPrice(16), requireGroup("carrier")},
+			expectedWinners: []string{"bid-1", "bid-2"}},
+		{name: "Group Cap", maxBids: 2, constraints: []models.RankingConstraint{maxFrom("carrier", 1)},
+			expectedWinners: []string{"bid-1", "bid-3"}, expectedLosses: map[string]models.Reason{"bid-2": models.ReasonRankingConstraint}},
+		{name: "Group Cap Refills Outside Group", maxBids: 4, constraints: []models.RankingConstraint{maxFrom("carrier", 1)},
+			expectedWinners: []string{"bid-1", "bid-3", "bid-4"}},
+		{name: "Required Group Swaps Lowest Winner", maxBids: 2, constraints: []models.RankingConstraint{requireGroup("captive")},
+			expectedWinners: []string{"bid-1", "bid-4"}},
+		{name: "Max Price Takes Best Satisfying Bid", maxBids: 2, constraints: []models.RankingConstraint{maxPrice(15)},
+			expectedWinners: []string{"bid-1", "bid-3"}},
+		{name: "Group Cap And Max Price", maxBids: 2, constraints: []models.RankingConstraint{maxFrom("carrier", 1), maxPrice(10)},
+			expectedWinners: []string{"bid-1", "bid-4"}},
+		{name: "Unsatisfiable Max Price", maxBids: 2, constraints: []models.RankingConstraint{maxPrice(5)},
+			expectedWinners: []string{"bid-1", "bid-2"}, expectedUnsatisfied: []models.RankingConstraint{maxPrice(5)}},
+		{name: "Conflicting Requirements Keep Earlier", maxBids: 1, constraints: []models.RankingConstraint{requireGroup("carrier"), requireGroup("captive")},
+			expectedWinners: []string{"bid-1"}, expectedUnsatisfied: []models.RankingConstraint{requireGroup("captive")}},
+		{name: "Single Slot Swap", maxBids: 1, constraints: []models.RankingConstraint{requireGroup("captive"), maxPrice(10)},
+			expectedWinners: []string{"bid-4"}},
+		{name: "Diversity Keeps Partner Best Seat", maxBids: 2, seats: true, constraints: []models.RankingConstraint{maxPrice(10)},
+			expectedWinners: []string{"bid-1", "bid-4"}},
+		{name: "Partner Seat Swapped In", maxBids: 1, seats: true, constraints: []models.RankingConstraint{maxPrice(10)},
+			expectedWinners: []string{"bid-1b"}, expectedLosses: map[string]models.Reason{"bid-1": models.ReasonRankingConstraint}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, err := services.NewAuctionService(newConstraintTestConfig(t, tc.maxBids, tc.seats))
+			require.NoError(t, err)
+			defer service.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			debug := models.NewDebugInfo()
+			response, err := service.RunAuction(models.ContextWithDebug(ctx, debug), &models.BidRequest{
+				RequestID: "constraints-" + tc.name, LeadID: "lead-1", Vertical: "auto", Constraints: tc.constraints,
+			})
+			require.NoError(t, err)
+
+			winners := make([]string, 0, len(response.Bids))
+			for _, bid := range response.Bids {
+				winners = append(winners, bid.ID)
+			}
+			assert.Equal(t, tc.expectedWinners, winners)
+			assert.Equal(t, tc.expectedUnsatisfied, response.Summary.UnsatisfiedConstraints)
+			assert.Equal(t, len(tc.expectedWinners), response.Summary.Winners)
+
+			losses := map[string]models.Reason{}
+			for _, partnerID := range []string{"partner-1", "partner-2", "partner-3", "partner-4"} {
+				partner, _ := debug.Partner(partnerID)
+				for bidID, reason := range partner.Losses {
+					losses[bidID] = reason
+				}
+			}
+			for bidID, reason := range tc.expectedLosses {
+				assert.Equal(t, reason, losses[bidID], bidID)
+			}
+			for _, winner := range winners {
+				assert.NotContains(t, losses, winner)
+			}
+		})
+	}
+}
+
+// TestRankingConstraintsValidation tests that malformed constraints fail the request with 400
+func TestRankingConstraintsValidation(t *testing.T) {
+	cfg := newConstraintTestConfig(t, 2, false)
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	router, _ := newTestRouter(t, service, cfg)
+
+	testCases := []struct {
+		name          string
+		constraints   string
+		expectedError string
+	}{
+		{name: "Unknown Type", constraints: `[{"type": "min_winners"}]`,
+			expectedError: `invalid ranking constraints: constraint 0: unknown type "min_winners"`},
+		{name: "Unknown Group", constraints: `[{"type": "max_price_at_least_one", "max_price": 10}, {"type": "require_partner_group", "group": "direct"}]`,
+			expectedError: `invalid ranking constraints: constraint 1: unknown partner group "direct"`},
+		{name: "Zero Group Max", constraints: `[{"type": "max_from_partner_group", "group": "carrier"}]`,
+			expectedError: "invalid ranking constraints: constraint 0: max must be at least 1: 0"},
+		{name: "Missing Max Price", constraints: `[{"type": "max_price_at_least_one"}]`,
+			expectedError: "invalid ranking constraints: constraint 0: max price must be positive: 0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := `{"request_id": "constraints-` + tc.name + `", "lead_id": "lead-1", "vertical": "auto", "constraints": ` + tc.constraints + `}`
+			req := httptest.NewRequest(http.MethodPost, "/v1/bids", bytes.NewBufferString(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+			var response struct {
+				Error string `json:"error"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tc.expectedError, response.Error)
+		})
+	}
+}
+
+// TestPartnerGroupsValidation tests that partner groups list known partners once each
+func TestPartnerGroupsValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		groups      map[string][]string
+		expectedErr string
+	}{
+		{name: "Valid", groups: map[string][]string{"carrier": {"partner-1", "partner-2"}}},
+		{name: "Empty Group", groups: map[string][]string{"carrier": {}}, expectedErr: "partner group carrier has no partners"},
+		{name: "Unknown Partner", groups: map[string][]string{"carrier": {"partner-9"}}, expectedErr: `unknown partner "partner-9" in partner group carrier`},
+		{name: "Duplicate Partner", groups: map[string][]string{"carrier": {"partner-1", "partner-1"}}, expectedErr: "partner partner-1 listed twice in partner group carrier"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.PartnerGroups = tc.groups
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
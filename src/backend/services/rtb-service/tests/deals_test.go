@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// dealTestNow is the clock time deal auctions run at
+var dealTestNow = time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// runDealTestAuction runs an auction between an open-market partner bidding 10.0 and a buyer
+// bidding buyerPrice on dealID, returning the winning bid
+func runDealTestAuction(t *testing.T, dealID string, deal *config.DealConfig, buyerPrice float64, maxWinners int) []*models.Bid {
+	open := newPartnerServer(t, models.Bid{ID: "open-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://open.example.com/c"})
+	buyer := newPartnerServer(t, models.Bid{ID: "deal-bid", Price: buyerPrice, QualityScore: 0.5, ClickURL: "http://buyer.example.com/c", DealID: dealID})
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"open":  {ID: "open", Endpoint: open.URL, APIKey: "key-open", Timeout: 200 * time.Millisecond, Enabled: true},
+		"buyer": {ID: "buyer", Endpoint: buyer.URL, APIKey: "key-buyer", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(maxWinners))
+	cfg.MinBidPrice = 5.0
+	cfg.Deals = map[string]*config.DealConfig{dealID: deal}
+	service, err := services.NewAuctionServiceWithClock(cfg, fixedClock{now: dealTestNow})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{
+		RequestID: "deal-test",
+		LeadID:    "lead-1",
+		Vertical:  "auto",
+		Geo:       &models.Geo{Zip: "78701"},
+	})
+	require.NoError(t, err)
+	return response.Bids
+}
+
+// TestDealBids tests deal eligibility, pricing, floor bypass, and guaranteed slots
+func TestDealBids(t *testing.T) {
+	testCases := []struct {
+		name            string
+		dealID          string
+		deal            *config.DealConfig
+		buyerPrice      float64
+		maxWinners      int
+		expectedWinners []string
+		expectedPrice   float64
+		expectedOutcome string
+	}{
+		{
+			name:            "Fixed Price Wins",
+			dealID:          "fixed-price",
+			deal:            &config.DealConfig{PartnerID: "buyer", FixedPrice: 30.0, Verticals: []string{"auto"}, Zips: []string{"78701"}},
+			buyerPrice:      1.0,
+			maxWinners:      1,
+			expectedWinners: []string{"deal-bid"},
+			expectedPrice:   30.0,
+			expectedOutcome: "won",
+		},
+		{
+			name:            "Floor Override Bypasses Open Floor",
+			dealID:          "floor-override",
+			deal:            &config.DealConfig{PartnerID: "buyer", FloorPrice: 1.5},
+			buyerPrice:      2.0,
+			maxWinners:      2,
+			expectedWinners: []string{"open-bid", "deal-bid"},
+			expectedPrice:   2.0,
+			expectedOutcome: "won",
+		},
+		{
+			name:            "Guaranteed Slot",
+			dealID:          "guaranteed",
+			deal:            &config.DealConfig{PartnerID: "buyer", FloorPrice: 1.5, Guaranteed: true},
+			buyerPrice:      2.0,
+			maxWinners:      1,
+			expectedWinners: []string{"deal-bid"},
+			expectedPrice:   2.0,
+			expectedOutcome: "won",
+		},
+		{
+			name:            "Below Deal Floor",
+			dealID:          "below-floor",
+			deal:            &config.DealConfig{PartnerID: "buyer", FloorPrice: 3.0},
+			buyerPrice:      2.0,
+			maxWinners:      2,
+			expectedWinners: []string{"open-bid"},
+			expectedOutcome: "below_floor",
+		},
+		{
+			name:            "Wrong Partner",
+			dealID:          "wrong-partner",
+			deal:            &config.DealConfig{PartnerID: "open", FixedPrice: 30.0},
+			buyerPrice:      20.0,
+			maxWinners:      2,
+			expectedWinners: []string{"open-bid"},
+			expectedOutcome: "wrong_partner",
+		},
+		{
+			name:            "Vertical Excluded",
+			dealID:          "vertical-excluded",
+			deal:            &config.DealConfig{PartnerID: "buyer", FixedPrice: 30.0, Verticals: []string{"home"}},
+			buyerPrice:      20.0,
+			maxWinners:      2,
+			expectedWinners: []string{"open-bid"},
+			expectedOutcome: "vertical_excluded",
+		},
+		{
+			name:            "Zip Excluded",
+			dealID:          "zip-excluded",
+			deal:            &config.DealConfig{PartnerID: "buyer", FixedPrice: 30.0, Zips: []string{"10001"}},
+			buyerPrice:      20.0,
+			maxWinners:      2,
+			expectedWinners: []string{"open-bid"},
+			expectedOutcome: "zip_excluded",
+		},
+		{
+			name:            "Expired",
+			dealID:          "expired",
+			deal:            &config.DealConfig{PartnerID: "buyer", FixedPrice: 30.0, ExpiresAt: dealTestNow.Add(-time.Hour)},
+			buyerPrice:      20.0,
+			maxWinners:      2,
+			expectedWinners: []string{"open-bid"},
+			expectedOutcome: "expired",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			labels := map[string]string{"deal": tc.dealID, "outcome": tc.expectedOutcome}
+			before := gatheredMetric(t, "rtb_deal_bids_total", labels)
+			winners := runDealTestAuction(t, tc.dealID, tc.deal, tc.buyerPrice, tc.maxWinners)
+
+			ids := make([]string, 0, len(winners))
+			for _, bid := range winners {
+				ids = append(ids, bid.ID)
+				if bid.ID == "deal-bid" {
+					assert.Equal(t, tc.expectedPrice, bid.Price)
+					assert.Equal(t, tc.dealID, bid.DealID)
+				}
+			}
+			assert.Equal(t, tc.expectedWinners, ids)
+			assert.Equal(t, 1.0, gatheredMetric(t, "rtb_deal_bids_total", labels)-before)
+		})
+	}
+}
+
+// TestUnknownDealBid tests that bids on unconfigured deals are dropped and counted without their deal ID
+func TestUnknownDealBid(t *testing.T) {
+	before := gatheredMetric(t, "rtb_deal_bids_total", map[string]string{"deal": "unknown", "outcome": "unknown_deal"})
+
+	rogue := newPartnerServer(t, models.Bid{ID: "rogue-bid", Price: 20.0, QualityScore: 0.5, ClickURL: "http://rogue.example.com/c", DealID: "not-configured"})
+	service, err := services.NewAuctionService(newTestConfig(map[string]*config.PartnerConfig{
+		"rogue": {ID: "rogue", Endpoint: rogue.URL, APIKey: "key", Timeout: 200 * time.Millisecond, Enabled: true},
+	}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err = service.RunAuction(ctx, &models.BidRequest{RequestID: "unknown-deal-test", LeadID: "lead-1", Vertical: "auto"})
+	assert.ErrorIs(t, err, services.ErrNoValidBids)
+
+	assert.Equal(t, before+1, gatheredMetric(t, "rtb_deal_bids_total", map[string]string{"deal": "unknown", "outcome": "unknown_deal"}))
+	assert.Zero(t, gatheredMetric(t, "rtb_deal_bids_total", map[string]string{"deal": "not-configured"}))
+}
+
+// TestDealValidation tests deal configuration requirements
+func TestDealValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		deal        *config.DealConfig
+		expectedErr string
+	}{
+		{name: "Fixed Price", deal: &config.DealConfig{PartnerID: "partner-1", FixedPrice: 12.0}},
+		{name: "Floor Price", deal: &config.DealConfig{PartnerID: "partner-1", FloorPrice: 0.005}},
+		{name: "Unknown Partner", deal: &config.DealConfig{PartnerID: "partner-9", FixedPrice: 12.0}, expectedErr: "unknown partner"},
+		{name: "No Price", deal: &config.DealConfig{PartnerID: "partner-1"}, expectedErr: "exactly one of"},
+		{name: "Both Prices", deal: &config.DealConfig{PartnerID: "partner-1", FixedPrice: 12.0, FloorPrice: 8.0}, expectedErr: "exactly one of"},
+		{name: "Above Max Price", deal: &config.DealConfig{PartnerID: "partner-1", FixedPrice: 500.0}, expectedErr: "invalid price"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Deals = map[string]*config.DealConfig{"deal-1": tc.deal}
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
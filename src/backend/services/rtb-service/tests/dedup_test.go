@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// runDedupTestAuction runs a debug-enabled auction against partners returning the given bids
+// and returns the winning bid IDs in rank order with the debug output
+func runDedupTestAuction(t *testing.T, dedup *config.DedupConfig, vertical string, bids ...models.Bid) ([]string, *models.DebugInfo) {
+	cfg := newTestConfig(make(map[string]*config.PartnerConfig), withMaxBids(len(bids)))
+	cfg.Dedup = dedup
+	for _, bid := range bids {
+		server := newPartnerServer(t, bid)
+		partnerID := "partner-" + bid.ID
+		cfg.Partners[partnerID] = &config.PartnerConfig{
+			ID: partnerID, Endpoint: server.URL, APIKey: "key", Timeout: 200 * time.Millisecond, Enabled: true,
+		}
+	}
+
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	debug := models.NewDebugInfo()
+
+	response, err := service.RunAuction(models.ContextWithDebug(ctx, debug), &models.BidRequest{
+		RequestID: "dedup-test",
+		LeadID:    "lead-1",
+		Vertical:  vertical,
+	})
+	require.NoError(t, err)
+
+	winners := make([]string, 0, len(response.Bids))
+	for _, bid := range response.Bids {
+		winners = append(winners, bid.ID)
+	}
+	return winners, debug
+}
+
+// TestDuplicateDemandDetection tests that resold demand is reduced to its highest effective-price instance
+func TestDuplicateDemandDetection(t *testing.T) {
+	defaultKeys := &config.DedupConfig{
+		Keys:      []string{config.DedupKeyURL, config.DedupKeyAdomain},
+		Verticals: map[string][]string{"home": {config.DedupKeyCreativeTitle}},
+	}
+
+	testCases := []struct {
+		name     string
+		dedup    *config.DedupConfig
+		vertical string
+		bids     []models.Bid
+		winners  []string
+	}{
+		{
+			name:     "Query Parameters Only Differ",
+			dedup:    defaultKeys,
+			vertical: "auto",
+			bids: []models.Bid{
+				{ID: "a", Price: 10.0, QualityScore: 0.5, ClickURL: "https://carrier.com/quote?src=partner-a&lead=1"},
+				{ID: "b", Price: 8.0, QualityScore: 0.5, ClickURL: "http://WWW.Carrier.com:443/quote/?src=partner-b#top"},
+			},
+			winners: []string{"a"},
+		},
+		{
+			name:     "Different Paths",
+			dedup:    defaultKeys,
+			vertical: "auto",
+			bids: []models.Bid{
+				{ID: "a", Price: 10.0, QualityScore: 0.5, ClickURL: "https://carrier.com/auto"},
+				{ID: "b", Price: 8.0, QualityScore: 0.5, ClickURL: "https://carrier.com/home"},
+			},
+			winners: []string{"a", "b"},
+		},
+		{
+			name:     "Same Advertiser Domain",
+			dedup:    defaultKeys,
+			vertical: "auto",
+			bids: []models.Bid{
+				{ID: "a", Price: 8.0, QualityScore: 0.5, ClickURL: "https://tracker-a.com/c", AdvertiserDomains: []string{"carrier.com"}},
+				{ID: "b", Price: 10.0, QualityScore: 0.5, ClickURL: "https://tracker-b.com/c", AdvertiserDomains: []string{"www.carrier.com"}},
+			},
+			winners: []string{"b"},
+		},
+		{
+			name:     "Vertical Override",
+			dedup:    defaultKeys,
+			vertical: "home",
+			bids: []models.Bid{
+				{ID: "a", Price: 10.0, QualityScore: 0.5, ClickURL: "https://carrier.com/quote", Creative: map[string]interface{}{"title": "Save on Home  Insurance"}},
+				{ID: "b", Price: 8.0, QualityScore: 0.5, ClickURL: "https://carrier.com/quote", Creative: map[string]interface{}{"title": "save on home insurance"}},
+				{ID: "c", Price: 6.0, QualityScore: 0.5, ClickURL: "https://carrier.com/quote", Creative: map[string]interface{}{"title": "Bundle and save"}},
+			},
+			winners: []string{"a", "c"},
+		},
+		{
+			name:     "Disabled",
+			dedup:    nil,
+			vertical: "auto",
+			bids: []models.Bid{
+				{ID: "a", Price: 10.0, QualityScore: 0.5, ClickURL: "https://carrier.com/quote"},
+				{ID: "b", Price: 8.0, QualityScore: 0.5, ClickURL: "https://carrier.com/quote"},
+			},
+			winners: []string{"a", "b"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			winners, debug := runDedupTestAuction(t, tc.dedup, tc.vertical, tc.bids...)
+			assert.Equal(t, tc.winners, winners)
+
+			for _, bid := range tc.bids {
+				partner, _ := debug.Partner("partner-" + bid.ID)
+				if contains(tc.winners, bid.ID) {
+					assert.Empty(t, partner.Losses, bid.ID)
+				} else {
+					assert.Equal(t, models.ReasonDuplicateDemand, partner.Losses[bid.ID], bid.ID)
+				}
+			}
+		})
+	}
+}
+
+// TestDedupValidation tests that unknown dedup keys are rejected
+func TestDedupValidation(t *testing.T) {
+	cfg := newStrategyTestConfig()
+	cfg.Dedup = &config.DedupConfig{Keys: []string{config.DedupKeyURL}}
+	assert.NoError(t, cfg.Validate())
+
+	cfg.Dedup.Verticals = map[string][]string{"auto": {"landing_page"}}
+	assert.ErrorContains(t, cfg.Validate(), "unknown dedup key")
+}
+
+// contains reports whether values includes value
+func contains(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/replay"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newTiedPartnersConfig returns a deterministic config for three partners bidding the same price and quality
+func newTiedPartnersConfig(t *testing.T) *config.Config {
+	partners := make(map[string]*config.PartnerConfig)
+	for _, id := range []string{"tie-c", "tie-a", "tie-b"} {
+		server := newAnalyticsPartner(t, id, 10.0)
+		partners[id] = &config.PartnerConfig{ID: id, Endpoint: server.URL, APIKey: "key-" + id, Timeout: 200 * time.Millisecond, Enabled: true}
+	}
+	cfg := newTestConfig(partners, withMaxBids(3))
+	cfg.DeterministicMode = true
+	return cfg
+}
+
+// TestDeterministicTieOrdering tests that tied bids rank in partner order under every strategy
+func TestDeterministicTieOrdering(t *testing.T) {
+	testCases := []struct {
+		name     string
+		strategy string
+	}{
+		{name: "Effective Price", strategy: config.StrategyEffectivePrice},
+		{name: "Quality Weighted", strategy: config.StrategyQualityWeighted},
+		{name: "Passthrough", strategy: config.StrategyPassthrough},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newTiedPartnersConfig(t)
+			cfg.Strategies = map[string]string{config.DefaultStrategyKey: tc.strategy}
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+
+			for i := 0; i < 10; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+				response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: fmt.Sprintf("tie-%d", i), LeadID: "lead-1", Vertical: "auto"})
+				cancel()
+				require.NoError(t, err)
+
+				winners := []string{}
+				for _, bid := range response.Bids {
+					winners = append(winners, bid.PartnerID)
+				}
+				assert.Equal(t, []string{"tie-a", "tie-b", "tie-c"}, winners)
+			}
+		})
+	}
+}
+
+// TestDeterministicReplay tests that replaying a deterministic auction reproduces the recorded winners byte for byte
+func TestDeterministicReplay(t *testing.T) {
+	cfg := newTiedPartnersConfig(t)
+	cfg.Recording = &config.RecordingConfig{Enabled: true, SampleRate: 1, Path: filepath.Join(t.TempDir(), "auctions.jsonl"), MaxSizeBytes: 1 << 20, BufferSize: 10}
+	recordings := recordTestAuctions(t, cfg, "auto")
+	require.Len(t, recordings, 1)
+	recorded, err := json.Marshal(recordings[0].Winners)
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		result, err := replay.NewReplayer(cfg).Replay(context.Background(), recordings[0])
+		require.NoError(t, err)
+		replayed, err := json.Marshal(result.Replayed)
+		require.NoError(t, err)
+		assert.Equal(t, string(recorded), string(replayed))
+		assert.False(t, result.Diff.Changed)
+	}
+}
+
+// TestDeterministicSampling tests that recording samples and endpoint picks follow the request ID
+func TestDeterministicSampling(t *testing.T) {
+	t.Run("Recording Sample", func(t *testing.T) {
+		verticals := make([]string, 20)
+		for i := range verticals {
+			verticals[i] = fmt.Sprintf("auto-%d", i)
+		}
+		sampled := func() []string {
+			cfg := newTiedPartnersConfig(t)
+			cfg.Recording = &config.RecordingConfig{Enabled: true, SampleRate: 0.5, Path: filepath.Join(t.TempDir(), "auctions.jsonl"), MaxSizeBytes: 1 << 20, BufferSize: 20}
+			requestIDs := []string{}
+			for _, recording := range recordTestAuctions(t, cfg, verticals...) {
+				requestIDs = append(requestIDs, recording.Request.RequestID)
+			}
+			return requestIDs
+		}
+
+		first := sampled()
+		assert.NotEmpty(t, first)
+		assert.Less(t, len(first), len(verticals))
+		assert.Equal(t, first, sampled())
+	})
+
+	t.Run("Endpoint Pick", func(t *testing.T) {
+		primary, _ := newCountingPartner(t, "bid-primary")
+		secondary, _ := newCountingPartner(t, "bid-secondary")
+		picks := make(map[string]map[string]bool)
+		for i := 0; i < 5; i++ {
+			cfg := newTestConfig(map[string]*config.PartnerConfig{
+				"partner-a": {ID: "partner-a", Endpoints: []config.EndpointConfig{{URL: primary.URL, Weight: 1}, {URL: secondary.URL, Weight: 1}}, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true},
+			})
+			cfg.DeterministicMode = true
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+
+			for _, requestID := range []string{"pick-1", "pick-2", "pick-3", "pick-4"} {
+				ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+				response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: requestID, LeadID: "lead-1", Vertical: "auto"})
+				cancel()
+				require.NoError(t, err)
+				if picks[requestID] == nil {
+					picks[requestID] = make(map[string]bool)
+				}
+				picks[requestID][response.Bids[0].ID] = true
+			}
+		}
+
+		// Every service picks the same endpoint for a request
+		for requestID, bidIDs := range picks {
+			assert.Len(t, bidIDs, 1, requestID)
+		}
+	})
+}
@@ -0,0 +1,120 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// TestDryRunBidRequest tests that dry runs reach partners with the test header and return side effects instead of performing them
+func TestDryRunBidRequest(t *testing.T) {
+	var testHeaders atomic.Int32
+	partner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get(services.PartnerTestHeader) == "1" {
+			testHeaders.Add(1)
+		}
+		var request models.BidRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Vertical == "life" {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "dry-bid", Price: 15.0, QualityScore: 0.5, ClickURL: "http://partner.example.com/c"})
+	}))
+	t.Cleanup(partner.Close)
+	receiver, webhookServer := newWebhookReceiver(t, http.StatusOK)
+
+	auditPath := filepath.Join(t.TempDir(), "winning-bids.jsonl")
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner1": {ID: "partner1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withAdmin(), withAudit(auditPath))
+	cfg.Analytics = &config.AnalyticsConfig{Enabled: true, Windows: 24, MaxSeries: 10, Precision: 0.02}
+	cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: webhookServer.URL})
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	router := gin.New()
+	router.POST("/v1/bids/dryrun", handler.HandleDryRunBidRequest)
+
+	testCases := []struct {
+		name            string
+		adminKey        string
+		vertical        string
+		expectedStatus  int
+		expectedBids    int
+		expectedReason  models.Reason
+		expectedEffects []string
+	}{
+		{name: "Missing Admin Key", vertical: "auto", expectedStatus: http.StatusUnauthorized},
+		{
+			name: "Winning Bid", adminKey: testAdminKey, vertical: "auto", expectedStatus: http.StatusOK, expectedBids: 1,
+			expectedEffects: []string{models.SideEffectPartnerCall, models.SideEffectPartnerWin, models.SideEffectPriceAnalytics, models.SideEffectAuditRecord, models.SideEffectWebhook, models.SideEffectWebhook},
+		},
+		{
+			name: "No Bids", adminKey: testAdminKey, vertical: "life", expectedStatus: http.StatusOK, expectedReason: "no_valid_bids",
+			expectedEffects: []string{models.SideEffectPartnerCall, models.SideEffectPriceAnalytics, models.SideEffectWebhook},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requestsBefore := gatheredMetric(t, "rtb_bid_requests_total", map[string]string{"traffic": "dry_run"})
+			body, _ := json.Marshal(models.BidRequest{RequestID: "dry-" + tc.vertical, LeadID: "lead-1", Vertical: tc.vertical})
+			req, _ := http.NewRequest(http.MethodPost, "/v1/bids/dryrun", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.adminKey != "" {
+				req.Header.Set("X-Admin-Key", tc.adminKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			var response models.BidResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Len(t, response.Bids, tc.expectedBids)
+			assert.Equal(t, tc.expectedReason, response.Reason)
+			require.NotNil(t, response.DryRun)
+
+			var effects []string
+			for _, effect := range response.DryRun.Effects() {
+				effects = append(effects, effect.Type)
+			}
+			assert.Equal(t, tc.expectedEffects, effects)
+			assert.Equal(t, requestsBefore+1, gatheredMetric(t, "rtb_bid_requests_total", map[string]string{"traffic": "dry_run"}))
+		})
+	}
+
+	// Partners were told both auctions were tests, and nothing was recorded or sent
+	assert.Equal(t, int32(2), testHeaders.Load())
+	report, err := service.PartnerReport("partner1", time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, report.Calls)
+	summary, err := service.PriceAnalytics("auto", "", time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, summary.Count)
+
+	require.NoError(t, service.Close())
+	assert.Empty(t, receiver.received())
+	auditLog, err := os.ReadFile(auditPath)
+	require.NoError(t, err)
+	assert.Empty(t, auditLog)
+}
@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// slowPartnerDelay is how long the slow partner takes to answer unless its call is cancelled
+const slowPartnerDelay = 300 * time.Millisecond
+
+// newSlowPartnerServer starts a partner endpoint that returns bid after slowPartnerDelay, or
+// nothing once the call is cancelled
+func newSlowPartnerServer(t *testing.T, bid models.Bid) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(slowPartnerDelay):
+		case <-r.Context().Done():
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bid)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newEarlyTerminationConfig creates a single-winner config with early termination enabled, a
+// fast partner-lead, and a partner-slow with a max bid of 20
+func newEarlyTerminationConfig(leadURL, slowURL string) *config.Config {
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-lead": {ID: "partner-lead", Endpoint: leadURL, APIKey: "key-lead", Timeout: 500 * time.Millisecond, Enabled: true},
+		"partner-slow": {ID: "partner-slow", Endpoint: slowURL, APIKey: "key-slow", Timeout: 500 * time.Millisecond, Enabled: true, MaxBid: 20},
+	}, withBidTimeout(time.Second))
+	cfg.EarlyTermination = &config.EarlyTerminationConfig{Enabled: true}
+	return cfg
+}
+
+// TestEarlyTermination tests that bid collection stops waiting for a slow partner only when the
+// bid in hand outranks anything it could return, whatever quality scores, acknowledgement
+// bonuses, and multipliers are applied
+func TestEarlyTermination(t *testing.T) {
+	leadBid := models.Bid{ID: "bid-lead", Price: 50.0, QualityScore: 0.2, ClickURL: "http://example.com/lead"}
+	slowBid := models.Bid{ID: "bid-slow", Price: 20.0, QualityScore: 0.2, ClickURL: "http://example.com/slow"}
+
+	testCases := []struct {
+		name        string
+		lead        func(bid *models.Bid)
+		slow        func(bid *models.Bid)
+		modify      func(cfg *config.Config)
+		leadQuality bool
+		early       bool
+		winner      string
+	}{
+		{name: "Disabled", modify: func(cfg *config.Config) { cfg.EarlyTermination = nil }, winner: "bid-lead"},
+		// 50 * (1 + 0.1*0.3) = 51.5 at worst against 20 * (1 + 0.3) = 26 at best
+		{name: "Leader Outranks Max Bid", early: true, winner: "bid-lead"},
+		{
+			name:   "No Max Bid",
+			modify: func(cfg *config.Config) { cfg.Partners["partner-slow"].MaxBid = 0 },
+			winner: "bid-lead",
+		},
+		{
+			// The leader's raw price is above the max bid, but a top quality score lifts 25 to 32.5
+			name:   "Quality Score Flips Ranking",
+			lead:   func(bid *models.Bid) { bid.Price, bid.QualityScore = 30.0, 0.1 },
+			slow:   func(bid *models.Bid) { bid.Price, bid.QualityScore = 25.0, 1.0 },
+			modify: func(cfg *config.Config) { cfg.Partners["partner-slow"].MaxBid = 25 },
+			winner: "bid-slow",
+		},
+		{
+			// 23 * 1.3 = 29.9 is below 30.9, but the acknowledgement bonus lifts it to 35.88
+			name:        "Acknowledgement Bonus Flips Ranking",
+			lead:        func(bid *models.Bid) { bid.Price, bid.QualityScore = 30.0, 0.1 },
+			slow:        func(bid *models.Bid) { bid.Price, bid.QualityScore, bid.QualityAcknowledged = 23.0, 1.0, true },
+			modify:      func(cfg *config.Config) { cfg.Partners["partner-slow"].MaxBid, cfg.QualityAcknowledgedBonus = 23, 0.2 },
+			leadQuality: true,
+			winner:      "bid-slow",
+		},
+		{
+			name:   "Acknowledgement Bonus Without Lead Quality",
+			lead:   func(bid *models.Bid) { bid.Price, bid.QualityScore = 30.0, 0.1 },
+			slow:   func(bid *models.Bid) { bid.Price, bid.QualityScore, bid.QualityAcknowledged = 23.0, 1.0, true },
+			modify: func(cfg *config.Config) { cfg.Partners["partner-slow"].MaxBid, cfg.QualityAcknowledgedBonus = 23, 0.2 },
+			early:  true,
+			winner: "bid-lead",
+		},
+		{
+			// A 2x vertical multiplier lets the slow partner reach 52, above the leader's 51.5
+			name: "Vertical Multiplier",
+			modify: func(cfg *config.Config) {
+				cfg.Partners["partner-slow"].VerticalMultipliers = map[string]float64{"auto": 2.0}
+			},
+			winner: "bid-lead",
+		},
+		{
+			// Some hours halve the leader to 25.75, below the slow partner's 26 in others
+			name: "Time Multiplier Range",
+			modify: func(cfg *config.Config) {
+				cfg.TimeMultipliers = &config.TimeMultiplierConfig{Rules: []config.TimeMultiplierRule{{StartHour: 0, EndHour: 1, Multiplier: 0.5}}}
+			},
+			winner: "bid-lead",
+		},
+		{
+			name: "Tie At Bound",
+			lead: func(bid *models.Bid) { bid.Price = 20.0 },
+			slow: func(bid *models.Bid) { bid.Price = 19.0 },
+			modify: func(cfg *config.Config) {
+				weight := 0.0
+				cfg.QualityWeight = &weight
+			},
+			winner: "bid-lead",
+		},
+		{
+			// Both clamp to the max bid price of 40, so the slow partner could tie the leader
+			name:   "Clamped To Max Bid Price",
+			lead:   func(bid *models.Bid) { bid.Price, bid.QualityScore = 40.0, 0.1 },
+			slow:   func(bid *models.Bid) { bid.Price, bid.QualityScore = 35.0, 0.1 },
+			modify: func(cfg *config.Config) { cfg.MaxBidPrice, cfg.Partners["partner-slow"].MaxBid = 40, 35 },
+			winner: "bid-lead",
+		},
+		{name: "Two Winners", modify: func(cfg *config.Config) { cfg.MaxBidsPerRequest = 2 }, winner: "bid-lead"},
+		{name: "Bidder Quorum", modify: func(cfg *config.Config) { cfg.MinBidders = map[string]int{"auto": 2} }, winner: "bid-lead"},
+		{
+			name:   "Other Strategy",
+			modify: func(cfg *config.Config) { cfg.Strategies = map[string]string{"auto": config.StrategyQualityWeighted} },
+			winner: "bid-lead",
+		},
+		{name: "Deterministic", modify: func(cfg *config.Config) { cfg.DeterministicMode = true }, winner: "bid-lead"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			lead, slow := leadBid, slowBid
+			if tc.lead != nil {
+				tc.lead(&lead)
+			}
+			if tc.slow != nil {
+				tc.slow(&slow)
+			}
+			cfg := newEarlyTerminationConfig(newPartnerServer(t, lead).URL, newSlowPartnerServer(t, slow).URL)
+			if tc.modify != nil {
+				tc.modify(cfg)
+			}
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			request := &models.BidRequest{RequestID: "early-" + tc.name, LeadID: "lead-1", Vertical: "auto"}
+			if tc.leadQuality {
+				score := 0.8
+				request.LeadQuality = &models.LeadQuality{Score: &score}
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			debug := models.NewDebugInfo()
+			started := time.Now()
+			response, err := service.RunAuction(models.ContextWithDebug(ctx, debug), request)
+			elapsed := time.Since(started)
+			require.NoError(t, err)
+
+			require.NotEmpty(t, response.Bids)
+			assert.Equal(t, tc.winner, response.Bids[0].ID)
+			slowDebug, _ := debug.Partner("partner-slow")
+			if tc.early {
+				assert.Less(t, elapsed, slowPartnerDelay)
+				assert.Equal(t, 1, response.Summary.EarlyTerminatedPartners)
+				assert.Equal(t, models.ReasonEarlyTerminated, slowDebug.SkipReason)
+				// A cancelled call is not held against the partner
+				assert.Zero(t, service.GetPartnerStats()["partner-slow"])
+			} else {
+				assert.GreaterOrEqual(t, elapsed, slowPartnerDelay)
+				assert.Zero(t, response.Summary.EarlyTerminatedPartners)
+				assert.Empty(t, slowDebug.SkipReason)
+			}
+		})
+	}
+}
+
+// TestEarlyTerminationMaxBid tests that partners' max bids are enforced only with early
+// termination enabled, since only then do they bound what a partner may return
+func TestEarlyTerminationMaxBid(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		partner := newPartnerServer(t, models.Bid{ID: "bid-over", Price: 30.0, QualityScore: 0.5, ClickURL: "http://example.com/over"})
+		cfg := newEarlyTerminationConfig(partner.URL, partner.URL)
+		delete(cfg.Partners, "partner-lead")
+		cfg.EarlyTermination.Enabled = enabled
+		service, err := services.NewAuctionService(cfg)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		debug := models.NewDebugInfo()
+		response, err := service.RunAuction(models.ContextWithDebug(ctx, debug), &models.BidRequest{RequestID: "max-bid", LeadID: "lead-1", Vertical: "auto"})
+		cancel()
+		service.Close()
+
+		if !enabled {
+			require.NoError(t, err)
+			assert.Equal(t, "bid-over", response.Bids[0].ID)
+			continue
+		}
+		assert.ErrorIs(t, err, services.ErrNoValidBids)
+		partnerDebug, _ := debug.Partner("partner-slow")
+		assert.Equal(t, models.ReasonAboveMaxBid, partnerDebug.Losses["bid-over"])
+	}
+}
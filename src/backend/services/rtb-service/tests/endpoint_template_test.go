@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newTemplateTestRequest returns a request with every templated field set
+func newTemplateTestRequest() *models.BidRequest {
+	return &models.BidRequest{
+		RequestID: "template-test",
+		LeadID:    "lead-1",
+		Vertical:  "auto",
+		Geo:       &models.Geo{Country: "US", Region: "TX", Zip: "78701"},
+		Device:    &models.Device{Type: "mobile"},
+	}
+}
+
+// TestEndpointTemplates tests that adapters resolve endpoint and query parameter placeholders
+// from the request, escaping each value for where it appears
+func TestEndpointTemplates(t *testing.T) {
+	testCases := []struct {
+		name          string
+		format        string
+		endpoint      string
+		queryParams   map[string]string
+		modify        func(*models.BidRequest)
+		expectedURL   string
+		expectedField string
+	}{
+		{name: "Untemplated", endpoint: "https://partner.example/bid", expectedURL: "https://partner.example/bid"},
+		{name: "Path", endpoint: "https://partner.example/bid/{vertical}/{state}", expectedURL: "https://partner.example/bid/auto/TX"},
+		{name: "Path Escaping", endpoint: "https://partner.example/bid/{vertical}",
+			modify:      func(request *models.BidRequest) { request.Vertical = "home & auto/renters" },
+			expectedURL: "https://partner.example/bid/home%20&%20auto%2Frenters"},
+		{name: "Query Parameters", endpoint: "https://partner.example/bid",
+			queryParams: map[string]string{"v": "{vertical}", "zip": "{zip}", "src": "rtb-{country}"},
+			expectedURL: "https://partner.example/bid?src=rtb-US&v=auto&zip=78701"},
+		{name: "Endpoint Query And Parameters", endpoint: "https://partner.example/bid?lead={lead_id}",
+			queryParams: map[string]string{"device": "{device}"},
+			modify:      func(request *models.BidRequest) { request.LeadID = "lead 1&2" },
+			expectedURL: "https://partner.example/bid?lead=lead+1%262&device=mobile"},
+		{name: "XML Adapter", format: config.FormatXML, endpoint: "https://partner.example/{request_id}", expectedURL: "https://partner.example/template-test"},
+		{name: "Missing Geo", endpoint: "https://partner.example/bid/{state}",
+			modify: func(request *models.BidRequest) { request.Geo = nil }, expectedField: "state"},
+		{name: "Empty Query Parameter Field", endpoint: "https://partner.example/bid", queryParams: map[string]string{"zip": "{zip}"},
+			modify: func(request *models.BidRequest) { request.Geo.Zip = "" }, expectedField: "zip"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partner := &config.PartnerConfig{ID: "templated", Endpoint: tc.endpoint, QueryParams: tc.queryParams, Format: tc.format}
+			adapter, err := services.NewPartnerAdapter(partner)
+			require.NoError(t, err)
+			request := newTemplateTestRequest()
+			if tc.modify != nil {
+				tc.modify(request)
+			}
+
+			httpReq, err := adapter.BuildRequest(request, partner)
+			if tc.expectedField != "" {
+				require.ErrorIs(t, err, services.ErrEmptyTemplateField)
+				var adapterErr *services.AdapterError
+				require.True(t, errors.As(err, &adapterErr))
+				assert.Equal(t, tc.expectedField, adapterErr.Field)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedURL, httpReq.URL.String())
+		})
+	}
+}
+
+// TestEndpointTemplateValidation tests that unknown placeholders fail at config load
+func TestEndpointTemplateValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		endpoint    string
+		queryParams map[string]string
+		expectedErr string
+	}{
+		{name: "Known Placeholders", endpoint: "https://partner-1/bid/{vertical}/{state}", queryParams: map[string]string{"zip": "{zip}", "lead": "{lead_id}"}},
+		{name: "Unknown Endpoint Placeholder", endpoint: "https://partner-1/bid/{product}", expectedErr: "unknown placeholder {product}"},
+		{name: "Unclosed Placeholder", endpoint: "https://partner-1/bid/{vertical", expectedErr: "unclosed placeholder"},
+		{name: "Placeholder In Host", endpoint: "https://{vertical}.partner-1/bid", expectedErr: "placeholder outside its path and query"},
+		{name: "Unknown Query Placeholder", endpoint: "https://partner-1/bid", queryParams: map[string]string{"p": "{email}"},
+			expectedErr: "invalid query parameter p for partner partner-1: unknown placeholder {email}"},
+		{name: "Empty Query Parameter Name", endpoint: "https://partner-1/bid", queryParams: map[string]string{"": "{zip}"},
+			expectedErr: "empty query parameter name"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].Endpoint = tc.endpoint
+			cfg.Partners["partner-1"].QueryParams = tc.queryParams
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+// TestEndpointTemplateAuction tests that auctions call the resolved URL, and that leads missing a
+// templated field skip the partner without counting against it
+func TestEndpointTemplateAuction(t *testing.T) {
+	paths := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.RequestURI()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "templated-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/templated"})
+	}))
+	defer server.Close()
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"templated": {ID: "templated", Endpoint: server.URL + "/bid/{vertical}/{state}", QueryParams: map[string]string{"zip": "{zip}"},
+			APIKey: "key", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 2, Cooldown: time.Minute}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		request := newTemplateTestRequest()
+		request.Geo = nil
+		_, err := service.RunAuction(ctx, request)
+		require.ErrorIs(t, err, services.ErrNoValidBids)
+	}
+	assert.Zero(t, service.GetPartnerStats()["templated"])
+
+	response, err := service.RunAuction(ctx, newTemplateTestRequest())
+	require.NoError(t, err)
+	require.Len(t, response.Bids, 1)
+	assert.Equal(t, "/bid/auto/TX?zip=78701", <-paths)
+	assert.Empty(t, paths)
+}
@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// deadEndpoint returns the URL of a server that has been shut down, so calls are refused
+func deadEndpoint() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+// newCountingPartner starts a partner that bids with bidID and counts its calls
+func newCountingPartner(t *testing.T, bidID string) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		json.NewEncoder(w).Encode(models.Bid{ID: bidID, Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/" + bidID})
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// newEndpointTestService creates a service with one partner served by endpoints
+func newEndpointTestService(t *testing.T, endpoints []config.EndpointConfig) *services.AuctionService {
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-a": {ID: "partner-a", Endpoints: endpoints, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 2, Cooldown: time.Minute}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	return service
+}
+
+// runEndpointTestAuction runs an auction and returns the winning bid ID
+func runEndpointTestAuction(t *testing.T, service *services.AuctionService) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "endpoint-test", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	return response.Bids[0].ID
+}
+
+// TestPartnerEndpointFailover tests that a refused primary fails over to the backup within the
+// auction and stops being tried first once its breaker opens
+func TestPartnerEndpointFailover(t *testing.T) {
+	primary := deadEndpoint()
+	backup, backupCalls := newCountingPartner(t, "bid-backup")
+	service := newEndpointTestService(t, []config.EndpointConfig{
+		{URL: primary, Weight: 1, Region: "us-east"},
+		{URL: backup.URL, Weight: 0, Region: "us-west"},
+	})
+
+	for i := 0; i < 4; i++ {
+		assert.Equal(t, "bid-backup", runEndpointTestAuction(t, service))
+	}
+
+	statuses := service.PartnerStatuses()
+	require.Len(t, statuses, 1)
+	assert.Equal(t, services.BreakerClosed, statuses[0].BreakerState)
+	require.Len(t, statuses[0].Endpoints, 2)
+
+	// The primary is skipped once its breaker opens after two refusals
+	primaryStatus, backupStatus := statuses[0].Endpoints[0], statuses[0].Endpoints[1]
+	assert.Equal(t, "us-east", primaryStatus.Region)
+	assert.Equal(t, services.BreakerOpen, primaryStatus.BreakerState)
+	assert.Equal(t, 2, primaryStatus.Failures)
+	assert.Equal(t, services.BreakerClosed, backupStatus.BreakerState)
+	assert.Equal(t, 4, backupStatus.Successes)
+	assert.Equal(t, int32(4), atomic.LoadInt32(backupCalls))
+}
+
+// TestPartnerEndpointWeights tests that first attempts are spread across endpoints by weight
+func TestPartnerEndpointWeights(t *testing.T) {
+	heavy, heavyCalls := newCountingPartner(t, "bid-heavy")
+	light, lightCalls := newCountingPartner(t, "bid-light")
+	service := newEndpointTestService(t, []config.EndpointConfig{
+		{URL: heavy.URL, Weight: 3},
+		{URL: light.URL, Weight: 1},
+	})
+
+	const auctions = 400
+	for i := 0; i < auctions; i++ {
+		runEndpointTestAuction(t, service)
+	}
+
+	assert.Equal(t, int32(auctions), atomic.LoadInt32(heavyCalls)+atomic.LoadInt32(lightCalls))
+	assert.InDelta(t, 0.75, float64(atomic.LoadInt32(heavyCalls))/auctions, 0.1)
+}
+
+// TestPartnerSingleEndpointStatus tests that the single Endpoint field is reported as one endpoint
+func TestPartnerSingleEndpointStatus(t *testing.T) {
+	server, _ := newCountingPartner(t, "bid-1")
+	service, err := services.NewAuctionService(newTestConfig(map[string]*config.PartnerConfig{
+		"partner-a": {ID: "partner-a", Endpoint: server.URL, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true},
+	}))
+	require.NoError(t, err)
+
+	assert.Equal(t, "bid-1", runEndpointTestAuction(t, service))
+
+	endpoints := service.PartnerStatuses()[0].Endpoints
+	require.Len(t, endpoints, 1)
+	assert.Equal(t, server.URL, endpoints[0].URL)
+	assert.Equal(t, 1, endpoints[0].Weight)
+	assert.Equal(t, 1, endpoints[0].Successes)
+	assert.Greater(t, endpoints[0].AvgLatencyMs, 0.0)
+}
+
+// TestPartnerEndpointValidation tests endpoint list requirements
+func TestPartnerEndpointValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		endpoint    string
+		endpoints   []config.EndpointConfig
+		expectedErr string
+	}{
+		{name: "Single Endpoint", endpoint: "http://partner-1"},
+		{name: "Primary And Backup", endpoints: []config.EndpointConfig{{URL: "http://east", Weight: 1}, {URL: "http://west"}}},
+		{name: "No Endpoint", expectedErr: "missing endpoint for partner"},
+		{name: "Empty URL", endpoints: []config.EndpointConfig{{Weight: 1}}, expectedErr: "missing endpoint URL"},
+		{name: "Duplicate URL", endpoints: []config.EndpointConfig{{URL: "http://east", Weight: 1}, {URL: "http://east"}}, expectedErr: "duplicate endpoint"},
+		{name: "Negative Weight", endpoints: []config.EndpointConfig{{URL: "http://east", Weight: 1}, {URL: "http://west", Weight: -1}}, expectedErr: "invalid weight"},
+		{name: "Only Backups", endpoints: []config.EndpointConfig{{URL: "http://east"}, {URL: "http://west"}}, expectedErr: "positive weight"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].Endpoint = tc.endpoint
+			cfg.Partners["partner-1"].Endpoints = tc.endpoints
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package tests
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// TestEnrichRequestDevice tests User-Agent device classification and that caller-supplied fields win
+func TestEnrichRequestDevice(t *testing.T) {
+	cfg := newStrategyTestConfig()
+	cfg.Enrichment = &config.EnrichmentConfig{ParseUserAgent: true}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name         string
+		userAgent    string
+		device       *models.Device
+		expectedType string
+	}{
+		{
+			name:         "iPhone",
+			userAgent:    "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			expectedType: models.DeviceTypeMobile,
+		},
+		{
+			name:         "iPad",
+			userAgent:    "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			expectedType: models.DeviceTypeTablet,
+		},
+		{
+			name:         "Desktop Chrome",
+			userAgent:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			expectedType: models.DeviceTypeDesktop,
+		},
+		{
+			name:         "Crawler",
+			userAgent:    "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expectedType: models.DeviceTypeBot,
+		},
+		{
+			name:         "Caller Supplied",
+			userAgent:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			device:       &models.Device{Type: models.DeviceTypeMobile},
+			expectedType: models.DeviceTypeMobile,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := &models.BidRequest{RequestID: "enrich-1", Device: tc.device}
+			service.EnrichRequest(request, "203.0.113.7", tc.userAgent)
+
+			require.NotNil(t, request.Device)
+			assert.Equal(t, tc.expectedType, request.Device.Type)
+			// No GeoIP database is configured, so geo stays empty rather than failing
+			assert.Nil(t, request.Geo)
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// TestEstimate tests estimates from regional and vertical-wide clearing prices and the static fallback
+func TestEstimate(t *testing.T) {
+	clock := &steppingClock{now: time.Date(2030, 6, 1, 12, 30, 0, 0, time.UTC)}
+	service, cfg := newAnalyticsTestService(t, clock, 24)
+	cfg.Estimates = &config.EstimatesConfig{
+		Enabled:               true,
+		Window:                24 * time.Hour,
+		MinSamples:            4,
+		HighConfidenceSamples: 8,
+		CacheMaxAge:           5 * time.Minute,
+		Static:                map[string]config.StaticEstimate{"life": {Low: 5, High: 12}},
+	}
+
+	for i := 0; i < 3; i++ {
+		runAnalyticsAuction(t, service, "auto", "TX")
+	}
+	runAnalyticsAuction(t, service, "auto", "CA")
+	runAnalyticsAuction(t, service, "life", "")
+
+	testCases := []struct {
+		name          string
+		vertical      string
+		state         string
+		expected      *services.Estimate
+		expectedError error
+	}{
+		{name: "State Data", vertical: "auto", state: "TX", expected: &services.Estimate{Vertical: "auto", State: "TX", Low: 10, High: 20, Confidence: services.EstimateConfidenceMedium, Source: services.EstimateSourceAnalytics, Samples: 6}},
+		{name: "Thin State Data", vertical: "auto", state: "CA", expected: &services.Estimate{Vertical: "auto", Low: 10, High: 20, Confidence: services.EstimateConfidenceHigh, Source: services.EstimateSourceAnalytics, Samples: 8}},
+		{name: "Vertical Data", vertical: "auto", expected: &services.Estimate{Vertical: "auto", Low: 10, High: 20, Confidence: services.EstimateConfidenceHigh, Source: services.EstimateSourceAnalytics, Samples: 8}},
+		{name: "Static Fallback", vertical: "life", state: "TX", expected: &services.Estimate{Vertical: "life", Low: 5, High: 12, Confidence: services.EstimateConfidenceLow, Source: services.EstimateSourceStatic}},
+		{name: "No Estimate", vertical: "home", expectedError: services.ErrNoEstimate},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			estimate, err := service.Estimate(tc.vertical, tc.state)
+			if tc.expectedError != nil {
+				require.ErrorIs(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.InDelta(t, tc.expected.Low, estimate.Low, tc.expected.Low*0.01)
+			assert.InDelta(t, tc.expected.High, estimate.High, tc.expected.High*0.01)
+			estimate.Low, estimate.High = tc.expected.Low, tc.expected.High
+			assert.Equal(t, tc.expected, estimate)
+		})
+	}
+
+	cfg.Estimates.Enabled = false
+	_, err := service.Estimate("auto", "")
+	assert.ErrorIs(t, err, services.ErrEstimatesDisabled)
+}
+
+// TestEstimateEndpoint tests query parsing, caching headers, and error statuses for the estimate endpoint
+func TestEstimateEndpoint(t *testing.T) {
+	clock := &steppingClock{now: time.Date(2030, 6, 1, 12, 30, 0, 0, time.UTC)}
+	service, cfg := newAnalyticsTestService(t, clock, 24)
+	cfg.Estimates = &config.EstimatesConfig{Enabled: true, Window: time.Hour, MinSamples: 1, HighConfidenceSamples: 10, CacheMaxAge: 5 * time.Minute}
+	runAnalyticsAuction(t, service, "auto", "TX")
+
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	router := gin.New()
+	router.GET("/v1/estimates", handler.HandleEstimate)
+
+	testCases := []struct {
+		name                 string
+		query                string
+		expectedStatus       int
+		expectedCacheControl string
+	}{
+		{name: "Estimate", query: "?vertical=auto&state=TX", expectedStatus: http.StatusOK, expectedCacheControl: "public, max-age=300"},
+		{name: "Missing Vertical", query: "?state=TX", expectedStatus: http.StatusBadRequest},
+		{name: "No Estimate", query: "?vertical=home", expectedStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/v1/estimates"+tc.query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tc.expectedStatus, w.Code)
+			assert.Equal(t, tc.expectedCacheControl, w.Header().Get("Cache-Control"))
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			// Estimates carry only the range and how it was derived, never partner identities
+			var body map[string]any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.ElementsMatch(t, []string{"vertical", "state", "low", "high", "confidence", "source", "samples"}, keysOf(body))
+		})
+	}
+}
+
+// keysOf returns the keys of a decoded JSON object
+func keysOf(object map[string]any) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	return keys
+}
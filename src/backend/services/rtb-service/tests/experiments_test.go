@@ -0,0 +1,242 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// experimentWeight returns a pointer to a quality weight
+func experimentWeight(weight float64) *float64 {
+	return &weight
+}
+
+// newExperimentTestConfig returns a config with two partners where partner-b wins on quality:
+// bid-a is 10 with no quality score and bid-b is 9 with a quality score of 0.5
+func newExperimentTestConfig(t *testing.T, experiments map[string]*config.ExperimentConfig) *config.Config {
+	partnerA := newPartnerServer(t, models.Bid{ID: "bid-a", Price: 10.0, ClickURL: "http://example.com/a"})
+	partnerB := newPartnerServer(t, models.Bid{ID: "bid-b", Price: 9.0, QualityScore: 0.5, ClickURL: "http://example.com/b"})
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-a": {ID: "partner-a", Endpoint: partnerA.URL, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true},
+		"partner-b": {ID: "partner-b", Endpoint: partnerB.URL, APIKey: "key-b", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.Experiments = experiments
+	return cfg
+}
+
+// runExperimentTestAuction runs an auction for a lead
+func runExperimentTestAuction(t *testing.T, service *services.AuctionService, leadID string) *models.BidResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "experiment-" + leadID, LeadID: leadID, Vertical: "auto"})
+	require.NoError(t, err)
+	return response
+}
+
+// TestExperimentValidation tests experiment traffic, bucketing, and override bounds, and that
+// experiments cannot override the same parameter
+func TestExperimentValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		experiments map[string]*config.ExperimentConfig
+		expectedErr string
+	}{
+		{
+			name: "Independent Experiments",
+			experiments: map[string]*config.ExperimentConfig{
+				"weight": {TrafficPercent: 10, Overrides: config.ExperimentOverrides{QualityWeight: experimentWeight(0.5)}},
+				"floor":  {TrafficPercent: 5, BucketBy: "user_data.email_hash", Overrides: config.ExperimentOverrides{Floor: 2}},
+			},
+		},
+		{
+			name:        "No Traffic",
+			experiments: map[string]*config.ExperimentConfig{"weight": {Overrides: config.ExperimentOverrides{QualityWeight: experimentWeight(0.5)}}},
+			expectedErr: "traffic percent",
+		},
+		{
+			name:        "Traffic Above 100",
+			experiments: map[string]*config.ExperimentConfig{"weight": {TrafficPercent: 101, Overrides: config.ExperimentOverrides{QualityWeight: experimentWeight(0.5)}}},
+			expectedErr: "traffic percent",
+		},
+		{
+			name:        "Unknown Bucketing Key",
+			experiments: map[string]*config.ExperimentConfig{"weight": {TrafficPercent: 10, BucketBy: "ip", Overrides: config.ExperimentOverrides{QualityWeight: experimentWeight(0.5)}}},
+			expectedErr: "unknown bucketing key",
+		},
+		{
+			name:        "No Overrides",
+			experiments: map[string]*config.ExperimentConfig{"empty": {TrafficPercent: 10}},
+			expectedErr: "overrides no parameters",
+		},
+		{
+			name:        "Quality Weight Out Of Range",
+			experiments: map[string]*config.ExperimentConfig{"weight": {TrafficPercent: 10, Overrides: config.ExperimentOverrides{QualityWeight: experimentWeight(1.5)}}},
+			expectedErr: "quality weight",
+		},
+		{
+			name:        "Unknown Strategy",
+			experiments: map[string]*config.ExperimentConfig{"strategy": {TrafficPercent: 10, Overrides: config.ExperimentOverrides{Strategy: "highest_bid"}}},
+			expectedErr: "unknown optimization strategy",
+		},
+		{
+			name:        "Floor Above Max Price",
+			experiments: map[string]*config.ExperimentConfig{"floor": {TrafficPercent: 10, Overrides: config.ExperimentOverrides{Floor: 500}}},
+			expectedErr: "floor",
+		},
+		{
+			name: "Overlapping Parameters",
+			experiments: map[string]*config.ExperimentConfig{
+				"floor-a": {TrafficPercent: 10, Overrides: config.ExperimentOverrides{Floor: 2}},
+				"floor-b": {TrafficPercent: 10, Overrides: config.ExperimentOverrides{Floor: 3, Strategy: config.StrategyQualityWeighted}},
+			},
+			expectedErr: "experiments floor-a and floor-b both override floor",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Experiments = tc.experiments
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+// TestExperimentTreatments tests that auctions in a treatment run with its parameters and auctions
+// left out of the experiment run with the configured ones
+func TestExperimentTreatments(t *testing.T) {
+	testCases := []struct {
+		name                string
+		experiment          *config.ExperimentConfig
+		expectedWinner      string
+		expectedAssignments []models.ExperimentAssignment
+	}{
+		{
+			name:                "Quality Weight Treatment",
+			experiment:          &config.ExperimentConfig{TrafficPercent: 100, Overrides: config.ExperimentOverrides{QualityWeight: experimentWeight(0)}},
+			expectedWinner:      "bid-a",
+			expectedAssignments: []models.ExperimentAssignment{{ID: "test", Variant: config.ExperimentVariantTreatment}},
+		},
+		{
+			name:                "Floor Treatment",
+			experiment:          &config.ExperimentConfig{TrafficPercent: 100, Overrides: config.ExperimentOverrides{Floor: 9.5}},
+			expectedWinner:      "bid-a",
+			expectedAssignments: []models.ExperimentAssignment{{ID: "test", Variant: config.ExperimentVariantTreatment}},
+		},
+		{
+			name:                "Strategy Treatment",
+			experiment:          &config.ExperimentConfig{TrafficPercent: 100, BucketBy: config.ExperimentBucketRequestID, Overrides: config.ExperimentOverrides{Strategy: config.StrategyQualityWeighted}},
+			expectedWinner:      "bid-b",
+			expectedAssignments: []models.ExperimentAssignment{{ID: "test", Variant: config.ExperimentVariantTreatment}},
+		},
+		{
+			name:           "Missing Bucketing Key",
+			experiment:     &config.ExperimentConfig{TrafficPercent: 100, BucketBy: "user_data.cohort", Overrides: config.ExperimentOverrides{QualityWeight: experimentWeight(0)}},
+			expectedWinner: "bid-b",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newExperimentTestConfig(t, map[string]*config.ExperimentConfig{"test": tc.experiment})
+			require.NoError(t, cfg.Validate())
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			response := runExperimentTestAuction(t, service, "lead-1")
+			require.Len(t, response.Bids, 1)
+			assert.Equal(t, tc.expectedWinner, response.Bids[0].ID)
+			assert.Equal(t, tc.expectedAssignments, response.Experiments)
+		})
+	}
+}
+
+// TestExperimentBucketing tests that a lead stays in one variant and traffic splits near the configured percentage
+func TestExperimentBucketing(t *testing.T) {
+	cfg := newExperimentTestConfig(t, map[string]*config.ExperimentConfig{
+		"weight": {TrafficPercent: 20, Overrides: config.ExperimentOverrides{QualityWeight: experimentWeight(0)}},
+	})
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	const leads = 500
+	treated := 0
+	for i := 0; i < leads; i++ {
+		leadID := fmt.Sprintf("lead-%d", i)
+		first := runExperimentTestAuction(t, service, leadID)
+		second := runExperimentTestAuction(t, service, leadID)
+		require.Len(t, first.Experiments, 1)
+		assert.Equal(t, first.Experiments, second.Experiments)
+
+		// Treated leads sell to the higher price once quality no longer counts
+		if first.Experiments[0].Variant == config.ExperimentVariantTreatment {
+			treated++
+			assert.Equal(t, "bid-a", first.Bids[0].ID)
+		} else {
+			assert.Equal(t, "bid-b", first.Bids[0].ID)
+		}
+	}
+	assert.InDelta(t, 0.2, float64(treated)/leads, 0.05)
+
+	reports := service.Experiments()
+	require.Len(t, reports, 1)
+	assert.Equal(t, int64(2*treated), reports[0].Variants[config.ExperimentVariantTreatment].Auctions)
+	assert.Equal(t, int64(2*(leads-treated)), reports[0].Variants[config.ExperimentVariantControl].Auctions)
+}
+
+// TestExperimentResults tests that winners, revenue, and webhooks are attributed to the variant an auction ran in
+func TestExperimentResults(t *testing.T) {
+	receiver, server := newWebhookReceiver(t, http.StatusOK)
+	cfg := newExperimentTestConfig(t, map[string]*config.ExperimentConfig{
+		"results-floor": {TrafficPercent: 100, Overrides: config.ExperimentOverrides{Floor: 9.5}},
+	})
+	cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: server.URL})
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	treatment := map[string]string{"experiment": "results-floor", "variant": config.ExperimentVariantTreatment}
+	floorLosses := map[string]string{"partner": "partner-b", "reason": "experiment_floor"}
+	lossesBefore := gatheredMetric(t, "rtb_bid_losses_total", floorLosses)
+	revenueBefore := gatheredMetric(t, "rtb_experiment_revenue_total", treatment)
+
+	runExperimentTestAuction(t, service, "lead-1")
+	runExperimentTestAuction(t, service, "lead-2")
+
+	reports := service.Experiments()
+	require.Len(t, reports, 1)
+	assert.Equal(t, services.ExperimentResult{Auctions: 2, Winners: 2, Revenue: 20}, reports[0].Variants[config.ExperimentVariantTreatment])
+	assert.Equal(t, services.ExperimentResult{}, reports[0].Variants[config.ExperimentVariantControl])
+	assert.Equal(t, 20.0, gatheredMetric(t, "rtb_experiment_revenue_total", treatment)-revenueBefore)
+	assert.Equal(t, lossesBefore+2, gatheredMetric(t, "rtb_bid_losses_total", floorLosses))
+
+	require.Eventually(t, func() bool { return len(receiver.received()) == 4 }, 2*time.Second, 5*time.Millisecond)
+	for _, delivery := range receiver.received() {
+		var event struct {
+			Data struct {
+				Experiments []models.ExperimentAssignment `json:"experiments"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.Unmarshal(delivery.body, &event))
+		assert.Equal(t, []models.ExperimentAssignment{{ID: "results-floor", Variant: config.ExperimentVariantTreatment}}, event.Data.Experiments)
+	}
+}
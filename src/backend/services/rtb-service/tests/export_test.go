@@ -0,0 +1,310 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/export"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// failingStore is a blob store whose writes always fail
+type failingStore struct{}
+
+func (failingStore) Put(ctx context.Context, name string, data []byte) error {
+	return errors.New("store unavailable")
+}
+
+// newExportTestConfig returns an export config writing hourly windows to dir. The flush interval
+// is long enough that only explicit flushes and Close write files during a test.
+func newExportTestConfig(dir string) *config.ExportConfig {
+	return &config.ExportConfig{
+		Enabled:       true,
+		SampleRate:    1,
+		Window:        time.Hour,
+		FlushInterval: time.Hour,
+		MaxFileRows:   100,
+		BufferSize:    100,
+		Dir:           dir,
+	}
+}
+
+// exportFiles returns the names of the files in an export directory, sorted
+func exportFiles(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	require.NoError(t, err)
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+// readExportRows decodes the rows of a newline-delimited JSON export file
+func readExportRows(t *testing.T, path string) []export.Row {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var rows []export.Row
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var row export.Row
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &row))
+		rows = append(rows, row)
+	}
+	require.NoError(t, scanner.Err())
+	return rows
+}
+
+// TestExportWindows tests that rows are written per time window once the window ends, in files
+// named for the window, and that Close writes windows still open
+func TestExportWindows(t *testing.T) {
+	start := time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name            string
+		format          string
+		maxFileRows     int
+		flushAt         time.Duration
+		expectedFlushed []int
+		expectedClosed  []int
+	}{
+		{name: "Open Windows Wait For Close", flushAt: 90 * time.Minute, expectedFlushed: []int{3}, expectedClosed: []int{3, 2}},
+		{name: "Ended Windows", flushAt: 2 * time.Hour, expectedFlushed: []int{3, 2}, expectedClosed: []int{3, 2}},
+		{name: "Nothing Ended", flushAt: 30 * time.Minute, expectedClosed: []int{3, 2}},
+		{name: "Parts At Max File Rows", maxFileRows: 2, flushAt: 30 * time.Minute, expectedFlushed: []int{2, 2}, expectedClosed: []int{2, 1, 2}},
+		{name: "CSV", format: config.ExportFormatCSV, flushAt: 2 * time.Hour, expectedFlushed: []int{3, 2}, expectedClosed: []int{3, 2}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "exports")
+			cfg := newExportTestConfig(dir)
+			cfg.Format = tc.format
+			if tc.maxFileRows > 0 {
+				cfg.MaxFileRows = tc.maxFileRows
+			}
+			clock := &steppingClock{now: start}
+			exporter := export.NewExporter(cfg, &export.DirStore{Dir: dir}, clock)
+
+			exporter.Export([]export.Row{
+				{AuctionTime: start.Add(5 * time.Minute), RequestID: "req-1", PartnerID: "partner-1", BidID: "bid-1", CPL: 10, Won: true, Rank: 1, ClearingPrice: 10},
+				{AuctionTime: start.Add(5 * time.Minute), RequestID: "req-1", PartnerID: "partner-2", BidID: "bid-2", CPL: 8},
+			})
+			exporter.Export([]export.Row{{AuctionTime: start.Add(50 * time.Minute), RequestID: "req-2", PartnerID: "partner-1", BidID: "bid-3", CPL: 9.5}})
+			exporter.Export([]export.Row{
+				{AuctionTime: start.Add(70 * time.Minute), RequestID: "req-3", PartnerID: "partner-1", BidID: "bid-4", CPL: 11},
+				{AuctionTime: start.Add(75 * time.Minute), RequestID: "req-4", PartnerID: "partner-2", BidID: "bid-5", CPL: 7},
+			})
+
+			clock.now = start.Add(tc.flushAt)
+			require.NoError(t, exporter.Flush())
+			flushed := exportFiles(t, dir)
+			require.Len(t, flushed, len(tc.expectedFlushed))
+
+			require.NoError(t, exporter.Close())
+			files := exportFiles(t, dir)
+			require.Len(t, files, len(tc.expectedClosed))
+
+			extension := ".ndjson"
+			if tc.format == config.ExportFormatCSV {
+				extension = ".csv"
+			}
+			total := 0
+			for i, name := range files {
+				assert.True(t, strings.HasSuffix(name, extension), name)
+				assert.False(t, strings.HasPrefix(name, "."), name)
+				if strings.HasPrefix(name, "auctions-20240120T100000Z-20240120T110000Z-") {
+					assert.Less(t, i, 2, "first window files sort first")
+				} else {
+					assert.True(t, strings.HasPrefix(name, "auctions-20240120T110000Z-20240120T120000Z-"), name)
+				}
+
+				var rows int
+				if tc.format == config.ExportFormatCSV {
+					file, err := os.Open(filepath.Join(dir, name))
+					require.NoError(t, err)
+					records, err := csv.NewReader(file).ReadAll()
+					file.Close()
+					require.NoError(t, err)
+					assert.Equal(t, "auction_time", records[0][0])
+					rows = len(records) - 1
+				} else {
+					rows = len(readExportRows(t, filepath.Join(dir, name)))
+				}
+				assert.Equal(t, tc.expectedClosed[i], rows, name)
+				total += rows
+			}
+			assert.Equal(t, 5, total)
+		})
+	}
+}
+
+// TestExportWriteFailure tests that failed writes are counted and their rows dropped
+func TestExportWriteFailure(t *testing.T) {
+	cfg := newExportTestConfig("")
+	clock := &steppingClock{now: time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC)}
+	exporter := export.NewExporter(cfg, failingStore{}, clock)
+
+	failuresBefore := gatheredMetric(t, "rtb_export_write_failures_total", nil)
+	droppedBefore := gatheredMetric(t, "rtb_export_rows_dropped_total", map[string]string{"reason": "write_error"})
+	exportedBefore := gatheredMetric(t, "rtb_export_rows_total", nil)
+
+	exporter.Export([]export.Row{{AuctionTime: clock.now, RequestID: "req-1"}, {AuctionTime: clock.now, RequestID: "req-1"}})
+	clock.now = clock.now.Add(time.Hour)
+	assert.ErrorContains(t, exporter.Flush(), "store unavailable")
+	assert.NoError(t, exporter.Close())
+
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_export_write_failures_total", nil)-failuresBefore)
+	assert.Equal(t, 2.0, gatheredMetric(t, "rtb_export_rows_dropped_total", map[string]string{"reason": "write_error"})-droppedBefore)
+	assert.Equal(t, exportedBefore, gatheredMetric(t, "rtb_export_rows_total", nil))
+}
+
+// TestExportAuction tests that sampled auctions export one row per bid with the auction joined
+// in, and that dry runs are never exported
+func TestExportAuction(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newStrategyTestConfig()
+	cfg.MaxBidsPerRequest = 1
+	cfg.Partners["partner-1"].Endpoint = newPartnerServer(t, models.Bid{ID: "bid-1", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/1"}).URL
+	cfg.Partners["partner-2"].Endpoint = newPartnerServer(t, models.Bid{ID: "bid-2", Price: 8.0, QualityScore: 0.5, ClickURL: "http://example.com/2"}).URL
+	cfg.Export = newExportTestConfig(dir)
+	service, err := services.NewAuctionServiceWithClock(cfg, &steppingClock{now: time.Date(2024, 1, 20, 10, 30, 0, 0, time.UTC)})
+	require.NoError(t, err)
+
+	request := &models.BidRequest{RequestID: "export-test", LeadID: "lead-1", Vertical: "auto",
+		Geo: &models.Geo{Country: "US", Region: "TX"}, Device: &models.Device{Type: "mobile"}}
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	response, err := service.RunAuction(ctx, request)
+	require.NoError(t, err)
+	require.Len(t, response.Bids, 1)
+
+	dryRun := &models.BidRequest{RequestID: "export-dry-run", LeadID: "lead-2", Vertical: "auto"}
+	_, err = service.RunAuction(models.ContextWithDryRun(ctx, models.NewDryRun()), dryRun)
+	require.NoError(t, err)
+	require.NoError(t, service.Close())
+
+	files := exportFiles(t, dir)
+	require.Len(t, files, 1)
+	assert.True(t, strings.HasPrefix(files[0], "auctions-20240120T100000Z-20240120T110000Z-"))
+	rows := readExportRows(t, filepath.Join(dir, files[0]))
+	require.Len(t, rows, 2)
+
+	winners := 0
+	for _, row := range rows {
+		assert.Equal(t, "export-test", row.RequestID)
+		assert.Equal(t, "lead-1", row.LeadID)
+		assert.Equal(t, "TX", row.State)
+		assert.Equal(t, "mobile", row.DeviceType)
+		assert.Equal(t, 2, row.PartnersContacted)
+		assert.Equal(t, 2, row.Bids)
+		assert.True(t, row.Sold)
+		if row.Won {
+			winners++
+			assert.Equal(t, response.Bids[0].PartnerID, row.PartnerID)
+			assert.Equal(t, 1, row.Rank)
+			assert.Equal(t, response.Bids[0].CPL(), row.ClearingPrice)
+		} else {
+			assert.Zero(t, row.Rank)
+			assert.Zero(t, row.ClearingPrice)
+		}
+	}
+	assert.Equal(t, 1, winners)
+}
+
+// TestExportS3Store tests that the S3-compatible store uploads files with signed PUTs
+func TestExportS3Store(t *testing.T) {
+	type upload struct {
+		method, path, authorization, contentHash string
+		body                                     []byte
+	}
+	uploads := make(chan upload, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		uploads <- upload{r.Method, r.URL.EscapedPath(), r.Header.Get("Authorization"), r.Header.Get("X-Amz-Content-Sha256"), body}
+	}))
+	defer server.Close()
+
+	t.Setenv("EXPORT_TEST_SECRET", "secret")
+	store, err := export.NewS3Store(&config.S3ExportConfig{Endpoint: server.URL + "/", Bucket: "training", Prefix: "rtb/auctions/",
+		Region: "us-east-1", AccessKeyID: "test-key", SecretAccessKey: "env:EXPORT_TEST_SECRET"})
+	require.NoError(t, err)
+	require.NoError(t, store.Put(context.Background(), "auctions-1.ndjson", []byte("{}\n")))
+
+	received := <-uploads
+	assert.Equal(t, http.MethodPut, received.method)
+	assert.Equal(t, "/training/rtb/auctions/auctions-1.ndjson", received.path)
+	assert.Equal(t, []byte("{}\n"), received.body)
+	assert.Equal(t, "ca3d163bab055381827226140568f3bef7eaac187cebd76878e0b63e9e442356", received.contentHash)
+	assert.Regexp(t, `^AWS4-HMAC-SHA256 Credential=test-key/\d{8}/us-east-1/s3/aws4_request, `+
+		`SignedHeaders=host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$`, received.authorization)
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer failing.Close()
+	store, err = export.NewS3Store(&config.S3ExportConfig{Endpoint: failing.URL, Bucket: "training", Region: "us-east-1",
+		AccessKeyID: "test-key", SecretAccessKey: "secret"})
+	require.NoError(t, err)
+	assert.ErrorContains(t, store.Put(context.Background(), "auctions-1.ndjson", []byte("{}\n")), "status 403")
+}
+
+// TestExportValidation tests export configuration validation
+func TestExportValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		modify      func(*config.ExportConfig)
+		expectedErr string
+	}{
+		{name: "Valid", modify: func(c *config.ExportConfig) {}},
+		{name: "Disabled", modify: func(c *config.ExportConfig) { c.Enabled, c.SampleRate = false, 0 }},
+		{name: "Sample Rate", modify: func(c *config.ExportConfig) { c.SampleRate = 0 }, expectedErr: "export sample rate"},
+		{name: "Format", modify: func(c *config.ExportConfig) { c.Format = "parquet" }, expectedErr: "unknown export format: parquet"},
+		{name: "Window", modify: func(c *config.ExportConfig) { c.Window = 48 * time.Hour }, expectedErr: "export window must be between 1m and 24h"},
+		{name: "Flush Interval", modify: func(c *config.ExportConfig) { c.FlushInterval = 2 * time.Hour }, expectedErr: "export flush interval"},
+		{name: "No Destination", modify: func(c *config.ExportConfig) { c.Dir = "" }, expectedErr: "exactly one of dir and s3"},
+		{name: "Both Destinations", modify: func(c *config.ExportConfig) {
+			c.S3 = &config.S3ExportConfig{Endpoint: "https://s3.example", Bucket: "b", Region: "r", AccessKeyID: "k", SecretAccessKey: "s"}
+		}, expectedErr: "exactly one of dir and s3"},
+		{name: "S3 Missing Keys", modify: func(c *config.ExportConfig) {
+			c.Dir, c.S3 = "", &config.S3ExportConfig{Endpoint: "https://s3.example", Bucket: "b", Region: "r"}
+		}, expectedErr: "export s3 needs an access key id and secret access key"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Export = newExportTestConfig("/var/lib/rtb/exports")
+			tc.modify(cfg.Export)
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
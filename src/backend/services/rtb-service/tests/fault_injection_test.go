@@ -0,0 +1,202 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newFaultTestConfig returns a config with fault injection enabled, where "flaky" trips its
+// breaker on its first failure and "steady" always bids
+func newFaultTestConfig(t *testing.T) (*config.Config, *recordingPartner) {
+	flaky := newRecordingPartner(t, models.Bid{ID: "flaky-bid", Price: 8.0, QualityScore: 0.5, ClickURL: "http://example.com/flaky"})
+	steady := newPartnerServer(t, models.Bid{ID: "steady-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/steady"})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"flaky":  {ID: "flaky", Endpoint: flaky.server.URL, APIKey: "key-flaky", Timeout: 200 * time.Millisecond, Enabled: true},
+		"steady": {ID: "steady", Endpoint: steady.URL, APIKey: "key-steady", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2), withAdmin())
+	cfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Minute}
+	cfg.FaultInjection = &config.FaultInjectionConfig{Enabled: true}
+	return cfg, flaky
+}
+
+// flakyFailures returns the failures recorded for the flaky partner
+func flakyFailures(service *services.AuctionService) int {
+	for _, status := range service.PartnerStatuses() {
+		if status.ID == "flaky" {
+			return status.Failures
+		}
+	}
+	return 0
+}
+
+// TestFaultInjectionBreakerRecovery tests that an injected error fault opens the partner's
+// breaker, shows in the admin list and metrics, and that the partner recovers once it expires
+func TestFaultInjectionBreakerRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg, flaky := newFaultTestConfig(t)
+	clock := &steppingClock{now: time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC)}
+	service, err := services.NewAuctionServiceWithClock(cfg, clock)
+	require.NoError(t, err)
+	defer service.Close()
+	adminHandler, err := handlers.NewAdminHandler(service, cfg, handlers.BuildInfo{})
+	require.NoError(t, err)
+	router := gin.New()
+	adminHandler.RegisterRoutes(router.Group("/admin"))
+	admin := map[string]string{"X-Admin-Key": testAdminKey}
+
+	w := serveOverrideTest(router, http.MethodPost, "/admin/faults",
+		`{"kind": "error", "partner_id": "flaky", "percentage": 100, "ttl": "5m", "reason": "breaker drill", "requested_by": "oncall"}`, admin)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Fault services.Fault `json:"fault"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	var listed struct {
+		Enabled bool             `json:"enabled"`
+		Faults  []services.Fault `json:"faults"`
+	}
+	w = serveOverrideTest(router, http.MethodGet, "/admin/faults", "", admin)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.True(t, listed.Enabled)
+	require.Len(t, listed.Faults, 1)
+	assert.Equal(t, services.FaultError, listed.Faults[0].Kind)
+	assert.Equal(t, "breaker drill", listed.Faults[0].Reason)
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_faults_active", map[string]string{"kind": "error", "partner": "flaky"}))
+
+	// The injected error fails flaky before its request is sent, tripping its breaker
+	runBreakerStateAuction(t, service)
+	assert.Equal(t, services.BreakerOpen, service.PartnerBreakerState("flaky"))
+	assert.Equal(t, 1, flakyFailures(service))
+	assert.Empty(t, flaky.received())
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_faults_injected_total", map[string]string{"fault": created.Fault.ID}))
+
+	// Once the fault expires and the cooldown passes, the half-open probe succeeds
+	clock.now = clock.now.Add(6 * time.Minute)
+	assert.Empty(t, service.Faults())
+	assert.Equal(t, 0.0, gatheredMetric(t, "rtb_faults_active", map[string]string{"kind": "error", "partner": "flaky"}))
+	runBreakerStateAuction(t, service)
+	assert.Len(t, flaky.received(), 1)
+	assert.Equal(t, services.BreakerClosed, service.PartnerBreakerState("flaky"))
+
+	w = serveOverrideTest(router, http.MethodDelete, "/admin/faults/"+created.Fault.ID, "", admin)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestFaultInjectionKinds tests that latency and malformed faults fail partner calls like a slow
+// or broken partner, and that Redis faults fail Redis operations until lifted
+func TestFaultInjectionKinds(t *testing.T) {
+	testCases := []struct {
+		name  string
+		fault services.Fault
+	}{
+		{name: "Latency", fault: services.Fault{Kind: services.FaultLatency, PartnerID: "flaky", Percentage: 100, Latency: time.Second}},
+		{name: "Malformed", fault: services.Fault{Kind: services.FaultMalformed, PartnerID: "flaky", Percentage: 100}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg, flaky := newFaultTestConfig(t)
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			tc.fault.Reason, tc.fault.RequestedBy = "drill", "oncall"
+			_, err = service.AddFault(tc.fault, time.Minute)
+			require.NoError(t, err)
+
+			runBreakerStateAuction(t, service)
+			assert.Equal(t, 1, flakyFailures(service))
+			assert.Empty(t, flaky.received())
+		})
+	}
+
+	t.Run("Redis", func(t *testing.T) {
+		cfg, _ := newFaultTestConfig(t)
+		redisServer := miniredis.RunT(t)
+		host, portText, err := net.SplitHostPort(redisServer.Addr())
+		require.NoError(t, err)
+		port, err := strconv.Atoi(portText)
+		require.NoError(t, err)
+		cfg.Redis = &config.RedisConfig{Host: host, Port: port, Timeout: time.Second}
+		service, err := services.NewAuctionService(cfg)
+		require.NoError(t, err)
+		defer service.Close()
+
+		fault, err := service.AddFault(services.Fault{Kind: services.FaultRedis, Percentage: 100, Reason: "drill", RequestedBy: "oncall"}, time.Minute)
+		require.NoError(t, err)
+		assert.ErrorIs(t, service.CheckRedis(context.Background()), services.ErrInjectedFault)
+
+		require.NoError(t, service.RemoveFault(fault.ID, "oncall"))
+		assert.NoError(t, service.CheckRedis(context.Background()))
+	})
+}
+
+// TestAddFaultValidation tests that faults are refused where injection is disabled or guarded,
+// and without a bounded TTL, a reason, or a valid target
+func TestAddFaultValidation(t *testing.T) {
+	valid := services.Fault{Kind: services.FaultError, PartnerID: "flaky", Percentage: 50, Reason: "drill", RequestedBy: "oncall"}
+	with := func(change func(*services.Fault)) services.Fault {
+		fault := valid
+		change(&fault)
+		return fault
+	}
+
+	testCases := []struct {
+		name        string
+		disabled    bool
+		environment string
+		fault       services.Fault
+		ttl         time.Duration
+		expectedErr error
+	}{
+		{name: "Valid", fault: valid, ttl: time.Minute},
+		{name: "Staging", environment: "staging", fault: valid, ttl: time.Minute},
+		{name: "Disabled", disabled: true, fault: valid, ttl: time.Minute, expectedErr: services.ErrFaultsDisabled},
+		{name: "Production", environment: "production", fault: valid, ttl: time.Minute, expectedErr: services.ErrFaultsDisabled},
+		{name: "Prod", environment: "Prod", fault: valid, ttl: time.Minute, expectedErr: services.ErrFaultsDisabled},
+		{name: "TTL Too Long", fault: valid, ttl: 2 * time.Hour, expectedErr: services.ErrFaultTTL},
+		{name: "No Reason", fault: with(func(f *services.Fault) { f.Reason = "" }), ttl: time.Minute, expectedErr: services.ErrFaultReason},
+		{name: "Unknown Kind", fault: with(func(f *services.Fault) { f.Kind = "timeout" }), ttl: time.Minute, expectedErr: services.ErrFaultKind},
+		{name: "No Percentage", fault: with(func(f *services.Fault) { f.Percentage = 0 }), ttl: time.Minute, expectedErr: services.ErrFaultPercent},
+		{name: "Latency Without Duration", fault: with(func(f *services.Fault) { f.Kind = services.FaultLatency }), ttl: time.Minute, expectedErr: services.ErrFaultLatency},
+		{name: "Latency Too Long", fault: with(func(f *services.Fault) { f.Kind, f.Latency = services.FaultLatency, time.Minute }), ttl: time.Minute, expectedErr: services.ErrFaultLatency},
+		{name: "Unknown Partner", fault: with(func(f *services.Fault) { f.PartnerID = "partner-9" }), ttl: time.Minute, expectedErr: services.ErrFaultPartner},
+		{name: "Redis With Partner", fault: with(func(f *services.Fault) { f.Kind = services.FaultRedis }), ttl: time.Minute, expectedErr: services.ErrFaultPartner},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(config.EnvironmentVar, tc.environment)
+			cfg, _ := newFaultTestConfig(t)
+			cfg.FaultInjection.Enabled = !tc.disabled
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			_, err = service.AddFault(tc.fault, tc.ttl)
+			if tc.expectedErr == nil {
+				assert.NoError(t, err)
+				assert.Len(t, service.Faults(), 1)
+			} else {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Empty(t, service.Faults())
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// Values the fields policies under test withhold
+const (
+	withheldSSN   = "123-45-6789"
+	withheldZip   = "90210"
+	withheldEmail = "lead@example.com"
+)
+
+// bodyRecorder is a partner or sandbox endpoint that records raw request bodies and never bids
+type bodyRecorder struct {
+	server *httptest.Server
+	mutex  sync.Mutex
+	bodies []string
+}
+
+// newBodyRecorder starts an endpoint that records request bodies and answers with a no-bid
+func newBodyRecorder(t *testing.T) *bodyRecorder {
+	recorder := &bodyRecorder{}
+	recorder.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		recorder.mutex.Lock()
+		recorder.bodies = append(recorder.bodies, string(body))
+		recorder.mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(recorder.server.Close)
+	return recorder
+}
+
+// received returns the recorded bodies
+func (r *bodyRecorder) received() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]string(nil), r.bodies...)
+}
+
+// TestFieldsPolicyPayloads tests that withheld fields and UserData keys never reach the serialized
+// partner request, whatever the adapter, mapping, or mirror
+func TestFieldsPolicyPayloads(t *testing.T) {
+	deny := &config.FieldsPolicy{Mode: config.FieldsPolicyDeny, Fields: []string{"geo", "consent"}, UserData: []string{"ssn"}}
+	allow := &config.FieldsPolicy{Mode: config.FieldsPolicyAllow, Fields: []string{"request_id", "vertical"}, UserData: []string{"age"}}
+	passThrough := &config.FieldMapping{
+		Request: map[string]string{
+			"ref":        "request_id",
+			"ext.ssn":    "user_data.ssn",
+			"ext.zip":    "geo.zip",
+			"ext.tcf":    "consent.consent_string",
+			"ext.all":    "user_data",
+			"ext.device": "device",
+		},
+		Response: map[string]string{"id": "id", "price": "price", "click_url": "url"},
+	}
+
+	testCases := []struct {
+		name        string
+		format      string
+		mapping     *config.FieldMapping
+		policy      *config.FieldsPolicy
+		mirror      bool
+		expected    []string
+		notExpected []string
+	}{
+		{name: "JSON Deny", format: config.FormatJSON, policy: deny,
+			expected: []string{`"age":42`, withheldEmail}, notExpected: []string{withheldSSN, withheldZip, sampleTCFString, `"geo"`, `"consent"`, `"ssn"`}},
+		{name: "JSON Allow", format: config.FormatJSON, policy: allow,
+			expected: []string{`"request_id"`, `"vertical":"auto"`, `"age":42`}, notExpected: []string{withheldSSN, withheldEmail, withheldZip, sampleTCFString, `"lead_id"`, `"timeout"`, `"timestamp"`, `"device"`}},
+		{name: "XML Deny", format: config.FormatXML, policy: deny,
+			expected: []string{withheldEmail}, notExpected: []string{withheldSSN, withheldZip, "<Geo>"}},
+		{name: "Mapped Pass-Through Deny", format: config.FormatMappedJSON, mapping: passThrough, policy: deny,
+			expected: []string{withheldEmail}, notExpected: []string{withheldSSN, withheldZip, sampleTCFString}},
+		{name: "Mapped Pass-Through Allow", format: config.FormatMappedJSON, mapping: passThrough, policy: allow,
+			expected: []string{`"age":42`}, notExpected: []string{withheldSSN, withheldEmail, withheldZip, sampleTCFString, "mobile"}},
+		{name: "Mirror Deny", format: config.FormatJSON, policy: deny, mirror: true,
+			expected: []string{withheldEmail}, notExpected: []string{withheldSSN, withheldZip, sampleTCFString}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partner := newBodyRecorder(t)
+			sandbox := newBodyRecorder(t)
+			partnerConfig := &config.PartnerConfig{
+				ID: "minimized", Endpoint: partner.server.URL, APIKey: "key-minimized", Timeout: 200 * time.Millisecond,
+				Enabled: true, Format: tc.format, FieldMapping: tc.mapping, Fields: tc.policy,
+			}
+			if tc.mirror {
+				partnerConfig.Mirror = &config.PartnerMirror{Endpoint: sandbox.server.URL, Timeout: 100 * time.Millisecond, MaxQPS: 10}
+			}
+			cfg := newTestConfig(map[string]*config.PartnerConfig{"minimized": partnerConfig})
+			service := newTestService(t, cfg)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			// The partner never bids, so the auction has no winners
+			service.RunAuction(ctx, &models.BidRequest{
+				RequestID: "fields-policy",
+				LeadID:    "lead-1",
+				Vertical:  "auto",
+				UserData:  map[string]interface{}{"ssn": withheldSSN, "email": withheldEmail, "age": 42},
+				Consent:   &models.Consent{GDPRApplies: true, ConsentString: sampleTCFString},
+				Geo:       &models.Geo{Country: "US", Zip: withheldZip},
+				Device:    &models.Device{Type: "mobile"},
+			})
+
+			recorder := partner
+			if tc.mirror {
+				recorder = sandbox
+				assert.Eventually(t, func() bool { return len(sandbox.received()) == 1 }, time.Second, 10*time.Millisecond)
+			}
+			require.Len(t, recorder.received(), 1)
+			body := recorder.received()[0]
+			for _, value := range tc.expected {
+				assert.Contains(t, body, value)
+			}
+			for _, value := range tc.notExpected {
+				assert.NotContains(t, body, value)
+			}
+		})
+	}
+}
+
+// TestFieldsPolicyValidation tests that fields policies only name known fields and never withhold
+// a field the partner's templates read
+func TestFieldsPolicyValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		policy      *config.FieldsPolicy
+		endpoint    string
+		schemas     map[string]*config.UserDataSchema
+		expectedErr string
+	}{
+		{name: "Deny", policy: &config.FieldsPolicy{Mode: config.FieldsPolicyDeny, Fields: []string{"geo"}, UserData: []string{"ssn"}}},
+		{name: "Allow", policy: &config.FieldsPolicy{Mode: config.FieldsPolicyAllow, Fields: []string{"request_id", "user_data"}}},
+		{name: "Unknown Mode", policy: &config.FieldsPolicy{Mode: "redact"}, expectedErr: "unknown fields policy mode"},
+		{name: "Unknown Field", policy: &config.FieldsPolicy{Mode: config.FieldsPolicyDeny, Fields: []string{"ext"}}, expectedErr: `unknown request field "ext"`},
+		{name: "Repeated Field", policy: &config.FieldsPolicy{Mode: config.FieldsPolicyDeny, Fields: []string{"geo", "geo"}}, expectedErr: "twice"},
+		{name: "Denied User Data With Keys", policy: &config.FieldsPolicy{Mode: config.FieldsPolicyDeny, Fields: []string{"user_data"}, UserData: []string{"ssn"}},
+			expectedErr: "denies user_data and lists user data keys"},
+		{name: "Empty Key", policy: &config.FieldsPolicy{Mode: config.FieldsPolicyDeny, UserData: []string{""}}, expectedErr: "empty or repeated user data key"},
+		{name: "Key Outside Schemas", policy: &config.FieldsPolicy{Mode: config.FieldsPolicyAllow, UserData: []string{"ssn"}},
+			schemas:     map[string]*config.UserDataSchema{"auto": {Fields: map[string]*config.UserDataField{"zip": {Type: config.UserDataTypeString}}}},
+			expectedErr: `user data key "ssn" outside every vertical schema`},
+		{name: "Key In Schema", policy: &config.FieldsPolicy{Mode: config.FieldsPolicyAllow, UserData: []string{"zip"}},
+			schemas: map[string]*config.UserDataSchema{"auto": {Fields: map[string]*config.UserDataField{"zip": {Type: config.UserDataTypeString}}}}},
+		{name: "Withholds Templated Field", policy: &config.FieldsPolicy{Mode: config.FieldsPolicyDeny, Fields: []string{"geo"}},
+			endpoint: "http://partner1.example.com/bid/{state}", expectedErr: "withholds geo"},
+		{name: "Allows Templated Field", policy: &config.FieldsPolicy{Mode: config.FieldsPolicyAllow, Fields: []string{"vertical"}},
+			endpoint: "http://partner1.example.com/bid/{vertical}"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].Fields = tc.policy
+			if tc.endpoint != "" {
+				cfg.Partners["partner-1"].Endpoint = tc.endpoint
+			}
+			cfg.UserDataSchemas = tc.schemas
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+// TestFieldsPolicyPartnerStatus tests that the admin partner view shows each partner's policy
+func TestFieldsPolicyPartnerStatus(t *testing.T) {
+	cfg := newStrategyTestConfig()
+	policy := &config.FieldsPolicy{Mode: config.FieldsPolicyAllow, Fields: []string{"request_id"}}
+	cfg.Partners["partner-1"].Fields = policy
+	service := newTestService(t, cfg)
+
+	for _, status := range service.PartnerStatuses() {
+		if status.ID == "partner-1" {
+			assert.Equal(t, policy, status.Fields)
+		} else {
+			assert.Nil(t, status.Fields)
+		}
+	}
+}
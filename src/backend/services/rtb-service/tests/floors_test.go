@@ -0,0 +1,237 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newFloorTestConfig returns a config for two partners bidding 10 and 20 with regional price
+// analytics and the given adaptive floors
+func newFloorTestConfig(t *testing.T, floors *config.AdaptiveFloorConfig) *config.Config {
+	low := newAnalyticsPartner(t, "low", 10.0)
+	high := newAnalyticsPartner(t, "high", 20.0)
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"low":  {ID: "low", Endpoint: low.URL, APIKey: "key-low", Timeout: 200 * time.Millisecond, Enabled: true},
+		"high": {ID: "high", Endpoint: high.URL, APIKey: "key-high", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2))
+	cfg.Analytics = &config.AnalyticsConfig{Enabled: true, Windows: 24, MaxSeries: 10, Precision: 0.01, ByRegion: true, Floors: floors}
+	return cfg
+}
+
+// runFloorAuction runs an auction for auto leads in region with debug output
+func runFloorAuction(t *testing.T, service *services.AuctionService, requestID, region string) (*models.BidResponse, *models.DebugInfo) {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	debug := models.NewDebugInfo()
+	response, err := service.RunAuction(models.ContextWithDebug(ctx, debug), &models.BidRequest{
+		RequestID: requestID,
+		LeadID:    "lead-1",
+		Vertical:  "auto",
+		Geo:       &models.Geo{Region: region},
+	})
+	require.NoError(t, err)
+	return response, debug
+}
+
+// TestAdaptiveFloorSuggestions tests the floors suggested by the analytics endpoint: the clearing-price
+// percentile bounded by the configured floors, or the static floor while data is sparse
+func TestAdaptiveFloorSuggestions(t *testing.T) {
+	testCases := []struct {
+		name            string
+		auctions        int
+		minFloor        float64
+		maxFloor        float64
+		expectedFloor   float64
+		expectedSource  string
+		expectedSamples uint64
+	}{
+		{name: "Sparse Data", auctions: 2, maxFloor: 50, expectedFloor: 0.01, expectedSource: models.FloorSourceStatic, expectedSamples: 4},
+		{name: "Learned", auctions: 5, maxFloor: 50, expectedFloor: 10, expectedSource: models.FloorSourceLearned, expectedSamples: 10},
+		{name: "Bounded Below", auctions: 5, minFloor: 12, maxFloor: 50, expectedFloor: 12, expectedSource: models.FloorSourceLearned, expectedSamples: 10},
+		{name: "Bounded Above", auctions: 5, maxFloor: 8, expectedFloor: 8, expectedSource: models.FloorSourceLearned, expectedSamples: 10},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newFloorTestConfig(t, &config.AdaptiveFloorConfig{
+				Enabled:         true,
+				Percentile:      0.2,
+				Window:          24 * time.Hour,
+				MinSamples:      10,
+				MinFloor:        tc.minFloor,
+				MaxFloor:        tc.maxFloor,
+				RefreshInterval: time.Minute,
+			})
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+			for i := 0; i < tc.auctions; i++ {
+				runFloorAuction(t, service, "floor-suggestion", "TX")
+			}
+
+			handler, err := handlers.NewBidHandler(service, cfg)
+			require.NoError(t, err)
+			router := gin.New()
+			router.GET("/v1/analytics/floors", handler.HandleAdaptiveFloors)
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, "/v1/analytics/floors?vertical=auto", nil)
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var body struct {
+				Floors []services.FloorSuggestion `json:"floors"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			// Regional series are tracked by analytics but not suggested without regional floors
+			require.Len(t, body.Floors, 1)
+			suggestion := body.Floors[0]
+			assert.Equal(t, "auto", suggestion.Vertical)
+			assert.Empty(t, suggestion.Region)
+			assert.InDelta(t, tc.expectedFloor, suggestion.Floor, tc.expectedFloor*0.01)
+			assert.Equal(t, tc.expectedSource, suggestion.Source)
+			assert.Equal(t, tc.expectedSamples, suggestion.Samples)
+			assert.Equal(t, 0.01, suggestion.StaticFloor)
+			assert.False(t, suggestion.Applied)
+		})
+	}
+
+	t.Run("Disabled", func(t *testing.T) {
+		cfg := newFloorTestConfig(t, nil)
+		service, err := services.NewAuctionService(cfg)
+		require.NoError(t, err)
+		defer service.Close()
+		handler, err := handlers.NewBidHandler(service, cfg)
+		require.NoError(t, err)
+		router := gin.New()
+		router.GET("/v1/analytics/floors", handler.HandleAdaptiveFloors)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/v1/analytics/floors", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// TestAdaptiveFloorApplied tests that applied floors drop open bids below them and are recorded in
+// debug output and auction.completed webhooks, preferring a region's learned floor
+func TestAdaptiveFloorApplied(t *testing.T) {
+	receiver, webhookServer := newWebhookReceiver(t, http.StatusOK)
+	cfg := newFloorTestConfig(t, &config.AdaptiveFloorConfig{
+		Enabled:         true,
+		Apply:           true,
+		Percentile:      0.2,
+		Window:          24 * time.Hour,
+		MinSamples:      4,
+		MinFloor:        15,
+		MaxFloor:        50,
+		ByRegion:        true,
+		RefreshInterval: time.Second,
+	})
+	cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: webhookServer.URL, Events: []string{config.WebhookEventAuctionCompleted}})
+	clock := &steppingClock{now: time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)}
+	service, err := services.NewAuctionServiceWithClock(cfg, clock)
+	require.NoError(t, err)
+	defer service.Close()
+
+	testCases := []struct {
+		name            string
+		region          string
+		expectedFloor   models.AuctionFloor
+		expectedWinners []string
+	}{
+		{name: "Sparse Data", region: "TX", expectedFloor: models.AuctionFloor{Floor: 0.01, Source: models.FloorSourceStatic}, expectedWinners: []string{"high", "low"}},
+		{name: "Static Until Refreshed", region: "TX", expectedFloor: models.AuctionFloor{Floor: 0.01, Source: models.FloorSourceStatic}, expectedWinners: []string{"high", "low"}},
+		{name: "Learned For Region", region: "TX", expectedFloor: models.AuctionFloor{Floor: 15, Source: models.FloorSourceLearned, Region: "TX"}, expectedWinners: []string{"high"}},
+		{name: "Vertical For Sparse Region", region: "CA", expectedFloor: models.AuctionFloor{Floor: 15, Source: models.FloorSourceLearned}, expectedWinners: []string{"high"}},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			// Two auctions give the four samples a floor is learned from, once the cache refreshes
+			if i == 2 {
+				clock.now = clock.now.Add(2 * time.Second)
+			}
+			requestID := "floor-applied-" + strings.ReplaceAll(tc.name, " ", "-")
+			response, debug := runFloorAuction(t, service, requestID, tc.region)
+
+			winners := make([]string, 0, len(response.Bids))
+			for _, bid := range response.Bids {
+				winners = append(winners, bid.PartnerID)
+			}
+			assert.ElementsMatch(t, tc.expectedWinners, winners)
+			require.NotNil(t, debug.Floor())
+			assert.Equal(t, tc.expectedFloor, *debug.Floor())
+			if len(tc.expectedWinners) == 1 {
+				low, _ := debug.Partner("low")
+				assert.Equal(t, map[string]models.Reason{"low-bid": models.ReasonAdaptiveFloor}, low.Losses)
+			}
+
+			var floor map[string]any
+			require.Eventually(t, func() bool {
+				for _, delivery := range receiver.received() {
+					data, _ := delivery.event.Data.(map[string]any)
+					if data["request_id"] == requestID {
+						floor, _ = data["floor"].(map[string]any)
+						return true
+					}
+				}
+				return false
+			}, 2*time.Second, 10*time.Millisecond)
+			assert.Equal(t, tc.expectedFloor.Floor, floor["floor"])
+			assert.Equal(t, tc.expectedFloor.Source, floor["source"])
+		})
+	}
+}
+
+// TestAdaptiveFloorValidation tests the bounds on adaptive floor settings
+func TestAdaptiveFloorValidation(t *testing.T) {
+	valid := func() *config.AdaptiveFloorConfig {
+		return &config.AdaptiveFloorConfig{Enabled: true, Percentile: 0.2, Window: 24 * time.Hour, MinSamples: 100, MaxFloor: 50, RefreshInterval: time.Minute}
+	}
+	testCases := []struct {
+		name        string
+		modify      func(cfg *config.Config)
+		expectedErr string
+	}{
+		{name: "Valid", modify: func(cfg *config.Config) {}},
+		{name: "Analytics Disabled", modify: func(cfg *config.Config) { cfg.Analytics.Enabled = false }, expectedErr: "adaptive floors require price analytics"},
+		{name: "Percentile", modify: func(cfg *config.Config) { cfg.Analytics.Floors.Percentile = 1 }, expectedErr: "adaptive floor percentile must be between 0 and 1"},
+		{name: "Window Beyond Analytics", modify: func(cfg *config.Config) { cfg.Analytics.Floors.Window = 48 * time.Hour }, expectedErr: "adaptive floor window must be between 1h and the 24h of analytics windows"},
+		{name: "Min Samples", modify: func(cfg *config.Config) { cfg.Analytics.Floors.MinSamples = 0 }, expectedErr: "adaptive floor min samples must be positive"},
+		{name: "Min Above Max", modify: func(cfg *config.Config) { cfg.Analytics.Floors.MinFloor = 60 }, expectedErr: "adaptive floor bounds"},
+		{name: "Max Above Max Bid", modify: func(cfg *config.Config) { cfg.Analytics.Floors.MaxFloor = 200 }, expectedErr: "adaptive floor bounds"},
+		{name: "Regional Without Regional Analytics", modify: func(cfg *config.Config) {
+			cfg.Analytics.ByRegion = false
+			cfg.Analytics.Floors.ByRegion = true
+		}, expectedErr: "regional adaptive floors require regional analytics"},
+		{name: "Refresh Interval", modify: func(cfg *config.Config) { cfg.Analytics.Floors.RefreshInterval = 0 }, expectedErr: "adaptive floor refresh interval must be between 1s and 1h"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Analytics = &config.AnalyticsConfig{Enabled: true, Windows: 24, MaxSeries: 10, Precision: 0.01, ByRegion: true, Floors: valid()}
+			tc.modify(cfg)
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package tests
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"               // v2.31.0
+	"github.com/gin-gonic/gin"                       // v1.9.1
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+	"github.com/stretchr/testify/require"            // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// testAdminKey is the admin key accepted by test configs built withAdmin
+const testAdminKey = "test-admin-key-0123"
+
+// testConfigOption adjusts a config built by newTestConfig
+type testConfigOption func(cfg *config.Config)
+
+// newTestConfig returns the config tests share, with partners keyed by ID: port 8080, a 500ms
+// bid timeout, one winner per auction, and bid prices from 0.01 to 100, adjusted by options in
+// order
+func newTestConfig(partners map[string]*config.PartnerConfig, options ...testConfigOption) *config.Config {
+	cfg := &config.Config{
+		Port:              8080,
+		BidTimeout:        500 * time.Millisecond,
+		MaxBidsPerRequest: 1,
+		MinBidPrice:       0.01,
+		MaxBidPrice:       100.0,
+		Partners:          partners,
+	}
+	for _, option := range options {
+		option(cfg)
+	}
+	return cfg
+}
+
+// withBidTimeout sets the auction's bid timeout
+func withBidTimeout(timeout time.Duration) testConfigOption {
+	return func(cfg *config.Config) {
+		cfg.BidTimeout = timeout
+	}
+}
+
+// withMaxBids sets how many bids an auction returns
+func withMaxBids(maxBids int) testConfigOption {
+	return func(cfg *config.Config) {
+		cfg.MaxBidsPerRequest = maxBids
+	}
+}
+
+// withAdmin enables the admin API for testAdminKey
+func withAdmin() testConfigOption {
+	return func(cfg *config.Config) {
+		cfg.Admin = &config.AdminConfig{Enabled: true, APIKeys: []string{testAdminKey}}
+	}
+}
+
+// withAudit writes winning bids to an audit log at path
+func withAudit(path string) testConfigOption {
+	return func(cfg *config.Config) {
+		cfg.Audit = &config.AuditConfig{Enabled: true, Path: path, MaxSizeBytes: 1 << 20, MaxFiles: 1, BufferSize: 10}
+	}
+}
+
+// useTestRedis points cfg at redisServer
+func useTestRedis(t *testing.T, cfg *config.Config, redisServer *miniredis.Miniredis) {
+	host, portText, err := net.SplitHostPort(redisServer.Addr())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portText)
+	require.NoError(t, err)
+	cfg.Redis = &config.RedisConfig{Host: host, Port: port, Timeout: time.Second}
+}
+
+// newTestService creates a service from cfg, closed when the test ends
+func newTestService(tb testing.TB, cfg *config.Config) *services.AuctionService {
+	return newTestServiceWithClock(tb, cfg, utils.SystemClock{})
+}
+
+// newTestServiceWithClock creates a service from cfg that reads time from clock, closed when the
+// test ends
+func newTestServiceWithClock(tb testing.TB, cfg *config.Config, clock utils.Clock) *services.AuctionService {
+	service, err := services.NewAuctionServiceWithClock(cfg, clock)
+	require.NoError(tb, err)
+	tb.Cleanup(func() { service.Close() })
+	return service
+}
+
+// newTestRouter returns a router serving bid requests to service on /v1/bids and /v2/bids, and
+// its handler for tests that route more
+func newTestRouter(tb testing.TB, service *services.AuctionService, cfg *config.Config) (*gin.Engine, *handlers.BidHandler) {
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(tb, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/v1/bids", handler.HandleBidRequest)
+	router.POST("/v2/bids", handler.HandleBidRequestV2)
+	return router, handler
+}
+
+// steppingClock is a Clock that tests advance between auctions
+type steppingClock struct {
+	now time.Time
+}
+
+func (c *steppingClock) Now() time.Time {
+	return c.now
+}
+
+// newPartnerServer starts a partner endpoint that always returns the given bid
+func newPartnerServer(t *testing.T, bid models.Bid) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(bid)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// gatheredMetric sums the counter and gauge values or histogram sample counts of a metric whose labels include labels
+func gatheredMetric(t *testing.T, name string, labels map[string]string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+
+	total := 0.0
+	for _, family := range families {
+		if family.GetName() != name {
+			continue
+		}
+	metrics:
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if expected, exists := labels[pair.GetName()]; exists && expected != pair.GetValue() {
+					continue metrics
+				}
+			}
+			if metric.GetCounter() != nil {
+				total += metric.GetCounter().GetValue()
+			}
+			if metric.GetHistogram() != nil {
+				total += float64(metric.GetHistogram().GetSampleCount())
+			}
+			if metric.GetGauge() != nil {
+				total += metric.GetGauge().GetValue()
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newHTTPClientTestService creates a service with one partner reached through a hostname, so DNS is exercised
+func newHTTPClientTestService(t *testing.T, partnerID string, httpClient *config.HTTPClientConfig) *services.AuctionService {
+	server := newPartnerServer(t, models.Bid{ID: "bid-1", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	endpoint := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		partnerID: {ID: partnerID, Endpoint: endpoint, APIKey: "key", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.HTTPClient = httpClient
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	return service
+}
+
+// runHTTPClientTestAuctions runs count sequential auctions
+func runHTTPClientTestAuctions(t *testing.T, service *services.AuctionService, count int) {
+	for i := 0; i < count; i++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		_, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "http-client-test", LeadID: "lead-1", Vertical: "auto"})
+		cancel()
+		require.NoError(t, err)
+	}
+}
+
+// TestPartnerConnectionReuse tests that keep-alive connections are reused across auctions and phases are traced
+func TestPartnerConnectionReuse(t *testing.T) {
+	service := newHTTPClientTestService(t, "reuse-partner", &config.HTTPClientConfig{
+		MaxIdleConns:        10,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     time.Minute,
+		TLSHandshakeTimeout: time.Second,
+		DNSCacheTTL:         time.Minute,
+	})
+	newConnections := map[string]string{"partner": "reuse-partner", "reused": "false"}
+	reused := map[string]string{"partner": "reuse-partner", "reused": "true"}
+	phases := map[string]map[string]string{}
+	for _, phase := range []string{"dns", "connect", "first_byte"} {
+		phases[phase] = map[string]string{"partner": "reuse-partner", "phase": phase}
+	}
+	newBefore := gatheredMetric(t, "rtb_partner_connections_total", newConnections)
+	reusedBefore := gatheredMetric(t, "rtb_partner_connections_total", reused)
+	phasesBefore := map[string]float64{}
+	for phase, labels := range phases {
+		phasesBefore[phase] = gatheredMetric(t, "rtb_partner_http_phase_seconds", labels)
+	}
+
+	runHTTPClientTestAuctions(t, service, 3)
+
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_partner_connections_total", newConnections)-newBefore)
+	assert.Equal(t, 2.0, gatheredMetric(t, "rtb_partner_connections_total", reused)-reusedBefore)
+
+	// The cached lookup is traced once, the single dial at least once per resolved address,
+	// and every call records time to first byte
+	phaseCount := func(phase string) float64 {
+		return gatheredMetric(t, "rtb_partner_http_phase_seconds", phases[phase]) - phasesBefore[phase]
+	}
+	assert.Equal(t, 1.0, phaseCount("dns"))
+	assert.GreaterOrEqual(t, phaseCount("connect"), 1.0)
+	assert.Equal(t, 3.0, phaseCount("first_byte"))
+}
+
+// TestPartnerDefaultHTTPClient tests that a nil HTTP client config keeps working with default transport settings
+func TestPartnerDefaultHTTPClient(t *testing.T) {
+	service := newHTTPClientTestService(t, "default-partner", nil)
+	connections := map[string]string{"partner": "default-partner"}
+	before := gatheredMetric(t, "rtb_partner_connections_total", connections)
+	runHTTPClientTestAuctions(t, service, 2)
+
+	assert.Equal(t, 2.0, gatheredMetric(t, "rtb_partner_connections_total", connections)-before)
+}
+
+// TestHTTPClientValidation tests transport knob bounds
+func TestHTTPClientValidation(t *testing.T) {
+	valid := func() *config.HTTPClientConfig {
+		return &config.HTTPClientConfig{
+			MaxIdleConns:        100,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+			EnableHTTP2:         true,
+		}
+	}
+
+	testCases := []struct {
+		name        string
+		modify      func(c *config.HTTPClientConfig)
+		expectedErr string
+	}{
+		{name: "Defaults", modify: func(c *config.HTTPClientConfig) {}},
+		{name: "No Idle Connections Per Host", modify: func(c *config.HTTPClientConfig) { c.MaxIdleConnsPerHost = 0 }, expectedErr: "invalid idle connection limits"},
+		{name: "Idle Timeout Too Low", modify: func(c *config.HTTPClientConfig) { c.IdleConnTimeout = time.Millisecond }, expectedErr: "idle connection timeout too low"},
+		{name: "TLS Timeout Too High", modify: func(c *config.HTTPClientConfig) { c.TLSHandshakeTimeout = time.Hour }, expectedErr: "TLS handshake timeout"},
+		{name: "Negative DNS TTL", modify: func(c *config.HTTPClientConfig) { c.DNSCacheTTL = -time.Second }, expectedErr: "DNS cache TTL"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.HTTPClient = valid()
+			tc.modify(cfg.HTTPClient)
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
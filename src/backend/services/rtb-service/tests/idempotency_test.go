@@ -0,0 +1,311 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+	"go.uber.org/zap"                     // v1.24.0
+	"go.uber.org/zap/zapcore"             // v1.24.0
+	"go.uber.org/zap/zaptest/observer"    // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newSlowPartner returns a partner that bids after delay, counting its calls
+func newSlowPartner(t *testing.T, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "bid-1", Price: 8.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// newIdempotencyTestConfig returns a config with idempotency on and one partner at endpoint
+func newIdempotencyTestConfig(endpoint string) *config.Config {
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: endpoint, APIKey: "key-1", Timeout: 300 * time.Millisecond, Enabled: true},
+	})
+	cfg.Idempotency = &config.IdempotencyConfig{Enabled: true, Window: time.Minute}
+	return cfg
+}
+
+// newIdempotencyTestRouter serves bid requests for cfg, logging to the returned observer
+func newIdempotencyTestRouter(t *testing.T, cfg *config.Config) (*gin.Engine, *observer.ObservedLogs) {
+	service := newTestService(t, cfg)
+	core, logs := observer.New(zapcore.WarnLevel)
+	service.SetLogger(zap.New(core))
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/v1/bids", handler.HandleBidRequest)
+	return router, logs
+}
+
+// postIdempotentBid posts request and returns the recorded response
+func postIdempotentBid(t *testing.T, router *gin.Engine, request models.BidRequest) *httptest.ResponseRecorder {
+	body, err := json.Marshal(request)
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/v1/bids", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestIdempotencyFingerprint tests that a reused request ID replays only when the lead ID,
+// vertical, and fingerprinted UserData fields match the first request, and otherwise returns 409
+// with the request_id_conflict code, counted apart from replays
+func TestIdempotencyFingerprint(t *testing.T) {
+	first := models.BidRequest{LeadID: "lead-1", Vertical: "auto", UserData: map[string]interface{}{"zip": "94107", "email": "jane@example.com", "source": "web"}}
+
+	testCases := []struct {
+		name           string
+		fields         []string
+		retry          func(request *models.BidRequest)
+		expectConflict bool
+	}{
+		{
+			name:  "Identical Retry",
+			retry: func(request *models.BidRequest) {},
+		},
+		{
+			name:  "Field Outside Fingerprint",
+			retry: func(request *models.BidRequest) { request.UserData["source"] = "app" },
+		},
+		{
+			name: "Key Order",
+			retry: func(request *models.BidRequest) {
+				request.UserData = map[string]interface{}{"source": "web", "email": "jane@example.com", "zip": "94107"}
+			},
+		},
+		{
+			name:           "Different Lead",
+			retry:          func(request *models.BidRequest) { request.LeadID = "lead-2" },
+			expectConflict: true,
+		},
+		{
+			name:           "Different Vertical",
+			retry:          func(request *models.BidRequest) { request.Vertical = "home" },
+			expectConflict: true,
+		},
+		{
+			name:           "Different Fingerprinted Field",
+			retry:          func(request *models.BidRequest) { request.UserData["zip"] = "10001" },
+			expectConflict: true,
+		},
+		{
+			name:           "Fingerprinted Field Added",
+			retry:          func(request *models.BidRequest) { request.UserData["phone"] = "5551234567" },
+			expectConflict: true,
+		},
+		{
+			name:   "Configured Fields",
+			fields: []string{"source"},
+			retry: func(request *models.BidRequest) {
+				request.UserData["source"] = "app"
+			},
+			expectConflict: true,
+		},
+		{
+			name:   "Configured Fields Ignore Defaults",
+			fields: []string{"source"},
+			retry: func(request *models.BidRequest) {
+				request.UserData["zip"] = "10001"
+			},
+		},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partner, calls := newSlowPartner(t, 0)
+			cfg := newIdempotencyTestConfig(partner.URL)
+			cfg.Idempotency.FingerprintFields = tc.fields
+			router, logs := newIdempotencyTestRouter(t, cfg)
+
+			request := first
+			request.RequestID = "fingerprint-" + strconv.Itoa(i)
+			request.UserData = map[string]interface{}{}
+			for field, value := range first.UserData {
+				request.UserData[field] = value
+			}
+			w := postIdempotentBid(t, router, request)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			replays := gatheredMetric(t, "rtb_idempotent_replays_total", nil)
+			conflicts := gatheredMetric(t, "rtb_idempotency_conflicts_total", nil)
+			tc.retry(&request)
+			w = postIdempotentBid(t, router, request)
+			assert.Equal(t, int32(1), calls.Load(), "a retry never runs a second auction")
+
+			if !tc.expectConflict {
+				assert.Equal(t, http.StatusOK, w.Code)
+				assert.Equal(t, "true", w.Header().Get("Idempotent-Replay"))
+				assert.Equal(t, replays+1, gatheredMetric(t, "rtb_idempotent_replays_total", nil))
+				assert.Equal(t, conflicts, gatheredMetric(t, "rtb_idempotency_conflicts_total", nil))
+				assert.Zero(t, logs.FilterMessage("request ID reused for a different payload").Len())
+				return
+			}
+			assert.Equal(t, http.StatusConflict, w.Code)
+			assert.Empty(t, w.Header().Get("Idempotent-Replay"))
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, "request_id_conflict", body["code"])
+			assert.NotContains(t, w.Body.String(), "bid-1", "the stored response is never sold to another lead")
+			assert.Equal(t, replays, gatheredMetric(t, "rtb_idempotent_replays_total", nil))
+			assert.Equal(t, conflicts+1, gatheredMetric(t, "rtb_idempotency_conflicts_total", nil))
+
+			entries := logs.FilterMessage("request ID reused for a different payload").All()
+			require.Len(t, entries, 1)
+			fields := entries[0].ContextMap()
+			assert.Equal(t, request.RequestID, fields["request_id"])
+			assert.NotEmpty(t, fields["stored_fingerprint"])
+			assert.NotEmpty(t, fields["fingerprint"])
+			assert.NotEqual(t, fields["stored_fingerprint"], fields["fingerprint"])
+		})
+	}
+}
+
+// TestIdempotencyConcurrentConflict tests that of two requests sharing a request ID and arriving
+// while neither auction has completed, a conflicting one is rejected and only one auction runs,
+// while an identical one is coalesced into a replay
+func TestIdempotencyConcurrentConflict(t *testing.T) {
+	testCases := []struct {
+		name           string
+		secondLead     string
+		expectedStatus []int
+		expectReplay   bool
+	}{
+		{name: "Conflicting Pair", secondLead: "lead-2", expectedStatus: []int{http.StatusConflict, http.StatusOK}},
+		{name: "Identical Pair", secondLead: "lead-1", expectedStatus: []int{http.StatusOK, http.StatusOK}, expectReplay: true},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partner, calls := newSlowPartner(t, 100*time.Millisecond)
+			router, _ := newIdempotencyTestRouter(t, newIdempotencyTestConfig(partner.URL))
+			requestID := "concurrent-" + strconv.Itoa(i)
+
+			var wg sync.WaitGroup
+			recorders := make([]*httptest.ResponseRecorder, 2)
+			for j, leadID := range []string{"lead-1", tc.secondLead} {
+				wg.Add(1)
+				go func(j int, leadID string) {
+					defer wg.Done()
+					recorders[j] = postIdempotentBid(t, router, models.BidRequest{RequestID: requestID, LeadID: leadID, Vertical: "auto"})
+				}(j, leadID)
+			}
+			wg.Wait()
+
+			statuses := []int{recorders[0].Code, recorders[1].Code}
+			assert.ElementsMatch(t, tc.expectedStatus, statuses)
+			assert.Equal(t, int32(1), calls.Load(), "only one auction runs for a request ID")
+			replays := 0
+			for _, recorder := range recorders {
+				if recorder.Header().Get("Idempotent-Replay") == "true" {
+					replays++
+				}
+			}
+			if tc.expectReplay {
+				assert.Equal(t, 1, replays)
+			} else {
+				assert.Zero(t, replays)
+			}
+		})
+	}
+}
+
+// TestIdempotencyConflictAcrossInstances tests that with Redis, an instance rejects a request ID
+// reused for another lead on a different instance, including while that auction still runs
+func TestIdempotencyConflictAcrossInstances(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	host, portText, err := net.SplitHostPort(redisServer.Addr())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portText)
+	require.NoError(t, err)
+
+	partner, calls := newSlowPartner(t, 100*time.Millisecond)
+	newInstance := func() *services.AuctionService {
+		cfg := newIdempotencyTestConfig(partner.URL)
+		cfg.Redis = &config.RedisConfig{Host: host, Port: port, Timeout: time.Second}
+		service := newTestService(t, cfg)
+		return service
+	}
+	first, second := newInstance(), newInstance()
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, err := first.RunAuctionIdempotent(context.Background(), &models.BidRequest{RequestID: "shared", LeadID: "lead-1", Vertical: "auto"})
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	// The first auction has only marked the request ID as started
+	_, _, err = second.RunAuctionIdempotent(context.Background(), &models.BidRequest{RequestID: "shared", LeadID: "lead-2", Vertical: "auto"})
+	var conflict *services.RequestConflictError
+	require.ErrorAs(t, err, &conflict)
+	assert.Equal(t, "shared", conflict.RequestID)
+	assert.NotEqual(t, conflict.StoredFingerprint, conflict.Fingerprint)
+	require.NoError(t, <-done)
+
+	response, replayed, err := second.RunAuctionIdempotent(context.Background(), &models.BidRequest{RequestID: "shared", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	assert.True(t, replayed)
+	require.Len(t, response.Bids, 1)
+	_, _, err = second.RunAuctionIdempotent(context.Background(), &models.BidRequest{RequestID: "shared", LeadID: "lead-2", Vertical: "auto"})
+	assert.ErrorIs(t, err, services.ErrRequestConflict)
+	assert.Equal(t, int32(1), calls.Load())
+}
+
+// TestIdempotencyCoalescedDebug tests that a caller coalesced onto a concurrent debug auction
+// gets its response without the debug output
+func TestIdempotencyCoalescedDebug(t *testing.T) {
+	partner, calls := newSlowPartner(t, 100*time.Millisecond)
+	service := newTestService(t, newIdempotencyTestConfig(partner.URL))
+
+	type result struct {
+		response *models.BidResponse
+		replayed bool
+		err      error
+	}
+	debugDone := make(chan result, 1)
+	go func() {
+		ctx := models.ContextWithDebug(context.Background(), models.NewDebugInfo())
+		response, replayed, err := service.RunAuctionIdempotent(ctx, &models.BidRequest{RequestID: "coalesced", LeadID: "lead-1", Vertical: "auto"})
+		debugDone <- result{response, replayed, err}
+	}()
+	require.Eventually(t, func() bool { return calls.Load() == 1 }, time.Second, 5*time.Millisecond)
+
+	response, replayed, err := service.RunAuctionIdempotent(context.Background(), &models.BidRequest{RequestID: "coalesced", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	assert.True(t, replayed)
+	require.Len(t, response.Bids, 1)
+	assert.Nil(t, response.Debug)
+
+	first := <-debugDone
+	require.NoError(t, first.err)
+	assert.False(t, first.replayed)
+	assert.NotNil(t, first.response.Debug)
+	assert.Equal(t, int32(1), calls.Load())
+}
@@ -0,0 +1,266 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// ivtTestRequest describes one bid request sent through the IVT tests' router
+type ivtTestRequest struct {
+	userAgent string
+	remoteIP  string
+	leadID    string
+	noOrigin  bool
+}
+
+// newIVTTestRouter serves the bid and admin endpoints for an auction against one partner counting its calls
+func newIVTTestRouter(t *testing.T, ivt *config.IVTConfig, useRedis bool) (*gin.Engine, *atomic.Int32) {
+	gin.SetMode(gin.TestMode)
+	var calls atomic.Int32
+	partner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		json.NewEncoder(w).Encode(models.Bid{ID: "ivt-bid", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/ivt"})
+	}))
+	t.Cleanup(partner.Close)
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withAdmin())
+	cfg.IVT = ivt
+	if useRedis {
+		redisServer := miniredis.RunT(t)
+		host, portText, err := net.SplitHostPort(redisServer.Addr())
+		require.NoError(t, err)
+		port, err := strconv.Atoi(portText)
+		require.NoError(t, err)
+		cfg.Redis = &config.RedisConfig{Host: host, Port: port, Timeout: time.Second}
+	}
+
+	service := newTestService(t, cfg)
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	admin, err := handlers.NewAdminHandler(service, cfg, handlers.BuildInfo{})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.POST("/v1/bids", handler.HandleBidRequest)
+	admin.RegisterRoutes(router.Group("/admin"))
+	return router, &calls
+}
+
+// sendIVTRequest posts a bid request with the given User-Agent, client IP, and lead
+func sendIVTRequest(router *gin.Engine, request ivtTestRequest) *httptest.ResponseRecorder {
+	body := `{"request_id": "", "lead_id": "` + request.leadID + `", "vertical": "auto"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/bids", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", request.userAgent)
+	if !request.noOrigin {
+		req.Header.Set("Origin", "https://affiliate.example.com")
+	}
+	req.RemoteAddr = request.remoteIP + ":40000"
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// TestIVTFilter tests each invalid traffic rule and action on the bid endpoint
+func TestIVTFilter(t *testing.T) {
+	human := ivtTestRequest{userAgent: "Mozilla/5.0 (iPhone)", remoteIP: "198.51.100.7", leadID: "lead-1"}
+	with := func(change func(*ivtTestRequest)) ivtTestRequest {
+		request := human
+		change(&request)
+		return request
+	}
+
+	testCases := []struct {
+		name           string
+		ruleActions    map[string]string
+		useRedis       bool
+		requests       []ivtTestRequest
+		expectedStatus int
+		expectedCalls  int32
+		expectedRule   string
+		expectedAction string
+	}{
+		{name: "Clean Traffic", requests: []ivtTestRequest{human}, expectedStatus: http.StatusOK, expectedCalls: 1},
+		{
+			name: "Bot User Agent", requests: []ivtTestRequest{with(func(r *ivtTestRequest) { r.userAgent = "Mozilla/5.0 (compatible; Googlebot/2.1)" })},
+			expectedStatus: http.StatusForbidden, expectedRule: config.IVTRuleUserAgent, expectedAction: config.IVTActionReject,
+		},
+		{
+			name: "Blocked Network", requests: []ivtTestRequest{with(func(r *ivtTestRequest) { r.remoteIP = "203.0.113.200" })},
+			expectedStatus: http.StatusForbidden, expectedRule: config.IVTRuleIPBlocklist, expectedAction: config.IVTActionReject,
+		},
+		{
+			name: "Blocked Address", requests: []ivtTestRequest{with(func(r *ivtTestRequest) { r.remoteIP = "192.0.2.10" })},
+			expectedStatus: http.StatusForbidden, expectedRule: config.IVTRuleIPBlocklist, expectedAction: config.IVTActionReject,
+		},
+		{
+			name: "Missing Header", requests: []ivtTestRequest{with(func(r *ivtTestRequest) { r.noOrigin = true })},
+			expectedStatus: http.StatusForbidden, expectedRule: config.IVTRuleMissingHeader, expectedAction: config.IVTActionReject,
+		},
+		{
+			name: "Lead Velocity", requests: []ivtTestRequest{human, human, human},
+			expectedStatus: http.StatusForbidden, expectedCalls: 2, expectedRule: config.IVTRuleLeadVelocity, expectedAction: config.IVTActionReject,
+		},
+		{
+			name: "Lead Velocity In Redis", useRedis: true, requests: []ivtTestRequest{human, human, human},
+			expectedStatus: http.StatusForbidden, expectedCalls: 2, expectedRule: config.IVTRuleLeadVelocity, expectedAction: config.IVTActionReject,
+		},
+		{
+			name: "Shadowed", ruleActions: map[string]string{config.IVTRuleUserAgent: config.IVTActionShadow},
+			requests:       []ivtTestRequest{with(func(r *ivtTestRequest) { r.userAgent = "python-requests/2.31" })},
+			expectedStatus: http.StatusNoContent, expectedRule: config.IVTRuleUserAgent, expectedAction: config.IVTActionShadow,
+		},
+		{
+			name: "Flagged", ruleActions: map[string]string{config.IVTRuleUserAgent: config.IVTActionFlag},
+			requests:       []ivtTestRequest{with(func(r *ivtTestRequest) { r.userAgent = "HeadlessChrome/120" })},
+			expectedStatus: http.StatusOK, expectedCalls: 1, expectedRule: config.IVTRuleUserAgent, expectedAction: config.IVTActionFlag,
+		},
+		{
+			name: "Strictest Action Wins", ruleActions: map[string]string{config.IVTRuleUserAgent: config.IVTActionFlag, config.IVTRuleIPBlocklist: config.IVTActionShadow},
+			requests:       []ivtTestRequest{with(func(r *ivtTestRequest) { r.userAgent = "HeadlessChrome/120"; r.remoteIP = "203.0.113.9" })},
+			expectedStatus: http.StatusNoContent, expectedRule: config.IVTRuleIPBlocklist, expectedAction: config.IVTActionShadow,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, calls := newIVTTestRouter(t, &config.IVTConfig{
+				Enabled:          true,
+				Action:           config.IVTActionReject,
+				RuleActions:      tc.ruleActions,
+				BotUserAgents:    []string{"bot", "python-requests", "headless"},
+				BlockedCIDRs:     []string{"203.0.113.0/24", "192.0.2.10"},
+				MaxLeadRepeats:   2,
+				LeadRepeatWindow: time.Minute,
+				RequiredHeaders:  []string{"Origin"},
+			}, tc.useRedis)
+			labels := map[string]string{"rule": tc.expectedRule, "action": tc.expectedAction}
+			before := gatheredMetric(t, "rtb_ivt_filtered_total", labels)
+
+			var w *httptest.ResponseRecorder
+			for _, request := range tc.requests {
+				w = sendIVTRequest(router, request)
+			}
+
+			assert.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			assert.Equal(t, tc.expectedCalls, calls.Load())
+			if tc.expectedRule != "" {
+				assert.Equal(t, 1.0, gatheredMetric(t, "rtb_ivt_filtered_total", labels)-before)
+			}
+		})
+	}
+}
+
+// TestIVTShadowResponse tests that a shadowed request gets the same response as an auction without bids
+func TestIVTShadowResponse(t *testing.T) {
+	router, calls := newIVTTestRouter(t, &config.IVTConfig{Enabled: true, Action: config.IVTActionShadow, BotUserAgents: []string{"bot"}}, false)
+	shadowed := sendIVTRequest(router, ivtTestRequest{userAgent: "crawlbot", remoteIP: "198.51.100.7", leadID: "lead-1"})
+
+	cfg := newStrategyTestConfig()
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	noBids, _ := newTestRouter(t, service, cfg)
+	unsold := sendIVTRequest(noBids, ivtTestRequest{userAgent: "Mozilla/5.0", remoteIP: "198.51.100.7", leadID: "lead-1"})
+
+	// Request IDs differ per request; everything else must match
+	for _, header := range []string{"X-Request-Id", "X-RTB-Request-Id"} {
+		unsold.Header().Del(header)
+		shadowed.Header().Del(header)
+	}
+	assert.Zero(t, calls.Load())
+	assert.Equal(t, http.StatusNoContent, shadowed.Code)
+	assert.Equal(t, unsold.Code, shadowed.Code)
+	assert.Equal(t, unsold.Header(), shadowed.Header())
+	assert.Equal(t, unsold.Body.String(), shadowed.Body.String())
+}
+
+// TestIVTBlocklistAdmin tests reading and replacing the blocklists through the admin API
+func TestIVTBlocklistAdmin(t *testing.T) {
+	router, _ := newIVTTestRouter(t, &config.IVTConfig{Enabled: true, BotUserAgents: []string{"bot"}, BlockedCIDRs: []string{"203.0.113.0/24"}}, false)
+	admin := func(method, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/ivt/blocklists", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Admin-Key", testAdminKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	blocklists := func(w *httptest.ResponseRecorder) services.IVTBlocklists {
+		var body struct {
+			Blocklists services.IVTBlocklists `json:"blocklists"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Blocklists
+	}
+	visitor := ivtTestRequest{userAgent: "Mozilla/5.0", remoteIP: "198.51.100.7", leadID: "lead-1", noOrigin: true}
+
+	w := admin(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, services.IVTBlocklists{UserAgents: []string{"bot"}, CIDRs: []string{"203.0.113.0/24"}}, blocklists(w))
+	assert.Equal(t, http.StatusOK, sendIVTRequest(router, visitor).Code)
+
+	// A replaced list applies to the next request
+	w = admin(http.MethodPut, `{"user_agents": ["bot"], "cidrs": ["198.51.100.0/24"]}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusForbidden, sendIVTRequest(router, visitor).Code)
+
+	// An invalid entry keeps the current lists
+	w = admin(http.MethodPut, `{"user_agents": [], "cidrs": ["not-a-network"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []string{"198.51.100.0/24"}, blocklists(admin(http.MethodGet, "")).CIDRs)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/ivt/blocklists", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// TestIVTConfigValidation tests IVT rule, action, and blocklist validation
+func TestIVTConfigValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ivt         *config.IVTConfig
+		expectedErr string
+	}{
+		{name: "Disabled", ivt: &config.IVTConfig{Action: "drop"}},
+		{name: "Default Action", ivt: &config.IVTConfig{Enabled: true, BlockedCIDRs: []string{"10.0.0.0/8", "2001:db8::1"}}},
+		{name: "Unknown Action", ivt: &config.IVTConfig{Enabled: true, Action: "drop"}, expectedErr: `unknown ivt action "drop" for rule default`},
+		{name: "Unknown Rule", ivt: &config.IVTConfig{Enabled: true, RuleActions: map[string]string{"asn": config.IVTActionReject}}, expectedErr: `unknown ivt rule "asn"`},
+		{name: "Missing Rule Action", ivt: &config.IVTConfig{Enabled: true, RuleActions: map[string]string{config.IVTRuleUserAgent: ""}}, expectedErr: "missing ivt action for rule user_agent"},
+		{name: "Invalid Network", ivt: &config.IVTConfig{Enabled: true, BlockedCIDRs: []string{"10.0.0.0/33"}}, expectedErr: `invalid ivt blocklist entry "10.0.0.0/33"`},
+		{name: "Missing Window", ivt: &config.IVTConfig{Enabled: true, MaxLeadRepeats: 3}, expectedErr: "ivt lead repeat window must be greater than 0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.IVT = tc.ivt
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
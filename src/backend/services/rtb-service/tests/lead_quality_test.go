@@ -0,0 +1,320 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// leadQualityStart is when the lead quality tests run
+var leadQualityStart = time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC)
+
+// newLeadQuality returns lead quality signals with every field set
+func newLeadQuality() *models.LeadQuality {
+	consentTime := leadQualityStart.Add(-5 * time.Minute)
+	sessionDuration, previouslySold, score := int64(95000), false, 0.8
+	return &models.LeadQuality{ConsentTimestamp: &consentTime, SessionDurationMs: &sessionDuration, PreviouslySold: &previouslySold, Score: &score}
+}
+
+// qualityMapping maps lead quality onto a mapped partner's field names
+var qualityMapping = &config.FieldMapping{
+	Request: map[string]string{
+		"ref":           "request_id",
+		"quality.score": "lead_quality.score",
+		"quality.sold":  "lead_quality.previously_sold",
+	},
+	Response: map[string]string{
+		"id":                   "offer.offer_id",
+		"price":                "offer.payout",
+		"click_url":            "offer.redirect",
+		"quality_acknowledged": "offer.ack",
+	},
+}
+
+// TestLeadQualityAdapters tests that every partner format forwards the lead quality signals and
+// reads a partner's quality acknowledgement
+func TestLeadQualityAdapters(t *testing.T) {
+	testCases := []struct {
+		name             string
+		adapter          services.PartnerAdapter
+		expectedBody     string
+		response         string
+		expectedAck      bool
+		expectedErrField string
+	}{
+		{
+			name:         "JSON",
+			adapter:      services.JSONAdapter{},
+			expectedBody: `"lead_quality":{"consent_timestamp":"2024-01-20T09:55:00Z","session_duration_ms":95000,"previously_sold":false,"score":0.8}`,
+			response:     `{"id": "bid-1", "price": 5, "click_url": "http://example.com/1", "quality_acknowledged": true}`,
+			expectedAck:  true,
+		},
+		{
+			name:         "JSON Not Acknowledged",
+			adapter:      services.JSONAdapter{},
+			expectedBody: `"score":0.8`,
+			response:     `{"id": "bid-1", "price": 5, "click_url": "http://example.com/1"}`,
+		},
+		{
+			name:             "JSON Wrong Type",
+			adapter:          services.JSONAdapter{},
+			expectedBody:     `"score":0.8`,
+			response:         `{"id": "bid-1", "price": 5, "click_url": "http://example.com/1", "quality_acknowledged": "yes"}`,
+			expectedErrField: "quality_acknowledged",
+		},
+		{
+			name:    "XML",
+			adapter: services.XMLAdapter{},
+			expectedBody: "<LeadQuality><ConsentTimestamp>2024-01-20T09:55:00Z</ConsentTimestamp><SessionDurationMs>95000</SessionDurationMs>" +
+				"<PreviouslySold>false</PreviouslySold><Score>0.8</Score></LeadQuality>",
+			response:    `<Bid><ID>bid-1</ID><Price>5</Price><ClickURL>http://example.com/1</ClickURL><QualityAcknowledged>true</QualityAcknowledged></Bid>`,
+			expectedAck: true,
+		},
+		{
+			name:             "XML Wrong Type",
+			adapter:          services.XMLAdapter{},
+			expectedBody:     "<Score>0.8</Score>",
+			response:         `<Bid><ID>bid-1</ID><Price>5</Price><ClickURL>http://example.com/1</ClickURL><QualityAcknowledged>maybe</QualityAcknowledged></Bid>`,
+			expectedErrField: "QualityAcknowledged",
+		},
+		{
+			name:         "Mapped JSON",
+			adapter:      services.NewMappedJSONAdapter(qualityMapping),
+			expectedBody: `"quality":{"score":0.8,"sold":false}`,
+			response:     `{"offer": {"offer_id": "bid-1", "payout": 5, "redirect": "http://example.com/1", "ack": true}}`,
+			expectedAck:  true,
+		},
+		{
+			name:         "Form",
+			adapter:      services.NewFormAdapter(qualityMapping),
+			expectedBody: "quality.score=0.8&quality.sold=false",
+			response:     `{"offer": {"offer_id": "bid-1", "payout": 5, "redirect": "http://example.com/1", "ack": true}}`,
+			expectedAck:  true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			request := newAdapterTestRequest()
+			request.LeadQuality = newLeadQuality()
+			built, err := tc.adapter.BuildRequest(request, &config.PartnerConfig{Endpoint: "http://quality.example.com/bid"})
+			require.NoError(t, err)
+			assert.Contains(t, readRequestBody(t, built), tc.expectedBody)
+
+			bids, err := tc.adapter.ParseResponse([]byte(tc.response), http.StatusOK)
+			if tc.expectedErrField != "" {
+				var adapterErr *services.AdapterError
+				require.ErrorAs(t, err, &adapterErr)
+				assert.Equal(t, tc.expectedErrField, adapterErr.Field)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, bids, 1)
+			assert.Equal(t, tc.expectedAck, bids[0].QualityAcknowledged)
+		})
+	}
+}
+
+// TestLeadQualityValidation tests that requests with out-of-range lead quality signals are
+// rejected with a field error per invalid signal
+func TestLeadQualityValidation(t *testing.T) {
+	future, negative, tooHigh := leadQualityStart.Add(2*time.Minute), int64(-1), 1.5
+	skewed := leadQualityStart.Add(30 * time.Second)
+
+	testCases := []struct {
+		name           string
+		quality        *models.LeadQuality
+		expectedFields []string
+	}{
+		{name: "No Signals"},
+		{name: "Valid Signals", quality: newLeadQuality()},
+		{name: "Consent Within Clock Skew", quality: &models.LeadQuality{ConsentTimestamp: &skewed}},
+		{name: "Consent In Future", quality: &models.LeadQuality{ConsentTimestamp: &future}, expectedFields: []string{"lead_quality.consent_timestamp"}},
+		{
+			name:           "Several Invalid",
+			quality:        &models.LeadQuality{SessionDurationMs: &negative, Score: &tooHigh},
+			expectedFields: []string{"lead_quality.session_duration_ms", "lead_quality.score"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].Endpoint = newPartnerServer(t, models.Bid{ID: "bid-1", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/1"}).URL
+			delete(cfg.Partners, "partner-2")
+			service, err := services.NewAuctionServiceWithClock(cfg, &steppingClock{now: leadQualityStart})
+			require.NoError(t, err)
+			defer service.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			_, err = service.RunAuction(ctx, &models.BidRequest{RequestID: "quality-" + tc.name, LeadID: "lead-1", Vertical: "auto", LeadQuality: tc.quality})
+
+			if len(tc.expectedFields) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			var userDataErr *services.UserDataError
+			require.True(t, errors.As(err, &userDataErr), "expected a UserDataError, got %v", err)
+			fields := make([]string, 0, len(userDataErr.Fields))
+			for _, field := range userDataErr.Fields {
+				assert.Equal(t, models.FieldReasonRange, field.Reason)
+				fields = append(fields, field.Field)
+			}
+			assert.Equal(t, tc.expectedFields, fields)
+		})
+	}
+}
+
+// TestQualityAcknowledgedBonus tests that a bid acknowledging the request's lead quality gets the
+// configured effective price bonus, only when the request carried lead quality, and that the
+// bonus stays small
+func TestQualityAcknowledgedBonus(t *testing.T) {
+	testCases := []struct {
+		name            string
+		bonus           float64
+		quality         *models.LeadQuality
+		expectedPartner string
+		expectedErr     string
+	}{
+		{name: "Bonus Applied", bonus: 0.1, quality: newLeadQuality(), expectedPartner: "partner-1"},
+		{name: "No Lead Quality", bonus: 0.1, expectedPartner: "partner-2"},
+		{name: "No Bonus Configured", quality: newLeadQuality(), expectedPartner: "partner-2"},
+		{name: "Bonus Too High", bonus: 0.5, expectedErr: "quality acknowledged bonus must be in [0, 0.2]: 0.5"},
+		{name: "Negative Bonus", bonus: -0.1, expectedErr: "quality acknowledged bonus must be in [0, 0.2]: -0.1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.MaxBidsPerRequest = 1
+			cfg.QualityAcknowledgedBonus = tc.bonus
+			cfg.Partners["partner-1"].Endpoint = newPartnerServer(t, models.Bid{ID: "bid-1", Price: 5.0, QualityScore: 0.5,
+				ClickURL: "http://example.com/1", QualityAcknowledged: true}).URL
+			cfg.Partners["partner-2"].Endpoint = newPartnerServer(t, models.Bid{ID: "bid-2", Price: 5.3, QualityScore: 0.5,
+				ClickURL: "http://example.com/2"}).URL
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, cfg.Validate(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, cfg.Validate())
+			service, err := services.NewAuctionServiceWithClock(cfg, &steppingClock{now: leadQualityStart})
+			require.NoError(t, err)
+			defer service.Close()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "bonus-" + tc.name, LeadID: "lead-1", Vertical: "auto", LeadQuality: tc.quality})
+			require.NoError(t, err)
+			require.Len(t, response.Bids, 1)
+			assert.Equal(t, tc.expectedPartner, response.Bids[0].PartnerID)
+		})
+	}
+}
+
+// TestLeadQualityExport tests that exported rows carry the lead quality signals left by the PII
+// policy and each bid's acknowledgement
+func TestLeadQualityExport(t *testing.T) {
+	dir := t.TempDir()
+	cfg := newStrategyTestConfig()
+	cfg.MaxBidsPerRequest = 1
+	cfg.PIIPolicy = &config.PIIPolicy{Drop: []string{"lead_quality.session_duration_ms"}}
+	cfg.Partners["partner-1"].Endpoint = newPartnerServer(t, models.Bid{ID: "bid-1", Price: 6.0, QualityScore: 0.5,
+		ClickURL: "http://example.com/1", QualityAcknowledged: true}).URL
+	cfg.Partners["partner-2"].Endpoint = newPartnerServer(t, models.Bid{ID: "bid-2", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/2"}).URL
+	cfg.Export = newExportTestConfig(dir)
+	require.NoError(t, cfg.Validate())
+	service, err := services.NewAuctionServiceWithClock(cfg, &steppingClock{now: leadQualityStart.Add(30 * time.Minute)})
+	require.NoError(t, err)
+
+	quality := newLeadQuality()
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	_, err = service.RunAuction(ctx, &models.BidRequest{RequestID: "quality-export", LeadID: "lead-1", Vertical: "auto", LeadQuality: quality})
+	require.NoError(t, err)
+	require.NoError(t, service.Close())
+
+	files := exportFiles(t, dir)
+	require.Len(t, files, 1)
+	rows := readExportRows(t, filepath.Join(dir, files[0]))
+	require.Len(t, rows, 2)
+	for _, row := range rows {
+		require.NotNil(t, row.ConsentTimestamp)
+		assert.True(t, quality.ConsentTimestamp.Equal(*row.ConsentTimestamp))
+		assert.Nil(t, row.SessionDurationMs)
+		assert.Equal(t, quality.PreviouslySold, row.PreviouslySold)
+		assert.Equal(t, quality.Score, row.LeadScore)
+		assert.Equal(t, row.PartnerID == "partner-1", row.QualityAcknowledged)
+	}
+	// The partner call path still receives every signal
+	assert.NotNil(t, quality.SessionDurationMs)
+}
+
+// TestLeadQualityPIIPolicy tests that a PII policy may only drop known lead quality fields and
+// that sanitized requests leave the dropped fields out
+func TestLeadQualityPIIPolicy(t *testing.T) {
+	testCases := []struct {
+		name            string
+		policy          *config.PIIPolicy
+		expectedErr     string
+		expectedQuality func(quality *models.LeadQuality) *models.LeadQuality
+	}{
+		{
+			name:            "No Policy",
+			expectedQuality: func(quality *models.LeadQuality) *models.LeadQuality { return quality },
+		},
+		{
+			name:   "Drop Score",
+			policy: &config.PIIPolicy{Drop: []string{"lead_quality.score", "email"}},
+			expectedQuality: func(quality *models.LeadQuality) *models.LeadQuality {
+				quality.Score = nil
+				return quality
+			},
+		},
+		{
+			name: "Drop Every Field",
+			policy: &config.PIIPolicy{Drop: []string{"lead_quality.consent_timestamp", "lead_quality.session_duration_ms",
+				"lead_quality.previously_sold", "lead_quality.score"}},
+			expectedQuality: func(quality *models.LeadQuality) *models.LeadQuality { return nil },
+		},
+		{
+			name:        "Unknown Field",
+			policy:      &config.PIIPolicy{Drop: []string{"lead_quality.session_id"}},
+			expectedErr: `PII policy drops unknown lead quality field "lead_quality.session_id"`,
+		},
+		{
+			name:        "Masked Field",
+			policy:      &config.PIIPolicy{Mask: []string{"lead_quality.score"}},
+			expectedErr: `PII policy can only drop lead quality field "lead_quality.score"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.PIIPolicy = tc.policy
+
+			err := cfg.Validate()
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			request := &models.BidRequest{RequestID: "pii-quality", LeadQuality: newLeadQuality()}
+			sanitized := request.Sanitized(tc.policy)
+			assert.Equal(t, tc.expectedQuality(newLeadQuality()), sanitized.LeadQuality)
+			assert.Equal(t, newLeadQuality(), request.LeadQuality)
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// newLocalizationTestRouter serves bids from a partner with French and English variants and a
+// partner with unlocalized display fields only
+func newLocalizationTestRouter(t *testing.T, localization *config.LocalizationConfig) *gin.Engine {
+	localized := newPartnerServer(t, models.Bid{ID: "bid-localized", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/1",
+		Creative: map[string]interface{}{
+			"title":       "Save on car insurance",
+			"description": "Compare quotes",
+			"image":       "https://cdn.example.com/1.png",
+			"localized": map[string]interface{}{
+				"fr-CA": map[string]interface{}{"title": "Économisez sur l'assurance auto", "description": "Comparez les soumissions"},
+				"en":    map[string]interface{}{"title": "Save big on car insurance"},
+			},
+		}})
+	plain := newPartnerServer(t, models.Bid{ID: "bid-plain", Price: 9.0, QualityScore: 0.5, ClickURL: "http://example.com/2",
+		Creative: map[string]interface{}{"title": "Cheap auto quotes"}})
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: localized.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+		"partner-2": {ID: "partner-2", Endpoint: plain.URL, APIKey: "key-2", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2))
+	cfg.Localization = localization
+	service := newTestService(t, cfg)
+	router, _ := newTestRouter(t, service, cfg)
+	return router
+}
+
+// TestResponseLocalization tests that winning bids show the display fields of the caller's best
+// matching language, that unlocalized bids are unchanged, and that the locale is recorded
+func TestResponseLocalization(t *testing.T) {
+	testCases := []struct {
+		name                string
+		localization        *config.LocalizationConfig
+		acceptLanguage      string
+		expectedLocale      string
+		expectedTitle       string
+		expectedDescription string
+	}{
+		{name: "Exact Match", localization: &config.LocalizationConfig{Enabled: true}, acceptLanguage: "fr-ca, en;q=0.5",
+			expectedLocale: "fr-CA", expectedTitle: "Économisez sur l'assurance auto", expectedDescription: "Comparez les soumissions"},
+		{name: "Same Language", localization: &config.LocalizationConfig{Enabled: true}, acceptLanguage: "fr-FR",
+			expectedLocale: "fr-FR", expectedTitle: "Économisez sur l'assurance auto", expectedDescription: "Comparez les soumissions"},
+		{name: "Quality Order", localization: &config.LocalizationConfig{Enabled: true}, acceptLanguage: "de, fr-CA;q=0.4, en-US;q=0.8",
+			expectedLocale: "en-US", expectedTitle: "Save big on car insurance", expectedDescription: "Compare quotes"},
+		{name: "Refused Language", localization: &config.LocalizationConfig{Enabled: true}, acceptLanguage: "fr-CA;q=0, *",
+			expectedTitle: "Save on car insurance", expectedDescription: "Compare quotes"},
+		{name: "Default Language", localization: &config.LocalizationConfig{Enabled: true, DefaultLanguage: "fr-CA"}, acceptLanguage: "de",
+			expectedLocale: "fr-CA", expectedTitle: "Économisez sur l'assurance auto", expectedDescription: "Comparez les soumissions"},
+		{name: "No Match", localization: &config.LocalizationConfig{Enabled: true}, acceptLanguage: "de",
+			expectedTitle: "Save on car insurance", expectedDescription: "Compare quotes"},
+		{name: "Disabled", acceptLanguage: "fr-CA",
+			expectedTitle: "Save on car insurance", expectedDescription: "Compare quotes"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := newLocalizationTestRouter(t, tc.localization)
+			expectedLabel := tc.expectedLocale
+			if expectedLabel == "" {
+				expectedLabel = "none"
+			}
+			before := gatheredMetric(t, "rtb_response_locales_total", map[string]string{"locale": expectedLabel})
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/bids", bytes.NewBufferString(`{"request_id": "locale-1", "lead_id": "lead-1", "vertical": "auto"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Language", tc.acceptLanguage)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var response models.BidResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tc.expectedLocale, response.Locale)
+			require.Len(t, response.Bids, 2)
+			creatives := map[string]map[string]interface{}{}
+			for _, bid := range response.Bids {
+				creatives[bid.PartnerID] = bid.Creative
+			}
+			assert.Equal(t, tc.expectedTitle, creatives["partner-1"]["title"])
+			assert.Equal(t, tc.expectedDescription, creatives["partner-1"]["description"])
+			assert.Equal(t, "https://cdn.example.com/1.png", creatives["partner-1"]["image"])
+			// Partners without localized variants keep working unchanged
+			assert.Equal(t, map[string]interface{}{"title": "Cheap auto quotes"}, creatives["partner-2"])
+
+			if tc.localization == nil {
+				assert.Empty(t, w.Header().Get("Vary"))
+				assert.Equal(t, before, gatheredMetric(t, "rtb_response_locales_total", map[string]string{"locale": expectedLabel}))
+				return
+			}
+			assert.Equal(t, "Accept-Language", w.Header().Get("Vary"))
+			assert.Equal(t, before+1, gatheredMetric(t, "rtb_response_locales_total", map[string]string{"locale": expectedLabel}))
+		})
+	}
+}
+
+// TestLocalizedCreativeValidation tests that bids with malformed localized variants are rejected
+func TestLocalizedCreativeValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		creative    map[string]interface{}
+		expectedErr error
+	}{
+		{name: "Unlocalized", creative: map[string]interface{}{"title": "Quotes"}},
+		{name: "Valid Variants", creative: map[string]interface{}{"localized": map[string]interface{}{
+			"fr-CA":      map[string]interface{}{"title": "Soumissions", "description": "Comparez"},
+			"zh-Hant-TW": map[string]interface{}{"title": "報價"},
+		}}},
+		{name: "Malformed Tag", creative: map[string]interface{}{"localized": map[string]interface{}{
+			"french": map[string]interface{}{"title": "Soumissions"},
+		}}, expectedErr: models.ErrInvalidLocalizedCreative},
+		{name: "Variant Not An Object", creative: map[string]interface{}{"localized": map[string]interface{}{"fr": "Soumissions"}},
+			expectedErr: models.ErrInvalidLocalizedCreative},
+		{name: "Title Not A String", creative: map[string]interface{}{"localized": map[string]interface{}{
+			"fr": map[string]interface{}{"title": 5.0},
+		}}, expectedErr: models.ErrInvalidLocalizedCreative},
+		{name: "Variants Not An Object", creative: map[string]interface{}{"localized": []interface{}{"fr"}},
+			expectedErr: models.ErrInvalidLocalizedCreative},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bid := &models.Bid{ID: "bid-1", PartnerID: "partner-1", Price: 5.0, ClickURL: "http://example.com/1", QualityScore: 0.5, Creative: tc.creative}
+			assert.Equal(t, tc.expectedErr, models.ValidateBid(bid))
+		})
+	}
+}
+
+// TestLocalizationValidation tests the default language setting
+func TestLocalizationValidation(t *testing.T) {
+	testCases := []struct {
+		name         string
+		localization *config.LocalizationConfig
+		expectedErr  string
+	}{
+		{name: "Valid Default", localization: &config.LocalizationConfig{Enabled: true, DefaultLanguage: "fr-CA"}},
+		{name: "No Default", localization: &config.LocalizationConfig{Enabled: true}},
+		{name: "Malformed Default", localization: &config.LocalizationConfig{Enabled: true, DefaultLanguage: "fr_CA"},
+			expectedErr: `invalid default language: "fr_CA"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Localization = tc.localization
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
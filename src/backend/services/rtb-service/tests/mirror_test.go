@@ -0,0 +1,176 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// mirrorSandbox records the requests a partner sandbox receives and answers with status after delay
+type mirrorSandbox struct {
+	mutex    sync.Mutex
+	requests []*http.Request
+}
+
+// newMirrorSandbox starts a sandbox answering with status after delay
+func newMirrorSandbox(t *testing.T, status int, delay time.Duration) (*mirrorSandbox, *httptest.Server) {
+	sandbox := &mirrorSandbox{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sandbox.mutex.Lock()
+		sandbox.requests = append(sandbox.requests, r)
+		sandbox.mutex.Unlock()
+		time.Sleep(delay)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return sandbox, server
+}
+
+// received returns the requests received so far
+func (s *mirrorSandbox) received() []*http.Request {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*http.Request(nil), s.requests...)
+}
+
+// newMirrorTestService creates a service whose partner bids 10 and mirrors to the sandbox
+func newMirrorTestService(t *testing.T, partnerID string, mirror *config.PartnerMirror, disabled bool) *services.AuctionService {
+	partner := newPartnerServer(t, models.Bid{ID: "bid-1", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		partnerID: {ID: partnerID, Endpoint: partner.URL, APIKey: "live-key", Timeout: 200 * time.Millisecond, Enabled: true, Mirror: mirror},
+	})
+	cfg.MirroringDisabled = disabled
+	service := newTestService(t, cfg)
+	return service
+}
+
+// TestPartnerMirror tests that mirrored requests reach the sandbox with the partner's or sandbox
+// key, are capped by the mirror QPS, and never change the auction or the partner's breaker
+func TestPartnerMirror(t *testing.T) {
+	testCases := []struct {
+		name             string
+		status           int
+		delay            time.Duration
+		apiKey           string
+		maxQPS           int
+		disabled         bool
+		auctions         int
+		expectedMirrored int
+		expectedAuth     string
+		expectedResults  map[string]float64
+	}{
+		{name: "Partner Key", status: http.StatusNoContent, maxQPS: 10, auctions: 2, expectedMirrored: 2, expectedAuth: "Bearer live-key",
+			expectedResults: map[string]float64{"success": 2}},
+		{name: "Sandbox Key", status: http.StatusOK, apiKey: "sandbox-key", maxQPS: 10, auctions: 1, expectedMirrored: 1, expectedAuth: "Bearer sandbox-key",
+			expectedResults: map[string]float64{"success": 1}},
+		{name: "Sandbox Errors", status: http.StatusInternalServerError, maxQPS: 10, auctions: 2, expectedMirrored: 2, expectedAuth: "Bearer live-key",
+			expectedResults: map[string]float64{"failure": 2}},
+		{name: "Slow Sandbox", status: http.StatusOK, delay: 300 * time.Millisecond, maxQPS: 10, auctions: 1, expectedMirrored: 1, expectedAuth: "Bearer live-key",
+			expectedResults: map[string]float64{"failure": 1}},
+		{name: "QPS Cap", status: http.StatusOK, maxQPS: 1, auctions: 3, expectedMirrored: 1, expectedAuth: "Bearer live-key",
+			expectedResults: map[string]float64{"success": 1, "dropped": 2}},
+		{name: "Globally Disabled", status: http.StatusOK, maxQPS: 10, disabled: true, auctions: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partnerID := "mirror-" + tc.name
+			sandbox, server := newMirrorSandbox(t, tc.status, tc.delay)
+			service := newMirrorTestService(t, partnerID, &config.PartnerMirror{
+				Endpoint: server.URL + "/sandbox",
+				Timeout:  100 * time.Millisecond,
+				MaxQPS:   tc.maxQPS,
+				APIKey:   tc.apiKey,
+			}, tc.disabled)
+			resultsBefore := make(map[string]float64, len(tc.expectedResults))
+			for result := range tc.expectedResults {
+				resultsBefore[result] = gatheredMetric(t, "rtb_partner_mirrors_total", map[string]string{"partner": partnerID, "result": result})
+			}
+			durationsBefore := gatheredMetric(t, "rtb_partner_mirror_duration_seconds", map[string]string{"partner": partnerID})
+
+			for i := 0; i < tc.auctions; i++ {
+				ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+				started := time.Now()
+				response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "mirror-request", LeadID: "lead-1", Vertical: "auto"})
+				cancel()
+				require.NoError(t, err)
+				// The auction neither waits for the mirror nor sees its response
+				assert.Less(t, time.Since(started), 250*time.Millisecond)
+				require.Len(t, response.Bids, 1)
+				assert.Equal(t, 10.0, response.Bids[0].Price)
+			}
+			// Close waits for mirrored requests in flight
+			service.Close()
+
+			received := sandbox.received()
+			require.Len(t, received, tc.expectedMirrored)
+			for _, req := range received {
+				assert.Equal(t, "/sandbox", req.URL.Path)
+				assert.Equal(t, tc.expectedAuth, req.Header.Get("Authorization"))
+			}
+			for result, expected := range tc.expectedResults {
+				assert.Equal(t, expected, gatheredMetric(t, "rtb_partner_mirrors_total", map[string]string{"partner": partnerID, "result": result})-resultsBefore[result], result)
+			}
+			assert.Equal(t, float64(tc.expectedMirrored), gatheredMetric(t, "rtb_partner_mirror_duration_seconds", map[string]string{"partner": partnerID})-durationsBefore)
+			assert.Equal(t, services.BreakerClosed, service.PartnerBreakerState(partnerID))
+		})
+	}
+
+	t.Run("Dry Run", func(t *testing.T) {
+		sandbox, server := newMirrorSandbox(t, http.StatusOK, 0)
+		service := newMirrorTestService(t, "mirror-dry-run", &config.PartnerMirror{Endpoint: server.URL, MaxQPS: 10}, false)
+		dryRun := models.NewDryRun()
+		ctx, cancel := context.WithTimeout(models.ContextWithDryRun(context.Background(), dryRun), 500*time.Millisecond)
+		defer cancel()
+		_, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "mirror-dry-run", LeadID: "lead-1", Vertical: "auto"})
+		require.NoError(t, err)
+		service.Close()
+
+		assert.Empty(t, sandbox.received())
+		assert.Contains(t, dryRun.Effects(), models.SideEffect{Type: models.SideEffectMirror, PartnerID: "mirror-dry-run"})
+	})
+}
+
+// TestPartnerMirrorValidation tests the mirror endpoint, timeout, QPS, and sandbox key settings
+func TestPartnerMirrorValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mirror      *config.PartnerMirror
+		expectedErr string
+	}{
+		{name: "No Mirror"},
+		{name: "Valid", mirror: &config.PartnerMirror{Endpoint: "https://sandbox.partner-1/bid/{vertical}", Timeout: time.Second, MaxQPS: 5, APIKey: "sandbox-key"}},
+		{name: "Relative Endpoint", mirror: &config.PartnerMirror{Endpoint: "/bid", MaxQPS: 5}, expectedErr: `invalid mirror endpoint for partner partner-1: "/bid"`},
+		{name: "Unknown Placeholder", mirror: &config.PartnerMirror{Endpoint: "https://sandbox.partner-1/{unknown}", MaxQPS: 5},
+			expectedErr: "invalid mirror endpoint template for partner partner-1"},
+		{name: "Timeout Too Long", mirror: &config.PartnerMirror{Endpoint: "https://sandbox.partner-1", Timeout: time.Minute, MaxQPS: 5},
+			expectedErr: "mirror timeout must be between 0 and 5s for partner partner-1"},
+		{name: "No QPS Cap", mirror: &config.PartnerMirror{Endpoint: "https://sandbox.partner-1"}, expectedErr: "mirror max QPS must be positive for partner partner-1"},
+		{name: "Unresolved Key", mirror: &config.PartnerMirror{Endpoint: "https://sandbox.partner-1", MaxQPS: 5, APIKey: "env:RTB_TEST_MISSING_MIRROR_KEY"},
+			expectedErr: "unresolved mirror API key for partner partner-1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].Mirror = tc.mirror
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newSeatPartner starts a partner that answers every call with the given bids as a JSON array
+func newSeatPartner(t *testing.T, bids []models.Bid) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(bids)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// newSeatBids returns count valid bids for a partner, priced in descending order
+func newSeatBids(prefix string, count int, topPrice float64) []models.Bid {
+	bids := make([]models.Bid, 0, count)
+	for i := 0; i < count; i++ {
+		bids = append(bids, models.Bid{
+			ID:           fmt.Sprintf("%s-%d", prefix, i+1),
+			Price:        topPrice - float64(i),
+			QualityScore: 0.5,
+			ClickURL:     fmt.Sprintf("http://%s-%d.example.com/click", prefix, i+1),
+		})
+	}
+	return bids
+}
+
+// TestMultiSeatAuction tests that every valid seat enters the auction, capped per response, and
+// that only each partner's best seat can win
+func TestMultiSeatAuction(t *testing.T) {
+	seatsA := newSeatBids("a", 7, 20.0)
+	seatsA[1].ClickURL = "" // invalid seats are dropped without affecting the others
+	seatsB := newSeatBids("b", 2, 15.0)
+	partnerA := newSeatPartner(t, seatsA)
+	partnerB := newSeatPartner(t, seatsB)
+
+	service, err := services.NewAuctionService(newTestConfig(map[string]*config.PartnerConfig{
+		"partner-a": {ID: "partner-a", Endpoint: partnerA.URL, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true},
+		"partner-b": {ID: "partner-b", Endpoint: partnerB.URL, APIKey: "key-b", Timeout: 200 * time.Millisecond, Enabled: true, MaxBidsPerResponse: 1},
+	}, withMaxBids(5)))
+	require.NoError(t, err)
+
+	var mutex sync.Mutex
+	var streamed []string
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	debug := models.NewDebugInfo()
+	response, err := service.RunAuctionStream(models.ContextWithDebug(ctx, debug), &models.BidRequest{
+		RequestID: "multi-seat-test",
+		LeadID:    "lead-1",
+		Vertical:  "auto",
+	}, func(bid *models.Bid) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		streamed = append(streamed, bid.ID)
+	})
+	require.NoError(t, err)
+
+	winners := make([]string, 0, len(response.Bids))
+	for _, bid := range response.Bids {
+		winners = append(winners, bid.ID)
+	}
+	assert.ElementsMatch(t, []string{"a-1", "b-1"}, winners)
+	assert.ElementsMatch(t, []string{"a-1", "a-3", "a-4", "a-5", "a-6", "b-1"}, streamed)
+
+	partner, _ := debug.Partner("partner-a")
+	assert.Equal(t, 7, partner.Bids)
+	assert.Equal(t, map[string]models.Reason{
+		"a-3": "partner_cap",
+		"a-4": "partner_cap",
+		"a-5": "partner_cap",
+		"a-6": "partner_cap",
+		"a-7": "response_cap",
+	}, partner.Losses)
+
+	partner, _ = debug.Partner("partner-b")
+	assert.Equal(t, 2, partner.Bids)
+	assert.Equal(t, map[string]models.Reason{"b-2": "response_cap"}, partner.Losses)
+}
+
+// TestMaxBidsPerResponseValidation tests per-response bid cap bounds
+func TestMaxBidsPerResponseValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		value       int
+		expectedErr string
+	}{
+		{name: "Default", value: 0},
+		{name: "Raised", value: 20},
+		{name: "Negative", value: -1, expectedErr: "max bids per response"},
+		{name: "Too High", value: 51, expectedErr: "max bids per response"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].MaxBidsPerResponse = tc.value
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
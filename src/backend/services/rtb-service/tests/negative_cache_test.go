@@ -0,0 +1,271 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// negativeCacheStores are the negative cache backends every negative cache test runs against
+var negativeCacheStores = []string{"Memory", "Redis"}
+
+// negativeCacheTTL is how long no-bids stay cached in the negative cache tests
+const negativeCacheTTL = time.Minute
+
+// newNegativeCachePartner returns a partner answering every call with status and body after
+// delay, and its call count
+func newNegativeCachePartner(t *testing.T, status int, body string, delay time.Duration) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// newNegativeCacheTestConfig returns a config where "sparse" is the partner at url and "steady"
+// always bids, caching no-bids by vertical and risk tier in the named store
+func newNegativeCacheTestConfig(t *testing.T, store, url string, partners []string) (*config.Config, *miniredis.Miniredis) {
+	steady := newPartnerServer(t, models.Bid{ID: "steady-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/steady"})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"sparse": {ID: "sparse", Endpoint: url, APIKey: "key-sparse", Timeout: 100 * time.Millisecond, Enabled: true},
+		"steady": {ID: "steady", Endpoint: steady.URL, APIKey: "key-steady", Timeout: 100 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2), withAdmin())
+	cfg.NegativeCache = &config.NegativeCacheConfig{
+		Enabled:       true,
+		TTL:           negativeCacheTTL,
+		SegmentFields: []string{config.SegmentFieldVertical, "user_data.risk_tier"},
+		Partners:      partners,
+	}
+	if store != "Redis" {
+		return cfg, nil
+	}
+	redisServer := miniredis.RunT(t)
+	host, portText, err := net.SplitHostPort(redisServer.Addr())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portText)
+	require.NoError(t, err)
+	cfg.Redis = &config.RedisConfig{Host: host, Port: port, Timeout: time.Second}
+	return cfg, redisServer
+}
+
+// runNegativeCacheAuction runs an auto auction for a lead in riskTier, or without a tier when it
+// is empty, and returns the debug output
+func runNegativeCacheAuction(t *testing.T, ctx context.Context, service *services.AuctionService, riskTier string) *models.DebugInfo {
+	request := &models.BidRequest{RequestID: "neg-" + strconv.FormatInt(time.Now().UnixNano(), 36), LeadID: "lead-1", Vertical: "auto"}
+	if riskTier != "" {
+		request.UserData = map[string]interface{}{"risk_tier": riskTier}
+	}
+	debug := models.NewDebugInfo()
+	ctx, cancel := context.WithTimeout(models.ContextWithDebug(ctx, debug), 500*time.Millisecond)
+	defer cancel()
+	_, err := service.RunAuction(ctx, request)
+	require.NoError(t, err)
+	return debug
+}
+
+// TestNegativeCache tests which partner responses are cached and which later auctions skip the partner
+func TestNegativeCache(t *testing.T) {
+	testCases := []struct {
+		name          string
+		status        int
+		body          string
+		delay         time.Duration
+		firstTier     string
+		secondTier    string
+		partners      []string
+		expectedCalls int32
+	}{
+		{name: "No Content", status: http.StatusNoContent, firstTier: "sr22", secondTier: "sr22", expectedCalls: 1},
+		{name: "Empty Bid List", status: http.StatusOK, body: `[]`, firstTier: "sr22", secondTier: "sr22", expectedCalls: 1},
+		{name: "Server Error", status: http.StatusInternalServerError, firstTier: "sr22", secondTier: "sr22", expectedCalls: 2},
+		{name: "Timeout", status: http.StatusNoContent, delay: 300 * time.Millisecond, firstTier: "sr22", secondTier: "sr22", expectedCalls: 2},
+		{name: "Malformed Response", status: http.StatusOK, body: `{"id": `, firstTier: "sr22", secondTier: "sr22", expectedCalls: 2},
+		{name: "Invalid Bid", status: http.StatusOK, body: `{"id": "bad-bid"}`, firstTier: "sr22", secondTier: "sr22", expectedCalls: 2},
+		{name: "Other Segment", status: http.StatusNoContent, firstTier: "sr22", secondTier: "standard", expectedCalls: 2},
+		{name: "Missing Segment Field", status: http.StatusNoContent, expectedCalls: 2},
+		{name: "Partner Not Covered", status: http.StatusNoContent, firstTier: "sr22", secondTier: "sr22", partners: []string{"steady"}, expectedCalls: 2},
+	}
+
+	for _, tc := range testCases {
+		for _, store := range negativeCacheStores {
+			t.Run(tc.name+"/"+store, func(t *testing.T) {
+				sparse, calls := newNegativeCachePartner(t, tc.status, tc.body, tc.delay)
+				cfg, _ := newNegativeCacheTestConfig(t, store, sparse.URL, tc.partners)
+				service, err := services.NewAuctionService(cfg)
+				require.NoError(t, err)
+				defer service.Close()
+
+				hits := gatheredMetric(t, "rtb_negative_cache_lookups_total", map[string]string{"partner": "sparse", "result": "hit"})
+				runNegativeCacheAuction(t, context.Background(), service, tc.firstTier)
+				debug := runNegativeCacheAuction(t, context.Background(), service, tc.secondTier)
+
+				assert.Equal(t, tc.expectedCalls, calls.Load())
+				decisions, _ := debug.Partner("sparse")
+				skipped := tc.expectedCalls == 1
+				if skipped {
+					assert.Equal(t, models.ReasonNegativeCached, decisions.SkipReason)
+				} else {
+					assert.Empty(t, decisions.SkipReason)
+				}
+				assert.Equal(t, skipped, gatheredMetric(t, "rtb_negative_cache_lookups_total", map[string]string{"partner": "sparse", "result": "hit"})-hits == 1)
+			})
+		}
+	}
+}
+
+// TestNegativeCacheExpiry tests that a partner is called again once its cached no-bid expires
+func TestNegativeCacheExpiry(t *testing.T) {
+	for _, store := range negativeCacheStores {
+		t.Run(store, func(t *testing.T) {
+			sparse, calls := newNegativeCachePartner(t, http.StatusNoContent, "", 0)
+			cfg, redisServer := newNegativeCacheTestConfig(t, store, sparse.URL, nil)
+			clock := &steppingClock{now: time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)}
+			service, err := services.NewAuctionServiceWithClock(cfg, clock)
+			require.NoError(t, err)
+			defer service.Close()
+
+			runNegativeCacheAuction(t, context.Background(), service, "sr22")
+			runNegativeCacheAuction(t, context.Background(), service, "sr22")
+			assert.Equal(t, int32(1), calls.Load())
+
+			clock.now = clock.now.Add(negativeCacheTTL)
+			if redisServer != nil {
+				redisServer.FastForward(negativeCacheTTL)
+			}
+			runNegativeCacheAuction(t, context.Background(), service, "sr22")
+			assert.Equal(t, int32(2), calls.Load())
+		})
+	}
+}
+
+// TestNegativeCacheDryRun tests that dry runs honor cached no-bids but record new ones instead of caching them
+func TestNegativeCacheDryRun(t *testing.T) {
+	sparse, calls := newNegativeCachePartner(t, http.StatusNoContent, "", 0)
+	cfg, _ := newNegativeCacheTestConfig(t, "Memory", sparse.URL, nil)
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	dryRun := models.NewDryRun()
+	runNegativeCacheAuction(t, models.ContextWithDryRun(context.Background(), dryRun), service, "sr22")
+	effects := 0
+	for _, effect := range dryRun.Effects() {
+		if effect.Type == models.SideEffectNegativeCache {
+			effects++
+			assert.Equal(t, "sparse", effect.PartnerID)
+		}
+	}
+	assert.Equal(t, 1, effects)
+
+	runNegativeCacheAuction(t, context.Background(), service, "sr22")
+	assert.Equal(t, int32(2), calls.Load())
+
+	debug := runNegativeCacheAuction(t, models.ContextWithDryRun(context.Background(), models.NewDryRun()), service, "sr22")
+	decisions, _ := debug.Partner("sparse")
+	assert.Equal(t, models.ReasonNegativeCached, decisions.SkipReason)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+// TestNegativeCacheFlush tests flushing cached no-bids through the admin endpoint
+func TestNegativeCacheFlush(t *testing.T) {
+	testCases := []struct {
+		name            string
+		query           string
+		disabled        bool
+		expectedStatus  int
+		expectedRemoved int
+		expectedCalls   int32
+	}{
+		{name: "Flush Partner", query: "?partner=sparse", expectedStatus: http.StatusOK, expectedRemoved: 1, expectedCalls: 2},
+		{name: "Flush All", expectedStatus: http.StatusOK, expectedRemoved: 1, expectedCalls: 2},
+		{name: "Flush Other Partner", query: "?partner=steady", expectedStatus: http.StatusOK, expectedRemoved: 0, expectedCalls: 1},
+		{name: "Flush Partner Prefix", query: "?partner=spars", expectedStatus: http.StatusOK, expectedRemoved: 0, expectedCalls: 1},
+		{name: "Disabled", disabled: true, expectedStatus: http.StatusNotFound, expectedCalls: 2},
+	}
+
+	for _, tc := range testCases {
+		for _, store := range negativeCacheStores {
+			t.Run(tc.name+"/"+store, func(t *testing.T) {
+				gin.SetMode(gin.TestMode)
+				sparse, calls := newNegativeCachePartner(t, http.StatusNoContent, "", 0)
+				cfg, _ := newNegativeCacheTestConfig(t, store, sparse.URL, nil)
+				cfg.NegativeCache.Enabled = !tc.disabled
+				service, err := services.NewAuctionService(cfg)
+				require.NoError(t, err)
+				defer service.Close()
+				admin, err := handlers.NewAdminHandler(service, cfg, handlers.BuildInfo{})
+				require.NoError(t, err)
+				router := gin.New()
+				admin.RegisterRoutes(router.Group("/admin"))
+
+				runNegativeCacheAuction(t, context.Background(), service, "sr22")
+				req := httptest.NewRequest(http.MethodDelete, "/admin/negative-cache"+tc.query, nil)
+				req.Header.Set("X-Admin-Key", testAdminKey)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+				if tc.expectedStatus == http.StatusOK {
+					var response struct {
+						Removed int `json:"removed"`
+					}
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+					assert.Equal(t, tc.expectedRemoved, response.Removed)
+				}
+				runNegativeCacheAuction(t, context.Background(), service, "sr22")
+				assert.Equal(t, tc.expectedCalls, calls.Load())
+			})
+		}
+	}
+}
+
+// TestNegativeCacheValidation tests negative cache configuration checks
+func TestNegativeCacheValidation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		negativeCache *config.NegativeCacheConfig
+		expectedError bool
+	}{
+		{name: "Disabled", negativeCache: &config.NegativeCacheConfig{}},
+		{name: "Valid", negativeCache: &config.NegativeCacheConfig{Enabled: true, TTL: time.Minute,
+			SegmentFields: []string{"vertical", "geo.region", "user_data.risk_tier"}, Partners: []string{"partner-1"}}},
+		{name: "Zero TTL", negativeCache: &config.NegativeCacheConfig{Enabled: true, SegmentFields: []string{"vertical"}}, expectedError: true},
+		{name: "No Segment Fields", negativeCache: &config.NegativeCacheConfig{Enabled: true, TTL: time.Minute}, expectedError: true},
+		{name: "Unknown Segment Field", negativeCache: &config.NegativeCacheConfig{Enabled: true, TTL: time.Minute, SegmentFields: []string{"state"}}, expectedError: true},
+		{name: "Empty UserData Field", negativeCache: &config.NegativeCacheConfig{Enabled: true, TTL: time.Minute, SegmentFields: []string{"user_data."}}, expectedError: true},
+		{name: "Unknown Partner", negativeCache: &config.NegativeCacheConfig{Enabled: true, TTL: time.Minute, SegmentFields: []string{"vertical"}, Partners: []string{"nobody"}}, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.NegativeCache = tc.negativeCache
+			if tc.expectedError {
+				assert.Error(t, cfg.Validate())
+			} else {
+				assert.NoError(t, cfg.Validate())
+			}
+		})
+	}
+}
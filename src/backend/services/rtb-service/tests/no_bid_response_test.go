@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+)
+
+// noBidProfileKey is the API key of the no-bid test profile
+const noBidProfileKey = "no-bid-client"
+
+// newNoBidTestRouter serves the bid endpoints for a partner that never bids, with the global
+// no-bid response, a house offer for auto leads, and a profile for noBidProfileKey
+func newNoBidTestRouter(t *testing.T, global, profile *config.NoBidResponseConfig) *gin.Engine {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: server.URL, APIKey: "key", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(5))
+	cfg.NoBidResponse = global
+	cfg.HouseOffers = map[string]*config.HouseOfferConfig{
+		"auto": {ID: "house-auto", ClickURL: "https://example.com/auto-quotes", Creative: map[string]interface{}{"headline": "Compare auto quotes"}},
+	}
+	if profile != nil {
+		cfg.ResponseProfiles = map[string]*config.ResponseProfile{"no-bid": {APIKeys: []string{noBidProfileKey}, NoBidResponse: profile}}
+	}
+	service := newTestService(t, cfg)
+	router, _ := newTestRouter(t, service, cfg)
+	return router
+}
+
+// TestNoBidResponse tests the configured status, reason, and house offer of responses to auctions
+// without valid bids
+func TestNoBidResponse(t *testing.T) {
+	reason := &config.NoBidResponseConfig{Status: http.StatusOK, IncludeReason: true}
+	houseOffers := &config.NoBidResponseConfig{Status: http.StatusOK, HouseOffers: true}
+
+	testCases := []struct {
+		name               string
+		global             *config.NoBidResponseConfig
+		profile            *config.NoBidResponseConfig
+		path               string
+		apiKey             string
+		vertical           string
+		expectedStatus     int
+		expectedReason     string
+		expectedHouseOffer bool
+	}{
+		{name: "Default", path: "/v1/bids", vertical: "auto", expectedStatus: http.StatusNoContent},
+		{name: "Empty 200", global: &config.NoBidResponseConfig{Status: http.StatusOK}, path: "/v1/bids", vertical: "auto", expectedStatus: http.StatusOK},
+		{name: "Reason", global: reason, path: "/v1/bids", vertical: "auto", expectedStatus: http.StatusOK, expectedReason: "no_valid_bids"},
+		{name: "House Offer", global: houseOffers, path: "/v1/bids", vertical: "auto", expectedStatus: http.StatusOK, expectedHouseOffer: true},
+		{name: "House Offer V2", global: houseOffers, path: "/v2/bids", vertical: "auto", expectedStatus: http.StatusOK, expectedHouseOffer: true},
+		{name: "No House Offer For Vertical", global: houseOffers, path: "/v1/bids", vertical: "home", expectedStatus: http.StatusOK},
+		{name: "Profile Override", global: reason, profile: &config.NoBidResponseConfig{Status: http.StatusNoContent}, path: "/v1/bids", apiKey: noBidProfileKey,
+			vertical: "auto", expectedStatus: http.StatusNoContent},
+		{name: "Profile House Offer", profile: houseOffers, path: "/v1/bids", apiKey: noBidProfileKey, vertical: "auto", expectedStatus: http.StatusOK,
+			expectedHouseOffer: true},
+		{name: "Other Client Keeps Global", profile: houseOffers, path: "/v1/bids", apiKey: "someone-else", vertical: "auto", expectedStatus: http.StatusNoContent},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := newNoBidTestRouter(t, tc.global, tc.profile)
+			before := gatheredMetric(t, "rtb_successful_bids_total", map[string]string{"partner": "house"})
+			req := httptest.NewRequest(http.MethodPost, tc.path, bytes.NewBufferString(`{"request_id": "", "lead_id": "lead-1", "vertical": "`+tc.vertical+`"}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.apiKey != "" {
+				req.Header.Set("X-API-Key", tc.apiKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedStatus == http.StatusNoContent {
+				assert.Empty(t, w.Body.String())
+				return
+			}
+
+			var response struct {
+				RequestID  string                   `json:"request_id"`
+				Bids       []map[string]interface{} `json:"bids"`
+				Reason     string                   `json:"reason"`
+				HouseOffer bool                     `json:"house_offer"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.NotEmpty(t, response.RequestID)
+			assert.Equal(t, tc.expectedReason, response.Reason)
+			assert.Equal(t, tc.expectedHouseOffer, response.HouseOffer)
+			if !tc.expectedHouseOffer {
+				assert.Empty(t, response.Bids)
+				return
+			}
+			require.Len(t, response.Bids, 1)
+			assert.Equal(t, "house-auto", response.Bids[0]["id"])
+			assert.Equal(t, "house", response.Bids[0]["partner_id"])
+			assert.Equal(t, "https://example.com/auto-quotes", response.Bids[0]["click_url"])
+			// House offers are not partner bids
+			assert.Equal(t, before, gatheredMetric(t, "rtb_successful_bids_total", map[string]string{"partner": "house"}))
+		})
+	}
+}
+
+// TestNoBidResponseValidation tests the no-bid response and house offer settings
+func TestNoBidResponseValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		modify      func(cfg *config.Config)
+		expectedErr string
+	}{
+		{name: "Default", modify: func(cfg *config.Config) {}},
+		{name: "Status 200 With House Offers", modify: func(cfg *config.Config) {
+			cfg.NoBidResponse = &config.NoBidResponseConfig{Status: http.StatusOK, IncludeReason: true, HouseOffers: true}
+			cfg.HouseOffers = map[string]*config.HouseOfferConfig{"auto": {ID: "house-auto", ClickURL: "https://example.com/auto"}}
+		}},
+		{name: "Unsupported Status", modify: func(cfg *config.Config) {
+			cfg.NoBidResponse = &config.NoBidResponseConfig{Status: http.StatusNotFound}
+		}, expectedErr: "no-bid response status must be 200 or 204: 404"},
+		{name: "Reason Without Body", modify: func(cfg *config.Config) {
+			cfg.NoBidResponse = &config.NoBidResponseConfig{IncludeReason: true}
+		}, expectedErr: "no-bid response reasons and house offers need status 200"},
+		{name: "Profile Without Fields Or No-Bid Response", modify: func(cfg *config.Config) {
+			cfg.ResponseProfiles = map[string]*config.ResponseProfile{"client": {APIKeys: []string{"key"}}}
+		}, expectedErr: "response profile client needs api keys and fields or a no-bid response"},
+		{name: "Profile No-Bid Response", modify: func(cfg *config.Config) {
+			cfg.ResponseProfiles = map[string]*config.ResponseProfile{"client": {APIKeys: []string{"key"}, NoBidResponse: &config.NoBidResponseConfig{HouseOffers: true}}}
+		}, expectedErr: "response profile client: no-bid response reasons and house offers need status 200"},
+		{name: "House Offer Without ID", modify: func(cfg *config.Config) {
+			cfg.HouseOffers = map[string]*config.HouseOfferConfig{"auto": {ClickURL: "https://example.com/auto"}}
+		}, expectedErr: "house offer for vertical auto needs an id"},
+		{name: "House Offer Click URL", modify: func(cfg *config.Config) {
+			cfg.HouseOffers = map[string]*config.HouseOfferConfig{"auto": {ID: "house-auto", ClickURL: "/auto"}}
+		}, expectedErr: `invalid house offer click URL for vertical auto: "/auto"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			tc.modify(cfg)
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// noBidMapping maps a partner whose declines carry a reason at decision.reason
+var noBidMapping = &config.FieldMapping{
+	Response: map[string]string{
+		"id":                            "offer.offer_id",
+		"price":                         "offer.payout",
+		"click_url":                     "offer.redirect",
+		config.ResponseFieldBids:        "offers",
+		config.ResponseFieldNoBidReason: "decision.reason",
+	},
+}
+
+// TestAdapterNoBid tests the no-bid forms each adapter recognizes and the reason codes it reports
+func TestAdapterNoBid(t *testing.T) {
+	testCases := []struct {
+		name           string
+		adapter        services.PartnerAdapter
+		body           string
+		expectedNoBid  bool
+		expectedReason string
+	}{
+		{name: "JSON Empty List", adapter: services.JSONAdapter{}, body: `[]`, expectedNoBid: true},
+		{name: "JSON Reason", adapter: services.JSONAdapter{}, body: `{"no_bid_reason": "below_floor"}`, expectedNoBid: true, expectedReason: "below_floor"},
+		{name: "JSON Bid Mentioning Key", adapter: services.JSONAdapter{},
+			body: `{"id": "bid-1", "price": 5, "click_url": "http://example.com", "creative": {"html": "\"no_bid_reason\""}}`},
+		{name: "XML NoBid Element", adapter: services.XMLAdapter{}, body: `<NoBid reason="no_demand"/>`, expectedNoBid: true, expectedReason: "no_demand"},
+		{name: "XML Empty Bids", adapter: services.XMLAdapter{}, body: `<Bids></Bids>`, expectedNoBid: true},
+		{name: "XML Bid", adapter: services.XMLAdapter{}, body: `<Bid><ID>bid-1</ID><Price>5</Price><ClickURL>http://example.com</ClickURL></Bid>`},
+		{name: "Mapped Reason", adapter: services.NewMappedJSONAdapter(noBidMapping), body: `{"decision": {"reason": "budget_exhausted"}}`,
+			expectedNoBid: true, expectedReason: "budget_exhausted"},
+		{name: "Mapped Empty Bids", adapter: services.NewMappedJSONAdapter(noBidMapping), body: `{"offers": []}`, expectedNoBid: true},
+		{name: "Mapped Missing Bids", adapter: services.NewMappedJSONAdapter(noBidMapping), body: `{}`, expectedNoBid: true},
+		{name: "Mapped Bid", adapter: services.NewMappedJSONAdapter(noBidMapping),
+			body: `{"offers": [{"offer": {"offer_id": "bid-1", "payout": 5, "redirect": "http://example.com"}}]}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bids, err := tc.adapter.ParseResponse([]byte(tc.body), http.StatusOK)
+			if !tc.expectedNoBid {
+				require.NoError(t, err)
+				assert.Len(t, bids, 1)
+				return
+			}
+			assert.Empty(t, bids)
+			assert.True(t, errors.Is(err, services.ErrNoBid))
+			var noBid *services.NoBidResponse
+			require.True(t, errors.As(err, &noBid))
+			assert.Equal(t, tc.expectedReason, noBid.Reason)
+		})
+	}
+}
+
+// TestNoBidResponseDescription tests the debug descriptions of no-bid reasons
+func TestNoBidResponseDescription(t *testing.T) {
+	testCases := []struct {
+		reason              string
+		expectedDescription string
+	}{
+		{reason: string(services.NoBidReasonBelowFloor), expectedDescription: "no-bid: below their floor"},
+		{reason: string(services.NoBidReasonOutsideTargeting), expectedDescription: "no-bid: lead outside their targeting"},
+		{reason: "", expectedDescription: "no-bid"},
+		{reason: "capacity", expectedDescription: "no-bid: capacity"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expectedDescription, func(t *testing.T) {
+			noBid := &services.NoBidResponse{Reason: tc.reason}
+			assert.Equal(t, tc.expectedDescription, noBid.Description())
+		})
+	}
+}
+
+// TestAuctionNoBidTracking tests that partner no-bids are counted apart from failures and never
+// retried or held against the circuit breaker
+func TestAuctionNoBidTracking(t *testing.T) {
+	testCases := []struct {
+		name                string
+		status              int
+		body                string
+		expectedReason      string
+		expectedDescription string
+	}{
+		{name: "Below Floor", status: http.StatusOK, body: `{"no_bid_reason": "below_floor"}`, expectedReason: "below_floor", expectedDescription: "no-bid: below their floor"},
+		{name: "Unknown Code", status: http.StatusOK, body: `{"no_bid_reason": "capacity"}`, expectedReason: "other", expectedDescription: "no-bid: capacity"},
+		{name: "No Content", status: http.StatusNoContent, expectedReason: "unspecified", expectedDescription: "no-bid"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			declining, calls := newNegativeCachePartner(t, tc.status, tc.body, 0)
+			cfg, _ := newNegativeCacheTestConfig(t, "Memory", declining.URL, nil)
+			cfg.NegativeCache = nil
+			cfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 1, Cooldown: time.Minute}
+			cfg.Partners["sparse"].Retry = &config.RetryPolicy{MaxAttempts: 3, RetryOn: []string{config.RetryOn5xx, config.RetryOn429, config.RetryOnConnectError}}
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			labels := map[string]string{"partner": "sparse", "reason": tc.expectedReason}
+			before := gatheredMetric(t, "rtb_no_bid_total", labels)
+			var debug *models.DebugInfo
+			for i := 0; i < 3; i++ {
+				debug = runNegativeCacheAuction(t, context.Background(), service, "sr22")
+			}
+
+			assert.Equal(t, int32(3), calls.Load())
+			assert.Equal(t, 3.0, gatheredMetric(t, "rtb_no_bid_total", labels)-before)
+			assert.Equal(t, services.BreakerClosed, service.PartnerBreakerState("sparse"))
+			for _, status := range service.PartnerStatuses() {
+				if status.ID == "sparse" {
+					assert.Equal(t, 0, status.Failures)
+					assert.Equal(t, 3, status.NoBids)
+				}
+			}
+
+			decisions, _ := debug.Partner("sparse")
+			assert.Equal(t, tc.expectedDescription, decisions.NoBid)
+			assert.Empty(t, decisions.Error)
+
+			report, err := service.PartnerReport("sparse", time.Hour)
+			require.NoError(t, err)
+			assert.Equal(t, uint64(3), report.Calls)
+			assert.Equal(t, 1.0, report.NoBidRate)
+		})
+	}
+}
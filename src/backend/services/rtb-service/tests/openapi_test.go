@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/openapi"
+)
+
+// openAPIBodySchema returns the JSON schema of an operation's request body, or of a response
+// when status is set
+func openAPIBodySchema(t *testing.T, path, method, status string) (*openapi.Document, *openapi.Schema) {
+	document, err := handlers.OpenAPIDocument()
+	require.NoError(t, err)
+	item := document.Paths[path]
+	require.NotNil(t, item, path)
+
+	operation := item.Get
+	if method == http.MethodPost {
+		operation = item.Post
+	}
+	require.NotNil(t, operation, method+" "+path)
+	if status == "" {
+		return document, operation.RequestBody.Content["application/json"].Schema
+	}
+	require.Contains(t, operation.Responses, status)
+	return document, operation.Responses[status].Content["application/json"].Schema
+}
+
+// TestOpenAPIBidRequestRoundTrip tests example bid requests against the published schema, and
+// that requests the service decodes and re-encodes still match it
+func TestOpenAPIBidRequestRoundTrip(t *testing.T) {
+	testCases := []struct {
+		name        string
+		body        string
+		expectedErr string
+	}{
+		{name: "Minimal Request", body: `{"lead_id": "lead-1", "vertical": "auto"}`},
+		{
+			name: "Full Request",
+			body: `{"request_id": "req-1", "lead_id": "lead-1", "vertical": "auto", "user_data": {"age": 34, "zip": "94105"},
+				"timeout": 150000000, "timestamp": "2024-01-20T10:00:00Z", "consent": {"gdpr_applies": true, "consent_string": "CO-abc"},
+				"geo": {"country": "US", "region": "CA", "zip": "94105"}, "device": {"type": "mobile", "os": "ios"}}`,
+		},
+		{name: "Null Optional Object", body: `{"lead_id": "lead-1", "vertical": "auto", "geo": null}`},
+		{name: "Duration As String", body: `{"lead_id": "lead-1", "vertical": "auto", "timeout": "150ms"}`, expectedErr: "timeout"},
+		{name: "Invalid Timestamp", body: `{"lead_id": "lead-1", "vertical": "auto", "timestamp": "yesterday"}`, expectedErr: "timestamp"},
+		{name: "Wrong Nested Type", body: `{"lead_id": "lead-1", "vertical": "auto", "consent": {"gdpr_applies": "yes"}}`, expectedErr: "consent.gdpr_applies"},
+		{name: "Unknown Property", body: `{"lead_id": "lead-1", "vertical": "auto", "budget": 5}`, expectedErr: "budget"},
+	}
+
+	document, schema := openAPIBodySchema(t, "/v1/bids", http.MethodPost, "")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := document.Validate(schema, []byte(tc.body))
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			var request models.BidRequest
+			require.NoError(t, json.Unmarshal([]byte(tc.body), &request))
+			encoded, err := json.Marshal(&request)
+			require.NoError(t, err)
+			assert.NoError(t, document.Validate(schema, encoded), string(encoded))
+		})
+	}
+}
+
+// TestOpenAPIBidResponses tests that the bodies the bid endpoint writes match the published
+// response schemas, with either codec and with response shaping
+func TestOpenAPIBidResponses(t *testing.T) {
+	testCases := []struct {
+		name           string
+		query          string
+		body           string
+		expectedStatus int
+	}{
+		{name: "Full Payload", body: `{"lead_id": "lead-1", "vertical": "auto"}`, expectedStatus: http.StatusOK},
+		{name: "Shaped Payload", query: "?fields=id,price", body: `{"lead_id": "lead-1", "vertical": "auto"}`, expectedStatus: http.StatusOK},
+		{name: "Malformed Request", body: `{"lead_id": 1}`, expectedStatus: http.StatusBadRequest},
+		{name: "Unknown Response Field", query: "?fields=id,creatve", body: `{"lead_id": "lead-1", "vertical": "auto"}`, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		for _, codec := range codecNames {
+			t.Run(tc.name+"/"+codec, func(t *testing.T) {
+				router := newShapingTestRouter(t, codec)
+				req := httptest.NewRequest(http.MethodPost, "/v1/bids"+tc.query, bytes.NewBufferString(tc.body))
+				req.Header.Set("Content-Type", "application/json")
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+				document, schema := openAPIBodySchema(t, "/v1/bids", http.MethodPost, strconv.Itoa(w.Code))
+				assert.NoError(t, document.Validate(schema, w.Body.Bytes()), w.Body.String())
+			})
+		}
+	}
+}
+
+// TestOpenAPIDebugVerticalExt tests that the document builds with bid extensions in debug output,
+// and that a response carrying them matches the published schema
+func TestOpenAPIDebugVerticalExt(t *testing.T) {
+	debug := models.NewDebugInfo()
+	debug.RecordVerticalExt("partner-1", "bid-1", json.RawMessage(`{"carrier": "acme"}`))
+	body, err := json.Marshal(&models.BidResponse{RequestID: "openapi-ext", Bids: []*models.Bid{}, Debug: debug})
+	require.NoError(t, err)
+
+	document, schema := openAPIBodySchema(t, "/v1/bids", http.MethodPost, strconv.Itoa(http.StatusOK))
+	assert.NoError(t, document.Validate(schema, body), string(body))
+}
+
+// TestOpenAPIServed tests that the document is served as JSON and that the Swagger UI page loads it
+func TestOpenAPIServed(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/openapi.json", (&handlers.BidHandler{}).HandleOpenAPI)
+	router.GET("/admin/docs", (&handlers.AdminHandler{}).HandleSwaggerUI)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var served openapi.Document
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+	assert.Equal(t, openapi.Version, served.OpenAPI)
+	for _, path := range []string{"/v1/bids", "/v1/bids/batch", "/health", "/v1/partners/{id}/report"} {
+		assert.Contains(t, served.Paths, path)
+	}
+	for _, name := range []string{"BidRequest", "BidResponse", "Bid", "ErrorResponse", "PartnerReport"} {
+		assert.Contains(t, served.Components.Schemas, name)
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/docs", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `url: "/openapi.json"`)
+}
@@ -0,0 +1,210 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newOverrideTestRouter returns a router serving auctions, health, and the admin endpoints, where
+// partner-1 bids 10 and partner-2 bids 6, with overrides expiring against clock
+func newOverrideTestRouter(t *testing.T, clock *steppingClock) (*gin.Engine, *services.AuctionService) {
+	gin.SetMode(gin.TestMode)
+	partner1 := newPartnerServer(t, models.Bid{ID: "bid-1", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	partner2 := newPartnerServer(t, models.Bid{ID: "bid-2", Price: 6.0, QualityScore: 0.5, ClickURL: "http://example.com/2"})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner1.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+		"partner-2": {ID: "partner-2", Endpoint: partner2.URL, APIKey: "key-2", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2), withAdmin())
+	service := newTestServiceWithClock(t, cfg, clock)
+	bidHandler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	adminHandler, err := handlers.NewAdminHandler(service, cfg, handlers.BuildInfo{})
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.POST("/v1/bids", bidHandler.HandleBidRequest)
+	router.GET("/health", bidHandler.HandleHealthCheck)
+	adminHandler.RegisterRoutes(router.Group("/admin"))
+	return router, service
+}
+
+// serveOverrideTest sends a request with the given headers and returns the recorded response
+func serveOverrideTest(router *gin.Engine, method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// overrideTestWinners runs an auction and returns its winning bid IDs, sorted
+func overrideTestWinners(t *testing.T, router *gin.Engine, headers map[string]string) []string {
+	w := serveOverrideTest(router, http.MethodPost, "/v1/bids", `{"lead_id": "lead-1", "vertical": "auto"}`, headers)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var response models.BidResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	winners := make([]string, 0, len(response.Bids))
+	for _, bid := range response.Bids {
+		winners = append(winners, bid.ID)
+	}
+	sort.Strings(winners)
+	return winners
+}
+
+// TestAddOverrideValidation tests that overrides without a bounded TTL, a reason, or an effect are rejected
+func TestAddOverrideValidation(t *testing.T) {
+	valid := services.Override{Floor: 5, Reason: "partner incident", RequestedBy: "oncall"}
+	testCases := []struct {
+		name          string
+		override      func(services.Override) services.Override
+		ttl           time.Duration
+		expectedError error
+	}{
+		{name: "Valid", override: func(o services.Override) services.Override { return o }, ttl: time.Hour},
+		{name: "Missing TTL", override: func(o services.Override) services.Override { return o }, expectedError: services.ErrOverrideTTL},
+		{name: "TTL Too Long", override: func(o services.Override) services.Override { return o }, ttl: 48 * time.Hour, expectedError: services.ErrOverrideTTL},
+		{name: "Missing Reason", override: func(o services.Override) services.Override { o.Reason = ""; return o }, ttl: time.Hour, expectedError: services.ErrOverrideReason},
+		{name: "Missing Requester", override: func(o services.Override) services.Override { o.RequestedBy = ""; return o }, ttl: time.Hour, expectedError: services.ErrOverrideReason},
+		{name: "No Effect", override: func(o services.Override) services.Override { o.Floor = 0; return o }, ttl: time.Hour, expectedError: services.ErrOverrideEmpty},
+		{name: "Negative Floor", override: func(o services.Override) services.Override { o.Floor = -1; return o }, ttl: time.Hour, expectedError: services.ErrOverrideFloor},
+		{name: "Unknown Partner", override: func(o services.Override) services.Override { o.DisabledPartners = []string{"partner-9"}; return o },
+			ttl: time.Hour, expectedError: services.ErrOverridePartner},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, err := services.NewAuctionService(newStrategyTestConfig())
+			require.NoError(t, err)
+			defer service.Close()
+
+			override, err := service.AddOverride(tc.override(valid), tc.ttl)
+			if tc.expectedError != nil {
+				assert.ErrorIs(t, err, tc.expectedError)
+				assert.Empty(t, service.Overrides())
+				return
+			}
+			require.NoError(t, err)
+			assert.NotEmpty(t, override.ID)
+			assert.Equal(t, override.CreatedAt.Add(tc.ttl), override.ExpiresAt)
+			assert.Len(t, service.Overrides(), 1)
+		})
+	}
+}
+
+// TestAdminOverrides tests that admin overrides apply to every auction, show in health and the
+// admin list, and stop applying once they expire or are lifted
+func TestAdminOverrides(t *testing.T) {
+	clock := &steppingClock{now: time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)}
+	router, service := newOverrideTestRouter(t, clock)
+	admin := map[string]string{"X-Admin-Key": testAdminKey}
+	assert.Equal(t, []string{"bid-1", "bid-2"}, overrideTestWinners(t, router, nil))
+
+	// Overrides need a parseable TTL and a reason
+	w := serveOverrideTest(router, http.MethodPost, "/admin/overrides", `{"floor": 8, "reason": "floor incident", "requested_by": "oncall"}`, admin)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = serveOverrideTest(router, http.MethodPost, "/admin/overrides", `{"floor": 8, "ttl": "10m", "requested_by": "oncall"}`, admin)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// A floor drops partner-2's bid until the override expires
+	w = serveOverrideTest(router, http.MethodPost, "/admin/overrides", `{"floor": 8, "ttl": "10m", "reason": "floor incident", "requested_by": "oncall"}`, admin)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, 8.0, gatheredMetric(t, "rtb_override_floor", nil))
+	losses := gatheredMetric(t, "rtb_bid_losses_total", map[string]string{"partner": "partner-2", "reason": "override_floor"})
+	assert.Equal(t, []string{"bid-1"}, overrideTestWinners(t, router, nil))
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_bid_losses_total", map[string]string{"partner": "partner-2", "reason": "override_floor"})-losses)
+
+	var listed struct {
+		Overrides []services.Override `json:"overrides"`
+	}
+	w = serveOverrideTest(router, http.MethodGet, "/admin/overrides", "", admin)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	require.Len(t, listed.Overrides, 1)
+	assert.Equal(t, "floor incident", listed.Overrides[0].Reason)
+	assert.Equal(t, "oncall", listed.Overrides[0].RequestedBy)
+	assert.NotEmpty(t, listed.Overrides[0].AdminKey)
+	assert.NotContains(t, w.Body.String(), testAdminKey)
+
+	w = serveOverrideTest(router, http.MethodGet, "/health", "", nil)
+	assert.Contains(t, w.Body.String(), `"reason":"floor incident"`)
+
+	clock.now = clock.now.Add(11 * time.Minute)
+	assert.Empty(t, service.Overrides())
+	assert.Equal(t, 0.0, gatheredMetric(t, "rtb_override_floor", nil))
+	assert.Equal(t, []string{"bid-1", "bid-2"}, overrideTestWinners(t, router, nil))
+
+	// A kill switch skips partner-1 until it is lifted
+	w = serveOverrideTest(router, http.MethodPost, "/admin/overrides",
+		`{"disabled_partners": ["partner-1"], "ttl": "1h", "reason": "bad creatives", "requested_by": "oncall"}`, admin)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Override services.Override `json:"override"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, []string{"bid-2"}, overrideTestWinners(t, router, nil))
+
+	w = serveOverrideTest(router, http.MethodDelete, "/admin/overrides/"+created.Override.ID, "", admin)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = serveOverrideTest(router, http.MethodDelete, "/admin/overrides/"+created.Override.ID, "", admin)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, []string{"bid-1", "bid-2"}, overrideTestWinners(t, router, nil))
+}
+
+// TestOverrideHeader tests per-request overrides, which only admin callers may send
+func TestOverrideHeader(t *testing.T) {
+	testCases := []struct {
+		name            string
+		override        string
+		adminKey        string
+		expectedStatus  int
+		expectedWinners []string
+	}{
+		{name: "Floor", override: "floor=8", adminKey: testAdminKey, expectedStatus: http.StatusOK, expectedWinners: []string{"bid-1"}},
+		{name: "Disable", override: "disable=partner-1", adminKey: testAdminKey, expectedStatus: http.StatusOK, expectedWinners: []string{"bid-2"}},
+		{name: "Floor And Disable", override: "floor=5; disable=partner-2", adminKey: testAdminKey, expectedStatus: http.StatusOK, expectedWinners: []string{"bid-1"}},
+		{name: "Without Admin Key", override: "floor=8", expectedStatus: http.StatusForbidden},
+		{name: "Wrong Admin Key", override: "floor=8", adminKey: "not-an-admin-key", expectedStatus: http.StatusForbidden},
+		{name: "Malformed", override: "floor=high", adminKey: testAdminKey, expectedStatus: http.StatusBadRequest},
+		{name: "Unknown Setting", override: "ceiling=8", adminKey: testAdminKey, expectedStatus: http.StatusBadRequest},
+		{name: "Unknown Partner", override: "disable=partner-9", adminKey: testAdminKey, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, service := newOverrideTestRouter(t, &steppingClock{now: time.Now()})
+			headers := map[string]string{"X-RTB-Override": tc.override}
+			if tc.adminKey != "" {
+				headers["X-Admin-Key"] = tc.adminKey
+			}
+
+			if tc.expectedStatus != http.StatusOK {
+				w := serveOverrideTest(router, http.MethodPost, "/v1/bids", `{"lead_id": "lead-1", "vertical": "auto"}`, headers)
+				assert.Equal(t, tc.expectedStatus, w.Code)
+				return
+			}
+			before := gatheredMetric(t, "rtb_overridden_auctions_total", map[string]string{"source": "header"})
+			assert.Equal(t, tc.expectedWinners, overrideTestWinners(t, router, headers))
+			assert.Equal(t, 1.0, gatheredMetric(t, "rtb_overridden_auctions_total", map[string]string{"source": "header"})-before)
+
+			// The override applied to that request alone
+			assert.Empty(t, service.Overrides())
+			assert.Equal(t, []string{"bid-1", "bid-2"}, overrideTestWinners(t, router, nil))
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// backoffStart is when the rate limit backoff tests begin
+var backoffStart = time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC)
+
+// rateLimitReply is a partner answer with a status and an optional Retry-After header
+type rateLimitReply struct {
+	status     int
+	retryAfter string
+}
+
+// newRateLimitingPartner starts a partner that sends replies in order, then bids
+func newRateLimitingPartner(t *testing.T, replies []rateLimitReply) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		call := int(calls.Add(1))
+		if call <= len(replies) {
+			if replies[call-1].retryAfter != "" {
+				w.Header().Set("Retry-After", replies[call-1].retryAfter)
+			}
+			w.WriteHeader(replies[call-1].status)
+			return
+		}
+		json.NewEncoder(w).Encode(models.Bid{ID: "bid-backoff", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/backoff"})
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// runBackoffAuction runs an auction and returns its response and debug output
+func runBackoffAuction(t *testing.T, service *services.AuctionService) (*models.BidResponse, *models.DebugInfo) {
+	debug := models.NewDebugInfo()
+	ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), 500*time.Millisecond)
+	defer cancel()
+	response, _ := service.RunAuction(ctx, &models.BidRequest{RequestID: "backoff-" + strconv.FormatInt(time.Now().UnixNano(), 36), LeadID: "lead-1", Vertical: "auto"})
+	return response, debug
+}
+
+// backoffStatus returns the limited partner's status
+func backoffStatus(t *testing.T, service *services.AuctionService) services.PartnerStatus {
+	for _, status := range service.PartnerStatuses() {
+		if status.ID == "limited" {
+			return status
+		}
+	}
+	t.Fatal("partner limited has no status")
+	return services.PartnerStatus{}
+}
+
+// TestRateLimitBackoff tests that a rate-limited partner is skipped until its Retry-After or
+// exponential backoff passes, without tripping its breaker, and that a successful probe clears it
+func TestRateLimitBackoff(t *testing.T) {
+	testCases := []struct {
+		name             string
+		replies          []rateLimitReply
+		expectedBackoffs []time.Duration
+		expectedSource   string
+		expectedFailures int
+	}{
+		{
+			name:             "Retry-After Seconds",
+			replies:          []rateLimitReply{{status: http.StatusTooManyRequests, retryAfter: "3"}},
+			expectedBackoffs: []time.Duration{3 * time.Second},
+			expectedSource:   "retry_after",
+		},
+		{
+			name:             "Retry-After Date",
+			replies:          []rateLimitReply{{status: http.StatusServiceUnavailable, retryAfter: backoffStart.Add(5 * time.Second).Format(http.TimeFormat)}},
+			expectedBackoffs: []time.Duration{5 * time.Second},
+			expectedSource:   "retry_after",
+		},
+		{
+			name:             "Retry-After Capped",
+			replies:          []rateLimitReply{{status: http.StatusTooManyRequests, retryAfter: "3600"}},
+			expectedBackoffs: []time.Duration{8 * time.Second},
+			expectedSource:   "retry_after",
+		},
+		{
+			name: "Exponential Fallback",
+			replies: []rateLimitReply{
+				{status: http.StatusTooManyRequests},
+				{status: http.StatusTooManyRequests, retryAfter: "soon"},
+				{status: http.StatusTooManyRequests},
+				{status: http.StatusTooManyRequests},
+				{status: http.StatusTooManyRequests},
+			},
+			expectedBackoffs: []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 8 * time.Second},
+			expectedSource:   "exponential",
+		},
+		{
+			name:             "Unavailable Without Retry-After",
+			replies:          []rateLimitReply{{status: http.StatusServiceUnavailable}},
+			expectedBackoffs: []time.Duration{0},
+			expectedFailures: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, calls := newRateLimitingPartner(t, tc.replies)
+			cfg := newStrategyTestConfig()
+			cfg.MaxBidsPerRequest = 1
+			cfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 2, Cooldown: time.Minute}
+			cfg.RateLimitBackoff = &config.RateLimitBackoffConfig{Base: time.Second, Max: 8 * time.Second}
+			cfg.Partners = map[string]*config.PartnerConfig{
+				"limited": {ID: "limited", Endpoint: server.URL, APIKey: "key-limited", Timeout: 200 * time.Millisecond, Enabled: true},
+			}
+			clock := &steppingClock{now: backoffStart}
+			service, err := services.NewAuctionServiceWithClock(cfg, clock)
+			require.NoError(t, err)
+			defer service.Close()
+
+			labels := map[string]string{"partner": "limited", "source": tc.expectedSource}
+			before := gatheredMetric(t, "rtb_partner_rate_limits_total", labels)
+			rateLimits := 0
+			for i, backoff := range tc.expectedBackoffs {
+				runBackoffAuction(t, service)
+				require.Equal(t, int32(i+1), calls.Load())
+				status := backoffStatus(t, service)
+				assert.Equal(t, services.BreakerClosed, status.BreakerState)
+				if backoff == 0 {
+					assert.Nil(t, status.BackoffUntil)
+					continue
+				}
+				rateLimits++
+				require.NotNil(t, status.BackoffUntil)
+				assert.Equal(t, clock.now.Add(backoff), *status.BackoffUntil)
+				assert.Equal(t, rateLimits, status.RateLimits)
+
+				// Skipped until the backoff passes
+				clock.now = clock.now.Add(backoff - time.Millisecond)
+				_, debug := runBackoffAuction(t, service)
+				decisions, _ := debug.Partner("limited")
+				assert.Equal(t, models.ReasonPartnerBackoff, decisions.SkipReason)
+				assert.Equal(t, int32(i+1), calls.Load())
+				clock.now = clock.now.Add(time.Millisecond)
+			}
+			if tc.expectedSource != "" {
+				assert.Equal(t, float64(rateLimits), gatheredMetric(t, "rtb_partner_rate_limits_total", labels)-before)
+			}
+
+			// The probe after the backoff bids and clears it
+			response, _ := runBackoffAuction(t, service)
+			require.NotNil(t, response)
+			require.Len(t, response.Bids, 1)
+			status := backoffStatus(t, service)
+			assert.Nil(t, status.BackoffUntil)
+			assert.Zero(t, status.RateLimits)
+			assert.Equal(t, tc.expectedFailures, status.Failures)
+		})
+	}
+}
+
+// TestRateLimitRetry tests that a configured 429 retry waits at least the Retry-After hint, and
+// gives up within the auction when the hint exceeds the remaining budget
+func TestRateLimitRetry(t *testing.T) {
+	testCases := []struct {
+		name          string
+		retryAfter    string
+		expectBid     bool
+		expectedCalls int32
+	}{
+		{name: "Short Hint Retried", expectBid: true, expectedCalls: 2},
+		{name: "Long Hint Not Retried", retryAfter: "2", expectedCalls: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, calls := newRateLimitingPartner(t, []rateLimitReply{{status: http.StatusTooManyRequests, retryAfter: tc.retryAfter}})
+			response, err := runRetryTestAuction(t, "limited", server.URL, 200*time.Millisecond,
+				&config.RetryPolicy{MaxAttempts: 3, RetryOn: []string{config.RetryOn429}, BackoffBase: time.Millisecond})
+
+			if tc.expectBid {
+				require.NoError(t, err)
+				assert.Equal(t, "bid-backoff", response.Bids[0].ID)
+			} else {
+				assert.Error(t, err)
+			}
+			assert.Equal(t, tc.expectedCalls, calls.Load())
+		})
+	}
+}
+
+// TestRateLimitBackoffValidation tests the bounds on the rate limit backoff
+func TestRateLimitBackoffValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		backoff     *config.RateLimitBackoffConfig
+		expectedErr string
+	}{
+		{name: "Defaults"},
+		{name: "Custom", backoff: &config.RateLimitBackoffConfig{Base: 500 * time.Millisecond, Max: time.Minute}},
+		{name: "Negative Base", backoff: &config.RateLimitBackoffConfig{Base: -time.Second}, expectedErr: "rate limit backoff must be between 0 and 1h0m0s"},
+		{name: "Max Too Long", backoff: &config.RateLimitBackoffConfig{Max: 2 * time.Hour}, expectedErr: "rate limit backoff must be between 0 and 1h0m0s"},
+		{name: "Base Above Max", backoff: &config.RateLimitBackoffConfig{Base: time.Minute, Max: time.Second}, expectedErr: "rate limit backoff base 1m0s exceeds max 1s"},
+		{name: "Base Above Default Max", backoff: &config.RateLimitBackoffConfig{Base: 10 * time.Minute}, expectedErr: "rate limit backoff base 10m0s exceeds max 5m0s"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.RateLimitBackoff = tc.backoff
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,318 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newBudgetTestService creates a service where the budgeted partner bids 10 with a daily budget of
+// 35, enough for three wins, and an unbudgeted partner bids 6
+func newBudgetTestService(t *testing.T, withRedis bool) *services.AuctionService {
+	cfg := newBudgetTestConfig(t)
+	if withRedis {
+		useTestRedis(t, cfg, miniredis.RunT(t))
+	}
+	return newTestService(t, cfg)
+}
+
+// newBudgetTestConfig returns the config of newBudgetTestService without Redis
+func newBudgetTestConfig(t *testing.T) *config.Config {
+	budgeted := newPartnerServer(t, models.Bid{ID: "budgeted-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/budgeted"})
+	open := newPartnerServer(t, models.Bid{ID: "open-bid", Price: 6.0, QualityScore: 0.5, ClickURL: "http://example.com/open"})
+	return newTestConfig(map[string]*config.PartnerConfig{
+		"budgeted": {ID: "budgeted", Endpoint: budgeted.URL, APIKey: "key-budgeted", Timeout: 500 * time.Millisecond, Enabled: true, MaxBid: 10.0, DailyBudget: 35.0},
+		"open":     {ID: "open", Endpoint: open.URL, APIKey: "key-open", Timeout: 500 * time.Millisecond, Enabled: true},
+	}, withBidTimeout(time.Second), withMaxBids(2))
+}
+
+// runBudgetTestAuction runs one auction and returns whether the budgeted partner won it
+func runBudgetTestAuction(ctx context.Context, service *services.AuctionService, requestID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: requestID, LeadID: "lead-1", Vertical: "auto"})
+	if err != nil {
+		return false, err
+	}
+	for _, bid := range response.Bids {
+		if bid.PartnerID == "budgeted" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// TestPartnerBudgetConcurrency tests that concurrent auctions never charge a partner past its daily
+// budget, and that every win is charged
+func TestPartnerBudgetConcurrency(t *testing.T) {
+	testCases := []struct {
+		name      string
+		withRedis bool
+	}{
+		{name: "Memory", withRedis: false},
+		{name: "Redis", withRedis: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newBudgetTestService(t, tc.withRedis)
+
+			var wins sync.WaitGroup
+			var mutex sync.Mutex
+			won := 0
+			for i := 0; i < 40; i++ {
+				wins.Add(1)
+				go func(i int) {
+					defer wins.Done()
+					budgetedWon, err := runBudgetTestAuction(context.Background(), service, fmt.Sprintf("budget-%d", i))
+					assert.NoError(t, err)
+					if budgetedWon {
+						mutex.Lock()
+						won++
+						mutex.Unlock()
+					}
+				}(i)
+			}
+			wins.Wait()
+
+			status, err := service.PartnerBudget(context.Background(), "budgeted")
+			require.NoError(t, err)
+			assert.Equal(t, 3, won)
+			assert.Equal(t, 30.0, status.Spent)
+			assert.Zero(t, status.Held)
+			assert.Equal(t, 35.0, status.Budget)
+
+			// The remaining 5 cannot cover another bid of up to 10
+			debug := models.NewDebugInfo()
+			budgetedWon, err := runBudgetTestAuction(models.ContextWithDebug(context.Background(), debug), service, "budget-spent")
+			require.NoError(t, err)
+			assert.False(t, budgetedWon)
+			partner, _ := debug.Partner("budgeted")
+			assert.Equal(t, models.ReasonBudgetSpent, partner.SkipReason)
+		})
+	}
+}
+
+// TestPartnerBudgetDryRun tests that dry runs record the charge they would have made without
+// spending the budget
+func TestPartnerBudgetDryRun(t *testing.T) {
+	service := newBudgetTestService(t, false)
+
+	dryRun := models.NewDryRun()
+	budgetedWon, err := runBudgetTestAuction(models.ContextWithDryRun(context.Background(), dryRun), service, "budget-dry-run")
+	require.NoError(t, err)
+	assert.True(t, budgetedWon)
+
+	var charges []models.SideEffect
+	for _, effect := range dryRun.Effects() {
+		if effect.Type == models.SideEffectBudgetSpend {
+			charges = append(charges, effect)
+		}
+	}
+	require.Len(t, charges, 1)
+	assert.Equal(t, "budgeted", charges[0].PartnerID)
+
+	status, err := service.PartnerBudget(context.Background(), "budgeted")
+	require.NoError(t, err)
+	assert.Zero(t, status.Spent)
+	assert.Zero(t, status.Held)
+
+	_, err = service.PartnerBudget(context.Background(), "open")
+	assert.ErrorIs(t, err, services.ErrNoBudget)
+}
+
+// TestPartnerBudgetCommitDropsEveryWinner tests that an auction fails with no valid bids when
+// every winner's budget hold could not be committed
+func TestPartnerBudgetCommitDropsEveryWinner(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	// Redis goes down while the partner is bidding, after its hold was taken
+	budgeted := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		redisServer.Close()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "budgeted-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/budgeted"})
+	}))
+	t.Cleanup(budgeted.Close)
+
+	cfg := newBudgetTestConfig(t)
+	delete(cfg.Partners, "open")
+	cfg.Partners["budgeted"].Endpoint = budgeted.URL
+	useTestRedis(t, cfg, redisServer)
+	service := newTestService(t, cfg)
+
+	debug := models.NewDebugInfo()
+	_, err := runBudgetTestAuction(models.ContextWithDebug(context.Background(), debug), service, "budget-commit-failed")
+	assert.ErrorIs(t, err, services.ErrNoValidBids)
+	partner, _ := debug.Partner("budgeted")
+	assert.Empty(t, partner.SkipReason, "the hold was taken before the partner was called")
+}
+
+// budgetAlertCount returns how often the budgeted partner crossed each alert threshold
+func budgetAlertCount(t *testing.T, thresholds ...string) map[string]float64 {
+	counts := make(map[string]float64, len(thresholds))
+	for _, threshold := range thresholds {
+		counts[threshold] = gatheredMetric(t, "rtb_partner_budget_alerts_total", map[string]string{"partner": "budgeted", "threshold": threshold})
+	}
+	return counts
+}
+
+// TestPartnerBudgetAlerts tests that each alert threshold fires once per day, including thresholds
+// crossed together by one charge
+func TestPartnerBudgetAlerts(t *testing.T) {
+	testCases := []struct {
+		name      string
+		withRedis bool
+	}{
+		{name: "Memory", withRedis: false},
+		{name: "Redis", withRedis: true},
+	}
+
+	thresholds := []string{"10", "20", "50", "80"}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newBudgetTestConfig(t)
+			cfg.Partners["budgeted"].BudgetAlertThresholds = []float64{10, 20, 50, 80}
+			if tc.withRedis {
+				useTestRedis(t, cfg, miniredis.RunT(t))
+			}
+			service := newTestService(t, cfg)
+			before := budgetAlertCount(t, thresholds...)
+
+			// Each win charges 10 of 35: the first crosses 10% and 20%, the second 50%, the third 80%
+			expected := []map[string]float64{
+				{"10": 1, "20": 1, "50": 0, "80": 0},
+				{"10": 1, "20": 1, "50": 1, "80": 0},
+				{"10": 1, "20": 1, "50": 1, "80": 1},
+				{"10": 1, "20": 1, "50": 1, "80": 1},
+			}
+			for i, counts := range expected {
+				_, err := runBudgetTestAuction(context.Background(), service, fmt.Sprintf("budget-alert-%d", i))
+				require.NoError(t, err)
+				after := budgetAlertCount(t, thresholds...)
+				for _, threshold := range thresholds {
+					assert.Equal(t, counts[threshold], after[threshold]-before[threshold], "auction %d, threshold %s", i, threshold)
+				}
+			}
+
+			status, err := service.PartnerBudget(context.Background(), "budgeted")
+			require.NoError(t, err)
+			assert.Equal(t, []float64{10, 20, 50, 80}, status.Alerts)
+		})
+	}
+}
+
+// TestPartnerBudgetAlertsRestart tests that a new instance sharing the Redis budget does not
+// alert thresholds already crossed that day
+func TestPartnerBudgetAlertsRestart(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	newService := func() *services.AuctionService {
+		cfg := newBudgetTestConfig(t)
+		cfg.Partners["budgeted"].BudgetAlertThresholds = []float64{20, 50}
+		useTestRedis(t, cfg, redisServer)
+		return newTestService(t, cfg)
+	}
+	before := budgetAlertCount(t, "20", "50")
+
+	first := newService()
+	won, err := runBudgetTestAuction(context.Background(), first, "budget-restart-1")
+	require.NoError(t, err)
+	require.True(t, won)
+
+	second := newService()
+	won, err = runBudgetTestAuction(context.Background(), second, "budget-restart-2")
+	require.NoError(t, err)
+	require.True(t, won)
+
+	after := budgetAlertCount(t, "20", "50")
+	assert.Equal(t, 1.0, after["20"]-before["20"])
+	assert.Equal(t, 1.0, after["50"]-before["50"])
+}
+
+// TestPartnerBudgetAlertWebhook tests that crossed thresholds are sent as webhooks when enabled,
+// and that dry runs alert nothing
+func TestPartnerBudgetAlertWebhook(t *testing.T) {
+	receiver, webhookServer := newWebhookReceiver(t, http.StatusOK)
+	cfg := newBudgetTestConfig(t)
+	cfg.Partners["budgeted"].BudgetAlertThresholds = []float64{20}
+	cfg.Budgets = &config.BudgetConfig{AlertWebhook: true}
+	cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: webhookServer.URL, Events: []string{config.WebhookEventPartnerBudgetAlert}})
+	service := newTestService(t, cfg)
+
+	dryRun := models.NewDryRun()
+	_, err := runBudgetTestAuction(models.ContextWithDryRun(context.Background(), dryRun), service, "budget-alert-dry-run")
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		_, err := runBudgetTestAuction(context.Background(), service, fmt.Sprintf("budget-alert-webhook-%d", i))
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool { return len(receiver.received()) == 1 }, time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	deliveries := receiver.received()
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, config.WebhookEventPartnerBudgetAlert, deliveries[0].event.Type)
+	data, ok := deliveries[0].event.Data.(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "budgeted", data["partner_id"])
+	assert.Equal(t, 20.0, data["threshold"])
+	assert.Equal(t, 35.0, data["budget"])
+	assert.Equal(t, 10.0, data["spent"])
+}
+
+// TestPartnerBudgetValidation tests daily budget and hold TTL bounds
+func TestPartnerBudgetValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		budget      float64
+		maxBid      float64
+		holdTTL     time.Duration
+		alerts      []float64
+		expectedErr string
+	}{
+		{name: "Unbudgeted", budget: 0},
+		{name: "Covers Max Price", budget: 100.0},
+		{name: "Covers Partner Max Bid", budget: 20.0, maxBid: 20.0},
+		{name: "Below Max Price", budget: 50.0, expectedErr: "daily budget for partner partner-1"},
+		{name: "Negative", budget: -1.0, expectedErr: "daily budget for partner partner-1"},
+		{name: "Hold TTL", budget: 100.0, holdTTL: 5 * time.Minute},
+		{name: "Hold TTL Below Timeout", budget: 100.0, holdTTL: 100 * time.Millisecond, expectedErr: "budget hold TTL"},
+		{name: "Hold TTL Too Long", budget: 100.0, holdTTL: 2 * time.Hour, expectedErr: "budget hold TTL"},
+		{name: "Alert Thresholds", budget: 100.0, alerts: []float64{50, 80, 100}},
+		{name: "Alert Thresholds Unbudgeted", alerts: []float64{50}, expectedErr: "require a daily budget"},
+		{name: "Alert Threshold Zero", budget: 100.0, alerts: []float64{0}, expectedErr: "budget alert thresholds for partner partner-1"},
+		{name: "Alert Threshold Above 100", budget: 100.0, alerts: []float64{120}, expectedErr: "budget alert thresholds for partner partner-1"},
+		{name: "Alert Threshold Duplicate", budget: 100.0, alerts: []float64{50, 50}, expectedErr: "duplicate budget alert threshold"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].DailyBudget = tc.budget
+			cfg.Partners["partner-1"].MaxBid = tc.maxBid
+			cfg.Partners["partner-1"].BudgetAlertThresholds = tc.alerts
+			if tc.holdTTL != 0 {
+				cfg.Budgets = &config.BudgetConfig{HoldTTL: tc.holdTTL}
+			}
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
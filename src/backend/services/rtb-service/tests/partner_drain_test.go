@@ -0,0 +1,171 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newDrainTestService returns a service where partner-1 bids 10 and partner-2 bids 6, with
+// reservations held for an hour against clock
+func newDrainTestService(t *testing.T, clock *steppingClock, drainDeadline time.Time) (*services.AuctionService, *config.Config) {
+	partner1 := newPartnerServer(t, models.Bid{ID: "bid-1", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	partner2 := newPartnerServer(t, models.Bid{ID: "bid-2", Price: 6.0, QualityScore: 0.5, ClickURL: "http://example.com/2"})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner1.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true, DrainDeadline: drainDeadline},
+		"partner-2": {ID: "partner-2", Endpoint: partner2.URL, APIKey: "key-2", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2))
+	cfg.Reservations = &config.ReservationsConfig{Enabled: true, TTL: time.Hour, SweepInterval: time.Hour, MaxEntries: 100}
+	service := newTestServiceWithClock(t, cfg, clock)
+	return service, cfg
+}
+
+// drainTestWinners returns the partners of the bids in response
+func drainTestWinners(response *models.BidResponse) []string {
+	partners := make([]string, 0, len(response.Bids))
+	for _, bid := range response.Bids {
+		partners = append(partners, bid.PartnerID)
+	}
+	return partners
+}
+
+// TestDrainingPartnerSkipped tests that draining partners, from config or the admin API, get no
+// auctions while other partners keep bidding
+func TestDrainingPartnerSkipped(t *testing.T) {
+	start := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name          string
+		drainDeadline time.Time
+		adminDrain    bool
+		expected      []string
+	}{
+		{name: "Not Draining", expected: []string{"partner-1", "partner-2"}},
+		{name: "Config Drain", drainDeadline: start.Add(time.Hour), expected: []string{"partner-2"}},
+		{name: "Config Drain Past Deadline", drainDeadline: start.Add(-time.Hour), expected: []string{"partner-2"}},
+		{name: "Admin Drain", adminDrain: true, expected: []string{"partner-2"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, _ := newDrainTestService(t, &steppingClock{now: start}, tc.drainDeadline)
+			if tc.adminDrain {
+				require.NoError(t, service.DrainPartner("partner-1", start.Add(time.Hour), "ops"))
+			}
+
+			debug := models.NewDebugInfo()
+			ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), 500*time.Millisecond)
+			defer cancel()
+			response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "drain-1", LeadID: "lead-1", Vertical: "auto"})
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expected, drainTestWinners(response))
+			assert.ElementsMatch(t, tc.expected, service.AvailablePartners())
+			if len(tc.expected) == 1 {
+				skipped, _ := debug.Partner("partner-1")
+				assert.Equal(t, models.ReasonDraining, skipped.SkipReason)
+			}
+		})
+	}
+}
+
+// TestDrainingPartnerSettlement tests that reserved bids of a draining partner settle until its
+// drain deadline and are dropped after it
+func TestDrainingPartnerSettlement(t *testing.T) {
+	start := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name            string
+		confirmAfter    time.Duration
+		expected        []string
+		expectedDropped float64
+	}{
+		{name: "Before Deadline", confirmAfter: 10 * time.Minute, expected: []string{"partner-1", "partner-2"}},
+		{name: "After Deadline", confirmAfter: 40 * time.Minute, expected: []string{"partner-2"}, expectedDropped: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := &steppingClock{now: start}
+			service, _ := newDrainTestService(t, clock, time.Time{})
+			reservation := reserveTestAuction(t, service, "drain-settle")
+			require.Len(t, reservation.Bids, 2)
+			require.NoError(t, service.DrainPartner("partner-1", start.Add(30*time.Minute), "ops"))
+			before := gatheredMetric(t, "rtb_drained_partner_bids_dropped_total", map[string]string{"partner": "partner-1"})
+
+			clock.now = start.Add(tc.confirmAfter)
+			response, err := service.ConfirmReservation(context.Background(), reservation.Token)
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.expected, drainTestWinners(response))
+			assert.Equal(t, before+tc.expectedDropped, gatheredMetric(t, "rtb_drained_partner_bids_dropped_total", map[string]string{"partner": "partner-1"}))
+		})
+	}
+}
+
+// TestPartnerDrainReload tests that config reloads replace config drains and keep admin drains
+func TestPartnerDrainReload(t *testing.T) {
+	start := time.Date(2024, 5, 6, 12, 0, 0, 0, time.UTC)
+	service, cfg := newDrainTestService(t, &steppingClock{now: start}, start.Add(time.Hour))
+	require.NoError(t, service.DrainPartner("partner-2", start.Add(2*time.Hour), "ops"))
+
+	reloaded := map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: cfg.Partners["partner-1"].Endpoint, Enabled: true},
+		"partner-2": {ID: "partner-2", Endpoint: cfg.Partners["partner-2"].Endpoint, Enabled: true},
+	}
+	service.SetPartnerDrains(reloaded)
+
+	_, draining := service.PartnerDrainDeadline("partner-1")
+	assert.False(t, draining)
+	deadline, draining := service.PartnerDrainDeadline("partner-2")
+	assert.True(t, draining)
+	assert.Equal(t, start.Add(2*time.Hour), deadline)
+}
+
+// TestDrainPartnerEndpoint tests starting a drain through the admin API
+func TestDrainPartnerEndpoint(t *testing.T) {
+	headers := map[string]string{"X-Admin-Key": testAdminKey}
+	testCases := []struct {
+		name           string
+		partnerID      string
+		body           string
+		expectedStatus int
+		expectedError  string
+	}{
+		{name: "Duration", partnerID: "partner-1", body: `{"duration": "72h", "requested_by": "ops"}`, expectedStatus: http.StatusCreated},
+		{name: "Deadline", partnerID: "partner-1", body: `{"deadline": "2099-01-01T00:00:00Z", "requested_by": "ops"}`, expectedStatus: http.StatusCreated},
+		{name: "Unknown Partner", partnerID: "partner-9", body: `{"duration": "72h", "requested_by": "ops"}`, expectedStatus: http.StatusNotFound,
+			expectedError: "Unknown partner"},
+		{name: "Past Deadline", partnerID: "partner-1", body: `{"deadline": "2000-01-01T00:00:00Z", "requested_by": "ops"}`,
+			expectedStatus: http.StatusBadRequest, expectedError: "drain deadline must be in the future"},
+		{name: "Both Deadline And Duration", partnerID: "partner-1", body: `{"deadline": "2099-01-01T00:00:00Z", "duration": "1h", "requested_by": "ops"}`,
+			expectedStatus: http.StatusBadRequest, expectedError: "Set either deadline or duration"},
+		{name: "No Requester", partnerID: "partner-1", body: `{"duration": "72h"}`, expectedStatus: http.StatusBadRequest,
+			expectedError: "drain requester is required"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, service := newOverrideTestRouter(t, &steppingClock{now: time.Now()})
+
+			w := serveOverrideTest(router, http.MethodPost, "/admin/partners/"+tc.partnerID+"/drain", tc.body, headers)
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			if tc.expectedError != "" {
+				assert.Equal(t, tc.expectedError, body["error"])
+				_, draining := service.PartnerDrainDeadline("partner-1")
+				assert.False(t, draining)
+				return
+			}
+			assert.Equal(t, tc.partnerID, body["partner_id"])
+			assert.NotEmpty(t, body["drain_deadline"])
+			assert.Equal(t, []string{"bid-2"}, overrideTestWinners(t, router, nil))
+		})
+	}
+}
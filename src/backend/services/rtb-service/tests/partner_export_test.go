@@ -0,0 +1,154 @@
+package tests
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// TestPartnerReportQualityAndLatency tests the bid rate, average latency, and per-vertical quality
+// scores of a partner report
+func TestPartnerReportQualityAndLatency(t *testing.T) {
+	reporter, _ := newReportTestReporter(nil)
+	reporter.RecordCall("partner-1", services.PartnerCall{Latency: 20 * time.Millisecond, Bids: 2, Vertical: "auto", QualityScores: []float64{0.4, 0.6}})
+	reporter.RecordCall("partner-1", services.PartnerCall{Latency: 40 * time.Millisecond, Bids: 1, Vertical: "home", QualityScores: []float64{0.9}})
+	reporter.RecordCall("partner-1", services.PartnerCall{Latency: 60 * time.Millisecond, NoBid: true, Vertical: "auto"})
+	reporter.RecordCall("partner-1", services.PartnerCall{Latency: 80 * time.Millisecond, Bids: 1, InvalidBids: 1, Vertical: "auto"})
+
+	report, err := reporter.Report("partner-1", time.Hour)
+	require.NoError(t, err)
+	assert.InDelta(t, 0.5, report.BidRate, 1e-9)
+	assert.Equal(t, 50.0, report.AvgLatencyMs)
+	assert.Equal(t, map[string]float64{"auto": 0.5, "home": 0.9}, report.QualityScores)
+	assert.Nil(t, report.BudgetUtilization)
+
+	verticals, err := reporter.Verticals(time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"auto", "home"}, verticals)
+	_, err = reporter.Verticals(0)
+	assert.ErrorIs(t, err, services.ErrInvalidReportWindow)
+}
+
+// TestPartnerExport tests that the export is admin only, streams a row per partner matching the
+// partner report in CSV and xlsx, and rejects bad windows and formats
+func TestPartnerExport(t *testing.T) {
+	cfg := newBudgetTestConfig(t)
+	cfg.Admin = &config.AdminConfig{Enabled: true, APIKeys: []string{testAdminKey}}
+	service := newTestService(t, cfg)
+	won, err := runBudgetTestAuction(context.Background(), service, "export-1")
+	require.NoError(t, err)
+	require.True(t, won)
+
+	gin.SetMode(gin.TestMode)
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	router := gin.New()
+	router.GET("/v1/partners/export", handler.HandlePartnerExport)
+	admin := map[string]string{"X-Admin-Key": testAdminKey}
+
+	expectedHeader := []string{
+		"partner_id", "window", "requests", "bid_rate", "win_rate", "avg_latency_ms", "p95_latency_ms",
+		"avg_clearing_price", "timeout_rate", "budget_utilization", "quality_score_auto",
+	}
+	var expectedRows [][]string
+	for _, partnerID := range []string{"budgeted", "open"} {
+		report, err := service.PartnerReport(partnerID, 7*24*time.Hour)
+		require.NoError(t, err)
+		utilization := ""
+		if report.BudgetUtilization != nil {
+			utilization = formatExportFloat(*report.BudgetUtilization)
+		}
+		expectedRows = append(expectedRows, []string{
+			partnerID, "168h0m0s", strconv.FormatUint(report.Calls, 10), formatExportFloat(report.BidRate),
+			formatExportFloat(report.WinRate), formatExportFloat(report.AvgLatencyMs), formatExportFloat(report.P95LatencyMs),
+			formatExportFloat(report.AvgClearingPrice), formatExportFloat(report.TimeoutRate), utilization,
+			formatExportFloat(report.QualityScores["auto"]),
+		})
+	}
+	assert.NotEmpty(t, expectedRows[0][9], "the budgeted partner reports its utilization")
+	assert.Empty(t, expectedRows[1][9], "a partner without a budget leaves utilization empty")
+	assert.Equal(t, "0.5", expectedRows[0][10])
+
+	t.Run("CSV", func(t *testing.T) {
+		w := serveOverrideTest(router, http.MethodGet, "/v1/partners/export?window=7d", "", admin)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Regexp(t, `^attachment; filename="partners-7d-\d{8}\.csv"$`, w.Header().Get("Content-Disposition"))
+
+		records, err := csv.NewReader(w.Body).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 3)
+		assert.Equal(t, expectedHeader, records[0])
+		assert.Equal(t, expectedRows, records[1:])
+	})
+
+	t.Run("XLSX", func(t *testing.T) {
+		w := serveOverrideTest(router, http.MethodGet, "/v1/partners/export?format=xlsx", "", admin)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", w.Header().Get("Content-Type"))
+		assert.Regexp(t, `^attachment; filename="partners-7d-\d{8}\.xlsx"$`, w.Header().Get("Content-Disposition"))
+
+		archive, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+		require.NoError(t, err)
+		parts := make(map[string]string)
+		for _, file := range archive.File {
+			reader, err := file.Open()
+			require.NoError(t, err)
+			content, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			parts[file.Name] = string(content)
+		}
+		for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels"} {
+			assert.Contains(t, parts, name)
+		}
+		sheet := parts["xl/worksheets/sheet1.xml"]
+		assert.Equal(t, 3, strings.Count(sheet, "<row "))
+		assert.Contains(t, sheet, `<c r="A1" t="inlineStr"><is><t>partner_id</t></is></c>`)
+		assert.Contains(t, sheet, `<c r="K1" t="inlineStr"><is><t>quality_score_auto</t></is></c>`)
+		assert.Contains(t, sheet, `<c r="A2" t="inlineStr"><is><t>budgeted</t></is></c>`)
+		assert.Contains(t, sheet, `<c r="J2"><v>`+expectedRows[0][9]+`</v></c>`)
+		assert.NotContains(t, sheet, `r="J3"`, "empty cells are left out")
+		assert.True(t, strings.HasSuffix(sheet, "</sheetData></worksheet>"))
+	})
+
+	testCases := []struct {
+		name           string
+		path           string
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{name: "Missing Admin Key", path: "/v1/partners/export", expectedStatus: http.StatusUnauthorized},
+		{name: "Wrong Admin Key", path: "/v1/partners/export", headers: map[string]string{"X-Admin-Key": "wrong"}, expectedStatus: http.StatusUnauthorized},
+		{name: "Unknown Format", path: "/v1/partners/export?format=pdf", headers: admin, expectedStatus: http.StatusBadRequest},
+		{name: "Window Beyond Retention", path: "/v1/partners/export?window=32d", headers: admin, expectedStatus: http.StatusBadRequest},
+		{name: "Malformed Window", path: "/v1/partners/export?window=xd", headers: admin, expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := serveOverrideTest(router, http.MethodGet, tc.path, "", tc.headers)
+			assert.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			assert.Empty(t, w.Header().Get("Content-Disposition"))
+		})
+	}
+}
+
+// formatExportFloat formats a float as the export writes it
+func formatExportFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newFairSelectionTestService creates a fair-mode service capped at max partners per auction whose
+// partners, at the given traffic percentages, never bid
+func newFairSelectionTestService(t *testing.T, max int, percentages map[string]float64) (*services.AuctionService, *config.Config) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	partners := make(map[string]*config.PartnerConfig, len(percentages))
+	for partnerID, percentage := range percentages {
+		partners[partnerID] = &config.PartnerConfig{ID: partnerID, Endpoint: server.URL, APIKey: "key", Timeout: 200 * time.Millisecond, Enabled: true, TrafficPercentage: percentage}
+	}
+	cfg := newTestConfig(partners, withMaxBids(5))
+	cfg.MaxPartnersPerAuction = max
+	cfg.PartnerSelectionMode = config.PartnerSelectionFair
+	service := newTestService(t, cfg)
+	return service, cfg
+}
+
+// runFairSelectionAuction runs an auction for a lead and returns the partners selection chose
+func runFairSelectionAuction(t *testing.T, service *services.AuctionService, partnerIDs []string, request *models.BidRequest) []string {
+	debug := models.NewDebugInfo()
+	ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), 500*time.Millisecond)
+	defer cancel()
+	_, err := service.RunAuction(ctx, request)
+	require.ErrorIs(t, err, services.ErrNoValidBids)
+
+	var chosen []string
+	for _, partnerID := range partnerIDs {
+		decision, _ := debug.Partner(partnerID)
+		require.NotNil(t, decision.Selection, partnerID)
+		if decision.Selection.Reason != "not_selected" {
+			chosen = append(chosen, partnerID)
+		}
+	}
+	return chosen
+}
+
+// TestFairPartnerSelection tests that fair selection picks the same partners for the same lead and
+// offers partners traffic in proportion to their traffic percentage
+func TestFairPartnerSelection(t *testing.T) {
+	testCases := []struct {
+		name                string
+		percentages         map[string]float64
+		expectedOfferShares map[string][2]float64
+	}{
+		{
+			name:                "Equal Weights",
+			percentages:         map[string]float64{"p-a": 0, "p-b": 0, "p-c": 0, "p-d": 0},
+			expectedOfferShares: map[string][2]float64{"p-a": {0.15, 0.35}, "p-b": {0.15, 0.35}, "p-c": {0.15, 0.35}, "p-d": {0.15, 0.35}},
+		},
+		{
+			name:                "Reduced Traffic Percentage",
+			percentages:         map[string]float64{"p-a": 100, "p-b": 100, "p-c": 100, "p-d": 25},
+			expectedOfferShares: map[string][2]float64{"p-a": {0.2, 0.45}, "p-b": {0.2, 0.45}, "p-c": {0.2, 0.45}, "p-d": {0.02, 0.15}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, _ := newFairSelectionTestService(t, 1, tc.percentages)
+			partnerIDs := []string{"p-a", "p-b", "p-c", "p-d"}
+
+			offers := make(map[string]int)
+			const leads = 400
+			for i := 0; i < leads; i++ {
+				request := &models.BidRequest{RequestID: fmt.Sprintf("fair-%d", i), LeadID: fmt.Sprintf("lead-%d", i), Vertical: "auto"}
+				chosen := runFairSelectionAuction(t, service, partnerIDs, request)
+				require.Len(t, chosen, 1)
+				offers[chosen[0]]++
+
+				// The same lead is offered to the same partner, in or out of an auction
+				if i%50 == 0 {
+					assert.Equal(t, chosen, runFairSelectionAuction(t, service, partnerIDs, request))
+					selected, err := service.SelectPartnersFairly(request, partnerIDs)
+					require.NoError(t, err)
+					assert.Equal(t, chosen, selected)
+				}
+			}
+
+			for partnerID, bounds := range tc.expectedOfferShares {
+				share := float64(offers[partnerID]) / leads
+				assert.GreaterOrEqual(t, share, bounds[0], partnerID)
+				assert.LessOrEqual(t, share, bounds[1], partnerID)
+			}
+		})
+	}
+}
+
+// TestPartnerTrafficMix tests that the traffic mix report compares each partner's offered verticals
+// and regions with all capped auctions, and that fair selection offers representative traffic
+func TestPartnerTrafficMix(t *testing.T) {
+	partnerIDs := []string{"p-a", "p-b", "p-c", "p-d", "p-e", "p-f"}
+	percentages := make(map[string]float64, len(partnerIDs))
+	for _, partnerID := range partnerIDs {
+		percentages[partnerID] = 100
+	}
+	service, cfg := newFairSelectionTestService(t, 2, percentages)
+
+	verticals := []string{"auto", "auto", "auto", "home"}
+	regions := []string{"TX", "CA", ""}
+	for i := 0; i < 480; i++ {
+		runFairSelectionAuction(t, service, partnerIDs, &models.BidRequest{
+			RequestID: fmt.Sprintf("mix-%d", i),
+			LeadID:    fmt.Sprintf("lead-%d", i),
+			Vertical:  verticals[i%len(verticals)],
+			Geo:       &models.Geo{Region: regions[i%len(regions)]},
+		})
+	}
+
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	router := gin.New()
+	router.GET("/v1/partner-selection/traffic", handler.HandlePartnerTrafficMix)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/v1/partner-selection/traffic", nil)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report services.TrafficMixReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "fair", report.Mode)
+	assert.Equal(t, uint64(480), report.Overall.Auctions)
+	assert.Equal(t, map[string]float64{"auto": 0.75, "home": 0.25}, report.Overall.Verticals)
+	assert.InDelta(t, 1.0/3, report.Overall.Regions["unknown"], 1e-9)
+
+	offered := uint64(0)
+	for _, partnerID := range partnerIDs {
+		mix, exists := report.Partners[partnerID]
+		require.True(t, exists, partnerID)
+		offered += mix.Auctions
+		assert.InDelta(t, float64(mix.Auctions)/480, mix.OfferRate, 1e-9, partnerID)
+		assert.Less(t, mix.VerticalDistance, 0.15, partnerID)
+		assert.Less(t, mix.RegionDistance, 0.15, partnerID)
+	}
+	assert.Equal(t, uint64(2*480), offered)
+
+	t.Run("Selection Disabled", func(t *testing.T) {
+		service, err := services.NewAuctionService(newStrategyTestConfig())
+		require.NoError(t, err)
+		defer service.Close()
+		_, err = service.PartnerTrafficMix()
+		assert.ErrorIs(t, err, services.ErrPartnerSelectionDisabled)
+	})
+}
+
+// TestPartnerSelectionModeValidation tests the selection mode and partner traffic percentage bounds
+func TestPartnerSelectionModeValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mode        string
+		percentage  float64
+		expectedErr string
+	}{
+		{name: "Default"},
+		{name: "Fair", mode: config.PartnerSelectionFair, percentage: 50},
+		{name: "Performance", mode: config.PartnerSelectionPerformance},
+		{name: "Unknown Mode", mode: "random", expectedErr: "unknown partner selection mode: random"},
+		{name: "Negative Percentage", percentage: -1, expectedErr: "traffic percentage must be between 0 and 100 for partner partner-1: -1"},
+		{name: "Percentage Above 100", percentage: 150, expectedErr: "traffic percentage must be between 0 and 100 for partner partner-1: 150"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.MaxPartnersPerAuction = 1
+			cfg.PartnerSelectionMode = tc.mode
+			cfg.Partners["partner-1"].TrafficPercentage = tc.percentage
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,370 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// hygieneClock is a Clock tests advance while the idle partner check reads it
+type hygieneClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *hygieneClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+// advance moves the clock forward by d
+func (c *hygieneClock) advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// newSwitchablePartner returns a partner that answers with no bid until price is set, then bids it
+func newSwitchablePartner(t *testing.T, id string) (*httptest.Server, *atomic.Int64) {
+	var price atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if price.Load() == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: id + "-bid", Price: float64(price.Load()), QualityScore: 0.5, ClickURL: "http://example.com/" + id})
+	}))
+	t.Cleanup(server.Close)
+	return server, &price
+}
+
+// newHygieneTestConfig returns a config where "bidder" bids 10 and subject is the only other partner
+func newHygieneTestConfig(t *testing.T, subject *config.PartnerConfig) *config.Config {
+	bidder := newPartnerServer(t, models.Bid{ID: "bidder-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/bidder"})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"bidder": {ID: "bidder", Endpoint: bidder.URL, APIKey: "key-bidder", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withAdmin())
+	cfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 5, Cooldown: time.Hour}
+	if subject != nil {
+		cfg.Partners[subject.ID] = subject
+	}
+	return cfg
+}
+
+// runHygieneTestAuction runs one auction and returns its winning partners
+func runHygieneTestAuction(t *testing.T, service *services.AuctionService) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "hygiene-" + strconv.FormatInt(time.Now().UnixNano(), 36), LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	return drainTestWinners(response)
+}
+
+// TestPartnerHygieneReport tests the findings, idle cause, and suggested action of partners that
+// never bid, are circuit-broken, time out, are never called, are disabled, or never win
+func TestPartnerHygieneReport(t *testing.T) {
+	silent, _ := newNegativeCachePartner(t, http.StatusNoContent, "", 0)
+	failing, _ := newNegativeCachePartner(t, http.StatusInternalServerError, "", 0)
+	slow, _ := newNegativeCachePartner(t, http.StatusNoContent, "", 150*time.Millisecond)
+	loser := newPartnerServer(t, models.Bid{ID: "loser-bid", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/loser"})
+	subject := func(endpoint string) *config.PartnerConfig {
+		return &config.PartnerConfig{ID: "subject", Endpoint: endpoint, APIKey: "key-subject", Timeout: 50 * time.Millisecond, Enabled: true}
+	}
+
+	testCases := []struct {
+		name             string
+		subject          *config.PartnerConfig
+		failureThreshold int
+		serviceRegion    string
+		elapsed          time.Duration
+		query            string
+		expectedFindings []string
+		expectedCause    string
+		expectedAction   string
+		expectedLastBid  bool
+	}{
+		{
+			name:             "Never Bid",
+			subject:          subject(silent.URL),
+			elapsed:          31 * 24 * time.Hour,
+			expectedFindings: []string{services.HygieneNoBids},
+			expectedCause:    services.IdleNeverBid,
+			expectedAction:   services.HygieneActionShadow,
+		},
+		{
+			name:             "Circuit Broken",
+			subject:          subject(failing.URL),
+			failureThreshold: 1,
+			elapsed:          31 * 24 * time.Hour,
+			expectedFindings: []string{services.HygieneNoBids},
+			expectedCause:    services.IdleCircuitOpen,
+			expectedAction:   services.HygieneActionCheckEndpoint,
+		},
+		{
+			name:             "Timing Out",
+			subject:          subject(slow.URL),
+			elapsed:          31 * 24 * time.Hour,
+			expectedFindings: []string{services.HygieneNoBids, services.HygieneAllTimeouts},
+			expectedCause:    services.IdleTimingOut,
+			expectedAction:   services.HygieneActionCheckEndpoint,
+		},
+		{
+			name: "Never Called",
+			subject: func() *config.PartnerConfig {
+				partner := subject(silent.URL)
+				partner.PreferredRegions = []string{"eu-west"}
+				return partner
+			}(),
+			serviceRegion:    "us-east",
+			elapsed:          31 * 24 * time.Hour,
+			expectedFindings: []string{services.HygieneNoBids, services.HygieneNoTraffic},
+			expectedCause:    services.IdleNotCalled,
+			expectedAction:   services.HygieneActionReviewTargets,
+		},
+		{
+			name: "Disabled",
+			subject: func() *config.PartnerConfig {
+				partner := subject(silent.URL)
+				partner.Enabled = false
+				return partner
+			}(),
+			elapsed:          31 * 24 * time.Hour,
+			expectedFindings: []string{services.HygieneNoBids, services.HygieneNoTraffic},
+			expectedCause:    services.IdleDisabled,
+			expectedAction:   services.HygieneActionRemove,
+		},
+		{
+			name:             "Never Wins",
+			subject:          subject(loser.URL),
+			elapsed:          61 * 24 * time.Hour,
+			expectedFindings: []string{services.HygieneNoWins},
+			expectedAction:   services.HygieneActionReviewPricing,
+			expectedLastBid:  true,
+		},
+		{
+			name:    "Tracked Too Briefly",
+			subject: subject(silent.URL),
+			elapsed: 10 * 24 * time.Hour,
+		},
+		{
+			name:             "Idle Days From Query",
+			subject:          subject(silent.URL),
+			elapsed:          10 * 24 * time.Hour,
+			query:            "?bid_days=7",
+			expectedFindings: []string{services.HygieneNoBids},
+			expectedCause:    services.IdleNeverBid,
+			expectedAction:   services.HygieneActionShadow,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newHygieneTestConfig(t, tc.subject)
+			cfg.ServiceRegion = tc.serviceRegion
+			if tc.failureThreshold > 0 {
+				cfg.CircuitBreaker.FailureThreshold = tc.failureThreshold
+			}
+			clock := &hygieneClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+			service, err := services.NewAuctionServiceWithClock(cfg, clock)
+			require.NoError(t, err)
+			defer service.Close()
+
+			clock.advance(tc.elapsed)
+			assert.Equal(t, []string{"bidder"}, runHygieneTestAuction(t, service))
+
+			gin.SetMode(gin.TestMode)
+			adminHandler, err := handlers.NewAdminHandler(service, cfg, handlers.BuildInfo{})
+			require.NoError(t, err)
+			router := gin.New()
+			adminHandler.RegisterRoutes(router.Group("/admin"))
+			w := serveOverrideTest(router, http.MethodGet, "/admin/partners/hygiene"+tc.query, "", map[string]string{"X-Admin-Key": testAdminKey})
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var report services.HygieneReport
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, "168h0m0s", report.Window)
+			if tc.expectedFindings == nil {
+				assert.Empty(t, report.Partners)
+				return
+			}
+			require.Len(t, report.Partners, 1, "the bidding and winning partner is not listed")
+			entry := report.Partners[0]
+			assert.Equal(t, "subject", entry.PartnerID)
+			assert.Equal(t, tc.expectedFindings, entry.Findings)
+			assert.Equal(t, tc.expectedCause, entry.IdleCause)
+			assert.Equal(t, tc.expectedAction, entry.SuggestedAction)
+			assert.Equal(t, 100.0, entry.TrafficPercentage)
+			if tc.expectedLastBid {
+				assert.NotNil(t, entry.LastBidAt)
+			} else {
+				assert.Nil(t, entry.LastBidAt)
+			}
+			assert.Nil(t, entry.LastWinAt)
+			assert.Nil(t, entry.ShadowedAt)
+		})
+	}
+
+	t.Run("Invalid Idle Days", func(t *testing.T) {
+		router, _ := newOverrideTestRouter(t, &steppingClock{now: time.Now()})
+		for _, query := range []string{"?bid_days=0", "?win_days=x"} {
+			w := serveOverrideTest(router, http.MethodGet, "/admin/partners/hygiene"+query, "", map[string]string{"X-Admin-Key": testAdminKey})
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+}
+
+// TestPartnerAutoShadow tests that a partner called without bidding for the shadow days is moved
+// into shadow mode with a webhook, while a circuit-broken one is not; that a shadowed partner's
+// bids never win; and that a restored partner gets a fresh idle stretch and competes again
+func TestPartnerAutoShadow(t *testing.T) {
+	receiver, server := newWebhookReceiver(t, http.StatusOK)
+	switchable, price := newSwitchablePartner(t, "silent")
+	failing, _ := newNegativeCachePartner(t, http.StatusInternalServerError, "", 0)
+	cfg := newHygieneTestConfig(t, &config.PartnerConfig{ID: "silent", Endpoint: switchable.URL, APIKey: "key-silent", Timeout: 200 * time.Millisecond, Enabled: true})
+	cfg.Partners["failing"] = &config.PartnerConfig{ID: "failing", Endpoint: failing.URL, APIKey: "key-failing", Timeout: 200 * time.Millisecond, Enabled: true}
+	cfg.CircuitBreaker.FailureThreshold = 1
+	cfg.Hygiene = &config.HygieneConfig{AutoShadow: true, ShadowAfterDays: 30, CheckInterval: 5 * time.Millisecond}
+	cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: server.URL, Events: []string{config.WebhookEventPartnerShadowed}})
+	require.NoError(t, cfg.Validate())
+
+	clock := &hygieneClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+	service, err := services.NewAuctionServiceWithClock(cfg, clock)
+	require.NoError(t, err)
+	defer service.Close()
+
+	// Ten days in, neither partner has been idle long enough
+	clock.advance(10 * 24 * time.Hour)
+	runHygieneTestAuction(t, service)
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, service.PartnerShadowed("silent"))
+
+	clock.advance(21 * 24 * time.Hour)
+	runHygieneTestAuction(t, service)
+	require.Eventually(t, func() bool { return service.PartnerShadowed("silent") }, time.Second, 5*time.Millisecond)
+	assert.False(t, service.PartnerShadowed("failing"), "a circuit-broken partner did not choose to stop bidding")
+	assert.Equal(t, services.BreakerOpen, service.PartnerBreakerState("failing"))
+
+	require.Eventually(t, func() bool { return len(receiver.received()) == 1 }, time.Second, 5*time.Millisecond)
+	event := receiver.received()[0].event
+	assert.Equal(t, config.WebhookEventPartnerShadowed, event.Type)
+	data, ok := event.Data.(map[string]interface{})
+	require.True(t, ok)
+	assert.Equal(t, "silent", data["partner_id"])
+	assert.Equal(t, float64(31), data["idle_days"])
+	assert.NotEmpty(t, data["last_call_at"])
+	assert.Nil(t, data["last_bid_at"])
+
+	// A shadowed partner is still called, but even its best bid loses
+	price.Store(20)
+	assert.Equal(t, []string{"bidder"}, runHygieneTestAuction(t, service))
+	price.Store(0)
+
+	require.NoError(t, service.RestorePartner("silent", "ops"))
+	assert.ErrorIs(t, service.RestorePartner("silent", "ops"), services.ErrPartnerNotShadowed)
+	assert.ErrorIs(t, service.RestorePartner("partner-9", "ops"), services.ErrUnknownPartner)
+
+	// Restoring restarts the idle stretch, so the partner is not shadowed again straight away
+	runHygieneTestAuction(t, service)
+	time.Sleep(50 * time.Millisecond)
+	assert.False(t, service.PartnerShadowed("silent"))
+	assert.Len(t, receiver.received(), 1)
+
+	price.Store(20)
+	assert.Equal(t, []string{"silent"}, runHygieneTestAuction(t, service))
+}
+
+// TestPartnerActivityRestart tests that partner activity and shadowing kept in Redis survive a
+// restart, with tracking dated from the first instance
+func TestPartnerActivityRestart(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	host, portText, err := net.SplitHostPort(redisServer.Addr())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portText)
+	require.NoError(t, err)
+
+	silent, _ := newNegativeCachePartner(t, http.StatusNoContent, "", 0)
+	cfg := newHygieneTestConfig(t, &config.PartnerConfig{ID: "silent", Endpoint: silent.URL, APIKey: "key-silent", Timeout: 200 * time.Millisecond, Enabled: true})
+	cfg.Redis = &config.RedisConfig{Host: host, Port: port, Timeout: time.Second}
+	cfg.Hygiene = &config.HygieneConfig{AutoShadow: true, CheckInterval: 5 * time.Millisecond}
+
+	start := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	clock := &hygieneClock{now: start}
+	first, err := services.NewAuctionServiceWithClock(cfg, clock)
+	require.NoError(t, err)
+	clock.advance(91 * 24 * time.Hour)
+	runHygieneTestAuction(t, first)
+	require.Eventually(t, func() bool { return first.PartnerShadowed("silent") }, time.Second, 5*time.Millisecond)
+	lastCall := clock.Now()
+	require.NoError(t, first.Close())
+
+	// The second instance neither checks for idle partners nor has seen a call
+	cfg.Hygiene = nil
+	clock.advance(24 * time.Hour)
+	second, err := services.NewAuctionServiceWithClock(cfg, clock)
+	require.NoError(t, err)
+	defer second.Close()
+	assert.True(t, second.PartnerShadowed("silent"))
+
+	report, err := second.PartnerHygiene(0, 0)
+	require.NoError(t, err)
+	require.Len(t, report.Partners, 1)
+	entry := report.Partners[0]
+	assert.Equal(t, "silent", entry.PartnerID)
+	assert.Equal(t, start, entry.TrackedSince)
+	require.NotNil(t, entry.LastCallAt)
+	assert.Equal(t, lastCall, *entry.LastCallAt)
+	assert.Equal(t, []string{services.HygieneNoBids, services.HygieneNoWins, services.HygieneShadowed}, entry.Findings)
+	assert.Equal(t, services.HygieneActionDisable, entry.SuggestedAction)
+
+	require.NoError(t, second.RestorePartner("silent", "ops"))
+	assert.False(t, second.PartnerShadowed("silent"))
+}
+
+// TestHygieneConfigValidation tests that partners are only shadowed once idle and that calls are
+// judged within the partner report retention
+func TestHygieneConfigValidation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		hygiene       *config.HygieneConfig
+		expectedError string
+	}{
+		{name: "Defaults", hygiene: &config.HygieneConfig{AutoShadow: true}},
+		{name: "Shadow Before Idle", hygiene: &config.HygieneConfig{AutoShadow: true, IdleBidDays: 30, ShadowAfterDays: 10},
+			expectedError: "shadow after days must be at least the idle bid days"},
+		{name: "Window Beyond Retention", hygiene: &config.HygieneConfig{ReportWindow: 40 * 24 * time.Hour},
+			expectedError: "hygiene report window must be at most"},
+		{name: "Negative Days", hygiene: &config.HygieneConfig{IdleWinDays: -1}, expectedError: "must not be negative"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Hygiene = tc.hygiene
+			err := cfg.Validate()
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
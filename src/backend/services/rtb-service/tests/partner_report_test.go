@@ -0,0 +1,272 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+	"go.uber.org/zap"                     // v1.24.0
+	"go.uber.org/zap/zapcore"             // v1.24.0
+	"go.uber.org/zap/zaptest/observer"    // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// reportTestStart is the start of the first hour of synthetic partner traffic
+var reportTestStart = time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// feedReportHour records one hour of synthetic traffic with known answers: 90 fast calls and 10 slow
+// calls, 4 of which time out; 6 of the 96 bids are invalid and half of the 90 valid bids win at 10 or 20
+func feedReportHour(reporter *services.PartnerReporter, partnerID string) {
+	for i := 0; i < 100; i++ {
+		call := services.PartnerCall{Latency: 40 * time.Millisecond, Bids: 1}
+		switch {
+		case i >= 96:
+			call = services.PartnerCall{Latency: 150 * time.Millisecond, TimedOut: true}
+		case i >= 90:
+			call.Latency = 150 * time.Millisecond
+		}
+		if i < 6 {
+			call.InvalidBids = 1
+		}
+		reporter.RecordCall(partnerID, call)
+	}
+	for i := 0; i < 45; i++ {
+		reporter.RecordWin(partnerID, float64(10+10*(i%2)))
+	}
+}
+
+// newReportTestReporter creates a reporter for partner-1 with sla and a clock at reportTestStart
+func newReportTestReporter(sla *config.PartnerSLA) (*services.PartnerReporter, *steppingClock) {
+	clock := &steppingClock{now: reportTestStart}
+	cfg := &config.Config{Partners: map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Timeout: 200 * time.Millisecond, Enabled: true, SLA: sla},
+	}}
+	return services.NewPartnerReporter(cfg, clock), clock
+}
+
+// TestPartnerReport tests report rates, clearing price, latency, and SLA compliance against a known stream
+func TestPartnerReport(t *testing.T) {
+	testCases := []struct {
+		name                   string
+		sla                    *config.PartnerSLA
+		expectedLatencyMet     bool
+		expectedTimeoutRateMet bool
+	}{
+		{name: "No SLA"},
+		{name: "SLA Met", sla: &config.PartnerSLA{P95Latency: 200 * time.Millisecond, MaxTimeoutRate: 0.05}, expectedLatencyMet: true, expectedTimeoutRateMet: true},
+		{name: "Latency Missed", sla: &config.PartnerSLA{P95Latency: 100 * time.Millisecond, MaxTimeoutRate: 0.05}, expectedTimeoutRateMet: true},
+		{name: "Timeout Rate Missed", sla: &config.PartnerSLA{P95Latency: 200 * time.Millisecond, MaxTimeoutRate: 0.02}, expectedLatencyMet: true},
+		{name: "Timeout Rate Only", sla: &config.PartnerSLA{MaxTimeoutRate: 0.05}, expectedLatencyMet: true, expectedTimeoutRateMet: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter, _ := newReportTestReporter(tc.sla)
+			feedReportHour(reporter, "partner-1")
+
+			report, err := reporter.Report("partner-1", time.Hour)
+			require.NoError(t, err)
+
+			assert.Equal(t, "partner-1", report.PartnerID)
+			assert.Equal(t, uint64(100), report.Calls)
+			assert.InDelta(t, 0.04, report.TimeoutRate, 1e-9)
+			assert.InDelta(t, 6.0/96.0, report.InvalidBidRate, 1e-9)
+			assert.InDelta(t, 0.5, report.WinRate, 1e-9)
+			assert.Equal(t, uint64(45), report.Wins)
+			assert.InDelta(t, 14.89, report.AvgClearingPrice, 0.01)
+			assert.InDelta(t, 150, report.P95LatencyMs, 15)
+
+			if tc.sla == nil {
+				assert.Nil(t, report.SLA)
+				return
+			}
+			require.NotNil(t, report.SLA)
+			assert.Equal(t, tc.expectedLatencyMet, report.SLA.LatencyMet)
+			assert.Equal(t, tc.expectedTimeoutRateMet, report.SLA.TimeoutRateMet)
+			assert.Equal(t, tc.expectedLatencyMet && tc.expectedTimeoutRateMet, report.SLA.Met)
+		})
+	}
+}
+
+// TestPartnerSLABreaches tests that completed hours are judged once, with a metric and log per missed target
+func TestPartnerSLABreaches(t *testing.T) {
+	testCases := []struct {
+		name             string
+		sla              *config.PartnerSLA
+		expectedBreaches map[string]float64
+	}{
+		{
+			name:             "Both Targets Missed",
+			sla:              &config.PartnerSLA{P95Latency: 100 * time.Millisecond, MaxTimeoutRate: 0.02},
+			expectedBreaches: map[string]float64{"p95_latency": 1, "timeout_rate": 1},
+		},
+		{
+			name:             "Targets Met",
+			sla:              &config.PartnerSLA{P95Latency: 200 * time.Millisecond, MaxTimeoutRate: 0.05},
+			expectedBreaches: map[string]float64{"p95_latency": 0, "timeout_rate": 0},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reporter, clock := newReportTestReporter(tc.sla)
+			core, logs := observer.New(zapcore.WarnLevel)
+			reporter.SetLogger(zap.New(core))
+
+			before := make(map[string]float64, len(tc.expectedBreaches))
+			for slo := range tc.expectedBreaches {
+				before[slo] = gatheredMetric(t, "rtb_partner_sla_breach_total", map[string]string{"partner": "partner-1", "slo": slo})
+			}
+
+			feedReportHour(reporter, "partner-1")
+			report, err := reporter.Report("partner-1", time.Hour)
+			require.NoError(t, err)
+			assert.Zero(t, report.SLA.BreachedHours, "the current hour is not judged until it completes")
+
+			// The first call of the next hour judges the completed one
+			clock.now = clock.now.Add(time.Hour)
+			reporter.RecordCall("partner-1", services.PartnerCall{Latency: 40 * time.Millisecond, Bids: 1})
+			reporter.RecordCall("partner-1", services.PartnerCall{Latency: 40 * time.Millisecond, Bids: 1})
+
+			breaches := 0
+			for slo, expected := range tc.expectedBreaches {
+				actual := gatheredMetric(t, "rtb_partner_sla_breach_total", map[string]string{"partner": "partner-1", "slo": slo}) - before[slo]
+				assert.Equal(t, expected, actual, slo)
+				breaches += int(expected)
+			}
+
+			entries := logs.FilterMessage("partner SLA breached").All()
+			require.Len(t, entries, breaches)
+			for _, entry := range entries {
+				assert.Equal(t, "partner-1", entry.ContextMap()["partner"])
+				assert.Equal(t, reportTestStart, entry.ContextMap()["hour"])
+			}
+
+			report, err = reporter.Report("partner-1", 2*time.Hour)
+			require.NoError(t, err)
+			assert.Equal(t, uint64(102), report.Calls)
+			assert.Equal(t, breaches > 0, report.SLA.BreachedHours == 1)
+		})
+	}
+}
+
+// TestPartnerReportWindows tests window coverage, expiry, quiet hours, and rejected queries
+func TestPartnerReportWindows(t *testing.T) {
+	reporter, clock := newReportTestReporter(&config.PartnerSLA{P95Latency: 10 * time.Millisecond})
+
+	// Ten slow calls fall below the minimum sample for judging an hour
+	for i := 0; i < 10; i++ {
+		reporter.RecordCall("partner-1", services.PartnerCall{Latency: 150 * time.Millisecond, Bids: 1})
+	}
+	clock.now = clock.now.Add(5 * time.Hour)
+	reporter.RecordCall("partner-1", services.PartnerCall{Latency: 5 * time.Millisecond, Bids: 1})
+
+	testCases := []struct {
+		name          string
+		partnerID     string
+		window        time.Duration
+		expectedCalls uint64
+		expectedErr   error
+	}{
+		{name: "Current Hour", partnerID: "partner-1", window: time.Hour, expectedCalls: 1},
+		{name: "Both Hours", partnerID: "partner-1", window: 6 * time.Hour, expectedCalls: 11},
+		{name: "Retention", partnerID: "partner-1", window: services.PartnerReportRetention, expectedCalls: 11},
+		{name: "Beyond Retention", partnerID: "partner-1", window: services.PartnerReportRetention + time.Hour, expectedErr: services.ErrInvalidReportWindow},
+		{name: "Empty Window", partnerID: "partner-1", expectedErr: services.ErrInvalidReportWindow},
+		{name: "Unknown Partner", partnerID: "partner-9", window: time.Hour, expectedErr: services.ErrUnknownPartner},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			report, err := reporter.Report(tc.partnerID, tc.window)
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedCalls, report.Calls)
+			assert.Zero(t, report.SLA.BreachedHours)
+		})
+	}
+
+	// Hours older than the retention are dropped as their slots are reused
+	clock.now = clock.now.Add(services.PartnerReportRetention)
+	reporter.RecordCall("partner-1", services.PartnerCall{Latency: 5 * time.Millisecond, Bids: 1})
+	report, err := reporter.Report("partner-1", services.PartnerReportRetention)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), report.Calls)
+}
+
+// TestPartnerReportEndpoint tests that auctions feed partner reports served by the report endpoint
+// to admin callers only
+func TestPartnerReportEndpoint(t *testing.T) {
+	partner := newPartnerServer(t, models.Bid{ID: "bid-1", Price: 8.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withAdmin())
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	router, handler := newTestRouter(t, service, cfg)
+	router.GET("/v1/partners/:id/report", handler.HandlePartnerReport)
+
+	body, err := json.Marshal(models.BidRequest{RequestID: "report-test", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/v1/bids", bytes.NewBuffer(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	testCases := []struct {
+		name           string
+		path           string
+		adminKey       string
+		noAdminKey     bool
+		expectedStatus int
+		expectedWindow string
+	}{
+		{name: "Days Window", path: "/v1/partners/partner-1/report?window=7d", expectedStatus: http.StatusOK, expectedWindow: "168h0m0s"},
+		{name: "Default Window", path: "/v1/partners/partner-1/report", expectedStatus: http.StatusOK, expectedWindow: "168h0m0s"},
+		{name: "Hours Window", path: "/v1/partners/partner-1/report?window=1h", expectedStatus: http.StatusOK, expectedWindow: "1h0m0s"},
+		{name: "Window Beyond Retention", path: "/v1/partners/partner-1/report?window=32d", expectedStatus: http.StatusBadRequest},
+		{name: "Malformed Window", path: "/v1/partners/partner-1/report?window=xd", expectedStatus: http.StatusBadRequest},
+		{name: "Unknown Partner", path: "/v1/partners/partner-9/report", expectedStatus: http.StatusNotFound},
+		{name: "Missing Admin Key", path: "/v1/partners/partner-1/report", noAdminKey: true, expectedStatus: http.StatusUnauthorized},
+		{name: "Wrong Admin Key", path: "/v1/partners/partner-1/report", adminKey: "wrong-key", expectedStatus: http.StatusUnauthorized},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, tc.path, nil)
+			if !tc.noAdminKey {
+				adminKey := testAdminKey
+				if tc.adminKey != "" {
+					adminKey = tc.adminKey
+				}
+				req.Header.Set("X-Admin-Key", adminKey)
+			}
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			var report services.PartnerReport
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, tc.expectedWindow, report.Window)
+			assert.Equal(t, uint64(1), report.Calls)
+			assert.Equal(t, uint64(1), report.Wins)
+			assert.Equal(t, 1.0, report.WinRate)
+			assert.Equal(t, 8.0, report.AvgClearingPrice)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// TestPartnerScheduleSkipsOffHoursPartner tests that partners outside their active hours are not called
+func TestPartnerScheduleSkipsOffHoursPartner(t *testing.T) {
+	callCenter := newPartnerServer(t, models.Bid{ID: "bid-call-center", Price: 20.0, QualityScore: 0.9, ClickURL: "http://example.com/cc"})
+	always := newPartnerServer(t, models.Bid{ID: "bid-always", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/always"})
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"call-center": {
+			ID: "call-center", Endpoint: callCenter.URL, APIKey: "key-cc", Timeout: 200 * time.Millisecond, Enabled: true,
+			Schedule: &config.PartnerSchedule{
+				Timezone: "America/New_York",
+				Windows:  []config.ScheduleWindow{{Days: []string{"mon", "tue", "wed", "thu", "fri"}, StartHour: 8, EndHour: 20}},
+			},
+		},
+		"always": {ID: "always", Endpoint: always.URL, APIKey: "key-always", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2))
+
+	testCases := []struct {
+		name        string
+		now         time.Time
+		inSchedule  bool
+		expectedIDs []string
+	}{
+		// 2024-01-15 is a Monday; Eastern time is UTC-5
+		{name: "Within Hours", now: time.Date(2024, 1, 15, 15, 0, 0, 0, time.UTC), inSchedule: true, expectedIDs: []string{"bid-call-center", "bid-always"}},
+		{name: "Overnight", now: time.Date(2024, 1, 16, 3, 0, 0, 0, time.UTC), inSchedule: false, expectedIDs: []string{"bid-always"}},
+		{name: "Weekend", now: time.Date(2024, 1, 13, 15, 0, 0, 0, time.UTC), inSchedule: false, expectedIDs: []string{"bid-always"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, err := services.NewAuctionServiceWithClock(cfg, fixedClock{now: tc.now})
+			require.NoError(t, err)
+			assert.Equal(t, tc.inSchedule, service.PartnerInSchedule("call-center"))
+			assert.True(t, service.PartnerInSchedule("always"))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "schedule-" + tc.name, LeadID: "lead-1", Vertical: "auto"})
+			require.NoError(t, err)
+
+			ids := make([]string, 0, len(response.Bids))
+			for _, bid := range response.Bids {
+				ids = append(ids, bid.ID)
+			}
+			assert.Equal(t, tc.expectedIDs, ids)
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// selectionTestPrices are the bids of the partner selection test partners; a zero price never bids
+var selectionTestPrices = map[string]float64{"p-a": 5, "p-b": 9, "p-c": 7, "p-d": 0, "p-e": 8}
+
+// newSelectionTestService creates a service capped at two partners per auction whose partners
+// count their calls
+func newSelectionTestService(t *testing.T, clock *steppingClock, deals map[string]*config.DealConfig) (*services.AuctionService, map[string]*atomic.Int64) {
+	calls := make(map[string]*atomic.Int64, len(selectionTestPrices))
+	partners := make(map[string]*config.PartnerConfig, len(selectionTestPrices))
+	for partnerID, price := range selectionTestPrices {
+		partnerID, price := partnerID, price
+		calls[partnerID] = &atomic.Int64{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls[partnerID].Add(1)
+			if price == 0 {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(models.Bid{ID: "bid-" + partnerID, Price: price, QualityScore: 0.5, ClickURL: "http://example.com/" + partnerID})
+		}))
+		t.Cleanup(server.Close)
+		partners[partnerID] = &config.PartnerConfig{ID: partnerID, Endpoint: server.URL, APIKey: "key", Timeout: 200 * time.Millisecond, Enabled: true}
+	}
+
+	cfg := newTestConfig(partners, withMaxBids(5))
+	cfg.Deals = deals
+	cfg.MaxPartnersPerAuction = 2
+	service := newTestServiceWithClock(t, cfg, clock)
+	return service, calls
+}
+
+// runSelectionTestAuction runs an auction in a vertical and returns its debug output
+func runSelectionTestAuction(t *testing.T, service *services.AuctionService, vertical string) *models.DebugInfo {
+	debug := models.NewDebugInfo()
+	ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), 500*time.Millisecond)
+	defer cancel()
+	_, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "selection-test", LeadID: "lead-1", Vertical: vertical})
+	if err != nil {
+		require.ErrorIs(t, err, services.ErrNoValidBids)
+	}
+	return debug
+}
+
+// TestPartnerSelection tests that capped auctions contact the best scoring partners for the
+// vertical, always including partners with an active deal, and contact every eligible partner
+// while stats are cold
+func TestPartnerSelection(t *testing.T) {
+	now := time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name              string
+		deals             map[string]*config.DealConfig
+		vertical          string
+		advance           time.Duration
+		expectedReasons   map[string]models.Reason
+		expectedContacted []string
+	}{
+		{
+			name:              "Ranked By Bid Rate And Clearing Price",
+			vertical:          "auto",
+			expectedReasons:   map[string]models.Reason{"p-b": "ranked", "p-e": "ranked", "p-c": "not_selected", "p-a": "not_selected", "p-d": "not_selected"},
+			expectedContacted: []string{"p-b", "p-e"},
+		},
+		{
+			name:              "Active Deal",
+			deals:             map[string]*config.DealConfig{"deal-a": {PartnerID: "p-a", FloorPrice: 1, Verticals: []string{"auto"}}},
+			vertical:          "auto",
+			expectedReasons:   map[string]models.Reason{"p-a": "deal", "p-b": "ranked", "p-e": "not_selected", "p-c": "not_selected", "p-d": "not_selected"},
+			expectedContacted: []string{"p-a", "p-b"},
+		},
+		{
+			name:              "Expired Deal",
+			deals:             map[string]*config.DealConfig{"deal-a": {PartnerID: "p-a", FloorPrice: 1, ExpiresAt: now.Add(-time.Hour)}},
+			vertical:          "auto",
+			expectedReasons:   map[string]models.Reason{"p-b": "ranked", "p-e": "ranked", "p-a": "not_selected"},
+			expectedContacted: []string{"p-b", "p-e"},
+		},
+		{
+			name:              "Deal In Another Vertical",
+			deals:             map[string]*config.DealConfig{"deal-a": {PartnerID: "p-a", FloorPrice: 1, Verticals: []string{"home"}}},
+			vertical:          "auto",
+			expectedReasons:   map[string]models.Reason{"p-b": "ranked", "p-e": "ranked", "p-a": "not_selected"},
+			expectedContacted: []string{"p-b", "p-e"},
+		},
+		{
+			name:              "Cold Vertical",
+			vertical:          "home",
+			expectedReasons:   map[string]models.Reason{"p-a": "cold", "p-b": "cold", "p-c": "cold", "p-d": "cold", "p-e": "cold"},
+			expectedContacted: []string{"p-a", "p-b", "p-c", "p-d", "p-e"},
+		},
+		{
+			name:              "Stats Aged Out",
+			vertical:          "auto",
+			advance:           7 * time.Hour,
+			expectedReasons:   map[string]models.Reason{"p-a": "cold", "p-b": "cold", "p-c": "cold", "p-d": "cold", "p-e": "cold"},
+			expectedContacted: []string{"p-a", "p-b", "p-c", "p-d", "p-e"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := &steppingClock{now: now}
+			service, calls := newSelectionTestService(t, clock, tc.deals)
+
+			// Every partner is contacted until its stats in the vertical warm up
+			for i := 0; i < 20; i++ {
+				runSelectionTestAuction(t, service, "auto")
+			}
+			for partnerID, count := range calls {
+				require.Equal(t, int64(20), count.Load(), partnerID)
+				count.Store(0)
+			}
+			clock.now = clock.now.Add(tc.advance)
+
+			// The same stats pick the same partners every time
+			for run := 0; run < 2; run++ {
+				debug := runSelectionTestAuction(t, service, tc.vertical)
+				for partnerID, reason := range tc.expectedReasons {
+					decision, _ := debug.Partner(partnerID)
+					require.NotNil(t, decision.Selection, partnerID)
+					assert.Equal(t, reason, decision.Selection.Reason, partnerID)
+					if reason == "not_selected" {
+						assert.Equal(t, models.ReasonNotSelected, decision.SkipReason, partnerID)
+					}
+				}
+			}
+
+			contacted := []string{}
+			for partnerID, count := range calls {
+				if count.Load() > 0 {
+					assert.Equal(t, int64(2), count.Load(), partnerID)
+					contacted = append(contacted, partnerID)
+				}
+			}
+			assert.ElementsMatch(t, tc.expectedContacted, contacted)
+		})
+	}
+}
+
+// TestPartnerSelectionScores tests that debug output carries each partner's score, bid rate
+// times average clearing price
+func TestPartnerSelectionScores(t *testing.T) {
+	service, _ := newSelectionTestService(t, &steppingClock{now: time.Date(2024, 1, 20, 10, 0, 0, 0, time.UTC)}, nil)
+	for i := 0; i < 20; i++ {
+		runSelectionTestAuction(t, service, "auto")
+	}
+
+	debug := runSelectionTestAuction(t, service, "auto")
+	for partnerID, price := range selectionTestPrices {
+		decision, _ := debug.Partner(partnerID)
+		require.NotNil(t, decision.Selection, partnerID)
+		assert.InDelta(t, price, decision.Selection.Score, 1e-9, partnerID)
+	}
+}
+
+// TestPartnerSelectionValidation tests that a negative partner cap fails at config load
+func TestPartnerSelectionValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		max         int
+		expectedErr string
+	}{
+		{name: "Uncapped", max: 0},
+		{name: "Capped", max: 3},
+		{name: "Negative", max: -1, expectedErr: "invalid max partners per auction: -1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.MaxPartnersPerAuction = tc.max
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newStatsTestService creates a service with a healthy partner answering after delay and a partner
+// that always fails, with breakers that never open so every auction records a failure
+func newStatsTestService(tb testing.TB, delay time.Duration) *services.AuctionService {
+	healthy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		json.NewEncoder(w).Encode(models.Bid{ID: "healthy-bid", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/healthy"})
+	}))
+	tb.Cleanup(healthy.Close)
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	tb.Cleanup(failing.Close)
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"healthy": {ID: "healthy", Endpoint: healthy.URL, APIKey: "key-healthy", Timeout: time.Second, Enabled: true},
+		"failing": {ID: "failing", Endpoint: failing.URL, APIKey: "key-failing", Timeout: time.Second, Enabled: true},
+	}, withBidTimeout(2*time.Second))
+	cfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 1 << 30, Cooldown: time.Minute}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(tb, err)
+	return service
+}
+
+// runStatsTestAuction runs one auction against the stats test partners
+func runStatsTestAuction(tb testing.TB, service *services.AuctionService, requestID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	_, err := service.RunAuction(ctx, &models.BidRequest{RequestID: requestID, LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(tb, err)
+}
+
+// TestPartnerStatsConcurrency tests that failures counted by concurrent auctions are all reported
+// while stats and statuses are read alongside them
+func TestPartnerStatsConcurrency(t *testing.T) {
+	testCases := []struct {
+		name     string
+		auctions int
+	}{
+		{name: "Single Auction", auctions: 1},
+		{name: "Concurrent Auctions", auctions: 200},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newStatsTestService(t, time.Millisecond)
+
+			var wg sync.WaitGroup
+			for i := 0; i < tc.auctions; i++ {
+				wg.Add(2)
+				go func(i int) {
+					defer wg.Done()
+					runStatsTestAuction(t, service, fmt.Sprintf("stats-%d", i))
+				}(i)
+				go func() {
+					defer wg.Done()
+					service.GetPartnerStats()
+					service.PartnerStatuses()
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, map[string]int{"failing": tc.auctions}, service.GetPartnerStats())
+			for _, status := range service.PartnerStatuses() {
+				expected := 0
+				if status.ID == "failing" {
+					expected = tc.auctions
+				}
+				assert.Equal(t, expected, status.Failures, status.ID)
+			}
+		})
+	}
+}
+
+// BenchmarkConcurrentAuctions measures auction throughput with 1k auctions in flight while a
+// failing partner records a failure in every one of them
+func BenchmarkConcurrentAuctions(b *testing.B) {
+	service := newStatsTestService(b, 2*time.Millisecond)
+	b.SetParallelism(max(1, 1000/runtime.GOMAXPROCS(0)))
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			runStatsTestAuction(b, service, fmt.Sprintf("bench-%d", i))
+		}
+	})
+}
@@ -0,0 +1,281 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// partnerTLSServerName is the only name on the mTLS partner's certificate
+const partnerTLSServerName = "partner.internal"
+
+// testCA issues certificates for TLS tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+// newTestCA creates a self-signed CA
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue returns the PEM certificate and key of a server certificate for dnsName, or of a client
+// certificate when dnsName is empty
+func (ca *testCA) issue(t *testing.T, commonName, dnsName string) ([]byte, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if dnsName != "" {
+		template.DNSNames = []string{dnsName}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+// writeTestFile writes data to name in dir and returns its path
+func writeTestFile(t *testing.T, dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+// mtlsPartner is a partner requiring client certificates issued by clientCA, serving a certificate
+// for partnerTLSServerName issued by serverCA
+type mtlsPartner struct {
+	server   *httptest.Server
+	serverCA *testCA
+	clientCA *testCA
+}
+
+// newMTLSPartner starts an mTLS partner that bids
+func newMTLSPartner(t *testing.T) *mtlsPartner {
+	partner := &mtlsPartner{serverCA: newTestCA(t, "partner server CA"), clientCA: newTestCA(t, "partner client CA")}
+	certPEM, keyPEM := partner.serverCA.issue(t, "partner", partnerTLSServerName)
+	serverCert, err := tls.X509KeyPair(certPEM, keyPEM)
+	require.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(partner.clientCA.cert)
+
+	partner.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "mtls-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/mtls"})
+	}))
+	partner.server.TLS = &tls.Config{Certificates: []tls.Certificate{serverCert}, ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	partner.server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	partner.server.StartTLS()
+	t.Cleanup(partner.server.Close)
+	return partner
+}
+
+// newPartnerTLSTestConfig returns a config with one partner reached over TLS
+func newPartnerTLSTestConfig(partnerID, endpoint string, tlsConfig *config.PartnerTLS) *config.Config {
+	return newTestConfig(map[string]*config.PartnerConfig{
+		partnerID: {ID: partnerID, Endpoint: endpoint, APIKey: "key", Timeout: 200 * time.Millisecond, Enabled: true, TLS: tlsConfig},
+	})
+}
+
+// runPartnerTLSTestAuction runs an auction and returns whether the partner's bid won
+func runPartnerTLSTestAuction(t *testing.T, service *services.AuctionService) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "mtls-test", LeadID: "lead-1", Vertical: "auto"})
+	if err != nil {
+		require.ErrorIs(t, err, services.ErrNoValidBids)
+		return false
+	}
+	return len(response.Bids) == 1 && response.Bids[0].ID == "mtls-bid"
+}
+
+// TestPartnerMutualTLS tests client certificates, private CAs, and ServerName overrides, and that
+// handshake failures are counted by reason
+func TestPartnerMutualTLS(t *testing.T) {
+	partner := newMTLSPartner(t)
+	dir := t.TempDir()
+	clientCert, clientKey := partner.clientCA.issue(t, "rtb-service", "")
+	certFile := writeTestFile(t, dir, "client.pem", clientCert)
+	keyFile := writeTestFile(t, dir, "client-key.pem", clientKey)
+	caFile := writeTestFile(t, dir, "ca.pem", partner.serverCA.pem)
+	t.Setenv("RTB_TEST_CLIENT_CERT", string(clientCert))
+
+	testCases := []struct {
+		name           string
+		tls            *config.PartnerTLS
+		expectedBid    bool
+		expectedReason string
+	}{
+		{name: "Client Certificate From Files", tls: &config.PartnerTLS{CertFile: certFile, KeyFile: keyFile, CAFile: caFile, ServerName: partnerTLSServerName},
+			expectedBid: true},
+		{name: "Client Certificate From Secrets", tls: &config.PartnerTLS{Cert: "env:RTB_TEST_CLIENT_CERT", Key: "file:" + keyFile, CAFile: caFile,
+			ServerName: partnerTLSServerName}, expectedBid: true},
+		{name: "Insecure Skip Verify", tls: &config.PartnerTLS{CertFile: certFile, KeyFile: keyFile, InsecureSkipVerify: true}, expectedBid: true},
+		{name: "Missing Client Certificate", tls: &config.PartnerTLS{CAFile: caFile, ServerName: partnerTLSServerName}, expectedReason: "rejected"},
+		{name: "Server Not In CA Bundle", tls: &config.PartnerTLS{CertFile: certFile, KeyFile: keyFile, ServerName: partnerTLSServerName},
+			expectedReason: "verify"},
+		{name: "Server Name Not Overridden", tls: &config.PartnerTLS{CertFile: certFile, KeyFile: keyFile, CAFile: caFile}, expectedReason: "verify"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, err := services.NewAuctionService(newPartnerTLSTestConfig("mtls-partner", partner.server.URL, tc.tls))
+			require.NoError(t, err)
+			defer service.Close()
+
+			labels := map[string]string{"partner": "mtls-partner"}
+			reasonLabels := map[string]string{"partner": "mtls-partner", "reason": tc.expectedReason}
+			before := gatheredMetric(t, "rtb_partner_tls_handshake_failures_total", labels)
+			beforeReason := gatheredMetric(t, "rtb_partner_tls_handshake_failures_total", reasonLabels)
+			assert.Equal(t, tc.expectedBid, runPartnerTLSTestAuction(t, service))
+			failures := gatheredMetric(t, "rtb_partner_tls_handshake_failures_total", labels) - before
+			if tc.expectedReason == "" {
+				assert.Zero(t, failures)
+				return
+			}
+			assert.Equal(t, 1.0, failures)
+			assert.Equal(t, 1.0, gatheredMetric(t, "rtb_partner_tls_handshake_failures_total", reasonLabels)-beforeReason)
+		})
+	}
+}
+
+// TestPartnerTLSReload tests that certificates reload from their files, and that a reload that
+// cannot load them keeps the partner on its current certificate
+func TestPartnerTLSReload(t *testing.T) {
+	partner := newMTLSPartner(t)
+	dir := t.TempDir()
+	trustedCert, trustedKey := partner.clientCA.issue(t, "rtb-service", "")
+	untrustedCert, untrustedKey := newTestCA(t, "other CA").issue(t, "rtb-service", "")
+	tlsConfig := &config.PartnerTLS{
+		CertFile:   writeTestFile(t, dir, "client.pem", trustedCert),
+		KeyFile:    writeTestFile(t, dir, "client-key.pem", trustedKey),
+		CAFile:     writeTestFile(t, dir, "ca.pem", partner.serverCA.pem),
+		ServerName: partnerTLSServerName,
+	}
+	cfg := newPartnerTLSTestConfig("reload-partner", partner.server.URL, tlsConfig)
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	require.True(t, runPartnerTLSTestAuction(t, service))
+
+	writeTestFile(t, dir, "client.pem", []byte("not a certificate"))
+	err = service.SetPartnerTLS(cfg.Partners)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid TLS for partner reload-partner")
+	assert.True(t, runPartnerTLSTestAuction(t, service))
+
+	writeTestFile(t, dir, "client.pem", untrustedCert)
+	writeTestFile(t, dir, "client-key.pem", untrustedKey)
+	require.NoError(t, service.SetPartnerTLS(cfg.Partners))
+	assert.False(t, runPartnerTLSTestAuction(t, service))
+
+	writeTestFile(t, dir, "client.pem", trustedCert)
+	writeTestFile(t, dir, "client-key.pem", trustedKey)
+	require.NoError(t, service.SetPartnerTLS(cfg.Partners))
+	assert.True(t, runPartnerTLSTestAuction(t, service))
+}
+
+// TestPartnerTLSValidation tests that certificates load at validation, with errors naming the
+// partner, and that skipping verification needs the global guard
+func TestPartnerTLSValidation(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, "validation CA")
+	clientCert, clientKey := ca.issue(t, "rtb-service", "")
+	_, otherKey := ca.issue(t, "other", "")
+	certFile := writeTestFile(t, dir, "client.pem", clientCert)
+	keyFile := writeTestFile(t, dir, "client-key.pem", clientKey)
+	otherKeyFile := writeTestFile(t, dir, "other-key.pem", otherKey)
+	caFile := writeTestFile(t, dir, "ca.pem", ca.pem)
+	emptyCAFile := writeTestFile(t, dir, "empty-ca.pem", []byte("no certificates here"))
+
+	testCases := []struct {
+		name          string
+		tls           *config.PartnerTLS
+		endpoint      string
+		allowInsecure bool
+		expectedErr   string
+	}{
+		{name: "Client Certificate And CA", tls: &config.PartnerTLS{CertFile: certFile, KeyFile: keyFile, CAFile: caFile}},
+		{name: "CA Only", tls: &config.PartnerTLS{CAFile: caFile, ServerName: "partner.internal"}},
+		{name: "Missing Certificate File", tls: &config.PartnerTLS{CertFile: filepath.Join(dir, "missing.pem"), KeyFile: keyFile},
+			expectedErr: "invalid TLS for partner partner-1: reading client certificate"},
+		{name: "Key Without Certificate", tls: &config.PartnerTLS{KeyFile: keyFile}, expectedErr: "must be set together"},
+		{name: "Files And Secrets", tls: &config.PartnerTLS{CertFile: certFile, KeyFile: keyFile, Cert: "env:RTB_TEST_UNSET_CERT"},
+			expectedErr: "both as files and as secrets"},
+		{name: "Unresolved Secret", tls: &config.PartnerTLS{Cert: "env:RTB_TEST_UNSET_CERT", Key: "file:" + keyFile},
+			expectedErr: "unresolved client certificate"},
+		{name: "Mismatched Key", tls: &config.PartnerTLS{CertFile: certFile, KeyFile: otherKeyFile}, expectedErr: "loading client certificate"},
+		{name: "Empty CA Bundle", tls: &config.PartnerTLS{CAFile: emptyCAFile}, expectedErr: "no certificates in CA bundle"},
+		{name: "Insecure Without Guard", tls: &config.PartnerTLS{InsecureSkipVerify: true},
+			expectedErr: "insecure_skip_verify for partner partner-1 requires allow_insecure_partner_tls"},
+		{name: "Insecure With Guard", tls: &config.PartnerTLS{InsecureSkipVerify: true}, allowInsecure: true},
+		{name: "Plain HTTP Endpoint", tls: &config.PartnerTLS{CAFile: caFile}, endpoint: "http://partner-1", expectedErr: "needs an https endpoint"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.AllowInsecurePartnerTLS = tc.allowInsecure
+			cfg.Partners["partner-1"].Endpoint = "https://partner-1"
+			if tc.endpoint != "" {
+				cfg.Partners["partner-1"].Endpoint = tc.endpoint
+			}
+			cfg.Partners["partner-1"].TLS = tc.tls
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expectedErr)
+		})
+	}
+}
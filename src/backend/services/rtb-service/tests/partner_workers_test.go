@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+	"go.uber.org/goleak"                  // v1.1.11
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// TestPartnerWorkerLimit tests that partner calls across concurrent auctions never exceed the
+// worker limit and that every partner is still called
+func TestPartnerWorkerLimit(t *testing.T) {
+	testCases := []struct {
+		name     string
+		workers  int
+		partners int
+		auctions int
+	}{
+		{name: "Single Worker", workers: 1, partners: 3, auctions: 2},
+		{name: "Fewer Workers Than Partners", workers: 2, partners: 4, auctions: 3},
+		{name: "Default Limit", workers: 0, partners: 4, auctions: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var inFlight, peak atomic.Int32
+			partners := make(map[string]*config.PartnerConfig, tc.partners)
+			for i := 0; i < tc.partners; i++ {
+				id := fmt.Sprintf("partner-%d", i)
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					current := inFlight.Add(1)
+					defer inFlight.Add(-1)
+					for {
+						highest := peak.Load()
+						if current <= highest || peak.CompareAndSwap(highest, current) {
+							break
+						}
+					}
+					time.Sleep(10 * time.Millisecond)
+					json.NewEncoder(w).Encode(models.Bid{ID: id + "-bid", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/" + id})
+				}))
+				t.Cleanup(server.Close)
+				partners[id] = &config.PartnerConfig{ID: id, Endpoint: server.URL, APIKey: "key-" + id, Timeout: time.Second, Enabled: true}
+			}
+
+			cfg := newTestConfig(partners, withBidTimeout(2*time.Second), withMaxBids(tc.partners))
+			cfg.PartnerWorkers = tc.workers
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			results := make(chan int, tc.auctions)
+			for i := 0; i < tc.auctions; i++ {
+				go func(i int) {
+					ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+					defer cancel()
+					response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: fmt.Sprintf("workers-%d", i), LeadID: "lead-1", Vertical: "auto"})
+					if err != nil {
+						results <- 0
+						return
+					}
+					results <- len(response.Bids)
+				}(i)
+			}
+			for i := 0; i < tc.auctions; i++ {
+				assert.Equal(t, tc.partners, <-results)
+			}
+
+			if tc.workers > 0 {
+				assert.LessOrEqual(t, peak.Load(), int32(tc.workers))
+			}
+		})
+	}
+}
+
+// TestTimedOutAuctionsLeakNothing tests that auctions whose partners all time out leave no
+// goroutines behind once the service closes, and that every partner's failed call is counted
+// as a call error: a timeout for the partner that ran out its own timeout, and a cancellation
+// for the one still running when bid collection ran out of time
+func TestTimedOutAuctionsLeakNothing(t *testing.T) {
+	ignore := goleak.IgnoreCurrent()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	const auctions, concurrency = 1000, 50
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-timeout":   {ID: "partner-timeout", Endpoint: server.URL, APIKey: "key-1", Timeout: 10 * time.Millisecond, Enabled: true},
+		"partner-cancelled": {ID: "partner-cancelled", Endpoint: server.URL, APIKey: "key-2", Timeout: 500 * time.Millisecond, Enabled: true},
+	}, withBidTimeout(time.Second), withMaxBids(2))
+	// Every auction must call both partners, so their breakers never open
+	cfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 2 * auctions}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+
+	callErrors := func(partnerID string, reasons ...models.Reason) float64 {
+		total := 0.0
+		for _, reason := range reasons {
+			total += gatheredMetric(t, "rtb_partner_call_errors_total", map[string]string{"partner": partnerID, "reason": string(reason)})
+		}
+		return total
+	}
+	timeoutsBefore := callErrors("partner-timeout", models.ReasonCallTimeout, models.ReasonCallCancelled)
+	cancelledBefore := callErrors("partner-cancelled", models.ReasonCallCancelled)
+
+	var timedOut atomic.Int32
+	var wg sync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for i := worker; i < auctions; i += concurrency {
+				ctx, cancel := context.WithTimeout(context.Background(), 40*time.Millisecond)
+				_, err := service.RunAuction(ctx, &models.BidRequest{RequestID: fmt.Sprintf("leak-%d", i), LeadID: "lead-1", Vertical: "auto"})
+				cancel()
+				if errors.Is(err, services.ErrAuctionTimeout) {
+					timedOut.Add(1)
+				}
+			}
+		}(worker)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(auctions), timedOut.Load())
+
+	// Calls outlasting the drain grace still record their errors once they finish
+	require.Eventually(t, func() bool {
+		return callErrors("partner-timeout", models.ReasonCallTimeout, models.ReasonCallCancelled)-timeoutsBefore == auctions &&
+			callErrors("partner-cancelled", models.ReasonCallCancelled)-cancelledBefore == auctions
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, service.Close())
+	server.Close()
+	goleak.VerifyNone(t, ignore)
+}
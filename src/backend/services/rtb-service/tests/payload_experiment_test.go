@@ -0,0 +1,265 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// payloadTestStart is when payload experiment tests run, a day before their experiments end
+var payloadTestStart = time.Date(2030, 3, 2, 12, 0, 0, 0, time.UTC)
+
+// newIncomePartner returns a partner that bids 12 on leads whose user data carries income and 8
+// on the rest, noting by lead ID whether it was sent income
+func newIncomePartner(t *testing.T) (*httptest.Server, *sync.Map) {
+	var sawIncome sync.Map
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request models.BidRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, income := request.UserData["income"]
+		sawIncome.Store(request.LeadID, income)
+		price := 8.0
+		if income {
+			// Treatment calls take long enough that their average latency never rounds to zero
+			time.Sleep(time.Millisecond)
+			price = 12.0
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "bid-" + request.LeadID, Price: price, QualityScore: 0.5, ClickURL: "http://example.com/" + request.LeadID})
+	}))
+	t.Cleanup(server.Close)
+	return server, &sawIncome
+}
+
+// newPayloadTestConfig returns a config with partner-1 at endpoint withholding income, and
+// sending it on trafficPercent of its calls in a payload experiment ending a day after the start
+func newPayloadTestConfig(endpoint string, trafficPercent float64) *config.Config {
+	return newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {
+			ID: "partner-1", Endpoint: endpoint, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true,
+			Fields: &config.FieldsPolicy{Mode: config.FieldsPolicyDeny, UserData: []string{"income"}},
+			PayloadExperiment: &config.PayloadExperiment{
+				ID:             "send-income",
+				TrafficPercent: trafficPercent,
+				EndsAt:         payloadTestStart.Add(24 * time.Hour),
+				Variant:        config.PayloadVariant{ExtraFields: []string{"user_data.income"}},
+			},
+		},
+	})
+}
+
+// runPayloadTestAuction runs an auction for leadID and returns its winning bid, if any
+func runPayloadTestAuction(t *testing.T, service *services.AuctionService, requestID, leadID string) *models.Bid {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{
+		RequestID: requestID,
+		LeadID:    leadID,
+		Vertical:  "auto",
+		UserData:  map[string]interface{}{"zip": "94107", "income": 85000},
+	})
+	require.NoError(t, err)
+	require.Len(t, response.Bids, 1)
+	return response.Bids[0]
+}
+
+// TestPayloadExperimentSplit tests that a payload experiment sends the extra field on its share
+// of leads, keeps each lead in one variant across retries, tags bids with their variant, and
+// reports each variant's bid rate, price, and latency on the partner report endpoint
+func TestPayloadExperimentSplit(t *testing.T) {
+	partner, sawIncome := newIncomePartner(t)
+	cfg := newPayloadTestConfig(partner.URL, 50)
+	service := newTestServiceWithClock(t, cfg, &steppingClock{now: payloadTestStart})
+
+	variants := make(map[string]int)
+	for i := 0; i < 40; i++ {
+		leadID := "lead-" + strconv.Itoa(i)
+		first := runPayloadTestAuction(t, service, leadID+"-first", leadID)
+		retry := runPayloadTestAuction(t, service, leadID+"-retry", leadID)
+
+		require.NotNil(t, first.PayloadExperiment)
+		assert.Equal(t, first.PayloadExperiment, retry.PayloadExperiment, "a lead keeps its variant across retries")
+		assert.Equal(t, "send-income", first.PayloadExperiment.ID)
+		income, _ := sawIncome.Load(leadID)
+		treatment := first.PayloadExperiment.Variant == config.ExperimentVariantTreatment
+		assert.Equal(t, treatment, income, "only the treatment is sent income")
+		variants[first.PayloadExperiment.Variant]++
+	}
+	require.NotZero(t, variants[config.ExperimentVariantControl])
+	require.NotZero(t, variants[config.ExperimentVariantTreatment])
+
+	cfg.Admin = &config.AdminConfig{Enabled: true, APIKeys: []string{testAdminKey}}
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/v1/partners/:id/report", handler.HandlePartnerReport)
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/v1/partners/partner-1/report?window=1h", nil)
+	req.Header.Set("X-Admin-Key", testAdminKey)
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var report services.PartnerReport
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, uint64(80), report.Calls)
+	experiment := report.PayloadExperiment
+	require.NotNil(t, experiment)
+	assert.Equal(t, "send-income", experiment.ID)
+	assert.True(t, experiment.Active)
+	control := experiment.Variants[config.ExperimentVariantControl]
+	treatment := experiment.Variants[config.ExperimentVariantTreatment]
+	assert.Equal(t, uint64(2*variants[config.ExperimentVariantControl]), control.Calls)
+	assert.Equal(t, uint64(2*variants[config.ExperimentVariantTreatment]), treatment.Calls)
+	assert.Equal(t, 1.0, control.BidRate)
+	assert.Equal(t, 1.0, treatment.BidRate)
+	assert.Equal(t, 8.0, control.AvgPrice)
+	assert.Equal(t, 12.0, treatment.AvgPrice)
+	assert.Positive(t, treatment.AvgLatencyMs)
+	assert.Equal(t, 4.0, experiment.AvgPriceDelta)
+	assert.Zero(t, experiment.BidRateDelta)
+}
+
+// TestPayloadExperimentExclusions tests which calls a payload experiment leaves out: every call
+// once it has ended, and calls without a lead ID to assign by
+func TestPayloadExperimentExclusions(t *testing.T) {
+	testCases := []struct {
+		name            string
+		now             time.Time
+		leadID          string
+		expectedVariant string
+		expectIncome    bool
+		expectActive    bool
+	}{
+		{name: "Running", now: payloadTestStart, leadID: "lead-1", expectedVariant: config.ExperimentVariantTreatment, expectIncome: true, expectActive: true},
+		{name: "Ended", now: payloadTestStart.Add(25 * time.Hour), leadID: "lead-1"},
+		{name: "No Lead ID", now: payloadTestStart, expectActive: true},
+	}
+
+	for i, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partner, sawIncome := newIncomePartner(t)
+			service := newTestServiceWithClock(t, newPayloadTestConfig(partner.URL, 100), &steppingClock{now: tc.now})
+
+			bid := runPayloadTestAuction(t, service, "exclusion-"+strconv.Itoa(i), tc.leadID)
+			income, _ := sawIncome.Load(tc.leadID)
+			assert.Equal(t, tc.expectIncome, income)
+			if tc.expectedVariant == "" {
+				assert.Nil(t, bid.PayloadExperiment)
+			} else {
+				require.NotNil(t, bid.PayloadExperiment)
+				assert.Equal(t, tc.expectedVariant, bid.PayloadExperiment.Variant)
+			}
+
+			report, err := service.PartnerReport("partner-1", time.Hour)
+			require.NoError(t, err)
+			require.NotNil(t, report.PayloadExperiment, "an ended experiment's results stay retrievable")
+			assert.Equal(t, tc.expectActive, report.PayloadExperiment.Active)
+			calls := report.PayloadExperiment.Variants[config.ExperimentVariantTreatment].Calls + report.PayloadExperiment.Variants[config.ExperimentVariantControl].Calls
+			if tc.expectedVariant == "" {
+				assert.Zero(t, calls)
+			} else {
+				assert.Equal(t, uint64(1), calls)
+			}
+		})
+	}
+}
+
+// TestPayloadExperimentVariantEndpoint tests that a variant endpoint takes the treatment's calls
+// in place of the partner's endpoint, and that a partner cannot tag its own bids with a variant
+func TestPayloadExperimentVariantEndpoint(t *testing.T) {
+	var configuredCalls atomic.Int32
+	configured := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		configuredCalls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "configured-bid", Price: 8.0, QualityScore: 0.5, ClickURL: "http://example.com/configured",
+			PayloadExperiment: &models.ExperimentAssignment{ID: "forged", Variant: config.ExperimentVariantTreatment}})
+	}))
+	t.Cleanup(configured.Close)
+	variant := newPartnerServer(t, models.Bid{ID: "variant-bid", Price: 15.0, QualityScore: 0.5, ClickURL: "http://example.com/variant"})
+
+	cfg := newPayloadTestConfig(configured.URL, 100)
+	cfg.Partners["partner-1"].PayloadExperiment.Variant = config.PayloadVariant{Endpoint: variant.URL}
+	clock := &steppingClock{now: payloadTestStart}
+	service := newTestServiceWithClock(t, cfg, clock)
+
+	bid := runPayloadTestAuction(t, service, "endpoint-1", "lead-1")
+	assert.Equal(t, "variant-bid", bid.ID)
+	assert.Equal(t, &models.ExperimentAssignment{ID: "send-income", Variant: config.ExperimentVariantTreatment}, bid.PayloadExperiment)
+	assert.Zero(t, configuredCalls.Load())
+
+	// Bids outside the experiment carry no variant, whatever the partner sent
+	bid = runPayloadTestAuction(t, service, "endpoint-2", "")
+	assert.Equal(t, "configured-bid", bid.ID)
+	assert.Nil(t, bid.PayloadExperiment)
+}
+
+// TestPayloadExperimentValidation tests that payload experiments have traffic, an end, and a
+// variant that changes the payload, and only add fields the partner is not already sent
+func TestPayloadExperimentValidation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		modify        func(partner *config.PartnerConfig)
+		expectedError string
+	}{
+		{name: "Valid", modify: func(partner *config.PartnerConfig) {}},
+		{name: "Request Field", modify: func(partner *config.PartnerConfig) {
+			partner.Fields = &config.FieldsPolicy{Mode: config.FieldsPolicyAllow, Fields: []string{"lead_id", "vertical", "user_data"}}
+			partner.PayloadExperiment.Variant.ExtraFields = []string{"geo"}
+		}},
+		{name: "Allowed User Data Key", modify: func(partner *config.PartnerConfig) {
+			partner.Fields = &config.FieldsPolicy{Mode: config.FieldsPolicyAllow, Fields: []string{"lead_id"}, UserData: []string{"zip"}}
+		}},
+		{name: "Missing ID", modify: func(partner *config.PartnerConfig) { partner.PayloadExperiment.ID = "" },
+			expectedError: "missing payload experiment ID"},
+		{name: "No Traffic", modify: func(partner *config.PartnerConfig) { partner.PayloadExperiment.TrafficPercent = 0 },
+			expectedError: "traffic percent must be in (0, 100]"},
+		{name: "No End", modify: func(partner *config.PartnerConfig) { partner.PayloadExperiment.EndsAt = time.Time{} },
+			expectedError: "has no end"},
+		{name: "No Change", modify: func(partner *config.PartnerConfig) { partner.PayloadExperiment.Variant = config.PayloadVariant{} },
+			expectedError: "changes nothing"},
+		{name: "Field Already Sent", modify: func(partner *config.PartnerConfig) {
+			partner.PayloadExperiment.Variant.ExtraFields = []string{"user_data.zip"}
+		}, expectedError: "which the partner is already sent"},
+		{name: "Unknown Field", modify: func(partner *config.PartnerConfig) {
+			partner.PayloadExperiment.Variant.ExtraFields = []string{"bogus"}
+		}, expectedError: `unknown or repeated extra field "bogus"`},
+		{name: "User Data Denied", modify: func(partner *config.PartnerConfig) {
+			partner.Fields = &config.FieldsPolicy{Mode: config.FieldsPolicyDeny, Fields: []string{"user_data"}}
+		}, expectedError: "withholds user_data"},
+		{name: "Format Without Mapping", modify: func(partner *config.PartnerConfig) {
+			partner.PayloadExperiment.Variant = config.PayloadVariant{Format: config.FormatMappedJSON}
+		}, expectedError: "requires a request field mapping"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newPayloadTestConfig("http://partner-1", 25)
+			tc.modify(cfg.Partners["partner-1"])
+			err := cfg.Validate()
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorContains(t, err, tc.expectedError)
+		})
+	}
+}
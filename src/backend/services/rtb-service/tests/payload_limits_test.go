@@ -0,0 +1,204 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// padJSON pads an encoded JSON document with trailing whitespace to exactly size bytes
+func padJSON(t *testing.T, document []byte, size int) []byte {
+	require.LessOrEqual(t, len(document), size)
+	return append(document, bytes.Repeat([]byte(" "), size-len(document))...)
+}
+
+// newPayloadLimitRequest returns a bid request body of exactly bodyBytes whose UserData encodes
+// to exactly userDataBytes
+func newPayloadLimitRequest(t *testing.T, userDataBytes, bodyBytes int) []byte {
+	padding := strings.Repeat("x", userDataBytes-len(`{"pad":""}`))
+	encoded, err := json.Marshal(map[string]interface{}{
+		"request_id": "payload-" + strconv.FormatInt(time.Now().UnixNano(), 36),
+		"lead_id":    "lead-1",
+		"vertical":   "auto",
+		"user_data":  map[string]interface{}{"pad": padding},
+	})
+	require.NoError(t, err)
+	return padJSON(t, encoded, bodyBytes)
+}
+
+// TestInboundPayloadLimits tests that bid requests past the body limit are rejected before they
+// are parsed and that requests within it are rejected past the UserData limit
+func TestInboundPayloadLimits(t *testing.T) {
+	limits := &config.PayloadLimitsConfig{MaxRequestBytes: 2048, MaxUserDataBytes: 512}
+
+	testCases := []struct {
+		name            string
+		userDataBytes   int
+		bodyBytes       int
+		chunked         bool
+		expectedStatus  int
+		expectedPayload string
+	}{
+		{name: "Body At Limit", userDataBytes: 100, bodyBytes: 2048, expectedStatus: http.StatusOK},
+		{name: "Body Over Limit", userDataBytes: 100, bodyBytes: 2049, expectedStatus: http.StatusRequestEntityTooLarge, expectedPayload: "request_body"},
+		{name: "Chunked Body Over Limit", userDataBytes: 100, bodyBytes: 2049, chunked: true, expectedStatus: http.StatusRequestEntityTooLarge, expectedPayload: "request_body"},
+		{name: "User Data At Limit", userDataBytes: 512, bodyBytes: 1024, expectedStatus: http.StatusOK},
+		{name: "User Data Over Limit", userDataBytes: 513, bodyBytes: 1024, expectedStatus: http.StatusRequestEntityTooLarge, expectedPayload: "user_data"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partner := newPartnerServer(t, models.Bid{ID: "bid-1", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+			cfg := newStrategyTestConfig()
+			cfg.MaxBidsPerRequest = 1
+			cfg.PayloadLimits = limits
+			cfg.Partners = map[string]*config.PartnerConfig{
+				"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+			}
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+			router, _ := newTestRouter(t, service, cfg)
+
+			labels := map[string]string{"payload": tc.expectedPayload, "traffic": "live"}
+			before := gatheredMetric(t, "rtb_payloads_too_large_total", labels)
+
+			req := httptest.NewRequest(http.MethodPost, "/v1/bids", bytes.NewReader(newPayloadLimitRequest(t, tc.userDataBytes, tc.bodyBytes)))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.chunked {
+				req.ContentLength = -1
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedPayload != "" {
+				assert.Equal(t, 1.0, gatheredMetric(t, "rtb_payloads_too_large_total", labels)-before)
+			}
+		})
+	}
+}
+
+// TestPartnerResponseLimit tests that partner responses past the partner's size limit fail with
+// the response_too_large reason without being retried, however the partner sends them
+func TestPartnerResponseLimit(t *testing.T) {
+	bid := []byte(`{"id": "big-bid", "price": 5, "click_url": "http://example.com/big"}`)
+
+	testCases := []struct {
+		name          string
+		limit         int64
+		bodyBytes     int
+		gzip          bool
+		chunked       bool
+		expectedError bool
+	}{
+		{name: "At Limit", limit: 1024, bodyBytes: 1024},
+		{name: "Over Limit", limit: 1024, bodyBytes: 1025, expectedError: true},
+		{name: "Chunked Over Limit", limit: 1024, bodyBytes: 1025, chunked: true, expectedError: true},
+		{name: "Gzip At Limit", limit: 1024, bodyBytes: 1024, gzip: true},
+		{name: "Gzip Decoded Over Limit", limit: 1024, bodyBytes: 1025, gzip: true, expectedError: true},
+		{name: "Default Limit", bodyBytes: 4096},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := padJSON(t, append([]byte(nil), bid...), tc.bodyBytes)
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				payload := body
+				if tc.gzip {
+					w.Header().Set("Content-Encoding", "gzip")
+					payload = gzipBytes(t, body)
+				}
+				if !tc.chunked {
+					w.Header().Set("Content-Length", strconv.Itoa(len(payload)))
+					w.Write(payload)
+					return
+				}
+				w.Write(payload[:len(payload)/2])
+				w.(http.Flusher).Flush()
+				w.Write(payload[len(payload)/2:])
+			}))
+			defer server.Close()
+
+			cfg := newStrategyTestConfig()
+			cfg.MaxBidsPerRequest = 1
+			cfg.Partners = map[string]*config.PartnerConfig{
+				"bulky": {ID: "bulky", Endpoint: server.URL, APIKey: "key-bulky", Timeout: 200 * time.Millisecond, Enabled: true,
+					Gzip: tc.gzip, MaxResponseBytes: tc.limit,
+					Retry: &config.RetryPolicy{MaxAttempts: 3, RetryOn: []string{config.RetryOn5xx, config.RetryOnConnectError}}},
+			}
+			require.NoError(t, cfg.Validate())
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			labels := map[string]string{"partner": "bulky"}
+			before := gatheredMetric(t, "rtb_partner_responses_too_large_total", labels)
+			debug := models.NewDebugInfo()
+			ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), 500*time.Millisecond)
+			defer cancel()
+			response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "bulky-" + tc.name, LeadID: "lead-1", Vertical: "auto"})
+
+			assert.Equal(t, int32(1), calls.Load())
+			decisions, _ := debug.Partner("bulky")
+			if !tc.expectedError {
+				require.NoError(t, err)
+				require.Len(t, response.Bids, 1)
+				assert.Equal(t, "big-bid", response.Bids[0].ID)
+				assert.Zero(t, gatheredMetric(t, "rtb_partner_responses_too_large_total", labels)-before)
+				return
+			}
+			assert.ErrorIs(t, err, services.ErrNoValidBids)
+			assert.Contains(t, decisions.Error, services.ReasonResponseTooLarge)
+			assert.Equal(t, 1.0, gatheredMetric(t, "rtb_partner_responses_too_large_total", labels)-before)
+		})
+	}
+}
+
+// TestPayloadLimitsValidation tests the bounds on the inbound and partner response size limits
+func TestPayloadLimitsValidation(t *testing.T) {
+	testCases := []struct {
+		name             string
+		limits           *config.PayloadLimitsConfig
+		maxResponseBytes int64
+		expectedErr      string
+	}{
+		{name: "Defaults"},
+		{name: "Custom Limits", limits: &config.PayloadLimitsConfig{MaxRequestBytes: 1 << 20, MaxUserDataBytes: 1 << 19}, maxResponseBytes: 4 << 20},
+		{name: "Negative Request Limit", limits: &config.PayloadLimitsConfig{MaxRequestBytes: -1}, expectedErr: "max request bytes must be between 0 and"},
+		{name: "User Data Above Request Limit", limits: &config.PayloadLimitsConfig{MaxRequestBytes: 1024, MaxUserDataBytes: 2048}, expectedErr: "max user data bytes must be between 0 and the max request bytes 1024: 2048"},
+		{name: "User Data Above Default Request Limit", limits: &config.PayloadLimitsConfig{MaxUserDataBytes: 1 << 20}, expectedErr: "max user data bytes must be between 0 and the max request bytes"},
+		{name: "Response Limit Too High", maxResponseBytes: 64 << 20, expectedErr: "max response bytes must be between 0 and 16777216 for partner partner-1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.PayloadLimits = tc.limits
+			cfg.Partners["partner-1"].MaxResponseBytes = tc.maxResponseBytes
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+	"go.uber.org/zap"                     // v1.24.0
+	"go.uber.org/zap/zapcore"             // v1.24.0
+	"go.uber.org/zap/zaptest/observer"    // v1.24.0
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// Raw PII values that must never appear outside the partner-call path
+var rawPII = map[string]string{
+	"email": "jane.doe@example.com",
+	"phone": "5551234567",
+	"ssn":   "123-45-6789",
+}
+
+// newPIIPolicy drops, masks, and hashes one field each
+func newPIIPolicy() *config.PIIPolicy {
+	return &config.PIIPolicy{
+		Drop:     []string{"ssn"},
+		Mask:     []string{"phone"},
+		Hash:     []string{"email"},
+		HashSalt: "test-salt",
+	}
+}
+
+// newPIIUserData returns UserData carrying every raw PII value plus a non-personal field
+func newPIIUserData() map[string]interface{} {
+	userData := map[string]interface{}{"zip": "94107"}
+	for field, value := range rawPII {
+		userData[field] = value
+	}
+	return userData
+}
+
+// assertNoRawPII fails if any raw PII value appears verbatim in data
+func assertNoRawPII(t *testing.T, sink string, data []byte) {
+	for field, value := range rawPII {
+		assert.NotContains(t, string(data), value, "%s leaked raw %s", sink, field)
+	}
+}
+
+// TestSanitizeUserData tests the drop, mask, and hash actions
+func TestSanitizeUserData(t *testing.T) {
+	userData := newPIIUserData()
+	sanitized := models.SanitizeUserData(userData, newPIIPolicy())
+
+	assert.NotContains(t, sanitized, "ssn")
+	assert.Equal(t, "******4567", sanitized["phone"])
+	assert.Equal(t, models.HashValue("test-salt", rawPII["email"]), sanitized["email"])
+	assert.Equal(t, "94107", sanitized["zip"])
+
+	// The input map is never modified
+	assert.Equal(t, rawPII["ssn"], userData["ssn"])
+}
+
+// redisValue returns everything stored under key, whatever its type
+func redisValue(t *testing.T, redisServer *miniredis.Miniredis, key string) string {
+	var values []string
+	var err error
+	switch keyType := redisServer.Type(key); keyType {
+	case "string":
+		var value string
+		value, err = redisServer.Get(key)
+		values = []string{value}
+	case "hash":
+		var fields []string
+		fields, err = redisServer.HKeys(key)
+		for _, field := range fields {
+			values = append(values, field, redisServer.HGet(key, field))
+		}
+	case "list":
+		values, err = redisServer.List(key)
+	case "set":
+		values, err = redisServer.Members(key)
+	case "zset":
+		values, err = redisServer.ZMembers(key)
+	default:
+		t.Fatalf("redis key %s has unscanned type %q", key, keyType)
+	}
+	require.NoError(t, err)
+	return strings.Join(values, " ")
+}
+
+// TestPIINeverReachesSinks tests that logs and the Redis-backed recent-auction buffer only see sanitized UserData
+func TestPIINeverReachesSinks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	redisServer := miniredis.RunT(t)
+	host, portText, err := net.SplitHostPort(redisServer.Addr())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portText)
+	require.NoError(t, err)
+
+	partner := newRecordingPartner(t, models.Bid{ID: "bid-1", Price: 8.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer failing.Close()
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.server.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.Redis = &config.RedisConfig{Host: host, Port: port, Timeout: time.Second}
+	cfg.Idempotency = &config.IdempotencyConfig{Enabled: true, Window: time.Minute}
+	cfg.PIIPolicy = newPIIPolicy()
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+
+	core, logs := observer.New(zapcore.DebugLevel)
+	handler.SetLogger(zap.New(core))
+
+	router := gin.New()
+	router.POST("/v1/bids", handler.HandleBidRequest)
+
+	postBid := func(requestID string) int {
+		body, err := json.Marshal(models.BidRequest{RequestID: requestID, LeadID: "lead-1", Vertical: "auto", UserData: newPIIUserData()})
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/bids", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A completed auction is stored in the recent-auction buffer
+	assert.Equal(t, http.StatusOK, postBid("pii-success"))
+
+	// A failed auction is logged
+	cfg.Partners["partner-1"].Endpoint = failing.URL
+	assert.Equal(t, http.StatusNoContent, postBid("pii-failure"))
+
+	// The partner-call path still receives raw values
+	require.NotEmpty(t, partner.received())
+	assert.Equal(t, rawPII["email"], partner.received()[0].UserData["email"])
+
+	keys := redisServer.Keys()
+	require.NotEmpty(t, keys)
+	for _, key := range keys {
+		assertNoRawPII(t, "redis key "+key, []byte(key+redisValue(t, redisServer, key)))
+	}
+
+	require.NotZero(t, logs.Len())
+	for _, entry := range logs.All() {
+		data, err := json.Marshal(entry.ContextMap())
+		require.NoError(t, err)
+		assertNoRawPII(t, "log entry", data)
+	}
+}
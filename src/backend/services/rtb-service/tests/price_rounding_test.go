@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+	"github.com/yourdomain/rtb-service/src/utils"
+)
+
+// TestPriceRounder tests rounding of prices whose float representation is not the decimal they show
+func TestPriceRounder(t *testing.T) {
+	halfUp := &config.PriceRoundingConfig{Precision: 2, Mode: config.RoundingHalfUp}
+	floor := &config.PriceRoundingConfig{Precision: 2, Mode: config.RoundingFloor}
+
+	testCases := []struct {
+		name     string
+		config   *config.PriceRoundingConfig
+		price    float64
+		expected float64
+	}{
+		{name: "Float Noise Above", config: halfUp, price: 10.470000000000001, expected: 10.47},
+		{name: "Float Noise Below Floor", config: floor, price: 10.469999999999999, expected: 10.47},
+		{name: "Tie Half Up", config: halfUp, price: 10.475, expected: 10.48},
+		{name: "Tie Floor", config: floor, price: 10.475, expected: 10.47},
+		{name: "Tie Stored Below Half", config: halfUp, price: 1.005, expected: 1.01},
+		{name: "Tie Scaled Below Half", config: halfUp, price: 2.675, expected: 2.68},
+		{name: "Sum Noise", config: halfUp, price: 0.1 + 0.2, expected: 0.3},
+		{name: "Carry Into Integer", config: halfUp, price: 99.999, expected: 100},
+		{name: "Floor Without Carry", config: floor, price: 99.999, expected: 99.99},
+		{name: "Whole Units Down", config: &config.PriceRoundingConfig{Precision: 0}, price: 10.4, expected: 10},
+		{name: "Whole Units Tie", config: &config.PriceRoundingConfig{Precision: 0}, price: 10.5, expected: 11},
+		{name: "Whole Units Floor", config: &config.PriceRoundingConfig{Precision: 0, Mode: config.RoundingFloor}, price: 10.99, expected: 10},
+		{name: "Three Places", config: &config.PriceRoundingConfig{Precision: 3}, price: 1.0005, expected: 1.001},
+		{name: "Maximum Precision", config: &config.PriceRoundingConfig{Precision: config.MaxPricePrecision}, price: 0.1234565, expected: 0.123457},
+		{name: "Zero", config: halfUp, price: 0, expected: 0},
+		{name: "Below Noise Floor", config: floor, price: 1e-10, expected: 0},
+		{name: "Negative Tie Half Up", config: halfUp, price: -1.005, expected: -1.01},
+		{name: "Negative Floor", config: floor, price: -1.001, expected: -1.01},
+		{name: "Infinity", config: halfUp, price: math.Inf(1), expected: math.Inf(1)},
+		{name: "Default Config", config: nil, price: 10.475, expected: 10.48},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, utils.NewPriceRounder(tc.config).Round(tc.price))
+		})
+	}
+}
+
+// TestPriceRoundingValidation tests price precision and rounding mode limits
+func TestPriceRoundingValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		rounding    *config.PriceRoundingConfig
+		expectedErr string
+	}{
+		{name: "Default"},
+		{name: "Floor", rounding: &config.PriceRoundingConfig{Precision: 2, Mode: config.RoundingFloor}},
+		{name: "Whole Units", rounding: &config.PriceRoundingConfig{Precision: 0, Mode: config.RoundingHalfUp}},
+		{name: "Negative Precision", rounding: &config.PriceRoundingConfig{Precision: -1}, expectedErr: "price precision must be between 0 and 6: -1"},
+		{name: "Precision Too High", rounding: &config.PriceRoundingConfig{Precision: 7}, expectedErr: "price precision must be between 0 and 6: 7"},
+		{name: "Unknown Mode", rounding: &config.PriceRoundingConfig{Precision: 2, Mode: "banker"}, expectedErr: `unknown price rounding mode "banker"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.PriceRounding = tc.rounding
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+// TestClearingPriceRounding tests that winners are ranked at full precision and that the rounded
+// price is what the response and audit log report
+func TestClearingPriceRounding(t *testing.T) {
+	testCases := []struct {
+		name               string
+		rounding           *config.PriceRoundingConfig
+		prices             map[string]string
+		revShare           string
+		expectedWinners    []string
+		expectedPrices     map[string]float64
+		expectedNormalized map[string]float64
+	}{
+		{
+			name: "Ranked Before Rounding", prices: map[string]string{"partner-a": "10.001", "partner-b": "10.004"},
+			expectedWinners: []string{"partner-b", "partner-a"},
+			expectedPrices:  map[string]float64{"partner-a": 10, "partner-b": 10},
+		},
+		{
+			name: "Float Noise", prices: map[string]string{"partner-a": "10.470000000000001", "partner-b": "2.675"},
+			expectedWinners: []string{"partner-a", "partner-b"},
+			expectedPrices:  map[string]float64{"partner-a": 10.47, "partner-b": 2.68},
+		},
+		{
+			name: "Floor", rounding: &config.PriceRoundingConfig{Precision: 2, Mode: config.RoundingFloor},
+			prices:          map[string]string{"partner-a": "10.479", "partner-b": "10.475"},
+			expectedWinners: []string{"partner-a", "partner-b"},
+			expectedPrices:  map[string]float64{"partner-a": 10.47, "partner-b": 10.47},
+		},
+		{
+			// 12.345% of 333 is 41.10885 per lead; the percentage itself is not rounded
+			name: "Revenue Share Half Up", prices: map[string]string{"partner-a": "12.345", "partner-b": "41.1"}, revShare: "partner-a",
+			expectedWinners:    []string{"partner-a", "partner-b"},
+			expectedPrices:     map[string]float64{"partner-a": 12.345, "partner-b": 41.1},
+			expectedNormalized: map[string]float64{"partner-a": 41.11},
+		},
+		{
+			name: "Revenue Share Floor", rounding: &config.PriceRoundingConfig{Precision: 2, Mode: config.RoundingFloor},
+			prices: map[string]string{"partner-a": "12.345", "partner-b": "41.1"}, revShare: "partner-a",
+			expectedWinners:    []string{"partner-a", "partner-b"},
+			expectedPrices:     map[string]float64{"partner-a": 12.345, "partner-b": 41.1},
+			expectedNormalized: map[string]float64{"partner-a": 41.1},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partners := make(map[string]*config.PartnerConfig, len(tc.prices))
+			for id, price := range tc.prices {
+				body := `{"id": "` + id + `-bid", "price": ` + price + `, "quality_score": 0.5, "click_url": "http://` + id + `.example.com/c"}`
+				server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.Write([]byte(body))
+				}))
+				t.Cleanup(server.Close)
+				partners[id] = &config.PartnerConfig{ID: id, Endpoint: server.URL, APIKey: "key-" + id, Timeout: 200 * time.Millisecond, Enabled: true}
+			}
+			if tc.revShare != "" {
+				partners[tc.revShare].PricingModel = config.PricingModelRevShare
+			}
+
+			path := filepath.Join(t.TempDir(), "winning-bids.jsonl")
+			cfg := newTestConfig(partners, withMaxBids(2), withAudit(path))
+			cfg.ExpectedPremiums = map[string]float64{"auto": 333.0}
+			cfg.DeterministicMode = true
+			cfg.PriceRounding = tc.rounding
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "rounding-test", LeadID: "lead-1", Vertical: "auto"})
+			require.NoError(t, err)
+			require.NoError(t, service.Close())
+
+			winners := []string{}
+			clearing := make(map[string]float64, len(response.Bids))
+			for _, bid := range response.Bids {
+				winners = append(winners, bid.PartnerID)
+				assert.Equal(t, tc.expectedPrices[bid.PartnerID], bid.Price, bid.PartnerID)
+				if normalized, exists := tc.expectedNormalized[bid.PartnerID]; exists {
+					assert.Equal(t, normalized, bid.NormalizedPrice, bid.PartnerID)
+				}
+				clearing[bid.PartnerID] = bid.CPL()
+			}
+			assert.Equal(t, tc.expectedWinners, winners)
+
+			records := readAuditRecords(t, path)
+			require.Len(t, records, len(response.Bids))
+			for _, record := range records {
+				assert.Equal(t, clearing[record.PartnerID], record.ClearingPrice, record.PartnerID)
+				assert.Equal(t, tc.expectedPrices[record.PartnerID], record.BidPrice, record.PartnerID)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// TestRevShareNormalization tests auctions mixing cost-per-lead and revenue-share partners
+func TestRevShareNormalization(t *testing.T) {
+	testCases := []struct {
+		name               string
+		revSharePrice      float64
+		vertical           string
+		expectedWinners    []string
+		expectedNormalized map[string]float64
+		expectedSkip       models.Reason
+		expectedCalls      int32
+	}{
+		{
+			name: "Revenue Share Wins", revSharePrice: 12.5, vertical: "auto",
+			expectedWinners: []string{"revshare", "cpl"}, expectedNormalized: map[string]float64{"revshare": 50.0, "cpl": 40.0}, expectedCalls: 1,
+		},
+		{
+			name: "Revenue Share Loses", revSharePrice: 5.0, vertical: "auto",
+			expectedWinners: []string{"cpl", "revshare"}, expectedNormalized: map[string]float64{"cpl": 40.0, "revshare": 20.0}, expectedCalls: 1,
+		},
+		{
+			name: "Revenue Share Above 100 Percent", revSharePrice: 120.0, vertical: "auto",
+			expectedWinners: []string{"cpl"}, expectedNormalized: map[string]float64{"cpl": 40.0}, expectedCalls: 1,
+		},
+		{
+			name: "Missing Expected Premium", revSharePrice: 12.5, vertical: "home",
+			expectedWinners: []string{"cpl"}, expectedNormalized: map[string]float64{"cpl": 40.0}, expectedSkip: "premium_unknown",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var revShareCalls atomic.Int32
+			cplPartner := newPartnerServer(t, models.Bid{ID: "cpl-bid", Price: 40.0, QualityScore: 0.5, ClickURL: "http://cpl.example.com/c"})
+			revSharePartner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				revShareCalls.Add(1)
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"id": "revshare-bid", "price": ` + formatPrice(tc.revSharePrice) + `, "quality_score": 0.5, "click_url": "http://revshare.example.com/c"}`))
+			}))
+			t.Cleanup(revSharePartner.Close)
+
+			cfg := newTestConfig(map[string]*config.PartnerConfig{
+				"cpl":      {ID: "cpl", Endpoint: cplPartner.URL, APIKey: "key-cpl", Timeout: 200 * time.Millisecond, Enabled: true},
+				"revshare": {ID: "revshare", Endpoint: revSharePartner.URL, APIKey: "key-revshare", Timeout: 200 * time.Millisecond, Enabled: true, PricingModel: config.PricingModelRevShare},
+			}, withMaxBids(2))
+			cfg.ExpectedPremiums = map[string]float64{"auto": 400.0}
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+
+			debug := models.NewDebugInfo()
+			ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), 500*time.Millisecond)
+			defer cancel()
+			response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "pricing-test", LeadID: "lead-1", Vertical: tc.vertical})
+			require.NoError(t, err)
+
+			winners := []string{}
+			for _, bid := range response.Bids {
+				winners = append(winners, bid.PartnerID)
+				assert.Equal(t, tc.expectedNormalized[bid.PartnerID], bid.NormalizedPrice, bid.PartnerID)
+				if bid.PartnerID == "revshare" {
+					// The bid keeps the partner's own percentage alongside its cost per lead
+					assert.Equal(t, config.PricingModelRevShare, bid.PricingModel)
+					assert.Equal(t, tc.revSharePrice, bid.Price)
+				} else {
+					assert.Equal(t, config.PricingModelCPL, bid.PricingModel)
+				}
+			}
+			assert.Equal(t, tc.expectedWinners, winners)
+			assert.Equal(t, tc.expectedCalls, revShareCalls.Load())
+
+			partnerDebug, _ := debug.Partner("revshare")
+			assert.Equal(t, tc.expectedSkip, partnerDebug.SkipReason)
+		})
+	}
+}
+
+// TestPricingValidation tests pricing model and expected premium requirements
+func TestPricingValidation(t *testing.T) {
+	testCases := []struct {
+		name         string
+		pricingModel string
+		premiums     map[string]float64
+		expectedErr  string
+	}{
+		{name: "Default Pricing", premiums: map[string]float64{"auto": 400}},
+		{name: "Revenue Share", pricingModel: config.PricingModelRevShare, premiums: map[string]float64{"auto": 400}},
+		{name: "Unknown Pricing Model", pricingModel: "cpa", expectedErr: `unknown pricing model "cpa"`},
+		{name: "Zero Premium", premiums: map[string]float64{"auto": 0}, expectedErr: "expected premium for vertical auto must be greater than 0"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].PricingModel = tc.pricingModel
+			cfg.ExpectedPremiums = tc.premiums
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+// formatPrice formats a price as a JSON number
+func formatPrice(price float64) string {
+	return strconv.FormatFloat(price, 'f', -1, 64)
+}
@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newQuorumTestConfig returns a config whose partners bid when listed in bidding; auto leads need two bidders
+func newQuorumTestConfig(t *testing.T, bidding ...string) *config.Config {
+	partners := make(map[string]*config.PartnerConfig, len(bidding))
+	for _, partnerID := range bidding {
+		server := newPartnerServer(t, models.Bid{ID: partnerID + "-bid", Price: 8.0, QualityScore: 0.5, ClickURL: "http://" + partnerID + ".example.com/c"})
+		partners[partnerID] = &config.PartnerConfig{ID: partnerID, Endpoint: server.URL, APIKey: "key-" + partnerID, Timeout: 200 * time.Millisecond, Enabled: true}
+	}
+
+	cfg := newTestConfig(partners, withMaxBids(2))
+	cfg.MinBidders = map[string]int{"auto": 2}
+	return cfg
+}
+
+// TestAuctionQuorum tests that leads are only sold when enough distinct partners bid
+func TestAuctionQuorum(t *testing.T) {
+	testCases := []struct {
+		name        string
+		vertical    string
+		bidding     []string
+		expectedErr error
+	}{
+		{name: "Quorum Met", vertical: "auto", bidding: []string{"partner-1", "partner-2"}},
+		{name: "Quorum Missed", vertical: "auto", bidding: []string{"partner-1"}, expectedErr: services.ErrInsufficientCompetition},
+		{name: "Vertical Without Quorum", vertical: "home", bidding: []string{"partner-1"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			before := gatheredMetric(t, "rtb_quorum_failures_total", map[string]string{"vertical": tc.vertical})
+			service, err := services.NewAuctionService(newQuorumTestConfig(t, tc.bidding...))
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "quorum-test", LeadID: "lead-1", Vertical: tc.vertical})
+
+			if tc.expectedErr != nil {
+				assert.ErrorIs(t, err, tc.expectedErr)
+				assert.Equal(t, before+1, gatheredMetric(t, "rtb_quorum_failures_total", map[string]string{"vertical": tc.vertical}))
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, response.Bids, len(tc.bidding))
+			assert.Equal(t, before, gatheredMetric(t, "rtb_quorum_failures_total", map[string]string{"vertical": tc.vertical}))
+		})
+	}
+}
+
+// TestQuorumFailureResponse tests the HTTP and stream responses for an auction that missed its quorum
+func TestQuorumFailureResponse(t *testing.T) {
+	testCases := []struct {
+		name           string
+		failureStatus  int
+		expectedStatus int
+	}{
+		{name: "Default Empty Winners", expectedStatus: http.StatusOK},
+		{name: "Configured Empty Winners", failureStatus: http.StatusOK, expectedStatus: http.StatusOK},
+		{name: "Configured No Content", failureStatus: http.StatusNoContent, expectedStatus: http.StatusNoContent},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newQuorumTestConfig(t, "partner-1")
+			cfg.QuorumFailureStatus = tc.failureStatus
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			router, handler := newTestRouter(t, service, cfg)
+			router.POST("/v1/bids/stream", handler.HandleBidStream)
+
+			body, err := json.Marshal(models.BidRequest{RequestID: "quorum-" + tc.name, LeadID: "lead-1", Vertical: "auto"})
+			require.NoError(t, err)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodPost, "/v1/bids", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tc.expectedStatus, w.Code)
+			if tc.expectedStatus == http.StatusOK {
+				var response models.BidResponse
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.Empty(t, response.Bids)
+				assert.Equal(t, models.ReasonInsufficientCompetition, response.Reason)
+			}
+
+			// The stream ends with an empty winners event rather than the no-bid error event
+			w = httptest.NewRecorder()
+			req, _ = http.NewRequest(http.MethodPost, "/v1/bids/stream", bytes.NewBuffer(body))
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Contains(t, w.Body.String(), "event:winners")
+			assert.Contains(t, w.Body.String(), models.ReasonInsufficientCompetition)
+			assert.NotContains(t, w.Body.String(), "event:error")
+		})
+	}
+}
+
+// TestQuorumValidation tests min bidder and quorum failure status requirements
+func TestQuorumValidation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		minBidders    map[string]int
+		failureStatus int
+		expectedErr   string
+	}{
+		{name: "Valid Quorum", minBidders: map[string]int{"auto": 2, config.DefaultStrategyKey: 1}, failureStatus: http.StatusNoContent},
+		{name: "Zero Bidders", minBidders: map[string]int{"auto": 0}, expectedErr: "min bidders must be at least 1"},
+		{name: "Invalid Status", failureStatus: http.StatusNotFound, expectedErr: "quorum failure status must be 200 or 204"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.MinBidders = tc.minBidders
+			cfg.QuorumFailureStatus = tc.failureStatus
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"                       // v1.9.1
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+	"github.com/stretchr/testify/assert"             // v1.8.4
+	"github.com/stretchr/testify/require"            // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// TestReasonsHandler tests that the reasons endpoint lists the registry, optionally by kind
+func TestReasonsHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	service, cfg := newDrainTestService(t, &steppingClock{now: time.Now()}, time.Time{})
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	router := gin.New()
+	router.GET("/v1/reasons", handler.HandleReasons)
+
+	testCases := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedKinds  []models.ReasonKind
+		expectedReason models.Reason
+	}{
+		{name: "All Reasons", expectedStatus: http.StatusOK,
+			expectedKinds:  []models.ReasonKind{models.ReasonKindAuction, models.ReasonKindCallError, models.ReasonKindLoss, models.ReasonKindNoBid, models.ReasonKindSelection, models.ReasonKindSkip},
+			expectedReason: models.ReasonDraining},
+		{name: "Skip Reasons", query: "?kind=skip", expectedStatus: http.StatusOK,
+			expectedKinds: []models.ReasonKind{models.ReasonKindSkip}, expectedReason: models.ReasonQPSCapped},
+		{name: "Loss Reasons", query: "?kind=loss", expectedStatus: http.StatusOK,
+			expectedKinds: []models.ReasonKind{models.ReasonKindLoss}, expectedReason: models.ReasonManualOverrideFloor},
+		{name: "Unknown Kind", query: "?kind=penalty", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/reasons"+tc.query, nil))
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response struct {
+				Reasons []models.ReasonInfo `json:"reasons"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			kinds := []models.ReasonKind{}
+			listed := map[models.Reason]bool{}
+			for _, info := range response.Reasons {
+				if len(kinds) == 0 || kinds[len(kinds)-1] != info.Kind {
+					kinds = append(kinds, info.Kind)
+				}
+				listed[info.Reason] = true
+				assert.NotEmpty(t, info.Description, info.Reason)
+			}
+			assert.Equal(t, tc.expectedKinds, kinds)
+			assert.True(t, listed[tc.expectedReason], tc.expectedReason)
+		})
+	}
+}
+
+// TestEmittedReasonsRegistered tests that every reason in the skip, loss, and no-bid metrics and
+// in debug output is registered
+func TestEmittedReasonsRegistered(t *testing.T) {
+	router, service := newOverrideTestRouter(t, &steppingClock{now: time.Now()})
+	admin := map[string]string{"X-Admin-Key": testAdminKey}
+
+	testCases := []struct {
+		name            string
+		headers         map[string]string
+		drain           bool
+		expectedPartner string
+	}{
+		{name: "Loss", headers: map[string]string{"X-Admin-Key": testAdminKey, "X-RTB-Auction-Params": `{"floor": 8}`}, expectedPartner: "partner-2"},
+		{name: "Skip", headers: admin, drain: true, expectedPartner: "partner-2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.drain {
+				require.NoError(t, service.DrainPartner(tc.expectedPartner, time.Now().Add(time.Hour), "reasons test"))
+			}
+			w := serveOverrideTest(router, http.MethodPost, "/v1/bids?debug=true", `{"lead_id": "lead-1", "vertical": "auto"}`, tc.headers)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var response struct {
+				Debug *models.DebugInfo `json:"debug"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.NotNil(t, response.Debug)
+			partner, exists := response.Debug.Partner(tc.expectedPartner)
+			require.True(t, exists)
+			emitted := []models.Reason{partner.SkipReason}
+			for _, reason := range partner.Losses {
+				emitted = append(emitted, reason)
+			}
+			require.NotEqual(t, []models.Reason{""}, emitted)
+			for _, reason := range emitted {
+				if reason != "" {
+					assert.True(t, reason.IsValid(), reason)
+				}
+			}
+		})
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	metrics := map[string]bool{"rtb_partner_skips_total": true, "rtb_bid_losses_total": true, "rtb_no_bid_total": true, "rtb_partner_call_errors_total": true}
+	for _, family := range families {
+		if !metrics[family.GetName()] {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			for _, pair := range metric.GetLabel() {
+				if pair.GetName() == "reason" {
+					assert.True(t, models.Reason(pair.GetValue()).IsValid(), family.GetName()+" reason "+pair.GetValue())
+				}
+			}
+		}
+	}
+	assert.Positive(t, gatheredMetric(t, "rtb_partner_skips_total", map[string]string{"reason": string(models.ReasonDraining)}))
+	assert.Positive(t, gatheredMetric(t, "rtb_bid_losses_total", map[string]string{"reason": string(models.ReasonManualOverrideFloor)}))
+}
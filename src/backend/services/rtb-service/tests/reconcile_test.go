@@ -0,0 +1,382 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/audit"
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/reconcile"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// reconcileWindow is the three fixture days, March 1 to 3 2024
+var reconcileWindow = reconcile.Window{From: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)}
+
+// readPartnerCSV parses a partner CSV fixture
+func readPartnerCSV(t *testing.T, name string) []reconcile.PartnerRecord {
+	file, err := os.Open("testdata/reconcile/" + name)
+	require.NoError(t, err)
+	defer file.Close()
+	records, err := reconcile.ParseCSV(file)
+	require.NoError(t, err)
+	return records
+}
+
+// fixtureWins returns partner-1's fixture audit records in window
+func fixtureWins(t *testing.T, window reconcile.Window) []audit.Record {
+	var wins []audit.Record
+	for _, record := range readAuditRecords(t, "testdata/reconcile/audit.jsonl") {
+		if record.PartnerID == "partner-1" && window.Contains(record.Timestamp) {
+			wins = append(wins, record)
+		}
+	}
+	return wins
+}
+
+// newReconcileTestService returns a service whose audit log starts as the fixture audit log
+// followed by tail, and its config
+func newReconcileTestService(t *testing.T, tail string) (*services.AuctionService, *config.Config) {
+	fixture, err := os.ReadFile("testdata/reconcile/audit.jsonl")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "winning-bids.jsonl")
+	require.NoError(t, os.WriteFile(path, append(fixture, tail...), 0o644))
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{}, withAdmin(), withAudit(path))
+	service := newTestService(t, cfg)
+	return service, cfg
+}
+
+// TestParseCSV tests header aliases, value formats, and the line numbers of malformed partner CSVs
+func TestParseCSV(t *testing.T) {
+	t.Run("Fixture", func(t *testing.T) {
+		records := readPartnerCSV(t, "partner_clean.csv")
+		require.Len(t, records, 7)
+		// The byte order mark, upper case headers, price alias, and unknown column are handled
+		assert.Equal(t, reconcile.PartnerRecord{
+			Line: 2, BidID: "b-1", RequestID: "req-1", Timestamp: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), Amount: 12.505, Vertical: "auto",
+		}, records[0])
+		assert.Equal(t, 8.25, records[1].Amount)
+		// The blank line is skipped but still counted
+		assert.Equal(t, 7, records[4].Line)
+		assert.Equal(t, "req-5", records[4].RequestID)
+
+		records = readPartnerCSV(t, "partner_discrepancies.csv")
+		require.Len(t, records, 9)
+		assert.Equal(t, time.Date(2024, 3, 1, 9, 0, 5, 0, time.UTC), records[0].Timestamp)
+		assert.Equal(t, time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC), records[7].Timestamp)
+
+		records = readPartnerCSV(t, "partner_request_ids.csv")
+		require.Len(t, records, 4)
+		assert.Empty(t, records[0].BidID)
+		assert.Equal(t, time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC), records[0].Timestamp)
+	})
+
+	testCases := []struct {
+		name        string
+		csv         string
+		expectedLen int
+		expectedErr string
+	}{
+		{name: "Zoned Timestamp", csv: "bid_id,timestamp,amount\nb-1,2024-03-01T09:00:00-05:00,1\n", expectedLen: 1},
+		{name: "Short Row", csv: "bid_id,amount,date,vertical\nb-1,1,2024-03-01\n", expectedLen: 1},
+		{name: "Header Only", csv: "bid_id,date,amount\n", expectedLen: 0},
+		{name: "Empty", csv: "", expectedErr: "line 1: missing header"},
+		{name: "No ID Column", csv: "lead,date,amount\nl-1,2024-03-01,1\n", expectedErr: "line 1: header needs a bid_id or request_id column"},
+		{name: "No Date Column", csv: "bid_id,amount\nb-1,1\n", expectedErr: "line 1: header needs a date or timestamp column"},
+		{name: "No Amount Column", csv: "bid_id,date\nb-1,2024-03-01\n", expectedErr: "line 1: header needs an amount column"},
+		{name: "Two Amount Columns", csv: "bid_id,date,amount,price\nb-1,2024-03-01,1,1\n", expectedErr: "line 1: more than one amount column"},
+		{name: "Row Without IDs", csv: "bid_id,request_id,date,amount\nb-1,,2024-03-01,1\n,,2024-03-01,1\n", expectedErr: "line 3: needs a bid_id or request_id"},
+		{name: "Invalid Date", csv: "bid_id,date,amount\nb-1,2024-03-01,1\nb-2,03/01/2024,1\n", expectedErr: `line 3: invalid date "03/01/2024"`},
+		{name: "Missing Date", csv: "bid_id,date,amount\nb-1,,1\n", expectedErr: "line 2: missing date"},
+		{name: "Missing Amount", csv: "bid_id,date,amount\nb-1,2024-03-01,\n", expectedErr: "line 2: missing amount"},
+		{name: "Invalid Amount", csv: "bid_id,date,amount\nb-1,2024-03-01,ten\n", expectedErr: `line 2: invalid amount "ten"`},
+		{name: "Negative Amount", csv: "bid_id,date,amount\nb-1,2024-03-01,-1\n", expectedErr: `line 2: invalid amount "-1"`},
+		{name: "Unterminated Quote", csv: "bid_id,date,amount\nb-1,2024-03-01,1\n\"b-2,2024-03-01,1\n", expectedErr: "line 3:"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			records, err := reconcile.ParseCSV(strings.NewReader(tc.csv))
+			if tc.expectedErr != "" {
+				require.Error(t, err)
+				var parseErr *reconcile.ParseError
+				assert.ErrorAs(t, err, &parseErr)
+				assert.Contains(t, err.Error(), tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Len(t, records, tc.expectedLen)
+		})
+	}
+}
+
+// TestReconcile tests matching and discrepancy classification of the partner CSV fixtures against
+// the audit log fixture
+func TestReconcile(t *testing.T) {
+	t.Run("Clean", func(t *testing.T) {
+		// b-1 is half a cent over, within the tolerance
+		result := reconcile.Reconcile("partner-1", reconcileWindow, fixtureWins(t, reconcileWindow), readPartnerCSV(t, "partner_clean.csv"), reconcile.DefaultTolerance)
+		assert.Equal(t, 7, result.Matched)
+		assert.Empty(t, result.Days)
+		assert.Equal(t, 68.85, result.OurAmount)
+		assert.Equal(t, 68.855, result.PartnerAmount)
+
+		// Without a tolerance the half cent is a mismatch
+		result = reconcile.Reconcile("partner-1", reconcileWindow, fixtureWins(t, reconcileWindow), readPartnerCSV(t, "partner_clean.csv"), 0)
+		require.Len(t, result.Days, 1)
+		assert.Equal(t, []reconcile.Discrepancy{{
+			Kind: reconcile.DiscrepancyAmountMismatch, BidID: "b-1", RequestID: "req-1", Line: 2, MatchedBy: reconcile.MatchedByBidID,
+			OurAmount: 12.5, PartnerAmount: 12.505, Difference: 0.005,
+		}}, result.Days[0].Discrepancies)
+	})
+
+	t.Run("Discrepancies", func(t *testing.T) {
+		result := reconcile.Reconcile("partner-1", reconcileWindow, fixtureWins(t, reconcileWindow), readPartnerCSV(t, "partner_discrepancies.csv"), reconcile.DefaultTolerance)
+		assert.Equal(t, &reconcile.Result{
+			PartnerID:      "partner-1",
+			From:           reconcileWindow.From,
+			To:             reconcileWindow.To,
+			Tolerance:      reconcile.DefaultTolerance,
+			OurWins:        7,
+			OurAmount:      68.85,
+			PartnerRecords: 8,
+			PartnerAmount:  76,
+			Difference:     7.15,
+			// b-1, b-2, both b-reused, their-5 by request ID, and b-7
+			Matched:       6,
+			OutsideWindow: 1,
+			Days: []reconcile.DayResult{
+				{
+					Day: "2024-03-01", OurWins: 3, OurAmount: 30.75, PartnerRecords: 4, PartnerAmount: 41, Difference: 10.25,
+					Discrepancies: []reconcile.Discrepancy{
+						{Kind: reconcile.DiscrepancyAmountMismatch, BidID: "b-2", RequestID: "req-2", Line: 3, MatchedBy: reconcile.MatchedByBidID, OurAmount: 8.25, PartnerAmount: 9.25, Difference: 1},
+						{Kind: reconcile.DiscrepancyDuplicate, BidID: "b-2", RequestID: "req-2", Line: 6, MatchedBy: reconcile.MatchedByBidID, PartnerAmount: 9.25, Difference: 9.25},
+					},
+				},
+				{
+					Day: "2024-03-02", OurWins: 3, OurAmount: 33.1, PartnerRecords: 3, PartnerAmount: 30, Difference: -3.1,
+					Discrepancies: []reconcile.Discrepancy{
+						{Kind: reconcile.DiscrepancyMissingFromOurs, BidID: "b-99", RequestID: "req-99", Line: 8, PartnerAmount: 4, Difference: 4},
+						{Kind: reconcile.DiscrepancyMissingFromPartner, BidID: "b-6", RequestID: "req-6", OurAmount: 7.1, Difference: -7.1},
+					},
+				},
+			},
+		}, result)
+	})
+
+	t.Run("Request IDs Only", func(t *testing.T) {
+		window := reconcile.Window{From: reconcileWindow.From, To: reconcileWindow.From.AddDate(0, 0, 1)}
+		result := reconcile.Reconcile("partner-1", window, fixtureWins(t, window), readPartnerCSV(t, "partner_request_ids.csv"), reconcile.DefaultTolerance)
+		assert.Equal(t, 2, result.Matched)
+		require.Len(t, result.Days, 1)
+		assert.Equal(t, []reconcile.Discrepancy{
+			{Kind: reconcile.DiscrepancyDuplicate, BidID: "b-reused", RequestID: "req-3", Line: 4, MatchedBy: reconcile.MatchedByRequestID, PartnerAmount: 10, Difference: 10},
+			{Kind: reconcile.DiscrepancyMissingFromOurs, RequestID: "req-8", Line: 5, PartnerAmount: 6, Difference: 6},
+			{Kind: reconcile.DiscrepancyMissingFromPartner, BidID: "b-2", RequestID: "req-2", OurAmount: 8.25, Difference: -8.25},
+		}, result.Days[0].Discrepancies)
+	})
+}
+
+// TestReconcileMatching tests the order of bid ID and request ID matching
+func TestReconcileMatching(t *testing.T) {
+	day := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	window := reconcile.Window{From: day.Add(-12 * time.Hour), To: day.Add(12 * time.Hour)}
+	win := func(requestID, bidID string) audit.Record {
+		return audit.Record{Timestamp: day, RequestID: requestID, PartnerID: "partner-1", BidID: bidID, ClearingPrice: 5}
+	}
+	row := func(line int, requestID, bidID string) reconcile.PartnerRecord {
+		return reconcile.PartnerRecord{Line: line, Timestamp: day, RequestID: requestID, BidID: bidID, Amount: 5}
+	}
+
+	testCases := []struct {
+		name          string
+		wins          []audit.Record
+		records       []reconcile.PartnerRecord
+		expectedKinds []string
+		expectedBy    []string
+	}{
+		{
+			name:       "Reused Bid ID Without Request IDs",
+			wins:       []audit.Record{win("req-1", "b-1"), win("req-2", "b-1")},
+			records:    []reconcile.PartnerRecord{row(2, "", "b-1"), row(3, "", "b-1")},
+			expectedBy: []string{reconcile.MatchedByBidID, reconcile.MatchedByBidID},
+		},
+		{
+			name:          "Reused Bid ID Listed Three Times",
+			wins:          []audit.Record{win("req-1", "b-1"), win("req-2", "b-1")},
+			records:       []reconcile.PartnerRecord{row(2, "", "b-1"), row(3, "", "b-1"), row(4, "", "b-1")},
+			expectedKinds: []string{reconcile.DiscrepancyDuplicate},
+		},
+		{
+			name:          "Bid ID Of Another Request",
+			wins:          []audit.Record{win("req-1", "b-1")},
+			records:       []reconcile.PartnerRecord{row(2, "req-2", "b-1")},
+			expectedKinds: []string{reconcile.DiscrepancyMissingFromOurs, reconcile.DiscrepancyMissingFromPartner},
+		},
+		{
+			// The fallback runs after every bid ID match, so row 2 cannot take the win row 3 names
+			name:       "Fallback After Bid IDs",
+			wins:       []audit.Record{win("req-1", "b-1"), win("req-1", "b-2")},
+			records:    []reconcile.PartnerRecord{row(2, "req-1", "their-1"), row(3, "req-1", "b-1")},
+			expectedBy: []string{reconcile.MatchedByRequestID, reconcile.MatchedByBidID},
+		},
+		{
+			name:          "Fallback Exhausted",
+			wins:          []audit.Record{win("req-1", "b-1")},
+			records:       []reconcile.PartnerRecord{row(2, "req-1", "b-1"), row(3, "req-1", "their-1")},
+			expectedKinds: []string{reconcile.DiscrepancyDuplicate},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := reconcile.Reconcile("partner-1", window, tc.wins, tc.records, reconcile.DefaultTolerance)
+			var kinds []string
+			for _, dayResult := range result.Days {
+				for _, discrepancy := range dayResult.Discrepancies {
+					kinds = append(kinds, discrepancy.Kind)
+				}
+			}
+			assert.Equal(t, tc.expectedKinds, kinds)
+			if tc.expectedBy != nil {
+				assert.Equal(t, len(tc.expectedBy), result.Matched)
+			}
+		})
+	}
+}
+
+// TestParseReportWindow tests report window bounds
+func TestParseReportWindow(t *testing.T) {
+	testCases := []struct {
+		name        string
+		from        string
+		to          string
+		expected    reconcile.Window
+		expectedErr bool
+	}{
+		{name: "Dates", from: "2024-03-01", to: "2024-03-03", expected: reconcileWindow},
+		{name: "Single Day", from: "2024-03-01", to: "2024-03-01", expected: reconcile.Window{From: reconcileWindow.From, To: reconcileWindow.From.AddDate(0, 0, 1)}},
+		{
+			name: "Timestamps", from: "2024-03-01T06:00:00Z", to: "2024-03-01T12:00:00-05:00",
+			expected: reconcile.Window{From: time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC), To: time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)},
+		},
+		{name: "Missing From", to: "2024-03-01", expectedErr: true},
+		{name: "Invalid To", from: "2024-03-01", to: "March 3", expectedErr: true},
+		{name: "Empty Window", from: "2024-03-01T06:00:00Z", to: "2024-03-01T06:00:00Z", expectedErr: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			window, err := reconcile.ParseWindow(tc.from, tc.to)
+			if tc.expectedErr {
+				assert.ErrorIs(t, err, reconcile.ErrInvalidWindow)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tc.expected.From.Equal(window.From), window.From)
+			assert.True(t, tc.expected.To.Equal(window.To), window.To)
+		})
+	}
+}
+
+// TestPartnerSpend tests spend buckets read from the audit log, skipping a partly written last line
+func TestPartnerSpend(t *testing.T) {
+	service, _ := newReconcileTestService(t, `{"timestamp":"2024-03-03T01:00:00Z","request_id":"req-`)
+
+	report, err := service.PartnerSpend("partner-1", reconcileWindow)
+	require.NoError(t, err)
+	assert.Equal(t, 7, report.Wins)
+	assert.Equal(t, 68.85, report.Spend)
+	assert.Equal(t, []reconcile.SpendBucket{
+		{Day: "2024-03-01", Vertical: "auto", Wins: 2, Spend: 22.5},
+		{Day: "2024-03-01", Vertical: "home", Wins: 1, Spend: 8.25},
+		{Day: "2024-03-02", Vertical: "auto", Wins: 2, Spend: 26},
+		{Day: "2024-03-02", Vertical: "home", Wins: 1, Spend: 7.1},
+		{Day: "2024-03-03", Vertical: "auto", Wins: 1, Spend: 5},
+	}, report.Buckets)
+
+	report, err = service.PartnerSpend("partner-2", reconcile.Window{From: reconcileWindow.From, To: reconcileWindow.From.AddDate(0, 0, 1)})
+	require.NoError(t, err)
+	assert.Equal(t, []reconcile.SpendBucket{{Day: "2024-03-01", Vertical: "auto", Wins: 1, Spend: 20}}, report.Buckets)
+	// The window is half-open, so req-6 at 23:59:59 is in and req-7 at midnight is out
+	report, err = service.PartnerSpend("partner-1", reconcile.Window{From: reconcileWindow.From, To: time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)})
+	require.NoError(t, err)
+	assert.Equal(t, 6, report.Wins)
+
+	// A malformed line before the end is not skipped
+	broken, _ := newReconcileTestService(t, "not json\n"+`{"timestamp":"2024-03-03T02:00:00Z","partner_id":"partner-1"}`+"\n")
+	_, err = broken.PartnerSpend("partner-1", reconcileWindow)
+	assert.Error(t, err)
+
+	disabled, err := services.NewAuctionService(&config.Config{BidTimeout: 500 * time.Millisecond, MaxBidsPerRequest: 1, Partners: map[string]*config.PartnerConfig{}})
+	require.NoError(t, err)
+	defer disabled.Close()
+	_, err = disabled.PartnerSpend("partner-1", reconcileWindow)
+	assert.ErrorIs(t, err, services.ErrReconciliationDisabled)
+}
+
+// TestReconciliationEndpoints tests authentication, query and CSV validation, and the window taken
+// from the CSV by the report endpoints
+func TestReconciliationEndpoints(t *testing.T) {
+	service, cfg := newReconcileTestService(t, "")
+	handler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	router := gin.New()
+	router.GET("/v1/reports/partner-spend", handler.HandlePartnerSpend)
+	router.POST("/v1/reports/reconcile", handler.HandleReconcile)
+
+	discrepancies, err := os.ReadFile("testdata/reconcile/partner_discrepancies.csv")
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name             string
+		method           string
+		target           string
+		body             string
+		adminKey         string
+		expectedStatus   int
+		expectedContains string
+	}{
+		{name: "Spend", method: http.MethodGet, target: "/v1/reports/partner-spend?partner=partner-1&from=2024-03-01&to=2024-03-03", adminKey: testAdminKey, expectedStatus: http.StatusOK, expectedContains: `"spend":68.85`},
+		{name: "Spend Without Admin Key", method: http.MethodGet, target: "/v1/reports/partner-spend?partner=partner-1&from=2024-03-01&to=2024-03-03", expectedStatus: http.StatusUnauthorized},
+		{name: "Spend Without Partner", method: http.MethodGet, target: "/v1/reports/partner-spend?from=2024-03-01&to=2024-03-03", adminKey: testAdminKey, expectedStatus: http.StatusBadRequest, expectedContains: "partner is required"},
+		{name: "Spend Without Window", method: http.MethodGet, target: "/v1/reports/partner-spend?partner=partner-1", adminKey: testAdminKey, expectedStatus: http.StatusBadRequest, expectedContains: "from and to are required"},
+		{
+			// The window comes from the CSV, so b-0 on February 29 is missing from ours
+			name: "Reconcile", method: http.MethodPost, target: "/v1/reports/reconcile?partner=partner-1", body: string(discrepancies), adminKey: testAdminKey,
+			expectedStatus: http.StatusOK, expectedContains: `"day":"2024-02-29"`,
+		},
+		{
+			name: "Reconcile Window", method: http.MethodPost, target: "/v1/reports/reconcile?partner=partner-1&from=2024-03-01&to=2024-03-03&tolerance=20", body: string(discrepancies), adminKey: testAdminKey,
+			expectedStatus: http.StatusOK, expectedContains: `"outside_window":1`,
+		},
+		{name: "Reconcile Without Admin Key", method: http.MethodPost, target: "/v1/reports/reconcile?partner=partner-1", body: string(discrepancies), expectedStatus: http.StatusUnauthorized},
+		{name: "Invalid Tolerance", method: http.MethodPost, target: "/v1/reports/reconcile?partner=partner-1&tolerance=-1", body: string(discrepancies), adminKey: testAdminKey, expectedStatus: http.StatusBadRequest},
+		{name: "Malformed CSV", method: http.MethodPost, target: "/v1/reports/reconcile?partner=partner-1", body: "bid_id,date,amount\nb-1,2024-03-01,ten\n", adminKey: testAdminKey, expectedStatus: http.StatusBadRequest, expectedContains: "line 2"},
+		{name: "Empty CSV", method: http.MethodPost, target: "/v1/reports/reconcile?partner=partner-1", body: "bid_id,date,amount\n", adminKey: testAdminKey, expectedStatus: http.StatusBadRequest, expectedContains: "from and to are required"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			if tc.adminKey != "" {
+				req.Header.Set("X-Admin-Key", tc.adminKey)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			assert.Contains(t, w.Body.String(), tc.expectedContains)
+		})
+	}
+}
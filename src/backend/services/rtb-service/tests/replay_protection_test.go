@@ -0,0 +1,235 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+)
+
+// Replay protection test API keys: one opted-in client and one that is not
+const (
+	replayTestKey      = "lead-seller-key"
+	replayTestOtherKey = "unprotected-key"
+)
+
+// newReplayProtectionTestConfig returns a config with one bidding partner and the lead-seller client opted
+// in to replay protection with the default clock skew
+func newReplayProtectionTestConfig(t *testing.T, failOpen bool) *config.Config {
+	partner := newPartnerServer(t, models.Bid{ID: "replay-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/replay"})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.ReplayProtection = &config.ReplayProtectionConfig{
+		FailOpen: failOpen,
+		Clients:  map[string]*config.ReplayClient{"lead-seller": {APIKeys: []string{replayTestKey}}},
+	}
+	return cfg
+}
+
+// newReplayTestRouter returns a router serving replay-protected auctions from a service reading
+// time from clock
+func newReplayTestRouter(t *testing.T, cfg *config.Config, clock *steppingClock) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	service := newTestServiceWithClock(t, cfg, clock)
+	bidHandler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.POST("/v1/bids", bidHandler.ReplayProtection(), bidHandler.HandleBidRequest)
+	return router
+}
+
+// serveReplayTest sends an auction request with the given API key, timestamp, and nonce, leaving
+// out empty headers
+func serveReplayTest(router *gin.Engine, apiKey string, timestamp time.Time, nonce string) *httptest.ResponseRecorder {
+	headers := map[string]string{"X-API-Key": apiKey}
+	if !timestamp.IsZero() {
+		headers["X-RTB-Timestamp"] = strconv.FormatInt(timestamp.Unix(), 10)
+	}
+	if nonce != "" {
+		headers["X-RTB-Nonce"] = nonce
+	}
+	return serveOverrideTest(router, http.MethodPost, "/v1/bids", `{"lead_id": "lead-1", "vertical": "auto"}`, headers)
+}
+
+// replayErrorCode returns the code of an error response
+func replayErrorCode(t *testing.T, w *httptest.ResponseRecorder) string {
+	var body struct {
+		Code string `json:"code"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+	return body.Code
+}
+
+// TestReplayProtectionTimestamps tests that opted-in clients need both headers and a timestamp
+// within the clock skew, inclusive, in either direction, and that other clients are not checked
+func TestReplayProtectionTimestamps(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	skew := config.DefaultReplayMaxSkew
+
+	testCases := []struct {
+		name            string
+		apiKey          string
+		timestamp       time.Time
+		nonce           string
+		expectedStatus  int
+		expectedCode    string
+		expectedOutcome string
+	}{
+		{name: "Current", apiKey: replayTestKey, timestamp: now, nonce: "n-1", expectedStatus: http.StatusOK, expectedOutcome: "accepted"},
+		{name: "At Skew Past", apiKey: replayTestKey, timestamp: now.Add(-skew), nonce: "n-1", expectedStatus: http.StatusOK, expectedOutcome: "accepted"},
+		{name: "At Skew Future", apiKey: replayTestKey, timestamp: now.Add(skew), nonce: "n-1", expectedStatus: http.StatusOK, expectedOutcome: "accepted"},
+		{name: "Beyond Skew Past", apiKey: replayTestKey, timestamp: now.Add(-skew - time.Second), nonce: "n-1", expectedStatus: http.StatusUnauthorized, expectedCode: "replay_timestamp_skew", expectedOutcome: "skewed"},
+		{name: "Beyond Skew Future", apiKey: replayTestKey, timestamp: now.Add(skew + time.Second), nonce: "n-1", expectedStatus: http.StatusUnauthorized, expectedCode: "replay_timestamp_skew", expectedOutcome: "skewed"},
+		{name: "Missing Timestamp", apiKey: replayTestKey, nonce: "n-1", expectedStatus: http.StatusUnauthorized, expectedCode: "replay_headers_missing", expectedOutcome: "missing"},
+		{name: "Missing Nonce", apiKey: replayTestKey, timestamp: now, expectedStatus: http.StatusUnauthorized, expectedCode: "replay_headers_missing", expectedOutcome: "missing"},
+		{name: "Other Client", apiKey: replayTestOtherKey, expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router := newReplayTestRouter(t, newReplayProtectionTestConfig(t, false), &steppingClock{now: now})
+			labels := map[string]string{"client": "lead-seller", "outcome": tc.expectedOutcome}
+			before := gatheredMetric(t, "rtb_replay_checks_total", labels)
+
+			w := serveReplayTest(router, tc.apiKey, tc.timestamp, tc.nonce)
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedCode != "" {
+				assert.Equal(t, tc.expectedCode, replayErrorCode(t, w))
+			}
+			if tc.expectedOutcome != "" {
+				assert.Equal(t, 1.0, gatheredMetric(t, "rtb_replay_checks_total", labels)-before)
+			}
+		})
+	}
+}
+
+// TestReplayProtectionMalformedTimestamp tests that timestamps other than Unix seconds are refused
+func TestReplayProtectionMalformedTimestamp(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	router := newReplayTestRouter(t, newReplayProtectionTestConfig(t, false), &steppingClock{now: now})
+
+	w := serveOverrideTest(router, http.MethodPost, "/v1/bids", `{"lead_id": "lead-1", "vertical": "auto"}`,
+		map[string]string{"X-API-Key": replayTestKey, "X-RTB-Timestamp": now.Format(time.RFC3339), "X-RTB-Nonce": "n-1"})
+	require.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+	assert.Equal(t, "replay_headers_missing", replayErrorCode(t, w))
+}
+
+// TestReplayProtectionNonces tests that a nonce is refused while it is remembered, across
+// instances when they share Redis, that nonces are scoped to their client, and that a nonce is
+// accepted again once the replay window has passed
+func TestReplayProtectionNonces(t *testing.T) {
+	for _, store := range []string{"Memory", "Redis"} {
+		t.Run(store, func(t *testing.T) {
+			clock := &steppingClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+			cfg := newReplayProtectionTestConfig(t, false)
+			cfg.ReplayProtection.Clients["broker"] = &config.ReplayClient{APIKeys: []string{"broker-key"}}
+			var redisServer *miniredis.Miniredis
+			if store == "Redis" {
+				redisServer = miniredis.RunT(t)
+				useTestRedis(t, cfg, redisServer)
+			}
+			router := newReplayTestRouter(t, cfg, clock)
+
+			w := serveReplayTest(router, replayTestKey, clock.now, "n-1")
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			before := gatheredMetric(t, "rtb_replay_checks_total", map[string]string{"client": "lead-seller", "outcome": "replayed"})
+			w = serveReplayTest(router, replayTestKey, clock.now, "n-1")
+			require.Equal(t, http.StatusUnauthorized, w.Code, w.Body.String())
+			assert.Equal(t, "replay_nonce_reused", replayErrorCode(t, w))
+			assert.Equal(t, 1.0, gatheredMetric(t, "rtb_replay_checks_total", map[string]string{"client": "lead-seller", "outcome": "replayed"})-before)
+
+			w = serveReplayTest(router, "broker-key", clock.now, "n-1")
+			assert.Equal(t, http.StatusOK, w.Code, "nonces are scoped to their client")
+
+			if redisServer != nil {
+				other := newReplayTestRouter(t, cfg, clock)
+				w = serveReplayTest(other, replayTestKey, clock.now, "n-1")
+				assert.Equal(t, http.StatusUnauthorized, w.Code, "a nonce is shared across instances")
+				redisServer.FastForward(cfg.ReplayProtection.Window())
+			}
+			clock.now = clock.now.Add(cfg.ReplayProtection.Window())
+			w = serveReplayTest(router, replayTestKey, clock.now, "n-1")
+			assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		})
+	}
+}
+
+// TestReplayProtectionRedisUnavailable tests that requests pass when Redis is down under
+// fail-open and are refused with 503 otherwise
+func TestReplayProtectionRedisUnavailable(t *testing.T) {
+	testCases := []struct {
+		name            string
+		failOpen        bool
+		expectedStatus  int
+		expectedOutcome string
+	}{
+		{name: "Fail Open", failOpen: true, expectedStatus: http.StatusOK, expectedOutcome: "failed_open"},
+		{name: "Fail Closed", failOpen: false, expectedStatus: http.StatusServiceUnavailable, expectedOutcome: "unavailable"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			clock := &steppingClock{now: time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)}
+			cfg := newReplayProtectionTestConfig(t, tc.failOpen)
+			redisServer := miniredis.RunT(t)
+			useTestRedis(t, cfg, redisServer)
+			cfg.Redis.Timeout = 100 * time.Millisecond
+			router := newReplayTestRouter(t, cfg, clock)
+			redisServer.Close()
+
+			labels := map[string]string{"client": "lead-seller", "outcome": tc.expectedOutcome}
+			before := gatheredMetric(t, "rtb_replay_checks_total", labels)
+			w := serveReplayTest(router, replayTestKey, clock.now, "n-1")
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if !tc.failOpen {
+				assert.Equal(t, "replay_check_unavailable", replayErrorCode(t, w))
+			}
+			assert.Equal(t, 1.0, gatheredMetric(t, "rtb_replay_checks_total", labels)-before)
+		})
+	}
+}
+
+// TestReplayProtectionValidation tests replay protection configuration bounds
+func TestReplayProtectionValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		replay      *config.ReplayProtectionConfig
+		expectedErr string
+	}{
+		{name: "Default Skew", replay: &config.ReplayProtectionConfig{Clients: map[string]*config.ReplayClient{"a": {APIKeys: []string{"key-a"}}}}},
+		{name: "Max Skew", replay: &config.ReplayProtectionConfig{MaxSkew: config.MaxReplayMaxSkew}},
+		{name: "Skew Too Small", replay: &config.ReplayProtectionConfig{MaxSkew: time.Millisecond}, expectedErr: "max skew"},
+		{name: "Skew Too Large", replay: &config.ReplayProtectionConfig{MaxSkew: config.MaxReplayMaxSkew + time.Second}, expectedErr: "max skew"},
+		{name: "No Keys", replay: &config.ReplayProtectionConfig{Clients: map[string]*config.ReplayClient{"a": {}}}, expectedErr: "needs api keys"},
+		{name: "Empty Key", replay: &config.ReplayProtectionConfig{Clients: map[string]*config.ReplayClient{"a": {APIKeys: []string{""}}}}, expectedErr: "empty api key"},
+		{name: "Shared Key", replay: &config.ReplayProtectionConfig{Clients: map[string]*config.ReplayClient{"a": {APIKeys: []string{"key"}}, "b": {APIKeys: []string{"key"}}}}, expectedErr: "api key in both"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.ReplayProtection = tc.replay
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/replay"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newReplayTestConfig returns a config for two partners bidding 10 and 20 that records every auction to path
+func newReplayTestConfig(t *testing.T, path string) *config.Config {
+	low := newAnalyticsPartner(t, "low", 10.0)
+	high := newAnalyticsPartner(t, "high", 20.0)
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"low":  {ID: "low", Endpoint: low.URL, APIKey: "key-low", Timeout: 200 * time.Millisecond, Enabled: true},
+		"high": {ID: "high", Endpoint: high.URL, APIKey: "key-high", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2), withAdmin())
+	cfg.Recording = &config.RecordingConfig{Enabled: true, SampleRate: 1, Path: path, MaxSizeBytes: 1 << 20, BufferSize: 10}
+	return cfg
+}
+
+// recordTestAuctions runs an auction per vertical with recording enabled and returns the recordings
+func recordTestAuctions(t *testing.T, cfg *config.Config, verticals ...string) []*models.Recording {
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	for _, vertical := range verticals {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		service.RunAuction(ctx, &models.BidRequest{RequestID: "replay-" + vertical, LeadID: "lead-1", Vertical: vertical})
+		cancel()
+	}
+	require.NoError(t, service.Close())
+
+	file, err := os.Open(cfg.Recording.Path)
+	require.NoError(t, err)
+	defer file.Close()
+	recordings, err := replay.Read(file)
+	require.NoError(t, err)
+	return recordings
+}
+
+// TestAuctionRecording tests that sampled auctions capture the request, raw partner responses, and winners
+func TestAuctionRecording(t *testing.T) {
+	cfg := newReplayTestConfig(t, filepath.Join(t.TempDir(), "auctions.jsonl"))
+	recordings := recordTestAuctions(t, cfg, "auto", "life")
+	require.Len(t, recordings, 2)
+
+	testCases := []struct {
+		name            string
+		recording       *models.Recording
+		expectedStatus  int
+		expectedWinners []string
+		expectedError   string
+	}{
+		{name: "Sold Auction", recording: recordings[0], expectedStatus: http.StatusOK, expectedWinners: []string{"high", "low"}},
+		{name: "Unsold Auction", recording: recordings[1], expectedStatus: http.StatusNoContent, expectedWinners: []string{}, expectedError: services.ErrNoValidBids.Error()},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, models.RecordingVersion, tc.recording.Version)
+			require.Len(t, tc.recording.Exchanges, 2)
+			for _, exchange := range tc.recording.Exchanges {
+				assert.Equal(t, tc.expectedStatus, exchange.Status)
+				if tc.expectedStatus == http.StatusOK {
+					var bid models.Bid
+					require.NoError(t, json.Unmarshal(exchange.Body, &bid))
+					assert.Equal(t, exchange.PartnerID+"-bid", bid.ID)
+				}
+			}
+
+			winners := []string{}
+			for _, winner := range tc.recording.Winners {
+				winners = append(winners, winner.PartnerID)
+			}
+			assert.Equal(t, tc.expectedWinners, winners)
+			assert.Equal(t, tc.expectedError, tc.recording.Error)
+		})
+	}
+}
+
+// TestAuctionReplay tests that replays use recorded responses under the current config and diff the winners
+func TestAuctionReplay(t *testing.T) {
+	cfg := newReplayTestConfig(t, filepath.Join(t.TempDir(), "auctions.jsonl"))
+	recordings := recordTestAuctions(t, cfg, "auto")
+	require.Len(t, recordings, 1)
+
+	// Partners are unreachable during replay, so every response must come from the recording
+	for _, partner := range cfg.Partners {
+		partner.Endpoint = "http://127.0.0.1:1"
+	}
+
+	testCases := []struct {
+		name               string
+		modify             func(cfg *config.Config)
+		expectedChanged    bool
+		expectedRemoved    int
+		expectedDelta      float64
+		expectedUnrecorded []string
+	}{
+		{name: "Unchanged Config", modify: func(cfg *config.Config) {}},
+		{name: "Single Winner", modify: func(cfg *config.Config) { cfg.MaxBidsPerRequest = 1 }, expectedChanged: true, expectedRemoved: 1, expectedDelta: -10},
+		{name: "Higher Floor", modify: func(cfg *config.Config) { cfg.MinBidPrice = 15.0 }, expectedChanged: true, expectedRemoved: 1, expectedDelta: -10},
+		{
+			name: "New Partner",
+			modify: func(cfg *config.Config) {
+				cfg.Partners = map[string]*config.PartnerConfig{"low": cfg.Partners["low"], "high": cfg.Partners["high"], "new": {ID: "new", Endpoint: "http://127.0.0.1:1", APIKey: "key-new", Timeout: 200 * time.Millisecond, Enabled: true}}
+			},
+			expectedUnrecorded: []string{"new"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			replayConfig := *cfg
+			tc.modify(&replayConfig)
+
+			result, err := replay.NewReplayer(&replayConfig).Replay(context.Background(), recordings[0])
+			require.NoError(t, err)
+			assert.Empty(t, result.Error)
+			assert.Equal(t, "replay-auto", result.RequestID)
+			assert.Equal(t, tc.expectedChanged, result.Diff.Changed)
+			assert.Len(t, result.Diff.Removed, tc.expectedRemoved)
+			assert.Equal(t, tc.expectedDelta, result.Diff.RevenueDelta)
+			assert.ElementsMatch(t, tc.expectedUnrecorded, result.UnrecordedPartners)
+		})
+	}
+}
+
+// TestReplayCompare tests matching winners by partner and bid ID
+func TestReplayCompare(t *testing.T) {
+	original := []models.RecordedWinner{{PartnerID: "a", BidID: "a1", Price: 10}, {PartnerID: "b", BidID: "b1", Price: 8}}
+
+	testCases := []struct {
+		name             string
+		replayed         []models.RecordedWinner
+		expectedChanged  bool
+		expectedAdded    int
+		expectedRemoved  int
+		expectedRepriced int
+		expectedDelta    float64
+	}{
+		{name: "Same Winners", replayed: []models.RecordedWinner{{PartnerID: "b", BidID: "b1", Price: 8}, {PartnerID: "a", BidID: "a1", Price: 10}}},
+		{name: "Winner Replaced", replayed: []models.RecordedWinner{{PartnerID: "a", BidID: "a1", Price: 10}, {PartnerID: "c", BidID: "c1", Price: 9}}, expectedChanged: true, expectedAdded: 1, expectedRemoved: 1, expectedDelta: 1},
+		{name: "Price Changed", replayed: []models.RecordedWinner{{PartnerID: "a", BidID: "a1", Price: 12.5}, {PartnerID: "b", BidID: "b1", Price: 8}}, expectedChanged: true, expectedRepriced: 1, expectedDelta: 2.5},
+		{name: "No Winners", replayed: nil, expectedChanged: true, expectedRemoved: 2, expectedDelta: -18},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff := replay.Compare(original, tc.replayed)
+			assert.Equal(t, tc.expectedChanged, diff.Changed)
+			assert.Len(t, diff.Added, tc.expectedAdded)
+			assert.Len(t, diff.Removed, tc.expectedRemoved)
+			assert.Len(t, diff.PriceChanges, tc.expectedRepriced)
+			assert.Equal(t, tc.expectedDelta, diff.RevenueDelta)
+		})
+	}
+}
+
+// TestReplayEndpoint tests the admin replay endpoint's request parsing and summary
+func TestReplayEndpoint(t *testing.T) {
+	cfg := newReplayTestConfig(t, filepath.Join(t.TempDir(), "auctions.jsonl"))
+	recordTestAuctions(t, cfg, "auto", "home")
+	recorded, err := os.ReadFile(cfg.Recording.Path)
+	require.NoError(t, err)
+
+	// Replays run under the admin's config, which now keeps only the top bid
+	adminConfig := *cfg
+	adminConfig.MaxBidsPerRequest = 1
+	adminConfig.Recording = nil
+	service, err := services.NewAuctionService(&adminConfig)
+	require.NoError(t, err)
+	admin, err := handlers.NewAdminHandler(service, &adminConfig, handlers.BuildInfo{})
+	require.NoError(t, err)
+	router := gin.New()
+	admin.RegisterRoutes(router.Group("/admin"))
+
+	testCases := []struct {
+		name             string
+		body             string
+		expectedStatus   int
+		expectedReplayed float64
+		expectedChanged  float64
+	}{
+		{name: "Recording File", body: string(recorded), expectedStatus: http.StatusOK, expectedReplayed: 2, expectedChanged: 2},
+		{name: "Single Recording", body: strings.SplitN(string(recorded), "\n", 2)[0], expectedStatus: http.StatusOK, expectedReplayed: 1, expectedChanged: 1},
+		{name: "Malformed Recording", body: "{not json", expectedStatus: http.StatusBadRequest},
+		{name: "Unsupported Version", body: `{"version": 99, "request": {"request_id": "r"}}`, expectedStatus: http.StatusBadRequest},
+		{name: "Empty Body", body: "", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "/admin/replay", bytes.NewBufferString(tc.body))
+			req.Header.Set("X-Admin-Key", testAdminKey)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+			var body struct {
+				Summary map[string]float64 `json:"summary"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tc.expectedReplayed, body.Summary["replayed"])
+			assert.Equal(t, tc.expectedChanged, body.Summary["changed"])
+			assert.Equal(t, -10*tc.expectedChanged, body.Summary["revenue_delta"])
+		})
+	}
+}
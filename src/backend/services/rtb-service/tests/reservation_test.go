@@ -0,0 +1,266 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// reservationStores are the reservation backends every reservation test runs against
+var reservationStores = []string{"Memory", "Redis"}
+
+// newReservationTestConfig returns a config for two partners bidding 10 and 20 whose reservations
+// last ttl in the named store, with webhooks sent to url
+func newReservationTestConfig(t *testing.T, store string, ttl time.Duration, url string) *config.Config {
+	low := newAnalyticsPartner(t, "low", 10.0)
+	high := newAnalyticsPartner(t, "high", 20.0)
+	webhooksCfg := newWebhookTestConfig(t, config.WebhookEndpoint{URL: url})
+	webhooksCfg.QueueSize = 1000
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"low":  {ID: "low", Endpoint: low.URL, APIKey: "key-low", Timeout: 200 * time.Millisecond, Enabled: true},
+		"high": {ID: "high", Endpoint: high.URL, APIKey: "key-high", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2))
+	cfg.Reservations = &config.ReservationsConfig{Enabled: true, TTL: ttl, SweepInterval: 5 * time.Millisecond, MaxEntries: 1000}
+	cfg.Webhooks = webhooksCfg
+	if store == "Redis" {
+		redisServer := miniredis.RunT(t)
+		host, portText, err := net.SplitHostPort(redisServer.Addr())
+		require.NoError(t, err)
+		port, err := strconv.Atoi(portText)
+		require.NoError(t, err)
+		cfg.Redis = &config.RedisConfig{Host: host, Port: port, Timeout: time.Second}
+	}
+	return cfg
+}
+
+// reserveTestAuction reserves the winners of an auction for requestID
+func reserveTestAuction(t *testing.T, service *services.AuctionService, requestID string) *services.Reservation {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	reservation, err := service.Reserve(ctx, &models.BidRequest{RequestID: requestID, LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	return reservation
+}
+
+// webhookRequestIDs returns the request IDs of the received events of eventType
+func webhookRequestIDs(receiver *webhookReceiver, eventType string) map[string]int {
+	requestIDs := make(map[string]int)
+	for _, delivery := range receiver.received() {
+		if delivery.event.Type == eventType {
+			data, _ := delivery.event.Data.(map[string]any)
+			requestID, _ := data["request_id"].(string)
+			requestIDs[requestID]++
+		}
+	}
+	return requestIDs
+}
+
+// TestReservationEndpoints tests reserving winners, settling them on confirmation, and the confirm statuses
+func TestReservationEndpoints(t *testing.T) {
+	for _, store := range reservationStores {
+		t.Run(store, func(t *testing.T) {
+			receiver, webhookServer := newWebhookReceiver(t, http.StatusOK)
+			cfg := newReservationTestConfig(t, store, time.Minute, webhookServer.URL)
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			handler, err := handlers.NewBidHandler(service, cfg)
+			require.NoError(t, err)
+			router := gin.New()
+			router.POST("/v1/bids/reserve", handler.HandleReserveRequest)
+			router.POST("/v1/bids/confirm/:token", handler.HandleConfirmReservation)
+
+			body, _ := json.Marshal(models.BidRequest{RequestID: "reserve-" + store, LeadID: "lead-1", Vertical: "auto"})
+			req, _ := http.NewRequest(http.MethodPost, "/v1/bids/reserve", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var reservation services.Reservation
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &reservation))
+			require.NotEmpty(t, reservation.Token)
+			require.Len(t, reservation.Bids, 2)
+			assert.WithinDuration(t, time.Now().Add(time.Minute), reservation.ExpiresAt, 5*time.Second)
+
+			// Nothing is settled while the reservation is held
+			report, err := service.PartnerReport("high", time.Hour)
+			require.NoError(t, err)
+			assert.Zero(t, report.Wins)
+
+			testCases := []struct {
+				name           string
+				token          string
+				expectedStatus int
+				expectedBids   int
+			}{
+				{name: "Confirm", token: reservation.Token, expectedStatus: http.StatusOK, expectedBids: 2},
+				{name: "Confirm Again", token: reservation.Token, expectedStatus: http.StatusConflict},
+				{name: "Unknown Token", token: "unknown-token", expectedStatus: http.StatusGone},
+			}
+
+			for _, tc := range testCases {
+				t.Run(tc.name, func(t *testing.T) {
+					req, _ := http.NewRequest(http.MethodPost, "/v1/bids/confirm/"+tc.token, nil)
+					w := httptest.NewRecorder()
+					router.ServeHTTP(w, req)
+
+					require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+					if tc.expectedStatus != http.StatusOK {
+						return
+					}
+					var response models.BidResponse
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+					assert.Len(t, response.Bids, tc.expectedBids)
+					assert.Equal(t, "reserve-"+store, response.RequestID)
+				})
+			}
+
+			report, err = service.PartnerReport("high", time.Hour)
+			require.NoError(t, err)
+			assert.Equal(t, uint64(1), report.Wins)
+			require.NoError(t, service.Close())
+			assert.Equal(t, map[string]int{"reserve-" + store: 2}, webhookRequestIDs(receiver, config.WebhookEventBidWon))
+			assert.Equal(t, map[string]int{"reserve-" + store: 1}, webhookRequestIDs(receiver, config.WebhookEventAuctionCompleted))
+		})
+	}
+}
+
+// TestReservationExpiry tests that unconfirmed reservations are abandoned once and can no longer be confirmed
+func TestReservationExpiry(t *testing.T) {
+	for _, store := range reservationStores {
+		t.Run(store, func(t *testing.T) {
+			receiver, webhookServer := newWebhookReceiver(t, http.StatusOK)
+			cfg := newReservationTestConfig(t, store, 20*time.Millisecond, webhookServer.URL)
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+
+			reservation := reserveTestAuction(t, service, "expire-"+store)
+			require.Eventually(t, func() bool {
+				return len(webhookRequestIDs(receiver, config.WebhookEventReservationAbandoned)) == 1
+			}, 2*time.Second, 5*time.Millisecond)
+
+			_, err = service.ConfirmReservation(context.Background(), reservation.Token)
+			assert.ErrorIs(t, err, services.ErrReservationExpired)
+
+			require.NoError(t, service.Close())
+			assert.Equal(t, map[string]int{"expire-" + store: 1}, webhookRequestIDs(receiver, config.WebhookEventReservationAbandoned))
+			assert.Empty(t, webhookRequestIDs(receiver, config.WebhookEventBidWon))
+			assert.Empty(t, webhookRequestIDs(receiver, config.WebhookEventAuctionCompleted))
+		})
+	}
+}
+
+// TestReservationConcurrentConfirm tests that exactly one of many concurrent confirmations succeeds
+func TestReservationConcurrentConfirm(t *testing.T) {
+	for _, store := range reservationStores {
+		t.Run(store, func(t *testing.T) {
+			_, webhookServer := newWebhookReceiver(t, http.StatusOK)
+			service, err := services.NewAuctionService(newReservationTestConfig(t, store, time.Minute, webhookServer.URL))
+			require.NoError(t, err)
+			defer service.Close()
+			reservation := reserveTestAuction(t, service, "concurrent-"+store)
+
+			const confirmations = 20
+			errs := make(chan error, confirmations)
+			var wg sync.WaitGroup
+			for i := 0; i < confirmations; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := service.ConfirmReservation(context.Background(), reservation.Token)
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+
+			var confirmed, conflicts int
+			for err := range errs {
+				switch {
+				case err == nil:
+					confirmed++
+				case assert.ErrorIs(t, err, services.ErrReservationConfirmed):
+					conflicts++
+				}
+			}
+			assert.Equal(t, 1, confirmed)
+			assert.Equal(t, confirmations-1, conflicts)
+		})
+	}
+}
+
+// TestReservationConfirmExpiryRace tests that confirmations racing the expiry sweep settle every
+// reservation exactly once, either confirmed or abandoned
+func TestReservationConfirmExpiryRace(t *testing.T) {
+	for _, store := range reservationStores {
+		t.Run(store, func(t *testing.T) {
+			receiver, webhookServer := newWebhookReceiver(t, http.StatusOK)
+			cfg := newReservationTestConfig(t, store, 30*time.Millisecond, webhookServer.URL)
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+
+			const count = 30
+			reservations := make([]*services.Reservation, count)
+			for i := range reservations {
+				reservations[i] = reserveTestAuction(t, service, fmt.Sprintf("race-%s-%d", store, i))
+			}
+
+			// Confirm each reservation around its expiry while the sweeper runs
+			confirmed := make(map[string]bool)
+			var mutex sync.Mutex
+			var wg sync.WaitGroup
+			for i, reservation := range reservations {
+				wg.Add(1)
+				go func(i int, reservation *services.Reservation) {
+					defer wg.Done()
+					time.Sleep(time.Until(reservation.ExpiresAt) + time.Duration(i%7-3)*time.Millisecond)
+					_, err := service.ConfirmReservation(context.Background(), reservation.Token)
+					if err == nil {
+						mutex.Lock()
+						confirmed[reservation.RequestID] = true
+						mutex.Unlock()
+						return
+					}
+					assert.ErrorIs(t, err, services.ErrReservationExpired)
+				}(i, reservation)
+			}
+			wg.Wait()
+
+			// Let the sweeper abandon anything a confirmation did not reach
+			time.Sleep(50 * time.Millisecond)
+			require.NoError(t, service.Close())
+
+			abandoned := webhookRequestIDs(receiver, config.WebhookEventReservationAbandoned)
+			won := webhookRequestIDs(receiver, config.WebhookEventBidWon)
+			for _, reservation := range reservations {
+				requestID := reservation.RequestID
+				if confirmed[requestID] {
+					assert.Equal(t, 2, won[requestID], requestID)
+					assert.Zero(t, abandoned[requestID], requestID)
+				} else {
+					assert.Zero(t, won[requestID], requestID)
+					assert.Equal(t, 1, abandoned[requestID], requestID)
+				}
+			}
+		})
+	}
+}
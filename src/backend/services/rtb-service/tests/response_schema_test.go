@@ -0,0 +1,332 @@
+package tests
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// TestResponseValidation tests strict and lenient parsing of partner responses, the coercions
+// lenient mode counts, and the field and raw value named by each rejection
+func TestResponseValidation(t *testing.T) {
+	testCases := []struct {
+		name             string
+		mode             string
+		format           string
+		mapping          *config.FieldMapping
+		body             string
+		expectedID       string
+		expectedPrice    float64
+		expectedDomains  []string
+		expectedCoercion map[string]string
+		expectedField    string
+		expectedReason   string
+		expectedValue    string
+	}{
+		{
+			name:             "Lenient String Price",
+			mode:             config.ResponseValidationLenient,
+			body:             `{"id": "b1", "price": "12.5", "click_url": "http://example.com/1"}`,
+			expectedID:       "b1",
+			expectedPrice:    12.5,
+			expectedCoercion: map[string]string{"field": "price", "kind": "string_number"},
+		},
+		{
+			name:           "Strict String Price",
+			mode:           config.ResponseValidationStrict,
+			body:           `{"id": "b1", "price": "12.5", "click_url": "http://example.com/1"}`,
+			expectedField:  "price",
+			expectedReason: services.SchemaReasonWrongType,
+			expectedValue:  `"12.5"`,
+		},
+		{
+			name:           "Lenient NaN Price",
+			mode:           config.ResponseValidationLenient,
+			body:           `{"id": "b1", "price": "NaN", "click_url": "http://example.com/1"}`,
+			expectedField:  "price",
+			expectedReason: services.SchemaReasonNotFinite,
+			expectedValue:  `"NaN"`,
+		},
+		{
+			name:           "Lenient Uncoercible Price",
+			mode:           config.ResponseValidationLenient,
+			body:           `{"id": "b1", "price": true, "click_url": "http://example.com/1"}`,
+			expectedField:  "price",
+			expectedReason: services.SchemaReasonWrongType,
+			expectedValue:  `true`,
+		},
+		{
+			name:             "Lenient Unknown Field",
+			mode:             config.ResponseValidationLenient,
+			body:             `{"id": "b1", "price": 12.5, "click_url": "http://example.com/1", "bidder_notes": "x"}`,
+			expectedID:       "b1",
+			expectedPrice:    12.5,
+			expectedCoercion: map[string]string{"field": "bidder_notes", "kind": "unknown_field"},
+		},
+		{
+			name:           "Strict Unknown Field",
+			mode:           config.ResponseValidationStrict,
+			body:           `{"id": "b1", "price": 12.5, "click_url": "http://example.com/1", "bidder_notes": {"tier": 2}}`,
+			expectedField:  "bidder_notes",
+			expectedReason: services.SchemaReasonUnknownField,
+			expectedValue:  `{"tier":2}`,
+		},
+		{
+			name:           "Strict Missing Click URL",
+			mode:           config.ResponseValidationStrict,
+			body:           `{"id": "b1", "price": 12.5}`,
+			expectedField:  "click_url",
+			expectedReason: services.SchemaReasonMissing,
+		},
+		{
+			name:          "Lenient Missing Click URL",
+			mode:          config.ResponseValidationLenient,
+			body:          `{"id": "b1", "price": 12.5}`,
+			expectedID:    "b1",
+			expectedPrice: 12.5,
+		},
+		{
+			name:             "Lenient Numeric ID",
+			mode:             config.ResponseValidationLenient,
+			body:             `{"id": 7731, "price": 12.5, "click_url": "http://example.com/1"}`,
+			expectedID:       "7731",
+			expectedPrice:    12.5,
+			expectedCoercion: map[string]string{"field": "id", "kind": "number_string"},
+		},
+		{
+			name:           "Strict Numeric ID",
+			mode:           config.ResponseValidationStrict,
+			body:           `{"id": 7731, "price": 12.5, "click_url": "http://example.com/1"}`,
+			expectedField:  "id",
+			expectedReason: services.SchemaReasonWrongType,
+			expectedValue:  `7731`,
+		},
+		{
+			name:             "Lenient Precision",
+			mode:             config.ResponseValidationLenient,
+			body:             `{"id": "b1", "price": 12.123456, "click_url": "http://example.com/1"}`,
+			expectedID:       "b1",
+			expectedPrice:    12.1235,
+			expectedCoercion: map[string]string{"field": "price", "kind": "precision"},
+		},
+		{
+			name:          "Strict Precision",
+			mode:          config.ResponseValidationStrict,
+			body:          `{"id": "b1", "price": 12.123456, "click_url": "http://example.com/1"}`,
+			expectedID:    "b1",
+			expectedPrice: 12.123456,
+		},
+		{
+			name:             "Lenient Single Domain",
+			mode:             config.ResponseValidationLenient,
+			body:             `{"id": "b1", "price": 12.5, "click_url": "http://example.com/1", "adomain": "carrier.example.com"}`,
+			expectedID:       "b1",
+			expectedPrice:    12.5,
+			expectedDomains:  []string{"carrier.example.com"},
+			expectedCoercion: map[string]string{"field": "adomain", "kind": "string_list"},
+		},
+		{
+			name:           "Truncated Value",
+			mode:           config.ResponseValidationStrict,
+			body:           `{"id": "b1", "price": 12.5, "click_url": "http://example.com/1", "notes": "` + strings.Repeat("x", 200) + `"}`,
+			expectedField:  "notes",
+			expectedReason: services.SchemaReasonUnknownField,
+			expectedValue:  `"` + strings.Repeat("x", 63) + "...",
+		},
+		{
+			name:           "Strict XML Missing Click URL",
+			mode:           config.ResponseValidationStrict,
+			format:         config.FormatXML,
+			body:           `<Bid><ID>legacy-1</ID><Price>12.5</Price></Bid>`,
+			expectedField:  "ClickURL",
+			expectedReason: services.SchemaReasonMissing,
+		},
+		{
+			name:           "Lenient XML Infinite Price",
+			mode:           config.ResponseValidationLenient,
+			format:         config.FormatXML,
+			body:           `<Bid><ID>legacy-1</ID><Price>+Inf</Price><ClickURL>http://example.com/1</ClickURL></Bid>`,
+			expectedField:  "Price",
+			expectedReason: services.SchemaReasonNotFinite,
+			expectedValue:  `"+Inf"`,
+		},
+		{
+			name:             "Lenient Mapped String Payout",
+			mode:             config.ResponseValidationLenient,
+			format:           config.FormatMappedJSON,
+			mapping:          legacyMapping,
+			body:             `{"offer": {"offer_id": "o1", "payout": "14.20", "redirect": "http://example.com/o1"}}`,
+			expectedID:       "o1",
+			expectedPrice:    14.2,
+			expectedCoercion: map[string]string{"field": "offer.payout", "kind": "string_number"},
+		},
+		{
+			name:           "Strict Mapped String Payout",
+			mode:           config.ResponseValidationStrict,
+			format:         config.FormatMappedJSON,
+			mapping:        legacyMapping,
+			body:           `{"offer": {"offer_id": "o1", "payout": "14.20", "redirect": "http://example.com/o1"}}`,
+			expectedField:  "offer.payout",
+			expectedReason: services.SchemaReasonWrongType,
+			expectedValue:  `"14.20"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partner := &config.PartnerConfig{ID: "schema-partner", Format: tc.format, FieldMapping: tc.mapping, ResponseValidation: tc.mode}
+			adapter, err := services.NewPartnerAdapter(partner)
+			require.NoError(t, err)
+
+			var coercionsBefore float64
+			if tc.expectedCoercion != nil {
+				tc.expectedCoercion["partner"] = partner.ID
+				coercionsBefore = gatheredMetric(t, "rtb_partner_response_coercions_total", tc.expectedCoercion)
+			}
+
+			bids, err := adapter.ParseResponse([]byte(tc.body), http.StatusOK)
+			if tc.expectedReason != "" {
+				var adapterErr *services.AdapterError
+				require.ErrorAs(t, err, &adapterErr)
+				assert.Equal(t, tc.expectedField, adapterErr.Field)
+				var schemaErr *services.SchemaError
+				require.ErrorAs(t, err, &schemaErr)
+				assert.Equal(t, tc.expectedField, schemaErr.Field)
+				assert.Equal(t, tc.expectedReason, schemaErr.Reason)
+				assert.Equal(t, tc.expectedValue, schemaErr.Value)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Len(t, bids, 1)
+			assert.Equal(t, tc.expectedID, bids[0].ID)
+			assert.Equal(t, tc.expectedPrice, bids[0].Price)
+			if tc.expectedDomains != nil {
+				assert.Equal(t, tc.expectedDomains, bids[0].AdvertiserDomains)
+			}
+			if tc.expectedCoercion != nil {
+				assert.Equal(t, 1.0, gatheredMetric(t, "rtb_partner_response_coercions_total", tc.expectedCoercion)-coercionsBefore)
+			}
+		})
+	}
+}
+
+// TestResponseValidationSingleBid tests that a lone bid object, which the JSON adapter reads
+// without decoding it generically, parses exactly as the same bid inside an array does
+func TestResponseValidationSingleBid(t *testing.T) {
+	bodies := []string{
+		`{"id": "b1", "price": 12.5, "click_url": "http://example.com/1"}`,
+		`{"id":"b1","price":12.34567,"quality_score":0.123456,"click_url":"http://example.com/1"}`,
+		`{"id": "b1", "price": 1.25e1, "click_url": "http://example.com/1", "quality_acknowledged": true}`,
+		`{"id": "b1", "price": 12, "click_url": "http://example.com/1", "deal_id": null, "partner_id": "other"}`,
+		`{"id": "b1", "price": 12, "click_url": "http://example.com/1", "expires_at": " 2026-01-02T03:04:05Z "}`,
+		`{"id": "b\u0031", "price": 12, "click_url": "http://example.com/1"}`,
+		`{"id": "b1", "price": "12", "click_url": "http://example.com/1"}`,
+		`{"id": 1, "price": 12, "click_url": "http://example.com/1"}`,
+		`{"id": "b1", "price": 1e999, "click_url": "http://example.com/1"}`,
+		`{"id": "b1", "price": 12, "click_url": "http://example.com/1", "extra": 1}`,
+		`{"id": "b1", "id": "b2", "price": 12, "click_url": "http://example.com/1"}`,
+		`{"id": "b1", "price": 12, "click_url": null}`,
+		`{"id": "b1", "price": 12, "click_url": "http://example.com/1"} trailing`,
+		`{}`,
+	}
+
+	for _, mode := range []string{config.ResponseValidationLenient, config.ResponseValidationStrict} {
+		adapter, err := services.NewPartnerAdapter(&config.PartnerConfig{ID: "single-bid-partner", ResponseValidation: mode})
+		require.NoError(t, err)
+		for _, body := range bodies {
+			single, singleErr := adapter.ParseResponse([]byte(body), http.StatusOK)
+			// Data after the first value is ignored, so the array leaves it out
+			listed, listedErr := adapter.ParseResponse([]byte("["+strings.TrimSuffix(body, " trailing")+"]"), http.StatusOK)
+			assert.Equal(t, listedErr, singleErr, "%s: %s", mode, body)
+			assert.Equal(t, listed, single, "%s: %s", mode, body)
+		}
+	}
+}
+
+// TestSchemaErrorsInPartnerStats tests that rejected responses count as partner failures and that
+// partner statuses keep the latest schema errors for partner reports
+func TestSchemaErrorsInPartnerStats(t *testing.T) {
+	testCases := []struct {
+		name           string
+		mode           string
+		auctions       int
+		expectedErrors int
+		expectedRecent int
+	}{
+		{name: "Strict", mode: config.ResponseValidationStrict, auctions: 3, expectedErrors: 3, expectedRecent: 3},
+		{name: "Strict Beyond Recent Limit", mode: config.ResponseValidationStrict, auctions: 12, expectedErrors: 12, expectedRecent: 10},
+		{name: "Lenient", mode: config.ResponseValidationLenient, auctions: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			sloppy, _ := newNegativeCachePartner(t, http.StatusOK, `{"id": "sloppy-bid", "price": 9.5, "click_url": "http://example.com/sloppy", "seat": "s1"}`, 0)
+			cfg, _ := newNegativeCacheTestConfig(t, "Memory", sloppy.URL, nil)
+			cfg.NegativeCache = nil
+			cfg.CircuitBreaker = &config.CircuitBreakerConfig{FailureThreshold: 100, Cooldown: time.Minute}
+			cfg.Partners["sparse"].ResponseValidation = tc.mode
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			labels := map[string]string{"partner": "sparse", "field": "seat", "reason": services.SchemaReasonUnknownField}
+			before := gatheredMetric(t, "rtb_partner_schema_errors_total", labels)
+			started := time.Now()
+			for i := 0; i < tc.auctions; i++ {
+				runNegativeCacheAuction(t, context.Background(), service, "")
+			}
+			assert.Equal(t, float64(tc.expectedErrors), gatheredMetric(t, "rtb_partner_schema_errors_total", labels)-before)
+
+			for _, status := range service.PartnerStatuses() {
+				if status.ID != "sparse" {
+					continue
+				}
+				assert.Equal(t, tc.expectedErrors, status.SchemaErrors)
+				assert.Equal(t, tc.expectedErrors, status.Failures)
+				require.Len(t, status.RecentSchemaErrors, tc.expectedRecent)
+				for _, schemaErr := range status.RecentSchemaErrors {
+					assert.Equal(t, "seat", schemaErr.Field)
+					assert.Equal(t, `"s1"`, schemaErr.Value)
+					assert.False(t, schemaErr.At.Before(started))
+				}
+			}
+		})
+	}
+}
+
+// TestResponseValidationConfig tests that partners accept only the known response validation modes
+func TestResponseValidationConfig(t *testing.T) {
+	testCases := []struct {
+		name        string
+		mode        string
+		expectedErr string
+	}{
+		{name: "Default", mode: ""},
+		{name: "Strict", mode: config.ResponseValidationStrict},
+		{name: "Lenient", mode: config.ResponseValidationLenient},
+		{name: "Unknown", mode: "pedantic", expectedErr: `unknown response validation mode "pedantic" in partner partner-1`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].ResponseValidation = tc.mode
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"                       // v1.9.1
+	"github.com/prometheus/client_golang/prometheus" // v1.16.0
+	"github.com/stretchr/testify/assert"             // v1.8.4
+	"github.com/stretchr/testify/require"            // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// shapingProfileKey selects the compact response profile in the shaping tests
+const shapingProfileKey = "client-compact"
+
+// newShapingTestRouter serves the bid endpoint for an auction against one partner whose bid has
+// a creative, with a compact response profile for shapingProfileKey
+func newShapingTestRouter(t *testing.T, codec string) *gin.Engine {
+	partner := newPartnerServer(t, models.Bid{ID: "shaped-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/shaped",
+		Creative: map[string]interface{}{"html": "<div>" + string(bytes.Repeat([]byte("creative "), 100)) + "</div>"}})
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.JSONCodec = codec
+	cfg.ResponseProfiles = map[string]*config.ResponseProfile{
+		"compact": {APIKeys: []string{shapingProfileKey}, Fields: []string{"id", "price"}},
+	}
+	service := newTestService(t, cfg)
+	router, _ := newTestRouter(t, service, cfg)
+	return router
+}
+
+// responseSizeSum returns the total bytes recorded for a response profile
+func responseSizeSum(t *testing.T, profile string) float64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	require.NoError(t, err)
+	for _, family := range families {
+		if family.GetName() != "rtb_response_size_bytes" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			if metric.GetLabel()[0].GetValue() == profile {
+				return metric.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+	return 0
+}
+
+// TestParseBidFields tests parsing field lists, including the offending name in errors
+func TestParseBidFields(t *testing.T) {
+	testCases := []struct {
+		name           string
+		list           string
+		expectedFields models.BidFields
+		expectedError  string
+	}{
+		{name: "Empty List", list: "", expectedFields: models.AllBidFields},
+		{name: "Single Field", list: "price", expectedFields: models.BidFieldPrice},
+		{name: "Several Fields", list: "id, price,click_url", expectedFields: models.BidFieldID | models.BidFieldPrice | models.BidFieldClickURL},
+		{name: "Repeated Field", list: "id,id", expectedFields: models.BidFieldID},
+		{name: "Every Field", list: "id,partner_id,price,click_url,quality_score,expires_at,creative,adomain,deal_id,pricing_model,normalized_price,quality_acknowledged,vertical_ext,payload_experiment", expectedFields: models.AllBidFields},
+		{name: "Unknown Field", list: "id,bogus", expectedError: `unknown bid field "bogus"`},
+		{name: "Go Field Name", list: "ClickURL", expectedError: `unknown bid field "ClickURL"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fields, err := models.ParseBidFields(tc.list)
+			if tc.expectedError != "" {
+				assert.True(t, errors.Is(err, models.ErrUnknownBidField))
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedFields, fields)
+		})
+	}
+}
+
+// TestShapedResponseEncoding tests that shaped responses match encoding/json for every field and
+// omit unselected fields with every codec
+func TestShapedResponseEncoding(t *testing.T) {
+	response := newCodecTestResponse("shape-1", 12.5, int64(250*time.Millisecond))
+	full, err := json.Marshal(response)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name            string
+		fields          models.BidFields
+		expectedKeys    []string
+		expectedMissing []string
+	}{
+		{name: "All Fields", fields: models.AllBidFields},
+		{name: "Id Price Click", fields: models.BidFieldID | models.BidFieldPrice | models.BidFieldClickURL,
+			expectedKeys: []string{"id", "price", "click_url"}, expectedMissing: []string{"creative", "quality_score", "partner_id", "expires_at"}},
+		{name: "Omitempty Field Only", fields: models.BidFieldDealID, expectedMissing: []string{"id", "deal_id"}},
+	}
+
+	for _, tc := range testCases {
+		for _, name := range codecNames {
+			t.Run(tc.name+"/"+name, func(t *testing.T) {
+				codec, err := models.NewCodec(name)
+				require.NoError(t, err)
+				var buffer bytes.Buffer
+				require.NoError(t, codec.Encode(&buffer, &models.ShapedResponse{Response: response, Fields: tc.fields}))
+				if tc.fields == models.AllBidFields {
+					assert.Equal(t, string(full), string(bytes.TrimSuffix(buffer.Bytes(), []byte("\n"))))
+					return
+				}
+
+				var decoded struct {
+					RequestID string                   `json:"request_id"`
+					Bids      []map[string]interface{} `json:"bids"`
+				}
+				require.NoError(t, json.Unmarshal(buffer.Bytes(), &decoded))
+				assert.Equal(t, "shape-1", decoded.RequestID)
+				require.Len(t, decoded.Bids, 2)
+				for _, key := range tc.expectedKeys {
+					assert.Contains(t, decoded.Bids[0], key)
+				}
+				for _, key := range tc.expectedMissing {
+					assert.NotContains(t, decoded.Bids[0], key)
+				}
+			})
+		}
+	}
+}
+
+// TestHandlerResponseShaping tests the fields parameter and response profiles on the bid endpoint
+func TestHandlerResponseShaping(t *testing.T) {
+	testCases := []struct {
+		name            string
+		query           string
+		apiKey          string
+		expectedStatus  int
+		expectedError   string
+		expectedKeys    []string
+		expectedMissing []string
+	}{
+		{name: "Default Full Payload", expectedStatus: http.StatusOK,
+			expectedKeys: []string{"id", "partner_id", "price", "click_url", "quality_score", "expires_at", "creative"}},
+		{name: "Fields Parameter", query: "?fields=id,price,click_url", expectedStatus: http.StatusOK,
+			expectedKeys: []string{"id", "price", "click_url"}, expectedMissing: []string{"creative", "quality_score", "partner_id"}},
+		{name: "Empty Fields Parameter", query: "?fields=", expectedStatus: http.StatusOK, expectedKeys: []string{"creative", "quality_score"}},
+		{name: "Unknown Field", query: "?fields=id,creatve", expectedStatus: http.StatusBadRequest, expectedError: `unknown bid field "creatve"`},
+		{name: "Profile By API Key", apiKey: shapingProfileKey, expectedStatus: http.StatusOK,
+			expectedKeys: []string{"id", "price"}, expectedMissing: []string{"creative", "click_url"}},
+		{name: "Fields Parameter Overrides Profile", query: "?fields=click_url", apiKey: shapingProfileKey, expectedStatus: http.StatusOK,
+			expectedKeys: []string{"click_url"}, expectedMissing: []string{"id", "price"}},
+		{name: "Unknown API Key", apiKey: "someone-else", expectedStatus: http.StatusOK, expectedKeys: []string{"creative"}},
+	}
+
+	for _, tc := range testCases {
+		for _, codec := range codecNames {
+			t.Run(tc.name+"/"+codec, func(t *testing.T) {
+				router := newShapingTestRouter(t, codec)
+				req := httptest.NewRequest(http.MethodPost, "/v1/bids"+tc.query, bytes.NewBufferString(`{"request_id": "", "lead_id": "lead-1", "vertical": "auto"}`))
+				req.Header.Set("Content-Type", "application/json")
+				if tc.apiKey != "" {
+					req.Header.Set("X-API-Key", tc.apiKey)
+				}
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+				if tc.expectedError != "" {
+					var body map[string]string
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+					assert.Equal(t, tc.expectedError, body["error"])
+					return
+				}
+
+				var response struct {
+					RequestID string                   `json:"request_id"`
+					Bids      []map[string]interface{} `json:"bids"`
+				}
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+				assert.NotEmpty(t, response.RequestID)
+				require.Len(t, response.Bids, 1)
+				for _, key := range tc.expectedKeys {
+					assert.Contains(t, response.Bids[0], key)
+				}
+				for _, key := range tc.expectedMissing {
+					assert.NotContains(t, response.Bids[0], key)
+				}
+			})
+		}
+	}
+}
+
+// TestResponseSizeMetric tests that shaped responses are recorded smaller than full ones
+func TestResponseSizeMetric(t *testing.T) {
+	router := newShapingTestRouter(t, config.JSONCodecStd)
+	send := func(query string, apiKey string) int {
+		req := httptest.NewRequest(http.MethodPost, "/v1/bids"+query, bytes.NewBufferString(`{"request_id": "", "lead_id": "lead-1", "vertical": "auto"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-API-Key", apiKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		return w.Body.Len()
+	}
+
+	testCases := []struct {
+		name    string
+		query   string
+		apiKey  string
+		profile string
+	}{
+		{name: "Full", profile: "full"},
+		{name: "Fields Parameter", query: "?fields=id,price,click_url", profile: "query"},
+		{name: "Profile", apiKey: shapingProfileKey, profile: "compact"},
+	}
+
+	sizes := make(map[string]int)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			before := responseSizeSum(t, tc.profile)
+			sizes[tc.profile] = send(tc.query, tc.apiKey)
+			assert.Equal(t, float64(sizes[tc.profile]), responseSizeSum(t, tc.profile)-before)
+		})
+	}
+	assert.Less(t, sizes["query"]*4, sizes["full"])
+	assert.Less(t, sizes["compact"], sizes["query"])
+}
+
+// TestResponseProfileValidation tests response profile configuration checks
+func TestResponseProfileValidation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		profiles      map[string]*config.ResponseProfile
+		expectedError bool
+	}{
+		{name: "Valid Profiles", profiles: map[string]*config.ResponseProfile{
+			"compact": {APIKeys: []string{"a"}, Fields: []string{"id", "price"}},
+			"links":   {APIKeys: []string{"b", "c"}, Fields: []string{"click_url"}},
+		}},
+		{name: "No API Keys", profiles: map[string]*config.ResponseProfile{"compact": {Fields: []string{"id"}}}, expectedError: true},
+		{name: "Empty API Key", profiles: map[string]*config.ResponseProfile{"compact": {APIKeys: []string{""}, Fields: []string{"id"}}}, expectedError: true},
+		{name: "No Fields", profiles: map[string]*config.ResponseProfile{"compact": {APIKeys: []string{"a"}}}, expectedError: true},
+		{name: "Shared API Key", profiles: map[string]*config.ResponseProfile{
+			"compact": {APIKeys: []string{"a"}, Fields: []string{"id"}},
+			"links":   {APIKeys: []string{"a"}, Fields: []string{"click_url"}},
+		}, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.ResponseProfiles = tc.profiles
+			if tc.expectedError {
+				assert.Error(t, cfg.Validate())
+			} else {
+				assert.NoError(t, cfg.Validate())
+			}
+		})
+	}
+}
+
+// TestResponseProfileUnknownField tests that a profile naming an unknown field stops the bid handler from starting
+func TestResponseProfileUnknownField(t *testing.T) {
+	cfg := newStrategyTestConfig()
+	cfg.ResponseProfiles = map[string]*config.ResponseProfile{"compact": {APIKeys: []string{"a"}, Fields: []string{"id", "bogus"}}}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	_, err = handlers.NewBidHandler(service, cfg)
+	assert.ErrorIs(t, err, models.ErrUnknownBidField)
+	assert.ErrorContains(t, err, `"bogus"`)
+}
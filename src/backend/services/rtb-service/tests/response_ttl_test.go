@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// maxAgeSeconds returns the max-age of a Cache-Control header, or -1 when it has none
+func maxAgeSeconds(t *testing.T, header string) int {
+	value, found := strings.CutPrefix(header, "max-age=")
+	if !found {
+		return -1
+	}
+	seconds, err := strconv.Atoi(value)
+	require.NoError(t, err)
+	return seconds
+}
+
+// TestResponseTTL tests that winning bids expire at the vertical's response TTL unless the
+// partner's own expiry is sooner, and that caching headers follow the response's valid_until
+func TestResponseTTL(t *testing.T) {
+	testCases := []struct {
+		name          string
+		ttls          map[string]time.Duration
+		vertical      string
+		partnerExpiry time.Duration
+		expectedValid time.Duration
+	}{
+		{name: "Partner Without Expiry", ttls: map[string]time.Duration{"default": time.Minute}, vertical: "auto", expectedValid: time.Minute},
+		{name: "Partner Expiry Shorter", ttls: map[string]time.Duration{"default": time.Minute}, vertical: "auto", partnerExpiry: 20 * time.Second, expectedValid: 20 * time.Second},
+		{name: "Partner Expiry Longer", ttls: map[string]time.Duration{"default": time.Minute}, vertical: "auto", partnerExpiry: 2 * time.Hour, expectedValid: time.Minute},
+		{name: "Vertical Override", ttls: map[string]time.Duration{"default": time.Minute, "home": 5 * time.Minute}, vertical: "home", expectedValid: 5 * time.Minute},
+		{name: "Partner Expiry Without TTL", vertical: "auto", partnerExpiry: 30 * time.Second, expectedValid: 30 * time.Second},
+		{name: "No Expiry", vertical: "auto"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			bid := models.Bid{ID: "bid-1", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/1"}
+			if tc.partnerExpiry > 0 {
+				bid.ExpiresAt = time.Now().Add(tc.partnerExpiry).UTC().Truncate(time.Second)
+			}
+			cfg := newStrategyTestConfig()
+			cfg.MaxBidsPerRequest = 1
+			cfg.ResponseTTLs = tc.ttls
+			cfg.Partners = map[string]*config.PartnerConfig{
+				"partner-1": {ID: "partner-1", Endpoint: newPartnerServer(t, bid).URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+			}
+			require.NoError(t, cfg.Validate())
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+			router, _ := newTestRouter(t, service, cfg)
+
+			body, _ := json.Marshal(models.BidRequest{RequestID: "ttl-" + tc.name, LeadID: "lead-1", Vertical: tc.vertical})
+			req := httptest.NewRequest(http.MethodPost, "/v1/bids", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			started := time.Now()
+			router.ServeHTTP(w, req)
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+			var response models.BidResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Len(t, response.Bids, 1)
+			if tc.expectedValid == 0 {
+				assert.Nil(t, response.ValidUntil)
+				assert.True(t, response.Bids[0].ExpiresAt.IsZero())
+				assert.Equal(t, "no-cache, no-store, must-revalidate", w.Header().Get("Cache-Control"))
+				assert.Empty(t, w.Header().Get("Expires"))
+				return
+			}
+
+			require.NotNil(t, response.ValidUntil)
+			assert.WithinDuration(t, started.Add(tc.expectedValid), *response.ValidUntil, 2*time.Second)
+			assert.True(t, response.ValidUntil.Equal(response.Bids[0].ExpiresAt))
+			if tc.partnerExpiry > 0 && tc.partnerExpiry <= tc.expectedValid {
+				assert.True(t, bid.ExpiresAt.Equal(response.Bids[0].ExpiresAt), "partner expiry kept")
+			}
+			maxAge := maxAgeSeconds(t, w.Header().Get("Cache-Control"))
+			assert.InDelta(t, tc.expectedValid.Seconds(), float64(maxAge), 2)
+			assert.Equal(t, response.ValidUntil.UTC().Format(http.TimeFormat), w.Header().Get("Expires"))
+		})
+	}
+}
+
+// TestConfirmExpiredResponse tests that a reservation cannot outlive its response's valid_until
+// and that confirming it afterwards is rejected with the response_expired code
+func TestConfirmExpiredResponse(t *testing.T) {
+	testCases := []struct {
+		name           string
+		reservationTTL time.Duration
+		responseTTL    time.Duration
+		elapsed        time.Duration
+		expectedStatus int
+		expectedCode   string
+	}{
+		{name: "Confirmed While Valid", reservationTTL: time.Minute, responseTTL: 10 * time.Second, elapsed: 5 * time.Second, expectedStatus: http.StatusOK},
+		{name: "Response Expired", reservationTTL: time.Minute, responseTTL: 10 * time.Second, elapsed: 11 * time.Second, expectedStatus: http.StatusGone, expectedCode: "response_expired"},
+		{name: "Reservation Expired", reservationTTL: time.Minute, responseTTL: 5 * time.Minute, elapsed: 2 * time.Minute, expectedStatus: http.StatusGone, expectedCode: "reservation_expired"},
+	}
+
+	for _, store := range reservationStores {
+		for _, tc := range testCases {
+			t.Run(store+"/"+tc.name, func(t *testing.T) {
+				_, webhookServer := newWebhookReceiver(t, http.StatusOK)
+				cfg := newReservationTestConfig(t, store, tc.reservationTTL, webhookServer.URL)
+				// Confirmation abandons expired reservations itself; the sweeper never runs
+				cfg.Reservations.SweepInterval = time.Minute
+				cfg.ResponseTTLs = map[string]time.Duration{"default": tc.responseTTL}
+				clock := &steppingClock{now: time.Now().UTC().Truncate(time.Millisecond)}
+				service, err := services.NewAuctionServiceWithClock(cfg, clock)
+				require.NoError(t, err)
+				defer service.Close()
+				handler, err := handlers.NewBidHandler(service, cfg)
+				require.NoError(t, err)
+				router := gin.New()
+				router.POST("/v1/bids/confirm/:token", handler.HandleConfirmReservation)
+
+				reservation := reserveTestAuction(t, service, "ttl-reserve-"+strings.ReplaceAll(tc.name, " ", "-"))
+				require.NotNil(t, reservation.ValidUntil)
+				assert.Equal(t, clock.now.Add(tc.responseTTL), *reservation.ValidUntil)
+				expectedExpiry := clock.now.Add(tc.reservationTTL)
+				if tc.responseTTL < tc.reservationTTL {
+					expectedExpiry = clock.now.Add(tc.responseTTL)
+				}
+				assert.Equal(t, expectedExpiry, reservation.ExpiresAt)
+
+				clock.now = clock.now.Add(tc.elapsed)
+				// A repeated confirmation finds the reservation abandoned and answers the same way
+				for attempt := 0; attempt < 2; attempt++ {
+					req := httptest.NewRequest(http.MethodPost, "/v1/bids/confirm/"+reservation.Token, nil)
+					w := httptest.NewRecorder()
+					router.ServeHTTP(w, req)
+					if tc.expectedStatus == http.StatusOK {
+						require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+						break
+					}
+					require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+					var body map[string]string
+					require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+					assert.Equal(t, tc.expectedCode, body["code"])
+				}
+			})
+		}
+	}
+}
+
+// TestResponseTTLValidation tests the bounds on response TTLs
+func TestResponseTTLValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		ttls        map[string]time.Duration
+		expectedErr string
+	}{
+		{name: "Unset"},
+		{name: "Per Vertical", ttls: map[string]time.Duration{"default": time.Minute, "auto": 0, "home": time.Hour}},
+		{name: "Negative", ttls: map[string]time.Duration{"auto": -time.Second}, expectedErr: "response TTL for vertical auto must be between 0 and 24h0m0s: -1s"},
+		{name: "Too Long", ttls: map[string]time.Duration{"default": 48 * time.Hour}, expectedErr: "response TTL for vertical default must be between 0 and 24h0m0s: 48h0m0s"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.ResponseTTLs = tc.ttls
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
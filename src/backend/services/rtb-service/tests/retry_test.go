@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// resetConnection closes the client connection without a response
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err == nil {
+		conn.Close()
+	}
+}
+
+// newFlakyPartner starts a partner that fails its first failures calls with fail, then bids
+func newFlakyPartner(t *testing.T, failures int32, fail func(w http.ResponseWriter)) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			fail(w)
+			return
+		}
+		json.NewEncoder(w).Encode(models.Bid{ID: "bid-retry", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/retry"})
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// runRetryTestAuction runs an auction against a single partner with the given retry policy
+func runRetryTestAuction(t *testing.T, partnerID, endpoint string, timeout time.Duration, retry *config.RetryPolicy) (*models.BidResponse, error) {
+	service, err := services.NewAuctionService(newTestConfig(map[string]*config.PartnerConfig{
+		partnerID: {ID: partnerID, Endpoint: endpoint, APIKey: "key", Timeout: timeout, Enabled: true, Retry: retry},
+	}))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	return service.RunAuction(ctx, &models.BidRequest{RequestID: "retry-test", LeadID: "lead-1", Vertical: "auto"})
+}
+
+// TestPartnerRetryPolicy tests which failures are retried and that retries stop at the attempt limit
+func TestPartnerRetryPolicy(t *testing.T) {
+	retryAll := &config.RetryPolicy{
+		MaxAttempts: 3,
+		RetryOn:     []string{config.RetryOnConnectError, config.RetryOn5xx, config.RetryOn429},
+		BackoffBase: 5 * time.Millisecond,
+	}
+	status := func(code int) func(w http.ResponseWriter) {
+		return func(w http.ResponseWriter) { w.WriteHeader(code) }
+	}
+
+	testCases := []struct {
+		name          string
+		retry         *config.RetryPolicy
+		failures      int32
+		fail          func(w http.ResponseWriter)
+		expectBid     bool
+		expectedCalls int32
+	}{
+		{name: "Single Attempt By Default", retry: nil, failures: 1, fail: status(http.StatusServiceUnavailable), expectedCalls: 1},
+		{name: "Retry Server Error", retry: retryAll, failures: 1, fail: status(http.StatusServiceUnavailable), expectBid: true, expectedCalls: 2},
+		{name: "Retry Throttled", retry: retryAll, failures: 2, fail: status(http.StatusTooManyRequests), expectBid: true, expectedCalls: 3},
+		{name: "Retry Connection Reset", retry: retryAll, failures: 1, fail: resetConnection, expectBid: true, expectedCalls: 2},
+		{name: "Never Retry Validation Errors", retry: retryAll, failures: 1, fail: status(http.StatusBadRequest), expectedCalls: 1},
+		{
+			name:          "Condition Not Configured",
+			retry:         &config.RetryPolicy{MaxAttempts: 3, RetryOn: []string{config.RetryOn5xx}},
+			failures:      1,
+			fail:          status(http.StatusTooManyRequests),
+			expectedCalls: 1,
+		},
+		{name: "Attempt Limit", retry: retryAll, failures: 5, fail: status(http.StatusBadGateway), expectedCalls: 3},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			server, calls := newFlakyPartner(t, tc.failures, tc.fail)
+
+			response, err := runRetryTestAuction(t, "retry-partner", server.URL, 200*time.Millisecond, tc.retry)
+			if tc.expectBid {
+				require.NoError(t, err)
+				assert.Equal(t, "bid-retry", response.Bids[0].ID)
+			} else {
+				assert.Error(t, err)
+			}
+			assert.Equal(t, tc.expectedCalls, atomic.LoadInt32(calls))
+		})
+	}
+}
+
+// TestPartnerRetryBudget tests that retries give up rather than exceed the partner timeout
+func TestPartnerRetryBudget(t *testing.T) {
+	server, calls := newFlakyPartner(t, 100, func(w http.ResponseWriter) {
+		time.Sleep(40 * time.Millisecond)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	_, err := runRetryTestAuction(t, "budget-partner", server.URL, 100*time.Millisecond,
+		&config.RetryPolicy{MaxAttempts: 5, RetryOn: []string{config.RetryOn5xx}, BackoffBase: time.Millisecond})
+
+	// Each attempt takes 40ms, so the 100ms budget leaves room for at most three
+	assert.Error(t, err)
+	assert.LessOrEqual(t, atomic.LoadInt32(calls), int32(3))
+}
+
+// TestPartnerRetryMetrics tests that attempts and retry successes are counted per partner
+func TestPartnerRetryMetrics(t *testing.T) {
+	server, _ := newFlakyPartner(t, 1, func(w http.ResponseWriter) { w.WriteHeader(http.StatusInternalServerError) })
+	partner := map[string]string{"partner": "metrics-partner"}
+	attemptsBefore := gatheredMetric(t, "rtb_partner_attempts_total", partner)
+	successesBefore := gatheredMetric(t, "rtb_partner_retry_successes_total", partner)
+
+	_, err := runRetryTestAuction(t, "metrics-partner", server.URL, 200*time.Millisecond,
+		&config.RetryPolicy{MaxAttempts: 2, RetryOn: []string{config.RetryOn5xx}})
+	require.NoError(t, err)
+
+	assert.Equal(t, 2.0, gatheredMetric(t, "rtb_partner_attempts_total", partner)-attemptsBefore)
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_partner_retry_successes_total", partner)-successesBefore)
+}
+
+// TestRetryPolicyValidation tests retry policy bounds
+func TestRetryPolicyValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		retry       *config.RetryPolicy
+		expectedErr string
+	}{
+		{name: "Zero Value", retry: &config.RetryPolicy{}},
+		{name: "Valid", retry: &config.RetryPolicy{MaxAttempts: 3, RetryOn: []string{config.RetryOn5xx}, BackoffBase: 10 * time.Millisecond}},
+		{name: "Too Many Attempts", retry: &config.RetryPolicy{MaxAttempts: 6}, expectedErr: "max attempts"},
+		{name: "Backoff Exceeds Timeout", retry: &config.RetryPolicy{MaxAttempts: 2, BackoffBase: time.Second}, expectedErr: "retry backoff"},
+		{name: "Unknown Condition", retry: &config.RetryPolicy{MaxAttempts: 2, RetryOn: []string{"4xx"}}, expectedErr: "unknown retry condition"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].Retry = tc.retry
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
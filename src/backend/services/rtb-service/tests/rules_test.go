@@ -0,0 +1,258 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/rules"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// ruleTestEnv supplies rule fields from maps, leaving out fields without a value
+type ruleTestEnv struct {
+	numbers map[rules.Field]float64
+	strings map[rules.Field]string
+	data    map[string]interface{}
+}
+
+func (e *ruleTestEnv) Number(field rules.Field, key string) (float64, bool) {
+	if field == rules.FieldRequestUserData {
+		value, ok := e.data[key].(float64)
+		return value, ok
+	}
+	value, ok := e.numbers[field]
+	return value, ok
+}
+
+func (e *ruleTestEnv) String(field rules.Field, key string) (string, bool) {
+	if field == rules.FieldRequestUserData {
+		value, ok := e.data[key].(string)
+		return value, ok
+	}
+	value, ok := e.strings[field]
+	return value, ok
+}
+
+// newRuleTestEnv returns a Saturday noon bid of 4.50 from partner-x for an auto lead in CA
+func newRuleTestEnv() *ruleTestEnv {
+	return &ruleTestEnv{
+		numbers: map[rules.Field]float64{rules.FieldBidPrice: 4.5, rules.FieldBidQualityScore: 0.8, rules.FieldTimeHour: 12},
+		strings: map[rules.Field]string{
+			rules.FieldPartnerID:       "partner-x",
+			rules.FieldRequestVertical: "auto",
+			rules.FieldRequestRegion:   "CA",
+			rules.FieldTimeWeekday:     "sat",
+			rules.FieldBidDealID:       "",
+		},
+		data: map[string]interface{}{"age": 34.0, "homeowner": "yes"},
+	}
+}
+
+// TestRuleConditions tests that conditions compile and match as written, with AND binding
+// tighter than OR and comparisons of missing fields false
+func TestRuleConditions(t *testing.T) {
+	testCases := []struct {
+		name      string
+		condition string
+		expected  bool
+	}{
+		{name: "Weekend Floor", condition: `partner.id == "partner-x" AND request.vertical == "auto" AND time.weekday IN ["sat", "sun"] AND bid.price < 5`, expected: true},
+		{name: "Weekday", condition: `time.weekday IN ["mon", "tue", "wed", "thu", "fri"]`},
+		{name: "Keywords Any Case", condition: `bid.price >= 4.5 and not partner.id != "partner-x"`, expected: true},
+		{name: "AND Before OR", condition: `partner.id == "partner-y" AND bid.price < 5 OR request.region == "CA"`, expected: true},
+		{name: "Parentheses", condition: `partner.id == "partner-y" AND (bid.price < 5 OR request.region == "CA")`},
+		{name: "Number List", condition: `time.hour IN [11, 12, 13]`, expected: true},
+		{name: "Open Bid", condition: `bid.deal_id == ""`, expected: true},
+		{name: "User Data Number", condition: `request.user_data.age >= 18 AND request.user_data.age < 65`, expected: true},
+		{name: "User Data String", condition: `request.user_data.homeowner == "yes"`, expected: true},
+		{name: "User Data Wrong Type", condition: `request.user_data.homeowner == 1`},
+		{name: "Missing Field", condition: `request.country == "US"`},
+		{name: "Missing Field Not Equal", condition: `request.country != "US"`},
+		{name: "Missing Field Negated", condition: `NOT request.country == "US"`, expected: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			condition, err := rules.Compile(tc.condition)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, condition.Matches(newRuleTestEnv()))
+		})
+	}
+}
+
+// TestBidRuleValidation tests that rules are checked at config load, with conditions that do
+// not compile reported by rule ID and position
+func TestBidRuleValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		rules       config.RulesConfig
+		expectedErr string
+	}{
+		{
+			name: "Valid",
+			rules: config.RulesConfig{Timezone: "America/New_York", Bids: []config.BidRule{
+				{ID: "weekend-floor", When: `time.weekday IN ["sat", "sun"] AND bid.price < 5`, Action: config.RuleActionReject},
+				{ID: "boost_x", When: `partner.id == "partner-x"`, Action: config.RuleActionAdjustPrice, PriceMultiplier: 1.1},
+			}},
+		},
+		{
+			name:        "Unknown Field",
+			rules:       config.RulesConfig{Bids: []config.BidRule{{ID: "floor", When: `bid.price < 5 AND bid.prise < 5`, Action: config.RuleActionReject}}},
+			expectedErr: `invalid condition for bid rule floor: position 19: unknown field "bid.prise"`,
+		},
+		{
+			name:        "Type Mismatch",
+			rules:       config.RulesConfig{Bids: []config.BidRule{{ID: "floor", When: `bid.price < "5"`, Action: config.RuleActionReject}}},
+			expectedErr: `bid rule floor: position 13: bid.price is a number, found string "5"`,
+		},
+		{
+			name:        "String Ordering",
+			rules:       config.RulesConfig{Bids: []config.BidRule{{ID: "floor", When: `partner.id > "a"`, Action: config.RuleActionReject}}},
+			expectedErr: "position 12: strings can only be compared with == or !=",
+		},
+		{
+			name:        "Unclosed Parenthesis",
+			rules:       config.RulesConfig{Bids: []config.BidRule{{ID: "floor", When: `(bid.price < 5`, Action: config.RuleActionReject}}},
+			expectedErr: `position 15: expected ")", found end of condition`,
+		},
+		{
+			name:        "Unknown Weekday",
+			rules:       config.RulesConfig{Bids: []config.BidRule{{ID: "floor", When: `time.weekday == "saturday"`, Action: config.RuleActionReject}}},
+			expectedErr: `position 17: unknown weekday "saturday"`,
+		},
+		{
+			name:        "Empty Condition",
+			rules:       config.RulesConfig{Bids: []config.BidRule{{ID: "floor", Action: config.RuleActionReject}}},
+			expectedErr: "bid rule floor: position 1: empty condition",
+		},
+		{
+			name:        "Unknown Action",
+			rules:       config.RulesConfig{Bids: []config.BidRule{{ID: "floor", When: `bid.price < 5`, Action: "drop"}}},
+			expectedErr: `unknown action "drop" for bid rule floor`,
+		},
+		{
+			name:        "Adjust Without Multiplier",
+			rules:       config.RulesConfig{Bids: []config.BidRule{{ID: "boost", When: `bid.price < 5`, Action: config.RuleActionAdjustPrice}}},
+			expectedErr: "bid rule boost needs a positive price multiplier",
+		},
+		{
+			name:        "Invalid ID",
+			rules:       config.RulesConfig{Bids: []config.BidRule{{ID: "Weekend Floor", When: `bid.price < 5`, Action: config.RuleActionReject}}},
+			expectedErr: `bid rule 1 has invalid id "Weekend Floor"`,
+		},
+		{
+			name: "Duplicate ID",
+			rules: config.RulesConfig{Bids: []config.BidRule{
+				{ID: "floor", When: `bid.price < 5`, Action: config.RuleActionReject},
+				{ID: "floor", When: `bid.price < 6`, Action: config.RuleActionReject},
+			}},
+			expectedErr: "duplicate bid rule id floor",
+		},
+		{name: "Invalid Timezone", rules: config.RulesConfig{Timezone: "Mars/Olympus"}, expectedErr: "invalid rules timezone"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Rules = &tc.rules
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+// TestBidRules tests that rules accept, reject, and reprice bids in order as they arrive, with
+// rejected bids losing to the rule's ID
+func TestBidRules(t *testing.T) {
+	// A Saturday in Eastern time, though already Sunday in UTC
+	saturday := time.Date(2024, 6, 16, 1, 0, 0, 0, time.UTC)
+	weekendFloor := config.BidRule{ID: "weekend-floor", When: `partner.id == "partner-x" AND request.vertical == "auto" AND time.weekday == "sat" AND bid.price < 5`, Action: config.RuleActionReject}
+
+	testCases := []struct {
+		name           string
+		rules          []config.BidRule
+		expectedWinner string
+		expectedPrice  float64
+		expectedLoss   models.Reason
+	}{
+		{name: "No Rules", expectedWinner: "partner-x", expectedPrice: 4.5},
+		{name: "Reject", rules: []config.BidRule{weekendFloor}, expectedWinner: "partner-y", expectedPrice: 3.0, expectedLoss: models.RuleReason("weekend-floor")},
+		{
+			name:           "Accept First",
+			rules:          []config.BidRule{{ID: "trusted", When: `bid.quality_score >= 0.8`, Action: config.RuleActionAccept}, weekendFloor},
+			expectedWinner: "partner-x",
+			expectedPrice:  4.5,
+		},
+		{
+			name:           "Adjust Then Reject",
+			rules:          []config.BidRule{{ID: "boost-y", When: `partner.id == "partner-y"`, Action: config.RuleActionAdjustPrice, PriceMultiplier: 2}, weekendFloor},
+			expectedWinner: "partner-y",
+			expectedPrice:  6.0,
+			expectedLoss:   models.RuleReason("weekend-floor"),
+		},
+		{
+			name:           "Adjusted Above Rule",
+			rules:          []config.BidRule{{ID: "boost-x", When: `partner.id == "partner-x"`, Action: config.RuleActionAdjustPrice, PriceMultiplier: 1.2}, weekendFloor},
+			expectedWinner: "partner-x",
+			expectedPrice:  5.4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			x := newPartnerServer(t, models.Bid{ID: "bid-x", Price: 4.5, QualityScore: 0.8, ClickURL: "http://example.com/x"})
+			y := newPartnerServer(t, models.Bid{ID: "bid-y", Price: 3.0, QualityScore: 0.8, ClickURL: "http://example.com/y"})
+			cfg := newTestConfig(map[string]*config.PartnerConfig{
+				"partner-x": {ID: "partner-x", Endpoint: x.URL, APIKey: "key-x", Timeout: 200 * time.Millisecond, Enabled: true},
+				"partner-y": {ID: "partner-y", Endpoint: y.URL, APIKey: "key-y", Timeout: 200 * time.Millisecond, Enabled: true},
+			})
+			cfg.Rules = &config.RulesConfig{Timezone: "America/New_York", Bids: tc.rules}
+			require.NoError(t, cfg.Validate())
+			service, err := services.NewAuctionServiceWithClock(cfg, fixedClock{now: saturday})
+			require.NoError(t, err)
+			defer service.Close()
+			lossesBefore := gatheredMetric(t, "rtb_bid_losses_total", map[string]string{"partner": "partner-x", "reason": string(models.RuleReason("weekend-floor"))})
+
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			debug := models.NewDebugInfo()
+			response, err := service.RunAuction(models.ContextWithDebug(ctx, debug), &models.BidRequest{RequestID: "rules-" + tc.name, LeadID: "lead-1", Vertical: "auto"})
+			require.NoError(t, err)
+
+			require.Len(t, response.Bids, 1)
+			assert.Equal(t, tc.expectedWinner, response.Bids[0].PartnerID)
+			assert.InDelta(t, tc.expectedPrice, response.Bids[0].Price, 1e-9)
+			partnerDebug, _ := debug.Partner("partner-x")
+			if tc.expectedLoss == "" {
+				assert.Empty(t, partnerDebug.Losses)
+				return
+			}
+			assert.Equal(t, tc.expectedLoss, partnerDebug.Losses["bid-x"])
+			assert.True(t, tc.expectedLoss.IsValid())
+			assert.Equal(t, lossesBefore+1, gatheredMetric(t, "rtb_bid_losses_total", map[string]string{"partner": "partner-x", "reason": string(tc.expectedLoss)}))
+			assert.Positive(t, gatheredMetric(t, "rtb_bid_rule_matches_total", map[string]string{"rule": "weekend-floor", "action": config.RuleActionReject}))
+		})
+	}
+}
+
+// TestBidRuleEvaluationCost tests that checking a bid against a realistic rule set stays under
+// 10µs and allocates nothing
+func TestBidRuleEvaluationCost(t *testing.T) {
+	if testing.Short() {
+		t.Skip("benchmark")
+	}
+	result := testing.Benchmark(BenchmarkBidRules)
+	assert.Less(t, result.NsPerOp(), int64(10000))
+	assert.Zero(t, result.AllocsPerOp())
+}
@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// fixtureScorer replays scoring responses recorded from the model service, keyed by request ID
+type fixtureScorer struct {
+	Responses map[string]struct {
+		Scores map[string]float64 `json:"scores"`
+	} `json:"responses"`
+}
+
+func (f *fixtureScorer) ScoreBids(ctx context.Context, request *models.BidRequest, bids []*models.Bid) (map[string]float64, error) {
+	response, exists := f.Responses[request.RequestID]
+	if !exists {
+		return nil, errors.New("no recorded scoring response")
+	}
+	return response.Scores, nil
+}
+
+// failingScorer always returns an error
+type failingScorer struct{}
+
+func (failingScorer) ScoreBids(ctx context.Context, request *models.BidRequest, bids []*models.Bid) (map[string]float64, error) {
+	return nil, errors.New("model unavailable")
+}
+
+// loadFixtureScorer loads the recorded scoring fixture
+func loadFixtureScorer(t *testing.T) *fixtureScorer {
+	data, err := os.ReadFile("testdata/scoring_fixture.json")
+	require.NoError(t, err)
+
+	var scorer fixtureScorer
+	require.NoError(t, json.Unmarshal(data, &scorer))
+	return &scorer
+}
+
+// newScoringTestService creates an auction service with two partners, where partner-a wins on price alone
+func newScoringTestService(t *testing.T, scoring *config.ScoringConfig) *services.AuctionService {
+	partnerA := newPartnerServer(t, models.Bid{ID: "bid-a", Price: 10.0, QualityScore: 0.2, ClickURL: "http://example.com/a"})
+	partnerB := newPartnerServer(t, models.Bid{ID: "bid-b", Price: 9.0, QualityScore: 0.3, ClickURL: "http://example.com/b"})
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-a": {ID: "partner-a", Endpoint: partnerA.URL, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true},
+		"partner-b": {ID: "partner-b", Endpoint: partnerB.URL, APIKey: "key-b", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.Scoring = scoring
+
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	return service
+}
+
+// runScoringTestAuction runs an auction and returns the winning bid ID
+func runScoringTestAuction(t *testing.T, service *services.AuctionService) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "scoring-test-1", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	require.Len(t, response.Bids, 1)
+	return response.Bids[0].ID
+}
+
+// TestScoringReranksBids tests that recorded model scores replace quality scores and change the winner
+func TestScoringReranksBids(t *testing.T) {
+	service := newScoringTestService(t, &config.ScoringConfig{Timeout: 50 * time.Millisecond, BlendWeight: 1.0})
+	assert.Equal(t, "bid-a", runScoringTestAuction(t, service))
+
+	service.SetScoringService(loadFixtureScorer(t))
+	assert.Equal(t, "bid-b", runScoringTestAuction(t, service))
+}
+
+// TestScoringFallback tests that scorer errors and timeouts never fail the auction
+func TestScoringFallback(t *testing.T) {
+	slowScorer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(200 * time.Millisecond):
+		}
+		w.Write([]byte(`{"scores":{"bid-b":1.0}}`))
+	}))
+	defer slowScorer.Close()
+
+	testCases := []struct {
+		name   string
+		scorer services.ScoringService
+	}{
+		{name: "Scorer Error", scorer: failingScorer{}},
+		{name: "Scorer Timeout", scorer: services.NewHTTPScoringService(&config.ScoringConfig{
+			Endpoint: slowScorer.URL,
+			Timeout:  20 * time.Millisecond,
+		})},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service := newScoringTestService(t, &config.ScoringConfig{Timeout: 20 * time.Millisecond, BlendWeight: 1.0})
+			service.SetScoringService(tc.scorer)
+
+			assert.Equal(t, "bid-a", runScoringTestAuction(t, service))
+		})
+	}
+}
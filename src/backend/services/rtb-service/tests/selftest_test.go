@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// selfTestReadiness is the part of a readiness report the self-test tests check
+type selfTestReadiness struct {
+	Checks map[string]struct {
+		Status string `json:"status"`
+		Detail string `json:"detail"`
+	} `json:"checks"`
+}
+
+// newSelfTestRouter serves readiness and the admin endpoints for cfg with a shared self-test,
+// keeping every simulated partner's bid
+func newSelfTestRouter(t *testing.T, cfg *config.Config) (*gin.Engine, *handlers.SelfTest) {
+	cfg.MaxBidsPerRequest = 3
+	cfg.Admin = &config.AdminConfig{Enabled: true, APIKeys: []string{testAdminKey}}
+	service := newTestService(t, cfg)
+
+	bidHandler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+	admin, err := handlers.NewAdminHandler(service, cfg, handlers.BuildInfo{})
+	require.NoError(t, err)
+	selfTest, err := handlers.NewSelfTest(cfg)
+	require.NoError(t, err)
+	bidHandler.SetSelfTest(selfTest)
+	admin.SetSelfTest(selfTest)
+
+	router := gin.New()
+	router.GET("/readyz", bidHandler.HandleReadiness)
+	admin.RegisterRoutes(router.Group("/admin"))
+	return router, selfTest
+}
+
+// TestSelfTest tests that the self-test's outcome gates readiness only when the startup self-test is enabled
+func TestSelfTest(t *testing.T) {
+	testCases := []struct {
+		name              string
+		selfTest          *config.SelfTestConfig
+		minBidders        map[string]int
+		run               bool
+		expectedPassed    bool
+		expectedSteps     []string
+		expectedStatus    int
+		expectedReadiness string
+		expectedDetail    string
+	}{
+		{
+			name:              "Passing Run",
+			selfTest:          &config.SelfTestConfig{Enabled: true, Auctions: 2},
+			run:               true,
+			expectedPassed:    true,
+			expectedSteps:     []string{"partners", "service", "metrics", "auction", "winners", "metrics", "serialization", "metrics", "auction", "winners", "metrics", "serialization"},
+			expectedStatus:    http.StatusOK,
+			expectedReadiness: "ok",
+		},
+		{
+			name:              "Failing Run",
+			selfTest:          &config.SelfTestConfig{Enabled: true, Auctions: 2},
+			minBidders:        map[string]int{"selftest": 4},
+			run:               true,
+			expectedSteps:     []string{"partners", "service", "metrics", "auction"},
+			expectedStatus:    http.StatusServiceUnavailable,
+			expectedReadiness: "fail",
+			expectedDetail:    "auction step failed on auction 1: " + services.ErrInsufficientCompetition.Error(),
+		},
+		{
+			name:              "Not Yet Run",
+			selfTest:          &config.SelfTestConfig{Enabled: true, Auctions: 2},
+			expectedStatus:    http.StatusServiceUnavailable,
+			expectedReadiness: "fail",
+			expectedDetail:    "self-test has not run",
+		},
+		{
+			name:              "Disabled",
+			minBidders:        map[string]int{"selftest": 4},
+			run:               true,
+			expectedSteps:     []string{"partners", "service", "metrics", "auction"},
+			expectedStatus:    http.StatusOK,
+			expectedReadiness: "skipped",
+			expectedDetail:    "self-test not enabled",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.SelfTest = tc.selfTest
+			cfg.MinBidders = tc.minBidders
+			router, selfTest := newSelfTestRouter(t, cfg)
+
+			if tc.run {
+				report := selfTest.Run(context.Background())
+				assert.Equal(t, tc.expectedPassed, report.Passed)
+				steps := make([]string, 0, len(report.Steps))
+				for _, step := range report.Steps {
+					steps = append(steps, step.Name)
+				}
+				assert.Equal(t, tc.expectedSteps, steps)
+				assert.Same(t, report, selfTest.Last())
+			}
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			assert.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			var readiness selfTestReadiness
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &readiness))
+			assert.Equal(t, tc.expectedReadiness, readiness.Checks["selftest"].Status)
+			if tc.expectedDetail != "" {
+				assert.Equal(t, tc.expectedDetail, readiness.Checks["selftest"].Detail)
+			}
+		})
+	}
+}
+
+// TestSelfTestEndpoint tests that the admin endpoint runs the self-test on demand and answers 503 when it fails
+func TestSelfTestEndpoint(t *testing.T) {
+	testCases := []struct {
+		name           string
+		minBidders     map[string]int
+		expectedStatus int
+		expectedPassed bool
+	}{
+		{name: "Passing Run", expectedStatus: http.StatusOK, expectedPassed: true},
+		{name: "Failing Run", minBidders: map[string]int{"selftest": 4}, expectedStatus: http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.MinBidders = tc.minBidders
+			router, selfTest := newSelfTestRouter(t, cfg)
+
+			req := httptest.NewRequest(http.MethodPost, "/admin/selftest", nil)
+			req.Header.Set("X-Admin-Key", testAdminKey)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Equal(t, tc.expectedStatus, w.Code, w.Body.String())
+			var report handlers.SelfTestReport
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, tc.expectedPassed, report.Passed)
+			assert.Len(t, report.Steps, len(selfTest.Last().Steps))
+		})
+	}
+}
+
+// TestSelfTestSynthetic tests that self-test winners count only under the synthetic traffic label
+// and that synthetic auctions leave no side effects
+func TestSelfTestSynthetic(t *testing.T) {
+	cfg := newStrategyTestConfig()
+	cfg.SelfTest = &config.SelfTestConfig{Enabled: true, Auctions: 3}
+	_, selfTest := newSelfTestRouter(t, cfg)
+
+	live := gatheredMetric(t, "rtb_successful_bids_total", map[string]string{"traffic": "live"})
+	synthetic := gatheredMetric(t, "rtb_successful_bids_total", map[string]string{"traffic": "synthetic"})
+	require.True(t, selfTest.Run(context.Background()).Passed)
+	assert.Equal(t, live, gatheredMetric(t, "rtb_successful_bids_total", map[string]string{"traffic": "live"}))
+	assert.Equal(t, synthetic+9, gatheredMetric(t, "rtb_successful_bids_total", map[string]string{"traffic": "synthetic"}))
+
+	// Synthetic auctions on any service run as dry runs
+	partner := newPartnerServer(t, models.Bid{ID: "bid-1", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	cfg = newStrategyTestConfig()
+	cfg.MaxBidsPerRequest = 1
+	cfg.Partners = map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	ctx, cancel := context.WithTimeout(models.ContextWithSynthetic(context.Background()), cfg.BidTimeout)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "synthetic-1", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	require.Len(t, response.Bids, 1)
+	assert.NotNil(t, response.DryRun)
+	report, err := service.PartnerReport("partner-1", time.Hour)
+	require.NoError(t, err)
+	assert.Zero(t, report.Calls)
+}
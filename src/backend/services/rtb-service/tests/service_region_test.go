@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/replay"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// TestPartnerPreferredRegions tests that partners preferring other service regions are skipped
+// unless no partner preferring this one is eligible
+func TestPartnerPreferredRegions(t *testing.T) {
+	testCases := []struct {
+		name       string
+		region     string
+		preferredA []string
+		preferredB []string
+		winner     string
+		skipReason models.Reason
+	}{
+		{name: "Preferred Region", region: "us-east", preferredA: []string{"us-east"}, winner: "bid-a"},
+		{name: "Other Region", region: "us-west", preferredA: []string{"us-east"}, winner: "bid-b", skipReason: models.ReasonRegionMismatch},
+		{name: "One Of Several", region: "us-west", preferredA: []string{"us-east", "us-west"}, winner: "bid-a"},
+		{name: "No Regional Partner", region: "us-west", preferredA: []string{"us-east"}, preferredB: []string{"eu-west"}, winner: "bid-a"},
+		{name: "No Service Region", preferredA: []string{"us-east"}, winner: "bid-a"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partnerA := newPartnerServer(t, models.Bid{ID: "bid-a", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/a"})
+			partnerB := newPartnerServer(t, models.Bid{ID: "bid-b", Price: 9.0, QualityScore: 0.5, ClickURL: "http://example.com/b"})
+			cfg := newTestConfig(map[string]*config.PartnerConfig{
+				"partner-a": {ID: "partner-a", Endpoint: partnerA.URL, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true,
+					PreferredRegions: tc.preferredA},
+				"partner-b": {ID: "partner-b", Endpoint: partnerB.URL, APIKey: "key-b", Timeout: 200 * time.Millisecond, Enabled: true,
+					PreferredRegions: tc.preferredB},
+			})
+			cfg.ServiceRegion = tc.region
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			response := runTargetingTestAuction(t, service, &models.BidRequest{LeadID: "lead-1", Vertical: "auto"})
+			assert.Equal(t, tc.winner, response.Bids[0].ID)
+			partner, _ := response.Debug.Partner("partner-a")
+			assert.Equal(t, tc.skipReason, partner.SkipReason)
+		})
+	}
+}
+
+// TestServiceRegionTagging tests that the service region reaches partner requests, webhook
+// events, health, and metrics
+func TestServiceRegionTagging(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	var (
+		mutex   sync.Mutex
+		regions []string
+	)
+	partner := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		regions = append(regions, r.Header.Get(services.RegionHeader))
+		mutex.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "bid-1", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	}))
+	t.Cleanup(partner.Close)
+	receiver, webhookServer := newWebhookReceiver(t, http.StatusOK)
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: webhookServer.URL})
+	cfg.ServiceRegion = "us-east"
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	bidHandler, err := handlers.NewBidHandler(service, cfg)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = service.RunAuction(ctx, &models.BidRequest{RequestID: "region-1", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+
+	mutex.Lock()
+	assert.Equal(t, []string{"us-east"}, regions)
+	mutex.Unlock()
+	require.Eventually(t, func() bool { return len(receiver.received()) == 2 }, time.Second, 5*time.Millisecond)
+	for _, delivery := range receiver.received() {
+		assert.Equal(t, "us-east", delivery.event.Region, delivery.event.Type)
+	}
+
+	router := gin.New()
+	router.GET("/health", bidHandler.HandleHealthCheck)
+	router.GET("/metrics", gin.WrapH(handlers.MetricsHandler(cfg.ServiceRegion)))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Contains(t, w.Body.String(), `"region":"us-east"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Regexp(t, `rtb_partner_attempts_total\{[^}]*service_region="us-east"`, w.Body.String())
+}
+
+// TestServiceRegionReplay tests that recordings capture their service region and replay as it,
+// whichever region replays them
+func TestServiceRegionReplay(t *testing.T) {
+	cfg := newReplayTestConfig(t, filepath.Join(t.TempDir(), "auctions.jsonl"))
+	cfg.ServiceRegion = "us-east"
+	cfg.Partners["high"].PreferredRegions = []string{"us-east"}
+	cfg.Partners["low"].PreferredRegions = []string{"us-west"}
+	recordings := recordTestAuctions(t, cfg, "auto")
+	require.Len(t, recordings, 1)
+	assert.Equal(t, "us-east", recordings[0].Region)
+	require.Len(t, recordings[0].Winners, 1)
+	assert.Equal(t, "high", recordings[0].Winners[0].PartnerID)
+
+	cfg.ServiceRegion = "us-west"
+	result, err := replay.NewReplayer(cfg).Replay(context.Background(), recordings[0])
+	require.NoError(t, err)
+	assert.Equal(t, "us-east", result.Region)
+	assert.False(t, result.Diff.Changed)
+}
+
+// TestServiceRegionValidation tests service region and preferred region names
+func TestServiceRegionValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		region      string
+		preferred   []string
+		expectedErr string
+	}{
+		{name: "Valid", region: "us-east", preferred: []string{"us-east", "us-west-2"}},
+		{name: "Unset", preferred: []string{"us-east"}},
+		{name: "Uppercase Region", region: "US-East", expectedErr: "invalid service region"},
+		{name: "Region With Space", region: "us east", expectedErr: "invalid service region"},
+		{name: "Empty Preferred Region", region: "us-east", preferred: []string{""}, expectedErr: "invalid preferred region"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.ServiceRegion = tc.region
+			cfg.Partners["partner-1"].PreferredRegions = tc.preferred
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
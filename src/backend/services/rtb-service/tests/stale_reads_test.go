@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"    // v2.31.0
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// latencyProxy forwards connections to a Redis server, holding every command for delay
+type latencyProxy struct {
+	listener net.Listener
+	delay    atomic.Int64
+}
+
+// newLatencyProxy starts a proxy to addr, closed when the test ends
+func newLatencyProxy(t *testing.T, addr string) *latencyProxy {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	proxy := &latencyProxy{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go proxy.forward(conn, addr)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+	return proxy
+}
+
+// forward copies commands from client to the server after the current delay, and replies back
+func (p *latencyProxy) forward(client net.Conn, addr string) {
+	defer client.Close()
+	server, err := net.Dial("tcp", addr)
+	if err != nil {
+		return
+	}
+	defer server.Close()
+	go func() {
+		io.Copy(client, server)
+		client.Close()
+	}()
+	buffer := make([]byte, 32*1024)
+	for {
+		n, err := client.Read(buffer)
+		if n > 0 {
+			time.Sleep(time.Duration(p.delay.Load()))
+			if _, err := server.Write(buffer[:n]); err != nil {
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// redisConfig returns a Redis config pointing at the proxy with stale reads on
+func (p *latencyProxy) redisConfig(t *testing.T, staleReads *config.StaleReadsConfig) *config.RedisConfig {
+	host, portText, err := net.SplitHostPort(p.listener.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portText)
+	require.NoError(t, err)
+	return &config.RedisConfig{Host: host, Port: port, Timeout: time.Second, StaleReads: staleReads}
+}
+
+// staleReadCase is an auction whose partner is skipped on a value read from Redis: a cached no-bid
+// or a spent budget
+type staleReadCase struct {
+	name     string
+	cache    string
+	config   func(t *testing.T) *config.Config
+	request  models.BidRequest
+	warmups  int
+	skipped  string
+	reason   models.Reason
+	fallback models.Reason // the skip reason once the cached value expires with Redis down
+}
+
+// staleReadCases returns the Redis-backed reads served stale
+func staleReadCases() []staleReadCase {
+	return []staleReadCase{
+		{
+			name:  "Negative Cache",
+			cache: "negative_cache",
+			config: func(t *testing.T) *config.Config {
+				sparse, _ := newNegativeCachePartner(t, http.StatusNoContent, "", 0)
+				cfg, _ := newNegativeCacheTestConfig(t, "Memory", sparse.URL, nil)
+				return cfg
+			},
+			request: models.BidRequest{LeadID: "lead-1", Vertical: "auto", UserData: map[string]interface{}{"risk_tier": "sr22"}},
+			warmups: 1,
+			skipped: "sparse",
+			reason:  models.ReasonNegativeCached,
+		},
+		{
+			name:  "Spent Budget",
+			cache: "budget",
+			config: func(t *testing.T) *config.Config {
+				cfg := newBudgetTestConfig(t)
+				cfg.Partners["budgeted"].DailyBudget = 30.0
+				return cfg
+			},
+			request:  models.BidRequest{LeadID: "lead-1", Vertical: "auto"},
+			warmups:  3,
+			skipped:  "budgeted",
+			reason:   models.ReasonBudgetSpent,
+			fallback: models.ReasonBudgetUnavailable,
+		},
+	}
+}
+
+// runStaleReadAuction runs request and returns its debug output and how long it took
+func runStaleReadAuction(t *testing.T, service *services.AuctionService, request models.BidRequest) (*models.DebugInfo, time.Duration) {
+	request.RequestID = "stale-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	debug := models.NewDebugInfo()
+	ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), time.Second)
+	defer cancel()
+	started := time.Now()
+	_, err := service.RunAuction(ctx, &request)
+	require.NoError(t, err)
+	return debug, time.Since(started)
+}
+
+// p99 returns the 99th percentile of durations
+func p99(durations []time.Duration) time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[(len(sorted)*99-1)/100]
+}
+
+// TestStaleReadsRedisLatency tests that with stale reads on, a Redis latency spike leaves auction
+// p99 where it was: skips are served from memory while refreshes wait on Redis in the background
+func TestStaleReadsRedisLatency(t *testing.T) {
+	const auctions, redisDelay = 20, 200 * time.Millisecond
+
+	for _, tc := range staleReadCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			proxy := newLatencyProxy(t, miniredis.RunT(t).Addr())
+			cfg := tc.config(t)
+			cfg.Redis = proxy.redisConfig(t, &config.StaleReadsConfig{Enabled: true, FreshFor: 20 * time.Millisecond, HardTTL: time.Minute})
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			for i := 0; i < tc.warmups; i++ {
+				runStaleReadAuction(t, service, tc.request)
+			}
+			measure := func() time.Duration {
+				durations := make([]time.Duration, auctions)
+				for i := range durations {
+					debug, elapsed := runStaleReadAuction(t, service, tc.request)
+					decisions, _ := debug.Partner(tc.skipped)
+					require.Equal(t, tc.reason, decisions.SkipReason)
+					durations[i] = elapsed
+					time.Sleep(5 * time.Millisecond)
+				}
+				return p99(durations)
+			}
+			baseline := measure()
+
+			stale := gatheredMetric(t, "rtb_redis_stale_reads_total", map[string]string{"cache": tc.cache, "result": "stale"})
+			proxy.delay.Store(int64(redisDelay))
+			spiked := measure()
+			proxy.delay.Store(0)
+
+			assert.Less(t, spiked, baseline+redisDelay/4, "baseline p99 %v", baseline)
+			assert.Greater(t, gatheredMetric(t, "rtb_redis_stale_reads_total", map[string]string{"cache": tc.cache, "result": "stale"}), stale)
+		})
+	}
+}
+
+// TestStaleReadsRedisOutage tests that with Redis down, cached values are served until their hard
+// TTL and every read then falls back as if Redis were unavailable
+func TestStaleReadsRedisOutage(t *testing.T) {
+	for _, tc := range staleReadCases() {
+		t.Run(tc.name, func(t *testing.T) {
+			redisServer := miniredis.RunT(t)
+			cfg := tc.config(t)
+			useTestRedis(t, cfg, redisServer)
+			cfg.Redis.StaleReads = &config.StaleReadsConfig{Enabled: true, FreshFor: time.Second, HardTTL: time.Minute}
+			clock := &hygieneClock{now: time.Date(2024, 3, 4, 12, 0, 0, 0, time.UTC)}
+			service, err := services.NewAuctionServiceWithClock(cfg, clock)
+			require.NoError(t, err)
+			defer service.Close()
+
+			for i := 0; i < tc.warmups; i++ {
+				runStaleReadAuction(t, service, tc.request)
+			}
+			redisServer.Close()
+			failures := gatheredMetric(t, "rtb_redis_stale_refreshes_total", map[string]string{"cache": tc.cache, "result": "error"})
+
+			clock.advance(2 * time.Second)
+			debug, _ := runStaleReadAuction(t, service, tc.request)
+			decisions, _ := debug.Partner(tc.skipped)
+			assert.Equal(t, tc.reason, decisions.SkipReason, "a stale value is served while Redis is down")
+			require.Eventually(t, func() bool {
+				return gatheredMetric(t, "rtb_redis_stale_refreshes_total", map[string]string{"cache": tc.cache, "result": "error"}) > failures
+			}, 5*time.Second, 10*time.Millisecond)
+
+			clock.advance(time.Minute)
+			debug, _ = runStaleReadAuction(t, service, tc.request)
+			decisions, _ = debug.Partner(tc.skipped)
+			assert.Equal(t, tc.fallback, decisions.SkipReason, "an expired value falls back")
+		})
+	}
+}
+
+// TestStaleReadsValidation tests that the hard TTL must outlast the freshness window
+func TestStaleReadsValidation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		staleReads    *config.StaleReadsConfig
+		expectedError bool
+	}{
+		{name: "Defaults", staleReads: &config.StaleReadsConfig{Enabled: true}},
+		{name: "Disabled", staleReads: &config.StaleReadsConfig{FreshFor: time.Minute, HardTTL: time.Second}},
+		{name: "Hard TTL Within Freshness", staleReads: &config.StaleReadsConfig{Enabled: true, FreshFor: time.Minute, HardTTL: time.Minute}, expectedError: true},
+		{name: "Freshness Past Default Hard TTL", staleReads: &config.StaleReadsConfig{Enabled: true, FreshFor: time.Hour}, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Redis = &config.RedisConfig{Host: "localhost", Port: 6379, Timeout: time.Second, StaleReads: tc.staleReads}
+			if tc.expectedError {
+				assert.ErrorContains(t, cfg.Validate(), "stale reads")
+			} else {
+				assert.NoError(t, cfg.Validate())
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package tests
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newTargetingTestService creates an auction service where partner-a wins on price alone
+// unless its targeting rules apply
+func newTargetingTestService(t *testing.T, targeting func(partner *config.PartnerConfig)) *services.AuctionService {
+	partnerA := newPartnerServer(t, models.Bid{ID: "bid-a", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/a"})
+	partnerB := newPartnerServer(t, models.Bid{ID: "bid-b", Price: 9.0, QualityScore: 0.5, ClickURL: "http://example.com/b"})
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-a": {ID: "partner-a", Endpoint: partnerA.URL, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true},
+		"partner-b": {ID: "partner-b", Endpoint: partnerB.URL, APIKey: "key-b", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	targeting(cfg.Partners["partner-a"])
+
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	return service
+}
+
+// runTargetingTestAuction runs a debug-enabled auction and returns the response
+func runTargetingTestAuction(t *testing.T, service *services.AuctionService, request *models.BidRequest) *models.BidResponse {
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	request.RequestID = "targeting-test"
+	response, err := service.RunAuction(models.ContextWithDebug(ctx, models.NewDebugInfo()), request)
+	require.NoError(t, err)
+	require.Len(t, response.Bids, 1)
+	return response
+}
+
+// TestPartnerRegionFilter tests that partners only receive requests from their allowed regions
+func TestPartnerRegionFilter(t *testing.T) {
+	service := newTargetingTestService(t, func(partner *config.PartnerConfig) {
+		partner.AllowedRegions = []string{"CA", "TX", "FL"}
+	})
+
+	testCases := []struct {
+		name   string
+		geo    *models.Geo
+		winner string
+	}{
+		{name: "Allowed Region", geo: &models.Geo{Country: "US", Region: "tx"}, winner: "bid-a"},
+		{name: "Excluded Region", geo: &models.Geo{Country: "US", Region: "NY"}, winner: "bid-b"},
+		{name: "Unknown Region", geo: nil, winner: "bid-b"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response := runTargetingTestAuction(t, service, &models.BidRequest{LeadID: "lead-1", Vertical: "auto", Geo: tc.geo})
+			assert.Equal(t, tc.winner, response.Bids[0].ID)
+
+			partner, _ := response.Debug.Partner("partner-a")
+			if tc.winner == "bid-b" {
+				assert.Equal(t, models.ReasonGeoExcluded, partner.SkipReason)
+			} else {
+				assert.Empty(t, partner.SkipReason)
+			}
+		})
+	}
+}
+
+// TestPartnerDeviceMultiplier tests that device multipliers adjust effective price and appear in debug output
+func TestPartnerDeviceMultiplier(t *testing.T) {
+	service := newTargetingTestService(t, func(partner *config.PartnerConfig) {
+		partner.DeviceMultipliers = map[string]float64{models.DeviceTypeMobile: 0.5}
+	})
+
+	desktop := runTargetingTestAuction(t, service, &models.BidRequest{LeadID: "lead-1", Vertical: "auto",
+		Device: &models.Device{Type: models.DeviceTypeDesktop}})
+	assert.Equal(t, "bid-a", desktop.Bids[0].ID)
+
+	mobile := runTargetingTestAuction(t, service, &models.BidRequest{LeadID: "lead-1", Vertical: "auto",
+		Device: &models.Device{Type: models.DeviceTypeMobile}})
+	assert.Equal(t, "bid-b", mobile.Bids[0].ID)
+
+	partner, exists := mobile.Debug.Partner("partner-a")
+	require.True(t, exists)
+	assert.Equal(t, 0.5, partner.Multipliers["device"])
+	assert.Equal(t, 1.0, partner.Multipliers["vertical"])
+}
+
+// TestPartnerTargetingValidation tests that device multipliers are bounded and keyed by known device types
+func TestPartnerTargetingValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		multipliers map[string]float64
+		expectedErr string
+	}{
+		{name: "Valid", multipliers: map[string]float64{"mobile": 1.2, "tablet": 0.8}},
+		{name: "Unknown Device", multipliers: map[string]float64{"phone": 1.2}, expectedErr: "unknown device type"},
+		{name: "Multiplier Too Low", multipliers: map[string]float64{"desktop": 0.05}, expectedErr: "invalid multiplier"},
+		{name: "Multiplier Too High", multipliers: map[string]float64{"bot": 11}, expectedErr: "invalid multiplier"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Partners["partner-1"].DeviceMultipliers = tc.multipliers
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
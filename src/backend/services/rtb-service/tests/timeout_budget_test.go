@@ -0,0 +1,132 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// blockingScorer never answers before its context ends
+type blockingScorer struct{}
+
+func (blockingScorer) ScoreBids(ctx context.Context, request *models.BidRequest, bids []*models.Bid) (map[string]float64, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// TestTimeoutBudgetSplit tests how the time left for an auction is divided between phases
+func TestTimeoutBudgetSplit(t *testing.T) {
+	testCases := []struct {
+		name                 string
+		budget               *config.TimeoutBudgetConfig
+		remaining            time.Duration
+		expectedCollection   time.Duration
+		expectedOptimization time.Duration
+	}{
+		{name: "Defaults", remaining: 500 * time.Millisecond, expectedCollection: 450 * time.Millisecond, expectedOptimization: 48 * time.Millisecond},
+		{name: "Fixed Reserve", budget: &config.TimeoutBudgetConfig{Reserve: 100 * time.Millisecond, Serialization: 10 * time.Millisecond},
+			remaining: 500 * time.Millisecond, expectedCollection: 400 * time.Millisecond, expectedOptimization: 90 * time.Millisecond},
+		{name: "Fraction Above Reserve", budget: &config.TimeoutBudgetConfig{Reserve: 20 * time.Millisecond, ReserveFraction: 0.2},
+			remaining: 500 * time.Millisecond, expectedCollection: 400 * time.Millisecond, expectedOptimization: 100 * time.Millisecond},
+		{name: "Reserve Capped At Half", budget: &config.TimeoutBudgetConfig{Reserve: 100 * time.Millisecond},
+			remaining: 120 * time.Millisecond, expectedCollection: 60 * time.Millisecond, expectedOptimization: 60 * time.Millisecond},
+		{name: "Serialization Leaves Half For Optimization", budget: &config.TimeoutBudgetConfig{Reserve: 40 * time.Millisecond, Serialization: 30 * time.Millisecond},
+			remaining: 500 * time.Millisecond, expectedCollection: 460 * time.Millisecond, expectedOptimization: 20 * time.Millisecond},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			collection, optimization := tc.budget.Split(tc.remaining)
+			assert.Equal(t, tc.expectedCollection, collection)
+			assert.Equal(t, tc.expectedOptimization, optimization)
+		})
+	}
+}
+
+// TestTimeoutBudgetValidation tests that timeout budgets leaving no time for collection are rejected
+func TestTimeoutBudgetValidation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		budget        *config.TimeoutBudgetConfig
+		expectedError bool
+	}{
+		{name: "Valid", budget: &config.TimeoutBudgetConfig{Reserve: 50 * time.Millisecond, ReserveFraction: 0.1, Serialization: 5 * time.Millisecond}},
+		{name: "Negative Reserve", budget: &config.TimeoutBudgetConfig{Reserve: -time.Millisecond}, expectedError: true},
+		{name: "Negative Serialization", budget: &config.TimeoutBudgetConfig{Serialization: -time.Millisecond}, expectedError: true},
+		{name: "Fraction Above Half", budget: &config.TimeoutBudgetConfig{ReserveFraction: 0.6}, expectedError: true},
+		{name: "Reserve Above Half Timeout", budget: &config.TimeoutBudgetConfig{Reserve: 300 * time.Millisecond}, expectedError: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.BidTimeout = 500 * time.Millisecond
+			cfg.TimeoutBudget = tc.budget
+
+			err := cfg.Validate()
+			if tc.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+// TestTimeoutBudgetOptimizationFallback tests that an auction whose scorer outlasts the
+// optimization slice still answers, ranked by price, within its deadline
+func TestTimeoutBudgetOptimizationFallback(t *testing.T) {
+	service := newScoringTestService(t, &config.ScoringConfig{Timeout: time.Second, BlendWeight: 1.0})
+	defer service.Close()
+	service.SetScoringService(blockingScorer{})
+
+	labels := map[string]string{"phase": "optimization"}
+	before := gatheredMetric(t, "rtb_auction_budget_overruns_total", labels)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "budget-test-1", LeadID: "lead-1", Vertical: "auto"})
+	require.NoError(t, err)
+	require.Len(t, response.Bids, 1)
+
+	assert.Equal(t, "bid-a", response.Bids[0].ID)
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_auction_budget_overruns_total", labels)-before)
+	assert.Less(t, response.ProcessingTime, 500*time.Millisecond)
+	assert.Greater(t, response.CollectionTime, time.Duration(0))
+	assert.GreaterOrEqual(t, response.OptimizationTime, 40*time.Millisecond)
+	assert.LessOrEqual(t, response.CollectionTime+response.OptimizationTime, response.ProcessingTime)
+}
+
+// TestTimeoutBudgetCollectionOverrun tests that partners still bidding when the collection slice
+// ends time the auction out early rather than at the deadline
+func TestTimeoutBudgetCollectionOverrun(t *testing.T) {
+	slow, _ := newNegativeCachePartner(t, http.StatusOK, `{"id": "slow-bid", "price": 5, "click_url": "http://example.com/slow"}`, 300*time.Millisecond)
+	cfg, _ := newNegativeCacheTestConfig(t, "Memory", slow.URL, nil)
+	cfg.NegativeCache = nil
+	cfg.Partners["sparse"].Timeout = 400 * time.Millisecond
+	cfg.TimeoutBudget = &config.TimeoutBudgetConfig{ReserveFraction: 0.5}
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	labels := map[string]string{"phase": "collection"}
+	before := gatheredMetric(t, "rtb_auction_budget_overruns_total", labels)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = service.RunAuction(ctx, &models.BidRequest{RequestID: "budget-test-2", LeadID: "lead-1", Vertical: "auto"})
+
+	assert.True(t, errors.Is(err, services.ErrAuctionTimeout))
+	assert.Less(t, time.Since(start), 300*time.Millisecond)
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_auction_budget_overruns_total", labels)-before)
+}
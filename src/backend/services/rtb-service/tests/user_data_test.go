@@ -0,0 +1,265 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newAutoSchema returns the auto vertical schema used by the UserData tests; credit_band is only
+// needed by partner-b
+func newAutoSchema() map[string]*config.UserDataSchema {
+	return map[string]*config.UserDataSchema{
+		"auto": {Fields: map[string]*config.UserDataField{
+			"vehicle_year": {Type: config.UserDataTypeInt, Required: true},
+			"zip":          {Type: config.UserDataTypeString, Required: true, Pattern: `^[0-9]{5}$`},
+			"name":         {Type: config.UserDataTypeString, MaxLength: 8},
+			"owns_home":    {Type: config.UserDataTypeBool},
+			"credit_band":  {Type: config.UserDataTypeString, RequiredForPartners: []string{"partner-b"}},
+		}},
+	}
+}
+
+// newUserDataTestConfig configures two partners counting their calls and the auto schema
+func newUserDataTestConfig(t *testing.T, calls map[string]*atomic.Int32) *config.Config {
+	partners := make(map[string]*config.PartnerConfig, len(calls))
+	for id, count := range calls {
+		id, count := id, count
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			count.Add(1)
+			json.NewEncoder(w).Encode(models.Bid{ID: id + "-bid", Price: 5.0, QualityScore: 0.5, ClickURL: "http://" + id + ".example.com/c"})
+		}))
+		t.Cleanup(server.Close)
+		partners[id] = &config.PartnerConfig{ID: id, Endpoint: server.URL, APIKey: "key-" + id, Timeout: 200 * time.Millisecond, Enabled: true}
+	}
+
+	cfg := newTestConfig(partners, withMaxBids(2))
+	cfg.UserDataSchemas = newAutoSchema()
+	return cfg
+}
+
+// TestUserDataValidation tests field-level validation of UserData against the vertical's schema
+func TestUserDataValidation(t *testing.T) {
+	valid := map[string]interface{}{"vehicle_year": 2019.0, "zip": "90210"}
+	with := func(key string, value interface{}) map[string]interface{} {
+		userData := map[string]interface{}{"vehicle_year": 2019.0, "zip": "90210"}
+		userData[key] = value
+		return userData
+	}
+
+	testCases := []struct {
+		name           string
+		vertical       string
+		userData       map[string]interface{}
+		expectedFields []models.FieldError
+	}{
+		{name: "Valid", vertical: "auto", userData: valid},
+		{name: "Optional Fields Present", vertical: "auto", userData: with("owns_home", true)},
+		{name: "Partner Field Missing", vertical: "auto", userData: valid},
+		{name: "Vertical Without Schema", vertical: "home", userData: nil},
+		{
+			name: "Required Fields Missing", vertical: "auto", userData: nil,
+			expectedFields: []models.FieldError{
+				{Field: "vehicle_year", Reason: models.FieldReasonRequired, Message: "is required"},
+				{Field: "zip", Reason: models.FieldReasonRequired, Message: "is required"},
+			},
+		},
+		{
+			name: "Null Required Field", vertical: "auto", userData: with("zip", nil),
+			expectedFields: []models.FieldError{{Field: "zip", Reason: models.FieldReasonRequired, Message: "is required"}},
+		},
+		{
+			name: "Integer As String", vertical: "auto", userData: with("vehicle_year", "2019"),
+			expectedFields: []models.FieldError{{Field: "vehicle_year", Reason: models.FieldReasonType, Message: "must be an integer"}},
+		},
+		{
+			name: "Fractional Integer", vertical: "auto", userData: with("vehicle_year", 2019.5),
+			expectedFields: []models.FieldError{{Field: "vehicle_year", Reason: models.FieldReasonType, Message: "must be an integer"}},
+		},
+		{
+			name: "Pattern Mismatch", vertical: "auto", userData: with("zip", "9021"),
+			expectedFields: []models.FieldError{{Field: "zip", Reason: models.FieldReasonPattern, Message: "must match ^[0-9]{5}$"}},
+		},
+		{
+			name: "Too Long", vertical: "auto", userData: with("name", "Maximilian"),
+			expectedFields: []models.FieldError{{Field: "name", Reason: models.FieldReasonMaxLength, Message: "must be at most 8 characters"}},
+		},
+		{
+			name: "Multibyte Within Length", vertical: "auto", userData: with("name", "Zoë Ørst"),
+		},
+		{
+			name: "Wrong Bool", vertical: "auto", userData: with("owns_home", "yes"),
+			expectedFields: []models.FieldError{{Field: "owns_home", Reason: models.FieldReasonType, Message: "must be a boolean"}},
+		},
+	}
+
+	calls := map[string]*atomic.Int32{"partner-a": {}, "partner-b": {}}
+	service, err := services.NewAuctionService(newUserDataTestConfig(t, calls))
+	require.NoError(t, err)
+	defer service.Close()
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := service.ValidateUserData(&models.BidRequest{RequestID: "schema-test", Vertical: tc.vertical, UserData: tc.userData})
+			if tc.expectedFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, services.ErrInvalidUserData)
+			var userDataErr *services.UserDataError
+			require.ErrorAs(t, err, &userDataErr)
+			assert.Equal(t, tc.expectedFields, userDataErr.Fields)
+		})
+	}
+}
+
+// TestUserDataPartnerSkip tests that a missing partner-only field skips that partner instead of
+// rejecting the request
+func TestUserDataPartnerSkip(t *testing.T) {
+	testCases := []struct {
+		name            string
+		userData        map[string]interface{}
+		expectedWinners int
+		expectedCalls   map[string]int32
+		expectedSkip    models.Reason
+	}{
+		{
+			name: "Partner Field Present", userData: map[string]interface{}{"vehicle_year": 2019.0, "zip": "90210", "credit_band": "good"},
+			expectedWinners: 2, expectedCalls: map[string]int32{"partner-a": 1, "partner-b": 1},
+		},
+		{
+			name: "Partner Field Missing", userData: map[string]interface{}{"vehicle_year": 2019.0, "zip": "90210"},
+			expectedWinners: 1, expectedCalls: map[string]int32{"partner-a": 1, "partner-b": 0}, expectedSkip: "user_data_missing",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := map[string]*atomic.Int32{"partner-a": {}, "partner-b": {}}
+			service, err := services.NewAuctionService(newUserDataTestConfig(t, calls))
+			require.NoError(t, err)
+			defer service.Close()
+
+			debug := models.NewDebugInfo()
+			ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), 500*time.Millisecond)
+			defer cancel()
+			response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "skip-test", LeadID: "lead-1", Vertical: "auto", UserData: tc.userData})
+			require.NoError(t, err)
+
+			assert.Len(t, response.Bids, tc.expectedWinners)
+			for id, expected := range tc.expectedCalls {
+				assert.Equal(t, expected, calls[id].Load(), id)
+			}
+			partnerDebug, _ := debug.Partner("partner-b")
+			assert.Equal(t, tc.expectedSkip, partnerDebug.SkipReason)
+		})
+	}
+}
+
+// TestUserDataSchemaValidation tests schema limits in config validation
+func TestUserDataSchemaValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		field       *config.UserDataField
+		expectedErr string
+	}{
+		{name: "String Field", field: &config.UserDataField{Type: config.UserDataTypeString, Pattern: `^\d+$`, MaxLength: 5}},
+		{name: "Partner Field", field: &config.UserDataField{Type: config.UserDataTypeInt, RequiredForPartners: []string{"partner-1"}}},
+		{name: "Unknown Type", field: &config.UserDataField{Type: "date"}, expectedErr: `unknown type "date" for user data field f in vertical auto`},
+		{name: "Invalid Pattern", field: &config.UserDataField{Type: config.UserDataTypeString, Pattern: "("}, expectedErr: "invalid pattern for user data field f in vertical auto"},
+		{name: "Pattern On Integer", field: &config.UserDataField{Type: config.UserDataTypeInt, Pattern: `^1`}, expectedErr: "pattern and max length need type string"},
+		{name: "Negative Max Length", field: &config.UserDataField{Type: config.UserDataTypeString, MaxLength: -1}, expectedErr: "must not be negative: -1"},
+		{name: "Unknown Partner", field: &config.UserDataField{Type: config.UserDataTypeBool, RequiredForPartners: []string{"missing"}}, expectedErr: `unknown partner "missing" for user data field f in vertical auto`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.UserDataSchemas = map[string]*config.UserDataSchema{"auto": {Fields: map[string]*config.UserDataField{"f": tc.field}}}
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+// TestUserDataHandler tests field-level 400 responses, the schema endpoint, and schema reloads
+func TestUserDataHandler(t *testing.T) {
+	calls := map[string]*atomic.Int32{"partner-a": {}, "partner-b": {}}
+	cfg := newUserDataTestConfig(t, calls)
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	router, handler := newTestRouter(t, service, cfg)
+	router.GET("/v1/schemas/:vertical", handler.HandleUserDataSchema)
+
+	bid := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/v1/bids", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	schema := func(vertical string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/schemas/"+vertical, nil))
+		return w
+	}
+
+	// Invalid UserData is rejected with every failed field
+	w := bid(`{"request_id": "schema-1", "lead_id": "lead-1", "vertical": "auto", "user_data": {"vehicle_year": "2019"}}`)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	var rejected struct {
+		Error  string              `json:"error"`
+		Fields []models.FieldError `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rejected))
+	assert.Equal(t, "Invalid user data", rejected.Error)
+	assert.Equal(t, []models.FieldError{
+		{Field: "vehicle_year", Reason: models.FieldReasonType, Message: "must be an integer"},
+		{Field: "zip", Reason: models.FieldReasonRequired, Message: "is required"},
+	}, rejected.Fields)
+
+	// The schema endpoint serves the rules the request was checked against
+	w = schema("auto")
+	require.Equal(t, http.StatusOK, w.Code)
+	var served struct {
+		Vertical string                           `json:"vertical"`
+		Fields   map[string]*config.UserDataField `json:"fields"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &served))
+	assert.Equal(t, "auto", served.Vertical)
+	assert.Equal(t, newAutoSchema()["auto"].Fields, served.Fields)
+	assert.Equal(t, http.StatusNotFound, schema("home").Code)
+
+	// A reloaded schema applies to the next request and the endpoint
+	require.NoError(t, service.SetUserDataSchemas(map[string]*config.UserDataSchema{
+		"home": {Fields: map[string]*config.UserDataField{"sqft": {Type: config.UserDataTypeInt, Required: true}}},
+	}))
+	w = bid(`{"request_id": "schema-2", "lead_id": "lead-1", "vertical": "auto", "user_data": {"vehicle_year": "2019"}}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusNotFound, schema("auto").Code)
+	assert.Equal(t, http.StatusOK, schema("home").Code)
+
+	// A schema with an invalid pattern is refused and the running schemas are kept
+	assert.Error(t, service.SetUserDataSchemas(map[string]*config.UserDataSchema{
+		"home": {Fields: map[string]*config.UserDataField{"sqft": {Type: config.UserDataTypeString, Pattern: "("}}},
+	}))
+	assert.Equal(t, http.StatusOK, schema("home").Code)
+}
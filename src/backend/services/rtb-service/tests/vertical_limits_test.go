@@ -0,0 +1,203 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// verticalLoad mirrors a vertical pool's entry in the readiness report
+type verticalLoad struct {
+	InFlight      int    `json:"in_flight"`
+	MaxConcurrent int    `json:"max_concurrent"`
+	Shed          uint64 `json:"shed"`
+}
+
+// newVerticalLimitTestConfig returns a config for one partner at partnerURL with the given
+// vertical limits
+func newVerticalLimitTestConfig(partnerURL string, limits map[string]*config.VerticalLimit) *config.Config {
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: partnerURL, APIKey: "key", Timeout: 400 * time.Millisecond, Enabled: true},
+	})
+	cfg.MaxConcurrentAuctions = 10
+	cfg.VerticalLimits = limits
+	return cfg
+}
+
+// postVerticalBid posts a bid request for a vertical and returns the status code
+func postVerticalBid(router *gin.Engine, requestID, vertical string) int {
+	body := fmt.Sprintf(`{"request_id": %q, "lead_id": "lead-1", "vertical": %q}`, requestID, vertical)
+	req := httptest.NewRequest(http.MethodPost, "/v1/bids", bytes.NewBufferString(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w.Code
+}
+
+// TestVerticalConcurrencyIsolation tests that a vertical at its concurrency limit sheds its own
+// auctions while other verticals keep running in the default pool, and that the readiness report
+// shows each pool's load
+func TestVerticalConcurrencyIsolation(t *testing.T) {
+	arrived := make(chan string, 10)
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request models.BidRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		arrived <- request.Vertical
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "bid-" + request.RequestID, Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := newVerticalLimitTestConfig(server.URL, map[string]*config.VerticalLimit{
+		"health":                   {MaxConcurrent: 1},
+		config.DefaultVerticalPool: {MaxConcurrent: 5},
+	})
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	router, handler := newTestRouter(t, service, cfg)
+	router.GET("/readyz", handler.HandleReadiness)
+
+	statuses := make(chan int, 2)
+	go func() { statuses <- postVerticalBid(router, "health-1", "health") }()
+	require.Equal(t, "health", <-arrived)
+	go func() { statuses <- postVerticalBid(router, "auto-1", "auto") }()
+	require.Equal(t, "auto", <-arrived)
+
+	// The health pool is full, so a second health auction is shed without calling partners
+	assert.Equal(t, http.StatusServiceUnavailable, postVerticalBid(router, "health-2", "health"))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var report struct {
+		Verticals map[string]verticalLoad `json:"verticals"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, map[string]verticalLoad{
+		"health":  {InFlight: 1, MaxConcurrent: 1, Shed: 1},
+		"default": {InFlight: 1, MaxConcurrent: 5},
+	}, report.Verticals)
+	assert.Equal(t, 1.0, gatheredMetric(t, "rtb_vertical_auctions_in_flight", map[string]string{"pool": "health"}))
+
+	close(release)
+	assert.Equal(t, http.StatusOK, <-statuses)
+	assert.Equal(t, http.StatusOK, <-statuses)
+	assert.Equal(t, http.StatusOK, postVerticalBid(router, "health-3", "health"))
+	assert.Equal(t, 0.0, gatheredMetric(t, "rtb_vertical_auctions_in_flight", map[string]string{"pool": "health"}))
+}
+
+// TestVerticalTimeoutOverride tests that a vertical's timeout replaces the bid timeout for its
+// auctions only
+func TestVerticalTimeoutOverride(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(150 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "bid-1", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := newVerticalLimitTestConfig(server.URL, map[string]*config.VerticalLimit{"health": {Timeout: 100 * time.Millisecond}})
+	assert.Equal(t, 100*time.Millisecond, cfg.AuctionTimeout("health"))
+	assert.Equal(t, 500*time.Millisecond, cfg.AuctionTimeout("auto"))
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	router, _ := newTestRouter(t, service, cfg)
+
+	testCases := []struct {
+		name           string
+		vertical       string
+		expectedStatus int
+	}{
+		{name: "Override Shorter Than Partner", vertical: "health", expectedStatus: http.StatusGatewayTimeout},
+		{name: "Unlisted Vertical Keeps Bid Timeout", vertical: "auto", expectedStatus: http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expectedStatus, postVerticalBid(router, "timeout-"+tc.vertical, tc.vertical))
+		})
+	}
+}
+
+// TestVerticalPartnerQPSShare tests that a vertical only uses its share of a partner's QPS cap,
+// leaving the rest to other verticals
+func TestVerticalPartnerQPSShare(t *testing.T) {
+	server := newPartnerServer(t, models.Bid{ID: "bid-1", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	cfg := newVerticalLimitTestConfig(server.URL, map[string]*config.VerticalLimit{"health": {PartnerQPSShare: 0.5}})
+	cfg.Partners["partner-1"].MaxQPS = 4
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	run := func(requestID, vertical string) models.Reason {
+		debug := models.NewDebugInfo()
+		ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), 500*time.Millisecond)
+		defer cancel()
+		service.RunAuction(ctx, &models.BidRequest{RequestID: requestID, LeadID: "lead-1", Vertical: vertical})
+		partner, _ := debug.Partner("partner-1")
+		return partner.SkipReason
+	}
+
+	testCases := []struct {
+		vertical     string
+		expectedSkip models.Reason
+	}{
+		{vertical: "health"},
+		{vertical: "health"},
+		{vertical: "health", expectedSkip: "vertical_qps_capped"},
+		{vertical: "auto"},
+		{vertical: "auto"},
+		{vertical: "auto", expectedSkip: "qps_capped"},
+	}
+
+	for i, tc := range testCases {
+		t.Run(fmt.Sprintf("%d %s", i, tc.vertical), func(t *testing.T) {
+			assert.Equal(t, tc.expectedSkip, run(fmt.Sprintf("qps-share-%d", i), tc.vertical))
+		})
+	}
+}
+
+// TestVerticalLimitValidation tests the bounds on vertical limits
+func TestVerticalLimitValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		limit       *config.VerticalLimit
+		expectedErr string
+	}{
+		{name: "Valid", limit: &config.VerticalLimit{MaxConcurrent: 50, Timeout: time.Second, PartnerQPSShare: 0.5}},
+		{name: "Missing", expectedErr: "missing vertical limits for health"},
+		{name: "Negative Concurrency", limit: &config.VerticalLimit{MaxConcurrent: -1}, expectedErr: "invalid max concurrent auctions for vertical health: -1"},
+		{name: "Timeout Too Short", limit: &config.VerticalLimit{Timeout: time.Millisecond}, expectedErr: "timeout for vertical health must be between 50ms and 10s"},
+		{name: "Share Above One", limit: &config.VerticalLimit{PartnerQPSShare: 1.5}, expectedErr: "partner QPS share for vertical health must be between 0 and 1: 1.5"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.VerticalLimits = map[string]*config.VerticalLimit{"health": tc.limit}
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
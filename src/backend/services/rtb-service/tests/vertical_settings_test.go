@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// newVerticalSettingsConfig returns a config with two seat partners, where partner-a's three seats
+// outbid partner-b's two, and two winners by default
+func newVerticalSettingsConfig(t *testing.T, verticals map[string]*config.VerticalConfig) *config.Config {
+	partnerA := newSeatPartner(t, newSeatBids("a", 3, 20.0))
+	partnerB := newSeatPartner(t, newSeatBids("b", 2, 15.0))
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-a": {ID: "partner-a", Endpoint: partnerA.URL, APIKey: "key-a", Timeout: 200 * time.Millisecond, Enabled: true},
+		"partner-b": {ID: "partner-b", Endpoint: partnerB.URL, APIKey: "key-b", Timeout: 200 * time.Millisecond, Enabled: true},
+	}, withMaxBids(2), withAdmin())
+	cfg.Verticals = verticals
+	return cfg
+}
+
+// runVerticalAuction runs an auction for vertical and returns its response and debug output
+func runVerticalAuction(t *testing.T, service *services.AuctionService, vertical string) (*models.BidResponse, *models.DebugInfo) {
+	debug := models.NewDebugInfo()
+	ctx, cancel := context.WithTimeout(models.ContextWithDebug(context.Background(), debug), time.Second)
+	defer cancel()
+	response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "vertical-" + vertical, LeadID: "lead-1", Vertical: vertical})
+	require.NoError(t, err)
+	return response, debug
+}
+
+// winnerIDs returns the IDs of the response's winning bids in order
+func winnerIDs(response *models.BidResponse) []string {
+	ids := make([]string, 0, len(response.Bids))
+	for _, bid := range response.Bids {
+		ids = append(ids, bid.ID)
+	}
+	return ids
+}
+
+// TestEffectiveVertical tests that a vertical's settings are laid over the global ones, which
+// unlisted verticals and unset settings keep
+func TestEffectiveVertical(t *testing.T) {
+	cfg := newStrategyTestConfig()
+	cfg.MaxBidsPerRequest = 3
+	cfg.ResponseTTLs = map[string]time.Duration{"default": time.Minute, "auto": 2 * time.Minute}
+	cfg.Verticals = map[string]*config.VerticalConfig{
+		"health": {MaxBids: 1, Diversity: config.DiversityNone, Floor: 5.0, ResponseTTL: 30 * time.Second, Strategy: config.StrategyEffectivePrice},
+		"auto":   {Floor: 2.5},
+	}
+
+	testCases := []struct {
+		name     string
+		vertical string
+		expected config.EffectiveVertical
+	}{
+		{
+			name:     "Unlisted",
+			vertical: "home",
+			expected: config.EffectiveVertical{Vertical: "home", MaxBids: 3, Diversity: config.DiversityPartner, ResponseTTL: time.Minute, Strategy: config.StrategyPassthrough},
+		},
+		{
+			name:     "Fully Overridden",
+			vertical: "health",
+			expected: config.EffectiveVertical{Vertical: "health", Overridden: true, MaxBids: 1, Diversity: config.DiversityNone, Floor: 5.0, ResponseTTL: 30 * time.Second, Strategy: config.StrategyEffectivePrice},
+		},
+		{
+			name:     "Partly Overridden",
+			vertical: "auto",
+			expected: config.EffectiveVertical{Vertical: "auto", Overridden: true, MaxBids: 3, Diversity: config.DiversityPartner, Floor: 2.5, ResponseTTL: 2 * time.Minute, Strategy: config.StrategyEffectivePrice},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, cfg.EffectiveVertical(tc.vertical))
+		})
+	}
+}
+
+// TestVerticalSettingsValidation tests that vertical settings are rejected when unknown, out of
+// bounds, or contradicting a global setting
+func TestVerticalSettingsValidation(t *testing.T) {
+	testCases := []struct {
+		name          string
+		settings      *config.VerticalConfig
+		modify        func(c *config.Config)
+		expectedError string
+	}{
+		{name: "Valid", settings: &config.VerticalConfig{MaxBids: 3, Diversity: config.DiversityNone, Floor: 12.0, ResponseTTL: time.Minute, Strategy: config.StrategyPassthrough}},
+		{name: "Empty", settings: &config.VerticalConfig{}},
+		{name: "Missing", expectedError: "missing vertical settings"},
+		{name: "Negative Max Bids", settings: &config.VerticalConfig{MaxBids: -1}, expectedError: "invalid max bids"},
+		{name: "Unknown Diversity", settings: &config.VerticalConfig{Diversity: "seat"}, expectedError: "unknown diversity mode"},
+		{name: "Negative Floor", settings: &config.VerticalConfig{Floor: -1}, expectedError: "invalid floor"},
+		{name: "Floor At Max Bid Price", settings: &config.VerticalConfig{Floor: 100.0}, expectedError: "at or above the max bid price"},
+		{name: "Floor Above Max Bid Price", settings: &config.VerticalConfig{Floor: 150.0}, expectedError: "at or above the max bid price"},
+		{name: "Response TTL Too Long", settings: &config.VerticalConfig{ResponseTTL: 48 * time.Hour}, expectedError: "response TTL for vertical"},
+		{name: "Unknown Strategy", settings: &config.VerticalConfig{Strategy: "highest_bid"}, expectedError: "unknown optimization strategy"},
+		{
+			name:          "Strategy Set Twice",
+			settings:      &config.VerticalConfig{Strategy: config.StrategyPassthrough},
+			modify:        func(c *config.Config) { c.Strategies["health"] = config.StrategyQualityWeighted },
+			expectedError: "set in both verticals and strategies",
+		},
+		{
+			name:          "Response TTL Set Twice",
+			settings:      &config.VerticalConfig{ResponseTTL: time.Minute},
+			modify:        func(c *config.Config) { c.ResponseTTLs = map[string]time.Duration{"health": 2 * time.Minute} },
+			expectedError: "set in both verticals and response_ttl",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			delete(cfg.Strategies, "health")
+			if tc.modify != nil {
+				tc.modify(cfg)
+			}
+			cfg.Verticals = map[string]*config.VerticalConfig{"health": tc.settings}
+			if tc.expectedError != "" {
+				assert.ErrorContains(t, cfg.Validate(), tc.expectedError)
+			} else {
+				assert.NoError(t, cfg.Validate())
+			}
+		})
+	}
+
+	t.Run("Reserved Name", func(t *testing.T) {
+		cfg := newStrategyTestConfig()
+		cfg.Verticals = map[string]*config.VerticalConfig{config.DefaultStrategyKey: {MaxBids: 2}}
+		assert.ErrorContains(t, cfg.Validate(), "reserved name")
+	})
+}
+
+// TestVerticalSettingsAuction tests that each vertical's auctions pick winners with its max bids,
+// diversity mode, and floor, and unlisted verticals with the global settings
+func TestVerticalSettingsAuction(t *testing.T) {
+	service, err := services.NewAuctionService(newVerticalSettingsConfig(t, map[string]*config.VerticalConfig{
+		"health": {MaxBids: 4, Diversity: config.DiversityNone},
+		"auto":   {Floor: 16.0},
+	}))
+	require.NoError(t, err)
+	defer service.Close()
+
+	testCases := []struct {
+		name            string
+		vertical        string
+		expectedWinners []string
+		expectedFloor   *models.AuctionFloor
+		expectedLosses  map[string]map[string]models.Reason
+	}{
+		{
+			name:            "Unlisted Vertical",
+			vertical:        "home",
+			expectedWinners: []string{"a-1", "b-1"},
+			expectedLosses: map[string]map[string]models.Reason{
+				"partner-a": {"a-2": models.ReasonPartnerCap, "a-3": models.ReasonPartnerCap},
+			},
+		},
+		{
+			name:            "More Winners Without Diversity",
+			vertical:        "health",
+			expectedWinners: []string{"a-1", "a-2", "a-3", "b-1"},
+		},
+		{
+			name:            "Vertical Floor",
+			vertical:        "auto",
+			expectedWinners: []string{"a-1"},
+			expectedFloor:   &models.AuctionFloor{Floor: 16.0, Source: models.FloorSourceVertical},
+			expectedLosses: map[string]map[string]models.Reason{
+				"partner-a": {"a-2": models.ReasonPartnerCap, "a-3": models.ReasonPartnerCap},
+				"partner-b": {"b-1": models.ReasonVerticalFloor, "b-2": models.ReasonVerticalFloor},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response, debug := runVerticalAuction(t, service, tc.vertical)
+			assert.Equal(t, tc.expectedWinners, winnerIDs(response))
+			assert.Equal(t, tc.expectedFloor, response.Floor)
+			for _, partnerID := range []string{"partner-a", "partner-b"} {
+				partner, _ := debug.Partner(partnerID)
+				assert.Equal(t, tc.expectedLosses[partnerID], partner.Losses, partnerID)
+			}
+		})
+	}
+}
+
+// TestSetVerticals tests that reloaded vertical settings apply to the next auction and show on
+// the effective config endpoint, and that invalid ones leave the current settings in place
+func TestSetVerticals(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := newVerticalSettingsConfig(t, nil)
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+	adminHandler, err := handlers.NewAdminHandler(service, cfg, handlers.BuildInfo{})
+	require.NoError(t, err)
+	router := gin.New()
+	adminHandler.RegisterRoutes(router.Group("/admin"))
+
+	effective := func() config.EffectiveVertical {
+		w := serveOverrideTest(router, http.MethodGet, "/admin/config/effective?vertical=health", "", map[string]string{"X-Admin-Key": testAdminKey})
+		require.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Effective config.EffectiveVertical `json:"effective"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Effective
+	}
+
+	response, _ := runVerticalAuction(t, service, "health")
+	assert.Equal(t, []string{"a-1", "b-1"}, winnerIDs(response))
+	assert.Equal(t, config.EffectiveVertical{Vertical: "health", MaxBids: 2, Diversity: config.DiversityPartner, Strategy: config.StrategyEffectivePrice}, effective())
+
+	reloaded := newVerticalSettingsConfig(t, map[string]*config.VerticalConfig{
+		"health": {MaxBids: 3, Diversity: config.DiversityNone, ResponseTTL: time.Minute, Strategy: config.StrategyQualityWeighted},
+	})
+	require.NoError(t, service.SetVerticals(reloaded))
+	response, _ = runVerticalAuction(t, service, "health")
+	assert.Equal(t, []string{"a-1", "a-2", "a-3"}, winnerIDs(response))
+	require.NotNil(t, response.ValidUntil)
+	expected := config.EffectiveVertical{Vertical: "health", Overridden: true, MaxBids: 3, Diversity: config.DiversityNone, ResponseTTL: time.Minute, Strategy: config.StrategyQualityWeighted}
+	assert.Equal(t, expected, effective())
+
+	invalid := newVerticalSettingsConfig(t, map[string]*config.VerticalConfig{"health": {Floor: 150.0}})
+	assert.ErrorContains(t, service.SetVerticals(invalid), "max bid price")
+	assert.Equal(t, expected, effective(), "invalid settings leave the current ones in place")
+}
@@ -0,0 +1,208 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"            // v1.9.1
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/handlers"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+)
+
+// warmupPartner is a partner server that counts the connections it accepts and the methods of
+// the requests it receives
+type warmupPartner struct {
+	server      *httptest.Server
+	mutex       sync.Mutex
+	connections int
+	methods     []string
+}
+
+// newWarmupPartner starts a partner that bids 5 after delay
+func newWarmupPartner(t *testing.T, delay time.Duration) *warmupPartner {
+	partner := &warmupPartner{}
+	partner.server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		partner.mutex.Lock()
+		partner.methods = append(partner.methods, r.Method)
+		partner.mutex.Unlock()
+		time.Sleep(delay)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(models.Bid{ID: "bid-1", Price: 5.0, QualityScore: 0.5, ClickURL: "http://example.com/1"})
+	}))
+	partner.server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			partner.mutex.Lock()
+			partner.connections++
+			partner.mutex.Unlock()
+		}
+	}
+	partner.server.Start()
+	t.Cleanup(partner.server.Close)
+	return partner
+}
+
+// accepted returns the connections accepted and the request methods received so far
+func (p *warmupPartner) accepted() (int, []string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return p.connections, append([]string(nil), p.methods...)
+}
+
+// newWarmupTestConfig returns a config with warmup enabled for one partner at endpoint
+func newWarmupTestConfig(endpoint, method string, warmup *config.WarmupConfig) *config.Config {
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner-1": {ID: "partner-1", Endpoint: endpoint, APIKey: "key", Timeout: 400 * time.Millisecond, Enabled: true, WarmupMethod: method},
+	})
+	cfg.Warmup = warmup
+	return cfg
+}
+
+// TestPartnerWarmup tests that warmup opens keep-alive connections by the partner's method, that
+// the first auction reuses them, and that the results reach the readiness report
+func TestPartnerWarmup(t *testing.T) {
+	testCases := []struct {
+		name                string
+		method              string
+		expectedMethod      string
+		expectedConnections int
+		expectedRequests    []string
+		expectedAccepted    int
+	}{
+		{name: "Head", method: config.WarmupHead, expectedMethod: "head", expectedConnections: 2,
+			expectedRequests: []string{http.MethodHead, http.MethodHead}, expectedAccepted: 2},
+		{name: "Options", method: config.WarmupOptions, expectedMethod: "options", expectedConnections: 2,
+			expectedRequests: []string{http.MethodOptions, http.MethodOptions}, expectedAccepted: 2},
+		// A connect warmup checks the host with one handshake and keeps nothing, so the auction dials again
+		{name: "Connect By Default", expectedMethod: "connect", expectedConnections: 1, expectedAccepted: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			partner := newWarmupPartner(t, 0)
+			cfg := newWarmupTestConfig(partner.server.URL+"/bid/{vertical}", tc.method, &config.WarmupConfig{Enabled: true, Timeout: time.Second})
+			service, err := services.NewAuctionService(cfg)
+			require.NoError(t, err)
+			defer service.Close()
+
+			results := service.WarmupPartners(context.Background())
+			require.Contains(t, results, "partner-1")
+			result := results["partner-1"]
+			assert.True(t, result.Success, result.Error)
+			assert.Equal(t, tc.expectedMethod, result.Method)
+			assert.Equal(t, tc.expectedConnections, result.Connections)
+			assert.Greater(t, result.HandshakeMs, 0.0)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+			defer cancel()
+			response, err := service.RunAuction(ctx, &models.BidRequest{RequestID: "warmup-1", LeadID: "lead-1", Vertical: "auto"})
+			require.NoError(t, err)
+			require.Len(t, response.Bids, 1)
+
+			accepted, methods := partner.accepted()
+			assert.Equal(t, tc.expectedAccepted, accepted)
+			assert.Equal(t, append(tc.expectedRequests, http.MethodPost), methods)
+
+			handler, err := handlers.NewBidHandler(service, cfg)
+			require.NoError(t, err)
+			router := gin.New()
+			router.GET("/readyz", handler.HandleReadiness)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+			var report struct {
+				Warmup map[string]services.WarmupResult `json:"warmup"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+			assert.Equal(t, results, report.Warmup)
+		})
+	}
+}
+
+// TestPartnerWarmupFailures tests that unreachable and slow partners fail their warmup without
+// holding it past its timeout, and that nothing runs when warmup is disabled
+func TestPartnerWarmupFailures(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	deadURL := "http://" + listener.Addr().String()
+	listener.Close()
+	slow := newWarmupPartner(t, 2*time.Second)
+
+	testCases := []struct {
+		name     string
+		endpoint string
+		method   string
+		warmup   *config.WarmupConfig
+	}{
+		{name: "Unreachable", endpoint: deadURL, warmup: &config.WarmupConfig{Enabled: true, Timeout: 200 * time.Millisecond}},
+		{name: "Slow", endpoint: slow.server.URL, method: config.WarmupHead, warmup: &config.WarmupConfig{Enabled: true, Timeout: 200 * time.Millisecond}},
+		{name: "Disabled", endpoint: deadURL},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, err := services.NewAuctionService(newWarmupTestConfig(tc.endpoint, tc.method, tc.warmup))
+			require.NoError(t, err)
+			defer service.Close()
+
+			started := time.Now()
+			results := service.WarmupPartners(context.Background())
+			assert.Less(t, time.Since(started), time.Second)
+			assert.Equal(t, results, service.WarmupResults())
+			if tc.warmup == nil {
+				assert.Nil(t, results)
+				return
+			}
+			result := results["partner-1"]
+			assert.False(t, result.Success)
+			assert.NotEmpty(t, result.Error)
+			assert.Zero(t, result.Connections)
+		})
+	}
+}
+
+// TestWarmupValidation tests the warmup connection, timeout, and method settings
+func TestWarmupValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		warmup      *config.WarmupConfig
+		httpClient  *config.HTTPClientConfig
+		method      string
+		expectedErr string
+	}{
+		{name: "Defaults", warmup: &config.WarmupConfig{Enabled: true}},
+		{name: "Disabled Ignores Settings", warmup: &config.WarmupConfig{Connections: 100}},
+		{name: "Above Default Idle Limit", warmup: &config.WarmupConfig{Enabled: true, Connections: 3},
+			expectedErr: "warmup connections must be between 0 and the 2 idle connections kept per host: 3"},
+		{name: "Within Configured Idle Limit", warmup: &config.WarmupConfig{Enabled: true, Connections: 8},
+			httpClient: &config.HTTPClientConfig{MaxIdleConns: 100, MaxIdleConnsPerHost: 10, IdleConnTimeout: time.Minute, TLSHandshakeTimeout: time.Second}},
+		{name: "Timeout Too Long", warmup: &config.WarmupConfig{Enabled: true, Timeout: time.Minute},
+			expectedErr: "warmup timeout must be between 0 and 30s: 1m0s"},
+		{name: "Unknown Method", method: "get", expectedErr: `unknown warmup method for partner partner-1: "get"`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Warmup = tc.warmup
+			cfg.HTTPClient = tc.httpClient
+			cfg.Partners["partner-1"].WarmupMethod = tc.method
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,306 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"  // v1.8.4
+	"github.com/stretchr/testify/require" // v1.8.4
+
+	"github.com/yourdomain/rtb-service/src/config"
+	"github.com/yourdomain/rtb-service/src/models"
+	"github.com/yourdomain/rtb-service/src/services"
+	"github.com/yourdomain/rtb-service/src/webhooks"
+)
+
+// webhookDelivery is one request received by a webhook receiver
+type webhookDelivery struct {
+	header http.Header
+	body   []byte
+	event  webhooks.Event
+}
+
+// webhookReceiver records deliveries and answers with statuses in turn, repeating the last
+type webhookReceiver struct {
+	mutex      sync.Mutex
+	statuses   []int
+	deliveries []webhookDelivery
+}
+
+// newWebhookReceiver starts a receiver answering with statuses
+func newWebhookReceiver(t *testing.T, statuses ...int) (*webhookReceiver, *httptest.Server) {
+	receiver := &webhookReceiver{statuses: statuses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		delivery := webhookDelivery{header: r.Header.Clone(), body: body}
+		json.Unmarshal(body, &delivery.event)
+
+		receiver.mutex.Lock()
+		status := receiver.statuses[min(len(receiver.deliveries), len(receiver.statuses)-1)]
+		receiver.deliveries = append(receiver.deliveries, delivery)
+		receiver.mutex.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return receiver, server
+}
+
+// received returns the deliveries so far
+func (r *webhookReceiver) received() []webhookDelivery {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]webhookDelivery(nil), r.deliveries...)
+}
+
+// newWebhookTestConfig returns a webhooks config with fast retries and a temporary dead letter log
+func newWebhookTestConfig(t *testing.T, endpoints ...config.WebhookEndpoint) *config.WebhooksConfig {
+	return &config.WebhooksConfig{
+		Enabled:        true,
+		Endpoints:      endpoints,
+		Secret:         "webhook-secret",
+		Timeout:        time.Second,
+		MaxAttempts:    3,
+		BackoffBase:    time.Millisecond,
+		BackoffMax:     5 * time.Millisecond,
+		Workers:        2,
+		QueueSize:      10,
+		DeadLetterPath: filepath.Join(t.TempDir(), "dead-letters.jsonl"),
+		RecentFailures: 10,
+	}
+}
+
+// readDeadLetters reads the failures written to a dead letter log
+func readDeadLetters(t *testing.T, path string) []webhooks.Failure {
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var failures []webhooks.Failure
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var failure webhooks.Failure
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &failure))
+		failures = append(failures, failure)
+	}
+	return failures
+}
+
+// TestWebhookDelivery tests signing, retries, permanent failures, and dead letters
+func TestWebhookDelivery(t *testing.T) {
+	testCases := []struct {
+		name              string
+		statuses          []int
+		expectedAttempts  int
+		expectedDelivered bool
+		expectedRetries   float64
+	}{
+		{name: "Delivered", statuses: []int{http.StatusOK}, expectedAttempts: 1, expectedDelivered: true},
+		{name: "Retried Then Delivered", statuses: []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusNoContent}, expectedAttempts: 3, expectedDelivered: true, expectedRetries: 2},
+		{name: "Permanent Failure", statuses: []int{http.StatusBadRequest}, expectedAttempts: 1},
+		{name: "Attempts Exhausted", statuses: []int{http.StatusInternalServerError}, expectedAttempts: 3, expectedRetries: 2},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			receiver, server := newWebhookReceiver(t, tc.statuses...)
+			cfg := newWebhookTestConfig(t, config.WebhookEndpoint{URL: server.URL})
+			dispatcher, err := webhooks.NewDispatcher(cfg)
+			require.NoError(t, err)
+			retriesBefore := gatheredMetric(t, "rtb_webhook_retries_total", map[string]string{"event": config.WebhookEventBidWon})
+
+			dispatcher.Dispatch(webhooks.Event{ID: "event-1", Type: config.WebhookEventBidWon, CreatedAt: time.Now().UTC(), Data: map[string]string{"bid_id": "bid-1"}})
+			require.Eventually(t, func() bool {
+				return len(receiver.received()) == tc.expectedAttempts && (tc.expectedDelivered || len(dispatcher.Failures()) == 1)
+			}, 2*time.Second, 5*time.Millisecond)
+			require.NoError(t, dispatcher.Close())
+
+			for _, delivery := range receiver.received() {
+				assert.Equal(t, "event-1", delivery.header.Get(webhooks.HeaderIdempotencyKey))
+				assert.Equal(t, config.WebhookEventBidWon, delivery.header.Get(webhooks.HeaderEvent))
+				assert.Equal(t, webhooks.Sign([]byte("webhook-secret"), delivery.header.Get(webhooks.HeaderTimestamp), delivery.body), delivery.header.Get(webhooks.HeaderSignature))
+				assert.Equal(t, "event-1", delivery.event.ID)
+			}
+			assert.Equal(t, retriesBefore+tc.expectedRetries, gatheredMetric(t, "rtb_webhook_retries_total", map[string]string{"event": config.WebhookEventBidWon}))
+
+			deadLetters := readDeadLetters(t, cfg.DeadLetterPath)
+			if tc.expectedDelivered {
+				assert.Empty(t, dispatcher.Failures())
+				assert.Empty(t, deadLetters)
+				return
+			}
+			require.Len(t, deadLetters, 1)
+			assert.Equal(t, dispatcher.Failures(), deadLetters)
+			assert.Equal(t, "event-1", deadLetters[0].EventID)
+			assert.Equal(t, server.URL, deadLetters[0].URL)
+			assert.Equal(t, tc.expectedAttempts, deadLetters[0].Attempts)
+			assert.Equal(t, receiver.received()[0].body, []byte(deadLetters[0].Payload))
+		})
+	}
+}
+
+// TestWebhookShutdown tests that deliveries still waiting to retry at shutdown are dead-lettered
+func TestWebhookShutdown(t *testing.T) {
+	receiver, server := newWebhookReceiver(t, http.StatusBadGateway)
+	cfg := newWebhookTestConfig(t, config.WebhookEndpoint{URL: server.URL})
+	cfg.BackoffBase, cfg.BackoffMax = time.Hour, time.Hour
+	dispatcher, err := webhooks.NewDispatcher(cfg)
+	require.NoError(t, err)
+
+	dispatcher.Dispatch(webhooks.Event{ID: "event-1", Type: config.WebhookEventBidWon, Data: map[string]string{}})
+	require.Eventually(t, func() bool { return len(receiver.received()) == 1 }, time.Second, 5*time.Millisecond)
+
+	start := time.Now()
+	require.NoError(t, dispatcher.Close())
+	assert.Less(t, time.Since(start), time.Second)
+
+	dispatcher.Dispatch(webhooks.Event{ID: "event-2", Type: config.WebhookEventBidWon, Data: map[string]string{}})
+	failures := dispatcher.Failures()
+	require.Len(t, failures, 2)
+	assert.Equal(t, "event-2", failures[0].EventID)
+	assert.Contains(t, failures[1].Error, webhooks.ErrShutdown.Error())
+	assert.Len(t, readDeadLetters(t, cfg.DeadLetterPath), 1)
+}
+
+// TestAuctionWebhooks tests the events dispatched for won and unsold auctions and endpoint event filters
+func TestAuctionWebhooks(t *testing.T) {
+	partner := newPartnerServer(t, models.Bid{ID: "won-bid", Price: 12.5, QualityScore: 0.5, ClickURL: "http://partner.example.com/c"})
+	wonOnly, wonServer := newWebhookReceiver(t, http.StatusOK)
+	all, allServer := newWebhookReceiver(t, http.StatusOK)
+
+	// A single worker delivers in dispatch order
+	webhooksCfg := newWebhookTestConfig(t,
+		config.WebhookEndpoint{URL: wonServer.URL, Events: []string{config.WebhookEventBidWon}},
+		config.WebhookEndpoint{URL: allServer.URL},
+	)
+	webhooksCfg.Workers = 1
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"partner1": {ID: "partner1", Endpoint: partner.URL, APIKey: "key-1", Timeout: 200 * time.Millisecond, Enabled: true},
+	})
+	cfg.MinBidders = map[string]int{"life": 2}
+	cfg.Webhooks = webhooksCfg
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	runAuction := func(requestID, vertical string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		defer cancel()
+		service.RunAuction(ctx, &models.BidRequest{RequestID: requestID, LeadID: "lead-" + requestID, Vertical: vertical})
+	}
+	runAuction("won", "auto")
+	runAuction("quorum", "life")
+	runAuction("won", "auto")
+
+	require.Eventually(t, func() bool { return len(wonOnly.received()) == 2 && len(all.received()) == 5 }, 2*time.Second, 5*time.Millisecond)
+
+	events := make(map[string][]webhooks.Event)
+	for _, delivery := range all.received() {
+		events[delivery.event.Type] = append(events[delivery.event.Type], delivery.event)
+	}
+	for _, delivery := range wonOnly.received() {
+		assert.Equal(t, config.WebhookEventBidWon, delivery.event.Type)
+	}
+
+	testCases := []struct {
+		name     string
+		event    webhooks.Event
+		expected map[string]interface{}
+	}{
+		{name: "Bid Won", event: events[config.WebhookEventBidWon][0], expected: map[string]interface{}{"request_id": "won", "lead_id": "lead-won", "partner_id": "partner1", "bid_id": "won-bid", "price": 12.5}},
+		{name: "Auction Completed", event: events[config.WebhookEventAuctionCompleted][0], expected: map[string]interface{}{"request_id": "won", "winners": []interface{}{"partner1"}}},
+		{name: "Quorum Failure", event: events[config.WebhookEventAuctionCompleted][1], expected: map[string]interface{}{"request_id": "quorum", "winners": []interface{}{}, "reason": string(models.ReasonInsufficientCompetition)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, ok := tc.event.Data.(map[string]interface{})
+			require.True(t, ok)
+			for key, expected := range tc.expected {
+				assert.Equal(t, expected, data[key], key)
+			}
+		})
+	}
+
+	// A repeated auction for the same request yields the same event IDs
+	require.Len(t, events[config.WebhookEventBidWon], 2)
+	assert.Equal(t, events[config.WebhookEventBidWon][0].ID, events[config.WebhookEventBidWon][1].ID)
+	assert.NotEqual(t, events[config.WebhookEventAuctionCompleted][0].ID, events[config.WebhookEventAuctionCompleted][1].ID)
+}
+
+// TestBidLostWebhook tests that an endpoint subscribed to bid.lost receives the losing bid of a
+// partner sharing bid guidance, and no other events
+func TestBidLostWebhook(t *testing.T) {
+	winner := newPartnerServer(t, models.Bid{ID: "winner-bid", Price: 10.0, QualityScore: 0.5, ClickURL: "http://example.com/winner"})
+	loser := newPartnerServer(t, models.Bid{ID: "loser-bid", Price: 8.0, QualityScore: 0.5, ClickURL: "http://example.com/loser"})
+	receiver, server := newWebhookReceiver(t, http.StatusOK)
+
+	cfg := newTestConfig(map[string]*config.PartnerConfig{
+		"winner": {ID: "winner", Endpoint: winner.URL, APIKey: "key-winner", Timeout: 200 * time.Millisecond, Enabled: true, ShareBidGuidance: true},
+		"loser":  {ID: "loser", Endpoint: loser.URL, APIKey: "key-loser", Timeout: 200 * time.Millisecond, Enabled: true, ShareBidGuidance: true},
+	})
+	cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: server.URL, Events: []string{config.WebhookEventBidLost}})
+	service, err := services.NewAuctionService(cfg)
+	require.NoError(t, err)
+	defer service.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_, err = service.RunAuction(ctx, &models.BidRequest{RequestID: "lost", LeadID: "lead-lost", Vertical: "auto"})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return len(receiver.received()) == 1 }, 2*time.Second, 5*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	deliveries := receiver.received()
+	require.Len(t, deliveries, 1, "only bid.lost is delivered")
+	event := deliveries[0].event
+	assert.Equal(t, config.WebhookEventBidLost, event.Type)
+	data, ok := event.Data.(map[string]interface{})
+	require.True(t, ok)
+	for key, expected := range map[string]interface{}{"request_id": "lost", "lead_id": "lead-lost", "vertical": "auto", "partner_id": "loser", "bid_id": "loser-bid"} {
+		assert.Equal(t, expected, data[key], key)
+	}
+	assert.NotNil(t, data["price_to_beat"])
+}
+
+// TestWebhookValidation tests webhook endpoint, event, and delivery limit requirements
+func TestWebhookValidation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		modify      func(cfg *config.WebhooksConfig)
+		expectedErr string
+	}{
+		{name: "Valid Webhooks", modify: func(cfg *config.WebhooksConfig) {}},
+		{name: "No Endpoints", modify: func(cfg *config.WebhooksConfig) { cfg.Endpoints = nil }, expectedErr: "webhooks enabled without endpoints"},
+		{name: "Invalid URL", modify: func(cfg *config.WebhooksConfig) { cfg.Endpoints[0].URL = "ftp://example.com" }, expectedErr: "invalid webhook URL"},
+		{name: "Bid Lost Event", modify: func(cfg *config.WebhooksConfig) { cfg.Endpoints[0].Events = []string{config.WebhookEventBidLost} }},
+		{name: "Unknown Event", modify: func(cfg *config.WebhooksConfig) { cfg.Endpoints[0].Events = []string{"bid.expired"} }, expectedErr: "unknown webhook event"},
+		{name: "Zero Attempts", modify: func(cfg *config.WebhooksConfig) { cfg.MaxAttempts = 0 }, expectedErr: "webhook max attempts must be between 1 and 20"},
+		{name: "Backoff Above Max", modify: func(cfg *config.WebhooksConfig) { cfg.BackoffBase = time.Minute }, expectedErr: "webhook backoff base must be positive"},
+		{name: "Missing Dead Letter Path", modify: func(cfg *config.WebhooksConfig) { cfg.DeadLetterPath = "" }, expectedErr: "missing webhook dead letter path"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := newStrategyTestConfig()
+			cfg.Webhooks = newWebhookTestConfig(t, config.WebhookEndpoint{URL: "https://fulfillment.example.com/hooks", Events: []string{config.WebhookEventBidWon}})
+			tc.modify(cfg.Webhooks)
+
+			err := cfg.Validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.expectedErr)
+			}
+		})
+	}
+}